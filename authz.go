@@ -0,0 +1,113 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/skygeario/skygear-server/pkg/server/authzconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+const authzUsage = `usage: skygear-server authz export
+       skygear-server authz import FILE
+
+export prints the authorization configuration as JSON to stdout.
+import applies the authorization configuration in FILE, or stdin if
+FILE is -.`
+
+// runAuthz exports or imports the authorization configuration of the
+// app configured by the environment. It returns the exit status of the
+// command.
+func runAuthz(args []string) int {
+	if len(args) == 0 || (args[0] == "import" && len(args) != 2) ||
+		(args[0] != "import" && args[0] != "export") {
+		fmt.Fprintln(os.Stderr, authzUsage)
+		return 2
+	}
+
+	config := skyconfig.NewConfiguration()
+	config.ReadFromEnv()
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	conn, err := skydb.Open(
+		context.Background(),
+		config.DB.ImplName,
+		config.App.Name,
+		config.App.AccessControl,
+		config.DB.Option,
+		config.App.DevMode,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	if args[0] == "import" {
+		if err := importAuthz(conn, args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to import: %v\n", err)
+			return 1
+		}
+	}
+
+	doc, err := authzconfig.Export(conn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to export: %v\n", err)
+		return 1
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to export: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
+
+func importAuthz(conn skydb.Conn, path string) error {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	doc := authzconfig.Document{}
+	if err := doc.FromMap(m); err != nil {
+		return err
+	}
+	return authzconfig.Apply(conn, &doc)
+}
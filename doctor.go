@@ -0,0 +1,75 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/doctor"
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skygear"
+)
+
+// runDoctor checks the services configured for the server and prints
+// a report to stdout. It returns the exit status of the command.
+func runDoctor() int {
+	config := skyconfig.NewConfiguration()
+	config.ReadFromEnv()
+	if err := config.Validate(); err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	checks := doctor.DatabaseChecks(config)
+	checks = append(checks,
+		doctor.AssetStoreCheck(func() asset.Store {
+			return skygear.NewAssetStore(config)
+		}),
+		doctor.PushCheck("APNS", func() (push.CredentialChecker, error) {
+			if !config.APNS.Enable {
+				return nil, nil
+			}
+			pusher, err := skygear.NewAPNSPusher(config, nil)
+			if err != nil {
+				return nil, err
+			}
+			return pusher.(push.CredentialChecker), nil
+		}),
+		doctor.PushCheck("GCM", func() (push.CredentialChecker, error) {
+			if !config.GCM.Enable {
+				return nil, nil
+			}
+			return skygear.NewGCMPusher(config), nil
+		}),
+	)
+
+	pluginNames := []string{}
+	for name := range config.Plugin {
+		pluginNames = append(pluginNames, name)
+	}
+	sort.Strings(pluginNames)
+	for _, name := range pluginNames {
+		checks = append(checks, doctor.PluginCheck(name, config.Plugin[name]))
+	}
+
+	if !doctor.Run(checks, os.Stdout) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+const migrateUsage = `usage: skygear-server migrate [status] [DIR]
+
+migrate applies the schema migrations in DIR, or DB_MIGRATION_DIR if DIR
+is not specified, that are not yet applied to the record types.
+status prints the migrations to be applied without applying them.`
+
+// runMigrate applies the schema migrations of record types of the app
+// configured by the environment. It returns the exit status of the
+// command.
+func runMigrate(args []string) int {
+	statusOnly := len(args) > 0 && args[0] == "status"
+	if statusOnly {
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	config := skyconfig.NewConfiguration()
+	config.ReadFromEnv()
+	if len(args) == 1 {
+		config.DB.MigrationDir = args[0]
+	}
+	if config.DB.MigrationDir == "" {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	migrations, err := skydb.LoadSchemaMigrations(config.DB.MigrationDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load migrations: %v\n", err)
+		return 1
+	}
+
+	conn, err := skydb.Open(
+		context.Background(),
+		config.DB.ImplName,
+		config.App.Name,
+		config.App.AccessControl,
+		config.DB.Option,
+		config.App.DevMode,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	db := conn.PublicDB()
+	if statusOnly {
+		versions, err := db.SchemaVersions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read schema versions: %v\n", err)
+			return 1
+		}
+		for _, migration := range skydb.PendingSchemaMigrations(migrations, versions) {
+			fmt.Printf("pending %s %s\n", migration.RecordType, migration.Version)
+		}
+		return 0
+	}
+
+	applied, err := skydb.MigrateSchemas(db, migrations)
+	for _, migration := range applied {
+		fmt.Printf("applied %s %s\n", migration.RecordType, migration.Version)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/skygeario/skygear-server/pkg/server/assetmigration"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skygear"
+)
+
+const migrateAssetsUsage = `usage: skygear-server migrate-assets [-progress FILE] [-replace-urls] TARGET
+
+migrate-assets copies all assets from the asset store configured by the
+environment to the asset store configured in the JSON file TARGET, in the
+format of the "asset_store" configuration, with "fs.path" for the
+directory of the fs store. The checksum of each copied file is verified.
+
+Migrated assets are recorded in the progress FILE, asset-migration.progress
+by default, and are skipped when the command is run again. Run it again
+after switching the server to the target store to copy the assets uploaded
+during the migration.
+
+-replace-urls replaces the URL prefix of the source store with that of the
+target store in string fields of records after all assets are copied.`
+
+// runMigrateAssets copies the assets of the app configured by the
+// environment to another asset store. It returns the exit status of the
+// command.
+func runMigrateAssets(args []string) int {
+	flags := flag.NewFlagSet("migrate-assets", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, migrateAssetsUsage)
+	}
+	progressPath := flags.String("progress", "asset-migration.progress", "")
+	replaceURLs := flags.Bool("replace-urls", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	config := skyconfig.NewConfiguration()
+	config.ReadFromEnv()
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	targetConfig, err := readAssetStoreConfig(config, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read target: %v\n", err)
+		return 1
+	}
+
+	progress, err := assetmigration.OpenProgress(*progressPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open progress: %v\n", err)
+		return 1
+	}
+	defer progress.Close()
+
+	conn, err := skydb.Open(
+		context.Background(),
+		config.DB.ImplName,
+		config.App.Name,
+		config.App.AccessControl,
+		config.DB.Option,
+		config.App.DevMode,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	m := &assetmigration.Migrator{
+		Conn:        conn,
+		Source:      skygear.NewAssetStore(config),
+		Destination: skygear.NewAssetStore(targetConfig),
+		Progress:    progress,
+	}
+	if *replaceURLs {
+		m.URLPrefix = assetStoreURLPrefix(config)
+		m.NewURLPrefix = assetStoreURLPrefix(targetConfig)
+		if m.URLPrefix == "" || m.NewURLPrefix == "" {
+			fmt.Fprintln(os.Stderr, "-replace-urls requires the URL prefixes of both asset stores")
+			return 1
+		}
+	}
+
+	result, err := m.Run()
+	if result != nil {
+		fmt.Printf("migrated %d, skipped %d, failed %d\n", result.Migrated, result.Skipped, len(result.Failed))
+
+		names := make([]string, 0, len(result.Failed))
+		for name := range result.Failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("failed %s: %v\n", name, result.Failed[name])
+		}
+
+		recordTypes := make([]string, 0, len(result.URLsReplaced))
+		for recordType := range result.URLsReplaced {
+			recordTypes = append(recordTypes, recordType)
+		}
+		sort.Strings(recordTypes)
+		for _, recordType := range recordTypes {
+			fmt.Printf("replaced URLs of %d %s records\n", result.URLsReplaced[recordType], recordType)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	if len(result.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readAssetStoreConfig returns a copy of config with the asset store
+// replaced by the one in the JSON file at path.
+func readAssetStoreConfig(config skyconfig.Configuration, path string) (skyconfig.Configuration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, &config.AssetStore); err != nil {
+		return config, err
+	}
+
+	// path of the fs store is not read from JSON by the configuration
+	fs := struct {
+		FS struct {
+			Path string `json:"path"`
+		} `json:"fs"`
+	}{}
+	if err := json.Unmarshal(data, &fs); err != nil {
+		return config, err
+	}
+	if fs.FS.Path != "" {
+		config.AssetStore.FileSystemStore.Path = fs.FS.Path
+	}
+	return config, nil
+}
+
+// assetStoreURLPrefix returns the URL prefix of the public URLs of the
+// configured asset store.
+func assetStoreURLPrefix(config skyconfig.Configuration) string {
+	var prefix string
+	switch config.AssetStore.ImplName {
+	case "fs":
+		prefix = config.AssetStore.FileSystemStore.URLPrefix
+	case "s3":
+		prefix = config.AssetStore.S3Store.URLPrefix
+	case "cloud":
+		prefix = config.AssetStore.CloudStore.PublicPrefix
+	}
+	if prefix == "" || prefix[len(prefix)-1] == '/' {
+		return prefix
+	}
+	return prefix + "/"
+}
@@ -36,6 +36,11 @@ type Store interface {
 	GeneratePostFileRequest(name string) (*PostFileRequest, error)
 }
 
+// Deleter is implemented by a Store which can delete files.
+type Deleter interface {
+	DeleteFile(name string) error
+}
+
 // URLSigner signs a signature and returns a URL accessible to that asset.
 type URLSigner interface {
 	// SignedURL returns a url with access to the named file. If asset
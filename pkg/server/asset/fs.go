@@ -76,6 +76,12 @@ func (s *fileStore) PutFileReader(name string, src io.Reader, length int64, cont
 	return nil
 }
 
+// DeleteFile removes a file from file system
+func (s *fileStore) DeleteFile(name string) error {
+	path := filepath.Join(s.dir, name)
+	return os.Remove(path)
+}
+
 // GeneratePostFileRequest return a PostFileRequest for uploading asset
 func (s *fileStore) GeneratePostFileRequest(name string) (*PostFileRequest, error) {
 	return &PostFileRequest{
@@ -80,6 +80,11 @@ func (s *s3Store) PutFileReader(
 	return s.bucket.PutReader(name, src, length, contentType, s3.Private)
 }
 
+// DeleteFile deletes a file from s3
+func (s *s3Store) DeleteFile(name string) error {
+	return s.bucket.Del(name)
+}
+
 // GeneratePostFileRequest return a PostFileRequest for uploading asset
 func (s *s3Store) GeneratePostFileRequest(name string) (*PostFileRequest, error) {
 	return &PostFileRequest{
@@ -0,0 +1,308 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package assetjob runs bulk operations on assets in background, such as
+// copying or moving assets to another prefix when the layout of the asset
+// store is restructured.
+package assetjob
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+var log = logging.LoggerEntry("assetjob")
+
+var timeNow = func() time.Time { return time.Now().UTC() }
+
+// Operations of a Job.
+const (
+	// Copy copies the files and information of assets to new names.
+	Copy = "copy"
+
+	// Move renames assets, updating the records referencing them, and
+	// deletes the files of the old names.
+	Move = "move"
+
+	// Delete deletes assets not referenced by any records.
+	Delete = "delete"
+
+	// Sign signs the URLs of assets again, such as after the URL
+	// prefix or signing secret of the asset store is changed.
+	Sign = "sign"
+)
+
+// Statuses of a Job.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+)
+
+// Retention is how long a completed Job is kept by the Runner.
+const Retention = 24 * time.Hour
+
+// Item is an asset operated by a Job. NewName is the name the asset is
+// copied or moved to.
+type Item struct {
+	Name    string `json:"name"`
+	NewName string `json:"new_name,omitempty"`
+}
+
+// Result is the outcome of operating an Item. URL is the signed URL of
+// the asset after the operation if requested.
+type Result struct {
+	Name    string       `json:"name"`
+	NewName string       `json:"new_name,omitempty"`
+	URL     string       `json:"url,omitempty"`
+	Error   skyerr.Error `json:"error,omitempty"`
+}
+
+// Job is a bulk operation on assets.
+type Job struct {
+	ID          string     `json:"id"`
+	Operation   string     `json:"operation"`
+	Status      string     `json:"status"`
+	Sign        bool       `json:"sign"`
+	Total       int        `json:"total"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Results     []Result   `json:"results"`
+
+	items []Item
+}
+
+// Runner runs Jobs in background, and keeps them in memory so that their
+// results can be retrieved.
+type Runner struct {
+	ConnOpener func() (skydb.Conn, error)
+	Store      asset.Store
+
+	mutex sync.Mutex
+	jobs  map[string]*Job
+}
+
+// NewRunner returns a Runner operating assets in the store.
+func NewRunner(connOpener func() (skydb.Conn, error), store asset.Store) *Runner {
+	return &Runner{
+		ConnOpener: connOpener,
+		Store:      store,
+		jobs:       map[string]*Job{},
+	}
+}
+
+// Start starts a Job running the operation on the items in background. If
+// sign is true, the results contain the signed URLs of the assets.
+func (r *Runner) Start(operation string, items []Item, sign bool) (Job, error) {
+	switch operation {
+	case Copy, Move:
+		for _, item := range items {
+			if item.NewName == "" || item.NewName == item.Name {
+				return Job{}, fmt.Errorf("assetjob: invalid new name of asset %s", item.Name)
+			}
+		}
+	case Delete:
+	case Sign:
+		sign = true
+	default:
+		return Job{}, fmt.Errorf("assetjob: unknown operation %s", operation)
+	}
+
+	if _, ok := r.Store.(asset.URLSigner); sign && !ok {
+		return Job{}, fmt.Errorf("assetjob: asset store cannot sign URLs")
+	}
+	if _, ok := r.Store.(asset.Deleter); !ok && (operation == Move || operation == Delete) {
+		return Job{}, fmt.Errorf("assetjob: asset store cannot delete files")
+	}
+
+	job := &Job{
+		ID:        uuid.New(),
+		Operation: operation,
+		Status:    StatusRunning,
+		Sign:      sign,
+		Total:     len(items),
+		CreatedAt: timeNow(),
+		Results:   []Result{},
+		items:     items,
+	}
+
+	r.mutex.Lock()
+	r.removeExpiredJobs()
+	r.jobs[job.ID] = job
+	snapshot := job.snapshot()
+	r.mutex.Unlock()
+
+	go r.run(job)
+	return snapshot, nil
+}
+
+// Get returns the Job of the ID.
+func (r *Runner) Get(id string) (Job, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// removeExpiredJobs removes the Jobs completed longer than Retention ago.
+// It must be called with the mutex held.
+func (r *Runner) removeExpiredJobs() {
+	now := timeNow()
+	for id, job := range r.jobs {
+		if job.CompletedAt != nil && now.Sub(*job.CompletedAt) > Retention {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+func (r *Runner) run(job *Job) {
+	logger := log.WithField("job", job.ID)
+
+	conn, err := r.ConnOpener()
+	if err != nil {
+		logger.WithError(err).Errorln("assetjob: failed to open skydb.Conn")
+	} else {
+		defer conn.Close()
+	}
+
+	for _, item := range job.items {
+		result := Result{Name: item.Name, NewName: item.NewName}
+		if conn == nil {
+			result.Error = skyerr.MakeError(err)
+		} else if err := r.operate(conn, job, item, &result); err != nil {
+			logger.WithError(err).WithField("asset", item.Name).Warnln("assetjob: failed to operate asset")
+			result.Error = makeError(err)
+		}
+
+		r.mutex.Lock()
+		job.Results = append(job.Results, result)
+		r.mutex.Unlock()
+	}
+
+	r.mutex.Lock()
+	completedAt := timeNow()
+	job.Status = StatusCompleted
+	job.CompletedAt = &completedAt
+	r.mutex.Unlock()
+}
+
+func (r *Runner) operate(conn skydb.Conn, job *Job, item Item, result *Result) error {
+	name := item.Name
+	switch job.Operation {
+	case Copy:
+		if err := r.copy(conn, item); err != nil {
+			return err
+		}
+		name = item.NewName
+	case Move:
+		if err := r.move(conn, item); err != nil {
+			return err
+		}
+		name = item.NewName
+	case Delete:
+		if err := r.delete(conn, item); err != nil {
+			return err
+		}
+	case Sign:
+		info := skydb.Asset{}
+		if err := conn.GetAsset(name, &info); err != nil {
+			return skydb.ErrAssetNotFound
+		}
+	}
+
+	if job.Sign && job.Operation != Delete {
+		url, err := r.Store.(asset.URLSigner).SignedURL(name)
+		if err != nil {
+			return err
+		}
+		result.URL = url
+	}
+	return nil
+}
+
+// copyFile copies the file of the item in the store and returns the
+// information of the asset copied.
+func (r *Runner) copyFile(conn skydb.Conn, item Item) (*skydb.Asset, error) {
+	asset := skydb.Asset{}
+	if err := conn.GetAsset(item.Name, &asset); err != nil {
+		return nil, skydb.ErrAssetNotFound
+	}
+
+	reader, err := r.Store.GetFileReader(item.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if err := r.Store.PutFileReader(item.NewName, reader, asset.Size, asset.ContentType); err != nil {
+		return nil, err
+	}
+
+	asset.Name = item.NewName
+	return &asset, nil
+}
+
+func (r *Runner) copy(conn skydb.Conn, item Item) error {
+	asset, err := r.copyFile(conn, item)
+	if err != nil {
+		return err
+	}
+	return conn.SaveAsset(asset)
+}
+
+// move copies the file to the new name before renaming the asset, so that
+// records are not referencing a missing file if the copy fails. The old
+// file is deleted after the records are updated.
+func (r *Runner) move(conn skydb.Conn, item Item) error {
+	if _, err := r.copyFile(conn, item); err != nil {
+		return err
+	}
+	if err := conn.RenameAsset(item.Name, item.NewName); err != nil {
+		return err
+	}
+	return r.Store.(asset.Deleter).DeleteFile(item.Name)
+}
+
+// delete deletes the information of the asset before the file, which
+// fails if the asset is referenced by records.
+func (r *Runner) delete(conn skydb.Conn, item Item) error {
+	if err := conn.DeleteAsset(item.Name); err != nil {
+		return err
+	}
+	return r.Store.(asset.Deleter).DeleteFile(item.Name)
+}
+
+func makeError(err error) skyerr.Error {
+	if err == skydb.ErrAssetNotFound {
+		return skyerr.NewError(skyerr.ResourceNotFound, "asset not found")
+	}
+	return skyerr.MakeError(err)
+}
+
+func (job *Job) snapshot() Job {
+	snapshot := *job
+	snapshot.Results = append([]Result{}, job.Results...)
+	snapshot.items = nil
+	return snapshot
+}
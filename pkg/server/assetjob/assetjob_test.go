@@ -0,0 +1,131 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assetjob
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func waitJob(r *Runner, id string) Job {
+	for i := 0; i < 100; i++ {
+		if job, ok := r.Get(id); ok && job.Status == StatusCompleted {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	panic("job is not completed")
+}
+
+func TestRunner(t *testing.T) {
+	Convey("Runner", t, func() {
+		dir, err := ioutil.TempDir("", "assetjob")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		store := asset.NewFileStore(dir, "http://skygear.dev/files", "secret", true)
+		So(store.PutFileReader("images/a.png", strings.NewReader("a"), 1, "image/png"), ShouldBeNil)
+
+		conn := skydbtest.NewMapConn()
+		So(conn.SaveAsset(&skydb.Asset{
+			Name:        "images/a.png",
+			ContentType: "image/png",
+			Size:        1,
+		}), ShouldBeNil)
+
+		r := NewRunner(func() (skydb.Conn, error) { return conn, nil }, store)
+
+		Convey("copies assets", func() {
+			job, err := r.Start(Copy, []Item{
+				{Name: "images/a.png", NewName: "photos/a.png"},
+				{Name: "images/b.png", NewName: "photos/b.png"},
+			}, true)
+			So(err, ShouldBeNil)
+			So(job.Status, ShouldEqual, StatusRunning)
+			So(job.Total, ShouldEqual, 2)
+
+			job = waitJob(r, job.ID)
+			So(job.Results, ShouldHaveLength, 2)
+			So(job.Results[0].Error, ShouldBeNil)
+			So(job.Results[0].URL, ShouldEqual, "http://skygear.dev/files/photos/a.png")
+			So(job.Results[1].Error.Code(), ShouldEqual, skyerr.ResourceNotFound)
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, "photos/a.png"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "a")
+			So(conn.AssetMap, ShouldContainKey, "images/a.png")
+			So(conn.AssetMap, ShouldContainKey, "photos/a.png")
+		})
+
+		Convey("moves assets", func() {
+			job, err := r.Start(Move, []Item{
+				{Name: "images/a.png", NewName: "photos/a.png"},
+			}, false)
+			So(err, ShouldBeNil)
+
+			job = waitJob(r, job.ID)
+			So(job.Results, ShouldResemble, []Result{
+				{Name: "images/a.png", NewName: "photos/a.png"},
+			})
+
+			_, err = os.Stat(filepath.Join(dir, "images/a.png"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+			_, err = os.Stat(filepath.Join(dir, "photos/a.png"))
+			So(err, ShouldBeNil)
+			So(conn.AssetMap, ShouldNotContainKey, "images/a.png")
+			So(conn.AssetMap["photos/a.png"].ContentType, ShouldEqual, "image/png")
+		})
+
+		Convey("deletes assets", func() {
+			job, err := r.Start(Delete, []Item{{Name: "images/a.png"}}, false)
+			So(err, ShouldBeNil)
+
+			job = waitJob(r, job.ID)
+			So(job.Results[0].Error, ShouldBeNil)
+			So(conn.AssetMap, ShouldBeEmpty)
+			_, err = os.Stat(filepath.Join(dir, "images/a.png"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("signs asset URLs", func() {
+			job, err := r.Start(Sign, []Item{{Name: "images/a.png"}}, false)
+			So(err, ShouldBeNil)
+
+			job = waitJob(r, job.ID)
+			So(job.Results[0].URL, ShouldEqual, "http://skygear.dev/files/images/a.png")
+		})
+
+		Convey("rejects copying without new name", func() {
+			_, err := r.Start(Copy, []Item{{Name: "images/a.png"}}, false)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("returns no job of unknown ID", func() {
+			_, ok := r.Get("unknown")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,281 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package assetmigration copies the files of all assets from one asset
+// store to another, so that the asset store of an app can be switched
+// without downtime.
+//
+// The server keeps serving assets from the source store during the
+// migration. Migrated assets are recorded in a Progress file, so that the
+// migration can be resumed, and run again to copy the assets uploaded in
+// the meantime before and after the server is switched to the
+// destination store.
+package assetmigration
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+var log = logging.LoggerEntry("assetmigration")
+
+// Progress records the names of the assets migrated in a file, one
+// quoted name per line.
+type Progress struct {
+	file     *os.File
+	migrated map[string]bool
+}
+
+// OpenProgress reads the assets migrated from the file at path, which
+// is created if not exists. Assets migrated afterwards are appended to
+// the file.
+func OpenProgress(path string) (*Progress, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			// the last line is incomplete if the migration is
+			// interrupted while writing it
+			continue
+		}
+		migrated[name] = true
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Progress{
+		file:     file,
+		migrated: migrated,
+	}, nil
+}
+
+// IsMigrated returns whether the asset is migrated.
+func (p *Progress) IsMigrated(name string) bool {
+	return p.migrated[name]
+}
+
+// Len returns the number of assets migrated.
+func (p *Progress) Len() int {
+	return len(p.migrated)
+}
+
+// MarkMigrated records the asset as migrated.
+func (p *Progress) MarkMigrated(name string) error {
+	if p.migrated[name] {
+		return nil
+	}
+	if _, err := fmt.Fprintln(p.file, strconv.Quote(name)); err != nil {
+		return err
+	}
+	p.migrated[name] = true
+	return nil
+}
+
+// Close closes the file of the progress.
+func (p *Progress) Close() error {
+	return p.file.Close()
+}
+
+// Result is the outcome of a migration.
+type Result struct {
+	// Migrated is the number of assets copied in this run.
+	Migrated int
+
+	// Skipped is the number of assets migrated in previous runs.
+	Skipped int
+
+	// Failed maps the names of assets failed to be copied to the errors.
+	Failed map[string]error
+
+	// URLsReplaced maps record types to the number of records of which
+	// the URLs are replaced.
+	URLsReplaced map[string]int64
+}
+
+// Migrator copies the files of the assets saved in Conn from Source to
+// Destination.
+//
+// If URLPrefix and NewURLPrefix are set, URLs of the source store saved
+// in string fields of records are replaced by URLs of the destination
+// store after all assets are copied.
+type Migrator struct {
+	Conn         skydb.Conn
+	Source       asset.Store
+	Destination  asset.Store
+	Progress     *Progress
+	URLPrefix    string
+	NewURLPrefix string
+}
+
+// Run migrates the assets not yet migrated according to the Progress.
+// Assets failed to be copied are reported in the result, and are copied
+// again when the migration is resumed. URLs are not replaced if any of
+// the assets failed, so that records are not referencing missing files.
+func (m *Migrator) Run() (*Result, error) {
+	names, err := m.Conn.GetAssetNames("")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Failed:       map[string]error{},
+		URLsReplaced: map[string]int64{},
+	}
+	for _, name := range names {
+		if m.Progress.IsMigrated(name) {
+			result.Skipped++
+			continue
+		}
+
+		if err := m.migrate(name); err != nil {
+			log.WithError(err).WithField("asset", name).Warnln("assetmigration: failed to migrate asset")
+			result.Failed[name] = err
+			continue
+		}
+		if err := m.Progress.MarkMigrated(name); err != nil {
+			return result, fmt.Errorf("failed to save progress: %v", err)
+		}
+		result.Migrated++
+	}
+
+	if len(result.Failed) > 0 || m.URLPrefix == "" || m.NewURLPrefix == "" {
+		return result, nil
+	}
+
+	if err := m.replaceURLs(result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// migrate copies the file of the asset, and verifies the checksum of the
+// file read back from the destination store.
+func (m *Migrator) migrate(name string) error {
+	info := skydb.Asset{}
+	if err := m.Conn.GetAsset(name, &info); err != nil {
+		return err
+	}
+
+	reader, err := m.Source.GetFileReader(name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	source := newChecksumReader(reader)
+	if err := m.Destination.PutFileReader(name, source, info.Size, info.ContentType); err != nil {
+		return err
+	}
+	if source.size != info.Size {
+		return fmt.Errorf("size of source file is %d, expected %d", source.size, info.Size)
+	}
+
+	copied, err := m.Destination.GetFileReader(name)
+	if err != nil {
+		return err
+	}
+	defer copied.Close()
+
+	destination := newChecksumReader(copied)
+	if _, err := io.Copy(ioutil.Discard, destination); err != nil {
+		return err
+	}
+	if !bytes.Equal(source.Sum(), destination.Sum()) {
+		return fmt.Errorf("checksum mismatch: source %x, destination %x", source.Sum(), destination.Sum())
+	}
+	return nil
+}
+
+// replaceURLs replaces the URL prefix in all string fields of all record
+// types.
+func (m *Migrator) replaceURLs(result *Result) error {
+	schemas, err := m.Conn.PublicDB().GetRecordSchemas()
+	if err != nil {
+		return err
+	}
+
+	recordTypes := make([]string, 0, len(schemas))
+	for recordType := range schemas {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+
+	for _, recordType := range recordTypes {
+		fields := []string{}
+		for field, fieldType := range schemas[recordType] {
+			if fieldType.Type == skydb.TypeString && !strings.HasPrefix(field, "_") {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		sort.Strings(fields)
+
+		count, err := m.Conn.ReplaceURLPrefix(recordType, fields, m.URLPrefix, m.NewURLPrefix)
+		if err != nil {
+			return fmt.Errorf("failed to replace URLs of %s: %v", recordType, err)
+		}
+		if count > 0 {
+			result.URLsReplaced[recordType] = count
+		}
+	}
+	return nil
+}
+
+// checksumReader computes the checksum and size of the content read.
+type checksumReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	size   int64
+}
+
+func newChecksumReader(reader io.Reader) *checksumReader {
+	h := sha256.New()
+	return &checksumReader{
+		reader: io.TeeReader(reader, h),
+		hash:   h,
+	}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *checksumReader) Sum() []byte {
+	return r.hash.Sum(nil)
+}
@@ -0,0 +1,133 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package assetmigration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+)
+
+type replaceURLConn struct {
+	*skydbtest.MapConn
+	replaced map[string][]string
+}
+
+func (conn *replaceURLConn) ReplaceURLPrefix(recordType string, fields []string, oldPrefix, newPrefix string) (int64, error) {
+	conn.replaced[recordType] = fields
+	return 1, nil
+}
+
+func TestMigrator(t *testing.T) {
+	Convey("Migrator", t, func() {
+		dir, err := ioutil.TempDir("", "assetmigration")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		source := asset.NewFileStore(filepath.Join(dir, "source"), "http://old.dev/files", "", true)
+		destination := asset.NewFileStore(filepath.Join(dir, "destination"), "https://cdn.dev", "", true)
+
+		db := skydbtest.NewMapDB()
+		db.Extend("note", skydb.RecordSchema{
+			"link":  skydb.FieldType{Type: skydb.TypeString},
+			"count": skydb.FieldType{Type: skydb.TypeInteger},
+		})
+		conn := &replaceURLConn{
+			MapConn:  skydbtest.NewMapConn(),
+			replaced: map[string][]string{},
+		}
+		conn.InternalPublicDB = db
+
+		for _, name := range []string{"a.png", "b.png"} {
+			So(source.PutFileReader(name, strings.NewReader(name), int64(len(name)), "image/png"), ShouldBeNil)
+			So(conn.SaveAsset(&skydb.Asset{Name: name, ContentType: "image/png", Size: int64(len(name))}), ShouldBeNil)
+		}
+
+		progressPath := filepath.Join(dir, "progress")
+		progress, err := OpenProgress(progressPath)
+		So(err, ShouldBeNil)
+		defer progress.Close()
+
+		m := &Migrator{
+			Conn:         conn,
+			Source:       source,
+			Destination:  destination,
+			Progress:     progress,
+			URLPrefix:    "http://old.dev/files/",
+			NewURLPrefix: "https://cdn.dev/",
+		}
+
+		Convey("copies assets and replaces URLs", func() {
+			result, err := m.Run()
+			So(err, ShouldBeNil)
+			So(result.Migrated, ShouldEqual, 2)
+			So(result.Failed, ShouldBeEmpty)
+			So(result.URLsReplaced, ShouldResemble, map[string]int64{"note": 1})
+			So(conn.replaced, ShouldResemble, map[string][]string{"note": {"link"}})
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, "destination", "b.png"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "b.png")
+		})
+
+		Convey("resumes from progress", func() {
+			So(progress.MarkMigrated("a.png"), ShouldBeNil)
+			So(progress.Close(), ShouldBeNil)
+
+			progress, err := OpenProgress(progressPath)
+			So(err, ShouldBeNil)
+			defer progress.Close()
+			So(progress.IsMigrated("a.png"), ShouldBeTrue)
+			m.Progress = progress
+
+			result, err := m.Run()
+			So(err, ShouldBeNil)
+			So(result.Migrated, ShouldEqual, 1)
+			So(result.Skipped, ShouldEqual, 1)
+			So(progress.Len(), ShouldEqual, 2)
+
+			_, err = os.Stat(filepath.Join(dir, "destination", "a.png"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("reports assets with mismatched size and does not replace URLs", func() {
+			So(conn.SaveAsset(&skydb.Asset{Name: "a.png", ContentType: "image/png", Size: 100}), ShouldBeNil)
+
+			result, err := m.Run()
+			So(err, ShouldBeNil)
+			So(result.Migrated, ShouldEqual, 1)
+			So(result.Failed, ShouldContainKey, "a.png")
+			So(conn.replaced, ShouldBeEmpty)
+			So(progress.IsMigrated("a.png"), ShouldBeFalse)
+		})
+
+		Convey("reports missing source files", func() {
+			So(conn.SaveAsset(&skydb.Asset{Name: "c.png", ContentType: "image/png", Size: 1}), ShouldBeNil)
+
+			result, err := m.Run()
+			So(err, ShouldBeNil)
+			So(result.Migrated, ShouldEqual, 2)
+			So(result.Failed, ShouldContainKey, "c.png")
+		})
+	})
+}
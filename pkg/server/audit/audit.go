@@ -0,0 +1,157 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit emits structured auth events, such as logins and role
+// changes, to sinks like syslog, Kafka and webhooks, so that they can be
+// fed into security monitoring systems.
+package audit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+var log = logging.LoggerEntry("audit")
+
+var timeNow = time.Now
+
+// SchemaVersion is the version of the schema of Event. It is increased
+// when a field is removed or its meaning is changed.
+const SchemaVersion = 1
+
+// Types of the auth events.
+const (
+	Signup          = "auth.signup"
+	LoginSucceeded  = "auth.login.succeeded"
+	LoginFailed     = "auth.login.failed"
+	TokenIssued     = "auth.token.issued"
+	TokenRevoked    = "auth.token.revoked"
+	PasswordChanged = "auth.password.changed"
+	RolesAssigned   = "auth.roles.assigned"
+	RolesRevoked    = "auth.roles.revoked"
+)
+
+// Event is an auth event.
+//
+// UserID is the user the event is about, and ActorID is the user who
+// performed the action, which is different from UserID when an admin
+// changes the roles of a user.
+type Event struct {
+	SchemaVersion int                    `json:"schema_version"`
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	Timestamp     time.Time              `json:"timestamp"`
+	AppName       string                 `json:"app_name"`
+	UserID        string                 `json:"user_id,omitempty"`
+	ActorID       string                 `json:"actor_id,omitempty"`
+	RemoteAddr    string                 `json:"remote_addr,omitempty"`
+	UserAgent     string                 `json:"user_agent,omitempty"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+}
+
+// IsFailure returns true if the event is about a failed attempt.
+func (e Event) IsFailure() bool {
+	return e.Type == LoginFailed
+}
+
+// Sink writes auth events to an external system.
+type Sink interface {
+	Write(event Event) error
+}
+
+// Stream queues auth events and writes them to the sinks.
+type Stream struct {
+	AppName string
+	Sinks   []Sink
+	events  chan Event
+}
+
+// NewStream returns a Stream queuing at most queueSize events.
+func NewStream(appName string, queueSize int, sinks ...Sink) *Stream {
+	return &Stream{
+		AppName: appName,
+		Sinks:   sinks,
+		events:  make(chan Event, queueSize),
+	}
+}
+
+// Emit fills in the common fields of the event, including the remote
+// address and the user agent of the request, and queues it to be written.
+// The event is dropped if the queue is full.
+//
+// It is a no-op on a nil Stream or a Stream without sinks.
+func (s *Stream) Emit(req *http.Request, event Event) {
+	if s == nil || len(s.Sinks) == 0 {
+		return
+	}
+
+	event.SchemaVersion = SchemaVersion
+	event.ID = uuid.New()
+	event.Timestamp = timeNow().UTC()
+	event.AppName = s.AppName
+	if req != nil {
+		event.RemoteAddr = remoteAddr(req)
+		event.UserAgent = req.UserAgent()
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		log.WithField("type", event.Type).Warnln("audit: queue is full, dropping event")
+	}
+}
+
+// Run writes the queued events until the Stream is closed.
+func (s *Stream) Run() {
+	for event := range s.events {
+		s.write(event)
+	}
+}
+
+// Close stops the Stream from writing events.
+func (s *Stream) Close() {
+	close(s.events)
+}
+
+func (s *Stream) write(event Event) {
+	for _, sink := range s.Sinks {
+		if err := sink.Write(event); err != nil {
+			log.WithFields(logrus.Fields{
+				"type": event.Type,
+				"id":   event.ID,
+			}).WithError(err).Warnln("audit: failed to write event")
+		}
+	}
+}
+
+// remoteAddr returns the address of the client, preferring the first
+// address in X-Forwarded-For set by a reverse proxy.
+func remoteAddr(req *http.Request) string {
+	if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,190 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/webhook"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type memorySink struct {
+	events []Event
+	err    error
+}
+
+func (s *memorySink) Write(event Event) error {
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestStream(t *testing.T) {
+	Convey("Stream", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		sink := &memorySink{}
+		failingSink := &memorySink{err: errors.New("sink is down")}
+		stream := NewStream("myapp", 10, failingSink, sink)
+
+		Convey("fills in common fields and writes to all sinks", func() {
+			req, _ := http.NewRequest("POST", "http://localhost:3000/", nil)
+			req.RemoteAddr = "192.0.2.1:54321"
+			req.Header.Set("User-Agent", "skygear-js")
+
+			stream.Emit(req, Event{
+				Type:   LoginSucceeded,
+				UserID: "user-1",
+			})
+			stream.Close()
+			stream.Run()
+
+			So(failingSink.events, ShouldHaveLength, 1)
+			So(sink.events, ShouldHaveLength, 1)
+			event := sink.events[0]
+			So(event.ID, ShouldNotBeEmpty)
+			event.ID = ""
+			So(event, ShouldResemble, Event{
+				SchemaVersion: 1,
+				Type:          LoginSucceeded,
+				Timestamp:     time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+				AppName:       "myapp",
+				UserID:        "user-1",
+				RemoteAddr:    "192.0.2.1",
+				UserAgent:     "skygear-js",
+			})
+		})
+
+		Convey("prefers forwarded address", func() {
+			req, _ := http.NewRequest("POST", "http://localhost:3000/", nil)
+			req.RemoteAddr = "10.0.0.1:54321"
+			req.Header.Set("X-Forwarded-For", "198.51.100.7, 10.0.0.1")
+
+			stream.Emit(req, Event{Type: LoginFailed})
+			stream.Close()
+			stream.Run()
+
+			So(sink.events[0].RemoteAddr, ShouldEqual, "198.51.100.7")
+		})
+
+		Convey("drops events when the queue is full", func() {
+			stream := NewStream("myapp", 1, sink)
+			stream.Emit(nil, Event{Type: TokenIssued})
+			stream.Emit(nil, Event{Type: TokenRevoked})
+			stream.Close()
+			stream.Run()
+
+			So(sink.events, ShouldHaveLength, 1)
+			So(sink.events[0].Type, ShouldEqual, TokenIssued)
+		})
+
+		Convey("is no-op without sinks", func() {
+			var nilStream *Stream
+			So(func() { nilStream.Emit(nil, Event{Type: Signup}) }, ShouldNotPanic)
+
+			stream := NewStream("myapp", 0)
+			So(func() { stream.Emit(nil, Event{Type: Signup}) }, ShouldNotPanic)
+		})
+	})
+}
+
+type capturedRequest struct {
+	path        string
+	contentType string
+	signature   string
+	body        []byte
+}
+
+func newCaptureServer(requests *[]capturedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*requests = append(*requests, capturedRequest{
+			path:        r.URL.Path,
+			contentType: r.Header.Get("Content-Type"),
+			signature:   r.Header.Get(webhook.SignatureHeader),
+			body:        body,
+		})
+	}))
+}
+
+func TestSinks(t *testing.T) {
+	event := Event{
+		SchemaVersion: 1,
+		ID:            "event-1",
+		Type:          RolesAssigned,
+		Timestamp:     time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		AppName:       "myapp",
+		UserID:        "user-1",
+		ActorID:       "admin",
+		Data: map[string]interface{}{
+			"roles": []interface{}{"admin"},
+		},
+	}
+
+	Convey("KafkaSink", t, func() {
+		requests := []capturedRequest{}
+		server := newCaptureServer(&requests)
+		defer server.Close()
+
+		sink := &KafkaSink{URL: server.URL + "/", Topic: "auth-events"}
+		So(sink.Write(event), ShouldBeNil)
+
+		So(requests, ShouldHaveLength, 1)
+		So(requests[0].path, ShouldEqual, "/topics/auth-events")
+		So(requests[0].contentType, ShouldEqual, "application/vnd.kafka.json.v2+json")
+		So(string(requests[0].body), ShouldEqual, `{"records":[{"key":"user-1","value":`+
+			`{"schema_version":1,"id":"event-1","type":"auth.roles.assigned","timestamp":"2006-01-02T15:04:05Z",`+
+			`"app_name":"myapp","user_id":"user-1","actor_id":"admin","data":{"roles":["admin"]}}}]}`)
+	})
+
+	Convey("WebhookSink", t, func() {
+		requests := []capturedRequest{}
+		server := newCaptureServer(&requests)
+		defer server.Close()
+
+		sink := &WebhookSink{URL: server.URL + "/auth", Secret: "secret"}
+		So(sink.Write(event), ShouldBeNil)
+
+		So(requests, ShouldHaveLength, 1)
+		So(requests[0].path, ShouldEqual, "/auth")
+		So(requests[0].contentType, ShouldEqual, "application/json")
+		So(requests[0].signature, ShouldEqual, webhook.Sign("secret", requests[0].body))
+
+		received := Event{}
+		So(json.Unmarshal(requests[0].body, &received), ShouldBeNil)
+		So(received.Type, ShouldEqual, RolesAssigned)
+		So(received.ActorID, ShouldEqual, "admin")
+	})
+
+	Convey("WebhookSink reports error status", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		sink := &WebhookSink{URL: server.URL}
+		So(sink.Write(event), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"net/http"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/webhook"
+)
+
+// SyslogSink writes auth events as JSON to syslog with the auth facility.
+// Failed attempts are written with the warning severity.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog server at raddr. If network is
+// empty, it connects to the local syslog server.
+func NewSyslogSink(network, raddr, tag string) (*SyslogSink, error) {
+	writer, err := syslog.Dial(network, raddr, syslog.LOG_AUTH|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{writer}, nil
+}
+
+// Write implements Sink.
+func (s *SyslogSink) Write(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.IsFailure() {
+		return s.writer.Warning(string(body))
+	}
+	return s.writer.Info(string(body))
+}
+
+// KafkaSink writes auth events to a Kafka topic through a Kafka REST
+// Proxy. Events are keyed by the user ID, so that events of a user are
+// kept in order.
+type KafkaSink struct {
+	URL    string
+	Topic  string
+	Client *http.Client
+}
+
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key,omitempty"`
+	Value Event  `json:"value"`
+}
+
+// Write implements Sink.
+func (s *KafkaSink) Write(event Event) error {
+	body, err := json.Marshal(kafkaRecords{
+		Records: []kafkaRecord{{Key: event.UserID, Value: event}},
+	})
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimRight(s.URL, "/") + "/topics/" + s.Topic
+	return post(s.Client, url, "application/vnd.kafka.json.v2+json", body, nil)
+}
+
+// WebhookSink posts auth events as JSON to a URL. If Secret is set, the
+// body is signed in the same way as webhook deliveries.
+type WebhookSink struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Write implements Sink.
+func (s *WebhookSink) Write(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if s.Secret != "" {
+		header.Set(webhook.SignatureHeader, webhook.Sign(s.Secret, body))
+	}
+	return post(s.Client, s.URL, "application/json", body, header)
+}
+
+func post(client *http.Client, url string, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package authzconfig exports the authorization configuration of an app as
+// a declarative document, and applies such a document to another app.
+//
+// The document covers roles, admin roles, default roles, the creation
+// access and default access of each record type, and the field ACL. API
+// keys are set by the API_KEY and MASTER_KEY configuration of the server
+// and are not part of the document.
+package authzconfig
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+)
+
+// Document is the authorization configuration of an app.
+//
+// A nil field of a document is left unchanged when the document is
+// applied, so that a partial document only changes the configuration it
+// mentions.
+type Document struct {
+	Roles        []string                    `json:"roles"`
+	AdminRoles   []string                    `json:"admin_roles"`
+	DefaultRoles []string                    `json:"default_roles"`
+	RecordTypes  map[string]RecordTypeAccess `json:"record_types"`
+	FieldAccess  skydb.FieldACLEntryList     `json:"field_access"`
+}
+
+// RecordTypeAccess is the access configuration of a record type.
+type RecordTypeAccess struct {
+	CreateRoles   []string        `json:"create_roles"`
+	DefaultAccess skydb.RecordACL `json:"default_access,omitempty"`
+}
+
+// Export reads the authorization configuration from conn. Record types are
+// those having a schema in the public database.
+func Export(conn skydb.Conn) (*Document, error) {
+	doc := Document{
+		RecordTypes: map[string]RecordTypeAccess{},
+	}
+
+	var err error
+	if doc.Roles, err = conn.GetAllRoles(); err != nil {
+		return nil, err
+	}
+	if doc.AdminRoles, err = conn.GetAdminRoles(); err != nil {
+		return nil, err
+	}
+	if doc.DefaultRoles, err = conn.GetDefaultRoles(); err != nil {
+		return nil, err
+	}
+	doc.Roles = sortedStrings(doc.Roles)
+	doc.AdminRoles = sortedStrings(doc.AdminRoles)
+	doc.DefaultRoles = sortedStrings(doc.DefaultRoles)
+
+	schemas, err := conn.PublicDB().GetRecordSchemas()
+	if err != nil {
+		return nil, err
+	}
+	for recordType := range schemas {
+		creationACL, err := conn.GetRecordAccess(recordType)
+		if err != nil {
+			return nil, err
+		}
+		defaultACL, err := conn.GetRecordDefaultAccess(recordType)
+		if err != nil {
+			return nil, err
+		}
+
+		access := RecordTypeAccess{
+			DefaultAccess: defaultACL,
+		}
+		for _, ace := range creationACL {
+			if ace.Role != "" {
+				access.CreateRoles = append(access.CreateRoles, ace.Role)
+			}
+		}
+		access.CreateRoles = sortedStrings(access.CreateRoles)
+		doc.RecordTypes[recordType] = access
+	}
+
+	fieldACL, err := conn.GetRecordFieldAccess()
+	if err != nil {
+		return nil, err
+	}
+	doc.FieldAccess = fieldACL.AllEntries()
+	if doc.FieldAccess == nil {
+		doc.FieldAccess = skydb.FieldACLEntryList{}
+	}
+	sort.Sort(doc.FieldAccess)
+
+	return &doc, nil
+}
+
+// Apply writes the authorization configuration in doc to conn. Applying
+// the same document again does not change the configuration.
+//
+// Roles are created if they do not exist, but existing roles not in the
+// document are kept because they may still be assigned to users.
+func Apply(conn skydb.Conn, doc *Document) error {
+	if err := conn.EnsureRoles(doc.allRoles()); err != nil {
+		return err
+	}
+
+	if doc.AdminRoles != nil {
+		if err := conn.SetAdminRoles(doc.AdminRoles); err != nil {
+			return err
+		}
+	}
+	if doc.DefaultRoles != nil {
+		if err := conn.SetDefaultRoles(doc.DefaultRoles); err != nil {
+			return err
+		}
+	}
+
+	recordTypes := []string{}
+	for recordType := range doc.RecordTypes {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+	for _, recordType := range recordTypes {
+		access := doc.RecordTypes[recordType]
+		if access.CreateRoles != nil {
+			entries := []skydb.RecordACLEntry{}
+			for _, role := range access.CreateRoles {
+				entries = append(entries, skydb.NewRecordACLEntryRole(role, skydb.CreateLevel))
+			}
+			if err := conn.SetRecordAccess(recordType, skydb.NewRecordACL(entries)); err != nil {
+				return err
+			}
+		}
+		if access.DefaultAccess != nil {
+			if err := conn.SetRecordDefaultAccess(recordType, access.DefaultAccess); err != nil {
+				return err
+			}
+		}
+	}
+
+	if doc.FieldAccess != nil {
+		if err := conn.SetRecordFieldAccess(skydb.NewFieldACL(doc.FieldAccess)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortedStrings sorts strs in place. A nil slice is converted to an empty
+// slice, because a nil field is left unchanged when a document is applied.
+func sortedStrings(strs []string) []string {
+	if strs == nil {
+		return []string{}
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+// allRoles returns the roles mentioned anywhere in the document.
+func (doc *Document) allRoles() []string {
+	seen := map[string]bool{}
+	roles := []string{}
+	add := func(list []string) {
+		for _, role := range list {
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	add(doc.Roles)
+	add(doc.AdminRoles)
+	add(doc.DefaultRoles)
+	for _, access := range doc.RecordTypes {
+		add(access.CreateRoles)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// FromMap decodes a document from its JSON representation.
+func (doc *Document) FromMap(m map[string]interface{}) error {
+	var err error
+	if doc.Roles, err = stringSlice(m, "roles"); err != nil {
+		return err
+	}
+	if doc.AdminRoles, err = stringSlice(m, "admin_roles"); err != nil {
+		return err
+	}
+	if doc.DefaultRoles, err = stringSlice(m, "default_roles"); err != nil {
+		return err
+	}
+
+	if raw, ok := m["record_types"]; ok && raw != nil {
+		typeMap, ok := raw.(map[string]interface{})
+		if !ok {
+			return errors.New("record_types must be an object")
+		}
+		doc.RecordTypes = map[string]RecordTypeAccess{}
+		for recordType, rawAccess := range typeMap {
+			accessMap, ok := rawAccess.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("record_types.%s must be an object", recordType)
+			}
+			access := RecordTypeAccess{}
+			if access.CreateRoles, err = stringSlice(accessMap, "create_roles"); err != nil {
+				return fmt.Errorf("record_types.%s: %v", recordType, err)
+			}
+			if access.DefaultAccess, err = recordACL(accessMap, "default_access"); err != nil {
+				return fmt.Errorf("record_types.%s: %v", recordType, err)
+			}
+			doc.RecordTypes[recordType] = access
+		}
+	}
+
+	if raw, ok := m["field_access"]; ok && raw != nil {
+		slice, ok := raw.([]interface{})
+		if !ok {
+			return errors.New("field_access must be an array")
+		}
+		doc.FieldAccess = skydb.FieldACLEntryList{}
+		for i, v := range slice {
+			entryMap, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("invalid field_access entry at %d", i)
+			}
+			entry := skydb.FieldACLEntry{}
+			if err := (*skyconv.MapFieldACLEntry)(&entry).FromMap(entryMap); err != nil {
+				return fmt.Errorf("invalid field_access entry at %d: %v", i, err)
+			}
+			doc.FieldAccess = append(doc.FieldAccess, entry)
+		}
+	}
+
+	return nil
+}
+
+func stringSlice(m map[string]interface{}, key string) ([]string, error) {
+	raw, ok := m[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	slice, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", key)
+	}
+	strs := []string{}
+	for _, v := range slice {
+		str, ok := v.(string)
+		if !ok || str == "" {
+			return nil, fmt.Errorf("%s must be an array of strings", key)
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+func recordACL(m map[string]interface{}, key string) (skydb.RecordACL, error) {
+	raw, ok := m[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	slice, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array", key)
+	}
+	acl := skydb.RecordACL{}
+	for i, v := range slice {
+		entryMap, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s entry at %d", key, i)
+		}
+		ace := skydb.RecordACLEntry{}
+		if err := (*skyconv.MapACLEntry)(&ace).FromMap(entryMap); err != nil {
+			return nil, fmt.Errorf("invalid %s entry at %d: %v", key, i, err)
+		}
+		acl = append(acl, ace)
+	}
+	return acl, nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authzconfig
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type authzConn struct {
+	skydb.Conn
+	db           *skydbtest.MapDB
+	roles        map[string]bool
+	adminRoles   []string
+	defaultRoles []string
+	creation     map[string]skydb.RecordACL
+	defaults     map[string]skydb.RecordACL
+	fieldACL     skydb.FieldACL
+}
+
+func newAuthzConn() *authzConn {
+	return &authzConn{
+		db:           skydbtest.NewMapDB(),
+		roles:        map[string]bool{},
+		adminRoles:   []string{},
+		defaultRoles: []string{},
+		creation:     map[string]skydb.RecordACL{},
+		defaults:     map[string]skydb.RecordACL{},
+	}
+}
+
+func (c *authzConn) PublicDB() skydb.Database {
+	return c.db
+}
+
+func (c *authzConn) GetAllRoles() ([]string, error) {
+	roles := []string{}
+	for role := range c.roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+func (c *authzConn) EnsureRoles(roles []string) error {
+	for _, role := range roles {
+		c.roles[role] = true
+	}
+	return nil
+}
+
+func (c *authzConn) GetAdminRoles() ([]string, error) {
+	return c.adminRoles, nil
+}
+
+func (c *authzConn) SetAdminRoles(roles []string) error {
+	c.EnsureRoles(roles)
+	c.adminRoles = roles
+	return nil
+}
+
+func (c *authzConn) GetDefaultRoles() ([]string, error) {
+	return c.defaultRoles, nil
+}
+
+func (c *authzConn) SetDefaultRoles(roles []string) error {
+	c.EnsureRoles(roles)
+	c.defaultRoles = roles
+	return nil
+}
+
+func (c *authzConn) GetRecordAccess(recordType string) (skydb.RecordACL, error) {
+	return c.creation[recordType], nil
+}
+
+func (c *authzConn) SetRecordAccess(recordType string, acl skydb.RecordACL) error {
+	for _, ace := range acl {
+		c.EnsureRoles([]string{ace.Role})
+	}
+	c.creation[recordType] = acl
+	return nil
+}
+
+func (c *authzConn) GetRecordDefaultAccess(recordType string) (skydb.RecordACL, error) {
+	return c.defaults[recordType], nil
+}
+
+func (c *authzConn) SetRecordDefaultAccess(recordType string, acl skydb.RecordACL) error {
+	c.defaults[recordType] = acl
+	return nil
+}
+
+func (c *authzConn) GetRecordFieldAccess() (skydb.FieldACL, error) {
+	return c.fieldACL, nil
+}
+
+func (c *authzConn) SetRecordFieldAccess(acl skydb.FieldACL) error {
+	c.fieldACL = acl
+	return nil
+}
+
+func TestExportApply(t *testing.T) {
+	Convey("authzconfig", t, func() {
+		src := newAuthzConn()
+		src.db.RecordSchemaMap["note"] = skydb.RecordSchema{}
+		src.db.RecordSchemaMap["comment"] = skydb.RecordSchema{}
+		src.EnsureRoles([]string{"moderator"})
+		src.SetAdminRoles([]string{"admin"})
+		src.SetDefaultRoles([]string{"user"})
+		src.SetRecordAccess("note", skydb.NewRecordACL([]skydb.RecordACLEntry{
+			skydb.NewRecordACLEntryRole("writer", skydb.CreateLevel),
+		}))
+		src.SetRecordDefaultAccess("note", skydb.NewRecordACL([]skydb.RecordACLEntry{
+			skydb.NewRecordACLEntryRole("user", skydb.ReadLevel),
+		}))
+		src.SetRecordFieldAccess(skydb.NewFieldACL(skydb.FieldACLEntryList{
+			{
+				RecordType:  "note",
+				RecordField: "secret",
+				UserRole:    skydb.NewFieldUserRole("_owner"),
+				Writable:    true,
+				Readable:    true,
+			},
+		}))
+
+		Convey("exports document", func() {
+			doc, err := Export(src)
+			So(err, ShouldBeNil)
+
+			data, err := json.Marshal(doc)
+			So(err, ShouldBeNil)
+			So(data, ShouldEqualJSON, `{
+				"roles": ["admin", "moderator", "user", "writer"],
+				"admin_roles": ["admin"],
+				"default_roles": ["user"],
+				"record_types": {
+					"comment": {
+						"create_roles": []
+					},
+					"note": {
+						"create_roles": ["writer"],
+						"default_access": [{"role": "user", "level": "read"}]
+					}
+				},
+				"field_access": [{
+					"record_type": "note",
+					"record_field": "secret",
+					"user_role": "_owner",
+					"writable": true,
+					"readable": true,
+					"comparable": false,
+					"discoverable": false
+				}]
+			}`)
+		})
+
+		Convey("applies exported document to another app", func() {
+			doc, err := Export(src)
+			So(err, ShouldBeNil)
+			data, err := json.Marshal(doc)
+			So(err, ShouldBeNil)
+
+			m := map[string]interface{}{}
+			So(json.Unmarshal(data, &m), ShouldBeNil)
+			decoded := Document{}
+			So(decoded.FromMap(m), ShouldBeNil)
+
+			dst := newAuthzConn()
+			dst.db.RecordSchemaMap["note"] = skydb.RecordSchema{}
+			dst.db.RecordSchemaMap["comment"] = skydb.RecordSchema{}
+			So(Apply(dst, &decoded), ShouldBeNil)
+
+			applied, err := Export(dst)
+			So(err, ShouldBeNil)
+			So(applied, ShouldResemble, doc)
+
+			Convey("idempotently", func() {
+				So(Apply(dst, &decoded), ShouldBeNil)
+				reapplied, err := Export(dst)
+				So(err, ShouldBeNil)
+				So(reapplied, ShouldResemble, doc)
+			})
+		})
+
+		Convey("leaves configuration not in document unchanged", func() {
+			doc := Document{}
+			So(doc.FromMap(map[string]interface{}{
+				"default_roles": []interface{}{"member"},
+			}), ShouldBeNil)
+			So(Apply(src, &doc), ShouldBeNil)
+
+			So(src.defaultRoles, ShouldResemble, []string{"member"})
+			So(src.adminRoles, ShouldResemble, []string{"admin"})
+			So(src.creation["note"], ShouldHaveLength, 1)
+			So(src.fieldACL.AllEntries(), ShouldHaveLength, 1)
+		})
+
+		Convey("rejects invalid document", func() {
+			doc := Document{}
+			So(doc.FromMap(map[string]interface{}{
+				"record_types": map[string]interface{}{
+					"note": map[string]interface{}{
+						"default_access": []interface{}{
+							map[string]interface{}{"role": "user", "level": "admin"},
+						},
+					},
+				},
+			}), ShouldNotBeNil)
+			So(doc.FromMap(map[string]interface{}{
+				"roles": "admin",
+			}), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chaos injects faults into requests and plugin calls, so that
+// client retry logic and plugin failure handling can be exercised
+// deliberately. It is intended for development and staging only.
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrDropped is returned by a plugin call dropped by an Injector.
+var ErrDropped = errors.New("chaos: plugin call dropped")
+
+// Injector decides which faults are injected.
+type Injector struct {
+	// Latency is added to each affected request.
+	Latency time.Duration
+
+	// ErrorRate is the probability, from 0 to 1, that an affected request
+	// fails with an error.
+	ErrorRate float64
+
+	// DropRate is the probability, from 0 to 1, that an affected plugin
+	// call is dropped.
+	DropRate float64
+
+	// Actions are the names of the actions, lambdas and hooks affected.
+	// All of them are affected if Actions is empty.
+	Actions []string
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// Affects returns whether faults are injected for the specified name.
+func (i *Injector) Affects(name string) bool {
+	if i == nil {
+		return false
+	}
+
+	if len(i.Actions) == 0 {
+		return true
+	}
+	for _, action := range i.Actions {
+		if action == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay sleeps for the injected latency if the name is affected.
+func (i *Injector) Delay(name string) {
+	if i.Affects(name) && i.Latency > 0 {
+		time.Sleep(i.Latency)
+	}
+}
+
+// ShouldFail returns whether the request with the specified action
+// should fail.
+func (i *Injector) ShouldFail(name string) bool {
+	return i.Affects(name) && i.roll(i.ErrorRate)
+}
+
+// ShouldDrop returns whether the plugin call with the specified name
+// should be dropped.
+func (i *Injector) ShouldDrop(name string) bool {
+	return i.Affects(name) && i.roll(i.DropRate)
+}
+
+func (i *Injector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.rand == nil {
+		i.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return i.rand.Float64() < rate
+}
@@ -0,0 +1,67 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInjector(t *testing.T) {
+	Convey("Injector", t, func() {
+		Convey("affects all actions by default", func() {
+			i := &Injector{}
+			So(i.Affects("record:query"), ShouldBeTrue)
+		})
+
+		Convey("affects only specified actions", func() {
+			i := &Injector{Actions: []string{"record:save"}}
+			So(i.Affects("record:save"), ShouldBeTrue)
+			So(i.Affects("record:query"), ShouldBeFalse)
+		})
+
+		Convey("nil injector affects nothing", func() {
+			var i *Injector
+			So(i.Affects("record:query"), ShouldBeFalse)
+			So(i.ShouldFail("record:query"), ShouldBeFalse)
+			So(i.ShouldDrop("record:query"), ShouldBeFalse)
+		})
+
+		Convey("fails and drops by rate", func() {
+			i := &Injector{ErrorRate: 1, DropRate: 0}
+			So(i.ShouldFail("record:query"), ShouldBeTrue)
+			So(i.ShouldDrop("record:query"), ShouldBeFalse)
+
+			i = &Injector{ErrorRate: 0, DropRate: 1, Actions: []string{"hello"}}
+			So(i.ShouldFail("hello"), ShouldBeFalse)
+			So(i.ShouldDrop("hello"), ShouldBeTrue)
+			So(i.ShouldDrop("world"), ShouldBeFalse)
+		})
+
+		Convey("delays affected actions", func() {
+			i := &Injector{Latency: 20 * time.Millisecond, Actions: []string{"slow"}}
+
+			start := time.Now()
+			i.Delay("fast")
+			So(time.Since(start), ShouldBeLessThan, 20*time.Millisecond)
+
+			start = time.Now()
+			i.Delay("slow")
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+		})
+	})
+}
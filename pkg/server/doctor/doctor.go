@@ -0,0 +1,211 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doctor runs preflight checks against the services that
+// Skygear Server depends on and prints a report of the results.
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/pq"
+)
+
+// RequiredExtensions is the list of Postgres extensions checked by
+// DatabaseChecks.
+var RequiredExtensions = []string{"postgis", "citext", "pg_trgm"}
+
+// Check is a single diagnosis to be run by the doctor.
+type Check struct {
+	Name string
+
+	// Hint tells the user what to do when the check fails.
+	Hint string
+
+	Run func() error
+}
+
+// SkipError is returned by a check that does not apply to the current
+// configuration.
+type SkipError struct {
+	Reason string
+}
+
+func (e SkipError) Error() string {
+	return e.Reason
+}
+
+// Skip returns a SkipError with the specified reason.
+func Skip(reason string) error {
+	return SkipError{reason}
+}
+
+// Run runs each of the checks in order and writes a report to w. It
+// returns false if any of the checks failed.
+func Run(checks []Check, w io.Writer) bool {
+	ok := true
+	for _, check := range checks {
+		err := runCheck(check)
+		switch err.(type) {
+		case nil:
+			fmt.Fprintf(w, "[ OK ] %s\n", check.Name)
+		case SkipError:
+			fmt.Fprintf(w, "[SKIP] %s: %v\n", check.Name, err)
+		default:
+			ok = false
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", check.Name, err)
+			if check.Hint != "" {
+				fmt.Fprintf(w, "       %s\n", check.Hint)
+			}
+		}
+	}
+	return ok
+}
+
+func runCheck(check Check) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return check.Run()
+}
+
+// DatabaseChecks returns the checks of database connectivity, privileges
+// and extensions.
+func DatabaseChecks(config skyconfig.Configuration) []Check {
+	requirePQ := func(f func() error) func() error {
+		return func() error {
+			if config.DB.ImplName != "pq" {
+				return Skip(fmt.Sprintf("not supported by %s", config.DB.ImplName))
+			}
+			return f()
+		}
+	}
+
+	return []Check{
+		{
+			Name: "database connectivity",
+			Hint: "Check DATABASE_URL and that the database server is running.",
+			Run: requirePQ(func() error {
+				return pq.CheckConnection(config.DB.Option)
+			}),
+		},
+		{
+			Name: "database privileges",
+			Hint: "Grant CREATE on the database, or USAGE and CREATE on the app schema, to the database user.",
+			Run: requirePQ(func() error {
+				return pq.CheckPrivileges(config.DB.Option, config.App.Name)
+			}),
+		},
+		{
+			Name: "database extensions",
+			Hint: "Install the missing extensions on the database server, e.g. the postgis and postgresql-contrib packages.",
+			Run: requirePQ(func() error {
+				return pq.CheckExtensions(config.DB.Option, RequiredExtensions)
+			}),
+		},
+	}
+}
+
+// AssetStoreCheck returns a check that writes a small file to the
+// asset store.
+func AssetStoreCheck(storeOpener func() asset.Store) Check {
+	return Check{
+		Name: "asset store write access",
+		Hint: "Check the ASSET_STORE settings and the permissions of the bucket or directory.",
+		Run: func() error {
+			const content = "skygear-server doctor"
+			store := storeOpener()
+			return store.PutFileReader(
+				"skygear-doctor-check",
+				strings.NewReader(content),
+				int64(len(content)),
+				"text/plain",
+			)
+		},
+	}
+}
+
+// PushCheck returns a check that verifies the credential of a push
+// service. senderOpener returns nil if the service is not enabled.
+func PushCheck(service string, senderOpener func() (push.CredentialChecker, error)) Check {
+	return Check{
+		Name: fmt.Sprintf("%s credential", service),
+		Hint: fmt.Sprintf("Check the %s credential in the configuration and that it is not revoked.", service),
+		Run: func() error {
+			sender, err := senderOpener()
+			if err != nil {
+				return err
+			}
+			if sender == nil {
+				return Skip("not enabled")
+			}
+			return sender.CheckCredential()
+		},
+	}
+}
+
+// PluginCheck returns a check that verifies the plugin can be reached
+// with its configured transport.
+func PluginCheck(name string, config *skyconfig.PluginConfig) Check {
+	return Check{
+		Name: fmt.Sprintf("plugin %s reachability", name),
+		Hint: fmt.Sprintf("Check that the plugin is running and %s_PATH points to it.", name),
+		Run: func() error {
+			return checkPluginReachable(config.Transport, config.Path)
+		},
+	}
+}
+
+func checkPluginReachable(transport string, path string) error {
+	switch transport {
+	case "http":
+		client := http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Get(path)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	case "zmq":
+		u, err := url.Parse(path)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "tcp" {
+			return Skip(fmt.Sprintf("cannot check %s address", u.Scheme))
+		}
+		conn, err := net.DialTimeout("tcp", u.Host, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case "exec":
+		_, err := exec.LookPath(path)
+		return err
+	default:
+		return fmt.Errorf("unknown plugin transport %s", transport)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type credentialChecker struct {
+	err error
+}
+
+func (c *credentialChecker) CheckCredential() error {
+	return c.err
+}
+
+func TestRun(t *testing.T) {
+	Convey("Run", t, func() {
+		buf := &bytes.Buffer{}
+
+		Convey("reports passed and skipped checks", func() {
+			ok := Run([]Check{
+				{Name: "passing", Run: func() error { return nil }},
+				{Name: "skipped", Run: func() error { return Skip("not enabled") }},
+			}, buf)
+			So(ok, ShouldBeTrue)
+			So(buf.String(), ShouldEqual, "[ OK ] passing\n[SKIP] skipped: not enabled\n")
+		})
+
+		Convey("reports failed check with hint", func() {
+			ok := Run([]Check{
+				{Name: "failing", Hint: "Fix it.", Run: func() error { return errors.New("broken") }},
+			}, buf)
+			So(ok, ShouldBeFalse)
+			So(buf.String(), ShouldEqual, "[FAIL] failing: broken\n       Fix it.\n")
+		})
+
+		Convey("reports panicking check as failed", func() {
+			ok := Run([]Check{
+				{Name: "panicking", Run: func() error { panic("unrecognized implementation") }},
+			}, buf)
+			So(ok, ShouldBeFalse)
+			So(buf.String(), ShouldEqual, "[FAIL] panicking: unrecognized implementation\n")
+		})
+	})
+}
+
+func TestPushCheck(t *testing.T) {
+	Convey("PushCheck", t, func() {
+		Convey("skips disabled service", func() {
+			check := PushCheck("APNS", func() (push.CredentialChecker, error) {
+				return nil, nil
+			})
+			So(check.Run(), ShouldHaveSameTypeAs, SkipError{})
+		})
+
+		Convey("returns error of credential check", func() {
+			check := PushCheck("GCM", func() (push.CredentialChecker, error) {
+				return &credentialChecker{errors.New("401 Unauthorized")}, nil
+			})
+			So(check.Run(), ShouldNotBeNil)
+		})
+	})
+}
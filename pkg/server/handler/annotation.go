@@ -0,0 +1,388 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+const (
+	// defaultAnnotationLimit is the number of annotations returned if
+	// limit is not specified.
+	defaultAnnotationLimit = 50
+	// maxAnnotationLimit is the maximum number of annotations returned
+	// in a request.
+	maxAnnotationLimit = 500
+	// maxAnnotationLength is the maximum length of the content of an
+	// annotation in characters.
+	maxAnnotationLength = 10000
+)
+
+type annotationResult struct {
+	ID        string    `json:"id"`
+	RecordID  string    `json:"record_id"`
+	AuthorID  string    `json:"author_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newAnnotationResult(annotation skydb.Annotation) annotationResult {
+	return annotationResult{
+		ID:        annotation.ID,
+		RecordID:  annotation.RecordID.String(),
+		AuthorID:  annotation.AuthorID,
+		Content:   annotation.Content,
+		CreatedAt: annotation.CreatedAt,
+	}
+}
+
+// checkAnnotationAccess returns an error if the user cannot access the
+// record at the specified level. Annotations inherit the access control
+// of the record they are attached to.
+func checkAnnotationAccess(rpayload *router.Payload, recordID skydb.RecordID, level skydb.RecordACLLevel) skyerr.Error {
+	record := skydb.Record{}
+	if err := rpayload.Database.Get(recordID, &record); err == skydb.ErrRecordNotFound {
+		return skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+	} else if err != nil {
+		return skyerr.MakeError(err)
+	}
+
+	if rpayload.HasMasterKey() {
+		return nil
+	}
+	if record.DeletedAt != nil {
+		return skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+	}
+	if !record.Accessible(rpayload.AuthInfo, level) {
+		return skyerr.NewError(skyerr.PermissionDenied, "no permission to access annotations of the record")
+	}
+	return nil
+}
+
+type annotationAddPayload struct {
+	RawID    string `mapstructure:"record_id"`
+	Content  string `mapstructure:"content"`
+	RecordID skydb.RecordID
+}
+
+func (payload *annotationAddPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *annotationAddPayload) Validate() skyerr.Error {
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "record_id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+
+	if strings.TrimSpace(payload.Content) == "" {
+		return skyerr.NewInvalidArgument("content must not be empty", []string{"content"})
+	}
+	if len([]rune(payload.Content)) > maxAnnotationLength {
+		return skyerr.NewInvalidArgument("content must not be longer than 10000 characters", []string{"content"})
+	}
+	return nil
+}
+
+/*
+AnnotationAddHandler adds an annotation, such as a comment, to a record
+on behalf of the current user.
+
+Read access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:annotation:add",
+    "access_token": "ACCESS_TOKEN",
+    "record_id": "article/1",
+    "content": "Great article!"
+}
+EOF
+
+{
+    "result": {
+        "id": "ANNOTATION_ID",
+        "record_id": "article/1",
+        "author_id": "USER_ID",
+        "content": "Great article!",
+        "created_at": "2017-01-01T00:00:00Z"
+    }
+}
+*/
+type AnnotationAddHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *AnnotationAddHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *AnnotationAddHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *AnnotationAddHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &annotationAddPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if skyErr := checkAnnotationAccess(rpayload, payload.RecordID, skydb.ReadLevel); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	annotation := skydb.Annotation{
+		ID:        uuid.New(),
+		RecordID:  payload.RecordID,
+		AuthorID:  rpayload.AuthInfoID,
+		Content:   payload.Content,
+		CreatedAt: timeNow(),
+	}
+	if err := rpayload.DBConn.AddAnnotation(&annotation); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newAnnotationResult(annotation)
+}
+
+type annotationListPayload struct {
+	RawID    string `mapstructure:"record_id"`
+	Offset   int    `mapstructure:"offset"`
+	Limit    int    `mapstructure:"limit"`
+	RecordID skydb.RecordID
+}
+
+func (payload *annotationListPayload) Decode(data map[string]interface{}) skyerr.Error {
+	payload.Limit = defaultAnnotationLimit
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *annotationListPayload) Validate() skyerr.Error {
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "record_id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+
+	if payload.Offset < 0 {
+		return skyerr.NewInvalidArgument("offset must not be negative", []string{"offset"})
+	}
+	if payload.Limit <= 0 || payload.Limit > maxAnnotationLimit {
+		return skyerr.NewInvalidArgument("limit must be between 1 and 500", []string{"limit"})
+	}
+	return nil
+}
+
+/*
+AnnotationListHandler returns the annotations of a record, ordered by the
+time they are added. At most limit annotations are returned, 50 by
+default.
+
+Read access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:annotation:list",
+    "access_token": "ACCESS_TOKEN",
+    "record_id": "article/1",
+    "offset": 0,
+    "limit": 50
+}
+EOF
+*/
+type AnnotationListHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *AnnotationListHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *AnnotationListHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *AnnotationListHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &annotationListPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if skyErr := checkAnnotationAccess(rpayload, payload.RecordID, skydb.ReadLevel); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	annotations, err := rpayload.DBConn.GetAnnotations(
+		payload.RecordID, uint64(payload.Offset), uint64(payload.Limit))
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	results := make([]annotationResult, len(annotations))
+	for i, annotation := range annotations {
+		results[i] = newAnnotationResult(annotation)
+	}
+	response.Result = results
+}
+
+type annotationRemovePayload struct {
+	ID string `mapstructure:"id"`
+}
+
+func (payload *annotationRemovePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *annotationRemovePayload) Validate() skyerr.Error {
+	if payload.ID == "" {
+		return skyerr.NewInvalidArgument("id must not be empty", []string{"id"})
+	}
+	return nil
+}
+
+/*
+AnnotationRemoveHandler removes an annotation of a record.
+
+An annotation can be removed by its author, or by users with write access
+to the record.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:annotation:remove",
+    "access_token": "ACCESS_TOKEN",
+    "id": "ANNOTATION_ID"
+}
+EOF
+*/
+type AnnotationRemoveHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *AnnotationRemoveHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *AnnotationRemoveHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *AnnotationRemoveHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &annotationRemovePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	annotation, err := rpayload.DBConn.GetAnnotation(payload.ID)
+	if err == skydb.ErrAnnotationNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "annotation not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	var level skydb.RecordACLLevel = skydb.WriteLevel
+	if annotation.AuthorID == rpayload.AuthInfoID {
+		level = skydb.ReadLevel
+	}
+	if skyErr := checkAnnotationAccess(rpayload, annotation.RecordID, level); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	err = rpayload.DBConn.DeleteAnnotation(payload.ID)
+	if err == skydb.ErrAnnotationNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "annotation not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = struct {
+		ID string `json:"id"`
+	}{payload.ID}
+}
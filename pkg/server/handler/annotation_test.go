@@ -0,0 +1,214 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type annotationConn struct {
+	skydb.Conn
+	annotations []skydb.Annotation
+}
+
+func (conn *annotationConn) AddAnnotation(annotation *skydb.Annotation) error {
+	conn.annotations = append(conn.annotations, *annotation)
+	return nil
+}
+
+func (conn *annotationConn) GetAnnotation(id string) (*skydb.Annotation, error) {
+	for _, annotation := range conn.annotations {
+		if annotation.ID == id {
+			return &annotation, nil
+		}
+	}
+	return nil, skydb.ErrAnnotationNotFound
+}
+
+func (conn *annotationConn) GetAnnotations(recordID skydb.RecordID, offset uint64, limit uint64) ([]skydb.Annotation, error) {
+	annotations := []skydb.Annotation{}
+	for _, annotation := range conn.annotations {
+		if annotation.RecordID == recordID {
+			annotations = append(annotations, annotation)
+		}
+	}
+	if offset > uint64(len(annotations)) {
+		offset = uint64(len(annotations))
+	}
+	annotations = annotations[offset:]
+	if limit < uint64(len(annotations)) {
+		annotations = annotations[:limit]
+	}
+	return annotations, nil
+}
+
+func (conn *annotationConn) DeleteAnnotation(id string) error {
+	for i, annotation := range conn.annotations {
+		if annotation.ID == id {
+			conn.annotations = append(conn.annotations[:i], conn.annotations[i+1:]...)
+			return nil
+		}
+	}
+	return skydb.ErrAnnotationNotFound
+}
+
+func TestAnnotationHandlers(t *testing.T) {
+	Convey("Annotation handlers", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := &annotationConn{}
+		db := skydbtest.NewMapDB()
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("article", "1"),
+			OwnerID: "alice",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("bob", skydb.ReadLevel),
+			},
+		}), ShouldBeNil)
+
+		newRouter := func(handler router.Handler, userID string) *handlertest.SingleRouteRouter {
+			return handlertest.NewSingleRouteRouter(handler, func(p *router.Payload) {
+				p.DBConn = conn
+				p.Database = db
+				p.AuthInfoID = userID
+				p.AuthInfo = &skydb.AuthInfo{ID: userID}
+			})
+		}
+
+		Convey("adds annotation to readable record", func() {
+			r := newRouter(&AnnotationAddHandler{}, "bob")
+			resp := r.POST(`{"record_id": "article/1", "content": "Nice!"}`)
+			So(conn.annotations, ShouldHaveLength, 1)
+
+			annotation := conn.annotations[0]
+			So(annotation.RecordID, ShouldResemble, skydb.NewRecordID("article", "1"))
+			So(annotation.AuthorID, ShouldEqual, "bob")
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"id": "`+annotation.ID+`",
+		"record_id": "article/1",
+		"author_id": "bob",
+		"content": "Nice!",
+		"created_at": "2017-01-01T00:00:00Z"
+	}
+}`)
+		})
+
+		Convey("refuses to add annotation to unreadable record", func() {
+			r := newRouter(&AnnotationAddHandler{}, "carol")
+			resp := r.POST(`{"record_id": "article/1", "content": "Nice!"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "PermissionDenied",
+		"code": 102,
+		"message": "no permission to access annotations of the record"
+	}
+}`)
+			So(conn.annotations, ShouldBeEmpty)
+		})
+
+		Convey("refuses to add empty annotation", func() {
+			r := newRouter(&AnnotationAddHandler{}, "bob")
+			resp := r.POST(`{"record_id": "article/1", "content": " "}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "content must not be empty",
+		"info": {"arguments": ["content"]}
+	}
+}`)
+		})
+
+		Convey("lists annotations of record", func() {
+			conn.annotations = []skydb.Annotation{
+				{ID: "1", RecordID: skydb.NewRecordID("article", "1"), AuthorID: "alice", Content: "a", CreatedAt: timeNow()},
+				{ID: "2", RecordID: skydb.NewRecordID("article", "2"), AuthorID: "alice", Content: "b", CreatedAt: timeNow()},
+				{ID: "3", RecordID: skydb.NewRecordID("article", "1"), AuthorID: "bob", Content: "c", CreatedAt: timeNow()},
+			}
+
+			r := newRouter(&AnnotationListHandler{}, "bob")
+			resp := r.POST(`{"record_id": "article/1", "offset": 1}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": [{
+		"id": "3",
+		"record_id": "article/1",
+		"author_id": "bob",
+		"content": "c",
+		"created_at": "2017-01-01T00:00:00Z"
+	}]
+}`)
+		})
+
+		Convey("removes annotation", func() {
+			conn.annotations = []skydb.Annotation{
+				{ID: "1", RecordID: skydb.NewRecordID("article", "1"), AuthorID: "bob", Content: "a"},
+				{ID: "2", RecordID: skydb.NewRecordID("article", "1"), AuthorID: "carol", Content: "b"},
+			}
+
+			Convey("by author", func() {
+				r := newRouter(&AnnotationRemoveHandler{}, "bob")
+				resp := r.POST(`{"id": "1"}`)
+				So(resp.Body.Bytes(), ShouldEqualJSON, `{"result": {"id": "1"}}`)
+				So(conn.annotations, ShouldHaveLength, 1)
+			})
+
+			Convey("by owner of record", func() {
+				r := newRouter(&AnnotationRemoveHandler{}, "alice")
+				resp := r.POST(`{"id": "2"}`)
+				So(resp.Body.Bytes(), ShouldEqualJSON, `{"result": {"id": "2"}}`)
+				So(conn.annotations, ShouldHaveLength, 1)
+			})
+
+			Convey("not by other reader of record", func() {
+				r := newRouter(&AnnotationRemoveHandler{}, "bob")
+				resp := r.POST(`{"id": "2"}`)
+				So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "PermissionDenied",
+		"code": 102,
+		"message": "no permission to access annotations of the record"
+	}
+}`)
+				So(conn.annotations, ShouldHaveLength, 2)
+			})
+
+			Convey("not found", func() {
+				r := newRouter(&AnnotationRemoveHandler{}, "bob")
+				resp := r.POST(`{"id": "3"}`)
+				So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "ResourceNotFound",
+		"code": 110,
+		"message": "annotation not found"
+	}
+}`)
+			})
+		})
+	})
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/tenant"
 )
 
 // AssetUploadHandler models the handler for asset upload request
@@ -30,6 +31,7 @@ type AssetUploadHandler struct {
 	AssetStore    skyAsset.Store   `inject:"AssetStore"`
 	AccessKey     router.Processor `preprocessor:"accesskey"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
 }
@@ -45,6 +47,7 @@ func (h *AssetUploadHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.AccessKey,
 		h.DBConn,
+		h.Maintenance,
 		h.PluginReady,
 	}
 }
@@ -54,43 +57,29 @@ func (h *AssetUploadHandler) GetPreprocessors() []router.Processor {
 	return h.preprocessors
 }
 
+// PayloadSchema returns the schema of the asset upload request
+func (h *AssetUploadHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "filename", Type: router.StringField, Required: true},
+		{Name: "content-type", Type: router.StringField, Required: true},
+		{Name: "content-size", Type: router.NumberField, Required: true},
+	}
+}
+
 // Handle is the handling method of the asset upload request
 func (h *AssetUploadHandler) Handle(
 	payload *router.Payload,
 	response *router.Response,
 ) {
-	filename, ok := payload.Data["filename"].(string)
-	if !ok {
-		response.Err = skyerr.NewInvalidArgument(
-			"Missing filename or filename is invalid",
-			[]string{"filename"},
-		)
-		return
-	}
-
-	contentType, ok := payload.Data["content-type"].(string)
-	if !ok {
-		response.Err = skyerr.NewInvalidArgument(
-			"Missing content type or content type is invalid",
-			[]string{"content-type"},
-		)
-		return
-	}
-
-	contentSizeFloat, ok := payload.Data["content-size"].(float64)
-	if !ok {
-		response.Err = skyerr.NewInvalidArgument(
-			"Missing content size or content size is invalid",
-			[]string{"content-size"},
-		)
-		return
-	}
+	filename, _ := payload.Data["filename"].(string)
+	contentType, _ := payload.Data["content-type"].(string)
+	contentSizeFloat, _ := payload.Data["content-size"].(float64)
 	contentSize := int64(contentSizeFloat)
 
 	// Add UUID to Filename
 	dir, file := filepath.Split(filename)
 	file = strings.Join([]string{uuidNew(), file}, "-")
-	filename = filepath.Join(dir, file)
+	filename = tenant.AssetName(payload.Tenant, filepath.Join(dir, file))
 
 	// Generate POST File Request
 	assetStore := h.AssetStore
@@ -25,6 +25,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -148,6 +149,17 @@ func TestAssetUploadHandler(t *testing.T) {
       }`)
 
 			So(res.Code, ShouldEqual, http.StatusBadRequest)
+			So(res.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "filename is required",
+					"info": {
+						"arguments": ["filename"],
+						"errors": {"filename": "filename is required"}
+					}
+				}
+			}`)
 		})
 
 		Convey("Fail when no content type", func() {
@@ -0,0 +1,264 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/assetjob"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type assetBulkPayload struct {
+	Operation string   `mapstructure:"operation"`
+	Names     []string `mapstructure:"names"`
+	Prefix    string   `mapstructure:"prefix"`
+	NewPrefix string   `mapstructure:"new_prefix"`
+	Sign      bool     `mapstructure:"sign"`
+}
+
+func (payload *assetBulkPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *assetBulkPayload) Validate() skyerr.Error {
+	switch payload.Operation {
+	case assetjob.Copy, assetjob.Move:
+		if payload.NewPrefix == "" {
+			return skyerr.NewInvalidArgument("new_prefix is required to copy or move assets", []string{"new_prefix"})
+		}
+		if payload.NewPrefix == payload.Prefix {
+			return skyerr.NewInvalidArgument("new_prefix must be different from prefix", []string{"new_prefix"})
+		}
+	case assetjob.Delete, assetjob.Sign:
+	default:
+		return skyerr.NewInvalidArgument(
+			`operation must be "copy", "move", "delete" or "sign"`,
+			[]string{"operation"},
+		)
+	}
+
+	if len(payload.Names) == 0 && payload.Prefix == "" {
+		return skyerr.NewInvalidArgument("names or prefix is required", []string{"names", "prefix"})
+	}
+	for _, name := range payload.Names {
+		if !strings.HasPrefix(name, payload.Prefix) {
+			return skyerr.NewInvalidArgument(
+				fmt.Sprintf("asset %s does not start with prefix", name),
+				[]string{"names"},
+			)
+		}
+	}
+	return nil
+}
+
+// Items returns the assets to operate, which are renamed by replacing
+// the prefix with the new prefix.
+func (payload *assetBulkPayload) Items(names []string) []assetjob.Item {
+	items := make([]assetjob.Item, len(names))
+	for i, name := range names {
+		items[i].Name = name
+		if payload.NewPrefix != "" {
+			items[i].NewName = payload.NewPrefix + strings.TrimPrefix(name, payload.Prefix)
+		}
+	}
+	return items
+}
+
+/*
+AssetBulkHandler starts a job to copy, move, delete or re-sign assets in
+bulk, which runs in background. Master key is required.
+
+The assets are specified by "names", or all assets starting with
+"prefix". To copy or move assets, the prefix of the names is replaced by
+"new_prefix". Moving an asset updates the records referencing it.
+Deleting an asset fails if it is referenced by records.
+
+Specify "sign": true to return the signed URLs of the copied or moved
+assets in the results. The "sign" operation only signs the URLs, such as
+after the URL prefix of the asset store is changed.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "asset:bulk",
+    "master_key": "MASTER_KEY",
+    "operation": "move",
+    "prefix": "images/",
+    "new_prefix": "photos/",
+    "sign": true
+}
+EOF
+
+{
+    "result": {
+        "id": "3e5e9a3c-4f9e-4b8b-8f5b-2f1d1e6b7c2a",
+        "operation": "move",
+        "status": "running",
+        "sign": true,
+        "total": 2,
+        "created_at": "2017-01-01T00:00:00Z",
+        "results": []
+    }
+}
+
+The progress and results of the job are returned by asset:bulk:status.
+*/
+type AssetBulkHandler struct {
+	AssetJobs        *assetjob.Runner `inject:"AssetJobs"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *AssetBulkHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *AssetBulkHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *AssetBulkHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &assetBulkPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	names := payload.Names
+	if len(names) == 0 {
+		var err error
+		names, err = rpayload.DBConn.GetAssetNames(payload.Prefix)
+		if err != nil {
+			response.Err = skyerr.MakeError(err)
+			return
+		}
+	}
+
+	job, err := h.AssetJobs.Start(payload.Operation, payload.Items(names), payload.Sign)
+	if err != nil {
+		response.Err = skyerr.NewError(skyerr.NotSupported, err.Error())
+		return
+	}
+	response.Result = job
+}
+
+type assetBulkStatusPayload struct {
+	JobID string `mapstructure:"job_id"`
+}
+
+func (payload *assetBulkStatusPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *assetBulkStatusPayload) Validate() skyerr.Error {
+	if payload.JobID == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"job_id"})
+	}
+	return nil
+}
+
+/*
+AssetBulkStatusHandler returns a job started by asset:bulk, including the
+results of the assets operated so far. Master key is required. Jobs are
+kept for a day after completion.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "asset:bulk:status",
+    "master_key": "MASTER_KEY",
+    "job_id": "3e5e9a3c-4f9e-4b8b-8f5b-2f1d1e6b7c2a"
+}
+EOF
+
+{
+    "result": {
+        "id": "3e5e9a3c-4f9e-4b8b-8f5b-2f1d1e6b7c2a",
+        "operation": "move",
+        "status": "completed",
+        "sign": true,
+        "total": 2,
+        "created_at": "2017-01-01T00:00:00Z",
+        "completed_at": "2017-01-01T00:00:01Z",
+        "results": [
+            {
+                "name": "images/a.png",
+                "new_name": "photos/a.png",
+                "url": "http://localhost:3000/files/photos/a.png?expiredAt=1483229700&signature=..."
+            },
+            {
+                "name": "images/b.png",
+                "new_name": "photos/b.png",
+                "error": {
+                    "name": "ResourceNotFound",
+                    "code": 110,
+                    "message": "asset not found"
+                }
+            }
+        ]
+    }
+}
+*/
+type AssetBulkStatusHandler struct {
+	AssetJobs        *assetjob.Runner `inject:"AssetJobs"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *AssetBulkStatusHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *AssetBulkStatusHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *AssetBulkStatusHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &assetBulkStatusPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	job, ok := h.AssetJobs.Get(payload.JobID)
+	if !ok {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "job not found")
+		return
+	}
+	response.Result = job
+}
@@ -0,0 +1,141 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/assetjob"
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+func TestAssetBulkHandler(t *testing.T) {
+	Convey("AssetBulkHandler", t, func() {
+		dir, err := ioutil.TempDir("", "assetjob")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		store := asset.NewFileStore(dir, "http://skygear.dev/files", "secret", true)
+		conn := skydbtest.NewMapConn()
+		for _, name := range []string{"images/a.png", "images/b.png", "video.mp4"} {
+			So(store.PutFileReader(name, strings.NewReader("a"), 1, "image/png"), ShouldBeNil)
+			So(conn.SaveAsset(&skydb.Asset{Name: name, ContentType: "image/png", Size: 1}), ShouldBeNil)
+		}
+
+		jobs := assetjob.NewRunner(func() (skydb.Conn, error) { return conn, nil }, store)
+		r := handlertest.NewSingleRouteRouter(&AssetBulkHandler{
+			AssetJobs: jobs,
+		}, func(p *router.Payload) {
+			p.DBConn = conn
+		})
+
+		Convey("starts job on assets with prefix", func() {
+			resp := r.POST(`{
+	"operation": "copy",
+	"prefix": "images/",
+	"new_prefix": "photos/"
+}`)
+			So(resp.Code, ShouldEqual, 200)
+
+			result := struct {
+				Result assetjob.Job `json:"result"`
+			}{}
+			So(json.Unmarshal(resp.Body.Bytes(), &result), ShouldBeNil)
+			So(result.Result.Operation, ShouldEqual, assetjob.Copy)
+			So(result.Result.Total, ShouldEqual, 2)
+
+			_, ok := jobs.Get(result.Result.ID)
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("rejects names not starting with prefix", func() {
+			resp := r.POST(`{
+	"operation": "move",
+	"names": ["images/a.png", "video.mp4"],
+	"prefix": "images/",
+	"new_prefix": "photos/"
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "asset video.mp4 does not start with prefix",
+		"info": {"arguments": ["names"]}
+	}
+}`)
+		})
+
+		Convey("rejects copying without new prefix", func() {
+			resp := r.POST(`{
+	"operation": "copy",
+	"names": ["images/a.png"]
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "new_prefix is required to copy or move assets",
+		"info": {"arguments": ["new_prefix"]}
+	}
+}`)
+		})
+
+		Convey("rejects unknown operation", func() {
+			resp := r.POST(`{
+	"operation": "archive",
+	"prefix": "images/"
+}`)
+			So(resp.Code, ShouldEqual, 400)
+		})
+	})
+}
+
+func TestAssetBulkStatusHandler(t *testing.T) {
+	Convey("AssetBulkStatusHandler", t, func() {
+		jobs := assetjob.NewRunner(func() (skydb.Conn, error) {
+			return skydbtest.NewMapConn(), nil
+		}, nil)
+		r := handlertest.NewSingleRouteRouter(&AssetBulkStatusHandler{
+			AssetJobs: jobs,
+		}, func(p *router.Payload) {})
+
+		Convey("returns not found for unknown job", func() {
+			resp := r.POST(`{"job_id": "unknown"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "ResourceNotFound",
+		"code": 110,
+		"message": "job not found"
+	}
+}`)
+		})
+
+		Convey("rejects missing job ID", func() {
+			resp := r.POST(`{}`)
+			So(resp.Code, ShouldEqual, 400)
+		})
+	})
+}
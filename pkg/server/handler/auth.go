@@ -20,12 +20,14 @@ import (
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/audit"
 	"github.com/skygeario/skygear-server/pkg/server/authtoken"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/provider"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/webhook"
 )
 
 var errUserDuplicated = skyerr.NewError(skyerr.Duplicated, "user duplicated")
@@ -112,16 +114,19 @@ func (payload *signupPayload) duplicatedKeysInAuthDataAndProfile() []string {
 //  }
 //  EOF
 type SignupHandler struct {
-	TokenStore       authtoken.Store    `inject:"TokenStore"`
-	ProviderRegistry *provider.Registry `inject:"ProviderRegistry"`
-	HookRegistry     *hook.Registry     `inject:"HookRegistry"`
-	AssetStore       asset.Store        `inject:"AssetStore"`
-	AccessModel      skydb.AccessModel  `inject:"AccessModel"`
-	AuthRecordKeys   [][]string         `inject:"AuthRecordKeys"`
-	AccessKey        router.Processor   `preprocessor:"accesskey"`
-	DBConn           router.Processor   `preprocessor:"dbconn"`
-	InjectPublicDB   router.Processor   `preprocessor:"inject_public_db"`
-	PluginReady      router.Processor   `preprocessor:"plugin_ready"`
+	TokenStore       authtoken.Store     `inject:"TokenStore"`
+	ProviderRegistry *provider.Registry  `inject:"ProviderRegistry"`
+	HookRegistry     *hook.Registry      `inject:"HookRegistry"`
+	AssetStore       asset.Store         `inject:"AssetStore"`
+	AccessModel      skydb.AccessModel   `inject:"AccessModel"`
+	AuthRecordKeys   [][]string          `inject:"AuthRecordKeys"`
+	Webhook          *webhook.Dispatcher `inject:"WebhookDispatcher"`
+	AuditStream      *audit.Stream       `inject:"AuditStream"`
+	AccessKey        router.Processor    `preprocessor:"accesskey"`
+	DBConn           router.Processor    `preprocessor:"dbconn"`
+	Maintenance      router.Processor    `preprocessor:"maintenance"`
+	InjectPublicDB   router.Processor    `preprocessor:"inject_public_db"`
+	PluginReady      router.Processor    `preprocessor:"plugin_ready"`
 	preprocessors    []router.Processor
 }
 
@@ -129,6 +134,7 @@ func (h *SignupHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.AccessKey,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectPublicDB,
 		h.PluginReady,
 	}
@@ -238,6 +244,21 @@ func (h *SignupHandler) Handle(payload *router.Payload, response *router.Respons
 		return
 	}
 
+	h.Webhook.Dispatch(webhook.AuthSignup, "", map[string]interface{}{
+		"user_id": info.ID,
+	})
+	h.AuditStream.Emit(payload.Req, audit.Event{
+		Type:   audit.Signup,
+		UserID: info.ID,
+		Data: map[string]interface{}{
+			"provider":  p.Provider,
+			"anonymous": p.IsAnonymous(),
+		},
+	})
+	h.AuditStream.Emit(payload.Req, audit.Event{
+		Type:   audit.TokenIssued,
+		UserID: info.ID,
+	})
 	response.Result = authResponse
 }
 
@@ -285,15 +306,17 @@ curl -X POST -H "Content-Type: application/json" \
 EOF
 */
 type LoginHandler struct {
-	TokenStore       authtoken.Store    `inject:"TokenStore"`
-	ProviderRegistry *provider.Registry `inject:"ProviderRegistry"`
-	HookRegistry     *hook.Registry     `inject:"HookRegistry"`
-	AssetStore       asset.Store        `inject:"AssetStore"`
-	AuthRecordKeys   [][]string         `inject:"AuthRecordKeys"`
-	AccessKey        router.Processor   `preprocessor:"accesskey"`
-	DBConn           router.Processor   `preprocessor:"dbconn"`
-	InjectPublicDB   router.Processor   `preprocessor:"inject_public_db"`
-	PluginReady      router.Processor   `preprocessor:"plugin_ready"`
+	TokenStore       authtoken.Store     `inject:"TokenStore"`
+	ProviderRegistry *provider.Registry  `inject:"ProviderRegistry"`
+	HookRegistry     *hook.Registry      `inject:"HookRegistry"`
+	AssetStore       asset.Store         `inject:"AssetStore"`
+	AuthRecordKeys   [][]string          `inject:"AuthRecordKeys"`
+	Webhook          *webhook.Dispatcher `inject:"WebhookDispatcher"`
+	AuditStream      *audit.Stream       `inject:"AuditStream"`
+	AccessKey        router.Processor    `preprocessor:"accesskey"`
+	DBConn           router.Processor    `preprocessor:"dbconn"`
+	InjectPublicDB   router.Processor    `preprocessor:"inject_public_db"`
+	PluginReady      router.Processor    `preprocessor:"plugin_ready"`
 	preprocessors    []router.Processor
 }
 
@@ -336,6 +359,15 @@ func (h *LoginHandler) Handle(payload *router.Payload, response *router.Response
 	}
 
 	if skyErr = handleLoginFunc(payload, p, &info, &user); skyErr != nil {
+		h.AuditStream.Emit(payload.Req, audit.Event{
+			Type:   audit.LoginFailed,
+			UserID: info.ID,
+			Data: map[string]interface{}{
+				"provider":  p.Provider,
+				"auth_data": p.AuthData.GetData(),
+				"reason":    skyErr.Name(),
+			},
+		})
 		response.Err = skyErr
 		return
 	}
@@ -376,6 +408,20 @@ func (h *LoginHandler) Handle(payload *router.Payload, response *router.Response
 		return
 	}
 
+	h.Webhook.Dispatch(webhook.AuthLogin, "", map[string]interface{}{
+		"user_id": info.ID,
+	})
+	h.AuditStream.Emit(payload.Req, audit.Event{
+		Type:   audit.LoginSucceeded,
+		UserID: info.ID,
+		Data: map[string]interface{}{
+			"provider": p.Provider,
+		},
+	})
+	h.AuditStream.Emit(payload.Req, audit.Event{
+		Type:   audit.TokenIssued,
+		UserID: info.ID,
+	})
 	response.Result = authResponse
 }
 
@@ -444,6 +490,13 @@ func (h *LoginHandler) handleLoginWithAuthData(payload *router.Payload, p *login
 		return skyerr.NewError(skyerr.InvalidCredentials, "auth_data or password incorrect")
 	}
 
+	// Password hashes generated with other algorithms or parameters, such
+	// as imported hashes, are upgraded to the server's preferred ones; the
+	// auth info is saved when the activity time is updated.
+	if authinfo.NeedsRehash() {
+		authinfo.RehashPassword(p.Password)
+	}
+
 	return nil
 }
 
@@ -466,6 +519,7 @@ func (h *LoginHandler) authPrincipal(ctx context.Context, p *loginPayload) (stri
 // LogoutHandler receives an access token and invalidates it
 type LogoutHandler struct {
 	TokenStore    authtoken.Store  `inject:"TokenStore"`
+	AuditStream   *audit.Stream    `inject:"AuditStream"`
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
@@ -496,6 +550,10 @@ func (h *LogoutHandler) Handle(payload *router.Payload, response *router.Respons
 	if err != nil {
 		response.Err = skyerr.MakeError(err)
 	} else {
+		h.AuditStream.Emit(payload.Req, audit.Event{
+			Type:   audit.TokenRevoked,
+			UserID: payload.AuthInfoID,
+		})
 		response.Result = struct {
 			Status string `json:"status,omitempty"`
 		}{
@@ -551,8 +609,10 @@ func (payload *passwordPayload) Validate() skyerr.Error {
 type PasswordHandler struct {
 	TokenStore    authtoken.Store  `inject:"TokenStore"`
 	AssetStore    asset.Store      `inject:"AssetStore"`
+	AuditStream   *audit.Stream    `inject:"AuditStream"`
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	InjectUser    router.Processor `preprocessor:"inject_user"`
 	RequireAuth   router.Processor `preprocessor:"require_auth"`
@@ -564,6 +624,7 @@ func (h *PasswordHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectUser,
 		h.RequireAuth,
@@ -611,6 +672,15 @@ func (h *PasswordHandler) Handle(payload *router.Payload, response *router.Respo
 		panic(err)
 	}
 
+	h.AuditStream.Emit(payload.Req, audit.Event{
+		Type:   audit.PasswordChanged,
+		UserID: info.ID,
+	})
+	h.AuditStream.Emit(payload.Req, audit.Event{
+		Type:   audit.TokenIssued,
+		UserID: info.ID,
+	})
+
 	user := payload.User
 	if user == nil {
 		user = &skydb.Record{}
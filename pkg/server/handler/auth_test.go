@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/skygeario/skygear-server/pkg/server/audit"
 	"github.com/skygeario/skygear-server/pkg/server/authtoken"
 	"github.com/skygeario/skygear-server/pkg/server/authtoken/authtokentest"
 	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
@@ -586,9 +587,66 @@ func TestLoginHandler(t *testing.T) {
 			errorResponse := resp.Err.(skyerr.Error)
 			So(errorResponse.Code(), ShouldEqual, skyerr.ResourceNotFound)
 		})
+
+		Convey("emits auth events", func() {
+			sink := &auditSink{}
+			handler.AuditStream = audit.NewStream("myapp", 10, sink)
+
+			authinfo := skydb.NewAuthInfo("secret")
+			conn.CreateAuth(&authinfo)
+
+			db.EXPECT().
+				Query(gomock.Any()).
+				Do(MakeUsernameEmailQueryAssertion("john.doe", "")).
+				Return(skydb.NewRows(skydb.NewMemoryRows([]skydb.Record{skydb.Record{
+					ID:   skydb.NewRecordID("user", authinfo.ID),
+					Data: map[string]interface{}{"username": "john.doe"},
+				}})), nil).
+				AnyTimes()
+
+			for _, password := range []string{"wrongsecret", "secret"} {
+				req := router.Payload{
+					Data: map[string]interface{}{
+						"auth_data": map[string]interface{}{
+							"username": "john.doe",
+						},
+						"password": password,
+					},
+					DBConn:   conn,
+					Database: db,
+				}
+				handler.Handle(&req, &router.Response{})
+			}
+			handler.AuditStream.Close()
+			handler.AuditStream.Run()
+
+			So(sink.events, ShouldHaveLength, 3)
+			So(sink.events[0].Type, ShouldEqual, audit.LoginFailed)
+			So(sink.events[0].UserID, ShouldEqual, authinfo.ID)
+			So(sink.events[0].Data, ShouldResemble, map[string]interface{}{
+				"provider": "",
+				"auth_data": map[string]interface{}{
+					"username": "john.doe",
+				},
+				"reason": "InvalidCredentials",
+			})
+			So(sink.events[1].Type, ShouldEqual, audit.LoginSucceeded)
+			So(sink.events[1].UserID, ShouldEqual, authinfo.ID)
+			So(sink.events[2].Type, ShouldEqual, audit.TokenIssued)
+			So(sink.events[2].UserID, ShouldEqual, authinfo.ID)
+		})
 	})
 }
 
+type auditSink struct {
+	events []audit.Event
+}
+
+func (s *auditSink) Write(event audit.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
 func TestLoginHandlerWithProvider(t *testing.T) {
 	Convey("LoginHandler", t, func() {
 		realTime := timeNow
@@ -821,6 +879,10 @@ func (conn *singleUserConn) GetRecordFieldAccess() (skydb.FieldACL, error) {
 	return skydb.FieldACL{}, nil
 }
 
+func (conn *singleUserConn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	return nil, nil
+}
+
 func (conn *singleUserConn) EnsureAuthRecordKeysValid(authRecordKeys [][]string) error {
 	return nil
 }
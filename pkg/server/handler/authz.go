@@ -0,0 +1,164 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"github.com/skygeario/skygear-server/pkg/server/authzconfig"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type authzImportPayload struct {
+	Document authzconfig.Document
+}
+
+func (payload *authzImportPayload) Decode(data map[string]interface{}) skyerr.Error {
+	m, ok := data["document"].(map[string]interface{})
+	if !ok {
+		return skyerr.NewInvalidArgument("expected document object", []string{"document"})
+	}
+	if err := payload.Document.FromMap(m); err != nil {
+		return skyerr.NewInvalidArgument(err.Error(), []string{"document"})
+	}
+	return payload.Validate()
+}
+
+func (payload *authzImportPayload) Validate() skyerr.Error {
+	return nil
+}
+
+/*
+AuthzExportHandler exports the authorization configuration, which
+includes roles, admin roles, default roles, the creation access and
+default access of each record type, and the field ACL. Master key is
+required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "authz:export",
+    "master_key": "MASTER_KEY"
+}
+EOF
+
+{
+    "result": {
+        "roles": ["admin", "user", "writer"],
+        "admin_roles": ["admin"],
+        "default_roles": ["user"],
+        "record_types": {
+            "note": {
+                "create_roles": ["writer"],
+                "default_access": [{"role": "user", "level": "read"}]
+            }
+        },
+        "field_access": []
+    }
+}
+*/
+type AuthzExportHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *AuthzExportHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *AuthzExportHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *AuthzExportHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	doc, err := authzconfig.Export(rpayload.DBConn)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = doc
+}
+
+/*
+AuthzImportHandler applies an authorization configuration exported by
+authz:export. Applying the same document again does not change the
+configuration. Configuration not mentioned in the document is left
+unchanged. Master key is required.
+
+The result is the authorization configuration after the import.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "authz:import",
+    "master_key": "MASTER_KEY",
+    "document": {
+        "admin_roles": ["admin"],
+        "record_types": {
+            "note": {
+                "create_roles": ["writer"]
+            }
+        }
+    }
+}
+EOF
+*/
+type AuthzImportHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *AuthzImportHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *AuthzImportHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *AuthzImportHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &authzImportPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if err := authzconfig.Apply(rpayload.DBConn, &payload.Document); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	doc, err := authzconfig.Export(rpayload.DBConn)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = doc
+}
@@ -0,0 +1,134 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/authzconfig"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type authzConn struct {
+	skydb.Conn
+	roles      []string
+	adminRoles []string
+}
+
+func (c *authzConn) PublicDB() skydb.Database {
+	return skydbtest.NewMapDB()
+}
+
+func (c *authzConn) GetAllRoles() ([]string, error) {
+	return c.roles, nil
+}
+
+func (c *authzConn) EnsureRoles(roles []string) error {
+	for _, role := range roles {
+		found := false
+		for _, existing := range c.roles {
+			found = found || existing == role
+		}
+		if !found {
+			c.roles = append(c.roles, role)
+		}
+	}
+	return nil
+}
+
+func (c *authzConn) GetAdminRoles() ([]string, error) {
+	return c.adminRoles, nil
+}
+
+func (c *authzConn) SetAdminRoles(roles []string) error {
+	c.adminRoles = roles
+	return nil
+}
+
+func (c *authzConn) GetDefaultRoles() ([]string, error) {
+	return []string{}, nil
+}
+
+func (c *authzConn) GetRecordFieldAccess() (skydb.FieldACL, error) {
+	return skydb.FieldACL{}, nil
+}
+
+func (c *authzConn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	return nil, nil
+}
+
+func TestAuthzImportHandler(t *testing.T) {
+	Convey("AuthzImportHandler", t, func() {
+		conn := &authzConn{
+			roles:      []string{"admin"},
+			adminRoles: []string{"admin"},
+		}
+		handler := &AuthzImportHandler{}
+
+		Convey("applies document", func() {
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"document": map[string]interface{}{
+						"admin_roles": []interface{}{"moderator"},
+					},
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, &authzconfig.Document{
+				Roles:        []string{"admin", "moderator"},
+				AdminRoles:   []string{"moderator"},
+				DefaultRoles: []string{},
+				RecordTypes:  map[string]authzconfig.RecordTypeAccess{},
+				FieldAccess:  skydb.FieldACLEntryList{},
+			})
+		})
+
+		Convey("rejects invalid document", func() {
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"document": map[string]interface{}{
+						"admin_roles": "moderator",
+					},
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldNotBeNil)
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(conn.adminRoles, ShouldResemble, []string{"admin"})
+		})
+
+		Convey("rejects missing document", func() {
+			req := router.Payload{
+				DBConn: conn,
+				Data:   map[string]interface{}{},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+	})
+}
@@ -0,0 +1,260 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/recordutil"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type counterResult struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
+
+// counterPayload identifies a counter either by a field of a record or,
+// with the master key, by an arbitrary name.
+type counterPayload struct {
+	RawID    string `mapstructure:"record_id"`
+	Field    string `mapstructure:"field"`
+	Name     string `mapstructure:"name"`
+	RecordID skydb.RecordID
+}
+
+func (payload *counterPayload) Validate() skyerr.Error {
+	if payload.RawID == "" {
+		if payload.Name == "" {
+			return skyerr.NewInvalidArgument("unspecified record_id or name in request", []string{"record_id", "name"})
+		}
+		return nil
+	}
+
+	if payload.Name != "" {
+		return skyerr.NewInvalidArgument("only one of record_id and name can be specified", []string{"record_id", "name"})
+	}
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "record_id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+
+	if payload.Field == "" {
+		return skyerr.NewInvalidArgument("unspecified field in request", []string{"field"})
+	}
+	payload.Name = recordID.String() + "/" + payload.Field
+	return nil
+}
+
+// checkCounterAccess checks whether the counter can be accessed. A counter
+// attached to a record field inherits the access control of the record
+// and the field, while a counter identified by an arbitrary name can only
+// be accessed with the master key.
+func checkCounterAccess(rpayload *router.Payload, payload *counterPayload, level skydb.RecordACLLevel, mode skydb.FieldAccessMode) skyerr.Error {
+	if payload.RecordID.IsEmpty() {
+		if !rpayload.HasMasterKey() {
+			return skyerr.NewError(skyerr.PermissionDenied, "no permission to access counter by name")
+		}
+		return nil
+	}
+
+	fetcher := recordutil.NewRecordFetcher(rpayload.Database, rpayload.DBConn, rpayload.HasMasterKey())
+	record, skyErr := fetcher.FetchRecord(payload.RecordID, rpayload.AuthInfo, level)
+	if skyErr != nil {
+		return skyErr
+	}
+	if rpayload.HasMasterKey() {
+		return nil
+	}
+
+	fieldACL, err := rpayload.DBConn.GetRecordFieldAccess()
+	if err != nil {
+		return skyerr.MakeError(err)
+	}
+	if !fieldACL.Accessible(payload.RecordID.Type, payload.Field, mode, rpayload.AuthInfo, record) {
+		return skyerr.NewError(skyerr.PermissionDenied, "no permission to access counter of the field")
+	}
+	return nil
+}
+
+type counterIncrementPayload struct {
+	counterPayload `mapstructure:",squash"`
+	Delta          int64 `mapstructure:"delta"`
+}
+
+func (payload *counterIncrementPayload) Decode(data map[string]interface{}) skyerr.Error {
+	// increment by one if delta is not specified
+	payload.Delta = 1
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+// CounterIncrementHandler atomically increments a counter by delta and
+// returns the value of the counter after the increment. Delta defaults
+// to 1 and can be negative.
+//
+// A counter is attached to a field of a record, and write access to both
+// the record and the field is required. Counters with arbitrary names
+// can be incremented with the master key.
+// curl -X POST -H "Content-Type: application/json" \
+//   -d @- http://localhost:3000/ <<EOF
+// {
+//     "action": "counter:increment",
+//     "access_token": "ACCESS_TOKEN",
+//     "record_id": "note/1001",
+//     "field": "likes",
+//     "delta": 1
+// }
+// EOF
+//
+// {
+//     "request_id": "REQUEST_ID",
+//     "result": {
+//         "name": "note/1001/likes",
+//         "value": 42
+//     }
+// }
+type CounterIncrementHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *CounterIncrementHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *CounterIncrementHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *CounterIncrementHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &counterIncrementPayload{}
+	skyErr := payload.Decode(rpayload.Data)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	skyErr = checkCounterAccess(rpayload, &payload.counterPayload, skydb.WriteLevel, skydb.WriteFieldAccessMode)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	value, err := rpayload.DBConn.IncrementCounter(payload.Name, payload.Delta)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = counterResult{payload.Name, value}
+}
+
+type counterGetPayload struct {
+	counterPayload `mapstructure:",squash"`
+}
+
+func (payload *counterGetPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+// CounterGetHandler returns the current value of a counter.
+//
+// Read access to the record and the field is required. Counters with
+// arbitrary names can be read with the master key.
+// curl -X POST -H "Content-Type: application/json" \
+//   -d @- http://localhost:3000/ <<EOF
+// {
+//     "action": "counter:get",
+//     "access_token": "ACCESS_TOKEN",
+//     "record_id": "note/1001",
+//     "field": "likes"
+// }
+// EOF
+//
+// {
+//     "request_id": "REQUEST_ID",
+//     "result": {
+//         "name": "note/1001/likes",
+//         "value": 42
+//     }
+// }
+type CounterGetHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *CounterGetHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.PluginReady,
+	}
+}
+
+func (h *CounterGetHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *CounterGetHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &counterGetPayload{}
+	skyErr := payload.Decode(rpayload.Data)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	skyErr = checkCounterAccess(rpayload, &payload.counterPayload, skydb.ReadLevel, skydb.ReadFieldAccessMode)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	value, err := rpayload.DBConn.GetCounter(payload.Name)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = counterResult{payload.Name, value}
+}
@@ -0,0 +1,297 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type counterConn struct {
+	*skydbtest.MapConn
+	counters map[string]int64
+	err      error
+}
+
+func (conn *counterConn) IncrementCounter(name string, delta int64) (int64, error) {
+	if conn.err != nil {
+		return 0, conn.err
+	}
+	conn.counters[name] += delta
+	return conn.counters[name], nil
+}
+
+func (conn *counterConn) GetCounter(name string) (int64, error) {
+	if conn.err != nil {
+		return 0, conn.err
+	}
+	return conn.counters[name], nil
+}
+
+func newCounterDB() *skydbtest.MapDB {
+	db := skydbtest.NewMapDB()
+	db.Save(&skydb.Record{
+		ID:      skydb.NewRecordID("note", "1001"),
+		OwnerID: "user1",
+		ACL: skydb.RecordACL{
+			skydb.NewRecordACLEntryPublic(skydb.ReadLevel),
+		},
+	})
+	db.Save(&skydb.Record{
+		ID:      skydb.NewRecordID("note", "secret"),
+		OwnerID: "user1",
+		ACL: skydb.RecordACL{
+			skydb.NewRecordACLEntryDirect("user1", skydb.WriteLevel),
+		},
+	})
+	return db
+}
+
+func TestCounterIncrementHandler(t *testing.T) {
+	Convey("CounterIncrementHandler", t, func() {
+		conn := &counterConn{
+			MapConn: skydbtest.NewMapConn(),
+			counters: map[string]int64{
+				"note/1001/likes": 41,
+				"likes":           41,
+			},
+		}
+		db := newCounterDB()
+		authInfo := &skydb.AuthInfo{ID: "user1"}
+		accessKey := router.ClientAccessKey
+		r := handlertest.NewSingleRouteRouter(&CounterIncrementHandler{}, func(p *router.Payload) {
+			p.DBConn = conn
+			p.Database = db
+			p.AuthInfoID = authInfo.ID
+			p.AuthInfo = authInfo
+			p.AccessKey = accessKey
+		})
+
+		Convey("increment by one by default", func() {
+			resp := r.POST(`{"record_id": "note/1001", "field": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"name": "note/1001/likes",
+		"value": 42
+	}
+}`)
+			So(conn.counters["note/1001/likes"], ShouldEqual, 42)
+		})
+
+		Convey("increment by delta", func() {
+			resp := r.POST(`{"record_id": "note/1001", "field": "likes", "delta": -5}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"name": "note/1001/likes",
+		"value": 36
+	}
+}`)
+		})
+
+		Convey("reject increment without write access to the record", func() {
+			authInfo.ID = "user2"
+			resp := r.POST(`{"record_id": "note/1001", "field": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 102,
+		"message": "no permission to perform operation",
+		"name": "PermissionDenied"
+	}
+}`)
+			So(conn.counters["note/1001/likes"], ShouldEqual, 41)
+		})
+
+		Convey("reject increment without write access to the field", func() {
+			conn.SetRecordFieldAccess(skydb.NewFieldACL(skydb.FieldACLEntryList{
+				{
+					RecordType:  "note",
+					RecordField: "likes",
+					UserRole:    skydb.FieldUserRole{skydb.PublicFieldUserRoleType, ""},
+					Readable:    true,
+				},
+			}))
+
+			resp := r.POST(`{"record_id": "note/1001", "field": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 102,
+		"message": "no permission to access counter of the field",
+		"name": "PermissionDenied"
+	}
+}`)
+			So(conn.counters["note/1001/likes"], ShouldEqual, 41)
+		})
+
+		Convey("reject increment of counter of record not found", func() {
+			resp := r.POST(`{"record_id": "note/1002", "field": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 110,
+		"message": "record not found",
+		"name": "ResourceNotFound"
+	}
+}`)
+		})
+
+		Convey("reject increment of counter by name without master key", func() {
+			resp := r.POST(`{"name": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 102,
+		"message": "no permission to access counter by name",
+		"name": "PermissionDenied"
+	}
+}`)
+			So(conn.counters["likes"], ShouldEqual, 41)
+		})
+
+		Convey("increment counter by name with master key", func() {
+			accessKey = router.MasterAccessKey
+			resp := r.POST(`{"name": "likes", "delta": 2}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"name": "likes",
+		"value": 43
+	}
+}`)
+		})
+
+		Convey("reject request without record_id or name", func() {
+			resp := r.POST(`{"delta": 1}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 108,
+		"message": "unspecified record_id or name in request",
+		"info": {
+			"arguments": [
+				"record_id",
+				"name"
+			]
+		},
+		"name": "InvalidArgument"
+	}
+}`)
+		})
+
+		Convey("reject request without field", func() {
+			resp := r.POST(`{"record_id": "note/1001"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 108,
+		"message": "unspecified field in request",
+		"info": {
+			"arguments": [
+				"field"
+			]
+		},
+		"name": "InvalidArgument"
+	}
+}`)
+		})
+
+		Convey("return error from conn", func() {
+			conn.err = errors.New("some error")
+			resp := r.POST(`{"record_id": "note/1001", "field": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 10000,
+		"message": "some error",
+		"name": "UnexpectedError"
+	}
+}`)
+		})
+	})
+}
+
+func TestCounterGetHandler(t *testing.T) {
+	Convey("CounterGetHandler", t, func() {
+		conn := &counterConn{
+			MapConn: skydbtest.NewMapConn(),
+			counters: map[string]int64{
+				"note/1001/likes":   42,
+				"note/secret/likes": 7,
+				"likes":             42,
+			},
+		}
+		db := newCounterDB()
+		accessKey := router.ClientAccessKey
+		r := handlertest.NewSingleRouteRouter(&CounterGetHandler{}, func(p *router.Payload) {
+			p.DBConn = conn
+			p.Database = db
+			p.AccessKey = accessKey
+		})
+
+		Convey("get counter", func() {
+			resp := r.POST(`{"record_id": "note/1001", "field": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"name": "note/1001/likes",
+		"value": 42
+	}
+}`)
+		})
+
+		Convey("get counter never incremented", func() {
+			resp := r.POST(`{"record_id": "note/1001", "field": "shares"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"name": "note/1001/shares",
+		"value": 0
+	}
+}`)
+		})
+
+		Convey("reject get without read access to the record", func() {
+			resp := r.POST(`{"record_id": "note/secret", "field": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 102,
+		"message": "no permission to perform operation",
+		"name": "PermissionDenied"
+	}
+}`)
+		})
+
+		Convey("reject get counter by name without master key", func() {
+			resp := r.POST(`{"name": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 102,
+		"message": "no permission to access counter by name",
+		"name": "PermissionDenied"
+	}
+}`)
+		})
+
+		Convey("get counter by name with master key", func() {
+			accessKey = router.MasterAccessKey
+			resp := r.POST(`{"name": "likes"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"name": "likes",
+		"value": 42
+	}
+}`)
+		})
+	})
+}
@@ -77,6 +77,15 @@ type DeviceReigsterResult struct {
 
 // DeviceRegisterHandler creates or updates a device and associates it to a user
 //
+// Other devices registered with the same device token are removed as
+// configured by the device token policy:
+//
+//   - transfer (default): the token is transferred from the devices of all
+//     users, so that a device shared by users only receives notifications
+//     of the user registering it last.
+//   - shared: only the other devices of the same user are removed, so that
+//     the token may be registered by multiple users.
+//
 // Example to create a new device:
 //
 //	curl -X POST -H "Content-Type: application/json" \
@@ -104,8 +113,10 @@ type DeviceReigsterResult struct {
 //	EOF
 //
 type DeviceRegisterHandler struct {
+	TokenPolicy   string           `inject:"DeviceTokenPolicy"`
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	RequireAuth   router.Processor `preprocessor:"require_auth"`
@@ -117,6 +128,7 @@ func (h *DeviceRegisterHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.RequireAuth,
@@ -159,9 +171,13 @@ func (h *DeviceRegisterHandler) Handle(rpayload *router.Payload, response *route
 		}
 	}
 
-	// delete all devices with the same token
-	if err := conn.DeleteDevicesByToken(payload.DeviceToken, skydb.ZeroTime); err != nil {
-		if err != skydb.ErrDeviceNotFound {
+	if payload.DeviceToken != "" {
+		if err := h.removeDuplicates(conn, device.ID, payload.DeviceToken, rpayload.AuthInfoID); err != nil {
+			log.WithFields(logrus.Fields{
+				"deviceID": device.ID,
+				"err":      err,
+			}).Errorln("Failed to remove devices with the same token")
+
 			response.Err = skyerr.NewResourceDeleteFailureErrWithStringID("device", "")
 			return
 		}
@@ -186,6 +202,34 @@ func (h *DeviceRegisterHandler) Handle(rpayload *router.Payload, response *route
 	}
 }
 
+// removeDuplicates removes devices other than the registering one with the
+// same token as configured by the token policy.
+func (h *DeviceRegisterHandler) removeDuplicates(conn skydb.Conn, deviceID string, token string, userID string) error {
+	if h.TokenPolicy != "shared" {
+		// The registering device is saved afterwards, so it is
+		// fine to be removed as well.
+		err := conn.DeleteDevicesByToken(token, skydb.ZeroTime)
+		if err == skydb.ErrDeviceNotFound {
+			return nil
+		}
+		return err
+	}
+
+	devices, err := conn.QueryDevicesByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		if device.ID == deviceID || device.Token != token {
+			continue
+		}
+		if err := conn.DeleteDevice(device.ID); err != nil && err != skydb.ErrDeviceNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
 // DeviceUnregisterHandler removes user id from a device
 //
 // Example to unregister a device:
@@ -203,6 +247,7 @@ func (h *DeviceRegisterHandler) Handle(rpayload *router.Payload, response *route
 type DeviceUnregisterHandler struct {
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	RequireAuth   router.Processor `preprocessor:"require_auth"`
@@ -214,6 +259,7 @@ func (h *DeviceUnregisterHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.RequireAuth,
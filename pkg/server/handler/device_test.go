@@ -61,6 +61,16 @@ func (conn *naiveConn) SaveDevice(device *skydb.Device) error {
 	return nil
 }
 
+func (conn *naiveConn) QueryDevicesByUser(user string) ([]skydb.Device, error) {
+	devices := []skydb.Device{}
+	for _, device := range conn.devices {
+		if device.AuthInfoID == user {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
 func (conn *naiveConn) DeleteDevice(id string) error {
 	if conn.mockDeleteError != nil {
 		return conn.mockDeleteError
@@ -197,6 +207,43 @@ func TestDeviceRegisterHandler(t *testing.T) {
 			So(conn.devices["existing_id"], ShouldResemble, skydb.Device{})
 		})
 
+		Convey("keeps devices of other users with the same token if shared", func() {
+			otherUserDevice := skydb.Device{
+				ID:               "other_user_device",
+				Type:             "ios",
+				Token:            "existing_token",
+				Topic:            "existing_topic",
+				AuthInfoID:       "existing_user",
+				LastRegisteredAt: time.Date(2005, 1, 2, 15, 4, 5, 0, time.UTC),
+			}
+			sameUserDevice := skydb.Device{
+				ID:               "same_user_device",
+				Type:             "ios",
+				Token:            "existing_token",
+				Topic:            "existing_topic",
+				AuthInfoID:       "authinfoid",
+				LastRegisteredAt: time.Date(2005, 1, 2, 15, 4, 5, 0, time.UTC),
+			}
+			So(conn.SaveDevice(&otherUserDevice), ShouldBeNil)
+			So(conn.SaveDevice(&sameUserDevice), ShouldBeNil)
+
+			payload.Data = map[string]interface{}{
+				"type":         "ios",
+				"device_token": "existing_token",
+				"topic":        "existing_topic",
+			}
+
+			handler := &DeviceRegisterHandler{TokenPolicy: "shared"}
+			handler.Handle(&payload, &resp)
+
+			result := resp.Result.(DeviceReigsterResult)
+			So(result.ID, ShouldNotBeEmpty)
+			So(conn.devices[result.ID].Token, ShouldEqual, "existing_token")
+			So(conn.devices["other_user_device"], ShouldResemble, otherUserDevice)
+			_, ok := conn.devices["same_user_device"]
+			So(ok, ShouldBeFalse)
+		})
+
 		Convey("complains on empty device type", func() {
 			payload.Data = map[string]interface{}{
 				"device_token": "token",
@@ -0,0 +1,303 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+const (
+	// defaultDuplicatePairLimit is the number of candidate duplicate pairs
+	// considered if limit is not specified.
+	defaultDuplicatePairLimit = 1000
+	// maxDuplicatePairLimit is the maximum number of candidate duplicate
+	// pairs considered in a request.
+	maxDuplicatePairLimit = 10000
+)
+
+func parseRecordIDArgument(rawID string, argument string) (skydb.RecordID, skyerr.Error) {
+	ss := strings.SplitN(rawID, "/", 2)
+	if len(ss) != 2 || ss[0] == "" || ss[1] == "" {
+		return skydb.RecordID{}, skyerr.NewInvalidArgument(
+			`record: "`+argument+`" should be of format '{type}/{id}', got "`+rawID+`"`,
+			[]string{argument},
+		)
+	}
+	return skydb.NewRecordID(ss[0], ss[1]), nil
+}
+
+type duplicateKeyPayload struct {
+	Field      string  `mapstructure:"field"`
+	Similarity float64 `mapstructure:"similarity"`
+}
+
+type recordDuplicatesPayload struct {
+	RecordType string                `mapstructure:"record_type"`
+	Keys       []duplicateKeyPayload `mapstructure:"keys"`
+	Limit      int                   `mapstructure:"limit"`
+}
+
+func (payload *recordDuplicatesPayload) Decode(data map[string]interface{}) skyerr.Error {
+	payload.Limit = defaultDuplicatePairLimit
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *recordDuplicatesPayload) Validate() skyerr.Error {
+	if payload.RecordType == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"record_type"})
+	}
+	if len(payload.Keys) == 0 {
+		return skyerr.NewInvalidArgument("at least one key is required", []string{"keys"})
+	}
+	for _, key := range payload.Keys {
+		if key.Field == "" {
+			return skyerr.NewInvalidArgument("key field is required", []string{"keys"})
+		}
+		if key.Similarity < 0 || key.Similarity > 1 {
+			return skyerr.NewInvalidArgument("similarity must be between 0 and 1", []string{"keys"})
+		}
+	}
+	if payload.Limit <= 0 || payload.Limit > maxDuplicatePairLimit {
+		return skyerr.NewInvalidArgument("limit must be between 1 and 10000", []string{"limit"})
+	}
+	return nil
+}
+
+func (payload *recordDuplicatesPayload) DuplicateKeys() []skydb.DuplicateKey {
+	keys := make([]skydb.DuplicateKey, len(payload.Keys))
+	for i, key := range payload.Keys {
+		keys[i] = skydb.DuplicateKey{
+			Field:      key.Field,
+			Similarity: key.Similarity,
+		}
+	}
+	return keys
+}
+
+/*
+RecordDuplicatesHandler finds groups of records that are candidate
+duplicates of each other. Two records are candidate duplicates if all keys
+match. A key without similarity matches if the field values are equal;
+otherwise the field values are compared by trigram similarity, which must
+be at least the specified similarity.
+
+At most limit pairs of candidate duplicates are considered, defaulting to
+1000.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:duplicates",
+    "access_token": "ACCESS_TOKEN",
+    "record_type": "contact",
+    "keys": [
+        {"field": "email"},
+        {"field": "name", "similarity": 0.6}
+    ]
+}
+EOF
+
+{
+    "result": {
+        "groups": [
+            ["contact/1", "contact/2"]
+        ]
+    }
+}
+*/
+type RecordDuplicatesHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordDuplicatesHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAdmin,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordDuplicatesHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordDuplicatesHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &recordDuplicatesPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	groups, err := rpayload.Database.FindDuplicates(
+		payload.RecordType, payload.DuplicateKeys(), payload.Limit)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	result := make([][]string, len(groups))
+	for i, group := range groups {
+		result[i] = make([]string, len(group))
+		for j, recordID := range group {
+			result[i][j] = recordID.String()
+		}
+	}
+	response.Result = map[string]interface{}{
+		"groups": result,
+	}
+}
+
+type recordMergePayload struct {
+	RawWinnerID string   `mapstructure:"winner"`
+	RawLoserIDs []string `mapstructure:"losers"`
+	WinnerID    skydb.RecordID
+	LoserIDs    []skydb.RecordID
+}
+
+func (payload *recordMergePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *recordMergePayload) Validate() skyerr.Error {
+	winnerID, skyErr := parseRecordIDArgument(payload.RawWinnerID, "winner")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.WinnerID = winnerID
+
+	if len(payload.RawLoserIDs) == 0 {
+		return skyerr.NewInvalidArgument("at least one loser is required", []string{"losers"})
+	}
+
+	payload.LoserIDs = make([]skydb.RecordID, len(payload.RawLoserIDs))
+	for i, rawID := range payload.RawLoserIDs {
+		loserID, skyErr := parseRecordIDArgument(rawID, "losers")
+		if skyErr != nil {
+			return skyErr
+		}
+		if loserID.Type != winnerID.Type {
+			return skyerr.NewInvalidArgument("losers must be of the same type as winner", []string{"losers"})
+		}
+		if loserID.Key == winnerID.Key {
+			return skyerr.NewInvalidArgument("winner cannot be a loser", []string{"losers"})
+		}
+		payload.LoserIDs[i] = loserID
+	}
+	return nil
+}
+
+/*
+RecordMergeHandler merges duplicate records (losers) into a record (winner).
+The ACL entries of the losers are added to the winner, references to
+the losers are rewritten to reference the winner, and the losers are
+archived and removed.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:merge",
+    "access_token": "ACCESS_TOKEN",
+    "winner": "contact/1",
+    "losers": ["contact/2"]
+}
+EOF
+
+{
+    "result": {
+        "id": "contact/1",
+        "merged": ["contact/2"]
+    }
+}
+*/
+type RecordMergeHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordMergeHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAdmin,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordMergeHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordMergeHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &recordMergePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	db := rpayload.Database
+	merge := func() error {
+		return db.MergeRecords(payload.WinnerID, payload.LoserIDs)
+	}
+
+	var err error
+	if txDB, ok := db.(skydb.Transactional); ok {
+		err = skydb.WithTransaction(txDB, merge)
+	} else {
+		err = merge()
+	}
+
+	if err == skydb.ErrRecordNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"id":     payload.WinnerID.String(),
+		"merged": payload.RawLoserIDs,
+	}
+}
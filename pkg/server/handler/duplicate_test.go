@@ -0,0 +1,174 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/mock_skydb"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+func TestRecordDuplicatesHandler(t *testing.T) {
+	Convey("RecordDuplicatesHandler", t, func() {
+		ctrl := gomock.NewController(handlertest.NewGoroutineAwareTestReporter(t))
+		defer ctrl.Finish()
+		db := mock_skydb.NewMockDatabase(ctrl)
+
+		r := handlertest.NewSingleRouteRouter(&RecordDuplicatesHandler{}, func(p *router.Payload) {
+			p.Database = db
+		})
+
+		Convey("should return groups of duplicates", func() {
+			db.EXPECT().FindDuplicates("contact", []skydb.DuplicateKey{
+				{Field: "email"},
+				{Field: "name", Similarity: 0.6},
+			}, 1000).Return([][]skydb.RecordID{
+				{skydb.NewRecordID("contact", "1"), skydb.NewRecordID("contact", "2")},
+			}, nil)
+
+			resp := r.POST(`{
+				"record_type": "contact",
+				"keys": [
+					{"field": "email"},
+					{"field": "name", "similarity": 0.6}
+				]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"groups": [["contact/1", "contact/2"]]
+				}
+			}`)
+		})
+
+		Convey("should reject missing keys", func() {
+			resp := r.POST(`{"record_type": "contact"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 108,
+					"message": "at least one key is required",
+					"name": "InvalidArgument",
+					"info": {"arguments": ["keys"]}
+				}
+			}`)
+		})
+
+		Convey("should reject similarity out of range", func() {
+			resp := r.POST(`{
+				"record_type": "contact",
+				"keys": [{"field": "name", "similarity": 1.5}]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 108,
+					"message": "similarity must be between 0 and 1",
+					"name": "InvalidArgument",
+					"info": {"arguments": ["keys"]}
+				}
+			}`)
+		})
+	})
+}
+
+func TestRecordMergeHandler(t *testing.T) {
+	Convey("RecordMergeHandler", t, func() {
+		ctrl := gomock.NewController(handlertest.NewGoroutineAwareTestReporter(t))
+		defer ctrl.Finish()
+		db := mock_skydb.NewMockTxDatabase(ctrl)
+
+		r := handlertest.NewSingleRouteRouter(&RecordMergeHandler{}, func(p *router.Payload) {
+			p.Database = db
+		})
+
+		Convey("should merge records in a transaction", func() {
+			gomock.InOrder(
+				db.EXPECT().Begin().Return(nil),
+				db.EXPECT().MergeRecords(
+					skydb.NewRecordID("contact", "1"),
+					[]skydb.RecordID{skydb.NewRecordID("contact", "2")},
+				).Return(nil),
+				db.EXPECT().Commit().Return(nil),
+			)
+
+			resp := r.POST(`{
+				"winner": "contact/1",
+				"losers": ["contact/2"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"id": "contact/1",
+					"merged": ["contact/2"]
+				}
+			}`)
+		})
+
+		Convey("should return not found if a record does not exist", func() {
+			gomock.InOrder(
+				db.EXPECT().Begin().Return(nil),
+				db.EXPECT().MergeRecords(gomock.Any(), gomock.Any()).
+					Return(skydb.ErrRecordNotFound),
+				db.EXPECT().Rollback().Return(nil),
+			)
+
+			resp := r.POST(`{
+				"winner": "contact/1",
+				"losers": ["contact/2"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 110,
+					"message": "record not found",
+					"name": "ResourceNotFound"
+				}
+			}`)
+		})
+
+		Convey("should reject losers of a different type", func() {
+			resp := r.POST(`{
+				"winner": "contact/1",
+				"losers": ["note/2"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 108,
+					"message": "losers must be of the same type as winner",
+					"name": "InvalidArgument",
+					"info": {"arguments": ["losers"]}
+				}
+			}`)
+		})
+
+		Convey("should reject winner as a loser", func() {
+			resp := r.POST(`{
+				"winner": "contact/1",
+				"losers": ["contact/1"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 108,
+					"message": "winner cannot be a loser",
+					"name": "InvalidArgument",
+					"info": {"arguments": ["losers"]}
+				}
+			}`)
+		})
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/failover"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+/*
+FailoverHandler promotes the standby database and switches the server to
+use it. Record writes are rejected for the configured grace period after
+failing over. Master key is required.
+
+The database is not connected to authenticate the request, so that the
+server can fail over while the primary database is down.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "_status:failover",
+    "master_key": "MASTER_KEY"
+}
+EOF
+*/
+type FailoverHandler struct {
+	Failover      *failover.Manager `inject:"Failover"`
+	Authenticator router.Processor  `preprocessor:"authenticator"`
+	preprocessors []router.Processor
+}
+
+func (h *FailoverHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+	}
+}
+
+func (h *FailoverHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *FailoverHandler) Handle(payload *router.Payload, response *router.Response) {
+	if !payload.HasMasterKey() {
+		response.Err = skyerr.NewError(skyerr.PermissionDenied, "failover requires master key")
+		return
+	}
+
+	if !h.Failover.Enabled() {
+		response.Err = skyerr.NewError(skyerr.NotSupported, "standby database is not configured")
+		return
+	}
+
+	err := h.Failover.Failover()
+	if err == failover.ErrFailedOver {
+		response.Err = skyerr.NewError(skyerr.InvalidArgument, "already failed over to standby database")
+		return
+	} else if err == failover.ErrFailingOver {
+		response.Err = skyerr.NewError(skyerr.InvalidArgument, "standby database is being promoted")
+		return
+	} else if err != nil {
+		response.Err = skyerr.NewError(skyerr.UnexpectedError, err.Error())
+		return
+	}
+
+	response.Result = h.Failover.Status()
+}
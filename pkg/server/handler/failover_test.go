@@ -0,0 +1,101 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/failover"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type failoverProber struct {
+	promoted bool
+}
+
+func (p *failoverProber) Ping(option string) error {
+	return nil
+}
+
+func (p *failoverProber) ReplicationLag(option string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (p *failoverProber) LockPromotion(option string) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func (p *failoverProber) Promote(option string) error {
+	p.promoted = true
+	return nil
+}
+
+func TestFailoverHandler(t *testing.T) {
+	Convey("FailoverHandler", t, func() {
+		prober := &failoverProber{}
+		handler := &FailoverHandler{
+			Failover: failover.NewManager("primary", "standby", prober),
+		}
+
+		Convey("fails over to standby database", func() {
+			req := router.Payload{
+				AccessKey: router.MasterAccessKey,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(prober.promoted, ShouldBeTrue)
+			So(resp.Result.(failover.Status).Active, ShouldEqual, failover.Standby)
+			So(handler.Failover.Option(), ShouldEqual, "standby")
+		})
+
+		Convey("rejects request without master key", func() {
+			req := router.Payload{}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.PermissionDenied)
+			So(prober.promoted, ShouldBeFalse)
+		})
+
+		Convey("rejects failing over twice", func() {
+			So(handler.Failover.Failover(), ShouldBeNil)
+
+			req := router.Payload{
+				AccessKey: router.MasterAccessKey,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("rejects without standby database", func() {
+			handler.Failover = failover.NewManager("primary", "", prober)
+
+			req := router.Payload{
+				AccessKey: router.MasterAccessKey,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.NotSupported)
+		})
+	})
+}
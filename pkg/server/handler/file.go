@@ -32,6 +32,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/tenant"
 )
 
 // used to clean file path
@@ -161,6 +162,7 @@ type UploadFileHandler struct {
 	AssetStore    skyAsset.Store   `inject:"AssetStore"`
 	AccessKey     router.Processor `preprocessor:"accesskey"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	preprocessors []router.Processor
 }
 
@@ -175,6 +177,7 @@ func (h *UploadFileHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.AccessKey,
 		h.DBConn,
+		h.Maintenance,
 	}
 }
 
@@ -225,7 +228,7 @@ func (h *UploadFileHandler) Handle(
 		dir, file := filepath.Split(uploadRequest.filename)
 		file = strings.Join([]string{uuidNew(), file}, "-")
 
-		asset.Name = filepath.Join(dir, file)
+		asset.Name = tenant.AssetName(payload.Tenant, filepath.Join(dir, file))
 		asset.ContentType = uploadRequest.contentType
 	}
 
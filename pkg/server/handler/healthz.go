@@ -16,14 +16,19 @@ package handler
 
 import (
 	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/failover"
 )
 
 type healthStatusResponse struct {
-	Status string `json:"status,omitempty"`
+	Status      string           `json:"status,omitempty"`
+	Replication *failover.Status `json:"replication,omitempty"`
 }
 
+// HealthzHandler returns the health status of the server. The replication
+// status is included if a standby database is configured.
 type HealthzHandler struct {
-	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	Failover      *failover.Manager `inject:"Failover"`
+	PluginReady   router.Processor  `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
 }
 
@@ -42,6 +47,10 @@ func (h *HealthzHandler) Handle(playload *router.Payload, response *router.Respo
 		rep healthStatusResponse
 	)
 	rep.Status = "OK"
+	if h.Failover.Enabled() {
+		status := h.Failover.Status()
+		rep.Replication = &status
+	}
 	response.Result = rep
 	return
 }
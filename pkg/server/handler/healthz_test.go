@@ -31,5 +31,6 @@ func TestHealthzHandler(t *testing.T) {
 		So(resp.Result, ShouldHaveSameTypeAs, healthStatusResponse{})
 		s := resp.Result.(healthStatusResponse)
 		So(s.Status, ShouldEqual, "OK")
+		So(s.Replication, ShouldBeNil)
 	})
 }
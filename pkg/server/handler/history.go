@@ -0,0 +1,189 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/recordutil"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+const (
+	defaultRecordHistoryLimit = 20
+	maxRecordHistoryLimit     = 100
+)
+
+type recordHistoryPayload struct {
+	RawID    string `mapstructure:"id"`
+	Limit    int    `mapstructure:"limit"`
+	RecordID skydb.RecordID
+}
+
+func (payload *recordHistoryPayload) Decode(data map[string]interface{}) skyerr.Error {
+	payload.Limit = defaultRecordHistoryLimit
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *recordHistoryPayload) Validate() skyerr.Error {
+	ss := strings.SplitN(payload.RawID, "/", 2)
+	if len(ss) != 2 || ss[0] == "" || ss[1] == "" {
+		return skyerr.NewInvalidArgument(
+			`record: "id" should be of format '{type}/{id}', got "`+payload.RawID+`"`,
+			[]string{"id"},
+		)
+	}
+	payload.RecordID = skydb.NewRecordID(ss[0], ss[1])
+
+	if payload.Limit <= 0 || payload.Limit > maxRecordHistoryLimit {
+		return skyerr.NewInvalidArgument("limit must be between 1 and 100", []string{"limit"})
+	}
+	return nil
+}
+
+type recordHistoryResult struct {
+	Action    skydb.RecordHistoryAction `json:"action"`
+	ActorID   string                    `json:"actor_id,omitempty"`
+	CreatedAt time.Time                 `json:"created_at"`
+	Record    *skyconv.JSONRecord       `json:"record"`
+}
+
+/*
+RecordHistoryHandler returns the change history of a record, newest first.
+Each entry contains the record before the change, which is null if the
+change created the record.
+
+Changes are recorded only for record types listed in
+DB_HISTORY_RECORD_TYPES. Read access to the record is required, and the
+history of a deleted record can only be read with the master key.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:history",
+    "access_token": "ACCESS_TOKEN",
+    "id": "note/1004",
+    "limit": 20
+}
+EOF
+
+{
+    "result": [
+        {
+            "action": "save",
+            "actor_id": "USER_ID",
+            "created_at": "2017-01-01T00:01:00Z",
+            "record": {
+                "_id": "note/1004",
+                "_type": "record",
+                "_access": null,
+                "content": "first draft"
+            }
+        },
+        {
+            "action": "save",
+            "actor_id": "USER_ID",
+            "created_at": "2017-01-01T00:00:00Z",
+            "record": null
+        }
+    ]
+}
+*/
+type RecordHistoryHandler struct {
+	AssetStore    asset.Store      `inject:"AssetStore"`
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordHistoryHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordHistoryHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordHistoryHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &recordHistoryPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	record := skydb.Record{}
+	err := rpayload.Database.Get(payload.RecordID, &record)
+	if err == skydb.ErrRecordNotFound {
+		if !rpayload.HasMasterKey() {
+			response.Err = skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+			return
+		}
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	} else if !rpayload.HasMasterKey() && !record.Accessible(rpayload.AuthInfo, skydb.ReadLevel) {
+		response.Err = skyerr.NewError(skyerr.PermissionDenied, "no permission to read the record history")
+		return
+	}
+
+	resultFilter, err := recordutil.NewRecordResultFilter(
+		rpayload.DBConn,
+		h.AssetStore,
+		rpayload.AuthInfo,
+		rpayload.HasMasterKey(),
+	)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	history, err := rpayload.Database.GetRecordHistory(payload.RecordID, payload.Limit)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	results := make([]recordHistoryResult, len(history))
+	for i, entry := range history {
+		results[i] = recordHistoryResult{
+			Action:    entry.Action,
+			ActorID:   entry.ActorID,
+			CreatedAt: entry.CreatedAt,
+			Record:    resultFilter.JSONResult(entry.Record),
+		}
+	}
+	response.Result = results
+}
@@ -0,0 +1,170 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type historyConn struct {
+	skydb.Conn
+}
+
+func (conn *historyConn) GetRecordFieldAccess() (skydb.FieldACL, error) {
+	return skydb.FieldACL{}, nil
+}
+
+func (conn *historyConn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	return nil, nil
+}
+
+type historyDB struct {
+	*skydbtest.MapDB
+	history map[skydb.RecordID][]skydb.RecordHistory
+}
+
+func (db *historyDB) GetRecordHistory(id skydb.RecordID, limit int) ([]skydb.RecordHistory, error) {
+	history := db.history[id]
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+func TestRecordHistoryHandler(t *testing.T) {
+	Convey("RecordHistoryHandler", t, func() {
+		noteID := skydb.NewRecordID("note", "1")
+		deletedID := skydb.NewRecordID("note", "2")
+		createdAt := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		db := &historyDB{
+			MapDB: skydbtest.NewMapDB(),
+			history: map[skydb.RecordID][]skydb.RecordHistory{
+				noteID: {
+					{
+						Action:    skydb.RecordHistorySave,
+						ActorID:   "alice",
+						CreatedAt: createdAt.Add(time.Minute),
+						Record: &skydb.Record{
+							ID:      noteID,
+							OwnerID: "alice",
+							Data:    map[string]interface{}{"content": "first draft"},
+						},
+					},
+					{
+						Action:    skydb.RecordHistorySave,
+						ActorID:   "alice",
+						CreatedAt: createdAt,
+					},
+				},
+				deletedID: {
+					{
+						Action:    skydb.RecordHistoryDelete,
+						ActorID:   "alice",
+						CreatedAt: createdAt,
+						Record: &skydb.Record{
+							ID:      deletedID,
+							OwnerID: "alice",
+							Data:    map[string]interface{}{"content": "gone"},
+						},
+					},
+				},
+			},
+		}
+		So(db.Save(&skydb.Record{
+			ID:      noteID,
+			OwnerID: "alice",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("alice", skydb.WriteLevel),
+			},
+			Data: map[string]interface{}{"content": "second draft"},
+		}), ShouldBeNil)
+
+		newRouter := func(userID string, masterKey bool) *handlertest.SingleRouteRouter {
+			return handlertest.NewSingleRouteRouter(&RecordHistoryHandler{}, func(p *router.Payload) {
+				p.DBConn = &historyConn{}
+				p.Database = db
+				p.AuthInfoID = userID
+				p.AuthInfo = &skydb.AuthInfo{ID: userID}
+				if masterKey {
+					p.AccessKey = router.MasterAccessKey
+				}
+			})
+		}
+
+		Convey("return history newest first", func() {
+			resp := newRouter("alice", false).POST(`{"id": "note/1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": [
+		{
+			"action": "save",
+			"actor_id": "alice",
+			"created_at": "2017-01-01T00:01:00Z",
+			"record": {
+				"_id": "note/1",
+				"_type": "record",
+				"_access": null,
+				"_ownerID": "alice",
+				"content": "first draft"
+			}
+		},
+		{
+			"action": "save",
+			"actor_id": "alice",
+			"created_at": "2017-01-01T00:00:00Z",
+			"record": null
+		}
+	]
+}`)
+		})
+
+		Convey("limit the number of entries", func() {
+			resp := newRouter("alice", false).POST(`{"id": "note/1", "limit": 1}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(resp.Body.String(), ShouldContainSubstring, "first draft")
+			So(resp.Body.String(), ShouldNotContainSubstring, `"record":null`)
+		})
+
+		Convey("reject user without read access", func() {
+			resp := newRouter("bob", false).POST(`{"id": "note/1"}`)
+			So(resp.Code, ShouldEqual, 403)
+		})
+
+		Convey("reject history of deleted record without master key", func() {
+			resp := newRouter("alice", false).POST(`{"id": "note/2"}`)
+			So(resp.Code, ShouldEqual, 404)
+		})
+
+		Convey("return history of deleted record with master key", func() {
+			resp := newRouter("", true).POST(`{"id": "note/2"}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(resp.Body.String(), ShouldContainSubstring, `"delete"`)
+		})
+
+		Convey("reject invalid limit", func() {
+			resp := newRouter("alice", false).POST(`{"id": "note/1", "limit": 1000}`)
+			So(resp.Code, ShouldEqual, 400)
+		})
+	})
+}
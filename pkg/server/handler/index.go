@@ -0,0 +1,316 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type indexResponse struct {
+	Fields []string          `json:"fields"`
+	Method skydb.IndexMethod `json:"method"`
+	Unique bool              `json:"unique"`
+}
+
+func newIndexResponse(index skydb.RecordIndex) indexResponse {
+	return indexResponse{
+		Fields: index.Fields,
+		Method: index.Method,
+		Unique: index.Unique,
+	}
+}
+
+func validateIndexName(recordType string, name string) skyerr.Error {
+	missingArgs := []string{}
+	if recordType == "" {
+		missingArgs = append(missingArgs, "record_type")
+	}
+	if name == "" {
+		missingArgs = append(missingArgs, "name")
+	}
+	if len(missingArgs) > 0 {
+		return skyerr.NewInvalidArgument("missing required fields", missingArgs)
+	}
+	if strings.HasPrefix(recordType, "_") {
+		return skyerr.NewInvalidArgument("attempts to change reserved table", []string{"record_type"})
+	}
+	return nil
+}
+
+type indexCreatePayload struct {
+	RecordType string   `mapstructure:"record_type"`
+	Name       string   `mapstructure:"name"`
+	Fields     []string `mapstructure:"fields"`
+	Method     string   `mapstructure:"method"`
+	Unique     bool     `mapstructure:"unique"`
+}
+
+func (payload *indexCreatePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	if payload.Method == "" {
+		payload.Method = string(skydb.BTreeIndex)
+	}
+	return payload.Validate()
+}
+
+func (payload *indexCreatePayload) Validate() skyerr.Error {
+	if skyErr := validateIndexName(payload.RecordType, payload.Name); skyErr != nil {
+		return skyErr
+	}
+	if len(payload.Fields) == 0 {
+		return skyerr.NewInvalidArgument("at least one field is required", []string{"fields"})
+	}
+	method := skydb.IndexMethod(payload.Method)
+	if !method.IsValid() {
+		return skyerr.NewInvalidArgument("method must be one of btree, gin or gist", []string{"method"})
+	}
+	if payload.Unique && method != skydb.BTreeIndex {
+		return skyerr.NewInvalidArgument("only btree index can be unique", []string{"unique"})
+	}
+	return nil
+}
+
+func (payload *indexCreatePayload) RecordIndex() skydb.RecordIndex {
+	return skydb.RecordIndex{
+		Fields: payload.Fields,
+		Method: skydb.IndexMethod(payload.Method),
+		Unique: payload.Unique,
+	}
+}
+
+/*
+IndexCreateHandler creates an index on fields of a record type. The method
+of the index is btree by default, gin for JSON and list fields, or gist
+for location fields. Only btree index can be unique.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "index:create",
+    "master_key": "MASTER_KEY",
+    "record_type": "note",
+    "name": "note_tags_idx",
+    "fields": ["tags"],
+    "method": "gin"
+}
+EOF
+*/
+type IndexCreateHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	InjectDB         router.Processor `preprocessor:"inject_db"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	PluginReady      router.Processor `preprocessor:"plugin_ready"`
+	preprocessors    []router.Processor
+}
+
+func (h *IndexCreateHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectDB,
+		h.RequireMasterKey,
+		h.PluginReady,
+	}
+}
+
+func (h *IndexCreateHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *IndexCreateHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "record_type", Type: router.StringField, Required: true},
+		{Name: "name", Type: router.StringField, Required: true},
+		{Name: "fields", Type: router.ArrayField, Elem: router.StringField, Required: true},
+		{Name: "method", Type: router.StringField, Enum: []string{"btree", "gin", "gist"}},
+		{Name: "unique", Type: router.BooleanField},
+	}
+}
+
+func (h *IndexCreateHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &indexCreatePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	index := payload.RecordIndex()
+	if err := rpayload.Database.CreateIndex(payload.RecordType, payload.Name, index); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"indexes": map[string]indexResponse{
+			payload.Name: newIndexResponse(index),
+		},
+	}
+}
+
+type indexDropPayload struct {
+	RecordType string `mapstructure:"record_type"`
+	Name       string `mapstructure:"name"`
+}
+
+func (payload *indexDropPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return validateIndexName(payload.RecordType, payload.Name)
+}
+
+/*
+IndexDropHandler drops an index on a record type.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "index:drop",
+    "master_key": "MASTER_KEY",
+    "record_type": "note",
+    "name": "note_tags_idx"
+}
+EOF
+*/
+type IndexDropHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	InjectDB         router.Processor `preprocessor:"inject_db"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	PluginReady      router.Processor `preprocessor:"plugin_ready"`
+	preprocessors    []router.Processor
+}
+
+func (h *IndexDropHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectDB,
+		h.RequireMasterKey,
+		h.PluginReady,
+	}
+}
+
+func (h *IndexDropHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *IndexDropHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "record_type", Type: router.StringField, Required: true},
+		{Name: "name", Type: router.StringField, Required: true},
+	}
+}
+
+func (h *IndexDropHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &indexDropPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	err := rpayload.Database.DropIndex(payload.RecordType, payload.Name)
+	if err == skydb.ErrIndexNotFound {
+		response.Err = skyerr.NewErrorf(skyerr.ResourceNotFound,
+			"index %s does not exist in record type %s", payload.Name, payload.RecordType)
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"name": payload.Name,
+	}
+}
+
+/*
+IndexFetchHandler returns the indexes on fields of a record type.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "index:fetch",
+    "master_key": "MASTER_KEY",
+    "record_type": "note"
+}
+EOF
+
+{
+    "result": {
+        "indexes": {
+            "note_tags_idx": {"fields": ["tags"], "method": "gin", "unique": false}
+        }
+    }
+}
+*/
+type IndexFetchHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	InjectDB         router.Processor `preprocessor:"inject_db"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	PluginReady      router.Processor `preprocessor:"plugin_ready"`
+	preprocessors    []router.Processor
+}
+
+func (h *IndexFetchHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectDB,
+		h.RequireMasterKey,
+		h.PluginReady,
+	}
+}
+
+func (h *IndexFetchHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *IndexFetchHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "record_type", Type: router.StringField, Required: true},
+	}
+}
+
+func (h *IndexFetchHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	recordType, _ := rpayload.Data["record_type"].(string)
+	indexes, err := rpayload.Database.ListIndexes(recordType)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	result := map[string]indexResponse{}
+	for name, index := range indexes {
+		result[name] = newIndexResponse(index)
+	}
+	response.Result = map[string]interface{}{
+		"indexes": result,
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type indexDB struct {
+	*skydbtest.MapDB
+	indexes map[string]skydb.RecordIndex
+}
+
+func (db *indexDB) CreateIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	db.indexes[indexName] = index
+	return nil
+}
+
+func (db *indexDB) DropIndex(recordType, indexName string) error {
+	if _, ok := db.indexes[indexName]; !ok {
+		return skydb.ErrIndexNotFound
+	}
+	delete(db.indexes, indexName)
+	return nil
+}
+
+func (db *indexDB) ListIndexes(recordType string) (map[string]skydb.RecordIndex, error) {
+	return db.indexes, nil
+}
+
+func TestIndexHandlers(t *testing.T) {
+	Convey("Index handlers", t, func() {
+		db := &indexDB{
+			MapDB: skydbtest.NewMapDB(),
+			indexes: map[string]skydb.RecordIndex{
+				"note_title_idx": {
+					Fields: []string{"title"},
+					Method: skydb.BTreeIndex,
+					Unique: true,
+				},
+			},
+		}
+		prepare := func(p *router.Payload) {
+			p.Database = db
+		}
+
+		Convey("creates btree index by default", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexCreateHandler{}, prepare)
+			resp := r.POST(`{
+				"record_type": "note",
+				"name": "note_due_idx",
+				"fields": ["due_at", "priority"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"indexes": {
+						"note_due_idx": {"fields": ["due_at", "priority"], "method": "btree", "unique": false}
+					}
+				}
+			}`)
+			So(db.indexes["note_due_idx"], ShouldResemble, skydb.RecordIndex{
+				Fields: []string{"due_at", "priority"},
+				Method: skydb.BTreeIndex,
+			})
+		})
+
+		Convey("creates gin index", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexCreateHandler{}, prepare)
+			resp := r.POST(`{
+				"record_type": "note",
+				"name": "note_tags_idx",
+				"fields": ["tags"],
+				"method": "gin"
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(db.indexes["note_tags_idx"].Method, ShouldEqual, skydb.GINIndex)
+		})
+
+		Convey("rejects unique gist index", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexCreateHandler{}, prepare)
+			resp := r.POST(`{
+				"record_type": "place",
+				"name": "place_location_idx",
+				"fields": ["location"],
+				"method": "gist",
+				"unique": true
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "only btree index can be unique",
+					"info": {"arguments": ["unique"]}
+				}
+			}`)
+		})
+
+		Convey("rejects unknown method", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexCreateHandler{}, prepare)
+			resp := r.POST(`{
+				"record_type": "note",
+				"name": "note_title_idx",
+				"fields": ["title"],
+				"method": "hash"
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "method must be one of btree, gin, gist",
+					"info": {
+						"arguments": ["method"],
+						"errors": {"method": "method must be one of btree, gin, gist"}
+					}
+				}
+			}`)
+		})
+
+		Convey("reports all invalid fields", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexCreateHandler{}, prepare)
+			resp := r.POST(`{
+				"record_type": "note",
+				"fields": "title",
+				"unique": "yes"
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "name is required; fields must be an array; unique must be a boolean",
+					"info": {
+						"arguments": ["name", "fields", "unique"],
+						"errors": {
+							"name": "name is required",
+							"fields": "fields must be an array",
+							"unique": "unique must be a boolean"
+						}
+					}
+				}
+			}`)
+			So(db.indexes, ShouldHaveLength, 1)
+		})
+
+		Convey("drops index", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexDropHandler{}, prepare)
+			resp := r.POST(`{"record_type": "note", "name": "note_title_idx"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {"name": "note_title_idx"}
+			}`)
+			So(db.indexes, ShouldBeEmpty)
+		})
+
+		Convey("returns not found when dropping missing index", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexDropHandler{}, prepare)
+			resp := r.POST(`{"record_type": "note", "name": "note_body_idx"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "ResourceNotFound",
+					"code": 110,
+					"message": "index note_body_idx does not exist in record type note"
+				}
+			}`)
+		})
+
+		Convey("fetches indexes", func() {
+			r := handlertest.NewSingleRouteRouter(&IndexFetchHandler{}, prepare)
+			resp := r.POST(`{"record_type": "note"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"indexes": {
+						"note_title_idx": {"fields": ["title"], "method": "btree", "unique": true}
+					}
+				}
+			}`)
+		})
+	})
+}
@@ -0,0 +1,191 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+const (
+	// defaultLeaderboardLimit is the number of top ranked records
+	// returned if limit is not specified.
+	defaultLeaderboardLimit = 10
+	// maxLeaderboardLimit is the maximum number of top ranked records
+	// returned in a request.
+	maxLeaderboardLimit = 100
+)
+
+type leaderboardEntryResult struct {
+	Rank     uint64  `json:"rank"`
+	RecordID string  `json:"record_id"`
+	OwnerID  string  `json:"owner_id"`
+	Score    float64 `json:"score"`
+}
+
+func newLeaderboardEntryResult(entry skydb.LeaderboardEntry) *leaderboardEntryResult {
+	return &leaderboardEntryResult{
+		Rank:     entry.Rank,
+		RecordID: entry.RecordID.String(),
+		OwnerID:  entry.OwnerID,
+		Score:    entry.Score,
+	}
+}
+
+type leaderboardPayload struct {
+	RecordType string `mapstructure:"record_type"`
+	ScoreField string `mapstructure:"score_field"`
+	Order      string `mapstructure:"order"`
+	Limit      int    `mapstructure:"limit"`
+}
+
+func (payload *leaderboardPayload) Decode(data map[string]interface{}) skyerr.Error {
+	payload.Limit = defaultLeaderboardLimit
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *leaderboardPayload) Validate() skyerr.Error {
+	if payload.RecordType == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"record_type"})
+	}
+	if payload.ScoreField == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"score_field"})
+	}
+	if strings.HasPrefix(payload.ScoreField, "_") || strings.Contains(payload.ScoreField, ".") {
+		return skyerr.NewInvalidArgument("score_field must be a field of the record type", []string{"score_field"})
+	}
+	if payload.Order != "" && payload.Order != "asc" && payload.Order != "desc" {
+		return skyerr.NewInvalidArgument(`order must be "asc" or "desc"`, []string{"order"})
+	}
+	if payload.Limit <= 0 || payload.Limit > maxLeaderboardLimit {
+		return skyerr.NewInvalidArgument("limit must be between 1 and 100", []string{"limit"})
+	}
+	return nil
+}
+
+/*
+RecordLeaderboardHandler returns the top ranked records of a record type
+ranked by a number field, together with the rank of the current user.
+
+Records with higher scores are ranked higher unless order is "asc".
+Records with equal scores have the same rank, and records without a score
+are not ranked. The rank of a user is the rank of the highest ranked
+record owned by the user; user is null if the user owns no ranked records.
+Only records readable by the user are ranked.
+
+At most limit top ranked records are returned, defaulting to 10.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:leaderboard",
+    "access_token": "ACCESS_TOKEN",
+    "record_type": "score",
+    "score_field": "points",
+    "limit": 2
+}
+EOF
+
+{
+    "result": {
+        "top": [
+            {"rank": 1, "record_id": "score/2", "owner_id": "bob", "score": 300},
+            {"rank": 2, "record_id": "score/3", "owner_id": "carol", "score": 200}
+        ],
+        "user": {"rank": 5, "record_id": "score/5", "owner_id": "USER_ID", "score": 50}
+    }
+}
+*/
+type RecordLeaderboardHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordLeaderboardHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordLeaderboardHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordLeaderboardHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &leaderboardPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if !rpayload.HasMasterKey() {
+		fieldACL, err := rpayload.DBConn.GetRecordFieldAccess()
+		if err != nil {
+			response.Err = skyerr.MakeError(err)
+			return
+		}
+		if !fieldACL.Accessible(payload.RecordType, payload.ScoreField, skydb.ReadFieldAccessMode, rpayload.AuthInfo, nil) {
+			response.Err = skyerr.NewError(skyerr.RecordQueryDenied, fmt.Sprintf(
+				`Cannot rank by field "%s" due to Field ACL, need to be readable`, payload.ScoreField))
+			return
+		}
+	}
+
+	leaderboard, err := rpayload.Database.Leaderboard(skydb.LeaderboardQuery{
+		Type:                payload.RecordType,
+		ScoreField:          payload.ScoreField,
+		Ascending:           payload.Order == "asc",
+		Limit:               uint64(payload.Limit),
+		UserID:              rpayload.AuthInfoID,
+		ViewAsUser:          rpayload.AuthInfo,
+		BypassAccessControl: rpayload.HasMasterKey(),
+	})
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	top := make([]*leaderboardEntryResult, len(leaderboard.Top))
+	for i, entry := range leaderboard.Top {
+		top[i] = newLeaderboardEntryResult(entry)
+	}
+	var user *leaderboardEntryResult
+	if leaderboard.User != nil {
+		user = newLeaderboardEntryResult(*leaderboard.User)
+	}
+	response.Result = struct {
+		Top  []*leaderboardEntryResult `json:"top"`
+		User *leaderboardEntryResult   `json:"user"`
+	}{top, user}
+}
@@ -0,0 +1,130 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type leaderboardDB struct {
+	*skydbtest.MapDB
+	query       skydb.LeaderboardQuery
+	leaderboard skydb.Leaderboard
+}
+
+func (db *leaderboardDB) Leaderboard(query skydb.LeaderboardQuery) (*skydb.Leaderboard, error) {
+	db.query = query
+	return &db.leaderboard, nil
+}
+
+func TestRecordLeaderboardHandler(t *testing.T) {
+	Convey("RecordLeaderboardHandler", t, func() {
+		conn := skydbtest.NewMapConn()
+		db := &leaderboardDB{
+			MapDB: skydbtest.NewMapDB(),
+			leaderboard: skydb.Leaderboard{
+				Top: []skydb.LeaderboardEntry{
+					{Rank: 1, RecordID: skydb.NewRecordID("score", "2"), OwnerID: "bob", Score: 300},
+					{Rank: 2, RecordID: skydb.NewRecordID("score", "3"), OwnerID: "carol", Score: 200},
+				},
+			},
+		}
+		authInfo := &skydb.AuthInfo{ID: "alice"}
+		r := handlertest.NewSingleRouteRouter(&RecordLeaderboardHandler{}, func(p *router.Payload) {
+			p.DBConn = conn
+			p.Database = db
+			p.AuthInfoID = "alice"
+			p.AuthInfo = authInfo
+		})
+
+		Convey("returns top ranked records and rank of user", func() {
+			db.leaderboard.User = &skydb.LeaderboardEntry{
+				Rank: 5, RecordID: skydb.NewRecordID("score", "5"), OwnerID: "alice", Score: 50,
+			}
+
+			resp := r.POST(`{"record_type": "score", "score_field": "points", "limit": 2}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"top": [
+			{"rank": 1, "record_id": "score/2", "owner_id": "bob", "score": 300},
+			{"rank": 2, "record_id": "score/3", "owner_id": "carol", "score": 200}
+		],
+		"user": {"rank": 5, "record_id": "score/5", "owner_id": "alice", "score": 50}
+	}
+}`)
+			So(db.query, ShouldResemble, skydb.LeaderboardQuery{
+				Type:       "score",
+				ScoreField: "points",
+				Limit:      2,
+				UserID:     "alice",
+				ViewAsUser: authInfo,
+			})
+		})
+
+		Convey("returns null user if user is not ranked", func() {
+			resp := r.POST(`{"record_type": "score", "score_field": "points", "order": "asc"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"top": [
+			{"rank": 1, "record_id": "score/2", "owner_id": "bob", "score": 300},
+			{"rank": 2, "record_id": "score/3", "owner_id": "carol", "score": 200}
+		],
+		"user": null
+	}
+}`)
+			So(db.query.Ascending, ShouldBeTrue)
+			So(db.query.Limit, ShouldEqual, 10)
+		})
+
+		Convey("refuses to rank by field not readable", func() {
+			conn.SetRecordFieldAccess(skydb.NewFieldACL(skydb.FieldACLEntryList{
+				{
+					RecordType:  "score",
+					RecordField: "points",
+					UserRole:    skydb.FieldUserRole{skydb.PublicFieldUserRoleType, ""},
+				},
+			}))
+
+			resp := r.POST(`{"record_type": "score", "score_field": "points"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "RecordQueryDenied",
+		"code": 124,
+		"message": "Cannot rank by field \"points\" due to Field ACL, need to be readable"
+	}
+}`)
+		})
+
+		Convey("refuses invalid order", func() {
+			resp := r.POST(`{"record_type": "score", "score_field": "points", "order": "up"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "order must be \"asc\" or \"desc\"",
+		"info": {"arguments": ["order"]}
+	}
+}`)
+		})
+	})
+}
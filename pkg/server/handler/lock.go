@@ -0,0 +1,364 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+const (
+	// defaultLockTTL is the lease of a lock if ttl is not specified.
+	defaultLockTTL = 60
+	// maxLockTTL is the maximum lease of a lock in seconds. Clients
+	// holding a lock longer should renew it periodically.
+	maxLockTTL = 3600
+)
+
+type lockResult struct {
+	ID       string    `json:"id"`
+	OwnerID  string    `json:"owner_id"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+func newLockResult(lock *skydb.Lock) lockResult {
+	return lockResult{
+		ID:       lock.RecordID.String(),
+		OwnerID:  lock.OwnerID,
+		ExpireAt: lock.ExpireAt,
+	}
+}
+
+type lockPayload struct {
+	RawID    string `mapstructure:"id"`
+	TTL      int    `mapstructure:"ttl"`
+	RecordID skydb.RecordID
+}
+
+func (payload *lockPayload) Decode(data map[string]interface{}) skyerr.Error {
+	payload.TTL = defaultLockTTL
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *lockPayload) Validate() skyerr.Error {
+	ss := strings.SplitN(payload.RawID, "/", 2)
+	if len(ss) != 2 || ss[0] == "" || ss[1] == "" {
+		return skyerr.NewInvalidArgument(
+			`record: "id" should be of format '{type}/{id}', got "`+payload.RawID+`"`,
+			[]string{"id"},
+		)
+	}
+	payload.RecordID = skydb.NewRecordID(ss[0], ss[1])
+
+	if payload.TTL <= 0 || payload.TTL > maxLockTTL {
+		return skyerr.NewInvalidArgument("ttl must be between 1 and 3600 seconds", []string{"ttl"})
+	}
+	return nil
+}
+
+func (payload *lockPayload) Lease() time.Duration {
+	return time.Duration(payload.TTL) * time.Second
+}
+
+// checkLockAccess returns an error if the user cannot access the record
+// at the specified level.
+func checkLockAccess(rpayload *router.Payload, recordID skydb.RecordID, level skydb.RecordACLLevel) skyerr.Error {
+	record := skydb.Record{}
+	if err := rpayload.Database.Get(recordID, &record); err == skydb.ErrRecordNotFound {
+		return skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+	} else if err != nil {
+		return skyerr.MakeError(err)
+	}
+
+	if !rpayload.HasMasterKey() && !record.Accessible(rpayload.AuthInfo, level) {
+		return skyerr.NewError(skyerr.PermissionDenied, "no permission to lock the record")
+	}
+	return nil
+}
+
+/*
+RecordLockHandler acquires an advisory lock of a record for the current
+user, so that other users can tell the record is being edited. The lock
+expires after ttl seconds unless it is renewed.
+
+If the record is locked by another user, RecordLocked error is returned
+with the owner of the lock.
+
+Write access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:lock",
+    "access_token": "ACCESS_TOKEN",
+    "id": "note/1004",
+    "ttl": 60
+}
+EOF
+
+{
+    "result": {
+        "id": "note/1004",
+        "owner_id": "USER_ID",
+        "expire_at": "2017-01-01T00:01:00Z"
+    }
+}
+*/
+type RecordLockHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordLockHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordLockHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordLockHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &lockPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if skyErr := checkLockAccess(rpayload, payload.RecordID, skydb.WriteLevel); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	lock, err := rpayload.DBConn.AcquireLock(payload.RecordID, rpayload.AuthInfoID, payload.Lease())
+	if err == skydb.ErrRecordLocked {
+		response.Err = skyerr.NewErrorWithInfo(
+			skyerr.RecordLocked,
+			"record is locked by another user",
+			map[string]interface{}{
+				"owner_id":  lock.OwnerID,
+				"expire_at": lock.ExpireAt,
+			},
+		)
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newLockResult(lock)
+}
+
+/*
+RecordLockRenewHandler extends the lock of a record held by the current
+user by ttl seconds. ResourceNotFound error is returned if the user does not
+hold the lock, for example when the lock has expired.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:lock:renew",
+    "access_token": "ACCESS_TOKEN",
+    "id": "note/1004",
+    "ttl": 60
+}
+EOF
+*/
+type RecordLockRenewHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordLockRenewHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordLockRenewHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordLockRenewHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &lockPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	lock, err := rpayload.DBConn.RenewLock(payload.RecordID, rpayload.AuthInfoID, payload.Lease())
+	if err == skydb.ErrLockNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "lock not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newLockResult(lock)
+}
+
+/*
+RecordLockReleaseHandler releases the lock of a record held by the current
+user.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:lock:release",
+    "access_token": "ACCESS_TOKEN",
+    "id": "note/1004"
+}
+EOF
+*/
+type RecordLockReleaseHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordLockReleaseHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordLockReleaseHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordLockReleaseHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &lockPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	err := rpayload.DBConn.ReleaseLock(payload.RecordID, rpayload.AuthInfoID)
+	if err == skydb.ErrLockNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "lock not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = struct {
+		ID string `json:"id"`
+	}{payload.RecordID.String()}
+}
+
+/*
+RecordLockGetHandler returns the unexpired lock of a record. Read access
+to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:lock:get",
+    "access_token": "ACCESS_TOKEN",
+    "id": "note/1004"
+}
+EOF
+*/
+type RecordLockGetHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordLockGetHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordLockGetHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordLockGetHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &lockPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if skyErr := checkLockAccess(rpayload, payload.RecordID, skydb.ReadLevel); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	lock, err := rpayload.DBConn.GetLock(payload.RecordID)
+	if err == skydb.ErrLockNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "record is not locked")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newLockResult(lock)
+}
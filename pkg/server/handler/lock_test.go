@@ -0,0 +1,208 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type lockConn struct {
+	skydb.Conn
+	locks map[skydb.RecordID]skydb.Lock
+}
+
+var lockExpireAt = time.Date(2017, 1, 1, 0, 1, 0, 0, time.UTC)
+
+func (conn *lockConn) AcquireLock(recordID skydb.RecordID, ownerID string, ttl time.Duration) (*skydb.Lock, error) {
+	if lock, ok := conn.locks[recordID]; ok && lock.OwnerID != ownerID {
+		return &lock, skydb.ErrRecordLocked
+	}
+	lock := skydb.Lock{RecordID: recordID, OwnerID: ownerID, ExpireAt: lockExpireAt}
+	conn.locks[recordID] = lock
+	return &lock, nil
+}
+
+func (conn *lockConn) RenewLock(recordID skydb.RecordID, ownerID string, ttl time.Duration) (*skydb.Lock, error) {
+	lock, ok := conn.locks[recordID]
+	if !ok || lock.OwnerID != ownerID {
+		return nil, skydb.ErrLockNotFound
+	}
+	lock.ExpireAt = lock.ExpireAt.Add(ttl)
+	conn.locks[recordID] = lock
+	return &lock, nil
+}
+
+func (conn *lockConn) ReleaseLock(recordID skydb.RecordID, ownerID string) error {
+	lock, ok := conn.locks[recordID]
+	if !ok || lock.OwnerID != ownerID {
+		return skydb.ErrLockNotFound
+	}
+	delete(conn.locks, recordID)
+	return nil
+}
+
+func (conn *lockConn) GetLock(recordID skydb.RecordID) (*skydb.Lock, error) {
+	lock, ok := conn.locks[recordID]
+	if !ok {
+		return nil, skydb.ErrLockNotFound
+	}
+	return &lock, nil
+}
+
+func TestRecordLockHandlers(t *testing.T) {
+	Convey("Record lock handlers", t, func() {
+		conn := &lockConn{
+			locks: map[skydb.RecordID]skydb.Lock{},
+		}
+		db := skydbtest.NewMapDB()
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("note", "1"),
+			OwnerID: "alice",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("bob", skydb.ReadLevel),
+			},
+		}), ShouldBeNil)
+
+		newRouter := func(handler router.Handler, userID string) *handlertest.SingleRouteRouter {
+			return handlertest.NewSingleRouteRouter(handler, func(p *router.Payload) {
+				p.DBConn = conn
+				p.Database = db
+				p.AuthInfoID = userID
+				p.AuthInfo = &skydb.AuthInfo{ID: userID}
+			})
+		}
+
+		Convey("acquire lock", func() {
+			r := newRouter(&RecordLockHandler{}, "alice")
+			resp := r.POST(`{"id": "note/1", "ttl": 60}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"id": "note/1",
+		"owner_id": "alice",
+		"expire_at": "2017-01-01T00:01:00Z"
+	}
+}`)
+			So(conn.locks, ShouldContainKey, skydb.NewRecordID("note", "1"))
+		})
+
+		Convey("fail to acquire lock held by another user", func() {
+			conn.locks[skydb.NewRecordID("note", "1")] = skydb.Lock{
+				RecordID: skydb.NewRecordID("note", "1"),
+				OwnerID:  "carol",
+				ExpireAt: lockExpireAt,
+			}
+
+			r := newRouter(&RecordLockHandler{}, "alice")
+			resp := r.POST(`{"id": "note/1"}`)
+			So(resp.Code, ShouldEqual, 409)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "RecordLocked",
+		"code": 126,
+		"message": "record is locked by another user",
+		"info": {
+			"owner_id": "carol",
+			"expire_at": "2017-01-01T00:01:00Z"
+		}
+	}
+}`)
+		})
+
+		Convey("fail to acquire lock without write access", func() {
+			r := newRouter(&RecordLockHandler{}, "bob")
+			resp := r.POST(`{"id": "note/1"}`)
+			So(resp.Code, ShouldEqual, 403)
+			So(conn.locks, ShouldBeEmpty)
+		})
+
+		Convey("fail to acquire lock of non-existent record", func() {
+			r := newRouter(&RecordLockHandler{}, "alice")
+			resp := r.POST(`{"id": "note/2"}`)
+			So(resp.Code, ShouldEqual, 404)
+		})
+
+		Convey("reject invalid ttl", func() {
+			r := newRouter(&RecordLockHandler{}, "alice")
+			resp := r.POST(`{"id": "note/1", "ttl": 86400}`)
+			So(resp.Code, ShouldEqual, 400)
+		})
+
+		Convey("renew lock", func() {
+			conn.locks[skydb.NewRecordID("note", "1")] = skydb.Lock{
+				RecordID: skydb.NewRecordID("note", "1"),
+				OwnerID:  "alice",
+				ExpireAt: lockExpireAt,
+			}
+
+			r := newRouter(&RecordLockRenewHandler{}, "alice")
+			resp := r.POST(`{"id": "note/1", "ttl": 60}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"id": "note/1",
+		"owner_id": "alice",
+		"expire_at": "2017-01-01T00:02:00Z"
+	}
+}`)
+
+			r = newRouter(&RecordLockRenewHandler{}, "bob")
+			resp = r.POST(`{"id": "note/1", "ttl": 60}`)
+			So(resp.Code, ShouldEqual, 404)
+		})
+
+		Convey("release lock", func() {
+			conn.locks[skydb.NewRecordID("note", "1")] = skydb.Lock{
+				RecordID: skydb.NewRecordID("note", "1"),
+				OwnerID:  "alice",
+				ExpireAt: lockExpireAt,
+			}
+
+			r := newRouter(&RecordLockReleaseHandler{}, "bob")
+			resp := r.POST(`{"id": "note/1"}`)
+			So(resp.Code, ShouldEqual, 404)
+
+			r = newRouter(&RecordLockReleaseHandler{}, "alice")
+			resp = r.POST(`{"id": "note/1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{"result": {"id": "note/1"}}`)
+			So(conn.locks, ShouldBeEmpty)
+		})
+
+		Convey("get lock with read access", func() {
+			conn.locks[skydb.NewRecordID("note", "1")] = skydb.Lock{
+				RecordID: skydb.NewRecordID("note", "1"),
+				OwnerID:  "alice",
+				ExpireAt: lockExpireAt,
+			}
+
+			r := newRouter(&RecordLockGetHandler{}, "bob")
+			resp := r.POST(`{"id": "note/1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"id": "note/1",
+		"owner_id": "alice",
+		"expire_at": "2017-01-01T00:01:00Z"
+	}
+}`)
+		})
+	})
+}
@@ -0,0 +1,179 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type maintenanceResult struct {
+	Enabled   bool       `json:"enabled"`
+	Message   string     `json:"message,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+}
+
+func newMaintenanceResult(maintenance *skydb.Maintenance) maintenanceResult {
+	result := maintenanceResult{
+		Enabled:   maintenance.Enabled,
+		Message:   maintenance.Message,
+		UpdatedBy: maintenance.UpdatedBy,
+	}
+	if !maintenance.UpdatedAt.IsZero() {
+		updatedAt := maintenance.UpdatedAt
+		result.UpdatedAt = &updatedAt
+	}
+	return result
+}
+
+type maintenancePayload struct {
+	Enabled *bool  `mapstructure:"enabled"`
+	Message string `mapstructure:"message"`
+}
+
+func (payload *maintenancePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *maintenancePayload) Validate() skyerr.Error {
+	if payload.Enabled == nil {
+		return skyerr.NewInvalidArgument("enabled is required", []string{"enabled"})
+	}
+	return nil
+}
+
+/*
+MaintenanceSetHandler turns the maintenance mode of the app on or off.
+Master key is required.
+
+In maintenance mode, requests saving or deleting records and signing up
+are rejected with UnderMaintenance error, which can be retried after the
+maintenance. Reads and pubsub are not affected, and requests with master
+key are still accepted. The change takes effect on all servers of the app
+within a few seconds.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "maintenance:set",
+    "master_key": "MASTER_KEY",
+    "enabled": true,
+    "message": "Upgrading database, back in 10 minutes."
+}
+EOF
+
+{
+    "result": {
+        "enabled": true,
+        "message": "Upgrading database, back in 10 minutes.",
+        "updated_at": "2017-01-01T00:00:00Z"
+    }
+}
+*/
+type MaintenanceSetHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *MaintenanceSetHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *MaintenanceSetHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *MaintenanceSetHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &maintenancePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	maintenance := skydb.Maintenance{
+		Enabled:   *payload.Enabled,
+		Message:   payload.Message,
+		UpdatedAt: timeNow().UTC(),
+		UpdatedBy: rpayload.AuthInfoID,
+	}
+	if err := rpayload.DBConn.SetMaintenance(&maintenance); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	if maintenance.Enabled {
+		log.WithField("message", maintenance.Message).Warnln("Maintenance mode is enabled")
+	} else {
+		log.Infoln("Maintenance mode is disabled")
+	}
+
+	response.Result = newMaintenanceResult(&maintenance)
+}
+
+/*
+MaintenanceGetHandler returns the maintenance mode of the app. Master key
+is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "maintenance:get",
+    "master_key": "MASTER_KEY"
+}
+EOF
+*/
+type MaintenanceGetHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *MaintenanceGetHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *MaintenanceGetHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *MaintenanceGetHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	maintenance, err := rpayload.DBConn.GetMaintenance()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newMaintenanceResult(maintenance)
+}
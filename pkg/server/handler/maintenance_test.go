@@ -0,0 +1,105 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type maintenanceConn struct {
+	skydb.Conn
+	maintenance skydb.Maintenance
+}
+
+func (c *maintenanceConn) GetMaintenance() (*skydb.Maintenance, error) {
+	maintenance := c.maintenance
+	return &maintenance, nil
+}
+
+func (c *maintenanceConn) SetMaintenance(maintenance *skydb.Maintenance) error {
+	c.maintenance = *maintenance
+	return nil
+}
+
+func TestMaintenanceHandlers(t *testing.T) {
+	Convey("Maintenance handlers", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := &maintenanceConn{}
+
+		Convey("enables maintenance", func() {
+			handler := &MaintenanceSetHandler{}
+			req := router.Payload{
+				DBConn:     conn,
+				AuthInfoID: "admin",
+				Data: map[string]interface{}{
+					"enabled": true,
+					"message": "Upgrading database",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			updatedAt := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, maintenanceResult{
+				Enabled:   true,
+				Message:   "Upgrading database",
+				UpdatedAt: &updatedAt,
+				UpdatedBy: "admin",
+			})
+			So(conn.maintenance, ShouldResemble, skydb.Maintenance{
+				Enabled:   true,
+				Message:   "Upgrading database",
+				UpdatedAt: updatedAt,
+				UpdatedBy: "admin",
+			})
+		})
+
+		Convey("rejects payload without enabled", func() {
+			handler := &MaintenanceSetHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data:   map[string]interface{}{},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("gets maintenance never set", func() {
+			handler := &MaintenanceGetHandler{}
+			req := router.Payload{
+				DBConn: conn,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, maintenanceResult{})
+		})
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type positionBetweenPayload struct {
+	Before string `mapstructure:"before"`
+	After  string `mapstructure:"after"`
+}
+
+func (payload *positionBetweenPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *positionBetweenPayload) Validate() skyerr.Error {
+	if payload.Before != "" && !skydb.IsValidPosition(payload.Before) {
+		return skyerr.NewInvalidArgument("invalid position", []string{"before"})
+	}
+	if payload.After != "" && !skydb.IsValidPosition(payload.After) {
+		return skyerr.NewInvalidArgument("invalid position", []string{"after"})
+	}
+	if payload.Before != "" && payload.After != "" && payload.Before >= payload.After {
+		return skyerr.NewInvalidArgument("before must be sorted before after", []string{"before", "after"})
+	}
+	return nil
+}
+
+// PositionBetweenHandler returns a value of a position field sorted
+// between two positions, so that a record can be moved between two
+// records by saving only the moved record. Leave out before to insert
+// at the start, and leave out after to insert at the end.
+// curl -X POST -H "Content-Type: application/json" \
+//   -d @- http://localhost:3000/ <<EOF
+// {
+//     "action": "record:position",
+//     "api_key": "API_KEY",
+//     "before": "V",
+//     "after": "W"
+// }
+// EOF
+//
+// {
+//     "request_id": "REQUEST_ID",
+//     "result": {
+//         "position": "VV"
+//     }
+// }
+type PositionBetweenHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	preprocessors []router.Processor
+}
+
+func (h *PositionBetweenHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+	}
+}
+
+func (h *PositionBetweenHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *PositionBetweenHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &positionBetweenPayload{}
+	skyErr := payload.Decode(rpayload.Data)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	position, err := skydb.PositionBetween(payload.Before, payload.After)
+	if err != nil {
+		response.Err = skyerr.NewError(skyerr.InvalidArgument, err.Error())
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"position": position,
+	}
+}
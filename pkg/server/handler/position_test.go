@@ -0,0 +1,66 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+func TestPositionBetweenHandler(t *testing.T) {
+	Convey("PositionBetweenHandler", t, func() {
+		r := handlertest.NewSingleRouteRouter(&PositionBetweenHandler{}, func(p *router.Payload) {})
+
+		Convey("return position between positions", func() {
+			resp := r.POST(`{"before": "V", "after": "W"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"position": "VV"
+	}
+}`)
+		})
+
+		Convey("return position at the end", func() {
+			resp := r.POST(`{"before": "V"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"position": "l"
+	}
+}`)
+		})
+
+		Convey("return error on unsorted positions", func() {
+			resp := r.POST(`{"before": "W", "after": "V"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 108,
+		"message": "before must be sorted before after",
+		"info": {
+			"arguments": [
+				"before",
+				"after"
+			]
+		},
+		"name": "InvalidArgument"
+	}
+}`)
+		})
+	})
+}
@@ -21,13 +21,13 @@ import (
 
 type PubSubHandler struct {
 	WebSocket     *pubsub.WsPubSub
-	AccessKey     router.Processor `preprocessor:"accesskey"`
+	Authenticator router.Processor `preprocessor:"authenticator"`
 	preprocessors []router.Processor
 }
 
 func (h *PubSubHandler) Setup() {
 	h.preprocessors = []router.Processor{
-		h.AccessKey,
+		h.Authenticator,
 	}
 }
 
@@ -42,5 +42,11 @@ func (h *PubSubHandler) Handle(payload *router.Payload, response *router.Respons
 		return
 	}
 
-	h.WebSocket.Handle(writer, payload.Req)
+	// The access token authenticates the user of the connection, who is
+	// the only one allowed to subscribe to the private user channel.
+	h.WebSocket.Handle(writer, payload.Req, pubsub.Client{
+		UserID:    payload.AuthInfoID,
+		MasterKey: payload.HasMasterKey(),
+		Tenant:    payload.Tenant,
+	})
 }
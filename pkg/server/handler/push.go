@@ -15,10 +15,13 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	"github.com/mitchellh/mapstructure"
+	"golang.org/x/net/trace"
+
 	"github.com/skygeario/skygear-server/pkg/server/push"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -26,17 +29,28 @@ import (
 )
 
 // Remarks: this variable is for mocking in test cases
-var sendPushNotification = func(sender push.Sender, device skydb.Device, m push.Mapper) {
-	go func() {
-		log.Infof("Sending notification to device token = %s", device.Token)
-		err := sender.Send(m, device)
-
-		if err != nil {
-			log.Warnf("Failed to send notification: %v\n", err)
-		} else {
-			log.Infof("Sent notification to device token = %s", device.Token)
-		}
-	}()
+//
+// The notification is sent with the request context, so it is not sent
+// if the request is cancelled or timed out before sending. Each sending
+// is traced, and identified by the request ID in logs and traces.
+var sendPushNotification = func(ctx context.Context, sender push.Sender, device skydb.Device, m push.Mapper) {
+	requestID := router.RequestIDFromContext(ctx)
+	logger := log.WithField("request_id", requestID)
+
+	tr := trace.New("skygear.push", device.Type)
+	defer tr.Finish()
+	tr.LazyPrintf("request_id: %s, device_id: %s", requestID, device.ID)
+
+	logger.Infof("Sending notification to device token = %s", device.Token)
+	err := sender.Send(ctx, m, device)
+
+	if err != nil {
+		tr.LazyPrintf("error: %v", err)
+		tr.SetError()
+		logger.Warnf("Failed to send notification: %v\n", err)
+	} else {
+		logger.Infof("Sent notification to device token = %s", device.Token)
+	}
 }
 
 type sendPushResponseItem struct {
@@ -146,7 +160,7 @@ func (h *PushToUserHandler) Handle(rpayload *router.Payload, response *router.Re
 				if _, ok := deviceIDs[device.Token]; !ok {
 					deviceIDs[device.Token] = true
 					pushMap := push.MapMapper(payload.Notification)
-					sendPushNotification(h.NotificationSender, device, pushMap)
+					sendPushNotification(rpayload.Context, h.NotificationSender, device, pushMap)
 				}
 			}
 		}
@@ -220,7 +234,7 @@ func (h *PushToDeviceHandler) Handle(rpayload *router.Payload, response *router.
 			})
 		} else if payload.Topic == "" || payload.Topic == device.Topic {
 			pushMap := push.MapMapper(payload.Notification)
-			sendPushNotification(h.NotificationSender, device, pushMap)
+			sendPushNotification(rpayload.Context, h.NotificationSender, device, pushMap)
 			resultItems = append(resultItems, sendPushResponseItem{
 				id: deviceID,
 			})
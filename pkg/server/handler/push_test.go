@@ -15,6 +15,7 @@
 package handler
 
 import (
+	"context"
 	"testing"
 
 	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
@@ -26,6 +27,28 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 )
 
+type contextSender struct {
+	ctx context.Context
+}
+
+func (s *contextSender) Send(ctx context.Context, m push.Mapper, device skydb.Device) error {
+	s.ctx = ctx
+	return ctx.Err()
+}
+
+func TestSendPushNotification(t *testing.T) {
+	Convey("sendPushNotification", t, func() {
+		Convey("sends with the request context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			sender := &contextSender{}
+			sendPushNotification(ctx, sender, skydb.Device{ID: "device"}, push.EmptyMapper)
+			So(sender.ctx, ShouldEqual, ctx)
+		})
+	})
+}
+
 func TestPushToDevice(t *testing.T) {
 	Convey("push to device", t, func() {
 		testdevice := skydb.Device{
@@ -49,7 +72,7 @@ func TestPushToDevice(t *testing.T) {
 
 		Convey("push to single device", func(c C) {
 			called := false
-			sendPushNotification = func(sender push.Sender, device skydb.Device, m push.Mapper) {
+			sendPushNotification = func(ctx context.Context, sender push.Sender, device skydb.Device, m push.Mapper) {
 				c.So(device, ShouldResemble, testdevice)
 				c.So(m.Map(), ShouldResemble, map[string]interface{}{
 					"aps": map[string]interface{}{
@@ -82,7 +105,7 @@ func TestPushToDevice(t *testing.T) {
 
 		Convey("push to non-existent device", func() {
 			called := false
-			sendPushNotification = func(sender push.Sender, device skydb.Device, m push.Mapper) {
+			sendPushNotification = func(ctx context.Context, sender push.Sender, device skydb.Device, m push.Mapper) {
 				called = true
 			}
 			resp := r.POST(`{
@@ -147,7 +170,7 @@ func TestPushToUser(t *testing.T) {
 
 		Convey("push to single user", func(c C) {
 			sentDevices := []skydb.Device{}
-			sendPushNotification = func(sender push.Sender, device skydb.Device, m push.Mapper) {
+			sendPushNotification = func(ctx context.Context, sender push.Sender, device skydb.Device, m push.Mapper) {
 				c.So(m.Map(), ShouldResemble, map[string]interface{}{
 					"aps": map[string]interface{}{
 						"alert": "This is a message.",
@@ -180,7 +203,7 @@ func TestPushToUser(t *testing.T) {
 
 		Convey("push to non-existent user", func() {
 			called := false
-			sendPushNotification = func(sender push.Sender, device skydb.Device, m push.Mapper) {
+			sendPushNotification = func(ctx context.Context, sender push.Sender, device skydb.Device, m push.Mapper) {
 				called = true
 			}
 			resp := r.POST(`{
@@ -19,14 +19,37 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/querydsl"
 	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
 
+// substituteComputedSorts replaces sorts by the key path of a computed key
+// (i.e. `_transient_<key>`) with the expression of the computed key, so that
+// records can be sorted by a computed value.
+func substituteComputedSorts(query *skydb.Query) {
+	for i, sort := range query.Sorts {
+		if !sort.Expression.IsKeyPath() {
+			continue
+		}
+
+		keyPath := sort.Expression.Value.(string)
+		if !strings.HasPrefix(keyPath, "_transient_") {
+			continue
+		}
+
+		expr, ok := query.ComputedKeys[strings.TrimPrefix(keyPath, "_transient_")]
+		if ok && expr.Type != skydb.Literal {
+			query.Sorts[i].Expression = expr
+		}
+	}
+}
+
 // QueryParser is a context for parsing raw query to skydb.Query
 type QueryParser struct {
 	UserID string
@@ -36,12 +59,14 @@ type QueryParser struct {
 //
 // The structure takes the following form:
 //
-//     [ _expression_ , _sort_order ]
+//     [ _expression_ , _sort_order ( , _null_order ) ]
 //
 // Expression supports key path type or the function type. Literal type is
 // not supported.
 //
 // Sort Order only supports `"asc"` or `"desc"`.
+//
+// Null Order is optional and supports `"nulls_first"` or `"nulls_last"`.
 func (parser *QueryParser) sortFromRaw(rawSort []interface{}, sort *skydb.Sort) {
 	// Parse expression.
 	expr := parser.parseExpression(rawSort[0])
@@ -64,8 +89,23 @@ func (parser *QueryParser) sortFromRaw(rawSort []interface{}, sort *skydb.Sort)
 		panic(fmt.Errorf("unknown sort order: %v", orderStr))
 	}
 
+	// Parse null order.
+	nullOrder := skydb.NullsDefault
+	if len(rawSort) > 2 {
+		nullStr, _ := rawSort[2].(string)
+		switch nullStr {
+		case "nulls_first":
+			nullOrder = skydb.NullsFirst
+		case "nulls_last":
+			nullOrder = skydb.NullsLast
+		default:
+			panic(fmt.Errorf("unknown null order: %v", rawSort[2]))
+		}
+	}
+
 	sort.Expression = expr
 	sort.Order = sortOrder
+	sort.Nulls = nullOrder
 }
 
 func (parser *QueryParser) sortsFromRaw(rawSorts []interface{}) []skydb.Sort {
@@ -74,8 +114,8 @@ func (parser *QueryParser) sortsFromRaw(rawSorts []interface{}) []skydb.Sort {
 
 	for i := range rawSorts {
 		sortSlice, _ := rawSorts[i].([]interface{})
-		if len(sortSlice) != 2 {
-			panic(fmt.Errorf("got len(sort descriptor) = %v, want 2", len(sortSlice)))
+		if len(sortSlice) != 2 && len(sortSlice) != 3 {
+			panic(fmt.Errorf("got len(sort descriptor) = %v, want 2 or 3", len(sortSlice)))
 		}
 		parser.sortFromRaw(sortSlice, &sorts[i])
 	}
@@ -111,6 +151,16 @@ func (parser *QueryParser) predicateOperatorFromString(operatorString string) sk
 		return skydb.In
 	case "func":
 		return skydb.Functional
+	case "between":
+		return skydb.Between
+	case "isnull":
+		return skydb.IsNull
+	case "isnotnull":
+		return skydb.IsNotNull
+	case "containsall":
+		return skydb.ContainsAll
+	case "containsany":
+		return skydb.ContainsAny
 	default:
 		panic(fmt.Errorf("unrecognized operator = %s", operatorString))
 	}
@@ -151,6 +201,14 @@ func (parser *QueryParser) predicateFromRaw(rawPredicate []interface{}) skydb.Pr
 		panic(fmt.Errorf("Expected number of expressions be 2, got %v", len(predicate.Children)))
 	}
 
+	if predicate.Operator.IsTernary() && len(predicate.Children) != 3 {
+		panic(fmt.Errorf("Expected number of expressions be 3, got %v", len(predicate.Children)))
+	}
+
+	if predicate.Operator.IsUnary() && len(predicate.Children) != 1 {
+		panic(fmt.Errorf("Expected number of expressions be 1, got %v", len(predicate.Children)))
+	}
+
 	return predicate
 }
 
@@ -203,6 +261,26 @@ func (parser *QueryParser) parseFunc(s []interface{}) (f skydb.Func, err error)
 		f, err = parser.parseDistanceFunc(s[2:])
 	case "userRelation":
 		f, err = parser.parseUserRelationFunc(s[2:])
+	case "lower":
+		f, err = parser.parseLowerFunc(s[2:])
+	case "datePart":
+		f, err = parser.parseDatePartFunc(s[2:])
+	case "descendants":
+		f, err = parser.parseDescendantFunc(s[2:])
+	case "ancestors":
+		f, err = parser.parseAncestorFunc(s[2:])
+	case "depth":
+		f, err = parser.parseTreeDepthFunc(s[2:])
+	case "random":
+		f, err = parser.parseRandomFunc(s[2:])
+	case "concat":
+		f, err = parser.parseConcatFunc(s[2:])
+	case "coalesce":
+		f, err = parser.parseCoalesceFunc(s[2:])
+	case "upper", "length", "abs":
+		f, err = parser.parseUnaryFunc(funcName, s[2:])
+	case "add", "subtract", "multiply", "divide":
+		f, err = parser.parseArithmeticFunc(funcName, s[2:])
 	case "":
 		return nil, errors.New("empty function name")
 	default:
@@ -234,6 +312,209 @@ func (parser *QueryParser) parseDistanceFunc(s []interface{}) (skydb.DistanceFun
 	}, nil
 }
 
+func (parser *QueryParser) parseLowerFunc(s []interface{}) (skydb.LowerFunc, error) {
+	emptyLowerFunc := skydb.LowerFunc{}
+	if len(s) != 1 {
+		return emptyLowerFunc, fmt.Errorf("want 1 argument for lower func, got %d", len(s))
+	}
+
+	var field string
+	if err := skyconv.MapFrom(s[0], (*skyconv.MapKeyPath)(&field)); err != nil {
+		return emptyLowerFunc, fmt.Errorf("invalid key path: %v", err)
+	}
+
+	return skydb.LowerFunc{
+		KeyPath: field,
+	}, nil
+}
+
+func (parser *QueryParser) parseDatePartFunc(s []interface{}) (skydb.DatePartFunc, error) {
+	emptyDatePartFunc := skydb.DatePartFunc{}
+	if len(s) != 2 {
+		return emptyDatePartFunc, fmt.Errorf("want 2 arguments for datePart func, got %d", len(s))
+	}
+
+	var field string
+	if err := skyconv.MapFrom(s[0], (*skyconv.MapKeyPath)(&field)); err != nil {
+		return emptyDatePartFunc, fmt.Errorf("invalid key path: %v", err)
+	}
+
+	partStr, _ := s[1].(string)
+	part := skydb.DatePart(partStr)
+	if !part.IsValid() {
+		return emptyDatePartFunc, fmt.Errorf("invalid date part: %v", s[1])
+	}
+
+	return skydb.DatePartFunc{
+		KeyPath: field,
+		Part:    part,
+	}, nil
+}
+
+func (parser *QueryParser) parseDescendantFunc(s []interface{}) (skydb.DescendantFunc, error) {
+	emptyDescendantFunc := skydb.DescendantFunc{}
+	if len(s) != 2 && len(s) != 3 {
+		return emptyDescendantFunc, fmt.Errorf("want 2 or 3 arguments for descendants func, got %d", len(s))
+	}
+
+	var field string
+	if err := skyconv.MapFrom(s[0], (*skyconv.MapKeyPath)(&field)); err != nil {
+		return emptyDescendantFunc, fmt.Errorf("invalid key path: %v", err)
+	}
+
+	var ref skydb.Reference
+	if err := skyconv.MapFrom(s[1], (*skyconv.MapReference)(&ref)); err != nil {
+		return emptyDescendantFunc, fmt.Errorf("invalid reference: %v", err)
+	}
+
+	maxDepth := 0
+	if len(s) == 3 {
+		depth, ok := s[2].(float64)
+		if !ok || depth < 1 {
+			return emptyDescendantFunc, fmt.Errorf("invalid max depth: %v", s[2])
+		}
+		maxDepth = int(depth)
+	}
+
+	return skydb.DescendantFunc{
+		ParentKeyPath: field,
+		RecordID:      ref.ID,
+		MaxDepth:      maxDepth,
+	}, nil
+}
+
+func (parser *QueryParser) parseAncestorFunc(s []interface{}) (skydb.AncestorFunc, error) {
+	emptyAncestorFunc := skydb.AncestorFunc{}
+	if len(s) != 2 {
+		return emptyAncestorFunc, fmt.Errorf("want 2 arguments for ancestors func, got %d", len(s))
+	}
+
+	var field string
+	if err := skyconv.MapFrom(s[0], (*skyconv.MapKeyPath)(&field)); err != nil {
+		return emptyAncestorFunc, fmt.Errorf("invalid key path: %v", err)
+	}
+
+	var ref skydb.Reference
+	if err := skyconv.MapFrom(s[1], (*skyconv.MapReference)(&ref)); err != nil {
+		return emptyAncestorFunc, fmt.Errorf("invalid reference: %v", err)
+	}
+
+	return skydb.AncestorFunc{
+		ParentKeyPath: field,
+		RecordID:      ref.ID,
+	}, nil
+}
+
+func (parser *QueryParser) parseTreeDepthFunc(s []interface{}) (skydb.TreeDepthFunc, error) {
+	if len(s) != 1 {
+		return skydb.TreeDepthFunc{}, fmt.Errorf("want 1 argument for depth func, got %d", len(s))
+	}
+
+	var field string
+	if err := skyconv.MapFrom(s[0], (*skyconv.MapKeyPath)(&field)); err != nil {
+		return skydb.TreeDepthFunc{}, fmt.Errorf("invalid key path: %v", err)
+	}
+
+	return skydb.TreeDepthFunc{
+		ParentKeyPath: field,
+	}, nil
+}
+
+func (parser *QueryParser) parseRandomFunc(s []interface{}) (skydb.RandomFunc, error) {
+	if len(s) == 0 {
+		return skydb.RandomFunc{}, nil
+	} else if len(s) != 1 {
+		return skydb.RandomFunc{}, fmt.Errorf("want 0 or 1 argument for random func, got %d", len(s))
+	}
+
+	seed, ok := s[0].(float64)
+	if !ok || seed < -1 || seed > 1 {
+		return skydb.RandomFunc{}, fmt.Errorf("invalid random seed: %v", s[0])
+	}
+	return skydb.RandomFunc{
+		Seed: &seed,
+	}, nil
+}
+
+func (parser *QueryParser) parseConcatFunc(s []interface{}) (skydb.ConcatFunc, error) {
+	if len(s) == 0 {
+		return skydb.ConcatFunc{}, errors.New("want at least 1 argument for concat func, got 0")
+	}
+
+	exprs := make([]skydb.Expression, len(s))
+	for i, rawExpr := range s {
+		exprs[i] = parser.parseExpression(rawExpr)
+	}
+
+	return skydb.ConcatFunc{
+		Expressions: exprs,
+	}, nil
+}
+
+func (parser *QueryParser) parseCoalesceFunc(s []interface{}) (skydb.CoalesceFunc, error) {
+	if len(s) == 0 {
+		return skydb.CoalesceFunc{}, errors.New("want at least 1 argument for coalesce func, got 0")
+	}
+
+	exprs := make([]skydb.Expression, len(s))
+	for i, rawExpr := range s {
+		exprs[i] = parser.parseExpression(rawExpr)
+	}
+
+	return skydb.CoalesceFunc{
+		Expressions: exprs,
+	}, nil
+}
+
+func (parser *QueryParser) parseUnaryFunc(funcName string, s []interface{}) (skydb.Func, error) {
+	if len(s) != 1 {
+		return nil, fmt.Errorf("want 1 argument for %s func, got %d", funcName, len(s))
+	}
+
+	expr := parser.parseExpression(s[0])
+	switch funcName {
+	case "upper":
+		return skydb.UpperFunc{Expression: expr}, nil
+	case "length":
+		return skydb.LengthFunc{Expression: expr}, nil
+	case "abs":
+		return skydb.AbsFunc{Expression: expr}, nil
+	default:
+		return nil, fmt.Errorf("got unrecgonized function name = %s", funcName)
+	}
+}
+
+var arithmeticOperators = map[string]skydb.ArithmeticOperator{
+	"add":      skydb.Add,
+	"subtract": skydb.Subtract,
+	"multiply": skydb.Multiply,
+	"divide":   skydb.Divide,
+}
+
+func (parser *QueryParser) parseArithmeticFunc(funcName string, s []interface{}) (skydb.ArithmeticFunc, error) {
+	emptyArithmeticFunc := skydb.ArithmeticFunc{}
+	if len(s) != 2 {
+		return emptyArithmeticFunc, fmt.Errorf("want 2 arguments for %s func, got %d", funcName, len(s))
+	}
+
+	exprs := make([]skydb.Expression, len(s))
+	for i, rawExpr := range s {
+		expr := parser.parseExpression(rawExpr)
+		if expr.Type == skydb.Literal {
+			if _, ok := expr.Value.(float64); !ok {
+				return emptyArithmeticFunc, fmt.Errorf("want number literal for %s func, got %T", funcName, expr.Value)
+			}
+		}
+		exprs[i] = expr
+	}
+
+	return skydb.ArithmeticFunc{
+		Operator: arithmeticOperators[funcName],
+		Left:     exprs[0],
+		Right:    exprs[1],
+	}, nil
+}
+
 func (parser *QueryParser) parseUserRelationFunc(s []interface{}) (skydb.UserRelationFunc, error) {
 	emptyUserRelationFunc := skydb.UserRelationFunc{}
 	if len(s) != 2 {
@@ -259,6 +540,16 @@ func (parser *QueryParser) parseUserRelationFunc(s []interface{}) (skydb.UserRel
 
 }
 
+// ParseQuery parses a raw query in the form of the payload of
+// record:query, such as the query of a scheduled report.
+func (parser *QueryParser) ParseQuery(rawQuery map[string]interface{}) (skydb.Query, skyerr.Error) {
+	query := skydb.Query{}
+	if err := parser.queryFromRaw(rawQuery, &query); err != nil {
+		return skydb.Query{}, err
+	}
+	return query, nil
+}
+
 func (parser *QueryParser) queryFromRaw(rawQuery map[string]interface{}, query *skydb.Query) (err skyerr.Error) {
 	defer func() {
 		// use panic to escape from inner error
@@ -296,11 +587,29 @@ func (parser *QueryParser) queryFromRaw(rawQuery map[string]interface{}, query *
 		return nil
 	})
 
+	if rawDSL, ok := rawQuery["query"]; ok && rawDSL != nil {
+		if err := parser.queryFromDSL(rawQuery, query); err != nil {
+			return err
+		}
+	}
+
 	if transientIncludes, ok := rawQuery["include"].(map[string]interface{}); ok {
 		query.ComputedKeys = map[string]skydb.Expression{}
 		for key, value := range transientIncludes {
+			if rawInclude, ok := value.(map[string]interface{}); ok && rawInclude["$type"] == "related" {
+				related, err := parser.relatedIncludeFromRaw(rawInclude)
+				if err != nil {
+					return err
+				}
+				if query.RelatedIncludes == nil {
+					query.RelatedIncludes = map[string]skydb.RelatedQuery{}
+				}
+				query.RelatedIncludes[key] = related
+				continue
+			}
 			query.ComputedKeys[key] = parser.parseExpression(value)
 		}
+		substituteComputedSorts(query)
 	}
 
 	mustDoSlice(rawQuery, "desired_keys", func(desiredKeys []interface{}) skyerr.Error {
@@ -315,10 +624,39 @@ func (parser *QueryParser) queryFromRaw(rawQuery map[string]interface{}, query *
 		return nil
 	})
 
+	mustDoSlice(rawQuery, "distinct_on", func(distinctOn []interface{}) skyerr.Error {
+		query.DistinctOn = make([]string, len(distinctOn))
+		for i, key := range distinctOn {
+			key, ok := key.(string)
+			if !ok {
+				return skyerr.NewError(skyerr.InvalidArgument, "unexpected value in distinct_on")
+			}
+			query.DistinctOn[i] = key
+		}
+		return nil
+	})
+
 	if getCount, ok := rawQuery["count"].(bool); ok {
 		query.GetCount = getCount
 	}
 
+	if explain, ok := rawQuery["explain"].(bool); ok {
+		query.Explain = explain
+	}
+
+	if includeDeleted, ok := rawQuery["include_deleted"].(bool); ok {
+		query.IncludeDeleted = includeDeleted
+	}
+
+	if asOf, ok := rawQuery["as_of"].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, asOf)
+		if err != nil {
+			return skyerr.NewInvalidArgument("invalid as_of", []string{"as_of"})
+		}
+		t = t.UTC()
+		query.AsOf = &t
+	}
+
 	if offset, _ := rawQuery["offset"].(float64); offset > 0 {
 		query.Offset = uint64(offset)
 	}
@@ -327,6 +665,90 @@ func (parser *QueryParser) queryFromRaw(rawQuery map[string]interface{}, query *
 		query.Limit = new(uint64)
 		*query.Limit = uint64(limit)
 	}
+
+	if after, ok := rawQuery["after"].(string); ok {
+		cursor, err := skydb.DecodeCursor(after)
+		if err != nil {
+			return skyerr.NewInvalidArgument("invalid cursor", []string{"after"})
+		}
+		query.After = cursor
+	}
+
+	if pageSize, _ := rawQuery["page_size"].(float64); pageSize > 0 {
+		query.PageSize = uint64(pageSize)
+	}
+	return nil
+}
+
+// relatedIncludeFromRaw parses an include of the records referencing the
+// queried records by the field "key", for example the first 3 comments of
+// each post:
+//
+//     {
+//         "$type": "related",
+//         "record_type": "comment",
+//         "key": "post",
+//         "sort": [[{"$type": "keypath", "$val": "_created_at"}, "desc"]],
+//         "page_size": 3
+//     }
+//
+// The predicate, sort and page size are parsed as in a query, and the page
+// size is required.
+func (parser *QueryParser) relatedIncludeFromRaw(rawInclude map[string]interface{}) (skydb.RelatedQuery, skyerr.Error) {
+	related := skydb.RelatedQuery{}
+	for _, key := range []string{"include", "distinct_on", "count", "explain", "as_of", "offset", "limit", "after"} {
+		if _, ok := rawInclude[key]; ok {
+			return related, skyerr.NewInvalidArgument(
+				fmt.Sprintf("%s cannot be used in related include", key),
+				[]string{"include", key},
+			)
+		}
+	}
+
+	if err := parser.queryFromRaw(rawInclude, &related.Query); err != nil {
+		return related, err
+	}
+
+	related.Field, _ = rawInclude["key"].(string)
+	if related.Field == "" {
+		return related, skyerr.NewInvalidArgument("key of related include cannot be empty", []string{"include", "key"})
+	}
+	if related.PageSize == 0 {
+		return related, skyerr.NewInvalidArgument("page_size of related include is required", []string{"include", "page_size"})
+	}
+	return related, nil
+}
+
+// queryFromDSL parses the predicate and sorts of the query from the
+// string query DSL, for example:
+//
+//     status = "open" AND price < 100 ORDER BY created_at DESC
+//
+// The query DSL cannot be specified with the predicate or sort.
+func (parser *QueryParser) queryFromDSL(rawQuery map[string]interface{}, query *skydb.Query) skyerr.Error {
+	dsl, ok := rawQuery["query"].(string)
+	if !ok {
+		return skyerr.NewInvalidArgument("query must be a string", []string{"query"})
+	}
+	if _, ok := rawQuery["predicate"]; ok {
+		return skyerr.NewInvalidArgument("query cannot be specified with predicate", []string{"query", "predicate"})
+	}
+	if _, ok := rawQuery["sort"]; ok {
+		return skyerr.NewInvalidArgument("query cannot be specified with sort", []string{"query", "sort"})
+	}
+
+	predicate, sorts, err := querydsl.Parse(dsl)
+	if err != nil {
+		return skyerr.NewInvalidArgument(fmt.Sprintf("failed to parse query: %v", err), []string{"query"})
+	}
+	if !predicate.IsEmpty() {
+		if err := predicate.Validate(); err != nil {
+			return err
+		}
+	}
+
+	query.Predicate = predicate
+	query.Sorts = sorts
 	return nil
 }
 
@@ -416,9 +838,13 @@ func (c *queryAccessVisitor) VisitPredicate(p skydb.Predicate) {
 	// falls into a certain group. If the parent of a predicate is performing
 	// non-simple comparison, the predicate in question is always performing
 	// non-simple comparison.
+	//
+	// Or is allowed so that the discoverable fields can be matched against
+	// multiple lists of values, such as the emails and phone numbers in
+	// an address book.
 	simple := func() bool {
 		op := p.Operator
-		simpleOp := op == skydb.Equal || op == skydb.In || op == skydb.And
+		simpleOp := op == skydb.Equal || op == skydb.In || op == skydb.And || op == skydb.Or
 		if len(c.pStack) > 0 {
 			return c.pStack[len(c.pStack)-1].SimpleComparison && simpleOp
 		}
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -56,6 +57,491 @@ func TestQueryFromRaw(t *testing.T) {
 			})
 		})
 
+		Convey("should parse query DSL", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"query":       `category.name = "Interesting" ORDER BY noteOrder DESC`,
+			}, &query)
+			So(err, ShouldBeNil)
+			So(query, ShouldResemble, skydb.Query{
+				Type: "note",
+				Predicate: skydb.Predicate{
+					skydb.Equal,
+					[]interface{}{
+						skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "category.name",
+						},
+						skydb.Expression{
+							Type:  skydb.Literal,
+							Value: "Interesting",
+						},
+					},
+				},
+				Sorts: []skydb.Sort{
+					{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "noteOrder",
+						},
+						Order: skydb.Desc,
+					},
+				},
+			})
+		})
+
+		Convey("should return error for invalid query DSL", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"query":       `category.name = `,
+			}, &query)
+			So(err, ShouldResemble, skyerr.NewInvalidArgument(
+				"failed to parse query: unexpected end of query at position 16",
+				[]string{"query"}))
+		})
+
+		Convey("should return error for query DSL with predicate", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"query":       `category.name = "Interesting"`,
+				"predicate": []interface{}{
+					"isnull",
+					map[string]interface{}{"$type": "keypath", "$val": "category"},
+				},
+			}, &query)
+			So(err, ShouldResemble, skyerr.NewInvalidArgument(
+				"query cannot be specified with predicate",
+				[]string{"query", "predicate"}))
+		})
+
+		Convey("should parse isnull predicate", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"predicate": []interface{}{
+					"isnull",
+					map[string]interface{}{"$type": "keypath", "$val": "category"},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+			So(query, ShouldResemble, skydb.Query{
+				Type: "note",
+				Predicate: skydb.Predicate{
+					skydb.IsNull,
+					[]interface{}{
+						skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "category",
+						},
+					},
+				},
+			})
+		})
+
+		Convey("should parse predicate with lower func", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"predicate": []interface{}{
+					"eq",
+					[]interface{}{
+						"func",
+						"lower",
+						map[string]interface{}{"$type": "keypath", "$val": "title"},
+					},
+					"Hello",
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+			So(query.Predicate, ShouldResemble, skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{
+						Type:  skydb.Function,
+						Value: skydb.LowerFunc{KeyPath: "title"},
+					},
+					skydb.Expression{
+						Type:  skydb.Literal,
+						Value: "Hello",
+					},
+				},
+			})
+		})
+
+		Convey("should parse predicate with nested expression funcs", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"predicate": []interface{}{
+					"and",
+					[]interface{}{
+						"gt",
+						[]interface{}{
+							"func",
+							"length",
+							map[string]interface{}{"$type": "keypath", "$val": "title"},
+						},
+						float64(10),
+					},
+					[]interface{}{
+						"gte",
+						[]interface{}{
+							"func",
+							"coalesce",
+							map[string]interface{}{"$type": "keypath", "$val": "score"},
+							float64(0),
+						},
+						float64(5),
+					},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+			So(query.Predicate, ShouldResemble, skydb.Predicate{
+				skydb.And,
+				[]interface{}{
+					skydb.Predicate{
+						skydb.GreaterThan,
+						[]interface{}{
+							skydb.Expression{
+								Type: skydb.Function,
+								Value: skydb.LengthFunc{
+									Expression: skydb.Expression{Type: skydb.KeyPath, Value: "title"},
+								},
+							},
+							skydb.Expression{Type: skydb.Literal, Value: float64(10)},
+						},
+					},
+					skydb.Predicate{
+						skydb.GreaterThanOrEqual,
+						[]interface{}{
+							skydb.Expression{
+								Type: skydb.Function,
+								Value: skydb.CoalesceFunc{
+									Expressions: []skydb.Expression{
+										{Type: skydb.KeyPath, Value: "score"},
+										{Type: skydb.Literal, Value: float64(0)},
+									},
+								},
+							},
+							skydb.Expression{Type: skydb.Literal, Value: float64(5)},
+						},
+					},
+				},
+			})
+		})
+
+		Convey("should parse predicate and sort with date part func", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"predicate": []interface{}{
+					"in",
+					[]interface{}{
+						"func",
+						"datePart",
+						map[string]interface{}{"$type": "keypath", "$val": "_created_at"},
+						"dow",
+					},
+					[]interface{}{float64(0), float64(6)},
+				},
+				"sort": []interface{}{
+					[]interface{}{
+						[]interface{}{
+							"func",
+							"datePart",
+							map[string]interface{}{"$type": "keypath", "$val": "_created_at"},
+							"hour",
+						},
+						"asc",
+					},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+			So(query.Predicate, ShouldResemble, skydb.Predicate{
+				skydb.In,
+				[]interface{}{
+					skydb.Expression{
+						Type: skydb.Function,
+						Value: skydb.DatePartFunc{
+							KeyPath: "_created_at",
+							Part:    skydb.DatePartDayOfWeek,
+						},
+					},
+					skydb.Expression{
+						Type:  skydb.Literal,
+						Value: []interface{}{float64(0), float64(6)},
+					},
+				},
+			})
+			So(query.Sorts, ShouldResemble, []skydb.Sort{
+				{
+					Expression: skydb.Expression{
+						Type: skydb.Function,
+						Value: skydb.DatePartFunc{
+							KeyPath: "_created_at",
+							Part:    skydb.DatePartHour,
+						},
+					},
+					Order: skydb.Asc,
+				},
+			})
+		})
+
+		Convey("should parse sort with seeded random func", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"sort": []interface{}{
+					[]interface{}{
+						[]interface{}{"func", "random", float64(0.5)},
+						"asc",
+					},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+
+			seed := 0.5
+			So(query.Sorts, ShouldResemble, []skydb.Sort{
+				{
+					Expression: skydb.Expression{
+						Type:  skydb.Function,
+						Value: skydb.RandomFunc{Seed: &seed},
+					},
+					Order: skydb.Asc,
+				},
+			})
+		})
+
+		Convey("should parse sort with null order", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"sort": []interface{}{
+					[]interface{}{
+						map[string]interface{}{"$type": "keypath", "$val": "score"},
+						"desc",
+						"nulls_last",
+					},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+
+			So(query.Sorts, ShouldResemble, []skydb.Sort{
+				{
+					Expression: skydb.Expression{
+						Type:  skydb.KeyPath,
+						Value: "score",
+					},
+					Order: skydb.Desc,
+					Nulls: skydb.NullsLast,
+				},
+			})
+		})
+
+		Convey("should return error for unknown null order", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"sort": []interface{}{
+					[]interface{}{
+						map[string]interface{}{"$type": "keypath", "$val": "score"},
+						"desc",
+						"nulls_middle",
+					},
+				},
+			}, &query)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("should parse tree functions", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "comment",
+				"predicate": []interface{}{
+					"and",
+					[]interface{}{
+						"func",
+						"descendants",
+						map[string]interface{}{"$type": "keypath", "$val": "parent"},
+						map[string]interface{}{"$type": "ref", "$id": "comment/1"},
+						float64(2),
+					},
+					[]interface{}{
+						"neq",
+						[]interface{}{
+							"func",
+							"depth",
+							map[string]interface{}{"$type": "keypath", "$val": "parent"},
+						},
+						float64(0),
+					},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+			So(query.Predicate, ShouldResemble, skydb.Predicate{
+				skydb.And,
+				[]interface{}{
+					skydb.Predicate{
+						skydb.Functional,
+						[]interface{}{
+							skydb.Expression{
+								Type: skydb.Function,
+								Value: skydb.DescendantFunc{
+									ParentKeyPath: "parent",
+									RecordID:      skydb.NewRecordID("comment", "1"),
+									MaxDepth:      2,
+								},
+							},
+						},
+					},
+					skydb.Predicate{
+						skydb.NotEqual,
+						[]interface{}{
+							skydb.Expression{
+								Type: skydb.Function,
+								Value: skydb.TreeDepthFunc{
+									ParentKeyPath: "parent",
+								},
+							},
+							skydb.Expression{
+								Type:  skydb.Literal,
+								Value: float64(0),
+							},
+						},
+					},
+				},
+			})
+		})
+
+		Convey("should parse related include", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "post",
+				"include": map[string]interface{}{
+					"comments": map[string]interface{}{
+						"$type":       "related",
+						"record_type": "comment",
+						"key":         "post",
+						"sort": []interface{}{
+							[]interface{}{
+								map[string]interface{}{"$type": "keypath", "$val": "_created_at"},
+								"desc",
+							},
+						},
+						"page_size": float64(3),
+					},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+			So(query.ComputedKeys, ShouldBeEmpty)
+			So(query.RelatedIncludes, ShouldResemble, map[string]skydb.RelatedQuery{
+				"comments": skydb.RelatedQuery{
+					Query: skydb.Query{
+						Type: "comment",
+						Sorts: []skydb.Sort{
+							{
+								Expression: skydb.Expression{Type: skydb.KeyPath, Value: "_created_at"},
+								Order:      skydb.Desc,
+							},
+						},
+						PageSize: 3,
+					},
+					Field: "post",
+				},
+			})
+		})
+
+		Convey("should reject related include without page size", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "post",
+				"include": map[string]interface{}{
+					"comments": map[string]interface{}{
+						"$type":       "related",
+						"record_type": "comment",
+						"key":         "post",
+					},
+				},
+			}, &query)
+			So(err, ShouldNotBeNil)
+			So(err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("should reject related include with limit", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "post",
+				"include": map[string]interface{}{
+					"comments": map[string]interface{}{
+						"$type":       "related",
+						"record_type": "comment",
+						"key":         "post",
+						"page_size":   float64(3),
+						"limit":       float64(10),
+					},
+				},
+			}, &query)
+			So(err, ShouldNotBeNil)
+			So(err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("should parse computed keys and sort by them", func() {
+			query := skydb.Query{}
+			err := parser.queryFromRaw(map[string]interface{}{
+				"record_type": "note",
+				"include": map[string]interface{}{
+					"total": []interface{}{
+						"func",
+						"add",
+						map[string]interface{}{"$type": "keypath", "$val": "price"},
+						map[string]interface{}{"$type": "keypath", "$val": "tax"},
+					},
+					"fullName": []interface{}{
+						"func",
+						"concat",
+						map[string]interface{}{"$type": "keypath", "$val": "firstName"},
+						" ",
+						map[string]interface{}{"$type": "keypath", "$val": "lastName"},
+					},
+				},
+				"sort": []interface{}{
+					[]interface{}{
+						map[string]interface{}{"$type": "keypath", "$val": "_transient_total"},
+						"desc",
+					},
+				},
+			}, &query)
+			So(err, ShouldBeNil)
+
+			total := skydb.Expression{
+				Type: skydb.Function,
+				Value: skydb.ArithmeticFunc{
+					Operator: skydb.Add,
+					Left:     skydb.Expression{Type: skydb.KeyPath, Value: "price"},
+					Right:    skydb.Expression{Type: skydb.KeyPath, Value: "tax"},
+				},
+			}
+			So(query.ComputedKeys, ShouldResemble, map[string]skydb.Expression{
+				"total": total,
+				"fullName": skydb.Expression{
+					Type: skydb.Function,
+					Value: skydb.ConcatFunc{
+						Expressions: []skydb.Expression{
+							{Type: skydb.KeyPath, Value: "firstName"},
+							{Type: skydb.Literal, Value: " "},
+							{Type: skydb.KeyPath, Value: "lastName"},
+						},
+					},
+				},
+			})
+			So(query.Sorts, ShouldResemble, []skydb.Sort{
+				{Expression: total, Order: skydb.Desc},
+			})
+		})
+
 		Convey("functional predicate with user relation", func() {
 			query := skydb.Query{}
 			err := parser.queryFromRaw(map[string]interface{}{
@@ -0,0 +1,346 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+/*
+QueryValidateHandler parses a query in the same form accepted by
+record:query and checks it against the schema of the record type without
+executing it.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/query/validate <<EOF
+{
+    "action": "query:validate",
+    "record_type": "note",
+    "predicate": [
+        "and",
+        ["eq", {"$type": "keypath", "$val": "title"}, "Hello"],
+        ["like", {"$type": "keypath", "$val": "priority"}, "high%"]
+    ],
+    "sort": [[{"$type": "keypath", "$val": "_created_at"}, "desc"]]
+}
+EOF
+
+The result reports whether the query is valid and the errors found, such
+as a field that does not exist or an operator that cannot be applied to
+the type of the field:
+
+{
+    "valid": false,
+    "errors": [
+        {
+            "key_path": "priority",
+            "message": "operator like cannot be applied to field \"priority\" of type number"
+        }
+    ],
+    "query": {
+        "record_type": "note",
+        "predicate": [...],
+        "sort": [...]
+    }
+}
+
+"query" is the normalized form of the parsed query, in which a query
+specified by the "query" string is expanded into predicate and sort. It
+can be submitted to record:query as is. "query" is absent if the query
+cannot be parsed.
+*/
+type QueryValidateHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *QueryValidateHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.PluginReady,
+	}
+}
+
+func (h *QueryValidateHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *QueryValidateHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "record_type", Type: router.StringField, Required: true},
+		{Name: "predicate", Type: router.ArrayField},
+		{Name: "sort", Type: router.ArrayField, Elem: router.ArrayField},
+		{Name: "query", Type: router.StringField},
+	}
+}
+
+type queryValidationError struct {
+	KeyPath string `json:"key_path,omitempty"`
+	Message string `json:"message"`
+}
+
+type queryValidationResult struct {
+	Valid  bool                   `json:"valid"`
+	Errors []queryValidationError `json:"errors"`
+	Query  *normalizedQuery       `json:"query,omitempty"`
+}
+
+// normalizedQuery serializes the record type, predicate and sorts of a
+// query in the form parsed by QueryParser.
+type normalizedQuery skydb.Query
+
+func (q normalizedQuery) MarshalJSON() ([]byte, error) {
+	var optionalPredicate *jsonPredicate
+	if !q.Predicate.IsEmpty() {
+		optionalPredicate = (*jsonPredicate)(&q.Predicate)
+	}
+
+	sorts := make([]jsonSort, len(q.Sorts))
+	for i, sort := range q.Sorts {
+		sorts[i] = jsonSort(sort)
+	}
+
+	return json.Marshal(struct {
+		Type      string         `json:"record_type"`
+		Predicate *jsonPredicate `json:"predicate,omitempty"`
+		Sorts     []jsonSort     `json:"sort,omitempty"`
+	}{
+		q.Type,
+		optionalPredicate,
+		sorts,
+	})
+}
+
+type jsonSort skydb.Sort
+
+func (s jsonSort) MarshalJSON() ([]byte, error) {
+	order := "asc"
+	if s.Order == skydb.Desc {
+		order = "desc"
+	}
+
+	results := []interface{}{jsonExpression(s.Expression), order}
+	switch s.Nulls {
+	case skydb.NullsFirst:
+		results = append(results, "nulls_first")
+	case skydb.NullsLast:
+		results = append(results, "nulls_last")
+	}
+	return json.Marshal(results)
+}
+
+func (h *QueryValidateHandler) Handle(payload *router.Payload, response *router.Response) {
+	query := skydb.Query{}
+	parser := QueryParser{UserID: payload.AuthInfoID}
+	if err := parser.queryFromRaw(payload.Data, &query); err != nil {
+		response.Result = queryValidationResult{
+			Errors: []queryValidationError{{Message: err.Message()}},
+		}
+		return
+	}
+
+	validator := queryValidator{
+		Database:   payload.Database,
+		RecordType: query.Type,
+	}
+	errs, err := validator.Validate(query)
+	if err != nil {
+		panic(err)
+	}
+
+	response.Result = queryValidationResult{
+		Valid:  len(errs) == 0,
+		Errors: errs,
+		Query:  (*normalizedQuery)(&query),
+	}
+}
+
+// queryValidator checks the key paths of a query against the schema of
+// the record type, and checks that the operators of the query can be
+// applied to the type of the fields.
+type queryValidator struct {
+	Database   skydb.Database
+	RecordType string
+	errors     []queryValidationError
+}
+
+func (v *queryValidator) Validate(query skydb.Query) ([]queryValidationError, error) {
+	v.errors = []queryValidationError{}
+
+	schema, err := v.Database.RemoteColumnTypes(v.RecordType)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		v.addError("", fmt.Sprintf(`record type "%s" does not exist`, v.RecordType))
+		return v.errors, nil
+	}
+
+	v.validatePredicate(query.Predicate)
+	for _, sort := range query.Sorts {
+		v.validateExpression(sort.Expression)
+	}
+	return v.errors, nil
+}
+
+func (v *queryValidator) addError(keyPath string, message string) {
+	v.errors = append(v.errors, queryValidationError{
+		KeyPath: keyPath,
+		Message: message,
+	})
+}
+
+// fieldType returns the type of the field at the key path, or false if
+// the key path does not exist. The value of a computed key is not known
+// until the query is executed.
+func (v *queryValidator) fieldType(keyPath string) (skydb.FieldType, bool) {
+	if strings.HasPrefix(keyPath, "_transient_") {
+		return skydb.FieldType{}, false
+	}
+
+	fields, err := skydb.TraverseColumnTypes(v.Database, v.RecordType, keyPath)
+	if err != nil {
+		v.addError(keyPath, err.Error())
+		return skydb.FieldType{}, false
+	}
+	return fields[len(fields)-1], true
+}
+
+func (v *queryValidator) validateExpression(expr skydb.Expression) {
+	if expr.IsKeyPath() {
+		v.fieldType(expr.Value.(string))
+	}
+}
+
+func (v *queryValidator) validatePredicate(predicate skydb.Predicate) {
+	if predicate.IsEmpty() || predicate.Operator == skydb.Functional {
+		return
+	}
+
+	if predicate.Operator.IsCompound() {
+		for _, child := range predicate.Children {
+			v.validatePredicate(child.(skydb.Predicate))
+		}
+		return
+	}
+
+	lhs := predicate.Children[0].(skydb.Expression)
+	if !lhs.IsKeyPath() {
+		for _, child := range predicate.Children {
+			v.validateExpression(child.(skydb.Expression))
+		}
+		return
+	}
+
+	keyPath := lhs.Value.(string)
+	fieldType, ok := v.fieldType(keyPath)
+	if ok && !operatorApplicable(predicate.Operator, fieldType.Type) {
+		v.addError(keyPath, fmt.Sprintf(
+			`operator %s cannot be applied to field "%s" of type %s`,
+			opString(predicate.Operator), keyPath, fieldType.ToSimpleName()))
+		ok = false
+	}
+
+	for _, child := range predicate.Children[1:] {
+		expr := child.(skydb.Expression)
+		if expr.Type != skydb.Literal {
+			v.validateExpression(expr)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		values := []interface{}{expr.Value}
+		switch predicate.Operator {
+		case skydb.In, skydb.ContainsAll, skydb.ContainsAny:
+			if list, isList := expr.Value.([]interface{}); isList {
+				values = list
+			}
+		}
+		for _, value := range values {
+			if !literalCompatible(predicate.Operator, fieldType.Type, value) {
+				v.addError(keyPath, fmt.Sprintf(
+					`field "%s" of type %s cannot be compared with %#v`,
+					keyPath, fieldType.ToSimpleName(), value))
+				break
+			}
+		}
+	}
+}
+
+func operatorApplicable(op skydb.Operator, dataType skydb.DataType) bool {
+	switch op {
+	case skydb.Like, skydb.ILike:
+		return dataType == skydb.TypeString
+	case skydb.GreaterThan, skydb.GreaterThanOrEqual, skydb.LessThan,
+		skydb.LessThanOrEqual, skydb.Between:
+		return dataType == skydb.TypeString || dataType == skydb.TypeDateTime ||
+			dataType.IsNumberCompatibleType()
+	case skydb.ContainsAll, skydb.ContainsAny:
+		return dataType == skydb.TypeJSON
+	}
+	return true
+}
+
+func literalCompatible(op skydb.Operator, dataType skydb.DataType, value interface{}) bool {
+	if value == nil {
+		return op != skydb.Like && op != skydb.ILike
+	}
+
+	switch dataType {
+	case skydb.TypeString:
+		_, ok := value.(string)
+		return ok
+	case skydb.TypeNumber, skydb.TypeInteger, skydb.TypeSequence:
+		_, ok := value.(float64)
+		return ok
+	case skydb.TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case skydb.TypeDateTime:
+		switch v := value.(type) {
+		case time.Time:
+			return true
+		case string:
+			_, err := time.Parse(time.RFC3339Nano, v)
+			return err == nil
+		}
+		return false
+	case skydb.TypeReference:
+		switch value.(type) {
+		case skydb.Reference, string:
+			return true
+		}
+		return false
+	case skydb.TypeLocation:
+		_, ok := value.(skydb.Location)
+		return ok
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueryValidateHandler(t *testing.T) {
+	Convey("QueryValidateHandler", t, func() {
+		db := skydbtest.NewMapDB()
+		db.RecordSchemaMap["note"] = skydb.RecordSchema{
+			"_id":         skydb.FieldType{Type: skydb.TypeString},
+			"_created_at": skydb.FieldType{Type: skydb.TypeDateTime},
+			"title":       skydb.FieldType{Type: skydb.TypeString},
+			"priority":    skydb.FieldType{Type: skydb.TypeNumber},
+			"done":        skydb.FieldType{Type: skydb.TypeBoolean},
+			"tags":        skydb.FieldType{Type: skydb.TypeJSON},
+			"city":        skydb.FieldType{Type: skydb.TypeReference, ReferenceType: "city"},
+		}
+		db.RecordSchemaMap["city"] = skydb.RecordSchema{
+			"name": skydb.FieldType{Type: skydb.TypeString},
+		}
+
+		r := handlertest.NewSingleRouteRouter(&QueryValidateHandler{}, func(p *router.Payload) {
+			p.Database = db
+		})
+
+		Convey("returns the normalized form of a valid query", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"predicate": [
+					"and",
+					["eq", {"$type": "keypath", "$val": "city.name"}, "Hong Kong"],
+					["gte", {"$type": "keypath", "$val": "_created_at"}, {"$type": "date", "$date": "2017-01-01T00:00:00Z"}],
+					["containsany", {"$type": "keypath", "$val": "tags"}, ["a", "b"]]
+				],
+				"sort": [[{"$type": "keypath", "$val": "priority"}, "desc", "nulls_last"]]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"valid": true,
+					"errors": [],
+					"query": {
+						"record_type": "note",
+						"predicate": [
+							"and",
+							["eq", {"$type": "keypath", "$val": "city.name"}, "Hong Kong"],
+							["gte", {"$type": "keypath", "$val": "_created_at"}, {"$type": "date", "$date": "2017-01-01T00:00:00Z"}],
+							["containsany", {"$type": "keypath", "$val": "tags"}, ["a", "b"]]
+						],
+						"sort": [[{"$type": "keypath", "$val": "priority"}, "desc", "nulls_last"]]
+					}
+				}
+			}`)
+		})
+
+		Convey("expands the query string", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"query": "NOT done = TRUE ORDER BY title"
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"valid": true,
+					"errors": [],
+					"query": {
+						"record_type": "note",
+						"predicate": ["not", ["eq", {"$type": "keypath", "$val": "done"}, true]],
+						"sort": [[{"$type": "keypath", "$val": "title"}, "asc"]]
+					}
+				}
+			}`)
+		})
+
+		Convey("reports schema and type errors", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"predicate": [
+					"or",
+					["eq", {"$type": "keypath", "$val": "content"}, "hello"],
+					["like", {"$type": "keypath", "$val": "priority"}, "high%"],
+					["eq", {"$type": "keypath", "$val": "priority"}, "high"],
+					["in", {"$type": "keypath", "$val": "done"}, [true, "no"]]
+				],
+				"sort": [[{"$type": "keypath", "$val": "title.name"}, "asc"]]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"valid": false,
+					"errors": [{
+						"key_path": "content",
+						"message": "keypath \"content\" does not exist"
+					}, {
+						"key_path": "priority",
+						"message": "operator like cannot be applied to field \"priority\" of type number"
+					}, {
+						"key_path": "priority",
+						"message": "field \"priority\" of type number cannot be compared with \"high\""
+					}, {
+						"key_path": "done",
+						"message": "field \"done\" of type boolean cannot be compared with \"no\""
+					}, {
+						"key_path": "title.name",
+						"message": "field \"title\" in keypath \"title.name\" is not a reference"
+					}],
+					"query": {
+						"record_type": "note",
+						"predicate": [
+							"or",
+							["eq", {"$type": "keypath", "$val": "content"}, "hello"],
+							["like", {"$type": "keypath", "$val": "priority"}, "high%"],
+							["eq", {"$type": "keypath", "$val": "priority"}, "high"],
+							["in", {"$type": "keypath", "$val": "done"}, [true, "no"]]
+						],
+						"sort": [[{"$type": "keypath", "$val": "title.name"}, "asc"]]
+					}
+				}
+			}`)
+		})
+
+		Convey("reports record type that does not exist", func() {
+			resp := r.POST(`{
+				"record_type": "todo"
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"valid": false,
+					"errors": [{
+						"message": "record type \"todo\" does not exist"
+					}],
+					"query": {
+						"record_type": "todo"
+					}
+				}
+			}`)
+		})
+
+		Convey("reports query that cannot be parsed", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"predicate": ["unknown", {"$type": "keypath", "$val": "title"}, "a"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"valid": false,
+					"errors": [{
+						"message": "failed to construct query: unrecognized operator = unknown"
+					}]
+				}
+			}`)
+		})
+	})
+}
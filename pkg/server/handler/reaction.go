@@ -0,0 +1,434 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/webhook"
+)
+
+// maxReactionTypeLength is the maximum length of a reaction type in
+// characters.
+const maxReactionTypeLength = 64
+
+type reactionResult struct {
+	RecordID  string               `json:"record_id"`
+	Counts    skydb.ReactionCounts `json:"counts"`
+	Reactions []string             `json:"reactions"`
+}
+
+// reactionDatabaseID returns the ID of the database the records of db
+// are saved in, by which reactions to the records are keyed. It is the
+// user ID for a private database, and empty for the public database.
+func reactionDatabaseID(db skydb.Database) string {
+	if db.DatabaseType() == skydb.PrivateDatabase {
+		return db.ID()
+	}
+	return ""
+}
+
+// getReactionResult returns the reaction counts of the record, and the
+// reaction types the user reacted to the record with.
+func getReactionResult(conn skydb.Conn, databaseID string, recordID skydb.RecordID, userID string) (*reactionResult, skyerr.Error) {
+	counts, err := conn.GetReactionCounts(databaseID, recordID)
+	if err != nil {
+		return nil, skyerr.MakeError(err)
+	}
+
+	reactions, err := conn.GetUserReactions(databaseID, recordID, userID)
+	if err != nil {
+		return nil, skyerr.MakeError(err)
+	}
+
+	return &reactionResult{
+		RecordID:  recordID.String(),
+		Counts:    counts,
+		Reactions: reactions,
+	}, nil
+}
+
+// getReactionRecord returns the record if the user can react to it.
+// Reactions inherit the access control of the record they are attached
+// to, so read access to the record is required.
+func getReactionRecord(rpayload *router.Payload, recordID skydb.RecordID) (*skydb.Record, skyerr.Error) {
+	record := skydb.Record{}
+	if err := rpayload.Database.Get(recordID, &record); err == skydb.ErrRecordNotFound {
+		return nil, skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+	} else if err != nil {
+		return nil, skyerr.MakeError(err)
+	}
+
+	if rpayload.HasMasterKey() {
+		return &record, nil
+	}
+	if record.DeletedAt != nil {
+		return nil, skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+	}
+	if !record.Accessible(rpayload.AuthInfo, skydb.ReadLevel) {
+		return nil, skyerr.NewError(skyerr.PermissionDenied, "no permission to access reactions of the record")
+	}
+	return &record, nil
+}
+
+// notifyReactionOwner sends a push notification to the devices of the
+// owner of the record about the new reaction. Users are not notified of
+// their own reactions.
+func notifyReactionOwner(ctx context.Context, sender push.Sender, conn skydb.Conn, record *skydb.Record, reaction *skydb.Reaction) {
+	if sender == nil || record.OwnerID == "" || record.OwnerID == reaction.UserID {
+		return
+	}
+
+	devices, err := conn.QueryDevicesByUser(record.OwnerID)
+	if err != nil {
+		log.Warnf("Failed to query devices of record owner: %v", err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"record_id": reaction.RecordID.String(),
+		"user_id":   reaction.UserID,
+		"type":      reaction.Type,
+	}
+	pushMap := push.MapMapper{
+		"apns": map[string]interface{}{
+			"aps": map[string]interface{}{
+				"alert": map[string]interface{}{
+					"loc-key":  "REACTION_ADDED",
+					"loc-args": []interface{}{reaction.UserID, reaction.Type},
+				},
+			},
+			"reaction": data,
+		},
+		"gcm": map[string]interface{}{
+			"notification": map[string]interface{}{
+				"body_loc_key": "REACTION_ADDED",
+			},
+			"data": map[string]interface{}{
+				"reaction": data,
+			},
+		},
+	}
+
+	// FIXME: The deduplication should be done at device register.
+	deviceTokens := map[string]bool{}
+	for _, device := range devices {
+		if deviceTokens[device.Token] {
+			continue
+		}
+		deviceTokens[device.Token] = true
+		sendPushNotification(ctx, sender, device, pushMap)
+	}
+}
+
+type reactionPayload struct {
+	RawID    string `mapstructure:"record_id"`
+	Type     string `mapstructure:"type"`
+	RecordID skydb.RecordID
+}
+
+func (payload *reactionPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *reactionPayload) Validate() skyerr.Error {
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "record_id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+
+	if payload.Type == "" {
+		return skyerr.NewInvalidArgument("type must not be empty", []string{"type"})
+	}
+	if len([]rune(payload.Type)) > maxReactionTypeLength {
+		return skyerr.NewInvalidArgument("type must not be longer than 64 characters", []string{"type"})
+	}
+	return nil
+}
+
+/*
+ReactHandler adds a reaction of the current user to a record, and
+returns the updated reaction counts of the record.
+
+Reacting is idempotent: a user reacts to a record at most once for each
+reaction type. When a new reaction is added, the reaction:added webhook
+event is dispatched, and the owner of the record is notified by push
+notification.
+
+Read access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:react",
+    "access_token": "ACCESS_TOKEN",
+    "record_id": "article/1",
+    "type": "like"
+}
+EOF
+
+{
+    "result": {
+        "record_id": "article/1",
+        "counts": {"like": 1},
+        "reactions": ["like"]
+    }
+}
+*/
+type ReactHandler struct {
+	NotificationSender push.Sender         `inject:"PushSender"`
+	Webhook            *webhook.Dispatcher `inject:"WebhookDispatcher"`
+	Authenticator      router.Processor    `preprocessor:"authenticator"`
+	DBConn             router.Processor    `preprocessor:"dbconn"`
+	Maintenance        router.Processor    `preprocessor:"maintenance"`
+	InjectAuth         router.Processor    `preprocessor:"inject_auth"`
+	InjectDB           router.Processor    `preprocessor:"inject_db"`
+	RequireAuth        router.Processor    `preprocessor:"require_auth"`
+	PluginReady        router.Processor    `preprocessor:"plugin_ready"`
+	preprocessors      []router.Processor
+}
+
+func (h *ReactHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *ReactHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ReactHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &reactionPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	record, skyErr := getReactionRecord(rpayload, payload.RecordID)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	databaseID := reactionDatabaseID(rpayload.Database)
+	reaction := skydb.Reaction{
+		RecordID:   payload.RecordID,
+		DatabaseID: databaseID,
+		UserID:     rpayload.AuthInfoID,
+		Type:       payload.Type,
+		CreatedAt:  timeNow(),
+	}
+	added, err := rpayload.DBConn.AddReaction(&reaction)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	if added {
+		h.Webhook.Dispatch(webhook.ReactionAdded, payload.RecordID.Type, map[string]interface{}{
+			"record_id": payload.RecordID.String(),
+			"user_id":   reaction.UserID,
+			"type":      reaction.Type,
+		})
+		notifyReactionOwner(rpayload.Context, h.NotificationSender, rpayload.DBConn, record, &reaction)
+	}
+
+	result, skyErr := getReactionResult(rpayload.DBConn, databaseID, payload.RecordID, rpayload.AuthInfoID)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+	response.Result = result
+}
+
+/*
+UnreactHandler removes a reaction of the current user from a record, and
+returns the updated reaction counts of the record.
+
+Removing a reaction that does not exist is not an error. When a reaction
+is removed, the reaction:removed webhook event is dispatched.
+
+Read access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:unreact",
+    "access_token": "ACCESS_TOKEN",
+    "record_id": "article/1",
+    "type": "like"
+}
+EOF
+*/
+type UnreactHandler struct {
+	Webhook       *webhook.Dispatcher `inject:"WebhookDispatcher"`
+	Authenticator router.Processor    `preprocessor:"authenticator"`
+	DBConn        router.Processor    `preprocessor:"dbconn"`
+	Maintenance   router.Processor    `preprocessor:"maintenance"`
+	InjectAuth    router.Processor    `preprocessor:"inject_auth"`
+	InjectDB      router.Processor    `preprocessor:"inject_db"`
+	RequireAuth   router.Processor    `preprocessor:"require_auth"`
+	PluginReady   router.Processor    `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *UnreactHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *UnreactHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *UnreactHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &reactionPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if _, skyErr := getReactionRecord(rpayload, payload.RecordID); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	databaseID := reactionDatabaseID(rpayload.Database)
+	removed, err := rpayload.DBConn.RemoveReaction(databaseID, payload.RecordID, rpayload.AuthInfoID, payload.Type)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	if removed {
+		h.Webhook.Dispatch(webhook.ReactionRemoved, payload.RecordID.Type, map[string]interface{}{
+			"record_id": payload.RecordID.String(),
+			"user_id":   rpayload.AuthInfoID,
+			"type":      payload.Type,
+		})
+	}
+
+	result, skyErr := getReactionResult(rpayload.DBConn, databaseID, payload.RecordID, rpayload.AuthInfoID)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+	response.Result = result
+}
+
+type reactionListPayload struct {
+	RawID    string `mapstructure:"record_id"`
+	RecordID skydb.RecordID
+}
+
+func (payload *reactionListPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *reactionListPayload) Validate() skyerr.Error {
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "record_id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+	return nil
+}
+
+/*
+ReactionListHandler returns the reaction counts of a record, and the
+reaction types the current user reacted to the record with.
+
+Read access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:reactions",
+    "access_token": "ACCESS_TOKEN",
+    "record_id": "article/1"
+}
+EOF
+*/
+type ReactionListHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *ReactionListHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *ReactionListHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ReactionListHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &reactionListPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if _, skyErr := getReactionRecord(rpayload, payload.RecordID); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	result, skyErr := getReactionResult(rpayload.DBConn, reactionDatabaseID(rpayload.Database), payload.RecordID, rpayload.AuthInfoID)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+	response.Result = result
+}
@@ -0,0 +1,287 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type reactionConn struct {
+	skydb.Conn
+	reactions []skydb.Reaction
+	devices   []skydb.Device
+}
+
+func (conn *reactionConn) AddReaction(reaction *skydb.Reaction) (bool, error) {
+	for _, r := range conn.reactions {
+		if r.RecordID == reaction.RecordID && r.DatabaseID == reaction.DatabaseID &&
+			r.UserID == reaction.UserID && r.Type == reaction.Type {
+			return false, nil
+		}
+	}
+	conn.reactions = append(conn.reactions, *reaction)
+	return true, nil
+}
+
+func (conn *reactionConn) RemoveReaction(databaseID string, recordID skydb.RecordID, userID string, reactionType string) (bool, error) {
+	for i, r := range conn.reactions {
+		if r.RecordID == recordID && r.DatabaseID == databaseID &&
+			r.UserID == userID && r.Type == reactionType {
+			conn.reactions = append(conn.reactions[:i], conn.reactions[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (conn *reactionConn) GetReactionCounts(databaseID string, recordID skydb.RecordID) (skydb.ReactionCounts, error) {
+	counts := skydb.ReactionCounts{}
+	for _, r := range conn.reactions {
+		if r.RecordID == recordID && r.DatabaseID == databaseID {
+			counts[r.Type]++
+		}
+	}
+	return counts, nil
+}
+
+func (conn *reactionConn) GetUserReactions(databaseID string, recordID skydb.RecordID, userID string) ([]string, error) {
+	reactionTypes := []string{}
+	for _, r := range conn.reactions {
+		if r.RecordID == recordID && r.DatabaseID == databaseID && r.UserID == userID {
+			reactionTypes = append(reactionTypes, r.Type)
+		}
+	}
+	return reactionTypes, nil
+}
+
+func (conn *reactionConn) QueryDevicesByUser(userID string) ([]skydb.Device, error) {
+	devices := []skydb.Device{}
+	for _, device := range conn.devices {
+		if device.AuthInfoID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+type reactionPrivateDB struct {
+	*skydbtest.MapDB
+	userID string
+}
+
+func (db reactionPrivateDB) DatabaseType() skydb.DatabaseType { return skydb.PrivateDatabase }
+
+func (db reactionPrivateDB) ID() string { return db.userID }
+
+type reactionSender struct{}
+
+func (sender reactionSender) Send(ctx context.Context, m push.Mapper, device skydb.Device) error {
+	return nil
+}
+
+func TestReactionHandlers(t *testing.T) {
+	Convey("Reaction handlers", t, func() {
+		originalSendFunc := sendPushNotification
+		defer func() {
+			sendPushNotification = originalSendFunc
+		}()
+
+		notified := []skydb.Device{}
+		sendPushNotification = func(ctx context.Context, sender push.Sender, device skydb.Device, m push.Mapper) {
+			notified = append(notified, device)
+		}
+
+		conn := &reactionConn{
+			devices: []skydb.Device{
+				{ID: "device1", Type: "ios", Token: "token1", AuthInfoID: "alice"},
+				{ID: "device2", Type: "ios", Token: "token1", AuthInfoID: "alice"},
+				{ID: "device3", Type: "android", Token: "token2", AuthInfoID: "bob"},
+			},
+		}
+		db := skydbtest.NewMapDB()
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("article", "1"),
+			OwnerID: "alice",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("bob", skydb.ReadLevel),
+			},
+		}), ShouldBeNil)
+
+		newRouter := func(handler router.Handler, userID string) *handlertest.SingleRouteRouter {
+			return handlertest.NewSingleRouteRouter(handler, func(p *router.Payload) {
+				p.DBConn = conn
+				p.Database = db
+				p.AuthInfoID = userID
+				p.AuthInfo = &skydb.AuthInfo{ID: userID}
+			})
+		}
+
+		Convey("reacts to readable record and notifies owner", func() {
+			r := newRouter(&ReactHandler{NotificationSender: reactionSender{}}, "bob")
+			resp := r.POST(`{"record_id": "article/1", "type": "like"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_id": "article/1",
+		"counts": {"like": 1},
+		"reactions": ["like"]
+	}
+}`)
+			So(notified, ShouldHaveLength, 1)
+			So(notified[0].ID, ShouldEqual, "device1")
+		})
+
+		Convey("reacts idempotently", func() {
+			conn.reactions = []skydb.Reaction{
+				{RecordID: skydb.NewRecordID("article", "1"), UserID: "bob", Type: "like"},
+			}
+
+			r := newRouter(&ReactHandler{NotificationSender: reactionSender{}}, "bob")
+			resp := r.POST(`{"record_id": "article/1", "type": "like"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_id": "article/1",
+		"counts": {"like": 1},
+		"reactions": ["like"]
+	}
+}`)
+			So(conn.reactions, ShouldHaveLength, 1)
+			So(notified, ShouldBeEmpty)
+		})
+
+		Convey("does not notify owner of own reaction", func() {
+			r := newRouter(&ReactHandler{NotificationSender: reactionSender{}}, "alice")
+			r.POST(`{"record_id": "article/1", "type": "like"}`)
+			So(conn.reactions, ShouldHaveLength, 1)
+			So(notified, ShouldBeEmpty)
+		})
+
+		Convey("refuses to react to unreadable record", func() {
+			r := newRouter(&ReactHandler{}, "carol")
+			resp := r.POST(`{"record_id": "article/1", "type": "like"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "PermissionDenied",
+		"code": 102,
+		"message": "no permission to access reactions of the record"
+	}
+}`)
+			So(conn.reactions, ShouldBeEmpty)
+		})
+
+		Convey("refuses to react without type", func() {
+			r := newRouter(&ReactHandler{}, "bob")
+			resp := r.POST(`{"record_id": "article/1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "type must not be empty",
+		"info": {"arguments": ["type"]}
+	}
+}`)
+		})
+
+		Convey("unreacts", func() {
+			conn.reactions = []skydb.Reaction{
+				{RecordID: skydb.NewRecordID("article", "1"), UserID: "alice", Type: "like"},
+				{RecordID: skydb.NewRecordID("article", "1"), UserID: "bob", Type: "like"},
+			}
+
+			r := newRouter(&UnreactHandler{}, "bob")
+			resp := r.POST(`{"record_id": "article/1", "type": "like"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_id": "article/1",
+		"counts": {"like": 1},
+		"reactions": []
+	}
+}`)
+
+			resp = r.POST(`{"record_id": "article/1", "type": "like"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_id": "article/1",
+		"counts": {"like": 1},
+		"reactions": []
+	}
+}`)
+		})
+
+		Convey("lists reactions", func() {
+			conn.reactions = []skydb.Reaction{
+				{RecordID: skydb.NewRecordID("article", "1"), UserID: "alice", Type: "like"},
+				{RecordID: skydb.NewRecordID("article", "1"), UserID: "bob", Type: "love"},
+				{RecordID: skydb.NewRecordID("article", "2"), UserID: "bob", Type: "like"},
+			}
+
+			r := newRouter(&ReactionListHandler{}, "bob")
+			resp := r.POST(`{"record_id": "article/1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_id": "article/1",
+		"counts": {"like": 1, "love": 1},
+		"reactions": ["love"]
+	}
+}`)
+		})
+
+		Convey("keeps reactions to records of private databases apart", func() {
+			privateDB := reactionPrivateDB{MapDB: skydbtest.NewMapDB(), userID: "bob"}
+			So(privateDB.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("article", "1"),
+				OwnerID: "bob",
+			}), ShouldBeNil)
+			conn.reactions = []skydb.Reaction{
+				{RecordID: skydb.NewRecordID("article", "1"), UserID: "alice", Type: "like"},
+			}
+
+			r := handlertest.NewSingleRouteRouter(&ReactHandler{NotificationSender: reactionSender{}}, func(p *router.Payload) {
+				p.DBConn = conn
+				p.Database = privateDB
+				p.AuthInfoID = "bob"
+				p.AuthInfo = &skydb.AuthInfo{ID: "bob"}
+			})
+			resp := r.POST(`{"record_id": "article/1", "type": "like"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_id": "article/1",
+		"counts": {"like": 1},
+		"reactions": ["like"]
+	}
+}`)
+			So(conn.reactions[1].DatabaseID, ShouldEqual, "bob")
+
+			r = newRouter(&ReactionListHandler{}, "alice")
+			resp = r.POST(`{"record_id": "article/1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_id": "article/1",
+		"counts": {"like": 1},
+		"reactions": ["like"]
+	}
+}`)
+		})
+	})
+}
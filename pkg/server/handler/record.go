@@ -17,14 +17,20 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/sirupsen/logrus"
 
 	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/httpcache"
 	pluginEvent "github.com/skygeario/skygear-server/pkg/server/plugin/event"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
+	"github.com/skygeario/skygear-server/pkg/server/querylimit"
+	"github.com/skygeario/skygear-server/pkg/server/recordstats"
 	"github.com/skygeario/skygear-server/pkg/server/recordutil"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -81,9 +87,19 @@ func (s serializedError) MarshalJSON() ([]byte, error) {
 type recordSavePayload struct {
 	Atomic bool `mapstructure:"atomic"`
 
+	// Merge saves only the supplied fields into the existing records.
+	Merge bool `mapstructure:"merge"`
+
 	// RawMaps stores the original incoming `records`.
 	RawMaps []map[string]interface{} `mapstructure:"records"`
 
+	// RawDeleteIDs stores the original incoming `deletes`, the IDs of
+	// records to be deleted after the records are saved.
+	RawDeleteIDs []string `mapstructure:"deletes"`
+
+	// DeleteIDs contains the de-serialized RawDeleteIDs
+	DeleteIDs []skydb.RecordID
+
 	// IncomigItems contains de-serialized recordID or de-serialization error,
 	// the item is one-one corresponding to RawMaps.
 	IncomingItems []interface{}
@@ -119,10 +135,22 @@ func (payload *recordSavePayload) Decode(data map[string]interface{}) skyerr.Err
 }
 
 func (payload *recordSavePayload) Validate() skyerr.Error {
-	if payload.ItemLen() == 0 {
+	if payload.ItemLen() == 0 && len(payload.RawDeleteIDs) == 0 {
 		return skyerr.NewInvalidArgument("expected list of record", []string{"records"})
 	}
 
+	payload.DeleteIDs = make([]skydb.RecordID, len(payload.RawDeleteIDs))
+	for i, rawID := range payload.RawDeleteIDs {
+		ss := strings.SplitN(rawID, "/", 2)
+		if len(ss) == 1 {
+			return skyerr.NewInvalidArgument(
+				`record: "_id" should be of format '{type}/{id}', got "`+rawID+`"`,
+				[]string{"deletes"},
+			)
+		}
+		payload.DeleteIDs[i] = skydb.NewRecordID(ss[0], ss[1])
+	}
+
 	payload.Clean = true
 	payload.Errs = []skyerr.Error{}
 	payload.IncomingItems = []interface{}{}
@@ -139,6 +167,17 @@ func (payload *recordSavePayload) Validate() skyerr.Error {
 		}
 	}
 
+	for _, record := range payload.Records {
+		for _, deleteID := range payload.DeleteIDs {
+			if record.ID == deleteID {
+				return skyerr.NewInvalidArgument(
+					fmt.Sprintf("record %s cannot be both saved and deleted", deleteID),
+					[]string{"deletes"},
+				)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -183,11 +222,34 @@ func (payload *recordSavePayload) InitRecord(m map[string]interface{}, r *skydb.
 		r.ACL = acl
 	}
 
+	if rev, ok := m["_rev"]; ok && rev != nil {
+		f, ok := rev.(float64)
+		if !ok || f != float64(int64(f)) || f < 0 {
+			return skyerr.NewInvalidArgument("_rev must be an integer", []string{"_rev"})
+		}
+		r.Revision = int64(f)
+	}
+
+	patches, err := skyconv.ExtractPatches(m)
+	if err != nil {
+		return skyerr.NewInvalidArgument(err.Error(), []string{"$patch"})
+	}
+	increments, err := skyconv.ExtractIncrements(m)
+	if err != nil {
+		return skyerr.NewInvalidArgument(err.Error(), []string{"$inc"})
+	}
+
 	payload.purgeReservedKey(m)
 	data := map[string]interface{}{}
 	if err := (*skyconv.MapData)(&data).FromMap(m); err != nil {
 		return skyerr.NewError(skyerr.InvalidArgument, err.Error())
 	}
+	for key, value := range increments {
+		data[key] = value
+	}
+	for key, value := range patches {
+		data[key] = value
+	}
 	r.Data = data
 
 	return nil
@@ -237,19 +299,146 @@ curl -X POST -H "Content-Type: application/json" \
   ]
 }
 EOF
+
+Save with revision
+
+Records returned by the server carry a revision in `_rev`. When the
+record revision policy is "reject" or "require", supplying `_rev` makes
+the save fail with RecordConflict if the record has been modified since
+that revision. With "require", saving an existing record without `_rev`
+is an error.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+  "action": "record:save",
+  "database_id": "_public",
+  "access_token": "986bee3b-8dd9-45c2-b40c-8b6ef274cf12",
+  "records": [
+    {
+      "_id": "note/71BAE736-E9C5-43CB-ADD1-D8633B80CAFA",
+      "_rev": 3,
+      "content": "hello"
+    }
+  ]
+}
+EOF
+
+Save and delete in a batch
+
+Records listed in `deletes` are deleted after the records are saved. With
+"atomic": true, the saves and deletes either all succeed or are all rolled
+back in one transaction. Otherwise each of them succeeds or fails on its
+own, and the results of the deletes follow the results of the saves.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+  "action": "record:save",
+  "database_id": "_public",
+  "access_token": "986bee3b-8dd9-45c2-b40c-8b6ef274cf12",
+  "atomic": true,
+  "records": [
+    {
+      "_id": "invoice/2",
+      "amount": 100
+    }
+  ],
+  "deletes": ["draft/1"]
+}
+EOF
+
+Increment fields
+
+Numeric fields listed in "$inc" are incremented by the given amount in the
+database, instead of being replaced by a value computed by the client.
+Concurrent increments to the same field do not lose updates. A field
+without a value is incremented from zero.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+  "action": "record:save",
+  "database_id": "_public",
+  "access_token": "986bee3b-8dd9-45c2-b40c-8b6ef274cf12",
+  "records": [
+    {
+      "_id": "note/71BAE736-E9C5-43CB-ADD1-D8633B80CAFA",
+      "$inc": {"likes": 1}
+    }
+  ]
+}
+EOF
+
+Patch fields
+
+JSON fields listed in "$patch" are patched in the database, instead of
+being replaced by the whole value. A patch is either a JSON merge patch
+(RFC 7386) object, or an array of JSON Patch (RFC 6902) operations. String
+fields are patched with an array of "splice" operations, which delete
+"delete" characters at "offset" and insert "insert" in their place. The
+save fails with InvalidArgument if a patch cannot be applied, such as
+when a JSON Patch "test" operation fails.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+  "action": "record:save",
+  "database_id": "_public",
+  "access_token": "986bee3b-8dd9-45c2-b40c-8b6ef274cf12",
+  "records": [
+    {
+      "_id": "note/71BAE736-E9C5-43CB-ADD1-D8633B80CAFA",
+      "$patch": {
+        "settings": {"theme": "dark", "font": null},
+        "tags": [{"op": "add", "path": "/-", "value": "urgent"}],
+        "content": [{"op": "splice", "offset": 6, "delete": 5, "insert": "there"}]
+      }
+    }
+  ]
+}
+EOF
+
+Merge into existing records
+
+With "merge": true, only the fields supplied in each record are written to
+the existing record in a single update, and other fields are left as they
+are in the database. Concurrent saves to different fields of the same
+record do not overwrite each other. Records that do not exist are not
+created and fail with ResourceNotFound.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+  "action": "record:save",
+  "database_id": "_public",
+  "access_token": "986bee3b-8dd9-45c2-b40c-8b6ef274cf12",
+  "merge": true,
+  "records": [
+    {
+      "_id": "note/71BAE736-E9C5-43CB-ADD1-D8633B80CAFA",
+      "noteOrder": 2
+    }
+  ]
+}
+EOF
 */
 type RecordSaveHandler struct {
-	HookRegistry   *hook.Registry     `inject:"HookRegistry"`
-	AssetStore     asset.Store        `inject:"AssetStore"`
-	AccessModel    skydb.AccessModel  `inject:"AccessModel"`
-	EventSender    pluginEvent.Sender `inject:"PluginEventSender"`
-	AuthRecordKeys [][]string         `inject:"AuthRecordKeys"`
-	Authenticator  router.Processor   `preprocessor:"authenticator"`
-	DBConn         router.Processor   `preprocessor:"dbconn"`
-	InjectAuth     router.Processor   `preprocessor:"inject_auth"`
-	InjectDB       router.Processor   `preprocessor:"inject_db"`
-	RequireAuth    router.Processor   `preprocessor:"require_auth"`
-	PluginReady    router.Processor   `preprocessor:"plugin_ready"`
+	HookRegistry   *hook.Registry         `inject:"HookRegistry"`
+	AssetStore     asset.Store            `inject:"AssetStore"`
+	AccessModel    skydb.AccessModel      `inject:"AccessModel"`
+	EventSender    pluginEvent.Sender     `inject:"PluginEventSender"`
+	AuthRecordKeys [][]string             `inject:"AuthRecordKeys"`
+	RecordStats    *recordstats.Collector `inject:"RecordStats"`
+	RevisionPolicy string                 `inject:"RecordRevisionPolicy"`
+	Transactions   *transaction.Registry  `inject:"TransactionRegistry"`
+	Authenticator  router.Processor       `preprocessor:"authenticator"`
+	DBConn         router.Processor       `preprocessor:"dbconn"`
+	Maintenance    router.Processor       `preprocessor:"maintenance"`
+	InjectAuth     router.Processor       `preprocessor:"inject_auth"`
+	InjectDB       router.Processor       `preprocessor:"inject_db"`
+	RequireAuth    router.Processor       `preprocessor:"require_auth"`
+	PluginReady    router.Processor       `preprocessor:"plugin_ready"`
 	preprocessors  []router.Processor
 }
 
@@ -257,6 +446,7 @@ func (h *RecordSaveHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.RequireAuth,
@@ -268,6 +458,15 @@ func (h *RecordSaveHandler) GetPreprocessors() []router.Processor {
 	return h.preprocessors
 }
 
+func (h *RecordSaveHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "records", Type: router.ArrayField, Elem: router.ObjectField},
+		{Name: "deletes", Type: router.ArrayField, Elem: router.StringField},
+		{Name: "atomic", Type: router.BooleanField},
+		{Name: "merge", Type: router.BooleanField},
+	}
+}
+
 func (h *RecordSaveHandler) Handle(payload *router.Payload, response *router.Response) {
 	p := &recordSavePayload{}
 	skyErr := p.Decode(payload.Data)
@@ -295,16 +494,19 @@ func (h *RecordSaveHandler) Handle(payload *router.Payload, response *router.Res
 	log.Debugf("Working with accessModel %v", h.AccessModel)
 
 	req := recordutil.RecordModifyRequest{
-		Db:            payload.Database,
-		Conn:          payload.DBConn,
-		AssetStore:    h.AssetStore,
-		HookRegistry:  h.HookRegistry,
-		AuthInfo:      payload.AuthInfo,
-		RecordsToSave: p.Records,
-		Atomic:        p.Atomic,
-		WithMasterKey: payload.HasMasterKey(),
-		Context:       payload.Context,
-		ModifyAt:      timeNow(),
+		Db:                payload.Database,
+		Conn:              payload.DBConn,
+		AssetStore:        h.AssetStore,
+		HookRegistry:      h.HookRegistry,
+		AuthInfo:          payload.AuthInfo,
+		RecordsToSave:     p.Records,
+		RevisionPolicy:    h.RevisionPolicy,
+		Merge:             p.Merge,
+		RecordIDsToDelete: p.DeleteIDs,
+		Atomic:            p.Atomic,
+		WithMasterKey:     payload.HasMasterKey(),
+		Context:           payload.Context,
+		ModifyAt:          timeNow(),
 	}
 	resp := recordutil.RecordModifyResponse{
 		ErrMap: map[skydb.RecordID]skyerr.Error{},
@@ -322,9 +524,9 @@ func (h *RecordSaveHandler) Handle(payload *router.Payload, response *router.Res
 				})
 			return
 		}
-		saveFunc = atomicModifyFunc(&req, &resp, recordutil.RecordSaveHandler)
+		saveFunc = atomicModifyFunc(&req, &resp, h.Transactions, recordutil.RecordBatchHandler)
 	} else {
-		saveFunc = recordutil.RecordSaveHandler
+		saveFunc = recordutil.RecordBatchHandler
 	}
 
 	// derive and extend record schema
@@ -341,14 +543,21 @@ func (h *RecordSaveHandler) Handle(payload *router.Payload, response *router.Res
 		return
 	}
 
-	if err := saveFunc(&req, &resp); err != nil {
-		log.Debugf("Failed to save records: %v", err)
-		response.Err = err
+	startTime := time.Now()
+	saveErr := saveFunc(&req, &resp)
+	observeRecordStats(h.RecordStats, recordstats.Save, recordTypeCounts(p.Records), startTime, saveErr)
+	if len(p.DeleteIDs) > 0 {
+		observeRecordStats(h.RecordStats, recordstats.Delete, recordIDTypeCounts(p.DeleteIDs), startTime, saveErr)
+	}
+	if saveErr != nil {
+		log.Debugf("Failed to save records: %v", saveErr)
+		response.Err = saveErr
 		return
 	}
 
-	results := make([]interface{}, 0, p.ItemLen())
+	results := make([]interface{}, 0, p.ItemLen()+len(p.DeleteIDs))
 	h.makeResultsFromIncomingItem(p.IncomingItems, resp, resultFilter, &results)
+	results = append(results, makeDeleteResults(p.DeleteIDs, resp)...)
 
 	response.Result = results
 
@@ -435,10 +644,15 @@ curl -X POST -H "Content-Type: application/json" \
     "ids": ["note/1004", "note/1005"]
 }
 EOF
+
+If read replicas of the database are configured, the records are read from
+a replica, which may not have the latest writes. To read from the primary
+database, specify "_strong_read": true.
 */
 type RecordFetchHandler struct {
 	AssetStore    asset.Store       `inject:"AssetStore"`
 	AccessModel   skydb.AccessModel `inject:"AccessModel"`
+	CachePolicy   *httpcache.Policy `inject:"CachePolicy"`
 	Authenticator router.Processor  `preprocessor:"authenticator"`
 	DBConn        router.Processor  `preprocessor:"dbconn"`
 	InjectAuth    router.Processor  `preprocessor:"inject_auth"`
@@ -461,6 +675,12 @@ func (h *RecordFetchHandler) GetPreprocessors() []router.Processor {
 	return h.preprocessors
 }
 
+func (h *RecordFetchHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "ids", Type: router.ArrayField, Elem: router.StringField, Required: true},
+	}
+}
+
 func (h *RecordFetchHandler) Handle(payload *router.Payload, response *router.Response) {
 	p := &recordFetchPayload{}
 	skyErr := p.Decode(payload.Data)
@@ -484,6 +704,7 @@ func (h *RecordFetchHandler) Handle(payload *router.Payload, response *router.Re
 	fetcher := recordutil.NewRecordFetcher(db, payload.DBConn, payload.HasMasterKey())
 
 	results := make([]interface{}, p.ItemLen(), p.ItemLen())
+	records := make([]skydb.Record, 0, p.ItemLen())
 	for i, recordID := range p.RecordIDs {
 		record, err := fetcher.FetchRecord(recordID, payload.AuthInfo, skydb.ReadLevel)
 		if err != nil {
@@ -494,13 +715,32 @@ func (h *RecordFetchHandler) Handle(payload *router.Payload, response *router.Re
 			continue
 		}
 		results[i] = resultFilter.JSONResult(record)
+		records = append(records, *record)
 	}
 
 	response.Result = results
+
+	// Responses containing errors are not cached.
+	if len(records) == len(p.RecordIDs) {
+		h.CachePolicy.SetRecordHeaders(payload, response, "", records)
+	}
 }
 
 type recordQueryPayload struct {
 	Query skydb.Query
+
+	// Meta is true if the pagination metadata is requested, and
+	// MetaCount is the count mode of the metadata.
+	Meta      bool
+	MetaCount string
+
+	// CountOnly is true if only the number of matching records is
+	// requested, without fetching the records.
+	CountOnly bool
+
+	// Stream is true if the records are streamed as newline-delimited
+	// JSON instead of being returned in a single response.
+	Stream bool
 }
 
 func (payload *recordQueryPayload) Decode(data map[string]interface{}, parser *QueryParser) skyerr.Error {
@@ -513,10 +753,60 @@ func (payload *recordQueryPayload) Decode(data map[string]interface{}, parser *Q
 		return err
 	}
 
+	if countOnly, ok := data["count_only"].(bool); ok {
+		payload.CountOnly = countOnly
+	}
+
+	if stream, ok := data["stream"].(bool); ok {
+		payload.Stream = stream
+	}
+
+	switch meta := data["meta"].(type) {
+	case nil:
+	case bool:
+		payload.Meta = meta
+	case map[string]interface{}:
+		payload.Meta = true
+		if count, ok := meta["count"]; ok {
+			payload.MetaCount, ok = count.(string)
+			if !ok {
+				return skyerr.NewInvalidArgument("meta count must be a string", []string{"meta"})
+			}
+		}
+	default:
+		return skyerr.NewInvalidArgument("meta must be a boolean or an object", []string{"meta"})
+	}
+
 	return payload.Validate()
 }
 
 func (payload *recordQueryPayload) Validate() skyerr.Error {
+	switch payload.MetaCount {
+	case recordutil.QueryMetaCountNone, recordutil.QueryMetaCountExact, recordutil.QueryMetaCountEstimated:
+	default:
+		return skyerr.NewInvalidArgument(
+			`meta count must be "exact" or "estimated"`,
+			[]string{"meta"},
+		)
+	}
+
+	if payload.Stream {
+		query := payload.Query
+		switch {
+		case payload.Meta:
+			return skyerr.NewInvalidArgument("meta cannot be used with stream", []string{"meta"})
+		case payload.CountOnly:
+			return skyerr.NewInvalidArgument("count_only cannot be used with stream", []string{"count_only"})
+		case query.GetCount:
+			return skyerr.NewInvalidArgument("count cannot be used with stream", []string{"count"})
+		case query.Explain:
+			return skyerr.NewInvalidArgument("explain cannot be used with stream", []string{"explain"})
+		case query.PageSize > 0:
+			return skyerr.NewInvalidArgument("page_size cannot be used with stream", []string{"page_size"})
+		case len(query.ComputedKeys) > 0, len(query.RelatedIncludes) > 0:
+			return skyerr.NewInvalidArgument("include cannot be used with stream", []string{"include"})
+		}
+	}
 	return nil
 }
 
@@ -534,15 +824,105 @@ curl -X POST -H "Content-Type: application/json" \
     ]
 }
 EOF
+
+Instead of "predicate" and "sort", the query can be written in the string
+query DSL as "query", for example:
+
+    "query": "status = \"open\" AND price < 100 ORDER BY _created_at DESC"
+
+To paginate by keyset, specify "page_size", and pass "next_cursor" in
+the info of the response as "after" to fetch the next page.
+
+To include a page of the records referencing each record, for example the
+latest 3 comments of each post, specify an include of "$type" "related"
+with the record type, the referencing field as "key", and "page_size":
+
+    "include": {
+        "comments": {
+            "$type": "related",
+            "record_type": "comment",
+            "key": "post",
+            "sort": [[{"$type": "keypath", "$val": "_created_at"}, "desc"]],
+            "page_size": 3
+        }
+    }
+
+An optional "predicate" filters the related records. The transient field
+contains the "records" and the "next_cursor" of the page. To fetch the
+next page, query the related record type with the same predicate and sort,
+where "key" equals the record, and pass "next_cursor" as "after".
+
+To diagnose a slow query, specify "explain": true with the master key.
+The query is analyzed and the plan is returned as "query_plan" in the
+info of the response.
+
+Soft-deleted records are excluded from the results. To include them,
+specify "include_deleted": true with the master key.
+
+To query the records as they were at a time, specify "as_of" as an
+RFC 3339 timestamp with the master key, for example
+"as_of": "2017-01-02T15:04:05Z". It requires the change history of the
+record type to be enabled. Included records are returned as they are now.
+
+If read replicas of the database are configured, the records are read from
+a replica, which may not have the latest writes. To read from the primary
+database, specify "_strong_read": true.
+
+To return the pagination metadata as "_meta" in the info of the response,
+specify "meta": true. The total number of matching records is included
+if "meta": {"count": "exact"} is specified, or "meta": {"count": "estimated"}
+to use the cheaper estimate of the database. For example:
+
+{
+    "_meta": {
+        "count": 120,
+        "page": {
+            "offset": 40,
+            "limit": 20,
+            "returned": 20,
+            "has_next": true,
+            "has_previous": true,
+            "next_offset": 60,
+            "previous_offset": 20
+        }
+    }
+}
+
+For queries paginated by keyset, "_meta" contains "next_cursor" instead of
+the offsets. Keyset pagination only goes forward, so there is no previous
+cursor.
+
+To count the matching records without fetching them, specify
+"count_only": true. The result is empty and the number of matching
+records, regardless of "offset" and "limit", is returned as "count" in
+the info of the response.
+
+To export a large number of records, specify "stream": true. The records
+are fetched from the database incrementally and written as
+newline-delimited JSON (application/x-ndjson), one record per line,
+instead of a single response. If an error occurs after the records
+started to be written, the last line is an object with "error". It
+cannot be used with "include", "count", "count_only", "meta", "explain"
+or "page_size".
+
+Queries exceeding the configured limits on their cost are rejected with
+RecordQueryTooComplex. Without the master key, "limit" and "page_size"
+cannot be more than the maximum of the record type, and queries without
+them return at most the maximum number of records. The nesting depth of
+the predicate and the number of referenced record types joined are
+limited regardless of the master key.
 */
 type RecordQueryHandler struct {
-	AssetStore    asset.Store       `inject:"AssetStore"`
-	AccessModel   skydb.AccessModel `inject:"AccessModel"`
-	Authenticator router.Processor  `preprocessor:"authenticator"`
-	DBConn        router.Processor  `preprocessor:"dbconn"`
-	InjectAuth    router.Processor  `preprocessor:"inject_auth"`
-	InjectDB      router.Processor  `preprocessor:"inject_db"`
-	PluginReady   router.Processor  `preprocessor:"plugin_ready"`
+	AssetStore    asset.Store            `inject:"AssetStore"`
+	AccessModel   skydb.AccessModel      `inject:"AccessModel"`
+	CachePolicy   *httpcache.Policy      `inject:"CachePolicy"`
+	RecordStats   *recordstats.Collector `inject:"RecordStats"`
+	QueryLimits   *querylimit.Limits     `inject:"QueryLimits"`
+	Authenticator router.Processor       `preprocessor:"authenticator"`
+	DBConn        router.Processor       `preprocessor:"dbconn"`
+	InjectAuth    router.Processor       `preprocessor:"inject_auth"`
+	InjectDB      router.Processor       `preprocessor:"inject_db"`
+	PluginReady   router.Processor       `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
 }
 
@@ -560,6 +940,28 @@ func (h *RecordQueryHandler) GetPreprocessors() []router.Processor {
 	return h.preprocessors
 }
 
+func (h *RecordQueryHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "record_type", Type: router.StringField, Required: true},
+		{Name: "predicate", Type: router.ArrayField},
+		{Name: "sort", Type: router.ArrayField, Elem: router.ArrayField},
+		{Name: "query", Type: router.StringField},
+		{Name: "include", Type: router.ObjectField},
+		{Name: "desired_keys", Type: router.ArrayField, Elem: router.StringField},
+		{Name: "distinct_on", Type: router.ArrayField, Elem: router.StringField},
+		{Name: "count", Type: router.BooleanField},
+		{Name: "count_only", Type: router.BooleanField},
+		{Name: "explain", Type: router.BooleanField},
+		{Name: "include_deleted", Type: router.BooleanField},
+		{Name: "as_of", Type: router.StringField},
+		{Name: "offset", Type: router.NumberField},
+		{Name: "limit", Type: router.NumberField},
+		{Name: "after", Type: router.StringField},
+		{Name: "page_size", Type: router.NumberField},
+		{Name: "stream", Type: router.BooleanField},
+	}
+}
+
 func (h *RecordQueryHandler) Handle(payload *router.Payload, response *router.Response) {
 	p := &recordQueryPayload{}
 	parser := QueryParser{UserID: payload.AuthInfoID}
@@ -577,6 +979,33 @@ func (h *RecordQueryHandler) Handle(payload *router.Payload, response *router.Re
 		p.Query.BypassAccessControl = true
 	}
 
+	if p.Query.Explain && !payload.HasMasterKey() {
+		response.Err = skyerr.NewError(skyerr.PermissionDenied, "explain requires master key")
+		return
+	}
+
+	if p.Query.IncludeDeleted && !payload.HasMasterKey() {
+		response.Err = skyerr.NewError(skyerr.PermissionDenied, "include_deleted requires master key")
+		return
+	}
+
+	if p.Query.AsOf != nil && !payload.HasMasterKey() {
+		response.Err = skyerr.NewError(skyerr.PermissionDenied, "as_of requires master key")
+		return
+	}
+
+	if err := h.QueryLimits.CheckPredicate(&p.Query); err != nil {
+		response.Err = err
+		return
+	}
+
+	if !payload.HasMasterKey() && !p.CountOnly {
+		if err := h.QueryLimits.ApplyLimit(&p.Query); err != nil {
+			response.Err = err
+			return
+		}
+	}
+
 	fieldACL := func() skydb.FieldACL {
 		acl, err := payload.DBConn.GetRecordFieldAccess()
 		if err != nil {
@@ -604,10 +1033,37 @@ func (h *RecordQueryHandler) Handle(payload *router.Payload, response *router.Re
 		}
 	}
 
+	for transientKey, related := range p.Query.RelatedIncludes {
+		if err := h.prepareRelatedInclude(payload, fieldACL, p.Query.Type, &related); err != nil {
+			response.Err = err
+			return
+		}
+		p.Query.RelatedIncludes[transientKey] = related
+	}
+
 	db := payload.Database
 
+	if p.CountOnly {
+		recordCount, err := db.QueryCount(&p.Query)
+		if err != nil {
+			response.Err = skyerr.MakeError(err)
+			return
+		}
+		response.Result = []interface{}{}
+		response.Info = map[string]interface{}{"count": recordCount}
+		return
+	}
+
+	if p.Stream {
+		h.stream(payload, response, &p.Query)
+		return
+	}
+
+	p.Query.Includes = recordutil.QueryIncludes(db, p.Query)
+	startTime := time.Now()
 	results, err := db.Query(&p.Query)
 	if err != nil {
+		h.RecordStats.Observe(p.Query.Type, recordstats.Query, 0, time.Since(startTime), err)
 		response.Err = skyerr.MakeError(err)
 		return
 	}
@@ -619,6 +1075,7 @@ func (h *RecordQueryHandler) Handle(payload *router.Payload, response *router.Re
 		records = append(records, record)
 	}
 
+	h.RecordStats.Observe(p.Query.Type, recordstats.Query, len(records), time.Since(startTime), results.Err())
 	if results.Err() != nil {
 		response.Err = skyerr.MakeError(results.Err())
 		return
@@ -629,7 +1086,27 @@ func (h *RecordQueryHandler) Handle(payload *router.Payload, response *router.Re
 	// so we replace them with some complete assets.
 	recordutil.MakeAssetsComplete(db, payload.DBConn, records)
 
-	eagerRecords := recordutil.DoQueryEager(db, recordutil.EagerIDs(db, records, p.Query))
+	// Records already included by the database are not fetched again.
+	eagerIDs := recordutil.EagerIDs(db, records, p.Query)
+	includedRecords := results.IncludedRecords()
+	for keyPath := range includedRecords {
+		delete(eagerIDs, keyPath)
+	}
+	eagerRecords := recordutil.DoQueryEager(db, eagerIDs)
+	for keyPath, records := range includedRecords {
+		eagerRecords[keyPath] = records
+	}
+
+	relatedPages, err := recordutil.DoQueryRelated(db, p.Query, records)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	for _, pages := range relatedPages {
+		for _, page := range pages {
+			recordutil.MakeAssetsComplete(db, payload.DBConn, page.Records)
+		}
+	}
 
 	recordResultFilter, err := recordutil.NewRecordResultFilter(
 		payload.DBConn,
@@ -646,6 +1123,7 @@ func (h *RecordQueryHandler) Handle(payload *router.Payload, response *router.Re
 		Database:           db,
 		Query:              p.Query,
 		EagerRecords:       eagerRecords,
+		RelatedPages:       relatedPages,
 		RecordResultFilter: recordResultFilter,
 	}
 
@@ -657,19 +1135,172 @@ func (h *RecordQueryHandler) Handle(payload *router.Payload, response *router.Re
 
 	response.Result = output
 
+	responseRecords := append([]skydb.Record{}, records...)
+	for _, recordsByKey := range eagerRecords {
+		for _, record := range recordsByKey {
+			responseRecords = append(responseRecords, *record)
+		}
+	}
+	for _, pages := range relatedPages {
+		for _, page := range pages {
+			responseRecords = append(responseRecords, page.Records...)
+		}
+	}
+	h.CachePolicy.SetRecordHeaders(payload, response, p.Query.Type, responseRecords)
+
 	resultInfo, err := recordutil.QueryResultInfo(db, &p.Query, results)
 	if err != nil {
 		response.Err = skyerr.MakeError(err)
 		return
 	}
+	if p.Meta {
+		meta, err := recordutil.NewQueryMeta(db, &p.Query, results, len(records), p.MetaCount)
+		if err != nil {
+			response.Err = skyerr.MakeError(err)
+			return
+		}
+		resultInfo["_meta"] = meta
+	}
 	if len(resultInfo) > 0 {
 		response.Info = resultInfo
 	}
 }
 
+// prepareRelatedInclude applies the access control and the limits of the
+// request to a related include of a query of recordType.
+func (h *RecordQueryHandler) prepareRelatedInclude(payload *router.Payload, fieldACL skydb.FieldACL, recordType string, related *skydb.RelatedQuery) skyerr.Error {
+	related.ViewAsUser = payload.AuthInfo
+	related.BypassAccessControl = payload.HasMasterKey()
+
+	if err := h.QueryLimits.CheckPredicate(&related.Query); err != nil {
+		return err
+	}
+
+	if !payload.HasMasterKey() {
+		if err := h.QueryLimits.ApplyLimit(&related.Query); err != nil {
+			return err
+		}
+	}
+
+	if !related.BypassAccessControl {
+		visitor := &queryAccessVisitor{
+			FieldACL:   fieldACL,
+			RecordType: related.Type,
+			AuthInfo:   related.ViewAsUser,
+			ExpressionACLChecker: ExpressionACLChecker{
+				FieldACL:   fieldACL,
+				RecordType: related.Type,
+				AuthInfo:   payload.AuthInfo,
+				Database:   payload.Database,
+			},
+		}
+		// The referencing field is checked as if it is compared in
+		// the predicate.
+		query := related.Query
+		query.Predicate = related.ReferencingPredicate(skydb.NewReference(recordType, ""))
+		query.Accept(visitor)
+		if err := visitor.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordQueryStreamBatchSize is the number of records of a streaming query
+// written to the response at a time.
+const recordQueryStreamBatchSize = 100
+
+// stream writes the records of the query to the response as
+// newline-delimited JSON while they are fetched from the database.
+func (h *RecordQueryHandler) stream(payload *router.Payload, response *router.Response, query *skydb.Query) {
+	db := payload.Database
+
+	recordResultFilter, err := recordutil.NewRecordResultFilter(
+		payload.DBConn,
+		h.AssetStore,
+		payload.AuthInfo,
+		query.BypassAccessControl,
+	)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	resultFilter := recordutil.QueryResultFilter{
+		Database:           db,
+		Query:              *query,
+		RecordResultFilter: recordResultFilter,
+	}
+
+	startTime := time.Now()
+	results, err := db.QueryStream(query)
+	if err != nil {
+		h.RecordStats.Observe(query.Type, recordstats.Query, 0, time.Since(startTime), err)
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	defer results.Close()
+
+	writer := response.Writer()
+	if writer == nil {
+		// The response is already written.
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/x-ndjson")
+	writer.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(writer)
+	flusher, _ := writer.(http.Flusher)
+
+	count := 0
+	records := make([]skydb.Record, 0, recordQueryStreamBatchSize)
+	writeRecords := func() error {
+		// Scan does not query assets,
+		// so we replace them with some complete assets.
+		recordutil.MakeAssetsComplete(db, payload.DBConn, records)
+		for i := range records {
+			if err := encoder.Encode(resultFilter.JSONResult(&records[i])); err != nil {
+				return err
+			}
+		}
+		count += len(records)
+		records = records[:0]
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	for results.Scan() {
+		records = append(records, results.Record())
+		if len(records) < recordQueryStreamBatchSize {
+			continue
+		}
+		if err := writeRecords(); err != nil {
+			// there is nothing we can do if error occurred after
+			// started writing a response. Log.
+			log.Errorf("Error writing records to response: %v", err)
+			return
+		}
+	}
+	if err := writeRecords(); err != nil {
+		log.Errorf("Error writing records to response: %v", err)
+		return
+	}
+
+	if err := results.Err(); err != nil {
+		encoder.Encode(map[string]interface{}{
+			"error": skyerr.MakeError(err),
+		})
+	}
+
+	h.RecordStats.Observe(query.Type, recordstats.Query, count, time.Since(startTime), results.Err())
+}
+
 type recordDeletePayload struct {
 	RawIDs    []string `mapstructure:"ids"`
 	Atomic    bool     `mapstructure:"atomic"`
+	Soft      bool     `mapstructure:"soft"`
 	RecordIDs []skydb.RecordID
 }
 
@@ -717,16 +1348,24 @@ curl -X POST -H "Content-Type: application/json" \
     "ids": ["note/EA6A3E68-90F3-49B5-B470-5FFDB7A0D4E8"]
 }
 EOF
+
+To keep the records in the database, specify "soft": true. Soft-deleted
+records are excluded from fetches and queries, except that they remain
+visible with the master key. Saving a soft-deleted record with the master
+key restores it, and deleting it again without "soft" purges it.
 */
 type RecordDeleteHandler struct {
-	HookRegistry  *hook.Registry    `inject:"HookRegistry"`
-	AccessModel   skydb.AccessModel `inject:"AccessModel"`
-	Authenticator router.Processor  `preprocessor:"authenticator"`
-	DBConn        router.Processor  `preprocessor:"dbconn"`
-	InjectAuth    router.Processor  `preprocessor:"inject_auth"`
-	InjectDB      router.Processor  `preprocessor:"inject_db"`
-	RequireAuth   router.Processor  `preprocessor:"require_auth"`
-	PluginReady   router.Processor  `preprocessor:"plugin_ready"`
+	HookRegistry  *hook.Registry         `inject:"HookRegistry"`
+	AccessModel   skydb.AccessModel      `inject:"AccessModel"`
+	RecordStats   *recordstats.Collector `inject:"RecordStats"`
+	Transactions  *transaction.Registry  `inject:"TransactionRegistry"`
+	Authenticator router.Processor       `preprocessor:"authenticator"`
+	DBConn        router.Processor       `preprocessor:"dbconn"`
+	Maintenance   router.Processor       `preprocessor:"maintenance"`
+	InjectAuth    router.Processor       `preprocessor:"inject_auth"`
+	InjectDB      router.Processor       `preprocessor:"inject_db"`
+	RequireAuth   router.Processor       `preprocessor:"require_auth"`
+	PluginReady   router.Processor       `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
 }
 
@@ -734,6 +1373,7 @@ func (h *RecordDeleteHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.RequireAuth,
@@ -745,6 +1385,14 @@ func (h *RecordDeleteHandler) GetPreprocessors() []router.Processor {
 	return h.preprocessors
 }
 
+func (h *RecordDeleteHandler) PayloadSchema() router.PayloadSchema {
+	return router.PayloadSchema{
+		{Name: "ids", Type: router.ArrayField, Elem: router.StringField, Required: true},
+		{Name: "atomic", Type: router.BooleanField},
+		{Name: "soft", Type: router.BooleanField},
+	}
+}
+
 func (h *RecordDeleteHandler) Handle(payload *router.Payload, response *router.Response) {
 	p := &recordDeletePayload{}
 	skyErr := p.Decode(payload.Data)
@@ -763,7 +1411,9 @@ func (h *RecordDeleteHandler) Handle(payload *router.Payload, response *router.R
 		Conn:              payload.DBConn,
 		HookRegistry:      h.HookRegistry,
 		RecordIDsToDelete: p.RecordIDs,
+		SoftDelete:        p.Soft,
 		Atomic:            p.Atomic,
+		ModifyAt:          timeNow(),
 		WithMasterKey:     payload.HasMasterKey(),
 		Context:           payload.Context,
 		AuthInfo:          payload.AuthInfo,
@@ -774,19 +1424,26 @@ func (h *RecordDeleteHandler) Handle(payload *router.Payload, response *router.R
 
 	var deleteFunc recordModifyFunc
 	if p.Atomic {
-		deleteFunc = atomicModifyFunc(&req, &resp, recordutil.RecordDeleteHandler)
+		deleteFunc = atomicModifyFunc(&req, &resp, h.Transactions, recordutil.RecordDeleteHandler)
 	} else {
 		deleteFunc = recordutil.RecordDeleteHandler
 	}
 
-	if err := deleteFunc(&req, &resp); err != nil {
+	startTime := time.Now()
+	err := deleteFunc(&req, &resp)
+	observeRecordStats(h.RecordStats, recordstats.Delete, recordIDTypeCounts(p.RecordIDs), startTime, err)
+	if err != nil {
 		log.Debugf("Failed to delete records: %v", err)
 		response.Err = err
 		return
 	}
 
-	results := make([]interface{}, 0, p.ItemLen())
-	for _, recordID := range p.RecordIDs {
+	response.Result = makeDeleteResults(p.RecordIDs, resp)
+}
+
+func makeDeleteResults(recordIDs []skydb.RecordID, resp recordutil.RecordModifyResponse) []interface{} {
+	results := make([]interface{}, 0, len(recordIDs))
+	for _, recordID := range recordIDs {
 		var result interface{}
 
 		if err, ok := resp.ErrMap[recordID]; ok {
@@ -807,13 +1464,18 @@ func (h *RecordDeleteHandler) Handle(payload *router.Payload, response *router.R
 
 		results = append(results, result)
 	}
-
-	response.Result = results
+	return results
 }
 
 type recordModifyFunc func(*recordutil.RecordModifyRequest, *recordutil.RecordModifyResponse) skyerr.Error
 
-func atomicModifyFunc(req *recordutil.RecordModifyRequest, resp *recordutil.RecordModifyResponse, mFunc recordModifyFunc) recordModifyFunc {
+// atomicModifyFunc runs mFunc in a transaction, which is exposed to the
+// hooks run by mFunc through the transaction registry.
+//
+// If the request is run in a unit of work of a plugin, mFunc is run in the
+// transaction of the unit of work, which is marked to be rolled back if
+// mFunc fails.
+func atomicModifyFunc(req *recordutil.RecordModifyRequest, resp *recordutil.RecordModifyResponse, transactions *transaction.Registry, mFunc recordModifyFunc) recordModifyFunc {
 	return func(req *recordutil.RecordModifyRequest, resp *recordutil.RecordModifyResponse) (err skyerr.Error) {
 		txDB, ok := req.Db.(skydb.Transactional)
 		if !ok {
@@ -821,9 +1483,29 @@ func atomicModifyFunc(req *recordutil.RecordModifyRequest, resp *recordutil.Reco
 			return
 		}
 
-		txErr := skydb.WithTransaction(txDB, func() error {
-			return mFunc(req, resp)
-		})
+		var txErr error
+		if tx := transaction.FromContext(req.Context); tx != nil {
+			if modifyErr := mFunc(req, resp); modifyErr != nil {
+				txErr = modifyErr
+			}
+			if txErr != nil || len(resp.ErrMap) > 0 {
+				transactions.MarkRollback(tx)
+			}
+		} else {
+			txErr = skydb.WithTransaction(txDB, func() error {
+				tx := transactions.Join(req.Conn)
+				req.Context = transaction.NewContext(req.Context, tx)
+
+				var err error
+				if modifyErr := mFunc(req, resp); modifyErr != nil {
+					err = modifyErr
+				}
+				if leaveErr := transactions.Leave(tx); err == nil {
+					err = leaveErr
+				}
+				return err
+			})
+		}
 
 		if len(resp.ErrMap) > 0 {
 			info := map[string]interface{}{}
@@ -834,6 +1516,9 @@ func atomicModifyFunc(req *recordutil.RecordModifyRequest, resp *recordutil.Reco
 			return skyerr.NewErrorWithInfo(skyerr.AtomicOperationFailure,
 				"Atomic Operation rolled back due to one or more errors",
 				info)
+		} else if txErr == transaction.ErrRolledBack {
+			err = skyerr.NewError(skyerr.AtomicOperationFailure,
+				"Atomic Operation rolled back by plugin")
 		} else if txErr != nil {
 			err = skyerr.NewErrorWithInfo(skyerr.AtomicOperationFailure,
 				"Atomic Operation rolled back due to an error",
@@ -843,3 +1528,28 @@ func atomicModifyFunc(req *recordutil.RecordModifyRequest, resp *recordutil.Reco
 		return
 	}
 }
+
+// observeRecordStats records the duration since startTime for each record
+// type involved in an operation, which failed if err is not nil.
+func observeRecordStats(stats *recordstats.Collector, op recordstats.Operation, typeCounts map[string]int, startTime time.Time, err error) {
+	elapsed := time.Since(startTime)
+	for recordType, count := range typeCounts {
+		stats.Observe(recordType, op, count, elapsed, err)
+	}
+}
+
+func recordTypeCounts(records []*skydb.Record) map[string]int {
+	counts := map[string]int{}
+	for _, record := range records {
+		counts[record.ID.Type]++
+	}
+	return counts
+}
+
+func recordIDTypeCounts(recordIDs []skydb.RecordID) map[string]int {
+	counts := map[string]int{}
+	for _, recordID := range recordIDs {
+		counts[recordID.Type]++
+	}
+	return counts
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/hook/hooktest"
+	"github.com/skygeario/skygear-server/pkg/server/querylimit"
 	"github.com/skygeario/skygear-server/pkg/server/recordutil"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -93,6 +95,37 @@ func TestRecordDeleteHandler(t *testing.T) {
 }`)
 		})
 
+		Convey("soft deletes existing records", func() {
+			realTime := timeNow
+			timeNow = func() time.Time { return time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC) }
+			defer func() {
+				timeNow = realTime
+			}()
+
+			resp := router.POST(`{
+	"ids": ["note/0"],
+	"soft": true
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": [
+		{"_id": "note/0", "_type": "record"}
+	]
+}`)
+
+			record := db.RecordMap["note/0"]
+			So(*record.DeletedAt, ShouldResemble, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC))
+			So(record.UpdaterID, ShouldEqual, "user0")
+
+			resp = router.POST(`{
+	"ids": ["note/0"]
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": [
+		{"_id": "note/0", "_type": "error", "code": 110, "message": "record not found", "name": "ResourceNotFound"}
+	]
+}`)
+		})
+
 		Convey("returns error when record doesn't exist", func() {
 			resp := router.POST(`{
 	"ids": ["note/0", "note/notexistid"]
@@ -245,6 +278,79 @@ func TestRecordSaveHandler(t *testing.T) {
 			}`)
 		})
 
+		Convey("Should not be able to take over soft-deleted record", func() {
+			deletedAt := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+			db.Save(&skydb.Record{
+				ID:        skydb.NewRecordID("note", "deleted"),
+				OwnerID:   "user1",
+				DeletedAt: &deletedAt,
+				ACL: skydb.RecordACL{
+					skydb.NewRecordACLEntryDirect("user1", skydb.WriteLevel),
+				},
+				Data: skydb.Data{"content": "secret"},
+			})
+
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/deleted",
+					"_access": [{"user_id": "user0", "level": "write"}],
+					"content": "taken over"
+				}]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [
+					{
+						"_id": "note/deleted",
+						"_type": "error",
+						"code": 102,
+						"message": "no permission to save a deleted record",
+						"name": "PermissionDenied"
+					}
+				]
+			}`)
+
+			record := db.RecordMap["note/deleted"]
+			So(record.OwnerID, ShouldEqual, "user1")
+			So(*record.DeletedAt, ShouldResemble, deletedAt)
+			So(record.Data["content"], ShouldEqual, "secret")
+		})
+
+		Convey("Rejects new record missing required fields", func() {
+			db.Extend("task", skydb.RecordSchema{
+				"title":  skydb.FieldType{Type: skydb.TypeString, Required: true},
+				"due_at": skydb.FieldType{Type: skydb.TypeDateTime, Required: true},
+				"status": skydb.FieldType{Type: skydb.TypeString, Required: true, Default: "todo"},
+			})
+
+			resp := r.POST(`{
+				"records": [{
+					"_id": "task/id1",
+					"title": "Write report"
+				}, {
+					"_id": "task/id2",
+					"status": null
+				}]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_id": "task/id1",
+					"_type": "error",
+					"code": 108,
+					"message": "missing required fields",
+					"name": "InvalidArgument",
+					"info": {"arguments": ["due_at"]}
+				}, {
+					"_id": "task/id2",
+					"_type": "error",
+					"code": 108,
+					"message": "missing required fields",
+					"name": "InvalidArgument",
+					"info": {"arguments": ["due_at", "status", "title"]}
+				}]
+			}`)
+			So(db.Get(skydb.NewRecordID("task", "id1"), &skydb.Record{}), ShouldEqual, skydb.ErrRecordNotFound)
+		})
+
 		Convey("Removes reserved keys on save", func() {
 			resp := r.POST(`{
 				"records": [{
@@ -289,81 +395,583 @@ func TestRecordSaveHandler(t *testing.T) {
 			}]}`)
 		})
 
-		Convey("Permission denied on saving a read only record", func() {
+		Convey("Permission denied on saving a read only record", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/readonly",
+					"content": "hello"
+				}]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_id": "note/readonly",
+					"_type": "error",
+					"code": 102,
+					"message": "no permission to perform operation",
+					"name": "PermissionDenied"
+				}]
+			}`)
+		})
+		Convey("REGRESSION #119: Returns record invalid error if _id is missing or malformated", func() {
+			resp := r.POST(`{
+				"records": [{
+				}, {
+					"_id": "invalidkey"
+				}]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_type": "error",
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "missing required fields",
+					"info": {"arguments":["id"]}
+				},{
+					"_type": "error",
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "record: \"_id\" should be of format '{type}/{id}', got \"invalidkey\"",
+					"info": {"arguments":["id"]}
+			}]}`)
+		})
+
+		Convey("REGRESSION #140: Save record correctly when record._access is null", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "type/id",
+					"_access": null
+				}]
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_type": "record",
+					"_id": "type/id",
+					"_access": null,
+					"_created_by":"user0",
+					"_updated_by":"user0",
+					"_ownerID": "user0"
+				}]
+			}`)
+		})
+
+		Convey("REGRESSION #333: Save record with empty key be ignored as start with _", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "type/id",
+					"": ""
+				}]
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_type": "record",
+					"_id": "type/id",
+					"_access": null,
+					"_created_by":"user0",
+					"_updated_by":"user0",
+					"_ownerID": "user0"
+				}]
+			}`)
+		})
+	})
+
+	Convey("RecordSaveHandler with deletes", t, func() {
+		mapDB := skydbtest.NewMapDB()
+		db := skydbtest.NewMockTxDatabase(mapDB)
+		conn := skydbtest.NewMapConn()
+
+		So(db.Save(&skydb.Record{
+			ID: skydb.NewRecordID("draft", "1"),
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("user0", skydb.WriteLevel),
+			},
+		}), ShouldBeNil)
+
+		r := handlertest.NewSingleRouteRouter(&RecordSaveHandler{}, func(payload *router.Payload) {
+			payload.DBConn = conn
+			payload.Database = db
+			payload.AuthInfo = &skydb.AuthInfo{
+				ID: "user0",
+			}
+		})
+
+		Convey("saves and deletes records", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "invoice/1",
+					"amount": 100
+				}],
+				"deletes": ["draft/1", "draft/notexistid"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_id": "invoice/1",
+					"_type": "record",
+					"_access": null,
+					"amount": 100,
+					"_created_by":"user0",
+					"_updated_by":"user0",
+					"_ownerID": "user0"
+				},
+				{"_id": "draft/1", "_type": "record"},
+				{"_id": "draft/notexistid", "_type": "error", "code": 110, "message": "record not found", "name": "ResourceNotFound"}]
+			}`)
+			So(mapDB.RecordMap, ShouldContainKey, "invoice/1")
+			So(mapDB.RecordMap, ShouldNotContainKey, "draft/1")
+			So(db.DidBegin, ShouldBeFalse)
+		})
+
+		Convey("deletes records without saving", func() {
+			resp := r.POST(`{
+				"deletes": ["draft/1"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{"_id": "draft/1", "_type": "record"}]
+			}`)
+		})
+
+		Convey("rolls back atomic batch if a delete fails", func() {
+			resp := r.POST(`{
+				"atomic": true,
+				"records": [{
+					"_id": "invoice/1",
+					"amount": 100
+				}],
+				"deletes": ["draft/notexistid"]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 115,
+					"info": {
+						"draft/notexistid": {
+							"code": 110,
+							"message": "record not found",
+							"name": "ResourceNotFound"
+						}
+					},
+					"message": "Atomic Operation rolled back due to one or more errors",
+					"name": "AtomicOperationFailure"
+				}
+			}`)
+			So(db.DidBegin, ShouldBeTrue)
+			So(db.DidRollback, ShouldBeTrue)
+			So(db.DidCommit, ShouldBeFalse)
+		})
+
+		Convey("commits atomic batch", func() {
+			resp := r.POST(`{
+				"atomic": true,
+				"records": [{
+					"_id": "invoice/1",
+					"amount": 100
+				}],
+				"deletes": ["draft/1"]
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(db.DidCommit, ShouldBeTrue)
+			So(mapDB.RecordMap, ShouldNotContainKey, "draft/1")
+		})
+
+		Convey("rejects record both saved and deleted", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "draft/1",
+					"content": "hello"
+				}],
+				"deletes": ["draft/1"]
+			}`)
+			So(resp.Code, ShouldEqual, 400)
+			So(mapDB.RecordMap, ShouldContainKey, "draft/1")
+		})
+	})
+
+	Convey("RecordSaveHandler with merge", t, func() {
+		db := skydbtest.NewMapDB()
+		conn := skydbtest.NewMapConn()
+
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("note", "1"),
+			OwnerID: "user0",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("user0", skydb.WriteLevel),
+			},
+			Data: map[string]interface{}{
+				"content":   "hello",
+				"noteOrder": 1,
+			},
+		}), ShouldBeNil)
+
+		r := handlertest.NewSingleRouteRouter(&RecordSaveHandler{}, func(payload *router.Payload) {
+			payload.DBConn = conn
+			payload.Database = db
+			payload.AuthInfo = &skydb.AuthInfo{
+				ID: "user0",
+			}
+		})
+
+		Convey("merges supplied fields into existing record", func() {
+			resp := r.POST(`{
+				"merge": true,
+				"records": [{
+					"_id": "note/1",
+					"noteOrder": 2
+				}]
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+
+			record := db.RecordMap["note/1"]
+			So(record.Data, ShouldResemble, skydb.Data{
+				"content":   "hello",
+				"noteOrder": float64(2),
+			})
+		})
+
+		Convey("does not create record", func() {
+			resp := r.POST(`{
+				"merge": true,
+				"records": [{
+					"_id": "note/notexistid",
+					"noteOrder": 2
+				}]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_id": "note/notexistid",
+					"_type": "error",
+					"code": 110,
+					"message": "record not found",
+					"name": "ResourceNotFound"
+				}]
+			}`)
+			So(db.RecordMap, ShouldNotContainKey, "note/notexistid")
+		})
+
+		Convey("does not write supplied fields denied by field ACL", func() {
+			publicRole := skydb.FieldUserRole{skydb.PublicFieldUserRoleType, ""}
+			conn.SetRecordFieldAccess(skydb.NewFieldACL(skydb.FieldACLEntryList{
+				{
+					RecordType:  "note",
+					RecordField: "content",
+					UserRole:    publicRole,
+					Writable:    false,
+					Readable:    true,
+				},
+			}))
+			mergeDB := &mergeRecordingDB{MapDB: db}
+			r := handlertest.NewSingleRouteRouter(&RecordSaveHandler{}, func(payload *router.Payload) {
+				payload.DBConn = conn
+				payload.Database = mergeDB
+				payload.AuthInfo = &skydb.AuthInfo{
+					ID: "user0",
+				}
+			})
+
+			resp := r.POST(`{
+				"merge": true,
+				"records": [{
+					"_id": "note/1",
+					"content": "bye",
+					"noteOrder": 2
+				}]
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(mergeDB.merged, ShouldHaveLength, 1)
+			So(mergeDB.merged[0].Data, ShouldNotContainKey, "content")
+			So(mergeDB.merged[0].Data["noteOrder"], ShouldEqual, float64(2))
+		})
+	})
+
+	Convey("RecordSaveHandler with increments", t, func() {
+		db := skydbtest.NewMapDB()
+		conn := skydbtest.NewMapConn()
+
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("note", "1"),
+			OwnerID: "user0",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("user0", skydb.WriteLevel),
+			},
+			Data: map[string]interface{}{
+				"likes": float64(1),
+			},
+		}), ShouldBeNil)
+
+		r := handlertest.NewSingleRouteRouter(&RecordSaveHandler{}, func(payload *router.Payload) {
+			payload.DBConn = conn
+			payload.Database = db
+			payload.AuthInfo = &skydb.AuthInfo{
+				ID: "user0",
+			}
+		})
+
+		Convey("increments field of existing record", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"$inc": {"likes": 2}
+				}]
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(db.RecordMap["note/1"].Data["likes"], ShouldEqual, float64(3))
+			So(resp.Body.String(), ShouldContainSubstring, `"likes":3`)
+		})
+
+		Convey("returns stored number if database keeps the increment", func() {
+			r := handlertest.NewSingleRouteRouter(&RecordSaveHandler{}, func(payload *router.Payload) {
+				payload.DBConn = conn
+				payload.Database = &incrementKeepingDB{MapDB: db}
+				payload.AuthInfo = &skydb.AuthInfo{
+					ID: "user0",
+				}
+			})
+
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"$inc": {"likes": 2}
+				}]
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(resp.Body.String(), ShouldContainSubstring, `"likes":3`)
+			So(resp.Body.String(), ShouldNotContainSubstring, `$inc`)
+		})
+
+		Convey("rejects non-numeric increment", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"$inc": {"likes": "1"}
+				}]
+			}`)
+			So(resp.Body.String(), ShouldContainSubstring, `increment of key \"likes\" is not a number`)
+		})
+
+		Convey("rejects key both set and incremented", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"likes": 5,
+					"$inc": {"likes": 1}
+				}]
+			}`)
+			So(resp.Body.String(), ShouldContainSubstring, `cannot both set and increment key \"likes\"`)
+		})
+	})
+
+	Convey("RecordSaveHandler with patches", t, func() {
+		db := skydbtest.NewMapDB()
+		conn := skydbtest.NewMapConn()
+
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("note", "1"),
+			OwnerID: "user0",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("user0", skydb.WriteLevel),
+			},
+			Data: map[string]interface{}{
+				"content": "hello world",
+				"settings": map[string]interface{}{
+					"theme": "light",
+					"tags":  []interface{}{"a"},
+				},
+			},
+		}), ShouldBeNil)
+
+		r := handlertest.NewSingleRouteRouter(&RecordSaveHandler{}, func(payload *router.Payload) {
+			payload.DBConn = conn
+			payload.Database = db
+			payload.AuthInfo = &skydb.AuthInfo{
+				ID: "user0",
+			}
+		})
+
+		Convey("merges merge patch into field of existing record", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"$patch": {"settings": {"theme": "dark", "tags": null}}
+				}]
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(db.RecordMap["note/1"].Data["settings"], ShouldResemble, map[string]interface{}{
+				"theme": "dark",
+			})
+		})
+
+		Convey("applies json patch and splices", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"$patch": {
+						"settings": [{"op": "add", "path": "/tags/-", "value": "b"}],
+						"content": [{"op": "splice", "offset": 6, "delete": 5, "insert": "there"}]
+					}
+				}]
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(db.RecordMap["note/1"].Data["settings"], ShouldResemble, map[string]interface{}{
+				"theme": "light",
+				"tags":  []interface{}{"a", "b"},
+			})
+			So(db.RecordMap["note/1"].Data["content"], ShouldEqual, "hello there")
+		})
+
+		Convey("rejects unknown json patch operation", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"$patch": {"settings": [{"op": "append", "path": "/tags"}]}
+				}]
+			}`)
+			So(resp.Body.String(), ShouldContainSubstring, `unknown json patch operation \"append\"`)
+		})
+
+		Convey("rejects key both set and patched", func() {
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/1",
+					"content": "hi",
+					"$patch": {"content": [{"op": "splice", "offset": 0, "insert": "oh, "}]}
+				}]
+			}`)
+			So(resp.Body.String(), ShouldContainSubstring, `cannot both set and patch key \"content\"`)
+		})
+	})
+
+	Convey("RecordSaveHandler with revision policy", t, func() {
+		db := skydbtest.NewMapDB()
+		conn := skydbtest.NewMapConn()
+
+		db.Save(&skydb.Record{
+			ID:        skydb.NewRecordID("note", "id"),
+			OwnerID:   "user0",
+			UpdatedAt: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+			UpdaterID: "user1",
+			Revision:  3,
+			Data: map[string]interface{}{
+				"content": "old",
+			},
+		})
+
+		handler := &RecordSaveHandler{
+			RevisionPolicy: "reject",
+		}
+		r := handlertest.NewSingleRouteRouter(handler, func(payload *router.Payload) {
+			payload.DBConn = conn
+			payload.Database = db
+			payload.AuthInfo = &skydb.AuthInfo{
+				ID: "user0",
+			}
+		})
+
+		Convey("saves record with current revision", func() {
 			resp := r.POST(`{
 				"records": [{
-					"_id": "note/readonly",
-					"content": "hello"
+					"_id": "note/id",
+					"_rev": 3,
+					"content": "new"
 				}]
 			}`)
 			So(resp.Body.Bytes(), ShouldEqualJSON, `{
 				"result": [{
-					"_id": "note/readonly",
-					"_type": "error",
-					"code": 102,
-					"message": "no permission to perform operation",
-					"name": "PermissionDenied"
+					"_id": "note/id",
+					"_type": "record",
+					"_access": null,
+					"_rev": 3,
+					"content": "new",
+					"_updated_by": "user0",
+					"_ownerID": "user0"
 				}]
 			}`)
 		})
-		Convey("REGRESSION #119: Returns record invalid error if _id is missing or malformated", func() {
+
+		Convey("rejects record with stale revision", func() {
 			resp := r.POST(`{
 				"records": [{
-				}, {
-					"_id": "invalidkey"
+					"_id": "note/id",
+					"_rev": 2,
+					"content": "new"
 				}]
 			}`)
 			So(resp.Body.Bytes(), ShouldEqualJSON, `{
 				"result": [{
+					"_id": "note/id",
 					"_type": "error",
-					"name": "InvalidArgument",
-					"code": 108,
-					"message": "missing required fields",
-					"info": {"arguments":["id"]}
-				},{
-					"_type": "error",
-					"name": "InvalidArgument",
-					"code": 108,
-					"message": "record: \"_id\" should be of format '{type}/{id}', got \"invalidkey\"",
-					"info": {"arguments":["id"]}
-			}]}`)
+					"code": 128,
+					"name": "RecordConflict",
+					"message": "record has been modified since the supplied revision",
+					"info": {
+						"base_revision": 2,
+						"current_revision": 3,
+						"updated_at": "2006-01-02T15:04:05Z",
+						"updated_by": "user1"
+					}
+				}]
+			}`)
+
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "id"), &record), ShouldBeNil)
+			So(record.Data["content"], ShouldEqual, "old")
 		})
 
-		Convey("REGRESSION #140: Save record correctly when record._access is null", func() {
+		Convey("saves record without revision", func() {
 			resp := r.POST(`{
 				"records": [{
-					"_id": "type/id",
-					"_access": null
+					"_id": "note/id",
+					"content": "new"
 				}]
 			}`)
-
 			So(resp.Body.Bytes(), ShouldEqualJSON, `{
 				"result": [{
+					"_id": "note/id",
 					"_type": "record",
-					"_id": "type/id",
 					"_access": null,
-					"_created_by":"user0",
-					"_updated_by":"user0",
+					"_rev": 3,
+					"content": "new",
+					"_updated_by": "user0",
 					"_ownerID": "user0"
 				}]
 			}`)
 		})
 
-		Convey("REGRESSION #333: Save record with empty key be ignored as start with _", func() {
+		Convey("rejects record without revision if required", func() {
+			handler.RevisionPolicy = "require"
 			resp := r.POST(`{
 				"records": [{
-					"_id": "type/id",
-					"": ""
+					"_id": "note/id",
+					"content": "new"
+				}]
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_id": "note/id",
+					"_type": "error",
+					"code": 108,
+					"name": "InvalidArgument",
+					"message": "_rev is required to save an existing record",
+					"info": {"arguments": ["_rev"]}
 				}]
 			}`)
+		})
 
+		Convey("ignores stale revision by default", func() {
+			handler.RevisionPolicy = ""
+			resp := r.POST(`{
+				"records": [{
+					"_id": "note/id",
+					"_rev": 2,
+					"content": "new"
+				}]
+			}`)
 			So(resp.Body.Bytes(), ShouldEqualJSON, `{
 				"result": [{
+					"_id": "note/id",
 					"_type": "record",
-					"_id": "type/id",
 					"_access": null,
-					"_created_by":"user0",
-					"_updated_by":"user0",
+					"_rev": 3,
+					"content": "new",
+					"_updated_by": "user0",
 					"_ownerID": "user0"
 				}]
 			}`)
@@ -747,6 +1355,10 @@ func (db bogusFieldDatabaseConnection) GetRecordFieldAccess() (skydb.FieldACL, e
 	return skydb.FieldACL{}, nil
 }
 
+func (db bogusFieldDatabaseConnection) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	return nil, nil
+}
+
 func (db bogusFieldDatabaseConnection) EnsureAuthRecordKeysValid(authRecordKeys [][]string) error {
 	return nil
 }
@@ -965,6 +1577,10 @@ func (db *queryResultsDatabase) Query(query *skydb.Query) (*skydb.Rows, error) {
 	return skydb.NewRows(skydb.NewMemoryRows(db.records)), nil
 }
 
+func (db *queryResultsDatabase) QueryStream(query *skydb.Query) (*skydb.Rows, error) {
+	return skydb.NewRows(skydb.NewMemoryRows(db.records)), nil
+}
+
 func (db *queryResultsDatabase) GetSchema(recordType string) (skydb.RecordSchema, error) {
 	return db.typemap[recordType], nil
 }
@@ -1014,6 +1630,96 @@ func TestRecordQueryResults(t *testing.T) {
 			}`)
 			So(resp.Code, ShouldEqual, 200)
 		})
+
+		Convey("returns pagination metadata of full page", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"limit": 3,
+				"offset": 3,
+				"meta": true
+			}`)
+
+			So(resp.Code, ShouldEqual, 200)
+			So(resp.Body.String(), ShouldContainSubstring, `"_meta":{"page":{"offset":3,"limit":3,"returned":3,"has_next":true,"has_previous":true,"next_offset":6,"previous_offset":0}}`)
+		})
+
+		Convey("returns pagination metadata with count", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"limit": 3,
+				"meta": {"count": "exact"}
+			}`)
+
+			So(resp.Code, ShouldEqual, 200)
+			So(resp.Body.String(), ShouldContainSubstring, `"_meta":{"count":3,"page":{"offset":0,"limit":3,"returned":3,"has_next":false,"has_previous":false}}`)
+		})
+
+		Convey("returns pagination metadata with estimated count", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"meta": {"count": "estimated"}
+			}`)
+
+			So(resp.Code, ShouldEqual, 200)
+			So(resp.Body.String(), ShouldContainSubstring, `"count_estimated":true`)
+		})
+
+		Convey("returns count only", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"limit": 1,
+				"count_only": true
+			}`)
+
+			So(resp.Body.String(), ShouldEqualJSON, `{
+				"result": [],
+				"info": {"count": 3}
+			}`)
+			So(resp.Code, ShouldEqual, 200)
+		})
+
+		Convey("streams records as newline-delimited JSON", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"stream": true
+			}`)
+
+			So(resp.Code, ShouldEqual, 200)
+			So(resp.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+
+			lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+			So(lines, ShouldHaveLength, 3)
+			So(lines[0], ShouldEqualJSON, `{"_type": "record", "_id": "note/1", "_access": null}`)
+			So(lines[1], ShouldEqualJSON, `{"_type": "record", "_id": "note/0", "_access": null}`)
+			So(lines[2], ShouldEqualJSON, `{"_type": "record", "_id": "note/2", "_access": null}`)
+		})
+
+		Convey("rejects stream with pagination metadata", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"stream": true,
+				"meta": true
+			}`)
+
+			So(resp.Body.String(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "meta cannot be used with stream",
+					"info": {"arguments": ["meta"]}
+				}
+			}`)
+			So(resp.Code, ShouldEqual, 400)
+		})
+
+		Convey("rejects invalid count mode of metadata", func() {
+			resp := r.POST(`{
+				"record_type": "note",
+				"meta": {"count": "approximate"}
+			}`)
+
+			So(resp.Code, ShouldEqual, 400)
+		})
 	})
 }
 
@@ -1065,29 +1771,257 @@ func TestRecordQuery(t *testing.T) {
 			})
 		})
 
-		Convey("Queries records with type and master key", func() {
-			authInfo := skydb.AuthInfo{
-				ID: "user0",
-			}
+		Convey("Queries records with type and master key", func() {
+			authInfo := skydb.AuthInfo{
+				ID: "user0",
+			}
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type": "note",
+				},
+				DBConn:    conn,
+				Database:  db,
+				AuthInfo:  &authInfo,
+				AccessKey: router.MasterAccessKey,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldBeNil)
+			So(db.lastquery, ShouldResemble, &skydb.Query{
+				Type:                "note",
+				ViewAsUser:          &authInfo,
+				BypassAccessControl: true,
+			})
+		})
+
+		Convey("Queries records with explain and master key", func() {
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type": "note",
+					"explain":     true,
+				},
+				DBConn:    conn,
+				Database:  db,
+				AccessKey: router.MasterAccessKey,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldBeNil)
+			So(db.lastquery, ShouldResemble, &skydb.Query{
+				Type:                "note",
+				Explain:             true,
+				BypassAccessControl: true,
+			})
+		})
+
+		Convey("Rejects explain without master key", func() {
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type": "note",
+					"explain":     true,
+				},
+				DBConn:   conn,
+				Database: db,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldNotBeNil)
+			So(response.Err.Code(), ShouldEqual, skyerr.PermissionDenied)
+		})
+
+		Convey("Queries soft-deleted records with master key", func() {
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type":     "note",
+					"include_deleted": true,
+				},
+				DBConn:    conn,
+				Database:  db,
+				AccessKey: router.MasterAccessKey,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldBeNil)
+			So(db.lastquery, ShouldResemble, &skydb.Query{
+				Type:                "note",
+				IncludeDeleted:      true,
+				BypassAccessControl: true,
+			})
+		})
+
+		Convey("Rejects include_deleted without master key", func() {
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type":     "note",
+					"include_deleted": true,
+				},
+				DBConn:   conn,
+				Database: db,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldNotBeNil)
+			So(response.Err.Code(), ShouldEqual, skyerr.PermissionDenied)
+		})
+
+		Convey("Queries records as of a time with master key", func() {
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type": "note",
+					"as_of":       "2017-01-02T15:04:05+08:00",
+				},
+				DBConn:    conn,
+				Database:  db,
+				AccessKey: router.MasterAccessKey,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldBeNil)
+			asOf := time.Date(2017, 1, 2, 7, 4, 5, 0, time.UTC)
+			So(db.lastquery, ShouldResemble, &skydb.Query{
+				Type:                "note",
+				AsOf:                &asOf,
+				BypassAccessControl: true,
+			})
+		})
+
+		Convey("Rejects invalid as_of", func() {
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type": "note",
+					"as_of":       "yesterday",
+				},
+				DBConn:    conn,
+				Database:  db,
+				AccessKey: router.MasterAccessKey,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldNotBeNil)
+			So(response.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("Rejects as_of without master key", func() {
 			payload := router.Payload{
 				Data: map[string]interface{}{
 					"record_type": "note",
+					"as_of":       "2017-01-02T15:04:05Z",
 				},
-				DBConn:    conn,
-				Database:  db,
-				AuthInfo:  &authInfo,
-				AccessKey: router.MasterAccessKey,
+				DBConn:   conn,
+				Database: db,
 			}
 			response := router.Response{}
 
 			handler := &RecordQueryHandler{}
 			handler.Handle(&payload, &response)
 
-			So(response.Err, ShouldBeNil)
-			So(db.lastquery, ShouldResemble, &skydb.Query{
-				Type:                "note",
-				ViewAsUser:          &authInfo,
-				BypassAccessControl: true,
+			So(response.Err, ShouldNotBeNil)
+			So(response.Err.Code(), ShouldEqual, skyerr.PermissionDenied)
+		})
+
+		Convey("with query limits", func() {
+			handler := &RecordQueryHandler{
+				QueryLimits: &querylimit.Limits{
+					MaxLimit:          100,
+					MaxPredicateDepth: 1,
+				},
+			}
+
+			Convey("limits records returned", func() {
+				payload := router.Payload{
+					Data: map[string]interface{}{
+						"record_type": "note",
+					},
+					DBConn:   conn,
+					Database: db,
+				}
+				response := router.Response{}
+				handler.Handle(&payload, &response)
+
+				So(response.Err, ShouldBeNil)
+				So(*db.lastquery.Limit, ShouldEqual, 100)
+			})
+
+			Convey("does not limit records returned with master key", func() {
+				payload := router.Payload{
+					Data: map[string]interface{}{
+						"record_type": "note",
+						"limit":       float64(1000),
+					},
+					DBConn:    conn,
+					Database:  db,
+					AccessKey: router.MasterAccessKey,
+				}
+				response := router.Response{}
+				handler.Handle(&payload, &response)
+
+				So(response.Err, ShouldBeNil)
+				So(*db.lastquery.Limit, ShouldEqual, 1000)
+			})
+
+			Convey("rejects limit more than maximum", func() {
+				payload := router.Payload{
+					Data: map[string]interface{}{
+						"record_type": "note",
+						"limit":       float64(1000),
+					},
+					DBConn:   conn,
+					Database: db,
+				}
+				response := router.Response{}
+				handler.Handle(&payload, &response)
+
+				So(response.Err, ShouldNotBeNil)
+				So(response.Err.Code(), ShouldEqual, skyerr.RecordQueryTooComplex)
+				So(db.lastquery, ShouldBeNil)
+			})
+
+			Convey("rejects predicate nested too deep", func() {
+				payload := router.Payload{
+					Data: map[string]interface{}{
+						"record_type": "note",
+						"predicate": []interface{}{
+							"not",
+							[]interface{}{
+								"eq",
+								map[string]interface{}{
+									"$type": "keypath",
+									"$val":  "title",
+								},
+								"hello",
+							},
+						},
+					},
+					DBConn:    conn,
+					Database:  db,
+					AccessKey: router.MasterAccessKey,
+				}
+				response := router.Response{}
+				handler.Handle(&payload, &response)
+
+				So(response.Err, ShouldNotBeNil)
+				So(response.Err.Code(), ShouldEqual, skyerr.RecordQueryTooComplex)
 			})
 		})
 
@@ -1438,6 +2372,48 @@ func TestRecordQuery(t *testing.T) {
 			So(db.lastquery.Offset, ShouldEqual, 400)
 		})
 
+		Convey("Queries records with cursor", func() {
+			cursor := skydb.Cursor{
+				Values: []interface{}{"Hello"},
+				Key:    "note1",
+			}
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type": "note",
+					"page_size":   float64(20),
+					"after":       cursor.Encode(),
+				},
+				DBConn:   conn,
+				Database: db,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldBeNil)
+			So(db.lastquery.PageSize, ShouldEqual, 20)
+			So(db.lastquery.After, ShouldResemble, &cursor)
+		})
+
+		Convey("Returns error on invalid cursor", func() {
+			payload := router.Payload{
+				Data: map[string]interface{}{
+					"record_type": "note",
+					"after":       "not a cursor",
+				},
+				DBConn:   conn,
+				Database: db,
+			}
+			response := router.Response{}
+
+			handler := &RecordQueryHandler{}
+			handler.Handle(&payload, &response)
+
+			So(response.Err, ShouldNotBeNil)
+			So(response.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
 		Convey("Queries records with count", func() {
 			payload := router.Payload{
 				Data: map[string]interface{}{
@@ -1617,6 +2593,67 @@ func TestRecordQuery(t *testing.T) {
 				So(response.Err, ShouldBeNil)
 			})
 
+			Convey("should allow discoverable field with equality combined with or", func() {
+				payload := router.Payload{
+					Data: map[string]interface{}{
+						"record_type": "note",
+						"predicate": []interface{}{
+							"or",
+							[]interface{}{
+								"in",
+								map[string]interface{}{
+									"$type": "keypath",
+									"$val":  "title",
+								},
+								[]interface{}{"Tale of Two Cities", "Great Expectations"},
+							},
+							[]interface{}{
+								"eq",
+								map[string]interface{}{
+									"$type": "keypath",
+									"$val":  "category",
+								},
+								"novel",
+							},
+						},
+					},
+					DBConn:   conn,
+					Database: db,
+				}
+				response := router.Response{}
+
+				handler := &RecordQueryHandler{}
+				handler.Handle(&payload, &response)
+				So(response.Err, ShouldBeNil)
+			})
+
+			Convey("should block discoverable field negated", func() {
+				payload := router.Payload{
+					Data: map[string]interface{}{
+						"record_type": "note",
+						"predicate": []interface{}{
+							"not",
+							[]interface{}{
+								"eq",
+								map[string]interface{}{
+									"$type": "keypath",
+									"$val":  "title",
+								},
+								"Tale of Two Cities",
+							},
+						},
+					},
+					DBConn:   conn,
+					Database: db,
+				}
+				response := router.Response{}
+
+				handler := &RecordQueryHandler{}
+				handler.Handle(&payload, &response)
+				So(response.Err, ShouldNotBeNil)
+				So(response.Err.Code(), ShouldEqual, skyerr.RecordQueryDenied)
+			})
+
 			Convey("should block non-comparable field in sort", func() {
 				payload := router.Payload{
 					Data: map[string]interface{}{
@@ -1713,31 +2750,30 @@ func TestRecordFetchHandler(t *testing.T) {
 	}()
 
 	Convey("RecordFetchHandler with Field ACL", t, func() {
-		db := skydbtest.NewMapDB()
-		db.RecordSchemaMap = skydbtest.RecordSchemaMap{
-			"note": {
-				"content":  skydb.FieldType{Type: skydb.TypeString},
-				"favorite": skydb.FieldType{Type: skydb.TypeBoolean},
-				"category": skydb.FieldType{Type: skydb.TypeString},
-			},
-		}
-		conn := skydbtest.NewMapConn()
+		conn, db := newTestDB(t)
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content":  skydb.FieldType{Type: skydb.TypeString},
+			"favorite": skydb.FieldType{Type: skydb.TypeBoolean},
+			"category": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
 		publicRole := skydb.FieldUserRole{skydb.PublicFieldUserRoleType, ""}
 
-		db.Save(&skydb.Record{
+		So(db.Save(&skydb.Record{
 			ID:        skydb.NewRecordID("note", "note0"),
 			OwnerID:   "user0",
 			CreatorID: "user0",
 			CreatedAt: timeNow(),
 			UpdaterID: "user0",
 			UpdatedAt: timeNow(),
+			Revision:  1,
 			Data: map[string]interface{}{
 				"content":  "Hello World!",
 				"category": "interesting",
 			},
-		})
+		}), ShouldBeNil)
 
-		conn.SetRecordFieldAccess(skydb.NewFieldACL(skydb.FieldACLEntryList{
+		err = conn.SetRecordFieldAccess(skydb.NewFieldACL(skydb.FieldACLEntryList{
 			{
 				RecordType:  "*",
 				RecordField: "*",
@@ -1753,6 +2789,7 @@ func TestRecordFetchHandler(t *testing.T) {
 				Readable:    false,
 			},
 		}))
+		So(err, ShouldBeNil)
 
 		r := handlertest.NewSingleRouteRouter(&RecordFetchHandler{}, func(payload *router.Payload) {
 			payload.DBConn = conn
@@ -1777,7 +2814,8 @@ func TestRecordFetchHandler(t *testing.T) {
 					"content": "Hello World!",
 					"_created_by":"user0",
 					"_updated_by":"user0",
-					"_ownerID": "user0"
+					"_ownerID": "user0",
+					"_rev": 1
 				}]
 			}`)
 		})
@@ -1796,7 +2834,8 @@ func TestRecordFetchHandler(t *testing.T) {
 					"category": "interesting",
 					"_created_by":"user0",
 					"_updated_by":"user0",
-					"_ownerID": "user0"
+					"_ownerID": "user0",
+					"_rev": 1
 				}]
 			}`)
 		})
@@ -2178,6 +3217,72 @@ func (db *referencedRecordDatabase) GetSchema(recordType string) (skydb.RecordSc
 	return typemap[recordType], nil
 }
 
+// includingRecordDatabase includes referenced records in the query result,
+// so that referenced records are not fetched by GetByIDs.
+type includingRecordDatabase struct {
+	*referencedRecordDatabase
+	lastquery *skydb.Query
+}
+
+func (db *includingRecordDatabase) GetByIDs(ids []skydb.RecordID) (*skydb.Rows, error) {
+	for _, id := range ids {
+		if id.Type == "category" {
+			panic("included record should not be fetched again")
+		}
+	}
+	return db.referencedRecordDatabase.GetByIDs(ids)
+}
+
+func (db *includingRecordDatabase) Query(query *skydb.Query) (*skydb.Rows, error) {
+	db.lastquery = query
+	included := map[string]map[string]*skydb.Record{}
+	for _, field := range query.Includes {
+		if field == "category" {
+			included[field] = map[string]*skydb.Record{
+				"important": &db.category,
+			}
+		}
+	}
+	return skydb.NewRows(&includedRowsIter{
+		skydb.NewMemoryRows([]skydb.Record{db.note}),
+		included,
+	}), nil
+}
+
+type includedRowsIter struct {
+	*skydb.MemoryRows
+	included map[string]map[string]*skydb.Record
+}
+
+func (rs *includedRowsIter) IncludedRecords() map[string]map[string]*skydb.Record {
+	return rs.included
+}
+
+type relatedRecordDatabase struct {
+	*referencedRecordDatabase
+	lastRelated *skydb.RelatedQuery
+	lastKeys    []string
+}
+
+func (db *relatedRecordDatabase) QueryRelated(related *skydb.RelatedQuery, keys []string) (map[string]*skydb.RelatedPage, error) {
+	db.lastRelated = related
+	db.lastKeys = keys
+	return map[string]*skydb.RelatedPage{
+		"note1": &skydb.RelatedPage{
+			Records: []skydb.Record{
+				{
+					ID:      skydb.NewRecordID("comment", "comment1"),
+					OwnerID: "ownerID",
+					Data: map[string]interface{}{
+						"note": skydb.NewReference("note", "note1"),
+					},
+				},
+			},
+			NextCursor: &skydb.Cursor{Key: "comment1"},
+		},
+	}, nil
+}
+
 func TestRecordQueryWithEagerLoad(t *testing.T) {
 	Convey("Given a referenced record in DB", t, func() {
 		db := &referencedRecordDatabase{
@@ -2264,6 +3369,81 @@ func TestRecordQueryWithEagerLoad(t *testing.T) {
 			}`)
 		})
 
+		Convey("query record with referenced record included by database", func() {
+			includingDB := &includingRecordDatabase{referencedRecordDatabase: db}
+			resp := handlertest.NewSingleRouteRouter(&RecordQueryHandler{}, func(payload *router.Payload) {
+				payload.Database = includingDB
+				payload.DBConn = conn
+			}).POST(`{
+				"record_type": "note",
+				"include": {
+					"category": {"$type": "keypath", "$val": "category"},
+					"user": {"$type": "keypath", "$val": "_owner"}
+				}
+			}`)
+
+			So(includingDB.lastquery.Includes, ShouldResemble, []string{"category"})
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_id": "note/note1",
+					"_type": "record",
+					"_access": null,
+					"_ownerID": "ownerID",
+					"category": {"$id":"category/important","$type":"ref"},
+					"city": {"$id":"city/beautiful","$type":"ref"},
+					"_transient": {
+						"category": {"_access":null,"_id":"category/important","_type":"record","_ownerID":"ownerID", "title": "This is important."},
+						"user": {"_access":null,"_id":"user/ownerID","_type":"record","_ownerID":"ownerID", "name": "Owner"}
+					}
+				}]
+			}`)
+		})
+
+		Convey("query record with related records included", func() {
+			relatedDB := &relatedRecordDatabase{referencedRecordDatabase: db}
+			resp := handlertest.NewSingleRouteRouter(&RecordQueryHandler{}, func(payload *router.Payload) {
+				payload.Database = relatedDB
+				payload.DBConn = conn
+			}).POST(`{
+				"record_type": "note",
+				"include": {
+					"comments": {
+						"$type": "related",
+						"record_type": "comment",
+						"key": "note",
+						"page_size": 1
+					}
+				}
+			}`)
+
+			So(relatedDB.lastRelated.Type, ShouldEqual, "comment")
+			So(relatedDB.lastRelated.Field, ShouldEqual, "note")
+			So(relatedDB.lastRelated.PageSize, ShouldEqual, 1)
+			So(relatedDB.lastKeys, ShouldResemble, []string{"note1"})
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": [{
+					"_id": "note/note1",
+					"_type": "record",
+					"_access": null,
+					"_ownerID": "ownerID",
+					"category": {"$id":"category/important","$type":"ref"},
+					"city": {"$id":"city/beautiful","$type":"ref"},
+					"_transient": {
+						"comments": {
+							"records": [{
+								"_id": "comment/comment1",
+								"_type": "record",
+								"_access": null,
+								"_ownerID": "ownerID",
+								"note": {"$id":"note/note1","$type":"ref"}
+							}],
+							"next_cursor": "`+(skydb.Cursor{Key: "comment1"}).Encode()+`"
+						}
+					}
+				}]
+			}`)
+		})
+
 		Convey("query record with eager load on user", func() {
 			resp := handlertest.NewSingleRouteRouter(&RecordQueryHandler{}, injectDBFunc).POST(`{
 				"record_type": "note",
@@ -2947,3 +4127,26 @@ func TestDeriveDeltaRecord(t *testing.T) {
 		})
 	})
 }
+
+// mergeRecordingDB records the records merged into the database.
+type mergeRecordingDB struct {
+	*skydbtest.MapDB
+	merged []*skydb.Record
+}
+
+func (db *mergeRecordingDB) Merge(record *skydb.Record) error {
+	merged := record.Copy()
+	db.merged = append(db.merged, &merged)
+	return db.MapDB.Merge(record)
+}
+
+// incrementKeepingDB saves a copy of the record, leaving the increments in
+// the saved record unresolved.
+type incrementKeepingDB struct {
+	*skydbtest.MapDB
+}
+
+func (db *incrementKeepingDB) Save(record *skydb.Record) error {
+	saved := record.Copy()
+	return db.MapDB.Save(&saved)
+}
@@ -0,0 +1,182 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/recordutil"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// recordImportBatchSize is the number of records inserted at a time by
+// RecordImportHandler.
+const recordImportBatchSize = 1000
+
+/*
+RecordImportHandler imports records into the public database in bulk.
+Master key is required.
+
+The request body is newline-delimited JSON, with a record per line in
+the format of record:save. The owner of a record is specified by
+"_ownerID", or is the user of the request if omitted.
+
+The records are inserted with COPY, which is much faster than saving them
+with record:save. However, existing records are not updated, and hooks
+are not executed. Either all or none of the records are imported.
+
+curl -X POST -H "X-Skygear-Api-Key: MASTER_KEY" \
+  -H "Content-Type: application/x-ndjson" \
+  --data-binary @- http://localhost:3000/record/import <<EOF
+{"_id": "note/1", "_ownerID": "user1", "content": "Hello"}
+{"_id": "note/2", "_ownerID": "user2", "content": "World"}
+EOF
+
+{
+    "result": {
+        "imported": 2
+    }
+}
+*/
+type RecordImportHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	InjectPublicDB   router.Processor `preprocessor:"inject_public_db"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	PluginReady      router.Processor `preprocessor:"plugin_ready"`
+	preprocessors    []router.Processor
+}
+
+func (h *RecordImportHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectPublicDB,
+		h.RequireMasterKey,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordImportHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordImportHandler) Handle(payload *router.Payload, response *router.Response) {
+	if payload.Req == nil || payload.Req.Body == nil {
+		response.Err = skyerr.NewError(skyerr.BadRequest, "missing records to import")
+		return
+	}
+
+	db := payload.Database
+	imported := 0
+	importRecords := func() error {
+		now := timeNow()
+		reader := bufio.NewReader(payload.Req.Body)
+		records := make([]*skydb.Record, 0, recordImportBatchSize)
+		for line := 1; ; line++ {
+			data, readErr := reader.ReadBytes('\n')
+			if readErr != nil && readErr != io.EOF {
+				return readErr
+			}
+
+			if len(bytes.TrimSpace(data)) > 0 {
+				record, err := decodeImportRecord(data, payload.AuthInfoID, now)
+				if err != nil {
+					return skyerr.NewErrorf(skyerr.InvalidArgument,
+						"invalid record at line %d: %v", line, err)
+				}
+				records = append(records, record)
+			}
+
+			if len(records) == recordImportBatchSize || (readErr == io.EOF && len(records) > 0) {
+				if _, err := recordutil.ExtendRecordSchema(db, records); err != nil {
+					return err
+				}
+				if err := db.SaveBulk(records); err != nil {
+					return err
+				}
+				imported += len(records)
+				records = make([]*skydb.Record, 0, recordImportBatchSize)
+			}
+
+			if readErr == io.EOF {
+				return nil
+			}
+		}
+	}
+
+	var err error
+	if txDB, ok := db.(skydb.Transactional); ok {
+		err = skydb.WithTransaction(txDB, importRecords)
+	} else {
+		err = importRecords()
+	}
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"imported": imported,
+	}
+}
+
+// decodeImportRecord decodes a line of the records to import. The record
+// is owned by ownerID unless "_ownerID" is specified.
+func decodeImportRecord(data []byte, ownerID string, now time.Time) (*skydb.Record, error) {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	if owner, ok := m["_ownerID"]; ok {
+		if ownerID, ok = owner.(string); !ok {
+			return nil, errors.New("_ownerID must be a string")
+		}
+	}
+
+	record := skydb.Record{}
+	if err := (*skyconv.JSONRecord)(&record).FromMap(m); err != nil {
+		return nil, err
+	}
+	if record.ID.Type == "" || record.ID.Key == "" {
+		return nil, errors.New("missing _id")
+	}
+	if strings.HasPrefix(record.ID.Type, "_") {
+		return nil, fmt.Errorf("cannot import into reserved record type %s", record.ID.Type)
+	}
+	if ownerID == "" {
+		return nil, errors.New("missing _ownerID")
+	}
+
+	record.OwnerID = ownerID
+	record.CreatorID = ownerID
+	record.UpdaterID = ownerID
+	record.CreatedAt = now
+	record.UpdatedAt = now
+	return &record, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+func TestRecordImportHandler(t *testing.T) {
+	Convey("RecordImportHandler", t, func() {
+		realTime := timeNow
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		db := skydbtest.NewMapDB()
+		g := handlertest.NewMockGateway("record/import", "/record/import", []string{"POST"}, &RecordImportHandler{}, func(p *router.Payload) {
+			p.DBConn = skydbtest.NewMapConn()
+			p.Database = db
+			p.AuthInfoID = "admin"
+		})
+
+		Convey("imports records", func() {
+			resp := g.Request("POST", `{"_id": "note/1", "_ownerID": "user1", "content": "Hello"}
+
+{"_id": "note/2", "content": "World"}
+`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{"result": {"imported": 2}}`)
+			So(db.RecordMap, ShouldHaveLength, 2)
+
+			note1 := db.RecordMap["note/1"]
+			So(note1.OwnerID, ShouldEqual, "user1")
+			So(note1.CreatedAt, ShouldResemble, now)
+			So(note1.Data["content"], ShouldEqual, "Hello")
+
+			note2 := db.RecordMap["note/2"]
+			So(note2.OwnerID, ShouldEqual, "admin")
+			So(note2.Data["content"], ShouldEqual, "World")
+
+			So(db.RecordSchemaMap["note"], ShouldResemble, skydb.RecordSchema{
+				"content": skydb.FieldType{Type: skydb.TypeString},
+			})
+		})
+
+		Convey("rejects invalid record", func() {
+			resp := g.Request("POST", `{"_id": "note/1", "content": "Hello"}
+{"content": "World"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "invalid record at line 2: missing _id"
+	}
+}`)
+			So(db.RecordMap, ShouldBeEmpty)
+		})
+
+		Convey("rejects existing record", func() {
+			db.RecordMap["note/1"] = skydb.Record{ID: skydb.NewRecordID("note", "1")}
+
+			resp := g.Request("POST", `{"_id": "note/1", "content": "Hello"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "Duplicated",
+		"code": 109,
+		"message": "violate unique constraint"
+	}
+}`)
+		})
+	})
+}
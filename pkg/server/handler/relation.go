@@ -144,40 +144,96 @@ func (h *RelationQueryHandler) Handle(rpayload *router.Payload, response *router
 		return
 	}
 
-	result := rpayload.DBConn.QueryRelation(
-		rpayload.AuthInfoID, payload.Name, payload.Direction, skydb.QueryConfig{
-			Limit:  payload.Limit,
-			Offset: payload.Offset,
-		})
-	resultList := make([]interface{}, 0, len(result))
-	for _, authinfo := range result {
-		user, err := fetchUser(
-			rpayload.Database,
-			rpayload.DBConn,
-			h.AssetStore,
-			*rpayload.AuthInfo,
-			authinfo.ID,
-			rpayload.HasMasterKey(),
-		)
-		if err != nil {
-			response.Err = skyerr.MakeError(err)
-			return
-		}
+	direction := payload.Direction
+	if direction == "" {
+		direction = "mutual"
+	}
+
+	// Users are queried with the user relation predicate, so that the
+	// total count is returned together with the page of users.
+	query := skydb.Query{
+		Type: rpayload.Database.UserRecordType(),
+		Predicate: skydb.Predicate{
+			Operator: skydb.Functional,
+			Children: []interface{}{
+				skydb.Expression{
+					Type: skydb.Function,
+					Value: skydb.UserRelationFunc{
+						KeyPath:           "_id",
+						RelationName:      payload.Name,
+						RelationDirection: direction,
+						User:              rpayload.AuthInfoID,
+					},
+				},
+			},
+		},
+		Sorts: []skydb.Sort{
+			{
+				Expression: skydb.Expression{
+					Type:  skydb.KeyPath,
+					Value: "_id",
+				},
+				Order: skydb.Ascending,
+			},
+		},
+		GetCount:            true,
+		Offset:              payload.Offset,
+		BypassAccessControl: true,
+	}
+	if payload.Limit != 0 {
+		limit := payload.Limit
+		query.Limit = &limit
+	}
+
+	rows, err := rpayload.Database.Query(&query)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	defer rows.Close()
+
+	filter, err := recordutil.NewRecordResultFilter(
+		rpayload.DBConn,
+		h.AssetStore,
+		rpayload.AuthInfo,
+		rpayload.HasMasterKey(),
+	)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
 
+	resultList := []interface{}{}
+	for rows.Scan() {
+		user := rows.Record()
 		resultList = append(resultList, struct {
 			ID   string      `json:"id"`
 			Type string      `json:"type"`
 			Data interface{} `json:"data"`
-		}{authinfo.ID, "user", &user})
+		}{user.ID.Key, "user", filter.JSONResult(&user)})
+	}
+	if err := rows.Err(); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
 	}
 	response.Result = resultList
-	count, countErr := rpayload.DBConn.QueryRelationCount(
-		rpayload.AuthInfoID, payload.Name, payload.Direction)
-	if countErr != nil {
-		log.WithFields(logrus.Fields{
-			"err": countErr,
-		}).Warnf("Relation Count Query fails")
-		count = 0
+
+	// The overall count is returned with each row of the page, so it
+	// is counted separately when the page is empty, for example when the
+	// offset is past the last user.
+	var count uint64
+	if overallCount := rows.OverallRecordCount(); overallCount != nil {
+		count = *overallCount
+	} else if len(resultList) == 0 {
+		var countErr error
+		count, countErr = rpayload.DBConn.QueryRelationCount(
+			rpayload.AuthInfoID, payload.Name, direction)
+		if countErr != nil {
+			log.WithFields(logrus.Fields{
+				"err": countErr,
+			}).Warnf("Relation Count Query fails")
+			count = 0
+		}
 	}
 	response.Info = struct {
 		Count uint64 `json:"count"`
@@ -251,6 +307,7 @@ type RelationAddHandler struct {
 	AssetStore    asset.Store      `inject:"AssetStore"`
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
@@ -261,6 +318,7 @@ func (h *RelationAddHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.PluginReady,
@@ -334,6 +392,7 @@ func (h *RelationAddHandler) Handle(rpayload *router.Payload, response *router.R
 type RelationRemoveHandler struct {
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
@@ -344,6 +403,7 @@ func (h *RelationRemoveHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.PluginReady,
@@ -15,8 +15,6 @@
 package handler
 
 import (
-	"sort"
-
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -30,12 +28,13 @@ import (
 )
 
 type testRelationConn struct {
-	AuthInfo     []skydb.AuthInfo
 	RelationName string
 	addedID      string
 	removeID     string
 	addErr       error
 	removeErr    error
+	count        uint64
+	countCalled  bool
 	skydb.Conn
 }
 
@@ -44,29 +43,6 @@ func (conn *testRelationConn) GetAuth(id string, authinfo *skydb.AuthInfo) error
 	return nil
 }
 
-type sortableAuthInfo []skydb.AuthInfo
-
-func (a sortableAuthInfo) Len() int           { return len(a) }
-func (a sortableAuthInfo) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a sortableAuthInfo) Less(i, j int) bool { return a[i].ID < a[j].ID }
-
-func (conn *testRelationConn) QueryRelation(user string, name string, direction string, config skydb.QueryConfig) []skydb.AuthInfo {
-	conn.RelationName = name
-	if conn.AuthInfo == nil {
-		return []skydb.AuthInfo{}
-	}
-
-	sort.Sort(sortableAuthInfo(conn.AuthInfo))
-	if config.Limit == 0 {
-		return conn.AuthInfo[config.Offset:]
-	}
-
-	if config.Offset+config.Limit-1 > uint64(len(conn.AuthInfo)) {
-		return conn.AuthInfo[config.Offset:]
-	}
-	return conn.AuthInfo[config.Offset : config.Offset+config.Limit]
-}
-
 func (conn *testRelationConn) AddRelation(user string, name string, targetUser string) error {
 	conn.RelationName = name
 	conn.addedID = targetUser
@@ -81,17 +57,29 @@ func (conn *testRelationConn) RemoveRelation(user string, name string, targetUse
 
 func (conn *testRelationConn) QueryRelationCount(user string, name string, direction string) (uint64, error) {
 	conn.RelationName = name
-	if conn.AuthInfo == nil {
-		return 0, nil
-	}
-	count := uint64(len(conn.AuthInfo))
-	return count, nil
+	conn.countCalled = true
+	return conn.count, nil
 }
 
 func (conn *testRelationConn) GetRecordFieldAccess() (skydb.FieldACL, error) {
 	return skydb.FieldACL{}, nil
 }
 
+func (conn *testRelationConn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	return nil, nil
+}
+
+// relationRows returns the overall count of the relation, which is
+// larger than the number of users in a page.
+type relationRows struct {
+	*skydb.MemoryRows
+	count uint64
+}
+
+func (rs *relationRows) OverallRecordCount() *uint64 {
+	return &rs.count
+}
+
 func TestRelationHandler(t *testing.T) {
 	Convey("RelationAddHandler", t, func() {
 		conn := testRelationConn{}
@@ -153,13 +141,41 @@ func TestRelationHandler(t *testing.T) {
 	Convey("RelationQueryHandler", t, func() {
 		conn := testRelationConn{}
 
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		db := mock_skydb.NewMockTxDatabase(ctrl)
+		db.EXPECT().UserRecordType().Return("user").AnyTimes()
+
+		r := handlertest.NewSingleRouteRouter(&RelationQueryHandler{}, func(p *router.Payload) {
+			p.DBConn = &conn
+			p.Database = db
+			p.AuthInfoID = "user-1"
+			p.AuthInfo = &skydb.AuthInfo{
+				ID: "user-1",
+			}
+		})
+
+		user101 := skydb.Record{
+			ID: skydb.NewRecordID("user", "101"),
+			Data: map[string]interface{}{
+				"username": "user101",
+				"email":    "user101@skygear.io",
+			},
+		}
+		user102 := skydb.Record{
+			ID: skydb.NewRecordID("user", "102"),
+			Data: map[string]interface{}{
+				"username": "user102",
+				"email":    "user102@skygear.io",
+			},
+		}
+
 		Convey("query outward relation", func() {
-			r := handlertest.NewSingleRouteRouter(&RelationQueryHandler{}, func(p *router.Payload) {
-				p.DBConn = &conn
-				p.AuthInfo = &skydb.AuthInfo{
-					ID: "user-1",
-				}
-			})
+			var query *skydb.Query
+			db.EXPECT().Query(gomock.Any()).
+				Do(func(q *skydb.Query) { query = q }).
+				Return(skydb.NewRows(&relationRows{skydb.NewMemoryRows([]skydb.Record{}), 0}), nil)
 
 			resp := r.POST(`{
     "name": "follow",
@@ -173,46 +189,32 @@ func TestRelationHandler(t *testing.T) {
         "count": 0
     }
 }`)
+			So(query.Type, ShouldEqual, "user")
+			So(query.GetCount, ShouldBeTrue)
+			So(query.Limit, ShouldBeNil)
+			So(query.Predicate, ShouldResemble, skydb.Predicate{
+				Operator: skydb.Functional,
+				Children: []interface{}{
+					skydb.Expression{
+						Type: skydb.Function,
+						Value: skydb.UserRelationFunc{
+							KeyPath:           "_id",
+							RelationName:      "_follow",
+							RelationDirection: "outward",
+							User:              "user-1",
+						},
+					},
+				},
+			})
 		})
 
 		Convey("query inward relation with count", func() {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
+			db.EXPECT().Query(gomock.Any()).
+				Return(skydb.NewRows(&relationRows{skydb.NewMemoryRows([]skydb.Record{user101}), 1}), nil)
 
-			db := mock_skydb.NewMockTxDatabase(ctrl)
-			db.EXPECT().
-				Get(gomock.Any(), gomock.Any()).
-				Do(func(recordID skydb.RecordID, record *skydb.Record) {
-					if recordID.Type != "user" || recordID.Key != "101" {
-						panic("Wrong RecordID")
-					}
-				}).
-				SetArg(1, skydb.Record{
-					ID: skydb.NewRecordID("user", "101"),
-					Data: map[string]interface{}{
-						"username": "user101",
-						"email":    "user101@skygear.io",
-					},
-				}).
-				Return(nil).
-				AnyTimes()
-
-			r := handlertest.NewSingleRouteRouter(&RelationQueryHandler{}, func(p *router.Payload) {
-				p.DBConn = &conn
-				p.Database = db
-				p.AuthInfo = &skydb.AuthInfo{
-					ID: "user-1",
-				}
-			})
-
-			users := []skydb.AuthInfo{}
-			users = append(users, skydb.AuthInfo{
-				ID: "101",
-			})
-			conn.AuthInfo = users
 			resp := r.POST(`{
     "name": "follow",
-    "direction": "outward"
+    "direction": "inward"
 }`)
 
 			So(resp.Code, ShouldEqual, 200)
@@ -234,134 +236,91 @@ func TestRelationHandler(t *testing.T) {
 }`)
 		})
 
-		Convey("query relation with _follow", func() {
-			r := handlertest.NewSingleRouteRouter(&RelationQueryHandler{}, func(p *router.Payload) {
-				p.DBConn = &conn
-				p.AuthInfo = &skydb.AuthInfo{
-					ID: "user-1",
-				}
-			})
+		Convey("query relation with _follow and default direction", func() {
+			var query *skydb.Query
+			db.EXPECT().Query(gomock.Any()).
+				Do(func(q *skydb.Query) { query = q }).
+				Return(skydb.NewRows(&relationRows{skydb.NewMemoryRows([]skydb.Record{}), 0}), nil)
 
 			resp := r.POST(`{
-    "name": "_follow",
-    "direction": "outward"
+    "name": "_follow"
 }`)
 
 			So(resp.Code, ShouldEqual, 200)
-			So(resp.Body.Bytes(), ShouldEqualJSON, `{
-    "result": [],
-    "info": {
-        "count": 0
-    }
-}`)
+			fn := query.Predicate.Children[0].(skydb.Expression).Value.(skydb.UserRelationFunc)
+			So(fn.RelationName, ShouldEqual, "_follow")
+			So(fn.RelationDirection, ShouldEqual, "mutual")
 		})
 
 		Convey("query relation with pagination", func() {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
-
-			db := mock_skydb.NewMockTxDatabase(ctrl)
-			firstUser := db.EXPECT().
-				Get(gomock.Any(), gomock.Any()).
-				Do(func(recordID skydb.RecordID, record *skydb.Record) {
-					if recordID.Type != "user" || recordID.Key != "101" {
-						panic("Wrong RecordID")
-					}
-				}).
-				SetArg(1, skydb.Record{
-					ID: skydb.NewRecordID("user", "101"),
-					Data: map[string]interface{}{
-						"username": "user101",
-						"email":    "user101@skygear.io",
-					},
-				}).
-				Return(nil).
-				Times(1)
+			var query *skydb.Query
+			db.EXPECT().Query(gomock.Any()).
+				Do(func(q *skydb.Query) { query = q }).
+				Return(skydb.NewRows(&relationRows{skydb.NewMemoryRows([]skydb.Record{user102}), 2}), nil)
 
-			r := handlertest.NewSingleRouteRouter(&RelationQueryHandler{}, func(p *router.Payload) {
-				p.DBConn = &conn
-				p.Database = db
-				p.AuthInfo = &skydb.AuthInfo{
-					ID: "user-1",
-				}
-			})
-
-			user1 := skydb.AuthInfo{
-				ID: "101",
-			}
-			user2 := skydb.AuthInfo{
-				ID: "102",
-			}
-
-			users := []skydb.AuthInfo{}
-			users = append(users, user1)
-			users = append(users, user2)
-			conn.AuthInfo = users
 			resp := r.POST(`{
     "name": "follow",
     "direction": "outward",
-	"limit": 1
+	"limit": 1,
+	"offset": 1
 }`)
 
 			So(resp.Code, ShouldEqual, 200)
 			So(resp.Body.Bytes(), ShouldEqualJSON, `{
     "result": [{
-        "id": "101",
+        "id": "102",
         "type": "user",
         "data":{
-            "_id": "user/101",
+            "_id": "user/102",
             "_type": "record",
             "_access": null,
-            "email": "user101@skygear.io",
-            "username": "user101"
+            "email": "user102@skygear.io",
+            "username": "user102"
         }
     }],
     "info": {
         "count": 2
     }
 }`)
+			So(*query.Limit, ShouldEqual, 1)
+			So(query.Offset, ShouldEqual, 1)
+		})
 
-			db.EXPECT().
-				Get(gomock.Any(), gomock.Any()).
-				Do(func(recordID skydb.RecordID, record *skydb.Record) {
-					if recordID.Type != "user" || recordID.Key != "102" {
-						panic("Wrong RecordID")
-					}
-				}).
-				SetArg(1, skydb.Record{
-					ID: skydb.NewRecordID("user", "102"),
-					Data: map[string]interface{}{
-						"username": "user102",
-						"email":    "user102@skygear.io",
-					},
-				}).
-				Return(nil).
-				After(firstUser)
+		Convey("query relation with offset past the last user", func() {
+			conn.count = 2
+			db.EXPECT().Query(gomock.Any()).
+				Return(skydb.NewRows(skydb.NewMemoryRows([]skydb.Record{})), nil)
 
-			resp = r.POST(`{
+			resp := r.POST(`{
     "name": "follow",
     "direction": "outward",
 	"limit": 1,
-	"offset": 1
+	"offset": 2
 }`)
 
 			So(resp.Code, ShouldEqual, 200)
 			So(resp.Body.Bytes(), ShouldEqualJSON, `{
-    "result": [{
-        "id": "102",
-        "type": "user",
-        "data":{
-            "_id": "user/102",
-            "_type": "record",
-            "_access": null,
-            "email": "user102@skygear.io",
-            "username": "user102"
-        }
-    }],
+    "result": [],
     "info": {
         "count": 2
     }
 }`)
+			So(conn.countCalled, ShouldBeTrue)
+			So(conn.RelationName, ShouldEqual, "_follow")
+		})
+
+		Convey("query relation without counting separately", func() {
+			db.EXPECT().Query(gomock.Any()).
+				Return(skydb.NewRows(&relationRows{skydb.NewMemoryRows([]skydb.Record{user101}), 3}), nil)
+
+			resp := r.POST(`{
+    "name": "follow",
+    "direction": "outward",
+	"limit": 1
+}`)
+
+			So(resp.Code, ShouldEqual, 200)
+			So(conn.countCalled, ShouldBeFalse)
 		})
 
 		Convey("query relation with wrong direction", func() {
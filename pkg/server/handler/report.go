@@ -0,0 +1,300 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/robfig/cron"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+type reportResult struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Query     map[string]interface{} `json:"query"`
+	Schedule  string                 `json:"schedule"`
+	Roles     []string               `json:"roles"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	LastRunAt *time.Time             `json:"last_run_at"`
+}
+
+func newReportResult(report skydb.Report) reportResult {
+	return reportResult{
+		ID:        report.ID,
+		Name:      report.Name,
+		Query:     report.Query,
+		Schedule:  report.Schedule,
+		Roles:     report.Roles,
+		UpdatedAt: report.UpdatedAt,
+		LastRunAt: report.LastRunAt,
+	}
+}
+
+type reportSavePayload struct {
+	ID       string                 `mapstructure:"id"`
+	Name     string                 `mapstructure:"name"`
+	Query    map[string]interface{} `mapstructure:"query"`
+	Schedule string                 `mapstructure:"schedule"`
+	Roles    []string               `mapstructure:"roles"`
+}
+
+func (payload *reportSavePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *reportSavePayload) Validate() skyerr.Error {
+	if payload.Name == "" {
+		return skyerr.NewInvalidArgument("empty report name", []string{"name"})
+	}
+
+	if payload.Query == nil {
+		return skyerr.NewInvalidArgument("empty report query", []string{"query"})
+	}
+	parser := QueryParser{}
+	if _, err := parser.ParseQuery(payload.Query); err != nil {
+		return err
+	}
+
+	if _, err := cron.Parse(payload.Schedule); err != nil {
+		return skyerr.NewInvalidArgument("schedule must be a cron spec", []string{"schedule"})
+	}
+
+	if len(payload.Roles) == 0 {
+		return skyerr.NewInvalidArgument("roles must not be empty", []string{"roles"})
+	}
+	return nil
+}
+
+/*
+ReportSaveHandler creates a scheduled report, or replaces the definition
+of an existing report. Master key is required.
+
+On each scheduled time, the query is run against the public database
+without access control. The records found are rendered as CSV and stored
+as an asset. The users of the roles, and of the roles inheriting them,
+are notified with a signed link to download it by push notification and
+by the report-ready event, which a plugin can handle to send an email.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "report:save",
+    "master_key": "MASTER_KEY",
+    "name": "Daily orders",
+    "query": {
+        "record_type": "order",
+        "predicate": [">=", {"$type": "keypath", "$val": "_created_at"}, {"$type": "date", "$date": "2017-01-01T00:00:00Z"}]
+    },
+    "schedule": "0 0 0 * * *",
+    "roles": ["manager"]
+}
+EOF
+
+{
+    "result": {
+        "id": "REPORT_ID",
+        "name": "Daily orders",
+        "query": {...},
+        "schedule": "0 0 0 * * *",
+        "roles": ["manager"],
+        "updated_at": "2017-01-01T00:00:00Z",
+        "last_run_at": null
+    }
+}
+*/
+type ReportSaveHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *ReportSaveHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *ReportSaveHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ReportSaveHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &reportSavePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	report := skydb.Report{
+		ID:        payload.ID,
+		Name:      payload.Name,
+		Query:     payload.Query,
+		Schedule:  payload.Schedule,
+		Roles:     payload.Roles,
+		UpdatedAt: timeNow().UTC(),
+	}
+	if report.ID == "" {
+		report.ID = uuid.New()
+	}
+	if err := rpayload.DBConn.SaveReport(&report); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newReportResult(report)
+}
+
+/*
+ReportListHandler returns the scheduled reports. Master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "report:list",
+    "master_key": "MASTER_KEY"
+}
+EOF
+
+{
+    "result": {
+        "reports": [{
+            "id": "REPORT_ID",
+            "name": "Daily orders",
+            ...
+        }]
+    }
+}
+*/
+type ReportListHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *ReportListHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *ReportListHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ReportListHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	reports, err := rpayload.DBConn.GetReports()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	results := []reportResult{}
+	for _, report := range reports {
+		results = append(results, newReportResult(report))
+	}
+	response.Result = map[string]interface{}{
+		"reports": results,
+	}
+}
+
+type reportDeletePayload struct {
+	ID string `mapstructure:"id"`
+}
+
+func (payload *reportDeletePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *reportDeletePayload) Validate() skyerr.Error {
+	if payload.ID == "" {
+		return skyerr.NewInvalidArgument("empty report id", []string{"id"})
+	}
+	return nil
+}
+
+/*
+ReportDeleteHandler deletes a scheduled report. Assets of the report
+which has run are not deleted. Master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "report:delete",
+    "master_key": "MASTER_KEY",
+    "id": "REPORT_ID"
+}
+EOF
+*/
+type ReportDeleteHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *ReportDeleteHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *ReportDeleteHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ReportDeleteHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &reportDeletePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	err := rpayload.DBConn.DeleteReport(payload.ID)
+	if err == skydb.ErrReportNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "report not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"id": payload.ID,
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type reportConn struct {
+	skydb.Conn
+	reports map[string]skydb.Report
+}
+
+func (c *reportConn) SaveReport(report *skydb.Report) error {
+	c.reports[report.ID] = *report
+	return nil
+}
+
+func (c *reportConn) GetReports() ([]skydb.Report, error) {
+	reports := []skydb.Report{}
+	for _, report := range c.reports {
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (c *reportConn) DeleteReport(id string) error {
+	if _, ok := c.reports[id]; !ok {
+		return skydb.ErrReportNotFound
+	}
+	delete(c.reports, id)
+	return nil
+}
+
+func TestReportHandlers(t *testing.T) {
+	Convey("Report handlers", t, func() {
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		realTime := timeNow
+		timeNow = func() time.Time { return now }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := &reportConn{reports: map[string]skydb.Report{}}
+		query := map[string]interface{}{"record_type": "order"}
+
+		Convey("saves report", func() {
+			handler := &ReportSaveHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"id":       "orders",
+					"name":     "Daily orders",
+					"query":    query,
+					"schedule": "0 0 0 * * *",
+					"roles":    []interface{}{"manager"},
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			report := skydb.Report{
+				ID:        "orders",
+				Name:      "Daily orders",
+				Query:     query,
+				Schedule:  "0 0 0 * * *",
+				Roles:     []string{"manager"},
+				UpdatedAt: now,
+			}
+			So(conn.reports["orders"], ShouldResemble, report)
+			So(resp.Result, ShouldResemble, newReportResult(report))
+		})
+
+		Convey("rejects invalid report", func() {
+			data := map[string]interface{}{
+				"name":     "Daily orders",
+				"query":    query,
+				"schedule": "0 0 0 * * *",
+				"roles":    []interface{}{"manager"},
+			}
+
+			Convey("with invalid schedule", func() {
+				data["schedule"] = "daily"
+			})
+
+			Convey("with invalid query", func() {
+				data["query"] = map[string]interface{}{"record_type": "order", "predicate": "invalid"}
+			})
+
+			Convey("without roles", func() {
+				data["roles"] = []interface{}{}
+			})
+
+			handler := &ReportSaveHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data:   data,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldNotBeNil)
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(conn.reports, ShouldBeEmpty)
+		})
+
+		Convey("lists reports", func() {
+			report := skydb.Report{
+				ID:        "orders",
+				Name:      "Daily orders",
+				Query:     query,
+				Schedule:  "0 0 0 * * *",
+				Roles:     []string{"manager"},
+				UpdatedAt: now,
+			}
+			conn.reports["orders"] = report
+
+			handler := &ReportListHandler{}
+			req := router.Payload{
+				DBConn: conn,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, map[string]interface{}{
+				"reports": []reportResult{newReportResult(report)},
+			})
+		})
+
+		Convey("deletes report", func() {
+			conn.reports["orders"] = skydb.Report{ID: "orders"}
+
+			handler := &ReportDeleteHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"id": "orders",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(conn.reports, ShouldBeEmpty)
+
+			resp = router.Response{}
+			handler.Handle(&req, &resp)
+			So(resp.Err.Code(), ShouldEqual, skyerr.ResourceNotFound)
+		})
+	})
+}
@@ -15,8 +15,11 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/mitchellh/mapstructure"
 
+	"github.com/skygeario/skygear-server/pkg/server/audit"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
@@ -63,6 +66,7 @@ type RoleDefaultHandler struct {
 	AccessKey     router.Processor `preprocessor:"accesskey"`
 	DevOnly       router.Processor `preprocessor:"dev_only"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
 }
@@ -72,6 +76,7 @@ func (h *RoleDefaultHandler) Setup() {
 		h.AccessKey,
 		h.DevOnly,
 		h.DBConn,
+		h.Maintenance,
 		h.PluginReady,
 	}
 }
@@ -121,6 +126,7 @@ type RoleAdminHandler struct {
 	AccessKey     router.Processor `preprocessor:"accesskey"`
 	DevOnly       router.Processor `preprocessor:"dev_only"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
 }
@@ -130,6 +136,7 @@ func (h *RoleAdminHandler) Setup() {
 		h.AccessKey,
 		h.DevOnly,
 		h.DBConn,
+		h.Maintenance,
 		h.PluginReady,
 	}
 }
@@ -204,8 +211,10 @@ func (payload *roleBatchPayload) Validate() skyerr.Error {
 //     "result": "OK"
 // }
 type RoleAssignHandler struct {
+	AuditStream   *audit.Stream    `inject:"AuditStream"`
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	RequireAdmin  router.Processor `preprocessor:"require_admin"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
@@ -216,6 +225,7 @@ func (h *RoleAssignHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.RequireAdmin,
 		h.PluginReady,
@@ -239,6 +249,17 @@ func (h *RoleAssignHandler) Handle(rpayload *router.Payload, response *router.Re
 		response.Err = skyerr.MakeError(err)
 		return
 	}
+
+	for _, userID := range payload.UserIDs {
+		h.AuditStream.Emit(rpayload.Req, audit.Event{
+			Type:    audit.RolesAssigned,
+			UserID:  userID,
+			ActorID: rpayload.AuthInfoID,
+			Data: map[string]interface{}{
+				"roles": payload.Roles,
+			},
+		})
+	}
 	response.Result = "OK"
 }
 
@@ -270,8 +291,10 @@ func (h *RoleAssignHandler) Handle(rpayload *router.Payload, response *router.Re
 //     "result": "OK"
 // }
 type RoleRevokeHandler struct {
+	AuditStream   *audit.Stream    `inject:"AuditStream"`
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	RequireAdmin  router.Processor `preprocessor:"require_admin"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
@@ -282,6 +305,7 @@ func (h *RoleRevokeHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.RequireAdmin,
 		h.PluginReady,
@@ -305,6 +329,17 @@ func (h *RoleRevokeHandler) Handle(rpayload *router.Payload, response *router.Re
 		response.Err = skyerr.MakeError(err)
 		return
 	}
+
+	for _, userID := range payload.UserIDs {
+		h.AuditStream.Emit(rpayload.Req, audit.Event{
+			Type:    audit.RolesRevoked,
+			UserID:  userID,
+			ActorID: rpayload.AuthInfoID,
+			Data: map[string]interface{}{
+				"roles": payload.Roles,
+			},
+		})
+	}
 	response.Result = "OK"
 }
 
@@ -421,3 +456,169 @@ func (h *RoleGetHandler) Handle(rpayload *router.Payload, response *router.Respo
 
 	response.Result = roleMap
 }
+
+type roleInheritPayload struct {
+	Role           string   `mapstructure:"role"`
+	InheritedRoles []string `mapstructure:"inherited_roles"`
+}
+
+func (payload *roleInheritPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *roleInheritPayload) Validate() skyerr.Error {
+	if payload.Role == "" {
+		return skyerr.NewInvalidArgument("unspecified role in request", []string{"role"})
+	}
+	if payload.InheritedRoles == nil {
+		return skyerr.NewInvalidArgument("unspecified inherited roles in request", []string{"inherited_roles"})
+	}
+
+	seen := map[string]bool{}
+	for _, role := range payload.InheritedRoles {
+		if role == "" {
+			return skyerr.NewInvalidArgument("inherited role must not be empty", []string{"inherited_roles"})
+		}
+		if seen[role] {
+			return skyerr.NewInvalidArgument("duplicated inherited role "+role, []string{"inherited_roles"})
+		}
+		seen[role] = true
+	}
+	return nil
+}
+
+// RoleInheritHandler enable system administrator to set the roles inherited
+// by a role. A user having the role also has the inherited roles, and the
+// roles inherited by them. The inherited roles replace those previously set
+// for the role; an empty list removes them.
+//
+// A role cannot inherit a role that inherits it, directly or indirectly.
+//
+// curl -X POST -H "Content-Type: application/json" \
+//   -d @- http://localhost:3000/ <<EOF
+// {
+//     "action": "role:inherit",
+//     "master_key": "MASTER_KEY",
+//     "role": "admin",
+//     "inherited_roles": [
+//        "moderator"
+//     ]
+// }
+// EOF
+//
+// {
+//     "result": {
+//         "role": "admin",
+//         "inherited_roles": [
+//            "moderator"
+//         ]
+//     }
+// }
+type RoleInheritHandler struct {
+	AccessKey     router.Processor `preprocessor:"accesskey"`
+	DevOnly       router.Processor `preprocessor:"dev_only"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RoleInheritHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.AccessKey,
+		h.DevOnly,
+		h.DBConn,
+		h.Maintenance,
+		h.PluginReady,
+	}
+}
+
+func (h *RoleInheritHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RoleInheritHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &roleInheritPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	hierarchy, err := rpayload.DBConn.GetRoleHierarchy()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	for _, inheritedRole := range payload.InheritedRoles {
+		if inheritedRole == payload.Role || hierarchy.Inherits(inheritedRole, payload.Role) {
+			response.Err = skyerr.NewInvalidArgument(
+				fmt.Sprintf("role %s cannot inherit role %s which inherits it", payload.Role, inheritedRole),
+				[]string{"inherited_roles"},
+			)
+			return
+		}
+	}
+
+	if err := rpayload.DBConn.SetRoleInheritance(payload.Role, payload.InheritedRoles); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = struct {
+		Role           string   `json:"role"`
+		InheritedRoles []string `json:"inherited_roles"`
+	}{payload.Role, payload.InheritedRoles}
+}
+
+// RoleHierarchyHandler returns the roles inherited directly by each role.
+//
+// curl -X POST -H "Content-Type: application/json" \
+//   -d @- http://localhost:3000/ <<EOF
+// {
+//     "action": "role:hierarchy",
+//     "master_key": "MASTER_KEY"
+// }
+// EOF
+//
+// {
+//     "result": {
+//         "admin": [
+//            "moderator"
+//         ],
+//         "moderator": [
+//            "member"
+//         ]
+//     }
+// }
+type RoleHierarchyHandler struct {
+	AccessKey     router.Processor `preprocessor:"accesskey"`
+	DevOnly       router.Processor `preprocessor:"dev_only"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *RoleHierarchyHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.AccessKey,
+		h.DevOnly,
+		h.DBConn,
+		h.PluginReady,
+	}
+}
+
+func (h *RoleHierarchyHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RoleHierarchyHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	hierarchy, err := rpayload.DBConn.GetRoleHierarchy()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	response.Result = hierarchy
+}
@@ -61,6 +61,7 @@ type roleConn struct {
 	skydb.Conn
 	adminRoles   []string
 	defaultRoles []string
+	hierarchy    skydb.RoleHierarchy
 }
 
 func (conn *roleConn) SetAdminRoles(roles []string) error {
@@ -73,6 +74,15 @@ func (conn *roleConn) SetDefaultRoles(roles []string) error {
 	return nil
 }
 
+func (conn *roleConn) GetRoleHierarchy() (skydb.RoleHierarchy, error) {
+	return conn.hierarchy, nil
+}
+
+func (conn *roleConn) SetRoleInheritance(role string, inheritedRoles []string) error {
+	conn.hierarchy[role] = inheritedRoles
+	return nil
+}
+
 func TestRoleDefaultHandler(t *testing.T) {
 	Convey("RoleDefaultHandler", t, func() {
 		mockConn := &roleConn{}
@@ -420,3 +430,93 @@ func TestRoleGetHandler(t *testing.T) {
 		})
 	})
 }
+
+func TestRoleInheritHandler(t *testing.T) {
+	Convey("RoleInheritHandler", t, func() {
+		mockConn := &roleConn{
+			hierarchy: skydb.RoleHierarchy{
+				"moderator": []string{"member"},
+			},
+		}
+		router := handlertest.NewSingleRouteRouter(&RoleInheritHandler{}, func(p *router.Payload) {
+			p.DBConn = mockConn
+		})
+
+		Convey("set inherited roles", func() {
+			resp := router.POST(`{
+    "role": "admin",
+    "inherited_roles": ["moderator"]
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+    "result": {
+        "role": "admin",
+        "inherited_roles": ["moderator"]
+    }
+}`)
+			So(mockConn.hierarchy, ShouldResemble, skydb.RoleHierarchy{
+				"admin":     []string{"moderator"},
+				"moderator": []string{"member"},
+			})
+		})
+
+		Convey("reject cyclic inheritance", func() {
+			mockConn.hierarchy["admin"] = []string{"moderator"}
+			resp := router.POST(`{
+    "role": "member",
+    "inherited_roles": ["admin"]
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+    "error": {
+        "code": 108,
+        "message": "role member cannot inherit role admin which inherits it",
+        "info": {
+            "arguments": [
+                "inherited_roles"
+            ]
+        },
+        "name": "InvalidArgument"
+    }
+}`)
+		})
+
+		Convey("reject request without inherited roles", func() {
+			resp := router.POST(`{
+    "role": "admin"
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+    "error": {
+        "code": 108,
+        "message": "unspecified inherited roles in request",
+        "info": {
+            "arguments": [
+                "inherited_roles"
+            ]
+        },
+        "name": "InvalidArgument"
+    }
+}`)
+		})
+	})
+}
+
+func TestRoleHierarchyHandler(t *testing.T) {
+	Convey("RoleHierarchyHandler", t, func() {
+		mockConn := &roleConn{
+			hierarchy: skydb.RoleHierarchy{
+				"admin":     []string{"moderator"},
+				"moderator": []string{"member"},
+			},
+		}
+		router := handlertest.NewSingleRouteRouter(&RoleHierarchyHandler{}, func(p *router.Payload) {
+			p.DBConn = mockConn
+		})
+
+		resp := router.POST(`{}`)
+		So(resp.Body.Bytes(), ShouldEqualJSON, `{
+    "result": {
+        "admin": ["moderator"],
+        "moderator": ["member"]
+    }
+}`)
+	})
+}
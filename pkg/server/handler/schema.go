@@ -15,6 +15,7 @@
 package handler
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 
@@ -49,6 +50,7 @@ type SchemaRenameHandler struct {
 	AccessKey     router.Processor   `preprocessor:"accesskey"`
 	DevOnly       router.Processor   `preprocessor:"dev_only"`
 	DBConn        router.Processor   `preprocessor:"dbconn"`
+	Maintenance   router.Processor   `preprocessor:"maintenance"`
 	InjectDB      router.Processor   `preprocessor:"inject_db"`
 	PluginReady   router.Processor   `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
@@ -59,6 +61,7 @@ func (h *SchemaRenameHandler) Setup() {
 		h.AccessKey,
 		h.DevOnly,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectDB,
 		h.PluginReady,
 	}
@@ -117,7 +120,7 @@ func (h *SchemaRenameHandler) Handle(rpayload *router.Payload, response *router.
 
 	db := rpayload.Database
 	if err := db.RenameSchema(payload.RecordType, payload.OldName, payload.NewName); err != nil {
-		response.Err = skyerr.NewError(skyerr.ResourceNotFound, err.Error())
+		response.Err = schemaAlterError(err)
 		return
 	}
 
@@ -138,6 +141,16 @@ func (h *SchemaRenameHandler) Handle(rpayload *router.Payload, response *router.
 	}
 }
 
+// schemaAlterError converts the error of altering a record schema to the
+// error in the response. Errors other than skyerr.Error are caused by
+// missing record types or fields.
+func schemaAlterError(err error) skyerr.Error {
+	if skyErr, ok := err.(skyerr.Error); ok {
+		return skyErr
+	}
+	return skyerr.NewError(skyerr.ResourceNotFound, err.Error())
+}
+
 /*
 SchemaDeleteHandler handles the action of deleting column
 curl -X POST -H "Content-Type: application/json" \
@@ -155,6 +168,7 @@ type SchemaDeleteHandler struct {
 	AccessKey     router.Processor   `preprocessor:"accesskey"`
 	DevOnly       router.Processor   `preprocessor:"dev_only"`
 	DBConn        router.Processor   `preprocessor:"dbconn"`
+	Maintenance   router.Processor   `preprocessor:"maintenance"`
 	InjectDB      router.Processor   `preprocessor:"inject_db"`
 	PluginReady   router.Processor   `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
@@ -165,6 +179,7 @@ func (h *SchemaDeleteHandler) Setup() {
 		h.AccessKey,
 		h.DevOnly,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectDB,
 		h.PluginReady,
 	}
@@ -217,7 +232,7 @@ func (h *SchemaDeleteHandler) Handle(rpayload *router.Payload, response *router.
 
 	db := rpayload.Database
 	if err := db.DeleteSchema(payload.RecordType, payload.ColumnName); err != nil {
-		response.Err = skyerr.NewError(skyerr.ResourceNotFound, err.Error())
+		response.Err = schemaAlterError(err)
 		return
 	}
 
@@ -249,7 +264,8 @@ curl -X POST -H "Content-Type: application/json" \
 		"student": {
 			"fields":[
 				{"name": "age", "type": "number"},
-				{"name": "nickname" "type": "string"}
+				{"name": "nickname" "type": "string"},
+				{"name": "grade", "type": "integer", "required": true, "default": 1}
 			]
 		}
 	}
@@ -261,6 +277,7 @@ type SchemaCreateHandler struct {
 	AccessKey     router.Processor   `preprocessor:"accesskey"`
 	DevOnly       router.Processor   `preprocessor:"dev_only"`
 	DBConn        router.Processor   `preprocessor:"dbconn"`
+	Maintenance   router.Processor   `preprocessor:"maintenance"`
 	InjectDB      router.Processor   `preprocessor:"inject_db"`
 	PluginReady   router.Processor   `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
@@ -271,6 +288,7 @@ func (h *SchemaCreateHandler) Setup() {
 		h.AccessKey,
 		h.DevOnly,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectDB,
 		h.PluginReady,
 	}
@@ -295,11 +313,13 @@ func (payload *schemaCreatePayload) Decode(data map[string]interface{}) skyerr.E
 	for recordType, schema := range payload.RawSchemas {
 		payload.Schemas[recordType] = make(skydb.RecordSchema)
 		for _, field := range schema.Fields {
-			var err error
-			payload.Schemas[recordType][field.Name], err = skydb.SimpleNameToFieldType(field.TypeName)
+			fieldType, err := skydb.SimpleNameToFieldType(field.TypeName)
 			if err != nil {
 				return skyerr.NewInvalidArgument("unexpected field type", []string{field.TypeName})
 			}
+			fieldType.Required = field.Required
+			fieldType.Default = field.Default
+			payload.Schemas[recordType][field.Name] = fieldType
 		}
 	}
 
@@ -311,10 +331,16 @@ func (payload *schemaCreatePayload) Validate() skyerr.Error {
 		if strings.HasPrefix(recordType, "_") {
 			return skyerr.NewInvalidArgument("attempts to create reserved table", []string{recordType})
 		}
-		for fieldName := range schema {
+		for fieldName, fieldType := range schema {
 			if strings.HasPrefix(fieldName, "_") {
 				return skyerr.NewInvalidArgument("attempts to create reserved field", []string{fieldName})
 			}
+			if !fieldType.DefaultCompatible() {
+				return skyerr.NewInvalidArgument(
+					fmt.Sprintf("default value of %s is not a valid %s", fieldName, fieldType.ToSimpleName()),
+					[]string{fieldName},
+				)
+			}
 		}
 	}
 	return nil
@@ -421,6 +447,7 @@ type SchemaAccessHandler struct {
 	AccessKey     router.Processor `preprocessor:"accesskey"`
 	DevOnly       router.Processor `preprocessor:"dev_only"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
@@ -442,6 +469,7 @@ func (h *SchemaAccessHandler) Setup() {
 		h.AccessKey,
 		h.DevOnly,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectDB,
 		h.PluginReady,
 	}
@@ -501,7 +529,9 @@ func (h *SchemaAccessHandler) Handle(rpayload *router.Payload, response *router.
 }
 
 /*
-SchemaDefaultAccessHandler handles the update of creation access of record
+SchemaDefaultAccessHandler handles the update of default access of record.
+The default access is applied to new records of the type saved without
+_access.
 curl -X POST -H "Content-Type: application/json" \
   -d @- http://localhost:3000/schema/default_access <<EOF
 {
@@ -518,6 +548,7 @@ type SchemaDefaultAccessHandler struct {
 	AccessKey     router.Processor `preprocessor:"accesskey"`
 	DevOnly       router.Processor `preprocessor:"dev_only"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
 	preprocessors []router.Processor
@@ -539,6 +570,7 @@ func (h *SchemaDefaultAccessHandler) Setup() {
 		h.AccessKey,
 		h.DevOnly,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectDB,
 		h.PluginReady,
 	}
@@ -601,6 +633,192 @@ func (h *SchemaDefaultAccessHandler) Handle(rpayload *router.Payload, response *
 	}
 }
 
+/*
+SchemaSystemFieldAccessHandler handles the update of how system fields of
+records of a type are serialized to requesters other than the owner.
+A system field can be visible, hidden or replaced with a pseudonym.
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/schema/system_field_access <<EOF
+{
+	"master_key": "MASTER_KEY",
+	"action": "schema:system_field_access",
+	"type": "confession",
+	"masks": {
+		"_owner_id": "pseudonym",
+		"_created_by": "pseudonym",
+		"_updated_by": "hidden",
+		"_access": "hidden"
+	}
+}
+EOF
+*/
+type SchemaSystemFieldAccessHandler struct {
+	AccessKey     router.Processor `preprocessor:"accesskey"`
+	DevOnly       router.Processor `preprocessor:"dev_only"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+type schemaSystemFieldAccessPayload struct {
+	Type  string                 `mapstructure:"type"`
+	Masks skydb.SystemFieldMasks `mapstructure:"masks"`
+}
+
+type schemaSystemFieldAccessResponse struct {
+	Type  string                 `json:"type"`
+	Masks skydb.SystemFieldMasks `json:"masks"`
+}
+
+func (h *SchemaSystemFieldAccessHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.AccessKey,
+		h.DevOnly,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectDB,
+		h.PluginReady,
+	}
+}
+
+func (h *SchemaSystemFieldAccessHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (payload *schemaSystemFieldAccessPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+
+	return payload.Validate()
+}
+
+func (payload *schemaSystemFieldAccessPayload) Validate() skyerr.Error {
+	if payload.Type == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"type"})
+	}
+
+	if err := payload.Masks.Validate(); err != nil {
+		return skyerr.NewInvalidArgument(err.Error(), []string{"masks"})
+	}
+
+	return nil
+}
+
+func (h *SchemaSystemFieldAccessHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := schemaSystemFieldAccessPayload{}
+	skyErr := payload.Decode(rpayload.Data)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if payload.Masks == nil {
+		payload.Masks = skydb.SystemFieldMasks{}
+	}
+
+	c := rpayload.Database.Conn()
+	if err := c.SetRecordSystemFieldAccess(payload.Type, payload.Masks); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = schemaSystemFieldAccessResponse{
+		Type:  payload.Type,
+		Masks: payload.Masks,
+	}
+}
+
+/*
+SchemaStrictHandler turns strict schema mode of a record type on or off.
+In strict schema mode, saving a record does not create the table or
+columns of the record type, so that a typo in a field name does not add
+a column to the schema. The record is rejected with IncompatibleSchema
+instead. The schema can still be changed by schema migration.
+Specify "*" as the type to turn on strict schema mode for every type.
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/schema/strict <<EOF
+{
+	"master_key": "MASTER_KEY",
+	"action": "schema:strict",
+	"type": "note",
+	"strict": true
+}
+EOF
+*/
+type SchemaStrictHandler struct {
+	AccessKey     router.Processor `preprocessor:"accesskey"`
+	DevOnly       router.Processor `preprocessor:"dev_only"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+type schemaStrictPayload struct {
+	Type   string `mapstructure:"type"`
+	Strict bool   `mapstructure:"strict"`
+}
+
+type schemaStrictResponse struct {
+	Type   string `json:"type"`
+	Strict bool   `json:"strict"`
+}
+
+func (h *SchemaStrictHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.AccessKey,
+		h.DevOnly,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectDB,
+		h.PluginReady,
+	}
+}
+
+func (h *SchemaStrictHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (payload *schemaStrictPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+
+	return payload.Validate()
+}
+
+func (payload *schemaStrictPayload) Validate() skyerr.Error {
+	if payload.Type == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"type"})
+	}
+
+	return nil
+}
+
+func (h *SchemaStrictHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := schemaStrictPayload{}
+	skyErr := payload.Decode(rpayload.Data)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	c := rpayload.Database.Conn()
+	if err := c.SetRecordStrictSchema(payload.Type, payload.Strict); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = schemaStrictResponse{
+		Type:   payload.Type,
+		Strict: payload.Strict,
+	}
+}
+
 type schemaFieldAccessResponse struct {
 	Access skydb.FieldACLEntryList `json:"access"`
 }
@@ -732,6 +950,7 @@ EOF
 type SchemaFieldAccessUpdateHandler struct {
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectUser    router.Processor `preprocessor:"inject_user"`
 	RequireAdmin  router.Processor `preprocessor:"require_admin"`
 	PluginReady   router.Processor `preprocessor:"plugin_ready"`
@@ -742,6 +961,7 @@ func (h *SchemaFieldAccessUpdateHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectUser,
 		h.RequireAdmin,
 		h.PluginReady,
@@ -259,6 +259,57 @@ func TestSchemaCreateHandler(t *testing.T) {
 			}`)
 		})
 
+		Convey("create field with default and required", func() {
+			resp := router.POST(`{
+				"record_types": {
+					"note": {
+						"fields": [
+							{"name": "field3", "type": "string", "required": true},
+							{"name": "field4", "type": "boolean", "default": false}
+						]
+					}
+				}
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"record_types": {
+						"note": {
+							"fields": [
+								{"name": "field1", "type": "string"},
+								{"name": "field2", "type": "datetime"},
+								{"name": "field3", "type": "string", "required": true},
+								{"name": "field4", "type": "boolean", "default": false}
+							]
+						}
+					}
+				}
+			}`)
+		})
+
+		Convey("create field with invalid default", func() {
+			resp := router.POST(`{
+				"record_types": {
+					"note": {
+						"fields": [
+							{"name": "field3", "type": "integer", "default": "one"}
+						]
+					}
+				}
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 108,
+					"message": "default value of field3 is not a valid integer",
+					"info": {
+						"arguments": ["field3"]
+					},
+					"name": "InvalidArgument"
+				}
+			}`)
+		})
+
 		Convey("create reserved field", func() {
 			resp := router.POST(`{
 				"record_types": {
@@ -413,6 +464,14 @@ func TestSchemaRenamePayload(t *testing.T) {
 	})
 }
 
+type noMigrationDB struct {
+	*skydbtest.MapDB
+}
+
+func (db *noMigrationDB) RenameSchema(recordType, oldColumnName, newColumnName string) error {
+	return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+}
+
 func TestSchemaRenameHandler(t *testing.T) {
 	Convey("SchemaRenameHandler", t, func() {
 		note := skydb.RecordSchema{
@@ -428,12 +487,12 @@ func TestSchemaRenameHandler(t *testing.T) {
 		_, err := db.Extend("note", note)
 		So(err, ShouldBeNil)
 
-		router := handlertest.NewSingleRouteRouter(&SchemaRenameHandler{}, func(p *router.Payload) {
+		r := handlertest.NewSingleRouteRouter(&SchemaRenameHandler{}, func(p *router.Payload) {
 			p.Database = db
 		})
 
 		Convey("rename normal field", func() {
-			resp := router.POST(`{
+			resp := r.POST(`{
 				"record_type": "note",
 				"item_name": "field1",
 				"new_name": "newName"
@@ -454,7 +513,7 @@ func TestSchemaRenameHandler(t *testing.T) {
 		})
 
 		Convey("rename reserved field", func() {
-			resp := router.POST(`{
+			resp := r.POST(`{
 				"record_type": "note",
 				"item_name": "_id",
 				"new_name": "newName"
@@ -475,7 +534,7 @@ func TestSchemaRenameHandler(t *testing.T) {
 		})
 
 		Convey("rename nonexisting field", func() {
-			resp := router.POST(`{
+			resp := r.POST(`{
 				"record_type": "note",
 				"item_name": "notexist",
 				"new_name": "newName"
@@ -491,7 +550,7 @@ func TestSchemaRenameHandler(t *testing.T) {
 		})
 
 		Convey("rename to existing field", func() {
-			resp := router.POST(`{
+			resp := r.POST(`{
 				"record_type": "note",
 				"item_name": "field1",
 				"new_name": "field2"
@@ -505,6 +564,25 @@ func TestSchemaRenameHandler(t *testing.T) {
 				}
 			}`)
 		})
+
+		Convey("rename field with migration disabled", func() {
+			noMigrationRouter := handlertest.NewSingleRouteRouter(&SchemaRenameHandler{}, func(p *router.Payload) {
+				p.Database = &noMigrationDB{db}
+			})
+			resp := noMigrationRouter.POST(`{
+				"record_type": "note",
+				"item_name": "field1",
+				"new_name": "newName"
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"code": 114,
+					"message": "Record schema requires migration but migration is disabled.",
+					"name": "IncompatibleSchema"
+				}
+			}`)
+		})
 	})
 }
 
@@ -926,6 +1004,139 @@ func TestSchemaDefaultAccessHandler(t *testing.T) {
 	})
 }
 
+func TestSchemaSystemFieldAccessHandler(t *testing.T) {
+	Convey("SchemaSystemFieldAccessHandler", t, func() {
+		conn := skydbtest.NewMapConn()
+		db := &mockSchemaDefaultAccessDatabase{DBConn: conn}
+
+		handler := handlertest.NewSingleRouteRouter(&SchemaSystemFieldAccessHandler{}, func(p *router.Payload) {
+			p.Database = db
+		})
+
+		Convey("sets masks of system fields", func() {
+			resp := handler.POST(`{
+				"type": "confession",
+				"masks": {
+					"_owner_id": "pseudonym",
+					"_access": "hidden"
+				}
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"type": "confession",
+					"masks": {
+						"_owner_id": "pseudonym",
+						"_access": "hidden"
+					}
+				}
+			}`)
+
+			access, err := conn.GetRecordSystemFieldAccess()
+			So(err, ShouldBeNil)
+			So(access["confession"].Masks, ShouldResemble, skydb.SystemFieldMasks{
+				"_owner_id": skydb.SystemFieldPseudonym,
+				"_access":   skydb.SystemFieldHidden,
+			})
+		})
+
+		Convey("rejects invalid mask", func() {
+			resp := handler.POST(`{
+				"type": "confession",
+				"masks": {
+					"_access": "pseudonym"
+				}
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "_access cannot be masked with pseudonym",
+					"info": {"arguments": ["masks"]}
+				}
+			}`)
+		})
+
+		Convey("rejects missing type", func() {
+			resp := handler.POST(`{
+				"masks": {
+					"_owner_id": "hidden"
+				}
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "missing required fields",
+					"info": {"arguments": ["type"]}
+				}
+			}`)
+		})
+	})
+}
+
+func TestSchemaStrictHandler(t *testing.T) {
+	Convey("SchemaStrictHandler", t, func() {
+		conn := skydbtest.NewMapConn()
+		db := &mockSchemaDefaultAccessDatabase{DBConn: conn}
+
+		handler := handlertest.NewSingleRouteRouter(&SchemaStrictHandler{}, func(p *router.Payload) {
+			p.Database = db
+		})
+
+		Convey("turns strict schema mode on and off", func() {
+			resp := handler.POST(`{
+				"type": "note",
+				"strict": true
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"type": "note",
+					"strict": true
+				}
+			}`)
+
+			strictSchema, err := conn.GetRecordStrictSchema()
+			So(err, ShouldBeNil)
+			So(strictSchema.IsStrict("note"), ShouldBeTrue)
+
+			resp = handler.POST(`{
+				"type": "note",
+				"strict": false
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"type": "note",
+					"strict": false
+				}
+			}`)
+
+			strictSchema, err = conn.GetRecordStrictSchema()
+			So(err, ShouldBeNil)
+			So(strictSchema.IsStrict("note"), ShouldBeFalse)
+		})
+
+		Convey("rejects missing type", func() {
+			resp := handler.POST(`{
+				"strict": true
+			}`)
+
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "missing required fields",
+					"info": {"arguments": ["type"]}
+				}
+			}`)
+		})
+	})
+}
+
 func TestSchemaFieldAccessGetHandler(t *testing.T) {
 	Convey("SchemaFieldAccessGetHandler", t, func() {
 		ctrl := gomock.NewController(handlertest.NewGoroutineAwareTestReporter(t))
@@ -40,8 +40,10 @@ func (s schemaFieldList) Less(i, j int) bool {
 }
 
 type schemaField struct {
-	Name     string `mapstructure:"name" json:"name"`
-	TypeName string `mapstructure:"type" json:"type"`
+	Name     string      `mapstructure:"name" json:"name"`
+	TypeName string      `mapstructure:"type" json:"type"`
+	Required bool        `mapstructure:"required" json:"required,omitempty"`
+	Default  interface{} `mapstructure:"default" json:"default,omitempty"`
 }
 
 func encodeRecordSchemas(data map[string]skydb.RecordSchema) map[string]schemaFieldList {
@@ -60,6 +62,8 @@ func encodeRecordSchemas(data map[string]skydb.RecordSchema) map[string]schemaFi
 			fieldList.Fields = append(fieldList.Fields, schemaField{
 				Name:     fieldName,
 				TypeName: val.ToSimpleName(),
+				Required: val.Required,
+				Default:  val.Default,
 			})
 		}
 		sort.Sort(fieldList)
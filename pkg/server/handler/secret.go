@@ -0,0 +1,399 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/secret"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type secretResult struct {
+	Name      string    `json:"name"`
+	KeyID     string    `json:"key_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type secretPayload struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
+}
+
+func (payload *secretPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+var errSecretKeyNotConfigured = skyerr.NewError(
+	skyerr.NotSupported,
+	"SECRET_KEY is not configured to seal secrets",
+)
+
+// reloadSecrets reloads the secrets resolved for the server and plugins
+// after a secret is changed. The change is still saved if reloading
+// fails, and takes effect when the secrets are reloaded periodically.
+func reloadSecrets(resolver *secret.Resolver) {
+	if resolver == nil {
+		return
+	}
+	if err := resolver.Reload(); err != nil {
+		log.Warnf("Failed to reload secrets: %v", err)
+	}
+}
+
+func (payload *secretPayload) Validate() skyerr.Error {
+	if payload.Name == "" {
+		return skyerr.NewInvalidArgument("empty secret name", []string{"name"})
+	}
+	return nil
+}
+
+/*
+SecretSetHandler seals the value of a secret, such as the client secret
+of an OAuth provider, and saves it to the database. Master key is
+required.
+
+Secrets named apns_certificate, apns_private_key, apns_token_key and
+gcm_api_key are used as push notification credentials when they are not
+configured. Secrets prefixed with oauth_ or smtp_ are passed to plugins
+in the init payload and the secrets-changed event. Changes take effect
+without restarting the server.
+
+SECRET_KEY is required to save secrets.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "secret:set",
+    "master_key": "MASTER_KEY",
+    "name": "gcm_api_key",
+    "value": "GCM_API_KEY"
+}
+EOF
+
+{
+    "result": {
+        "name": "gcm_api_key",
+        "key_id": "master",
+        "updated_at": "2017-01-01T00:00:00Z"
+    }
+}
+*/
+type SecretSetHandler struct {
+	Sealer           secret.Sealer    `inject:"SecretSealer"`
+	SecretResolver   *secret.Resolver `inject:"SecretResolver"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *SecretSetHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *SecretSetHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *SecretSetHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &secretPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+	if payload.Value == "" {
+		response.Err = skyerr.NewInvalidArgument("empty secret value", []string{"value"})
+		return
+	}
+
+	now := timeNow().UTC()
+	err := secret.Set(rpayload.DBConn, h.Sealer, payload.Name, payload.Value, now)
+	if err == secret.ErrNoKey {
+		response.Err = errSecretKeyNotConfigured
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	reloadSecrets(h.SecretResolver)
+
+	response.Result = secretResult{
+		Name:      payload.Name,
+		KeyID:     h.Sealer.KeyID(),
+		UpdatedAt: now,
+	}
+}
+
+/*
+SecretGetHandler returns the value of a secret. Master key is required,
+so that plugins can read the secrets without placing them in environment
+variables.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "secret:get",
+    "master_key": "MASTER_KEY",
+    "name": "smtp_password"
+}
+EOF
+
+{
+    "result": {
+        "name": "smtp_password",
+        "value": "SMTP_PASSWORD"
+    }
+}
+*/
+type SecretGetHandler struct {
+	Sealer           secret.Sealer    `inject:"SecretSealer"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *SecretGetHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *SecretGetHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *SecretGetHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &secretPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	value, err := secret.Get(rpayload.DBConn, h.Sealer, payload.Name)
+	if err == skydb.ErrSecretNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "secret not found")
+		return
+	} else if err == secret.ErrUnknownKey {
+		response.Err = skyerr.NewError(skyerr.UnexpectedError, "secret is sealed by an unknown key")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"name":  payload.Name,
+		"value": value,
+	}
+}
+
+/*
+SecretListHandler returns the names of the secrets and the keys sealing
+them. The values of the secrets are not returned. Master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "secret:list",
+    "master_key": "MASTER_KEY"
+}
+EOF
+
+{
+    "result": {
+        "secrets": [{
+            "name": "gcm_api_key",
+            "key_id": "master",
+            "updated_at": "2017-01-01T00:00:00Z"
+        }]
+    }
+}
+*/
+type SecretListHandler struct {
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *SecretListHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *SecretListHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *SecretListHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	secrets, err := rpayload.DBConn.GetSecrets()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	results := []secretResult{}
+	for _, secret := range secrets {
+		results = append(results, secretResult{
+			Name:      secret.Name,
+			KeyID:     secret.KeyID,
+			UpdatedAt: secret.UpdatedAt,
+		})
+	}
+	response.Result = map[string]interface{}{
+		"secrets": results,
+	}
+}
+
+/*
+SecretDeleteHandler deletes a secret. Master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "secret:delete",
+    "master_key": "MASTER_KEY",
+    "name": "gcm_api_key"
+}
+EOF
+*/
+type SecretDeleteHandler struct {
+	SecretResolver   *secret.Resolver `inject:"SecretResolver"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *SecretDeleteHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *SecretDeleteHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *SecretDeleteHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &secretPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	err := rpayload.DBConn.DeleteSecret(payload.Name)
+	if err == skydb.ErrSecretNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "secret not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	reloadSecrets(h.SecretResolver)
+
+	response.Result = map[string]interface{}{
+		"name": payload.Name,
+	}
+}
+
+/*
+SecretRotateHandler reseals the secrets which are sealed by an old key
+with the current key. Master key is required.
+
+After rotating, the old key can be removed from SECRET_OLD_KEYS. Secrets
+sealed by the master key before SECRET_KEY was required are resealed as
+well.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "secret:rotate",
+    "master_key": "MASTER_KEY"
+}
+EOF
+
+{
+    "result": {
+        "key_id": "2017-01",
+        "rotated": 2
+    }
+}
+*/
+type SecretRotateHandler struct {
+	Sealer           secret.Sealer    `inject:"SecretSealer"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *SecretRotateHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *SecretRotateHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *SecretRotateHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	count, err := secret.Rotate(rpayload.DBConn, h.Sealer, timeNow().UTC())
+	if err == secret.ErrNoKey {
+		response.Err = errSecretKeyNotConfigured
+		return
+	} else if err == secret.ErrUnknownKey {
+		response.Err = skyerr.NewError(skyerr.UnexpectedError, "secret is sealed by an unknown key")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"key_id":  h.Sealer.KeyID(),
+		"rotated": count,
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/secret"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type secretConn struct {
+	skydb.Conn
+	secrets map[string]skydb.Secret
+}
+
+func (c *secretConn) GetSecret(name string) (*skydb.Secret, error) {
+	s, ok := c.secrets[name]
+	if !ok {
+		return nil, skydb.ErrSecretNotFound
+	}
+	return &s, nil
+}
+
+func (c *secretConn) GetSecrets() ([]skydb.Secret, error) {
+	secrets := []skydb.Secret{}
+	for _, s := range c.secrets {
+		secrets = append(secrets, s)
+	}
+	return secrets, nil
+}
+
+func (c *secretConn) SetSecret(s *skydb.Secret) error {
+	c.secrets[s.Name] = *s
+	return nil
+}
+
+func (c *secretConn) DeleteSecret(name string) error {
+	if _, ok := c.secrets[name]; !ok {
+		return skydb.ErrSecretNotFound
+	}
+	delete(c.secrets, name)
+	return nil
+}
+
+func (c *secretConn) Close() error {
+	return nil
+}
+
+func TestSecretHandlers(t *testing.T) {
+	Convey("Secret handlers", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := &secretConn{secrets: map[string]skydb.Secret{}}
+		keyring, err := secret.NewKeyring("master", map[string][]byte{
+			"master": bytes.Repeat([]byte("m"), secret.KeySize),
+		})
+		So(err, ShouldBeNil)
+
+		Convey("sets secret sealed", func() {
+			handler := &SecretSetHandler{Sealer: keyring}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name":  "gcm_api_key",
+					"value": "GCM_API_KEY",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, secretResult{
+				Name:      "gcm_api_key",
+				KeyID:     "master",
+				UpdatedAt: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			})
+			So(string(conn.secrets["gcm_api_key"].Sealed), ShouldNotContainSubstring, "GCM_API_KEY")
+		})
+
+		Convey("reloads secrets after setting secret", func() {
+			resolver := &secret.Resolver{
+				Sealer: keyring,
+				ConnOpener: func() (skydb.Conn, error) {
+					return conn, nil
+				},
+				Names: []string{"gcm_api_key"},
+			}
+			So(resolver.Reload(), ShouldBeNil)
+			changed := map[string]string{}
+			resolver.OnChange(func(values map[string]string) {
+				changed = values
+			})
+
+			handler := &SecretSetHandler{Sealer: keyring, SecretResolver: resolver}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name":  "gcm_api_key",
+					"value": "GCM_API_KEY",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(changed, ShouldResemble, map[string]string{
+				"gcm_api_key": "GCM_API_KEY",
+			})
+
+			deleteHandler := &SecretDeleteHandler{SecretResolver: resolver}
+			req = router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name": "gcm_api_key",
+				},
+			}
+			resp = router.Response{}
+			deleteHandler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(changed, ShouldResemble, map[string]string{})
+		})
+
+		Convey("rejects setting secret without secret key", func() {
+			openOnly, err := secret.NewKeyring("", map[string][]byte{
+				"master": bytes.Repeat([]byte("m"), secret.KeySize),
+			})
+			So(err, ShouldBeNil)
+
+			handler := &SecretSetHandler{Sealer: openOnly}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name":  "gcm_api_key",
+					"value": "GCM_API_KEY",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.NotSupported)
+			So(conn.secrets, ShouldBeEmpty)
+		})
+
+		Convey("rejects empty secret value", func() {
+			handler := &SecretSetHandler{Sealer: keyring}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name": "gcm_api_key",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("gets secret", func() {
+			So(secret.Set(conn, keyring, "smtp_password", "SMTP_PASSWORD", timeNow()), ShouldBeNil)
+
+			handler := &SecretGetHandler{Sealer: keyring}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name": "smtp_password",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, map[string]interface{}{
+				"name":  "smtp_password",
+				"value": "SMTP_PASSWORD",
+			})
+		})
+
+		Convey("returns not found for non-existent secret", func() {
+			handler := &SecretGetHandler{Sealer: keyring}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name": "smtp_password",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.ResourceNotFound)
+		})
+
+		Convey("lists secrets without values", func() {
+			So(secret.Set(conn, keyring, "smtp_password", "SMTP_PASSWORD", timeNow()), ShouldBeNil)
+
+			handler := &SecretListHandler{}
+			req := router.Payload{
+				DBConn: conn,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, map[string]interface{}{
+				"secrets": []secretResult{{
+					Name:      "smtp_password",
+					KeyID:     "master",
+					UpdatedAt: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+				}},
+			})
+		})
+
+		Convey("deletes secret", func() {
+			So(secret.Set(conn, keyring, "smtp_password", "SMTP_PASSWORD", timeNow()), ShouldBeNil)
+
+			handler := &SecretDeleteHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"name": "smtp_password",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(conn.secrets, ShouldBeEmpty)
+		})
+
+		Convey("rotates secrets to new key", func() {
+			So(secret.Set(conn, keyring, "smtp_password", "SMTP_PASSWORD", timeNow()), ShouldBeNil)
+
+			newKeyring, err := secret.NewKeyring("new", map[string][]byte{
+				"master": bytes.Repeat([]byte("m"), secret.KeySize),
+				"new":    bytes.Repeat([]byte("n"), secret.KeySize),
+			})
+			So(err, ShouldBeNil)
+
+			handler := &SecretRotateHandler{Sealer: newKeyring}
+			req := router.Payload{
+				DBConn: conn,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, map[string]interface{}{
+				"key_id":  "new",
+				"rotated": 1,
+			})
+			So(conn.secrets["smtp_password"].KeyID, ShouldEqual, "new")
+		})
+	})
+}
@@ -0,0 +1,713 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/audit"
+	"github.com/skygeario/skygear-server/pkg/server/authtoken"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// serviceAccountAssertionMaxLifetime is the maximum lifetime of an
+// assertion signed by a service account, to limit the window for replay.
+const serviceAccountAssertionMaxLifetime = 5 * time.Minute
+
+var errServiceAccountInvalidCredentials = skyerr.NewError(skyerr.InvalidCredentials, "invalid service account credentials")
+
+type serviceAccountResult struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Roles      []string   `json:"roles"`
+	HasSecret  bool       `json:"has_secret"`
+	PublicKey  string     `json:"public_key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// newServiceAccountResult returns the service account without its
+// hashed secret.
+func newServiceAccountResult(account skydb.ServiceAccount, roles []string) serviceAccountResult {
+	if roles == nil {
+		roles = []string{}
+	}
+	return serviceAccountResult{
+		ID:         account.ID,
+		Name:       account.Name,
+		Roles:      roles,
+		HasSecret:  len(account.HashedSecret) > 0,
+		PublicKey:  account.PublicKey,
+		CreatedAt:  account.CreatedAt,
+		LastUsedAt: account.LastUsedAt,
+	}
+}
+
+// generateClientSecret returns a random client secret of 256 bits.
+func generateClientSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// validateServiceAccountPublicKey returns an error if the key is not a
+// PEM encoded RSA or ECDSA public key.
+func validateServiceAccountPublicKey(key string) skyerr.Error {
+	if _, err := jwt.ParseRSAPublicKeyFromPEM([]byte(key)); err == nil {
+		return nil
+	}
+	if _, err := jwt.ParseECPublicKeyFromPEM([]byte(key)); err == nil {
+		return nil
+	}
+	return skyerr.NewInvalidArgument("public_key must be a PEM encoded RSA or ECDSA public key", []string{"public_key"})
+}
+
+// setServiceAccountCredentials replaces the credentials of the service
+// account. A client secret is generated and returned if public key is
+// empty.
+func setServiceAccountCredentials(account *skydb.ServiceAccount, publicKey string) string {
+	account.PublicKey = publicKey
+	if publicKey != "" {
+		account.HashedSecret = nil
+		return ""
+	}
+
+	secret := generateClientSecret()
+	account.SetSecret(secret)
+	return secret
+}
+
+type serviceAccountCreatePayload struct {
+	Name      string   `mapstructure:"name"`
+	Roles     []string `mapstructure:"roles"`
+	PublicKey string   `mapstructure:"public_key"`
+}
+
+func (payload *serviceAccountCreatePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *serviceAccountCreatePayload) Validate() skyerr.Error {
+	if payload.Name == "" {
+		return skyerr.NewInvalidArgument("empty service account name", []string{"name"})
+	}
+	for _, role := range payload.Roles {
+		if role == "" {
+			return skyerr.NewInvalidArgument("roles must not contain empty role", []string{"roles"})
+		}
+	}
+	if payload.PublicKey != "" {
+		return validateServiceAccountPublicKey(payload.PublicKey)
+	}
+	return nil
+}
+
+/*
+ServiceAccountCreateHandler creates a service account for backend jobs
+to access the server without the master key.
+
+A service account is assigned roles and evaluated by access control like
+a user. It obtains an access token with auth:service_account:token, by
+either the client secret or an assertion signed by its private key.
+
+If public_key is not specified, a client secret is generated and returned
+in client_secret. The client secret is not returned again.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "service_account:create",
+    "master_key": "MASTER_KEY",
+    "name": "report-job",
+    "roles": ["reporter"]
+}
+EOF
+
+{
+    "result": {
+        "id": "SERVICE_ACCOUNT_ID",
+        "name": "report-job",
+        "roles": ["reporter"],
+        "has_secret": true,
+        "created_at": "2017-01-01T00:00:00Z",
+        "client_secret": "CLIENT_SECRET"
+    }
+}
+*/
+type ServiceAccountCreateHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *ServiceAccountCreateHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *ServiceAccountCreateHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ServiceAccountCreateHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &serviceAccountCreatePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	info := skydb.AuthInfo{
+		ID:    uuidNew(),
+		Roles: payload.Roles,
+	}
+	account := skydb.ServiceAccount{
+		ID:        info.ID,
+		Name:      payload.Name,
+		CreatedAt: timeNow().UTC(),
+	}
+	secret := setServiceAccountCredentials(&account, payload.PublicKey)
+
+	create := func() error {
+		if err := rpayload.DBConn.CreateAuth(&info); err != nil {
+			return err
+		}
+		return rpayload.DBConn.CreateServiceAccount(&account)
+	}
+
+	var err error
+	if txDB, ok := rpayload.DBConn.PublicDB().(skydb.Transactional); ok {
+		err = skydb.WithTransaction(txDB, create)
+	} else {
+		err = create()
+	}
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	result := map[string]interface{}{}
+	mergeServiceAccountResult(result, newServiceAccountResult(account, info.Roles))
+	if secret != "" {
+		result["client_secret"] = secret
+	}
+	response.Result = result
+}
+
+// mergeServiceAccountResult copies the fields of the service account
+// result into the map, for results that carry a client secret.
+func mergeServiceAccountResult(m map[string]interface{}, result serviceAccountResult) {
+	m["id"] = result.ID
+	m["name"] = result.Name
+	m["roles"] = result.Roles
+	m["has_secret"] = result.HasSecret
+	if result.PublicKey != "" {
+		m["public_key"] = result.PublicKey
+	}
+	m["created_at"] = result.CreatedAt
+	if result.LastUsedAt != nil {
+		m["last_used_at"] = result.LastUsedAt
+	}
+}
+
+/*
+ServiceAccountListHandler returns the service accounts with their roles.
+Client secrets of the service accounts are not returned.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "service_account:list",
+    "master_key": "MASTER_KEY"
+}
+EOF
+
+{
+    "result": {
+        "service_accounts": [{
+            "id": "SERVICE_ACCOUNT_ID",
+            "name": "report-job",
+            "roles": ["reporter"],
+            "has_secret": true,
+            "created_at": "2017-01-01T00:00:00Z",
+            "last_used_at": "2017-01-02T00:00:00Z"
+        }]
+    }
+}
+*/
+type ServiceAccountListHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *ServiceAccountListHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *ServiceAccountListHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ServiceAccountListHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	accounts, err := rpayload.DBConn.GetServiceAccounts()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	ids := make([]string, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+	roles := map[string][]string{}
+	if len(ids) > 0 {
+		if roles, err = rpayload.DBConn.GetRoles(ids); err != nil {
+			response.Err = skyerr.MakeError(err)
+			return
+		}
+	}
+
+	results := []serviceAccountResult{}
+	for _, account := range accounts {
+		results = append(results, newServiceAccountResult(account, roles[account.ID]))
+	}
+	response.Result = map[string]interface{}{
+		"service_accounts": results,
+	}
+}
+
+type serviceAccountIDPayload struct {
+	ID        string `mapstructure:"id"`
+	PublicKey string `mapstructure:"public_key"`
+}
+
+func (payload *serviceAccountIDPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *serviceAccountIDPayload) Validate() skyerr.Error {
+	if payload.ID == "" {
+		return skyerr.NewInvalidArgument("empty service account id", []string{"id"})
+	}
+	if payload.PublicKey != "" {
+		return validateServiceAccountPublicKey(payload.PublicKey)
+	}
+	return nil
+}
+
+// revokeServiceAccountTokens invalidates the access tokens issued to the
+// service account before now.
+func revokeServiceAccountTokens(conn skydb.Conn, id string) error {
+	info := skydb.AuthInfo{}
+	if err := conn.GetAuth(id, &info); err != nil {
+		return err
+	}
+	now := timeNow().UTC()
+	info.TokenValidSince = &now
+	return conn.UpdateAuth(&info)
+}
+
+/*
+ServiceAccountRotateHandler replaces the credentials of a service account.
+
+If public_key is specified, assertions are verified by the new public key
+and the client secret is removed. Otherwise a new client secret is
+generated and returned in client_secret. Access tokens issued with the
+previous credentials are revoked.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "service_account:rotate",
+    "master_key": "MASTER_KEY",
+    "id": "SERVICE_ACCOUNT_ID"
+}
+EOF
+
+{
+    "result": {
+        "id": "SERVICE_ACCOUNT_ID",
+        "name": "report-job",
+        "roles": ["reporter"],
+        "has_secret": true,
+        "created_at": "2017-01-01T00:00:00Z",
+        "client_secret": "NEW_CLIENT_SECRET"
+    }
+}
+*/
+type ServiceAccountRotateHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *ServiceAccountRotateHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *ServiceAccountRotateHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ServiceAccountRotateHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &serviceAccountIDPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	conn := rpayload.DBConn
+	account, err := conn.GetServiceAccount(payload.ID)
+	if err == skydb.ErrServiceAccountNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "service account not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	secret := setServiceAccountCredentials(account, payload.PublicKey)
+	if err := conn.UpdateServiceAccount(account); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	if err := revokeServiceAccountTokens(conn, account.ID); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	roles, err := conn.GetRoles([]string{account.ID})
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	result := map[string]interface{}{}
+	mergeServiceAccountResult(result, newServiceAccountResult(*account, roles[account.ID]))
+	if secret != "" {
+		result["client_secret"] = secret
+	}
+	response.Result = result
+}
+
+/*
+ServiceAccountDeleteHandler deletes a service account and revokes its
+access tokens. The records owned by the service account are kept.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "service_account:delete",
+    "master_key": "MASTER_KEY",
+    "id": "SERVICE_ACCOUNT_ID"
+}
+EOF
+
+{
+    "result": {
+        "id": "SERVICE_ACCOUNT_ID"
+    }
+}
+*/
+type ServiceAccountDeleteHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *ServiceAccountDeleteHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *ServiceAccountDeleteHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ServiceAccountDeleteHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &serviceAccountIDPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	conn := rpayload.DBConn
+	err := conn.DeleteServiceAccount(payload.ID)
+	if err == skydb.ErrServiceAccountNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "service account not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	if err := revokeServiceAccountTokens(conn, payload.ID); err != nil && err != skydb.ErrUserNotFound {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"id": payload.ID,
+	}
+}
+
+type serviceAccountTokenPayload struct {
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	Assertion    string `mapstructure:"assertion"`
+}
+
+func (payload *serviceAccountTokenPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *serviceAccountTokenPayload) Validate() skyerr.Error {
+	if payload.ClientID == "" {
+		return skyerr.NewInvalidArgument("empty client id", []string{"client_id"})
+	}
+	if (payload.ClientSecret == "") == (payload.Assertion == "") {
+		return skyerr.NewInvalidArgument("either client_secret or assertion must be specified", []string{"client_secret", "assertion"})
+	}
+	return nil
+}
+
+// verifyServiceAccountAssertion verifies that the assertion is a JWT
+// signed by the private key of the service account, issued by and about
+// the service account, and expires within the maximum lifetime.
+func verifyServiceAccountAssertion(account *skydb.ServiceAccount, assertion string) error {
+	if account.PublicKey == "" {
+		return fmt.Errorf("service account has no public key")
+	}
+
+	claims := jwt.StandardClaims{}
+	_, err := jwt.ParseWithClaims(assertion, &claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA:
+			return jwt.ParseRSAPublicKeyFromPEM([]byte(account.PublicKey))
+		case *jwt.SigningMethodECDSA:
+			return jwt.ParseECPublicKeyFromPEM([]byte(account.PublicKey))
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if claims.Issuer != account.ID || claims.Subject != account.ID {
+		return fmt.Errorf("assertion is not issued by the service account")
+	}
+	if claims.ExpiresAt == 0 {
+		return fmt.Errorf("assertion has no expiry")
+	}
+	// exp is checked against the wall clock by jwt-go as well
+	if time.Unix(claims.ExpiresAt, 0).After(time.Now().Add(serviceAccountAssertionMaxLifetime)) {
+		return fmt.Errorf("assertion expires too late")
+	}
+	return nil
+}
+
+/*
+ServiceAccountTokenHandler issues an access token to a service account.
+
+The service account authenticates with either its client secret, or an
+assertion: a JWT signed by its private key with RS256 or ES256 (or the
+384 and 512 variants), with both iss and sub set to the client ID and exp
+no later than 5 minutes from now.
+
+The access token is used like the access token of a user, and is revoked
+when the credentials are rotated or the service account is deleted.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "auth:service_account:token",
+    "api_key": "API_KEY",
+    "client_id": "SERVICE_ACCOUNT_ID",
+    "client_secret": "CLIENT_SECRET"
+}
+EOF
+
+{
+    "result": {
+        "user_id": "SERVICE_ACCOUNT_ID",
+        "access_token": "ACCESS_TOKEN",
+        "roles": ["reporter"]
+    }
+}
+*/
+type ServiceAccountTokenHandler struct {
+	TokenStore    authtoken.Store  `inject:"TokenStore"`
+	AuditStream   *audit.Stream    `inject:"AuditStream"`
+	AccessKey     router.Processor `preprocessor:"accesskey"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *ServiceAccountTokenHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.AccessKey,
+		h.DBConn,
+		h.PluginReady,
+	}
+}
+
+func (h *ServiceAccountTokenHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *ServiceAccountTokenHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &serviceAccountTokenPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if h.TokenStore == nil {
+		panic("token store is nil")
+	}
+
+	conn := rpayload.DBConn
+	account, skyErr := h.authenticate(conn, payload)
+	if skyErr != nil {
+		h.AuditStream.Emit(rpayload.Req, audit.Event{
+			Type:   audit.LoginFailed,
+			UserID: payload.ClientID,
+			Data: map[string]interface{}{
+				"service_account": true,
+				"reason":          skyErr.Name(),
+			},
+		})
+		response.Err = skyErr
+		return
+	}
+
+	info := skydb.AuthInfo{}
+	if err := conn.GetAuth(account.ID, &info); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	token, err := h.TokenStore.NewToken(rpayload.AppName, account.ID)
+	if err != nil {
+		panic(err)
+	}
+	if err = h.TokenStore.Put(&token); err != nil {
+		panic(err)
+	}
+
+	now := timeNow().UTC()
+	account.LastUsedAt = &now
+	if err := conn.UpdateServiceAccount(account); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	h.AuditStream.Emit(rpayload.Req, audit.Event{
+		Type:   audit.TokenIssued,
+		UserID: account.ID,
+		Data: map[string]interface{}{
+			"service_account": true,
+		},
+	})
+
+	roles := info.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	response.Result = map[string]interface{}{
+		"user_id":      account.ID,
+		"access_token": token.AccessToken,
+		"roles":        roles,
+	}
+}
+
+func (h *ServiceAccountTokenHandler) authenticate(conn skydb.Conn, payload *serviceAccountTokenPayload) (*skydb.ServiceAccount, skyerr.Error) {
+	account, err := conn.GetServiceAccount(payload.ClientID)
+	if err == skydb.ErrServiceAccountNotFound {
+		return nil, errServiceAccountInvalidCredentials
+	} else if err != nil {
+		return nil, skyerr.MakeError(err)
+	}
+
+	if payload.Assertion != "" {
+		if err := verifyServiceAccountAssertion(account, payload.Assertion); err != nil {
+			return nil, errServiceAccountInvalidCredentials
+		}
+	} else if !account.IsSameSecret(payload.ClientSecret) {
+		return nil, errServiceAccountInvalidCredentials
+	}
+	return account, nil
+}
@@ -0,0 +1,329 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/skygeario/skygear-server/pkg/server/authtoken/authtokentest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type serviceAccountConn struct {
+	*skydbtest.MapConn
+	accounts map[string]skydb.ServiceAccount
+}
+
+func (c *serviceAccountConn) CreateServiceAccount(account *skydb.ServiceAccount) error {
+	c.accounts[account.ID] = *account
+	return nil
+}
+
+func (c *serviceAccountConn) GetServiceAccount(id string) (*skydb.ServiceAccount, error) {
+	account, ok := c.accounts[id]
+	if !ok {
+		return nil, skydb.ErrServiceAccountNotFound
+	}
+	return &account, nil
+}
+
+func (c *serviceAccountConn) GetServiceAccounts() ([]skydb.ServiceAccount, error) {
+	accounts := []skydb.ServiceAccount{}
+	for _, account := range c.accounts {
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func (c *serviceAccountConn) UpdateServiceAccount(account *skydb.ServiceAccount) error {
+	if _, ok := c.accounts[account.ID]; !ok {
+		return skydb.ErrServiceAccountNotFound
+	}
+	c.accounts[account.ID] = *account
+	return nil
+}
+
+func (c *serviceAccountConn) DeleteServiceAccount(id string) error {
+	if _, ok := c.accounts[id]; !ok {
+		return skydb.ErrServiceAccountNotFound
+	}
+	delete(c.accounts, id)
+	return nil
+}
+
+func (c *serviceAccountConn) GetRoles(userIDs []string) (map[string][]string, error) {
+	roles := map[string][]string{}
+	for _, id := range userIDs {
+		if info, ok := c.UserMap[id]; ok {
+			roles[id] = info.Roles
+		}
+	}
+	return roles, nil
+}
+
+func signServiceAccountAssertion(key *ecdsa.PrivateKey, claims jwt.StandardClaims) string {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func TestServiceAccountHandlers(t *testing.T) {
+	Convey("Service account handlers", t, func() {
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		realTime := timeNow
+		timeNow = func() time.Time { return now }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := &serviceAccountConn{
+			MapConn:  skydbtest.NewMapConn(),
+			accounts: map[string]skydb.ServiceAccount{},
+		}
+
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		So(err, ShouldBeNil)
+		der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+		So(err, ShouldBeNil)
+		publicKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+		create := func(data map[string]interface{}) router.Response {
+			req := router.Payload{
+				DBConn: conn,
+				Data:   data,
+			}
+			resp := router.Response{}
+			(&ServiceAccountCreateHandler{}).Handle(&req, &resp)
+			return resp
+		}
+
+		issueToken := func(data map[string]interface{}) (router.Response, *authtokentest.SingleTokenStore) {
+			tokenStore := &authtokentest.SingleTokenStore{}
+			req := router.Payload{
+				AppName: "app",
+				DBConn:  conn,
+				Data:    data,
+			}
+			resp := router.Response{}
+			(&ServiceAccountTokenHandler{TokenStore: tokenStore}).Handle(&req, &resp)
+			return resp, tokenStore
+		}
+
+		Convey("creates service account with client secret", func() {
+			resp := create(map[string]interface{}{
+				"name":  "report-job",
+				"roles": []interface{}{"reporter"},
+			})
+
+			So(resp.Err, ShouldBeNil)
+			result := resp.Result.(map[string]interface{})
+			id := result["id"].(string)
+			secret := result["client_secret"].(string)
+			So(secret, ShouldNotBeEmpty)
+			So(result["has_secret"], ShouldBeTrue)
+			So(result["roles"], ShouldResemble, []string{"reporter"})
+			So(conn.UserMap[id].Roles, ShouldResemble, []string{"reporter"})
+			account := conn.accounts[id]
+			So(account.IsSameSecret(secret), ShouldBeTrue)
+
+			Convey("issues token with client secret", func() {
+				resp, tokenStore := issueToken(map[string]interface{}{
+					"client_id":     id,
+					"client_secret": secret,
+				})
+
+				So(resp.Err, ShouldBeNil)
+				result := resp.Result.(map[string]interface{})
+				So(result["user_id"], ShouldEqual, id)
+				So(result["access_token"], ShouldEqual, tokenStore.Token.AccessToken)
+				So(result["roles"], ShouldResemble, []string{"reporter"})
+				So(tokenStore.Token.AuthInfoID, ShouldEqual, id)
+				So(*conn.accounts[id].LastUsedAt, ShouldResemble, now)
+			})
+
+			Convey("rejects wrong client secret", func() {
+				resp, tokenStore := issueToken(map[string]interface{}{
+					"client_id":     id,
+					"client_secret": "wrong",
+				})
+
+				So(resp.Err.Code(), ShouldEqual, skyerr.InvalidCredentials)
+				So(tokenStore.Token, ShouldBeNil)
+			})
+
+			Convey("rotates client secret and revokes tokens", func() {
+				req := router.Payload{
+					DBConn: conn,
+					Data:   map[string]interface{}{"id": id},
+				}
+				resp := router.Response{}
+				(&ServiceAccountRotateHandler{}).Handle(&req, &resp)
+
+				So(resp.Err, ShouldBeNil)
+				newSecret := resp.Result.(map[string]interface{})["client_secret"].(string)
+				So(newSecret, ShouldNotEqual, secret)
+				rotated := conn.accounts[id]
+				So(rotated.IsSameSecret(secret), ShouldBeFalse)
+				So(rotated.IsSameSecret(newSecret), ShouldBeTrue)
+				So(*conn.UserMap[id].TokenValidSince, ShouldResemble, now)
+			})
+
+			Convey("lists service accounts without secrets", func() {
+				req := router.Payload{DBConn: conn}
+				resp := router.Response{}
+				(&ServiceAccountListHandler{}).Handle(&req, &resp)
+
+				So(resp.Err, ShouldBeNil)
+				results := resp.Result.(map[string]interface{})["service_accounts"].([]serviceAccountResult)
+				So(results, ShouldResemble, []serviceAccountResult{{
+					ID:        id,
+					Name:      "report-job",
+					Roles:     []string{"reporter"},
+					HasSecret: true,
+					CreatedAt: now,
+				}})
+			})
+
+			Convey("deletes service account and keeps auth info", func() {
+				req := router.Payload{
+					DBConn: conn,
+					Data:   map[string]interface{}{"id": id},
+				}
+				resp := router.Response{}
+				(&ServiceAccountDeleteHandler{}).Handle(&req, &resp)
+
+				So(resp.Err, ShouldBeNil)
+				So(conn.accounts, ShouldBeEmpty)
+				So(*conn.UserMap[id].TokenValidSince, ShouldResemble, now)
+
+				resp, _ = issueToken(map[string]interface{}{
+					"client_id":     id,
+					"client_secret": secret,
+				})
+				So(resp.Err.Code(), ShouldEqual, skyerr.InvalidCredentials)
+			})
+		})
+
+		Convey("creates service account with public key", func() {
+			resp := create(map[string]interface{}{
+				"name":       "sync-job",
+				"public_key": publicKey,
+			})
+
+			So(resp.Err, ShouldBeNil)
+			result := resp.Result.(map[string]interface{})
+			id := result["id"].(string)
+			So(result, ShouldNotContainKey, "client_secret")
+			So(result["has_secret"], ShouldBeFalse)
+
+			Convey("issues token with signed assertion", func() {
+				resp, tokenStore := issueToken(map[string]interface{}{
+					"client_id": id,
+					"assertion": signServiceAccountAssertion(privateKey, jwt.StandardClaims{
+						Issuer:    id,
+						Subject:   id,
+						ExpiresAt: time.Now().Add(time.Minute).Unix(),
+					}),
+				})
+
+				So(resp.Err, ShouldBeNil)
+				So(tokenStore.Token.AuthInfoID, ShouldEqual, id)
+			})
+
+			Convey("rejects assertion of another subject", func() {
+				resp, _ := issueToken(map[string]interface{}{
+					"client_id": id,
+					"assertion": signServiceAccountAssertion(privateKey, jwt.StandardClaims{
+						Issuer:    id,
+						Subject:   "someone-else",
+						ExpiresAt: time.Now().Add(time.Minute).Unix(),
+					}),
+				})
+
+				So(resp.Err.Code(), ShouldEqual, skyerr.InvalidCredentials)
+			})
+
+			Convey("rejects assertion without expiry", func() {
+				resp, _ := issueToken(map[string]interface{}{
+					"client_id": id,
+					"assertion": signServiceAccountAssertion(privateKey, jwt.StandardClaims{
+						Issuer:  id,
+						Subject: id,
+					}),
+				})
+
+				So(resp.Err.Code(), ShouldEqual, skyerr.InvalidCredentials)
+			})
+
+			Convey("rejects assertion signed by another key", func() {
+				otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				So(err, ShouldBeNil)
+				resp, _ := issueToken(map[string]interface{}{
+					"client_id": id,
+					"assertion": signServiceAccountAssertion(otherKey, jwt.StandardClaims{
+						Issuer:    id,
+						Subject:   id,
+						ExpiresAt: time.Now().Add(time.Minute).Unix(),
+					}),
+				})
+
+				So(resp.Err.Code(), ShouldEqual, skyerr.InvalidCredentials)
+			})
+
+			Convey("rejects client secret", func() {
+				resp, _ := issueToken(map[string]interface{}{
+					"client_id":     id,
+					"client_secret": "anything",
+				})
+
+				So(resp.Err.Code(), ShouldEqual, skyerr.InvalidCredentials)
+			})
+		})
+
+		Convey("rejects invalid public key", func() {
+			resp := create(map[string]interface{}{
+				"name":       "sync-job",
+				"public_key": "not a key",
+			})
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(conn.accounts, ShouldBeEmpty)
+		})
+
+		Convey("rejects token request with both secret and assertion", func() {
+			resp, _ := issueToken(map[string]interface{}{
+				"client_id":     "id",
+				"client_secret": "secret",
+				"assertion":     "assertion",
+			})
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+	})
+}
@@ -0,0 +1,283 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	skyAsset "github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/snapshot"
+	"github.com/skygeario/skygear-server/pkg/server/tenant"
+)
+
+type recordSnapshotPayload struct {
+	RecordType string `mapstructure:"record_type"`
+}
+
+func (payload *recordSnapshotPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *recordSnapshotPayload) Validate() skyerr.Error {
+	if payload.RecordType == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"record_type"})
+	}
+	if strings.HasPrefix(payload.RecordType, "_") {
+		return skyerr.NewInvalidArgument("attempts to snapshot reserved table", []string{"record_type"})
+	}
+	return nil
+}
+
+/*
+RecordSnapshotHandler takes a snapshot of the records, schema and indexes
+of a record type, and saves it to the asset store as a JSON file. The
+soft-deleted records are included. Assets referenced by the records are
+not part of the snapshot. Master key is required.
+
+The snapshot can be restored by record:snapshot:restore, to the same app
+or to another app after uploading the file to its asset store.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:snapshot",
+    "master_key": "MASTER_KEY",
+    "record_type": "note"
+}
+EOF
+
+{
+    "result": {
+        "record_type": "note",
+        "record_count": 2,
+        "asset": {
+            "$type": "asset",
+            "$name": "c4f2a7e0-6fa4-4f0d-9a59-0b4a8a4e1b1a-note-snapshot.json",
+            "$content_type": "application/json",
+            "$url": "http://localhost:3000/files/c4f2a7e0-6fa4-4f0d-9a59-0b4a8a4e1b1a-note-snapshot.json"
+        }
+    }
+}
+*/
+type RecordSnapshotHandler struct {
+	AssetStore       skyAsset.Store   `inject:"AssetStore"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	InjectDB         router.Processor `preprocessor:"inject_db"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	PluginReady      router.Processor `preprocessor:"plugin_ready"`
+	preprocessors    []router.Processor
+}
+
+func (h *RecordSnapshotHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectDB,
+		h.RequireMasterKey,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordSnapshotHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordSnapshotHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &recordSnapshotPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	schemas, err := rpayload.Database.GetRecordSchemas()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	if _, ok := schemas[payload.RecordType]; !ok {
+		response.Err = skyerr.NewErrorf(skyerr.ResourceNotFound, "record type %s does not exist", payload.RecordType)
+		return
+	}
+
+	s, err := snapshot.Take(rpayload.Database, payload.RecordType)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	asset := skydb.Asset{
+		Name:        tenant.AssetName(rpayload.Tenant, fmt.Sprintf("%s-%s-snapshot.json", uuidNew(), payload.RecordType)),
+		ContentType: "application/json",
+		Size:        int64(len(data)),
+	}
+	if err := h.AssetStore.PutFileReader(asset.Name, bytes.NewReader(data), asset.Size, asset.ContentType); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	if err := rpayload.DBConn.SaveAsset(&asset); err != nil {
+		response.Err = skyerr.NewResourceSaveFailureErrWithStringID("asset", asset.Name)
+		return
+	}
+
+	if signer, ok := h.AssetStore.(skyAsset.URLSigner); ok {
+		asset.Signer = signer
+	} else {
+		log.Warnf("Failed to acquire asset URLSigner, please check configuration")
+		response.Err = skyerr.NewError(skyerr.UnexpectedError, "Failed to sign the url")
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"record_type":  s.RecordType,
+		"record_count": len(s.Records),
+		"asset":        skyconv.ToMap((*skyconv.MapAsset)(&asset)),
+	}
+}
+
+type recordSnapshotRestorePayload struct {
+	Asset string `mapstructure:"asset"`
+}
+
+func (payload *recordSnapshotRestorePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *recordSnapshotRestorePayload) Validate() skyerr.Error {
+	if payload.Asset == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"asset"})
+	}
+	return nil
+}
+
+/*
+RecordSnapshotRestoreHandler restores a snapshot taken by record:snapshot
+from the asset store. The schema of the record type is extended with the
+fields of the snapshot, missing indexes are created, and the records of
+the snapshot are saved, replacing the records of the same ID. Records
+created after the snapshot is taken are left unchanged. Master key is
+required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:snapshot:restore",
+    "master_key": "MASTER_KEY",
+    "asset": "c4f2a7e0-6fa4-4f0d-9a59-0b4a8a4e1b1a-note-snapshot.json"
+}
+EOF
+
+{
+    "result": {
+        "record_type": "note",
+        "record_count": 2
+    }
+}
+*/
+type RecordSnapshotRestoreHandler struct {
+	AssetStore       skyAsset.Store   `inject:"AssetStore"`
+	Authenticator    router.Processor `preprocessor:"authenticator"`
+	DBConn           router.Processor `preprocessor:"dbconn"`
+	Maintenance      router.Processor `preprocessor:"maintenance"`
+	InjectDB         router.Processor `preprocessor:"inject_db"`
+	RequireMasterKey router.Processor `preprocessor:"require_master_key"`
+	PluginReady      router.Processor `preprocessor:"plugin_ready"`
+	preprocessors    []router.Processor
+}
+
+func (h *RecordSnapshotRestoreHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectDB,
+		h.RequireMasterKey,
+		h.PluginReady,
+	}
+}
+
+func (h *RecordSnapshotRestoreHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordSnapshotRestoreHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &recordSnapshotRestorePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if !tenant.OwnsAsset(rpayload.Tenant, payload.Asset) {
+		response.Err = skyerr.NewErrorf(skyerr.ResourceNotFound, "asset %s cannot be read", payload.Asset)
+		return
+	}
+
+	reader, err := h.AssetStore.GetFileReader(payload.Asset)
+	if err != nil {
+		response.Err = skyerr.NewErrorf(skyerr.ResourceNotFound, "asset %s cannot be read", payload.Asset)
+		return
+	}
+	defer reader.Close()
+
+	s := snapshot.Snapshot{}
+	if err := json.NewDecoder(reader).Decode(&s); err != nil || s.RecordType == "" {
+		response.Err = skyerr.NewInvalidArgument("asset is not a valid snapshot", []string{"asset"})
+		return
+	}
+	if strings.HasPrefix(s.RecordType, "_") {
+		response.Err = skyerr.NewInvalidArgument("attempts to restore reserved table", []string{"asset"})
+		return
+	}
+
+	db := rpayload.Database
+	restore := func() error {
+		return snapshot.Restore(db, &s)
+	}
+	if txDB, ok := db.(skydb.Transactional); ok {
+		err = skydb.WithTransaction(txDB, restore)
+	} else {
+		err = restore()
+	}
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"record_type":  s.RecordType,
+		"record_count": len(s.Records),
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type snapshotDB struct {
+	*indexDB
+}
+
+func (db *snapshotDB) Query(query *skydb.Query) (*skydb.Rows, error) {
+	records := []skydb.Record{}
+	for _, record := range db.RecordMap {
+		if record.ID.Type == query.Type {
+			records = append(records, record)
+		}
+	}
+	return skydb.NewRows(skydb.NewMemoryRows(records)), nil
+}
+
+type snapshotConn struct {
+	skydb.Conn
+	assets []skydb.Asset
+}
+
+func (conn *snapshotConn) SaveAsset(asset *skydb.Asset) error {
+	conn.assets = append(conn.assets, *asset)
+	return nil
+}
+
+func TestRecordSnapshotHandlers(t *testing.T) {
+	Convey("Record snapshot handlers", t, func() {
+		realUUIDNew := uuidNew
+		uuidNew = func() string { return "snapshot-uuid" }
+		defer func() {
+			uuidNew = realUUIDNew
+		}()
+
+		newSnapshotDB := func() *snapshotDB {
+			return &snapshotDB{&indexDB{
+				MapDB:   skydbtest.NewMapDB(),
+				indexes: map[string]skydb.RecordIndex{},
+			}}
+		}
+		source := newSnapshotDB()
+		source.Extend("note", skydb.RecordSchema{
+			"title": skydb.FieldType{Type: skydb.TypeString},
+		})
+		source.indexes["note_title_idx"] = skydb.RecordIndex{
+			Fields: []string{"title"},
+			Method: skydb.BTreeIndex,
+		}
+		So(source.Save(&skydb.Record{
+			ID:        skydb.NewRecordID("note", "1"),
+			OwnerID:   "alice",
+			CreatedAt: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			Data: map[string]interface{}{
+				"title": "Hello",
+			},
+		}), ShouldBeNil)
+
+		conn := &snapshotConn{}
+		store := newBufferedStore()
+		newRouter := func(handler router.Handler, db skydb.Database) *handlertest.SingleRouteRouter {
+			return handlertest.NewSingleRouteRouter(handler, func(p *router.Payload) {
+				p.DBConn = conn
+				p.Database = db
+			})
+		}
+
+		Convey("takes snapshot to asset store", func() {
+			r := newRouter(&RecordSnapshotHandler{AssetStore: store}, source)
+			resp := r.POST(`{"record_type": "note"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_type": "note",
+		"record_count": 1,
+		"asset": {
+			"$type": "asset",
+			"$name": "snapshot-uuid-note-snapshot.json",
+			"$content_type": "application/json",
+			"$url": "snapshot-uuid-note-snapshot.json?signedurl=true"
+		}
+	}
+}`)
+			So(store.name, ShouldEqual, "snapshot-uuid-note-snapshot.json")
+			So(store.contentType, ShouldEqual, "application/json")
+			So(conn.assets, ShouldHaveLength, 1)
+
+			Convey("and restores it to another database", func() {
+				target := newSnapshotDB()
+				r := newRouter(&RecordSnapshotRestoreHandler{AssetStore: store}, target)
+				resp := r.POST(`{"asset": "snapshot-uuid-note-snapshot.json"}`)
+				So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"record_type": "note",
+		"record_count": 1
+	}
+}`)
+
+				So(target.RecordSchemaMap["note"], ShouldContainKey, "title")
+				So(target.indexes, ShouldContainKey, "note_title_idx")
+
+				record := skydb.Record{}
+				So(target.Get(skydb.NewRecordID("note", "1"), &record), ShouldBeNil)
+				So(record.OwnerID, ShouldEqual, "alice")
+				So(record.Data["title"], ShouldEqual, "Hello")
+			})
+		})
+
+		Convey("refuses to snapshot non-existent record type", func() {
+			r := newRouter(&RecordSnapshotHandler{AssetStore: store}, source)
+			resp := r.POST(`{"record_type": "article"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "ResourceNotFound",
+		"code": 110,
+		"message": "record type article does not exist"
+	}
+}`)
+		})
+
+		Convey("refuses to restore invalid snapshot", func() {
+			store.buf.WriteString(`{"records": []}`)
+			r := newRouter(&RecordSnapshotRestoreHandler{AssetStore: store}, newSnapshotDB())
+			resp := r.POST(`{"asset": "invalid.json"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "asset is not a valid snapshot",
+		"info": {"arguments": ["asset"]}
+	}
+}`)
+		})
+	})
+}
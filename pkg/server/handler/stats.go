@@ -0,0 +1,157 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/querywatchdog"
+	"github.com/skygeario/skygear-server/pkg/server/recordstats"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type recordStatsPayload struct {
+	Reset bool `mapstructure:"reset"`
+}
+
+func (payload *recordStatsPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return nil
+}
+
+type recordStatsResponse struct {
+	RecordTypes map[string]map[recordstats.Operation]recordstats.Stats `json:"record_types"`
+}
+
+/*
+RecordStatsHandler returns the number of requests and latency percentiles
+of record operations per record type. Admin role or master key is
+required.
+
+Specify `reset` to discard the statistics after they are returned.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "stats:record",
+    "master_key": "MASTER_KEY",
+    "reset": false
+}
+EOF
+*/
+type RecordStatsHandler struct {
+	RecordStats   *recordstats.Collector `inject:"RecordStats"`
+	Authenticator router.Processor       `preprocessor:"authenticator"`
+	DBConn        router.Processor       `preprocessor:"dbconn"`
+	InjectAuth    router.Processor       `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor       `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *RecordStatsHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *RecordStatsHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *RecordStatsHandler) Handle(payload *router.Payload, response *router.Response) {
+	p := &recordStatsPayload{}
+	if err := p.Decode(payload.Data); err != nil {
+		response.Err = err
+		return
+	}
+
+	response.Result = recordStatsResponse{
+		RecordTypes: h.RecordStats.Snapshot(),
+	}
+
+	if p.Reset {
+		h.RecordStats.Reset()
+	}
+}
+
+type queryWatchdogStatsResponse struct {
+	Ceiling    float64                            `json:"ceiling_s"`
+	Violations map[string]querywatchdog.Violation `json:"violations"`
+}
+
+/*
+QueryWatchdogStatsHandler returns the queries cancelled by the query
+watchdog for exceeding the ceiling, keyed by the query shape. Admin role
+or master key is required.
+
+Specify `reset` to discard the violations after they are returned.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "stats:query_watchdog",
+    "master_key": "MASTER_KEY",
+    "reset": false
+}
+EOF
+*/
+type QueryWatchdogStatsHandler struct {
+	QueryWatchdog *querywatchdog.Watchdog `inject:"QueryWatchdog"`
+	Authenticator router.Processor        `preprocessor:"authenticator"`
+	DBConn        router.Processor        `preprocessor:"dbconn"`
+	InjectAuth    router.Processor        `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor        `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *QueryWatchdogStatsHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *QueryWatchdogStatsHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *QueryWatchdogStatsHandler) Handle(payload *router.Payload, response *router.Response) {
+	p := &recordStatsPayload{}
+	if err := p.Decode(payload.Data); err != nil {
+		response.Err = err
+		return
+	}
+
+	var ceiling float64
+	if h.QueryWatchdog != nil {
+		ceiling = h.QueryWatchdog.Ceiling.Seconds()
+	}
+	response.Result = queryWatchdogStatsResponse{
+		Ceiling:    ceiling,
+		Violations: h.QueryWatchdog.Snapshot(),
+	}
+
+	if p.Reset {
+		h.QueryWatchdog.Reset()
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/querywatchdog"
+	"github.com/skygeario/skygear-server/pkg/server/recordstats"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecordStatsHandler(t *testing.T) {
+	Convey("RecordStatsHandler", t, func() {
+		stats := recordstats.NewCollector()
+		stats.Observe("note", recordstats.Query, 2, time.Millisecond, nil)
+
+		handler := &RecordStatsHandler{
+			RecordStats: stats,
+		}
+
+		Convey("returns statistics per record type", func() {
+			req := router.Payload{
+				Data: map[string]interface{}{},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			result := resp.Result.(recordStatsResponse)
+			So(result.RecordTypes["note"][recordstats.Query].Count, ShouldEqual, 1)
+			So(result.RecordTypes["note"][recordstats.Query].Records, ShouldEqual, 2)
+			So(stats.Snapshot(), ShouldNotBeEmpty)
+		})
+
+		Convey("resets statistics", func() {
+			req := router.Payload{
+				Data: map[string]interface{}{
+					"reset": true,
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result.(recordStatsResponse).RecordTypes, ShouldContainKey, "note")
+			So(stats.Snapshot(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestQueryWatchdogStatsHandler(t *testing.T) {
+	Convey("QueryWatchdogStatsHandler", t, func() {
+		watchdog := querywatchdog.New(30 * time.Second)
+		key := watchdog.Report("SELECT pg_sleep(60)", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		handler := &QueryWatchdogStatsHandler{
+			QueryWatchdog: watchdog,
+		}
+
+		Convey("returns violations per query shape", func() {
+			req := router.Payload{
+				Data: map[string]interface{}{},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			result := resp.Result.(queryWatchdogStatsResponse)
+			So(result.Ceiling, ShouldEqual, 30)
+			So(result.Violations[key].Shape, ShouldEqual, "SELECT pg_sleep(60)")
+			So(result.Violations[key].Count, ShouldEqual, 1)
+			So(watchdog.Snapshot(), ShouldNotBeEmpty)
+		})
+
+		Convey("resets violations", func() {
+			req := router.Payload{
+				Data: map[string]interface{}{
+					"reset": true,
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result.(queryWatchdogStatsResponse).Violations, ShouldContainKey, key)
+			So(watchdog.Snapshot(), ShouldBeEmpty)
+		})
+	})
+}
@@ -17,6 +17,7 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 
@@ -77,6 +78,18 @@ func (p *jsonPredicate) MarshalJSON() ([]byte, error) {
 		return []byte{}, nil
 	}
 
+	if p.Operator == skydb.Functional {
+		if len(p.Children) != 1 {
+			return nil, fmt.Errorf("got len(operand) = %d, want 1", len(p.Children))
+		}
+		childExpr, ok := p.Children[0].(skydb.Expression)
+		if !ok {
+			return nil, fmt.Errorf("got %s.Operand[0] of type %T, want Expression",
+				p.Operator, p.Children[0])
+		}
+		return json.Marshal(jsonExpression(childExpr))
+	}
+
 	var results []interface{}
 	if p.Operator.IsCompound() {
 		results = append(results, opString(p.Operator))
@@ -86,12 +99,14 @@ func (p *jsonPredicate) MarshalJSON() ([]byte, error) {
 				return nil, fmt.Errorf("got %s.Operand[%d] of type %T, want Predicate",
 					p.Operator, i, child)
 			}
-			results = append(results, jsonPredicate(childPred))
+			results = append(results, (*jsonPredicate)(&childPred))
 		}
 	} else {
 		operandLen := 1
 		if p.Operator.IsBinary() {
 			operandLen = 2
+		} else if p.Operator.IsTernary() {
+			operandLen = 3
 		}
 
 		if operandLen != len(p.Children) {
@@ -119,12 +134,7 @@ func (expr jsonExpression) MarshalJSON() ([]byte, error) {
 	var i interface{}
 	switch expr.Type {
 	case skydb.Literal:
-		switch v := expr.Value.(type) {
-		case skydb.Reference:
-			i = skyconv.ToMap(skyconv.MapReference(v))
-		default:
-			i = expr.Value
-		}
+		i = jsonLiteral(expr.Value)
 	case skydb.KeyPath:
 		i = skyconv.ToMap(skyconv.MapKeyPath(expr.Value.(string)))
 	case skydb.Function:
@@ -136,6 +146,26 @@ func (expr jsonExpression) MarshalJSON() ([]byte, error) {
 	return json.Marshal(i)
 }
 
+// jsonLiteral converts the literal value to the form parsed by
+// skyconv.ParseLiteral.
+func jsonLiteral(value interface{}) interface{} {
+	switch v := value.(type) {
+	case skydb.Reference:
+		return skyconv.ToMap(skyconv.MapReference(v))
+	case time.Time:
+		return skyconv.ToMap(skyconv.MapTime(v))
+	case skydb.Location:
+		return skyconv.ToMap(skyconv.MapLocation(v))
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = jsonLiteral(item)
+		}
+		return values
+	}
+	return value
+}
+
 func opString(op skydb.Operator) string {
 	switch op {
 	case skydb.And:
@@ -162,6 +192,16 @@ func opString(op skydb.Operator) string {
 		return "ilike"
 	case skydb.In:
 		return "in"
+	case skydb.IsNull:
+		return "isnull"
+	case skydb.IsNotNull:
+		return "isnotnull"
+	case skydb.ContainsAll:
+		return "containsall"
+	case skydb.ContainsAny:
+		return "containsany"
+	case skydb.Between:
+		return "between"
 	default:
 		return "UNKNOWN_OPERATOR"
 	}
@@ -176,6 +216,79 @@ func funcSlice(i interface{}) []interface{} {
 			skyconv.ToMap(skyconv.MapKeyPath(f.Field)),
 			skyconv.ToMap(skyconv.MapLocation(f.Location)),
 		}
+	case skydb.LowerFunc:
+		return []interface{}{
+			"func",
+			"lower",
+			skyconv.ToMap(skyconv.MapKeyPath(f.KeyPath)),
+		}
+	case skydb.DatePartFunc:
+		return []interface{}{
+			"func",
+			"datePart",
+			skyconv.ToMap(skyconv.MapKeyPath(f.KeyPath)),
+			string(f.Part),
+		}
+	case skydb.DescendantFunc:
+		results := []interface{}{
+			"func",
+			"descendants",
+			skyconv.ToMap(skyconv.MapKeyPath(f.ParentKeyPath)),
+			skyconv.ToMap(skyconv.MapReference(skydb.NewReference(f.RecordID.Type, f.RecordID.Key))),
+		}
+		if f.MaxDepth > 0 {
+			results = append(results, f.MaxDepth)
+		}
+		return results
+	case skydb.AncestorFunc:
+		return []interface{}{
+			"func",
+			"ancestors",
+			skyconv.ToMap(skyconv.MapKeyPath(f.ParentKeyPath)),
+			skyconv.ToMap(skyconv.MapReference(skydb.NewReference(f.RecordID.Type, f.RecordID.Key))),
+		}
+	case skydb.RandomFunc:
+		if f.Seed == nil {
+			return []interface{}{"func", "random"}
+		}
+		return []interface{}{"func", "random", *f.Seed}
+	case skydb.TreeDepthFunc:
+		return []interface{}{
+			"func",
+			"depth",
+			skyconv.ToMap(skyconv.MapKeyPath(f.ParentKeyPath)),
+		}
+	case skydb.ConcatFunc:
+		results := []interface{}{"func", "concat"}
+		for _, expr := range f.Expressions {
+			results = append(results, jsonExpression(expr))
+		}
+		return results
+	case skydb.CoalesceFunc:
+		results := []interface{}{"func", "coalesce"}
+		for _, expr := range f.Expressions {
+			results = append(results, jsonExpression(expr))
+		}
+		return results
+	case skydb.UpperFunc:
+		return []interface{}{"func", "upper", jsonExpression(f.Expression)}
+	case skydb.LengthFunc:
+		return []interface{}{"func", "length", jsonExpression(f.Expression)}
+	case skydb.AbsFunc:
+		return []interface{}{"func", "abs", jsonExpression(f.Expression)}
+	case skydb.ArithmeticFunc:
+		var funcName string
+		for name, op := range arithmeticOperators {
+			if op == f.Operator {
+				funcName = name
+			}
+		}
+		return []interface{}{
+			"func",
+			funcName,
+			jsonExpression(f.Left),
+			jsonExpression(f.Right),
+		}
 	default:
 		panic(fmt.Errorf("got unrecgonized skydb.Func = %T", i))
 	}
@@ -448,6 +561,7 @@ func (payload *subscriptionSavePayload) Validate() skyerr.Error {
 type SubscriptionSaveHandler struct {
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	RequireAuth   router.Processor `preprocessor:"require_auth"`
@@ -459,6 +573,7 @@ func (h *SubscriptionSaveHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.RequireAuth,
@@ -520,6 +635,7 @@ func (h *SubscriptionSaveHandler) Handle(rpayload *router.Payload, response *rou
 type SubscriptionDeleteHandler struct {
 	Authenticator router.Processor `preprocessor:"authenticator"`
 	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
 	InjectAuth    router.Processor `preprocessor:"inject_auth"`
 	InjectDB      router.Processor `preprocessor:"inject_db"`
 	RequireAuth   router.Processor `preprocessor:"require_auth"`
@@ -531,6 +647,7 @@ func (h *SubscriptionDeleteHandler) Setup() {
 	h.preprocessors = []router.Processor{
 		h.Authenticator,
 		h.DBConn,
+		h.Maintenance,
 		h.InjectAuth,
 		h.InjectDB,
 		h.RequireAuth,
@@ -0,0 +1,41 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/sqlite"
+)
+
+var testDBSeq int
+
+// newTestDB returns a connection and its public database for the test
+// suites running on a real backend. With the sqlite build tag, each
+// call opens a new in-memory SQLite database.
+func newTestDB(t *testing.T) (skydb.Conn, skydb.Database) {
+	testDBSeq++
+	dsn := fmt.Sprintf("file:handlertest%d?mode=memory&cache=shared", testDBSeq)
+	conn, err := sqlite.Open(context.Background(), "com.oursky.skygear", skydb.RoleBasedAccess, dsn, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, conn.PublicDB()
+}
@@ -0,0 +1,221 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type transactionPayload struct {
+	TransactionID string `mapstructure:"transaction_id"`
+}
+
+func (payload *transactionPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *transactionPayload) Validate() skyerr.Error {
+	if payload.TransactionID == "" {
+		return skyerr.NewInvalidArgument("empty transaction id", []string{"transaction_id"})
+	}
+	return nil
+}
+
+func newTransactionNotFoundErr() skyerr.Error {
+	return skyerr.NewInvalidArgument("transaction not found or already finished", []string{"transaction_id"})
+}
+
+/*
+TransactionBeginHandler begins a unit of work of a plugin hook in the
+transaction of the operation triggering the hook.
+
+Hooks of atomic record:save and record:delete requests are run in the
+transaction of the request, of which the ID is the transaction_id in the
+context of the hook request. The returned transaction_id is the token of
+the unit of work. Record requests carrying the token in transaction_id
+are run in the transaction, so that their changes are committed or rolled
+back together with the triggering request.
+
+The unit of work is finished with transaction:commit or
+transaction:rollback before the hook returns, otherwise the transaction
+is rolled back. Requests in the transaction are run one at a time, and
+fail once the unit of work or the transaction is finished.
+
+Master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "transaction:begin",
+    "master_key": "MASTER_KEY",
+    "transaction_id": "TRANSACTION_ID_IN_HOOK_CONTEXT"
+}
+EOF
+
+{
+    "result": {
+        "transaction_id": "TOKEN"
+    }
+}
+*/
+type TransactionBeginHandler struct {
+	Transactions     *transaction.Registry `inject:"TransactionRegistry"`
+	Authenticator    router.Processor      `preprocessor:"authenticator"`
+	RequireMasterKey router.Processor      `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *TransactionBeginHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *TransactionBeginHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *TransactionBeginHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &transactionPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	tx, err := h.Transactions.Begin(payload.TransactionID)
+	if err != nil {
+		response.Err = newTransactionNotFoundErr()
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"transaction_id": tx.ID(),
+	}
+}
+
+/*
+TransactionCommitHandler finishes a unit of work begun with
+transaction:begin. The changes of the unit of work are committed when the
+triggering request commits its transaction.
+
+Master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "transaction:commit",
+    "master_key": "MASTER_KEY",
+    "transaction_id": "TOKEN"
+}
+EOF
+
+{
+    "result": {
+        "transaction_id": "TOKEN"
+    }
+}
+*/
+type TransactionCommitHandler struct {
+	Transactions     *transaction.Registry `inject:"TransactionRegistry"`
+	Authenticator    router.Processor      `preprocessor:"authenticator"`
+	RequireMasterKey router.Processor      `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *TransactionCommitHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *TransactionCommitHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *TransactionCommitHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	finishTransaction(rpayload, response, h.Transactions.Commit)
+}
+
+/*
+TransactionRollbackHandler finishes a unit of work begun with
+transaction:begin, and rolls back the transaction of the triggering
+request, which fails with AtomicOperationFailure.
+
+Master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "transaction:rollback",
+    "master_key": "MASTER_KEY",
+    "transaction_id": "TOKEN"
+}
+EOF
+
+{
+    "result": {
+        "transaction_id": "TOKEN"
+    }
+}
+*/
+type TransactionRollbackHandler struct {
+	Transactions     *transaction.Registry `inject:"TransactionRegistry"`
+	Authenticator    router.Processor      `preprocessor:"authenticator"`
+	RequireMasterKey router.Processor      `preprocessor:"require_master_key"`
+	preprocessors    []router.Processor
+}
+
+func (h *TransactionRollbackHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.RequireMasterKey,
+	}
+}
+
+func (h *TransactionRollbackHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *TransactionRollbackHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	finishTransaction(rpayload, response, h.Transactions.Rollback)
+}
+
+// finishTransaction finishes the unit of work of the token in the payload
+// with the specified function.
+func finishTransaction(rpayload *router.Payload, response *router.Response, finish func(token string) error) {
+	payload := &transactionPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if err := finish(payload.TransactionID); err != nil {
+		response.Err = newTransactionNotFoundErr()
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"transaction_id": payload.TransactionID,
+	}
+}
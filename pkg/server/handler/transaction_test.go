@@ -0,0 +1,148 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
+	"github.com/skygeario/skygear-server/pkg/server/recordutil"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTransactionHandlers(t *testing.T) {
+	Convey("Transaction handlers", t, func() {
+		registry := transaction.NewRegistry()
+		root := registry.Join(skydbtest.NewMapConn())
+
+		handle := func(handler router.Handler, id string) router.Response {
+			req := router.Payload{
+				Data: map[string]interface{}{
+					"transaction_id": id,
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+			return resp
+		}
+
+		Convey("begins unit of work", func() {
+			resp := handle(&TransactionBeginHandler{Transactions: registry}, root.ID())
+
+			So(resp.Err, ShouldBeNil)
+			token := resp.Result.(map[string]interface{})["transaction_id"].(string)
+			tx, err := registry.Get(token)
+			So(err, ShouldBeNil)
+			So(tx.RootID(), ShouldEqual, root.ID())
+
+			Convey("commits unit of work", func() {
+				resp := handle(&TransactionCommitHandler{Transactions: registry}, token)
+
+				So(resp.Err, ShouldBeNil)
+				So(registry.Leave(root), ShouldBeNil)
+			})
+
+			Convey("rolls back unit of work", func() {
+				resp := handle(&TransactionRollbackHandler{Transactions: registry}, token)
+
+				So(resp.Err, ShouldBeNil)
+				So(registry.Leave(root), ShouldEqual, transaction.ErrRolledBack)
+			})
+
+			Convey("rejects finished unit of work", func() {
+				handle(&TransactionCommitHandler{Transactions: registry}, token)
+				resp := handle(&TransactionRollbackHandler{Transactions: registry}, token)
+
+				So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			})
+		})
+
+		Convey("rejects unknown transaction", func() {
+			resp := handle(&TransactionBeginHandler{Transactions: registry}, "unknown")
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("rejects empty transaction id", func() {
+			resp := handle(&TransactionCommitHandler{Transactions: registry}, "")
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+	})
+}
+
+func TestAtomicModifyFuncTransaction(t *testing.T) {
+	Convey("atomicModifyFunc", t, func() {
+		registry := transaction.NewRegistry()
+		conn := skydbtest.NewMapConn()
+		db := skydbtest.NewMockTxDatabase(skydbtest.NewMapDB())
+		req := recordutil.RecordModifyRequest{
+			Db:   db,
+			Conn: conn,
+		}
+		resp := recordutil.RecordModifyResponse{
+			ErrMap: map[skydb.RecordID]skyerr.Error{},
+		}
+
+		Convey("exposes transaction to hooks", func() {
+			var tx *transaction.Transaction
+			modify := atomicModifyFunc(&req, &resp, registry, func(req *recordutil.RecordModifyRequest, resp *recordutil.RecordModifyResponse) skyerr.Error {
+				tx = transaction.FromContext(req.Context)
+				unit, err := registry.Begin(tx.ID())
+				So(err, ShouldBeNil)
+				So(unit.Conn(), ShouldEqual, conn)
+				So(registry.Commit(unit.ID()), ShouldBeNil)
+				return nil
+			})
+
+			So(modify(&req, &resp), ShouldBeNil)
+			So(db.DidCommit, ShouldBeTrue)
+			_, err := registry.Begin(tx.ID())
+			So(err, ShouldEqual, transaction.ErrNotFound)
+		})
+
+		Convey("rolls back transaction rolled back by plugin", func() {
+			modify := atomicModifyFunc(&req, &resp, registry, func(req *recordutil.RecordModifyRequest, resp *recordutil.RecordModifyResponse) skyerr.Error {
+				unit, _ := registry.Begin(transaction.FromContext(req.Context).ID())
+				registry.Rollback(unit.ID())
+				return nil
+			})
+
+			err := modify(&req, &resp)
+			So(err.Code(), ShouldEqual, skyerr.AtomicOperationFailure)
+			So(db.DidCommit, ShouldBeFalse)
+			So(db.DidRollback, ShouldBeTrue)
+		})
+
+		Convey("runs in unit of work without beginning transaction", func() {
+			root := registry.Join(conn)
+			unit, _ := registry.Begin(root.ID())
+			req.Context = transaction.NewContext(req.Context, unit)
+
+			modify := atomicModifyFunc(&req, &resp, registry, func(req *recordutil.RecordModifyRequest, resp *recordutil.RecordModifyResponse) skyerr.Error {
+				return skyerr.NewError(skyerr.UnexpectedError, "atomic operation failed")
+			})
+
+			So(modify(&req, &resp), ShouldNotBeNil)
+			So(db.DidBegin, ShouldBeFalse)
+			registry.Commit(unit.ID())
+			So(registry.Leave(root), ShouldEqual, transaction.ErrRolledBack)
+		})
+	})
+}
@@ -0,0 +1,436 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+type transitionResult struct {
+	ID          string                 `json:"id"`
+	RecordID    string                 `json:"record_id"`
+	Data        map[string]interface{} `json:"data"`
+	ScheduledAt time.Time              `json:"scheduled_at"`
+	CreatedAt   time.Time              `json:"created_at"`
+	CreatedBy   string                 `json:"created_by"`
+}
+
+func newTransitionResult(transition skydb.Transition) transitionResult {
+	return transitionResult{
+		ID:          transition.ID,
+		RecordID:    transition.RecordID.String(),
+		Data:        transition.Data,
+		ScheduledAt: transition.ScheduledAt,
+		CreatedAt:   transition.CreatedAt,
+		CreatedBy:   transition.CreatedBy,
+	}
+}
+
+// checkTransitionAccess returns the record, or an error if the user
+// cannot access the record at the specified level.
+func checkTransitionAccess(rpayload *router.Payload, recordID skydb.RecordID, level skydb.RecordACLLevel) (*skydb.Record, skyerr.Error) {
+	record := skydb.Record{}
+	if err := rpayload.Database.Get(recordID, &record); err == skydb.ErrRecordNotFound {
+		return nil, skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+	} else if err != nil {
+		return nil, skyerr.MakeError(err)
+	}
+
+	if !rpayload.HasMasterKey() && !record.Accessible(rpayload.AuthInfo, level) {
+		return nil, skyerr.NewError(skyerr.PermissionDenied, "no permission to access transitions of the record")
+	}
+	return &record, nil
+}
+
+// checkTransitionFieldAccess returns an error if the user cannot write
+// any of the fields of the transition because of the field ACL.
+func checkTransitionFieldAccess(rpayload *router.Payload, record *skydb.Record, data map[string]interface{}) skyerr.Error {
+	if rpayload.HasMasterKey() {
+		return nil
+	}
+
+	fieldACL, err := rpayload.DBConn.GetRecordFieldAccess()
+	if err != nil {
+		return skyerr.MakeError(err)
+	}
+
+	nonWritableFields := []string{}
+	for key := range data {
+		if !fieldACL.Accessible(record.ID.Type, key, skydb.WriteFieldAccessMode, rpayload.AuthInfo, record) {
+			nonWritableFields = append(nonWritableFields, key)
+		}
+	}
+	if len(nonWritableFields) > 0 {
+		sort.Strings(nonWritableFields)
+		return skyerr.NewDeniedArgument("Unable to save to some record fields because of Field ACL denied update.", nonWritableFields)
+	}
+	return nil
+}
+
+type transitionSchedulePayload struct {
+	RawID       string                 `mapstructure:"id"`
+	Data        map[string]interface{} `mapstructure:"data"`
+	RawAt       string                 `mapstructure:"at"`
+	RecordID    skydb.RecordID
+	ScheduledAt time.Time
+}
+
+func (payload *transitionSchedulePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *transitionSchedulePayload) Validate() skyerr.Error {
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+
+	if len(payload.Data) == 0 {
+		return skyerr.NewInvalidArgument("data must not be empty", []string{"data"})
+	}
+	for key := range payload.Data {
+		if strings.HasPrefix(key, "_") {
+			return skyerr.NewInvalidArgument("attempts to change reserved key", []string{"data"})
+		}
+	}
+
+	scheduledAt, err := time.Parse(time.RFC3339, payload.RawAt)
+	if err != nil {
+		return skyerr.NewInvalidArgument("at must be a time in RFC3339 format", []string{"at"})
+	}
+	payload.ScheduledAt = scheduledAt.UTC()
+	return nil
+}
+
+/*
+TransitionScheduleHandler schedules a change of fields of a record at a
+future time. At the scheduled time, the fields in data are saved to the
+record with record hooks executed, as if the record is saved by the
+current user.
+
+Write access to the record and the fields is required. The access is
+checked again when the transition is executed.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:transition:schedule",
+    "access_token": "ACCESS_TOKEN",
+    "id": "article/1",
+    "data": {
+        "status": "published"
+    },
+    "at": "2017-01-01T00:00:00Z"
+}
+EOF
+
+{
+    "result": {
+        "id": "TRANSITION_ID",
+        "record_id": "article/1",
+        "data": {
+            "status": "published"
+        },
+        "scheduled_at": "2017-01-01T00:00:00Z",
+        "created_at": "2016-12-01T00:00:00Z",
+        "created_by": "USER_ID"
+    }
+}
+*/
+type TransitionScheduleHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *TransitionScheduleHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *TransitionScheduleHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *TransitionScheduleHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &transitionSchedulePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if rpayload.Database.IsReadOnly() {
+		response.Err = skyerr.NewError(skyerr.NotSupported, "modifying the selected database is not supported")
+		return
+	}
+
+	now := timeNow()
+	if !payload.ScheduledAt.After(now) {
+		response.Err = skyerr.NewInvalidArgument("at must be in the future", []string{"at"})
+		return
+	}
+
+	record, skyErr := checkTransitionAccess(rpayload, payload.RecordID, skydb.WriteLevel)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+	if skyErr := checkTransitionFieldAccess(rpayload, record, payload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	transition := skydb.Transition{
+		ID:            uuid.New(),
+		RecordID:      payload.RecordID,
+		DatabaseID:    rpayload.Database.ID(),
+		Data:          payload.Data,
+		ScheduledAt:   payload.ScheduledAt,
+		CreatedAt:     now,
+		CreatedBy:     rpayload.AuthInfoID,
+		WithMasterKey: rpayload.HasMasterKey(),
+	}
+	if err := rpayload.DBConn.ScheduleTransition(&transition); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newTransitionResult(transition)
+}
+
+type transitionListPayload struct {
+	RawID    string `mapstructure:"id"`
+	RecordID skydb.RecordID
+}
+
+func (payload *transitionListPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *transitionListPayload) Validate() skyerr.Error {
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+	return nil
+}
+
+/*
+TransitionListHandler returns the transitions of a record which are not
+yet executed, ordered by the scheduled time.
+
+Read access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:transition:list",
+    "access_token": "ACCESS_TOKEN",
+    "id": "article/1"
+}
+EOF
+*/
+type TransitionListHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *TransitionListHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *TransitionListHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *TransitionListHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &transitionListPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if _, skyErr := checkTransitionAccess(rpayload, payload.RecordID, skydb.ReadLevel); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	transitions, err := rpayload.DBConn.GetTransitions(payload.RecordID)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	results := make([]transitionResult, len(transitions))
+	for i, transition := range transitions {
+		results[i] = newTransitionResult(transition)
+	}
+	response.Result = map[string]interface{}{
+		"transitions": results,
+	}
+}
+
+type transitionCancelPayload struct {
+	RawID        string `mapstructure:"id"`
+	TransitionID string `mapstructure:"transition_id"`
+	RecordID     skydb.RecordID
+}
+
+func (payload *transitionCancelPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *transitionCancelPayload) Validate() skyerr.Error {
+	recordID, skyErr := parseRecordIDArgument(payload.RawID, "id")
+	if skyErr != nil {
+		return skyErr
+	}
+	payload.RecordID = recordID
+
+	if payload.TransitionID == "" {
+		return skyerr.NewInvalidArgument("missing required fields", []string{"transition_id"})
+	}
+	return nil
+}
+
+/*
+TransitionCancelHandler cancels a transition of a record which is not yet
+executed.
+
+Write access to the record is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "record:transition:cancel",
+    "access_token": "ACCESS_TOKEN",
+    "id": "article/1",
+    "transition_id": "TRANSITION_ID"
+}
+EOF
+*/
+type TransitionCancelHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	InjectDB      router.Processor `preprocessor:"inject_db"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *TransitionCancelHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.InjectDB,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *TransitionCancelHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *TransitionCancelHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &transitionCancelPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if _, skyErr := checkTransitionAccess(rpayload, payload.RecordID, skydb.WriteLevel); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	// The transition must belong to the record, otherwise the access
+	// to the record does not grant access to the transition.
+	transitions, err := rpayload.DBConn.GetTransitions(payload.RecordID)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	found := false
+	for _, transition := range transitions {
+		if transition.ID == payload.TransitionID {
+			found = true
+			break
+		}
+	}
+
+	if found {
+		err = rpayload.DBConn.DeleteTransition(payload.TransitionID)
+	}
+	if !found || err == skydb.ErrTransitionNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "transition not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"id": payload.TransitionID,
+	}
+}
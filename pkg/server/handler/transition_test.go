@@ -0,0 +1,249 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type transitionConn struct {
+	skydb.Conn
+	transitions []skydb.Transition
+	fieldACL    skydb.FieldACL
+}
+
+func (conn *transitionConn) GetRecordFieldAccess() (skydb.FieldACL, error) {
+	return conn.fieldACL, nil
+}
+
+func (conn *transitionConn) ScheduleTransition(transition *skydb.Transition) error {
+	conn.transitions = append(conn.transitions, *transition)
+	return nil
+}
+
+func (conn *transitionConn) GetTransitions(recordID skydb.RecordID) ([]skydb.Transition, error) {
+	transitions := []skydb.Transition{}
+	for _, transition := range conn.transitions {
+		if transition.RecordID == recordID {
+			transitions = append(transitions, transition)
+		}
+	}
+	return transitions, nil
+}
+
+func (conn *transitionConn) DeleteTransition(id string) error {
+	for i, transition := range conn.transitions {
+		if transition.ID == id {
+			conn.transitions = append(conn.transitions[:i], conn.transitions[i+1:]...)
+			return nil
+		}
+	}
+	return skydb.ErrTransitionNotFound
+}
+
+func TestRecordTransitionHandlers(t *testing.T) {
+	Convey("Record transition handlers", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := &transitionConn{}
+		db := skydbtest.NewMapDB()
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("article", "1"),
+			OwnerID: "alice",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("bob", skydb.ReadLevel),
+			},
+		}), ShouldBeNil)
+
+		newRouter := func(handler router.Handler, userID string) *handlertest.SingleRouteRouter {
+			return handlertest.NewSingleRouteRouter(handler, func(p *router.Payload) {
+				p.DBConn = conn
+				p.Database = db
+				p.AuthInfoID = userID
+				p.AuthInfo = &skydb.AuthInfo{ID: userID}
+			})
+		}
+
+		existing := skydb.Transition{
+			ID:          "transition-1",
+			RecordID:    skydb.NewRecordID("article", "1"),
+			DatabaseID:  db.ID(),
+			Data:        map[string]interface{}{"status": "published"},
+			ScheduledAt: time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC),
+			CreatedAt:   time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBy:   "alice",
+		}
+
+		Convey("schedule transition", func() {
+			r := newRouter(&TransitionScheduleHandler{}, "alice")
+			resp := r.POST(`{
+	"id": "article/1",
+	"data": {"status": "published"},
+	"at": "2017-02-01T00:00:00Z"
+}`)
+			So(resp.Code, ShouldEqual, 200)
+			So(conn.transitions, ShouldHaveLength, 1)
+
+			transition := conn.transitions[0]
+			So(transition.ID, ShouldNotBeEmpty)
+			So(transition.RecordID, ShouldResemble, skydb.NewRecordID("article", "1"))
+			So(transition.DatabaseID, ShouldEqual, db.ID())
+			So(transition.Data, ShouldResemble, map[string]interface{}{"status": "published"})
+			So(transition.ScheduledAt, ShouldResemble, time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC))
+			So(transition.CreatedBy, ShouldEqual, "alice")
+			So(transition.WithMasterKey, ShouldBeFalse)
+		})
+
+		Convey("reject transition scheduled in the past", func() {
+			r := newRouter(&TransitionScheduleHandler{}, "alice")
+			resp := r.POST(`{
+	"id": "article/1",
+	"data": {"status": "published"},
+	"at": "2016-12-01T00:00:00Z"
+}`)
+			So(resp.Code, ShouldEqual, 400)
+			So(conn.transitions, ShouldBeEmpty)
+		})
+
+		Convey("reject transition changing reserved key", func() {
+			r := newRouter(&TransitionScheduleHandler{}, "alice")
+			resp := r.POST(`{
+	"id": "article/1",
+	"data": {"_owner_id": "bob"},
+	"at": "2017-02-01T00:00:00Z"
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "InvalidArgument",
+		"code": 108,
+		"message": "attempts to change reserved key",
+		"info": {
+			"arguments": ["data"]
+		}
+	}
+}`)
+		})
+
+		Convey("reject transition without write access", func() {
+			r := newRouter(&TransitionScheduleHandler{}, "bob")
+			resp := r.POST(`{
+	"id": "article/1",
+	"data": {"status": "published"},
+	"at": "2017-02-01T00:00:00Z"
+}`)
+			So(resp.Code, ShouldEqual, 403)
+			So(conn.transitions, ShouldBeEmpty)
+		})
+
+		Convey("reject transition of field denied by field ACL", func() {
+			conn.fieldACL = skydb.NewFieldACL(skydb.FieldACLEntryList{
+				{
+					RecordType:  "article",
+					RecordField: "status",
+					UserRole:    skydb.FieldUserRole{skydb.PublicFieldUserRoleType, ""},
+					Readable:    true,
+				},
+			})
+
+			r := newRouter(&TransitionScheduleHandler{}, "alice")
+			resp := r.POST(`{
+	"id": "article/1",
+	"data": {"status": "published", "title": "Hello"},
+	"at": "2017-02-01T00:00:00Z"
+}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"code": 123,
+		"message": "Unable to save to some record fields because of Field ACL denied update.",
+		"info": {
+			"arguments": ["status"]
+		},
+		"name": "DeniedArgument"
+	}
+}`)
+			So(conn.transitions, ShouldBeEmpty)
+		})
+
+		Convey("reject transition of non-existent record", func() {
+			r := newRouter(&TransitionScheduleHandler{}, "alice")
+			resp := r.POST(`{
+	"id": "article/2",
+	"data": {"status": "published"},
+	"at": "2017-02-01T00:00:00Z"
+}`)
+			So(resp.Code, ShouldEqual, 404)
+		})
+
+		Convey("list transitions", func() {
+			conn.transitions = []skydb.Transition{existing}
+
+			r := newRouter(&TransitionListHandler{}, "bob")
+			resp := r.POST(`{"id": "article/1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"transitions": [{
+			"id": "transition-1",
+			"record_id": "article/1",
+			"data": {"status": "published"},
+			"scheduled_at": "2017-02-01T00:00:00Z",
+			"created_at": "2017-01-01T00:00:00Z",
+			"created_by": "alice"
+		}]
+	}
+}`)
+		})
+
+		Convey("cancel transition", func() {
+			conn.transitions = []skydb.Transition{existing}
+
+			r := newRouter(&TransitionCancelHandler{}, "alice")
+			resp := r.POST(`{"id": "article/1", "transition_id": "transition-1"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"id": "transition-1"
+	}
+}`)
+			So(conn.transitions, ShouldBeEmpty)
+		})
+
+		Convey("fail to cancel transition without write access", func() {
+			conn.transitions = []skydb.Transition{existing}
+
+			r := newRouter(&TransitionCancelHandler{}, "bob")
+			resp := r.POST(`{"id": "article/1", "transition_id": "transition-1"}`)
+			So(resp.Code, ShouldEqual, 403)
+			So(conn.transitions, ShouldHaveLength, 1)
+		})
+
+		Convey("fail to cancel transition of another record", func() {
+			r := newRouter(&TransitionCancelHandler{}, "alice")
+			resp := r.POST(`{"id": "article/1", "transition_id": "transition-2"}`)
+			So(resp.Code, ShouldEqual, 404)
+		})
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type unreadResult struct {
+	Counts skydb.UnreadCounts `json:"counts"`
+	Total  int64              `json:"total"`
+}
+
+func getUnreadResult(conn skydb.Conn, userID string) (*unreadResult, skyerr.Error) {
+	counts, err := conn.GetUnreadCounts(userID)
+	if err != nil {
+		return nil, skyerr.MakeError(err)
+	}
+	return &unreadResult{
+		Counts: counts,
+		Total:  counts.Total(),
+	}, nil
+}
+
+/*
+UnreadFetchHandler returns the unread counts of the current user.
+
+The unread count of a record type configured in UNREAD_RECORD_TYPES is
+incremented when a record of the type is created and the user is
+notified of it by a subscription. The total of the unread counts is the
+badge number of the push notifications sent to the user.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "unread:fetch",
+    "access_token": "ACCESS_TOKEN"
+}
+EOF
+
+{
+    "result": {
+        "counts": {"message": 2, "comment": 1},
+        "total": 3
+    }
+}
+*/
+type UnreadFetchHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *UnreadFetchHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *UnreadFetchHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *UnreadFetchHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	result, skyErr := getUnreadResult(rpayload.DBConn, rpayload.AuthInfoID)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+	response.Result = result
+}
+
+type unreadResetPayload struct {
+	Channel string `mapstructure:"channel"`
+}
+
+func (payload *unreadResetPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return nil
+}
+
+/*
+UnreadResetHandler resets the unread count of a channel of the current
+user, or of all channels if channel is not specified, and returns the
+updated unread counts. The channel of a record type is the record type.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "unread:reset",
+    "access_token": "ACCESS_TOKEN",
+    "channel": "message"
+}
+EOF
+
+{
+    "result": {
+        "counts": {"comment": 1},
+        "total": 1
+    }
+}
+*/
+type UnreadResetHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAuth   router.Processor `preprocessor:"require_auth"`
+	PluginReady   router.Processor `preprocessor:"plugin_ready"`
+	preprocessors []router.Processor
+}
+
+func (h *UnreadResetHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAuth,
+		h.PluginReady,
+	}
+}
+
+func (h *UnreadResetHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *UnreadResetHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &unreadResetPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	if err := rpayload.DBConn.ResetUnreadCounts(rpayload.AuthInfoID, payload.Channel); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	result, skyErr := getUnreadResult(rpayload.DBConn, rpayload.AuthInfoID)
+	if skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+	response.Result = result
+}
@@ -0,0 +1,113 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+type unreadConn struct {
+	skydb.Conn
+	counts map[string]skydb.UnreadCounts
+}
+
+func (conn *unreadConn) GetUnreadCounts(userID string) (skydb.UnreadCounts, error) {
+	counts := skydb.UnreadCounts{}
+	for channel, count := range conn.counts[userID] {
+		counts[channel] = count
+	}
+	return counts, nil
+}
+
+func (conn *unreadConn) ResetUnreadCounts(userID string, channel string) error {
+	if channel == "" {
+		delete(conn.counts, userID)
+	} else {
+		delete(conn.counts[userID], channel)
+	}
+	return nil
+}
+
+func TestUnreadHandlers(t *testing.T) {
+	Convey("Unread handlers", t, func() {
+		conn := &unreadConn{
+			counts: map[string]skydb.UnreadCounts{
+				"alice": {"message": 2, "comment": 1},
+				"bob":   {"message": 1},
+			},
+		}
+
+		newRouter := func(handler router.Handler) *handlertest.SingleRouteRouter {
+			return handlertest.NewSingleRouteRouter(handler, func(p *router.Payload) {
+				p.DBConn = conn
+				p.AuthInfoID = "alice"
+				p.AuthInfo = &skydb.AuthInfo{ID: "alice"}
+			})
+		}
+
+		Convey("fetches unread counts of current user", func() {
+			r := newRouter(&UnreadFetchHandler{})
+			resp := r.POST(`{}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"counts": {"message": 2, "comment": 1},
+		"total": 3
+	}
+}`)
+		})
+
+		Convey("resets unread count of channel", func() {
+			r := newRouter(&UnreadResetHandler{})
+			resp := r.POST(`{"channel": "message"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"counts": {"comment": 1},
+		"total": 1
+	}
+}`)
+			So(conn.counts["bob"], ShouldResemble, skydb.UnreadCounts{"message": 1})
+		})
+
+		Convey("resets unread counts of all channels", func() {
+			r := newRouter(&UnreadResetHandler{})
+			resp := r.POST(`{}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {
+		"counts": {},
+		"total": 0
+	}
+}`)
+		})
+
+		Convey("rejects malformed channel", func() {
+			r := newRouter(&UnreadResetHandler{})
+			resp := r.POST(`{"channel": 1}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error": {
+		"name": "BadRequest",
+		"code": 107,
+		"message": "fails to decode the request payload"
+	}
+}`)
+		})
+	})
+}
@@ -0,0 +1,243 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+type userImportItem struct {
+	ID           string                 `mapstructure:"user_id"`
+	AuthDataData map[string]interface{} `mapstructure:"auth_data"`
+	PasswordHash *skydb.PasswordHash    `mapstructure:"password_hash"`
+	Roles        []string               `mapstructure:"roles"`
+	Profile      skydb.Data             `mapstructure:"profile"`
+
+	AuthData skydb.AuthData `mapstructure:"-"`
+}
+
+func (item *userImportItem) Validate() skyerr.Error {
+	if !item.AuthData.IsValid() {
+		return skyerr.NewInvalidArgument("invalid auth data", []string{"auth_data"})
+	}
+
+	for k := range item.AuthData.GetData() {
+		if _, found := item.Profile[k]; found {
+			return skyerr.NewInvalidArgument("duplicated keys found in auth data in profile", []string{k})
+		}
+	}
+
+	if item.PasswordHash == nil {
+		return skyerr.NewInvalidArgument("empty password hash", []string{"password_hash"})
+	}
+
+	return nil
+}
+
+type userImportPayload struct {
+	Users []userImportItem `mapstructure:"users"`
+
+	AuthRecordKeys [][]string `mapstructure:"-"`
+}
+
+func (payload *userImportPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	for i := range payload.Users {
+		item := &payload.Users[i]
+		item.AuthData = skydb.NewAuthData(item.AuthDataData, payload.AuthRecordKeys)
+	}
+	return payload.Validate()
+}
+
+func (payload *userImportPayload) Validate() skyerr.Error {
+	if len(payload.Users) == 0 {
+		return skyerr.NewInvalidArgument("empty users", []string{"users"})
+	}
+	return nil
+}
+
+type userImportResult struct {
+	UserID string `json:"user_id"`
+}
+
+/*
+UserImportHandler creates users migrated from another system, whose
+passwords are only available as hashes. Master key is required.
+
+The password hash is kept as is and verified when the user logs in for the
+first time, after which it is rehashed with the server's preferred
+algorithm. Supported algorithms are bcrypt, scrypt, argon2i and argon2id.
+The hash and salt of scrypt and argon2 are base64 encoded, and the
+parameters used to generate the hash must be declared:
+
+* scrypt: n, r, p
+* argon2i, argon2id: memory (KiB), iterations, p
+
+Each user is imported separately; results are returned in the same order
+as the users in the request.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "user:import",
+    "master_key": "MASTER_KEY",
+    "users": [
+        {
+            "user_id": "f6b7c1c4-4f1e-4c5b-9a1e-2a5f0b4c9d11",
+            "auth_data": {"email": "john.doe@example.com"},
+            "password_hash": {
+                "algorithm": "scrypt",
+                "hash": "2Wq5J0kS3Y9F0v3qK1Zt8w0p7k3vL2m9x0Jc5nE8rT4=",
+                "salt": "c29tZXNhbHRzb21lc2FsdA==",
+                "n": 16384,
+                "r": 8,
+                "p": 1
+            },
+            "roles": ["editor"],
+            "profile": {"name": "John Doe"}
+        },
+        {
+            "auth_data": {"username": "jane"},
+            "password_hash": {
+                "algorithm": "bcrypt",
+                "hash": "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+            }
+        }
+    ]
+}
+EOF
+
+{
+    "result": [
+        {"user_id": "f6b7c1c4-4f1e-4c5b-9a1e-2a5f0b4c9d11"},
+        {
+            "_type": "error",
+            "name": "Duplicated",
+            "code": 109,
+            "message": "user duplicated"
+        }
+    ]
+}
+*/
+type UserImportHandler struct {
+	HookRegistry     *hook.Registry    `inject:"HookRegistry"`
+	AssetStore       asset.Store       `inject:"AssetStore"`
+	AccessModel      skydb.AccessModel `inject:"AccessModel"`
+	AuthRecordKeys   [][]string        `inject:"AuthRecordKeys"`
+	Authenticator    router.Processor  `preprocessor:"authenticator"`
+	DBConn           router.Processor  `preprocessor:"dbconn"`
+	Maintenance      router.Processor  `preprocessor:"maintenance"`
+	InjectPublicDB   router.Processor  `preprocessor:"inject_public_db"`
+	RequireMasterKey router.Processor  `preprocessor:"require_master_key"`
+	PluginReady      router.Processor  `preprocessor:"plugin_ready"`
+	preprocessors    []router.Processor
+}
+
+func (h *UserImportHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectPublicDB,
+		h.RequireMasterKey,
+		h.PluginReady,
+	}
+}
+
+func (h *UserImportHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *UserImportHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &userImportPayload{
+		AuthRecordKeys: h.AuthRecordKeys,
+	}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	var defaultRoles []string
+	if h.AccessModel == skydb.RoleBasedAccess {
+		var err error
+		defaultRoles, err = rpayload.DBConn.GetDefaultRoles()
+		if err != nil {
+			response.Err = skyerr.NewError(skyerr.InternalQueryInvalid, "unable to query default roles")
+			return
+		}
+	}
+
+	createContext := createUserWithRecordContext{
+		rpayload.DBConn,
+		rpayload.Database,
+		h.AssetStore,
+		h.HookRegistry,
+		h.AuthRecordKeys,
+		rpayload.Context,
+	}
+
+	results := make([]interface{}, len(payload.Users))
+	for i := range payload.Users {
+		item := &payload.Users[i]
+		info, skyErr := h.importAuthInfo(item, defaultRoles)
+		if skyErr == nil {
+			_, skyErr = createContext.execute(&info, item.AuthData, item.Profile)
+		}
+
+		if skyErr != nil {
+			results[i] = newSerializedError(item.ID, skyErr)
+		} else {
+			results[i] = userImportResult{UserID: info.ID}
+		}
+	}
+
+	response.Result = results
+}
+
+func (h *UserImportHandler) importAuthInfo(item *userImportItem, defaultRoles []string) (skydb.AuthInfo, skyerr.Error) {
+	if skyErr := item.Validate(); skyErr != nil {
+		return skydb.AuthInfo{}, skyErr
+	}
+
+	info := skydb.AuthInfo{
+		ID:    item.ID,
+		Roles: item.Roles,
+	}
+	if info.ID == "" {
+		info.ID = uuid.New()
+	}
+	if info.Roles == nil {
+		info.Roles = defaultRoles
+	}
+
+	if err := info.SetPasswordHash(*item.PasswordHash); err != nil {
+		return skydb.AuthInfo{}, skyerr.NewInvalidArgument(
+			fmt.Sprintf("unsupported password hash: %s", item.PasswordHash.Algorithm),
+			[]string{"password_hash"},
+		)
+	}
+
+	return info, nil
+}
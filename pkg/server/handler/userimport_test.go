@@ -0,0 +1,175 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+func TestUserImportHandler(t *testing.T) {
+	Convey("UserImportHandler", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := skydbtest.NewMapConn()
+		db := skydbtest.NewMapDB()
+		txdb := skydbtest.NewMockTxDatabase(db)
+
+		handler := &UserImportHandler{
+			AuthRecordKeys: [][]string{[]string{"username"}, []string{"email"}},
+		}
+
+		bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.DefaultCost)
+		salt := []byte("somesaltsomesalt")
+		scryptKey, _ := scrypt.Key([]byte("secret"), salt, 1024, 8, 1, 32)
+
+		Convey("imports users with hashed passwords", func() {
+			req := router.Payload{
+				Data: map[string]interface{}{
+					"users": []interface{}{
+						map[string]interface{}{
+							"user_id": "user-1",
+							"auth_data": map[string]interface{}{
+								"email": "john.doe@example.com",
+							},
+							"password_hash": map[string]interface{}{
+								"algorithm": "scrypt",
+								"hash":      base64.StdEncoding.EncodeToString(scryptKey),
+								"salt":      base64.StdEncoding.EncodeToString(salt),
+								"n":         float64(1024),
+								"r":         float64(8),
+								"p":         float64(1),
+							},
+							"roles": []interface{}{"editor"},
+							"profile": map[string]interface{}{
+								"name": "John Doe",
+							},
+						},
+						map[string]interface{}{
+							"user_id": "user-2",
+							"auth_data": map[string]interface{}{
+								"username": "jane",
+							},
+							"password_hash": map[string]interface{}{
+								"algorithm": "bcrypt",
+								"hash":      string(bcryptHash),
+							},
+						},
+					},
+				},
+				DBConn:   conn,
+				Database: txdb,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			So(resp.Result, ShouldResemble, []interface{}{
+				userImportResult{UserID: "user-1"},
+				userImportResult{UserID: "user-2"},
+			})
+
+			info := conn.UserMap["user-1"]
+			So(info.Roles, ShouldResemble, []string{"editor"})
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.NeedsRehash(), ShouldBeTrue)
+
+			info = conn.UserMap["user-2"]
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.NeedsRehash(), ShouldBeFalse)
+
+			user := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("user", "user-1"), &user), ShouldBeNil)
+			So(user.Data["email"], ShouldEqual, "john.doe@example.com")
+			So(user.Data["name"], ShouldEqual, "John Doe")
+		})
+
+		Convey("reports error of each user", func() {
+			conn.UserMap["user-1"] = skydb.AuthInfo{ID: "user-1"}
+
+			req := router.Payload{
+				Data: map[string]interface{}{
+					"users": []interface{}{
+						map[string]interface{}{
+							"user_id": "user-1",
+							"auth_data": map[string]interface{}{
+								"username": "john",
+							},
+							"password_hash": map[string]interface{}{
+								"algorithm": "bcrypt",
+								"hash":      string(bcryptHash),
+							},
+						},
+						map[string]interface{}{
+							"user_id": "user-2",
+							"auth_data": map[string]interface{}{
+								"username": "jane",
+							},
+							"password_hash": map[string]interface{}{
+								"algorithm": "md5",
+								"hash":      "5ebe2294ecd0e0f08eab7690d2a6ee69",
+							},
+						},
+						map[string]interface{}{
+							"user_id": "user-3",
+							"auth_data": map[string]interface{}{
+								"username": "joe",
+							},
+						},
+					},
+				},
+				DBConn:   conn,
+				Database: txdb,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			results := resp.Result.([]interface{})
+			So(results, ShouldHaveLength, 3)
+			So(results[0].(serializedError).err.Code(), ShouldEqual, skyerr.Duplicated)
+			So(results[1].(serializedError).err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(results[2].(serializedError).err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(conn.UserMap, ShouldNotContainKey, "user-2")
+			So(conn.UserMap, ShouldNotContainKey, "user-3")
+		})
+
+		Convey("rejects empty users", func() {
+			req := router.Payload{
+				Data:     map[string]interface{}{},
+				DBConn:   conn,
+				Database: txdb,
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldNotBeNil)
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+	})
+}
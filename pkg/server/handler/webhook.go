@@ -0,0 +1,497 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+	"github.com/skygeario/skygear-server/pkg/server/webhook"
+)
+
+type webhookStatsResult struct {
+	Delivered           int        `json:"delivered"`
+	Failed              int        `json:"failed"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastDeliveredAt     *time.Time `json:"last_delivered_at,omitempty"`
+	LastFailedAt        *time.Time `json:"last_failed_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+type webhookResult struct {
+	ID          string             `json:"id"`
+	URL         string             `json:"url"`
+	Events      []string           `json:"events"`
+	RecordTypes []string           `json:"record_types"`
+	Delta       bool               `json:"delta"`
+	Signed      bool               `json:"signed"`
+	Paused      bool               `json:"paused"`
+	Disabled    bool               `json:"disabled"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Stats       webhookStatsResult `json:"stats"`
+}
+
+// newWebhookResult returns the webhook without its secret.
+func newWebhookResult(w skydb.Webhook) webhookResult {
+	return webhookResult{
+		ID:          w.ID,
+		URL:         w.URL,
+		Events:      w.Events,
+		RecordTypes: w.RecordTypes,
+		Delta:       w.Delta,
+		Signed:      w.Secret != "",
+		Paused:      w.Paused,
+		Disabled:    w.Disabled,
+		CreatedAt:   w.CreatedAt,
+		Stats: webhookStatsResult{
+			Delivered:           w.Stats.Delivered,
+			Failed:              w.Stats.Failed,
+			ConsecutiveFailures: w.Stats.ConsecutiveFailures,
+			LastDeliveredAt:     w.Stats.LastDeliveredAt,
+			LastFailedAt:        w.Stats.LastFailedAt,
+			LastError:           w.Stats.LastError,
+		},
+	}
+}
+
+type webhookCreatePayload struct {
+	URL         string   `mapstructure:"url"`
+	Events      []string `mapstructure:"events"`
+	RecordTypes []string `mapstructure:"record_types"`
+	Delta       bool     `mapstructure:"delta"`
+	Secret      string   `mapstructure:"secret"`
+}
+
+func (payload *webhookCreatePayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *webhookCreatePayload) Validate() skyerr.Error {
+	u, err := url.Parse(payload.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return skyerr.NewInvalidArgument("url must be an http or https URL", []string{"url"})
+	}
+
+	if len(payload.Events) == 0 {
+		return skyerr.NewInvalidArgument("events must not be empty", []string{"events"})
+	}
+	for _, name := range payload.Events {
+		if !isKnownWebhookEvent(name) {
+			return skyerr.NewInvalidArgument(fmt.Sprintf(`unknown event "%s"`, name), []string{"events"})
+		}
+	}
+
+	if payload.RecordTypes == nil {
+		payload.RecordTypes = []string{}
+	}
+	return nil
+}
+
+// isKnownWebhookEvent returns true if the name matches at least one
+// event, including the wildcard names such as `record:*`.
+func isKnownWebhookEvent(name string) bool {
+	for _, event := range webhook.Events {
+		if name == event {
+			return true
+		}
+		if strings.HasSuffix(name, "*") && strings.HasPrefix(event, strings.TrimSuffix(name, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+WebhookCreateHandler subscribes a URL to server events. Events are
+delivered as HTTP POST requests, signed with HMAC-SHA256 in the
+X-Skygear-Webhook-Signature header if secret is specified.
+
+Supported events are record:created, record:updated, record:deleted,
+auth:signup, auth:login, push:sent, push:failed, reaction:added,
+reaction:removed and schema:changed. A name ending with `*` matches
+events with the prefix. Record, reaction and schema events can be
+limited to the specified record types.
+
+If delta is true, the data of record:updated events is a JSON merge patch
+(RFC 7386) of the fields changed by the update, with the _id of the
+record, instead of the whole record. The whole record is delivered if the
+record before the update is unknown.
+
+The webhook is disabled after sustained delivery failures, and can be
+enabled again with webhook:resume.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "webhook:create",
+    "master_key": "MASTER_KEY",
+    "url": "https://example.com/webhook",
+    "events": ["record:*", "auth:signup"],
+    "record_types": ["note"],
+    "secret": "WEBHOOK_SECRET"
+}
+EOF
+
+{
+    "result": {
+        "id": "WEBHOOK_ID",
+        "url": "https://example.com/webhook",
+        "events": ["record:*", "auth:signup"],
+        "record_types": ["note"],
+        "delta": false,
+        "signed": true,
+        "paused": false,
+        "disabled": false,
+        "created_at": "2017-01-01T00:00:00Z",
+        "stats": {
+            "delivered": 0,
+            "failed": 0,
+            "consecutive_failures": 0
+        }
+    }
+}
+*/
+type WebhookCreateHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *WebhookCreateHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *WebhookCreateHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *WebhookCreateHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &webhookCreatePayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	w := skydb.Webhook{
+		ID:          uuid.New(),
+		URL:         payload.URL,
+		Secret:      payload.Secret,
+		Events:      payload.Events,
+		RecordTypes: payload.RecordTypes,
+		Delta:       payload.Delta,
+		CreatedAt:   timeNow().UTC(),
+	}
+	if err := rpayload.DBConn.SaveWebhook(&w); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = newWebhookResult(w)
+}
+
+/*
+WebhookListHandler returns the webhooks with their delivery statistics.
+Secrets of the webhooks are not returned.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "webhook:list",
+    "master_key": "MASTER_KEY"
+}
+EOF
+
+{
+    "result": {
+        "webhooks": [{
+            "id": "WEBHOOK_ID",
+            "url": "https://example.com/webhook",
+            "events": ["record:*", "auth:signup"],
+            "record_types": ["note"],
+            "delta": false,
+            "signed": true,
+            "paused": false,
+            "disabled": false,
+            "created_at": "2017-01-01T00:00:00Z",
+            "stats": {
+                "delivered": 42,
+                "failed": 1,
+                "consecutive_failures": 0,
+                "last_delivered_at": "2017-01-02T00:00:00Z",
+                "last_failed_at": "2017-01-01T12:00:00Z",
+                "last_error": "webhook responded with status 502"
+            }
+        }]
+    }
+}
+*/
+type WebhookListHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *WebhookListHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *WebhookListHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *WebhookListHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	webhooks, err := rpayload.DBConn.GetWebhooks()
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	results := []webhookResult{}
+	for _, w := range webhooks {
+		results = append(results, newWebhookResult(w))
+	}
+	response.Result = map[string]interface{}{
+		"webhooks": results,
+	}
+}
+
+type webhookIDPayload struct {
+	ID string `mapstructure:"id"`
+}
+
+func (payload *webhookIDPayload) Decode(data map[string]interface{}) skyerr.Error {
+	if err := mapstructure.Decode(data, payload); err != nil {
+		return skyerr.NewError(skyerr.BadRequest, "fails to decode the request payload")
+	}
+	return payload.Validate()
+}
+
+func (payload *webhookIDPayload) Validate() skyerr.Error {
+	if payload.ID == "" {
+		return skyerr.NewInvalidArgument("empty webhook id", []string{"id"})
+	}
+	return nil
+}
+
+// updateWebhook applies the update to the webhook of the ID specified in
+// the payload, and writes the updated webhook to the response.
+func updateWebhook(rpayload *router.Payload, response *router.Response, update func(w *skydb.Webhook)) {
+	payload := &webhookIDPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	w, err := rpayload.DBConn.GetWebhook(payload.ID)
+	if err == skydb.ErrWebhookNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "webhook not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	update(w)
+	if err := rpayload.DBConn.SaveWebhook(w); err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	// reload for the delivery statistics reset by SaveWebhook
+	w, err = rpayload.DBConn.GetWebhook(payload.ID)
+	if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+	response.Result = newWebhookResult(*w)
+}
+
+/*
+WebhookPauseHandler stops delivering events to the webhook. Events
+occurred when the webhook is paused are not delivered.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "webhook:pause",
+    "master_key": "MASTER_KEY",
+    "id": "WEBHOOK_ID"
+}
+EOF
+*/
+type WebhookPauseHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *WebhookPauseHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *WebhookPauseHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *WebhookPauseHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	updateWebhook(rpayload, response, func(w *skydb.Webhook) {
+		w.Paused = true
+	})
+}
+
+/*
+WebhookResumeHandler resumes delivering events to a paused webhook, or a
+webhook disabled after sustained delivery failures.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "webhook:resume",
+    "master_key": "MASTER_KEY",
+    "id": "WEBHOOK_ID"
+}
+EOF
+*/
+type WebhookResumeHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *WebhookResumeHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *WebhookResumeHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *WebhookResumeHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	updateWebhook(rpayload, response, func(w *skydb.Webhook) {
+		w.Paused = false
+		w.Disabled = false
+	})
+}
+
+/*
+WebhookDeleteHandler deletes the webhook.
+
+Admin or master key is required.
+
+curl -X POST -H "Content-Type: application/json" \
+  -d @- http://localhost:3000/ <<EOF
+{
+    "action": "webhook:delete",
+    "master_key": "MASTER_KEY",
+    "id": "WEBHOOK_ID"
+}
+EOF
+*/
+type WebhookDeleteHandler struct {
+	Authenticator router.Processor `preprocessor:"authenticator"`
+	DBConn        router.Processor `preprocessor:"dbconn"`
+	Maintenance   router.Processor `preprocessor:"maintenance"`
+	InjectAuth    router.Processor `preprocessor:"inject_auth"`
+	RequireAdmin  router.Processor `preprocessor:"require_admin"`
+	preprocessors []router.Processor
+}
+
+func (h *WebhookDeleteHandler) Setup() {
+	h.preprocessors = []router.Processor{
+		h.Authenticator,
+		h.DBConn,
+		h.Maintenance,
+		h.InjectAuth,
+		h.RequireAdmin,
+	}
+}
+
+func (h *WebhookDeleteHandler) GetPreprocessors() []router.Processor {
+	return h.preprocessors
+}
+
+func (h *WebhookDeleteHandler) Handle(rpayload *router.Payload, response *router.Response) {
+	payload := &webhookIDPayload{}
+	if skyErr := payload.Decode(rpayload.Data); skyErr != nil {
+		response.Err = skyErr
+		return
+	}
+
+	err := rpayload.DBConn.DeleteWebhook(payload.ID)
+	if err == skydb.ErrWebhookNotFound {
+		response.Err = skyerr.NewError(skyerr.ResourceNotFound, "webhook not found")
+		return
+	} else if err != nil {
+		response.Err = skyerr.MakeError(err)
+		return
+	}
+
+	response.Result = map[string]interface{}{
+		"id": payload.ID,
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type webhookConn struct {
+	skydb.Conn
+	webhooks map[string]skydb.Webhook
+}
+
+func (c *webhookConn) GetWebhook(id string) (*skydb.Webhook, error) {
+	w, ok := c.webhooks[id]
+	if !ok {
+		return nil, skydb.ErrWebhookNotFound
+	}
+	return &w, nil
+}
+
+func (c *webhookConn) GetWebhooks() ([]skydb.Webhook, error) {
+	webhooks := []skydb.Webhook{}
+	for _, w := range c.webhooks {
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, nil
+}
+
+func (c *webhookConn) SaveWebhook(w *skydb.Webhook) error {
+	saved := *w
+	if !saved.Disabled {
+		saved.Stats.ConsecutiveFailures = 0
+	}
+	c.webhooks[w.ID] = saved
+	return nil
+}
+
+func (c *webhookConn) DeleteWebhook(id string) error {
+	if _, ok := c.webhooks[id]; !ok {
+		return skydb.ErrWebhookNotFound
+	}
+	delete(c.webhooks, id)
+	return nil
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	Convey("Webhook handlers", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		conn := &webhookConn{webhooks: map[string]skydb.Webhook{}}
+
+		Convey("creates webhook", func() {
+			handler := &WebhookCreateHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"url":          "https://example.com/webhook",
+					"events":       []interface{}{"record:*", "auth:signup"},
+					"record_types": []interface{}{"note"},
+					"secret":       "secret",
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err, ShouldBeNil)
+			result := resp.Result.(webhookResult)
+			So(result.URL, ShouldEqual, "https://example.com/webhook")
+			So(result.Events, ShouldResemble, []string{"record:*", "auth:signup"})
+			So(result.RecordTypes, ShouldResemble, []string{"note"})
+			So(result.Signed, ShouldBeTrue)
+			So(result.CreatedAt, ShouldResemble, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+			So(conn.webhooks[result.ID].Secret, ShouldEqual, "secret")
+		})
+
+		Convey("rejects invalid url", func() {
+			handler := &WebhookCreateHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"url":    "ftp://example.com",
+					"events": []interface{}{"record:*"},
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(conn.webhooks, ShouldBeEmpty)
+		})
+
+		Convey("rejects unknown event", func() {
+			handler := &WebhookCreateHandler{}
+			req := router.Payload{
+				DBConn: conn,
+				Data: map[string]interface{}{
+					"url":    "https://example.com/webhook",
+					"events": []interface{}{"record:saved"},
+				},
+			}
+			resp := router.Response{}
+			handler.Handle(&req, &resp)
+
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("with webhook", func() {
+			conn.webhooks["webhook1"] = skydb.Webhook{
+				ID:       "webhook1",
+				URL:      "https://example.com/webhook",
+				Secret:   "secret",
+				Events:   []string{"*"},
+				Disabled: true,
+				Stats: skydb.WebhookStats{
+					Failed:              10,
+					ConsecutiveFailures: 10,
+					LastError:           "webhook responded with status 502",
+				},
+			}
+
+			Convey("lists webhooks without secret", func() {
+				handler := &WebhookListHandler{}
+				req := router.Payload{
+					DBConn: conn,
+				}
+				resp := router.Response{}
+				handler.Handle(&req, &resp)
+
+				So(resp.Err, ShouldBeNil)
+				results := resp.Result.(map[string]interface{})["webhooks"].([]webhookResult)
+				So(results, ShouldHaveLength, 1)
+				So(results[0].Signed, ShouldBeTrue)
+				So(results[0].Disabled, ShouldBeTrue)
+				So(results[0].Stats.Failed, ShouldEqual, 10)
+			})
+
+			Convey("pauses webhook", func() {
+				handler := &WebhookPauseHandler{}
+				req := router.Payload{
+					DBConn: conn,
+					Data: map[string]interface{}{
+						"id": "webhook1",
+					},
+				}
+				resp := router.Response{}
+				handler.Handle(&req, &resp)
+
+				So(resp.Err, ShouldBeNil)
+				So(conn.webhooks["webhook1"].Paused, ShouldBeTrue)
+			})
+
+			Convey("resumes disabled webhook", func() {
+				handler := &WebhookResumeHandler{}
+				req := router.Payload{
+					DBConn: conn,
+					Data: map[string]interface{}{
+						"id": "webhook1",
+					},
+				}
+				resp := router.Response{}
+				handler.Handle(&req, &resp)
+
+				So(resp.Err, ShouldBeNil)
+				result := resp.Result.(webhookResult)
+				So(result.Disabled, ShouldBeFalse)
+				So(result.Stats.ConsecutiveFailures, ShouldEqual, 0)
+			})
+
+			Convey("deletes webhook", func() {
+				handler := &WebhookDeleteHandler{}
+				req := router.Payload{
+					DBConn: conn,
+					Data: map[string]interface{}{
+						"id": "webhook1",
+					},
+				}
+				resp := router.Response{}
+				handler.Handle(&req, &resp)
+
+				So(resp.Err, ShouldBeNil)
+				So(conn.webhooks, ShouldBeEmpty)
+			})
+
+			Convey("returns not found for non-existent webhook", func() {
+				handler := &WebhookPauseHandler{}
+				req := router.Payload{
+					DBConn: conn,
+					Data: map[string]interface{}{
+						"id": "webhook2",
+					},
+				}
+				resp := router.Response{}
+				handler.Handle(&req, &resp)
+
+				So(resp.Err.Code(), ShouldEqual, skyerr.ResourceNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,131 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpcache emits HTTP caching headers for responses of public
+// records, so that the responses can be cached by a CDN, and purges the
+// cached responses when the records are modified.
+//
+// Cached responses are tagged by surrogate keys, which are the record
+// types and record IDs of the records in the response.
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+var log = logging.LoggerEntry("httpcache")
+
+// Policy specifies how responses of public records are cached.
+type Policy struct {
+	// MaxAge is the number of seconds for which responses are cached.
+	// Caching headers are not emitted if MaxAge is zero.
+	MaxAge int
+}
+
+// SetRecordHeaders sets Cache-Control and Surrogate-Key headers to the
+// response if it is cacheable. The records should include all records
+// in the response.
+//
+// A response is cacheable if the request is made anonymously to the
+// public database, and all records in the response are readable by
+// the public. Such response is the same for all anonymous requests.
+func (p *Policy) SetRecordHeaders(payload *router.Payload, response *router.Response, recordType string, records []skydb.Record) {
+	if p == nil || p.MaxAge <= 0 {
+		return
+	}
+
+	if payload.HasMasterKey() || payload.AuthInfo != nil {
+		return
+	}
+
+	if payload.Database == nil || payload.Database.DatabaseType() != skydb.PublicDatabase {
+		return
+	}
+
+	for _, record := range records {
+		if !record.ACL.Accessible(nil, skydb.ReadLevel) {
+			return
+		}
+	}
+
+	header := response.Header()
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", p.MaxAge))
+	header.Set("Surrogate-Key", strings.Join(RecordKeys(recordType, records), " "))
+}
+
+// RecordKeys returns the surrogate keys of the specified records, which are
+// the record types and the record IDs. The record type of an empty
+// response should be specified so that it is purged when a record of
+// that type is created.
+func RecordKeys(recordType string, records []skydb.Record) []string {
+	keySet := map[string]struct{}{}
+	if recordType != "" {
+		keySet[recordType] = struct{}{}
+	}
+	for _, record := range records {
+		keySet[record.ID.Type] = struct{}{}
+		keySet[record.ID.String()] = struct{}{}
+	}
+
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Purger purges cached responses tagged with any of the surrogate keys.
+type Purger interface {
+	Purge(keys []string) error
+}
+
+// HTTPPurger purges cached responses by sending a POST request to URL,
+// with the surrogate keys separated by space in the Surrogate-Key header.
+type HTTPPurger struct {
+	URL    string
+	Client *http.Client
+}
+
+// Purge implements Purger.
+func (p *HTTPPurger) Purge(keys []string) error {
+	req, err := http.NewRequest("POST", p.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("httpcache: purge request failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type purgeFunc func(keys []string) error
+
+func (f purgeFunc) Purge(keys []string) error {
+	return f(keys)
+}
+
+func TestPolicy(t *testing.T) {
+	Convey("Policy", t, func() {
+		policy := &Policy{MaxAge: 60}
+		payload := &router.Payload{
+			Database: skydbtest.NewMapDB(),
+		}
+		response := &router.Response{}
+		records := []skydb.Record{
+			{ID: skydb.NewRecordID("note", "1")},
+			{
+				ID: skydb.NewRecordID("note", "2"),
+				ACL: skydb.NewRecordACL([]skydb.RecordACLEntry{
+					skydb.NewRecordACLEntryPublic(skydb.ReadLevel),
+				}),
+			},
+		}
+
+		Convey("sets headers for public records", func() {
+			policy.SetRecordHeaders(payload, response, "note", records)
+			So(response.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=60")
+			So(response.Header().Get("Surrogate-Key"), ShouldEqual, "note note/1 note/2")
+		})
+
+		Convey("does not set headers for non-public records", func() {
+			records = append(records, skydb.Record{
+				ID: skydb.NewRecordID("note", "3"),
+				ACL: skydb.NewRecordACL([]skydb.RecordACLEntry{
+					skydb.NewRecordACLEntryRole("admin", skydb.ReadLevel),
+				}),
+			})
+			policy.SetRecordHeaders(payload, response, "note", records)
+			So(response.Meta, ShouldBeEmpty)
+		})
+
+		Convey("does not set headers for authenticated request", func() {
+			payload.AuthInfo = &skydb.AuthInfo{ID: "user1"}
+			policy.SetRecordHeaders(payload, response, "note", records)
+			So(response.Meta, ShouldBeEmpty)
+		})
+
+		Convey("does not set headers if disabled", func() {
+			policy = nil
+			policy.SetRecordHeaders(payload, response, "note", records)
+			So(response.Meta, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestRecordKeys(t *testing.T) {
+	Convey("RecordKeys", t, func() {
+		Convey("returns record types and IDs", func() {
+			keys := RecordKeys("note", []skydb.Record{
+				{ID: skydb.NewRecordID("note", "1")},
+				{ID: skydb.NewRecordID("user", "1")},
+			})
+			So(keys, ShouldResemble, []string{"note", "note/1", "user", "user/1"})
+		})
+
+		Convey("returns record type for empty response", func() {
+			So(RecordKeys("note", nil), ShouldResemble, []string{"note"})
+		})
+	})
+}
+
+func TestHTTPPurger(t *testing.T) {
+	Convey("HTTPPurger", t, func() {
+		var purgedKeys string
+		status := http.StatusOK
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			purgedKeys = r.Header.Get("Surrogate-Key")
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		purger := &HTTPPurger{URL: server.URL}
+
+		Convey("sends surrogate keys", func() {
+			So(purger.Purge([]string{"note", "note/1"}), ShouldBeNil)
+			So(purgedKeys, ShouldEqual, "note note/1")
+		})
+
+		Convey("returns error on failed request", func() {
+			status = http.StatusInternalServerError
+			So(purger.Purge([]string{"note"}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestInvalidator(t *testing.T) {
+	Convey("Invalidator", t, func() {
+		var purgedKeys []string
+		invalidator := &Invalidator{
+			Purger: purgeFunc(func(keys []string) error {
+				purgedKeys = keys
+				return nil
+			}),
+		}
+
+		Convey("purges public record", func() {
+			invalidator.handleRecordEvent(skydb.RecordEvent{
+				Record: &skydb.Record{ID: skydb.NewRecordID("note", "1")},
+				Event:  skydb.RecordUpdated,
+			})
+			So(purgedKeys, ShouldResemble, []string{"note", "note/1"})
+		})
+
+		Convey("ignores private record", func() {
+			invalidator.handleRecordEvent(skydb.RecordEvent{
+				Record: &skydb.Record{
+					ID:         skydb.NewRecordID("note", "1"),
+					DatabaseID: "user1",
+				},
+				Event: skydb.RecordUpdated,
+			})
+			So(purgedKeys, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpcache
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Invalidator purges cached responses of public records whenever a
+// record is modified in the public database.
+type Invalidator struct {
+	ConnOpener func() (skydb.Conn, error)
+	Purger     Purger
+	stop       chan struct{}
+}
+
+// Run listens for Conn record event
+func (i *Invalidator) Run() {
+	recordEventCh := i.subscribe()
+	i.stop = make(chan struct{})
+	defer func() { i.stop = nil }()
+
+	for {
+		select {
+		case event := <-recordEventCh:
+			i.handleRecordEvent(event)
+		case <-i.stop:
+			log.Infoln("httpcache: stopping the invalidator")
+			return
+		}
+	}
+}
+
+// Stop stops the running invalidator
+func (i *Invalidator) Stop() {
+	i.stop <- struct{}{}
+}
+
+func (i *Invalidator) subscribe() chan skydb.RecordEvent {
+	conn, err := i.ConnOpener()
+	if err != nil {
+		log.Panicf("httpcache: failed to obtain connection: %v", err)
+	}
+
+	ch := make(chan skydb.RecordEvent)
+	conn.Subscribe(ch)
+
+	return ch
+}
+
+func (i *Invalidator) handleRecordEvent(event skydb.RecordEvent) {
+	record := event.Record
+	if record == nil || record.DatabaseID != "" {
+		// only records in the public database are cached
+		return
+	}
+
+	keys := RecordKeys(record.ID.Type, []skydb.Record{*record})
+	if err := i.Purger.Purge(keys); err != nil {
+		log.WithFields(logrus.Fields{
+			"keys": keys,
+			"err":  err,
+		}).Errorln("httpcache: failed to purge cached responses")
+	}
+}
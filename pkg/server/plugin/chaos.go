@@ -0,0 +1,74 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+
+	"github.com/skygeario/skygear-server/pkg/server/chaos"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// chaosTransport drops calls to plugin as decided by the injector. Calls
+// that initialize the plugin are never dropped.
+type chaosTransport struct {
+	Transport
+	injector *chaos.Injector
+}
+
+// SetRouter forwards the router to the wrapped transport if it is a
+// BidirectionalTransport, so that the plugin can still send requests to
+// the server.
+func (t *chaosTransport) SetRouter(r *router.Router) {
+	if bidirectional, ok := t.Transport.(BidirectionalTransport); ok {
+		bidirectional.SetRouter(r)
+	}
+}
+
+func (t *chaosTransport) RunLambda(ctx context.Context, name string, in []byte) ([]byte, error) {
+	if t.injector.ShouldDrop(name) {
+		return nil, chaos.ErrDropped
+	}
+	return t.Transport.RunLambda(ctx, name, in)
+}
+
+func (t *chaosTransport) RunHandler(ctx context.Context, name string, in []byte) ([]byte, error) {
+	if t.injector.ShouldDrop(name) {
+		return nil, chaos.ErrDropped
+	}
+	return t.Transport.RunHandler(ctx, name, in)
+}
+
+func (t *chaosTransport) RunHook(ctx context.Context, hookName string, record *skydb.Record, oldRecord *skydb.Record, async bool) (*skydb.Record, error) {
+	if t.injector.ShouldDrop(hookName) {
+		return nil, chaos.ErrDropped
+	}
+	return t.Transport.RunHook(ctx, hookName, record, oldRecord, async)
+}
+
+func (t *chaosTransport) RunTimer(name string, in []byte) ([]byte, error) {
+	if t.injector.ShouldDrop(name) {
+		return nil, chaos.ErrDropped
+	}
+	return t.Transport.RunTimer(name, in)
+}
+
+func (t *chaosTransport) RunProvider(ctx context.Context, request *AuthRequest) (*AuthResponse, error) {
+	if t.injector.ShouldDrop(request.ProviderName) {
+		return nil, chaos.ErrDropped
+	}
+	return t.Transport.RunProvider(ctx, request)
+}
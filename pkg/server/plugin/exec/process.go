@@ -92,7 +92,7 @@ type execTransport struct {
 	state       skyplugin.TransportState
 }
 
-func (p *execTransport) run(args []string, env []string, in []byte) (out []byte, err error) {
+func (p *execTransport) run(ctx context.Context, args []string, env []string, in []byte) (out []byte, err error) {
 	finalArgs := make([]string, len(p.Args)+len(args))
 	for i, arg := range p.Args {
 		finalArgs[i] = arg
@@ -106,7 +106,12 @@ func (p *execTransport) run(args []string, env []string, in []byte) (out []byte,
 		return nil, err
 	}
 
-	cmd := osexec.Command(p.Path, finalArgs...)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// The plugin process is killed when the deadline of the request
+	// is exceeded.
+	cmd := osexec.CommandContext(ctx, p.Path, finalArgs...)
 	cmd.Env = []string{
 		"DATABASE_URL=" + p.DBConfig,
 		fmt.Sprintf("SKYGEAR_CONFIG=%s", encodedConfig),
@@ -123,9 +128,9 @@ func (p *execTransport) run(args []string, env []string, in []byte) (out []byte,
 }
 
 // runProc unwrap inner error returned from run
-func (p *execTransport) runProc(args []string, env []string, in []byte) (out []byte, err error) {
+func (p *execTransport) runProc(ctx context.Context, args []string, env []string, in []byte) (out []byte, err error) {
 	var data []byte
-	data, err = p.run(args, env, in)
+	data, err = p.run(ctx, args, env, in)
 	if err != nil {
 		return
 	}
@@ -169,7 +174,7 @@ func (p *execTransport) SetState(state skyplugin.TransportState) {
 }
 
 func (p *execTransport) SendEvent(name string, in []byte) ([]byte, error) {
-	return p.runProc([]string{"event", name}, []string{}, in)
+	return p.runProc(context.Background(), []string{"event", name}, []string{}, in)
 }
 
 func (p *execTransport) RunLambda(ctx context.Context, name string, in []byte) (out []byte, err error) {
@@ -181,7 +186,7 @@ func (p *execTransport) RunLambda(ctx context.Context, name string, in []byte) (
 	env := []string{
 		fmt.Sprintf("SKYGEAR_CONTEXT=%s", encodedCtx),
 	}
-	out, err = p.runProc([]string{"op", name}, env, in)
+	out, err = p.runProc(ctx, []string{"op", name}, env, in)
 	return
 }
 
@@ -194,7 +199,7 @@ func (p *execTransport) RunHandler(ctx context.Context, name string, in []byte)
 	env := []string{
 		fmt.Sprintf("SKYGEAR_CONTEXT=%s", encodedCtx),
 	}
-	out, err = p.runProc([]string{"handler", name}, env, in)
+	out, err = p.runProc(ctx, []string{"handler", name}, env, in)
 	return
 }
 
@@ -216,7 +221,7 @@ func (p *execTransport) RunHook(ctx context.Context, hookName string, record *sk
 	env := []string{
 		fmt.Sprintf("SKYGEAR_CONTEXT=%s", encodedCtx),
 	}
-	out, err := p.runProc([]string{"hook", hookName}, env, in)
+	out, err := p.runProc(ctx, []string{"hook", hookName}, env, in)
 	if err != nil {
 		return nil, err
 	}
@@ -231,12 +236,14 @@ func (p *execTransport) RunHook(ctx context.Context, hookName string, record *sk
 	recordout.CreatorID = record.CreatorID
 	recordout.UpdatedAt = record.UpdatedAt
 	recordout.UpdaterID = record.UpdaterID
+	recordout.DeletedAt = record.DeletedAt
+	recordout.Revision = record.Revision
 
 	return &recordout, nil
 }
 
 func (p *execTransport) RunTimer(name string, in []byte) (out []byte, err error) {
-	out, err = p.runProc([]string{"timer", name}, []string{}, in)
+	out, err = p.runProc(context.Background(), []string{"timer", name}, []string{}, in)
 	return
 }
 
@@ -250,7 +257,7 @@ func (p *execTransport) RunProvider(ctx context.Context, request *skyplugin.Auth
 		return nil, fmt.Errorf("failed to marshal auth request: %v", err)
 	}
 
-	out, err := p.runProc([]string{"provider", request.ProviderName, request.Action}, []string{}, in)
+	out, err := p.runProc(ctx, []string{"provider", request.ProviderName, request.Action}, []string{}, in)
 	if err != nil {
 		return nil, err
 	}
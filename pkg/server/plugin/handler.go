@@ -94,6 +94,11 @@ func (h *Handler) Handle(payload *router.Payload, response *router.Response) {
 		panic(err)
 	}
 
+	if err := checkDeadline(payload.Context); err != nil {
+		response.Err = err
+		return
+	}
+
 	outbytes, err := h.Plugin.transport.RunHandler(payload.Context, h.Name, inbytes)
 	log.WithFields(logrus.Fields{
 		"name": h.Name,
@@ -101,6 +106,10 @@ func (h *Handler) Handle(payload *router.Payload, response *router.Response) {
 	}).Debugf("Executed a handler with result")
 
 	if err != nil {
+		if err := checkDeadline(payload.Context); err != nil {
+			response.Err = err
+			return
+		}
 		switch e := err.(type) {
 		case skyerr.Error:
 			response.Err = e
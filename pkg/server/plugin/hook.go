@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
@@ -26,7 +27,22 @@ import (
 // plugin
 func CreateHookFunc(p *Plugin, hookInfo pluginHookInfo) hook.Func {
 	hookFunc := func(ctx context.Context, record *skydb.Record, oldRecord *skydb.Record) skyerr.Error {
-		recordout, err := p.transport.RunHook(ctx, hookInfo.Name, record, oldRecord, hookInfo.Async)
+		if err := checkDeadline(ctx); err != nil {
+			return err
+		}
+
+		var recordout *skydb.Record
+		var err error
+		runHook := func() {
+			recordout, err = p.transport.RunHook(ctx, hookInfo.Name, record, oldRecord, hookInfo.Async)
+		}
+		if hookInfo.Async {
+			runHook()
+		} else {
+			// The requests of the plugin in the transaction use its
+			// connection while the operation waits for the hook.
+			transaction.FromContext(ctx).Yield(runHook)
+		}
 		if err == nil && hookInfo.Trigger == string(hook.BeforeSave) && !hookInfo.Async {
 			*record = *recordout
 		}
@@ -35,6 +51,10 @@ func CreateHookFunc(p *Plugin, hookInfo pluginHookInfo) hook.Func {
 			return nil
 		}
 
+		if err := checkDeadline(ctx); err != nil {
+			return err
+		}
+
 		if pluginError, ok := err.(skyerr.Error); ok {
 			return pluginError
 		}
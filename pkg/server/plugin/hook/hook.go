@@ -41,6 +41,10 @@ type Func func(context.Context, *skydb.Record, *skydb.Record) skyerr.Error
 
 type recordTypeHookMap map[string][]Func
 
+// Observer is notified when the hooks of the kind are executed for the
+// record.
+type Observer func(kind Kind, record *skydb.Record)
+
 // Registry is a registry of hooks by record type.
 //
 // It provides method to execute hooks but is not responsible to execute
@@ -54,19 +58,28 @@ type Registry struct {
 	afterSaveHooks    recordTypeHookMap
 	beforeDeleteHooks recordTypeHookMap
 	afterDeleteHooks  recordTypeHookMap
+	observer          Observer
 }
 
 // NewRegistry returns a Registry ready for use.
 func NewRegistry() *Registry {
 	return &Registry{
-		sync.RWMutex{},
-		recordTypeHookMap{},
-		recordTypeHookMap{},
-		recordTypeHookMap{},
-		recordTypeHookMap{},
+		beforeSaveHooks:   recordTypeHookMap{},
+		afterSaveHooks:    recordTypeHookMap{},
+		beforeDeleteHooks: recordTypeHookMap{},
+		afterDeleteHooks:  recordTypeHookMap{},
 	}
 }
 
+// SetObserver sets the observer notified when hooks are executed, such
+// as to assert the hooks fired by a request in scenario tests. Executions
+// of record types without hooks are not notified.
+func (r *Registry) SetObserver(observer Observer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.observer = observer
+}
+
 // Register adds the specific hook for the supplied recordType to be executed
 // at the moment provided by kind.
 func (r *Registry) Register(kind Kind, recordType string, hook Func) error {
@@ -92,6 +105,13 @@ func (r *Registry) ExecuteHooks(ctx context.Context, kind Kind, record *skydb.Re
 		return skyerr.NewError(skyerr.UnexpectedError, "Error getting database hooks")
 	}
 
+	r.mutex.RLock()
+	observer := r.observer
+	r.mutex.RUnlock()
+	if observer != nil && len(hooks) > 0 {
+		observer(kind, record)
+	}
+
 	for _, hook := range hooks {
 		if err := hook(ctx, record, oldRecord); err != nil {
 			return err
@@ -125,6 +125,22 @@ func TestHookRegistry(t *testing.T) {
 			So(hook2.Context[0].Value(HelloContextKey), ShouldEqual, "world")
 		})
 
+		Convey("notifies observer of executed hooks", func() {
+			registry.Register(AfterSave, "note", afterSave.Func)
+			kinds := []Kind{}
+			registry.SetObserver(func(kind Kind, record *skydb.Record) {
+				kinds = append(kinds, kind)
+			})
+
+			record := &skydb.Record{
+				ID: skydb.NewRecordID("note", "id"),
+			}
+			registry.ExecuteHooks(ctx, AfterSave, record, nil)
+			registry.ExecuteHooks(ctx, BeforeSave, record, nil)
+
+			So(kinds, ShouldResemble, []Kind{AfterSave})
+		})
+
 		Convey("executes no hooks", func() {
 			record := &skydb.Record{
 				ID: skydb.NewRecordID("record", "id"),
@@ -28,6 +28,7 @@ import (
 	skyplugin "github.com/skygeario/skygear-server/pkg/server/plugin"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/common"
 	pluginrequest "github.com/skygeario/skygear-server/pkg/server/plugin/request"
+	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
@@ -95,6 +96,9 @@ func (p *httpTransport) ipc(req *pluginrequest.Request) (out []byte, err error)
 	}
 
 	httpreq = httpreq.WithContext(req.Context)
+	if requestID := router.RequestIDFromContext(req.Context); requestID != "" {
+		httpreq.Header.Set("X-Request-Id", requestID)
+	}
 	httpresp, err := p.httpClient.Do(httpreq)
 	if err != nil {
 		return nil, err
@@ -147,6 +151,8 @@ func (p *httpTransport) RunHook(ctx context.Context, hookName string, record *sk
 	recordout.CreatorID = record.CreatorID
 	recordout.UpdatedAt = record.UpdatedAt
 	recordout.UpdaterID = record.UpdaterID
+	recordout.DeletedAt = record.DeletedAt
+	recordout.Revision = record.Revision
 
 	return &recordout, nil
 }
@@ -19,6 +19,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/skygeario/skygear-server/pkg/server/querycache"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
@@ -30,6 +31,12 @@ type LambdaHandler struct {
 	UserRequired      bool
 	PreprocessorList  router.PreprocessorRegistry
 	preprocessors     []router.Processor
+
+	// Cache is not nil if responses of the lambda can be cached in
+	// CacheStore, with keys prefixed with CachePrefix.
+	Cache       *LambdaCacheOptions
+	CacheStore  querycache.Store
+	CachePrefix string
 }
 
 func NewLambdaHandler(info map[string]interface{}, ppreg router.PreprocessorRegistry, p *Plugin) *LambdaHandler {
@@ -40,6 +47,7 @@ func NewLambdaHandler(info map[string]interface{}, ppreg router.PreprocessorRegi
 	}
 	handler.AccessKeyRequired, _ = info["key_required"].(bool)
 	handler.UserRequired, _ = info["user_required"].(bool)
+	handler.Cache = newLambdaCacheOptions(info["cache"])
 	return handler
 }
 
@@ -52,7 +60,7 @@ func (h *LambdaHandler) Setup() {
 			"require_auth",
 			"plugin_ready",
 		)
-	} else if h.AccessKeyRequired {
+	} else if h.AccessKeyRequired || (h.Cache != nil && h.Cache.VaryByUser) {
 		h.preprocessors = h.PreprocessorList.GetByNames(
 			"authenticator",
 			"plugin_ready",
@@ -74,8 +82,30 @@ func (h *LambdaHandler) Handle(payload *router.Payload, response *router.Respons
 		return
 	}
 
+	var cacheKey string
+	if h.cacheEnabled() {
+		cacheKey, err = h.cacheKey(payload)
+		if err != nil {
+			response.Err = skyerr.MakeError(err)
+			return
+		}
+		if result := h.getCached(cacheKey); result != nil {
+			response.Result = result
+			return
+		}
+	}
+
+	if err := checkDeadline(payload.Context); err != nil {
+		response.Err = err
+		return
+	}
+
 	outbytes, err := h.Plugin.transport.RunLambda(payload.Context, h.Name, inbytes)
 	if err != nil {
+		if err := checkDeadline(payload.Context); err != nil {
+			response.Err = err
+			return
+		}
 		switch e := err.(type) {
 		case skyerr.Error:
 			response.Err = e
@@ -98,5 +128,9 @@ func (h *LambdaHandler) Handle(payload *router.Payload, response *router.Respons
 		"err":    err,
 	}).Debugf("Executed a lambda with result")
 
+	if h.cacheEnabled() {
+		h.setCached(cacheKey, outbytes)
+	}
+
 	response.Result = result
 }
@@ -0,0 +1,134 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+)
+
+// LambdaCacheOptions declares that the responses of a lambda can be
+// cached, so that repeated calls are served without invoking the plugin.
+//
+// The options are declared by the plugin in the registration info of the
+// lambda:
+//
+//	"cache": {"ttl": 60, "vary_by_args": ["city"], "vary_by_user": true}
+type LambdaCacheOptions struct {
+	// TTL is how long a response is cached.
+	TTL time.Duration
+
+	// VaryByArgs are the names of the arguments by which responses are
+	// cached. All arguments are used if it is nil. It is ignored if the
+	// lambda is called with positional arguments.
+	VaryByArgs []string
+
+	// VaryByUser caches responses per user.
+	VaryByUser bool
+}
+
+func newLambdaCacheOptions(info interface{}) *LambdaCacheOptions {
+	cacheInfo, ok := info.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	ttl, _ := cacheInfo["ttl"].(float64)
+	if ttl <= 0 {
+		return nil
+	}
+
+	options := &LambdaCacheOptions{
+		TTL: time.Duration(ttl * float64(time.Second)),
+	}
+	if varyByArgs, ok := cacheInfo["vary_by_args"].([]interface{}); ok {
+		options.VaryByArgs = []string{}
+		for _, arg := range varyByArgs {
+			if name, ok := arg.(string); ok {
+				options.VaryByArgs = append(options.VaryByArgs, name)
+			}
+		}
+	}
+	options.VaryByUser, _ = cacheInfo["vary_by_user"].(bool)
+	return options
+}
+
+// cacheKey returns the key of the response of the lambda to the payload
+// in the cache store.
+func (h *LambdaHandler) cacheKey(payload *router.Payload) (string, error) {
+	args := payload.Data["args"]
+	if named, ok := args.(map[string]interface{}); ok && h.Cache.VaryByArgs != nil {
+		selected := map[string]interface{}{}
+		for _, name := range h.Cache.VaryByArgs {
+			if value, ok := named[name]; ok {
+				selected[name] = value
+			}
+		}
+		args = selected
+	}
+
+	argBytes, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+
+	// Responses are not shared between tenants in multi-tenant mode,
+	// nor between calls with different access key types because the
+	// lambda is told whether it is called with master key.
+	hash := sha256.New()
+	hash.Write([]byte(payload.Tenant))
+	hash.Write([]byte{0})
+	hash.Write([]byte(payload.AccessKey.String()))
+	hash.Write([]byte{0})
+	hash.Write(argBytes)
+	if h.Cache.VaryByUser {
+		hash.Write([]byte{0})
+		hash.Write([]byte(payload.AuthInfoID))
+	}
+	return h.CachePrefix + "lambda:" + h.Name + ":" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (h *LambdaHandler) cacheEnabled() bool {
+	return h.Cache != nil && h.CacheStore != nil
+}
+
+// getCached returns the cached response of the lambda with the key, or nil
+// if it is not cached.
+func (h *LambdaHandler) getCached(key string) map[string]interface{} {
+	data, err := h.CacheStore.Get(key)
+	if err != nil {
+		log.WithError(err).Warnln("plugin: failed to get cached lambda response")
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.WithError(err).Warnln("plugin: failed to decode cached lambda response")
+		return nil
+	}
+	return result
+}
+
+func (h *LambdaHandler) setCached(key string, outbytes []byte) {
+	if err := h.CacheStore.Set(key, outbytes, h.Cache.TTL); err != nil {
+		log.WithError(err).Warnln("plugin: failed to cache lambda response")
+	}
+}
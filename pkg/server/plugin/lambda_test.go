@@ -18,11 +18,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/skygeario/skygear-server/pkg/server/skytest"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/skygeario/skygear-server/pkg/server/handler/handlertest"
+	"github.com/skygeario/skygear-server/pkg/server/querycache"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 )
 
@@ -54,6 +56,34 @@ func TestLambdaCreation(t *testing.T) {
 
 		So(handler.AccessKeyRequired, ShouldBeTrue)
 	})
+
+	Convey("create cacheable lambda", t, func() {
+		handler := NewLambdaHandler(map[string]interface{}{
+			"name": "hello:world",
+			"cache": map[string]interface{}{
+				"ttl":          float64(30),
+				"vary_by_args": []interface{}{"city"},
+				"vary_by_user": true,
+			},
+		}, nil, nil)
+
+		So(handler.Cache, ShouldResemble, &LambdaCacheOptions{
+			TTL:        30 * time.Second,
+			VaryByArgs: []string{"city"},
+			VaryByUser: true,
+		})
+	})
+
+	Convey("create lambda with zero cache ttl", t, func() {
+		handler := NewLambdaHandler(map[string]interface{}{
+			"name": "hello:world",
+			"cache": map[string]interface{}{
+				"ttl": float64(0),
+			},
+		}, nil, nil)
+
+		So(handler.Cache, ShouldBeNil)
+	})
 }
 
 func TestLambdaHandler(t *testing.T) {
@@ -109,4 +139,100 @@ func TestLambdaHandler(t *testing.T) {
 		})
 
 	})
+
+	Convey("test request deadline exceeded", t, func() {
+		transport := &fakeTransport{}
+		plugin := Plugin{
+			transport: transport,
+		}
+		handler := LambdaHandler{
+			Plugin: &plugin,
+			Name:   "hello:world",
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		r := handlertest.NewSingleRouteRouter(&handler, func(p *router.Payload) {
+			p.Context = ctx
+		})
+
+		resp := r.POST(`{
+	"args": ["bob"]
+}`)
+		So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"error":{"code":119,"message":"plugin call exceeded the request deadline","name":"PluginTimeout"}
+}`)
+		So(transport.lastContext, ShouldBeNil)
+	})
+}
+
+func TestLambdaHandlerCache(t *testing.T) {
+	Convey("cacheable lambda", t, func() {
+		transport := &nullTransport{}
+		plugin := Plugin{
+			transport: transport,
+		}
+		handler := LambdaHandler{
+			Plugin: &plugin,
+			Name:   "hello:world",
+			Cache: &LambdaCacheOptions{
+				TTL:        time.Minute,
+				VaryByArgs: []string{"city"},
+			},
+			CacheStore:  querycache.NewMemoryStore(),
+			CachePrefix: "app:",
+		}
+		userID := "alice"
+		accessKey := router.ClientAccessKey
+		r := handlertest.NewSingleRouteRouter(&handler, func(p *router.Payload) {
+			p.Context = context.Background()
+			p.AuthInfoID = userID
+			p.AccessKey = accessKey
+		})
+
+		resp := r.POST(`{"args": {"city": "hk", "unit": "c"}}`)
+		So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {"args": {"city": "hk", "unit": "c"}}
+}`)
+		So(transport.lastContext, ShouldNotBeNil)
+		transport.lastContext = nil
+
+		Convey("serves repeats from cache", func() {
+			resp := r.POST(`{"args": {"city": "hk", "unit": "f"}}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {"args": {"city": "hk", "unit": "c"}}
+}`)
+			So(transport.lastContext, ShouldBeNil)
+		})
+
+		Convey("calls plugin for different args", func() {
+			resp := r.POST(`{"args": {"city": "tk", "unit": "c"}}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+	"result": {"args": {"city": "tk", "unit": "c"}}
+}`)
+			So(transport.lastContext, ShouldNotBeNil)
+		})
+
+		Convey("shares responses between users", func() {
+			userID = "bob"
+			r.POST(`{"args": {"city": "hk", "unit": "c"}}`)
+			So(transport.lastContext, ShouldBeNil)
+		})
+
+		Convey("varies responses by access key type", func() {
+			accessKey = router.MasterAccessKey
+			r.POST(`{"args": {"city": "hk", "unit": "c"}}`)
+			So(transport.lastContext, ShouldNotBeNil)
+		})
+
+		Convey("varies responses by user", func() {
+			handler.Cache.VaryByUser = true
+			r.POST(`{"args": {"city": "hk", "unit": "c"}}`)
+			So(transport.lastContext, ShouldNotBeNil)
+			transport.lastContext = nil
+
+			userID = "bob"
+			r.POST(`{"args": {"city": "hk", "unit": "c"}}`)
+			So(transport.lastContext, ShouldNotBeNil)
+		})
+	})
 }
@@ -25,9 +25,11 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/robfig/cron"
+	"github.com/skygeario/skygear-server/pkg/server/chaos"
 	"github.com/skygeario/skygear-server/pkg/server/logging"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
 	"github.com/skygeario/skygear-server/pkg/server/plugin/provider"
+	"github.com/skygeario/skygear-server/pkg/server/querycache"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
 )
@@ -123,19 +125,38 @@ type Context struct {
 	ProviderRegistry *provider.Registry
 	Scheduler        *cron.Cron
 	Config           skyconfig.Configuration
+
+	// Chaos drops calls to plugins for resilience testing if not nil.
+	Chaos *chaos.Injector
+
+	// LambdaCache stores the responses of lambdas declared cacheable.
+	// Responses are not cached if it is nil.
+	LambdaCache querycache.Store
+
+	// Secrets returns the secrets passed to plugins in the init payload,
+	// such as the client secrets of OAuth providers. No secrets are
+	// passed if it is nil.
+	Secrets func() map[string]string
 }
 
 // AddPluginConfiguration creates and appends a plugin
 func (c *Context) AddPluginConfiguration(name string, path string, args []string) *Plugin {
 	plug := NewPlugin(name, path, args, c.Config)
+	if c.Chaos != nil {
+		plug.transport = &chaosTransport{plug.transport, c.Chaos}
+	}
 	c.plugins = append(c.plugins, &plug)
 	return &plug
 }
 
 func (c *Context) getInitPayload() ([]byte, error) {
 	payload := struct {
-		Config skyconfig.Configuration `json:"config"`
-	}{c.Config}
+		Config  skyconfig.Configuration `json:"config"`
+		Secrets map[string]string       `json:"secrets,omitempty"`
+	}{c.Config, nil}
+	if c.Secrets != nil {
+		payload.Secrets = c.Secrets()
+	}
 
 	return json.Marshal(payload)
 }
@@ -290,7 +311,7 @@ func (p *Plugin) processRegistrationInfo(context *Context, regInfo registrationI
 		"transport": p.transport,
 	}).Debugln("Got configuration from plugin, registering")
 	p.initHandler(context.Mux, context.Preprocessors, regInfo.Handlers, context.Config)
-	p.initLambda(context.Router, context.Preprocessors, regInfo.Lambdas)
+	p.initLambda(context, regInfo.Lambdas)
 	p.initHook(context.HookRegistry, regInfo.Hooks)
 	if context.Scheduler != nil {
 		p.initTimer(context.Scheduler, regInfo.Timers)
@@ -323,11 +344,15 @@ func (p *Plugin) initHandler(mux *http.ServeMux, ppreg router.PreprocessorRegist
 	}
 }
 
-func (p *Plugin) initLambda(r *router.Router, ppreg router.PreprocessorRegistry, lambdas []map[string]interface{}) {
+func (p *Plugin) initLambda(context *Context, lambdas []map[string]interface{}) {
 	for _, lambda := range lambdas {
-		handler := NewLambdaHandler(lambda, ppreg, p)
+		handler := NewLambdaHandler(lambda, context.Preprocessors, p)
+		if handler.Cache != nil {
+			handler.CacheStore = context.LambdaCache
+			handler.CachePrefix = context.Config.App.Name + ":"
+		}
 		handler.Setup()
-		r.Map(handler.Name, handler)
+		context.Router.Map(handler.Name, handler)
 		log.Debugf(`Registered lambda "%s" with router.`, handler.Name)
 	}
 }
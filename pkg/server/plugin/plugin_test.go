@@ -15,6 +15,8 @@
 package plugin
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"testing"
 
@@ -22,6 +24,7 @@ import (
 
 	"github.com/robfig/cron"
 
+	"github.com/skygeario/skygear-server/pkg/server/chaos"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
 )
@@ -64,6 +67,61 @@ func TestPlugin(t *testing.T) {
 		So(plugin.transport, ShouldHaveSameTypeAs, &nullTransport{})
 	})
 
+	Convey("new plugin with chaos injector", t, func() {
+		defer unregisterAllTransports()
+
+		RegisterTransport("null", nullFactory{})
+
+		ctx := &Context{
+			Config: config,
+			Chaos:  &chaos.Injector{DropRate: 1, Actions: []string{"dropped"}},
+		}
+		plugin := ctx.AddPluginConfiguration("null", "/tmp/nonexistent", []string{})
+		So(plugin.transport, ShouldHaveSameTypeAs, &chaosTransport{})
+
+		_, err := plugin.transport.RunLambda(context.Background(), "dropped", []byte{})
+		So(err, ShouldEqual, chaos.ErrDropped)
+
+		_, err = plugin.transport.RunLambda(context.Background(), "hello", []byte{})
+		So(err, ShouldBeNil)
+	})
+
+	Convey("chaos transport forwards router to bidirectional transport", t, func() {
+		inner := &bidirectionalTransport{}
+		var transport Transport = &chaosTransport{
+			Transport: inner,
+			injector:  &chaos.Injector{},
+		}
+
+		bidirectional, ok := transport.(BidirectionalTransport)
+		So(ok, ShouldBeTrue)
+
+		r := router.NewRouter()
+		bidirectional.SetRouter(r)
+		So(inner.router, ShouldEqual, r)
+	})
+
+	Convey("init payload includes secrets", t, func() {
+		ctx := &Context{Config: config}
+
+		data, err := ctx.getInitPayload()
+		So(err, ShouldBeNil)
+		payload := map[string]interface{}{}
+		So(json.Unmarshal(data, &payload), ShouldBeNil)
+		So(payload, ShouldNotContainKey, "secrets")
+
+		ctx.Secrets = func() map[string]string {
+			return map[string]string{"smtp_password": "SMTP_PASSWORD"}
+		}
+		data, err = ctx.getInitPayload()
+		So(err, ShouldBeNil)
+		payload = map[string]interface{}{}
+		So(json.Unmarshal(data, &payload), ShouldBeNil)
+		So(payload["secrets"], ShouldResemble, map[string]interface{}{
+			"smtp_password": "SMTP_PASSWORD",
+		})
+	})
+
 	Convey("panic unable to register timer", t, func() {
 		RegisterTransport("null", nullFactory{})
 		plugin := NewPlugin("null", "/tmp/nonexistent", []string{}, config)
@@ -117,3 +175,12 @@ func TestPlugin(t *testing.T) {
 	})
 
 }
+
+type bidirectionalTransport struct {
+	nullTransport
+	router *router.Router
+}
+
+func (t *bidirectionalTransport) SetRouter(r *router.Router) {
+	t.router = r
+}
@@ -88,6 +88,10 @@ func NewTimerRequest(name string) *Request {
 func (req *Request) MarshalJSON() ([]byte, error) {
 	// TODO(limouren): reduce copying of this method
 	pluginCtx := skyplugin.ContextMap(req.Context)
+	if req.Async {
+		// the transaction is finished before async hooks are run
+		delete(pluginCtx, "transaction_id")
+	}
 	if rawParam, ok := req.Param.(json.RawMessage); ok {
 		rawParamReq := struct {
 			Kind    string                 `json:"kind"`
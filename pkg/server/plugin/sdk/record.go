@@ -0,0 +1,105 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// DecodeRecord sets the fields of the struct pointed to by v from the data
+// of the record. Struct fields are mapped to record fields with the
+// `skygear` struct tag, and fields without the tag are left untouched:
+//
+//	type Note struct {
+//		Content string    `skygear:"content"`
+//		Likes   int       `skygear:"likes"`
+//		DueAt   time.Time `skygear:"due_at"`
+//	}
+//
+// A number in the record can be decoded into any numeric field.
+func DecodeRecord(record *skydb.Record, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("sdk: decode record into %T, want pointer to struct", v)
+	}
+
+	structValue := rv.Elem()
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		key := structType.Field(i).Tag.Get("skygear")
+		if key == "" {
+			continue
+		}
+
+		value, ok := record.Data[key]
+		if !ok || value == nil {
+			continue
+		}
+
+		field := structValue.Field(i)
+		dataValue := reflect.ValueOf(value)
+		switch {
+		case dataValue.Type().AssignableTo(field.Type()):
+			field.Set(dataValue)
+		case isNumberKind(dataValue.Kind()) && isNumberKind(field.Kind()):
+			field.Set(dataValue.Convert(field.Type()))
+		default:
+			return fmt.Errorf("sdk: cannot decode %s of type %T into %s", key, value, field.Type())
+		}
+	}
+	return nil
+}
+
+// EncodeRecord sets the data of the record from the fields of the struct v
+// with the `skygear` struct tag. See DecodeRecord for the struct tag.
+func EncodeRecord(v interface{}, record *skydb.Record) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("sdk: encode record from %T, want struct", v)
+	}
+
+	if record.Data == nil {
+		record.Data = map[string]interface{}{}
+	}
+
+	structType := rv.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		key := structType.Field(i).Tag.Get("skygear")
+		if key == "" {
+			continue
+		}
+
+		field := rv.Field(i)
+		if isNumberKind(field.Kind()) {
+			record.Data[key] = field.Convert(reflect.TypeOf(float64(0))).Interface()
+		} else {
+			record.Data[key] = field.Interface()
+		}
+	}
+	return nil
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sdk is for writing Skygear Server plugins in Go.
+//
+// Lambdas, handlers, hooks, timers, auth providers and events are
+// registered with a Plugin, which serves the requests of the http plugin
+// transport:
+//
+//	p := sdk.New()
+//	p.Lambda("hello", func(ctx sdk.Context, args json.RawMessage) (interface{}, error) {
+//		return map[string]interface{}{"message": "hello " + ctx.UserID}, nil
+//	}, sdk.UserRequired())
+//	p.Hook(sdk.BeforeSave, "note", "check_note", checkNote)
+//	log.Fatal(p.ListenAndServe(":8000"))
+//
+// and the server is configured with the plugin like this:
+//
+//	PLUGINS=GO
+//	GO_TRANSPORT=http
+//	GO_PATH=http://localhost:8000
+package sdk
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Context is the context of the request sent by Skygear Server.
+type Context struct {
+	UserID        string `json:"user_id"`
+	RequestID     string `json:"request_id"`
+	AccessKeyType string `json:"access_key_type"`
+
+	// Deadline is the deadline of the request, which is zero if the
+	// request has no deadline. RemainingMS is the remaining time in
+	// milliseconds when the plugin is called.
+	Deadline    time.Time `json:"deadline"`
+	RemainingMS int64     `json:"remaining_ms"`
+}
+
+// HasMasterKey returns true if the request is made with the master key.
+func (ctx Context) HasMasterKey() bool {
+	return ctx.AccessKeyType == "master"
+}
+
+// Remaining returns the time remaining before the deadline of the request
+// when the plugin is called, so that the plugin can bound its own calls.
+// It returns false if the request has no deadline.
+func (ctx Context) Remaining() (time.Duration, bool) {
+	if ctx.Deadline.IsZero() {
+		return 0, false
+	}
+	return time.Duration(ctx.RemainingMS) * time.Millisecond, true
+}
+
+// LambdaFunc runs a lambda with the arguments supplied by the client. The
+// result is encoded as JSON.
+type LambdaFunc func(ctx Context, args json.RawMessage) (interface{}, error)
+
+// HandlerFunc handles an HTTP request to the path of the handler.
+type HandlerFunc func(ctx Context, req *HTTPRequest) (*HTTPResponse, error)
+
+// HookFunc runs a hook on the record being saved or deleted. original is
+// nil if the record is being created. Modifications to record in a
+// before save hook are saved.
+type HookFunc func(ctx Context, record *skydb.Record, original *skydb.Record) error
+
+// TimerFunc runs a timer.
+type TimerFunc func(ctx Context) (interface{}, error)
+
+// EventFunc handles an event sent by Skygear Server, such as
+// "server-ready".
+type EventFunc func(data json.RawMessage) error
+
+// AuthProvider authenticates users with an external service.
+type AuthProvider interface {
+	Login(ctx Context, authData map[string]interface{}) (principalID string, newAuthData map[string]interface{}, err error)
+	Logout(ctx Context, authData map[string]interface{}) (newAuthData map[string]interface{}, err error)
+	Info(ctx Context, authData map[string]interface{}) (newAuthData map[string]interface{}, err error)
+}
+
+// HookTrigger is the database operation which triggers a hook.
+type HookTrigger string
+
+// The hook triggers supported by Skygear Server.
+const (
+	BeforeSave   = HookTrigger(hook.BeforeSave)
+	AfterSave    = HookTrigger(hook.AfterSave)
+	BeforeDelete = HookTrigger(hook.BeforeDelete)
+	AfterDelete  = HookTrigger(hook.AfterDelete)
+)
+
+// HTTPRequest is the HTTP request to a handler.
+type HTTPRequest struct {
+	Method      string              `json:"method,omitempty"`
+	Header      map[string][]string `json:"header"`
+	Body        []byte              `json:"body"`
+	Path        string              `json:"path,omitempty"`
+	QueryString string              `json:"query_string,omitempty"`
+}
+
+// HTTPResponse is the HTTP response of a handler.
+type HTTPResponse struct {
+	Status int                 `json:"status"`
+	Header map[string][]string `json:"header"`
+	Body   []byte              `json:"body"`
+}
+
+// Option configures a registered lambda, handler or hook.
+type Option func(*registration)
+
+type registration struct {
+	keyRequired  bool
+	userRequired bool
+	async        bool
+}
+
+// KeyRequired requires the request to the lambda or handler to be made
+// with an API key.
+func KeyRequired() Option {
+	return func(r *registration) {
+		r.keyRequired = true
+	}
+}
+
+// UserRequired requires the request to the lambda or handler to be made
+// by a logged in user.
+func UserRequired() Option {
+	return func(r *registration) {
+		r.userRequired = true
+	}
+}
+
+// Async runs the hook without blocking the database operation.
+// Modifications to the record in an async hook are not saved.
+func Async() Option {
+	return func(r *registration) {
+		r.async = true
+	}
+}
+
+func newRegistration(opts []Option) registration {
+	r := registration{}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
+
+type lambdaEntry struct {
+	registration
+	fn LambdaFunc
+}
+
+type handlerEntry struct {
+	registration
+	methods []string
+	fn      HandlerFunc
+}
+
+type hookEntry struct {
+	registration
+	trigger    HookTrigger
+	recordType string
+	fn         HookFunc
+}
+
+type timerEntry struct {
+	spec string
+	fn   TimerFunc
+}
+
+// Plugin holds the functions registered by a Go plugin. Functions should
+// be registered before the plugin serves requests.
+type Plugin struct {
+	lambdas       map[string]lambdaEntry
+	lambdaNames   []string
+	handlers      map[string]handlerEntry
+	handlerNames  []string
+	hooks         map[string]hookEntry
+	hookNames     []string
+	timers        map[string]timerEntry
+	timerNames    []string
+	providers     map[string]AuthProvider
+	providerNames []string
+	events        map[string][]EventFunc
+}
+
+// New returns a Plugin without registered functions.
+func New() *Plugin {
+	return &Plugin{
+		lambdas:   map[string]lambdaEntry{},
+		handlers:  map[string]handlerEntry{},
+		hooks:     map[string]hookEntry{},
+		timers:    map[string]timerEntry{},
+		providers: map[string]AuthProvider{},
+		events:    map[string][]EventFunc{},
+	}
+}
+
+// Lambda registers the function as a lambda, which is called by the
+// action of the name.
+func (p *Plugin) Lambda(name string, fn LambdaFunc, opts ...Option) {
+	if _, ok := p.lambdas[name]; !ok {
+		p.lambdaNames = append(p.lambdaNames, name)
+	}
+	p.lambdas[name] = lambdaEntry{newRegistration(opts), fn}
+}
+
+// Handler registers the function as a handler of HTTP requests to the
+// path of the name with the methods.
+func (p *Plugin) Handler(name string, methods []string, fn HandlerFunc, opts ...Option) {
+	if _, ok := p.handlers[name]; !ok {
+		p.handlerNames = append(p.handlerNames, name)
+	}
+	p.handlers[name] = handlerEntry{newRegistration(opts), methods, fn}
+}
+
+// Hook registers the function as a hook of the name, which is triggered
+// by the database operation on records of the type.
+func (p *Plugin) Hook(trigger HookTrigger, recordType string, name string, fn HookFunc, opts ...Option) {
+	if _, ok := p.hooks[name]; !ok {
+		p.hookNames = append(p.hookNames, name)
+	}
+	p.hooks[name] = hookEntry{newRegistration(opts), trigger, recordType, fn}
+}
+
+// Timer registers the function as a timer of the name, which is run on
+// the cron spec.
+func (p *Plugin) Timer(name string, spec string, fn TimerFunc) {
+	if _, ok := p.timers[name]; !ok {
+		p.timerNames = append(p.timerNames, name)
+	}
+	p.timers[name] = timerEntry{spec, fn}
+}
+
+// Provider registers the auth provider of the name.
+func (p *Plugin) Provider(name string, provider AuthProvider) {
+	if _, ok := p.providers[name]; !ok {
+		p.providerNames = append(p.providerNames, name)
+	}
+	p.providers[name] = provider
+}
+
+// Event registers the function as a handler of the event. Multiple
+// functions can handle the same event.
+func (p *Plugin) Event(name string, fn EventFunc) {
+	p.events[name] = append(p.events[name], fn)
+}
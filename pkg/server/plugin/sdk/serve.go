@@ -0,0 +1,282 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// request is the request sent by the http plugin transport.
+type request struct {
+	Kind    string          `json:"kind"`
+	Name    string          `json:"name"`
+	Param   json.RawMessage `json:"param"`
+	Context Context         `json:"context"`
+}
+
+type hookParam struct {
+	Record   *skyconv.JSONRecord `json:"record"`
+	Original *skyconv.JSONRecord `json:"original"`
+}
+
+type providerParam struct {
+	Action   string                 `json:"action"`
+	AuthData map[string]interface{} `json:"auth_data"`
+}
+
+type providerResult struct {
+	PrincipalID string                 `json:"principal_id,omitempty"`
+	AuthData    map[string]interface{} `json:"auth_data"`
+}
+
+// ListenAndServe serves the requests of the http plugin transport on the
+// address.
+func (p *Plugin) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, p)
+}
+
+// ServeHTTP implements http.Handler. It serves the requests of the http
+// plugin transport.
+func (p *Plugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := request{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]interface{}{}
+	if result, err := p.dispatch(&req); err != nil {
+		resp["error"] = errorMap(err)
+	} else {
+		resp["result"] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (p *Plugin) dispatch(req *request) (interface{}, error) {
+	switch req.Kind {
+	case "event":
+		return p.dispatchEvent(req)
+	case "op":
+		entry, ok := p.lambdas[req.Name]
+		if !ok {
+			return nil, notFound(req)
+		}
+		return entry.fn(req.Context, req.Param)
+	case "handler":
+		return p.dispatchHandler(req)
+	case "hook":
+		return p.dispatchHook(req)
+	case "timer":
+		entry, ok := p.timers[req.Name]
+		if !ok {
+			return nil, notFound(req)
+		}
+		return entry.fn(req.Context)
+	case "provider":
+		return p.dispatchProvider(req)
+	default:
+		return nil, skyerr.NewErrorf(skyerr.NotSupported, "unknown request kind %s", req.Kind)
+	}
+}
+
+func (p *Plugin) dispatchEvent(req *request) (interface{}, error) {
+	for _, fn := range p.events[req.Name] {
+		if err := fn(req.Param); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Name == "init" {
+		return p.registrationInfo(), nil
+	}
+	return nil, nil
+}
+
+func (p *Plugin) dispatchHandler(req *request) (interface{}, error) {
+	entry, ok := p.handlers[req.Name]
+	if !ok {
+		return nil, notFound(req)
+	}
+
+	httpReq := HTTPRequest{}
+	if err := json.Unmarshal(req.Param, &httpReq); err != nil {
+		return nil, skyerr.NewErrorf(skyerr.BadRequest, "failed to decode handler request: %v", err)
+	}
+	httpResp, err := entry.fn(req.Context, &httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp == nil {
+		httpResp = &HTTPResponse{}
+	}
+	if httpResp.Status == 0 {
+		httpResp.Status = http.StatusOK
+	}
+	return httpResp, nil
+}
+
+func (p *Plugin) dispatchHook(req *request) (interface{}, error) {
+	entry, ok := p.hooks[req.Name]
+	if !ok {
+		return nil, notFound(req)
+	}
+
+	param := hookParam{}
+	if err := json.Unmarshal(req.Param, &param); err != nil {
+		return nil, skyerr.NewErrorf(skyerr.BadRequest, "failed to decode hook request: %v", err)
+	}
+	if param.Record == nil {
+		return nil, skyerr.NewError(skyerr.BadRequest, "missing record in hook request")
+	}
+
+	record := (*skydb.Record)(param.Record)
+	if err := entry.fn(req.Context, record, (*skydb.Record)(param.Original)); err != nil {
+		return nil, err
+	}
+	return param.Record, nil
+}
+
+func (p *Plugin) dispatchProvider(req *request) (interface{}, error) {
+	provider, ok := p.providers[req.Name]
+	if !ok {
+		return nil, notFound(req)
+	}
+
+	param := providerParam{}
+	if err := json.Unmarshal(req.Param, &param); err != nil {
+		return nil, skyerr.NewErrorf(skyerr.BadRequest, "failed to decode provider request: %v", err)
+	}
+
+	var (
+		result = providerResult{}
+		err    error
+	)
+	switch param.Action {
+	case "login":
+		result.PrincipalID, result.AuthData, err = provider.Login(req.Context, param.AuthData)
+	case "logout":
+		result.AuthData, err = provider.Logout(req.Context, param.AuthData)
+	case "info":
+		result.AuthData, err = provider.Info(req.Context, param.AuthData)
+	default:
+		err = skyerr.NewErrorf(skyerr.NotSupported, "unknown provider action %s", param.Action)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// registrationInfo returns the functions registered with the plugin in
+// the format of the response to the init event.
+func (p *Plugin) registrationInfo() map[string]interface{} {
+	lambdas := []map[string]interface{}{}
+	for _, name := range p.lambdaNames {
+		entry := p.lambdas[name]
+		lambdas = append(lambdas, map[string]interface{}{
+			"name":          name,
+			"key_required":  entry.keyRequired,
+			"user_required": entry.userRequired,
+		})
+	}
+
+	handlers := []map[string]interface{}{}
+	for _, name := range p.handlerNames {
+		entry := p.handlers[name]
+		handlers = append(handlers, map[string]interface{}{
+			"name":          name,
+			"methods":       entry.methods,
+			"key_required":  entry.keyRequired,
+			"user_required": entry.userRequired,
+		})
+	}
+
+	hooks := []map[string]interface{}{}
+	for _, name := range p.hookNames {
+		entry := p.hooks[name]
+		hooks = append(hooks, map[string]interface{}{
+			"name":    name,
+			"trigger": string(entry.trigger),
+			"type":    entry.recordType,
+			"async":   entry.async,
+		})
+	}
+
+	timers := []map[string]interface{}{}
+	for _, name := range p.timerNames {
+		timers = append(timers, map[string]interface{}{
+			"name": name,
+			"spec": p.timers[name].spec,
+		})
+	}
+
+	providers := []map[string]interface{}{}
+	for _, name := range p.providerNames {
+		providers = append(providers, map[string]interface{}{
+			"type": "auth",
+			"id":   name,
+		})
+	}
+
+	return map[string]interface{}{
+		"op":       lambdas,
+		"handler":  handlers,
+		"hook":     hooks,
+		"timer":    timers,
+		"provider": providers,
+	}
+}
+
+func notFound(req *request) skyerr.Error {
+	return skyerr.NewErrorf(skyerr.ResourceNotFound, "%s %s is not registered", req.Kind, req.Name)
+}
+
+// errorMap converts the error returned by a registered function to the
+// error in the response. Errors other than skyerr.Error are reported as
+// UnexpectedError.
+func errorMap(err error) map[string]interface{} {
+	skyErr, ok := err.(skyerr.Error)
+	if !ok {
+		skyErr = skyerr.NewError(skyerr.UnexpectedError, fmt.Sprintf("%v", err))
+	}
+
+	m := map[string]interface{}{
+		"name":    skyErr.Name(),
+		"code":    skyErr.Code(),
+		"message": skyErr.Message(),
+	}
+	if info := skyErr.Info(); info != nil {
+		m["info"] = info
+	}
+	return m
+}
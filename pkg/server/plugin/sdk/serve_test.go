@@ -0,0 +1,182 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type note struct {
+	Content string `skygear:"content"`
+	Likes   int    `skygear:"likes"`
+	Secret  string
+}
+
+func TestPlugin(t *testing.T) {
+	Convey("Plugin", t, func() {
+		p := New()
+
+		post := func(body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("POST", "http://localhost:8000/", strings.NewReader(body))
+			resp := httptest.NewRecorder()
+			p.ServeHTTP(resp, req)
+			return resp
+		}
+
+		Convey("returns registered functions on init", func() {
+			p.Lambda("hello", func(ctx Context, args json.RawMessage) (interface{}, error) {
+				return nil, nil
+			}, UserRequired())
+			p.Handler("ping", []string{"GET"}, func(ctx Context, req *HTTPRequest) (*HTTPResponse, error) {
+				return nil, nil
+			}, KeyRequired())
+			p.Hook(BeforeSave, "note", "check_note", func(ctx Context, record *skydb.Record, original *skydb.Record) error {
+				return nil
+			}, Async())
+			p.Timer("cleanup", "@every 1h", func(ctx Context) (interface{}, error) {
+				return nil, nil
+			})
+
+			resp := post(`{"kind": "event", "name": "init", "param": {}}`)
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {
+					"op": [{"name": "hello", "key_required": false, "user_required": true}],
+					"handler": [{"name": "ping", "methods": ["GET"], "key_required": true, "user_required": false}],
+					"hook": [{"name": "check_note", "trigger": "beforeSave", "type": "note", "async": true}],
+					"timer": [{"name": "cleanup", "spec": "@every 1h"}],
+					"provider": []
+				}
+			}`)
+		})
+
+		Convey("runs lambda with context", func() {
+			p.Lambda("hello", func(ctx Context, args json.RawMessage) (interface{}, error) {
+				var name string
+				if err := json.Unmarshal(args, &name); err != nil {
+					return nil, err
+				}
+				return map[string]interface{}{"message": "hello " + name, "user_id": ctx.UserID}, nil
+			})
+
+			resp := post(`{"kind": "op", "name": "hello", "param": "world", "context": {"user_id": "user1"}}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {"message": "hello world", "user_id": "user1"}
+			}`)
+		})
+
+		Convey("runs hook on record", func() {
+			p.Hook(BeforeSave, "note", "check_note", func(ctx Context, record *skydb.Record, original *skydb.Record) error {
+				n := note{}
+				if err := DecodeRecord(record, &n); err != nil {
+					return err
+				}
+				if n.Content == "" {
+					return skyerr.NewInvalidArgument("empty content", []string{"content"})
+				}
+				n.Likes++
+				return EncodeRecord(n, record)
+			})
+
+			resp := post(`{
+				"kind": "hook",
+				"name": "check_note",
+				"param": {
+					"record": {"_id": "note/1", "_type": "record", "_access": null, "content": "hi", "likes": 1}
+				}
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"result": {"_id": "note/1", "_type": "record", "_access": null, "content": "hi", "likes": 2}
+			}`)
+
+			resp = post(`{
+				"kind": "hook",
+				"name": "check_note",
+				"param": {
+					"record": {"_id": "note/1", "_type": "record", "_access": null, "content": ""}
+				}
+			}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "empty content",
+					"info": {"arguments": ["content"]}
+				}
+			}`)
+		})
+
+		Convey("reports non-skygear error as unexpected error", func() {
+			p.Timer("cleanup", "@every 1h", func(ctx Context) (interface{}, error) {
+				return nil, errors.New("disk full")
+			})
+
+			resp := post(`{"kind": "timer", "name": "cleanup"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {"name": "UnexpectedError", "code": 10000, "message": "disk full"}
+			}`)
+		})
+
+		Convey("reports unregistered function as not found", func() {
+			resp := post(`{"kind": "op", "name": "missing"}`)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {"name": "ResourceNotFound", "code": 110, "message": "op missing is not registered"}
+			}`)
+		})
+	})
+}
+
+func TestDecodeRecord(t *testing.T) {
+	Convey("DecodeRecord", t, func() {
+		record := &skydb.Record{
+			ID: skydb.NewRecordID("note", "1"),
+			Data: map[string]interface{}{
+				"content": "hello",
+				"likes":   float64(3),
+				"Secret":  "ignored",
+			},
+		}
+
+		Convey("decodes tagged fields", func() {
+			n := note{}
+			So(DecodeRecord(record, &n), ShouldBeNil)
+			So(n, ShouldResemble, note{Content: "hello", Likes: 3})
+		})
+
+		Convey("rejects value of mismatched type", func() {
+			record.Data["content"] = float64(1)
+			So(DecodeRecord(record, &note{}), ShouldNotBeNil)
+		})
+
+		Convey("encodes tagged fields", func() {
+			out := &skydb.Record{}
+			So(EncodeRecord(note{Content: "hi", Likes: 2}, out), ShouldBeNil)
+			So(out.Data, ShouldResemble, map[string]interface{}{
+				"content": "hi",
+				"likes":   float64(2),
+			})
+		})
+	})
+}
@@ -0,0 +1,338 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transaction exposes the database transactions of record
+// operations to plugins, so that the record operations of a hook are
+// committed or rolled back together with the operation triggering it.
+//
+// When the hooks of an operation are run in a transaction, the hook
+// request carries the ID of the transaction in its context. The plugin
+// begins a unit of work in the transaction with transaction:begin, which
+// returns a token. Requests carrying the token in transaction_id run on the
+// connection of the transaction. The unit of work is finished with
+// transaction:commit, or with transaction:rollback, which rolls back the
+// whole transaction including the triggering operation.
+//
+// The connection of a transaction is used by one request at a time. The
+// triggering operation holds it until it leaves the transaction, except
+// while it waits for a synchronous hook, and requests of units of work
+// hold it for the whole request. A request of a unit of work fails once
+// the unit of work or the transaction is finished.
+package transaction
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+// ErrNotFound is returned when the transaction or the token does not
+// exist, or is already finished.
+var ErrNotFound = errors.New("transaction: not found")
+
+// ErrRolledBack is returned by Registry.Leave if a plugin rolled back the
+// transaction, or left a unit of work unfinished.
+var ErrRolledBack = errors.New("transaction: rolled back by plugin")
+
+// Transaction is either the transaction of a triggering operation, or a
+// unit of work begun in it by a plugin, which shares the connection of
+// the transaction.
+type Transaction struct {
+	id   string
+	conn skydb.Conn
+
+	// root is the transaction of the triggering operation, or nil if
+	// this is the transaction of the triggering operation.
+	root *Transaction
+
+	// connMutex of the root is held by the request using the connection.
+	connMutex sync.Mutex
+
+	// The following fields of the root are guarded by the mutex of the
+	// Registry.
+	rollbackOnly bool
+	tokens       map[string]bool
+}
+
+// ID returns the ID of the transaction, or the token of the unit of work.
+func (tx *Transaction) ID() string {
+	return tx.id
+}
+
+// RootID returns the ID of the transaction of the triggering operation.
+func (tx *Transaction) RootID() string {
+	return tx.rootTransaction().id
+}
+
+// Conn returns the connection of the transaction.
+func (tx *Transaction) Conn() skydb.Conn {
+	return tx.conn
+}
+
+func (tx *Transaction) rootTransaction() *Transaction {
+	if tx.root != nil {
+		return tx.root
+	}
+	return tx
+}
+
+// Registry keeps the transactions exposed to plugins. A nil Registry
+// exposes no transactions.
+type Registry struct {
+	mutex        sync.Mutex
+	transactions map[string]*Transaction
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		transactions: map[string]*Transaction{},
+	}
+}
+
+// Yield releases the connection of the transaction while fn is run, such
+// as when the holder waits for a synchronous hook, so that the requests of
+// the units of work of the hook can use the connection. It waits for such
+// requests to finish before returning.
+func (tx *Transaction) Yield(fn func()) {
+	if tx == nil {
+		fn()
+		return
+	}
+
+	root := tx.rootTransaction()
+	root.connMutex.Unlock()
+	defer root.connMutex.Lock()
+	fn()
+}
+
+// Join exposes the transaction in effect on the connection, which is
+// removed by Leave when the triggering operation finishes. The connection
+// is held by the caller until Leave.
+func (r *Registry) Join(conn skydb.Conn) *Transaction {
+	if r == nil {
+		return nil
+	}
+
+	tx := &Transaction{
+		id:     uuid.New(),
+		conn:   conn,
+		tokens: map[string]bool{},
+	}
+	tx.connMutex.Lock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.transactions[tx.id] = tx
+	return tx
+}
+
+// Leave removes the transaction and its units of work, and releases the
+// connection. It returns ErrRolledBack if the transaction should be rolled
+// back instead of being committed.
+func (r *Registry) Leave(tx *Transaction) error {
+	if r == nil || tx == nil {
+		return nil
+	}
+
+	root := tx.rootTransaction()
+	defer root.connMutex.Unlock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.transactions, root.id)
+	for token, finished := range root.tokens {
+		delete(r.transactions, token)
+		if !finished {
+			root.rollbackOnly = true
+		}
+	}
+
+	if root.rollbackOnly {
+		return ErrRolledBack
+	}
+	return nil
+}
+
+// Begin begins a unit of work in the transaction of the ID, and returns
+// the unit of work, of which the ID is the token.
+func (r *Registry) Begin(id string) (*Transaction, error) {
+	if r == nil {
+		return nil, ErrNotFound
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	root, ok := r.transactions[id]
+	if !ok || root.root != nil {
+		return nil, ErrNotFound
+	}
+
+	tx := &Transaction{
+		id:   uuid.New(),
+		conn: root.conn,
+		root: root,
+	}
+	r.transactions[tx.id] = tx
+	root.tokens[tx.id] = false
+	return tx, nil
+}
+
+// Get returns the unfinished unit of work of the token.
+func (r *Registry) Get(token string) (*Transaction, error) {
+	if r == nil {
+		return nil, ErrNotFound
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.get(token)
+}
+
+// Acquire returns the unfinished unit of work of the token, waiting until
+// the connection of the transaction is released by the request using it.
+// The connection is held by the caller until Release. ErrNotFound is
+// returned if the unit of work or the transaction is finished meanwhile.
+func (r *Registry) Acquire(token string) (*Transaction, error) {
+	tx, err := r.Get(token)
+	if err != nil {
+		return nil, err
+	}
+
+	tx.root.connMutex.Lock()
+
+	r.mutex.Lock()
+	_, err = r.get(token)
+	r.mutex.Unlock()
+	if err != nil {
+		tx.root.connMutex.Unlock()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Release releases the connection of the unit of work acquired by
+// Acquire.
+func (r *Registry) Release(tx *Transaction) {
+	if r == nil || tx == nil {
+		return
+	}
+	tx.rootTransaction().connMutex.Unlock()
+}
+
+func (r *Registry) get(token string) (*Transaction, error) {
+	tx, ok := r.transactions[token]
+	if !ok || tx.root == nil || tx.root.tokens[token] {
+		return nil, ErrNotFound
+	}
+	return tx, nil
+}
+
+// Commit finishes the unit of work of the token. Its changes are committed
+// with the transaction.
+func (r *Registry) Commit(token string) error {
+	return r.finish(token, false)
+}
+
+// Rollback finishes the unit of work of the token, and marks the
+// transaction to be rolled back.
+func (r *Registry) Rollback(token string) error {
+	return r.finish(token, true)
+}
+
+// MarkRollback marks the transaction of the unit of work to be rolled
+// back, such as when an atomic operation in the unit of work fails.
+func (r *Registry) MarkRollback(tx *Transaction) {
+	if r == nil || tx == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	tx.rootTransaction().rollbackOnly = true
+}
+
+func (r *Registry) finish(token string, rollback bool) error {
+	if r == nil {
+		return ErrNotFound
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tx, err := r.get(token)
+	if err != nil {
+		return err
+	}
+	tx.root.tokens[token] = true
+	if rollback {
+		tx.root.rollbackOnly = true
+	}
+	return nil
+}
+
+// Middleware returns a router.Middleware holding the connection of the
+// unit of work of the transaction_id in the request for the whole request.
+// The unit of work is carried by the context of the payload.
+func Middleware(r *Registry) router.Middleware {
+	return func(payload *router.Payload, response *router.Response, next func() int) int {
+		token, _ := payload.Data["transaction_id"].(string)
+		if r == nil || token == "" {
+			return next()
+		}
+
+		tx, err := r.Acquire(token)
+		if err != nil {
+			// transaction:begin carries the ID of the transaction instead
+			// of a token, which is not held. Other requests are rejected
+			// by the preprocessors as the unit of work is not found.
+			return next()
+		}
+		defer r.Release(tx)
+
+		payload.Context = NewContext(payload.Context, tx)
+		return next()
+	}
+}
+
+type contextKey string
+
+var transactionContextKey = contextKey("transaction")
+
+// NewContext returns a context carrying the transaction, which is
+// included in the context of hook requests.
+func NewContext(ctx context.Context, tx *Transaction) context.Context {
+	if tx == nil {
+		return ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, transactionContextKey, tx)
+}
+
+// FromContext returns the transaction carried by the context, or nil.
+func FromContext(ctx context.Context) *Transaction {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(transactionContextKey).(*Transaction)
+	return tx
+}
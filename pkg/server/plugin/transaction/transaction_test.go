@@ -0,0 +1,191 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("Registry", t, func() {
+		registry := NewRegistry()
+		conn := skydbtest.NewMapConn()
+		root := registry.Join(conn)
+
+		Convey("begins unit of work on the connection", func() {
+			tx, err := registry.Begin(root.ID())
+			So(err, ShouldBeNil)
+			So(tx.ID(), ShouldNotEqual, root.ID())
+			So(tx.RootID(), ShouldEqual, root.ID())
+			So(tx.Conn(), ShouldEqual, conn)
+
+			got, err := registry.Get(tx.ID())
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, tx)
+		})
+
+		Convey("does not return transaction not begun by plugin", func() {
+			_, err := registry.Get(root.ID())
+			So(err, ShouldEqual, ErrNotFound)
+		})
+
+		Convey("does not begin unit of work in unit of work", func() {
+			tx, _ := registry.Begin(root.ID())
+			_, err := registry.Begin(tx.ID())
+			So(err, ShouldEqual, ErrNotFound)
+		})
+
+		Convey("commits when units of work are committed", func() {
+			tx, _ := registry.Begin(root.ID())
+			So(registry.Commit(tx.ID()), ShouldBeNil)
+
+			_, err := registry.Get(tx.ID())
+			So(err, ShouldEqual, ErrNotFound)
+			So(registry.Commit(tx.ID()), ShouldEqual, ErrNotFound)
+			So(registry.Leave(root), ShouldBeNil)
+		})
+
+		Convey("rolls back when unit of work is rolled back", func() {
+			committed, _ := registry.Begin(root.ID())
+			rolledBack, _ := registry.Begin(root.ID())
+			So(registry.Commit(committed.ID()), ShouldBeNil)
+			So(registry.Rollback(rolledBack.ID()), ShouldBeNil)
+			So(registry.Leave(root), ShouldEqual, ErrRolledBack)
+		})
+
+		Convey("rolls back when unit of work is unfinished", func() {
+			registry.Begin(root.ID())
+			So(registry.Leave(root), ShouldEqual, ErrRolledBack)
+		})
+
+		Convey("rolls back when marked", func() {
+			tx, _ := registry.Begin(root.ID())
+			registry.MarkRollback(tx)
+			So(registry.Commit(tx.ID()), ShouldBeNil)
+			So(registry.Leave(root), ShouldEqual, ErrRolledBack)
+		})
+
+		Convey("removes transaction and units of work on leave", func() {
+			tx, _ := registry.Begin(root.ID())
+			registry.Leave(root)
+
+			_, err := registry.Get(tx.ID())
+			So(err, ShouldEqual, ErrNotFound)
+			_, err = registry.Begin(root.ID())
+			So(err, ShouldEqual, ErrNotFound)
+		})
+	})
+
+	Convey("Registry acquiring connection", t, func() {
+		registry := NewRegistry()
+		root := registry.Join(skydbtest.NewMapConn())
+		unit, _ := registry.Begin(root.ID())
+
+		Convey("waits for the triggering operation to yield", func() {
+			acquired := make(chan *Transaction)
+			go func() {
+				tx, _ := registry.Acquire(unit.ID())
+				acquired <- tx
+			}()
+
+			select {
+			case <-acquired:
+				t.Fatal("acquired connection held by triggering operation")
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			root.Yield(func() {
+				tx := <-acquired
+				So(tx, ShouldEqual, unit)
+				registry.Release(tx)
+			})
+		})
+
+		Convey("serializes requests of units of work", func() {
+			root.Yield(func() {
+				tx, err := registry.Acquire(unit.ID())
+				So(err, ShouldBeNil)
+
+				acquired := make(chan *Transaction)
+				go func() {
+					tx, _ := registry.Acquire(unit.ID())
+					acquired <- tx
+				}()
+
+				select {
+				case <-acquired:
+					t.Fatal("acquired connection held by another request")
+				case <-time.After(10 * time.Millisecond):
+				}
+
+				registry.Release(tx)
+				registry.Release(<-acquired)
+			})
+		})
+
+		Convey("fails waiting request when transaction ended", func() {
+			errCh := make(chan error)
+			go func() {
+				_, err := registry.Acquire(unit.ID())
+				errCh <- err
+			}()
+
+			time.Sleep(10 * time.Millisecond)
+			registry.Leave(root)
+			So(<-errCh, ShouldEqual, ErrNotFound)
+		})
+
+		Convey("fails waiting request when unit of work committed", func() {
+			root.Yield(func() {
+				tx, _ := registry.Acquire(unit.ID())
+
+				errCh := make(chan error)
+				go func() {
+					_, err := registry.Acquire(unit.ID())
+					errCh <- err
+				}()
+
+				time.Sleep(10 * time.Millisecond)
+				registry.Commit(unit.ID())
+				registry.Release(tx)
+				So(<-errCh, ShouldEqual, ErrNotFound)
+			})
+		})
+	})
+
+	Convey("nil Registry", t, func() {
+		var registry *Registry
+		So(registry.Join(skydbtest.NewMapConn()), ShouldBeNil)
+		So(registry.Leave(nil), ShouldBeNil)
+		_, err := registry.Begin("id")
+		So(err, ShouldEqual, ErrNotFound)
+	})
+}
+
+func TestContext(t *testing.T) {
+	Convey("Context", t, func() {
+		So(FromContext(context.Background()), ShouldBeNil)
+
+		tx := &Transaction{id: "tx"}
+		ctx := NewContext(context.Background(), tx)
+		So(FromContext(ctx), ShouldEqual, tx)
+		So(NewContext(ctx, nil), ShouldEqual, ctx)
+	})
+}
@@ -16,12 +16,17 @@ package plugin
 
 import (
 	"context"
+	"time"
 
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
 
+var timeNow = time.Now
+
 // AuthRequest is sent by Skygear Server to plugin which contains data for authentication
 type AuthRequest struct {
 	ProviderName string
@@ -103,6 +108,12 @@ type BidirectionalTransport interface {
 }
 
 // ContextMap returns a map of the user request context.
+//
+// If the request has a deadline, the deadline and the remaining time in
+// milliseconds are included, so that the plugin can bound its own calls.
+//
+// If the request is run in a transaction exposed to plugins, the ID of the
+// transaction is included, with which the plugin begins a unit of work.
 func ContextMap(ctx context.Context) map[string]interface{} {
 	if ctx == nil {
 		return map[string]interface{}{}
@@ -111,6 +122,9 @@ func ContextMap(ctx context.Context) map[string]interface{} {
 	if userID, ok := ctx.Value(router.UserIDContextKey).(string); ok {
 		pluginCtx["user_id"] = userID
 	}
+	if requestID := router.RequestIDFromContext(ctx); requestID != "" {
+		pluginCtx["request_id"] = requestID
+	}
 	if accessKeyType, ok := ctx.Value(router.AccessKeyTypeContextKey).(router.AccessKeyType); ok {
 		switch accessKeyType {
 		case router.ClientAccessKey:
@@ -119,5 +133,26 @@ func ContextMap(ctx context.Context) map[string]interface{} {
 			pluginCtx["access_key_type"] = "master"
 		}
 	}
+	if tx := transaction.FromContext(ctx); tx != nil {
+		pluginCtx["transaction_id"] = tx.RootID()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := deadline.Sub(timeNow())
+		if remaining < 0 {
+			remaining = 0
+		}
+		pluginCtx["deadline"] = deadline.UTC().Format(time.RFC3339Nano)
+		pluginCtx["remaining_ms"] = int64(remaining / time.Millisecond)
+	}
 	return pluginCtx
 }
+
+// checkDeadline returns a PluginTimeout error if the deadline of the
+// request has been exceeded, in which case the plugin should not be
+// called or its result is no longer awaited.
+func checkDeadline(ctx context.Context) skyerr.Error {
+	if ctx != nil && ctx.Err() == context.DeadlineExceeded {
+		return skyerr.NewError(skyerr.PluginTimeout, "plugin call exceeded the request deadline")
+	}
+	return nil
+}
@@ -17,9 +17,11 @@ package plugin
 import (
 	"context"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -121,4 +123,38 @@ func TestContextMap(t *testing.T) {
 			"access_key_type": "master",
 		})
 	})
+
+	Convey("Deadline", t, func() {
+		realTimeNow := timeNow
+		timeNow = func() time.Time { return time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC) }
+		defer func() {
+			timeNow = realTimeNow
+		}()
+
+		deadline := time.Date(2017, 1, 1, 0, 0, 1, 500000000, time.UTC)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		So(ContextMap(ctx), ShouldResemble, map[string]interface{}{
+			"deadline":     "2017-01-01T00:00:01.5Z",
+			"remaining_ms": int64(1500),
+		})
+	})
+
+	Convey("Transaction", t, func() {
+		registry := transaction.NewRegistry()
+		root := registry.Join(nil)
+		tx, _ := registry.Begin(root.ID())
+		ctx := transaction.NewContext(context.Background(), tx)
+		So(ContextMap(ctx), ShouldResemble, map[string]interface{}{
+			"transaction_id": root.ID(),
+		})
+	})
+
+	Convey("RequestID", t, func() {
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, router.RequestIDContextKey, "request-1")
+		So(ContextMap(ctx), ShouldResemble, map[string]interface{}{
+			"request_id": "request-1",
+		})
+	})
 }
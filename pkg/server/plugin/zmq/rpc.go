@@ -95,6 +95,8 @@ func (p *zmqTransport) RunHook(ctx context.Context, hookName string, record *sky
 	recordout.CreatorID = record.CreatorID
 	recordout.UpdatedAt = record.UpdatedAt
 	recordout.UpdaterID = record.UpdaterID
+	recordout.DeletedAt = record.DeletedAt
+	recordout.Revision = record.Revision
 
 	return &recordout, nil
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/authtoken"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/tenant"
 )
 
 func checkRequestAccessKey(payload *router.Payload, clientKey string, masterKey string) skyerr.Error {
@@ -43,16 +44,51 @@ func checkRequestAccessKey(payload *router.Payload, clientKey string, masterKey
 	return nil
 }
 
+// resolveTenant returns the app of the request. In multi-tenant mode, the
+// app is the tenant resolved from the API key or the host of the request,
+// and false is returned if no tenant is resolved. Otherwise it is the app
+// configured for the server.
+func resolveTenant(tenants *tenant.Resolver, payload *router.Payload, app tenant.Tenant) (tenant.Tenant, bool) {
+	if !tenants.Enabled() {
+		return app, true
+	}
+
+	host := ""
+	if payload.Req != nil {
+		host = payload.Req.Host
+	}
+	t, ok := tenants.Resolve(payload.APIKey(), host)
+	if !ok {
+		return tenant.Tenant{}, false
+	}
+	payload.Tenant = t.Name
+	return *t, true
+}
+
 // AccessKeyValidationPreprocessor provides preprocess method to check the
 // API key of the request.
 type AccessKeyValidationPreprocessor struct {
 	ClientKey string
 	MasterKey string
 	AppName   string
+
+	// Tenants, if enabled, resolves the tenant of the request, whose keys
+	// are checked instead.
+	Tenants *tenant.Resolver
 }
 
 func (p AccessKeyValidationPreprocessor) Preprocess(payload *router.Payload, response *router.Response) int {
-	if err := checkRequestAccessKey(payload, p.ClientKey, p.MasterKey); err != nil {
+	app, ok := resolveTenant(p.Tenants, payload, tenant.Tenant{
+		Name:      p.AppName,
+		APIKey:    p.ClientKey,
+		MasterKey: p.MasterKey,
+	})
+	if !ok {
+		response.Err = skyerr.NewErrorf(skyerr.AccessKeyNotAccepted, "Cannot resolve the tenant of the request")
+		return http.StatusUnauthorized
+	}
+
+	if err := checkRequestAccessKey(payload, app.APIKey, app.MasterKey); err != nil {
 		response.Err = err
 		return http.StatusUnauthorized
 	}
@@ -62,7 +98,7 @@ func (p AccessKeyValidationPreprocessor) Preprocess(payload *router.Payload, res
 		return http.StatusUnauthorized
 	}
 
-	payload.AppName = p.AppName
+	payload.AppName = app.Name
 	return http.StatusOK
 }
 
@@ -73,14 +109,31 @@ type UserAuthenticator struct {
 	MasterKey  string
 	AppName    string
 	TokenStore authtoken.Store
+
+	// Tenants, if enabled, resolves the tenant of the request, whose keys
+	// are checked instead. The tenant of a request without API key nor
+	// known host is resolved from its access token.
+	Tenants *tenant.Resolver
 }
 
 func (p *UserAuthenticator) Preprocess(payload *router.Payload, response *router.Response) int {
-	if err := checkRequestAccessKey(payload, p.ClientKey, p.MasterKey); err != nil {
-		response.Err = err
+	app, resolved := resolveTenant(p.Tenants, payload, tenant.Tenant{
+		Name:      p.AppName,
+		APIKey:    p.ClientKey,
+		MasterKey: p.MasterKey,
+	})
+	if !resolved && payload.APIKey() != "" {
+		response.Err = skyerr.NewErrorf(skyerr.AccessKeyNotAccepted, "Cannot verify api key: `%v`", payload.APIKey())
 		return http.StatusUnauthorized
 	}
 
+	if resolved {
+		if err := checkRequestAccessKey(payload, app.APIKey, app.MasterKey); err != nil {
+			response.Err = err
+			return http.StatusUnauthorized
+		}
+	}
+
 	// If payload contains an access token, check whether if the access
 	// token is valid. API Key is not required if there is valid access token.
 	if tokenString := payload.AccessTokenString(); tokenString != "" {
@@ -101,6 +154,16 @@ func (p *UserAuthenticator) Preprocess(payload *router.Payload, response *router
 			return http.StatusUnauthorized
 		}
 
+		if p.Tenants.Enabled() {
+			// A token is only accepted by the tenant issuing it.
+			t, ok := p.Tenants.Get(token.AppName)
+			if !ok || (resolved && t.Name != app.Name) {
+				response.Err = skyerr.NewError(skyerr.AccessTokenNotAccepted, "token is not issued by the tenant")
+				return http.StatusUnauthorized
+			}
+			payload.Tenant = t.Name
+		}
+
 		payload.AppName = token.AppName
 		payload.AuthInfoID = token.AuthInfoID
 		payload.Context = context.WithValue(payload.Context, router.UserIDContextKey, token.AuthInfoID)
@@ -108,6 +171,11 @@ func (p *UserAuthenticator) Preprocess(payload *router.Payload, response *router
 		return http.StatusOK
 	}
 
+	if !resolved {
+		response.Err = skyerr.NewErrorf(skyerr.NotAuthenticated, "Cannot resolve the tenant of the request")
+		return http.StatusUnauthorized
+	}
+
 	if payload.AccessKey == router.NoAccessKey {
 		response.Err = skyerr.NewErrorf(skyerr.NotAuthenticated, "Both api key and access token are empty")
 		return http.StatusUnauthorized
@@ -120,8 +188,18 @@ func (p *UserAuthenticator) Preprocess(payload *router.Payload, response *router
 			payload.AuthInfoID = userID
 			payload.Context = context.WithValue(payload.Context, router.UserIDContextKey, userID)
 		}
+
+		// Unlike _user_id, _as_user also drops the master key so that
+		// the request is handled in the security context of the user,
+		// i.e. access control applies as if the user made the request.
+		if userID, ok := payload.Data["_as_user"].(string); ok && userID != "" {
+			payload.AuthInfoID = userID
+			payload.AccessKey = router.ClientAccessKey
+			payload.Context = context.WithValue(payload.Context, router.UserIDContextKey, userID)
+			payload.Context = context.WithValue(payload.Context, router.AccessKeyTypeContextKey, payload.AccessKey)
+		}
 	}
 
-	payload.AppName = p.AppName
+	payload.AppName = app.Name
 	return http.StatusOK
 }
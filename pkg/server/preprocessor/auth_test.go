@@ -25,6 +25,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/authtoken/authtokentest"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	"github.com/skygeario/skygear-server/pkg/server/tenant"
 )
 
 func TestAccessKeyValidationPreprocessor(t *testing.T) {
@@ -150,6 +151,26 @@ func TestUserAuthenticator(t *testing.T) {
 			payload.Data["_user_id"] = "user-id"
 			So(payload.AuthInfoID, ShouldNotEqual, "user-id")
 		})
+
+		Convey("act as a user", func() {
+			payload.Data["api_key"] = "master-key"
+			payload.Data["_as_user"] = "user-id"
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(payload.AccessKey, ShouldEqual, router.ClientAccessKey)
+			So(payload.HasMasterKey(), ShouldBeFalse)
+			So(payload.AuthInfoID, ShouldEqual, "user-id")
+			So(payload.Context.Value(router.UserIDContextKey), ShouldEqual, "user-id")
+			So(payload.Context.Value(router.AccessKeyTypeContextKey), ShouldEqual, router.ClientAccessKey)
+			So(resp.Err, ShouldBeNil)
+		})
+
+		Convey("act as a user without master key", func() {
+			payload.Data["api_key"] = "client-key"
+			payload.Data["_as_user"] = "user-id"
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(payload.AccessKey, ShouldEqual, router.ClientAccessKey)
+			So(payload.AuthInfoID, ShouldBeEmpty)
+		})
 	})
 
 	Convey("test access user authenticator for access token", t, func() {
@@ -186,3 +207,99 @@ func TestUserAuthenticator(t *testing.T) {
 		})
 	})
 }
+
+func TestTenantResolution(t *testing.T) {
+	tenants := tenant.NewResolver([]tenant.Tenant{
+		{
+			Name:      "acme",
+			APIKey:    "acme-api-key",
+			MasterKey: "acme-master-key",
+			Hosts:     []string{"acme.example.com"},
+		},
+		{
+			Name:      "globex",
+			APIKey:    "globex-api-key",
+			MasterKey: "globex-master-key",
+		},
+	})
+
+	Convey("test access key validation preprocessor with tenants", t, func() {
+		pp := AccessKeyValidationPreprocessor{
+			ClientKey: "client-key",
+			MasterKey: "master-key",
+			AppName:   "app-name",
+			Tenants:   tenants,
+		}
+
+		payload := &router.Payload{
+			Data: map[string]interface{}{},
+			Meta: map[string]interface{}{},
+		}
+		resp := &router.Response{}
+
+		Convey("resolves tenant from api key", func() {
+			payload.Data["api_key"] = "globex-master-key"
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(payload.AccessKey, ShouldEqual, router.MasterAccessKey)
+			So(payload.AppName, ShouldEqual, "globex")
+			So(payload.Tenant, ShouldEqual, "globex")
+			So(resp.Err, ShouldBeNil)
+		})
+
+		Convey("rejects key of the server app", func() {
+			payload.Data["api_key"] = "client-key"
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusUnauthorized)
+			So(resp.Err.Code(), ShouldEqual, skyerr.AccessKeyNotAccepted)
+		})
+	})
+
+	Convey("test user authenticator with tenants", t, func() {
+		pp := UserAuthenticator{
+			ClientKey:  "client-key",
+			MasterKey:  "master-key",
+			AppName:    "app-name",
+			TokenStore: &authtokentest.SingleTokenStore{},
+			Tenants:    tenants,
+		}
+
+		payload := &router.Payload{
+			Data: map[string]interface{}{},
+			Meta: map[string]interface{}{},
+			Req:  &http.Request{Host: "acme.example.com:3000"},
+		}
+		resp := &router.Response{}
+
+		Convey("resolves tenant from host", func() {
+			token := authtoken.New("acme", "user-id", time.Time{})
+			pp.TokenStore.Put(&token)
+			payload.Data["access_token"] = token.AccessToken
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(payload.AppName, ShouldEqual, "acme")
+			So(payload.Tenant, ShouldEqual, "acme")
+			So(payload.AuthInfoID, ShouldEqual, "user-id")
+		})
+
+		Convey("resolves tenant from token", func() {
+			payload.Req = &http.Request{Host: "example.com"}
+			token := authtoken.New("globex", "user-id", time.Time{})
+			pp.TokenStore.Put(&token)
+			payload.Data["access_token"] = token.AccessToken
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(payload.Tenant, ShouldEqual, "globex")
+		})
+
+		Convey("rejects token of another tenant", func() {
+			token := authtoken.New("globex", "user-id", time.Time{})
+			pp.TokenStore.Put(&token)
+			payload.Data["access_token"] = token.AccessToken
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusUnauthorized)
+			So(resp.Err.Code(), ShouldEqual, skyerr.AccessTokenNotAccepted)
+		})
+
+		Convey("rejects request of unknown tenant", func() {
+			payload.Req = &http.Request{Host: "example.com"}
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusUnauthorized)
+			So(resp.Err.Code(), ShouldEqual, skyerr.NotAuthenticated)
+		})
+	})
+}
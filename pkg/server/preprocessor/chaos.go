@@ -0,0 +1,42 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"net/http"
+
+	"github.com/skygeario/skygear-server/pkg/server/chaos"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// ChaosPreprocessor injects latency and errors into requests of the
+// actions affected by the Injector. It must not be enabled in production.
+type ChaosPreprocessor struct {
+	Injector *chaos.Injector
+}
+
+func (p *ChaosPreprocessor) Preprocess(payload *router.Payload, response *router.Response) int {
+	action := payload.RouteAction()
+	p.Injector.Delay(action)
+
+	if p.Injector.ShouldFail(action) {
+		log.WithField("action", action).Warnln("Injecting error into request")
+		response.Err = skyerr.NewError(skyerr.UnexpectedError, "Error injected by chaos testing.")
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusOK
+}
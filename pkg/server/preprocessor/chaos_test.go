@@ -0,0 +1,57 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/chaos"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestChaosPreprocessor(t *testing.T) {
+	Convey("ChaosPreprocessor", t, func() {
+		payload := &router.Payload{
+			Data: map[string]interface{}{
+				"action": "record:query",
+			},
+		}
+
+		Convey("injects error into affected action", func() {
+			pp := &ChaosPreprocessor{
+				Injector: &chaos.Injector{ErrorRate: 1},
+			}
+			resp := &router.Response{}
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusInternalServerError)
+			So(resp.Err.Code(), ShouldEqual, skyerr.UnexpectedError)
+		})
+
+		Convey("does not inject error into unaffected action", func() {
+			pp := &ChaosPreprocessor{
+				Injector: &chaos.Injector{
+					ErrorRate: 1,
+					Actions:   []string{"record:save"},
+				},
+			}
+			resp := &router.Response{}
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+		})
+	})
+}
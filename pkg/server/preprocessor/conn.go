@@ -17,9 +17,13 @@ package preprocessor
 import (
 	"context"
 	"net/http"
+	"sync"
 
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
 	"github.com/skygeario/skygear-server/pkg/server/router"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/failover"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/offload"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
 
@@ -30,20 +34,127 @@ type ConnPreprocessor struct {
 	DBImpl        string
 	Option        string
 	DevMode       bool
+
+	// Offloader, if not nil, offloads large record fields of the opened
+	// databases to the asset store.
+	Offloader *offload.Offloader
+
+	// Failover, if not nil, selects the database to be opened, and makes
+	// the opened databases read only in the grace period after failing
+	// over.
+	Failover *failover.Manager
+
+	// provisioned are the tenants of which the schemas are provisioned
+	// and migrated.
+	provisionMutex sync.Mutex
+	provisioned    map[string]bool
 }
 
-func (p ConnPreprocessor) Preprocess(payload *router.Payload, response *router.Response) int {
-	log.Debugf("Opening DBConn: {%v %v %v}", p.DBImpl, p.AppName, p.Option)
+// replicaReadActions are the actions only reading records, which may read
+// from the read replicas of the database unless the request asks for a
+// strong read with `_strong_read`.
+var replicaReadActions = map[string]bool{
+	"record:fetch": true,
+	"record:query": true,
+}
 
+func (p *ConnPreprocessor) Preprocess(payload *router.Payload, response *router.Response) int {
+	// A request with a token of a unit of work in transaction_id runs on
+	// the connection of the transaction.
+	if token, ok := payload.Data["transaction_id"].(string); ok && token != "" {
+		return p.joinTransaction(token, payload, response)
+	}
+
+	option := p.Option
+	if p.Failover.Enabled() {
+		option = p.Failover.Option()
+	}
+
+	// In multi-tenant mode, each tenant has its own schema, which is
+	// provisioned and migrated when the tenant is first served.
+	appName := p.AppName
 	canMigrate := payload.HasMasterKey() || p.DevMode
-	conn, err := p.DBOpener(payload.Context, p.DBImpl, p.AppName, p.AccessControl, p.Option, canMigrate)
+	if payload.Tenant != "" {
+		appName = payload.Tenant
+		if err := p.provisionTenant(payload.Context, appName, option); err != nil {
+			response.Err = skyerr.NewError(skyerr.UnexpectedUnableToOpenDatabase, err.Error())
+			return http.StatusServiceUnavailable
+		}
+	}
+
+	log.Debugf("Opening DBConn: {%v %v %v}", p.DBImpl, appName, p.Option)
+
+	// The actor is written to the history of records changed through
+	// the connection.
+	ctx := payload.Context
+	if payload.AuthInfoID != "" {
+		ctx = skydb.ContextWithActor(ctx, payload.AuthInfoID)
+	}
+	if strongRead, _ := payload.Data["_strong_read"].(bool); replicaReadActions[payload.RouteAction()] && !strongRead {
+		ctx = skydb.ContextWithReplicaRead(ctx)
+	}
+
+	conn, err := p.DBOpener(ctx, p.DBImpl, appName, p.AccessControl, option, canMigrate)
 	if err != nil {
 		response.Err = skyerr.NewError(skyerr.UnexpectedUnableToOpenDatabase, err.Error())
 		return http.StatusServiceUnavailable
 	}
+	if p.Failover.Enabled() && p.Failover.ReadOnly() {
+		conn = failover.NewReadOnlyConn(conn)
+	}
+	if p.Offloader != nil {
+		conn = offload.NewConn(conn, p.Offloader)
+	}
 	payload.DBConn = conn
 
 	log.Debugf("Get DB OK")
 
 	return http.StatusOK
 }
+
+// provisionTenant provisions and migrates the schema of the tenant if it
+// is not provisioned yet. Afterwards the schema of the tenant is only
+// altered with the master key or in dev mode, as in single-tenant mode.
+func (p *ConnPreprocessor) provisionTenant(ctx context.Context, appName string, option string) error {
+	p.provisionMutex.Lock()
+	defer p.provisionMutex.Unlock()
+
+	if p.provisioned[appName] {
+		return nil
+	}
+
+	conn, err := p.DBOpener(ctx, p.DBImpl, appName, p.AccessControl, option, true)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	if p.provisioned == nil {
+		p.provisioned = map[string]bool{}
+	}
+	p.provisioned[appName] = true
+	return nil
+}
+
+// joinTransaction uses the connection of the transaction of the unit of
+// work, which is only exposed to plugins with the master key.
+//
+// The unit of work is acquired by transaction.Middleware, which holds the
+// connection for the whole request. The request fails instead of running
+// outside the transaction if the unit of work is not acquired, such as
+// when it is already finished.
+func (p *ConnPreprocessor) joinTransaction(token string, payload *router.Payload, response *router.Response) int {
+	if !payload.HasMasterKey() {
+		response.Err = skyerr.NewError(skyerr.PermissionDenied, "master key is required to run in a transaction")
+		return http.StatusForbidden
+	}
+
+	tx := transaction.FromContext(payload.Context)
+	if tx == nil || tx.ID() != token {
+		response.Err = skyerr.NewInvalidArgument("transaction not found or already finished", []string{"transaction_id"})
+		return http.StatusBadRequest
+	}
+
+	payload.DBConn = tx.Conn()
+	return http.StatusOK
+}
@@ -0,0 +1,171 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConnPreprocessorTransaction(t *testing.T) {
+	Convey("ConnPreprocessor in transaction", t, func() {
+		registry := transaction.NewRegistry()
+		conn := skydbtest.NewMapConn()
+		root := registry.Join(conn)
+		unit, _ := registry.Begin(root.ID())
+
+		pp := ConnPreprocessor{
+			DBOpener: func(context.Context, string, string, string, string, bool) (skydb.Conn, error) {
+				return nil, errors.New("should not open connection")
+			},
+		}
+		middleware := transaction.Middleware(registry)
+
+		// preprocess runs the preprocessor as a request of the plugin
+		// while the triggering operation waits for the hook.
+		preprocess := func(payload *router.Payload, resp *router.Response) (httpStatus int) {
+			root.Yield(func() {
+				httpStatus = middleware(payload, resp, func() int {
+					return pp.Preprocess(payload, resp)
+				})
+			})
+			return
+		}
+
+		Convey("uses connection of the transaction", func() {
+			payload := router.Payload{
+				Context:   context.Background(),
+				AccessKey: router.MasterAccessKey,
+				Data: map[string]interface{}{
+					"transaction_id": unit.ID(),
+				},
+			}
+			resp := router.Response{}
+			So(preprocess(&payload, &resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+			So(payload.DBConn, ShouldEqual, conn)
+			So(transaction.FromContext(payload.Context), ShouldEqual, unit)
+		})
+
+		Convey("rejects request without master key", func() {
+			payload := router.Payload{
+				Context:   context.Background(),
+				AccessKey: router.ClientAccessKey,
+				Data: map[string]interface{}{
+					"transaction_id": unit.ID(),
+				},
+			}
+			resp := router.Response{}
+			So(preprocess(&payload, &resp), ShouldEqual, http.StatusForbidden)
+			So(resp.Err.Code(), ShouldEqual, skyerr.PermissionDenied)
+		})
+
+		Convey("rejects finished unit of work", func() {
+			registry.Commit(unit.ID())
+			payload := router.Payload{
+				Context:   context.Background(),
+				AccessKey: router.MasterAccessKey,
+				Data: map[string]interface{}{
+					"transaction_id": unit.ID(),
+				},
+			}
+			resp := router.Response{}
+			So(preprocess(&payload, &resp), ShouldEqual, http.StatusBadRequest)
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("rejects request after transaction ended", func() {
+			registry.Leave(root)
+			payload := router.Payload{
+				Context:   context.Background(),
+				AccessKey: router.MasterAccessKey,
+				Data: map[string]interface{}{
+					"transaction_id": unit.ID(),
+				},
+			}
+			resp := router.Response{}
+			httpStatus := middleware(&payload, &resp, func() int {
+				return pp.Preprocess(&payload, &resp)
+			})
+			So(httpStatus, ShouldEqual, http.StatusBadRequest)
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(payload.DBConn, ShouldBeNil)
+		})
+
+		Convey("rejects request not acquired by middleware", func() {
+			payload := router.Payload{
+				Context:   context.Background(),
+				AccessKey: router.MasterAccessKey,
+				Data: map[string]interface{}{
+					"transaction_id": unit.ID(),
+				},
+			}
+			resp := router.Response{}
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusBadRequest)
+			So(resp.Err.Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+	})
+}
+
+func TestConnPreprocessorTenant(t *testing.T) {
+	Convey("ConnPreprocessor in multi-tenant mode", t, func() {
+		type openCall struct {
+			appName string
+			migrate bool
+		}
+		calls := []openCall{}
+		pp := ConnPreprocessor{
+			AppName: "app",
+			DBOpener: func(ctx context.Context, implName string, appName string, accessString string, optionString string, migrate bool) (skydb.Conn, error) {
+				calls = append(calls, openCall{appName, migrate})
+				return skydbtest.NewMapConn(), nil
+			},
+		}
+
+		preprocess := func(accessKey router.AccessKeyType) {
+			payload := router.Payload{
+				Context:   context.Background(),
+				Tenant:    "acme",
+				AccessKey: accessKey,
+				Data:      map[string]interface{}{},
+			}
+			resp := router.Response{}
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+		}
+
+		Convey("provisions tenant once and migrates with master key only", func() {
+			preprocess(router.ClientAccessKey)
+			preprocess(router.ClientAccessKey)
+			preprocess(router.MasterAccessKey)
+
+			So(calls, ShouldResemble, []openCall{
+				{"acme", true},
+				{"acme", false},
+				{"acme", false},
+				{"acme", true},
+			})
+		})
+	})
+}
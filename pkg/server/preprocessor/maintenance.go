@@ -0,0 +1,113 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+const (
+	// defaultMaintenanceCacheTTL is how long the maintenance mode read
+	// from the database is reused if CacheTTL is not specified.
+	defaultMaintenanceCacheTTL = 5 * time.Second
+
+	// maintenanceRetryAfter is the number of seconds clients are told
+	// to wait before retrying a rejected request.
+	maintenanceRetryAfter = 30
+)
+
+// MaintenancePreprocessor rejects requests with an UnderMaintenance error
+// when the app is in maintenance mode. It is placed in front of handlers
+// modifying data, so that reads and pubsub are still served during the
+// maintenance. Requests with master key are not rejected, so that
+// administrators can still fix data. Requests are also rejected if the
+// maintenance mode cannot be read.
+//
+// The maintenance mode is shared by all servers of the app through the
+// database. It is read at most once per CacheTTL, so a change made on
+// another server takes effect within CacheTTL.
+type MaintenancePreprocessor struct {
+	CacheTTL time.Duration
+
+	mutex    sync.Mutex
+	cached   *skydb.Maintenance
+	expireAt time.Time
+}
+
+func (p *MaintenancePreprocessor) Preprocess(payload *router.Payload, response *router.Response) int {
+	if payload.HasMasterKey() {
+		return http.StatusOK
+	}
+
+	maintenance, err := p.maintenance(payload.DBConn)
+	if err != nil {
+		// writes are rejected when the maintenance mode is unknown,
+		// because the database may be being migrated
+		log.WithField("err", err).Errorln("Failed to get maintenance mode")
+		return p.reject(response, "Unable to check maintenance mode. Please try again later.")
+	}
+
+	if !maintenance.Enabled {
+		return http.StatusOK
+	}
+
+	message := maintenance.Message
+	if message == "" {
+		message = "The app is under maintenance. Please try again later."
+	}
+	return p.reject(response, message)
+}
+
+func (p *MaintenancePreprocessor) reject(response *router.Response, message string) int {
+	response.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+	response.Err = skyerr.NewError(skyerr.UnderMaintenance, message)
+	return http.StatusServiceUnavailable
+}
+
+// maintenance returns the cached maintenance mode, or reads it from the
+// database if the cache has expired. The mutex is not held while reading
+// the database so that a slow database does not block other requests
+// waiting for the cache.
+func (p *MaintenancePreprocessor) maintenance(conn skydb.Conn) (*skydb.Maintenance, error) {
+	p.mutex.Lock()
+	cached, expireAt := p.cached, p.expireAt
+	p.mutex.Unlock()
+
+	if cached != nil && time.Now().Before(expireAt) {
+		return cached, nil
+	}
+
+	maintenance, err := conn.GetMaintenance()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := p.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultMaintenanceCacheTTL
+	}
+	p.mutex.Lock()
+	p.cached = maintenance
+	p.expireAt = time.Now().Add(ttl)
+	p.mutex.Unlock()
+	return maintenance, nil
+}
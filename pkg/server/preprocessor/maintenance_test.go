@@ -0,0 +1,102 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/mock_skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaintenancePreprocessor(t *testing.T) {
+	Convey("MaintenancePreprocessor", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		conn := mock_skydb.NewMockConn(ctrl)
+		pp := &MaintenancePreprocessor{
+			CacheTTL: time.Minute,
+		}
+
+		Convey("allows requests when not in maintenance", func() {
+			conn.EXPECT().GetMaintenance().Return(&skydb.Maintenance{}, nil)
+
+			payload := router.Payload{DBConn: conn}
+			resp := router.Response{}
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+		})
+
+		Convey("rejects requests in maintenance", func() {
+			conn.EXPECT().GetMaintenance().Return(&skydb.Maintenance{
+				Enabled: true,
+				Message: "Upgrading database",
+			}, nil).Times(1)
+
+			for i := 0; i < 2; i++ {
+				payload := router.Payload{DBConn: conn}
+				resp := router.Response{}
+				So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusServiceUnavailable)
+				So(resp.Err.Code(), ShouldEqual, skyerr.UnderMaintenance)
+				So(resp.Err.Message(), ShouldEqual, "Upgrading database")
+				So(resp.Header().Get("Retry-After"), ShouldEqual, "30")
+			}
+		})
+
+		Convey("rejects requests when maintenance mode cannot be read", func() {
+			conn.EXPECT().GetMaintenance().Return(nil, errors.New("connection refused"))
+
+			payload := router.Payload{DBConn: conn}
+			resp := router.Response{}
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusServiceUnavailable)
+			So(resp.Err.Code(), ShouldEqual, skyerr.UnderMaintenance)
+			So(resp.Header().Get("Retry-After"), ShouldEqual, "30")
+		})
+
+		Convey("reads maintenance mode again after an error", func() {
+			gomock.InOrder(
+				conn.EXPECT().GetMaintenance().Return(nil, errors.New("connection refused")),
+				conn.EXPECT().GetMaintenance().Return(&skydb.Maintenance{}, nil),
+			)
+
+			payload := router.Payload{DBConn: conn}
+			resp := router.Response{}
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusServiceUnavailable)
+
+			payload = router.Payload{DBConn: conn}
+			resp = router.Response{}
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+		})
+
+		Convey("allows requests with master key in maintenance", func() {
+			payload := router.Payload{
+				DBConn:    conn,
+				AccessKey: router.MasterAccessKey,
+			}
+			resp := router.Response{}
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+		})
+	})
+}
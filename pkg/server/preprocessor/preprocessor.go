@@ -279,3 +279,18 @@ func (p RequireAdminOrMasterKey) Preprocess(payload *router.Payload, response *r
 	)
 	return http.StatusUnauthorized
 }
+
+type RequireMasterKey struct {
+}
+
+func (p RequireMasterKey) Preprocess(payload *router.Payload, response *router.Response) int {
+	if payload.HasMasterKey() {
+		return http.StatusOK
+	}
+
+	response.Err = skyerr.NewError(
+		skyerr.PermissionDenied,
+		"master key is required for this action",
+	)
+	return http.StatusUnauthorized
+}
@@ -521,3 +521,32 @@ func TestInjectUserProcessor(t *testing.T) {
 		})
 	})
 }
+
+func TestRequireMasterKey(t *testing.T) {
+	Convey("RequireMasterKey", t, func() {
+		pp := RequireMasterKey{}
+
+		Convey("should ok with master key", func() {
+			payload := router.Payload{
+				AccessKey: router.MasterAccessKey,
+			}
+			resp := router.Response{}
+
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+		})
+
+		Convey("should reject admin user without master key", func() {
+			payload := router.Payload{
+				AccessKey: router.ClientAccessKey,
+				AuthInfo: &skydb.AuthInfo{
+					Roles: []string{"admin"},
+				},
+			}
+			resp := router.Response{}
+
+			So(pp.Preprocess(&payload, &resp), ShouldEqual, http.StatusUnauthorized)
+			So(resp.Err.Code(), ShouldEqual, skyerr.PermissionDenied)
+		})
+	})
+}
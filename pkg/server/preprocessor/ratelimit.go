@@ -0,0 +1,92 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/skygeario/skygear-server/pkg/server/ratelimit"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// RateLimitPreprocessor limits the rate of requests of each client. The
+// client is identified by its access token, or by its address if the
+// request has no access token. Requests with master key are not limited.
+//
+// The state of the limit is returned in the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers. When Enforce is false,
+// requests exceeding the limit are only counted in metrics and logged,
+// so that a limit can be observed before it is enforced.
+type RateLimitPreprocessor struct {
+	Limiter   *ratelimit.Limiter
+	MasterKey string
+	Enforce   bool
+}
+
+func (p *RateLimitPreprocessor) Preprocess(payload *router.Payload, response *router.Response) int {
+	if p.MasterKey != "" && payload.APIKey() == p.MasterKey {
+		return http.StatusOK
+	}
+
+	key := rateLimitKey(payload)
+	result := p.Limiter.Take(key)
+
+	header := response.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset.Seconds())))
+
+	if result.Allowed {
+		ratelimit.Metrics.Add(ratelimit.MetricAllowed, 1)
+		return http.StatusOK
+	}
+
+	if !p.Enforce {
+		ratelimit.Metrics.Add(ratelimit.MetricSoftLimited, 1)
+		log.WithField("key", key).Warnln("Request exceeded rate limit, not enforced")
+		return http.StatusOK
+	}
+
+	ratelimit.Metrics.Add(ratelimit.MetricLimited, 1)
+	header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter.Seconds())))
+	response.Err = skyerr.NewError(skyerr.RateLimitExceeded, "Too many requests.")
+	return http.StatusTooManyRequests
+}
+
+func rateLimitKey(payload *router.Payload) string {
+	if token := payload.AccessTokenString(); token != "" {
+		return "token:" + token
+	}
+
+	if payload.Req == nil {
+		return "addr:"
+	}
+	host, _, err := net.SplitHostPort(payload.Req.RemoteAddr)
+	if err != nil {
+		host = payload.Req.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+func ceilSeconds(seconds float64) int {
+	i := int(seconds)
+	if float64(i) < seconds {
+		i++
+	}
+	return i
+}
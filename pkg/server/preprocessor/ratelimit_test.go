@@ -0,0 +1,90 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preprocessor
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/ratelimit"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestRateLimitPreprocessor(t *testing.T) {
+	Convey("RateLimitPreprocessor", t, func() {
+		req, _ := http.NewRequest("POST", "http://skygear.test", nil)
+		req.RemoteAddr = "192.0.2.1:12345"
+		payload := &router.Payload{
+			Req:  req,
+			Data: map[string]interface{}{},
+		}
+
+		Convey("annotates response within limit", func() {
+			pp := &RateLimitPreprocessor{
+				Limiter: ratelimit.NewLimiter(1, 2),
+			}
+			resp := &router.Response{}
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+			So(resp.Header().Get("RateLimit-Limit"), ShouldEqual, "2")
+			So(resp.Header().Get("RateLimit-Remaining"), ShouldEqual, "1")
+			So(resp.Header().Get("RateLimit-Reset"), ShouldEqual, "1")
+		})
+
+		Convey("rejects request exceeding limit when enforced", func() {
+			pp := &RateLimitPreprocessor{
+				Limiter: ratelimit.NewLimiter(1, 1),
+				Enforce: true,
+			}
+			pp.Preprocess(payload, &router.Response{})
+
+			resp := &router.Response{}
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusTooManyRequests)
+			So(resp.Err.Code(), ShouldEqual, skyerr.RateLimitExceeded)
+			So(resp.Header().Get("RateLimit-Remaining"), ShouldEqual, "0")
+			So(resp.Header().Get("Retry-After"), ShouldEqual, "1")
+		})
+
+		Convey("allows request exceeding limit in soft mode", func() {
+			pp := &RateLimitPreprocessor{
+				Limiter: ratelimit.NewLimiter(1, 1),
+			}
+			pp.Preprocess(payload, &router.Response{})
+
+			resp := &router.Response{}
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(resp.Err, ShouldBeNil)
+			So(resp.Header().Get("RateLimit-Remaining"), ShouldEqual, "0")
+			So(resp.Header().Get("Retry-After"), ShouldEqual, "")
+		})
+
+		Convey("does not limit request with master key", func() {
+			pp := &RateLimitPreprocessor{
+				Limiter:   ratelimit.NewLimiter(1, 1),
+				MasterKey: "secret",
+				Enforce:   true,
+			}
+			payload.Data["api_key"] = "secret"
+			pp.Preprocess(payload, &router.Response{})
+
+			resp := &router.Response{}
+			So(pp.Preprocess(payload, resp), ShouldEqual, http.StatusOK)
+			So(resp.Header().Get("RateLimit-Limit"), ShouldEqual, "")
+		})
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"strings"
+)
+
+// UserChannelPrefix is the prefix of the private channel of each user.
+// Only the user may subscribe to the channel, and only handlers and
+// plugins with the master key may publish to it.
+const UserChannelPrefix = "user:"
+
+// UserChannel returns the private channel of the user.
+func UserChannel(userID string) string {
+	return UserChannelPrefix + userID
+}
+
+// IsUserChannel returns whether the channel is the private channel of a
+// user.
+func IsUserChannel(channel string) bool {
+	return strings.HasPrefix(channel, UserChannelPrefix)
+}
+
+// TenantChannel returns the channel of the tenant in the hub, which
+// isolates the channels of tenants of the same name. The channel is
+// returned as is if tenant is empty.
+func TenantChannel(tenant string, channel string) string {
+	if tenant == "" {
+		return channel
+	}
+	return tenant + "/" + channel
+}
+
+// Client is the identity of a websocket connection, which decides the
+// private channels it can subscribe and publish to.
+type Client struct {
+	// UserID is the ID of the authenticated user, empty if the
+	// connection is not authenticated.
+	UserID string
+
+	// MasterKey is true if the connection is authenticated with the
+	// master key, such as the connection of a plugin.
+	MasterKey bool
+
+	// Tenant is the tenant of the connection in multi-tenant mode, which
+	// only subscribes and publishes to the channels of the tenant.
+	Tenant string
+}
+
+// hubChannel returns the channel in the hub of the channel requested by
+// the client.
+func (c Client) hubChannel(channel string) string {
+	return TenantChannel(c.Tenant, channel)
+}
+
+// clientChannel returns the channel requested by the client of the
+// channel in the hub.
+func (c Client) clientChannel(channel string) string {
+	if c.Tenant == "" {
+		return channel
+	}
+	return strings.TrimPrefix(channel, c.Tenant+"/")
+}
+
+// CanSubscribe returns whether the client can subscribe to the channel.
+// The private channel of a user can only be subscribed by the user.
+func (c Client) CanSubscribe(channel string) bool {
+	if !IsUserChannel(channel) || c.MasterKey {
+		return true
+	}
+	return c.UserID != "" && channel == UserChannel(c.UserID)
+}
+
+// CanPublish returns whether the client can publish to the channel. Users
+// cannot publish to private channels, including their own.
+func (c Client) CanPublish(channel string) bool {
+	return !IsUserChannel(channel) || c.MasterKey
+}
@@ -0,0 +1,53 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClient(t *testing.T) {
+	Convey("Client", t, func() {
+		Convey("subscribes to its own user channel only", func() {
+			client := Client{UserID: "user0"}
+			So(client.CanSubscribe("user:user0"), ShouldBeTrue)
+			So(client.CanSubscribe("user:user1"), ShouldBeFalse)
+			So(client.CanSubscribe("news"), ShouldBeTrue)
+		})
+
+		Convey("cannot subscribe to user channels without a user", func() {
+			client := Client{}
+			So(client.CanSubscribe("user:"), ShouldBeFalse)
+			So(client.CanSubscribe("news"), ShouldBeTrue)
+		})
+
+		Convey("publishes to user channels with the master key only", func() {
+			So(Client{UserID: "user0"}.CanPublish("user:user0"), ShouldBeFalse)
+			So(Client{UserID: "user0"}.CanPublish("news"), ShouldBeTrue)
+			So(Client{MasterKey: true}.CanPublish("user:user0"), ShouldBeTrue)
+			So(Client{MasterKey: true}.CanSubscribe("user:user0"), ShouldBeTrue)
+		})
+
+		Convey("isolates channels of tenants", func() {
+			client := Client{Tenant: "acme"}
+			So(client.hubChannel("news"), ShouldEqual, "acme/news")
+			So(client.clientChannel("acme/news"), ShouldEqual, "news")
+			So(Client{}.hubChannel("news"), ShouldEqual, "news")
+			So(TenantChannel("acme", UserChannel("user0")), ShouldEqual, "acme/user:user0")
+		})
+	})
+}
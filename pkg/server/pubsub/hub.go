@@ -69,6 +69,24 @@ func (h *Hub) run() {
 	}
 }
 
+// Publish sends the data to the subscribers of the channel without
+// blocking the caller. The data is dropped if the hub is not running.
+func (h *Hub) Publish(channel string, data []byte) {
+	go func() {
+		select {
+		case h.Broadcast <- Parcel{Channel: channel, Data: data}:
+		case <-h.timeOut():
+			log.Warnf("Can't publish, hub %p is not running, %v:%s", h, channel, data)
+		}
+	}()
+}
+
+// PublishToUser sends the data to the private channel of the user of the
+// tenant, which is empty if the server is not in multi-tenant mode.
+func (h *Hub) PublishToUser(tenant string, userID string, data []byte) {
+	h.Publish(TenantChannel(tenant, UserChannel(userID)), data)
+}
+
 func (h *Hub) timeOut() <-chan time.Time {
 	return time.After(h.timeout * time.Second)
 }
@@ -145,6 +145,28 @@ func TestNormalSubscription(t *testing.T) {
 			hub.stop <- 1
 		})
 
+		Convey("Publish to the private channel of a user", func(c C) {
+			hub := NewHub()
+			go hub.run()
+			conn := connection{
+				Send: make(chan Parcel),
+			}
+			hub.Subscribe <- Parcel{
+				Channel:    UserChannel("user0"),
+				Connection: &conn,
+			}
+			hub.PublishToUser("", "user0", []byte("Hello"))
+
+			select {
+			case recv := <-conn.Send:
+				c.So(recv.Channel, ShouldEqual, "user:user0")
+				c.So(recv.Data, ShouldResemble, []byte("Hello"))
+			case <-time.After(500 * time.Millisecond):
+				t.Fatal("did not receive message published to user")
+			}
+			hub.stop <- 1
+		})
+
 		Convey("Received broadcast message time out", func(c C) {
 			hub := NewHub()
 			hub.timeout = 0
@@ -23,6 +23,7 @@ import (
 
 type connection struct {
 	ws       *websocket.Conn
+	client   Client
 	channels []string
 	Send     chan Parcel
 	done     chan bool
@@ -63,17 +64,19 @@ func NewWsPubsub(hub *Hub) *WsPubSub {
 	return &ws
 }
 
-// Handle will hijack the http responseWriter and req.
-func (w *WsPubSub) Handle(writer http.ResponseWriter, req *http.Request) {
+// Handle will hijack the http responseWriter and req. The client decides
+// the private channels the connection can subscribe and publish to.
+func (w *WsPubSub) Handle(writer http.ResponseWriter, req *http.Request, client Client) {
 	conn, err := w.upgrader.Upgrade(writer, req, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 	c := &connection{
-		ws:   conn,
-		Send: make(chan Parcel),
-		done: make(chan bool),
+		ws:     conn,
+		client: client,
+		Send:   make(chan Parcel),
+		done:   make(chan bool),
 	}
 	go w.writer(c)
 	go w.reader(c)
@@ -87,7 +90,7 @@ writer:
 			log.Debugf("Writing ws %p, %s, %s", c.ws, parcel.Channel, parcel.Data)
 			d := json.RawMessage(parcel.Data)
 			message, _ := json.Marshal(wsPayload{
-				Channel: parcel.Channel,
+				Channel: c.client.clientChannel(parcel.Channel),
 				Data:    &d,
 			})
 			c.ws.WriteMessage(websocket.TextMessage, message)
@@ -139,17 +142,25 @@ func (w *WsPubSub) reader(c *connection) {
 		}
 		switch payload.Action {
 		case "sub":
+			if !c.client.CanSubscribe(payload.Channel) {
+				log.Debugf("Rejected subscription to %v of %p", payload.Channel, c.ws)
+				c.ws.WriteMessage(
+					websocket.TextMessage,
+					[]byte("Error: not allowed to subscribe to channel "+payload.Channel),
+				)
+				continue
+			}
 			w.hub.Subscribe <- Parcel{
-				Channel:    payload.Channel,
+				Channel:    c.client.hubChannel(payload.Channel),
 				Connection: c,
 			}
-			c.channels = append(c.channels, payload.Channel)
+			c.channels = append(c.channels, c.client.hubChannel(payload.Channel))
 		case "unsub":
 			w.hub.Unsubscribe <- Parcel{
-				Channel:    payload.Channel,
+				Channel:    c.client.hubChannel(payload.Channel),
 				Connection: c,
 			}
-			c.channels = append(c.channels, payload.Channel)
+			c.channels = append(c.channels, c.client.hubChannel(payload.Channel))
 		case "pub":
 			if payload.Data == nil {
 				log.Debugf("Got nil pub data.")
@@ -160,8 +171,16 @@ func (w *WsPubSub) reader(c *connection) {
 				c.ws.WriteMessage(websocket.CloseMessage, nil)
 				return
 			}
+			if !c.client.CanPublish(payload.Channel) {
+				log.Debugf("Rejected publishing to %v of %p", payload.Channel, c.ws)
+				c.ws.WriteMessage(
+					websocket.TextMessage,
+					[]byte("Error: not allowed to publish to channel "+payload.Channel),
+				)
+				continue
+			}
 			w.hub.Broadcast <- Parcel{
-				Channel: payload.Channel,
+				Channel: c.client.hubChannel(payload.Channel),
 				Data:    []byte(*payload.Data),
 			}
 		default:
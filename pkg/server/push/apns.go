@@ -68,6 +68,22 @@ func newPushService(client *http.Client, gatewayType GatewayType) (*push.Service
 	}
 }
 
+// checkAPNSCredential pushes an empty notification to a device token that
+// cannot exist. APNS authenticates the provider before looking at the
+// device token, so any rejection other than 403 means the credential
+// is accepted.
+func checkAPNSCredential(service pushService, headers *push.Headers) error {
+	const unknownDeviceToken = "0000000000000000000000000000000000000000000000000000000000000000"
+	_, err := service.Push(unknownDeviceToken, headers, []byte(`{"aps":{}}`))
+	if pushError, ok := err.(*push.Error); ok && pushError != nil {
+		if pushError.Status == http.StatusForbidden {
+			return pushError
+		}
+		return nil
+	}
+	return err
+}
+
 func checkFailedNotifications(pusher APNSPusher) {
 	for failedNotification := range pusher.getFailedNotificationChannel() {
 		handleFailedNotification(pusher, failedNotification)
@@ -131,3 +131,29 @@ func TestAPNSFeedbackMechanism(t *testing.T) {
 		})
 	})
 }
+
+func TestCheckAPNSCredential(t *testing.T) {
+	Convey("checkAPNSCredential", t, func() {
+		service := &naiveService{}
+
+		Convey("accepts credential when device token is rejected", func() {
+			service.Err = &push.Error{
+				Reason: errors.New("BadDeviceToken"),
+				Status: http.StatusBadRequest,
+			}
+			err := checkAPNSCredential(service, &push.Headers{Topic: "com.example.App"})
+			So(err, ShouldBeNil)
+			So(service.Sent, ShouldHaveLength, 1)
+			So(service.Sent[0].Headers.Topic, ShouldEqual, "com.example.App")
+		})
+
+		Convey("returns error when credential is rejected", func() {
+			service.Err = &push.Error{
+				Reason: errors.New("InvalidProviderToken"),
+				Status: http.StatusForbidden,
+			}
+			err := checkAPNSCredential(service, &push.Headers{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
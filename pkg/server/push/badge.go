@@ -0,0 +1,108 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// BadgeSender is a Sender setting the badge number of the notifications
+// sent by the wrapped Sender to the total unread count of the user of
+// the device.
+//
+// The badge number is set to the "aps" dictionary of an APNS
+// notification and to the "notification" of a GCM notification, unless
+// it is already set by the caller.
+type BadgeSender struct {
+	Sender
+	ConnOpener func() (skydb.Conn, error)
+}
+
+// Send sets the badge number of the notification and sends it by the
+// wrapped Sender. The notification is sent without the badge number if
+// the unread count cannot be fetched.
+func (s *BadgeSender) Send(ctx context.Context, m Mapper, device skydb.Device) error {
+	if m != nil && device.AuthInfoID != "" {
+		badged, err := s.badge(m, device.AuthInfoID)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"device": device.ID,
+				"err":    err,
+			}).Warnln("push: failed to get unread counts for badge")
+		} else {
+			m = badged
+		}
+	}
+
+	return s.Sender.Send(ctx, m, device)
+}
+
+// badge returns a copy of the notification with the badge number set,
+// leaving the notification of the caller unchanged.
+func (s *BadgeSender) badge(m Mapper, userID string) (Mapper, error) {
+	data := m.Map()
+	apnsMap, _ := data["apns"].(map[string]interface{})
+	aps, hasAPS := apnsMap["aps"].(map[string]interface{})
+	gcmMap, _ := data["gcm"].(map[string]interface{})
+	notification, hasNotification := gcmMap["notification"].(map[string]interface{})
+
+	setsAPS := hasAPS && aps["badge"] == nil
+	setsGCM := hasNotification && notification["badge"] == nil
+	if !setsAPS && !setsGCM {
+		return m, nil
+	}
+
+	conn, err := s.ConnOpener()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	counts, err := conn.GetUnreadCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	total := counts.Total()
+
+	badged := copyMap(data)
+	if setsAPS {
+		aps = copyMap(aps)
+		aps["badge"] = total
+		apnsMap = copyMap(apnsMap)
+		apnsMap["aps"] = aps
+		badged["apns"] = apnsMap
+	}
+	if setsGCM {
+		notification = copyMap(notification)
+		notification["badge"] = strconv.FormatInt(total, 10)
+		gcmMap = copyMap(gcmMap)
+		gcmMap["notification"] = notification
+		badged["gcm"] = gcmMap
+	}
+	return MapMapper(badged), nil
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,123 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+type unreadConn struct {
+	skydb.Conn
+	counts skydb.UnreadCounts
+}
+
+func (conn *unreadConn) GetUnreadCounts(userID string) (skydb.UnreadCounts, error) {
+	if userID != "alice" {
+		return skydb.UnreadCounts{}, nil
+	}
+	return conn.counts, nil
+}
+
+func (conn *unreadConn) Close() error {
+	return nil
+}
+
+func TestBadgeSender(t *testing.T) {
+	Convey("BadgeSender", t, func() {
+		sender := &mockSender{}
+		badgeSender := &BadgeSender{
+			Sender: sender,
+			ConnOpener: func() (skydb.Conn, error) {
+				return &unreadConn{counts: skydb.UnreadCounts{"message": 2, "comment": 1}}, nil
+			},
+		}
+		device := skydb.Device{
+			ID:         "device",
+			AuthInfoID: "alice",
+		}
+
+		Convey("sets badge of apns and gcm notification", func() {
+			m := MapMapper{
+				"apns": map[string]interface{}{
+					"aps": map[string]interface{}{
+						"alert": "Hello",
+					},
+				},
+				"gcm": map[string]interface{}{
+					"notification": map[string]interface{}{
+						"title": "Hello",
+					},
+				},
+			}
+			So(badgeSender.Send(context.Background(), m, device), ShouldBeNil)
+			So(sender.note, ShouldResemble, map[string]interface{}{
+				"apns": map[string]interface{}{
+					"aps": map[string]interface{}{
+						"alert": "Hello",
+						"badge": int64(3),
+					},
+				},
+				"gcm": map[string]interface{}{
+					"notification": map[string]interface{}{
+						"title": "Hello",
+						"badge": "3",
+					},
+				},
+			})
+
+			Convey("without changing the notification of caller", func() {
+				aps := m["apns"].(map[string]interface{})["aps"].(map[string]interface{})
+				So(aps, ShouldNotContainKey, "badge")
+			})
+		})
+
+		Convey("does not override badge set by caller", func() {
+			m := MapMapper{
+				"apns": map[string]interface{}{
+					"aps": map[string]interface{}{
+						"badge": 10,
+					},
+				},
+			}
+			So(badgeSender.Send(context.Background(), m, device), ShouldBeNil)
+			So(sender.note, ShouldResemble, map[string]interface{}{
+				"apns": map[string]interface{}{
+					"aps": map[string]interface{}{
+						"badge": 10,
+					},
+				},
+			})
+		})
+
+		Convey("does not set badge of device without user", func() {
+			m := MapMapper{
+				"apns": map[string]interface{}{
+					"aps": map[string]interface{}{},
+				},
+			}
+			So(badgeSender.Send(context.Background(), m, skydb.Device{ID: "device"}), ShouldBeNil)
+			So(sender.note, ShouldResemble, map[string]interface{}{
+				"apns": map[string]interface{}{
+					"aps": map[string]interface{}{},
+				},
+			})
+		})
+	})
+}
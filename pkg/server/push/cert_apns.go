@@ -15,6 +15,7 @@
 package push
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/asn1"
@@ -149,7 +150,7 @@ func (pusher *certBasedAPNSPusher) Stop() {
 
 // Send sends a notification to the device identified by the
 // specified device
-func (pusher *certBasedAPNSPusher) Send(m Mapper, device skydb.Device) error {
+func (pusher *certBasedAPNSPusher) Send(ctx context.Context, m Mapper, device skydb.Device) error {
 	logger := log.WithFields(logrus.Fields{
 		"deviceToken": device.Token,
 		"deviceID":    device.ID,
@@ -175,6 +176,12 @@ func (pusher *certBasedAPNSPusher) Send(m Mapper, device skydb.Device) error {
 		Topic: pusher.topic,
 	}
 
+	// The push service does not take a context, so the notification can
+	// only be cancelled before it is sent.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	apnsid, err := pusher.service.Push(device.Token, &headers, serializedPayload)
 	if err != nil {
 		if pushError, ok := err.(*push.Error); ok && pushError != nil {
@@ -199,6 +206,13 @@ func (pusher *certBasedAPNSPusher) Send(m Mapper, device skydb.Device) error {
 	return nil
 }
 
+// CheckCredential verifies the certificate with APNS.
+func (pusher *certBasedAPNSPusher) CheckCredential() error {
+	return checkAPNSCredential(pusher.service, &push.Headers{
+		Topic: pusher.topic,
+	})
+}
+
 func (pusher certBasedAPNSPusher) getFailedNotificationChannel() chan failedNotification {
 	return pusher.failed
 }
@@ -15,6 +15,7 @@
 package push
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"testing"
@@ -117,8 +118,8 @@ func TestCertBaseAPNSPusherSend(t *testing.T) {
 				},
 			}
 
-			So(pusher.Send(customMap, device), ShouldBeNil)
-			So(pusher.Send(customMap, secondDevice), ShouldBeNil)
+			So(pusher.Send(context.Background(), customMap, device), ShouldBeNil)
+			So(pusher.Send(context.Background(), customMap, secondDevice), ShouldBeNil)
 
 			So(len(service.Sent), ShouldEqual, 2)
 
@@ -156,7 +157,7 @@ func TestCertBaseAPNSPusherSend(t *testing.T) {
 		})
 
 		Convey("returns error when missing apns dictionary", func() {
-			err := pusher.Send(EmptyMapper, device)
+			err := pusher.Send(context.Background(), EmptyMapper, device)
 			So(err, ShouldResemble, errors.New("push/apns: payload has no apns dictionary"))
 		})
 
@@ -166,7 +167,7 @@ func TestCertBaseAPNSPusherSend(t *testing.T) {
 				Status:    http.StatusBadRequest,
 				Timestamp: time.Time{},
 			}
-			err := pusher.Send(MapMapper{
+			err := pusher.Send(context.Background(), MapMapper{
 				"apns": map[string]interface{}{},
 			}, device)
 			So(err, ShouldResemble, service.Err)
@@ -179,7 +180,7 @@ func TestCertBaseAPNSPusherSend(t *testing.T) {
 				Timestamp: time.Now(),
 			}
 			service.Err = &pushError
-			err := pusher.Send(MapMapper{
+			err := pusher.Send(context.Background(), MapMapper{
 				"apns": map[string]interface{}{},
 			}, device)
 			So(err, ShouldResemble, &pushError)
@@ -201,7 +202,7 @@ func TestCertBaseAPNSPusherSend(t *testing.T) {
 				},
 			}
 
-			err := pusher.Send(customMap, device)
+			err := pusher.Send(context.Background(), customMap, device)
 
 			So(err, ShouldBeNil)
 
@@ -15,6 +15,8 @@
 package push
 
 import (
+	"context"
+
 	"github.com/google/go-gcm"
 	"github.com/mitchellh/mapstructure"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -28,7 +30,7 @@ type GCMPusher struct {
 }
 
 // Send sends the dictionary represented by m to device.
-func (p *GCMPusher) Send(m Mapper, device skydb.Device) error {
+func (p *GCMPusher) Send(ctx context.Context, m Mapper, device skydb.Device) error {
 	message := gcm.HttpMessage{}
 
 	if err := mapGCMMessage(m, &message); err != nil {
@@ -39,6 +41,12 @@ func (p *GCMPusher) Send(m Mapper, device skydb.Device) error {
 	message.To = device.Token
 	message.RegistrationIds = nil
 
+	// gcm.SendHttp does not take a context, so the notification can only
+	// be cancelled before it is sent.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// NOTE(limouren): might need to check repsonse for deleted / invalid
 	// device here
 	if _, err := gcmSendHTTP(p.APIKey, message); err != nil {
@@ -49,6 +57,18 @@ func (p *GCMPusher) Send(m Mapper, device skydb.Device) error {
 	return nil
 }
 
+// CheckCredential verifies the API key by sending a dry run message to
+// a registration token that cannot exist. GCM responds with HTTP 401
+// when the API key is rejected, and with a per-message error otherwise.
+func (p *GCMPusher) CheckCredential() error {
+	message := gcm.HttpMessage{
+		To:     "skygear-credential-check",
+		DryRun: true,
+	}
+	_, err := gcmSendHTTP(p.APIKey, message)
+	return err
+}
+
 func mapGCMMessage(mapper Mapper, msg *gcm.HttpMessage) error {
 	m := mapper.Map()
 	if gcmMap, ok := m["gcm"].(map[string]interface{}); ok {
@@ -15,6 +15,7 @@
 package push
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -46,7 +47,7 @@ func TestGCMSend(t *testing.T) {
 		}()
 
 		Convey("sends notification", func() {
-			err := pusher.Send(MapMapper{
+			err := pusher.Send(context.Background(), MapMapper{
 				"gcm": map[string]interface{}{
 					"content_available": true,
 					"notification": map[string]interface{}{
@@ -93,9 +94,18 @@ func TestGCMSend(t *testing.T) {
 				return nil, errors.New("gcm_test: some error")
 			}
 
-			err := pusher.Send(EmptyMapper, device)
+			err := pusher.Send(context.Background(), EmptyMapper, device)
 			So(err, ShouldResemble, errors.New("gcm_test: some error"))
 		})
+
+		Convey("does not send notification of cancelled context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := pusher.Send(ctx, EmptyMapper, device)
+			So(err, ShouldEqual, context.Canceled)
+			So(apiKey, ShouldBeEmpty)
+		})
 	})
 
 }
@@ -15,7 +15,10 @@
 package push
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 
@@ -52,7 +55,16 @@ func (m MapMapper) Map() map[string]interface{} {
 
 // Sender defines the methods that a push service should support.
 type Sender interface {
-	Send(m Mapper, device skydb.Device) error
+	// Send sends the notification to the device. The notification is
+	// not sent if ctx is done before sending.
+	Send(ctx context.Context, m Mapper, device skydb.Device) error
+}
+
+// CredentialChecker is implemented by senders that are able to verify
+// their credentials with the push service without delivering any
+// notification.
+type CredentialChecker interface {
+	CheckCredential() error
 }
 
 // RouteSender routes notifications to registered senders that is capable of
@@ -80,7 +92,7 @@ func (s RouteSender) Len() int {
 }
 
 // Send inspects device and route notification (m) to corresponding sender.
-func (s RouteSender) Send(m Mapper, device skydb.Device) error {
+func (s RouteSender) Send(ctx context.Context, m Mapper, device skydb.Device) error {
 	sender, ok := s.senders[device.Type]
 	if !ok {
 		log.WithFields(logrus.Fields{
@@ -91,5 +103,40 @@ func (s RouteSender) Send(m Mapper, device skydb.Device) error {
 		return fmt.Errorf("cannot find sender with type = %s", device.Type)
 	}
 
-	return sender.Send(m, device)
+	return sender.Send(ctx, m, device)
+}
+
+// SwitchSender sends notifications with the sender it holds, which can be
+// switched while notifications are being sent, such as after the push
+// notification credentials are rotated.
+type SwitchSender struct {
+	mutex  sync.RWMutex
+	sender Sender
+	stop   func()
+}
+
+// Switch replaces the sender. The stop function of the previous sender is
+// called, if any, after the new sender is in place.
+func (s *SwitchSender) Switch(sender Sender, stop func()) {
+	s.mutex.Lock()
+	previousStop := s.stop
+	s.sender = sender
+	s.stop = stop
+	s.mutex.Unlock()
+
+	if previousStop != nil {
+		previousStop()
+	}
+}
+
+// Send sends notification (m) with the current sender.
+func (s *SwitchSender) Send(ctx context.Context, m Mapper, device skydb.Device) error {
+	s.mutex.RLock()
+	sender := s.sender
+	s.mutex.RUnlock()
+
+	if sender == nil {
+		return errors.New("push: no sender is set")
+	}
+	return sender.Send(ctx, m, device)
 }
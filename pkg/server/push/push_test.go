@@ -15,6 +15,7 @@
 package push
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 
@@ -30,7 +31,7 @@ type mockSender struct {
 	err    error
 }
 
-func (s *mockSender) Send(m Mapper, device skydb.Device) error {
+func (s *mockSender) Send(ctx context.Context, m Mapper, device skydb.Device) error {
 	if s.err != nil {
 		return s.err
 	}
@@ -65,7 +66,7 @@ func TestRouteSender(t *testing.T) {
 				},
 			}
 
-			err := routeSender.Send(MapMapper(message), device)
+			err := routeSender.Send(context.Background(), MapMapper(message), device)
 			So(err, ShouldBeNil)
 			So(apnsSender.note, ShouldResemble, message)
 			So(apnsSender.device, ShouldResemble, device)
@@ -76,7 +77,7 @@ func TestRouteSender(t *testing.T) {
 				Type: "sns",
 			}
 
-			err := routeSender.Send(EmptyMapper, device)
+			err := routeSender.Send(context.Background(), EmptyMapper, device)
 			So(err.Error(), ShouldEqual, "cannot find sender with type = sns")
 		})
 
@@ -86,7 +87,7 @@ func TestRouteSender(t *testing.T) {
 			}
 
 			gcmSender.err = errors.New("mysterious error")
-			err := routeSender.Send(EmptyMapper, device)
+			err := routeSender.Send(context.Background(), EmptyMapper, device)
 			So(err, ShouldEqual, gcmSender.err)
 		})
 	})
@@ -99,3 +100,34 @@ func jsonToMap(j string) map[string]interface{} {
 	}
 	return m
 }
+
+func TestSwitchSender(t *testing.T) {
+	Convey("SwitchSender", t, func() {
+		switchSender := &SwitchSender{}
+		device := skydb.Device{
+			Type: "aps",
+		}
+
+		Convey("errors without sender", func() {
+			err := switchSender.Send(context.Background(), EmptyMapper, device)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("sends with the switched sender and stops the previous one", func() {
+			oldSender := mockSender{}
+			newSender := mockSender{}
+			stopped := false
+
+			switchSender.Switch(&oldSender, func() {
+				stopped = true
+			})
+			So(switchSender.Send(context.Background(), EmptyMapper, device), ShouldBeNil)
+			So(oldSender.device, ShouldResemble, device)
+
+			switchSender.Switch(&newSender, nil)
+			So(stopped, ShouldBeTrue)
+			So(switchSender.Send(context.Background(), EmptyMapper, device), ShouldBeNil)
+			So(newSender.device, ShouldResemble, device)
+		})
+	})
+}
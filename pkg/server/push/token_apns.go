@@ -15,6 +15,7 @@
 package push
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/tls"
 	"crypto/x509"
@@ -179,7 +180,7 @@ func (pusher *tokenBasedAPNSPusher) Stop() {
 
 // Send sends a notification to the device identified by the
 // specified device
-func (pusher *tokenBasedAPNSPusher) Send(m Mapper, device skydb.Device) error {
+func (pusher *tokenBasedAPNSPusher) Send(ctx context.Context, m Mapper, device skydb.Device) error {
 	logger := log.WithFields(logrus.Fields{
 		"deviceToken": device.Token,
 		"deviceID":    device.ID,
@@ -211,6 +212,12 @@ func (pusher *tokenBasedAPNSPusher) Send(m Mapper, device skydb.Device) error {
 		Authorization: pusher.getToken().value,
 	}
 
+	// The push service does not take a context, so the notification can
+	// only be cancelled before it is sent.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	apnsid, err := pusher.service.Push(device.Token, &headers, serializedPayload)
 	if err != nil {
 		if pushError, ok := err.(*push.Error); ok && pushError != nil {
@@ -235,6 +242,17 @@ func (pusher *tokenBasedAPNSPusher) Send(m Mapper, device skydb.Device) error {
 	return nil
 }
 
+// CheckCredential verifies the auth key with APNS. A fresh provider
+// token is signed if the pusher has not been started.
+func (pusher *tokenBasedAPNSPusher) CheckCredential() error {
+	if pusher.getToken().value == "" {
+		pusher.refreshToken()
+	}
+	return checkAPNSCredential(pusher.service, &push.Headers{
+		Authorization: pusher.getToken().value,
+	})
+}
+
 func (pusher tokenBasedAPNSPusher) getFailedNotificationChannel() chan failedNotification {
 	return pusher.failed
 }
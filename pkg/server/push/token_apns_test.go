@@ -15,6 +15,7 @@
 package push
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"testing"
@@ -91,8 +92,8 @@ func TestTokenBaseAPNSPusherSend(t *testing.T) {
 				},
 			}
 
-			So(pusher.Send(customMap, device), ShouldBeNil)
-			So(pusher.Send(customMap, secondDevice), ShouldBeNil)
+			So(pusher.Send(context.Background(), customMap, device), ShouldBeNil)
+			So(pusher.Send(context.Background(), customMap, secondDevice), ShouldBeNil)
 
 			So(len(service.Sent), ShouldEqual, 2)
 
@@ -132,7 +133,7 @@ func TestTokenBaseAPNSPusherSend(t *testing.T) {
 		})
 
 		Convey("returns error when missing apns dictionary", func() {
-			err := pusher.Send(EmptyMapper, device)
+			err := pusher.Send(context.Background(), EmptyMapper, device)
 			So(err, ShouldResemble, errors.New("push/apns: payload has no apns dictionary"))
 		})
 
@@ -142,7 +143,7 @@ func TestTokenBaseAPNSPusherSend(t *testing.T) {
 				Status:    http.StatusBadRequest,
 				Timestamp: time.Time{},
 			}
-			err := pusher.Send(MapMapper{
+			err := pusher.Send(context.Background(), MapMapper{
 				"apns": map[string]interface{}{},
 			}, device)
 			So(err, ShouldResemble, service.Err)
@@ -155,7 +156,7 @@ func TestTokenBaseAPNSPusherSend(t *testing.T) {
 				Timestamp: time.Now(),
 			}
 			service.Err = &pushError
-			err := pusher.Send(MapMapper{
+			err := pusher.Send(context.Background(), MapMapper{
 				"apns": map[string]interface{}{},
 			}, device)
 			So(err, ShouldResemble, &pushError)
@@ -177,7 +178,7 @@ func TestTokenBaseAPNSPusherSend(t *testing.T) {
 				},
 			}
 
-			err := pusher.Send(customMap, device)
+			err := pusher.Send(context.Background(), customMap, device)
 
 			So(err, ShouldBeNil)
 
@@ -0,0 +1,201 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package querycache caches the results of record queries keyed by the
+// generated SQL statement and its arguments.
+//
+// Cached results are invalidated per record type. Each record type has a
+// generation number in the cache store, which is part of the keys of the
+// results of queries on the record type. Invalidating a record type
+// increments its generation, so that results cached before are no longer
+// looked up and expire with the TTL. Since the generations are kept in
+// the store, a store shared by multiple servers, such as Redis, is
+// invalidated for all of them.
+package querycache
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+)
+
+var log = logging.LoggerEntry("querycache")
+
+// Cache caches the records returned by queries. A nil Cache caches
+// nothing. It is safe for concurrent use if the Store is.
+type Cache struct {
+	Store  Store
+	Prefix string
+	TTL    time.Duration
+}
+
+// New returns a Cache storing query results in the specified Store for
+// the TTL. Keys in the store are prefixed with prefix.
+func New(store Store, prefix string, ttl time.Duration) *Cache {
+	if prefix != "" {
+		prefix = prefix + ":"
+	}
+	return &Cache{
+		Store:  store,
+		Prefix: prefix,
+		TTL:    ttl,
+	}
+}
+
+// Key returns the key of the result of a query with the SQL statement
+// and arguments. recordTypes are the types of the records the query
+// depends on, the first of which is the queried record type.
+func (c *Cache) Key(recordTypes []string, sql string, args []interface{}) (string, error) {
+	h := sha256.New()
+	for _, recordType := range recordTypes {
+		generation, err := c.Store.Get(c.generationKey(recordType))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s@%s\x00", recordType, generation)
+	}
+	h.Write([]byte(sql))
+	h.Write([]byte{0})
+	if err := writeArgs(h, args); err != nil {
+		return "", err
+	}
+	return c.Prefix + "query:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeArgs(h hash.Hash, args []interface{}) error {
+	for _, arg := range args {
+		if valuer, ok := arg.(driver.Valuer); ok {
+			value, err := valuer.Value()
+			if err != nil {
+				return err
+			}
+			arg = value
+		}
+		fmt.Fprintf(h, "%T:%v\x00", arg, arg)
+	}
+	return nil
+}
+
+// Get returns the records cached with the key. It returns false if the
+// records are not cached or cannot be read from the store.
+func (c *Cache) Get(key string) ([]skydb.Record, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	data, err := c.Store.Get(key)
+	if err != nil {
+		log.WithError(err).Warnln("querycache: failed to get query result")
+		return nil, false
+	}
+	if data == nil {
+		return nil, false
+	}
+
+	cachedRecords := []cachedRecord{}
+	if err := json.Unmarshal(data, &cachedRecords); err != nil {
+		log.WithError(err).Warnln("querycache: failed to decode query result")
+		return nil, false
+	}
+	records := make([]skydb.Record, len(cachedRecords))
+	for i, cached := range cachedRecords {
+		records[i] = cached.toRecord()
+	}
+	return records, true
+}
+
+// Set caches the records with the key for the TTL of the cache.
+func (c *Cache) Set(key string, records []skydb.Record) {
+	if c == nil {
+		return
+	}
+
+	cachedRecords := make([]cachedRecord, len(records))
+	for i := range records {
+		cachedRecords[i] = newCachedRecord(&records[i])
+	}
+	data, err := json.Marshal(cachedRecords)
+	if err != nil {
+		log.WithError(err).Warnln("querycache: failed to encode query result")
+		return
+	}
+	if err := c.Store.Set(key, data, c.TTL); err != nil {
+		log.WithError(err).Warnln("querycache: failed to set query result")
+	}
+}
+
+// Invalidate invalidates the cached results of queries depending on the
+// record type.
+func (c *Cache) Invalidate(recordType string) {
+	if c == nil {
+		return
+	}
+
+	if err := c.Store.Incr(c.generationKey(recordType)); err != nil {
+		log.WithError(err).WithField("type", recordType).Errorln("querycache: failed to invalidate record type")
+	}
+}
+
+func (c *Cache) generationKey(recordType string) string {
+	return c.Prefix + "generation:" + recordType
+}
+
+// cachedRecord is a record with the metadata, which is not encoded by
+// skyconv.JSONRecord.
+type cachedRecord struct {
+	Record    *skyconv.JSONRecord `json:"record"`
+	OwnerID   string              `json:"owner_id"`
+	CreatedAt time.Time           `json:"created_at"`
+	CreatorID string              `json:"creator_id"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	UpdaterID string              `json:"updater_id"`
+	DeletedAt *time.Time          `json:"deleted_at,omitempty"`
+	Revision  int64               `json:"revision"`
+}
+
+func newCachedRecord(record *skydb.Record) cachedRecord {
+	return cachedRecord{
+		Record:    (*skyconv.JSONRecord)(record),
+		OwnerID:   record.OwnerID,
+		CreatedAt: record.CreatedAt,
+		CreatorID: record.CreatorID,
+		UpdatedAt: record.UpdatedAt,
+		UpdaterID: record.UpdaterID,
+		DeletedAt: record.DeletedAt,
+		Revision:  record.Revision,
+	}
+}
+
+func (cached cachedRecord) toRecord() skydb.Record {
+	record := skydb.Record{}
+	if cached.Record != nil {
+		record = skydb.Record(*cached.Record)
+	}
+	record.OwnerID = cached.OwnerID
+	record.CreatedAt = cached.CreatedAt
+	record.CreatorID = cached.CreatorID
+	record.UpdatedAt = cached.UpdatedAt
+	record.UpdaterID = cached.UpdaterID
+	record.DeletedAt = cached.DeletedAt
+	record.Revision = cached.Revision
+	return record
+}
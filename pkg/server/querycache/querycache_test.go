@@ -0,0 +1,127 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querycache
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestMemoryStore(t *testing.T) {
+	Convey("MemoryStore", t, func() {
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		realTimeNow := timeNow
+		timeNow = func() time.Time { return now }
+		defer func() {
+			timeNow = realTimeNow
+		}()
+
+		store := NewMemoryStore()
+
+		Convey("gets value before expiry", func() {
+			So(store.Set("key", []byte("value"), time.Minute), ShouldBeNil)
+			value, err := store.Get("key")
+			So(err, ShouldBeNil)
+			So(value, ShouldResemble, []byte("value"))
+
+			now = now.Add(time.Minute)
+			value, err = store.Get("key")
+			So(err, ShouldBeNil)
+			So(value, ShouldBeNil)
+		})
+
+		Convey("increments value", func() {
+			So(store.Incr("counter"), ShouldBeNil)
+			So(store.Incr("counter"), ShouldBeNil)
+			value, err := store.Get("counter")
+			So(err, ShouldBeNil)
+			So(string(value), ShouldEqual, "2")
+		})
+	})
+}
+
+func TestCache(t *testing.T) {
+	Convey("Cache", t, func() {
+		cache := New(NewMemoryStore(), "app", time.Minute)
+		createdAt := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		records := []skydb.Record{
+			{
+				ID:        skydb.NewRecordID("note", "1"),
+				OwnerID:   "alice",
+				CreatedAt: createdAt,
+				CreatorID: "alice",
+				UpdatedAt: createdAt,
+				UpdaterID: "alice",
+				Revision:  3,
+				Data: skydb.Data{
+					"content":    "hello",
+					"written_at": createdAt,
+				},
+			},
+		}
+
+		key, err := cache.Key([]string{"note"}, "SELECT * FROM note WHERE a = $1", []interface{}{"x"})
+		So(err, ShouldBeNil)
+
+		Convey("returns cached records", func() {
+			cache.Set(key, records)
+			cached, ok := cache.Get(key)
+			So(ok, ShouldBeTrue)
+			So(cached, ShouldResemble, records)
+		})
+
+		Convey("misses query with different arguments", func() {
+			cache.Set(key, records)
+			otherKey, err := cache.Key([]string{"note"}, "SELECT * FROM note WHERE a = $1", []interface{}{"y"})
+			So(err, ShouldBeNil)
+			So(otherKey, ShouldNotEqual, key)
+			_, ok := cache.Get(otherKey)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("invalidates record type", func() {
+			cache.Set(key, records)
+			cache.Invalidate("note")
+
+			newKey, err := cache.Key([]string{"note"}, "SELECT * FROM note WHERE a = $1", []interface{}{"x"})
+			So(err, ShouldBeNil)
+			_, ok := cache.Get(newKey)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("invalidates queries depending on record type", func() {
+			key, err := cache.Key([]string{"note", "user"}, "SELECT * FROM note", nil)
+			So(err, ShouldBeNil)
+			cache.Set(key, records)
+			cache.Invalidate("user")
+
+			newKey, err := cache.Key([]string{"note", "user"}, "SELECT * FROM note", nil)
+			So(err, ShouldBeNil)
+			_, ok := cache.Get(newKey)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("caches nothing if nil", func() {
+			var nilCache *Cache
+			nilCache.Set(key, records)
+			_, ok := nilCache.Get(key)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
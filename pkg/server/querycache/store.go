@@ -0,0 +1,175 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querycache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Store is a key-value store of the cache.
+type Store interface {
+	// Get returns the value of the key, or nil if the key does not
+	// exist or has expired.
+	Get(key string) ([]byte, error)
+
+	// Set sets the value of the key, which expires after ttl. The key
+	// does not expire if ttl is zero.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Incr increments the integer value of the key, which does not
+	// expire. A key which does not exist is incremented from zero.
+	Incr(key string) error
+}
+
+// memoryStorePurgeSize is the number of entries of a MemoryStore above
+// which expired entries are purged when a key is set.
+const memoryStorePurgeSize = 10000
+
+var timeNow = time.Now
+
+// MemoryStore is a Store keeping values in the memory of the process.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiredAt time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiredAt.IsZero() && !now.Before(e.expiredAt)
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: map[string]memoryEntry{},
+	}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if entry.expired(timeNow()) {
+		delete(s.entries, key)
+		return nil, nil
+	}
+	return entry.value, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := timeNow()
+	if len(s.entries) >= memoryStorePurgeSize {
+		for k, entry := range s.entries {
+			if entry.expired(now) {
+				delete(s.entries, k)
+			}
+		}
+	}
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiredAt = now.Add(ttl)
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+// Incr implements Store.
+func (s *MemoryStore) Incr(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var n int64
+	if entry, ok := s.entries[key]; ok {
+		n, _ = strconv.ParseInt(string(entry.value), 10, 64)
+	}
+	s.entries[key] = memoryEntry{value: []byte(strconv.FormatInt(n+1, 10))}
+	return nil
+}
+
+// RedisStore is a Store keeping values in Redis, which can be shared by
+// multiple servers.
+type RedisStore struct {
+	pool *redis.Pool
+}
+
+// NewRedisStore returns a RedisStore connecting to the Redis server at
+// the URL address.
+func NewRedisStore(address string) *RedisStore {
+	return &RedisStore{
+		pool: &redis.Pool{
+			MaxIdle: 50,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(address)
+			},
+			TestOnBorrow: func(c redis.Conn, t time.Time) error {
+				_, err := c.Do("PING")
+				return err
+			},
+		},
+	}
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(key string) ([]byte, error) {
+	c := s.pool.Get()
+	defer c.Close()
+
+	value, err := redis.Bytes(c.Do("GET", key))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	return value, err
+}
+
+// Set implements Store.
+func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	c := s.pool.Get()
+	defer c.Close()
+
+	var err error
+	if ttl > 0 {
+		_, err = c.Do("SET", key, value, "PX", int64(ttl/time.Millisecond))
+	} else {
+		_, err = c.Do("SET", key, value)
+	}
+	return err
+}
+
+// Incr implements Store.
+func (s *RedisStore) Incr(key string) error {
+	c := s.pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("INCR", key)
+	return err
+}
@@ -0,0 +1,130 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package querylimit limits the cost of record queries submitted by
+// clients, so that a single expensive query cannot overload the
+// database.
+//
+// The number of referenced record types joined by a query is limited by
+// the database implementation instead, because the joins are only known
+// when the query is compiled.
+package querylimit
+
+import (
+	"fmt"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// Limits are the limits on the cost of record queries. A limit of zero
+// means unlimited.
+type Limits struct {
+	// MaxLimit is the maximum number of records returned by a query.
+	// Queries without a limit are limited to it.
+	MaxLimit uint64
+
+	// RecordTypeMaxLimits overrides MaxLimit for the record types.
+	RecordTypeMaxLimits map[string]uint64
+
+	// MaxPredicateDepth is the maximum nesting depth of the predicate
+	// of a query, where a comparison has a depth of one.
+	MaxPredicateDepth int
+}
+
+// MaxLimitOf returns the maximum number of records returned by a query
+// of the record type. A nil Limits is unlimited.
+func (l *Limits) MaxLimitOf(recordType string) uint64 {
+	if l == nil {
+		return 0
+	}
+	if maxLimit, ok := l.RecordTypeMaxLimits[recordType]; ok {
+		return maxLimit
+	}
+	return l.MaxLimit
+}
+
+// CheckPredicate returns an error if the predicate of the query is
+// nested deeper than the limit.
+func (l *Limits) CheckPredicate(query *skydb.Query) skyerr.Error {
+	if l == nil || l.MaxPredicateDepth <= 0 {
+		return nil
+	}
+
+	if depth := PredicateDepth(query.Predicate); depth > l.MaxPredicateDepth {
+		return tooComplex(
+			fmt.Sprintf("predicate is nested deeper than %d levels", l.MaxPredicateDepth),
+			"max_predicate_depth", l.MaxPredicateDepth,
+		)
+	}
+	return nil
+}
+
+// ApplyLimit limits the number of records returned by the query. Queries
+// without a limit or page size are limited to the maximum, and an error
+// is returned if the query asks for more records than the maximum.
+func (l *Limits) ApplyLimit(query *skydb.Query) skyerr.Error {
+	maxLimit := l.MaxLimitOf(query.Type)
+	if maxLimit == 0 {
+		return nil
+	}
+
+	if query.PageSize > maxLimit {
+		return tooComplex(
+			fmt.Sprintf("page_size of %s cannot be more than %d", query.Type, maxLimit),
+			"max_limit", maxLimit,
+		)
+	}
+	if query.Limit != nil && *query.Limit > maxLimit {
+		return tooComplex(
+			fmt.Sprintf("limit of %s cannot be more than %d", query.Type, maxLimit),
+			"max_limit", maxLimit,
+		)
+	}
+	if query.Limit == nil && query.PageSize == 0 {
+		query.Limit = &maxLimit
+	}
+	return nil
+}
+
+// PredicateDepth returns the nesting depth of the predicate. An empty
+// predicate has a depth of zero.
+func PredicateDepth(p skydb.Predicate) int {
+	if p.IsEmpty() {
+		return 0
+	}
+	if !p.Operator.IsCompound() {
+		return 1
+	}
+
+	depth := 0
+	for _, child := range p.Children {
+		if predicate, ok := child.(skydb.Predicate); ok {
+			if childDepth := PredicateDepth(predicate); childDepth > depth {
+				depth = childDepth
+			}
+		}
+	}
+	return depth + 1
+}
+
+func tooComplex(message string, limitName string, limit interface{}) skyerr.Error {
+	return skyerr.NewErrorWithInfo(
+		skyerr.RecordQueryTooComplex,
+		"query too complex: "+message,
+		map[string]interface{}{
+			limitName: limit,
+		},
+	)
+}
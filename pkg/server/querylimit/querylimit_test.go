@@ -0,0 +1,129 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querylimit
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func equal(key string, value interface{}) skydb.Predicate {
+	return skydb.Predicate{
+		Operator: skydb.Equal,
+		Children: []interface{}{
+			skydb.Expression{Type: skydb.KeyPath, Value: key},
+			skydb.Expression{Type: skydb.Literal, Value: value},
+		},
+	}
+}
+
+func TestPredicateDepth(t *testing.T) {
+	Convey("PredicateDepth", t, func() {
+		So(PredicateDepth(skydb.Predicate{}), ShouldEqual, 0)
+		So(PredicateDepth(equal("title", "a")), ShouldEqual, 1)
+		So(PredicateDepth(skydb.Predicate{
+			Operator: skydb.And,
+			Children: []interface{}{
+				equal("title", "a"),
+				skydb.Predicate{
+					Operator: skydb.Or,
+					Children: []interface{}{
+						equal("title", "b"),
+						skydb.Predicate{
+							Operator: skydb.Not,
+							Children: []interface{}{equal("title", "c")},
+						},
+					},
+				},
+			},
+		}), ShouldEqual, 4)
+	})
+}
+
+func TestLimits(t *testing.T) {
+	Convey("Limits", t, func() {
+		limits := &Limits{
+			MaxLimit: 100,
+			RecordTypeMaxLimits: map[string]uint64{
+				"log": 1000,
+			},
+			MaxPredicateDepth: 2,
+		}
+
+		Convey("limits query without limit", func() {
+			query := skydb.Query{Type: "note"}
+			So(limits.ApplyLimit(&query), ShouldBeNil)
+			So(*query.Limit, ShouldEqual, 100)
+		})
+
+		Convey("limits query of record type with its own limit", func() {
+			query := skydb.Query{Type: "log"}
+			So(limits.ApplyLimit(&query), ShouldBeNil)
+			So(*query.Limit, ShouldEqual, 1000)
+		})
+
+		Convey("does not limit query paginated by keyset", func() {
+			query := skydb.Query{Type: "note", PageSize: 50}
+			So(limits.ApplyLimit(&query), ShouldBeNil)
+			So(query.Limit, ShouldBeNil)
+		})
+
+		Convey("rejects limit more than maximum", func() {
+			limit := uint64(101)
+			query := skydb.Query{Type: "note", Limit: &limit}
+			err := limits.ApplyLimit(&query)
+			So(err, ShouldNotBeNil)
+			So(err.Code(), ShouldEqual, skyerr.RecordQueryTooComplex)
+			So(err.Message(), ShouldEqual, "query too complex: limit of note cannot be more than 100")
+		})
+
+		Convey("rejects page size more than maximum", func() {
+			query := skydb.Query{Type: "note", PageSize: 101}
+			So(limits.ApplyLimit(&query), ShouldNotBeNil)
+		})
+
+		Convey("rejects predicate nested too deep", func() {
+			query := skydb.Query{
+				Type: "note",
+				Predicate: skydb.Predicate{
+					Operator: skydb.Or,
+					Children: []interface{}{
+						equal("title", "a"),
+						skydb.Predicate{
+							Operator: skydb.Not,
+							Children: []interface{}{equal("title", "c")},
+						},
+					},
+				},
+			}
+			err := limits.CheckPredicate(&query)
+			So(err, ShouldNotBeNil)
+			So(err.Code(), ShouldEqual, skyerr.RecordQueryTooComplex)
+			So(err.Info(), ShouldResemble, map[string]interface{}{"max_predicate_depth": 2})
+		})
+
+		Convey("does not limit with nil limits", func() {
+			var limits *Limits
+			query := skydb.Query{Type: "note"}
+			So(limits.ApplyLimit(&query), ShouldBeNil)
+			So(limits.CheckPredicate(&query), ShouldBeNil)
+			So(query.Limit, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package querywatchdog enforces a hard ceiling on the execution time of
+// database queries and keeps track of the queries exceeding it, so that
+// operators can identify the queries to be optimized.
+package querywatchdog
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var whitespaceRe = regexp.MustCompile(`\s+`)
+
+// Shape returns the shape of a SQL statement, which is the statement
+// with consecutive whitespaces collapsed. Arguments are not part of the
+// shape because they are passed separately as placeholders.
+func Shape(sql string) string {
+	return strings.TrimSpace(whitespaceRe.ReplaceAllLiteralString(sql, " "))
+}
+
+// Key returns a short key identifying a query shape.
+func Key(shape string) string {
+	sum := sha1.Sum([]byte(shape))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Violation is the record of a query shape exceeding the ceiling.
+type Violation struct {
+	// Shape is the SQL statement of the query.
+	Shape string `json:"shape"`
+
+	// Count is the number of times the query is cancelled.
+	Count int64 `json:"count"`
+
+	// LastViolatedAt is the time the query is last cancelled.
+	LastViolatedAt time.Time `json:"last_violated_at"`
+}
+
+// Watchdog cancels queries exceeding the ceiling and counts the
+// violations per query shape. It is safe for concurrent use.
+//
+// Watchdog implements expvar.Var so that it can be published as a metric.
+type Watchdog struct {
+	// Ceiling is the maximum execution time of a query. The watchdog
+	// is disabled if it is zero.
+	Ceiling time.Duration
+
+	mutex      sync.Mutex
+	violations map[string]*Violation
+}
+
+// New returns a Watchdog with the specified ceiling.
+func New(ceiling time.Duration) *Watchdog {
+	return &Watchdog{
+		Ceiling: ceiling,
+	}
+}
+
+// Enabled returns whether queries should be cancelled by the watchdog.
+// A nil Watchdog is disabled.
+func (w *Watchdog) Enabled() bool {
+	return w != nil && w.Ceiling > 0
+}
+
+// Report records that a query of the specified SQL statement is
+// cancelled because it exceeds the ceiling. It returns the key of the
+// query shape.
+//
+// Report on a nil Watchdog is a no-op.
+func (w *Watchdog) Report(sql string, at time.Time) string {
+	shape := Shape(sql)
+	key := Key(shape)
+	if w == nil {
+		return key
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.violations == nil {
+		w.violations = map[string]*Violation{}
+	}
+	v, ok := w.violations[key]
+	if !ok {
+		v = &Violation{Shape: shape}
+		w.violations[key] = v
+	}
+	v.Count++
+	v.LastViolatedAt = at
+	return key
+}
+
+// Snapshot returns the current violations keyed by the key of the query
+// shape.
+func (w *Watchdog) Snapshot() map[string]Violation {
+	snapshot := map[string]Violation{}
+	if w == nil {
+		return snapshot
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for key, v := range w.violations {
+		snapshot[key] = *v
+	}
+	return snapshot
+}
+
+// Reset discards all recorded violations.
+func (w *Watchdog) Reset() {
+	if w == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.violations = nil
+}
+
+// String implements expvar.Var. It returns the snapshot in JSON.
+func (w *Watchdog) String() string {
+	bytes, err := json.Marshal(w.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(bytes)
+}
@@ -0,0 +1,89 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querywatchdog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShape(t *testing.T) {
+	Convey("Shape", t, func() {
+		Convey("collapses whitespaces", func() {
+			So(Shape("SELECT *\n\tFROM \"note\"  WHERE id = $1 "), ShouldEqual, `SELECT * FROM "note" WHERE id = $1`)
+		})
+
+		Convey("returns same key for same shape", func() {
+			So(Key(Shape("SELECT 1")), ShouldEqual, Key(Shape(" SELECT\n1")))
+			So(Key(Shape("SELECT 1")), ShouldNotEqual, Key(Shape("SELECT 2")))
+			So(Key("SELECT 1"), ShouldHaveLength, 16)
+		})
+	})
+}
+
+func TestWatchdog(t *testing.T) {
+	Convey("Watchdog", t, func() {
+		w := New(time.Second)
+		at := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		Convey("is enabled with ceiling", func() {
+			So(w.Enabled(), ShouldBeTrue)
+			So(New(0).Enabled(), ShouldBeFalse)
+		})
+
+		Convey("returns empty snapshot", func() {
+			So(w.Snapshot(), ShouldResemble, map[string]Violation{})
+		})
+
+		Convey("counts violations per query shape", func() {
+			key := w.Report("SELECT * FROM note", at)
+			So(w.Report("SELECT *  FROM note", at.Add(time.Minute)), ShouldEqual, key)
+			w.Report("SELECT * FROM user", at)
+
+			snapshot := w.Snapshot()
+			So(snapshot, ShouldHaveLength, 2)
+			So(snapshot[key], ShouldResemble, Violation{
+				Shape:          "SELECT * FROM note",
+				Count:          2,
+				LastViolatedAt: at.Add(time.Minute),
+			})
+		})
+
+		Convey("resets violations", func() {
+			w.Report("SELECT 1", at)
+			w.Reset()
+			So(w.Snapshot(), ShouldBeEmpty)
+		})
+
+		Convey("implements expvar.Var", func() {
+			key := w.Report("SELECT 1", at)
+
+			var result map[string]interface{}
+			So(json.Unmarshal([]byte(w.String()), &result), ShouldBeNil)
+			So(result, ShouldContainKey, key)
+		})
+
+		Convey("nil watchdog is a no-op", func() {
+			var nilWatchdog *Watchdog
+			So(nilWatchdog.Enabled(), ShouldBeFalse)
+			So(nilWatchdog.Report("SELECT 1", at), ShouldEqual, Key("SELECT 1"))
+			So(nilWatchdog.Snapshot(), ShouldBeEmpty)
+			nilWatchdog.Reset()
+		})
+	})
+}
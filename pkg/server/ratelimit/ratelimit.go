@@ -0,0 +1,132 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit implements a token bucket rate limiter keyed by
+// client, and exports counters of the limited requests.
+package ratelimit
+
+import (
+	"expvar"
+	"math"
+	"sync"
+	"time"
+)
+
+// Metrics counts the requests checked by limiters. It is exported by
+// expvar as "ratelimit".
+var Metrics = expvar.NewMap("ratelimit")
+
+// Metric names in Metrics.
+const (
+	MetricAllowed     = "allowed"
+	MetricLimited     = "limited"
+	MetricSoftLimited = "soft_limited"
+)
+
+// Result is the state of the bucket of a key after a request is taken.
+type Result struct {
+	Allowed bool
+
+	// Limit is the maximum number of requests that can be made in a burst.
+	Limit int
+
+	// Remaining is the number of requests that can be made immediately.
+	Remaining int
+
+	// Reset is the duration until the bucket is full again.
+	Reset time.Duration
+
+	// RetryAfter is the duration until the next request is allowed. It is
+	// zero if the request is allowed.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// Limiter allows requests of each key at Rate per second on average,
+// with bursts of at most Burst requests.
+type Limiter struct {
+	Rate  float64
+	Burst int
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	pruneAt int
+	now     func() time.Time
+}
+
+// minPruneAt is the number of buckets below which buckets are not pruned.
+const minPruneAt = 1024
+
+// NewLimiter returns a new Limiter.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Limiter{
+		Rate:    rate,
+		Burst:   burst,
+		buckets: map[string]*bucket{},
+		pruneAt: minPruneAt,
+		now:     time.Now,
+	}
+}
+
+// Take takes a token from the bucket of the specified key.
+func (l *Limiter) Take(key string) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.pruneAt {
+			l.prune(now)
+			l.pruneAt = int(math.Max(minPruneAt, float64(2*len(l.buckets))))
+		}
+		b = &bucket{tokens: float64(l.Burst), updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*l.Rate)
+	b.updatedAt = now
+
+	result := Result{Limit: l.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = l.duration(float64(l.Burst) - b.tokens)
+	return result
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.Rate * float64(time.Second)))
+}
+
+// prune removes the buckets that are full, which are equivalent to
+// buckets that do not exist.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updatedAt).Seconds()*l.Rate >= float64(l.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
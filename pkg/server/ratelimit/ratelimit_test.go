@@ -0,0 +1,70 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLimiter(t *testing.T) {
+	Convey("Limiter", t, func() {
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		limiter := NewLimiter(1, 2)
+		limiter.now = func() time.Time { return now }
+
+		Convey("allows burst and then limits", func() {
+			So(limiter.Take("a").Allowed, ShouldBeTrue)
+
+			result := limiter.Take("a")
+			So(result.Allowed, ShouldBeTrue)
+			So(result.Limit, ShouldEqual, 2)
+			So(result.Remaining, ShouldEqual, 0)
+			So(result.Reset, ShouldEqual, 2*time.Second)
+
+			result = limiter.Take("a")
+			So(result.Allowed, ShouldBeFalse)
+			So(result.RetryAfter, ShouldEqual, time.Second)
+		})
+
+		Convey("refills over time", func() {
+			limiter.Take("a")
+			limiter.Take("a")
+			So(limiter.Take("a").Allowed, ShouldBeFalse)
+
+			now = now.Add(time.Second)
+			So(limiter.Take("a").Allowed, ShouldBeTrue)
+		})
+
+		Convey("limits keys separately", func() {
+			limiter.Take("a")
+			limiter.Take("a")
+			So(limiter.Take("a").Allowed, ShouldBeFalse)
+			So(limiter.Take("b").Allowed, ShouldBeTrue)
+		})
+
+		Convey("prunes full buckets", func() {
+			limiter.pruneAt = 1
+			limiter.Take("a")
+
+			now = now.Add(time.Minute)
+			limiter.Take("b")
+			So(limiter.buckets, ShouldContainKey, "b")
+			So(limiter.buckets, ShouldNotContainKey, "a")
+		})
+	})
+}
@@ -0,0 +1,239 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recordstats tracks the number and latency of record operations
+// per record type, so that the record types responsible for load can be
+// identified.
+package recordstats
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operation is a kind of record operation being tracked.
+type Operation string
+
+// A list of tracked record operations.
+const (
+	Query  Operation = "query"
+	Save   Operation = "save"
+	Delete Operation = "delete"
+)
+
+// DefaultSampleSize is the number of latest latency samples kept for each
+// record type and operation to compute latency percentiles.
+const DefaultSampleSize = 1000
+
+// DefaultMaxRecordTypes is the number of record types tracked separately
+// if Collector.MaxRecordTypes is not specified.
+const DefaultMaxRecordTypes = 100
+
+// OtherRecordType is the record type under which operations are tracked
+// once the number of record types tracked separately reaches the limit.
+// Record types come from requests and may not exist, so they are capped
+// to keep the memory bounded.
+const OtherRecordType = "_other"
+
+// Stats is the statistics of an operation on a record type.
+type Stats struct {
+	// Count is the number of requests made.
+	Count int64 `json:"count"`
+
+	// Records is the number of records processed.
+	Records int64 `json:"records"`
+
+	// Errors is the number of requests failed.
+	Errors int64 `json:"errors"`
+
+	// Latencies of the latest requests at the 50th, 90th and 99th
+	// percentile, in milliseconds.
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P99 float64 `json:"p99_ms"`
+}
+
+type operationStats struct {
+	count   int64
+	records int64
+	errors  int64
+	samples []time.Duration
+	next    int
+}
+
+func (s *operationStats) observe(records int, d time.Duration, err error, sampleSize int) {
+	s.count++
+	if err != nil {
+		s.errors++
+	} else {
+		s.records += int64(records)
+	}
+	if len(s.samples) < sampleSize {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % sampleSize
+}
+
+func (s *operationStats) stats() Stats {
+	sorted := make([]time.Duration, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Sort(durations(sorted))
+
+	return Stats{
+		Count:   s.count,
+		Records: s.records,
+		Errors:  s.errors,
+		P50:     percentile(sorted, 0.5),
+		P90:     percentile(sorted, 0.9),
+		P99:     percentile(sorted, 0.99),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+// in milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// Collector collects the statistics of record operations. It is safe for
+// concurrent use.
+//
+// Collector implements expvar.Var so that it can be published as a metric.
+type Collector struct {
+	// SampleSize is the number of latest latency samples kept for each
+	// record type and operation. DefaultSampleSize is used if it is zero.
+	SampleSize int
+
+	// MaxRecordTypes is the number of record types tracked separately.
+	// Operations on other record types are tracked under
+	// OtherRecordType. DefaultMaxRecordTypes is used if it is zero.
+	MaxRecordTypes int
+
+	mutex sync.Mutex
+	stats map[string]map[Operation]*operationStats
+}
+
+// NewCollector returns a Collector with the default sample size and
+// number of record types.
+func NewCollector() *Collector {
+	return &Collector{
+		SampleSize:     DefaultSampleSize,
+		MaxRecordTypes: DefaultMaxRecordTypes,
+	}
+}
+
+// Observe records that an operation processing the specified number of
+// records of a record type took the specified duration. The operation is
+// counted as an error if err is not nil, in which case the records are
+// not counted as processed.
+//
+// Observe on a nil Collector is a no-op.
+func (c *Collector) Observe(recordType string, op Operation, records int, d time.Duration, err error) {
+	if c == nil {
+		return
+	}
+
+	sampleSize := c.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = DefaultSampleSize
+	}
+	maxRecordTypes := c.MaxRecordTypes
+	if maxRecordTypes <= 0 {
+		maxRecordTypes = DefaultMaxRecordTypes
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stats == nil {
+		c.stats = map[string]map[Operation]*operationStats{}
+	}
+	opStats, ok := c.stats[recordType]
+	if !ok {
+		if len(c.stats) >= maxRecordTypes {
+			recordType = OtherRecordType
+			opStats = c.stats[recordType]
+		}
+		if opStats == nil {
+			opStats = map[Operation]*operationStats{}
+			c.stats[recordType] = opStats
+		}
+	}
+	s, ok := opStats[op]
+	if !ok {
+		s = &operationStats{}
+		opStats[op] = s
+	}
+	s.observe(records, d, err, sampleSize)
+}
+
+// Snapshot returns the current statistics keyed by record type and
+// operation.
+func (c *Collector) Snapshot() map[string]map[Operation]Stats {
+	snapshot := map[string]map[Operation]Stats{}
+	if c == nil {
+		return snapshot
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for recordType, opStats := range c.stats {
+		snapshot[recordType] = map[Operation]Stats{}
+		for op, s := range opStats {
+			snapshot[recordType][op] = s.stats()
+		}
+	}
+	return snapshot
+}
+
+// Reset discards all collected statistics.
+func (c *Collector) Reset() {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats = nil
+}
+
+// String implements expvar.Var. It returns the snapshot in JSON.
+func (c *Collector) String() string {
+	bytes, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(bytes)
+}
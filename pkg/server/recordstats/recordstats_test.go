@@ -0,0 +1,127 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recordstats
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollector(t *testing.T) {
+	Convey("Collector", t, func() {
+		c := &Collector{SampleSize: 10}
+
+		Convey("returns empty snapshot", func() {
+			So(c.Snapshot(), ShouldResemble, map[string]map[Operation]Stats{})
+		})
+
+		Convey("counts operations per record type", func() {
+			c.Observe("note", Query, 3, 10*time.Millisecond, nil)
+			c.Observe("note", Query, 1, 20*time.Millisecond, nil)
+			c.Observe("note", Save, 2, 5*time.Millisecond, nil)
+			c.Observe("user", Delete, 1, 1*time.Millisecond, nil)
+
+			snapshot := c.Snapshot()
+			So(snapshot["note"][Query].Count, ShouldEqual, 2)
+			So(snapshot["note"][Query].Records, ShouldEqual, 4)
+			So(snapshot["note"][Save].Count, ShouldEqual, 1)
+			So(snapshot["user"][Delete].Count, ShouldEqual, 1)
+			So(snapshot["user"], ShouldNotContainKey, Query)
+		})
+
+		Convey("computes latency percentiles", func() {
+			for i := 1; i <= 10; i++ {
+				c.Observe("note", Query, 1, time.Duration(i)*time.Millisecond, nil)
+			}
+
+			stats := c.Snapshot()["note"][Query]
+			So(stats.P50, ShouldEqual, 5)
+			So(stats.P90, ShouldEqual, 9)
+			So(stats.P99, ShouldEqual, 10)
+		})
+
+		Convey("keeps only the latest samples", func() {
+			for i := 0; i < 10; i++ {
+				c.Observe("note", Query, 1, 100*time.Millisecond, nil)
+			}
+			for i := 0; i < 10; i++ {
+				c.Observe("note", Query, 1, time.Millisecond, nil)
+			}
+
+			stats := c.Snapshot()["note"][Query]
+			So(stats.Count, ShouldEqual, 20)
+			So(stats.P99, ShouldEqual, 1)
+		})
+
+		Convey("counts failed operations as errors", func() {
+			c.Observe("note", Query, 3, time.Millisecond, nil)
+			c.Observe("note", Query, 0, time.Millisecond, errors.New("timeout"))
+
+			stats := c.Snapshot()["note"][Query]
+			So(stats.Count, ShouldEqual, 2)
+			So(stats.Records, ShouldEqual, 3)
+			So(stats.Errors, ShouldEqual, 1)
+		})
+
+		Convey("tracks record types beyond the limit as other", func() {
+			c.MaxRecordTypes = 2
+			c.Observe("note", Query, 1, time.Millisecond, nil)
+			c.Observe("user", Query, 1, time.Millisecond, nil)
+			c.Observe("missing1", Query, 1, time.Millisecond, nil)
+			c.Observe("missing2", Query, 1, time.Millisecond, nil)
+			c.Observe("note", Save, 1, time.Millisecond, nil)
+
+			snapshot := c.Snapshot()
+			So(snapshot, ShouldHaveLength, 3)
+			So(snapshot["note"][Save].Count, ShouldEqual, 1)
+			So(snapshot[OtherRecordType][Query].Count, ShouldEqual, 2)
+		})
+
+		Convey("resets statistics", func() {
+			c.Observe("note", Query, 1, time.Millisecond, nil)
+			c.Reset()
+			So(c.Snapshot(), ShouldBeEmpty)
+		})
+
+		Convey("marshals snapshot as expvar", func() {
+			c.Observe("note", Save, 1, 2*time.Millisecond, nil)
+
+			var v map[string]interface{}
+			So(json.Unmarshal([]byte(c.String()), &v), ShouldBeNil)
+			So(v, ShouldResemble, map[string]interface{}{
+				"note": map[string]interface{}{
+					"save": map[string]interface{}{
+						"count":   float64(1),
+						"records": float64(1),
+						"errors":  float64(0),
+						"p50_ms":  float64(2),
+						"p90_ms":  float64(2),
+						"p99_ms":  float64(2),
+					},
+				},
+			})
+		})
+
+		Convey("ignores observation on nil collector", func() {
+			var nilCollector *Collector
+			nilCollector.Observe("note", Query, 1, time.Millisecond, nil)
+			So(nilCollector.Snapshot(), ShouldBeEmpty)
+		})
+	})
+}
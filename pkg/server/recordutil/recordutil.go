@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -79,12 +80,32 @@ type RecordModifyRequest struct {
 	ModifyAt      time.Time
 
 	// Save only
-	RecordsToSave []*skydb.Record
+	RecordsToSave  []*skydb.Record
+	RevisionPolicy string
+	// Merge saves only the fields supplied in RecordsToSave into the
+	// existing records. Records that do not exist are not created.
+	Merge bool
 
 	// Delete Only
 	RecordIDsToDelete []skydb.RecordID
+	SoftDelete        bool
 }
 
+// Revision policies decide how the revision (_rev) supplied by the client
+// is checked when saving an existing record.
+const (
+	// RevisionPolicyIgnore saves the record regardless of the revision.
+	RevisionPolicyIgnore = "ignore"
+
+	// RevisionPolicyReject rejects the save if the supplied revision is
+	// stale. Records saved without a revision are not checked.
+	RevisionPolicyReject = "reject"
+
+	// RevisionPolicyRequire is like RevisionPolicyReject, but also rejects
+	// the save if no revision is supplied.
+	RevisionPolicyRequire = "require"
+)
+
 type RecordModifyResponse struct {
 	ErrMap           map[skydb.RecordID]skyerr.Error
 	SavedRecords     []*skydb.Record
@@ -139,6 +160,13 @@ func (f RecordFetcher) getDefaultAccess(recordType string) skydb.RecordACL {
 }
 
 func (f RecordFetcher) FetchRecord(recordID skydb.RecordID, authInfo *skydb.AuthInfo, accessLevel skydb.RecordACLLevel) (record *skydb.Record, err skyerr.Error) {
+	record, _, err = f.fetchRecord(recordID, authInfo, accessLevel)
+	return
+}
+
+// fetchRecord fetches the record as FetchRecord does, and reports whether
+// the record is not found because it is soft-deleted.
+func (f RecordFetcher) fetchRecord(recordID skydb.RecordID, authInfo *skydb.AuthInfo, accessLevel skydb.RecordACLLevel) (record *skydb.Record, softDeleted bool, err skyerr.Error) {
 	dbRecord := skydb.Record{}
 	if dbErr := f.db.Get(recordID, &dbRecord); dbErr != nil {
 		if dbErr == skydb.ErrRecordNotFound {
@@ -153,6 +181,14 @@ func (f RecordFetcher) FetchRecord(recordID skydb.RecordID, authInfo *skydb.Auth
 		return
 	}
 
+	// Soft-deleted records are only visible with the master key, so that
+	// they can be restored or purged.
+	if dbRecord.DeletedAt != nil && !f.withMasterKey {
+		softDeleted = true
+		err = skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+		return
+	}
+
 	record = &dbRecord
 	if !f.withMasterKey && !dbRecord.Accessible(authInfo, accessLevel) {
 		err = skyerr.NewError(
@@ -165,12 +201,22 @@ func (f RecordFetcher) FetchRecord(recordID skydb.RecordID, authInfo *skydb.Auth
 }
 
 func (f RecordFetcher) FetchOrCreateRecord(recordID skydb.RecordID, authInfo *skydb.AuthInfo) (record skydb.Record, created bool, err skyerr.Error) {
-	fetchedRecord, err := f.FetchRecord(recordID, authInfo, skydb.WriteLevel)
+	fetchedRecord, softDeleted, err := f.fetchRecord(recordID, authInfo, skydb.WriteLevel)
 	if err == nil {
 		record = *fetchedRecord
 		return
 	}
 
+	// Saving a soft-deleted record would overwrite the existing record,
+	// so it is not a creation, and it is restored with the master key only.
+	if softDeleted {
+		err = skyerr.NewError(
+			skyerr.PermissionDenied,
+			"no permission to save a deleted record",
+		)
+		return
+	}
+
 	if err.Code() == skyerr.ResourceNotFound {
 		allowCreation := func() bool {
 			if f.withMasterKey {
@@ -226,12 +272,27 @@ func RecordSaveHandler(req *RecordModifyRequest, resp *RecordModifyResponse) sky
 
 	// fetch records
 	originalRecordMap := map[skydb.RecordID]*skydb.Record{}
+	baseRevisionMap := map[skydb.RecordID]int64{}
+	mergeKeysMap := map[skydb.RecordID][]string{}
 	records = executeRecordFunc(records, resp.ErrMap, func(record *skydb.Record) (err skyerr.Error) {
 		dbRecord, created, err := fetcher.FetchOrCreateRecord(record.ID, req.AuthInfo)
 		if err != nil {
 			return err
 		}
 
+		if req.Merge && created {
+			return skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+		}
+
+		if !created {
+			if err = checkRevision(req.RevisionPolicy, record, &dbRecord); err != nil {
+				return
+			}
+			if record.Revision != 0 {
+				baseRevisionMap[record.ID] = record.Revision
+			}
+		}
+
 		now := req.ModifyAt
 		if created {
 			dbRecord.ID = record.ID
@@ -255,6 +316,15 @@ func RecordSaveHandler(req *RecordModifyRequest, resp *RecordModifyResponse) sky
 			}
 		}
 
+		if req.Merge {
+			// Supplied fields removed by the field ACL are not written.
+			keys := []string{}
+			for key := range record.Data {
+				keys = append(keys, key)
+			}
+			mergeKeysMap[record.ID] = keys
+		}
+
 		if !created {
 			origRecord := dbRecord.Copy()
 			injectSigner(&origRecord, req.AssetStore)
@@ -265,6 +335,8 @@ func RecordSaveHandler(req *RecordModifyRequest, resp *RecordModifyResponse) sky
 		*record = dbRecord
 		record.UpdatedAt = now
 		record.UpdaterID = req.AuthInfo.ID
+		// saving a soft-deleted record restores it
+		record.DeletedAt = nil
 
 		return
 	})
@@ -289,13 +361,46 @@ func RecordSaveHandler(req *RecordModifyRequest, resp *RecordModifyResponse) sky
 		removeRecordFieldTypeHints(r)
 	}
 
+	// check required fields before saving, so that all missing fields
+	// are reported rather than the first one violating the constraint
+	records = executeRecordFunc(records, resp.ErrMap, func(record *skydb.Record) skyerr.Error {
+		schema, _ := db.GetSchema(record.ID.Type)
+		_, isUpdate := originalRecordMap[record.ID]
+		if missing := schema.MissingRequiredFields(record.Data, !isUpdate); len(missing) > 0 {
+			return skyerr.NewInvalidArgument("missing required fields", missing)
+		}
+		return nil
+	})
+
 	// save records
 	records = executeRecordFunc(records, resp.ErrMap, func(record *skydb.Record) (err skyerr.Error) {
 		var deltaRecord skydb.Record
 		originalRecord, _ := originalRecordMap[record.ID]
 		DeriveDeltaRecord(&deltaRecord, originalRecord, record)
+		deltaRecord.BaseRevision = baseRevisionMap[record.ID]
+
+		var dbErr error
+		if req.Merge {
+			// Supplied fields are always written, even if they are
+			// unchanged from the fetched record, which might be stale.
+			for _, key := range mergeKeysMap[record.ID] {
+				if value, ok := record.Data[key]; ok {
+					deltaRecord.Data[key] = value
+				}
+			}
+			dbErr = db.Merge(&deltaRecord)
+		} else {
+			dbErr = db.Save(&deltaRecord)
+		}
+		if dbErr == nil {
+			dbErr = resolveIncrements(db, &deltaRecord)
+		}
 
-		if dbErr := db.Save(&deltaRecord); dbErr != nil {
+		if dbErr == skydb.ErrRecordConflict {
+			err = newRecordConflictErr(deltaRecord.BaseRevision, nil)
+		} else if dbErr == skydb.ErrRecordNotFound {
+			err = skyerr.NewError(skyerr.ResourceNotFound, "record not found")
+		} else if dbErr != nil {
 			err = skyerr.MakeError(dbErr)
 		}
 		*record = deltaRecord
@@ -320,6 +425,75 @@ func RecordSaveHandler(req *RecordModifyRequest, resp *RecordModifyResponse) sky
 	return nil
 }
 
+// resolveIncrements replaces the increments left in the saved record
+// with the stored numbers, so that skydb.Increment values are never
+// returned to the client, even if the database does not replace them.
+func resolveIncrements(db skydb.Database, record *skydb.Record) error {
+	keys := []string{}
+	for key, value := range record.Data {
+		if _, ok := value.(skydb.Increment); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	stored := skydb.Record{}
+	if err := db.Get(record.ID, &stored); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		record.Data[key] = stored.Data[key]
+	}
+	return nil
+}
+
+// checkRevision checks the revision supplied in record against the revision
+// of dbRecord according to the revision policy.
+func checkRevision(policy string, record *skydb.Record, dbRecord *skydb.Record) skyerr.Error {
+	switch policy {
+	case RevisionPolicyReject, RevisionPolicyRequire:
+	default:
+		// the revision is not checked, and the save is not conditional
+		record.Revision = 0
+		return nil
+	}
+
+	if record.Revision == 0 {
+		if policy == RevisionPolicyRequire {
+			return skyerr.NewInvalidArgument(
+				"_rev is required to save an existing record",
+				[]string{"_rev"},
+			)
+		}
+		return nil
+	}
+
+	if record.Revision != dbRecord.Revision {
+		return newRecordConflictErr(record.Revision, dbRecord)
+	}
+	return nil
+}
+
+// newRecordConflictErr returns a RecordConflict error. The current revision
+// and the last modification of the record are included if known.
+func newRecordConflictErr(revision int64, current *skydb.Record) skyerr.Error {
+	info := map[string]interface{}{
+		"base_revision": revision,
+	}
+	if current != nil {
+		info["current_revision"] = current.Revision
+		info["updated_at"] = current.UpdatedAt
+		info["updated_by"] = current.UpdaterID
+	}
+	return skyerr.NewErrorWithInfo(
+		skyerr.RecordConflict,
+		"record has been modified since the supplied revision",
+		info,
+	)
+}
+
 type saveHookTriggerer struct {
 	Context           context.Context
 	HookRegistry      *hook.Registry
@@ -385,6 +559,8 @@ func DeriveDeltaRecord(dst, base, delta *skydb.Record) {
 	dst.CreatorID = delta.CreatorID
 	dst.UpdatedAt = delta.UpdatedAt
 	dst.UpdaterID = delta.UpdaterID
+	dst.DeletedAt = delta.DeletedAt
+	dst.Revision = delta.Revision
 
 	dst.Data = map[string]interface{}{}
 	for key, value := range delta.Data {
@@ -439,6 +615,22 @@ func ExtendRecordSchema(db skydb.Database, records []*skydb.Record) (bool, error
 	return extended, nil
 }
 
+// RecordBatchHandler saves RecordsToSave and then deletes
+// RecordIDsToDelete, so that records of different types can be saved and
+// deleted in a single transaction.
+func RecordBatchHandler(req *RecordModifyRequest, resp *RecordModifyResponse) skyerr.Error {
+	if len(req.RecordsToSave) > 0 {
+		if err := RecordSaveHandler(req, resp); err != nil {
+			return err
+		}
+	}
+
+	if len(req.RecordIDsToDelete) > 0 {
+		return RecordDeleteHandler(req, resp)
+	}
+	return nil
+}
+
 func RecordDeleteHandler(req *RecordModifyRequest, resp *RecordModifyResponse) skyerr.Error {
 	db := req.Db
 	recordIDs := req.RecordIDsToDelete
@@ -468,6 +660,9 @@ func RecordDeleteHandler(req *RecordModifyRequest, resp *RecordModifyResponse) s
 	}
 
 	records = executeRecordFunc(records, resp.ErrMap, func(record *skydb.Record) (err skyerr.Error) {
+		if req.SoftDelete {
+			return softDeleteRecord(db, record, req.AuthInfo, req.ModifyAt)
+		}
 
 		if dbErr := db.Delete(record.ID); dbErr != nil {
 			return skyerr.MakeError(dbErr)
@@ -495,6 +690,26 @@ func RecordDeleteHandler(req *RecordModifyRequest, resp *RecordModifyResponse) s
 	return nil
 }
 
+// softDeleteRecord marks the record as deleted without removing it from
+// the database, so that it can be restored by saving it with the master key.
+func softDeleteRecord(db skydb.Database, record *skydb.Record, authInfo *skydb.AuthInfo, deletedAt time.Time) skyerr.Error {
+	deletedRecord := record.Copy()
+	deletedRecord.DeletedAt = &deletedAt
+	deletedRecord.UpdatedAt = deletedAt
+	if authInfo != nil {
+		deletedRecord.UpdaterID = authInfo.ID
+	}
+
+	var deltaRecord skydb.Record
+	DeriveDeltaRecord(&deltaRecord, record, &deletedRecord)
+	if dbErr := db.Save(&deltaRecord); dbErr != nil {
+		return skyerr.MakeError(dbErr)
+	}
+
+	*record = deletedRecord
+	return nil
+}
+
 type schemaMerger struct {
 	finalSchema skydb.RecordSchema
 	err         error
@@ -568,6 +783,37 @@ func EagerIDs(db skydb.Database, records []skydb.Record, query skydb.Query) map[
 	return eagers
 }
 
+// QueryIncludes returns the reference fields of the queried record type
+// that are eager loaded by computed keys. Records referenced by these
+// fields can be included by the database together with the query result,
+// instead of being fetched by DoQueryEager.
+func QueryIncludes(db skydb.Database, query skydb.Query) []string {
+	includes := []string{}
+	if len(query.ComputedKeys) == 0 {
+		return includes
+	}
+
+	typemap, err := db.GetSchema(query.Type)
+	if err != nil {
+		return includes
+	}
+
+	included := map[string]bool{}
+	for _, transientExpression := range query.ComputedKeys {
+		if transientExpression.Type != skydb.KeyPath {
+			continue
+		}
+		keyPath := transientExpression.Value.(string)
+		if typemap[keyPath].Type != skydb.TypeReference || included[keyPath] {
+			continue
+		}
+		included[keyPath] = true
+		includes = append(includes, keyPath)
+	}
+	sort.Strings(includes)
+	return includes
+}
+
 // getReferenceWithKeyPath returns a reference for use in eager loading
 // It handles the case where reserved attribute is a string ID instead of
 // a referenced ID.
@@ -619,6 +865,31 @@ func DoQueryEager(db skydb.Database, eagersIDs map[string][]skydb.RecordID) map[
 	return eagerRecords
 }
 
+// DoQueryRelated queries the related includes of the query for the
+// records. The first pages of related records are keyed by the transient
+// key of the include, and then by the key of the referenced record.
+func DoQueryRelated(db skydb.Database, query skydb.Query, records []skydb.Record) (map[string]map[string]*skydb.RelatedPage, error) {
+	relatedPages := map[string]map[string]*skydb.RelatedPage{}
+	if len(query.RelatedIncludes) == 0 || len(records) == 0 {
+		return relatedPages, nil
+	}
+
+	keys := make([]string, len(records))
+	for i, record := range records {
+		keys[i] = record.ID.Key
+	}
+
+	for transientKey, related := range query.RelatedIncludes {
+		related := related
+		pages, err := skydb.QueryRelated(db, &related, query.Type, keys)
+		if err != nil {
+			return nil, err
+		}
+		relatedPages[transientKey] = pages
+	}
+	return relatedPages, nil
+}
+
 func getRecordCount(db skydb.Database, query *skydb.Query, results *skydb.Rows) (uint64, error) {
 	if results != nil {
 		recordCount := results.OverallRecordCount()
@@ -644,9 +915,115 @@ func QueryResultInfo(db skydb.Database, query *skydb.Query, results *skydb.Rows)
 		}
 		resultInfo["count"] = recordCount
 	}
+	if results != nil {
+		if cursor := results.NextCursor(); cursor != nil {
+			resultInfo["next_cursor"] = cursor.Encode()
+		}
+		if plan := results.QueryPlan(); plan != nil {
+			resultInfo["query_plan"] = plan
+		}
+	}
 	return resultInfo, nil
 }
 
+// Count modes of the pagination metadata of a query result.
+const (
+	// QueryMetaCountNone omits the count of matching records.
+	QueryMetaCountNone = ""
+
+	// QueryMetaCountExact counts the matching records.
+	QueryMetaCountExact = "exact"
+
+	// QueryMetaCountEstimated returns the number of matching records
+	// estimated by the database, which is cheaper than counting them.
+	QueryMetaCountEstimated = "estimated"
+)
+
+// QueryMeta is the pagination metadata of a query result.
+type QueryMeta struct {
+	Count          *uint64       `json:"count,omitempty"`
+	CountEstimated bool          `json:"count_estimated,omitempty"`
+	Page           QueryPageInfo `json:"page"`
+	NextCursor     string        `json:"next_cursor,omitempty"`
+}
+
+// QueryPageInfo describes the page of a query result.
+//
+// For queries paginated by keyset, the next page is fetched with
+// QueryMeta.NextCursor. Otherwise, the next and previous pages are
+// fetched with NextOffset and PreviousOffset.
+type QueryPageInfo struct {
+	Offset         uint64  `json:"offset"`
+	Limit          *uint64 `json:"limit,omitempty"`
+	Returned       int     `json:"returned"`
+	HasNext        bool    `json:"has_next"`
+	HasPrevious    bool    `json:"has_previous"`
+	NextOffset     *uint64 `json:"next_offset,omitempty"`
+	PreviousOffset *uint64 `json:"previous_offset,omitempty"`
+}
+
+// NewQueryMeta returns the pagination metadata of a query result, of
+// which returned records are in the page.
+//
+// Without a count, a query paginated by offset is considered to have a
+// next page if the page is full.
+func NewQueryMeta(db skydb.Database, query *skydb.Query, results *skydb.Rows, returned int, countMode string) (*QueryMeta, error) {
+	meta := &QueryMeta{}
+	switch countMode {
+	case QueryMetaCountExact:
+		recordCount, err := getRecordCount(db, query, results)
+		if err != nil {
+			return nil, err
+		}
+		meta.Count = &recordCount
+	case QueryMetaCountEstimated:
+		estimateQuery := *query
+		estimateQuery.EstimateCount = true
+		recordCount, err := db.QueryCount(&estimateQuery)
+		if err != nil {
+			return nil, err
+		}
+		meta.Count = &recordCount
+		meta.CountEstimated = true
+	}
+
+	page := &meta.Page
+	page.Returned = returned
+	if query.IsPaginatedByKeyset() {
+		if query.PageSize > 0 {
+			page.Limit = &query.PageSize
+		}
+		if cursor := results.NextCursor(); cursor != nil {
+			page.HasNext = true
+			meta.NextCursor = cursor.Encode()
+		}
+		page.HasPrevious = query.After != nil
+		return meta, nil
+	}
+
+	page.Offset = query.Offset
+	page.Limit = query.Limit
+	if meta.Count != nil {
+		page.HasNext = query.Offset+uint64(returned) < *meta.Count
+	} else if query.Limit != nil {
+		page.HasNext = *query.Limit > 0 && uint64(returned) == *query.Limit
+	}
+	if page.HasNext {
+		nextOffset := query.Offset + uint64(returned)
+		page.NextOffset = &nextOffset
+	}
+
+	page.HasPrevious = query.Offset > 0
+	if page.HasPrevious && query.Limit != nil {
+		previousOffset := uint64(0)
+		if query.Offset > *query.Limit {
+			previousOffset = query.Offset - *query.Limit
+		}
+		page.PreviousOffset = &previousOffset
+	}
+	return meta, nil
+}
+
 func MakeAssetsComplete(db skydb.Database, conn skydb.Conn, records []skydb.Record) error {
 	if len(records) == 0 {
 		return nil
@@ -713,13 +1090,15 @@ func makeAssetsCompleteAndInjectSigner(db skydb.Database, conn skydb.Conn, recor
 // RecordResultFilter is for processing Record into results.
 //
 // 1. Apply field-based acl, remove fields that are not accessible to the
-//    provided authInfo
+//    provided authInfo, and mask system fields of records not owned by
+//    the provided authInfo
 // 2. Inject asset
 // 3. Return JSONRecord that is a copy of passed in Record that is ready to
 //    be serialized
 type RecordResultFilter struct {
 	AssetStore          asset.Store
 	FieldACL            skydb.FieldACL
+	SystemFieldAccess   map[string]skydb.SystemFieldAccess
 	AuthInfo            *skydb.AuthInfo
 	BypassAccessControl bool
 }
@@ -727,8 +1106,9 @@ type RecordResultFilter struct {
 // NewRecordResultFilter return a RecordResultFilter.
 func NewRecordResultFilter(conn skydb.Conn, assetStore asset.Store, authInfo *skydb.AuthInfo, bypassAccessControl bool) (RecordResultFilter, error) {
 	var (
-		acl skydb.FieldACL
-		err error
+		acl               skydb.FieldACL
+		systemFieldAccess map[string]skydb.SystemFieldAccess
+		err               error
 	)
 
 	if !bypassAccessControl {
@@ -736,12 +1116,18 @@ func NewRecordResultFilter(conn skydb.Conn, assetStore asset.Store, authInfo *sk
 		if err != nil {
 			return RecordResultFilter{}, err
 		}
+
+		systemFieldAccess, err = conn.GetRecordSystemFieldAccess()
+		if err != nil {
+			return RecordResultFilter{}, err
+		}
 	}
 
 	return RecordResultFilter{
 		AssetStore:          assetStore,
 		AuthInfo:            authInfo,
 		FieldACL:            acl,
+		SystemFieldAccess:   systemFieldAccess,
 		BypassAccessControl: bypassAccessControl,
 	}, nil
 }
@@ -754,6 +1140,7 @@ func (f *RecordResultFilter) JSONResult(record *skydb.Record) *skyconv.JSONRecor
 	recordCopy := record.Copy()
 	if !f.BypassAccessControl {
 		scrubRecordFieldsForRead(f.AuthInfo, &recordCopy, f.FieldACL)
+		f.SystemFieldAccess[record.ID.Type].Apply(f.AuthInfo, &recordCopy)
 	}
 	injectSigner(record, f.AssetStore)
 	return (*skyconv.JSONRecord)(&recordCopy)
@@ -763,6 +1150,7 @@ type QueryResultFilter struct {
 	Database           skydb.Database
 	Query              skydb.Query
 	EagerRecords       map[string]map[string]*skydb.Record
+	RelatedPages       map[string]map[string]*skydb.RelatedPage
 	RecordResultFilter RecordResultFilter
 }
 
@@ -791,5 +1179,26 @@ func (f *QueryResultFilter) JSONResult(record *skydb.Record) *skyconv.JSONRecord
 		recordCopy.Transient[transientKey] = transientValue
 	}
 
+	for transientKey := range f.Query.RelatedIncludes {
+		relatedRecords := []*skyconv.JSONRecord{}
+		var nextCursor interface{}
+		if page := f.RelatedPages[transientKey][recordCopy.ID.Key]; page != nil {
+			for i := range page.Records {
+				relatedRecords = append(relatedRecords, f.RecordResultFilter.JSONResult(&page.Records[i]))
+			}
+			if page.NextCursor != nil {
+				nextCursor = page.NextCursor.Encode()
+			}
+		}
+
+		if recordCopy.Transient == nil {
+			recordCopy.Transient = map[string]interface{}{}
+		}
+		recordCopy.Transient[transientKey] = map[string]interface{}{
+			"records":     relatedRecords,
+			"next_cursor": nextCursor,
+		}
+	}
+
 	return f.RecordResultFilter.JSONResult(&recordCopy)
 }
@@ -0,0 +1,126 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recordutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// ExecuteTransition saves the fields of a scheduled transition to the
+// record, as if the record is saved by the user who scheduled the
+// transition. Record hooks are executed as in saving records from a
+// request.
+//
+// Unless the transition is scheduled with the master key, the record ACL
+// and the field ACL are checked against the user who scheduled the
+// transition at the time of execution.
+//
+// The transition is not executed if the record no longer exists.
+func ExecuteTransition(ctx context.Context, conn skydb.Conn, transition skydb.Transition, hookRegistry *hook.Registry, assetStore asset.Store) skyerr.Error {
+	authInfo := &skydb.AuthInfo{ID: transition.CreatedBy}
+	if !transition.WithMasterKey {
+		if err := conn.GetAuth(transition.CreatedBy, authInfo); err == skydb.ErrUserNotFound {
+			return skyerr.NewError(skyerr.PermissionDenied,
+				fmt.Sprintf("user %s who scheduled the transition does not exist", transition.CreatedBy))
+		} else if err != nil {
+			return skyerr.MakeError(err)
+		}
+	}
+
+	var db skydb.Database
+	if transition.DatabaseID == skydb.PublicDatabaseIdentifier {
+		db = conn.PublicDB()
+	} else {
+		db = conn.PrivateDB(transition.DatabaseID)
+	}
+
+	if err := db.Get(transition.RecordID, &skydb.Record{}); err == skydb.ErrRecordNotFound {
+		return skyerr.NewError(skyerr.ResourceNotFound,
+			fmt.Sprintf("record %s of transition does not exist", transition.RecordID))
+	} else if err != nil {
+		return skyerr.MakeError(err)
+	}
+
+	record := skydb.Record{
+		ID: transition.RecordID,
+	}
+	if err := (*skyconv.MapData)(&record.Data).FromMap(transition.Data); err != nil {
+		return skyerr.NewError(skyerr.InvalidArgument,
+			fmt.Sprintf("invalid data of transition: %v", err))
+	}
+
+	records := []*skydb.Record{&record}
+	if _, err := ExtendRecordSchema(db, records); err != nil {
+		return skyerr.MakeError(err)
+	}
+
+	req := RecordModifyRequest{
+		Db:            db,
+		Conn:          conn,
+		AssetStore:    assetStore,
+		HookRegistry:  hookRegistry,
+		AuthInfo:      authInfo,
+		RecordsToSave: records,
+		Atomic:        true,
+		WithMasterKey: transition.WithMasterKey,
+		Context:       ctx,
+		ModifyAt:      time.Now().UTC(),
+	}
+	resp := RecordModifyResponse{
+		ErrMap: map[skydb.RecordID]skyerr.Error{},
+	}
+
+	save := func() error {
+		if err := RecordSaveHandler(&req, &resp); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	var err error
+	if txDB, ok := db.(skydb.Transactional); ok {
+		err = skydb.WithTransaction(txDB, save)
+	} else {
+		err = save()
+	}
+
+	if recordErr, ok := resp.ErrMap[transition.RecordID]; ok {
+		return recordErr
+	} else if err != nil {
+		return skyerr.MakeError(err)
+	}
+	return nil
+}
+
+// IsTransientTransitionError returns true if the transition failed for a
+// reason that may go away, such as an unavailable plugin or database, so
+// that the transition should be retried instead of discarded.
+func IsTransientTransitionError(err skyerr.Error) bool {
+	switch err.Code() {
+	case skyerr.PluginUnavailable, skyerr.PluginTimeout, skyerr.PluginInitializing,
+		skyerr.ResponseTimeout, skyerr.RecordLocked, skyerr.UnderMaintenance,
+		skyerr.RecordConflict:
+		return true
+	}
+	return err.Code() >= skyerr.UnexpectedError
+}
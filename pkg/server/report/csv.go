@@ -0,0 +1,103 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// RenderCSV renders the records as CSV. The first column is the ID of
+// the record, followed by a column of each key. If keys is nil, all keys
+// of the records are rendered in alphabetical order.
+func RenderCSV(records []skydb.Record, keys []string) ([]byte, error) {
+	if keys == nil {
+		keys = recordKeys(records)
+	}
+
+	buf := bytes.Buffer{}
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(append([]string{"_id"}, keys...)); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		row := []string{record.ID.Key}
+		for _, key := range keys {
+			value, err := csvValue(record.Data[key])
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, value)
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func recordKeys(records []skydb.Record) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, record := range records {
+		for key := range record.Data {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		// Strings starting with these characters are evaluated as
+		// formulas by spreadsheet applications.
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v, nil
+		}
+		return v, nil
+	case bool, int, int64, float64:
+		return fmt.Sprint(v), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case skydb.Reference:
+		return v.ID.Key, nil
+	case *skydb.Asset:
+		return v.Name, nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
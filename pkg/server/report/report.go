@@ -0,0 +1,274 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report runs scheduled reports. A report runs a record query on
+// its schedule, renders the records found as CSV and stores it as an
+// asset. The users of the designated roles are notified with a signed
+// link to download it, by push notification and by the report-ready
+// event, which a plugin can handle to send an email.
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/handler"
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+var log = logging.LoggerEntry("report")
+
+// ReadyEvent is the name of the plugin event sent after a report is run.
+const ReadyEvent = "report-ready"
+
+// Runner runs the reports which are due.
+type Runner struct {
+	ConnOpener func() (skydb.Conn, error)
+	AssetStore asset.Store
+
+	// PushSender sends the push notifications of reports. Push
+	// notifications are not sent if it is nil.
+	PushSender push.Sender
+
+	// SendEvent sends the report-ready event to plugins. The event is
+	// not sent if it is nil.
+	SendEvent func(name string, data []byte, async bool)
+}
+
+// RunDue runs the reports which are due at now. Each scheduled run of a
+// report is claimed before it runs, so that it is run by one server
+// only. A run that fails is not retried, and the report runs again at
+// the next scheduled time.
+func (r *Runner) RunDue(now time.Time) {
+	conn, err := r.ConnOpener()
+	if err != nil {
+		log.Warnf("Failed to run reports: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	reports, err := conn.GetReports()
+	if err != nil {
+		log.Warnf("Failed to run reports: %v", err)
+		return
+	}
+
+	for _, report := range reports {
+		runAt, ok, err := DueAt(report, now)
+		if err != nil {
+			log.Warnf("Failed to schedule report %s: %v", report.ID, err)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		claimed, err := conn.ClaimReportRun(report.ID, runAt)
+		if err != nil {
+			log.Warnf("Failed to claim report %s: %v", report.ID, err)
+			continue
+		} else if !claimed {
+			continue
+		}
+
+		if err := r.Run(conn, report, runAt); err != nil {
+			log.Warnf("Failed to run report %s: %v", report.ID, err)
+		}
+	}
+}
+
+// DueAt returns the latest scheduled time of the report at or before now
+// since the report last ran. Runs missed while no server was running
+// are not repeated. It returns false if the report is not due.
+func DueAt(report skydb.Report, now time.Time) (time.Time, bool, error) {
+	schedule, err := cron.Parse(report.Schedule)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	last := report.UpdatedAt
+	if report.LastRunAt != nil {
+		last = *report.LastRunAt
+	}
+
+	runAt := schedule.Next(last)
+	if runAt.IsZero() || runAt.After(now) {
+		return time.Time{}, false, nil
+	}
+	for {
+		next := schedule.Next(runAt)
+		if next.IsZero() || next.After(now) {
+			return runAt, true, nil
+		}
+		runAt = next
+	}
+}
+
+// Run runs the report scheduled at runAt. The query of the report is run
+// against the public database without access control, because reports
+// are defined with the master key to be shared with the designated
+// roles.
+func (r *Runner) Run(conn skydb.Conn, report skydb.Report, runAt time.Time) error {
+	parser := handler.QueryParser{}
+	query, skyErr := parser.ParseQuery(report.Query)
+	if skyErr != nil {
+		return skyErr
+	}
+	query.BypassAccessControl = true
+
+	results, err := conn.PublicDB().Query(&query)
+	if err != nil {
+		return err
+	}
+	records := []skydb.Record{}
+	for results.Scan() {
+		records = append(records, results.Record())
+	}
+	err = results.Err()
+	results.Close()
+	if err != nil {
+		return err
+	}
+
+	data, err := RenderCSV(records, query.DesiredKeys)
+	if err != nil {
+		return err
+	}
+
+	// The name of the asset is not guessable, so that the report is
+	// only accessible by the signed link.
+	reportAsset := skydb.Asset{
+		Name:        fmt.Sprintf("report/%s/%s-%s.csv", report.ID, runAt.UTC().Format("20060102T150405Z"), uuid.New()),
+		ContentType: "text/csv",
+		Size:        int64(len(data)),
+	}
+	err = r.AssetStore.PutFileReader(reportAsset.Name, bytes.NewReader(data), reportAsset.Size, reportAsset.ContentType)
+	if err != nil {
+		return err
+	}
+	if err := conn.SaveAsset(&reportAsset); err != nil {
+		return err
+	}
+
+	signer, ok := r.AssetStore.(asset.URLSigner)
+	if !ok {
+		return fmt.Errorf("asset store cannot sign the url of report")
+	}
+	url, err := signer.SignedURL(reportAsset.Name)
+	if err != nil {
+		return err
+	}
+
+	userIDs, err := recipients(conn, report.Roles)
+	if err != nil {
+		return err
+	}
+
+	r.notify(conn, report, url, userIDs)
+	r.sendReadyEvent(report, reportAsset, runAt, url, userIDs)
+	return nil
+}
+
+// recipients returns the IDs of the users of the roles, and of the roles
+// inheriting them.
+func recipients(conn skydb.Conn, roles []string) ([]string, error) {
+	allRoles, err := conn.GetAllRoles()
+	if err != nil {
+		return nil, err
+	}
+	hierarchy, err := conn.GetRoleHierarchy()
+	if err != nil {
+		return nil, err
+	}
+
+	recipientRoles := []string{}
+	for _, role := range allRoles {
+		for _, reportRole := range roles {
+			if role == reportRole || hierarchy.Inherits(role, reportRole) {
+				recipientRoles = append(recipientRoles, role)
+				break
+			}
+		}
+	}
+	return conn.GetUserIDsByRoles(recipientRoles)
+}
+
+func (r *Runner) notify(conn skydb.Conn, report skydb.Report, url string, userIDs []string) {
+	if r.PushSender == nil {
+		return
+	}
+
+	title := fmt.Sprintf("Report %s is ready", report.Name)
+	notification := push.MapMapper{
+		"apns": map[string]interface{}{
+			"aps": map[string]interface{}{
+				"alert": map[string]interface{}{
+					"title": title,
+				},
+			},
+			"report_id": report.ID,
+			"url":       url,
+		},
+		"gcm": map[string]interface{}{
+			"notification": map[string]interface{}{
+				"title": title,
+			},
+			"data": map[string]interface{}{
+				"report_id": report.ID,
+				"url":       url,
+			},
+		},
+	}
+
+	for _, userID := range userIDs {
+		devices, err := conn.QueryDevicesByUser(userID)
+		if err != nil {
+			log.Warnf("Failed to query devices of user %s for report %s: %v", userID, report.ID, err)
+			continue
+		}
+		for _, device := range devices {
+			if err := r.PushSender.Send(context.Background(), notification, device); err != nil {
+				log.Warnf("Failed to send report %s to device %s: %v", report.ID, device.ID, err)
+			}
+		}
+	}
+}
+
+func (r *Runner) sendReadyEvent(report skydb.Report, reportAsset skydb.Asset, runAt time.Time, url string, userIDs []string) {
+	if r.SendEvent == nil {
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       report.ID,
+		"name":     report.Name,
+		"run_at":   runAt.UTC(),
+		"asset":    reportAsset.Name,
+		"url":      url,
+		"user_ids": userIDs,
+	})
+	if err != nil {
+		log.Warnf("Failed to encode report %s: %v", report.ID, err)
+		return
+	}
+	r.SendEvent(ReadyEvent, data, true)
+}
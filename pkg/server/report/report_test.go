@@ -0,0 +1,301 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type reportDB struct {
+	skydb.Database
+	records []skydb.Record
+	query   *skydb.Query
+}
+
+func (db *reportDB) Query(query *skydb.Query) (*skydb.Rows, error) {
+	db.query = query
+	return skydb.NewRows(skydb.NewMemoryRows(db.records)), nil
+}
+
+type reportConn struct {
+	skydb.Conn
+	db        *reportDB
+	reports   []skydb.Report
+	claimed   map[string]time.Time
+	assets    []skydb.Asset
+	roles     []string
+	hierarchy skydb.RoleHierarchy
+	userRoles map[string][]string
+	devices   map[string][]skydb.Device
+}
+
+func (c *reportConn) PublicDB() skydb.Database {
+	return c.db
+}
+
+func (c *reportConn) GetReports() ([]skydb.Report, error) {
+	return c.reports, nil
+}
+
+func (c *reportConn) ClaimReportRun(id string, runAt time.Time) (bool, error) {
+	if last, ok := c.claimed[id]; ok && !last.Before(runAt) {
+		return false, nil
+	}
+	c.claimed[id] = runAt
+	return true, nil
+}
+
+func (c *reportConn) SaveAsset(asset *skydb.Asset) error {
+	c.assets = append(c.assets, *asset)
+	return nil
+}
+
+func (c *reportConn) GetAllRoles() ([]string, error) {
+	return c.roles, nil
+}
+
+func (c *reportConn) GetRoleHierarchy() (skydb.RoleHierarchy, error) {
+	return c.hierarchy, nil
+}
+
+func (c *reportConn) GetUserIDsByRoles(roles []string) ([]string, error) {
+	userIDs := []string{}
+	for _, userID := range []string{"admin", "manager", "staff"} {
+	userRoles:
+		for _, userRole := range c.userRoles[userID] {
+			for _, role := range roles {
+				if userRole == role {
+					userIDs = append(userIDs, userID)
+					break userRoles
+				}
+			}
+		}
+	}
+	return userIDs, nil
+}
+
+func (c *reportConn) QueryDevicesByUser(user string) ([]skydb.Device, error) {
+	return c.devices[user], nil
+}
+
+func (c *reportConn) Close() error {
+	return nil
+}
+
+type reportAssetStore struct {
+	asset.Store
+	files map[string]string
+}
+
+func (s *reportAssetStore) PutFileReader(name string, src io.Reader, length int64, contentType string) error {
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	s.files[name] = string(data)
+	return nil
+}
+
+func (s *reportAssetStore) SignedURL(name string) (string, error) {
+	return "http://skygear.test/files/" + name + "?signature=signed", nil
+}
+
+func (s *reportAssetStore) IsSignatureRequired() bool {
+	return true
+}
+
+type reportSender struct {
+	sent []skydb.Device
+	note map[string]interface{}
+}
+
+func (s *reportSender) Send(ctx context.Context, m push.Mapper, device skydb.Device) error {
+	s.sent = append(s.sent, device)
+	s.note = m.Map()
+	return nil
+}
+
+func TestDueAt(t *testing.T) {
+	Convey("DueAt", t, func() {
+		updatedAt := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+		report := skydb.Report{
+			Schedule:  "0 0 0 * * *",
+			UpdatedAt: updatedAt,
+		}
+
+		Convey("is not due before the first scheduled time", func() {
+			_, ok, err := DueAt(report, updatedAt.Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("is due at the scheduled time", func() {
+			runAt, ok, err := DueAt(report, time.Date(2017, 1, 2, 0, 0, 30, 0, time.UTC))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(runAt, ShouldResemble, time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC))
+		})
+
+		Convey("returns the latest missed run only", func() {
+			lastRunAt := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+			report.LastRunAt = &lastRunAt
+
+			runAt, ok, err := DueAt(report, time.Date(2017, 1, 5, 1, 0, 0, 0, time.UTC))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(runAt, ShouldResemble, time.Date(2017, 1, 5, 0, 0, 0, 0, time.UTC))
+		})
+
+		Convey("returns error of invalid schedule", func() {
+			report.Schedule = "invalid"
+			_, _, err := DueAt(report, updatedAt)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRenderCSV(t *testing.T) {
+	Convey("RenderCSV", t, func() {
+		records := []skydb.Record{
+			{
+				ID: skydb.NewRecordID("order", "1"),
+				Data: map[string]interface{}{
+					"amount":   float64(10),
+					"customer": skydb.NewReference("user", "alice"),
+					"note":     "=HYPERLINK(\"http://example.com\")",
+				},
+			},
+			{
+				ID: skydb.NewRecordID("order", "2"),
+				Data: map[string]interface{}{
+					"amount":    float64(20.5),
+					"placed_at": time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		}
+
+		Convey("renders all keys", func() {
+			data, err := RenderCSV(records, nil)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, `_id,amount,customer,note,placed_at
+1,10,alice,"'=HYPERLINK(""http://example.com"")",
+2,20.5,,,2017-01-01T00:00:00Z
+`)
+		})
+
+		Convey("renders desired keys", func() {
+			data, err := RenderCSV(records, []string{"amount"})
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "_id,amount\n1,10\n2,20.5\n")
+		})
+	})
+}
+
+func TestRunner(t *testing.T) {
+	Convey("Runner", t, func() {
+		updatedAt := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+		conn := &reportConn{
+			db: &reportDB{
+				records: []skydb.Record{
+					{
+						ID:   skydb.NewRecordID("order", "1"),
+						Data: map[string]interface{}{"amount": float64(10)},
+					},
+				},
+			},
+			reports: []skydb.Report{
+				{
+					ID:        "orders",
+					Name:      "Daily orders",
+					Query:     map[string]interface{}{"record_type": "order"},
+					Schedule:  "0 0 0 * * *",
+					Roles:     []string{"manager"},
+					UpdatedAt: updatedAt,
+				},
+			},
+			claimed:   map[string]time.Time{},
+			roles:     []string{"admin", "manager", "staff"},
+			hierarchy: skydb.RoleHierarchy{"admin": []string{"manager"}},
+			userRoles: map[string][]string{
+				"admin":   []string{"admin"},
+				"manager": []string{"manager"},
+				"staff":   []string{"staff"},
+			},
+			devices: map[string][]skydb.Device{
+				"admin":   []skydb.Device{{ID: "admin-device", Type: "ios"}},
+				"manager": []skydb.Device{{ID: "manager-device", Type: "android"}},
+				"staff":   []skydb.Device{{ID: "staff-device", Type: "ios"}},
+			},
+		}
+		store := &reportAssetStore{files: map[string]string{}}
+		sender := &reportSender{}
+		events := []map[string]interface{}{}
+		runner := &Runner{
+			ConnOpener: func() (skydb.Conn, error) {
+				return conn, nil
+			},
+			AssetStore: store,
+			PushSender: sender,
+			SendEvent: func(name string, data []byte, async bool) {
+				So(name, ShouldEqual, ReadyEvent)
+				event := map[string]interface{}{}
+				So(json.Unmarshal(data, &event), ShouldBeNil)
+				events = append(events, event)
+			},
+		}
+
+		Convey("does not run report before it is due", func() {
+			runner.RunDue(updatedAt.Add(time.Hour))
+			So(conn.assets, ShouldBeEmpty)
+			So(events, ShouldBeEmpty)
+		})
+
+		Convey("runs due report once", func() {
+			now := time.Date(2017, 1, 2, 0, 0, 30, 0, time.UTC)
+			runner.RunDue(now)
+			runner.RunDue(now)
+
+			So(conn.db.query.BypassAccessControl, ShouldBeTrue)
+			So(conn.assets, ShouldHaveLength, 1)
+			reportAsset := conn.assets[0]
+			So(reportAsset.ContentType, ShouldEqual, "text/csv")
+			So(store.files[reportAsset.Name], ShouldEqual, "_id,amount\n1,10\n")
+
+			url := "http://skygear.test/files/" + reportAsset.Name + "?signature=signed"
+			So(sender.sent, ShouldResemble, []skydb.Device{
+				{ID: "admin-device", Type: "ios"},
+				{ID: "manager-device", Type: "android"},
+			})
+			So(sender.note["gcm"].(map[string]interface{})["data"], ShouldResemble, map[string]interface{}{
+				"report_id": "orders",
+				"url":       url,
+			})
+
+			So(events, ShouldHaveLength, 1)
+			So(events[0]["url"], ShouldEqual, url)
+			So(events[0]["user_ids"], ShouldResemble, []interface{}{"admin", "manager"})
+		})
+	})
+}
@@ -23,23 +23,30 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 	"github.com/skygeario/skygear-server/pkg/server/skyversion"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+	"golang.org/x/net/trace"
 )
 
 // commonRouter implements the HandlerFunc interface that is common
 // to Router and Gateway.
 type commonRouter struct {
 	payloadFunc      func(req *http.Request) (p *Payload, err error)
-	matchHandlerFunc func(p *Payload) (h Handler, pp []Processor)
+	matchHandlerFunc func(p *Payload) (h Handler, pp []Processor, mm []Middleware)
 	ResponseTimeout  time.Duration
+
+	// Preprocessors are run for every request before the preprocessors
+	// of the matched handler.
+	Preprocessors []Processor
 }
 
 func (r *commonRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	var (
-		resp          Response
-		payload       *Payload
-		err           error
+		resp    Response
+		payload *Payload
+		err     error
 	)
 
 	version := strings.TrimPrefix(skyversion.Version(), "v")
@@ -54,6 +61,9 @@ func (r *commonRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(httpStatus)
 		return
 	}
+	if requestID := req.Header.Get("X-Request-Id"); requestID != "" {
+		payload.Context = context.WithValue(payload.Context, RequestIDContextKey, requestID)
+	}
 	r.HandlePayload(payload, &resp)
 }
 
@@ -62,13 +72,26 @@ func (r *commonRouter) HandlePayload(payload *Payload, resp *Response) {
 		httpStatus    = http.StatusOK
 		handler       Handler
 		preprocessors []Processor
+		middlewares   []Middleware
 		timedOut      bool
 	)
 
+	// Identify the request so that it can be traced across logs and
+	// plugins. The ID is also returned to the client.
+	requestID := RequestIDFromContext(payload.Context)
+	if requestID == "" {
+		requestID = uuid.New()
+		payload.Context = context.WithValue(payload.Context, RequestIDContextKey, requestID)
+	}
+	resp.Header().Set("X-Request-Id", requestID)
+
 	defer func() {
 		if r := recover(); r != nil {
 			resp.Err = errorFromRecoveringPanic(r)
-			log.WithField("recovered", r).Errorln("panic occurred while handling request")
+			log.WithFields(logrus.Fields{
+				"recovered":  r,
+				"request_id": requestID,
+			}).Errorln("panic occurred while handling request")
 		}
 
 		writer := resp.Writer()
@@ -77,6 +100,9 @@ func (r *commonRouter) HandlePayload(payload *Payload, resp *Response) {
 			return
 		}
 
+		for key, values := range resp.Meta {
+			writer.Header()[key] = values
+		}
 		writer.Header().Set("Content-Type", "application/json")
 
 		if timedOut {
@@ -84,7 +110,7 @@ func (r *commonRouter) HandlePayload(payload *Payload, resp *Response) {
 				skyerr.ResponseTimeout,
 				"Service taking too long to respond.",
 			)
-			log.Errorln("timed out serving request")
+			log.WithField("request_id", requestID).Errorln("timed out serving request")
 		}
 
 		if resp.Err != nil && httpStatus >= 200 && httpStatus <= 299 {
@@ -97,34 +123,50 @@ func (r *commonRouter) HandlePayload(payload *Payload, resp *Response) {
 		}
 	}()
 
-	handler, preprocessors = r.matchHandlerFunc(payload)
+	handler, preprocessors, middlewares = r.matchHandlerFunc(payload)
 	if handler == nil {
 		httpStatus = http.StatusNotFound
 		resp.Err = skyerr.NewError(skyerr.UndefinedOperation, "route unmatched")
 		return
 	}
+	if len(r.Preprocessors) > 0 {
+		preprocessors = append(append([]Processor{}, r.Preprocessors...), preprocessors...)
+	}
+
+	// Trace the handling of the request. The trace is finished by the
+	// handler goroutine, which may outlive this function on timeout.
+	tr := trace.New("skygear.router", payload.RouteAction())
+	tr.LazyPrintf("request_id: %s", requestID)
+	payload.Context = trace.NewContext(payload.Context, tr)
 
-	// Call handler
+	// Call handler. The deadline of the response is set to the context
+	// so that operations of the handler are cancelled when timed out.
 	var cancelFunc context.CancelFunc
-	payload.Context, cancelFunc = context.WithCancel(payload.Context)
+	if r.ResponseTimeout > 0 {
+		payload.Context, cancelFunc = context.WithTimeout(payload.Context, r.ResponseTimeout)
+	} else {
+		payload.Context, cancelFunc = context.WithCancel(payload.Context)
+	}
 	defer cancelFunc()
 
+	ctx := payload.Context
 	go func() {
-		httpStatus = r.callHandler(handler, preprocessors, payload, resp)
+		httpStatus = r.callHandler(handler, preprocessors, middlewares, payload, resp)
+		if resp.Err != nil {
+			tr.LazyPrintf("error: %v", resp.Err)
+			tr.SetError()
+		}
+		tr.Finish()
 		cancelFunc()
 	}()
 
-	// This function will return in one of the following conditions:
-	select {
-	case <-payload.Context.Done():
-		// request conext cancelled or response generated
-	case <-getTimeoutChan(r.ResponseTimeout):
-		// timeout exceeded
-		timedOut = true
-	}
+	// This function will return when the context is done, either the
+	// response is generated, the request is cancelled or timeout exceeded.
+	<-ctx.Done()
+	timedOut = ctx.Err() == context.DeadlineExceeded
 }
 
-func (r *commonRouter) callHandler(handler Handler, pp []Processor, payload *Payload, resp *Response) (httpStatus int) {
+func (r *commonRouter) callHandler(handler Handler, pp []Processor, mm []Middleware, payload *Payload, resp *Response) (httpStatus int) {
 	httpStatus = http.StatusOK
 
 	defer func() {
@@ -136,18 +178,38 @@ func (r *commonRouter) callHandler(handler Handler, pp []Processor, payload *Pay
 		}
 	}()
 
-	for _, p := range pp {
-		httpStatus = p.Preprocess(payload, resp)
-		if resp.Err != nil {
-			if httpStatus == http.StatusOK {
-				httpStatus = defaultStatusCode(resp.Err)
+	next := func() int {
+		status := http.StatusOK
+		for _, p := range pp {
+			status = p.Preprocess(payload, resp)
+			if resp.Err != nil {
+				if status == http.StatusOK {
+					status = defaultStatusCode(resp.Err)
+				}
+				return status
 			}
-			return
+		}
+
+		if h, ok := handler.(SchemaHandler); ok {
+			if err := h.PayloadSchema().Validate(payload.Data); err != nil {
+				resp.Err = err
+				return defaultStatusCode(err)
+			}
+		}
+
+		handler.Handle(payload, resp)
+		return status
+	}
+
+	// The first middleware is the outermost one.
+	for i := len(mm) - 1; i >= 0; i-- {
+		m, inner := mm[i], next
+		next = func() int {
+			return m(payload, resp, inner)
 		}
 	}
 
-	handler.Handle(payload, resp)
-	return httpStatus
+	return next()
 }
 
 func writeEntity(w http.ResponseWriter, i interface{}) error {
@@ -156,10 +218,3 @@ func writeEntity(w http.ResponseWriter, i interface{}) error {
 	}
 	return json.NewEncoder(w).Encode(i)
 }
-
-func getTimeoutChan(timeout time.Duration) <-chan time.Time {
-	if timeout.Seconds() > 0 {
-		return time.After(timeout)
-	}
-	return make(chan time.Time)
-}
@@ -46,6 +46,10 @@ func defaultStatusCode(err skyerr.Error) int {
 		skyerr.ResponseTimeout:         http.StatusServiceUnavailable,
 		skyerr.DeniedArgument:          http.StatusForbidden,
 		skyerr.RecordQueryDenied:       http.StatusForbidden,
+		skyerr.RateLimitExceeded:       http.StatusTooManyRequests,
+		skyerr.RecordLocked:            http.StatusConflict,
+		skyerr.UnderMaintenance:        http.StatusServiceUnavailable,
+		skyerr.RecordConflict:          http.StatusConflict,
 	}[err.Code()]
 	if !ok {
 		if err.Code() < 10000 {
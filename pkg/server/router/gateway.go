@@ -80,7 +80,7 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	g.commonRouter.ServeHTTP(w, req)
 }
 
-func (g *Gateway) matchHandler(p *Payload) (h Handler, pp []Processor) {
+func (g *Gateway) matchHandler(p *Payload) (h Handler, pp []Processor, mm []Middleware) {
 	method := p.Meta["method"].(string)
 	if pathRoute, ok := g.methodPaths[method]; ok {
 		h = pathRoute.Handler
@@ -0,0 +1,89 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps the handling of requests of an action.
+//
+// It calls next to continue with the remaining middlewares, the
+// preprocessors and the handler of the action, and returns the HTTP status
+// code returned by next. A middleware can modify the payload before calling
+// next and the response after that. To reject a request, a middleware sets
+// response.Err and returns without calling next.
+type Middleware func(payload *Payload, response *Response, next func() int) int
+
+// ProcessorMiddleware returns a Middleware running the Processor before
+// the preprocessors of the action. The request is rejected if the
+// Processor sets response.Err.
+func ProcessorMiddleware(p Processor) Middleware {
+	return func(payload *Payload, response *Response, next func() int) int {
+		httpStatus := p.Preprocess(payload, response)
+		if response.Err != nil {
+			if httpStatus == http.StatusOK {
+				httpStatus = defaultStatusCode(response.Err)
+			}
+			return httpStatus
+		}
+		return next()
+	}
+}
+
+type middlewareEntry struct {
+	pattern    string
+	middleware Middleware
+}
+
+// matchAction returns true if the pattern matches the action. The pattern
+// is either an action, a prefix of actions ending with "*" such as
+// "record:*", or "*" which matches all actions.
+func (e middlewareEntry) matchAction(action string) bool {
+	if strings.HasSuffix(e.pattern, "*") {
+		return strings.HasPrefix(action, strings.TrimSuffix(e.pattern, "*"))
+	}
+	return e.pattern == action
+}
+
+// Use registers middlewares wrapping the actions matching the pattern,
+// which is either an action, a prefix of actions ending with "*" such as
+// "record:*", or "*" which matches all actions.
+//
+// Middlewares run in the order they are registered, before the
+// preprocessors of the action. They can be registered before or after the
+// actions are mapped. It is intended for programs embedding the server to
+// add cross-cutting logic, such as custom authentication schemes, without
+// modifying the handlers.
+func (r *Router) Use(pattern string, middlewares ...Middleware) {
+	r.actions.Lock()
+	defer r.actions.Unlock()
+	for _, m := range middlewares {
+		r.middlewares = append(r.middlewares, middlewareEntry{pattern, m})
+	}
+}
+
+// matchMiddlewares returns the middlewares wrapping the action. It should
+// be called with the lock of actions held.
+func (r *Router) matchMiddlewares(action string) []Middleware {
+	var middlewares []Middleware
+	for _, entry := range r.middlewares {
+		if entry.matchAction(action) {
+			middlewares = append(middlewares, entry.middleware)
+		}
+	}
+	return middlewares
+}
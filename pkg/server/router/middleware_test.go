@@ -0,0 +1,106 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouterMiddlewares(t *testing.T) {
+	Convey("Given a router with middlewares", t, func() {
+		r := NewRouter()
+		calls := []string{}
+		r.Map("record:fetch", &CallbackHandler{
+			callback: func(p *Payload, resp *Response) {
+				calls = append(calls, "handler")
+				resp.Result = p.Data["tenant"]
+			},
+		})
+		r.Map("asset:put", &MockHandler{outputs: Response{
+			Result: "ok",
+		}})
+
+		post := func(action string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(
+				"POST",
+				"http://skygear.dev/",
+				strings.NewReader(`{"action": "`+action+`"}`),
+			)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			return resp
+		}
+
+		Convey("runs middlewares in order around the handler", func() {
+			r.Use("record:*", func(p *Payload, resp *Response, next func() int) int {
+				calls = append(calls, "outer")
+				p.Data["tenant"] = "acme"
+				status := next()
+				calls = append(calls, "outer done")
+				return status
+			})
+			r.Use("*", func(p *Payload, resp *Response, next func() int) int {
+				calls = append(calls, "inner")
+				return next()
+			})
+
+			resp := post("record:fetch")
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{"result": "acme"}`)
+			So(calls, ShouldResemble, []string{"outer", "inner", "handler", "outer done"})
+		})
+
+		Convey("does not run middlewares of other actions", func() {
+			r.Use("record:fetch", func(p *Payload, resp *Response, next func() int) int {
+				calls = append(calls, "middleware")
+				return next()
+			})
+
+			resp := post("asset:put")
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(calls, ShouldBeEmpty)
+		})
+
+		Convey("rejects request without calling next", func() {
+			r.Use("record:fetch", func(p *Payload, resp *Response, next func() int) int {
+				resp.Err = skyerr.NewError(skyerr.NotAuthenticated, "unknown scheme")
+				return http.StatusUnauthorized
+			})
+
+			resp := post("record:fetch")
+			So(resp.Code, ShouldEqual, http.StatusUnauthorized)
+			So(calls, ShouldBeEmpty)
+		})
+
+		Convey("runs processor as middleware", func() {
+			r.Use("record:fetch", ProcessorMiddleware(&getPreprocessor{
+				Status: http.StatusTooManyRequests,
+				Err:    skyerr.NewError(skyerr.RateLimitExceeded, "Too many requests."),
+			}))
+
+			resp := post("record:fetch")
+			So(resp.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(calls, ShouldBeEmpty)
+		})
+	})
+}
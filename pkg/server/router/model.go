@@ -28,6 +28,17 @@ type ContextKey string
 
 var UserIDContextKey ContextKey = "UserID"
 var AccessKeyTypeContextKey ContextKey = "AccessKeyType"
+var RequestIDContextKey ContextKey = "RequestID"
+
+// RequestIDFromContext returns the ID of the request being handled
+// with the context. Returns an empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(RequestIDContextKey).(string)
+	return requestID
+}
 
 // HandlerFunc specifies the function signature of a request handler function
 type HandlerFunc func(*Payload, *Response)
@@ -109,6 +120,11 @@ type Payload struct {
 	AuthInfo   *skydb.AuthInfo
 	AccessKey  AccessKeyType
 
+	// Tenant is the name of the tenant of the request in multi-tenant
+	// mode, and is empty otherwise. AppName is the tenant name if it is
+	// not empty.
+	Tenant string
+
 	// AccessToken stores access token for this payload.
 	//
 	// The field is injected by preprocessor. The field
@@ -182,3 +198,13 @@ func (resp *Response) Writer() (writer http.ResponseWriter) {
 	})
 	return
 }
+
+// Header returns the header map to be written in the HTTP response.
+//
+// The header map is stored in Meta.
+func (resp *Response) Header() http.Header {
+	if resp.Meta == nil {
+		resp.Meta = map[string][]string{}
+	}
+	return http.Header(resp.Meta)
+}
@@ -44,6 +44,7 @@ type Router struct {
 		sync.RWMutex
 		m map[string]pipeline
 	}
+	middlewares []middlewareEntry
 }
 
 // NewRouter is factory for Router
@@ -79,7 +80,7 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.commonRouter.ServeHTTP(w, req)
 }
 
-func (r *Router) matchHandler(p *Payload) (h Handler, pp []Processor) {
+func (r *Router) matchHandler(p *Payload) (h Handler, pp []Processor, mm []Middleware) {
 	r.actions.RLock()
 	defer r.actions.RUnlock()
 
@@ -94,6 +95,7 @@ func (r *Router) matchHandler(p *Payload) (h Handler, pp []Processor) {
 		if pipeline, ok := r.actions.m[action]; ok {
 			h = pipeline.Handler
 			pp = pipeline.Preprocessors
+			mm = r.matchMiddlewares(pipeline.Action)
 		}
 	}
 
@@ -102,6 +104,7 @@ func (r *Router) matchHandler(p *Payload) (h Handler, pp []Processor) {
 		if pipeline, ok := r.actions.m[p.RouteAction()]; ok {
 			h = pipeline.Handler
 			pp = pipeline.Preprocessors
+			mm = r.matchMiddlewares(pipeline.Action)
 		}
 	}
 
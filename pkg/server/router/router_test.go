@@ -15,6 +15,7 @@
 package router
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -25,6 +26,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 	. "github.com/skygeario/skygear-server/pkg/server/skytest"
 	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/trace"
 )
 
 type MockHandler struct {
@@ -82,6 +84,50 @@ func (h *ErrHandler) Handle(p *Payload, r *Response) {
 	r.Err = h.Err
 }
 
+type HeaderHandler struct {
+	Key   string
+	Value string
+}
+
+func (h *HeaderHandler) Setup() {
+	return
+}
+
+func (h *HeaderHandler) GetPreprocessors() []Processor {
+	return nil
+}
+
+func (h *HeaderHandler) Handle(p *Payload, r *Response) {
+	r.Header().Set(h.Key, h.Value)
+}
+
+func TestResponseHeader(t *testing.T) {
+	Convey("Router", t, func() {
+		r := NewRouter()
+
+		Convey("writes headers set by handler", func() {
+			r.Map("mock:handler", &HeaderHandler{
+				Key:   "Cache-Control",
+				Value: "public, max-age=60",
+			})
+
+			req, _ := http.NewRequest(
+				"POST",
+				"http://skygear.dev/",
+				strings.NewReader(`{"action": "mock:handler"}`),
+			)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp := httptest.NewRecorder()
+
+			r.ServeHTTP(resp, req)
+
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(resp.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=60")
+		})
+	})
+}
+
 func TestRouterMap(t *testing.T) {
 	mockResp := Response{}
 	type exampleResp struct {
@@ -293,6 +339,32 @@ func TestPreprocess(t *testing.T) {
 	})
 }
 
+func TestRouterPreprocessors(t *testing.T) {
+	Convey("Given a router with preprocessors for every request", t, func() {
+		r := NewRouter()
+		r.Map("mock:handler", &MockHandler{outputs: Response{
+			Result: "ok",
+		}})
+		r.Preprocessors = []Processor{&getPreprocessor{
+			Status: http.StatusTooManyRequests,
+			Err:    skyerr.NewError(skyerr.RateLimitExceeded, "Too many requests."),
+		}}
+
+		req, _ := http.NewRequest(
+			"POST",
+			"http://skygear.dev/",
+			strings.NewReader(`{"action": "mock:handler"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		r.ServeHTTP(resp, req)
+
+		So(resp.Code, ShouldEqual, http.StatusTooManyRequests)
+		So(resp.Body.Bytes(), ShouldEqualJSON, `{"error":{"name":"RateLimitExceeded","code":125,"message":"Too many requests."}}`)
+	})
+}
+
 func TestPreprocessorRegistry(t *testing.T) {
 	mockPreprocessor := &getPreprocessor{}
 
@@ -367,3 +439,56 @@ func TestRouterRequest(t *testing.T) {
 		})
 	})
 }
+
+func TestRequestContext(t *testing.T) {
+	Convey("Router", t, func() {
+		var ctx context.Context
+		callbackHandler := CallbackHandler{
+			callback: func(p *Payload, r *Response) {
+				ctx = p.Context
+			},
+		}
+
+		r := NewRouter()
+		r.Map("mock:callback", &callbackHandler)
+
+		req, _ := http.NewRequest(
+			"POST",
+			"http://skygear.dev/mock/callback",
+			strings.NewReader(""),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		Convey("generates request ID", func() {
+			r.ServeHTTP(resp, req)
+
+			requestID := RequestIDFromContext(ctx)
+			So(requestID, ShouldNotBeEmpty)
+			So(resp.Header().Get("X-Request-Id"), ShouldEqual, requestID)
+		})
+
+		Convey("uses request ID from header", func() {
+			req.Header.Set("X-Request-Id", "request-1")
+			r.ServeHTTP(resp, req)
+
+			So(RequestIDFromContext(ctx), ShouldEqual, "request-1")
+			So(resp.Header().Get("X-Request-Id"), ShouldEqual, "request-1")
+		})
+
+		Convey("traces request", func() {
+			r.ServeHTTP(resp, req)
+
+			_, ok := trace.FromContext(ctx)
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("sets deadline of response timeout", func() {
+			r.ResponseTimeout = time.Minute
+			r.ServeHTTP(resp, req)
+
+			_, ok := ctx.Deadline()
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,158 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// FieldType is the JSON type of a field in the request payload.
+type FieldType int
+
+// The JSON types of a field.
+const (
+	AnyField FieldType = iota
+	StringField
+	NumberField
+	BooleanField
+	ObjectField
+	ArrayField
+)
+
+func (t FieldType) String() string {
+	switch t {
+	case StringField:
+		return "a string"
+	case NumberField:
+		return "a number"
+	case BooleanField:
+		return "a boolean"
+	case ObjectField:
+		return "an object"
+	case ArrayField:
+		return "an array"
+	}
+	return "any value"
+}
+
+// Match returns true if the decoded JSON value is of the type.
+func (t FieldType) Match(value interface{}) bool {
+	switch value.(type) {
+	case string:
+		return t == AnyField || t == StringField
+	case float64:
+		return t == AnyField || t == NumberField
+	case bool:
+		return t == AnyField || t == BooleanField
+	case map[string]interface{}:
+		return t == AnyField || t == ObjectField
+	case []interface{}:
+		return t == AnyField || t == ArrayField
+	}
+	return t == AnyField
+}
+
+// Field declares a field in the request payload. A field with null value
+// is treated as missing.
+type Field struct {
+	Name     string
+	Type     FieldType
+	Required bool
+
+	// Elem is the type of the elements of an array field.
+	Elem FieldType
+
+	// Enum is the allowed values of a string field. Any value is
+	// allowed if Enum is empty.
+	Enum []string
+}
+
+func (f Field) validate(value interface{}) string {
+	if value == nil {
+		if f.Required {
+			return fmt.Sprintf("%s is required", f.Name)
+		}
+		return ""
+	}
+
+	if !f.Type.Match(value) {
+		return fmt.Sprintf("%s must be %s", f.Name, f.Type)
+	}
+
+	if elems, ok := value.([]interface{}); ok {
+		for _, elem := range elems {
+			if !f.Elem.Match(elem) {
+				return fmt.Sprintf("%s must be an array of which elements are %s", f.Name, f.Elem)
+			}
+		}
+	}
+
+	if s, ok := value.(string); ok && len(f.Enum) > 0 {
+		for _, allowed := range f.Enum {
+			if s == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s must be one of %s", f.Name, strings.Join(f.Enum, ", "))
+	}
+
+	return ""
+}
+
+// PayloadSchema declares the fields of the request payload of a handler.
+// Fields not declared in the schema are not validated.
+type PayloadSchema []Field
+
+// Validate validates the data of the request payload against the schema.
+//
+// All invalid fields are reported in a single InvalidArgument error. The
+// info of the error contains the names of invalid fields in "arguments"
+// and the message of each invalid field in "errors".
+func (schema PayloadSchema) Validate(data map[string]interface{}) skyerr.Error {
+	arguments := []string{}
+	messages := []string{}
+	errors := map[string]interface{}{}
+	for _, field := range schema {
+		if message := field.validate(data[field.Name]); message != "" {
+			arguments = append(arguments, field.Name)
+			messages = append(messages, message)
+			errors[field.Name] = message
+		}
+	}
+
+	if len(arguments) == 0 {
+		return nil
+	}
+	return skyerr.NewErrorWithInfo(
+		skyerr.InvalidArgument,
+		strings.Join(messages, "; "),
+		map[string]interface{}{
+			"arguments": arguments,
+			"errors":    errors,
+		},
+	)
+}
+
+// SchemaHandler is a Handler declaring the schema of its request payload.
+//
+// The payload is validated after the preprocessors of the handler are run,
+// and the handler is not called if the payload is invalid.
+type SchemaHandler interface {
+	Handler
+	PayloadSchema() PayloadSchema
+}
@@ -0,0 +1,119 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type schemaHandler struct {
+	CallbackHandler
+}
+
+func (h *schemaHandler) PayloadSchema() PayloadSchema {
+	return PayloadSchema{
+		{Name: "record_type", Type: StringField, Required: true},
+		{Name: "ids", Type: ArrayField, Elem: StringField},
+		{Name: "order", Type: StringField, Enum: []string{"asc", "desc"}},
+		{Name: "count", Type: BooleanField},
+	}
+}
+
+func TestPayloadSchema(t *testing.T) {
+	Convey("PayloadSchema", t, func() {
+		schema := (&schemaHandler{}).PayloadSchema()
+
+		Convey("accepts valid data", func() {
+			So(schema.Validate(map[string]interface{}{
+				"record_type": "note",
+				"ids":         []interface{}{"note/1"},
+				"order":       "asc",
+				"count":       nil,
+				"unknown":     float64(1),
+			}), ShouldBeNil)
+		})
+
+		Convey("reports all invalid fields", func() {
+			err := schema.Validate(map[string]interface{}{
+				"ids":   []interface{}{"note/1", float64(2)},
+				"order": "random",
+				"count": "true",
+			})
+			So(err.Code(), ShouldEqual, skyerr.InvalidArgument)
+			So(err.Message(), ShouldEqual, "record_type is required; "+
+				"ids must be an array of which elements are a string; "+
+				"order must be one of asc, desc; "+
+				"count must be a boolean")
+			So(err.Info(), ShouldResemble, map[string]interface{}{
+				"arguments": []string{"record_type", "ids", "order", "count"},
+				"errors": map[string]interface{}{
+					"record_type": "record_type is required",
+					"ids":         "ids must be an array of which elements are a string",
+					"order":       "order must be one of asc, desc",
+					"count":       "count must be a boolean",
+				},
+			})
+		})
+	})
+
+	Convey("Router with schema handler", t, func() {
+		called := false
+		r := NewRouter()
+		r.Map("record:query", &schemaHandler{CallbackHandler{
+			callback: func(p *Payload, resp *Response) {
+				called = true
+				resp.Result = "ok"
+			},
+		}})
+
+		post := func(body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("POST", "http://skygear.dev/", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			return resp
+		}
+
+		Convey("calls handler with valid payload", func() {
+			resp := post(`{"action": "record:query", "record_type": "note"}`)
+			So(resp.Code, ShouldEqual, http.StatusOK)
+			So(called, ShouldBeTrue)
+		})
+
+		Convey("rejects invalid payload without calling handler", func() {
+			resp := post(`{"action": "record:query", "record_type": 1}`)
+			So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			So(resp.Body.Bytes(), ShouldEqualJSON, `{
+				"error": {
+					"name": "InvalidArgument",
+					"code": 108,
+					"message": "record_type must be a string",
+					"info": {
+						"arguments": ["record_type"],
+						"errors": {"record_type": "record_type must be a string"}
+					}
+				}
+			}`)
+			So(called, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,307 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Runner runs scenarios against the handler of a server.
+type Runner struct {
+	Handler   http.Handler
+	APIKey    string
+	MasterKey string
+
+	// Hooks is the hook registry of the server, which is observed for
+	// the hooks fired by the steps. Expected hooks fail if it is nil.
+	Hooks *hook.Registry
+
+	mutex sync.Mutex
+	fired []FiredHook
+}
+
+// Failure is a step of a scenario not having the expected outcome.
+type Failure struct {
+	Step    string
+	Message string
+}
+
+func (f Failure) Error() string {
+	return fmt.Sprintf("%s: %s", f.Step, f.Message)
+}
+
+type response struct {
+	Status int
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+}
+
+// Run runs the scenario and returns the failures of its steps. An error
+// is returned if the users cannot be signed in or the seed records
+// cannot be saved.
+func (r *Runner) Run(scenario Scenario) ([]Failure, error) {
+	if r.Hooks != nil {
+		r.Hooks.SetObserver(r.observeHook)
+		defer r.Hooks.SetObserver(nil)
+	}
+
+	tokens, err := r.signIn(scenario.Users)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(scenario.Seed) > 0 {
+		resp, err := r.request(map[string]interface{}{
+			"action":  "record:save",
+			"api_key": r.MasterKey,
+			"records": scenario.Seed,
+			"atomic":  true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("failed to save seed records: %v", resp.Error)
+		}
+	}
+
+	failures := []Failure{}
+	for i, step := range scenario.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d (%s)", i+1, step.Action)
+		}
+
+		data := map[string]interface{}{}
+		for key, value := range step.Payload {
+			data[key] = value
+		}
+		data["action"] = step.Action
+		switch step.As {
+		case MasterUser:
+			data["api_key"] = r.MasterKey
+		case "":
+			data["api_key"] = r.APIKey
+		default:
+			data["api_key"] = r.APIKey
+			data["access_token"] = tokens[step.As]
+		}
+
+		r.resetFiredHooks()
+		resp, err := r.request(data)
+		if err != nil {
+			failures = append(failures, Failure{name, err.Error()})
+			continue
+		}
+		for _, message := range r.check(step.Expect, resp) {
+			failures = append(failures, Failure{name, message})
+		}
+	}
+	return failures, nil
+}
+
+// signIn logs in the users, or signs them up if they cannot log in, and
+// returns their access tokens by name.
+func (r *Runner) signIn(users map[string]User) (map[string]string, error) {
+	tokens := map[string]string{}
+	for name, user := range users {
+		var resp *response
+		for _, action := range []string{"auth:login", "auth:signup"} {
+			var err error
+			resp, err = r.request(map[string]interface{}{
+				"action":    action,
+				"api_key":   r.APIKey,
+				"auth_data": user.AuthData,
+				"password":  user.Password,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if resp.Error == nil {
+				break
+			}
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf(`failed to sign in user "%s": %v`, name, resp.Error)
+		}
+
+		result, _ := resp.Result.(map[string]interface{})
+		token, _ := result["access_token"].(string)
+		if token == "" {
+			return nil, fmt.Errorf(`failed to sign in user "%s": no access token`, name)
+		}
+		tokens[name] = token
+	}
+	return tokens, nil
+}
+
+func (r *Runner) request(data map[string]interface{}) (*response, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	r.Handler.ServeHTTP(recorder, req)
+
+	resp := response{Status: recorder.Code}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response with status %d: %s", recorder.Code, err)
+	}
+	return &resp, nil
+}
+
+func (r *Runner) check(expect Expectation, resp *response) []string {
+	messages := []string{}
+	if expect.Status != 0 && expect.Status != resp.Status {
+		messages = append(messages, fmt.Sprintf("expected status %d, got %d", expect.Status, resp.Status))
+	}
+
+	if expect.Error == nil {
+		if resp.Error != nil {
+			messages = append(messages, fmt.Sprintf("unexpected error %s", encode(resp.Error)))
+		}
+	} else if resp.Error == nil {
+		messages = append(messages, fmt.Sprintf("expected error %s, got none", encode(expect.Error)))
+	} else if message := match("error", expect.Error, resp.Error); message != "" {
+		messages = append(messages, message)
+	}
+
+	if expect.Result != nil {
+		if message := match("result", expect.Result, resp.Result); message != "" {
+			messages = append(messages, message)
+		}
+	}
+
+	fired := r.firedHooks()
+	for _, expected := range expect.Hooks {
+		if !containsHook(fired, expected) {
+			messages = append(messages, fmt.Sprintf("expected hook %s to be fired, fired %s", expected, hooksString(fired)))
+		}
+	}
+	return messages
+}
+
+func (r *Runner) observeHook(kind hook.Kind, record *skydb.Record) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.fired = append(r.fired, FiredHook{string(kind), record.ID.Type})
+}
+
+func (r *Runner) resetFiredHooks() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.fired = nil
+}
+
+func (r *Runner) firedHooks() []FiredHook {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]FiredHook{}, r.fired...)
+}
+
+func containsHook(hooks []FiredHook, h FiredHook) bool {
+	for _, fired := range hooks {
+		if fired == h {
+			return true
+		}
+	}
+	return false
+}
+
+func hooksString(hooks []FiredHook) string {
+	if len(hooks) == 0 {
+		return "none"
+	}
+	names := make([]string, len(hooks))
+	for i, h := range hooks {
+		names[i] = h.String()
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// match returns a message describing the first difference between the
+// expected and actual values, or an empty string if they match. Objects
+// match if every key of the expected object matches that of the actual
+// one.
+func match(path string, expected interface{}, actual interface{}) string {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actualMap, ok := actual.(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("%s: expected an object, got %s", path, encode(actual))
+		}
+		keys := make([]string, 0, len(expected))
+		for key := range expected {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			actualValue, ok := actualMap[key]
+			if !ok {
+				return fmt.Sprintf("%s.%s: expected %s, got nothing", path, key, encode(expected[key]))
+			}
+			if message := match(path+"."+key, expected[key], actualValue); message != "" {
+				return message
+			}
+		}
+		return ""
+	case []interface{}:
+		actualSlice, ok := actual.([]interface{})
+		if !ok {
+			return fmt.Sprintf("%s: expected an array, got %s", path, encode(actual))
+		}
+		if len(expected) != len(actualSlice) {
+			return fmt.Sprintf("%s: expected %d items, got %d", path, len(expected), len(actualSlice))
+		}
+		for i := range expected {
+			if message := match(fmt.Sprintf("%s[%d]", path, i), expected[i], actualSlice[i]); message != "" {
+				return message
+			}
+		}
+		return ""
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return fmt.Sprintf("%s: expected %s, got %s", path, encode(expected), encode(actual))
+	}
+	return ""
+}
+
+func encode(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
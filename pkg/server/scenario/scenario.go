@@ -0,0 +1,138 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scenario runs declarative scenario files against the server,
+// so that plugins can be tested end to end without bespoke scripts.
+//
+// A scenario signs in its users, saves the seed records with the master
+// key, then issues the requests of its steps in order and asserts their
+// responses and the hooks they fired.
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// MasterUser is the name of the user of a step issued with the master
+// key.
+const MasterUser = "master"
+
+// Scenario is a sequence of requests and the expected outcomes.
+type Scenario struct {
+	Name string `json:"name"`
+
+	// Users are the users signed in before the steps, by the name which
+	// steps refer to them. Users are signed up if they cannot log in.
+	Users map[string]User `json:"users"`
+
+	// Seed are the records saved with the master key before the steps.
+	Seed []map[string]interface{} `json:"seed"`
+
+	Steps []Step `json:"steps"`
+}
+
+// User is the credentials of a user of a scenario.
+type User struct {
+	AuthData map[string]interface{} `json:"auth_data"`
+	Password string                 `json:"password"`
+}
+
+// Step is a request issued by a scenario.
+type Step struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+
+	// As is the name of the user issuing the request, or MasterUser to
+	// issue the request with the master key. The request is issued with
+	// the API key only if it is empty.
+	As string `json:"as"`
+
+	Payload map[string]interface{} `json:"payload"`
+	Expect  Expectation            `json:"expect"`
+}
+
+// Expectation is the expected outcome of a step. The response is
+// expected to succeed unless Error is specified.
+type Expectation struct {
+	// Status is the expected HTTP status code if it is not zero.
+	Status int `json:"status"`
+
+	// Result and Error match the response if every key of the objects
+	// in them equals that in the response. Keys in the response not in
+	// them are ignored.
+	Result interface{} `json:"result"`
+	Error  interface{} `json:"error"`
+
+	// Hooks are the hooks expected to be fired by the request.
+	Hooks []FiredHook `json:"hooks"`
+}
+
+// FiredHook is a hook fired for a record.
+type FiredHook struct {
+	Kind       string `json:"kind"`
+	RecordType string `json:"record_type"`
+}
+
+func (h FiredHook) String() string {
+	return fmt.Sprintf("%s:%s", h.Kind, h.RecordType)
+}
+
+// Parse parses a scenario file.
+func Parse(data []byte) (Scenario, error) {
+	scenario := Scenario{}
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return Scenario{}, err
+	}
+	if err := scenario.Validate(); err != nil {
+		return Scenario{}, err
+	}
+	return scenario, nil
+}
+
+// ReadFile reads and parses the scenario file at the path. The name of
+// the scenario defaults to the path.
+func ReadFile(path string) (Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	scenario, err := Parse(data)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("%s: %s", path, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = path
+	}
+	return scenario, nil
+}
+
+// Validate returns an error if a step has no action or refers to an
+// unknown user.
+func (s Scenario) Validate() error {
+	if _, ok := s.Users[MasterUser]; ok {
+		return fmt.Errorf(`user cannot be named "%s"`, MasterUser)
+	}
+	for i, step := range s.Steps {
+		if step.Action == "" {
+			return fmt.Errorf("step %d: action is required", i+1)
+		}
+		if _, ok := s.Users[step.As]; !ok && step.As != "" && step.As != MasterUser {
+			return fmt.Errorf(`step %d: unknown user "%s"`, i+1, step.As)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestParse(t *testing.T) {
+	Convey("Parse", t, func() {
+		Convey("parses scenario", func() {
+			scenario, err := Parse([]byte(`{
+				"name": "save note",
+				"users": {"alice": {"auth_data": {"username": "alice"}, "password": "secret"}},
+				"steps": [{
+					"action": "record:save",
+					"as": "alice",
+					"payload": {"records": [{"_id": "note/1"}]},
+					"expect": {"hooks": [{"kind": "afterSave", "record_type": "note"}]}
+				}]
+			}`))
+			So(err, ShouldBeNil)
+			So(scenario.Name, ShouldEqual, "save note")
+			So(scenario.Users["alice"].Password, ShouldEqual, "secret")
+			So(scenario.Steps[0].Expect.Hooks, ShouldResemble, []FiredHook{{"afterSave", "note"}})
+		})
+
+		Convey("rejects step without action", func() {
+			_, err := Parse([]byte(`{"steps": [{"as": "master"}]}`))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rejects step of unknown user", func() {
+			_, err := Parse([]byte(`{"steps": [{"action": "me", "as": "bob"}]}`))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMatch(t *testing.T) {
+	Convey("match", t, func() {
+		actual := map[string]interface{}{
+			"_id":   "note/1",
+			"title": "hello",
+			"tags":  []interface{}{"a", "b"},
+		}
+
+		So(match("result", map[string]interface{}{"title": "hello"}, actual), ShouldEqual, "")
+		So(match("result", map[string]interface{}{"title": "bye"}, actual),
+			ShouldEqual, `result.title: expected "bye", got "hello"`)
+		So(match("result", map[string]interface{}{"content": "hi"}, actual),
+			ShouldEqual, `result.content: expected "hi", got nothing`)
+		So(match("result", map[string]interface{}{"tags": []interface{}{"a"}}, actual),
+			ShouldEqual, "result.tags: expected 2 items, got 1")
+	})
+}
+
+// fakeServer responds to login and record:save, firing the afterSave
+// hook of the saved records.
+type fakeServer struct {
+	hooks    *hook.Registry
+	requests []map[string]interface{}
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data := map[string]interface{}{}
+	json.NewDecoder(req.Body).Decode(&data)
+	s.requests = append(s.requests, data)
+
+	var result interface{}
+	switch data["action"] {
+	case "auth:login":
+		result = map[string]interface{}{"access_token": "alice-token"}
+	case "record:save":
+		if data["api_key"] != "master-key" && data["access_token"] == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"name": "NotAuthenticated"},
+			})
+			return
+		}
+		records := data["records"].([]interface{})
+		for _, r := range records {
+			recordID := skydb.RecordID{}
+			recordID.UnmarshalText([]byte(r.(map[string]interface{})["_id"].(string)))
+			s.hooks.ExecuteHooks(context.Background(), hook.AfterSave, &skydb.Record{ID: recordID}, nil)
+		}
+		result = records
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+}
+
+func TestRunner(t *testing.T) {
+	Convey("Runner", t, func() {
+		hooks := hook.NewRegistry()
+		hooks.Register(hook.AfterSave, "note", func(context.Context, *skydb.Record, *skydb.Record) skyerr.Error {
+			return nil
+		})
+		server := &fakeServer{hooks: hooks}
+		runner := &Runner{
+			Handler:   server,
+			APIKey:    "api-key",
+			MasterKey: "master-key",
+			Hooks:     hooks,
+		}
+
+		scenario := Scenario{
+			Users: map[string]User{
+				"alice": {AuthData: map[string]interface{}{"username": "alice"}, Password: "secret"},
+			},
+			Seed: []map[string]interface{}{{"_id": "note/seed"}},
+		}
+
+		Convey("passes steps with expected outcome", func() {
+			scenario.Steps = []Step{{
+				Action:  "record:save",
+				As:      "alice",
+				Payload: map[string]interface{}{"records": []interface{}{map[string]interface{}{"_id": "note/1"}}},
+				Expect: Expectation{
+					Status: http.StatusOK,
+					Result: []interface{}{map[string]interface{}{"_id": "note/1"}},
+					Hooks:  []FiredHook{{"afterSave", "note"}},
+				},
+			}}
+
+			failures, err := runner.Run(scenario)
+			So(err, ShouldBeNil)
+			So(failures, ShouldBeEmpty)
+			So(server.requests[1]["api_key"], ShouldEqual, "master-key")
+			So(server.requests[2]["access_token"], ShouldEqual, "alice-token")
+		})
+
+		Convey("reports steps without expected outcome", func() {
+			scenario.Steps = []Step{{
+				Name:    "anonymous save",
+				Action:  "record:save",
+				Payload: map[string]interface{}{"records": []interface{}{map[string]interface{}{"_id": "note/1"}}},
+				Expect: Expectation{
+					Hooks: []FiredHook{{"afterSave", "note"}},
+				},
+			}}
+
+			failures, err := runner.Run(scenario)
+			So(err, ShouldBeNil)
+			So(failures, ShouldResemble, []Failure{
+				{"anonymous save", `unexpected error {"name":"NotAuthenticated"}`},
+				{"anonymous save", "expected hook afterSave:note to be fired, fired none"},
+			})
+		})
+	})
+}
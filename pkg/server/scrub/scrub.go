@@ -0,0 +1,186 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scrub rewrites the personal data in records with fake or hashed
+// values, so that a copy of the production database can be used to
+// refresh a staging environment without exposing user data.
+//
+// The fields holding personal data are classified by rules. Values are
+// rewritten deterministically for the same salt, so that equal values,
+// such as the same email address in different record types, are still
+// equal after scrubbing.
+//
+// Besides the classified fields, the auth data of users, which are the
+// auth record keys of the user records, are always scrubbed, as well as
+// the personal data kept in the system tables: the OAuth profiles of
+// users, the classified fields in the record history, and annotations.
+package scrub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Rules maps each record type to its fields holding personal data, and
+// how each of the fields is scrubbed.
+type Rules struct {
+	Salt        string                                  `json:"salt"`
+	RecordTypes map[string]map[string]skydb.ScrubMethod `json:"record_types"`
+}
+
+// Validate checks that the record types and fields of the rules exist in
+// the schema of db, and that the scrub methods apply to the fields.
+func (rules *Rules) Validate(db skydb.Database) error {
+	schemas, err := db.GetRecordSchemas()
+	if err != nil {
+		return err
+	}
+
+	for _, recordType := range rules.sortedRecordTypes() {
+		schema, ok := schemas[recordType]
+		if !ok {
+			return fmt.Errorf("record type %s does not exist", recordType)
+		}
+
+		for field, method := range rules.RecordTypes[recordType] {
+			if strings.HasPrefix(field, "_") {
+				return fmt.Errorf("cannot scrub reserved field %s.%s", recordType, field)
+			}
+			if !method.IsValid() {
+				return fmt.Errorf("unknown scrub method %s for %s.%s", method, recordType, field)
+			}
+			fieldType, ok := schema[field]
+			if !ok {
+				return fmt.Errorf("field %s.%s does not exist", recordType, field)
+			}
+			if method.RequiresString() {
+				if fieldType.Type != skydb.TypeString {
+					return fmt.Errorf("scrub method %s only applies to string field, but %s.%s is %s",
+						method, recordType, field, fieldType.ToSimpleName())
+				}
+				if rules.Salt == "" {
+					return fmt.Errorf("salt is required for scrub method %s", method)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// withAuthData returns a copy of the rules which also scrubs the auth
+// record keys of the user records not classified by the rules. Fields
+// named email are replaced with fake email addresses, other string
+// fields are hashed, and fields of other types are removed.
+func (rules *Rules) withAuthData(db skydb.Database, authRecordKeys [][]string) (*Rules, error) {
+	schemas, err := db.GetRecordSchemas()
+	if err != nil {
+		return nil, err
+	}
+	schema, ok := schemas[db.UserRecordType()]
+	if !ok {
+		return rules, nil
+	}
+
+	recordTypes := map[string]map[string]skydb.ScrubMethod{}
+	for recordType, fields := range rules.RecordTypes {
+		recordTypes[recordType] = fields
+	}
+	userFields := map[string]skydb.ScrubMethod{}
+	for field, method := range recordTypes[db.UserRecordType()] {
+		userFields[field] = method
+	}
+
+	for _, keys := range authRecordKeys {
+		for _, key := range keys {
+			fieldType, ok := schema[key]
+			if _, classified := userFields[key]; !ok || classified {
+				continue
+			}
+			switch {
+			case fieldType.Type != skydb.TypeString:
+				userFields[key] = skydb.ScrubNull
+			case key == "email":
+				userFields[key] = skydb.ScrubEmail
+			default:
+				userFields[key] = skydb.ScrubHash
+			}
+		}
+	}
+	if len(userFields) > 0 {
+		recordTypes[db.UserRecordType()] = userFields
+	}
+
+	return &Rules{
+		Salt:        rules.Salt,
+		RecordTypes: recordTypes,
+	}, nil
+}
+
+func (rules *Rules) sortedRecordTypes() []string {
+	recordTypes := make([]string, 0, len(rules.RecordTypes))
+	for recordType := range rules.RecordTypes {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+	return recordTypes
+}
+
+// Scrub validates the rules and scrubs the records of all databases of
+// conn, including the auth record keys of the user records, and the
+// system tables in a single transaction. It returns the number of
+// records rewritten of each record type, and of rows of each system
+// table.
+func Scrub(conn skydb.Conn, rules *Rules, authRecordKeys [][]string) (map[string]int64, error) {
+	db := conn.PublicDB()
+	rules, err := rules.withAuthData(db, authRecordKeys)
+	if err != nil {
+		return nil, err
+	}
+	if err := rules.Validate(db); err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{}
+	scrub := func() error {
+		for _, recordType := range rules.sortedRecordTypes() {
+			count, err := conn.ScrubRecords(recordType, rules.RecordTypes[recordType], rules.Salt)
+			if err != nil {
+				return fmt.Errorf("failed to scrub %s: %v", recordType, err)
+			}
+			counts[recordType] = count
+		}
+
+		systemCounts, err := conn.ScrubSystemTables(rules.RecordTypes, rules.Salt)
+		if err != nil {
+			return fmt.Errorf("failed to scrub system tables: %v", err)
+		}
+		for table, count := range systemCounts {
+			counts[table] = count
+		}
+		return nil
+	}
+
+	if txDB, ok := db.(skydb.Transactional); ok {
+		err = skydb.WithTransaction(txDB, scrub)
+	} else {
+		err = scrub()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
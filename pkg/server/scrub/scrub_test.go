@@ -0,0 +1,159 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrub
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+)
+
+type scrubConn struct {
+	skydb.Conn
+	db       *skydbtest.MapDB
+	scrubbed map[string]map[string]skydb.ScrubMethod
+	history  map[string]map[string]skydb.ScrubMethod
+}
+
+func (conn *scrubConn) PublicDB() skydb.Database {
+	return conn.db
+}
+
+func (conn *scrubConn) ScrubRecords(recordType string, fields map[string]skydb.ScrubMethod, salt string) (int64, error) {
+	conn.scrubbed[recordType] = fields
+	return int64(len(fields)), nil
+}
+
+func (conn *scrubConn) ScrubSystemTables(recordTypes map[string]map[string]skydb.ScrubMethod, salt string) (map[string]int64, error) {
+	conn.history = recordTypes
+	return map[string]int64{"_auth": 1, "_history": 2, "_annotation": 3}, nil
+}
+
+func TestScrub(t *testing.T) {
+	Convey("Scrub", t, func() {
+		conn := &scrubConn{
+			db:       skydbtest.NewMapDB(),
+			scrubbed: map[string]map[string]skydb.ScrubMethod{},
+		}
+		conn.db.Extend("user", skydb.RecordSchema{
+			"email": skydb.FieldType{Type: skydb.TypeString},
+			"name":  skydb.FieldType{Type: skydb.TypeString},
+			"birth": skydb.FieldType{Type: skydb.TypeDateTime},
+		})
+		conn.db.Extend("order", skydb.RecordSchema{
+			"address": skydb.FieldType{Type: skydb.TypeString},
+		})
+
+		Convey("scrubs records of each record type", func() {
+			counts, err := Scrub(conn, &Rules{
+				Salt: "salt",
+				RecordTypes: map[string]map[string]skydb.ScrubMethod{
+					"user": {
+						"email": skydb.ScrubEmail,
+						"name":  skydb.ScrubName,
+						"birth": skydb.ScrubNull,
+					},
+					"order": {
+						"address": skydb.ScrubHash,
+					},
+				},
+			}, nil)
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, map[string]int64{
+				"user":        3,
+				"order":       1,
+				"_auth":       1,
+				"_history":    2,
+				"_annotation": 3,
+			})
+			So(conn.scrubbed["user"], ShouldResemble, map[string]skydb.ScrubMethod{
+				"email": skydb.ScrubEmail,
+				"name":  skydb.ScrubName,
+				"birth": skydb.ScrubNull,
+			})
+			So(conn.history, ShouldResemble, conn.scrubbed)
+		})
+
+		Convey("scrubs auth record keys not classified", func() {
+			conn.db.Extend("user", skydb.RecordSchema{
+				"username": skydb.FieldType{Type: skydb.TypeString},
+				"phone":    skydb.FieldType{Type: skydb.TypeString},
+				"code":     skydb.FieldType{Type: skydb.TypeNumber},
+			})
+			_, err := Scrub(conn, &Rules{
+				Salt: "salt",
+				RecordTypes: map[string]map[string]skydb.ScrubMethod{
+					"user": {"phone": skydb.ScrubNull},
+				},
+			}, [][]string{{"username"}, {"email"}, {"phone"}, {"code"}})
+			So(err, ShouldBeNil)
+			So(conn.scrubbed["user"], ShouldResemble, map[string]skydb.ScrubMethod{
+				"username": skydb.ScrubHash,
+				"email":    skydb.ScrubEmail,
+				"phone":    skydb.ScrubNull,
+				"code":     skydb.ScrubNull,
+			})
+		})
+
+		Convey("requires salt to scrub auth record keys", func() {
+			_, err := Scrub(conn, &Rules{}, [][]string{{"email"}})
+			So(err.Error(), ShouldEqual, "salt is required for scrub method email")
+			So(conn.scrubbed, ShouldBeEmpty)
+		})
+
+		Convey("rejects invalid rules", func() {
+			scrub := func(salt string, fields map[string]skydb.ScrubMethod) string {
+				_, err := Scrub(conn, &Rules{
+					Salt:        salt,
+					RecordTypes: map[string]map[string]skydb.ScrubMethod{"user": fields},
+				}, nil)
+				So(err, ShouldNotBeNil)
+				So(conn.scrubbed, ShouldBeEmpty)
+				return err.Error()
+			}
+
+			So(scrub("salt", map[string]skydb.ScrubMethod{"_owner_id": skydb.ScrubNull}),
+				ShouldEqual, "cannot scrub reserved field user._owner_id")
+			So(scrub("salt", map[string]skydb.ScrubMethod{"email": "shuffle"}),
+				ShouldEqual, "unknown scrub method shuffle for user.email")
+			So(scrub("salt", map[string]skydb.ScrubMethod{"phone": skydb.ScrubNull}),
+				ShouldEqual, "field user.phone does not exist")
+			So(scrub("salt", map[string]skydb.ScrubMethod{"birth": skydb.ScrubHash}),
+				ShouldEqual, "scrub method hash only applies to string field, but user.birth is datetime")
+			So(scrub("", map[string]skydb.ScrubMethod{"email": skydb.ScrubEmail}),
+				ShouldEqual, "salt is required for scrub method email")
+
+			_, err := Scrub(conn, &Rules{
+				RecordTypes: map[string]map[string]skydb.ScrubMethod{
+					"note": {"title": skydb.ScrubNull},
+				},
+			}, nil)
+			So(err.Error(), ShouldEqual, "record type note does not exist")
+		})
+
+		Convey("removes values without salt", func() {
+			_, err := Scrub(conn, &Rules{
+				RecordTypes: map[string]map[string]skydb.ScrubMethod{
+					"user": {"birth": skydb.ScrubNull},
+				},
+			}, nil)
+			So(err, ShouldBeNil)
+			So(conn.scrubbed, ShouldContainKey, "user")
+		})
+	})
+}
@@ -0,0 +1,161 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secret stores credentials of providers, such as the private key
+// of APNS or the client secret of an OAuth provider, encrypted at rest in
+// the database.
+//
+// Secrets are sealed with AES-GCM by a key in a Keyring. The key is
+// configured separately from the master key, which is shared with
+// clients and plugins. To rotate the key, add the new key as the primary
+// key and keep the old key in the keyring until all secrets are resealed
+// by Rotate.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// KeySize is the size of a key in bytes.
+const KeySize = 32
+
+// ErrUnknownKey is returned by Sealer.Open if the key sealing the secret
+// is not available.
+var ErrUnknownKey = errors.New("secret: unknown key")
+
+// ErrNoKey is returned by Sealer.Seal if no key is configured to seal
+// secrets.
+var ErrNoKey = errors.New("secret: no key to seal secrets")
+
+// LegacyKeyID is the ID of the key derived from the master key, which
+// sealed secrets before a separate key was required.
+const LegacyKeyID = "master"
+
+// Sealer encrypts and decrypts secrets. A Sealer backed by a key
+// management service can be used in place of Keyring.
+type Sealer interface {
+	// KeyID returns the ID of the key sealing new secrets.
+	KeyID() string
+
+	// Seal encrypts the value of the named secret.
+	Seal(name string, value []byte) ([]byte, error)
+
+	// Open decrypts the value of the named secret sealed by the
+	// specified key.
+	Open(name string, keyID string, sealed []byte) ([]byte, error)
+}
+
+// Keyring is a Sealer holding keys in memory.
+type Keyring struct {
+	primaryID string
+	aeads     map[string]cipher.AEAD
+}
+
+// NewKeyring returns a Keyring sealing secrets with the primary key and
+// opening secrets with any of the keys. If primaryID is empty, the
+// Keyring only opens secrets and Seal returns ErrNoKey.
+func NewKeyring(primaryID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primaryID]; primaryID != "" && !ok {
+		return nil, fmt.Errorf("secret: primary key %s is not in keyring", primaryID)
+	}
+
+	aeads := map[string]cipher.AEAD{}
+	for id, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("secret: key %s must be %d bytes", id, KeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads[id] = aead
+	}
+
+	return &Keyring{
+		primaryID: primaryID,
+		aeads:     aeads,
+	}, nil
+}
+
+// LegacyKey returns the key derived from the master key, which sealed
+// secrets before a separate key was required. It is not salted, so it
+// must only be used to open such secrets until Rotate reseals them.
+func LegacyKey(masterKey string) []byte {
+	sum := sha256.Sum256([]byte(masterKey))
+	return sum[:]
+}
+
+// ParseKeys parses keys in the format of `id1:base64key1,id2:base64key2`.
+func ParseKeys(s string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("secret: malformed key %q", pair)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("secret: malformed key %s: %v", parts[0], err)
+		}
+		keys[parts[0]] = key
+	}
+	return keys, nil
+}
+
+// KeyID implements Sealer.
+func (k *Keyring) KeyID() string {
+	return k.primaryID
+}
+
+// Seal implements Sealer. The name of the secret is authenticated, so
+// that a sealed value cannot be used as the value of another secret.
+func (k *Keyring) Seal(name string, value []byte) ([]byte, error) {
+	aead, ok := k.aeads[k.primaryID]
+	if !ok {
+		return nil, ErrNoKey
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, value, []byte(name)), nil
+}
+
+// Open implements Sealer.
+func (k *Keyring) Open(name string, keyID string, sealed []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("secret: sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(name))
+}
@@ -0,0 +1,160 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+var log = logging.LoggerEntry("secret")
+
+// Resolver keeps the values of the secrets used by the server and
+// plugins, and notifies listeners when they change, so that rotated
+// credentials take effect without restarting the server.
+type Resolver struct {
+	Sealer     Sealer
+	ConnOpener func() (skydb.Conn, error)
+
+	// Names are the names of the secrets to resolve.
+	Names []string
+
+	// Prefixes resolve every secret whose name starts with one of them,
+	// such as the client secrets of OAuth providers.
+	Prefixes []string
+
+	reloadMutex sync.Mutex
+	mutex       sync.RWMutex
+	values      map[string]string
+	listeners   []func(values map[string]string)
+}
+
+// Resolves returns whether the named secret is resolved.
+func (r *Resolver) Resolves(name string) bool {
+	for _, n := range r.Names {
+		if n == name {
+			return true
+		}
+	}
+	for _, prefix := range r.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the values of the resolved secrets by name.
+func (r *Resolver) Values() map[string]string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return copyValues(r.values)
+}
+
+// OnChange registers a listener called with the values of the resolved
+// secrets after any of them is set, deleted or changed by Reload.
+func (r *Resolver) OnChange(listener func(values map[string]string)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Reload loads the secrets from the database and calls the listeners if
+// any of them has changed. Secrets which cannot be opened, such as those
+// sealed by a removed key, are skipped with a warning.
+func (r *Resolver) Reload() error {
+	// Reloads are serialized so that listeners see changes in order.
+	r.reloadMutex.Lock()
+	defer r.reloadMutex.Unlock()
+
+	values, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	changed := !equalValues(r.values, values)
+	r.values = values
+	listeners := r.listeners
+	r.mutex.Unlock()
+
+	if changed {
+		for _, listener := range listeners {
+			listener(copyValues(values))
+		}
+	}
+	return nil
+}
+
+// Watch reloads the secrets periodically, so that secrets changed
+// through another server take effect. It does not return.
+func (r *Resolver) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := r.Reload(); err != nil {
+			log.Warnf("Failed to reload secrets: %v", err)
+		}
+	}
+}
+
+func (r *Resolver) load() (map[string]string, error) {
+	conn, err := r.ConnOpener()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	secrets, err := conn.GetSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, secret := range secrets {
+		if !r.Resolves(secret.Name) {
+			continue
+		}
+		value, err := r.Sealer.Open(secret.Name, secret.KeyID, secret.Sealed)
+		if err != nil {
+			log.Warnf("Failed to open secret %s sealed by key %s: %v", secret.Name, secret.KeyID, err)
+			continue
+		}
+		values[secret.Name] = string(value)
+	}
+	return values, nil
+}
+
+func copyValues(values map[string]string) map[string]string {
+	copied := map[string]string{}
+	for name, value := range values {
+		copied[name] = value
+	}
+	return copied
+}
+
+func equalValues(a, b map[string]string) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, ok := b[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
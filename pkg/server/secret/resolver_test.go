@@ -0,0 +1,95 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestResolver(t *testing.T) {
+	Convey("Resolver", t, func() {
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		conn := &secretConn{secrets: map[string]skydb.Secret{}}
+		keyring, err := NewKeyring("key1", map[string][]byte{
+			"key1": testKey("key1"),
+		})
+		So(err, ShouldBeNil)
+
+		resolver := &Resolver{
+			Sealer: keyring,
+			ConnOpener: func() (skydb.Conn, error) {
+				return conn, nil
+			},
+			Names:    []string{"gcm_api_key"},
+			Prefixes: []string{"oauth_", "smtp_"},
+		}
+		changes := []map[string]string{}
+		resolver.OnChange(func(values map[string]string) {
+			changes = append(changes, values)
+		})
+
+		Convey("resolves secrets by name and prefix", func() {
+			So(Set(conn, keyring, "gcm_api_key", "gcm", now), ShouldBeNil)
+			So(Set(conn, keyring, "oauth_github_client_secret", "github", now), ShouldBeNil)
+			So(Set(conn, keyring, "smtp_password", "smtp", now), ShouldBeNil)
+			So(Set(conn, keyring, "unrelated", "unrelated", now), ShouldBeNil)
+
+			So(resolver.Reload(), ShouldBeNil)
+			So(resolver.Values(), ShouldResemble, map[string]string{
+				"gcm_api_key":                "gcm",
+				"oauth_github_client_secret": "github",
+				"smtp_password":              "smtp",
+			})
+		})
+
+		Convey("notifies listeners only on change", func() {
+			So(Set(conn, keyring, "gcm_api_key", "gcm", now), ShouldBeNil)
+			So(resolver.Reload(), ShouldBeNil)
+			So(resolver.Reload(), ShouldBeNil)
+			So(changes, ShouldResemble, []map[string]string{
+				{"gcm_api_key": "gcm"},
+			})
+
+			So(Set(conn, keyring, "gcm_api_key", "rotated", now), ShouldBeNil)
+			So(resolver.Reload(), ShouldBeNil)
+			So(changes, ShouldResemble, []map[string]string{
+				{"gcm_api_key": "gcm"},
+				{"gcm_api_key": "rotated"},
+			})
+
+			delete(conn.secrets, "gcm_api_key")
+			So(resolver.Reload(), ShouldBeNil)
+			So(changes[2], ShouldResemble, map[string]string{})
+		})
+
+		Convey("skips secrets sealed by unknown key", func() {
+			otherKeyring, err := NewKeyring("key2", map[string][]byte{
+				"key2": testKey("key2"),
+			})
+			So(err, ShouldBeNil)
+			So(Set(conn, otherKeyring, "gcm_api_key", "gcm", now), ShouldBeNil)
+			So(Set(conn, keyring, "smtp_password", "smtp", now), ShouldBeNil)
+
+			So(resolver.Reload(), ShouldBeNil)
+			So(resolver.Values(), ShouldResemble, map[string]string{
+				"smtp_password": "smtp",
+			})
+		})
+	})
+}
@@ -0,0 +1,76 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Get returns the value of the named secret.
+func Get(conn skydb.Conn, sealer Sealer, name string) (string, error) {
+	secret, err := conn.GetSecret(name)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := sealer.Open(secret.Name, secret.KeyID, secret.Sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// Set seals the value of the named secret and saves it.
+func Set(conn skydb.Conn, sealer Sealer, name string, value string, now time.Time) error {
+	sealed, err := sealer.Seal(name, []byte(value))
+	if err != nil {
+		return err
+	}
+
+	return conn.SetSecret(&skydb.Secret{
+		Name:      name,
+		KeyID:     sealer.KeyID(),
+		Sealed:    sealed,
+		UpdatedAt: now,
+	})
+}
+
+// Rotate reseals the secrets which are not sealed by the current key of
+// the sealer. It returns the number of secrets resealed.
+func Rotate(conn skydb.Conn, sealer Sealer, now time.Time) (int, error) {
+	secrets, err := conn.GetSecrets()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, secret := range secrets {
+		if secret.KeyID == sealer.KeyID() {
+			continue
+		}
+
+		value, err := sealer.Open(secret.Name, secret.KeyID, secret.Sealed)
+		if err != nil {
+			return count, err
+		}
+		if err := Set(conn, sealer, secret.Name, string(value), now); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type secretConn struct {
+	skydb.Conn
+	secrets map[string]skydb.Secret
+}
+
+func (c *secretConn) GetSecret(name string) (*skydb.Secret, error) {
+	secret, ok := c.secrets[name]
+	if !ok {
+		return nil, skydb.ErrSecretNotFound
+	}
+	return &secret, nil
+}
+
+func (c *secretConn) GetSecrets() ([]skydb.Secret, error) {
+	secrets := []skydb.Secret{}
+	for _, secret := range c.secrets {
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+func (c *secretConn) SetSecret(secret *skydb.Secret) error {
+	c.secrets[secret.Name] = *secret
+	return nil
+}
+
+func (c *secretConn) Close() error {
+	return nil
+}
+
+func testKey(seed string) []byte {
+	sum := sha256.Sum256([]byte(seed))
+	return sum[:]
+}
+
+func TestKeyring(t *testing.T) {
+	Convey("Keyring", t, func() {
+		key1 := testKey("key1")
+		key2 := testKey("key2")
+		keyring, err := NewKeyring("key1", map[string][]byte{"key1": key1})
+		So(err, ShouldBeNil)
+
+		Convey("seals and opens value", func() {
+			sealed, err := keyring.Seal("gcm_api_key", []byte("secret"))
+			So(err, ShouldBeNil)
+			So(string(sealed), ShouldNotContainSubstring, "secret")
+
+			value, err := keyring.Open("gcm_api_key", "key1", sealed)
+			So(err, ShouldBeNil)
+			So(string(value), ShouldEqual, "secret")
+		})
+
+		Convey("rejects value sealed for another secret", func() {
+			sealed, err := keyring.Seal("gcm_api_key", []byte("secret"))
+			So(err, ShouldBeNil)
+
+			_, err = keyring.Open("smtp_password", "key1", sealed)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rejects unknown key", func() {
+			sealed, err := keyring.Seal("gcm_api_key", []byte("secret"))
+			So(err, ShouldBeNil)
+
+			_, err = keyring.Open("gcm_api_key", "key2", sealed)
+			So(err, ShouldEqual, ErrUnknownKey)
+		})
+
+		Convey("rejects invalid keys", func() {
+			_, err := NewKeyring("key2", map[string][]byte{"key1": key1})
+			So(err, ShouldNotBeNil)
+
+			_, err = NewKeyring("short", map[string][]byte{"short": []byte("short")})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("opens but does not seal without primary key", func() {
+			sealed, err := keyring.Seal("gcm_api_key", []byte("secret"))
+			So(err, ShouldBeNil)
+
+			openOnly, err := NewKeyring("", map[string][]byte{"key1": key1})
+			So(err, ShouldBeNil)
+			So(openOnly.KeyID(), ShouldEqual, "")
+
+			value, err := openOnly.Open("gcm_api_key", "key1", sealed)
+			So(err, ShouldBeNil)
+			So(string(value), ShouldEqual, "secret")
+
+			_, err = openOnly.Seal("gcm_api_key", []byte("secret"))
+			So(err, ShouldEqual, ErrNoKey)
+		})
+
+		Convey("parses keys", func() {
+			keys, err := ParseKeys("key1:" + base64.StdEncoding.EncodeToString(key1) +
+				",key2:" + base64.StdEncoding.EncodeToString(key2))
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, map[string][]byte{"key1": key1, "key2": key2})
+
+			_, err = ParseKeys("key1")
+			So(err, ShouldNotBeNil)
+			_, err = ParseKeys("key1:" + strings.Repeat("!", 4))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSecret(t *testing.T) {
+	Convey("Secret", t, func() {
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		conn := &secretConn{secrets: map[string]skydb.Secret{}}
+		oldKeyring, err := NewKeyring("old", map[string][]byte{
+			"old": testKey("old"),
+		})
+		So(err, ShouldBeNil)
+		keyring, err := NewKeyring("new", map[string][]byte{
+			"old": testKey("old"),
+			"new": testKey("new"),
+		})
+		So(err, ShouldBeNil)
+
+		Convey("sets and gets secret", func() {
+			So(Set(conn, keyring, "gcm_api_key", "secret", now), ShouldBeNil)
+			So(conn.secrets["gcm_api_key"].KeyID, ShouldEqual, "new")
+			So(conn.secrets["gcm_api_key"].UpdatedAt, ShouldResemble, now)
+
+			value, err := Get(conn, keyring, "gcm_api_key")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "secret")
+		})
+
+		Convey("returns error of non-existent secret", func() {
+			_, err := Get(conn, keyring, "gcm_api_key")
+			So(err, ShouldEqual, skydb.ErrSecretNotFound)
+		})
+
+		Convey("reseals secrets sealed by old key", func() {
+			So(Set(conn, oldKeyring, "gcm_api_key", "gcm", now), ShouldBeNil)
+			So(Set(conn, keyring, "smtp_password", "smtp", now), ShouldBeNil)
+
+			count, err := Rotate(conn, keyring, now.Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			So(conn.secrets["gcm_api_key"].KeyID, ShouldEqual, "new")
+
+			value, err := Get(conn, keyring, "gcm_api_key")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, "gcm")
+		})
+	})
+}
@@ -101,6 +101,15 @@ type PluginConfig struct {
 	Args      []string
 }
 
+// TenantConfig configures a tenant in multi-tenant mode, which is
+// resolved from its keys or the host of the request.
+type TenantConfig struct {
+	Name      string
+	APIKey    string
+	MasterKey string
+	Hosts     []string
+}
+
 // Configuration is Skygear's configuration
 // The configuration will load in following order:
 // 1. The ENV
@@ -111,20 +120,81 @@ type Configuration struct {
 		Host string `json:"host"`
 	} `json:"http"`
 	App struct {
-		Name            string     `json:"name"`
-		APIKey          string     `json:"api_key"`
-		MasterKey       string     `json:"master_key"`
-		AccessControl   string     `json:"access_control"`
-		AuthRecordKeys  [][]string `json:"auth_record_keys"`
-		DevMode         bool       `json:"dev_mode"`
-		CORSHost        string     `json:"cors_host"`
-		Slave           bool       `json:"slave"`
-		ResponseTimeout int64      `json:"response_timeout"`
+		Name              string     `json:"name"`
+		APIKey            string     `json:"api_key"`
+		MasterKey         string     `json:"master_key"`
+		AccessControl     string     `json:"access_control"`
+		AuthRecordKeys    [][]string `json:"auth_record_keys"`
+		DevMode           bool       `json:"dev_mode"`
+		CORSHost          string     `json:"cors_host"`
+		Slave             bool       `json:"slave"`
+		ResponseTimeout   int64      `json:"response_timeout"`
+		RevisionPolicy    string     `json:"revision_policy"`
+		DeviceTokenPolicy string     `json:"device_token_policy"`
 	} `json:"app"`
 	DB struct {
-		ImplName string `json:"implementation"`
-		Option   string `json:"option"`
+		ImplName               string         `json:"implementation"`
+		Option                 string         `json:"option"`
+		ReplicaOptions         []string       `json:"replica_options"`
+		StatementCacheSize     int            `json:"statement_cache_size"`
+		MaxOpenConns           int            `json:"max_open_conns"`
+		MaxIdleConns           int            `json:"max_idle_conns"`
+		ConnMaxLifetime        int            `json:"conn_max_lifetime"`
+		StatementTimeout       int            `json:"statement_timeout"`
+		MaxRetries             int            `json:"max_retries"`
+		QueryCeiling           int            `json:"query_ceiling"`
+		HistoryRecordTypes     []string       `json:"history_record_types"`
+		MigrationDir           string         `json:"migration_dir"`
+		MigrateOnStart         bool           `json:"migrate_on_start"`
+		MaxRecordTypes         int            `json:"max_record_types"`
+		MaxColumnsPerType      int            `json:"max_columns_per_type"`
+		QueryMaxLimit          int            `json:"query_max_limit"`
+		QueryMaxLimits         map[string]int `json:"query_max_limits"`
+		QueryMaxPredicateDepth int            `json:"query_max_predicate_depth"`
+		QueryMaxJoins          int            `json:"query_max_joins"`
 	} `json:"database"`
+	Failover struct {
+		StandbyOption    string `json:"standby_option"`
+		Auto             bool   `json:"auto"`
+		CheckInterval    int    `json:"check_interval"`
+		FailureThreshold int    `json:"failure_threshold"`
+		GracePeriod      int    `json:"grace_period"`
+	} `json:"failover"`
+	Secret struct {
+		Key     string `json:"key"`
+		KeyID   string `json:"key_id"`
+		OldKeys string `json:"old_keys"`
+	} `json:"secret"`
+	PasswordHash struct {
+		Algorithm         string `json:"algorithm"`
+		BcryptCost        int    `json:"bcrypt_cost"`
+		ScryptN           int    `json:"scrypt_n"`
+		ScryptR           int    `json:"scrypt_r"`
+		ScryptP           int    `json:"scrypt_p"`
+		Argon2Memory      int    `json:"argon2_memory"`
+		Argon2Iterations  int    `json:"argon2_iterations"`
+		Argon2Parallelism int    `json:"argon2_parallelism"`
+	} `json:"password_hash"`
+	Audit struct {
+		Sinks         string `json:"sinks"`
+		QueueSize     int    `json:"queue_size"`
+		Timeout       int    `json:"timeout"`
+		SyslogNetwork string `json:"syslog_network"`
+		SyslogAddress string `json:"syslog_address"`
+		SyslogTag     string `json:"syslog_tag"`
+		KafkaRESTURL  string `json:"kafka_rest_url"`
+		KafkaTopic    string `json:"kafka_topic"`
+		WebhookURL    string `json:"webhook_url"`
+		WebhookSecret string `json:"webhook_secret"`
+	} `json:"audit"`
+	Webhook struct {
+		Timeout          int `json:"timeout"`
+		FailureThreshold int `json:"failure_threshold"`
+		QueueSize        int `json:"queue_size"`
+	} `json:"webhook"`
+	Unread struct {
+		RecordTypes []string `json:"record_types"`
+	} `json:"unread"`
 	TokenStore struct {
 		ImplName string `json:"implementation"`
 		Path     string `json:"path"`
@@ -136,6 +206,10 @@ type Configuration struct {
 		ImplName string `json:"implementation"`
 		Public   bool   `json:"public"`
 
+		// OffloadThreshold is the size in bytes above which record fields
+		// are offloaded to the asset store. Zero disables offloading.
+		OffloadThreshold int `json:"offload_threshold"`
+
 		FileSystemStore struct {
 			Path      string `json:"-"`
 			URLPrefix string `json:"url_prefix"`
@@ -157,6 +231,41 @@ type Configuration struct {
 			PrivatePrefix string `json:"private_prefix"`
 		} `json:"cloud"`
 	} `json:"asset_store"`
+	HTTPCache struct {
+		MaxAge   int    `json:"max_age"`
+		PurgeURL string `json:"purge_url"`
+	} `json:"http_cache"`
+	QueryCache struct {
+		// ImplName is memory or redis. Queries are not cached if it is
+		// empty.
+		ImplName string `json:"implementation"`
+		Path     string `json:"-"`
+		TTL      int    `json:"ttl"`
+	} `json:"query_cache"`
+	RateLimit struct {
+		Mode  string  `json:"mode"`
+		Rate  float64 `json:"rate"`
+		Burst int     `json:"burst"`
+	} `json:"rate_limit"`
+	Chaos struct {
+		Enable         bool     `json:"enable"`
+		Latency        int      `json:"latency"`
+		ErrorRate      float64  `json:"error_rate"`
+		PluginDropRate float64  `json:"plugin_drop_rate"`
+		Actions        []string `json:"actions"`
+	} `json:"chaos"`
+	Position struct {
+		RebalanceSchedule string `json:"rebalance_schedule"`
+		RebalanceLength   int    `json:"rebalance_length"`
+	} `json:"position"`
+	Transition struct {
+		Schedule  string `json:"schedule"`
+		BatchSize int    `json:"batch_size"`
+		Lease     int    `json:"lease"`
+	} `json:"transition"`
+	Report struct {
+		Schedule string `json:"schedule"`
+	} `json:"report"`
 	APNS struct {
 		Enable bool   `json:"enable"`
 		Type   string `json:"type"`
@@ -194,6 +303,10 @@ type Configuration struct {
 		MaxBounce int `json:"max_bounce"`
 	} `json:"zmq"`
 	Plugin map[string]*PluginConfig `json:"-"`
+
+	// Tenants are the apps served in multi-tenant mode, which is
+	// enabled if it is not empty.
+	Tenants []TenantConfig `json:"-"`
 }
 
 func NewConfiguration() Configuration {
@@ -206,11 +319,42 @@ func NewConfiguration() Configuration {
 	config.App.CORSHost = "*"
 	config.App.Slave = false
 	config.App.ResponseTimeout = 60
+	config.App.RevisionPolicy = "ignore"
+	config.App.DeviceTokenPolicy = "transfer"
 	config.DB.ImplName = "pq"
 	config.DB.Option = "postgres://postgres:@localhost/postgres?sslmode=disable"
+	config.DB.StatementCacheSize = 100
+	config.DB.MaxOpenConns = 10
+	config.DB.MaxIdleConns = 2
+	config.DB.MaxRetries = 3
+	config.QueryCache.TTL = 60
+	config.Failover.CheckInterval = 10
+	config.Failover.FailureThreshold = 3
+	config.Failover.GracePeriod = 60
+	config.PasswordHash.Algorithm = "bcrypt"
+	config.PasswordHash.BcryptCost = 10
+	config.PasswordHash.ScryptN = 32768
+	config.PasswordHash.ScryptR = 8
+	config.PasswordHash.ScryptP = 1
+	config.PasswordHash.Argon2Memory = 65536
+	config.PasswordHash.Argon2Iterations = 3
+	config.PasswordHash.Argon2Parallelism = 2
+	config.Audit.QueueSize = 1000
+	config.Audit.Timeout = 10
+	config.Audit.SyslogTag = "skygear"
+	config.Audit.KafkaTopic = "skygear-auth-events"
+	config.Webhook.Timeout = 10
+	config.Webhook.FailureThreshold = 10
+	config.Webhook.QueueSize = 1000
 	config.TokenStore.ImplName = "fs"
 	config.TokenStore.Path = "data/token"
 	config.TokenStore.Expiry = 0
+	config.Position.RebalanceLength = 16
+	config.Transition.Schedule = "@every 1m"
+	config.Transition.BatchSize = 100
+	config.Transition.Lease = 300
+	config.Report.Schedule = "@every 1m"
+	config.DB.QueryMaxLimits = map[string]int{}
 	config.AssetStore.ImplName = "fs"
 	config.AssetStore.FileSystemStore.Path = "data/asset"
 	config.AssetStore.FileSystemStore.URLPrefix = "http://localhost:3000/files"
@@ -259,6 +403,113 @@ func (config *Configuration) Validate() error {
 	if config.APNS.Enable && !regexp.MustCompile("^(cert|token)$").MatchString(config.APNS.Type) {
 		return fmt.Errorf("APNS_TYPE must be cert or token")
 	}
+	if config.RateLimit.Mode != "" && !regexp.MustCompile("^(soft|enforce)$").MatchString(config.RateLimit.Mode) {
+		return fmt.Errorf("RATE_LIMIT_MODE must be soft or enforce")
+	}
+	if config.App.RevisionPolicy != "" && !regexp.MustCompile("^(ignore|reject|require)$").MatchString(config.App.RevisionPolicy) {
+		return fmt.Errorf("RECORD_REVISION_POLICY must be ignore, reject or require")
+	}
+	if config.App.DeviceTokenPolicy != "" && !regexp.MustCompile("^(transfer|shared)$").MatchString(config.App.DeviceTokenPolicy) {
+		return fmt.Errorf("DEVICE_TOKEN_POLICY must be transfer or shared")
+	}
+	if config.QueryCache.ImplName != "" && !regexp.MustCompile("^(memory|redis)$").MatchString(config.QueryCache.ImplName) {
+		return fmt.Errorf("QUERY_CACHE must be memory or redis")
+	}
+	if config.QueryCache.ImplName == "redis" && config.QueryCache.Path == "" {
+		return fmt.Errorf("QUERY_CACHE_PATH must be set for redis query cache")
+	}
+	if config.QueryCache.ImplName != "" && config.QueryCache.TTL <= 0 {
+		return fmt.Errorf("QUERY_CACHE_TTL must be positive")
+	}
+	if config.DB.StatementCacheSize < 0 {
+		return fmt.Errorf("DB_STATEMENT_CACHE_SIZE must not be negative")
+	}
+	if config.DB.QueryCeiling < 0 {
+		return fmt.Errorf("DB_QUERY_CEILING must not be negative")
+	}
+	if config.DB.MaxOpenConns < 0 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must not be negative")
+	}
+	if config.DB.MaxIdleConns < 0 {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS must not be negative")
+	}
+	if config.DB.ConnMaxLifetime < 0 {
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME must not be negative")
+	}
+	if config.DB.StatementTimeout < 0 {
+		return fmt.Errorf("DB_STATEMENT_TIMEOUT must not be negative")
+	}
+	if config.DB.MaxRetries < 0 {
+		return fmt.Errorf("DB_MAX_RETRIES must not be negative")
+	}
+	if config.DB.MaxRecordTypes < 0 {
+		return fmt.Errorf("DB_MAX_RECORD_TYPES must not be negative")
+	}
+	if config.DB.MaxColumnsPerType < 0 {
+		return fmt.Errorf("DB_MAX_COLUMNS_PER_TYPE must not be negative")
+	}
+	if config.DB.QueryMaxLimit < 0 {
+		return fmt.Errorf("DB_QUERY_MAX_LIMIT must not be negative")
+	}
+	for recordType, maxLimit := range config.DB.QueryMaxLimits {
+		if maxLimit < 0 {
+			return fmt.Errorf("DB_QUERY_MAX_LIMIT_%s must not be negative", strings.ToUpper(recordType))
+		}
+	}
+	if config.DB.QueryMaxPredicateDepth < 0 {
+		return fmt.Errorf("DB_QUERY_MAX_PREDICATE_DEPTH must not be negative")
+	}
+	if config.DB.QueryMaxJoins < 0 {
+		return fmt.Errorf("DB_QUERY_MAX_JOINS must not be negative")
+	}
+	if err := config.validateTenants(); err != nil {
+		return err
+	}
+	if config.DB.MigrateOnStart && config.DB.MigrationDir == "" {
+		return fmt.Errorf("DB_MIGRATION_DIR must be set with DB_MIGRATE_ON_START")
+	}
+	if config.Failover.StandbyOption != "" && config.Failover.CheckInterval <= 0 {
+		return fmt.Errorf("FAILOVER_CHECK_INTERVAL must be positive")
+	}
+	if config.Failover.StandbyOption != "" && config.Failover.FailureThreshold <= 0 {
+		return fmt.Errorf("FAILOVER_FAILURE_THRESHOLD must be positive")
+	}
+	if config.Secret.Key != "" && config.Secret.KeyID == "" {
+		return fmt.Errorf("SECRET_KEY_ID must be set with SECRET_KEY")
+	}
+	if err := config.validatePasswordHash(); err != nil {
+		return err
+	}
+	if err := config.validateAudit(); err != nil {
+		return err
+	}
+	if config.Webhook.Timeout < 0 {
+		return fmt.Errorf("WEBHOOK_TIMEOUT must not be negative")
+	}
+	if config.Webhook.FailureThreshold < 0 {
+		return fmt.Errorf("WEBHOOK_FAILURE_THRESHOLD must not be negative")
+	}
+	if config.Webhook.QueueSize < 0 {
+		return fmt.Errorf("WEBHOOK_QUEUE_SIZE must not be negative")
+	}
+	if config.Chaos.Enable && !config.App.DevMode {
+		return fmt.Errorf("CHAOS_ENABLE requires DEV_MODE")
+	}
+	if config.Chaos.ErrorRate < 0 || config.Chaos.ErrorRate > 1 {
+		return fmt.Errorf("CHAOS_ERROR_RATE must be between 0 and 1")
+	}
+	if config.Chaos.PluginDropRate < 0 || config.Chaos.PluginDropRate > 1 {
+		return fmt.Errorf("CHAOS_PLUGIN_DROP_RATE must be between 0 and 1")
+	}
+	if config.Position.RebalanceSchedule != "" && config.Position.RebalanceLength <= 0 {
+		return fmt.Errorf("POSITION_REBALANCE_LENGTH must be positive")
+	}
+	if config.Transition.Schedule != "" && config.Transition.BatchSize <= 0 {
+		return fmt.Errorf("TRANSITION_BATCH_SIZE must be positive")
+	}
+	if config.Transition.Schedule != "" && config.Transition.Lease <= 0 {
+		return fmt.Errorf("TRANSITION_LEASE must be positive")
+	}
 	if err := config.checkAuthRecordKeysDuplication(); err != nil {
 		return err
 	}
@@ -279,6 +530,68 @@ func (config *Configuration) checkAuthRecordKeysDuplication() error {
 	return nil
 }
 
+func (config *Configuration) validatePasswordHash() error {
+	c := config.PasswordHash
+	switch c.Algorithm {
+	case "":
+		// bcrypt with the default cost
+	case "bcrypt":
+		if c.BcryptCost < 4 || c.BcryptCost > 31 {
+			return fmt.Errorf("PASSWORD_BCRYPT_COST must be between 4 and 31")
+		}
+	case "scrypt":
+		if c.ScryptN <= 1 || c.ScryptN&(c.ScryptN-1) != 0 {
+			return fmt.Errorf("PASSWORD_SCRYPT_N must be a power of 2 greater than 1")
+		}
+		if c.ScryptR <= 0 || c.ScryptP <= 0 {
+			return fmt.Errorf("PASSWORD_SCRYPT_R and PASSWORD_SCRYPT_P must be positive")
+		}
+	case "argon2id":
+		if c.Argon2Memory <= 0 || c.Argon2Iterations <= 0 {
+			return fmt.Errorf("PASSWORD_ARGON2_MEMORY and PASSWORD_ARGON2_ITERATIONS must be positive")
+		}
+		if c.Argon2Parallelism <= 0 || c.Argon2Parallelism > 255 {
+			return fmt.Errorf("PASSWORD_ARGON2_PARALLELISM must be between 1 and 255")
+		}
+	default:
+		return fmt.Errorf("PASSWORD_HASH_ALGORITHM must be bcrypt, scrypt or argon2id")
+	}
+	return nil
+}
+
+func (config *Configuration) validateAudit() error {
+	for _, sink := range config.AuditSinks() {
+		switch sink {
+		case "syslog":
+		case "kafka":
+			if config.Audit.KafkaRESTURL == "" || config.Audit.KafkaTopic == "" {
+				return fmt.Errorf("AUDIT_KAFKA_REST_URL and AUDIT_KAFKA_TOPIC must be set for kafka sink")
+			}
+		case "webhook":
+			if config.Audit.WebhookURL == "" {
+				return fmt.Errorf("AUDIT_WEBHOOK_URL must be set for webhook sink")
+			}
+		default:
+			return fmt.Errorf("AUDIT_SINKS must be syslog, kafka or webhook")
+		}
+	}
+	if config.Audit.QueueSize < 0 {
+		return fmt.Errorf("AUDIT_QUEUE_SIZE must not be negative")
+	}
+	return nil
+}
+
+// AuditSinks returns the names of the sinks of auth events.
+func (config *Configuration) AuditSinks() []string {
+	sinks := []string{}
+	for _, sink := range strings.Split(config.Audit.Sinks, ",") {
+		if sink = strings.TrimSpace(sink); sink != "" {
+			sinks = append(sinks, sink)
+		}
+	}
+	return sinks
+}
+
 func (config *Configuration) ReadFromEnv() {
 	envErr := godotenv.Load()
 	if envErr != nil {
@@ -320,15 +633,110 @@ func (config *Configuration) ReadFromEnv() {
 		config.App.DevMode = devMode
 	}
 
+	revisionPolicy := os.Getenv("RECORD_REVISION_POLICY")
+	if revisionPolicy != "" {
+		config.App.RevisionPolicy = revisionPolicy
+	}
+
+	if deviceTokenPolicy := os.Getenv("DEVICE_TOKEN_POLICY"); deviceTokenPolicy != "" {
+		config.App.DeviceTokenPolicy = deviceTokenPolicy
+	}
+
 	dbImplName := os.Getenv("DB_IMPL_NAME")
 	if dbImplName != "" {
 		config.DB.ImplName = dbImplName
 	}
 
-	if config.DB.ImplName == "pq" && os.Getenv("DATABASE_URL") != "" {
+	if config.DB.ImplName == "sqlite" && strings.HasPrefix(config.DB.Option, "postgres://") {
+		// the default option is a PostgreSQL URL
+		config.DB.Option = "skygear.db"
+	}
+
+	if config.DB.ImplName == "mongo" && strings.HasPrefix(config.DB.Option, "postgres://") {
+		config.DB.Option = "mongodb://localhost:27017"
+	}
+
+	if (config.DB.ImplName == "pq" || config.DB.ImplName == "sqlite" || config.DB.ImplName == "mongo") &&
+		os.Getenv("DATABASE_URL") != "" {
 		config.DB.Option = os.Getenv("DATABASE_URL")
 	}
 
+	if replicaOptions := os.Getenv("DATABASE_REPLICA_URLS"); replicaOptions != "" {
+		config.DB.ReplicaOptions = strings.Split(replicaOptions, ",")
+	}
+
+	if cacheSize, err := strconv.ParseInt(os.Getenv("DB_STATEMENT_CACHE_SIZE"), 10, 0); err == nil {
+		config.DB.StatementCacheSize = int(cacheSize)
+	}
+
+	if queryCeiling, err := strconv.ParseInt(os.Getenv("DB_QUERY_CEILING"), 10, 0); err == nil {
+		config.DB.QueryCeiling = int(queryCeiling)
+	}
+
+	if maxOpenConns, err := strconv.ParseInt(os.Getenv("DB_MAX_OPEN_CONNS"), 10, 0); err == nil {
+		config.DB.MaxOpenConns = int(maxOpenConns)
+	}
+
+	if maxIdleConns, err := strconv.ParseInt(os.Getenv("DB_MAX_IDLE_CONNS"), 10, 0); err == nil {
+		config.DB.MaxIdleConns = int(maxIdleConns)
+	}
+
+	if connMaxLifetime, err := strconv.ParseInt(os.Getenv("DB_CONN_MAX_LIFETIME"), 10, 0); err == nil {
+		config.DB.ConnMaxLifetime = int(connMaxLifetime)
+	}
+
+	if statementTimeout, err := strconv.ParseInt(os.Getenv("DB_STATEMENT_TIMEOUT"), 10, 0); err == nil {
+		config.DB.StatementTimeout = int(statementTimeout)
+	}
+
+	if maxRetries, err := strconv.ParseInt(os.Getenv("DB_MAX_RETRIES"), 10, 0); err == nil {
+		config.DB.MaxRetries = int(maxRetries)
+	}
+
+	if recordTypes := os.Getenv("DB_HISTORY_RECORD_TYPES"); recordTypes != "" {
+		config.DB.HistoryRecordTypes = strings.Split(recordTypes, ",")
+	}
+
+	if maxRecordTypes, err := strconv.ParseInt(os.Getenv("DB_MAX_RECORD_TYPES"), 10, 0); err == nil {
+		config.DB.MaxRecordTypes = int(maxRecordTypes)
+	}
+
+	if maxColumns, err := strconv.ParseInt(os.Getenv("DB_MAX_COLUMNS_PER_TYPE"), 10, 0); err == nil {
+		config.DB.MaxColumnsPerType = int(maxColumns)
+	}
+
+	if maxLimit, err := strconv.ParseInt(os.Getenv("DB_QUERY_MAX_LIMIT"), 10, 0); err == nil {
+		config.DB.QueryMaxLimit = int(maxLimit)
+	}
+
+	for _, environ := range os.Environ() {
+		if !strings.HasPrefix(environ, "DB_QUERY_MAX_LIMIT_") {
+			continue
+		}
+
+		components := strings.SplitN(environ, "=", 2)
+		recordType := strings.ToLower(strings.TrimPrefix(components[0], "DB_QUERY_MAX_LIMIT_"))
+		if maxLimit, err := strconv.ParseInt(components[1], 10, 0); err == nil {
+			config.DB.QueryMaxLimits[recordType] = int(maxLimit)
+		}
+	}
+
+	if depth, err := strconv.ParseInt(os.Getenv("DB_QUERY_MAX_PREDICATE_DEPTH"), 10, 0); err == nil {
+		config.DB.QueryMaxPredicateDepth = int(depth)
+	}
+
+	if joins, err := strconv.ParseInt(os.Getenv("DB_QUERY_MAX_JOINS"), 10, 0); err == nil {
+		config.DB.QueryMaxJoins = int(joins)
+	}
+
+	if migrationDir := os.Getenv("DB_MIGRATION_DIR"); migrationDir != "" {
+		config.DB.MigrationDir = migrationDir
+	}
+
+	if migrateOnStart, err := parseBool(os.Getenv("DB_MIGRATE_ON_START")); err == nil {
+		config.DB.MigrateOnStart = migrateOnStart
+	}
+
 	if slave, err := parseBool(os.Getenv("SLAVE")); err == nil {
 		config.App.Slave = slave
 	}
@@ -343,10 +751,24 @@ func (config *Configuration) ReadFromEnv() {
 
 	config.readTokenStore()
 	config.readAssetStore()
+	config.readHTTPCache()
+	config.readQueryCache()
+	config.readRateLimit()
+	config.readChaos()
+	config.readPosition()
+	config.readTransition()
+	config.readReport()
+	config.readFailover()
+	config.readSecret()
+	config.readPasswordHash()
+	config.readAudit()
+	config.readWebhook()
+	config.readUnread()
 	config.readAPNS()
 	config.readGCM()
 	config.readLog()
 	config.readPlugins()
+	config.readTenants()
 }
 
 func (config *Configuration) readHost() {
@@ -400,6 +822,10 @@ func (config *Configuration) readAssetStore() {
 		config.AssetStore.Public = assetStorePublic
 	}
 
+	if threshold, err := strconv.ParseInt(os.Getenv("ASSET_STORE_OFFLOAD_THRESHOLD"), 10, 0); err == nil {
+		config.AssetStore.OffloadThreshold = int(threshold)
+	}
+
 	// Local Storage related
 	assetStorePath := os.Getenv("ASSET_STORE_PATH")
 	if assetStorePath != "" {
@@ -455,6 +881,230 @@ func (config *Configuration) readAssetStore() {
 	}
 }
 
+func (config *Configuration) readHTTPCache() {
+	if maxAge, err := strconv.ParseInt(os.Getenv("HTTP_CACHE_MAX_AGE"), 10, 0); err == nil {
+		config.HTTPCache.MaxAge = int(maxAge)
+	}
+
+	purgeURL := os.Getenv("HTTP_CACHE_PURGE_URL")
+	if purgeURL != "" {
+		config.HTTPCache.PurgeURL = purgeURL
+	}
+}
+
+func (config *Configuration) readQueryCache() {
+	if queryCache := os.Getenv("QUERY_CACHE"); queryCache != "" {
+		config.QueryCache.ImplName = queryCache
+	}
+
+	if path := os.Getenv("QUERY_CACHE_PATH"); path != "" {
+		config.QueryCache.Path = path
+	}
+
+	if ttl, err := strconv.ParseInt(os.Getenv("QUERY_CACHE_TTL"), 10, 0); err == nil {
+		config.QueryCache.TTL = int(ttl)
+	}
+}
+
+func (config *Configuration) readRateLimit() {
+	mode := os.Getenv("RATE_LIMIT_MODE")
+	if mode != "" {
+		config.RateLimit.Mode = mode
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_RATE"), 64); err == nil {
+		config.RateLimit.Rate = rate
+	}
+
+	if burst, err := strconv.ParseInt(os.Getenv("RATE_LIMIT_BURST"), 10, 0); err == nil {
+		config.RateLimit.Burst = int(burst)
+	}
+}
+
+func (config *Configuration) readChaos() {
+	if enable, err := parseBool(os.Getenv("CHAOS_ENABLE")); err == nil {
+		config.Chaos.Enable = enable
+	}
+
+	if latency, err := strconv.ParseInt(os.Getenv("CHAOS_LATENCY"), 10, 0); err == nil {
+		config.Chaos.Latency = int(latency)
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("CHAOS_ERROR_RATE"), 64); err == nil {
+		config.Chaos.ErrorRate = rate
+	}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("CHAOS_PLUGIN_DROP_RATE"), 64); err == nil {
+		config.Chaos.PluginDropRate = rate
+	}
+
+	if actions := os.Getenv("CHAOS_ACTIONS"); actions != "" {
+		config.Chaos.Actions = strings.Split(actions, ",")
+	}
+}
+
+func (config *Configuration) readPosition() {
+	if schedule := os.Getenv("POSITION_REBALANCE_SCHEDULE"); schedule != "" {
+		config.Position.RebalanceSchedule = schedule
+	}
+
+	if length, err := strconv.ParseInt(os.Getenv("POSITION_REBALANCE_LENGTH"), 10, 0); err == nil {
+		config.Position.RebalanceLength = int(length)
+	}
+}
+
+func (config *Configuration) readTransition() {
+	if schedule, ok := os.LookupEnv("TRANSITION_SCHEDULE"); ok {
+		config.Transition.Schedule = schedule
+	}
+
+	if batchSize, err := strconv.ParseInt(os.Getenv("TRANSITION_BATCH_SIZE"), 10, 0); err == nil {
+		config.Transition.BatchSize = int(batchSize)
+	}
+
+	if lease, err := strconv.ParseInt(os.Getenv("TRANSITION_LEASE"), 10, 0); err == nil {
+		config.Transition.Lease = int(lease)
+	}
+}
+
+func (config *Configuration) readReport() {
+	if schedule, ok := os.LookupEnv("REPORT_SCHEDULE"); ok {
+		config.Report.Schedule = schedule
+	}
+}
+
+func (config *Configuration) readFailover() {
+	if standbyOption := os.Getenv("FAILOVER_STANDBY_URL"); standbyOption != "" {
+		config.Failover.StandbyOption = standbyOption
+	}
+
+	if auto, err := parseBool(os.Getenv("FAILOVER_AUTO")); err == nil {
+		config.Failover.Auto = auto
+	}
+
+	if interval, err := strconv.ParseInt(os.Getenv("FAILOVER_CHECK_INTERVAL"), 10, 0); err == nil {
+		config.Failover.CheckInterval = int(interval)
+	}
+
+	if threshold, err := strconv.ParseInt(os.Getenv("FAILOVER_FAILURE_THRESHOLD"), 10, 0); err == nil {
+		config.Failover.FailureThreshold = int(threshold)
+	}
+
+	if gracePeriod, err := strconv.ParseInt(os.Getenv("FAILOVER_GRACE_PERIOD"), 10, 0); err == nil {
+		config.Failover.GracePeriod = int(gracePeriod)
+	}
+}
+
+func (config *Configuration) readSecret() {
+	if key := os.Getenv("SECRET_KEY"); key != "" {
+		config.Secret.Key = key
+	}
+
+	if keyID := os.Getenv("SECRET_KEY_ID"); keyID != "" {
+		config.Secret.KeyID = keyID
+	}
+
+	if oldKeys := os.Getenv("SECRET_OLD_KEYS"); oldKeys != "" {
+		config.Secret.OldKeys = oldKeys
+	}
+}
+
+func (config *Configuration) readPasswordHash() {
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		config.PasswordHash.Algorithm = algorithm
+	}
+
+	if cost, err := strconv.ParseInt(os.Getenv("PASSWORD_BCRYPT_COST"), 10, 0); err == nil {
+		config.PasswordHash.BcryptCost = int(cost)
+	}
+
+	if n, err := strconv.ParseInt(os.Getenv("PASSWORD_SCRYPT_N"), 10, 0); err == nil {
+		config.PasswordHash.ScryptN = int(n)
+	}
+
+	if r, err := strconv.ParseInt(os.Getenv("PASSWORD_SCRYPT_R"), 10, 0); err == nil {
+		config.PasswordHash.ScryptR = int(r)
+	}
+
+	if p, err := strconv.ParseInt(os.Getenv("PASSWORD_SCRYPT_P"), 10, 0); err == nil {
+		config.PasswordHash.ScryptP = int(p)
+	}
+
+	if memory, err := strconv.ParseInt(os.Getenv("PASSWORD_ARGON2_MEMORY"), 10, 0); err == nil {
+		config.PasswordHash.Argon2Memory = int(memory)
+	}
+
+	if iterations, err := strconv.ParseInt(os.Getenv("PASSWORD_ARGON2_ITERATIONS"), 10, 0); err == nil {
+		config.PasswordHash.Argon2Iterations = int(iterations)
+	}
+
+	if parallelism, err := strconv.ParseInt(os.Getenv("PASSWORD_ARGON2_PARALLELISM"), 10, 0); err == nil {
+		config.PasswordHash.Argon2Parallelism = int(parallelism)
+	}
+}
+
+func (config *Configuration) readAudit() {
+	if sinks := os.Getenv("AUDIT_SINKS"); sinks != "" {
+		config.Audit.Sinks = sinks
+	}
+
+	if queueSize, err := strconv.ParseInt(os.Getenv("AUDIT_QUEUE_SIZE"), 10, 0); err == nil {
+		config.Audit.QueueSize = int(queueSize)
+	}
+
+	if timeout, err := strconv.ParseInt(os.Getenv("AUDIT_TIMEOUT"), 10, 0); err == nil {
+		config.Audit.Timeout = int(timeout)
+	}
+
+	if network := os.Getenv("AUDIT_SYSLOG_NETWORK"); network != "" {
+		config.Audit.SyslogNetwork = network
+	}
+
+	if address := os.Getenv("AUDIT_SYSLOG_ADDRESS"); address != "" {
+		config.Audit.SyslogAddress = address
+	}
+
+	if tag := os.Getenv("AUDIT_SYSLOG_TAG"); tag != "" {
+		config.Audit.SyslogTag = tag
+	}
+
+	if url := os.Getenv("AUDIT_KAFKA_REST_URL"); url != "" {
+		config.Audit.KafkaRESTURL = url
+	}
+
+	if topic := os.Getenv("AUDIT_KAFKA_TOPIC"); topic != "" {
+		config.Audit.KafkaTopic = topic
+	}
+
+	if url := os.Getenv("AUDIT_WEBHOOK_URL"); url != "" {
+		config.Audit.WebhookURL = url
+	}
+
+	if secret := os.Getenv("AUDIT_WEBHOOK_SECRET"); secret != "" {
+		config.Audit.WebhookSecret = secret
+	}
+}
+
+func (config *Configuration) readWebhook() {
+	if timeout, err := strconv.ParseInt(os.Getenv("WEBHOOK_TIMEOUT"), 10, 0); err == nil {
+		config.Webhook.Timeout = int(timeout)
+	}
+
+	if threshold, err := strconv.ParseInt(os.Getenv("WEBHOOK_FAILURE_THRESHOLD"), 10, 0); err == nil {
+		config.Webhook.FailureThreshold = int(threshold)
+	}
+
+	if queueSize, err := strconv.ParseInt(os.Getenv("WEBHOOK_QUEUE_SIZE"), 10, 0); err == nil {
+		config.Webhook.QueueSize = int(queueSize)
+	}
+}
+
+func (config *Configuration) readUnread() {
+	if recordTypes := os.Getenv("UNREAD_RECORD_TYPES"); recordTypes != "" {
+		config.Unread.RecordTypes = strings.Split(recordTypes, ",")
+	}
+}
+
 func (config *Configuration) readAPNS() {
 	if shouldEnableAPNS, err := parseBool(os.Getenv("APNS_ENABLE")); err == nil {
 		config.APNS.Enable = shouldEnableAPNS
@@ -589,3 +1239,58 @@ func (config *Configuration) readPlugins() {
 		config.Plugin[p] = pluginConfig
 	}
 }
+
+func (config *Configuration) readTenants() {
+	tenants := os.Getenv("TENANTS")
+	if tenants == "" {
+		return
+	}
+
+	config.Tenants = []TenantConfig{}
+	for _, name := range strings.Split(tenants, ",") {
+		prefix := "TENANT_" + strings.ToUpper(name)
+		tenantConfig := TenantConfig{
+			Name:      name,
+			APIKey:    os.Getenv(prefix + "_API_KEY"),
+			MasterKey: os.Getenv(prefix + "_MASTER_KEY"),
+		}
+		if hosts := os.Getenv(prefix + "_HOSTS"); hosts != "" {
+			tenantConfig.Hosts = strings.Split(hosts, ",")
+		}
+		config.Tenants = append(config.Tenants, tenantConfig)
+	}
+}
+
+func (config *Configuration) validateTenants() error {
+	names := map[string]bool{}
+	keys := map[string]bool{}
+	hosts := map[string]bool{}
+	for _, tenant := range config.Tenants {
+		prefix := "TENANT_" + strings.ToUpper(tenant.Name)
+		if !regexp.MustCompile("^[A-Za-z0-9_]+$").MatchString(tenant.Name) {
+			return fmt.Errorf("tenant name '%s' contains invalid characters other than alphanumerics or underscores", tenant.Name)
+		}
+		if names[tenant.Name] {
+			return fmt.Errorf("tenant '%s' is duplicated", tenant.Name)
+		}
+		names[tenant.Name] = true
+
+		if tenant.APIKey == "" || tenant.MasterKey == "" {
+			return fmt.Errorf("%s_API_KEY and %s_MASTER_KEY must be set", prefix, prefix)
+		}
+		for _, key := range []string{tenant.APIKey, tenant.MasterKey} {
+			if keys[key] {
+				return fmt.Errorf("keys of tenant '%s' are used by another tenant", tenant.Name)
+			}
+			keys[key] = true
+		}
+
+		for _, host := range tenant.Hosts {
+			if hosts[host] {
+				return fmt.Errorf("host '%s' of %s_HOSTS is used by another tenant", host, prefix)
+			}
+			hosts[host] = true
+		}
+	}
+	return nil
+}
@@ -66,6 +66,32 @@ func TestRequiredConfig(t *testing.T) {
 			os.Setenv("APP_NAME", "")
 		})
 
+		Convey("Validate chaos testing requires dev mode", func() {
+			config := NewConfigurationWithKeys()
+			config.Chaos.Enable = true
+			So(config.Validate(), ShouldNotBeNil)
+
+			config.App.DevMode = true
+			So(config.Validate(), ShouldBeNil)
+		})
+
+		Convey("Validate the DEVICE_TOKEN_POLICY", func() {
+			config := NewConfigurationWithKeys()
+			So(config.App.DeviceTokenPolicy, ShouldEqual, "transfer")
+
+			os.Setenv("DEVICE_TOKEN_POLICY", "shared")
+			config.ReadFromEnv()
+			So(config.App.DeviceTokenPolicy, ShouldEqual, "shared")
+			So(config.Validate(), ShouldBeNil)
+
+			os.Setenv("DEVICE_TOKEN_POLICY", "unknown")
+			config.ReadFromEnv()
+			So(config.Validate(), ShouldNotBeNil)
+
+			// Clean up
+			os.Setenv("DEVICE_TOKEN_POLICY", "")
+		})
+
 		Convey("Validate the AUTH_RECORD_KEYS", func() {
 			config := NewConfigurationWithKeys()
 			os.Setenv("AUTH_RECORD_KEYS", "a,b,c")
@@ -128,6 +154,131 @@ func TestRequiredConfig(t *testing.T) {
 			os.Setenv("TOKEN_STORE_EXPIRY", "")
 		})
 
+		Convey("Read password hash config correctly", func() {
+			config := NewConfigurationWithKeys()
+			os.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+			os.Setenv("PASSWORD_ARGON2_MEMORY", "32768")
+			os.Setenv("PASSWORD_ARGON2_ITERATIONS", "4")
+
+			config.readPasswordHash()
+			So(config.PasswordHash.Algorithm, ShouldEqual, "argon2id")
+			So(config.PasswordHash.Argon2Memory, ShouldEqual, 32768)
+			So(config.PasswordHash.Argon2Iterations, ShouldEqual, 4)
+			So(config.PasswordHash.Argon2Parallelism, ShouldEqual, 2)
+			So(config.Validate(), ShouldBeNil)
+
+			config.PasswordHash.Argon2Parallelism = 0
+			So(config.Validate(), ShouldNotBeNil)
+
+			config.PasswordHash.Algorithm = "md5"
+			So(config.Validate(), ShouldNotBeNil)
+
+			config.PasswordHash.Algorithm = "bcrypt"
+			config.PasswordHash.BcryptCost = 32
+			So(config.Validate(), ShouldNotBeNil)
+
+			os.Setenv("PASSWORD_HASH_ALGORITHM", "")
+			os.Setenv("PASSWORD_ARGON2_MEMORY", "")
+			os.Setenv("PASSWORD_ARGON2_ITERATIONS", "")
+		})
+
+		Convey("Read query limits correctly", func() {
+			config := NewConfigurationWithKeys()
+			os.Setenv("DB_QUERY_MAX_LIMIT", "1000")
+			os.Setenv("DB_QUERY_MAX_LIMIT_NOTE", "100")
+			os.Setenv("DB_QUERY_MAX_PREDICATE_DEPTH", "8")
+			os.Setenv("DB_QUERY_MAX_JOINS", "4")
+
+			config.ReadFromEnv()
+			So(config.DB.QueryMaxLimit, ShouldEqual, 1000)
+			So(config.DB.QueryMaxLimits, ShouldResemble, map[string]int{"note": 100})
+			So(config.DB.QueryMaxPredicateDepth, ShouldEqual, 8)
+			So(config.DB.QueryMaxJoins, ShouldEqual, 4)
+			So(config.Validate(), ShouldBeNil)
+
+			config.DB.QueryMaxLimits["note"] = -1
+			So(config.Validate(), ShouldNotBeNil)
+
+			os.Unsetenv("DB_QUERY_MAX_LIMIT")
+			os.Unsetenv("DB_QUERY_MAX_LIMIT_NOTE")
+			os.Unsetenv("DB_QUERY_MAX_PREDICATE_DEPTH")
+			os.Unsetenv("DB_QUERY_MAX_JOINS")
+		})
+
+		Convey("Read sqlite database config correctly", func() {
+			config := NewConfigurationWithKeys()
+			os.Setenv("DB_IMPL_NAME", "sqlite")
+
+			config.ReadFromEnv()
+			So(config.DB.ImplName, ShouldEqual, "sqlite")
+			So(config.DB.Option, ShouldEqual, "skygear.db")
+
+			os.Setenv("DATABASE_URL", "file::memory:?cache=shared")
+			config.ReadFromEnv()
+			So(config.DB.Option, ShouldEqual, "file::memory:?cache=shared")
+
+			os.Unsetenv("DB_IMPL_NAME")
+			os.Unsetenv("DATABASE_URL")
+		})
+
+		Convey("Read connection pool config correctly", func() {
+			config := NewConfigurationWithKeys()
+			So(config.DB.MaxOpenConns, ShouldEqual, 10)
+			So(config.DB.MaxIdleConns, ShouldEqual, 2)
+
+			os.Setenv("DB_MAX_OPEN_CONNS", "20")
+			os.Setenv("DB_MAX_IDLE_CONNS", "5")
+			os.Setenv("DB_CONN_MAX_LIFETIME", "300")
+			os.Setenv("DB_STATEMENT_TIMEOUT", "30")
+			os.Setenv("DB_MAX_RETRIES", "5")
+
+			config.ReadFromEnv()
+			So(config.DB.MaxOpenConns, ShouldEqual, 20)
+			So(config.DB.MaxIdleConns, ShouldEqual, 5)
+			So(config.DB.ConnMaxLifetime, ShouldEqual, 300)
+			So(config.DB.StatementTimeout, ShouldEqual, 30)
+			So(config.DB.MaxRetries, ShouldEqual, 5)
+			So(config.Validate(), ShouldBeNil)
+
+			config.DB.StatementTimeout = -1
+			So(config.Validate(), ShouldNotBeNil)
+
+			os.Unsetenv("DB_MAX_OPEN_CONNS")
+			os.Unsetenv("DB_MAX_IDLE_CONNS")
+			os.Unsetenv("DB_CONN_MAX_LIFETIME")
+			os.Unsetenv("DB_STATEMENT_TIMEOUT")
+			os.Unsetenv("DB_MAX_RETRIES")
+		})
+
+		Convey("Read database replicas correctly", func() {
+			config := NewConfigurationWithKeys()
+			os.Setenv("DATABASE_REPLICA_URLS", "postgres://replica1/db,postgres://replica2/db")
+
+			config.ReadFromEnv()
+			So(config.DB.ReplicaOptions, ShouldResemble, []string{
+				"postgres://replica1/db",
+				"postgres://replica2/db",
+			})
+
+			os.Unsetenv("DATABASE_REPLICA_URLS")
+		})
+
+		Convey("Read mongo database config correctly", func() {
+			config := NewConfigurationWithKeys()
+			os.Setenv("DB_IMPL_NAME", "mongo")
+
+			config.ReadFromEnv()
+			So(config.DB.ImplName, ShouldEqual, "mongo")
+			So(config.DB.Option, ShouldEqual, "mongodb://localhost:27017")
+
+			os.Setenv("DATABASE_URL", "mongodb://db:27017/skygear")
+			config.ReadFromEnv()
+			So(config.DB.Option, ShouldEqual, "mongodb://db:27017/skygear")
+
+			os.Unsetenv("DB_IMPL_NAME")
+			os.Unsetenv("DATABASE_URL")
+		})
+
 		Convey("Read plugin config correctly", func() {
 			config := NewConfigurationWithKeys()
 			os.Setenv("PLUGINS", "CAT")
@@ -177,6 +328,45 @@ func TestRequiredConfig(t *testing.T) {
 			os.Setenv("BUG_TRANSPORT", "")
 			os.Setenv("BUG_PATH", "")
 		})
+
+		Convey("Read tenants correctly", func() {
+			config := NewConfigurationWithKeys()
+			os.Setenv("TENANTS", "acme,globex")
+			os.Setenv("TENANT_ACME_API_KEY", "acme-api-key")
+			os.Setenv("TENANT_ACME_MASTER_KEY", "acme-master-key")
+			os.Setenv("TENANT_ACME_HOSTS", "acme.example.com,api.acme.com")
+			os.Setenv("TENANT_GLOBEX_API_KEY", "globex-api-key")
+			os.Setenv("TENANT_GLOBEX_MASTER_KEY", "globex-master-key")
+
+			config.readTenants()
+			So(config.Tenants, ShouldResemble, []TenantConfig{
+				{
+					Name:      "acme",
+					APIKey:    "acme-api-key",
+					MasterKey: "acme-master-key",
+					Hosts:     []string{"acme.example.com", "api.acme.com"},
+				},
+				{
+					Name:      "globex",
+					APIKey:    "globex-api-key",
+					MasterKey: "globex-master-key",
+				},
+			})
+			So(config.Validate(), ShouldBeNil)
+
+			config.Tenants[1].APIKey = "acme-api-key"
+			So(config.Validate(), ShouldNotBeNil)
+
+			config.Tenants[1].APIKey = ""
+			So(config.Validate(), ShouldNotBeNil)
+
+			os.Setenv("TENANTS", "")
+			os.Setenv("TENANT_ACME_API_KEY", "")
+			os.Setenv("TENANT_ACME_MASTER_KEY", "")
+			os.Setenv("TENANT_ACME_HOSTS", "")
+			os.Setenv("TENANT_GLOBEX_API_KEY", "")
+			os.Setenv("TENANT_GLOBEX_MASTER_KEY", "")
+		})
 	})
 }
 
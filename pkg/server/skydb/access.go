@@ -28,6 +28,11 @@ type RecordACLEntry struct {
 	Level    RecordACLLevel `json:"level"`
 	UserID   string         `json:"user_id,omitempty"`
 	Public   bool           `json:"public,omitempty"`
+
+	// Deny makes the entry deny all accesses of the matched users,
+	// overriding the accesses granted by other entries. The level of a
+	// deny entry is ignored.
+	Deny bool `json:"deny,omitempty"`
 }
 
 // RecordACLLevel represent the operation a user granted on a resource
@@ -65,6 +70,13 @@ func NewRecordACLEntryRole(role string, level RecordACLLevel) RecordACLEntry {
 	}
 }
 
+// NewRecordACLEntryDeny returns an ACE denying the accesses of the users
+// matched by the specified ACE
+func NewRecordACLEntryDeny(ace RecordACLEntry) RecordACLEntry {
+	ace.Deny = true
+	return ace
+}
+
 // NewRecordACLEntryPublic return an ACE on public access
 func NewRecordACLEntryPublic(level RecordACLLevel) RecordACLEntry {
 	return RecordACLEntry{
@@ -74,6 +86,9 @@ func NewRecordACLEntryPublic(level RecordACLLevel) RecordACLEntry {
 }
 
 func (ace *RecordACLEntry) Accessible(authinfo *AuthInfo, level RecordACLLevel) bool {
+	if ace.Deny {
+		return false
+	}
 	if ace.Public {
 		return ace.AccessibleLevel(level)
 	}
@@ -85,7 +100,7 @@ func (ace *RecordACLEntry) Accessible(authinfo *AuthInfo, level RecordACLLevel)
 			return true
 		}
 	}
-	for _, role := range authinfo.Roles {
+	for _, role := range authinfo.EffectiveRoles() {
 		if role == ace.Role {
 			if ace.AccessibleLevel(level) {
 				return true
@@ -95,6 +110,28 @@ func (ace *RecordACLEntry) Accessible(authinfo *AuthInfo, level RecordACLLevel)
 	return false
 }
 
+// Denied returns true if the ACE is a deny entry matching the user.
+func (ace *RecordACLEntry) Denied(authinfo *AuthInfo) bool {
+	if !ace.Deny {
+		return false
+	}
+	if ace.Public {
+		return true
+	}
+	if authinfo == nil {
+		return false
+	}
+	if ace.UserID != "" && authinfo.ID == ace.UserID {
+		return true
+	}
+	for _, role := range authinfo.EffectiveRoles() {
+		if ace.Role != "" && role == ace.Role {
+			return true
+		}
+	}
+	return false
+}
+
 func (ace *RecordACLEntry) AccessibleLevel(level RecordACLLevel) bool {
 	if level == ReadLevel {
 		return true
@@ -126,6 +163,9 @@ func (acl RecordACL) Accessible(authinfo *AuthInfo, level RecordACLLevel) bool {
 
 	accessible := false
 	for _, ace := range acl {
+		if ace.Denied(authinfo) {
+			return false
+		}
 		if ace.Accessible(authinfo, level) {
 			accessible = true
 		}
@@ -134,6 +174,30 @@ func (acl RecordACL) Accessible(authinfo *AuthInfo, level RecordACLLevel) bool {
 	return accessible
 }
 
+// Merge returns a RecordACL granting the accesses granted by either acl or
+// other. Since an empty ACL grants all accesses, the result is empty if
+// either of them is empty.
+func (acl RecordACL) Merge(other RecordACL) RecordACL {
+	if len(acl) == 0 || len(other) == 0 {
+		return nil
+	}
+
+	merged := NewRecordACL(acl)
+	for _, otherACE := range other {
+		found := false
+		for _, ace := range merged {
+			if ace == otherACE {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, otherACE)
+		}
+	}
+	return merged
+}
+
 // FieldAccessMode is the intended access operation to be granted access
 type FieldAccessMode int
 
@@ -472,7 +536,7 @@ func (r FieldUserRole) Match(authinfo *AuthInfo, record *Record) bool {
 	case DynamicUserFieldUserRoleType:
 		return r.matchDynamic(authinfo, record)
 	case DefinedRoleFieldUserRoleType:
-		for _, role := range authinfo.Roles {
+		for _, role := range authinfo.EffectiveRoles() {
 			if role == r.Data {
 				return true
 			}
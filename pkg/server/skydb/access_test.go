@@ -357,3 +357,22 @@ func TestFieldUserRole(t *testing.T) {
 		})
 	})
 }
+
+func TestRecordACLMerge(t *testing.T) {
+	Convey("RecordACL Merge", t, func() {
+		readACE := NewRecordACLEntryDirect("johndoe", ReadLevel)
+		writeACE := NewRecordACLEntryRole("admin", WriteLevel)
+
+		Convey("should add missing entries", func() {
+			acl := RecordACL{readACE}
+			merged := acl.Merge(RecordACL{readACE, writeACE})
+			So(merged, ShouldResemble, RecordACL{readACE, writeACE})
+			So(acl, ShouldResemble, RecordACL{readACE})
+		})
+
+		Convey("should return empty ACL if either ACL is empty", func() {
+			So(RecordACL{readACE}.Merge(nil), ShouldBeNil)
+			So(RecordACL(nil).Merge(RecordACL{writeACE}), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,28 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "time"
+
+// Annotation is a comment attached to a record. An annotation has no
+// access control of its own; it can be read by users who can read the
+// record, and it is deleted when the record is deleted.
+type Annotation struct {
+	ID        string
+	RecordID  RecordID
+	AuthorID  string
+	Content   string
+	CreatedAt time.Time
+}
@@ -17,8 +17,6 @@ package skydb
 import (
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"github.com/skygeario/skygear-server/pkg/server/utils"
 	"github.com/skygeario/skygear-server/pkg/server/uuid"
 )
@@ -48,6 +46,10 @@ type AuthInfo struct {
 	ProviderInfo    ProviderInfo `json:"provider_info,omitempty"` // auth data for alternative methods
 	TokenValidSince *time.Time   `json:"token_valid_since,omitempty"`
 	LastSeenAt      *time.Time   `json:"last_seen_at,omitempty"`
+
+	// InheritedRoles are the roles inherited by Roles in the role
+	// hierarchy. They are not assigned to the user and are not saved.
+	InheritedRoles []string `json:"-"`
 }
 
 // AuthData contains the unique authentication data of a user
@@ -211,7 +213,7 @@ func NewProviderInfoAuthInfo(principalID string, authData map[string]interface{}
 
 // SetPassword sets the HashedPassword with the password specified
 func (info *AuthInfo) SetPassword(password string) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := PreferredPasswordHasher.Hash(password)
 	if err != nil {
 		panic("authinfo: Failed to hash password")
 	}
@@ -224,10 +226,40 @@ func (info *AuthInfo) SetPassword(password string) {
 	info.TokenValidSince = &timeNow
 }
 
+// SetPasswordHash sets the HashedPassword with a hash generated by another
+// system. The hash is verified on login and replaced by RehashPassword.
+func (info *AuthInfo) SetPasswordHash(hash PasswordHash) error {
+	hashedPassword, err := hash.Encode()
+	if err != nil {
+		return err
+	}
+	info.HashedPassword = hashedPassword
+	return nil
+}
+
 // IsSamePassword determines whether the specified password is the same
 // password as where the HashedPassword is generated from
 func (info AuthInfo) IsSamePassword(password string) bool {
-	return bcrypt.CompareHashAndPassword(info.HashedPassword, []byte(password)) == nil
+	return comparePasswordHash(info.HashedPassword, password)
+}
+
+// NeedsRehash returns true if the HashedPassword is not generated with
+// the server's preferred algorithm and parameters, such as an imported
+// hash or a hash generated before the configuration is changed.
+func (info AuthInfo) NeedsRehash() bool {
+	return len(info.HashedPassword) > 0 && PreferredPasswordHasher.NeedsRehash(info.HashedPassword)
+}
+
+// RehashPassword replaces the HashedPassword with a hash generated with
+// the server's preferred algorithm. Unlike SetPassword, issued access
+// tokens remain valid since the password itself is unchanged.
+func (info *AuthInfo) RehashPassword(password string) {
+	hashedPassword, err := PreferredPasswordHasher.Hash(password)
+	if err != nil {
+		panic("authinfo: Failed to hash password")
+	}
+
+	info.HashedPassword = hashedPassword
 }
 
 // SetProviderInfoData sets the auth data to the specified principal.
@@ -238,14 +270,25 @@ func (info *AuthInfo) SetProviderInfoData(principalID string, authData map[strin
 	info.ProviderInfo[principalID] = authData
 }
 
+// EffectiveRoles returns the roles assigned to the user and the roles
+// inherited by them.
+func (info *AuthInfo) EffectiveRoles() []string {
+	if len(info.InheritedRoles) == 0 {
+		return info.Roles
+	}
+	roles := make([]string, 0, len(info.Roles)+len(info.InheritedRoles))
+	roles = append(roles, info.Roles...)
+	return append(roles, info.InheritedRoles...)
+}
+
 // HasAnyRoles return true if authinfo belongs to one of the supplied roles
 func (info *AuthInfo) HasAnyRoles(roles []string) bool {
-	return utils.StringSliceContainAny(info.Roles, roles)
+	return utils.StringSliceContainAny(info.EffectiveRoles(), roles)
 }
 
 // HasAllRoles return true if authinfo has all roles supplied
 func (info *AuthInfo) HasAllRoles(roles []string) bool {
-	return utils.StringSliceContainAll(info.Roles, roles)
+	return utils.StringSliceContainAll(info.EffectiveRoles(), roles)
 }
 
 // GetProviderInfoData gets the auth data for the specified principal.
@@ -37,6 +37,44 @@ var ErrRoleUpdatesFailed = errors.New("skydb: Update of user roles failed")
 // cannot be found in the current container
 var ErrDeviceNotFound = errors.New("skydb: Specific device not found")
 
+// ErrRecordLocked is returned by Conn.AcquireLock if the record is
+// locked by another user and the lock has not expired.
+var ErrRecordLocked = errors.New("skydb: record is locked by another user")
+
+// ErrLockNotFound is returned by Conn.GetLock, Conn.RenewLock and
+// Conn.ReleaseLock if the record has no unexpired lock held by the
+// specified user.
+var ErrLockNotFound = errors.New("skydb: lock not found")
+
+// ErrAnnotationNotFound is returned by Conn.GetAnnotation and
+// Conn.DeleteAnnotation if the annotation does not exist.
+var ErrAnnotationNotFound = errors.New("skydb: annotation not found")
+
+// ErrTransitionNotFound is returned by Conn.DeleteTransition if the
+// transition does not exist.
+var ErrTransitionNotFound = errors.New("skydb: transition not found")
+
+// ErrReportNotFound is returned by Conn.DeleteReport if the report does
+// not exist.
+var ErrReportNotFound = errors.New("skydb: report not found")
+
+// ErrSecretNotFound is returned by Conn.GetSecret and Conn.DeleteSecret
+// if the secret does not exist.
+var ErrSecretNotFound = errors.New("skydb: secret not found")
+
+// ErrWebhookNotFound is returned by Conn.GetWebhook and Conn.DeleteWebhook
+// if the webhook does not exist.
+var ErrWebhookNotFound = errors.New("skydb: webhook not found")
+
+// ErrServiceAccountNotFound is returned by Conn.GetServiceAccount,
+// Conn.UpdateServiceAccount and Conn.DeleteServiceAccount if the service
+// account does not exist.
+var ErrServiceAccountNotFound = errors.New("skydb: service account not found")
+
+// ErrAssetNotFound is returned by Conn.RenameAsset and Conn.DeleteAsset
+// if the asset does not exist.
+var ErrAssetNotFound = errors.New("skydb: asset not found")
+
 // ErrDatabaseIsReadOnly is returned by skydb.Database if the requested
 // operation modifies the database and the database is readonly.
 var ErrDatabaseIsReadOnly = errors.New("skydb: database is read only")
@@ -118,6 +156,23 @@ type Conn interface {
 	// GetRoles returns roles of users specified by user IDs
 	GetRoles(userIDs []string) (map[string][]string, error)
 
+	// GetAllRoles returns all existing roles
+	GetAllRoles() ([]string, error)
+
+	// GetUserIDsByRoles returns the IDs of the users assigned any of
+	// the specified roles.
+	GetUserIDsByRoles(roles []string) ([]string, error)
+
+	// EnsureRoles creates the supplied roles if they do not exist
+	EnsureRoles(roles []string) error
+
+	// GetRoleHierarchy returns the roles inherited directly by each role
+	GetRoleHierarchy() (RoleHierarchy, error)
+
+	// SetRoleInheritance replaces the roles inherited directly by the
+	// role, the roles are created if they do not exist
+	SetRoleInheritance(role string, inheritedRoles []string) error
+
 	// SetRecordAccess sets default record access of a specific type
 	SetRecordAccess(recordType string, acl RecordACL) error
 
@@ -136,6 +191,21 @@ type Conn interface {
 	// GetRecordFieldAccess retrieve field ACL setting
 	GetRecordFieldAccess() (FieldACL, error)
 
+	// SetRecordSystemFieldAccess sets the masks of system fields of a
+	// specific type
+	SetRecordSystemFieldAccess(recordType string, masks SystemFieldMasks) error
+
+	// GetRecordSystemFieldAccess returns the system field access of all
+	// types, keyed by record type
+	GetRecordSystemFieldAccess() (map[string]SystemFieldAccess, error)
+
+	// SetRecordStrictSchema turns strict schema mode of a specific type
+	// on or off. The type AllRecordTypes applies to every type.
+	SetRecordStrictSchema(recordType string, strict bool) error
+
+	// GetRecordStrictSchema returns the record types in strict schema mode
+	GetRecordStrictSchema() (StrictSchema, error)
+
 	// GetAsset retrieves Asset information by its name
 	GetAsset(name string, asset *Asset) error
 
@@ -145,11 +215,219 @@ type Conn interface {
 	// be referenced by records.
 	SaveAsset(asset *Asset) error
 
+	// GetAssetNames returns the names of the assets starting with the
+	// prefix, sorted by name.
+	GetAssetNames(prefix string) ([]string, error)
+
+	// RenameAsset renames an Asset, and updates the asset fields of
+	// records referencing the asset to the new name.
+	RenameAsset(oldName, newName string) error
+
+	// DeleteAsset deletes the information of an Asset. It returns an
+	// error with skyerr.ConstraintViolated if the asset is referenced by
+	// records.
+	DeleteAsset(name string) error
+
 	QueryRelation(user string, name string, direction string, config QueryConfig) []AuthInfo
 	QueryRelationCount(user string, name string, direction string) (uint64, error)
 	AddRelation(user string, name string, targetUser string) error
 	RemoveRelation(user string, name string, targetUser string) error
 
+	// IncrementCounter atomically adds delta to the counter of the
+	// specified name and returns the value of the counter after the
+	// increment.
+	//
+	// Implementations may spread a counter over several rows so that
+	// concurrent increments of a hot counter do not wait on the same
+	// row lock.
+	IncrementCounter(name string, delta int64) (int64, error)
+
+	// GetCounter returns the current value of the counter of the specified
+	// name. A counter that was never incremented has a value of zero.
+	GetCounter(name string) (int64, error)
+
+	// AcquireLock locks the record for the owner until ttl elapses. If the
+	// record is already locked by the owner, the lock is extended.
+	//
+	// If the record is locked by another user, the current lock is
+	// returned together with ErrRecordLocked.
+	AcquireLock(recordID RecordID, ownerID string, ttl time.Duration) (*Lock, error)
+
+	// RenewLock extends the lock of the record held by the owner until
+	// ttl elapses. ErrLockNotFound is returned if the owner does not
+	// hold the lock, for example when the lock has expired.
+	RenewLock(recordID RecordID, ownerID string, ttl time.Duration) (*Lock, error)
+
+	// ReleaseLock releases the lock of the record held by the owner.
+	ReleaseLock(recordID RecordID, ownerID string) error
+
+	// GetLock returns the unexpired lock of the record.
+	GetLock(recordID RecordID) (*Lock, error)
+
+	// AddAnnotation saves a new annotation of a record.
+	AddAnnotation(annotation *Annotation) error
+
+	// GetAnnotation returns the annotation of the specified ID.
+	GetAnnotation(id string) (*Annotation, error)
+
+	// GetAnnotations returns at most limit annotations of the record,
+	// ordered by creation time, skipping the first offset annotations.
+	GetAnnotations(recordID RecordID, offset uint64, limit uint64) ([]Annotation, error)
+
+	// DeleteAnnotation removes the annotation of the specified ID.
+	DeleteAnnotation(id string) error
+
+	// AddReaction saves the reaction of a user to a record and
+	// increments the reaction count of the record. It returns false
+	// without changing the count if the user has already reacted to the
+	// record with the same reaction type.
+	AddReaction(reaction *Reaction) (bool, error)
+
+	// RemoveReaction removes the reaction of a user to a record of the
+	// database and decrements the reaction count of the record. It
+	// returns false if the user has not reacted to the record with the
+	// reaction type.
+	RemoveReaction(databaseID string, recordID RecordID, userID string, reactionType string) (bool, error)
+
+	// GetReactionCounts returns the reaction counts of the record of the
+	// database.
+	GetReactionCounts(databaseID string, recordID RecordID) (ReactionCounts, error)
+
+	// GetUserReactions returns the reaction types the user reacted to
+	// the record of the database with.
+	GetUserReactions(databaseID string, recordID RecordID, userID string) ([]string, error)
+
+	// IncrementUnreadCounts increments the unread count of the channel
+	// of each user by one. userIDs must not contain duplicates.
+	IncrementUnreadCounts(userIDs []string, channel string) error
+
+	// GetUnreadCounts returns the unread counts of the user.
+	GetUnreadCounts(userID string) (UnreadCounts, error)
+
+	// ResetUnreadCounts resets the unread count of the channel of the
+	// user to zero, or of all channels if channel is empty.
+	ResetUnreadCounts(userID string, channel string) error
+
+	// ScrubRecords rewrites the fields of all records of the record type
+	// in all databases with the scrub methods, and returns the number of
+	// records rewritten. The record metadata is not changed.
+	ScrubRecords(recordType string, fields map[string]ScrubMethod, salt string) (int64, error)
+
+	// ScrubSystemTables rewrites the personal data kept in the system
+	// tables: the OAuth profiles of users, the fields of the record
+	// types in the record history with the scrub methods, and the
+	// content of annotations. It returns the number of rows rewritten
+	// of each table.
+	ScrubSystemTables(recordTypes map[string]map[string]ScrubMethod, salt string) (map[string]int64, error)
+
+	// ReplaceURLPrefix replaces oldPrefix with newPrefix in the values of
+	// the string fields of all records of the record type in all
+	// databases, and returns the number of records rewritten. Values not
+	// starting with oldPrefix are not changed.
+	ReplaceURLPrefix(recordType string, fields []string, oldPrefix, newPrefix string) (int64, error)
+
+	// RebalancePositions replaces the values of a position field of
+	// records of the record type with evenly spaced positions in the
+	// same order, if any of the values is longer than maxLength.
+	// It returns true if the values are replaced.
+	RebalancePositions(recordType string, field string, maxLength int) (bool, error)
+
+	// ScheduleTransition saves a transition to be executed at the
+	// scheduled time.
+	ScheduleTransition(transition *Transition) error
+
+	// GetTransitions returns the transitions of the record which are
+	// not yet executed, ordered by the scheduled time.
+	GetTransitions(recordID RecordID) ([]Transition, error)
+
+	// ClaimDueTransitions claims at most limit transitions scheduled at
+	// or before the specified time, ordered by the scheduled time.
+	//
+	// A claimed transition is not returned again until the lease has
+	// passed, so that a transition is executed by one server at a time,
+	// and a transition not deleted after a failure is retried.
+	ClaimDueTransitions(before time.Time, lease time.Duration, limit int) ([]Transition, error)
+
+	// DeleteTransition removes the transition, so that it will not
+	// be executed.
+	DeleteTransition(id string) error
+
+	// SaveReport creates the report or replaces the definition of an
+	// existing report with the same ID.
+	SaveReport(report *Report) error
+
+	// GetReports returns all reports ordered by ID.
+	GetReports() ([]Report, error)
+
+	// ClaimReportRun sets the last run time of the report to runAt if
+	// the report has not run at or after runAt. It returns false if the
+	// run is claimed already, so that each scheduled run of a report
+	// is executed by one server only.
+	ClaimReportRun(id string, runAt time.Time) (bool, error)
+
+	// DeleteReport removes the report, so that it will not run again.
+	DeleteReport(id string) error
+
+	// GetSecret returns the secret of the specified name.
+	GetSecret(name string) (*Secret, error)
+
+	// GetSecrets returns all secrets ordered by name.
+	GetSecrets() ([]Secret, error)
+
+	// SetSecret creates or replaces the secret of the same name.
+	SetSecret(secret *Secret) error
+
+	// DeleteSecret removes the secret of the specified name.
+	DeleteSecret(name string) error
+
+	// GetWebhook returns the webhook of the specified ID.
+	GetWebhook(id string) (*Webhook, error)
+
+	// GetWebhooks returns all webhooks ordered by creation time.
+	GetWebhooks() ([]Webhook, error)
+
+	// SaveWebhook creates or updates the webhook. The delivery
+	// statistics are not saved, except that the consecutive failures
+	// are reset if the webhook is not disabled.
+	SaveWebhook(webhook *Webhook) error
+
+	// DeleteWebhook removes the webhook of the specified ID.
+	DeleteWebhook(id string) error
+
+	// RecordWebhookDelivery updates the delivery statistics of the
+	// webhook. deliveryErr is empty if the delivery succeeded. The
+	// webhook is disabled if it fails consecutively for
+	// failureThreshold times, and true is returned if it is disabled.
+	// A zero failureThreshold never disables the webhook.
+	RecordWebhookDelivery(id string, deliveryErr string, at time.Time, failureThreshold int) (bool, error)
+
+	// CreateServiceAccount saves a new service account. The AuthInfo of
+	// the service account must be created before.
+	CreateServiceAccount(account *ServiceAccount) error
+
+	// GetServiceAccount returns the service account of the specified ID.
+	GetServiceAccount(id string) (*ServiceAccount, error)
+
+	// GetServiceAccounts returns all service accounts ordered by
+	// creation time.
+	GetServiceAccounts() ([]ServiceAccount, error)
+
+	// UpdateServiceAccount updates the name, credentials and last used
+	// time of the service account.
+	UpdateServiceAccount(account *ServiceAccount) error
+
+	// DeleteServiceAccount removes the service account of the specified
+	// ID. Its AuthInfo is not removed.
+	DeleteServiceAccount(id string) error
+
+	// GetMaintenance returns the maintenance mode of the app. The
+	// maintenance mode is disabled if it was never set.
+	GetMaintenance() (*Maintenance, error)
+
+	// SetMaintenance saves the maintenance mode of the app, which is
+	// shared by all servers of the app.
+	SetMaintenance(maintenance *Maintenance) error
+
 	GetDevice(id string, device *Device) error
 
 	// QueryDevicesByUser queries the Device database which are registered
@@ -225,7 +503,12 @@ const (
 // For RecordCreated or RecordUpdated event, Record is the newly
 // created / updated Record. For RecordDeleted, Record is the Record
 // being deleted.
+//
+// For RecordUpdated event, Patch is the JSON merge patch of the data of
+// the Record from before the update, which is nil if the Record before
+// the update is unknown.
 type RecordEvent struct {
 	Record *Record
 	Event  RecordHookEvent
+	Patch  map[string]interface{}
 }
@@ -0,0 +1,46 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCursor(t *testing.T) {
+	Convey("Cursor", t, func() {
+		Convey("encodes and decodes", func() {
+			cursor := Cursor{
+				Values: []interface{}{"title", float64(1)},
+				Key:    "note1",
+			}
+
+			decoded, err := DecodeCursor(cursor.Encode())
+			So(err, ShouldBeNil)
+			So(*decoded, ShouldResemble, cursor)
+		})
+
+		Convey("returns error on malformed cursor", func() {
+			_, err := DecodeCursor("not a cursor")
+			So(err, ShouldEqual, ErrInvalidCursor)
+		})
+
+		Convey("returns error on cursor without key", func() {
+			_, err := DecodeCursor(Cursor{}.Encode())
+			So(err, ShouldEqual, ErrInvalidCursor)
+		})
+	})
+}
@@ -25,6 +25,10 @@ import (
 // cannot find the Record by the specified key
 var ErrRecordNotFound = errors.New("skydb: Record not found for the specified key")
 
+// ErrRecordConflict is returned by Database.Save if the record has been
+// saved since the revision specified by Record.BaseRevision.
+var ErrRecordConflict = errors.New("skydb: record has been modified since the base revision")
+
 // EmptyRows is a convenient variable that acts as an empty Rows.
 // Useful for skydb implementators and testing.
 var EmptyRows = NewRows(emptyRowsIter(0))
@@ -43,6 +47,14 @@ func (rs emptyRowsIter) OverallRecordCount() *uint64 {
 	return nil
 }
 
+func (rs emptyRowsIter) IncludedRecords() map[string]map[string]*Record {
+	return nil
+}
+
+func (rs emptyRowsIter) NextCursor() *Cursor {
+	return nil
+}
+
 var ErrDatabaseTxDidBegin = errors.New("skydb: a transaction has already begun")
 var ErrDatabaseTxDidNotBegin = errors.New("skydb: a transaction has not begun")
 var ErrDatabaseTxDone = errors.New("skydb: Database's transaction has already committed or rolled back")
@@ -111,10 +123,35 @@ type Database interface {
 	// Save updates the supplied Record in the Database if Record with
 	// the same key exists, else such Record is created.
 	//
+	// The revision of the Record is incremented on every save. If
+	// BaseRevision is not zero, Save returns ErrRecordConflict unless
+	// the existing Record has that revision.
+	//
+	// Increment values in the Record are added to the stored numbers,
+	// and are replaced with the stored numbers after saving.
+	//
 	// Save returns an error if the underlying implementation failed to
 	// create / modify the Record.
 	Save(record *Record) error
 
+	// Merge updates the fields in the supplied Record of the existing
+	// Record with the same key in a single statement, leaving the other
+	// fields unchanged, and writes the updated Record onto the supplied
+	// Record. Unlike Save, the Record is not created if it does not exist.
+	//
+	// Merge returns an ErrRecordNotFound if the Record does not exist,
+	// and an ErrRecordConflict if BaseRevision is not zero and the
+	// existing Record does not have that revision.
+	Merge(record *Record) error
+
+	// SaveBulk inserts the records into the Database in bulk, which is
+	// much faster than saving them one by one for a large import.
+	//
+	// The records must not exist in the Database, SaveBulk returns an
+	// error with skyerr.Duplicated if any of them exists. Either all or
+	// none of the records are inserted.
+	SaveBulk(records []*Record) error
+
 	// Delete removes the Record identified by the key in the Database.
 	//
 	// Delete returns an ErrRecordNotFound if the Record identified by
@@ -127,6 +164,15 @@ type Database interface {
 	// an Rows to iterate the results.
 	Query(query *Query) (*Rows, error)
 
+	// QueryStream is like Query, but the records are fetched from the
+	// Database incrementally while iterating the Rows, instead of being
+	// fetched all at once. It is intended for exporting a large number
+	// of records.
+	//
+	// Query.Includes, Query.PageSize and Query.GetCount are not supported
+	// by QueryStream. The Rows must be closed after iterating.
+	QueryStream(query *Query) (*Rows, error)
+
 	// QueryCount executes the supplied query against the Database and returns
 	// the number of records matching the query's predicate.
 	QueryCount(query *Query) (uint64, error)
@@ -151,6 +197,15 @@ type Database interface {
 	// FetchRecordTypes returns a list of all existing record type
 	GetRecordSchemas() (map[string]RecordSchema, error)
 
+	// SchemaVersions returns the version of the last schema migration
+	// applied to each record type.
+	SchemaVersions() (map[string]string, error)
+
+	// MigrateSchema applies the operations of the schema migration to
+	// the record type and records the version of the migration, in a
+	// single transaction.
+	MigrateSchema(migration SchemaMigration) error
+
 	GetSubscription(key string, deviceID string, subscription *Subscription) error
 	SaveSubscription(subscription *Subscription) error
 	DeleteSubscription(key string, deviceID string) error
@@ -160,6 +215,41 @@ type Database interface {
 	GetIndexesByRecordType(recordType string) (indexes map[string]Index, err error)
 	SaveIndex(recordType, indexName string, index Index) error
 	DeleteIndex(recordType string, indexName string) error
+
+	// CreateIndex creates an index of the name on fields of the record
+	// type.
+	CreateIndex(recordType, indexName string, index RecordIndex) error
+
+	// DropIndex drops the index of the name on the record type.
+	//
+	// DropIndex returns an ErrIndexNotFound if the index does not exist
+	// on the record type.
+	DropIndex(recordType, indexName string) error
+
+	// ListIndexes returns the indexes on fields of the record type by
+	// name.
+	ListIndexes(recordType string) (map[string]RecordIndex, error)
+
+	// FindDuplicates returns groups of records of the specified record
+	// type that are candidate duplicates of each other. Two records are
+	// candidate duplicates if all the keys match. At most limit pairs
+	// of candidate duplicates are considered.
+	FindDuplicates(recordType string, keys []DuplicateKey, limit int) ([][]RecordID, error)
+
+	// Leaderboard returns the top ranked records and the rank of the
+	// user of the query. Records without a score are not ranked.
+	Leaderboard(query LeaderboardQuery) (*Leaderboard, error)
+
+	// MergeRecords merges the losers into the winner. The ACL entries of
+	// the losers are added to the winner, references to the losers are
+	// rewritten to reference the winner, and the losers are archived
+	// and removed.
+	MergeRecords(winnerID RecordID, loserIDs []RecordID) error
+
+	// GetRecordHistory returns at most limit entries of the change history
+	// of the record, newest first. Changes are recorded only for record
+	// types with history enabled.
+	GetRecordHistory(id RecordID, limit int) ([]RecordHistory, error)
 }
 
 // Transactional defines the methods for a persistence storage that supports
@@ -270,6 +360,32 @@ func (r *Rows) OverallRecordCount() *uint64 {
 	return r.iter.OverallRecordCount()
 }
 
+// IncludedRecords returns the records referenced by fields specified in
+// Query.Includes, which are loaded together with the scanned records.
+//
+// The returned map is keyed by the included field name, then by the
+// key of the referenced record.
+func (r *Rows) IncludedRecords() map[string]map[string]*Record {
+	return r.iter.IncludedRecords()
+}
+
+// NextCursor returns the cursor of the next page of a query paginated
+// by keyset. Returns nil if there are no more records.
+//
+// It must be called after Scan returned false.
+func (r *Rows) NextCursor() *Cursor {
+	return r.iter.NextCursor()
+}
+
+// QueryPlan returns the query plan of a query with Query.Explain set.
+// Returns nil if the Database does not support explaining queries.
+func (r *Rows) QueryPlan() interface{} {
+	if planner, ok := r.iter.(QueryPlanner); ok {
+		return planner.QueryPlan()
+	}
+	return nil
+}
+
 // Err returns the last error encountered during Scan.
 //
 // NOTE: It is not an error if the underlying result set is exhausted.
@@ -293,6 +409,24 @@ type RowsIter interface {
 	Next(record *Record) error
 
 	OverallRecordCount() *uint64
+
+	// IncludedRecords returns the records referenced by fields specified
+	// in Query.Includes, of all records populated by Next so far.
+	// Returns nil if the query has nothing included.
+	IncludedRecords() map[string]map[string]*Record
+
+	// NextCursor returns the cursor of the next page of a query
+	// paginated by keyset after all rows are populated by Next.
+	// Returns nil if there are no more rows.
+	NextCursor() *Cursor
+}
+
+// QueryPlanner is implemented by a RowsIter which can return the plan
+// of the executed query.
+type QueryPlanner interface {
+	// QueryPlan returns the query plan, or nil if the query is not
+	// explained.
+	QueryPlan() interface{}
 }
 
 // MemoryRows is a native implementation of RowIter.
@@ -327,3 +461,11 @@ func (rs *MemoryRows) OverallRecordCount() *uint64 {
 	}
 	return &result
 }
+
+func (rs *MemoryRows) IncludedRecords() map[string]map[string]*Record {
+	return nil
+}
+
+func (rs *MemoryRows) NextCursor() *Cursor {
+	return nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+// DuplicateKey specifies a field to compare when finding duplicate
+// records.
+//
+// If Similarity is zero, two records are candidate duplicates only if the
+// field values are equal. Otherwise, the field values are compared by
+// trigram similarity and two records are candidate duplicates if the
+// similarity is at least Similarity, which should be in (0, 1].
+type DuplicateKey struct {
+	Field      string
+	Similarity float64
+}
+
+// IsFuzzy returns true if the key compares field values by similarity.
+func (key DuplicateKey) IsFuzzy() bool {
+	return key.Similarity > 0
+}
@@ -0,0 +1,127 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failover
+
+import (
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// NewReadOnlyConn returns a skydb.Conn whose databases reject record
+// writes with skydb.ErrDatabaseIsReadOnly.
+func NewReadOnlyConn(c skydb.Conn) skydb.Conn {
+	return &conn{c}
+}
+
+type conn struct {
+	skydb.Conn
+}
+
+func (c *conn) PublicDB() skydb.Database {
+	return NewReadOnlyDatabase(c.Conn.PublicDB())
+}
+
+func (c *conn) PrivateDB(userKey string) skydb.Database {
+	return NewReadOnlyDatabase(c.Conn.PrivateDB(userKey))
+}
+
+func (c *conn) UnionDB() skydb.Database {
+	return NewReadOnlyDatabase(c.Conn.UnionDB())
+}
+
+// NewReadOnlyDatabase returns a skydb.Database rejecting writes of
+// records, schemas, subscriptions and indexes.
+//
+// The returned Database is also a skydb.Transactional if the specified
+// Database is.
+func NewReadOnlyDatabase(db skydb.Database) skydb.Database {
+	readOnlyDB := &database{db}
+	if txDB, ok := db.(skydb.Transactional); ok {
+		return &txDatabase{readOnlyDB, txDB}
+	}
+	return readOnlyDB
+}
+
+type database struct {
+	skydb.Database
+}
+
+type txDatabase struct {
+	*database
+	skydb.Transactional
+}
+
+func (db *database) IsReadOnly() bool {
+	return true
+}
+
+func (db *database) Save(record *skydb.Record) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) Merge(record *skydb.Record) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) SaveBulk(records []*skydb.Record) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) Delete(id skydb.RecordID) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) Extend(recordType string, schema skydb.RecordSchema) (bool, error) {
+	return false, skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) RenameSchema(recordType, oldColumnName, newColumnName string) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) DeleteSchema(recordType, columnName string) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) MigrateSchema(migration skydb.SchemaMigration) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) SaveSubscription(subscription *skydb.Subscription) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) DeleteSubscription(key string, deviceID string) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) SaveIndex(recordType, indexName string, index skydb.Index) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) DeleteIndex(recordType string, indexName string) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) CreateIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) DropIndex(recordType, indexName string) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
+
+func (db *database) MergeRecords(winnerID skydb.RecordID, loserIDs []skydb.RecordID) error {
+	return skydb.ErrDatabaseIsReadOnly
+}
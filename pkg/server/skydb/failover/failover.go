@@ -0,0 +1,304 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package failover makes the server aware of a standby database in
+// another region, which is a replica of the primary database. The
+// primary database is health-checked periodically, and the server fails
+// over to the standby database when the primary database is down, either
+// manually or automatically.
+//
+// The standby database is promoted by one server only. A server holds
+// the promotion lock on the standby database while promoting it, and the
+// other servers switch to the standby database after it is promoted.
+//
+// After failing over, record writes are rejected for a grace period, so
+// that the promoted standby database can catch up with the replication
+// before accepting writes.
+package failover
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+)
+
+var log = logging.LoggerEntry("failover")
+
+var timeNow = func() time.Time { return time.Now().UTC() }
+
+// ErrNoStandby is returned by Failover if no standby database is
+// configured.
+var ErrNoStandby = errors.New("failover: standby database is not configured")
+
+// ErrFailedOver is returned by Failover if the server has already failed
+// over to the standby database.
+var ErrFailedOver = errors.New("failover: already failed over to standby database")
+
+// ErrFailingOver is returned by Failover if the standby database is being
+// promoted, by this server or another server.
+var ErrFailingOver = errors.New("failover: standby database is being promoted")
+
+// ErrPrimaryRecovered is returned by automatic failover if the primary
+// database responds again when the promotion lock is acquired.
+var ErrPrimaryRecovered = errors.New("failover: primary database recovered")
+
+// Prober checks and promotes database servers identified by connection
+// options.
+type Prober interface {
+	// Ping returns an error if the database cannot be connected.
+	Ping(option string) error
+
+	// ReplicationLag returns the time since the last transaction
+	// replayed from the primary database.
+	ReplicationLag(option string) (time.Duration, error)
+
+	// LockPromotion acquires the promotion lock of a replica, which is
+	// shared by all servers using the replica. It returns false if the
+	// lock is held by another server. The returned function releases the
+	// lock.
+	LockPromotion(option string) (unlock func(), ok bool, err error)
+
+	// Promote promotes a replica to accept writes. It is a no-op if the
+	// database is not a replica.
+	Promote(option string) error
+}
+
+// Region is the region of the database the server is using.
+type Region string
+
+// List of regions.
+const (
+	Primary Region = "primary"
+	Standby Region = "standby"
+)
+
+// Status is the replication status reported to operators.
+type Status struct {
+	Active         Region     `json:"active"`
+	PrimaryHealthy bool       `json:"primary_healthy"`
+	PrimaryError   string     `json:"primary_error,omitempty"`
+	ReadOnly       bool       `json:"read_only"`
+	FailedOverAt   *time.Time `json:"failed_over_at,omitempty"`
+	ReplicationLag *float64   `json:"replication_lag_s,omitempty"`
+}
+
+// Manager selects the database to be used by the server. It is safe for
+// concurrent use.
+type Manager struct {
+	PrimaryOption string
+	StandbyOption string
+	Prober        Prober
+
+	// AutoFailover enables failing over automatically after the primary
+	// database fails FailureThreshold consecutive health checks.
+	AutoFailover     bool
+	FailureThreshold int
+
+	// GracePeriod is the duration record writes are rejected after
+	// failing over.
+	GracePeriod time.Duration
+
+	mutex        sync.RWMutex
+	failedOver   bool
+	failingOver  bool
+	failedOverAt time.Time
+	failures     int
+	primaryErr   error
+	lag          time.Duration
+	lagErr       error
+}
+
+// NewManager returns a Manager using the primary database. Failover is
+// disabled if standbyOption is empty.
+func NewManager(primaryOption string, standbyOption string, prober Prober) *Manager {
+	return &Manager{
+		PrimaryOption:    primaryOption,
+		StandbyOption:    standbyOption,
+		Prober:           prober,
+		FailureThreshold: 3,
+	}
+}
+
+// Enabled returns whether a standby database is configured.
+func (m *Manager) Enabled() bool {
+	return m != nil && m.StandbyOption != ""
+}
+
+// Option returns the connection option of the database in use.
+func (m *Manager) Option() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.failedOver {
+		return m.StandbyOption
+	}
+	return m.PrimaryOption
+}
+
+// ReadOnly returns whether record writes should be rejected because the
+// server failed over within the grace period.
+func (m *Manager) ReadOnly() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.failedOver && timeNow().Before(m.failedOverAt.Add(m.GracePeriod))
+}
+
+// Check health-checks the primary database and measures the replication
+// lag of the standby database. It fails over automatically if enabled and
+// the primary database is down.
+func (m *Manager) Check() {
+	if !m.Enabled() {
+		return
+	}
+
+	primaryErr := m.Prober.Ping(m.PrimaryOption)
+
+	m.mutex.RLock()
+	failedOver := m.failedOver
+	m.mutex.RUnlock()
+
+	var lag time.Duration
+	var lagErr error
+	if !failedOver {
+		lag, lagErr = m.Prober.ReplicationLag(m.StandbyOption)
+	}
+
+	m.mutex.Lock()
+	m.primaryErr = primaryErr
+	m.lag, m.lagErr = lag, lagErr
+	if primaryErr != nil {
+		m.failures++
+	} else {
+		m.failures = 0
+	}
+	shouldFailover := m.AutoFailover && !m.failedOver && m.failures >= m.FailureThreshold
+	m.mutex.Unlock()
+
+	if primaryErr != nil {
+		log.WithField("error", primaryErr).Warnln("Primary database failed health check")
+	}
+	if lagErr != nil {
+		log.WithField("error", lagErr).Warnln("Unable to measure replication lag of standby database")
+	}
+
+	if shouldFailover {
+		log.Warnf("Primary database failed %d consecutive health checks, failing over", m.FailureThreshold)
+		err := m.failover(true)
+		if err == ErrFailingOver || err == ErrPrimaryRecovered {
+			log.WithField("error", err).Infoln("Skipped failing over to standby database")
+		} else if err != nil {
+			log.WithField("error", err).Errorln("Failed to fail over to standby database")
+		}
+	}
+}
+
+// Run health-checks the databases at the specified interval. It never
+// returns.
+func (m *Manager) Run(interval time.Duration) {
+	for {
+		m.Check()
+		time.Sleep(interval)
+	}
+}
+
+// Failover promotes the standby database and switches the server to
+// use it.
+func (m *Manager) Failover() error {
+	return m.failover(false)
+}
+
+// failover promotes the standby database while holding its promotion
+// lock, so that it is promoted by one server only. If checkPrimary is
+// true, the failover is aborted if the primary database responds again,
+// because the server holding the lock may be the only one which sees the
+// primary database down.
+func (m *Manager) failover(checkPrimary bool) error {
+	if !m.Enabled() {
+		return ErrNoStandby
+	}
+
+	m.mutex.Lock()
+	if m.failedOver {
+		m.mutex.Unlock()
+		return ErrFailedOver
+	}
+	if m.failingOver {
+		m.mutex.Unlock()
+		return ErrFailingOver
+	}
+	m.failingOver = true
+	m.mutex.Unlock()
+
+	err := m.promote(checkPrimary)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.failingOver = false
+	if err != nil {
+		return err
+	}
+
+	m.failedOver = true
+	m.failedOverAt = timeNow()
+	log.Warnf("Failed over to standby database, record writes are rejected until %v", m.failedOverAt.Add(m.GracePeriod))
+	return nil
+}
+
+func (m *Manager) promote(checkPrimary bool) error {
+	unlock, ok, err := m.Prober.LockPromotion(m.StandbyOption)
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrFailingOver
+	}
+	defer unlock()
+
+	if checkPrimary {
+		if err := m.Prober.Ping(m.PrimaryOption); err == nil {
+			return ErrPrimaryRecovered
+		}
+	}
+
+	return m.Prober.Promote(m.StandbyOption)
+}
+
+// Status returns the replication status.
+func (m *Manager) Status() Status {
+	readOnly := m.ReadOnly()
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	status := Status{
+		Active:         Primary,
+		PrimaryHealthy: m.primaryErr == nil,
+		ReadOnly:       readOnly,
+	}
+	if m.primaryErr != nil {
+		status.PrimaryError = m.primaryErr.Error()
+	}
+	if m.failedOver {
+		status.Active = Standby
+		failedOverAt := m.failedOverAt
+		status.FailedOverAt = &failedOverAt
+	} else if m.lagErr == nil {
+		lag := m.lag.Seconds()
+		status.ReplicationLag = &lag
+	}
+	return status
+}
@@ -0,0 +1,220 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failover
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeProber struct {
+	down     map[string]bool
+	lag      time.Duration
+	locked   bool
+	promoted []string
+
+	// onPromote is called on promoting, when the promotion lock is held.
+	onPromote func()
+}
+
+func (p *fakeProber) Ping(option string) error {
+	if p.down[option] {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (p *fakeProber) ReplicationLag(option string) (time.Duration, error) {
+	return p.lag, nil
+}
+
+func (p *fakeProber) LockPromotion(option string) (func(), bool, error) {
+	if p.locked {
+		return nil, false, nil
+	}
+	p.locked = true
+	return func() { p.locked = false }, true, nil
+}
+
+func (p *fakeProber) Promote(option string) error {
+	if p.onPromote != nil {
+		p.onPromote()
+	}
+	p.promoted = append(p.promoted, option)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	Convey("Manager", t, func() {
+		realTimeNow := timeNow
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+		defer func() {
+			timeNow = realTimeNow
+		}()
+
+		prober := &fakeProber{
+			down: map[string]bool{},
+			lag:  1500 * time.Millisecond,
+		}
+		m := NewManager("primary-dsn", "standby-dsn", prober)
+		m.GracePeriod = time.Minute
+
+		Convey("uses primary database", func() {
+			m.Check()
+			So(m.Option(), ShouldEqual, "primary-dsn")
+			So(m.ReadOnly(), ShouldBeFalse)
+
+			lag := 1.5
+			So(m.Status(), ShouldResemble, Status{
+				Active:         Primary,
+				PrimaryHealthy: true,
+				ReplicationLag: &lag,
+			})
+		})
+
+		Convey("fails over manually", func() {
+			So(m.Failover(), ShouldBeNil)
+			So(prober.promoted, ShouldResemble, []string{"standby-dsn"})
+			So(m.Option(), ShouldEqual, "standby-dsn")
+			So(m.ReadOnly(), ShouldBeTrue)
+
+			status := m.Status()
+			So(status.Active, ShouldEqual, Standby)
+			So(*status.FailedOverAt, ShouldResemble, now)
+			So(status.ReplicationLag, ShouldBeNil)
+
+			So(m.Failover(), ShouldEqual, ErrFailedOver)
+		})
+
+		Convey("does not fail over when another server holds the promotion lock", func() {
+			prober.locked = true
+			So(m.Failover(), ShouldEqual, ErrFailingOver)
+			So(prober.promoted, ShouldBeEmpty)
+			So(m.Option(), ShouldEqual, "primary-dsn")
+
+			prober.locked = false
+			So(m.Failover(), ShouldBeNil)
+			So(m.Option(), ShouldEqual, "standby-dsn")
+		})
+
+		Convey("does not hold the mutex while promoting", func() {
+			prober.onPromote = func() {
+				So(m.Option(), ShouldEqual, "primary-dsn")
+				So(m.Failover(), ShouldEqual, ErrFailingOver)
+			}
+			So(m.Failover(), ShouldBeNil)
+			So(prober.locked, ShouldBeFalse)
+			So(prober.promoted, ShouldResemble, []string{"standby-dsn"})
+		})
+
+		Convey("accepts writes after grace period", func() {
+			So(m.Failover(), ShouldBeNil)
+			now = now.Add(time.Minute)
+			So(m.ReadOnly(), ShouldBeFalse)
+		})
+
+		Convey("reports unhealthy primary without auto failover", func() {
+			prober.down["primary-dsn"] = true
+			for i := 0; i < 5; i++ {
+				m.Check()
+			}
+			So(m.Option(), ShouldEqual, "primary-dsn")
+
+			status := m.Status()
+			So(status.PrimaryHealthy, ShouldBeFalse)
+			So(status.PrimaryError, ShouldEqual, "connection refused")
+		})
+
+		Convey("fails over automatically after threshold", func() {
+			m.AutoFailover = true
+			prober.down["primary-dsn"] = true
+
+			m.Check()
+			m.Check()
+			So(m.Option(), ShouldEqual, "primary-dsn")
+
+			m.Check()
+			So(m.Option(), ShouldEqual, "standby-dsn")
+			So(prober.promoted, ShouldResemble, []string{"standby-dsn"})
+		})
+
+		Convey("does not fail over automatically when promotion lock is held", func() {
+			m.AutoFailover = true
+			prober.down["primary-dsn"] = true
+			prober.locked = true
+			m.Check()
+			m.Check()
+			m.Check()
+			So(m.Option(), ShouldEqual, "primary-dsn")
+			So(prober.promoted, ShouldBeEmpty)
+
+			prober.locked = false
+			m.Check()
+			So(m.Option(), ShouldEqual, "standby-dsn")
+		})
+
+		Convey("resets failures when primary recovers", func() {
+			m.AutoFailover = true
+			prober.down["primary-dsn"] = true
+			m.Check()
+			m.Check()
+			prober.down["primary-dsn"] = false
+			m.Check()
+			prober.down["primary-dsn"] = true
+			m.Check()
+			So(m.Option(), ShouldEqual, "primary-dsn")
+		})
+
+		Convey("is disabled without standby", func() {
+			m := NewManager("primary-dsn", "", prober)
+			So(m.Enabled(), ShouldBeFalse)
+			So(m.Failover(), ShouldEqual, ErrNoStandby)
+		})
+	})
+}
+
+func TestReadOnlyDatabase(t *testing.T) {
+	Convey("ReadOnlyDatabase", t, func() {
+		mapDB := skydbtest.NewMapDB()
+		record := skydb.Record{
+			ID:   skydb.NewRecordID("note", "1"),
+			Data: skydb.Data{"title": "hello"},
+		}
+		So(mapDB.Save(&record), ShouldBeNil)
+
+		db := NewReadOnlyDatabase(mapDB)
+
+		Convey("reads records", func() {
+			fetched := skydb.Record{}
+			So(db.Get(record.ID, &fetched), ShouldBeNil)
+			So(fetched.Data["title"], ShouldEqual, "hello")
+		})
+
+		Convey("rejects writes", func() {
+			So(db.IsReadOnly(), ShouldBeTrue)
+			So(db.Save(&record), ShouldEqual, skydb.ErrDatabaseIsReadOnly)
+			So(db.SaveBulk([]*skydb.Record{&record}), ShouldEqual, skydb.ErrDatabaseIsReadOnly)
+			So(db.Delete(record.ID), ShouldEqual, skydb.ErrDatabaseIsReadOnly)
+			_, err := db.Extend("note", skydb.RecordSchema{})
+			So(err, ShouldEqual, skydb.ErrDatabaseIsReadOnly)
+		})
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"context"
+	"time"
+)
+
+// RecordHistoryAction is the kind of change recorded in a RecordHistory.
+type RecordHistoryAction string
+
+const (
+	// RecordHistorySave is the action of saving a record.
+	RecordHistorySave RecordHistoryAction = "save"
+	// RecordHistoryDelete is the action of deleting a record.
+	RecordHistoryDelete RecordHistoryAction = "delete"
+)
+
+// RecordHistory is an entry in the change history of a record.
+//
+// Record is the record before the change, which is nil if the change
+// created the record.
+type RecordHistory struct {
+	ID        int64
+	RecordID  RecordID
+	Action    RecordHistoryAction
+	Record    *Record
+	ActorID   string
+	CreatedAt time.Time
+}
+
+type actorContextKey struct{}
+
+// ContextWithActor returns a copy of ctx carrying the ID of the user
+// making changes to the database, which is written to the record history.
+func ContextWithActor(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actorID)
+}
+
+// ActorFromContext returns the ID of the user making changes to the
+// database, or an empty string if there is none.
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actorID, _ := ctx.Value(actorContextKey{}).(string)
+	return actorID
+}
@@ -0,0 +1,51 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "errors"
+
+// ErrIndexNotFound is returned by Database.DropIndex if the index does not
+// exist on the record type.
+var ErrIndexNotFound = errors.New("skydb: index not found")
+
+// IndexMethod is the method of an index on fields of a record type.
+type IndexMethod string
+
+// The supported index methods.
+const (
+	// BTreeIndex is for equality and range comparison of fields.
+	BTreeIndex IndexMethod = "btree"
+	// GINIndex is for containment of JSON and list fields.
+	GINIndex IndexMethod = "gin"
+	// GiSTIndex is for distance comparison of location fields.
+	GiSTIndex IndexMethod = "gist"
+)
+
+// IsValid returns true if the index method is supported.
+func (method IndexMethod) IsValid() bool {
+	switch method {
+	case BTreeIndex, GINIndex, GiSTIndex:
+		return true
+	}
+	return false
+}
+
+// RecordIndex is an index on fields of a record type. Only b-tree indexes
+// can be unique.
+type RecordIndex struct {
+	Fields []string
+	Method IndexMethod
+	Unique bool
+}
@@ -0,0 +1,57 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+// LeaderboardQuery specifies a leaderboard of records of a record type
+// ranked by a number field.
+type LeaderboardQuery struct {
+	Type       string
+	ScoreField string
+
+	// Ascending ranks records with lower scores higher. Records with
+	// higher scores are ranked higher by default.
+	Ascending bool
+
+	// Limit is the number of top ranked records returned.
+	Limit uint64
+
+	// UserID is the user whose rank is returned along with the top
+	// ranked records. The rank of a user is the rank of the highest
+	// ranked record owned by the user.
+	UserID string
+
+	// ViewAsUser and BypassAccessControl work like those of Query.
+	// Records not readable by ViewAsUser are not ranked.
+	ViewAsUser          *AuthInfo
+	BypassAccessControl bool
+}
+
+// LeaderboardEntry is a ranked record of a leaderboard. Records with equal
+// scores have the same rank.
+type LeaderboardEntry struct {
+	Rank     uint64
+	RecordID RecordID
+	OwnerID  string
+	Score    float64
+}
+
+// Leaderboard is the result of a LeaderboardQuery.
+type Leaderboard struct {
+	Top []LeaderboardEntry
+
+	// User is the entry of the user of the query, or nil if the user
+	// owns no ranked records.
+	User *LeaderboardEntry
+}
@@ -0,0 +1,26 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "time"
+
+// Lock represents an advisory lease on a record held by a user. A lock
+// does not prevent the record from being modified; it lets clients tell
+// that another user is editing the record.
+type Lock struct {
+	RecordID RecordID
+	OwnerID  string
+	ExpireAt time.Time
+}
@@ -0,0 +1,31 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "time"
+
+// Maintenance is the maintenance mode of an app. When it is enabled,
+// requests modifying data are rejected while reads and pubsub are still
+// served, so that migrations and incident response can be carried out
+// safely.
+type Maintenance struct {
+	Enabled bool
+
+	// Message tells the clients the reason of the maintenance.
+	Message string
+
+	UpdatedAt time.Time
+	UpdatedBy string
+}
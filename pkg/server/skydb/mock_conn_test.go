@@ -152,6 +152,27 @@ func (_mr *_MockConnRecorder) GetRoles(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRoles", arg0)
 }
 
+func (_m *MockConn) GetAllRoles() ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetAllRoles")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAllRoles() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAllRoles")
+}
+
+func (_m *MockConn) EnsureRoles(roles []string) error {
+	ret := _m.ctrl.Call(_m, "EnsureRoles", roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) EnsureRoles(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "EnsureRoles", arg0)
+}
+
 func (_m *MockConn) SetRecordAccess(recordType string, acl RecordACL) error {
 	ret := _m.ctrl.Call(_m, "SetRecordAccess", recordType, acl)
 	ret0, _ := ret[0].(error)
@@ -287,6 +308,345 @@ func (_mr *_MockConnRecorder) RemoveRelation(arg0, arg1, arg2 interface{}) *gomo
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveRelation", arg0, arg1, arg2)
 }
 
+func (_m *MockConn) IncrementCounter(name string, delta int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "IncrementCounter", name, delta)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) IncrementCounter(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "IncrementCounter", arg0, arg1)
+}
+
+func (_m *MockConn) GetCounter(name string) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetCounter", name)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetCounter(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetCounter", arg0)
+}
+
+func (_m *MockConn) AcquireLock(recordID RecordID, ownerID string, ttl time.Duration) (*Lock, error) {
+	ret := _m.ctrl.Call(_m, "AcquireLock", recordID, ownerID, ttl)
+	ret0, _ := ret[0].(*Lock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) AcquireLock(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AcquireLock", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) RenewLock(recordID RecordID, ownerID string, ttl time.Duration) (*Lock, error) {
+	ret := _m.ctrl.Call(_m, "RenewLock", recordID, ownerID, ttl)
+	ret0, _ := ret[0].(*Lock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RenewLock(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RenewLock", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) ReleaseLock(recordID RecordID, ownerID string) error {
+	ret := _m.ctrl.Call(_m, "ReleaseLock", recordID, ownerID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) ReleaseLock(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReleaseLock", arg0, arg1)
+}
+
+func (_m *MockConn) GetLock(recordID RecordID) (*Lock, error) {
+	ret := _m.ctrl.Call(_m, "GetLock", recordID)
+	ret0, _ := ret[0].(*Lock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetLock(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLock", arg0)
+}
+
+func (_m *MockConn) RebalancePositions(recordType string, field string, maxLength int) (bool, error) {
+	ret := _m.ctrl.Call(_m, "RebalancePositions", recordType, field, maxLength)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RebalancePositions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RebalancePositions", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) ScheduleTransition(transition *Transition) error {
+	ret := _m.ctrl.Call(_m, "ScheduleTransition", transition)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) ScheduleTransition(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScheduleTransition", arg0)
+}
+
+func (_m *MockConn) GetTransitions(recordID RecordID) ([]Transition, error) {
+	ret := _m.ctrl.Call(_m, "GetTransitions", recordID)
+	ret0, _ := ret[0].([]Transition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetTransitions(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTransitions", arg0)
+}
+
+func (_m *MockConn) ClaimDueTransitions(before time.Time, lease time.Duration, limit int) ([]Transition, error) {
+	ret := _m.ctrl.Call(_m, "ClaimDueTransitions", before, lease, limit)
+	ret0, _ := ret[0].([]Transition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ClaimDueTransitions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ClaimDueTransitions", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) DeleteTransition(id string) error {
+	ret := _m.ctrl.Call(_m, "DeleteTransition", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteTransition(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteTransition", arg0)
+}
+
+func (_m *MockConn) GetUserIDsByRoles(roles []string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetUserIDsByRoles", roles)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetUserIDsByRoles(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetUserIDsByRoles", arg0)
+}
+
+func (_m *MockConn) SaveReport(report *Report) error {
+	ret := _m.ctrl.Call(_m, "SaveReport", report)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SaveReport(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveReport", arg0)
+}
+
+func (_m *MockConn) GetReports() ([]Report, error) {
+	ret := _m.ctrl.Call(_m, "GetReports")
+	ret0, _ := ret[0].([]Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetReports() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetReports")
+}
+
+func (_m *MockConn) ClaimReportRun(id string, runAt time.Time) (bool, error) {
+	ret := _m.ctrl.Call(_m, "ClaimReportRun", id, runAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ClaimReportRun(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ClaimReportRun", arg0, arg1)
+}
+
+func (_m *MockConn) DeleteReport(id string) error {
+	ret := _m.ctrl.Call(_m, "DeleteReport", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteReport(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteReport", arg0)
+}
+
+func (_m *MockConn) GetSecret(name string) (*Secret, error) {
+	ret := _m.ctrl.Call(_m, "GetSecret", name)
+	ret0, _ := ret[0].(*Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetSecret(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSecret", arg0)
+}
+
+func (_m *MockConn) GetSecrets() ([]Secret, error) {
+	ret := _m.ctrl.Call(_m, "GetSecrets")
+	ret0, _ := ret[0].([]Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetSecrets() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSecrets")
+}
+
+func (_m *MockConn) SetSecret(secret *Secret) error {
+	ret := _m.ctrl.Call(_m, "SetSecret", secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetSecret(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetSecret", arg0)
+}
+
+func (_m *MockConn) DeleteSecret(name string) error {
+	ret := _m.ctrl.Call(_m, "DeleteSecret", name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteSecret(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteSecret", arg0)
+}
+
+func (_m *MockConn) GetWebhook(id string) (*Webhook, error) {
+	ret := _m.ctrl.Call(_m, "GetWebhook", id)
+	ret0, _ := ret[0].(*Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetWebhook(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetWebhook", arg0)
+}
+
+func (_m *MockConn) CreateServiceAccount(_param0 *ServiceAccount) error {
+	ret := _m.ctrl.Call(_m, "CreateServiceAccount", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) CreateServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateServiceAccount", arg0)
+}
+
+func (_m *MockConn) DeleteServiceAccount(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteServiceAccount", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteServiceAccount", arg0)
+}
+
+func (_m *MockConn) GetServiceAccount(_param0 string) (*ServiceAccount, error) {
+	ret := _m.ctrl.Call(_m, "GetServiceAccount", _param0)
+	ret0, _ := ret[0].(*ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetServiceAccount", arg0)
+}
+
+func (_m *MockConn) GetServiceAccounts() ([]ServiceAccount, error) {
+	ret := _m.ctrl.Call(_m, "GetServiceAccounts")
+	ret0, _ := ret[0].([]ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetServiceAccounts() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetServiceAccounts")
+}
+
+func (_m *MockConn) UpdateServiceAccount(_param0 *ServiceAccount) error {
+	ret := _m.ctrl.Call(_m, "UpdateServiceAccount", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) UpdateServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateServiceAccount", arg0)
+}
+
+func (_m *MockConn) GetWebhooks() ([]Webhook, error) {
+	ret := _m.ctrl.Call(_m, "GetWebhooks")
+	ret0, _ := ret[0].([]Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetWebhooks() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetWebhooks")
+}
+
+func (_m *MockConn) SaveWebhook(webhook *Webhook) error {
+	ret := _m.ctrl.Call(_m, "SaveWebhook", webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SaveWebhook(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveWebhook", arg0)
+}
+
+func (_m *MockConn) DeleteWebhook(id string) error {
+	ret := _m.ctrl.Call(_m, "DeleteWebhook", id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteWebhook(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteWebhook", arg0)
+}
+
+func (_m *MockConn) RecordWebhookDelivery(id string, deliveryErr string, at time.Time, failureThreshold int) (bool, error) {
+	ret := _m.ctrl.Call(_m, "RecordWebhookDelivery", id, deliveryErr, at, failureThreshold)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RecordWebhookDelivery(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RecordWebhookDelivery", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockConn) GetMaintenance() (*Maintenance, error) {
+	ret := _m.ctrl.Call(_m, "GetMaintenance")
+	ret0, _ := ret[0].(*Maintenance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetMaintenance() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMaintenance")
+}
+
+func (_m *MockConn) SetMaintenance(maintenance *Maintenance) error {
+	ret := _m.ctrl.Call(_m, "SetMaintenance", maintenance)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetMaintenance(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMaintenance", arg0)
+}
+
 func (_m *MockConn) GetDevice(id string, device *Device) error {
 	ret := _m.ctrl.Call(_m, "GetDevice", id, device)
 	ret0, _ := ret[0].(error)
@@ -428,3 +788,247 @@ func (_m *MockConn) Close() error {
 func (_mr *_MockConnRecorder) Close() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Close")
 }
+
+func (_m *MockConn) SetRecordSystemFieldAccess(recordType string, masks SystemFieldMasks) error {
+	ret := _m.ctrl.Call(_m, "SetRecordSystemFieldAccess", recordType, masks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetRecordSystemFieldAccess(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRecordSystemFieldAccess", arg0, arg1)
+}
+
+func (_m *MockConn) GetRecordSystemFieldAccess() (map[string]SystemFieldAccess, error) {
+	ret := _m.ctrl.Call(_m, "GetRecordSystemFieldAccess")
+	ret0, _ := ret[0].(map[string]SystemFieldAccess)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetRecordSystemFieldAccess() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRecordSystemFieldAccess")
+}
+
+func (_m *MockConn) SetRecordStrictSchema(p0 string, p1 bool) error {
+	ret := _m.ctrl.Call(_m, "SetRecordStrictSchema", p0, p1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetRecordStrictSchema(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRecordStrictSchema", arg0, arg1)
+}
+
+func (_m *MockConn) GetRecordStrictSchema() (StrictSchema, error) {
+	ret := _m.ctrl.Call(_m, "GetRecordStrictSchema")
+	ret0, _ := ret[0].(StrictSchema)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetRecordStrictSchema() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRecordStrictSchema")
+}
+
+func (_m *MockConn) AddAnnotation(p0 *Annotation) error {
+	ret := _m.ctrl.Call(_m, "AddAnnotation", p0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) AddAnnotation(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddAnnotation", arg0)
+}
+
+func (_m *MockConn) GetAnnotation(p0 string) (*Annotation, error) {
+	ret := _m.ctrl.Call(_m, "GetAnnotation", p0)
+	ret0, _ := ret[0].(*Annotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAnnotation(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAnnotation", arg0)
+}
+
+func (_m *MockConn) GetAnnotations(p0 RecordID, p1 uint64, p2 uint64) ([]Annotation, error) {
+	ret := _m.ctrl.Call(_m, "GetAnnotations", p0, p1, p2)
+	ret0, _ := ret[0].([]Annotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAnnotations(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAnnotations", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) DeleteAnnotation(p0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteAnnotation", p0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteAnnotation(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteAnnotation", arg0)
+}
+
+func (_m *MockConn) AddReaction(p0 *Reaction) (bool, error) {
+	ret := _m.ctrl.Call(_m, "AddReaction", p0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) AddReaction(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddReaction", arg0)
+}
+
+func (_m *MockConn) RemoveReaction(p0 string, p1 RecordID, p2 string, p3 string) (bool, error) {
+	ret := _m.ctrl.Call(_m, "RemoveReaction", p0, p1, p2, p3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RemoveReaction(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveReaction", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockConn) GetReactionCounts(p0 string, p1 RecordID) (ReactionCounts, error) {
+	ret := _m.ctrl.Call(_m, "GetReactionCounts", p0, p1)
+	ret0, _ := ret[0].(ReactionCounts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetReactionCounts(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetReactionCounts", arg0, arg1)
+}
+
+func (_m *MockConn) GetUserReactions(p0 string, p1 RecordID, p2 string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetUserReactions", p0, p1, p2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetUserReactions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetUserReactions", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) GetRoleHierarchy() (RoleHierarchy, error) {
+	ret := _m.ctrl.Call(_m, "GetRoleHierarchy")
+	ret0, _ := ret[0].(RoleHierarchy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetRoleHierarchy() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRoleHierarchy")
+}
+
+func (_m *MockConn) SetRoleInheritance(p0 string, p1 []string) error {
+	ret := _m.ctrl.Call(_m, "SetRoleInheritance", p0, p1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetRoleInheritance(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRoleInheritance", arg0, arg1)
+}
+
+func (_m *MockConn) IncrementUnreadCounts(p0 []string, p1 string) error {
+	ret := _m.ctrl.Call(_m, "IncrementUnreadCounts", p0, p1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) IncrementUnreadCounts(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "IncrementUnreadCounts", arg0, arg1)
+}
+
+func (_m *MockConn) GetUnreadCounts(p0 string) (UnreadCounts, error) {
+	ret := _m.ctrl.Call(_m, "GetUnreadCounts", p0)
+	ret0, _ := ret[0].(UnreadCounts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetUnreadCounts(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetUnreadCounts", arg0)
+}
+
+func (_m *MockConn) ResetUnreadCounts(p0 string, p1 string) error {
+	ret := _m.ctrl.Call(_m, "ResetUnreadCounts", p0, p1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) ResetUnreadCounts(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ResetUnreadCounts", arg0, arg1)
+}
+
+func (_m *MockConn) ScrubRecords(p0 string, p1 map[string]ScrubMethod, p2 string) (int64, error) {
+	ret := _m.ctrl.Call(_m, "ScrubRecords", p0, p1, p2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ScrubRecords(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScrubRecords", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) ScrubSystemTables(p0 map[string]map[string]ScrubMethod, p1 string) (map[string]int64, error) {
+	ret := _m.ctrl.Call(_m, "ScrubSystemTables", p0, p1)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ScrubSystemTables(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScrubSystemTables", arg0, arg1)
+}
+
+func (_m *MockConn) GetAssetNames(p0 string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetAssetNames", p0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAssetNames(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAssetNames", arg0)
+}
+
+func (_m *MockConn) RenameAsset(p0 string, p1 string) error {
+	ret := _m.ctrl.Call(_m, "RenameAsset", p0, p1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) RenameAsset(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RenameAsset", arg0, arg1)
+}
+
+func (_m *MockConn) DeleteAsset(p0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteAsset", p0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteAsset(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteAsset", arg0)
+}
+
+func (_m *MockConn) ReplaceURLPrefix(recordType string, fields []string, oldPrefix string, newPrefix string) (int64, error) {
+	ret := _m.ctrl.Call(_m, "ReplaceURLPrefix", recordType, fields, oldPrefix, newPrefix)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ReplaceURLPrefix(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReplaceURLPrefix", arg0, arg1, arg2, arg3)
+}
@@ -296,6 +296,132 @@ func (_mr *_MockDatabaseRecorder) DeleteIndex(arg0, arg1 interface{}) *gomock.Ca
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteIndex", arg0, arg1)
 }
 
+func (_m *MockDatabase) FindDuplicates(recordType string, keys []DuplicateKey, limit int) ([][]RecordID, error) {
+	ret := _m.ctrl.Call(_m, "FindDuplicates", recordType, keys, limit)
+	ret0, _ := ret[0].([][]RecordID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDatabaseRecorder) FindDuplicates(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindDuplicates", arg0, arg1, arg2)
+}
+
+func (_m *MockDatabase) MergeRecords(winnerID RecordID, loserIDs []RecordID) error {
+	ret := _m.ctrl.Call(_m, "MergeRecords", winnerID, loserIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDatabaseRecorder) MergeRecords(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MergeRecords", arg0, arg1)
+}
+
+func (_m *MockDatabase) Merge(record *Record) error {
+	ret := _m.ctrl.Call(_m, "Merge", record)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDatabaseRecorder) Merge(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Merge", arg0)
+}
+
+func (_m *MockDatabase) GetRecordHistory(id RecordID, limit int) ([]RecordHistory, error) {
+	ret := _m.ctrl.Call(_m, "GetRecordHistory", id, limit)
+	ret0, _ := ret[0].([]RecordHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDatabaseRecorder) GetRecordHistory(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRecordHistory", arg0, arg1)
+}
+
+func (_m *MockDatabase) CreateIndex(recordType string, indexName string, index RecordIndex) error {
+	ret := _m.ctrl.Call(_m, "CreateIndex", recordType, indexName, index)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDatabaseRecorder) CreateIndex(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateIndex", arg0, arg1, arg2)
+}
+
+func (_m *MockDatabase) DropIndex(recordType string, indexName string) error {
+	ret := _m.ctrl.Call(_m, "DropIndex", recordType, indexName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDatabaseRecorder) DropIndex(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DropIndex", arg0, arg1)
+}
+
+func (_m *MockDatabase) ListIndexes(recordType string) (map[string]RecordIndex, error) {
+	ret := _m.ctrl.Call(_m, "ListIndexes", recordType)
+	ret0, _ := ret[0].(map[string]RecordIndex)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDatabaseRecorder) ListIndexes(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListIndexes", arg0)
+}
+
+func (_m *MockDatabase) SchemaVersions() (map[string]string, error) {
+	ret := _m.ctrl.Call(_m, "SchemaVersions")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDatabaseRecorder) SchemaVersions() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SchemaVersions")
+}
+
+func (_m *MockDatabase) MigrateSchema(p0 SchemaMigration) error {
+	ret := _m.ctrl.Call(_m, "MigrateSchema", p0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDatabaseRecorder) MigrateSchema(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MigrateSchema", arg0)
+}
+
+func (_m *MockDatabase) Leaderboard(p0 LeaderboardQuery) (*Leaderboard, error) {
+	ret := _m.ctrl.Call(_m, "Leaderboard", p0)
+	ret0, _ := ret[0].(*Leaderboard)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDatabaseRecorder) Leaderboard(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Leaderboard", arg0)
+}
+
+func (_m *MockDatabase) QueryStream(p0 *Query) (*Rows, error) {
+	ret := _m.ctrl.Call(_m, "QueryStream", p0)
+	ret0, _ := ret[0].(*Rows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDatabaseRecorder) QueryStream(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueryStream", arg0)
+}
+
+func (_m *MockDatabase) SaveBulk(p0 []*Record) error {
+	ret := _m.ctrl.Call(_m, "SaveBulk", p0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDatabaseRecorder) SaveBulk(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveBulk", arg0)
+}
+
 // Mock of Transactional interface
 type MockTransactional struct {
 	ctrl     *gomock.Controller
@@ -666,6 +792,111 @@ func (_mr *_MockTxDatabaseRecorder) DeleteIndex(arg0, arg1 interface{}) *gomock.
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteIndex", arg0, arg1)
 }
 
+func (_m *MockTxDatabase) FindDuplicates(recordType string, keys []DuplicateKey, limit int) ([][]RecordID, error) {
+	ret := _m.ctrl.Call(_m, "FindDuplicates", recordType, keys, limit)
+	ret0, _ := ret[0].([][]RecordID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) FindDuplicates(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindDuplicates", arg0, arg1, arg2)
+}
+
+func (_m *MockTxDatabase) MergeRecords(winnerID RecordID, loserIDs []RecordID) error {
+	ret := _m.ctrl.Call(_m, "MergeRecords", winnerID, loserIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) MergeRecords(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MergeRecords", arg0, arg1)
+}
+
+func (_m *MockTxDatabase) CreateIndex(recordType string, indexName string, index RecordIndex) error {
+	ret := _m.ctrl.Call(_m, "CreateIndex", recordType, indexName, index)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) CreateIndex(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateIndex", arg0, arg1, arg2)
+}
+
+func (_m *MockTxDatabase) DropIndex(recordType string, indexName string) error {
+	ret := _m.ctrl.Call(_m, "DropIndex", recordType, indexName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) DropIndex(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DropIndex", arg0, arg1)
+}
+
+func (_m *MockTxDatabase) ListIndexes(recordType string) (map[string]RecordIndex, error) {
+	ret := _m.ctrl.Call(_m, "ListIndexes", recordType)
+	ret0, _ := ret[0].(map[string]RecordIndex)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) ListIndexes(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListIndexes", arg0)
+}
+
+func (_m *MockTxDatabase) SchemaVersions() (map[string]string, error) {
+	ret := _m.ctrl.Call(_m, "SchemaVersions")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) SchemaVersions() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SchemaVersions")
+}
+
+func (_m *MockTxDatabase) MigrateSchema(p0 SchemaMigration) error {
+	ret := _m.ctrl.Call(_m, "MigrateSchema", p0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) MigrateSchema(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MigrateSchema", arg0)
+}
+
+func (_m *MockTxDatabase) Leaderboard(p0 LeaderboardQuery) (*Leaderboard, error) {
+	ret := _m.ctrl.Call(_m, "Leaderboard", p0)
+	ret0, _ := ret[0].(*Leaderboard)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) Leaderboard(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Leaderboard", arg0)
+}
+
+func (_m *MockTxDatabase) QueryStream(p0 *Query) (*Rows, error) {
+	ret := _m.ctrl.Call(_m, "QueryStream", p0)
+	ret0, _ := ret[0].(*Rows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) QueryStream(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueryStream", arg0)
+}
+
+func (_m *MockTxDatabase) SaveBulk(p0 []*Record) error {
+	ret := _m.ctrl.Call(_m, "SaveBulk", p0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) SaveBulk(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveBulk", arg0)
+}
+
 // Mock of RowsIter interface
 type MockRowsIter struct {
 	ctrl     *gomock.Controller
@@ -716,3 +947,23 @@ func (_m *MockRowsIter) OverallRecordCount() *uint64 {
 func (_mr *_MockRowsIterRecorder) OverallRecordCount() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "OverallRecordCount")
 }
+
+func (_m *MockRowsIter) IncludedRecords() map[string]map[string]*Record {
+	ret := _m.ctrl.Call(_m, "IncludedRecords")
+	ret0, _ := ret[0].(map[string]map[string]*Record)
+	return ret0
+}
+
+func (_mr *_MockRowsIterRecorder) IncludedRecords() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "IncludedRecords")
+}
+
+func (_m *MockRowsIter) NextCursor() *Cursor {
+	ret := _m.ctrl.Call(_m, "NextCursor")
+	ret0, _ := ret[0].(*Cursor)
+	return ret0
+}
+
+func (_mr *_MockRowsIterRecorder) NextCursor() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "NextCursor")
+}
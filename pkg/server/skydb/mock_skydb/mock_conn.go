@@ -30,6 +30,17 @@ func (_m *MockConn) EXPECT() *_MockConnRecorder {
 	return _m.recorder
 }
 
+func (_m *MockConn) AcquireLock(_param0 skydb.RecordID, _param1 string, _param2 time.Duration) (*skydb.Lock, error) {
+	ret := _m.ctrl.Call(_m, "AcquireLock", _param0, _param1, _param2)
+	ret0, _ := ret[0].(*skydb.Lock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) AcquireLock(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AcquireLock", arg0, arg1, arg2)
+}
+
 func (_m *MockConn) AddRelation(_param0 string, _param1 string, _param2 string) error {
 	ret := _m.ctrl.Call(_m, "AddRelation", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
@@ -70,6 +81,16 @@ func (_mr *_MockConnRecorder) CreateAuth(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateAuth", arg0)
 }
 
+func (_m *MockConn) CreateServiceAccount(_param0 *skydb.ServiceAccount) error {
+	ret := _m.ctrl.Call(_m, "CreateServiceAccount", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) CreateServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateServiceAccount", arg0)
+}
+
 func (_m *MockConn) DeleteAuth(_param0 string) error {
 	ret := _m.ctrl.Call(_m, "DeleteAuth", _param0)
 	ret0, _ := ret[0].(error)
@@ -110,6 +131,99 @@ func (_mr *_MockConnRecorder) DeleteEmptyDevicesByTime(arg0 interface{}) *gomock
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteEmptyDevicesByTime", arg0)
 }
 
+func (_m *MockConn) DeleteSecret(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteSecret", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteSecret(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteSecret", arg0)
+}
+
+func (_m *MockConn) DeleteServiceAccount(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteServiceAccount", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteServiceAccount", arg0)
+}
+
+func (_m *MockConn) DeleteTransition(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteTransition", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteTransition(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteTransition", arg0)
+}
+
+func (_m *MockConn) DeleteWebhook(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteWebhook", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteWebhook(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteWebhook", arg0)
+}
+
+func (_m *MockConn) GetUserIDsByRoles(_param0 []string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetUserIDsByRoles", _param0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetUserIDsByRoles(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetUserIDsByRoles", arg0)
+}
+
+func (_m *MockConn) SaveReport(_param0 *skydb.Report) error {
+	ret := _m.ctrl.Call(_m, "SaveReport", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SaveReport(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveReport", arg0)
+}
+
+func (_m *MockConn) GetReports() ([]skydb.Report, error) {
+	ret := _m.ctrl.Call(_m, "GetReports")
+	ret0, _ := ret[0].([]skydb.Report)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetReports() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetReports")
+}
+
+func (_m *MockConn) ClaimReportRun(_param0 string, _param1 time.Time) (bool, error) {
+	ret := _m.ctrl.Call(_m, "ClaimReportRun", _param0, _param1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ClaimReportRun(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ClaimReportRun", arg0, arg1)
+}
+
+func (_m *MockConn) DeleteReport(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteReport", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteReport(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteReport", arg0)
+}
+
 func (_m *MockConn) EnsureAuthRecordKeysExist(_param0 [][]string) error {
 	ret := _m.ctrl.Call(_m, "EnsureAuthRecordKeysExist", _param0)
 	ret0, _ := ret[0].(error)
@@ -130,6 +244,16 @@ func (_mr *_MockConnRecorder) EnsureAuthRecordKeysIndexesMatch(arg0 interface{})
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "EnsureAuthRecordKeysIndexesMatch", arg0)
 }
 
+func (_m *MockConn) EnsureRoles(_param0 []string) error {
+	ret := _m.ctrl.Call(_m, "EnsureRoles", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) EnsureRoles(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "EnsureRoles", arg0)
+}
+
 func (_m *MockConn) GetAdminRoles() ([]string, error) {
 	ret := _m.ctrl.Call(_m, "GetAdminRoles")
 	ret0, _ := ret[0].([]string)
@@ -141,6 +265,17 @@ func (_mr *_MockConnRecorder) GetAdminRoles() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAdminRoles")
 }
 
+func (_m *MockConn) GetAllRoles() ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetAllRoles")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAllRoles() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAllRoles")
+}
+
 func (_m *MockConn) GetAsset(_param0 string, _param1 *skydb.Asset) error {
 	ret := _m.ctrl.Call(_m, "GetAsset", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -182,6 +317,17 @@ func (_mr *_MockConnRecorder) GetAuthByPrincipalID(arg0, arg1 interface{}) *gomo
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAuthByPrincipalID", arg0, arg1)
 }
 
+func (_m *MockConn) GetCounter(_param0 string) (int64, error) {
+	ret := _m.ctrl.Call(_m, "GetCounter", _param0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetCounter(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetCounter", arg0)
+}
+
 func (_m *MockConn) GetDefaultRoles() ([]string, error) {
 	ret := _m.ctrl.Call(_m, "GetDefaultRoles")
 	ret0, _ := ret[0].([]string)
@@ -203,6 +349,39 @@ func (_mr *_MockConnRecorder) GetDevice(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetDevice", arg0, arg1)
 }
 
+func (_m *MockConn) ClaimDueTransitions(_param0 time.Time, _param1 time.Duration, _param2 int) ([]skydb.Transition, error) {
+	ret := _m.ctrl.Call(_m, "ClaimDueTransitions", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]skydb.Transition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ClaimDueTransitions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ClaimDueTransitions", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) GetLock(_param0 skydb.RecordID) (*skydb.Lock, error) {
+	ret := _m.ctrl.Call(_m, "GetLock", _param0)
+	ret0, _ := ret[0].(*skydb.Lock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetLock(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLock", arg0)
+}
+
+func (_m *MockConn) GetMaintenance() (*skydb.Maintenance, error) {
+	ret := _m.ctrl.Call(_m, "GetMaintenance")
+	ret0, _ := ret[0].(*skydb.Maintenance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetMaintenance() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMaintenance")
+}
+
 func (_m *MockConn) GetRecordAccess(_param0 string) (skydb.RecordACL, error) {
 	ret := _m.ctrl.Call(_m, "GetRecordAccess", _param0)
 	ret0, _ := ret[0].(skydb.RecordACL)
@@ -247,6 +426,94 @@ func (_mr *_MockConnRecorder) GetRoles(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRoles", arg0)
 }
 
+func (_m *MockConn) GetSecret(_param0 string) (*skydb.Secret, error) {
+	ret := _m.ctrl.Call(_m, "GetSecret", _param0)
+	ret0, _ := ret[0].(*skydb.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetSecret(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSecret", arg0)
+}
+
+func (_m *MockConn) GetSecrets() ([]skydb.Secret, error) {
+	ret := _m.ctrl.Call(_m, "GetSecrets")
+	ret0, _ := ret[0].([]skydb.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetSecrets() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSecrets")
+}
+
+func (_m *MockConn) GetServiceAccount(_param0 string) (*skydb.ServiceAccount, error) {
+	ret := _m.ctrl.Call(_m, "GetServiceAccount", _param0)
+	ret0, _ := ret[0].(*skydb.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetServiceAccount", arg0)
+}
+
+func (_m *MockConn) GetServiceAccounts() ([]skydb.ServiceAccount, error) {
+	ret := _m.ctrl.Call(_m, "GetServiceAccounts")
+	ret0, _ := ret[0].([]skydb.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetServiceAccounts() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetServiceAccounts")
+}
+
+func (_m *MockConn) GetTransitions(_param0 skydb.RecordID) ([]skydb.Transition, error) {
+	ret := _m.ctrl.Call(_m, "GetTransitions", _param0)
+	ret0, _ := ret[0].([]skydb.Transition)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetTransitions(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTransitions", arg0)
+}
+
+func (_m *MockConn) GetWebhook(_param0 string) (*skydb.Webhook, error) {
+	ret := _m.ctrl.Call(_m, "GetWebhook", _param0)
+	ret0, _ := ret[0].(*skydb.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetWebhook(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetWebhook", arg0)
+}
+
+func (_m *MockConn) GetWebhooks() ([]skydb.Webhook, error) {
+	ret := _m.ctrl.Call(_m, "GetWebhooks")
+	ret0, _ := ret[0].([]skydb.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetWebhooks() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetWebhooks")
+}
+
+func (_m *MockConn) IncrementCounter(_param0 string, _param1 int64) (int64, error) {
+	ret := _m.ctrl.Call(_m, "IncrementCounter", _param0, _param1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) IncrementCounter(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "IncrementCounter", arg0, arg1)
+}
+
 func (_m *MockConn) PrivateDB(_param0 string) skydb.Database {
 	ret := _m.ctrl.Call(_m, "PrivateDB", _param0)
 	ret0, _ := ret[0].(skydb.Database)
@@ -310,6 +577,38 @@ func (_mr *_MockConnRecorder) QueryRelationCount(arg0, arg1, arg2 interface{}) *
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueryRelationCount", arg0, arg1, arg2)
 }
 
+func (_m *MockConn) RebalancePositions(_param0 string, _param1 string, _param2 int) (bool, error) {
+	ret := _m.ctrl.Call(_m, "RebalancePositions", _param0, _param1, _param2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RebalancePositions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RebalancePositions", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) RecordWebhookDelivery(_param0 string, _param1 string, _param2 time.Time, _param3 int) (bool, error) {
+	ret := _m.ctrl.Call(_m, "RecordWebhookDelivery", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RecordWebhookDelivery(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RecordWebhookDelivery", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockConn) ReleaseLock(_param0 skydb.RecordID, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "ReleaseLock", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) ReleaseLock(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReleaseLock", arg0, arg1)
+}
+
 func (_m *MockConn) RemoveRelation(_param0 string, _param1 string, _param2 string) error {
 	ret := _m.ctrl.Call(_m, "RemoveRelation", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
@@ -320,6 +619,17 @@ func (_mr *_MockConnRecorder) RemoveRelation(arg0, arg1, arg2 interface{}) *gomo
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveRelation", arg0, arg1, arg2)
 }
 
+func (_m *MockConn) RenewLock(_param0 skydb.RecordID, _param1 string, _param2 time.Duration) (*skydb.Lock, error) {
+	ret := _m.ctrl.Call(_m, "RenewLock", _param0, _param1, _param2)
+	ret0, _ := ret[0].(*skydb.Lock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RenewLock(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RenewLock", arg0, arg1, arg2)
+}
+
 func (_m *MockConn) RevokeRoles(_param0 []string, _param1 []string) error {
 	ret := _m.ctrl.Call(_m, "RevokeRoles", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -350,6 +660,26 @@ func (_mr *_MockConnRecorder) SaveDevice(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveDevice", arg0)
 }
 
+func (_m *MockConn) SaveWebhook(_param0 *skydb.Webhook) error {
+	ret := _m.ctrl.Call(_m, "SaveWebhook", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SaveWebhook(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveWebhook", arg0)
+}
+
+func (_m *MockConn) ScheduleTransition(_param0 *skydb.Transition) error {
+	ret := _m.ctrl.Call(_m, "ScheduleTransition", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) ScheduleTransition(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScheduleTransition", arg0)
+}
+
 func (_m *MockConn) SetAdminRoles(_param0 []string) error {
 	ret := _m.ctrl.Call(_m, "SetAdminRoles", _param0)
 	ret0, _ := ret[0].(error)
@@ -370,6 +700,16 @@ func (_mr *_MockConnRecorder) SetDefaultRoles(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetDefaultRoles", arg0)
 }
 
+func (_m *MockConn) SetMaintenance(_param0 *skydb.Maintenance) error {
+	ret := _m.ctrl.Call(_m, "SetMaintenance", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetMaintenance(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMaintenance", arg0)
+}
+
 func (_m *MockConn) SetRecordAccess(_param0 string, _param1 skydb.RecordACL) error {
 	ret := _m.ctrl.Call(_m, "SetRecordAccess", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -400,6 +740,16 @@ func (_mr *_MockConnRecorder) SetRecordFieldAccess(arg0 interface{}) *gomock.Cal
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRecordFieldAccess", arg0)
 }
 
+func (_m *MockConn) SetSecret(_param0 *skydb.Secret) error {
+	ret := _m.ctrl.Call(_m, "SetSecret", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetSecret(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetSecret", arg0)
+}
+
 func (_m *MockConn) Subscribe(_param0 chan skydb.RecordEvent) error {
 	ret := _m.ctrl.Call(_m, "Subscribe", _param0)
 	ret0, _ := ret[0].(error)
@@ -429,3 +779,257 @@ func (_m *MockConn) UpdateAuth(_param0 *skydb.AuthInfo) error {
 func (_mr *_MockConnRecorder) UpdateAuth(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateAuth", arg0)
 }
+
+func (_m *MockConn) SetRecordSystemFieldAccess(_param0 string, _param1 skydb.SystemFieldMasks) error {
+	ret := _m.ctrl.Call(_m, "SetRecordSystemFieldAccess", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetRecordSystemFieldAccess(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRecordSystemFieldAccess", arg0, arg1)
+}
+
+func (_m *MockConn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	ret := _m.ctrl.Call(_m, "GetRecordSystemFieldAccess")
+	ret0, _ := ret[0].(map[string]skydb.SystemFieldAccess)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetRecordSystemFieldAccess() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRecordSystemFieldAccess")
+}
+
+func (_m *MockConn) SetRecordStrictSchema(_param0 string, _param1 bool) error {
+	ret := _m.ctrl.Call(_m, "SetRecordStrictSchema", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetRecordStrictSchema(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRecordStrictSchema", arg0, arg1)
+}
+
+func (_m *MockConn) GetRecordStrictSchema() (skydb.StrictSchema, error) {
+	ret := _m.ctrl.Call(_m, "GetRecordStrictSchema")
+	ret0, _ := ret[0].(skydb.StrictSchema)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetRecordStrictSchema() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRecordStrictSchema")
+}
+
+func (_m *MockConn) AddAnnotation(_param0 *skydb.Annotation) error {
+	ret := _m.ctrl.Call(_m, "AddAnnotation", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) AddAnnotation(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddAnnotation", arg0)
+}
+
+func (_m *MockConn) GetAnnotation(_param0 string) (*skydb.Annotation, error) {
+	ret := _m.ctrl.Call(_m, "GetAnnotation", _param0)
+	ret0, _ := ret[0].(*skydb.Annotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAnnotation(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAnnotation", arg0)
+}
+
+func (_m *MockConn) GetAnnotations(_param0 skydb.RecordID, _param1 uint64, _param2 uint64) ([]skydb.Annotation, error) {
+	ret := _m.ctrl.Call(_m, "GetAnnotations", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]skydb.Annotation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAnnotations(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAnnotations", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) DeleteAnnotation(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteAnnotation", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteAnnotation(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteAnnotation", arg0)
+}
+
+func (_m *MockConn) AddReaction(_param0 *skydb.Reaction) (bool, error) {
+	ret := _m.ctrl.Call(_m, "AddReaction", _param0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) AddReaction(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddReaction", arg0)
+}
+
+func (_m *MockConn) RemoveReaction(_param0 string, _param1 skydb.RecordID, _param2 string, _param3 string) (bool, error) {
+	ret := _m.ctrl.Call(_m, "RemoveReaction", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) RemoveReaction(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RemoveReaction", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockConn) GetReactionCounts(_param0 string, _param1 skydb.RecordID) (skydb.ReactionCounts, error) {
+	ret := _m.ctrl.Call(_m, "GetReactionCounts", _param0, _param1)
+	ret0, _ := ret[0].(skydb.ReactionCounts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetReactionCounts(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetReactionCounts", arg0, arg1)
+}
+
+func (_m *MockConn) GetUserReactions(_param0 string, _param1 skydb.RecordID, _param2 string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetUserReactions", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetUserReactions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetUserReactions", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) GetRoleHierarchy() (skydb.RoleHierarchy, error) {
+	ret := _m.ctrl.Call(_m, "GetRoleHierarchy")
+	ret0, _ := ret[0].(skydb.RoleHierarchy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetRoleHierarchy() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRoleHierarchy")
+}
+
+func (_m *MockConn) SetRoleInheritance(_param0 string, _param1 []string) error {
+	ret := _m.ctrl.Call(_m, "SetRoleInheritance", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) SetRoleInheritance(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetRoleInheritance", arg0, arg1)
+}
+
+func (_m *MockConn) IncrementUnreadCounts(_param0 []string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "IncrementUnreadCounts", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) IncrementUnreadCounts(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "IncrementUnreadCounts", arg0, arg1)
+}
+
+func (_m *MockConn) GetUnreadCounts(_param0 string) (skydb.UnreadCounts, error) {
+	ret := _m.ctrl.Call(_m, "GetUnreadCounts", _param0)
+	ret0, _ := ret[0].(skydb.UnreadCounts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetUnreadCounts(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetUnreadCounts", arg0)
+}
+
+func (_m *MockConn) ResetUnreadCounts(_param0 string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "ResetUnreadCounts", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) ResetUnreadCounts(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ResetUnreadCounts", arg0, arg1)
+}
+
+func (_m *MockConn) ScrubRecords(_param0 string, _param1 map[string]skydb.ScrubMethod, _param2 string) (int64, error) {
+	ret := _m.ctrl.Call(_m, "ScrubRecords", _param0, _param1, _param2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ScrubRecords(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScrubRecords", arg0, arg1, arg2)
+}
+
+func (_m *MockConn) ScrubSystemTables(_param0 map[string]map[string]skydb.ScrubMethod, _param1 string) (map[string]int64, error) {
+	ret := _m.ctrl.Call(_m, "ScrubSystemTables", _param0, _param1)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ScrubSystemTables(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ScrubSystemTables", arg0, arg1)
+}
+
+func (_m *MockConn) GetAssetNames(_param0 string) ([]string, error) {
+	ret := _m.ctrl.Call(_m, "GetAssetNames", _param0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) GetAssetNames(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAssetNames", arg0)
+}
+
+func (_m *MockConn) RenameAsset(_param0 string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "RenameAsset", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) RenameAsset(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RenameAsset", arg0, arg1)
+}
+
+func (_m *MockConn) DeleteAsset(_param0 string) error {
+	ret := _m.ctrl.Call(_m, "DeleteAsset", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) DeleteAsset(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteAsset", arg0)
+}
+
+func (_m *MockConn) ReplaceURLPrefix(_param0 string, _param1 []string, _param2 string, _param3 string) (int64, error) {
+	ret := _m.ctrl.Call(_m, "ReplaceURLPrefix", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockConnRecorder) ReplaceURLPrefix(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReplaceURLPrefix", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockConn) UpdateServiceAccount(_param0 *skydb.ServiceAccount) error {
+	ret := _m.ctrl.Call(_m, "UpdateServiceAccount", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockConnRecorder) UpdateServiceAccount(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateServiceAccount", arg0)
+}
@@ -120,6 +120,17 @@ func (_mr *_MockTxDatabaseRecorder) Extend(arg0, arg1 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Extend", arg0, arg1)
 }
 
+func (_m *MockTxDatabase) FindDuplicates(_param0 string, _param1 []skydb.DuplicateKey, _param2 int) ([][]skydb.RecordID, error) {
+	ret := _m.ctrl.Call(_m, "FindDuplicates", _param0, _param1, _param2)
+	ret0, _ := ret[0].([][]skydb.RecordID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) FindDuplicates(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindDuplicates", arg0, arg1, arg2)
+}
+
 func (_m *MockTxDatabase) Get(_param0 skydb.RecordID, _param1 *skydb.Record) error {
 	ret := _m.ctrl.Call(_m, "Get", _param0, _param1)
 	ret0, _ := ret[0].(error)
@@ -162,6 +173,17 @@ func (_mr *_MockTxDatabaseRecorder) GetMatchingSubscriptions(arg0 interface{}) *
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMatchingSubscriptions", arg0)
 }
 
+func (_m *MockTxDatabase) GetRecordHistory(_param0 skydb.RecordID, _param1 int) ([]skydb.RecordHistory, error) {
+	ret := _m.ctrl.Call(_m, "GetRecordHistory", _param0, _param1)
+	ret0, _ := ret[0].([]skydb.RecordHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) GetRecordHistory(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRecordHistory", arg0, arg1)
+}
+
 func (_m *MockTxDatabase) GetRecordSchemas() (map[string]skydb.RecordSchema, error) {
 	ret := _m.ctrl.Call(_m, "GetRecordSchemas")
 	ret0, _ := ret[0].(map[string]skydb.RecordSchema)
@@ -224,6 +246,26 @@ func (_mr *_MockTxDatabaseRecorder) IsReadOnly() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "IsReadOnly")
 }
 
+func (_m *MockTxDatabase) Merge(_param0 *skydb.Record) error {
+	ret := _m.ctrl.Call(_m, "Merge", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) Merge(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Merge", arg0)
+}
+
+func (_m *MockTxDatabase) MergeRecords(_param0 skydb.RecordID, _param1 []skydb.RecordID) error {
+	ret := _m.ctrl.Call(_m, "MergeRecords", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) MergeRecords(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MergeRecords", arg0, arg1)
+}
+
 func (_m *MockTxDatabase) Query(_param0 *skydb.Query) (*skydb.Rows, error) {
 	ret := _m.ctrl.Call(_m, "Query", _param0)
 	ret0, _ := ret[0].(*skydb.Rows)
@@ -326,3 +368,87 @@ func (_m *MockTxDatabase) UserRecordType() string {
 func (_mr *_MockTxDatabaseRecorder) UserRecordType() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "UserRecordType")
 }
+
+func (_m *MockTxDatabase) CreateIndex(_param0 string, _param1 string, _param2 skydb.RecordIndex) error {
+	ret := _m.ctrl.Call(_m, "CreateIndex", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) CreateIndex(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CreateIndex", arg0, arg1, arg2)
+}
+
+func (_m *MockTxDatabase) DropIndex(_param0 string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "DropIndex", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) DropIndex(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "DropIndex", arg0, arg1)
+}
+
+func (_m *MockTxDatabase) ListIndexes(_param0 string) (map[string]skydb.RecordIndex, error) {
+	ret := _m.ctrl.Call(_m, "ListIndexes", _param0)
+	ret0, _ := ret[0].(map[string]skydb.RecordIndex)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) ListIndexes(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListIndexes", arg0)
+}
+
+func (_m *MockTxDatabase) SchemaVersions() (map[string]string, error) {
+	ret := _m.ctrl.Call(_m, "SchemaVersions")
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) SchemaVersions() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SchemaVersions")
+}
+
+func (_m *MockTxDatabase) MigrateSchema(_param0 skydb.SchemaMigration) error {
+	ret := _m.ctrl.Call(_m, "MigrateSchema", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) MigrateSchema(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MigrateSchema", arg0)
+}
+
+func (_m *MockTxDatabase) Leaderboard(_param0 skydb.LeaderboardQuery) (*skydb.Leaderboard, error) {
+	ret := _m.ctrl.Call(_m, "Leaderboard", _param0)
+	ret0, _ := ret[0].(*skydb.Leaderboard)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) Leaderboard(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Leaderboard", arg0)
+}
+
+func (_m *MockTxDatabase) QueryStream(_param0 *skydb.Query) (*skydb.Rows, error) {
+	ret := _m.ctrl.Call(_m, "QueryStream", _param0)
+	ret0, _ := ret[0].(*skydb.Rows)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTxDatabaseRecorder) QueryStream(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueryStream", arg0)
+}
+
+func (_m *MockTxDatabase) SaveBulk(_param0 []*skydb.Record) error {
+	ret := _m.ctrl.Call(_m, "SaveBulk", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTxDatabaseRecorder) SaveBulk(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SaveBulk", arg0)
+}
@@ -0,0 +1,234 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"encoding/json"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+// recordAccessDocument is the document of a record type in the
+// _record_access collection, which keeps the access settings of the
+// record type.
+type recordAccessDocument struct {
+	RecordType       string                 `bson:"_id"`
+	CreationRoles    []string               `bson:"creation_roles,omitempty"`
+	SystemFieldMasks skydb.SystemFieldMasks `bson:"system_field_masks,omitempty"`
+	Salt             string                 `bson:"salt,omitempty"`
+	Strict           bool                   `bson:"strict,omitempty"`
+}
+
+// setRecordAccess sets the fields of the access document of the record
+// type.
+func (c *conn) setRecordAccess(recordType string, fields bson.M) error {
+	_, err := c.collection(recordAccessCollection).UpsertId(recordType, bson.M{"$set": fields})
+	return err
+}
+
+func (c *conn) SetRecordAccess(recordType string, acl skydb.RecordACL) error {
+	roles := []string{}
+	for _, ace := range acl {
+		if ace.Role != "" {
+			roles = append(roles, ace.Role)
+		}
+	}
+	if err := c.EnsureRoles(roles); err != nil {
+		return err
+	}
+
+	return c.setRecordAccess(recordType, bson.M{"creation_roles": roles})
+}
+
+func (c *conn) GetRecordAccess(recordType string) (skydb.RecordACL, error) {
+	doc := recordAccessDocument{}
+	err := c.collection(recordAccessCollection).FindId(recordType).One(&doc)
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+
+	entries := []skydb.RecordACLEntry{}
+	for _, role := range doc.CreationRoles {
+		entries = append(entries, skydb.NewRecordACLEntryRole(role, skydb.CreateLevel))
+	}
+	return skydb.NewRecordACL(entries), nil
+}
+
+func (c *conn) SetRecordDefaultAccess(recordType string, acl skydb.RecordACL) error {
+	return c.setRecordAccess(recordType, bson.M{"default_access": aclValue(acl)})
+}
+
+func (c *conn) GetRecordDefaultAccess(recordType string) (skydb.RecordACL, error) {
+	doc := bson.M{}
+	err := c.collection(recordAccessCollection).FindId(recordType).One(&doc)
+	if err == mgo.ErrNotFound {
+		// no default access is set for the record type
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseACL(doc["default_access"])
+}
+
+// recordFieldAccessDocument is an entry of the field ACL.
+type recordFieldAccessDocument struct {
+	RecordType   string `bson:"record_type"`
+	RecordField  string `bson:"record_field"`
+	UserRole     string `bson:"user_role"`
+	Writable     bool   `bson:"writable"`
+	Readable     bool   `bson:"readable"`
+	Comparable   bool   `bson:"comparable"`
+	Discoverable bool   `bson:"discoverable"`
+}
+
+func (c *conn) SetRecordFieldAccess(acl skydb.FieldACL) error {
+	collection := c.collection(recordFieldAccessCollection)
+	if _, err := collection.RemoveAll(nil); err != nil {
+		return err
+	}
+
+	for _, entry := range acl.AllEntries() {
+		err := collection.Insert(recordFieldAccessDocument{
+			RecordType:   entry.RecordType,
+			RecordField:  entry.RecordField,
+			UserRole:     entry.UserRole.String(),
+			Writable:     entry.Writable,
+			Readable:     entry.Readable,
+			Comparable:   entry.Comparable,
+			Discoverable: entry.Discoverable,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) GetRecordFieldAccess() (skydb.FieldACL, error) {
+	docs := []recordFieldAccessDocument{}
+	if err := c.collection(recordFieldAccessCollection).Find(nil).All(&docs); err != nil {
+		return skydb.FieldACL{}, err
+	}
+
+	entries := skydb.FieldACLEntryList{}
+	for _, doc := range docs {
+		userRole, err := skydb.ParseFieldUserRole(doc.UserRole)
+		if err != nil {
+			return skydb.FieldACL{}, err
+		}
+		entries = append(entries, skydb.FieldACLEntry{
+			RecordType:   doc.RecordType,
+			RecordField:  doc.RecordField,
+			UserRole:     userRole,
+			Writable:     doc.Writable,
+			Readable:     doc.Readable,
+			Comparable:   doc.Comparable,
+			Discoverable: doc.Discoverable,
+		})
+	}
+	return skydb.NewFieldACL(entries), nil
+}
+
+func (c *conn) SetRecordSystemFieldAccess(recordType string, masks skydb.SystemFieldMasks) error {
+	doc := recordAccessDocument{}
+	err := c.collection(recordAccessCollection).FindId(recordType).One(&doc)
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+
+	// The salt is kept on update, so that pseudonyms do not change when
+	// the masks are changed.
+	salt := doc.Salt
+	if salt == "" {
+		salt = uuid.New()
+	}
+	return c.setRecordAccess(recordType, bson.M{"system_field_masks": masks, "salt": salt})
+}
+
+func (c *conn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	docs := []recordAccessDocument{}
+	err := c.collection(recordAccessCollection).Find(bson.M{
+		"system_field_masks": bson.M{"$exists": true},
+	}).All(&docs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]skydb.SystemFieldAccess{}
+	for _, doc := range docs {
+		result[doc.RecordType] = skydb.SystemFieldAccess{
+			Masks: doc.SystemFieldMasks,
+			Salt:  doc.Salt,
+		}
+	}
+	return result, nil
+}
+
+func (c *conn) SetRecordStrictSchema(recordType string, strict bool) error {
+	return c.setRecordAccess(recordType, bson.M{"strict": strict})
+}
+
+func (c *conn) GetRecordStrictSchema() (skydb.StrictSchema, error) {
+	docs := []recordAccessDocument{}
+	if err := c.collection(recordAccessCollection).Find(bson.M{"strict": true}).All(&docs); err != nil {
+		return nil, err
+	}
+
+	strictSchema := skydb.StrictSchema{}
+	for _, doc := range docs {
+		strictSchema[doc.RecordType] = true
+	}
+	return strictSchema, nil
+}
+
+// aclValue returns the entries of the ACL as documents with the same
+// fields as the JSON of the entries, so that they can be matched by
+// accessFilter. A nil ACL is saved as null, which means the record is
+// accessible by everyone.
+func aclValue(acl skydb.RecordACL) []bson.M {
+	if acl == nil {
+		return nil
+	}
+	b, err := json.Marshal(acl)
+	if err != nil {
+		panic("unexpected serialize error on access entry")
+	}
+	entries := []bson.M{}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		panic("unexpected deserialize error on access entry")
+	}
+	return entries
+}
+
+func parseACL(value interface{}) (skydb.RecordACL, error) {
+	if value == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(normalizeBSON(value))
+	if err != nil {
+		return nil, err
+	}
+	acl := skydb.RecordACL{}
+	if err := json.Unmarshal(b, &acl); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type assetDocument struct {
+	Name        string `bson:"_id"`
+	ContentType string `bson:"content_type"`
+	Size        int64  `bson:"size"`
+}
+
+func (c *conn) GetAsset(name string, asset *skydb.Asset) error {
+	assets, err := c.GetAssets([]string{name})
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return errors.New("asset not found")
+	}
+
+	*asset = assets[0]
+	return nil
+}
+
+func (c *conn) GetAssets(names []string) ([]skydb.Asset, error) {
+	if len(names) == 0 {
+		return []skydb.Asset{}, nil
+	}
+
+	docs := []assetDocument{}
+	err := c.collection(assetCollection).Find(bson.M{"_id": bson.M{"$in": names}}).All(&docs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []skydb.Asset{}
+	for _, doc := range docs {
+		results = append(results, skydb.Asset{
+			Name:        doc.Name,
+			ContentType: doc.ContentType,
+			Size:        doc.Size,
+		})
+	}
+	return results, nil
+}
+
+func (c *conn) SaveAsset(asset *skydb.Asset) error {
+	_, err := c.collection(assetCollection).UpsertId(asset.Name, assetDocument{
+		Name:        asset.Name,
+		ContentType: asset.ContentType,
+		Size:        asset.Size,
+	})
+	return err
+}
+
+func (c *conn) GetAssetNames(prefix string) ([]string, error) {
+	docs := []assetDocument{}
+	err := c.collection(assetCollection).Find(bson.M{
+		"_id": bson.RegEx{Pattern: "^" + regexp.QuoteMeta(prefix)},
+	}).Sort("_id").All(&docs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, doc := range docs {
+		names = append(names, doc.Name)
+	}
+	return names, nil
+}
+
+// RenameAsset copies the asset to the new name, updates the asset fields
+// of all records referencing the old name, and then deletes the old
+// asset.
+func (c *conn) RenameAsset(oldName, newName string) error {
+	var asset skydb.Asset
+	if err := c.GetAsset(oldName, &asset); err != nil {
+		return skydb.ErrAssetNotFound
+	}
+	asset.Name = newName
+	if err := c.SaveAsset(&asset); err != nil {
+		return err
+	}
+
+	schemas, err := c.PublicDB().GetRecordSchemas()
+	if err != nil {
+		return err
+	}
+	for recordType, schema := range schemas {
+		for _, field := range assetFields(schema) {
+			_, err := c.collection(recordType).UpdateAll(
+				bson.M{field: oldName},
+				bson.M{"$set": bson.M{field: newName}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return c.DeleteAsset(oldName)
+}
+
+// DeleteAsset deletes the asset unless it is referenced by records, like
+// the foreign keys of asset columns in pq.
+func (c *conn) DeleteAsset(name string) error {
+	schemas, err := c.PublicDB().GetRecordSchemas()
+	if err != nil {
+		return err
+	}
+	for recordType, schema := range schemas {
+		for _, field := range assetFields(schema) {
+			count, err := c.collection(recordType).Find(bson.M{field: name}).Count()
+			if err != nil {
+				return err
+			} else if count > 0 {
+				return skyerr.NewErrorf(skyerr.ConstraintViolated,
+					"asset %s is referenced by records", name)
+			}
+		}
+	}
+
+	err = c.collection(assetCollection).RemoveId(name)
+	if err == mgo.ErrNotFound {
+		return skydb.ErrAssetNotFound
+	}
+	return err
+}
+
+func assetFields(schema skydb.RecordSchema) []string {
+	fields := []string{}
+	for field, fieldType := range schema {
+		if fieldType.Type == skydb.TypeAsset {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,51 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+type counterDocument struct {
+	Name  string `bson:"_id"`
+	Value int64  `bson:"value"`
+}
+
+// IncrementCounter increments the counter atomically with $inc.
+func (c *conn) IncrementCounter(name string, delta int64) (int64, error) {
+	log.Debugf("Increment Counter: %v, %v", name, delta)
+	doc := counterDocument{}
+	_, err := c.collection(counterCollection).FindId(name).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"value": delta}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &doc)
+	if err != nil {
+		return 0, err
+	}
+	return doc.Value, nil
+}
+
+func (c *conn) GetCounter(name string) (int64, error) {
+	doc := counterDocument{}
+	err := c.collection(counterCollection).FindId(name).One(&doc)
+	if err == mgo.ErrNotFound {
+		return 0, nil
+	}
+	return doc.Value, err
+}
@@ -0,0 +1,387 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/unsupported"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// errRevisionChanged is returned by write if the record is changed by
+// another write after it is read.
+var errRevisionChanged = errors.New("revision of record changed")
+
+// maxWriteAttempts is the number of times a record is written before
+// giving up if it is changed by other writes in between.
+const maxWriteAttempts = 3
+
+type database struct {
+	c            *conn
+	userID       string
+	databaseType skydb.DatabaseType
+
+	unsupported.Database
+}
+
+func (db *database) Conn() skydb.Conn       { return db.c }
+func (db *database) UserRecordType() string { return "user" }
+
+func (db *database) ID() string {
+	if db.DatabaseType() == skydb.PublicDatabase {
+		return skydb.PublicDatabaseIdentifier
+	} else if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.UnionDatabaseIdentifier
+	}
+
+	if db.userID == "" {
+		panic("Private database but userID is empty")
+	}
+	return db.userID
+}
+
+func (db *database) DatabaseType() skydb.DatabaseType { return db.databaseType }
+func (db *database) IsReadOnly() bool                 { return db.DatabaseType() == skydb.UnionDatabase }
+
+// TableName returns the name of the collection storing the records of
+// the record type.
+func (db *database) TableName(table string) string {
+	return table
+}
+
+func (db *database) Begin() error    { return db.c.Begin() }
+func (db *database) Commit() error   { return db.c.Commit() }
+func (db *database) Rollback() error { return db.c.Rollback() }
+
+func (db *database) Get(id skydb.RecordID, record *skydb.Record) error {
+	found, err := db.c.getRecord(id)
+	if err != nil {
+		return err
+	}
+	*record = *found
+	return nil
+}
+
+// GetByIDs returns the records of the IDs which are not deleted. Like pq,
+// all IDs are assumed to be of the same record type.
+func (db *database) GetByIDs(ids []skydb.RecordID) (*skydb.Rows, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("db.GetByIDs received empty array")
+	}
+
+	recordType := ""
+	keys := []string{}
+	for _, id := range ids {
+		if id.Key != "" {
+			keys = append(keys, id.Key)
+		}
+		if id.Type != "" && recordType == "" {
+			recordType = id.Type
+		}
+	}
+
+	schema, err := db.GetSchema(recordType)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, skydb.ErrRecordNotFound
+	}
+
+	records, err := db.c.findRecords(recordType, bson.M{
+		"_id":         bson.M{"$in": keys},
+		"_deleted_at": nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return skydb.NewRows(skydb.NewMemoryRows(records)), nil
+}
+
+// Save upserts the record. Like pq, fields not in the record are kept,
+// and the owner and creation of an existing record are not changed.
+func (db *database) Save(record *skydb.Record) error {
+	if record.ID.Key == "" {
+		return errors.New("db.save: got empty record id")
+	}
+	if record.ID.Type == "" {
+		return fmt.Errorf("db.save %s: got empty record type", record.ID.Key)
+	}
+	if record.OwnerID == "" {
+		return fmt.Errorf("db.save %s: got empty OwnerID", record.ID.Key)
+	}
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	return retryWrite(record, func() error {
+		existing, err := db.c.getRecord(record.ID)
+		if err == skydb.ErrRecordNotFound {
+			existing = nil
+		} else if err != nil {
+			return err
+		} else if existing.DatabaseID != db.userID {
+			// The record key is taken by a record in another database.
+			return skyerr.NewError(skyerr.Duplicated, "violate unique constraint")
+		}
+
+		if existing == nil && record.BaseRevision != 0 ||
+			existing != nil && record.BaseRevision != 0 && record.BaseRevision != existing.Revision {
+			return skydb.ErrRecordConflict
+		}
+
+		saved := *record
+		if existing != nil {
+			saved.OwnerID = existing.OwnerID
+			saved.CreatedAt = existing.CreatedAt
+			saved.CreatorID = existing.CreatorID
+		}
+		if err := db.write(&saved, existing); err != nil {
+			return err
+		}
+		*record = saved
+		return nil
+	})
+}
+
+// Merge updates the fields in the record of an existing record in the
+// database.
+func (db *database) Merge(record *skydb.Record) error {
+	if record.ID.Key == "" {
+		return errors.New("db.merge: got empty record id")
+	}
+	if record.ID.Type == "" {
+		return fmt.Errorf("db.merge %s: got empty record type", record.ID.Key)
+	}
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	return retryWrite(record, func() error {
+		existing, err := db.c.getRecord(record.ID)
+		if err == skydb.ErrRecordNotFound || err == nil && existing.DatabaseID != db.userID {
+			if record.BaseRevision != 0 {
+				return skydb.ErrRecordConflict
+			}
+			return skydb.ErrRecordNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if record.BaseRevision != 0 && record.BaseRevision != existing.Revision {
+			return skydb.ErrRecordConflict
+		}
+		merged := *record
+		merged.OwnerID = existing.OwnerID
+		merged.CreatedAt = existing.CreatedAt
+		merged.CreatorID = existing.CreatorID
+		if err := db.write(&merged, existing); err != nil {
+			return err
+		}
+		*record = merged
+		return nil
+	})
+}
+
+// retryWrite calls write again if the record is changed by another write
+// in between, so that increments and patches are applied to the latest
+// record. If the record is saved with a base revision, the conflict is
+// returned instead.
+func retryWrite(record *skydb.Record, write func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err != errRevisionChanged {
+			return err
+		}
+		if record.BaseRevision != 0 || attempt == maxWriteAttempts {
+			return skydb.ErrRecordConflict
+		}
+	}
+}
+
+// write writes the record merged with the existing record, which is nil
+// if the record is new. The record is updated to the saved record.
+//
+// An existing record is replaced only if its revision is not changed,
+// otherwise errRevisionChanged is returned.
+func (db *database) write(record *skydb.Record, existing *skydb.Record) error {
+	schema, err := db.GetSchema(record.ID.Type)
+	if err != nil {
+		return err
+	}
+
+	data := skydb.Data{}
+	if existing != nil {
+		data = existing.Data.Copy()
+	}
+	for key, value := range record.Data {
+		switch v := value.(type) {
+		case skydb.Increment:
+			current, _ := toFloat(data[key])
+			if schema[key].Type == skydb.TypeInteger {
+				data[key] = int64(current + v.Delta)
+			} else {
+				data[key] = current + v.Delta
+			}
+		case skydb.MergePatch:
+			data[key] = v.Apply(data[key])
+		case skydb.JSONPatch:
+			if data[key], err = v.Apply(data[key]); err != nil {
+				return skyerr.NewInvalidArgument(err.Error(), []string{key})
+			}
+		case skydb.TextPatch:
+			current, _ := data[key].(string)
+			if data[key], err = v.Apply(current); err != nil {
+				return skyerr.NewInvalidArgument(err.Error(), []string{key})
+			}
+		case skydb.Sequence:
+			// The value of a sequence field is assigned when the
+			// record is created.
+		case skydb.Unknown:
+			// Do not modify fields with unknown type because they are
+			// managed by the developer.
+		default:
+			data[key] = value
+		}
+	}
+
+	for key, fieldType := range schema {
+		if key[0] == '_' {
+			continue
+		}
+		if position, ok := data[key].(string); ok && fieldType.Type == skydb.TypePosition && !skydb.IsValidPosition(position) {
+			return skyerr.NewInvalidArgument(
+				fmt.Sprintf("invalid position for field %s", key), []string{key})
+		}
+		if existing == nil {
+			if err := db.fillNewField(record.ID.Type, key, fieldType, data); err != nil {
+				return err
+			}
+		}
+		if fieldType.Required && data[key] == nil {
+			return skyerr.NewErrorf(
+				skyerr.ConstraintViolated,
+				"failed to save %s: field %s is required", record.ID, key,
+			)
+		}
+	}
+
+	saved := *record
+	saved.Data = data
+	saved.DatabaseID = db.userID
+	saved.Revision = 1
+	if existing != nil {
+		saved.Revision = existing.Revision + 1
+	}
+
+	doc, err := recordDocument(&saved)
+	if err != nil {
+		return err
+	}
+
+	collection := db.c.collection(record.ID.Type)
+	if existing == nil {
+		err = collection.Insert(doc)
+		if isDuplicateKey(err) {
+			// The record is created by another write.
+			return errRevisionChanged
+		}
+	} else {
+		err = collection.Update(bson.M{"_id": record.ID.Key, "_rev": existing.Revision}, doc)
+		if err == mgo.ErrNotFound {
+			return errRevisionChanged
+		}
+	}
+	if mgo.IsDup(err) {
+		return skyerr.NewError(skyerr.Duplicated, "violate unique constraint")
+	} else if err != nil {
+		return err
+	}
+
+	*record = saved
+	return nil
+}
+
+// isDuplicateKey returns true if the error is caused by inserting a
+// document with the _id of an existing document, rather than violating
+// a unique index of fields.
+func isDuplicateKey(err error) bool {
+	return mgo.IsDup(err) && strings.Contains(err.Error(), "_id_")
+}
+
+// fillNewField sets the default value of the field of a new record, or
+// the next value of a sequence field.
+func (db *database) fillNewField(recordType, key string, fieldType skydb.FieldType, data skydb.Data) error {
+	if fieldType.Type == skydb.TypeSequence {
+		counterName := fmt.Sprintf("_sequence:%s:%s", recordType, key)
+		if n, ok := toFloat(data[key]); ok {
+			// An explicit value advances the sequence past it.
+			current, err := db.c.GetCounter(counterName)
+			if err != nil {
+				return err
+			}
+			if int64(n) > current {
+				_, err = db.c.IncrementCounter(counterName, int64(n)-current)
+			}
+			return err
+		}
+
+		next, err := db.c.IncrementCounter(counterName, 1)
+		if err != nil {
+			return err
+		}
+		data[key] = next
+		return nil
+	}
+
+	if _, ok := data[key]; !ok && fieldType.Default != nil {
+		data[key] = fieldType.Default
+	}
+	return nil
+}
+
+// SaveBulk saves the records one by one, because the writes cannot be
+// made atomic.
+func (db *database) SaveBulk(records []*skydb.Record) error {
+	for _, record := range records {
+		if err := db.Save(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *database) Delete(id skydb.RecordID) error {
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	err := db.c.collection(id.Type).Remove(bson.M{"_id": id.Key, "_database_id": db.userID})
+	if err == mgo.ErrNotFound {
+		return skydb.ErrRecordNotFound
+	} else if err != nil {
+		return fmt.Errorf("delete %s: failed to delete record", id)
+	}
+	return nil
+}
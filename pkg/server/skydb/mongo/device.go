@@ -0,0 +1,141 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+type deviceDocument struct {
+	ID               string    `bson:"_id"`
+	Type             string    `bson:"type"`
+	Token            string    `bson:"token,omitempty"`
+	AuthID           string    `bson:"auth_id,omitempty"`
+	Topic            string    `bson:"topic,omitempty"`
+	LastRegisteredAt time.Time `bson:"last_registered_at"`
+}
+
+func (c *conn) GetDevice(id string, device *skydb.Device) error {
+	devices, err := c.findDevices(bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return skydb.ErrDeviceNotFound
+	}
+
+	*device = devices[0]
+	return nil
+}
+
+func (c *conn) QueryDevicesByUser(user string) ([]skydb.Device, error) {
+	return c.findDevices(bson.M{"auth_id": user})
+}
+
+func (c *conn) QueryDevicesByUserAndTopic(user, topic string) ([]skydb.Device, error) {
+	return c.findDevices(bson.M{"auth_id": user, "topic": topic})
+}
+
+func (c *conn) findDevices(filter bson.M) ([]skydb.Device, error) {
+	docs := []deviceDocument{}
+	if err := c.collection(deviceCollection).Find(filter).All(&docs); err != nil {
+		return nil, err
+	}
+
+	results := []skydb.Device{}
+	for _, doc := range docs {
+		results = append(results, skydb.Device{
+			ID:               doc.ID,
+			Type:             doc.Type,
+			Token:            doc.Token,
+			AuthInfoID:       doc.AuthID,
+			Topic:            doc.Topic,
+			LastRegisteredAt: doc.LastRegisteredAt.UTC(),
+		})
+	}
+	return results, nil
+}
+
+// SaveDevice upserts the device. Like pq, an empty token or topic does
+// not overwrite the saved one.
+func (c *conn) SaveDevice(device *skydb.Device) error {
+	if device.ID == "" || device.Type == "" || device.LastRegisteredAt.IsZero() {
+		return errors.New("invalid device: empty id, type, or last registered at")
+	}
+
+	set := bson.M{
+		"type":               device.Type,
+		"last_registered_at": device.LastRegisteredAt.UTC(),
+	}
+	update := bson.M{"$set": set}
+	if device.Token != "" {
+		set["token"] = device.Token
+	}
+	if device.Topic != "" {
+		set["topic"] = device.Topic
+	}
+	if device.AuthInfoID != "" {
+		set["auth_id"] = device.AuthInfoID
+	} else {
+		update["$unset"] = bson.M{"auth_id": ""}
+	}
+
+	_, err := c.collection(deviceCollection).UpsertId(device.ID, update)
+	return err
+}
+
+func (c *conn) DeleteDevice(id string) error {
+	err := c.collection(deviceCollection).RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return skydb.ErrDeviceNotFound
+	}
+	return err
+}
+
+func (c *conn) DeleteDevicesByToken(token string, t time.Time) error {
+	filter := bson.M{"token": token}
+	if t != skydb.ZeroTime {
+		filter["last_registered_at"] = bson.M{"$lt": t.UTC()}
+	}
+	return c.removeDevices(filter)
+}
+
+func (c *conn) DeleteEmptyDevicesByTime(t time.Time) error {
+	filter := bson.M{"token": nil}
+	if t != skydb.ZeroTime {
+		filter["last_registered_at"] = bson.M{"$lt": t.UTC()}
+	}
+	return c.removeDevices(filter)
+}
+
+// removeDevices removes the devices matching the filter, or returns
+// skydb.ErrDeviceNotFound if no devices match.
+func (c *conn) removeDevices(filter bson.M) error {
+	info, err := c.collection(deviceCollection).RemoveAll(filter)
+	if err != nil {
+		return err
+	} else if info.Removed == 0 {
+		return skydb.ErrDeviceNotFound
+	}
+	return nil
+}
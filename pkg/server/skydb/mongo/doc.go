@@ -0,0 +1,48 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mongo implements skydb on MongoDB, for users migrating from
+// Parse-style deployments who already run MongoDB.
+//
+// The driver is registered as "mongo" and is only compiled with the
+// mongo build tag:
+//
+//	go build --tags mongo
+//
+// The option string is a MongoDB connection string, such as
+// "mongodb://localhost:27017/myapp". Data is saved in the database named
+// in the connection string, or in "app_" followed by the app name if no
+// database is named.
+//
+// Records of a record type are stored in the collection named after the
+// record type. The fields of a record are the fields of its document,
+// and the reserved fields are prefixed by an underscore like the columns
+// in pq. References and assets are stored as the keys and names they
+// refer to, and locations as GeoJSON points. The schemas of record types
+// are kept in the _record_type collection, and fields of documents not
+// in the schema are ignored.
+//
+// Predicates of queries are translated to query documents, and the
+// access control of records to an $or filter on the owner and the
+// entries in the _access field. Queries on fields of referenced records
+// and functions other than distance are not supported.
+//
+// MongoDB does not support transactions across documents. Begin, Commit
+// and Rollback only keep track of the transaction, and writes are not
+// rolled back. Concurrent saves of a record are detected by its revision
+// instead. Features relying on PostgreSQL, such as relations, locks,
+// annotations, reactions, transitions, secrets, webhooks and
+// subscriptions, return skyerr.NotSupported when written and nothing
+// when read.
+package mongo
@@ -0,0 +1,192 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"strings"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// Indexes are created natively in the collection of the record type.
+// Btree and gin indexes both become ascending indexes, and gist indexes
+// become 2dsphere indexes. Unique indexes only cover the records having
+// all of the fields, like a unique constraint ignoring nulls.
+
+const geoIndexKeyPrefix = "$2dsphere:"
+
+// namespaceNotFoundCode is returned by MongoDB when listing the indexes of
+// a collection that does not exist yet.
+const namespaceNotFoundCode = 26
+
+func (db *database) listIndexes(recordType string, uniqueOnly bool) (map[string]skydb.RecordIndex, error) {
+	mgoIndexes, err := db.c.collection(recordType).Indexes()
+	if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == namespaceNotFoundCode {
+		return map[string]skydb.RecordIndex{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	indexes := map[string]skydb.RecordIndex{}
+	for _, mgoIndex := range mgoIndexes {
+		if mgoIndex.Name == "_id_" || (uniqueOnly && !mgoIndex.Unique) {
+			continue
+		}
+
+		index := skydb.RecordIndex{
+			Fields: []string{},
+			Method: skydb.BTreeIndex,
+			Unique: mgoIndex.Unique,
+		}
+		for _, key := range mgoIndex.Key {
+			if strings.HasPrefix(key, geoIndexKeyPrefix) {
+				key = strings.TrimPrefix(key, geoIndexKeyPrefix)
+				index.Method = skydb.GiSTIndex
+			}
+			index.Fields = append(index.Fields, key)
+		}
+		indexes[mgoIndex.Name] = index
+	}
+	return indexes, nil
+}
+
+func (db *database) insertIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	indexes, err := db.listIndexes(recordType, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := indexes[indexName]; ok {
+		return skyerr.NewErrorf(skyerr.Duplicated, "index %s already exists", indexName)
+	}
+
+	mgoIndex := mgo.Index{
+		Name:   indexName,
+		Key:    []string{},
+		Unique: index.Unique,
+	}
+	for _, field := range index.Fields {
+		if index.Method == skydb.GiSTIndex {
+			mgoIndex.Key = append(mgoIndex.Key, geoIndexKeyPrefix+field)
+		} else {
+			mgoIndex.Key = append(mgoIndex.Key, field)
+		}
+	}
+	if index.Unique {
+		filter := bson.M{}
+		for _, field := range index.Fields {
+			filter[field] = bson.M{"$exists": true}
+		}
+		mgoIndex.PartialFilter = filter
+	}
+
+	err = db.c.collection(recordType).EnsureIndex(mgoIndex)
+	if mgo.IsDup(err) {
+		return skyerr.NewErrorf(skyerr.ConstraintViolated,
+			"fields of unique index %s have duplicated values", indexName)
+	}
+	return err
+}
+
+func (db *database) GetIndexesByRecordType(recordType string) (map[string]skydb.Index, error) {
+	recordIndexes, err := db.listIndexes(recordType, true)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := map[string]skydb.Index{}
+	for name, index := range recordIndexes {
+		indexes[name] = skydb.Index{Fields: index.Fields}
+	}
+	return indexes, nil
+}
+
+func (db *database) SaveIndex(recordType, indexName string, index skydb.Index) error {
+	return db.insertIndex(recordType, indexName, skydb.RecordIndex{
+		Fields: index.Fields,
+		Method: skydb.BTreeIndex,
+		Unique: true,
+	})
+}
+
+func (db *database) DeleteIndex(recordType string, indexName string) error {
+	indexes, err := db.listIndexes(recordType, true)
+	if err != nil {
+		return err
+	}
+	if _, ok := indexes[indexName]; !ok {
+		return nil
+	}
+	return db.c.collection(recordType).DropIndexName(indexName)
+}
+
+func (db *database) CreateIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	if index.Method == "" {
+		index.Method = skydb.BTreeIndex
+	}
+	if !index.Method.IsValid() {
+		return skyerr.NewErrorf(skyerr.InvalidArgument, "unknown index method %s", index.Method)
+	}
+	if index.Unique && index.Method != skydb.BTreeIndex {
+		return skyerr.NewErrorf(skyerr.InvalidArgument, "%s index cannot be unique", index.Method)
+	}
+	if len(index.Fields) == 0 {
+		return skyerr.NewError(skyerr.InvalidArgument, "at least one field is required to create an index")
+	}
+
+	schema, err := db.GetSchema(recordType)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return skyerr.NewErrorf(skyerr.ResourceNotFound, "record type %s does not exist", recordType)
+	}
+	for _, field := range index.Fields {
+		if _, ok := schema[field]; !ok {
+			return skyerr.NewErrorf(skyerr.InvalidArgument,
+				"field %s does not exist in record type %s", field, recordType)
+		}
+	}
+
+	return db.insertIndex(recordType, indexName, index)
+}
+
+func (db *database) DropIndex(recordType, indexName string) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	indexes, err := db.listIndexes(recordType, false)
+	if err != nil {
+		return err
+	}
+	if _, ok := indexes[indexName]; !ok {
+		return skydb.ErrIndexNotFound
+	}
+	return db.c.collection(recordType).DropIndexName(indexName)
+}
+
+func (db *database) ListIndexes(recordType string) (map[string]skydb.RecordIndex, error) {
+	return db.listIndexes(recordType, false)
+}
@@ -0,0 +1,80 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"github.com/globalsign/mgo"
+)
+
+// The collections of the data other than records, which are prefixed by
+// an underscore like the tables in pq.
+const (
+	recordTypeCollection        = "_record_type"
+	recordAccessCollection      = "_record_access"
+	recordFieldAccessCollection = "_record_field_access"
+	schemaMigrationCollection   = "_schema_migration"
+	authCollection              = "_auth"
+	roleCollection              = "_role"
+	assetCollection             = "_asset"
+	deviceCollection            = "_device"
+	counterCollection           = "_counter"
+	maintenanceCollection       = "_maintenance"
+)
+
+// systemIndexes are the indexes of the system collections, which are
+// created if they do not exist.
+var systemIndexes = map[string][]mgo.Index{
+	authCollection: {
+		{Key: []string{"principals.id"}},
+		{Key: []string{"roles"}},
+	},
+	deviceCollection: {
+		{Key: []string{"auth_id", "topic"}},
+		{Key: []string{"token"}},
+	},
+	schemaMigrationCollection: {
+		{Key: []string{"record_type", "version"}, Unique: true},
+	},
+}
+
+// userColumns are the fields of the user record type, which is created
+// if it does not exist.
+var userColumns = []columnDocument{
+	{Name: "username", Type: "string"},
+	{Name: "email", Type: "string"},
+	{Name: "phone", Type: "string"},
+	{Name: "last_login_at", Type: "datetime"},
+}
+
+func initDB(db *mgo.Database) error {
+	for name, indexes := range systemIndexes {
+		for _, index := range indexes {
+			if err := db.C(name).EnsureIndex(index); err != nil {
+				return err
+			}
+		}
+	}
+
+	err := db.C(recordTypeCollection).Insert(recordTypeDocument{
+		RecordType: "user",
+		Columns:    userColumns,
+	})
+	if err != nil && !mgo.IsDup(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// maintenanceID is the _id of the only document in the _maintenance
+// collection.
+const maintenanceID = 1
+
+type maintenanceDocument struct {
+	ID        int       `bson:"_id"`
+	Enabled   bool      `bson:"enabled"`
+	Message   string    `bson:"message"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	UpdatedBy string    `bson:"updated_by"`
+}
+
+func (c *conn) GetMaintenance() (*skydb.Maintenance, error) {
+	doc := maintenanceDocument{}
+	err := c.collection(maintenanceCollection).FindId(maintenanceID).One(&doc)
+	if err == mgo.ErrNotFound {
+		return &skydb.Maintenance{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &skydb.Maintenance{
+		Enabled:   doc.Enabled,
+		Message:   doc.Message,
+		UpdatedAt: doc.UpdatedAt.UTC(),
+		UpdatedBy: doc.UpdatedBy,
+	}, nil
+}
+
+func (c *conn) SetMaintenance(maintenance *skydb.Maintenance) error {
+	_, err := c.collection(maintenanceCollection).UpsertId(maintenanceID, maintenanceDocument{
+		ID:        maintenanceID,
+		Enabled:   maintenance.Enabled,
+		Message:   maintenance.Message,
+		UpdatedAt: maintenance.UpdatedAt.UTC(),
+		UpdatedBy: maintenance.UpdatedBy,
+	})
+	return err
+}
@@ -0,0 +1,202 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/globalsign/mgo"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/unsupported"
+)
+
+var log = logging.LoggerEntry("skydb")
+
+func notSupported(feature string) error {
+	return unsupported.Error("mongo", feature)
+}
+
+var (
+	sessions      = map[string]*mgo.Session{}
+	sessionsMutex sync.Mutex
+)
+
+var underscoreRe = regexp.MustCompile(`[.:]`)
+
+func toLowerAndUnderscore(s string) string {
+	return underscoreRe.ReplaceAllLiteralString(strings.ToLower(s), "_")
+}
+
+// Open returns a new connection to the MongoDB deployment specified by
+// the connection string. The indexes of the system collections are
+// created if migrate is true.
+func Open(ctx context.Context, appName string, accessModel skydb.AccessModel, url string, migrate bool) (skydb.Conn, error) {
+	if accessModel == skydb.RelationBasedAccess {
+		return nil, fmt.Errorf("Unsupported AccessModel: RelationBasedAccess")
+	}
+
+	dialInfo, err := mgo.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %s", err)
+	}
+	dbName := dialInfo.Database
+	if dbName == "" {
+		dbName = "app_" + toLowerAndUnderscore(appName)
+	}
+
+	session, err := getSession(url, dialInfo, dbName, migrate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each conn uses its own socket, which is returned to the pool of the
+	// shared session when the conn is closed.
+	s := session.Copy()
+	return &conn{
+		session:     s,
+		db:          s.DB(dbName),
+		appName:     appName,
+		accessModel: accessModel,
+		canMigrate:  migrate,
+		context:     ctx,
+		Conn:        unsupported.Conn{Backend: "mongo"},
+	}, nil
+}
+
+func getSession(url string, dialInfo *mgo.DialInfo, dbName string, migrate bool) (*mgo.Session, error) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	if session, ok := sessions[url]; ok {
+		return session, nil
+	}
+
+	session, err := mgo.DialWithInfo(dialInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection: %s", err)
+	}
+
+	if migrate {
+		if err := initDB(session.DB(dbName)); err != nil {
+			session.Close()
+			return nil, fmt.Errorf("failed to init database: %s", err)
+		}
+	}
+
+	sessions[url] = session
+	return session, nil
+}
+
+type conn struct {
+	session     *mgo.Session
+	db          *mgo.Database
+	appName     string
+	accessModel skydb.AccessModel
+	canMigrate  bool
+	inTx        bool
+	context     context.Context
+
+	unsupported.Conn
+}
+
+// collection returns the collection of the database of the app.
+func (c *conn) collection(name string) *mgo.Collection {
+	return c.db.C(name)
+}
+
+// Begin begins a transaction. MongoDB does not support transactions
+// across documents, so the writes in the transaction are not atomic.
+func (c *conn) Begin() error {
+	if c.inTx {
+		return skydb.ErrDatabaseTxDidBegin
+	}
+	c.inTx = true
+	return nil
+}
+
+// Commit commits a transaction.
+func (c *conn) Commit() error {
+	if !c.inTx {
+		return skydb.ErrDatabaseTxDidNotBegin
+	}
+	c.inTx = false
+	return nil
+}
+
+// Rollback ends a transaction. The writes in the transaction cannot be
+// rolled back and are kept.
+func (c *conn) Rollback() error {
+	if !c.inTx {
+		return skydb.ErrDatabaseTxDidNotBegin
+	}
+	log.Warnf("%p: Writes in transaction are not rolled back by the mongo backend", c)
+	c.inTx = false
+	return nil
+}
+
+func (c *conn) PublicDB() skydb.Database {
+	return &database{
+		c:            c,
+		databaseType: skydb.PublicDatabase,
+		Database:     unsupported.Database{Backend: "mongo"},
+	}
+}
+
+func (c *conn) PrivateDB(userKey string) skydb.Database {
+	return &database{
+		c:            c,
+		databaseType: skydb.PrivateDatabase,
+		userID:       userKey,
+		Database:     unsupported.Database{Backend: "mongo"},
+	}
+}
+
+func (c *conn) UnionDB() skydb.Database {
+	return &database{
+		c:            c,
+		databaseType: skydb.UnionDatabase,
+		Database:     unsupported.Database{Backend: "mongo"},
+	}
+}
+
+// Subscribe is a no-op because the mongo backend does not notify record
+// changes.
+func (c *conn) Subscribe(recordEventChan chan skydb.RecordEvent) error {
+	return nil
+}
+
+func (c *conn) Close() error {
+	c.session.Close()
+	return nil
+}
+
+// this ensures that our structure conform to certain interfaces.
+var (
+	_ skydb.Conn          = &conn{}
+	_ skydb.Database      = &database{}
+	_ skydb.Transactional = &database{}
+)
+
+func init() {
+	skydb.Register("mongo", skydb.DriverFunc(Open))
+}
@@ -0,0 +1,267 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// The tests below do not require a MongoDB server.
+
+func TestRecordDocument(t *testing.T) {
+	Convey("recordDocument", t, func() {
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		schema := skydb.RecordSchema{
+			"title":    skydb.FieldType{Type: skydb.TypeString},
+			"count":    skydb.FieldType{Type: skydb.TypeInteger},
+			"score":    skydb.FieldType{Type: skydb.TypeNumber},
+			"due_at":   skydb.FieldType{Type: skydb.TypeDateTime},
+			"category": skydb.FieldType{Type: skydb.TypeReference, ReferenceType: "category"},
+			"image":    skydb.FieldType{Type: skydb.TypeAsset},
+			"place":    skydb.FieldType{Type: skydb.TypeLocation},
+			"meta":     skydb.FieldType{Type: skydb.TypeJSON},
+		}
+		record := skydb.Record{
+			ID:        skydb.NewRecordID("note", "note0"),
+			OwnerID:   "user0",
+			CreatorID: "user0",
+			UpdaterID: "user0",
+			CreatedAt: now,
+			UpdatedAt: now,
+			Revision:  2,
+			ACL: skydb.NewRecordACL([]skydb.RecordACLEntry{
+				skydb.NewRecordACLEntryRole("admin", skydb.WriteLevel),
+			}),
+			Data: skydb.Data{
+				"title":    "Hello",
+				"count":    int64(3),
+				"score":    1.5,
+				"due_at":   now,
+				"category": skydb.NewReference("category", "category0"),
+				"image":    &skydb.Asset{Name: "image.png"},
+				"place":    skydb.NewLocation(114.2, 22.3),
+				"meta":     map[string]interface{}{"size": 1},
+				"empty":    nil,
+			},
+		}
+
+		Convey("stores references and assets by key and name", func() {
+			doc, err := recordDocument(&record)
+			So(err, ShouldBeNil)
+			So(doc["_id"], ShouldEqual, "note0")
+			So(doc["_rev"], ShouldEqual, 2)
+			So(doc["category"], ShouldEqual, "category0")
+			So(doc["image"], ShouldEqual, "image.png")
+			So(doc["place"], ShouldResemble, bson.M{
+				"type":        "Point",
+				"coordinates": []float64{114.2, 22.3},
+			})
+			So(doc, ShouldNotContainKey, "empty")
+			So(doc, ShouldNotContainKey, "_deleted_at")
+		})
+
+		Convey("decodes the saved document", func() {
+			doc, err := recordDocument(&record)
+			So(err, ShouldBeNil)
+			data, err := bson.Marshal(doc)
+			So(err, ShouldBeNil)
+			saved := bson.M{}
+			So(bson.Unmarshal(data, &saved), ShouldBeNil)
+
+			decoded, err := decodeRecord("note", saved, schema)
+			So(err, ShouldBeNil)
+			So(decoded.ID, ShouldResemble, record.ID)
+			So(decoded.OwnerID, ShouldEqual, "user0")
+			So(decoded.CreatedAt, ShouldResemble, now)
+			So(decoded.Revision, ShouldEqual, 2)
+			So(decoded.ACL, ShouldResemble, record.ACL)
+			So(decoded.Data, ShouldResemble, skydb.Data{
+				"title":    "Hello",
+				"count":    int64(3),
+				"score":    1.5,
+				"due_at":   now,
+				"category": skydb.NewReference("category", "category0"),
+				"image":    &skydb.Asset{Name: "image.png"},
+				"place":    skydb.NewLocation(114.2, 22.3),
+				"meta":     map[string]interface{}{"size": float64(1)},
+			})
+		})
+
+		Convey("ignores fields not in the schema", func() {
+			decoded, err := decodeRecord("note", bson.M{
+				"_id":     bson.ObjectIdHex("5a1b2c3d4e5f60718293a4b5"),
+				"title":   "Hello",
+				"unknown": "value",
+			}, schema)
+			So(err, ShouldBeNil)
+			So(decoded.ID.Key, ShouldEqual, "5a1b2c3d4e5f60718293a4b5")
+			So(decoded.Data, ShouldResemble, skydb.Data{"title": "Hello"})
+		})
+
+		Convey("rejects values that cannot be saved", func() {
+			record.Data["count"] = skydb.Increment{Delta: 1}
+			_, err := recordDocument(&record)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestPredicateFilter(t *testing.T) {
+	Convey("predicateFilter", t, func() {
+		keyPath := func(field string) skydb.Expression {
+			return skydb.Expression{Type: skydb.KeyPath, Value: field}
+		}
+		literal := func(value interface{}) skydb.Expression {
+			return skydb.Expression{Type: skydb.Literal, Value: value}
+		}
+
+		Convey("translates comparisons", func() {
+			filter, err := predicateFilter(skydb.Predicate{
+				Operator: skydb.GreaterThan,
+				Children: []interface{}{keyPath("count"), literal(int64(1))},
+			})
+			So(err, ShouldBeNil)
+			So(filter, ShouldResemble, bson.M{"count": bson.M{"$gt": int64(1)}})
+		})
+
+		Convey("reverses comparisons with a literal on the left", func() {
+			filter, err := predicateFilter(skydb.Predicate{
+				Operator: skydb.GreaterThan,
+				Children: []interface{}{literal(int64(1)), keyPath("count")},
+			})
+			So(err, ShouldBeNil)
+			So(filter, ShouldResemble, bson.M{"count": bson.M{"$lt": int64(1)}})
+		})
+
+		Convey("excludes nulls from not equal", func() {
+			filter, err := predicateFilter(skydb.Predicate{
+				Operator: skydb.NotEqual,
+				Children: []interface{}{keyPath("title"), literal("Hello")},
+			})
+			So(err, ShouldBeNil)
+			So(filter, ShouldResemble, bson.M{"title": bson.M{"$nin": []interface{}{"Hello", nil}}})
+		})
+
+		Convey("translates compound predicates", func() {
+			filter, err := predicateFilter(skydb.Predicate{
+				Operator: skydb.Not,
+				Children: []interface{}{skydb.Predicate{
+					Operator: skydb.Equal,
+					Children: []interface{}{keyPath("category"), literal(skydb.NewReference("category", "category0"))},
+				}},
+			})
+			So(err, ShouldBeNil)
+			So(filter, ShouldResemble, bson.M{"$nor": []bson.M{{"category": "category0"}}})
+		})
+
+		Convey("translates distance comparisons", func() {
+			filter, err := predicateFilter(skydb.Predicate{
+				Operator: skydb.LessThan,
+				Children: []interface{}{
+					skydb.Expression{Type: skydb.Function, Value: skydb.DistanceFunc{
+						Field:    "place",
+						Location: skydb.NewLocation(114.2, 22.3),
+					}},
+					literal(float64(earthRadius)),
+				},
+			})
+			So(err, ShouldBeNil)
+			So(filter, ShouldResemble, bson.M{"place": bson.M{"$geoWithin": bson.M{
+				"$centerSphere": []interface{}{[]float64{114.2, 22.3}, float64(1)},
+			}}})
+		})
+
+		Convey("rejects key paths of referenced records", func() {
+			_, err := predicateFilter(skydb.Predicate{
+				Operator: skydb.Equal,
+				Children: []interface{}{keyPath("category.name"), literal("Work")},
+			})
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.NotSupported)
+		})
+	})
+}
+
+func TestLikeRegex(t *testing.T) {
+	Convey("likeRegex", t, func() {
+		So(likeRegex("a%b_c", false), ShouldResemble, bson.RegEx{Pattern: "^a.*b.c$", Options: "s"})
+		So(likeRegex(`100\%.`, true), ShouldResemble, bson.RegEx{Pattern: `^100%\.$`, Options: "is"})
+	})
+}
+
+func TestAccessFilter(t *testing.T) {
+	Convey("accessFilter", t, func() {
+		Convey("matches public entries for anonymous users", func() {
+			So(accessFilter(nil, skydb.ReadLevel), ShouldResemble, bson.M{"$or": []bson.M{
+				{"_access": nil},
+				{"$and": []bson.M{
+					{"_access": bson.M{"$elemMatch": bson.M{"$or": []bson.M{{"public": true}}}}},
+					{"_access": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+						"deny": true,
+						"$or":  []bson.M{{"public": true}},
+					}}}},
+				}},
+			}})
+		})
+
+		Convey("requires the write level of grants", func() {
+			user := &skydb.AuthInfo{ID: "user0"}
+			So(accessFilter(user, skydb.WriteLevel), ShouldResemble, bson.M{"$or": []bson.M{
+				{"_access": nil},
+				{"_owner_id": "user0"},
+				{"$and": []bson.M{
+					{"_access": bson.M{"$elemMatch": bson.M{"$or": []bson.M{
+						{"user_id": "user0", "level": skydb.WriteLevel},
+						{"public": true, "level": skydb.WriteLevel},
+					}}}},
+					{"_access": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+						"deny": true,
+						"$or":  []bson.M{{"user_id": "user0"}, {"public": true}},
+					}}}},
+				}},
+			}})
+		})
+	})
+}
+
+func TestSortFields(t *testing.T) {
+	Convey("sortFields", t, func() {
+		Convey("appends the key", func() {
+			fields, err := sortFields([]skydb.Sort{{
+				Expression: skydb.Expression{Type: skydb.KeyPath, Value: "count"},
+				Order:      skydb.Descending,
+			}})
+			So(err, ShouldBeNil)
+			So(fields, ShouldResemble, []string{"-count", "_id"})
+		})
+
+		Convey("rejects nulls against the natural order", func() {
+			_, err := sortFields([]skydb.Sort{{
+				Expression: skydb.Expression{Type: skydb.KeyPath, Value: "count"},
+				Order:      skydb.Ascending,
+				Nulls:      skydb.NullsLast,
+			}})
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.NotSupported)
+		})
+	})
+}
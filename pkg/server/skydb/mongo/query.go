@@ -0,0 +1,535 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// Queries are translated to query documents evaluated by MongoDB.
+// Includes and computed keys are ignored, and the handlers fall back to
+// fetching the referenced records.
+
+// earthRadius is the radius of the earth in meters, which converts a
+// distance to the radians of a $centerSphere.
+const earthRadius = 6378100.0
+
+func (db *database) Query(query *skydb.Query) (*skydb.Rows, error) {
+	q, schema, err := db.find(query)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return skydb.EmptyRows, nil
+	}
+
+	docs := []bson.M{}
+	if err := q.All(&docs); err != nil {
+		return nil, err
+	}
+	records, err := decodeRecords(query.Type, docs, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := &queryRows{MemoryRows: skydb.NewMemoryRows(records)}
+	if query.GetCount {
+		count, err := db.QueryCount(query)
+		if err != nil {
+			return nil, err
+		}
+		rows.count = &count
+	}
+	return skydb.NewRows(rows), nil
+}
+
+// QueryStream returns the records as they are read from the cursor of
+// the query.
+func (db *database) QueryStream(query *skydb.Query) (*skydb.Rows, error) {
+	if len(query.Includes) > 0 || query.PageSize > 0 || query.GetCount {
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"includes, page size and count cannot be used with streaming query")
+	}
+
+	q, schema, err := db.find(query)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return skydb.EmptyRows, nil
+	}
+	return skydb.NewRows(&iterRows{
+		iter:       q.Iter(),
+		recordType: query.Type,
+		schema:     schema,
+	}), nil
+}
+
+func (db *database) QueryCount(query *skydb.Query) (uint64, error) {
+	filter, schema, err := db.queryFilter(query)
+	if err != nil || schema == nil {
+		return 0, err
+	}
+
+	count, err := db.c.collection(query.Type).Find(filter).Count()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(count), nil
+}
+
+// find returns the query of the records matching the query and the
+// schema of the record type. The query is nil if the record type does
+// not exist.
+func (db *database) find(query *skydb.Query) (*mgo.Query, skydb.RecordSchema, error) {
+	filter, schema, err := db.queryFilter(query)
+	if err != nil || schema == nil {
+		return nil, nil, err
+	}
+	fields, err := sortFields(query.Sorts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	q := db.c.collection(query.Type).Find(filter).Sort(fields...).Skip(int(query.Offset))
+	if query.Limit != nil {
+		q = q.Limit(int(*query.Limit))
+	}
+	return q, schema, nil
+}
+
+// queryFilter returns the query document of the records matching the
+// query and the schema of the record type. The schema is nil if the
+// record type does not exist.
+func (db *database) queryFilter(query *skydb.Query) (bson.M, skydb.RecordSchema, error) {
+	if query.Type == "" {
+		return nil, nil, errors.New("got empty query type")
+	}
+	if query.IsPaginatedByKeyset() {
+		return nil, nil, notSupported("keyset pagination")
+	}
+	if len(query.DistinctOn) > 0 {
+		return nil, nil, notSupported("distinct on")
+	}
+	if query.AsOf != nil {
+		return nil, nil, notSupported("as of queries")
+	}
+
+	schema, err := db.GetSchema(query.Type)
+	if err != nil || schema == nil {
+		return nil, nil, err
+	}
+
+	filters := []bson.M{}
+	if db.DatabaseType() != skydb.UnionDatabase {
+		filters = append(filters, bson.M{"_database_id": db.userID})
+	}
+	if !query.IncludeDeleted {
+		filters = append(filters, bson.M{"_deleted_at": nil})
+	}
+	if db.DatabaseType() == skydb.PublicDatabase && !query.BypassAccessControl {
+		filters = append(filters, accessFilter(query.ViewAsUser, skydb.ReadLevel))
+	}
+	if !query.Predicate.IsEmpty() {
+		filter, err := predicateFilter(query.Predicate)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	filter := bson.M{}
+	if len(filters) > 0 {
+		filter = bson.M{"$and": filters}
+	}
+	log.WithField("filter", filter).Debugln("Querying MongoDB")
+	return filter, schema, nil
+}
+
+// accessFilter returns the query document of the records accessible by
+// the user at the level, which is the same as the access predicate of
+// pq. A record is accessible if it has no ACL, if it is owned by the
+// user, or if an entry grants the access and no deny entry matches the
+// user.
+func accessFilter(user *skydb.AuthInfo, level skydb.RecordACLLevel) bson.M {
+	grants := []bson.M{}
+	denies := []bson.M{}
+	if user != nil {
+		if roles := user.EffectiveRoles(); len(roles) > 0 {
+			grants = append(grants, bson.M{"role": bson.M{"$in": roles}})
+			denies = append(denies, bson.M{"role": bson.M{"$in": roles}})
+		}
+		grants = append(grants, bson.M{"user_id": user.ID})
+		denies = append(denies, bson.M{"user_id": user.ID})
+	}
+	grants = append(grants, bson.M{"public": true})
+	denies = append(denies, bson.M{"public": true})
+
+	if level == skydb.WriteLevel {
+		for _, grant := range grants {
+			grant["level"] = skydb.WriteLevel
+		}
+	}
+
+	accessible := []bson.M{{"_access": nil}}
+	if user != nil {
+		accessible = append(accessible, bson.M{"_owner_id": user.ID})
+	}
+	accessible = append(accessible, bson.M{"$and": []bson.M{
+		{"_access": bson.M{"$elemMatch": bson.M{"$or": grants}}},
+		{"_access": bson.M{"$not": bson.M{"$elemMatch": bson.M{"deny": true, "$or": denies}}}},
+	}})
+	return bson.M{"$or": accessible}
+}
+
+// predicateFilter translates the predicate to a query document.
+func predicateFilter(p skydb.Predicate) (bson.M, error) {
+	switch p.Operator {
+	case skydb.And, skydb.Or:
+		filters := []bson.M{}
+		for _, child := range p.Children {
+			childPredicate, ok := child.(skydb.Predicate)
+			if !ok {
+				return nil, skyerr.NewError(skyerr.RecordQueryInvalid, "compound predicate must contain predicates")
+			}
+			filter, err := predicateFilter(childPredicate)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+		if p.Operator == skydb.And {
+			return bson.M{"$and": filters}, nil
+		}
+		return bson.M{"$or": filters}, nil
+	case skydb.Not:
+		childPredicate, ok := p.Children[0].(skydb.Predicate)
+		if !ok {
+			return nil, skyerr.NewError(skyerr.RecordQueryInvalid, "not predicate must contain a predicate")
+		}
+		filter, err := predicateFilter(childPredicate)
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"$nor": []bson.M{filter}}, nil
+	case skydb.Functional:
+		return nil, notSupported("functional predicate")
+	}
+
+	exprs := make([]skydb.Expression, len(p.Children))
+	for i, child := range p.Children {
+		expr, ok := child.(skydb.Expression)
+		if !ok {
+			return nil, skyerr.NewError(skyerr.RecordQueryInvalid, "comparison predicate must contain expressions")
+		}
+		exprs[i] = expr
+	}
+	return comparisonFilter(p.Operator, exprs)
+}
+
+// reversedOperators are the operators comparing a literal with a key
+// path, which compare the key path with the literal when reversed. A
+// literal in an array field is matched by equality.
+var reversedOperators = map[skydb.Operator]skydb.Operator{
+	skydb.Equal:              skydb.Equal,
+	skydb.NotEqual:           skydb.NotEqual,
+	skydb.GreaterThan:        skydb.LessThan,
+	skydb.LessThan:           skydb.GreaterThan,
+	skydb.GreaterThanOrEqual: skydb.LessThanOrEqual,
+	skydb.LessThanOrEqual:    skydb.GreaterThanOrEqual,
+	skydb.In:                 skydb.Equal,
+}
+
+var comparisonOperators = map[skydb.Operator]string{
+	skydb.GreaterThan:        "$gt",
+	skydb.LessThan:           "$lt",
+	skydb.GreaterThanOrEqual: "$gte",
+	skydb.LessThanOrEqual:    "$lte",
+}
+
+func comparisonFilter(op skydb.Operator, exprs []skydb.Expression) (bson.M, error) {
+	if filter, ok, err := distanceFilter(op, exprs); ok || err != nil {
+		return filter, err
+	}
+
+	if len(exprs) == 2 && exprs[0].Type == skydb.Literal && exprs[1].Type == skydb.KeyPath {
+		reversed, ok := reversedOperators[op]
+		if !ok {
+			return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid, "left operand of %v must be a key path", op)
+		}
+		op = reversed
+		exprs = []skydb.Expression{exprs[1], exprs[0]}
+	}
+
+	field, err := fieldName(exprs[0])
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(exprs)-1)
+	for i, expr := range exprs[1:] {
+		switch expr.Type {
+		case skydb.Literal:
+			values[i] = literalValue(expr.Value)
+		case skydb.KeyPath:
+			return nil, notSupported("comparing key paths")
+		default:
+			return nil, notSupported("functions")
+		}
+	}
+
+	switch op {
+	case skydb.IsNull:
+		return bson.M{field: nil}, nil
+	case skydb.IsNotNull:
+		return bson.M{field: bson.M{"$ne": nil}}, nil
+	case skydb.Equal:
+		return bson.M{field: values[0]}, nil
+	case skydb.NotEqual:
+		// Like SQL, null is neither equal nor not equal to a value.
+		if values[0] == nil {
+			return bson.M{field: bson.M{"$ne": nil}}, nil
+		}
+		return bson.M{field: bson.M{"$nin": []interface{}{values[0], nil}}}, nil
+	case skydb.GreaterThan, skydb.LessThan, skydb.GreaterThanOrEqual, skydb.LessThanOrEqual:
+		return bson.M{field: bson.M{comparisonOperators[op]: values[0]}}, nil
+	case skydb.Between:
+		return bson.M{field: bson.M{"$gte": values[0], "$lte": values[1]}}, nil
+	case skydb.Like, skydb.ILike:
+		pattern, ok := values[0].(string)
+		if !ok {
+			return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid, "comparison operator `%v` requires a string", op)
+		}
+		return bson.M{field: likeRegex(pattern, op == skydb.ILike)}, nil
+	case skydb.In, skydb.ContainsAll, skydb.ContainsAny:
+		list, ok := values[0].([]interface{})
+		if !ok {
+			return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid, "comparison operator `%v` requires an array", op)
+		}
+		if op == skydb.ContainsAll {
+			return bson.M{field: bson.M{"$all": list}}, nil
+		}
+		// An array field matches $in if any of its elements is in the
+		// list.
+		return bson.M{field: bson.M{"$in": list}}, nil
+	}
+	return nil, skyerr.NewErrorf(skyerr.NotSupported, "operator `%v` is not supported by the mongo backend", op)
+}
+
+// distanceFilter returns the query document comparing the distance from
+// a location field with a number, which is translated to whether the
+// field is within a sphere. ok is false if the predicate does not compare
+// a distance.
+func distanceFilter(op skydb.Operator, exprs []skydb.Expression) (filter bson.M, ok bool, err error) {
+	if len(exprs) != 2 {
+		return nil, false, nil
+	}
+
+	var (
+		fn     skydb.DistanceFunc
+		value  skydb.Expression
+		within bool
+	)
+	if f, isDistance := exprs[0].Value.(skydb.DistanceFunc); isDistance {
+		fn, value = f, exprs[1]
+		within = op == skydb.LessThan || op == skydb.LessThanOrEqual
+	} else if f, isDistance := exprs[1].Value.(skydb.DistanceFunc); isDistance {
+		fn, value = f, exprs[0]
+		within = op == skydb.GreaterThan || op == skydb.GreaterThanOrEqual
+	} else {
+		return nil, false, nil
+	}
+
+	if _, isComparison := comparisonOperators[op]; !isComparison {
+		return nil, true, skyerr.NewErrorf(skyerr.NotSupported,
+			"operator `%v` on distance is not supported by the mongo backend", op)
+	}
+	distance, isNumber := toFloat(value.Value)
+	if value.Type != skydb.Literal || !isNumber {
+		return nil, true, skyerr.NewError(skyerr.RecordQueryInvalid, "distance must be compared with a number")
+	}
+
+	sphere := bson.M{fn.Field: bson.M{"$geoWithin": bson.M{
+		"$centerSphere": []interface{}{
+			[]float64{fn.Location.Lng(), fn.Location.Lat()},
+			distance / earthRadius,
+		},
+	}}}
+	if within {
+		return sphere, true, nil
+	}
+	return bson.M{"$nor": []bson.M{sphere}}, true, nil
+}
+
+// fieldName returns the name of the document field of the key path.
+// Key paths to fields of referenced records cannot be queried without
+// joins.
+func fieldName(expr skydb.Expression) (string, error) {
+	if expr.Type != skydb.KeyPath {
+		return "", notSupported("comparing literals and functions")
+	}
+	keyPath, _ := expr.Value.(string)
+	if keyPath == "" || strings.Contains(keyPath, ".") {
+		return "", skyerr.NewErrorf(skyerr.NotSupported,
+			"key path %s is not supported by the mongo backend", keyPath)
+	}
+	return keyPath, nil
+}
+
+// literalValue converts the literal to the BSON value it is compared
+// with, the same as the values of fields saved by encodeValue.
+func literalValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case skydb.Reference:
+		return v.ID.Key
+	case *skydb.Asset:
+		return v.Name
+	case skydb.Location:
+		return geoJSONPoint(v)
+	case time.Time:
+		return v.UTC()
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = literalValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// likeRegex returns the regular expression of a SQL LIKE pattern, where %
+// matches any characters and _ matches one character.
+func likeRegex(pattern string, caseInsensitive bool) bson.RegEx {
+	expr := "^"
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr += regexp.QuoteMeta(string(r))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr += ".*"
+		case r == '_':
+			expr += "."
+		default:
+			expr += regexp.QuoteMeta(string(r))
+		}
+	}
+	expr += "$"
+
+	options := "s"
+	if caseInsensitive {
+		options = "is"
+	}
+	return bson.RegEx{Pattern: expr, Options: options}
+}
+
+// sortFields returns the fields to sort the records by, followed by the
+// key so that records of the same values are in a stable order. MongoDB
+// places nulls before other values in ascending order and after them in
+// descending order, which cannot be changed.
+func sortFields(sorts []skydb.Sort) ([]string, error) {
+	fields := []string{}
+	for _, s := range sorts {
+		if s.Expression.Type != skydb.KeyPath {
+			return nil, notSupported("sorting by functions")
+		}
+		field, err := fieldName(s.Expression)
+		if err != nil {
+			return nil, err
+		}
+
+		nullsFirst := s.Order != skydb.Descending
+		if s.Nulls == skydb.NullsFirst && !nullsFirst || s.Nulls == skydb.NullsLast && nullsFirst {
+			return nil, notSupported("placing nulls against the sort order")
+		}
+
+		if s.Order == skydb.Descending {
+			field = "-" + field
+		}
+		fields = append(fields, field)
+	}
+	return append(fields, "_id"), nil
+}
+
+// queryRows returns the number of all matching records as the overall
+// record count if the count is requested.
+type queryRows struct {
+	*skydb.MemoryRows
+	count *uint64
+}
+
+func (rs *queryRows) OverallRecordCount() *uint64 {
+	return rs.count
+}
+
+// iterRows decodes the records read from the cursor of a query.
+type iterRows struct {
+	iter       *mgo.Iter
+	recordType string
+	schema     skydb.RecordSchema
+}
+
+func (rs *iterRows) Close() error {
+	return rs.iter.Close()
+}
+
+func (rs *iterRows) Next(record *skydb.Record) error {
+	doc := bson.M{}
+	if !rs.iter.Next(&doc) {
+		if err := rs.iter.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	decoded, err := decodeRecord(rs.recordType, doc, rs.schema)
+	if err != nil {
+		return err
+	}
+	*record = decoded
+	return nil
+}
+
+func (rs *iterRows) OverallRecordCount() *uint64 {
+	return nil
+}
+
+func (rs *iterRows) IncludedRecords() map[string]map[string]*skydb.Record {
+	return nil
+}
+
+func (rs *iterRows) NextCursor() *skydb.Cursor {
+	return nil
+}
+
+var (
+	_ skydb.RowsIter = &queryRows{}
+	_ skydb.RowsIter = &iterRows{}
+)
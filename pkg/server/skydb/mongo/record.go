@@ -0,0 +1,322 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// recordDocument returns the document of the record. Fields without a
+// value are not stored, so that they match null in queries.
+func recordDocument(record *skydb.Record) (bson.M, error) {
+	doc := bson.M{
+		"_id":          record.ID.Key,
+		"_database_id": record.DatabaseID,
+		"_owner_id":    record.OwnerID,
+		"_created_at":  record.CreatedAt.UTC(),
+		"_created_by":  record.CreatorID,
+		"_updated_at":  record.UpdatedAt.UTC(),
+		"_updated_by":  record.UpdaterID,
+		"_rev":         record.Revision,
+	}
+	if record.ACL != nil {
+		doc["_access"] = aclValue(record.ACL)
+	}
+	if record.DeletedAt != nil && !record.DeletedAt.IsZero() {
+		doc["_deleted_at"] = record.DeletedAt.UTC()
+	}
+
+	for key, value := range record.Data {
+		encoded, err := encodeValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save field %s: %s", key, err)
+		}
+		if encoded != nil {
+			doc[key] = encoded
+		}
+	}
+	return doc, nil
+}
+
+// encodeValue returns the BSON value of a field. References and assets
+// are stored as the keys and names they refer to, like the foreign keys
+// in pq, and locations as GeoJSON points so that they can be indexed.
+func encodeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case skydb.Reference:
+		return v.ID.Key, nil
+	case *skydb.Asset:
+		return v.Name, nil
+	case skydb.Location:
+		return geoJSONPoint(v), nil
+	case *skydb.Location:
+		if v == nil {
+			return nil, nil
+		}
+		return geoJSONPoint(*v), nil
+	case skydb.Geometry:
+		return map[string]interface{}(v), nil
+	case time.Time:
+		return v.UTC(), nil
+	case skydb.Unknown, skydb.Sequence, skydb.Increment, skydb.MergePatch, skydb.JSONPatch, skydb.TextPatch:
+		return nil, fmt.Errorf("cannot save value of type %T", value)
+	}
+	return value, nil
+}
+
+func geoJSONPoint(loc skydb.Location) bson.M {
+	return bson.M{
+		"type":        "Point",
+		"coordinates": []float64{loc.Lng(), loc.Lat()},
+	}
+}
+
+func parseGeoJSONPoint(value interface{}) (skydb.Location, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || m["type"] != "Point" {
+		return skydb.Location{}, false
+	}
+	coordinates, ok := m["coordinates"].([]interface{})
+	if !ok || len(coordinates) != 2 {
+		return skydb.Location{}, false
+	}
+	lng, lngOK := toFloat(coordinates[0])
+	lat, latOK := toFloat(coordinates[1])
+	if !lngOK || !latOK {
+		return skydb.Location{}, false
+	}
+	return skydb.NewLocation(lng, lat), true
+}
+
+// decodeRecord decodes the document of a record of the record type.
+// Fields not in the schema are ignored, so that documents written by
+// other applications can be read.
+func decodeRecord(recordType string, doc bson.M, schema skydb.RecordSchema) (skydb.Record, error) {
+	record := skydb.Record{
+		ID:   skydb.NewRecordID(recordType, keyString(doc["_id"])),
+		Data: skydb.Data{},
+	}
+	record.DatabaseID, _ = doc["_database_id"].(string)
+	record.OwnerID, _ = doc["_owner_id"].(string)
+	record.CreatorID, _ = doc["_created_by"].(string)
+	record.UpdaterID, _ = doc["_updated_by"].(string)
+	if t, ok := doc["_created_at"].(time.Time); ok {
+		record.CreatedAt = t.UTC()
+	}
+	if t, ok := doc["_updated_at"].(time.Time); ok {
+		record.UpdatedAt = t.UTC()
+	}
+	if t, ok := doc["_deleted_at"].(time.Time); ok {
+		deletedAt := t.UTC()
+		record.DeletedAt = &deletedAt
+	}
+	record.Revision, _ = toInt64(doc["_rev"])
+
+	var err error
+	if record.ACL, err = parseACL(doc["_access"]); err != nil {
+		return skydb.Record{}, fmt.Errorf("failed to decode %s: %s", record.ID, err)
+	}
+
+	for key, value := range doc {
+		if strings.HasPrefix(key, "_") || value == nil {
+			continue
+		}
+		fieldType, ok := schema[key]
+		if !ok {
+			continue
+		}
+		record.Data[key] = decodeValue(normalizeBSON(value), fieldType)
+	}
+	return record, nil
+}
+
+func decodeRecords(recordType string, docs []bson.M, schema skydb.RecordSchema) ([]skydb.Record, error) {
+	records := make([]skydb.Record, len(docs))
+	for i, doc := range docs {
+		record, err := decodeRecord(recordType, doc, schema)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+// decodeValue converts the normalized BSON value of a field to the value
+// of the field type. A value not of the field type is returned as is.
+func decodeValue(value interface{}, fieldType skydb.FieldType) interface{} {
+	switch fieldType.Type {
+	case skydb.TypeNumber:
+		if n, ok := toFloat(value); ok {
+			return n
+		}
+	case skydb.TypeInteger, skydb.TypeSequence:
+		if n, ok := toInt64(value); ok {
+			return n
+		}
+	case skydb.TypeDateTime:
+		if t, ok := value.(time.Time); ok {
+			return t.UTC()
+		}
+	case skydb.TypeReference:
+		if key, ok := value.(string); ok {
+			return skydb.NewReference(fieldType.ReferenceType, key)
+		}
+	case skydb.TypeAsset:
+		if name, ok := value.(string); ok {
+			return &skydb.Asset{Name: name}
+		}
+	case skydb.TypeLocation:
+		if loc, ok := parseGeoJSONPoint(value); ok {
+			return loc
+		}
+	case skydb.TypeGeometry:
+		if m, ok := value.(map[string]interface{}); ok {
+			return skydb.Geometry(m)
+		}
+	case skydb.TypeJSON:
+		return jsonValue(value)
+	}
+	return value
+}
+
+// normalizeBSON converts the embedded documents in the decoded value to
+// maps, and object IDs to their hex strings.
+func normalizeBSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		return normalizeBSON(map[string]interface{}(v))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = normalizeBSON(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalizeBSON(item)
+		}
+		return items
+	case bson.ObjectId:
+		return v.Hex()
+	}
+	return value
+}
+
+// jsonValue converts the numbers in the value to float64, the same as the
+// values of json fields decoded by pq.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	}
+	if n, ok := toFloat(value); ok {
+		return n
+	}
+	return value
+}
+
+// keyString returns the record key of the _id of a document, which is an
+// object ID if the document is not saved by the server.
+func keyString(id interface{}) string {
+	switch v := id.(type) {
+	case string:
+		return v
+	case bson.ObjectId:
+		return v.Hex()
+	}
+	return fmt.Sprint(id)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// findRecords returns the records of the record type matching the
+// filter, ordered by key. No records are returned if the record type does
+// not exist.
+func (c *conn) findRecords(recordType string, filter bson.M) ([]skydb.Record, error) {
+	schema, err := c.getSchema(recordType)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return []skydb.Record{}, nil
+	}
+
+	docs := []bson.M{}
+	if err := c.collection(recordType).Find(filter).Sort("_id").All(&docs); err != nil {
+		return nil, err
+	}
+	return decodeRecords(recordType, docs, schema)
+}
+
+// getRecord returns the record of the ID in any database, or
+// skydb.ErrRecordNotFound if it does not exist.
+func (c *conn) getRecord(id skydb.RecordID) (*skydb.Record, error) {
+	records, err := c.findRecords(id.Type, bson.M{"_id": id.Key})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, skydb.ErrRecordNotFound
+	}
+	return &records[0], nil
+}
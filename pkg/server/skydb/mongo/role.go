@@ -0,0 +1,195 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"sort"
+
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// roleDocument is the document of a role in the _role collection. The
+// roles assigned to a user are kept in the document of the user.
+type roleDocument struct {
+	ID        string   `bson:"_id"`
+	IsAdmin   bool     `bson:"is_admin"`
+	ByDefault bool     `bson:"by_default"`
+	Inherits  []string `bson:"inherits,omitempty"`
+}
+
+func (c *conn) GetAdminRoles() ([]string, error) {
+	return c.findRoles(bson.M{"is_admin": true})
+}
+
+func (c *conn) SetAdminRoles(roles []string) error {
+	return c.setRoleFlag(roles, "is_admin")
+}
+
+func (c *conn) GetDefaultRoles() ([]string, error) {
+	return c.findRoles(bson.M{"by_default": true})
+}
+
+func (c *conn) SetDefaultRoles(roles []string) error {
+	return c.setRoleFlag(roles, "by_default")
+}
+
+// setRoleFlag sets the flag field of the roles, and resets the flag of
+// the other roles.
+func (c *conn) setRoleFlag(roles []string, field string) error {
+	if err := c.EnsureRoles(roles); err != nil {
+		return err
+	}
+
+	collection := c.collection(roleCollection)
+	_, err := collection.UpdateAll(bson.M{"_id": bson.M{"$nin": roles}}, bson.M{"$set": bson.M{field: false}})
+	if err != nil {
+		return err
+	}
+	_, err = collection.UpdateAll(bson.M{"_id": bson.M{"$in": roles}}, bson.M{"$set": bson.M{field: true}})
+	return err
+}
+
+func (c *conn) GetAllRoles() ([]string, error) {
+	return c.findRoles(nil)
+}
+
+// findRoles returns the IDs of the roles matching the filter, in
+// alphabetical order.
+func (c *conn) findRoles(filter interface{}) ([]string, error) {
+	docs := []roleDocument{}
+	if err := c.collection(roleCollection).Find(filter).Sort("_id").All(&docs); err != nil {
+		return nil, err
+	}
+
+	roles := []string{}
+	for _, doc := range docs {
+		roles = append(roles, doc.ID)
+	}
+	return roles, nil
+}
+
+func (c *conn) EnsureRoles(roles []string) error {
+	for _, role := range roles {
+		_, err := c.collection(roleCollection).UpsertId(role, bson.M{
+			"$setOnInsert": bson.M{"is_admin": false, "by_default": false},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) AssignRoles(userIDs []string, roles []string) error {
+	if err := c.EnsureRoles(roles); err != nil {
+		return err
+	}
+
+	_, err := c.collection(authCollection).UpdateAll(
+		bson.M{"_id": bson.M{"$in": userIDs}},
+		bson.M{"$addToSet": bson.M{"roles": bson.M{"$each": roles}}},
+	)
+	return err
+}
+
+func (c *conn) RevokeRoles(userIDs []string, roles []string) error {
+	_, err := c.collection(authCollection).UpdateAll(
+		bson.M{"_id": bson.M{"$in": userIDs}},
+		bson.M{"$pullAll": bson.M{"roles": roles}},
+	)
+	return err
+}
+
+func (c *conn) GetRoles(userIDs []string) (map[string][]string, error) {
+	docs := []authDocument{}
+	err := c.collection(authCollection).Find(bson.M{"_id": bson.M{"$in": userIDs}}).
+		Select(bson.M{"roles": 1}).All(&docs)
+	if err != nil {
+		return nil, err
+	}
+
+	roleMap := map[string][]string{}
+	for _, userID := range userIDs {
+		// keep an empty array even no roles found for that user
+		roleMap[userID] = []string{}
+	}
+	for _, doc := range docs {
+		roles := append([]string{}, doc.Roles...)
+		sort.Strings(roles)
+		roleMap[doc.ID] = roles
+	}
+	return roleMap, nil
+}
+
+func (c *conn) GetUserIDsByRoles(roles []string) ([]string, error) {
+	docs := []authDocument{}
+	err := c.collection(authCollection).Find(bson.M{"roles": bson.M{"$in": roles}}).
+		Select(bson.M{"_id": 1}).Sort("_id").All(&docs)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := []string{}
+	for _, doc := range docs {
+		userIDs = append(userIDs, doc.ID)
+	}
+	return userIDs, nil
+}
+
+func (c *conn) GetRoleHierarchy() (skydb.RoleHierarchy, error) {
+	docs := []roleDocument{}
+	err := c.collection(roleCollection).Find(bson.M{"inherits.0": bson.M{"$exists": true}}).
+		Sort("_id").All(&docs)
+	if err != nil {
+		return nil, err
+	}
+
+	hierarchy := skydb.RoleHierarchy{}
+	for _, doc := range docs {
+		inheritedRoles := append([]string{}, doc.Inherits...)
+		sort.Strings(inheritedRoles)
+		hierarchy[doc.ID] = inheritedRoles
+	}
+	return hierarchy, nil
+}
+
+func (c *conn) SetRoleInheritance(role string, inheritedRoles []string) error {
+	if err := c.EnsureRoles(append([]string{role}, inheritedRoles...)); err != nil {
+		return err
+	}
+
+	return c.collection(roleCollection).UpdateId(role, bson.M{
+		"$set": bson.M{"inherits": inheritedRoles},
+	})
+}
+
+// setInheritedRoles sets the roles inherited by the roles of the user.
+func (c *conn) setInheritedRoles(authinfo *skydb.AuthInfo) error {
+	if len(authinfo.Roles) == 0 {
+		authinfo.InheritedRoles = nil
+		return nil
+	}
+
+	hierarchy, err := c.GetRoleHierarchy()
+	if err != nil {
+		return err
+	}
+	authinfo.InheritedRoles = hierarchy.InheritedRoles(authinfo.Roles)
+	return nil
+}
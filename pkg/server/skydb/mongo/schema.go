@@ -0,0 +1,415 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// reservedSchema is the schema of the reserved fields of every record
+// type.
+var reservedSchema = skydb.RecordSchema{
+	"_id":          skydb.FieldType{Type: skydb.TypeString},
+	"_database_id": skydb.FieldType{Type: skydb.TypeString},
+	"_owner_id":    skydb.FieldType{Type: skydb.TypeString},
+	"_access":      skydb.FieldType{Type: skydb.TypeACL},
+	"_created_at":  skydb.FieldType{Type: skydb.TypeDateTime, Required: true},
+	"_created_by":  skydb.FieldType{Type: skydb.TypeString},
+	"_updated_at":  skydb.FieldType{Type: skydb.TypeDateTime, Required: true},
+	"_updated_by":  skydb.FieldType{Type: skydb.TypeString},
+	"_deleted_at":  skydb.FieldType{Type: skydb.TypeDateTime},
+	"_rev":         skydb.FieldType{Type: skydb.TypeInteger, Required: true},
+}
+
+// recordTypeDocument is the document of a record type in the _record_type
+// collection, which keeps the fields of the record type.
+type recordTypeDocument struct {
+	RecordType string           `bson:"_id"`
+	Columns    []columnDocument `bson:"columns"`
+}
+
+type columnDocument struct {
+	Name     string      `bson:"name"`
+	Type     string      `bson:"type"`
+	Required bool        `bson:"required,omitempty"`
+	Default  interface{} `bson:"default,omitempty"`
+}
+
+// getRecordType returns the document of the record type, or nil if the
+// record type does not exist.
+func (c *conn) getRecordType(recordType string) (*recordTypeDocument, error) {
+	doc := recordTypeDocument{}
+	err := c.collection(recordTypeCollection).FindId(recordType).One(&doc)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// getSchema returns the schema of the record type, or nil if the record
+// type does not exist.
+func (c *conn) getSchema(recordType string) (skydb.RecordSchema, error) {
+	doc, err := c.getRecordType(recordType)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return doc.schema()
+}
+
+func (doc *recordTypeDocument) schema() (skydb.RecordSchema, error) {
+	schema := skydb.RecordSchema{}
+	for key, fieldType := range reservedSchema {
+		schema[key] = fieldType
+	}
+	for _, column := range doc.Columns {
+		fieldType, err := skydb.SimpleNameToFieldType(column.Type)
+		if err != nil {
+			return nil, err
+		}
+		fieldType.Required = column.Required
+		fieldType.Default = column.Default
+		schema[column.Name] = fieldType
+	}
+	return schema, nil
+}
+
+// putColumns adds the columns to the record type, or replaces the
+// columns of the same names. The record type is created if it does not
+// exist.
+func (c *conn) putColumns(recordType string, recordSchema skydb.RecordSchema) error {
+	doc, err := c.getRecordType(recordType)
+	if err != nil {
+		return err
+	}
+	if doc == nil {
+		doc = &recordTypeDocument{RecordType: recordType, Columns: []columnDocument{}}
+	}
+
+	for name, fieldType := range recordSchema {
+		column := columnDocument{
+			Name:     name,
+			Type:     fieldType.ToSimpleName(),
+			Required: fieldType.Required,
+			Default:  fieldType.Default,
+		}
+		replaced := false
+		for i := range doc.Columns {
+			if doc.Columns[i].Name == name {
+				doc.Columns[i] = column
+				replaced = true
+			}
+		}
+		if !replaced {
+			doc.Columns = append(doc.Columns, column)
+		}
+	}
+
+	_, err = c.collection(recordTypeCollection).UpsertId(recordType, doc)
+	return err
+}
+
+func (db *database) RemoteColumnTypes(recordType string) (skydb.RecordSchema, error) {
+	return db.c.getSchema(recordType)
+}
+
+func (db *database) GetSchema(recordType string) (skydb.RecordSchema, error) {
+	return db.c.getSchema(recordType)
+}
+
+func (db *database) GetRecordSchemas() (map[string]skydb.RecordSchema, error) {
+	docs := []recordTypeDocument{}
+	if err := db.c.collection(recordTypeCollection).Find(nil).All(&docs); err != nil {
+		return nil, err
+	}
+
+	result := map[string]skydb.RecordSchema{}
+	for i := range docs {
+		schema, err := docs[i].schema()
+		if err != nil {
+			return nil, err
+		}
+		result[docs[i].RecordType] = schema
+	}
+	return result, nil
+}
+
+func (db *database) Extend(recordType string, recordSchema skydb.RecordSchema) (extended bool, err error) {
+	remoteRecordSchema, err := db.GetSchema(recordType)
+	if err != nil {
+		return false, err
+	}
+
+	for key, fieldType := range recordSchema {
+		if !fieldType.DefaultCompatible() {
+			return false, skyerr.NewInvalidArgument(
+				fmt.Sprintf("default value of %s is not a valid %s", key, fieldType.ToSimpleName()),
+				[]string{key},
+			)
+		}
+	}
+
+	// Find new columns, and columns with constraints added. Constraints
+	// are only added, so that a field derived from a saved record does
+	// not remove the constraints declared for the field.
+	updatingSchema := skydb.RecordSchema{}
+	for key, fieldType := range recordSchema {
+		remoteFieldType, ok := remoteRecordSchema[key]
+		if !ok {
+			updatingSchema[key] = fieldType
+			continue
+		}
+
+		if !remoteFieldType.DefinitionCompatibleTo(fieldType) {
+			return false, skyerr.NewError(
+				skyerr.IncompatibleSchema,
+				fmt.Sprintf("conflicting schema %v => %v", remoteFieldType, fieldType),
+			)
+		}
+		if constraintChanged(remoteFieldType, fieldType) {
+			remoteFieldType.Required = remoteFieldType.Required || fieldType.Required
+			if fieldType.Default != nil {
+				remoteFieldType.Default = fieldType.Default
+			}
+			updatingSchema[key] = remoteFieldType
+		}
+	}
+	if len(remoteRecordSchema) > 0 && len(updatingSchema) == 0 {
+		// The current record schema is superset of requested record
+		// schema. There is no need to extend the schema.
+		return false, nil
+	}
+
+	if err := db.checkStrictSchema(recordType, remoteRecordSchema, recordSchema); err != nil {
+		return false, err
+	}
+
+	if !db.c.canMigrate {
+		// The record schemas are different, but the database connection
+		// does not allow migration.
+		return false, skyerr.NewError(
+			skyerr.IncompatibleSchema,
+			"Record schema requires migration but migration is disabled.",
+		)
+	}
+
+	if err := db.c.putColumns(recordType, updatingSchema); err != nil {
+		return false, fmt.Errorf("failed to extend schema: %s", err)
+	}
+	return true, nil
+}
+
+// constraintChanged returns true if altering the column of the remote
+// field type to the field type changes its constraints.
+func constraintChanged(remoteFieldType skydb.FieldType, fieldType skydb.FieldType) bool {
+	defaultChanged := fieldType.Default != nil && !reflect.DeepEqual(fieldType.Default, remoteFieldType.Default)
+	return defaultChanged || fieldType.Required && !remoteFieldType.Required
+}
+
+// checkStrictSchema returns an error if the record type is in strict
+// schema mode, and the record type or some of its fields would be
+// created to extend the schema.
+func (db *database) checkStrictSchema(recordType string, remoteRecordSchema skydb.RecordSchema, recordSchema skydb.RecordSchema) error {
+	newColumns := []string{}
+	for key := range recordSchema {
+		if _, ok := remoteRecordSchema[key]; !ok {
+			newColumns = append(newColumns, key)
+		}
+	}
+	if len(remoteRecordSchema) > 0 && len(newColumns) == 0 {
+		return nil
+	}
+
+	strictSchema, err := db.c.GetRecordStrictSchema()
+	if err != nil {
+		return err
+	}
+	if !strictSchema.IsStrict(recordType) {
+		return nil
+	}
+
+	if len(remoteRecordSchema) == 0 {
+		return skyerr.NewErrorf(
+			skyerr.IncompatibleSchema,
+			`record type "%s" does not exist and cannot be created in strict schema mode`,
+			recordType,
+		)
+	}
+
+	sort.Strings(newColumns)
+	return skyerr.NewErrorWithInfo(
+		skyerr.IncompatibleSchema,
+		fmt.Sprintf(
+			`cannot create fields %s of record type "%s" in strict schema mode`,
+			strings.Join(newColumns, ", "), recordType,
+		),
+		map[string]interface{}{"arguments": newColumns},
+	)
+}
+
+func (db *database) RenameSchema(recordType, oldName, newName string) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	return db.c.renameColumn(recordType, oldName, newName)
+}
+
+// renameColumn renames the column of the record type and the field of
+// its records.
+func (c *conn) renameColumn(recordType, oldName, newName string) error {
+	doc, err := c.getRecordType(recordType)
+	if err != nil {
+		return err
+	} else if doc == nil {
+		doc = &recordTypeDocument{}
+	}
+	found := -1
+	for i, column := range doc.Columns {
+		if column.Name == newName {
+			return fmt.Errorf("failed to rename column: column %s of %s already exists", newName, recordType)
+		}
+		if column.Name == oldName {
+			found = i
+		}
+	}
+	if found < 0 {
+		return fmt.Errorf("failed to rename column: column %s of %s does not exist", oldName, recordType)
+	}
+
+	doc.Columns[found].Name = newName
+	if err := c.collection(recordTypeCollection).UpdateId(recordType, doc); err != nil {
+		return err
+	}
+
+	_, err = c.collection(recordType).UpdateAll(
+		bson.M{oldName: bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{oldName: newName}},
+	)
+	return err
+}
+
+func (db *database) DeleteSchema(recordType, columnName string) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	doc, err := db.c.getRecordType(recordType)
+	if err != nil {
+		return err
+	} else if doc == nil {
+		doc = &recordTypeDocument{}
+	}
+	columns := []columnDocument{}
+	for _, column := range doc.Columns {
+		if column.Name != columnName {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) == len(doc.Columns) {
+		return fmt.Errorf("failed to delete column: column %s of %s does not exist", columnName, recordType)
+	}
+
+	doc.Columns = columns
+	if err := db.c.collection(recordTypeCollection).UpdateId(recordType, doc); err != nil {
+		return err
+	}
+
+	_, err = db.c.collection(recordType).UpdateAll(
+		bson.M{columnName: bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{columnName: ""}},
+	)
+	return err
+}
+
+type schemaMigrationDocument struct {
+	RecordType string    `bson:"record_type"`
+	Version    string    `bson:"version"`
+	AppliedAt  time.Time `bson:"applied_at"`
+}
+
+func (db *database) SchemaVersions() (map[string]string, error) {
+	results := []struct {
+		RecordType string `bson:"_id"`
+		Version    string `bson:"version"`
+	}{}
+	err := db.c.collection(schemaMigrationCollection).Pipe([]bson.M{
+		{"$group": bson.M{"_id": "$record_type", "version": bson.M{"$max": "$version"}}},
+	}).All(&results)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, result := range results {
+		versions[result.RecordType] = result.Version
+	}
+	return versions, nil
+}
+
+// MigrateSchema applies the migration even if the connection cannot
+// migrate, because a declarative migration is run explicitly by the
+// operator. Retyping a column does not convert the existing values.
+func (db *database) MigrateSchema(migration skydb.SchemaMigration) error {
+	if err := migration.Validate(); err != nil {
+		return skyerr.NewError(skyerr.InvalidArgument, err.Error())
+	}
+
+	applied, err := db.c.collection(schemaMigrationCollection).Find(bson.M{
+		"record_type": migration.RecordType,
+		"version":     migration.Version,
+	}).Count()
+	if err != nil {
+		return err
+	} else if applied > 0 {
+		return skyerr.NewErrorf(skyerr.Duplicated,
+			`migration "%s" of %s is already applied`, migration.Version, migration.RecordType)
+	}
+
+	if err := db.c.putColumns(migration.RecordType, skydb.RecordSchema{}); err != nil {
+		return err
+	}
+	for _, op := range migration.Operations {
+		var err error
+		switch op.Type {
+		case skydb.AddColumnOperation, skydb.RetypeColumnOperation:
+			err = db.c.putColumns(migration.RecordType, skydb.RecordSchema{op.Column: op.FieldType})
+		case skydb.RenameColumnOperation:
+			err = db.c.renameColumn(migration.RecordType, op.Column, op.NewName)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s: %s", op.Type, op.Column, err)
+		}
+	}
+
+	return db.c.collection(schemaMigrationCollection).Insert(schemaMigrationDocument{
+		RecordType: migration.RecordType,
+		Version:    migration.Version,
+		AppliedAt:  time.Now().UTC(),
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build mongo
+
+package mongo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// authDocument is the document of a user in the _auth collection. The
+// provider info is stored as a list, so that principal IDs, which may
+// contain dots, are not field names and can be indexed.
+type authDocument struct {
+	ID              string              `bson:"_id"`
+	HashedPassword  []byte              `bson:"password,omitempty"`
+	Principals      []principalDocument `bson:"principals"`
+	Roles           []string            `bson:"roles"`
+	TokenValidSince *time.Time          `bson:"token_valid_since,omitempty"`
+	LastSeenAt      *time.Time          `bson:"last_seen_at,omitempty"`
+}
+
+type principalDocument struct {
+	ID   string                 `bson:"id"`
+	Data map[string]interface{} `bson:"data"`
+}
+
+func newAuthDocument(authinfo *skydb.AuthInfo) authDocument {
+	doc := authDocument{
+		ID:              authinfo.ID,
+		HashedPassword:  authinfo.HashedPassword,
+		Principals:      []principalDocument{},
+		Roles:           authinfo.Roles,
+		TokenValidSince: utcTime(authinfo.TokenValidSince),
+		LastSeenAt:      utcTime(authinfo.LastSeenAt),
+	}
+	if doc.Roles == nil {
+		doc.Roles = []string{}
+	}
+	for principalID, data := range authinfo.ProviderInfo {
+		doc.Principals = append(doc.Principals, principalDocument{principalID, data})
+	}
+	return doc
+}
+
+func (c *conn) CreateAuth(authinfo *skydb.AuthInfo) error {
+	if err := c.EnsureRoles(authinfo.Roles); err != nil {
+		return skydb.ErrRoleUpdatesFailed
+	}
+
+	err := c.collection(authCollection).Insert(newAuthDocument(authinfo))
+	if mgo.IsDup(err) {
+		return skydb.ErrUserDuplicated
+	}
+	return err
+}
+
+func (c *conn) UpdateAuth(authinfo *skydb.AuthInfo) error {
+	if err := c.EnsureRoles(authinfo.Roles); err != nil {
+		return skydb.ErrRoleUpdatesFailed
+	}
+
+	err := c.collection(authCollection).UpdateId(authinfo.ID, newAuthDocument(authinfo))
+	if err == mgo.ErrNotFound {
+		return skydb.ErrUserNotFound
+	}
+	return err
+}
+
+func (c *conn) GetAuth(id string, authinfo *skydb.AuthInfo) error {
+	return c.getAuth(authinfo, bson.M{"_id": id})
+}
+
+func (c *conn) GetAuthByPrincipalID(principalID string, authinfo *skydb.AuthInfo) error {
+	return c.getAuth(authinfo, bson.M{"principals.id": principalID})
+}
+
+func (c *conn) getAuth(authinfo *skydb.AuthInfo, filter bson.M) error {
+	doc := authDocument{}
+	err := c.collection(authCollection).Find(filter).One(&doc)
+	if err == mgo.ErrNotFound {
+		return skydb.ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+
+	authinfo.ID = doc.ID
+	authinfo.HashedPassword = doc.HashedPassword
+	authinfo.ProviderInfo = nil
+	if len(doc.Principals) > 0 {
+		authinfo.ProviderInfo = skydb.ProviderInfo{}
+		for _, principal := range doc.Principals {
+			data, ok := normalizeBSON(principal.Data).(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("failed to decode provider info of %s", doc.ID)
+			}
+			authinfo.ProviderInfo[principal.ID] = data
+		}
+	}
+	authinfo.TokenValidSince = utcTime(doc.TokenValidSince)
+	authinfo.LastSeenAt = utcTime(doc.LastSeenAt)
+	authinfo.Roles = nil
+	if len(doc.Roles) > 0 {
+		authinfo.Roles = doc.Roles
+	}
+	return c.setInheritedRoles(authinfo)
+}
+
+func (c *conn) DeleteAuth(id string) error {
+	err := c.collection(authCollection).RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return skydb.ErrUserNotFound
+	}
+	return err
+}
+
+// EnsureAuthRecordKeysExist adds the auth record keys to the user record
+// type as string fields.
+func (c *conn) EnsureAuthRecordKeysExist(authRecordKeys [][]string) error {
+	db := c.PublicDB()
+	schema, err := db.GetSchema(db.UserRecordType())
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve user record schema")
+	}
+
+	schemaToExtend := skydb.RecordSchema{}
+	for _, keys := range authRecordKeys {
+		for _, key := range keys {
+			if _, ok := schema[key]; !ok {
+				schemaToExtend[key] = skydb.FieldType{Type: skydb.TypeString}
+			}
+		}
+	}
+	if len(schemaToExtend) == 0 {
+		return nil
+	}
+
+	_, err = db.Extend(db.UserRecordType(), schemaToExtend)
+	return err
+}
+
+// EnsureAuthRecordKeysIndexesMatch makes each group of auth record keys
+// unique among user records.
+func (c *conn) EnsureAuthRecordKeysIndexesMatch(authRecordKeys [][]string) error {
+	db := c.PublicDB()
+	indexes, err := db.GetIndexesByRecordType(db.UserRecordType())
+	if err != nil {
+		return err
+	}
+
+	for _, keys := range authRecordKeys {
+		name := authRecordKeysIndexName(keys)
+		if _, ok := indexes[name]; ok {
+			continue
+		}
+		if err := db.SaveIndex(db.UserRecordType(), name, skydb.Index{Fields: keys}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func authRecordKeysIndexName(keys []string) string {
+	name := "auth_record_keys_user"
+	for _, key := range keys {
+		name += "_" + key
+	}
+	return name + "_key"
+}
+
+// utcTime returns nil for a nil or zero time, which is not saved.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,252 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package offload
+
+import (
+	"io"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// NewConn returns a skydb.Conn of which the databases offload large
+// fields with the specified Offloader.
+func NewConn(c skydb.Conn, offloader *Offloader) skydb.Conn {
+	return &conn{c, offloader}
+}
+
+type conn struct {
+	skydb.Conn
+	offloader *Offloader
+}
+
+func (c *conn) PublicDB() skydb.Database {
+	return NewDatabase(c.Conn.PublicDB(), c.offloader)
+}
+
+func (c *conn) PrivateDB(userKey string) skydb.Database {
+	return NewDatabase(c.Conn.PrivateDB(userKey), c.offloader)
+}
+
+func (c *conn) UnionDB() skydb.Database {
+	return NewDatabase(c.Conn.UnionDB(), c.offloader)
+}
+
+// NewDatabase returns a skydb.Database offloading large fields on save
+// and rehydrating offloaded fields on read.
+//
+// The returned Database is also a skydb.Transactional and a
+// skydb.RelatedQuerier if the specified Database is.
+func NewDatabase(db skydb.Database, offloader *Offloader) skydb.Database {
+	offloadDB := &database{db, offloader}
+	txDB, transactional := db.(skydb.Transactional)
+	querier, ok := db.(skydb.RelatedQuerier)
+	if !ok {
+		if transactional {
+			return &txDatabase{offloadDB, txDB}
+		}
+		return offloadDB
+	}
+
+	related := &relatedQuerier{querier, offloader}
+	if transactional {
+		return &txRelatedDatabase{offloadDB, txDB, related}
+	}
+	return &relatedDatabase{offloadDB, related}
+}
+
+type database struct {
+	skydb.Database
+	offloader *Offloader
+}
+
+type txDatabase struct {
+	*database
+	skydb.Transactional
+}
+
+type relatedDatabase struct {
+	*database
+	*relatedQuerier
+}
+
+type txRelatedDatabase struct {
+	*database
+	skydb.Transactional
+	*relatedQuerier
+}
+
+// relatedQuerier rehydrates records queried by the underlying
+// RelatedQuerier.
+type relatedQuerier struct {
+	querier   skydb.RelatedQuerier
+	offloader *Offloader
+}
+
+func (q *relatedQuerier) QueryRelated(related *skydb.RelatedQuery, keys []string) (map[string]*skydb.RelatedPage, error) {
+	pages, err := q.querier.QueryRelated(related, keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range pages {
+		for i := range page.Records {
+			if err := q.offloader.Rehydrate(&page.Records[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pages, nil
+}
+
+func (db *database) Get(id skydb.RecordID, record *skydb.Record) error {
+	if err := db.Database.Get(id, record); err != nil {
+		return err
+	}
+	return db.offloader.Rehydrate(record)
+}
+
+func (db *database) GetByIDs(ids []skydb.RecordID) (*skydb.Rows, error) {
+	rows, err := db.Database.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	return skydb.NewRows(&rowsIter{rows, db.offloader}), nil
+}
+
+func (db *database) QueryStream(query *skydb.Query) (*skydb.Rows, error) {
+	rows, err := db.Database.QueryStream(query)
+	if err != nil {
+		return nil, err
+	}
+	return skydb.NewRows(&rowsIter{rows, db.offloader}), nil
+}
+
+func (db *database) Save(record *skydb.Record) error {
+	return db.saveOffloaded(record, db.Database.Save)
+}
+
+func (db *database) Merge(record *skydb.Record) error {
+	return db.saveOffloaded(record, db.Database.Merge)
+}
+
+func (db *database) SaveBulk(records []*skydb.Record) error {
+	saved := make([]*skydb.Record, len(records))
+	offloaded := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		savedRecord := *record
+		savedRecord.Data = copyData(record.Data)
+		var err error
+		if offloaded[i], err = db.offloader.Offload(&savedRecord); err != nil {
+			return err
+		}
+		saved[i] = &savedRecord
+	}
+
+	if err := db.Database.SaveBulk(saved); err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		*record = *saved[i]
+		record.Data = copyData(saved[i].Data)
+		for key, value := range offloaded[i] {
+			record.Data[key] = value
+		}
+	}
+	return nil
+}
+
+func (db *database) saveOffloaded(record *skydb.Record, save func(*skydb.Record) error) error {
+	// Offload a copy of the record so that the pointers are not visible
+	// to the caller, whether the record is saved or not.
+	saved := *record
+	saved.Data = copyData(record.Data)
+	offloaded, err := db.offloader.Offload(&saved)
+	if err != nil {
+		return err
+	}
+
+	if err := save(&saved); err != nil {
+		return err
+	}
+
+	*record = saved
+	record.Data = copyData(saved.Data)
+	for key, value := range offloaded {
+		record.Data[key] = value
+	}
+	return nil
+}
+
+func (db *database) Query(query *skydb.Query) (*skydb.Rows, error) {
+	rows, err := db.Database.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	return skydb.NewRows(&rowsIter{rows, db.offloader}), nil
+}
+
+// rowsIter rehydrates records scanned from the underlying Rows.
+type rowsIter struct {
+	rows      *skydb.Rows
+	offloader *Offloader
+}
+
+func (rs *rowsIter) Close() error {
+	return rs.rows.Close()
+}
+
+func (rs *rowsIter) Next(record *skydb.Record) error {
+	if !rs.rows.Scan() {
+		if err := rs.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	*record = rs.rows.Record()
+	return rs.offloader.Rehydrate(record)
+}
+
+func (rs *rowsIter) OverallRecordCount() *uint64 {
+	return rs.rows.OverallRecordCount()
+}
+
+func (rs *rowsIter) QueryPlan() interface{} {
+	return rs.rows.QueryPlan()
+}
+
+func (rs *rowsIter) IncludedRecords() map[string]map[string]*skydb.Record {
+	included := rs.rows.IncludedRecords()
+	for _, records := range included {
+		for _, record := range records {
+			if err := rs.offloader.Rehydrate(record); err != nil {
+				log.Warnf("Failed to rehydrate included record %s: %v", record.ID, err)
+			}
+		}
+	}
+	return included
+}
+
+func copyData(data skydb.Data) skydb.Data {
+	copied := skydb.Data{}
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied
+}
+
+func (rs *rowsIter) NextCursor() *skydb.Cursor {
+	return rs.rows.NextCursor()
+}
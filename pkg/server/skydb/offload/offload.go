@@ -0,0 +1,162 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package offload stores large record fields in an asset store instead
+// of the database, keeping table rows small.
+//
+// An offloaded field is replaced by a pointer in the database. The pointer
+// contains the name of the asset holding the field value and the SHA-256
+// hash of the value. A string field is replaced by a string pointer:
+//
+//     skygear-offload:{name}#{hash}
+//
+// while a JSON field is replaced by a JSON pointer:
+//
+//     {"$type": "offload", "$name": "{name}", "$sha256": "{hash}"}
+//
+// Offloaded fields are rehydrated transparently when records are read.
+package offload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+var log = logging.LoggerEntry("skydb")
+
+const stringPointerPrefix = "skygear-offload:"
+
+// Offloader offloads record fields exceeding a size threshold to an
+// asset store, and rehydrates offloaded fields from the asset store.
+type Offloader struct {
+	Store asset.Store
+
+	// Threshold is the size in bytes above which a field is offloaded.
+	Threshold int
+}
+
+// Offload replaces string and JSON fields of the record exceeding
+// the threshold with pointers to assets holding the field values.
+//
+// The original values of the offloaded fields are returned.
+func (o *Offloader) Offload(record *skydb.Record) (map[string]interface{}, error) {
+	offloaded := map[string]interface{}{}
+	for key, value := range record.Data {
+		var content []byte
+		var contentType string
+		switch v := value.(type) {
+		case string:
+			content = []byte(v)
+			contentType = "text/plain; charset=utf-8"
+		case map[string]interface{}, []interface{}:
+			var err error
+			if content, err = json.Marshal(v); err != nil {
+				return nil, err
+			}
+			contentType = "application/json"
+		default:
+			continue
+		}
+
+		if len(content) <= o.Threshold {
+			continue
+		}
+
+		hash := sha256.Sum256(content)
+		hexHash := hex.EncodeToString(hash[:])
+		name := "offload-" + hexHash
+		err := o.Store.PutFileReader(name, bytes.NewReader(content), int64(len(content)), contentType)
+		if err != nil {
+			return nil, fmt.Errorf("offload: failed to put field %s: %v", key, err)
+		}
+
+		log.Debugf("Offloaded field %s of record %s to %s", key, record.ID, name)
+		offloaded[key] = value
+		if _, ok := value.(string); ok {
+			record.Data[key] = stringPointerPrefix + name + "#" + hexHash
+		} else {
+			record.Data[key] = map[string]interface{}{
+				"$type":   "offload",
+				"$name":   name,
+				"$sha256": hexHash,
+			}
+		}
+	}
+	return offloaded, nil
+}
+
+// Rehydrate replaces pointers in the record with the offloaded values.
+func (o *Offloader) Rehydrate(record *skydb.Record) error {
+	for key, value := range record.Data {
+		switch v := value.(type) {
+		case string:
+			if !strings.HasPrefix(v, stringPointerPrefix) {
+				continue
+			}
+			ss := strings.SplitN(strings.TrimPrefix(v, stringPointerPrefix), "#", 2)
+			if len(ss) != 2 {
+				continue
+			}
+			content, err := o.get(ss[0], ss[1])
+			if err != nil {
+				return err
+			}
+			record.Data[key] = string(content)
+		case map[string]interface{}:
+			if v["$type"] != "offload" {
+				continue
+			}
+			name, _ := v["$name"].(string)
+			hexHash, _ := v["$sha256"].(string)
+			content, err := o.get(name, hexHash)
+			if err != nil {
+				return err
+			}
+			var jsonValue interface{}
+			if err := json.Unmarshal(content, &jsonValue); err != nil {
+				return fmt.Errorf("offload: failed to decode %s: %v", name, err)
+			}
+			record.Data[key] = jsonValue
+		}
+	}
+	return nil
+}
+
+func (o *Offloader) get(name string, hexHash string) ([]byte, error) {
+	reader, err := o.Store.GetFileReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("offload: failed to get %s: %v", name, err)
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("offload: failed to read %s: %v", name, err)
+	}
+
+	hash := sha256.Sum256(content)
+	if hex.EncodeToString(hash[:]) != hexHash {
+		return nil, fmt.Errorf("offload: content of %s does not match its hash", name)
+	}
+	return content, nil
+}
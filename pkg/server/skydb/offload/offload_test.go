@@ -0,0 +1,225 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package offload
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type memoryStore map[string][]byte
+
+func (s memoryStore) GetFileReader(name string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(s[name])), nil
+}
+
+func (s memoryStore) PutFileReader(name string, src io.Reader, length int64, contentType string) error {
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	s[name] = data
+	return nil
+}
+
+func (s memoryStore) GeneratePostFileRequest(name string) (*asset.PostFileRequest, error) {
+	panic("not implemented")
+}
+
+func TestOffloader(t *testing.T) {
+	Convey("Offloader", t, func() {
+		store := memoryStore{}
+		offloader := &Offloader{Store: store, Threshold: 16}
+		longText := strings.Repeat("lorem ipsum ", 4)
+
+		Convey("offloads and rehydrates large string", func() {
+			record := skydb.Record{
+				ID: skydb.NewRecordID("note", "1"),
+				Data: skydb.Data{
+					"title":   "short",
+					"content": longText,
+				},
+			}
+
+			offloaded, err := offloader.Offload(&record)
+			So(err, ShouldBeNil)
+			So(offloaded, ShouldResemble, map[string]interface{}{
+				"content": longText,
+			})
+			So(record.Data["title"], ShouldEqual, "short")
+			So(record.Data["content"], ShouldStartWith, "skygear-offload:offload-")
+			So(store, ShouldHaveLength, 1)
+
+			So(offloader.Rehydrate(&record), ShouldBeNil)
+			So(record.Data, ShouldResemble, skydb.Data{
+				"title":   "short",
+				"content": longText,
+			})
+		})
+
+		Convey("offloads and rehydrates large JSON", func() {
+			value := map[string]interface{}{
+				"text": longText,
+			}
+			record := skydb.Record{
+				ID: skydb.NewRecordID("note", "1"),
+				Data: skydb.Data{
+					"json": value,
+				},
+			}
+
+			_, err := offloader.Offload(&record)
+			So(err, ShouldBeNil)
+			pointer := record.Data["json"].(map[string]interface{})
+			So(pointer["$type"], ShouldEqual, "offload")
+
+			So(offloader.Rehydrate(&record), ShouldBeNil)
+			So(record.Data["json"], ShouldResemble, value)
+		})
+
+		Convey("errors on content not matching the hash", func() {
+			record := skydb.Record{
+				ID: skydb.NewRecordID("note", "1"),
+				Data: skydb.Data{
+					"content": longText,
+				},
+			}
+
+			_, err := offloader.Offload(&record)
+			So(err, ShouldBeNil)
+			for name := range store {
+				store[name] = []byte("tampered")
+			}
+
+			err = offloader.Rehydrate(&record)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "does not match its hash")
+		})
+	})
+}
+
+func TestDatabase(t *testing.T) {
+	Convey("Database", t, func() {
+		store := memoryStore{}
+		mapDB := skydbtest.NewMapDB()
+		db := NewDatabase(mapDB, &Offloader{Store: store, Threshold: 16})
+		longText := strings.Repeat("lorem ipsum ", 4)
+
+		record := skydb.Record{
+			ID: skydb.NewRecordID("note", "1"),
+			Data: skydb.Data{
+				"content": longText,
+			},
+		}
+		So(db.Save(&record), ShouldBeNil)
+
+		Convey("keeps the original value in saved record", func() {
+			So(record.Data["content"], ShouldEqual, longText)
+		})
+
+		Convey("stores pointer in the underlying database", func() {
+			saved := mapDB.RecordMap["note/1"]
+			So(saved.Data["content"], ShouldStartWith, "skygear-offload:")
+		})
+
+		Convey("rehydrates fetched record", func() {
+			fetched := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &fetched), ShouldBeNil)
+			So(fetched.Data["content"], ShouldEqual, longText)
+		})
+	})
+}
+
+// plannedRows is the rows of an explained query.
+type plannedRows struct {
+	*skydb.MemoryRows
+}
+
+func (rs *plannedRows) QueryPlan() interface{} {
+	return "Seq Scan on note"
+}
+
+// relatedMapDB is a MapDB which can explain queries and query related
+// records at once, like the pq database.
+type relatedMapDB struct {
+	*skydbtest.MapDB
+}
+
+func (db *relatedMapDB) Query(query *skydb.Query) (*skydb.Rows, error) {
+	records := []skydb.Record{}
+	for _, record := range db.RecordMap {
+		records = append(records, record)
+	}
+	return skydb.NewRows(&plannedRows{skydb.NewMemoryRows(records)}), nil
+}
+
+func (db *relatedMapDB) QueryRelated(related *skydb.RelatedQuery, keys []string) (map[string]*skydb.RelatedPage, error) {
+	pages := map[string]*skydb.RelatedPage{}
+	for _, key := range keys {
+		pages[key] = &skydb.RelatedPage{
+			Records: []skydb.Record{db.RecordMap["note/1"]},
+		}
+	}
+	return pages, nil
+}
+
+func TestDatabaseForwarding(t *testing.T) {
+	Convey("Database wrapping a related querier", t, func() {
+		store := memoryStore{}
+		mapDB := &relatedMapDB{skydbtest.NewMapDB()}
+		db := NewDatabase(mapDB, &Offloader{Store: store, Threshold: 16})
+		longText := strings.Repeat("lorem ipsum ", 4)
+
+		record := skydb.Record{
+			ID: skydb.NewRecordID("note", "1"),
+			Data: skydb.Data{
+				"content": longText,
+			},
+		}
+		So(db.Save(&record), ShouldBeNil)
+
+		Convey("forwards query plan", func() {
+			rows, err := db.Query(&skydb.Query{Type: "note", Explain: true})
+			So(err, ShouldBeNil)
+			defer rows.Close()
+
+			So(rows.Scan(), ShouldBeTrue)
+			fetched := rows.Record()
+			So(fetched.Data["content"], ShouldEqual, longText)
+			So(rows.QueryPlan(), ShouldEqual, "Seq Scan on note")
+		})
+
+		Convey("rehydrates related records queried at once", func() {
+			querier, ok := db.(skydb.RelatedQuerier)
+			So(ok, ShouldBeTrue)
+
+			pages, err := querier.QueryRelated(&skydb.RelatedQuery{
+				Query: skydb.Query{Type: "note"},
+				Field: "parent",
+			}, []string{"parent1"})
+			So(err, ShouldBeNil)
+			So(pages["parent1"].Records, ShouldHaveLength, 1)
+			So(pages["parent1"].Records[0].Data["content"], ShouldEqual, longText)
+		})
+	})
+}
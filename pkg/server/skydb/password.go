@@ -0,0 +1,332 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Password hashing algorithms supported by AuthInfo.
+//
+// Hashes other than bcrypt are kept in a self-describing PHC string, so
+// that passwords can be verified regardless of the algorithm preferred
+// by the server. Hashes generated with other algorithms or parameters
+// are upgraded on successful login.
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmScrypt   = "scrypt"
+	PasswordAlgorithmArgon2i  = "argon2i"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+// PasswordHasher generates password hashes with a specific algorithm and
+// parameters.
+type PasswordHasher interface {
+	// Hash returns the hash of password, in the form stored in
+	// AuthInfo.HashedPassword.
+	Hash(password string) ([]byte, error)
+
+	// NeedsRehash returns true if hashed is not generated by this hasher
+	// with the same parameters.
+	NeedsRehash(hashed []byte) bool
+}
+
+// PreferredPasswordHasher is the PasswordHasher used to hash passwords set
+// by users. It is configured when the server starts.
+var PreferredPasswordHasher PasswordHasher = BcryptHasher{Cost: bcrypt.DefaultCost}
+
+// BcryptHasher hashes passwords with bcrypt.
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash implements PasswordHasher.
+func (h BcryptHasher) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+}
+
+// NeedsRehash implements PasswordHasher.
+func (h BcryptHasher) NeedsRehash(hashed []byte) bool {
+	cost, err := bcrypt.Cost(hashed)
+	return err != nil || cost != h.Cost
+}
+
+// ScryptHasher hashes passwords with scrypt.
+type ScryptHasher struct {
+	N         int
+	R         int
+	P         int
+	KeyLength int
+}
+
+// Hash implements PasswordHasher.
+func (h ScryptHasher) Hash(password string) ([]byte, error) {
+	salt, err := newPasswordSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(password), salt, h.N, h.R, h.P, h.keyLength())
+	if err != nil {
+		return nil, err
+	}
+	return encodePHC(PasswordAlgorithmScrypt, h.params(), salt, key), nil
+}
+
+// NeedsRehash implements PasswordHasher.
+func (h ScryptHasher) NeedsRehash(hashed []byte) bool {
+	phc, ok := parsePHC(hashed)
+	return !ok ||
+		phc.algorithm != PasswordAlgorithmScrypt ||
+		phc.params["n"] != h.N || phc.params["r"] != h.R || phc.params["p"] != h.P ||
+		len(phc.key) != h.keyLength()
+}
+
+func (h ScryptHasher) params() string {
+	return fmt.Sprintf("n=%d,r=%d,p=%d", h.N, h.R, h.P)
+}
+
+func (h ScryptHasher) keyLength() int {
+	if h.KeyLength == 0 {
+		return 32
+	}
+	return h.KeyLength
+}
+
+// Argon2idHasher hashes passwords with argon2id. Memory is in KiB.
+type Argon2idHasher struct {
+	Memory      int
+	Iterations  int
+	Parallelism int
+	KeyLength   int
+}
+
+// Hash implements PasswordHasher.
+func (h Argon2idHasher) Hash(password string) ([]byte, error) {
+	salt, err := newPasswordSalt()
+	if err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey([]byte(password), salt,
+		uint32(h.Iterations), uint32(h.Memory), uint8(h.Parallelism), uint32(h.keyLength()))
+	return encodePHC(PasswordAlgorithmArgon2id, h.params(), salt, key), nil
+}
+
+// NeedsRehash implements PasswordHasher.
+func (h Argon2idHasher) NeedsRehash(hashed []byte) bool {
+	phc, ok := parsePHC(hashed)
+	return !ok ||
+		phc.algorithm != PasswordAlgorithmArgon2id ||
+		phc.params["v"] != argon2.Version ||
+		phc.params["m"] != h.Memory || phc.params["t"] != h.Iterations || phc.params["p"] != h.Parallelism ||
+		len(phc.key) != h.keyLength()
+}
+
+func (h Argon2idHasher) params() string {
+	return fmt.Sprintf("v=%d$m=%d,t=%d,p=%d", argon2.Version, h.Memory, h.Iterations, h.Parallelism)
+}
+
+func (h Argon2idHasher) keyLength() int {
+	if h.KeyLength == 0 {
+		return 32
+	}
+	return h.KeyLength
+}
+
+func newPasswordSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// ErrUnsupportedPasswordHash is returned when an imported password hash
+// uses an unknown algorithm or is malformed.
+var ErrUnsupportedPasswordHash = errors.New("skydb: unsupported password hash")
+
+// PasswordHash describes a password hash generated by a foreign system,
+// together with the parameters needed to verify it.
+//
+// For bcrypt, Hash is the modular crypt string (e.g. "$2a$10$...") and
+// the remaining fields are ignored. For scrypt and argon2, Hash and Salt
+// are base64 encoded (standard encoding, padding optional).
+type PasswordHash struct {
+	Algorithm string `mapstructure:"algorithm"`
+	Hash      string `mapstructure:"hash"`
+	Salt      string `mapstructure:"salt"`
+
+	// scrypt parameters
+	N int `mapstructure:"n"`
+	R int `mapstructure:"r"`
+
+	// argon2 parameters
+	Memory     int `mapstructure:"memory"`
+	Iterations int `mapstructure:"iterations"`
+
+	// P is the parallelization (scrypt) or parallelism (argon2) factor.
+	P int `mapstructure:"p"`
+}
+
+// Encode validates the hash and returns it in the form stored in
+// AuthInfo.HashedPassword.
+func (h PasswordHash) Encode() ([]byte, error) {
+	switch h.Algorithm {
+	case PasswordAlgorithmBcrypt:
+		if _, err := bcrypt.Cost([]byte(h.Hash)); err != nil {
+			return nil, ErrUnsupportedPasswordHash
+		}
+		return []byte(h.Hash), nil
+	case PasswordAlgorithmScrypt:
+		if h.N <= 1 || h.N&(h.N-1) != 0 || h.R <= 0 || h.P <= 0 {
+			return nil, ErrUnsupportedPasswordHash
+		}
+		return h.encodePHC(ScryptHasher{N: h.N, R: h.R, P: h.P}.params())
+	case PasswordAlgorithmArgon2i, PasswordAlgorithmArgon2id:
+		if h.Memory <= 0 || h.Iterations <= 0 || h.P <= 0 || h.P > 255 {
+			return nil, ErrUnsupportedPasswordHash
+		}
+		return h.encodePHC(Argon2idHasher{Memory: h.Memory, Iterations: h.Iterations, Parallelism: h.P}.params())
+	}
+	return nil, ErrUnsupportedPasswordHash
+}
+
+func (h PasswordHash) encodePHC(params string) ([]byte, error) {
+	salt, err := decodePHCBase64(h.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, ErrUnsupportedPasswordHash
+	}
+	key, err := decodePHCBase64(h.Hash)
+	if err != nil || len(key) == 0 {
+		return nil, ErrUnsupportedPasswordHash
+	}
+
+	return encodePHC(h.Algorithm, params, salt, key), nil
+}
+
+func encodePHC(algorithm string, params string, salt []byte, key []byte) []byte {
+	phc := fmt.Sprintf("$%s$%s$%s$%s",
+		algorithm,
+		params,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+	return []byte(phc)
+}
+
+func decodePHCBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+type phcHash struct {
+	algorithm string
+	params    map[string]int
+	salt      []byte
+	key       []byte
+}
+
+// parsePHC parses a hash in the form of $alg$params...$salt$key.
+func parsePHC(hashed []byte) (phc phcHash, ok bool) {
+	fields := strings.Split(string(hashed), "$")
+	if len(fields) < 5 || fields[0] != "" {
+		return
+	}
+
+	var err error
+	phc.algorithm = fields[1]
+	phc.params = parsePHCParams(fields[2 : len(fields)-2])
+	if phc.salt, err = decodePHCBase64(fields[len(fields)-2]); err != nil {
+		return
+	}
+	if phc.key, err = decodePHCBase64(fields[len(fields)-1]); err != nil {
+		return
+	}
+	ok = true
+	return
+}
+
+// isBcryptHash returns true if the stored hash is generated by bcrypt.
+func isBcryptHash(hashed []byte) bool {
+	return bytes.HasPrefix(hashed, []byte("$2"))
+}
+
+// comparePasswordHash verifies password against a stored hash, which is
+// either a bcrypt hash or a PHC string.
+func comparePasswordHash(hashed []byte, password string) bool {
+	if len(hashed) == 0 {
+		return false
+	}
+	if isBcryptHash(hashed) {
+		return bcrypt.CompareHashAndPassword(hashed, []byte(password)) == nil
+	}
+
+	phc, ok := parsePHC(hashed)
+	if !ok {
+		return false
+	}
+	params, salt, key := phc.params, phc.salt, phc.key
+
+	var (
+		derived []byte
+		err     error
+	)
+	switch phc.algorithm {
+	case PasswordAlgorithmScrypt:
+		derived, err = scrypt.Key([]byte(password), salt, params["n"], params["r"], params["p"], len(key))
+		if err != nil {
+			return false
+		}
+	case PasswordAlgorithmArgon2i, PasswordAlgorithmArgon2id:
+		if params["v"] != argon2.Version || params["p"] <= 0 || params["p"] > 255 {
+			return false
+		}
+		t, m, p := uint32(params["t"]), uint32(params["m"]), uint8(params["p"])
+		if phc.algorithm == PasswordAlgorithmArgon2i {
+			derived = argon2.Key([]byte(password), salt, t, m, p, uint32(len(key)))
+		} else {
+			derived = argon2.IDKey([]byte(password), salt, t, m, p, uint32(len(key)))
+		}
+	default:
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(derived, key) == 1
+}
+
+func parsePHCParams(sections []string) map[string]int {
+	params := map[string]int{}
+	for _, section := range sections {
+		for _, pair := range strings.Split(section, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if v, err := strconv.Atoi(kv[1]); err == nil {
+				params[kv[0]] = v
+			}
+		}
+	}
+	return params
+}
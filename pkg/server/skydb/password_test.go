@@ -0,0 +1,189 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"encoding/base64"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+func TestImportedPasswordHash(t *testing.T) {
+	salt := []byte("somesaltsomesalt")
+	b64 := base64.StdEncoding.EncodeToString
+
+	Convey("AuthInfo with imported password hash", t, func() {
+		info := AuthInfo{}
+
+		Convey("verifies bcrypt hash", func() {
+			hashed, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.DefaultCost)
+			err := info.SetPasswordHash(PasswordHash{
+				Algorithm: "bcrypt",
+				Hash:      string(hashed),
+			})
+			So(err, ShouldBeNil)
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.IsSamePassword("wrong"), ShouldBeFalse)
+			So(info.NeedsRehash(), ShouldBeFalse)
+		})
+
+		Convey("verifies scrypt hash", func() {
+			key, _ := scrypt.Key([]byte("secret"), salt, 1024, 8, 1, 32)
+			err := info.SetPasswordHash(PasswordHash{
+				Algorithm: "scrypt",
+				Hash:      b64(key),
+				Salt:      b64(salt),
+				N:         1024,
+				R:         8,
+				P:         1,
+			})
+			So(err, ShouldBeNil)
+			So(string(info.HashedPassword), ShouldStartWith, "$scrypt$n=1024,r=8,p=1$")
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.IsSamePassword("wrong"), ShouldBeFalse)
+			So(info.NeedsRehash(), ShouldBeTrue)
+		})
+
+		Convey("verifies argon2id hash", func() {
+			key := argon2.IDKey([]byte("secret"), salt, 1, 1024, 2, 32)
+			err := info.SetPasswordHash(PasswordHash{
+				Algorithm:  "argon2id",
+				Hash:       b64(key),
+				Salt:       b64(salt),
+				Memory:     1024,
+				Iterations: 1,
+				P:          2,
+			})
+			So(err, ShouldBeNil)
+			So(string(info.HashedPassword), ShouldStartWith, "$argon2id$v=19$m=1024,t=1,p=2$")
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.IsSamePassword("wrong"), ShouldBeFalse)
+		})
+
+		Convey("verifies argon2i hash", func() {
+			key := argon2.Key([]byte("secret"), salt, 2, 512, 1, 16)
+			err := info.SetPasswordHash(PasswordHash{
+				Algorithm:  "argon2i",
+				Hash:       b64(key),
+				Salt:       b64(salt),
+				Memory:     512,
+				Iterations: 2,
+				P:          1,
+			})
+			So(err, ShouldBeNil)
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+		})
+
+		Convey("rehashes to bcrypt without invalidating tokens", func() {
+			key, _ := scrypt.Key([]byte("secret"), salt, 1024, 8, 1, 32)
+			So(info.SetPasswordHash(PasswordHash{
+				Algorithm: "scrypt",
+				Hash:      b64(key),
+				Salt:      b64(salt),
+				N:         1024,
+				R:         8,
+				P:         1,
+			}), ShouldBeNil)
+
+			info.RehashPassword("secret")
+			So(info.NeedsRehash(), ShouldBeFalse)
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.TokenValidSince, ShouldBeNil)
+		})
+
+		Convey("rejects unsupported hashes", func() {
+			So(info.SetPasswordHash(PasswordHash{
+				Algorithm: "md5",
+				Hash:      "5ebe2294ecd0e0f08eab7690d2a6ee69",
+			}), ShouldEqual, ErrUnsupportedPasswordHash)
+			So(info.SetPasswordHash(PasswordHash{
+				Algorithm: "bcrypt",
+				Hash:      "not-a-bcrypt-hash",
+			}), ShouldEqual, ErrUnsupportedPasswordHash)
+			So(info.SetPasswordHash(PasswordHash{
+				Algorithm: "scrypt",
+				Hash:      b64([]byte("key")),
+				Salt:      b64(salt),
+				N:         1000,
+				R:         8,
+				P:         1,
+			}), ShouldEqual, ErrUnsupportedPasswordHash)
+			So(info.HashedPassword, ShouldBeNil)
+		})
+	})
+}
+
+func TestPasswordHasher(t *testing.T) {
+	Convey("PasswordHasher", t, func() {
+		Convey("bcrypt hasher", func() {
+			hasher := BcryptHasher{Cost: bcrypt.MinCost}
+			hashed, err := hasher.Hash("secret")
+			So(err, ShouldBeNil)
+			So(comparePasswordHash(hashed, "secret"), ShouldBeTrue)
+			So(comparePasswordHash(hashed, "wrong"), ShouldBeFalse)
+			So(hasher.NeedsRehash(hashed), ShouldBeFalse)
+			So(BcryptHasher{Cost: bcrypt.MinCost + 1}.NeedsRehash(hashed), ShouldBeTrue)
+		})
+
+		Convey("scrypt hasher", func() {
+			hasher := ScryptHasher{N: 1024, R: 8, P: 1}
+			hashed, err := hasher.Hash("secret")
+			So(err, ShouldBeNil)
+			So(string(hashed), ShouldStartWith, "$scrypt$n=1024,r=8,p=1$")
+			So(comparePasswordHash(hashed, "secret"), ShouldBeTrue)
+			So(comparePasswordHash(hashed, "wrong"), ShouldBeFalse)
+			So(hasher.NeedsRehash(hashed), ShouldBeFalse)
+			So(ScryptHasher{N: 2048, R: 8, P: 1}.NeedsRehash(hashed), ShouldBeTrue)
+			So(BcryptHasher{Cost: bcrypt.MinCost}.NeedsRehash(hashed), ShouldBeTrue)
+		})
+
+		Convey("argon2id hasher", func() {
+			hasher := Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 2}
+			hashed, err := hasher.Hash("secret")
+			So(err, ShouldBeNil)
+			So(string(hashed), ShouldStartWith, "$argon2id$v=19$m=1024,t=1,p=2$")
+			So(comparePasswordHash(hashed, "secret"), ShouldBeTrue)
+			So(comparePasswordHash(hashed, "wrong"), ShouldBeFalse)
+			So(hasher.NeedsRehash(hashed), ShouldBeFalse)
+			So(Argon2idHasher{Memory: 1024, Iterations: 2, Parallelism: 2}.NeedsRehash(hashed), ShouldBeTrue)
+			So(ScryptHasher{N: 1024, R: 8, P: 1}.NeedsRehash(hashed), ShouldBeTrue)
+		})
+
+		Convey("upgrades stored hash to the preferred hasher", func() {
+			originalHasher := PreferredPasswordHasher
+			defer func() {
+				PreferredPasswordHasher = originalHasher
+			}()
+
+			PreferredPasswordHasher = BcryptHasher{Cost: bcrypt.MinCost}
+			info := AuthInfo{}
+			info.SetPassword("secret")
+			So(info.NeedsRehash(), ShouldBeFalse)
+
+			PreferredPasswordHasher = Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1}
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.NeedsRehash(), ShouldBeTrue)
+
+			info.RehashPassword("secret")
+			So(string(info.HashedPassword), ShouldStartWith, "$argon2id$")
+			So(info.IsSamePassword("secret"), ShouldBeTrue)
+			So(info.NeedsRehash(), ShouldBeFalse)
+		})
+	})
+}
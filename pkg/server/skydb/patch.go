@@ -0,0 +1,390 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JSONPatch is a field value which applies the JSON Patch (RFC 6902)
+// operations to the current value of a JSON field when the record is
+// saved, instead of replacing it. The operations are applied in order,
+// and the field is not changed if any of them fails.
+type JSONPatch []JSONPatchOperation
+
+// JSONPatchOperation is an operation of a JSONPatch. Path and From are
+// JSON pointers (RFC 6901).
+type JSONPatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// MergePatch is a field value which merges the JSON merge patch
+// (RFC 7386) into the current value of a JSON field when the record is
+// saved, instead of replacing it. Keys with a nil value are removed.
+type MergePatch map[string]interface{}
+
+// TextPatch is a field value which splices the current value of a string
+// field when the record is saved, instead of replacing it. The splices
+// are applied in order, so the offset of a splice is in the string
+// spliced by the previous ones.
+type TextPatch []TextSplice
+
+// TextSplice deletes Delete characters at Offset of a string and
+// inserts Insert in their place. Offset and Delete are counted in
+// Unicode code points.
+type TextSplice struct {
+	Offset int
+	Delete int
+	Insert string
+}
+
+var arrayIndexPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+// ParseJSONPointer returns the reference tokens of the JSON pointer,
+// which is empty for the pointer to the whole document.
+func ParseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf(`json pointer "%s" does not start with "/"`, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.Replace(token, "~1", "/", -1)
+		tokens[i] = strings.Replace(token, "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// Validate returns an error if an operation of the patch is unknown or
+// is missing its arguments.
+func (p JSONPatch) Validate() error {
+	if len(p) == 0 {
+		return errors.New("json patch has no operations")
+	}
+
+	for _, op := range p {
+		if _, err := ParseJSONPointer(op.Path); err != nil {
+			return err
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+		case "remove":
+			if op.Path == "" {
+				return errors.New("json patch cannot remove the whole document")
+			}
+		case "move", "copy":
+			if _, err := ParseJSONPointer(op.From); err != nil {
+				return err
+			}
+			if op.Op == "move" && op.Path != op.From && strings.HasPrefix(op.Path+"/", op.From+"/") {
+				return fmt.Errorf(`json patch cannot move "%s" into itself`, op.From)
+			}
+		default:
+			return fmt.Errorf(`unknown json patch operation "%s"`, op.Op)
+		}
+	}
+	return nil
+}
+
+// Apply returns the result of applying the patch to the JSON value,
+// which is not modified.
+func (p JSONPatch) Apply(target interface{}) (interface{}, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	doc := copyJSON(target)
+	for _, op := range p {
+		path, _ := ParseJSONPointer(op.Path)
+
+		var err error
+		switch op.Op {
+		case "add":
+			doc, err = jsonAdd(doc, path, copyJSON(op.Value))
+		case "remove":
+			doc, err = jsonRemove(doc, path)
+		case "replace":
+			if _, err = jsonGet(doc, path); err != nil {
+				break
+			}
+			if len(path) == 0 {
+				doc = copyJSON(op.Value)
+				break
+			}
+			if doc, err = jsonRemove(doc, path); err == nil {
+				doc, err = jsonAdd(doc, path, copyJSON(op.Value))
+			}
+		case "move", "copy":
+			from, _ := ParseJSONPointer(op.From)
+			var value interface{}
+			if value, err = jsonGet(doc, from); err != nil {
+				break
+			}
+			if op.Op == "move" {
+				if doc, err = jsonRemove(doc, from); err != nil {
+					break
+				}
+			} else {
+				value = copyJSON(value)
+			}
+			doc, err = jsonAdd(doc, path, value)
+		case "test":
+			var value interface{}
+			if value, err = jsonGet(doc, path); err == nil && !reflect.DeepEqual(value, op.Value) {
+				err = fmt.Errorf(`json patch test of "%s" failed`, op.Path)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func jsonGet(doc interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf(`json patch path "%s" does not exist`, strings.Join(path[:i+1], "/"))
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[index]
+		default:
+			return nil, fmt.Errorf(`json patch path "%s" does not exist`, strings.Join(path[:i+1], "/"))
+		}
+	}
+	return doc, nil
+}
+
+// jsonSet replaces the existing value at the path.
+func jsonSet(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := jsonGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[token] = value
+	case []interface{}:
+		index, err := arrayIndex(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = value
+	}
+	return doc, nil
+}
+
+func jsonAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parentPath := path[:len(path)-1]
+	parent, err := jsonGet(doc, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[token] = value
+		return doc, nil
+	case []interface{}:
+		index := len(container)
+		if token != "-" {
+			if index, err = arrayIndex(token, len(container)); err != nil {
+				return nil, err
+			}
+		}
+		items := make([]interface{}, 0, len(container)+1)
+		items = append(items, container[:index]...)
+		items = append(items, value)
+		items = append(items, container[index:]...)
+		return jsonSet(doc, parentPath, items)
+	default:
+		return nil, fmt.Errorf(`json patch path "%s" has no parent object or array`, strings.Join(path, "/"))
+	}
+}
+
+func jsonRemove(doc interface{}, path []string) (interface{}, error) {
+	if _, err := jsonGet(doc, path); err != nil {
+		return nil, err
+	}
+
+	parentPath := path[:len(path)-1]
+	parent, _ := jsonGet(doc, parentPath)
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		delete(container, token)
+	case []interface{}:
+		index, _ := arrayIndex(token, len(container)-1)
+		items := make([]interface{}, 0, len(container)-1)
+		items = append(items, container[:index]...)
+		items = append(items, container[index+1:]...)
+		return jsonSet(doc, parentPath, items)
+	}
+	return doc, nil
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	if !arrayIndexPattern.MatchString(token) {
+		return 0, fmt.Errorf(`json patch array index "%s" is invalid`, token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index > max {
+		return 0, fmt.Errorf(`json patch array index "%s" is out of bounds`, token)
+	}
+	return index, nil
+}
+
+// copyJSON returns a deep copy of the maps and slices of the JSON value.
+func copyJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = copyJSON(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = copyJSON(item)
+		}
+		return s
+	default:
+		return value
+	}
+}
+
+// Apply returns the result of merging the patch into the JSON value,
+// which is not modified. A value which is not an object is replaced by
+// the patch.
+func (p MergePatch) Apply(target interface{}) interface{} {
+	return mergePatch(target, map[string]interface{}(p))
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return copyJSON(patch)
+	}
+
+	result := map[string]interface{}{}
+	if targetMap, ok := target.(map[string]interface{}); ok {
+		for key, value := range targetMap {
+			result[key] = value
+		}
+	}
+	for key, value := range patchMap {
+		if value == nil {
+			delete(result, key)
+		} else {
+			result[key] = mergePatch(result[key], value)
+		}
+	}
+	return result
+}
+
+// CreateMergePatch returns the JSON merge patch which changes from into
+// to. Removed keys are nil in the patch, so keys with a nil value in to
+// are not distinguished from removed keys.
+func CreateMergePatch(from map[string]interface{}, to map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, value := range to {
+		old, ok := from[key]
+		if ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+
+		oldMap, oldIsMap := old.(map[string]interface{})
+		valueMap, valueIsMap := value.(map[string]interface{})
+		if oldIsMap && valueIsMap {
+			patch[key] = CreateMergePatch(oldMap, valueMap)
+		} else {
+			patch[key] = value
+		}
+	}
+	return patch
+}
+
+// Validate returns an error if a splice of the patch has a negative
+// offset or length.
+func (p TextPatch) Validate() error {
+	if len(p) == 0 {
+		return errors.New("text patch has no splices")
+	}
+	for _, splice := range p {
+		if splice.Offset < 0 || splice.Delete < 0 {
+			return errors.New("text patch cannot splice at negative offset or length")
+		}
+	}
+	return nil
+}
+
+// Apply returns the result of splicing the string.
+func (p TextPatch) Apply(target string) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	text := []rune(target)
+	for _, splice := range p {
+		if splice.Offset > len(text) {
+			return "", fmt.Errorf("text patch offset %d is out of bounds", splice.Offset)
+		}
+		end := splice.Offset + splice.Delete
+		if end > len(text) {
+			end = len(text)
+		}
+
+		spliced := make([]rune, 0, len(text))
+		spliced = append(spliced, text[:splice.Offset]...)
+		spliced = append(spliced, []rune(splice.Insert)...)
+		spliced = append(spliced, text[end:]...)
+		text = spliced
+	}
+	return string(text), nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONPatch(t *testing.T) {
+	Convey("ParseJSONPointer", t, func() {
+		path, err := ParseJSONPointer("")
+		So(err, ShouldBeNil)
+		So(path, ShouldBeEmpty)
+
+		path, err = ParseJSONPointer("/a~1b/c~0d/0")
+		So(err, ShouldBeNil)
+		So(path, ShouldResemble, []string{"a/b", "c~d", "0"})
+
+		_, err = ParseJSONPointer("a")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("JSONPatch", t, func() {
+		doc := map[string]interface{}{
+			"title": "note",
+			"tags":  []interface{}{"a", "b"},
+			"meta":  map[string]interface{}{"pinned": true},
+		}
+
+		Convey("applies operations in order", func() {
+			patched, err := JSONPatch{
+				{Op: "add", Path: "/tags/1", Value: "x"},
+				{Op: "add", Path: "/tags/-", Value: "c"},
+				{Op: "remove", Path: "/meta/pinned"},
+				{Op: "replace", Path: "/title", Value: "memo"},
+				{Op: "copy", From: "/title", Path: "/meta/title"},
+				{Op: "move", From: "/tags/0", Path: "/first"},
+				{Op: "test", Path: "/first", Value: "a"},
+			}.Apply(doc)
+			So(err, ShouldBeNil)
+			So(patched, ShouldResemble, map[string]interface{}{
+				"title": "memo",
+				"first": "a",
+				"tags":  []interface{}{"x", "b", "c"},
+				"meta":  map[string]interface{}{"title": "memo"},
+			})
+		})
+
+		Convey("does not modify the document", func() {
+			_, err := JSONPatch{
+				{Op: "add", Path: "/meta/color", Value: "red"},
+				{Op: "remove", Path: "/tags/0"},
+			}.Apply(doc)
+			So(err, ShouldBeNil)
+			So(doc["meta"], ShouldResemble, map[string]interface{}{"pinned": true})
+			So(doc["tags"], ShouldResemble, []interface{}{"a", "b"})
+		})
+
+		Convey("fails on failed test", func() {
+			_, err := JSONPatch{{Op: "test", Path: "/title", Value: "memo"}}.Apply(doc)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("fails on missing path", func() {
+			_, err := JSONPatch{{Op: "remove", Path: "/missing"}}.Apply(doc)
+			So(err, ShouldNotBeNil)
+
+			_, err = JSONPatch{{Op: "add", Path: "/missing/key", Value: 1}}.Apply(doc)
+			So(err, ShouldNotBeNil)
+
+			_, err = JSONPatch{{Op: "add", Path: "/tags/3", Value: 1}}.Apply(doc)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rejects unknown operation", func() {
+			So(JSONPatch{{Op: "append", Path: "/tags"}}.Validate(), ShouldNotBeNil)
+			So(JSONPatch{}.Validate(), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMergePatch(t *testing.T) {
+	Convey("MergePatch", t, func() {
+		Convey("merges patch recursively", func() {
+			So(MergePatch{
+				"title": "memo",
+				"meta":  map[string]interface{}{"pinned": nil, "color": "red"},
+				"tags":  nil,
+			}.Apply(map[string]interface{}{
+				"title": "note",
+				"tags":  []interface{}{"a"},
+				"meta":  map[string]interface{}{"pinned": true},
+			}), ShouldResemble, map[string]interface{}{
+				"title": "memo",
+				"meta":  map[string]interface{}{"color": "red"},
+			})
+		})
+
+		Convey("replaces non-object value", func() {
+			So(MergePatch{"a": float64(1)}.Apply(nil), ShouldResemble, map[string]interface{}{"a": float64(1)})
+			So(MergePatch{"a": float64(1)}.Apply("text"), ShouldResemble, map[string]interface{}{"a": float64(1)})
+		})
+	})
+
+	Convey("CreateMergePatch", t, func() {
+		from := map[string]interface{}{
+			"title":   "note",
+			"content": "long",
+			"meta":    map[string]interface{}{"pinned": true, "color": "red"},
+		}
+		to := map[string]interface{}{
+			"content": "long",
+			"meta":    map[string]interface{}{"pinned": true, "color": "blue"},
+			"tags":    []interface{}{"a"},
+		}
+
+		patch := CreateMergePatch(from, to)
+		So(patch, ShouldResemble, map[string]interface{}{
+			"title": nil,
+			"meta":  map[string]interface{}{"color": "blue"},
+			"tags":  []interface{}{"a"},
+		})
+		So(MergePatch(patch).Apply(from), ShouldResemble, to)
+	})
+}
+
+func TestTextPatch(t *testing.T) {
+	Convey("TextPatch", t, func() {
+		Convey("splices in order", func() {
+			text, err := TextPatch{
+				{Offset: 6, Delete: 5, Insert: "there"},
+				{Offset: 0, Delete: 0, Insert: "oh, "},
+			}.Apply("hello world")
+			So(err, ShouldBeNil)
+			So(text, ShouldEqual, "oh, hello there")
+		})
+
+		Convey("counts offset in characters", func() {
+			text, err := TextPatch{{Offset: 1, Delete: 1, Insert: "好"}}.Apply("你們嗎")
+			So(err, ShouldBeNil)
+			So(text, ShouldEqual, "你好嗎")
+		})
+
+		Convey("deletes to the end", func() {
+			text, err := TextPatch{{Offset: 5, Delete: 100}}.Apply("hello world")
+			So(err, ShouldBeNil)
+			So(text, ShouldEqual, "hello")
+		})
+
+		Convey("fails on offset out of bounds", func() {
+			_, err := TextPatch{{Offset: 6}}.Apply("hello")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
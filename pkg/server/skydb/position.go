@@ -0,0 +1,150 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// positionDigits are the digits of a position in ascending order. The
+// digits are ordered by their byte values, so that positions are sorted
+// by comparing them as strings.
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const positionBase = len(positionDigits)
+
+// IsValidPosition returns true if the string is a valid value of a
+// position field.
+//
+// A position is a fraction between 0 and 1 written in base 62 without
+// the leading "0.", such that a new position can always be found between
+// two positions. A position must not end with the digit 0, otherwise
+// there is no position between "V" and "V0".
+func IsValidPosition(position string) bool {
+	if position == "" || position[len(position)-1] == positionDigits[0] {
+		return false
+	}
+	for i := 0; i < len(position); i++ {
+		if strings.IndexByte(positionDigits, position[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// PositionBetween returns a position sorted after before and before
+// after. An empty before denotes the start of the list and an empty
+// after denotes the end of the list, so PositionBetween("", "") returns
+// the position of the only item of a list.
+//
+// The returned position is at most one digit longer than the longer one
+// of before and after, which means positions grow longer when items are
+// inserted repeatedly at the same place. Such positions can be shortened
+// by rebalancing the list with EvenPositions.
+func PositionBetween(before string, after string) (string, error) {
+	if before != "" && !IsValidPosition(before) {
+		return "", fmt.Errorf("invalid position = %#v", before)
+	}
+	if after != "" && !IsValidPosition(after) {
+		return "", fmt.Errorf("invalid position = %#v", after)
+	}
+	if before != "" && after != "" && before >= after {
+		return "", fmt.Errorf("position %#v is not before %#v", before, after)
+	}
+	return positionMidpoint(before, after), nil
+}
+
+// positionMidpoint returns a position between a and b, where an empty
+// b denotes the end of the list.
+func positionMidpoint(a string, b string) string {
+	if b != "" {
+		// Skip the common prefix, where a is padded with the digit 0
+		// to compare with b.
+		n := 0
+		for n < len(b) && positionDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			if n > len(a) {
+				return b[:n] + positionMidpoint("", b[n:])
+			}
+			return b[:n] + positionMidpoint(a[n:], b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(positionDigits, a[0])
+	}
+	digitB := positionBase
+	if b != "" {
+		digitB = strings.IndexByte(positionDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(positionDigits[(digitA+digitB+1)/2])
+	}
+
+	// The first digits are adjacent. Truncating b gives a position
+	// between a and b if b has more digits, otherwise the position is
+	// found after the first digit of a.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	if a == "" {
+		return string(positionDigits[digitA]) + positionMidpoint("", "")
+	}
+	return a[:1] + positionMidpoint(a[1:], "")
+}
+
+func positionDigitAt(position string, i int) byte {
+	if i < len(position) {
+		return position[i]
+	}
+	return positionDigits[0]
+}
+
+// EvenPositions returns n positions in ascending order that are evenly
+// spaced, using the fewest digits that leave room for inserting
+// positions of the same length between every two positions.
+func EvenPositions(n int) []string {
+	if n <= 0 {
+		return []string{}
+	}
+
+	length := 1
+	space := uint64(positionBase)
+	for space/uint64(n+1) < uint64(positionBase) {
+		space *= uint64(positionBase)
+		length++
+	}
+
+	step := space / uint64(n+1)
+	positions := make([]string, n)
+	for i := range positions {
+		positions[i] = encodePosition(uint64(i+1)*step, length)
+	}
+	return positions
+}
+
+func encodePosition(value uint64, length int) string {
+	digits := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		digits[i] = positionDigits[value%uint64(positionBase)]
+		value /= uint64(positionBase)
+	}
+	return strings.TrimRight(string(digits), positionDigits[:1])
+}
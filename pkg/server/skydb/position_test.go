@@ -0,0 +1,108 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPosition(t *testing.T) {
+	Convey("PositionBetween", t, func() {
+		Convey("returns position of empty list", func() {
+			position, err := PositionBetween("", "")
+			So(err, ShouldBeNil)
+			So(position, ShouldEqual, "V")
+		})
+
+		Convey("returns position between positions", func() {
+			position, err := PositionBetween("A", "C")
+			So(err, ShouldBeNil)
+			So(position, ShouldEqual, "B")
+		})
+
+		Convey("returns longer position between adjacent positions", func() {
+			position, err := PositionBetween("V", "W")
+			So(err, ShouldBeNil)
+			So(position, ShouldEqual, "VV")
+		})
+
+		Convey("returns position at the start and the end of list", func() {
+			position, err := PositionBetween("", "1")
+			So(err, ShouldBeNil)
+			So(position, ShouldEqual, "0V")
+
+			position, err = PositionBetween("z", "")
+			So(err, ShouldBeNil)
+			So(position, ShouldEqual, "zV")
+		})
+
+		Convey("keeps positions sorted on repeated insertions", func() {
+			positions := []string{}
+			for i := 0; i < 200; i++ {
+				before, after := "", ""
+				j := i % 3 * len(positions) / 2
+				if j > 0 {
+					before = positions[j-1]
+				}
+				if j < len(positions) {
+					after = positions[j]
+				}
+
+				position, err := PositionBetween(before, after)
+				So(err, ShouldBeNil)
+				So(IsValidPosition(position), ShouldBeTrue)
+				positions = append(positions[:j], append([]string{position}, positions[j:]...)...)
+			}
+			So(sort.StringsAreSorted(positions), ShouldBeTrue)
+		})
+
+		Convey("returns error on invalid positions", func() {
+			_, err := PositionBetween("V0", "")
+			So(err, ShouldNotBeNil)
+
+			_, err = PositionBetween("", "a-b")
+			So(err, ShouldNotBeNil)
+
+			_, err = PositionBetween("W", "V")
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("EvenPositions", t, func() {
+		Convey("returns sorted and distinct positions", func() {
+			positions := EvenPositions(1000)
+			So(len(positions), ShouldEqual, 1000)
+			So(sort.StringsAreSorted(positions), ShouldBeTrue)
+			for i, position := range positions {
+				So(IsValidPosition(position), ShouldBeTrue)
+				if i > 0 {
+					So(position, ShouldNotEqual, positions[i-1])
+				}
+			}
+		})
+
+		Convey("returns short positions", func() {
+			So(EvenPositions(2), ShouldResemble, []string{"Kf", "fK"})
+			So(len(EvenPositions(1000)[0]), ShouldBeLessThanOrEqualTo, 3)
+		})
+
+		Convey("returns no positions", func() {
+			So(EvenPositions(0), ShouldBeEmpty)
+		})
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/skydb/pq/builder"
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 	"github.com/skygeario/skygear-server/pkg/server/utils"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
 )
 
 func (c *conn) SetRecordAccess(recordType string, acl skydb.RecordACL) error {
@@ -148,7 +149,10 @@ func (c *conn) GetRecordDefaultAccess(recordType string) (skydb.RecordACL, error
 
 	nullableACLString := sql.NullString{}
 	err := c.QueryRowWith(builder).Scan(&nullableACLString)
-	if err != nil {
+	if err == sql.ErrNoRows {
+		// no default access is set for the record type
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -160,6 +164,57 @@ func (c *conn) GetRecordDefaultAccess(recordType string) (skydb.RecordACL, error
 	return nil, nil
 }
 
+func (c *conn) SetRecordSystemFieldAccess(recordType string, masks skydb.SystemFieldMasks) error {
+	masksJSON, err := json.Marshal(masks)
+	if err != nil {
+		return err
+	}
+
+	pkData := map[string]interface{}{
+		"record_type": recordType,
+	}
+	values := map[string]interface{}{
+		"masks": masksJSON,
+		"salt":  uuid.New(),
+	}
+
+	// The salt is kept on update, so that pseudonyms do not change when
+	// the masks are changed.
+	upsert := builder.UpsertQuery(c.tableName("_record_system_field_access"), pkData, values).
+		IgnoreKeyOnUpdate("salt")
+	_, err = c.ExecWith(upsert)
+	return err
+}
+
+func (c *conn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	builder := psql.
+		Select("record_type", "masks", "salt").
+		From(c.tableName("_record_system_field_access"))
+
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]skydb.SystemFieldAccess{}
+	for rows.Next() {
+		var (
+			recordType string
+			masksJSON  []byte
+			access     skydb.SystemFieldAccess
+		)
+		if err := rows.Scan(&recordType, &masksJSON, &access.Salt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(masksJSON, &access.Masks); err != nil {
+			return nil, err
+		}
+		result[recordType] = access
+	}
+	return result, rows.Err()
+}
+
 func (c *conn) SetRecordFieldAccess(acl skydb.FieldACL) (err error) {
 	tx, err := c.db.Beginx()
 	if err != nil {
@@ -141,6 +141,8 @@ func TestRecordFieldAccess(t *testing.T) {
 				{"*", "*", publicRole, false, false, false, false},
 				{"user", "email", anyUserRole, false, false, false, true},
 				{"user", "email", ownerRole, true, true, true, true},
+				{"user", "phone", anyUserRole, false, false, false, true},
+				{"user", "phone", ownerRole, true, true, true, true},
 				{"user", "username", anyUserRole, false, false, false, true},
 				{"user", "username", ownerRole, true, true, true, true},
 			}
@@ -199,3 +201,47 @@ func TestRecordFieldAccess(t *testing.T) {
 		})
 	})
 }
+
+func TestRecordSystemFieldAccess(t *testing.T) {
+	Convey("RecordSystemFieldAccess", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		Convey("returns empty access if not set", func() {
+			access, err := c.GetRecordSystemFieldAccess()
+			So(err, ShouldBeNil)
+			So(access, ShouldBeEmpty)
+		})
+
+		Convey("sets masks and keeps salt on update", func() {
+			err := c.SetRecordSystemFieldAccess("confession", skydb.SystemFieldMasks{
+				"_owner_id": skydb.SystemFieldPseudonym,
+			})
+			So(err, ShouldBeNil)
+
+			access, err := c.GetRecordSystemFieldAccess()
+			So(err, ShouldBeNil)
+			So(access["confession"].Masks, ShouldResemble, skydb.SystemFieldMasks{
+				"_owner_id": skydb.SystemFieldPseudonym,
+			})
+			salt := access["confession"].Salt
+			So(salt, ShouldNotBeEmpty)
+
+			err = c.SetRecordSystemFieldAccess("confession", skydb.SystemFieldMasks{
+				"_owner_id": skydb.SystemFieldHidden,
+				"_access":   skydb.SystemFieldHidden,
+			})
+			So(err, ShouldBeNil)
+
+			access, err = c.GetRecordSystemFieldAccess()
+			So(err, ShouldBeNil)
+			So(access["confession"], ShouldResemble, skydb.SystemFieldAccess{
+				Masks: skydb.SystemFieldMasks{
+					"_owner_id": skydb.SystemFieldHidden,
+					"_access":   skydb.SystemFieldHidden,
+				},
+				Salt: salt,
+			})
+		})
+	})
+}
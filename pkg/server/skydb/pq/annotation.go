@@ -0,0 +1,118 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"time"
+
+	sq "github.com/lann/squirrel"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) AddAnnotation(annotation *skydb.Annotation) error {
+	builder := psql.Insert(c.tableName("_annotation")).
+		Columns("id", "record_type", "record_id", "author_id", "content", "created_at").
+		Values(annotation.ID, annotation.RecordID.Type, annotation.RecordID.Key,
+			annotation.AuthorID, annotation.Content, annotation.CreatedAt.UTC())
+
+	_, err := c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) GetAnnotation(id string) (*skydb.Annotation, error) {
+	builder := c.selectAnnotations().
+		Where("id = ?", id)
+
+	annotations, err := c.queryAnnotations(builder)
+	if err != nil {
+		return nil, err
+	}
+	if len(annotations) == 0 {
+		return nil, skydb.ErrAnnotationNotFound
+	}
+	return &annotations[0], nil
+}
+
+func (c *conn) GetAnnotations(recordID skydb.RecordID, offset uint64, limit uint64) ([]skydb.Annotation, error) {
+	builder := c.selectAnnotations().
+		Where("record_type = ? AND record_id = ?", recordID.Type, recordID.Key).
+		Offset(offset).
+		Limit(limit)
+	return c.queryAnnotations(builder)
+}
+
+func (c *conn) DeleteAnnotation(id string) error {
+	builder := psql.Delete(c.tableName("_annotation")).
+		Where("id = ?", id)
+
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return skydb.ErrAnnotationNotFound
+	}
+	return nil
+}
+
+// deleteRecordAnnotations removes the annotations of a deleted record.
+func (c *conn) deleteRecordAnnotations(recordID skydb.RecordID) error {
+	builder := psql.Delete(c.tableName("_annotation")).
+		Where("record_type = ? AND record_id = ?", recordID.Type, recordID.Key)
+
+	_, err := c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) selectAnnotations() sq.SelectBuilder {
+	return psql.Select("id", "record_type", "record_id", "author_id", "content", "created_at").
+		From(c.tableName("_annotation")).
+		OrderBy("created_at", "id")
+}
+
+func (c *conn) queryAnnotations(builder sq.SelectBuilder) ([]skydb.Annotation, error) {
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	annotations := []skydb.Annotation{}
+	for rows.Next() {
+		var annotation skydb.Annotation
+		if err := rows.Scan(
+			&annotation.ID,
+			&annotation.RecordID.Type,
+			&annotation.RecordID.Key,
+			&annotation.AuthorID,
+			&annotation.Content,
+			&annotation.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		annotation.CreatedAt = annotation.CreatedAt.In(time.UTC)
+		annotations = append(annotations, annotation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
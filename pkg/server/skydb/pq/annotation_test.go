@@ -0,0 +1,103 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestAnnotation(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		first := skydb.Annotation{
+			ID:        "first",
+			RecordID:  skydb.NewRecordID("article", "1"),
+			AuthorID:  "alice",
+			Content:   "first!",
+			CreatedAt: now,
+		}
+		second := skydb.Annotation{
+			ID:        "second",
+			RecordID:  skydb.NewRecordID("article", "1"),
+			AuthorID:  "bob",
+			Content:   "second",
+			CreatedAt: now.Add(time.Minute),
+		}
+		So(c.AddAnnotation(&second), ShouldBeNil)
+		So(c.AddAnnotation(&first), ShouldBeNil)
+
+		Convey("gets annotations of record in creation order", func() {
+			annotations, err := c.GetAnnotations(skydb.NewRecordID("article", "1"), 0, 10)
+			So(err, ShouldBeNil)
+			So(annotations, ShouldResemble, []skydb.Annotation{first, second})
+
+			annotations, err = c.GetAnnotations(skydb.NewRecordID("article", "1"), 1, 10)
+			So(err, ShouldBeNil)
+			So(annotations, ShouldResemble, []skydb.Annotation{second})
+		})
+
+		Convey("gets no annotations of another record", func() {
+			annotations, err := c.GetAnnotations(skydb.NewRecordID("article", "2"), 0, 10)
+			So(err, ShouldBeNil)
+			So(annotations, ShouldBeEmpty)
+		})
+
+		Convey("gets annotation by ID", func() {
+			annotation, err := c.GetAnnotation("second")
+			So(err, ShouldBeNil)
+			So(*annotation, ShouldResemble, second)
+
+			_, err = c.GetAnnotation("nonexistent")
+			So(err, ShouldEqual, skydb.ErrAnnotationNotFound)
+		})
+
+		Convey("deletes annotation", func() {
+			So(c.DeleteAnnotation("first"), ShouldBeNil)
+
+			annotations, err := c.GetAnnotations(skydb.NewRecordID("article", "1"), 0, 10)
+			So(err, ShouldBeNil)
+			So(annotations, ShouldResemble, []skydb.Annotation{second})
+
+			So(c.DeleteAnnotation("first"), ShouldEqual, skydb.ErrAnnotationNotFound)
+		})
+
+		Convey("deletes annotations with the record", func() {
+			db := c.PublicDB()
+			_, err := db.Extend("article", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("article", "1"),
+				OwnerID: "alice",
+				Data:    map[string]interface{}{"title": "Hello"},
+			}), ShouldBeNil)
+
+			So(db.Delete(skydb.NewRecordID("article", "1")), ShouldBeNil)
+
+			annotations, err := c.GetAnnotations(skydb.NewRecordID("article", "1"), 0, 10)
+			So(err, ShouldBeNil)
+			So(annotations, ShouldBeEmpty)
+		})
+	})
+}
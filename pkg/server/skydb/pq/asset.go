@@ -16,11 +16,16 @@ package pq
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	sq "github.com/lann/squirrel"
+	"github.com/lib/pq"
 
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 	"github.com/skygeario/skygear-server/pkg/server/skydb/pq/builder"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
 
 func (c *conn) GetAsset(name string, asset *skydb.Asset) error {
@@ -83,3 +88,118 @@ func (c *conn) SaveAsset(asset *skydb.Asset) error {
 	_, err := c.ExecWith(upsert)
 	return err
 }
+
+func (c *conn) GetAssetNames(prefix string) ([]string, error) {
+	// escape the wildcards of LIKE in the prefix
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+	builder := psql.Select("id").
+		From(c.tableName("_asset")).
+		Where("id LIKE ?", pattern).
+		OrderBy("id")
+
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// RenameAsset inserts the asset with the new name, updates the asset
+// fields of all record types referencing the old name, and then deletes
+// the old asset, so that the foreign keys are not violated.
+func (c *conn) RenameAsset(oldName, newName string) error {
+	result, err := c.Exec(fmt.Sprintf(`
+		INSERT INTO %[1]s (id, content_type, size)
+		SELECT $2, content_type, size FROM %[1]s WHERE id = $1
+		ON CONFLICT (id) DO UPDATE
+		SET content_type = EXCLUDED.content_type, size = EXCLUDED.size
+	`, c.tableName("_asset")), oldName, newName)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return skydb.ErrAssetNotFound
+	}
+
+	db := c.PublicDB().(*database)
+	schemas, err := db.GetRecordSchemas()
+	if err != nil {
+		return err
+	}
+	for recordType, schema := range schemas {
+		for field, fieldType := range schema {
+			if fieldType.Type != skydb.TypeAsset {
+				continue
+			}
+
+			builder := psql.Update(c.tableName(recordType)).
+				Set(field, newName).
+				Where(pq.QuoteIdentifier(field)+" = ?", oldName)
+			if _, err := c.ExecWith(builder); err != nil {
+				return err
+			}
+			c.invalidateQueryCache(recordType)
+		}
+	}
+
+	return c.DeleteAsset(oldName)
+}
+
+func (c *conn) DeleteAsset(name string) error {
+	builder := psql.Delete(c.tableName("_asset")).
+		Where("id = ?", name)
+
+	result, err := c.ExecWith(builder)
+	if isForeignKeyViolated(err) {
+		return skyerr.NewErrorf(skyerr.ConstraintViolated,
+			"asset %s is referenced by records", name)
+	} else if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return skydb.ErrAssetNotFound
+	}
+	return nil
+}
+
+// ReplaceURLPrefix rewrites the fields in a single UPDATE statement.
+func (c *conn) ReplaceURLPrefix(recordType string, fields []string, oldPrefix, newPrefix string) (int64, error) {
+	if len(fields) == 0 || oldPrefix == "" {
+		return 0, nil
+	}
+
+	sets := make([]string, len(fields))
+	conditions := make([]string, len(fields))
+	for i, field := range fields {
+		column := pq.QuoteIdentifier(field)
+		matched := fmt.Sprintf("left(%s, $3) = $1", column)
+		sets[i] = fmt.Sprintf("%[1]s = CASE WHEN %[2]s THEN $2 || substr(%[1]s, $3 + 1) ELSE %[1]s END", column, matched)
+		conditions[i] = matched
+	}
+
+	stmt := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		c.tableName(recordType),
+		strings.Join(sets, ", "),
+		strings.Join(conditions, " OR "))
+	result, err := c.Exec(stmt, oldPrefix, newPrefix, utf8.RuneCountInString(oldPrefix))
+	if err != nil {
+		return 0, err
+	}
+	c.invalidateQueryCache(recordType)
+	return result.RowsAffected()
+}
@@ -0,0 +1,129 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestAssetOperations(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		for _, name := range []string{"images/a.png", "images/b.png", "images_c.png", "video.mp4"} {
+			So(c.SaveAsset(&skydb.Asset{
+				Name:        name,
+				ContentType: "image/png",
+				Size:        1,
+			}), ShouldBeNil)
+		}
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"image": skydb.FieldType{Type: skydb.TypeAsset},
+		})
+		So(err, ShouldBeNil)
+		So(db.Save(&skydb.Record{
+			ID: skydb.NewRecordID("note", "1"),
+			Data: map[string]interface{}{
+				"image": &skydb.Asset{Name: "images/a.png"},
+			},
+			OwnerID: "user_id",
+		}), ShouldBeNil)
+
+		Convey("gets asset names with prefix", func() {
+			names, err := c.GetAssetNames("images/")
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"images/a.png", "images/b.png"})
+		})
+
+		Convey("renames asset referenced by records", func() {
+			So(c.RenameAsset("images/a.png", "photos/a.png"), ShouldBeNil)
+
+			asset := skydb.Asset{}
+			So(c.GetAsset("photos/a.png", &asset), ShouldBeNil)
+			So(asset.ContentType, ShouldEqual, "image/png")
+			So(c.GetAsset("images/a.png", &asset), ShouldNotBeNil)
+
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &record), ShouldBeNil)
+			So(record.Data["image"].(*skydb.Asset).Name, ShouldEqual, "photos/a.png")
+		})
+
+		Convey("errors when renaming non-existing asset", func() {
+			So(c.RenameAsset("notexist.png", "exist.png"), ShouldEqual, skydb.ErrAssetNotFound)
+		})
+
+		Convey("deletes asset", func() {
+			So(c.DeleteAsset("images/b.png"), ShouldBeNil)
+			So(c.DeleteAsset("images/b.png"), ShouldEqual, skydb.ErrAssetNotFound)
+		})
+
+		Convey("errors when deleting asset referenced by records", func() {
+			err := c.DeleteAsset("images/a.png")
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.ConstraintViolated)
+		})
+	})
+}
+
+func TestReplaceURLPrefix(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"link":  skydb.FieldType{Type: skydb.TypeString},
+			"cover": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		for id, data := range map[string]map[string]interface{}{
+			"1": {"link": "http://old.dev/files/a.png", "cover": "http://old.dev/files/b.png"},
+			"2": {"link": "http://other.dev/http://old.dev/files/a.png"},
+			"3": {"cover": "http://old.dev/files/c.png"},
+		} {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("note", id),
+				Data:    data,
+				OwnerID: "user_id",
+			}), ShouldBeNil)
+		}
+
+		Convey("replaces URL prefix", func() {
+			count, err := c.ReplaceURLPrefix("note", []string{"link", "cover"}, "http://old.dev/files/", "https://cdn.dev/")
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &record), ShouldBeNil)
+			So(record.Data["link"], ShouldEqual, "https://cdn.dev/a.png")
+			So(record.Data["cover"], ShouldEqual, "https://cdn.dev/b.png")
+
+			So(db.Get(skydb.NewRecordID("note", "2"), &record), ShouldBeNil)
+			So(record.Data["link"], ShouldEqual, "http://other.dev/http://old.dev/files/a.png")
+
+			So(db.Get(skydb.NewRecordID("note", "3"), &record), ShouldBeNil)
+			So(record.Data["cover"], ShouldEqual, "https://cdn.dev/c.png")
+		})
+	})
+}
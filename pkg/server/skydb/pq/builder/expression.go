@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	sq "github.com/lann/squirrel"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -110,6 +111,61 @@ func funcToSQLOperand(alias string, fun skydb.Func) (string, []interface{}) {
 			fullQuoteIdentifier(alias, f.Field))
 		args := []interface{}{f.Location.Lng(), f.Location.Lat()}
 		return sql, args
+	case skydb.LowerFunc:
+		sql := fmt.Sprintf("LOWER(%s)", fullQuoteIdentifier(alias, f.KeyPath))
+		return sql, []interface{}{}
+	case skydb.DatePartFunc:
+		return datePartSQL(alias, f), []interface{}{}
+	case skydb.RandomFunc:
+		sql, err := randomSQL(f)
+		if err != nil {
+			panic(err)
+		}
+		return sql, []interface{}{}
+	case treeDepthFunc:
+		return treeDepthSQL(alias, f), []interface{}{}
+	case columnFunc:
+		return fullQuoteIdentifier(f.alias, f.column), []interface{}{}
+	case skydb.ConcatFunc:
+		operands := []string{}
+		args := []interface{}{}
+		for _, expr := range f.Expressions {
+			operand, operandArgs := funcOperandToSQL(alias, expr)
+			operands = append(operands, operand)
+			args = append(args, operandArgs...)
+		}
+		sql := fmt.Sprintf("CONCAT(%s)", strings.Join(operands, ", "))
+		return sql, args
+	case skydb.CoalesceFunc:
+		operands := []string{}
+		args := []interface{}{}
+		for _, expr := range f.Expressions {
+			operand, operandArgs := funcOperandToSQL(alias, expr)
+			operands = append(operands, operand)
+			args = append(args, operandArgs...)
+		}
+		sql := fmt.Sprintf("COALESCE(%s)", strings.Join(operands, ", "))
+		return sql, args
+	case skydb.UpperFunc:
+		operand, args := funcOperandToSQL(alias, f.Expression)
+		return fmt.Sprintf("UPPER(%s)", operand), args
+	case skydb.LengthFunc:
+		operand, args := funcOperandToSQL(alias, f.Expression)
+		return fmt.Sprintf("LENGTH(%s)", operand), args
+	case skydb.AbsFunc:
+		operand, args := funcOperandToSQL(alias, f.Expression)
+		return fmt.Sprintf("ABS(%s)", operand), args
+	case skydb.ArithmeticFunc:
+		left, args := funcOperandToSQL(alias, f.Left)
+		right, rightArgs := funcOperandToSQL(alias, f.Right)
+		args = append(args, rightArgs...)
+		if f.Operator == skydb.Divide {
+			// NULLIF yields NULL instead of failing the query
+			// when dividing by zero
+			right = fmt.Sprintf("NULLIF(%s, 0)", right)
+		}
+		sql := fmt.Sprintf("(%s %s %s)", left, string(f.Operator), right)
+		return sql, args
 	case skydb.CountFunc:
 		var sql string
 		if f.OverallRecords {
@@ -124,6 +180,73 @@ func funcToSQLOperand(alias string, fun skydb.Func) (string, []interface{}) {
 	}
 }
 
+// datePartSQL generates SQL extracting a date part. The part is inlined
+// because it is one of the valid skydb.DatePart.
+func datePartSQL(alias string, f skydb.DatePartFunc) string {
+	if !f.Part.IsValid() {
+		panic(fmt.Errorf("got unrecgonized date part = %s", f.Part))
+	}
+	return fmt.Sprintf("date_part('%s', %s)", string(f.Part), fullQuoteIdentifier(alias, f.KeyPath))
+}
+
+// randomSQL generates SQL returning a random number. The seed is inlined
+// so that the SQL can be used in ORDER BY.
+//
+// The seed is set in a subquery evaluated once before the first call of
+// random(), which makes the random numbers of the statement repeatable
+// without setting the seed in a separate statement on the same session.
+func randomSQL(f skydb.RandomFunc) (string, error) {
+	if f.Seed == nil {
+		return "random()", nil
+	}
+	if *f.Seed < -1 || *f.Seed > 1 {
+		return "", fmt.Errorf("random seed must be between -1 and 1, got %v", *f.Seed)
+	}
+	return fmt.Sprintf(`((SELECT 0 FROM (SELECT setseed(%v)) AS "_seed") + random())`, *f.Seed), nil
+}
+
+// columnFunc is a key path operand of a function bound to the column of
+// a joined table.
+type columnFunc struct {
+	alias    string
+	column   string
+	dataType skydb.DataType
+}
+
+func (f columnFunc) Args() []interface{} {
+	return []interface{}{}
+}
+
+func (f columnFunc) DataType() skydb.DataType {
+	return f.dataType
+}
+
+// funcOperandToSQL generates SQL for an expression nested inside a function.
+// Literals are casted so that postgres can infer the type of the
+// placeholder.
+func funcOperandToSQL(alias string, expr skydb.Expression) (string, []interface{}) {
+	switch expr.Type {
+	case skydb.KeyPath:
+		components := expr.KeyPathComponents()
+		return fullQuoteIdentifier(alias, components[len(components)-1]), []interface{}{}
+	case skydb.Function:
+		return funcToSQLOperand(alias, expr.Value.(skydb.Func))
+	}
+
+	switch v := expr.Value.(type) {
+	case nil:
+		return "NULL", []interface{}{}
+	case string:
+		return sq.Placeholders(1) + "::text", []interface{}{v}
+	case float64:
+		return sq.Placeholders(1) + "::double precision", []interface{}{v}
+	case bool:
+		return sq.Placeholders(1) + "::boolean", []interface{}{v}
+	default:
+		return LiteralToSQLOperand(v)
+	}
+}
+
 func LiteralToSQLOperand(literal interface{}) (string, []interface{}) {
 	// Array detection is borrowed from squirrel's expr.go
 	switch literalValue := literal.(type) {
@@ -31,6 +31,55 @@ func TestSqlizer(t *testing.T) {
 			So(args, ShouldResemble, []interface{}{})
 			So(err, ShouldBeNil)
 		})
+
+		Convey("concat function expression", func() {
+			expr := newExpressionSqlizer("note", skydb.FieldType{}, skydb.Expression{
+				skydb.Function,
+				skydb.ConcatFunc{[]skydb.Expression{
+					{skydb.KeyPath, "firstName"},
+					{skydb.Literal, " "},
+					{skydb.KeyPath, "lastName"},
+				}},
+			})
+			sql, args, err := expr.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `CONCAT("note"."firstName", ?::text, "note"."lastName")`)
+			So(args, ShouldResemble, []interface{}{" "})
+		})
+
+		Convey("arithmetic function expression", func() {
+			expr := newExpressionSqlizer("note", skydb.FieldType{}, skydb.Expression{
+				skydb.Function,
+				skydb.ArithmeticFunc{
+					skydb.Multiply,
+					skydb.Expression{skydb.KeyPath, "price"},
+					skydb.Expression{skydb.Literal, float64(2)},
+				},
+			})
+			sql, args, err := expr.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `("note"."price" * ?::double precision)`)
+			So(args, ShouldResemble, []interface{}{float64(2)})
+		})
+
+		Convey("nested division function expression", func() {
+			expr := newExpressionSqlizer("note", skydb.FieldType{}, skydb.Expression{
+				skydb.Function,
+				skydb.ArithmeticFunc{
+					skydb.Divide,
+					skydb.Expression{skydb.Function, skydb.ArithmeticFunc{
+						skydb.Add,
+						skydb.Expression{skydb.KeyPath, "a"},
+						skydb.Expression{skydb.KeyPath, "b"},
+					}},
+					skydb.Expression{skydb.KeyPath, "c"},
+				},
+			})
+			sql, args, err := expr.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `(("note"."a" + "note"."b") / NULLIF("note"."c", 0))`)
+			So(args, ShouldResemble, []interface{}{})
+		})
 	})
 }
 
@@ -16,6 +16,7 @@ package builder
 
 import (
 	"fmt"
+	"strings"
 
 	sq "github.com/lann/squirrel"
 	"github.com/lib/pq"
@@ -23,10 +24,28 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
 
+// maxJoins is the maximum number of tables joined by a query. Zero means
+// unlimited.
+var maxJoins int
+
+// SetMaxJoins sets the maximum number of tables joined by a query to
+// resolve key paths referencing other records. A limit of zero means
+// unlimited.
+func SetMaxJoins(joins int) {
+	maxJoins = joins
+}
+
 type PredicateSqlizerFactory interface {
 	UpdateTypemap(typemap skydb.RecordSchema) skydb.RecordSchema
 	AddJoinsToSelectBuilder(q sq.SelectBuilder) sq.SelectBuilder
 	NewPredicateSqlizer(p skydb.Predicate) (sq.Sqlizer, error)
+	NewSortOrderBySQL(sort skydb.Sort) (string, error)
+	NewSortExpressionSQL(sort skydb.Sort) (string, error)
+	NewKeysetSqlizer(sorts []skydb.Sort, values []interface{}) (sq.Sqlizer, error)
+	SetDistinctOn(keyPaths []string) error
+	JoinReferencedTable(field string) (string, error)
+	JoinedRecordTypes() []string
+	RelationNames() []string
 	NewAccessControlSqlizer(user *skydb.AuthInfo, aclLevel skydb.RecordACLLevel) (sq.Sqlizer, error)
 }
 
@@ -35,7 +54,9 @@ type predicateSqlizerFactory struct {
 	db           skydb.Database
 	primaryTable string
 	joinedTables []joinedTable
+	relations    []string
 	extraColumns map[string]skydb.FieldType
+	distinctOn   []string
 }
 
 func NewPredicateSqlizerFactory(db skydb.Database, primaryTable string) PredicateSqlizerFactory {
@@ -91,6 +112,10 @@ func (f *predicateSqlizerFactory) newCompoundPredicateSqlizer(p skydb.Predicate)
 		if err != nil {
 			return nil, err
 		}
+		if relation, ok := sqlizer.(userRelationPredicateSqlizer); ok {
+			relation.negated = !relation.negated
+			return relation, nil
+		}
 		return NotSqlizer{sqlizer}, nil
 	}
 }
@@ -103,13 +128,16 @@ func (f *predicateSqlizerFactory) newFunctionalPredicateSqlizer(predicate skydb.
 	switch fn := expr.Value.(type) {
 	case skydb.UserRelationFunc:
 		return f.newUserRelationFunctionalPredicateSqlizer(fn)
+	case skydb.DescendantFunc:
+		return f.newDescendantFunctionalPredicateSqlizer(fn)
+	case skydb.AncestorFunc:
+		return f.newAncestorFunctionalPredicateSqlizer(fn)
 	default:
 		panic("the specified function cannot be used as a functional predicate")
 	}
 }
 
 func (f *predicateSqlizerFactory) newUserRelationFunctionalPredicateSqlizer(fn skydb.UserRelationFunc) (sq.Sqlizer, error) {
-	table := fn.RelationName
 	direction := fn.RelationDirection
 	if direction == "" {
 		direction = "outward"
@@ -118,19 +146,163 @@ func (f *predicateSqlizerFactory) newUserRelationFunctionalPredicateSqlizer(fn s
 	if primaryColumn == "_owner" || primaryColumn == "" {
 		primaryColumn = "_owner_id"
 	}
+	f.relations = append(f.relations, fn.RelationName)
+
+	return userRelationPredicateSqlizer{
+		alias:         f.primaryTable,
+		primaryColumn: primaryColumn,
+		table:         f.db.TableName(fn.RelationName),
+		outward:       direction == "outward" || direction == "mutual",
+		inward:        direction == "inward" || direction == "mutual",
+		user:          fn.User,
+	}, nil
+}
 
-	var outwardAlias, inwardAlias string
-	if direction == "outward" || direction == "mutual" {
-		outwardAlias = f.createLeftJoin(table, primaryColumn, "right_id")
+func (f *predicateSqlizerFactory) newDescendantFunctionalPredicateSqlizer(fn skydb.DescendantFunc) (sq.Sqlizer, error) {
+	if err := f.checkTreeParentField(fn.ParentKeyPath); err != nil {
+		return nil, err
 	}
-	if direction == "inward" || direction == "mutual" {
-		inwardAlias = f.createLeftJoin(table, primaryColumn, "left_id")
+	if err := f.checkTreeRecordID(fn.RecordID); err != nil {
+		return nil, err
 	}
 
-	return userRelationPredicateSqlizer{
-		outwardAlias: outwardAlias,
-		inwardAlias:  inwardAlias,
-		user:         fn.User,
+	return descendantPredicateSqlizer{
+		alias:        f.primaryTable,
+		table:        f.db.TableName(f.primaryTable),
+		parentColumn: fn.ParentKeyPath,
+		recordKey:    fn.RecordID.Key,
+		maxDepth:     fn.MaxDepth,
+	}, nil
+}
+
+func (f *predicateSqlizerFactory) newAncestorFunctionalPredicateSqlizer(fn skydb.AncestorFunc) (sq.Sqlizer, error) {
+	if err := f.checkTreeParentField(fn.ParentKeyPath); err != nil {
+		return nil, err
+	}
+	if err := f.checkTreeRecordID(fn.RecordID); err != nil {
+		return nil, err
+	}
+
+	return ancestorPredicateSqlizer{
+		alias:        f.primaryTable,
+		table:        f.db.TableName(f.primaryTable),
+		parentColumn: fn.ParentKeyPath,
+		recordKey:    fn.RecordID.Key,
+	}, nil
+}
+
+// checkTreeParentField returns an error if the specified key path is not
+// a reference field of the primary table referencing the primary
+// table itself.
+func (f *predicateSqlizerFactory) checkTreeParentField(keyPath string) error {
+	if strings.Contains(keyPath, ".") {
+		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			`parent field "%s" must be a field of "%s"`, keyPath, f.primaryTable)
+	}
+
+	_, field, err := f.resolveKeyPath(keyPath)
+	if err != nil {
+		return err
+	}
+	if field.Type != skydb.TypeReference || field.ReferenceType != f.primaryTable {
+		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			`parent field "%s" is not a reference to "%s"`, keyPath, f.primaryTable)
+	}
+	return nil
+}
+
+func (f *predicateSqlizerFactory) checkTreeRecordID(id skydb.RecordID) error {
+	if id.Type != f.primaryTable {
+		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			`record "%s" is not of type "%s"`, id, f.primaryTable)
+	}
+	return nil
+}
+
+// bindFunc binds functions in the expression that require the table of
+// the primary record type, or that have operands referencing fields of
+// other records. Other expressions are returned unchanged.
+func (f *predicateSqlizerFactory) bindFunc(expr skydb.Expression) (skydb.Expression, error) {
+	if expr.Type != skydb.Function {
+		return expr, nil
+	}
+
+	var err error
+	switch fn := expr.Value.(type) {
+	case skydb.TreeDepthFunc:
+		if err := f.checkTreeParentField(fn.ParentKeyPath); err != nil {
+			return expr, err
+		}
+		return skydb.Expression{
+			Type: skydb.Function,
+			Value: treeDepthFunc{
+				TreeDepthFunc: fn,
+				table:         f.db.TableName(f.primaryTable),
+			},
+		}, nil
+	case skydb.ConcatFunc:
+		fn.Expressions, err = f.bindFuncOperands(fn.Expressions)
+		expr.Value = fn
+	case skydb.CoalesceFunc:
+		fn.Expressions, err = f.bindFuncOperands(fn.Expressions)
+		expr.Value = fn
+	case skydb.UpperFunc:
+		fn.Expression, err = f.bindFuncOperand(fn.Expression)
+		expr.Value = fn
+	case skydb.LengthFunc:
+		fn.Expression, err = f.bindFuncOperand(fn.Expression)
+		expr.Value = fn
+	case skydb.AbsFunc:
+		fn.Expression, err = f.bindFuncOperand(fn.Expression)
+		expr.Value = fn
+	case skydb.ArithmeticFunc:
+		if fn.Left, err = f.bindFuncOperand(fn.Left); err != nil {
+			return expr, err
+		}
+		fn.Right, err = f.bindFuncOperand(fn.Right)
+		expr.Value = fn
+	}
+	return expr, err
+}
+
+func (f *predicateSqlizerFactory) bindFuncOperands(exprs []skydb.Expression) ([]skydb.Expression, error) {
+	bound := make([]skydb.Expression, len(exprs))
+	for i, expr := range exprs {
+		var err error
+		if bound[i], err = f.bindFuncOperand(expr); err != nil {
+			return nil, err
+		}
+	}
+	return bound, nil
+}
+
+// bindFuncOperand binds an operand of a function. A key path referencing
+// a field of another record is resolved in the same way as predicates,
+// and replaced by the column of the joined table.
+func (f *predicateSqlizerFactory) bindFuncOperand(expr skydb.Expression) (skydb.Expression, error) {
+	if expr.Type == skydb.Function {
+		return f.bindFunc(expr)
+	}
+	if !expr.IsKeyPath() {
+		return expr, nil
+	}
+
+	components := expr.KeyPathComponents()
+	if len(components) == 1 {
+		return expr, nil
+	}
+
+	alias, field, err := f.resolveKeyPath(expr.Value.(string))
+	if err != nil {
+		return expr, err
+	}
+	return skydb.Expression{
+		Type: skydb.Function,
+		Value: columnFunc{
+			alias:    alias,
+			column:   components[len(components)-1],
+			dataType: field.Type,
+		},
 	}, nil
 }
 
@@ -159,6 +331,9 @@ func (f *predicateSqlizerFactory) newComparisonPredicateSqlizer(p skydb.Predicat
 	if p.Operator == skydb.In {
 		return &containsComparisonPredicateSqlizer{sqlizers}, nil
 	}
+	if p.Operator == skydb.ContainsAll || p.Operator == skydb.ContainsAny {
+		return &arrayComparisonPredicateSqlizer{sqlizers, p.Operator}, nil
+	}
 	return &comparisonPredicateSqlizer{sqlizers, p.Operator}, nil
 }
 
@@ -218,6 +393,11 @@ func (f *predicateSqlizerFactory) newExpressionSqlizer(expr skydb.Expression) (e
 	}
 
 	if expr.Type == skydb.Function {
+		expr, err := f.bindFunc(expr)
+		if err != nil {
+			return expressionSqlizer{}, err
+		}
+
 		funcInterface, ok := expr.Value.(skydb.Func)
 		if !ok {
 			panic(`expression value is not a function`)
@@ -234,42 +414,169 @@ func (f *predicateSqlizerFactory) newExpressionSqlizerForKeyPath(expr skydb.Expr
 		panic("expression is not a key path")
 	}
 
-	components := expr.KeyPathComponents()
-	keyPath := expr.Value.(string)
-	if len(components) > 2 {
-		return expressionSqlizer{}, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
-			`keypath "%s" with more than 2 components is not supported`, keyPath)
+	alias, field, err := f.resolveKeyPath(expr.Value.(string))
+	if err != nil {
+		return expressionSqlizer{}, err
 	}
+	return newExpressionSqlizer(alias, field, expr), nil
+}
 
-	alias := f.primaryTable
+// resolveKeyPath returns the alias of the table containing the last
+// component of the key path, and the field type of that component.
+//
+// For each reference in the key path (such as `author` in `author.name`),
+// the referenced table is left joined so that the last component
+// can be qualified with the alias of the joined table.
+func (f *predicateSqlizerFactory) resolveKeyPath(keyPath string) (string, skydb.FieldType, error) {
 	fields, err := skydb.TraverseColumnTypes(f.db, f.primaryTable, keyPath)
 	if err != nil {
-		return expressionSqlizer{}, skyerr.NewError(skyerr.RecordQueryInvalid, err.Error())
+		return "", skydb.FieldType{}, skyerr.NewError(skyerr.RecordQueryInvalid, err.Error())
 	}
 
+	components := strings.Split(keyPath, ".")
+	alias := f.primaryTable
 	field := skydb.FieldType{}
 	for i, keyPathField := range fields {
 		isLast := (i == len(components)-1)
 		field = keyPathField
 		if field.Type == skydb.TypeReference && !isLast {
-			alias = f.createLeftJoin(field.ReferenceType, components[i], "_id")
+			alias, err = f.createLeftJoin(alias, field.ReferenceType, components[i])
+			if err != nil {
+				return "", skydb.FieldType{}, err
+			}
 		}
 	}
-	return newExpressionSqlizer(alias, field, expr), nil
+	return alias, field, nil
+}
+
+// NewSortOrderBySQL returns the ORDER BY clause of a sort. Key paths
+// referencing other records are joined in the same way as predicates.
+func (f *predicateSqlizerFactory) NewSortOrderBySQL(sort skydb.Sort) (string, error) {
+	alias := f.primaryTable
+	if sort.Expression.IsKeyPath() {
+		var err error
+		alias, _, err = f.resolveKeyPath(sort.Expression.Value.(string))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var err error
+	sort.Expression, err = f.bindFunc(sort.Expression)
+	if err != nil {
+		return "", err
+	}
+	return SortOrderBySQL(alias, sort)
+}
+
+// NewSortExpressionSQL returns the SQL expression by which records are
+// sorted. Key paths referencing other records are joined in the same way
+// as predicates.
+func (f *predicateSqlizerFactory) NewSortExpressionSQL(sort skydb.Sort) (string, error) {
+	alias := f.primaryTable
+	if sort.Expression.IsKeyPath() {
+		var err error
+		alias, _, err = f.resolveKeyPath(sort.Expression.Value.(string))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var err error
+	sort.Expression, err = f.bindFunc(sort.Expression)
+	if err != nil {
+		return "", err
+	}
+	return SortExpressionSQL(alias, sort)
 }
 
-// createLeftJoin create an alias of a table to be joined to the primary table
-// and return the alias for the joined table
-func (f *predicateSqlizerFactory) createLeftJoin(secondaryTable string, primaryColumn string, secondaryColumn string) string {
-	newAlias := joinedTable{secondaryTable, primaryColumn, secondaryColumn}
+// NewKeysetSqlizer returns a sqlizer matching records sorted after
+// the specified values of the sorts. The sorts are expected to end with
+// a sort by a unique key, as returned by KeysetSorts.
+func (f *predicateSqlizerFactory) NewKeysetSqlizer(sorts []skydb.Sort, values []interface{}) (sq.Sqlizer, error) {
+	if len(sorts) != len(values) {
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"cursor does not match the sorts of the query")
+	}
+
+	exprs := make([]string, len(sorts))
+	orders := make([]skydb.SortOrder, len(sorts))
+	nulls := make([]skydb.NullOrder, len(sorts))
+	for i, sort := range sorts {
+		expr, err := f.NewSortExpressionSQL(sort)
+		if err != nil {
+			return nil, err
+		}
+		exprs[i] = expr
+		orders[i] = sort.Order
+		nulls[i] = sort.Nulls
+	}
+	return &keysetSqlizer{exprs, orders, nulls, values}, nil
+}
+
+// SetDistinctOn selects distinct records on the specified key paths
+// when the joins are added to a SelectBuilder. Key paths referencing
+// other records are joined in the same way as predicates.
+func (f *predicateSqlizerFactory) SetDistinctOn(keyPaths []string) error {
+	exprs := make([]string, len(keyPaths))
+	for i, keyPath := range keyPaths {
+		alias, _, err := f.resolveKeyPath(keyPath)
+		if err != nil {
+			return err
+		}
+		components := strings.Split(keyPath, ".")
+		exprs[i] = fullQuoteIdentifier(alias, components[len(components)-1])
+	}
+	f.distinctOn = exprs
+	return nil
+}
+
+// JoinReferencedTable left joins the table of records referenced by
+// the specified reference field, and returns the alias of the joined table.
+func (f *predicateSqlizerFactory) JoinReferencedTable(field string) (string, error) {
+	alias, _, err := f.resolveKeyPath(field + "._id")
+	return alias, err
+}
+
+// JoinedRecordTypes returns the record types of the joined tables.
+func (f *predicateSqlizerFactory) JoinedRecordTypes() []string {
+	recordTypes := []string{}
+	for _, alias := range f.joinedTables {
+		recordTypes = append(recordTypes, alias.secondaryTable)
+	}
+	return recordTypes
+}
+
+// RelationNames returns the names of the user relations referenced by
+// the predicates created.
+func (f *predicateSqlizerFactory) RelationNames() []string {
+	return f.relations
+}
+
+// createLeftJoin create an alias of a table to be joined to the table
+// of the specified alias by the _id of the joined table, and return the
+// alias for the joined table. It returns an error if the query would
+// join more tables than the limit.
+func (f *predicateSqlizerFactory) createLeftJoin(primaryAlias string, secondaryTable string, primaryColumn string) (string, error) {
+	newAlias := joinedTable{primaryAlias, secondaryTable, primaryColumn}
 	for i, alias := range f.joinedTables {
 		if alias.equal(newAlias) {
-			return f.aliasName(secondaryTable, i)
+			return f.aliasName(secondaryTable, i), nil
 		}
 	}
 
+	if maxJoins > 0 && len(f.joinedTables) >= maxJoins {
+		return "", skyerr.NewErrorWithInfo(
+			skyerr.RecordQueryTooComplex,
+			fmt.Sprintf("query too complex: cannot join more than %d referenced record types", maxJoins),
+			map[string]interface{}{
+				"max_joins": maxJoins,
+			},
+		)
+	}
+
 	f.joinedTables = append(f.joinedTables, newAlias)
-	return f.aliasName(secondaryTable, len(f.joinedTables)-1)
+	return f.aliasName(secondaryTable, len(f.joinedTables)-1), nil
 }
 
 func (f *predicateSqlizerFactory) aliasName(secondaryTable string, indexInJoinedTables int) string {
@@ -282,18 +589,23 @@ func (f *predicateSqlizerFactory) aliasName(secondaryTable string, indexInJoined
 }
 
 // AddJoinsToSelectBuilder adds join clauses to a SelectBuilder
+//
+// Every joined table is joined by its _id, which matches at most one row,
+// so the joins never produce duplicated rows and the query is not made
+// DISTINCT. Predicates on relations are compiled into EXISTS subqueries
+// instead of joins for the same reason.
 func (f *predicateSqlizerFactory) AddJoinsToSelectBuilder(q sq.SelectBuilder) sq.SelectBuilder {
 	for i, alias := range f.joinedTables {
 		aliasName := f.aliasName(alias.secondaryTable, i)
 		joinClause := fmt.Sprintf("%s AS %s ON %s = %s",
 			f.db.TableName(alias.secondaryTable), pq.QuoteIdentifier(aliasName),
-			fullQuoteIdentifier(f.primaryTable, alias.primaryColumn),
-			fullQuoteIdentifier(aliasName, alias.secondaryColumn))
+			fullQuoteIdentifier(alias.primaryAlias, alias.primaryColumn),
+			fullQuoteIdentifier(aliasName, "_id"))
 		q = q.LeftJoin(joinClause)
 	}
 
-	if len(f.joinedTables) > 0 {
-		q = q.Distinct()
+	if len(f.distinctOn) > 0 {
+		q = q.Options(fmt.Sprintf("DISTINCT ON (%s)", strings.Join(f.distinctOn, ", ")))
 	}
 	return q
 }
@@ -312,17 +624,29 @@ func (f *predicateSqlizerFactory) UpdateTypemap(typemap skydb.RecordSchema) skyd
 	for key, field := range f.extraColumns {
 		typemap[key] = field
 	}
+
+	// Computed columns are bound the same way as predicates. A column
+	// that cannot be bound is removed rather than failing the query.
+	for key, field := range typemap {
+		expr, err := f.bindFunc(field.Expression)
+		if err != nil {
+			delete(typemap, key)
+			continue
+		}
+		field.Expression = expr
+		typemap[key] = field
+	}
 	return typemap
 }
 
 // joinedTable represents a specification for table join
 type joinedTable struct {
-	secondaryTable  string
-	primaryColumn   string
-	secondaryColumn string
+	primaryAlias   string
+	secondaryTable string
+	primaryColumn  string
 }
 
 // equal compares whether two specifications of table join are equal
 func (a joinedTable) equal(b joinedTable) bool {
-	return a.secondaryTable == b.secondaryTable && a.primaryColumn == b.primaryColumn && a.secondaryColumn == b.secondaryColumn
+	return a.primaryAlias == b.primaryAlias && a.secondaryTable == b.secondaryTable && a.primaryColumn == b.primaryColumn
 }
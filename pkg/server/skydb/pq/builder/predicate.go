@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	sq "github.com/lann/squirrel"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -26,14 +27,20 @@ import (
 // accessPredicateSqlizer build the json matching expression base on user's
 // role, the builded express will filter out record which user is not accessible.
 //
+// The entries to match are passed as parameters, so that the same SQL is
+// generated for users with the same number of roles.
+//
 // The sql for record accessible by user rickmak
-// `"_access" @> '[{"user_id":"rickmak"}]'`
+// `"_access" @> ?::jsonb` with `[{"user_id":"rickmak"}]`
 //
 // Record accessible by user with admin role
-// `"_access" @> '[{"role":"admin"}]'`
+// `"_access" @> ?::jsonb` with `[{"role":"admin"}]`
 //
-// Record accessible by user rickmak or admin role
-// `"_access" @> '[{"role":"rickmak"}]' OR "_access" @> '[{"role":"admin"}]'`¬
+// A record is not accessible if any deny entry matches the user, unless the
+// user is the owner of the record. Deny entries are matched by
+// `[{"user_id":"rickmak","deny":true}]`. Since a deny entry also contains
+// the matching grant entry, a user matched by a deny entry is never
+// accessible regardless of the grant entries.
 type accessPredicateSqlizer struct {
 	alias string
 	user  *skydb.AuthInfo
@@ -41,67 +48,116 @@ type accessPredicateSqlizer struct {
 }
 
 func (p accessPredicateSqlizer) ToSql() (string, []interface{}, error) {
-	var b bytes.Buffer
-	b.WriteString(`(`)
-	args := []interface{}{}
+	access := fullQuoteIdentifier(p.alias, "_access")
+	grants := []map[string]interface{}{}
+	denies := []map[string]interface{}{}
 
 	if p.user != nil {
 		if p.user.ID == "" {
 			panic("cannot build access predicate without user")
 		}
 
-		escapedID, err := json.Marshal(p.user.ID)
-		if err != nil {
-			panic("unexpected serialize error on user_id")
+		for _, role := range p.user.EffectiveRoles() {
+			grants = append(grants, map[string]interface{}{"role": role})
+			denies = append(denies, map[string]interface{}{"role": role, "deny": true})
 		}
+		grants = append(grants, map[string]interface{}{"user_id": p.user.ID})
+		denies = append(denies, map[string]interface{}{"user_id": p.user.ID, "deny": true})
+	}
 
-		for _, role := range p.user.Roles {
-			escapedRole, err := json.Marshal(role)
-			if err != nil {
-				panic("unexpected serialize error on role")
-			}
-			b.WriteString(fmt.Sprintf(`%s @> '[{"role": %s}]' OR `, fullQuoteIdentifier(p.alias, "_access"), escapedRole))
-		}
-		b.WriteString(fmt.Sprintf(`%s @> '[{"user_id": %s}]' OR `, fullQuoteIdentifier(p.alias, "_access"), escapedID))
+	if p.level == skydb.ReadLevel {
+		grants = append(grants, map[string]interface{}{"public": true})
+	} else if p.level == skydb.WriteLevel {
+		grants = append(grants, map[string]interface{}{"public": true, "level": "write"})
+	}
+	denies = append(denies, map[string]interface{}{"public": true, "deny": true})
 
+	var b bytes.Buffer
+	args := []interface{}{}
+	b.WriteString(fmt.Sprintf(`(%s IS NULL OR `, access))
+	if p.user != nil {
 		b.WriteString(fmt.Sprintf(`%s = ? OR `, fullQuoteIdentifier(p.alias, "_owner_id")))
 		args = append(args, p.user.ID)
 	}
 
-	if p.level == skydb.ReadLevel {
-		b.WriteString(fmt.Sprintf(`%s @> '[{"public": true}]' OR `, fullQuoteIdentifier(p.alias, "_access")))
-	} else if p.level == skydb.WriteLevel {
-		b.WriteString(fmt.Sprintf(`%s @> '[{"public": true, "level": "write"}]' OR `, fullQuoteIdentifier(p.alias, "_access")))
+	containsSQL := func(entries []map[string]interface{}) string {
+		sqls := make([]string, len(entries))
+		for i, entry := range entries {
+			sqls[i] = fmt.Sprintf(`%s @> ?::jsonb`, access)
+			args = append(args, aceJSON(entry))
+		}
+		return strings.Join(sqls, " OR ")
 	}
-
-	b.WriteString(fmt.Sprintf(`%s IS NULL)`, fullQuoteIdentifier(p.alias, "_access")))
+	b.WriteString(`((`)
+	b.WriteString(containsSQL(grants))
+	b.WriteString(`) AND NOT (`)
+	b.WriteString(containsSQL(denies))
+	b.WriteString(`)))`)
 
 	return b.String(), args, nil
 }
 
+// aceJSON returns the JSON of an ACL containing the entry, to be matched
+// with the `_access` column by containment.
+func aceJSON(entry map[string]interface{}) string {
+	bytes, err := json.Marshal([]interface{}{entry})
+	if err != nil {
+		panic("unexpected serialize error on access entry")
+	}
+	return string(bytes)
+}
+
+// userRelationPredicateSqlizer generates SQL condition that checks if the
+// user in the specified column has a relation with the user.
+//
+// The relation is checked in subqueries instead of joining the relation
+// table, so that the condition can be combined with other conditions,
+// including conditions on another relation or the same relation of
+// another user.
+//
+// If negated, the condition checks that the user does not have the
+// relation. Each subquery is negated with NOT EXISTS, which is planned
+// as an anti-join, so that a mutual relation is absent if either
+// direction is absent.
 type userRelationPredicateSqlizer struct {
-	outwardAlias string
-	inwardAlias  string
-	user         string
+	alias         string
+	primaryColumn string
+	table         string
+	outward       bool
+	inward        bool
+	user          string
+	negated       bool
 }
 
 func (p userRelationPredicateSqlizer) ToSql() (sql string, args []interface{}, err error) {
-	if p.outwardAlias != "" && p.inwardAlias != "" {
-		sql = fmt.Sprintf("%s = %s AND %s = ?",
-			fullQuoteIdentifier(p.outwardAlias, "left_id"),
-			fullQuoteIdentifier(p.inwardAlias, "right_id"),
-			fullQuoteIdentifier(p.outwardAlias, "left_id"))
-	} else if p.outwardAlias != "" {
-		sql = fmt.Sprintf("%s = ?",
-			fullQuoteIdentifier(p.outwardAlias, "left_id"))
-	} else if p.inwardAlias != "" {
-		sql = fmt.Sprintf("%s = ?",
-			fullQuoteIdentifier(p.inwardAlias, "right_id"))
-	} else {
+	column := fullQuoteIdentifier(p.alias, p.primaryColumn)
+	exists := "EXISTS"
+	if p.negated {
+		exists = "NOT EXISTS"
+	}
+
+	conditions := []string{}
+	if p.outward {
+		conditions = append(conditions, fmt.Sprintf(
+			`%s (SELECT 1 FROM %s AS "_r" WHERE "_r"."right_id" = %s AND "_r"."left_id" = ?)`,
+			exists, p.table, column))
+		args = append(args, p.user)
+	}
+	if p.inward {
+		conditions = append(conditions, fmt.Sprintf(
+			`%s (SELECT 1 FROM %s AS "_r" WHERE "_r"."left_id" = %s AND "_r"."right_id" = ?)`,
+			exists, p.table, column))
+		args = append(args, p.user)
+	}
+	if len(conditions) == 0 {
 		panic("unexpected value in sqlizer")
 	}
-	args = []interface{}{p.user}
-	err = nil
+
+	if p.negated {
+		sql = "(" + strings.Join(conditions, " OR ") + ")"
+	} else {
+		sql = "(" + strings.Join(conditions, " AND ") + ")"
+	}
 	return
 }
 
@@ -188,6 +244,57 @@ func (p *containsComparisonPredicateSqlizer) ToSql() (sql string, args []interfa
 	return "", []interface{}{}, ErrCannotCompareUsingInOperator
 }
 
+// arrayComparisonPredicateSqlizer generates SQL condition that compares a
+// JSON array column against a list of values.
+//
+// ContainsAll is matched with the jsonb containment operator:
+// `"tags" @> '["red","blue"]'`
+//
+// ContainsAny is matched with jsonb_exists_any, which is the function
+// behind the `?|` operator. The operator itself is not used because `?`
+// would be taken as a placeholder:
+// `jsonb_exists_any("tags", ARRAY['red','blue'])`
+type arrayComparisonPredicateSqlizer struct {
+	sqlizers []expressionSqlizer
+	operator skydb.Operator
+}
+
+func (p *arrayComparisonPredicateSqlizer) ToSql() (sql string, args []interface{}, err error) {
+	lhs := p.sqlizers[0]
+	rhs := p.sqlizers[1]
+
+	values, ok := rhs.Value.([]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("comparison operator `%v` requires an array", p.operator)
+	}
+
+	sqlOperand, opArgs, err := lhs.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+	args = append([]interface{}{}, opArgs...)
+
+	switch p.operator {
+	case skydb.ContainsAll:
+		valuesInJSON, err := json.Marshal(values)
+		if err != nil {
+			return "", nil, err
+		}
+		sql = fmt.Sprintf("%s @> ?::jsonb", sqlOperand)
+		args = append(args, string(valuesInJSON))
+	case skydb.ContainsAny:
+		if len(values) == 0 {
+			return "FALSE", []interface{}{}, nil
+		}
+		sql = fmt.Sprintf("jsonb_exists_any(%s, ARRAY[%s])",
+			sqlOperand, sq.Placeholders(len(values)))
+		args = append(args, values...)
+	default:
+		return "", nil, fmt.Errorf("comparison operator `%v` is not supported", p.operator)
+	}
+	return sql, args, nil
+}
+
 type comparisonPredicateSqlizer struct {
 	sqlizers []expressionSqlizer
 	operator skydb.Operator
@@ -208,10 +315,15 @@ func (p *comparisonPredicateSqlizer) ToSql() (sql string, args []interface{}, er
 			}
 		}
 
+		caseInsensitive := isLowerFunc(lhs.Expression) || isLowerFunc(rhs.Expression)
+
 		sqlOperand, opArgs, err := lhs.ToSql()
 		if err != nil {
 			return "", nil, err
 		}
+		if caseInsensitive {
+			sqlOperand = lowerLiteralOperand(lhs.Expression, sqlOperand)
+		}
 		buffer.WriteString(sqlOperand)
 		args = append(args, opArgs...)
 
@@ -228,10 +340,37 @@ func (p *comparisonPredicateSqlizer) ToSql() (sql string, args []interface{}, er
 		if err != nil {
 			return "", nil, err
 		}
+		if caseInsensitive {
+			sqlOperand = lowerLiteralOperand(rhs.Expression, sqlOperand)
+		}
 		buffer.WriteString(sqlOperand)
 		args = append(args, opArgs...)
 
 		sql = buffer.String()
+	} else if p.operator == skydb.Between {
+		operands := make([]string, len(p.sqlizers))
+		for i, sqlizer := range p.sqlizers {
+			sqlOperand, opArgs, err := sqlizer.ToSql()
+			if err != nil {
+				return "", nil, err
+			}
+			operands[i] = sqlOperand
+			args = append(args, opArgs...)
+		}
+		sql = fmt.Sprintf("%s BETWEEN %s AND %s", operands[0], operands[1], operands[2])
+	} else if p.operator.IsUnary() {
+		sqlOperand, opArgs, err := p.sqlizers[0].ToSql()
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, opArgs...)
+
+		switch p.operator {
+		case skydb.IsNull:
+			sql = sqlOperand + ` IS NULL`
+		case skydb.IsNotNull:
+			sql = sqlOperand + ` IS NOT NULL`
+		}
 	} else {
 		err = fmt.Errorf("comparison operator `%v` is not supported", p.operator)
 	}
@@ -239,6 +378,28 @@ func (p *comparisonPredicateSqlizer) ToSql() (sql string, args []interface{}, er
 	return
 }
 
+func isLowerFunc(expr skydb.Expression) bool {
+	if expr.Type != skydb.Function {
+		return false
+	}
+	_, ok := expr.Value.(skydb.LowerFunc)
+	return ok
+}
+
+// lowerLiteralOperand converts a string literal to lower case in SQL, so
+// that it can be compared with a column converted by LowerFunc. The
+// conversion is done by the database so that both sides are converted
+// with the same collation.
+func lowerLiteralOperand(expr skydb.Expression, sqlOperand string) string {
+	if expr.Type != skydb.Literal {
+		return sqlOperand
+	}
+	if _, ok := expr.Value.(string); !ok {
+		return sqlOperand
+	}
+	return fmt.Sprintf("LOWER(%s)", sqlOperand)
+}
+
 func (p *comparisonPredicateSqlizer) writeOperator(buffer *bytes.Buffer) error {
 	switch p.operator {
 	default:
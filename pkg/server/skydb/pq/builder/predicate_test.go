@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	sq "github.com/lann/squirrel"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -71,6 +72,7 @@ func TestPredicateSqlizerFactory(t *testing.T) {
 				skydb.RecordSchema{
 					"title":   skydb.FieldType{Type: skydb.TypeString},
 					"content": skydb.FieldType{Type: skydb.TypeString},
+					"tags":    skydb.FieldType{Type: skydb.TypeJSON},
 				}, nil,
 			).AnyTimes()
 
@@ -121,6 +123,206 @@ func TestPredicateSqlizerFactory(t *testing.T) {
 			So(err, ShouldBeNil)
 		})
 
+		Convey("keypath is null", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.IsNull,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "content"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "\"note\".\"content\" IS NULL")
+			So(args, ShouldResemble, []interface{}{})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("keypath is not null", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.IsNotNull,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "content"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "\"note\".\"content\" IS NOT NULL")
+			So(args, ShouldResemble, []interface{}{})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("keypath contains all of array of values", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.ContainsAll,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "tags"},
+					skydb.Expression{skydb.Literal, []interface{}{"hello", "world"}},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "\"note\".\"tags\" @> ?::jsonb")
+			So(args, ShouldResemble, []interface{}{`["hello","world"]`})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("keypath contains any of array of values", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.ContainsAny,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "tags"},
+					skydb.Expression{skydb.Literal, []interface{}{"hello", "world"}},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "jsonb_exists_any(\"note\".\"tags\", ARRAY[?,?])")
+			So(args, ShouldResemble, []interface{}{"hello", "world"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("keypath contains any of empty array", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.ContainsAny,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "tags"},
+					skydb.Expression{skydb.Literal, []interface{}{}},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, _, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "FALSE")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("lower keypath equal string", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.LowerFunc{"title"}},
+					skydb.Expression{skydb.Literal, "Hello"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "LOWER(\"note\".\"title\")=LOWER(?)")
+			So(args, ShouldResemble, []interface{}{"Hello"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("lower keypath like string", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.Like,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.LowerFunc{"title"}},
+					skydb.Expression{skydb.Literal, "Hello%"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "LOWER(\"note\".\"title\") LIKE LOWER(?)")
+			So(args, ShouldResemble, []interface{}{"Hello%"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("length of keypath greater than number", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.GreaterThan,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.LengthFunc{
+						skydb.Expression{skydb.KeyPath, "title"},
+					}},
+					skydb.Expression{skydb.Literal, float64(10)},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "LENGTH(\"note\".\"title\")>?")
+			So(args, ShouldResemble, []interface{}{float64(10)})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("coalesce of keypath greater than or equal number", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.GreaterThanOrEqual,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.CoalesceFunc{[]skydb.Expression{
+						{skydb.KeyPath, "score"},
+						{skydb.Literal, float64(0)},
+					}}},
+					skydb.Expression{skydb.Literal, float64(5)},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "COALESCE(\"note\".\"score\", ?::double precision)>=?")
+			So(args, ShouldResemble, []interface{}{float64(0), float64(5)})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("upper and abs of keypath", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.UpperFunc{
+						skydb.Expression{skydb.KeyPath, "title"},
+					}},
+					skydb.Expression{skydb.Literal, "HELLO"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "UPPER(\"note\".\"title\")=?")
+			So(args, ShouldResemble, []interface{}{"HELLO"})
+			So(err, ShouldBeNil)
+
+			sqlizer, err = f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.LessThan,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.AbsFunc{
+						skydb.Expression{skydb.KeyPath, "score"},
+					}},
+					skydb.Expression{skydb.Literal, float64(3)},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err = sqlizer.ToSql()
+			So(sql, ShouldEqual, "ABS(\"note\".\"score\")<?")
+			So(args, ShouldResemble, []interface{}{float64(3)})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("date part of keypath equal number", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.DatePartFunc{"birthday", skydb.DatePartMonth}},
+					skydb.Expression{skydb.Literal, float64(4)},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "date_part('month', \"note\".\"birthday\")=?")
+			So(args, ShouldResemble, []interface{}{float64(4)})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("keypath between values", func() {
+			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
+				skydb.Between,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "title"},
+					skydb.Expression{skydb.Literal, "a"},
+					skydb.Expression{skydb.Literal, "m"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, "\"note\".\"title\" BETWEEN ? AND ?")
+			So(args, ShouldResemble, []interface{}{"a", "m"})
+			So(err, ShouldBeNil)
+		})
+
 		Convey("keypath is in array of values", func() {
 			sqlizer, err := f.newComparisonPredicateSqlizer(skydb.Predicate{
 				skydb.In,
@@ -163,6 +365,506 @@ func TestPredicateSqlizerFactory(t *testing.T) {
 		})
 	})
 
+	Convey("Key Path Traversal", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		db := mock_skydb.NewMockDatabase(ctrl)
+		db.EXPECT().RemoteColumnTypes(gomock.Eq("note")).
+			Return(
+				skydb.RecordSchema{
+					"title": skydb.FieldType{Type: skydb.TypeString},
+					"author": skydb.FieldType{
+						Type:          skydb.TypeReference,
+						ReferenceType: "user",
+					},
+				}, nil,
+			).AnyTimes()
+		db.EXPECT().RemoteColumnTypes(gomock.Eq("user")).
+			Return(
+				skydb.RecordSchema{
+					"name": skydb.FieldType{Type: skydb.TypeString},
+					"city": skydb.FieldType{
+						Type:          skydb.TypeReference,
+						ReferenceType: "city",
+					},
+				}, nil,
+			).AnyTimes()
+		db.EXPECT().RemoteColumnTypes(gomock.Eq("city")).
+			Return(
+				skydb.RecordSchema{
+					"name": skydb.FieldType{Type: skydb.TypeString},
+				}, nil,
+			).AnyTimes()
+		db.EXPECT().TableName(gomock.Eq("user")).
+			Return(`"app_test"."user"`).
+			AnyTimes()
+		db.EXPECT().TableName(gomock.Eq("city")).
+			Return(`"app_test"."city"`).
+			AnyTimes()
+
+		f := NewPredicateSqlizerFactory(db, "note").(*predicateSqlizerFactory)
+
+		Convey("keypath of referenced record", func() {
+			sqlizer, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "author.name"},
+					skydb.Expression{skydb.Literal, "Alice"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, `"_t0"."name" = ?`)
+			So(args, ShouldResemble, []interface{}{"Alice"})
+			So(err, ShouldBeNil)
+			So(f.joinedTables, ShouldResemble, []joinedTable{
+				{"note", "user", "author"},
+			})
+		})
+
+		Convey("keypath across multiple references", func() {
+			sqlizer, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "author.city.name"},
+					skydb.Expression{skydb.Literal, "Hong Kong"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, `"_t1"."name" = ?`)
+			So(args, ShouldResemble, []interface{}{"Hong Kong"})
+			So(err, ShouldBeNil)
+			So(f.joinedTables, ShouldResemble, []joinedTable{
+				{"note", "user", "author"},
+				{"_t0", "city", "city"},
+			})
+		})
+
+		Convey("keypath through non-reference field", func() {
+			_, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "title.name"},
+					skydb.Expression{skydb.Literal, "Alice"},
+				},
+			})
+			builderError, ok := err.(skyerr.Error)
+			So(ok, ShouldBeTrue)
+			So(builderError.Code(), ShouldEqual, skyerr.RecordQueryInvalid)
+		})
+
+		Convey("sort by keypath of referenced record", func() {
+			orderBy, err := f.NewSortOrderBySQL(skydb.Sort{
+				Expression: skydb.Expression{skydb.KeyPath, "author.name"},
+				Order:      skydb.Desc,
+			})
+			So(err, ShouldBeNil)
+			So(orderBy, ShouldEqual, `"_t0"."name" DESC`)
+		})
+
+		Convey("function with keypath of referenced record", func() {
+			sqlizer, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.ConcatFunc{[]skydb.Expression{
+						skydb.Expression{skydb.KeyPath, "title"},
+						skydb.Expression{skydb.KeyPath, "author.name"},
+					}}},
+					skydb.Expression{skydb.Literal, "Hello Alice"},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `CONCAT("note"."title", "_t0"."name") = ?`)
+			So(args, ShouldResemble, []interface{}{"Hello Alice"})
+			So(f.joinedTables, ShouldResemble, []joinedTable{
+				{"note", "user", "author"},
+			})
+		})
+
+		Convey("sort by function with keypath of referenced record", func() {
+			orderBy, err := f.NewSortOrderBySQL(skydb.Sort{
+				Expression: skydb.Expression{skydb.Function, skydb.UpperFunc{
+					skydb.Expression{skydb.KeyPath, "author.city.name"},
+				}},
+				Order: skydb.Asc,
+			})
+			So(err, ShouldBeNil)
+			So(orderBy, ShouldEqual, `UPPER("_t1"."name") ASC`)
+			So(f.joinedTables, ShouldResemble, []joinedTable{
+				{"note", "user", "author"},
+				{"_t0", "city", "city"},
+			})
+		})
+
+		Convey("function with keypath through non-reference field", func() {
+			_, err := f.NewSortOrderBySQL(skydb.Sort{
+				Expression: skydb.Expression{skydb.Function, skydb.LengthFunc{
+					skydb.Expression{skydb.KeyPath, "title.name"},
+				}},
+				Order: skydb.Asc,
+			})
+			builderError, ok := err.(skyerr.Error)
+			So(ok, ShouldBeTrue)
+			So(builderError.Code(), ShouldEqual, skyerr.RecordQueryInvalid)
+		})
+
+		Convey("predicate and sort share the same join", func() {
+			_, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "author.name"},
+					skydb.Expression{skydb.Literal, "Alice"},
+				},
+			})
+			So(err, ShouldBeNil)
+			_, err = f.NewSortOrderBySQL(skydb.Sort{
+				Expression: skydb.Expression{skydb.KeyPath, "author.name"},
+				Order:      skydb.Asc,
+			})
+			So(err, ShouldBeNil)
+
+			sql, _, err := f.AddJoinsToSelectBuilder(sq.Select("*").From(`"note"`)).ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `SELECT * FROM "note" LEFT JOIN "app_test"."user" AS "_t0" ON "note"."author" = "_t0"."_id"`)
+		})
+
+		Convey("joins across multiple references without DISTINCT", func() {
+			_, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "author.city.name"},
+					skydb.Expression{skydb.Literal, "Hong Kong"},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			sql, _, err := f.AddJoinsToSelectBuilder(sq.Select("*").From(`"note"`)).ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `SELECT * FROM "note" `+
+				`LEFT JOIN "app_test"."user" AS "_t0" ON "note"."author" = "_t0"."_id" `+
+				`LEFT JOIN "app_test"."city" AS "_t1" ON "_t0"."city" = "_t1"."_id"`)
+		})
+
+		Convey("joins more tables than the limit", func() {
+			SetMaxJoins(1)
+			defer SetMaxJoins(0)
+
+			_, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "author.name"},
+					skydb.Expression{skydb.Literal, "Alice"},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			_, err = f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Equal,
+				[]interface{}{
+					skydb.Expression{skydb.KeyPath, "author.city.name"},
+					skydb.Expression{skydb.Literal, "Hong Kong"},
+				},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.RecordQueryTooComplex)
+		})
+	})
+
+	Convey("Distinct On", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		db := mock_skydb.NewMockDatabase(ctrl)
+		db.EXPECT().RemoteColumnTypes(gomock.Eq("message")).
+			Return(
+				skydb.RecordSchema{
+					"body": skydb.FieldType{Type: skydb.TypeString},
+					"conversation": skydb.FieldType{
+						Type:          skydb.TypeReference,
+						ReferenceType: "conversation",
+					},
+				}, nil,
+			).AnyTimes()
+
+		f := NewPredicateSqlizerFactory(db, "message").(*predicateSqlizerFactory)
+
+		Convey("select distinct on keypath", func() {
+			err := f.SetDistinctOn([]string{"conversation"})
+			So(err, ShouldBeNil)
+
+			sql, _, err := f.AddJoinsToSelectBuilder(sq.Select("*").From(`"message"`)).ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `SELECT DISTINCT ON ("message"."conversation") * FROM "message"`)
+		})
+
+		Convey("distinct on non-existing keypath", func() {
+			err := f.SetDistinctOn([]string{"sender"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("sorts begin with distinct on keypaths", func() {
+			createdAtSort := skydb.Sort{
+				Expression: skydb.Expression{skydb.KeyPath, "_created_at"},
+				Order:      skydb.Desc,
+			}
+			sorts := DistinctOnSorts([]string{"conversation", "body"}, []skydb.Sort{
+				createdAtSort,
+				{Expression: skydb.Expression{skydb.KeyPath, "body"}, Order: skydb.Desc},
+			})
+			So(sorts, ShouldResemble, []skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "conversation"}, Order: skydb.Asc},
+				{Expression: skydb.Expression{skydb.KeyPath, "body"}, Order: skydb.Desc},
+				createdAtSort,
+			})
+		})
+	})
+
+	Convey("Tree Predicate", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		db := mock_skydb.NewMockDatabase(ctrl)
+		db.EXPECT().RemoteColumnTypes(gomock.Eq("comment")).
+			Return(
+				skydb.RecordSchema{
+					"body": skydb.FieldType{Type: skydb.TypeString},
+					"parent": skydb.FieldType{
+						Type:          skydb.TypeReference,
+						ReferenceType: "comment",
+					},
+					"author": skydb.FieldType{
+						Type:          skydb.TypeReference,
+						ReferenceType: "user",
+					},
+				}, nil,
+			).AnyTimes()
+		db.EXPECT().TableName(gomock.Eq("comment")).
+			Return(`"app_test"."comment"`).
+			AnyTimes()
+
+		f := NewPredicateSqlizerFactory(db, "comment").(*predicateSqlizerFactory)
+
+		Convey("descendants of record", func() {
+			sqlizer, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Functional,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.DescendantFunc{
+						ParentKeyPath: "parent",
+						RecordID:      skydb.NewRecordID("comment", "1"),
+					}},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, `"comment"."_id" IN (WITH RECURSIVE "_tree"("_id", "_path") AS (`+
+				`SELECT "_c"."_id", ARRAY[?::text, "_c"."_id"] FROM "app_test"."comment" AS "_c" WHERE "_c"."parent" = ? `+
+				`UNION ALL `+
+				`SELECT "_c"."_id", "_tree"."_path" || "_c"."_id" FROM "app_test"."comment" AS "_c" `+
+				`JOIN "_tree" ON "_c"."parent" = "_tree"."_id" `+
+				`WHERE NOT "_c"."_id" = ANY("_tree"."_path")) `+
+				`SELECT "_id" FROM "_tree")`)
+			So(args, ShouldResemble, []interface{}{"1", "1"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("descendants of record within max depth", func() {
+			sqlizer, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Functional,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.DescendantFunc{
+						ParentKeyPath: "parent",
+						RecordID:      skydb.NewRecordID("comment", "1"),
+						MaxDepth:      2,
+					}},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldContainSubstring, `WHERE NOT "_c"."_id" = ANY("_tree"."_path") AND array_length("_tree"."_path", 1) <= ?)`)
+			So(args, ShouldResemble, []interface{}{"1", "1", 2})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("ancestors of record", func() {
+			sqlizer, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Functional,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.AncestorFunc{
+						ParentKeyPath: "parent",
+						RecordID:      skydb.NewRecordID("comment", "3"),
+					}},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, `"comment"."_id" IN (WITH RECURSIVE "_tree"("_id", "_parent", "_path") AS (`+
+				`SELECT "_c"."_id", "_c"."parent", ARRAY["_c"."_id"] FROM "app_test"."comment" AS "_c" WHERE "_c"."_id" = ? `+
+				`UNION ALL `+
+				`SELECT "_c"."_id", "_c"."parent", "_tree"."_path" || "_c"."_id" FROM "app_test"."comment" AS "_c" `+
+				`JOIN "_tree" ON "_c"."_id" = "_tree"."_parent" `+
+				`WHERE NOT "_c"."_id" = ANY("_tree"."_path")) `+
+				`SELECT "_id" FROM "_tree" WHERE array_length("_path", 1) > 1)`)
+			So(args, ShouldResemble, []interface{}{"3"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("depth less than or equal number", func() {
+			sqlizer, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.LessThanOrEqual,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.TreeDepthFunc{"parent"}},
+					skydb.Expression{skydb.Literal, float64(1)},
+				},
+			})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldStartWith, `(WITH RECURSIVE "_tree"("_id", "_parent", "_path") AS (`+
+				`SELECT "_c"."_id", "_c"."parent", ARRAY["_c"."_id"] FROM "app_test"."comment" AS "_c" WHERE "_c"."_id" = "comment"."_id" `)
+			So(sql, ShouldEndWith, ` SELECT max(array_length("_path", 1)) - 1 FROM "_tree")<=?`)
+			So(args, ShouldResemble, []interface{}{float64(1)})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("sort by depth", func() {
+			orderBy, err := f.NewSortOrderBySQL(skydb.Sort{
+				Expression: skydb.Expression{skydb.Function, skydb.TreeDepthFunc{"parent"}},
+				Order:      skydb.Asc,
+			})
+			So(err, ShouldBeNil)
+			So(orderBy, ShouldEndWith, ` SELECT max(array_length("_path", 1)) - 1 FROM "_tree") ASC`)
+		})
+
+		Convey("parent field not referencing the same type", func() {
+			_, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Functional,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.DescendantFunc{
+						ParentKeyPath: "author",
+						RecordID:      skydb.NewRecordID("comment", "1"),
+					}},
+				},
+			})
+			builderError, ok := err.(skyerr.Error)
+			So(ok, ShouldBeTrue)
+			So(builderError.Code(), ShouldEqual, skyerr.RecordQueryInvalid)
+		})
+
+		Convey("record of another type", func() {
+			_, err := f.NewPredicateSqlizer(skydb.Predicate{
+				skydb.Functional,
+				[]interface{}{
+					skydb.Expression{skydb.Function, skydb.AncestorFunc{
+						ParentKeyPath: "parent",
+						RecordID:      skydb.NewRecordID("note", "1"),
+					}},
+				},
+			})
+			builderError, ok := err.(skyerr.Error)
+			So(ok, ShouldBeTrue)
+			So(builderError.Code(), ShouldEqual, skyerr.RecordQueryInvalid)
+		})
+	})
+
+	Convey("Keyset Pagination", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		db := mock_skydb.NewMockDatabase(ctrl)
+		db.EXPECT().RemoteColumnTypes(gomock.Eq("note")).
+			Return(
+				skydb.RecordSchema{
+					"_id":       skydb.FieldType{Type: skydb.TypeString},
+					"title":     skydb.FieldType{Type: skydb.TypeString},
+					"noteOrder": skydb.FieldType{Type: skydb.TypeNumber},
+				}, nil,
+			).AnyTimes()
+
+		f := NewPredicateSqlizerFactory(db, "note").(*predicateSqlizerFactory)
+
+		Convey("keyset sorts end with record key", func() {
+			sorts := KeysetSorts([]skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "title"}, Order: skydb.Desc},
+			})
+			So(sorts, ShouldResemble, []skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "title"}, Order: skydb.Desc},
+				{Expression: skydb.Expression{skydb.KeyPath, "_id"}, Order: skydb.Desc},
+			})
+		})
+
+		Convey("keyset of uniform sort orders", func() {
+			sqlizer, err := f.NewKeysetSqlizer(KeysetSorts([]skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "title"}, Order: skydb.Asc},
+			}), []interface{}{"Hello", "note1"})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `((("note"."title" > ? OR "note"."title" IS NULL)) OR ("note"."title" = ? AND ("note"."_id" > ? OR "note"."_id" IS NULL)))`)
+			So(args, ShouldResemble, []interface{}{
+				"Hello",
+				"Hello", "note1",
+			})
+		})
+
+		Convey("keyset of mixed sort orders", func() {
+			sqlizer, err := f.NewKeysetSqlizer(KeysetSorts([]skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "noteOrder"}, Order: skydb.Desc},
+				{Expression: skydb.Expression{skydb.KeyPath, "title"}, Order: skydb.Asc},
+			}), []interface{}{float64(1), "Hello", "note1"})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `(("note"."noteOrder" < ?) OR ("note"."noteOrder" = ? AND ("note"."title" > ? OR "note"."title" IS NULL)) OR ("note"."noteOrder" = ? AND "note"."title" = ? AND ("note"."_id" > ? OR "note"."_id" IS NULL)))`)
+			So(args, ShouldResemble, []interface{}{
+				float64(1),
+				float64(1), "Hello",
+				float64(1), "Hello", "note1",
+			})
+		})
+
+		Convey("keyset of null values with nulls last", func() {
+			sqlizer, err := f.NewKeysetSqlizer(KeysetSorts([]skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "title"}, Order: skydb.Desc, Nulls: skydb.NullsLast},
+			}), []interface{}{nil, "note1"})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `(("note"."title" IS NULL AND "note"."_id" < ?))`)
+			So(args, ShouldResemble, []interface{}{"note1"})
+		})
+
+		Convey("keyset of null values with nulls first", func() {
+			sqlizer, err := f.NewKeysetSqlizer(KeysetSorts([]skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "title"}, Order: skydb.Asc, Nulls: skydb.NullsFirst},
+			}), []interface{}{nil, "note1"})
+			So(err, ShouldBeNil)
+			sql, args, err := sqlizer.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `(("note"."title" IS NOT NULL) OR ("note"."title" IS NULL AND ("note"."_id" > ? OR "note"."_id" IS NULL)))`)
+			So(args, ShouldResemble, []interface{}{"note1"})
+		})
+
+		Convey("keyset of non-null values with nulls first", func() {
+			sqlizer, err := f.NewKeysetSqlizer(KeysetSorts([]skydb.Sort{
+				{Expression: skydb.Expression{skydb.KeyPath, "title"}, Order: skydb.Asc, Nulls: skydb.NullsFirst},
+			}), []interface{}{"Hello", "note1"})
+			So(err, ShouldBeNil)
+			sql, _, err := sqlizer.ToSql()
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, `(("note"."title" > ?) OR ("note"."title" = ? AND ("note"."_id" > ? OR "note"."_id" IS NULL)))`)
+		})
+
+		Convey("keyset values not matching sorts", func() {
+			_, err := f.NewKeysetSqlizer(KeysetSorts(nil), []interface{}{"Hello", "note1"})
+			builderError, ok := err.(skyerr.Error)
+			So(ok, ShouldBeTrue)
+			So(builderError.Code(), ShouldEqual, skyerr.RecordQueryInvalid)
+		})
+	})
+
 	Convey("Distance Predicate", t, func() {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -225,6 +927,36 @@ func TestNotSqlizer(t *testing.T) {
 	})
 }
 
+func TestUserRelationPredicateSqlizer(t *testing.T) {
+	Convey("userRelationPredicateSqlizer", t, func() {
+		sqlizer := userRelationPredicateSqlizer{
+			alias:         "note",
+			primaryColumn: "_owner_id",
+			table:         `"app"."_friend"`,
+			outward:       true,
+			inward:        true,
+			user:          "user1",
+		}
+
+		Convey("should generate relation predicate", func() {
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, `(EXISTS (SELECT 1 FROM "app"."_friend" AS "_r" WHERE "_r"."right_id" = "note"."_owner_id" AND "_r"."left_id" = ?) AND `+
+				`EXISTS (SELECT 1 FROM "app"."_friend" AS "_r" WHERE "_r"."left_id" = "note"."_owner_id" AND "_r"."right_id" = ?))`)
+			So(args, ShouldResemble, []interface{}{"user1", "user1"})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("should generate anti-join when negated", func() {
+			sqlizer.negated = true
+			sql, args, err := sqlizer.ToSql()
+			So(sql, ShouldEqual, `(NOT EXISTS (SELECT 1 FROM "app"."_friend" AS "_r" WHERE "_r"."right_id" = "note"."_owner_id" AND "_r"."left_id" = ?) OR `+
+				`NOT EXISTS (SELECT 1 FROM "app"."_friend" AS "_r" WHERE "_r"."left_id" = "note"."_owner_id" AND "_r"."right_id" = ?))`)
+			So(args, ShouldResemble, []interface{}{"user1", "user1"})
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
 func TestFalseSqlizer(t *testing.T) {
 	Convey("FalseSqlizer", t, func() {
 		Convey("should generate predicate that evaluates to false", func() {
@@ -251,11 +983,17 @@ func TestAccessPredicateSqlizer(t *testing.T) {
 			sql, args, err := sqlizer.ToSql()
 			So(err, ShouldBeNil)
 			So(sql, ShouldEqual,
-				`("note"."_access" @> '[{"user_id": "userid"}]' OR `+
+				`("note"."_access" IS NULL OR `+
 					`"note"."_owner_id" = ? OR `+
-					`"note"."_access" @> '[{"public": true}]' OR `+
-					`"note"."_access" IS NULL)`)
-			So(args, ShouldResemble, []interface{}{"userid"})
+					`(("note"."_access" @> ?::jsonb OR "note"."_access" @> ?::jsonb) AND `+
+					`NOT ("note"."_access" @> ?::jsonb OR "note"."_access" @> ?::jsonb)))`)
+			So(args, ShouldResemble, []interface{}{
+				"userid",
+				`[{"user_id":"userid"}]`,
+				`[{"public":true}]`,
+				`[{"deny":true,"user_id":"userid"}]`,
+				`[{"deny":true,"public":true}]`,
+			})
 		})
 
 		Convey("serialized for nil user and read", func() {
@@ -267,9 +1005,12 @@ func TestAccessPredicateSqlizer(t *testing.T) {
 			sql, args, err := sqlizer.ToSql()
 			So(err, ShouldBeNil)
 			So(sql, ShouldEqual,
-				`("_access" @> '[{"public": true}]' OR `+
-					`"_access" IS NULL)`)
-			So(args, ShouldResemble, []interface{}{})
+				`("_access" IS NULL OR `+
+					`(("_access" @> ?::jsonb) AND NOT ("_access" @> ?::jsonb)))`)
+			So(args, ShouldResemble, []interface{}{
+				`[{"public":true}]`,
+				`[{"deny":true,"public":true}]`,
+			})
 		})
 
 		Convey("serialized for nil user and write", func() {
@@ -281,9 +1022,12 @@ func TestAccessPredicateSqlizer(t *testing.T) {
 			sql, args, err := sqlizer.ToSql()
 			So(err, ShouldBeNil)
 			So(sql, ShouldEqual,
-				`("_access" @> '[{"public": true, "level": "write"}]' OR `+
-					`"_access" IS NULL)`)
-			So(args, ShouldResemble, []interface{}{})
+				`("_access" IS NULL OR `+
+					`(("_access" @> ?::jsonb) AND NOT ("_access" @> ?::jsonb)))`)
+			So(args, ShouldResemble, []interface{}{
+				`[{"level":"write","public":true}]`,
+				`[{"deny":true,"public":true}]`,
+			})
 		})
 
 		Convey("serialized for role based ACE", func() {
@@ -299,13 +1043,27 @@ func TestAccessPredicateSqlizer(t *testing.T) {
 			sql, args, err := sqlizer.ToSql()
 			So(err, ShouldBeNil)
 			So(sql, ShouldEqual,
-				`("_access" @> '[{"role": "admin"}]' OR `+
-					`"_access" @> '[{"role": "writer"}]' OR `+
-					`"_access" @> '[{"user_id": "userid"}]' OR `+
+				`("_access" IS NULL OR `+
 					`"_owner_id" = ? OR `+
-					`"_access" @> '[{"public": true}]' OR `+
-					`"_access" IS NULL)`)
-			So(args, ShouldResemble, []interface{}{"userid"})
+					`(("_access" @> ?::jsonb OR "_access" @> ?::jsonb OR "_access" @> ?::jsonb OR "_access" @> ?::jsonb) AND `+
+					`NOT ("_access" @> ?::jsonb OR "_access" @> ?::jsonb OR "_access" @> ?::jsonb OR "_access" @> ?::jsonb)))`)
+			So(args, ShouldResemble, []interface{}{
+				"userid",
+				`[{"role":"admin"}]`,
+				`[{"role":"writer"}]`,
+				`[{"user_id":"userid"}]`,
+				`[{"public":true}]`,
+				`[{"deny":true,"role":"admin"}]`,
+				`[{"deny":true,"role":"writer"}]`,
+				`[{"deny":true,"user_id":"userid"}]`,
+				`[{"deny":true,"public":true}]`,
+			})
+		})
+
+		Convey("generates same SQL for different users", func() {
+			sql1, _, _ := (&accessPredicateSqlizer{"", &skydb.AuthInfo{ID: "alice"}, skydb.ReadLevel}).ToSql()
+			sql2, _, _ := (&accessPredicateSqlizer{"", &skydb.AuthInfo{ID: "bob'); --"}, skydb.ReadLevel}).ToSql()
+			So(sql2, ShouldEqual, sql1)
 		})
 	})
 }
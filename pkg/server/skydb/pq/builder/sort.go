@@ -17,27 +17,18 @@ package builder
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 )
 
 func SortOrderBySQL(alias string, sort skydb.Sort) (string, error) {
-	var expr string
-
-	switch sort.Expression.Type {
-	case skydb.KeyPath:
-		expr = fullQuoteIdentifier(alias, sort.Expression.Value.(string))
-	case skydb.Function:
-		var err error
-		expr, err = funcOrderBySQL(alias, sort.Expression.Value.(skydb.Func))
-		if err != nil {
-			return "", err
-		}
-	default:
-		return "", errors.New("invalid Sort: specify either KeyPath or Func")
+	expr, err := SortExpressionSQL(alias, sort)
+	if err != nil {
+		return "", err
 	}
 
-	order, err := sortOrderOrderBySQL(sort.Order)
+	order, err := sortOrderOrderBySQL(sort.Order, sort.Nulls)
 	if err != nil {
 		return "", err
 	}
@@ -45,6 +36,19 @@ func SortOrderBySQL(alias string, sort skydb.Sort) (string, error) {
 	return fmt.Sprintf(expr + " " + order), nil
 }
 
+// SortExpressionSQL returns the SQL expression by which records are sorted.
+func SortExpressionSQL(alias string, sort skydb.Sort) (string, error) {
+	switch sort.Expression.Type {
+	case skydb.KeyPath:
+		components := sort.Expression.KeyPathComponents()
+		return fullQuoteIdentifier(alias, components[len(components)-1]), nil
+	case skydb.Function:
+		return funcOrderBySQL(alias, sort.Expression.Value.(skydb.Func))
+	default:
+		return "", errors.New("invalid Sort: specify either KeyPath or Func")
+	}
+}
+
 // due to sq not being able to pass args in OrderBy, we can't re-use funcToSQLOperand
 func funcOrderBySQL(alias string, fun skydb.Func) (string, error) {
 	switch f := fun.(type) {
@@ -56,18 +60,254 @@ func funcOrderBySQL(alias string, fun skydb.Func) (string, error) {
 			f.Location.Lat(),
 		)
 		return sql, nil
+	case skydb.LowerFunc:
+		return fmt.Sprintf("LOWER(%s)", fullQuoteIdentifier(alias, f.KeyPath)), nil
+	case skydb.DatePartFunc:
+		if !f.Part.IsValid() {
+			return "", fmt.Errorf("got unrecgonized date part = %s", f.Part)
+		}
+		return datePartSQL(alias, f), nil
+	case skydb.RandomFunc:
+		return randomSQL(f)
+	case treeDepthFunc:
+		return treeDepthSQL(alias, f), nil
+	case columnFunc:
+		return fullQuoteIdentifier(f.alias, f.column), nil
+	case skydb.ConcatFunc:
+		operands := make([]string, len(f.Expressions))
+		for i, expr := range f.Expressions {
+			operand, err := funcOperandOrderBySQL(alias, expr)
+			if err != nil {
+				return "", err
+			}
+			operands[i] = operand
+		}
+		return fmt.Sprintf("CONCAT(%s)", strings.Join(operands, ", ")), nil
+	case skydb.CoalesceFunc:
+		operands := make([]string, len(f.Expressions))
+		for i, expr := range f.Expressions {
+			operand, err := funcOperandOrderBySQL(alias, expr)
+			if err != nil {
+				return "", err
+			}
+			operands[i] = operand
+		}
+		return fmt.Sprintf("COALESCE(%s)", strings.Join(operands, ", ")), nil
+	case skydb.UpperFunc:
+		return unaryFuncOrderBySQL(alias, "UPPER", f.Expression)
+	case skydb.LengthFunc:
+		return unaryFuncOrderBySQL(alias, "LENGTH", f.Expression)
+	case skydb.AbsFunc:
+		return unaryFuncOrderBySQL(alias, "ABS", f.Expression)
+	case skydb.ArithmeticFunc:
+		left, err := funcOperandOrderBySQL(alias, f.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := funcOperandOrderBySQL(alias, f.Right)
+		if err != nil {
+			return "", err
+		}
+		if f.Operator == skydb.Divide {
+			right = fmt.Sprintf("NULLIF(%s, 0)", right)
+		}
+		return fmt.Sprintf("(%s %s %s)", left, string(f.Operator), right), nil
 	default:
 		return "", fmt.Errorf("got unrecgonized skydb.Func = %T", fun)
 	}
 }
 
-func sortOrderOrderBySQL(order skydb.SortOrder) (string, error) {
+func unaryFuncOrderBySQL(alias string, name string, expr skydb.Expression) (string, error) {
+	operand, err := funcOperandOrderBySQL(alias, expr)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s(%s)", name, operand), nil
+}
+
+// funcOperandOrderBySQL generates SQL for an expression nested inside a
+// function, with literals inlined.
+func funcOperandOrderBySQL(alias string, expr skydb.Expression) (string, error) {
+	switch expr.Type {
+	case skydb.KeyPath:
+		components := expr.KeyPathComponents()
+		return fullQuoteIdentifier(alias, components[len(components)-1]), nil
+	case skydb.Function:
+		return funcOrderBySQL(alias, expr.Value.(skydb.Func))
+	}
+
+	switch v := expr.Value.(type) {
+	case nil:
+		return "NULL", nil
+	case float64:
+		return fmt.Sprintf("%v::double precision", v), nil
+	case string:
+		return quoteLiteral(v) + "::text", nil
+	case bool:
+		return fmt.Sprintf("%t", v), nil
+	default:
+		return "", fmt.Errorf("got unsupported literal in sort = %T", v)
+	}
+}
+
+// quoteLiteral quotes a string literal so that it can be inlined in SQL.
+func quoteLiteral(literal string) string {
+	literal = strings.Replace(literal, `'`, `''`, -1)
+	if strings.Contains(literal, `\`) {
+		return `E'` + strings.Replace(literal, `\`, `\\`, -1) + `'`
+	}
+	return `'` + literal + `'`
+}
+
+func sortOrderOrderBySQL(order skydb.SortOrder, nulls skydb.NullOrder) (string, error) {
+	var sql string
 	switch order {
 	case skydb.Asc:
-		return "ASC", nil
+		sql = "ASC"
 	case skydb.Desc:
-		return "DESC", nil
+		sql = "DESC"
 	default:
 		return "", fmt.Errorf("unknown sort order = %v", order)
 	}
+
+	switch nulls {
+	case skydb.NullsDefault:
+		return sql, nil
+	case skydb.NullsFirst:
+		return sql + " NULLS FIRST", nil
+	case skydb.NullsLast:
+		return sql + " NULLS LAST", nil
+	default:
+		return "", fmt.Errorf("unknown null order = %v", nulls)
+	}
+}
+
+// DistinctOnSorts returns the sorts of a query selecting distinct records
+// on the specified key paths. Postgres requires the DISTINCT ON
+// expressions to match the leftmost ORDER BY expressions, so the sorts
+// begin with a sort by each of the key paths, in the order specified in
+// sorts if any, followed by the remaining sorts.
+func DistinctOnSorts(keyPaths []string, sorts []skydb.Sort) []skydb.Sort {
+	distinctSorts := make([]skydb.Sort, 0, len(keyPaths)+len(sorts))
+	remaining := make([]skydb.Sort, len(sorts))
+	copy(remaining, sorts)
+	for _, keyPath := range keyPaths {
+		sort := skydb.Sort{
+			Expression: skydb.Expression{
+				Type:  skydb.KeyPath,
+				Value: keyPath,
+			},
+			Order: skydb.Ascending,
+		}
+		for i, s := range remaining {
+			if s.Expression.IsKeyPath() && s.Expression.Value == keyPath {
+				sort.Order = s.Order
+				sort.Nulls = s.Nulls
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+		distinctSorts = append(distinctSorts, sort)
+	}
+	return append(distinctSorts, remaining...)
+}
+
+// KeysetSorts returns the sorts of a query paginated by keyset, which
+// ends with a sort by record key so that every record has a distinct
+// position in the results.
+func KeysetSorts(sorts []skydb.Sort) []skydb.Sort {
+	order := skydb.Ascending
+	if len(sorts) > 0 {
+		order = sorts[len(sorts)-1].Order
+	}
+
+	keysetSorts := make([]skydb.Sort, len(sorts), len(sorts)+1)
+	copy(keysetSorts, sorts)
+	return append(keysetSorts, skydb.Sort{
+		Expression: skydb.Expression{
+			Type:  skydb.KeyPath,
+			Value: "_id",
+		},
+		Order: order,
+	})
+}
+
+// keysetSqlizer generates SQL condition that matches records sorted after
+// the specified values in keyset pagination.
+//
+// The condition is expanded to comparisons of each expression, because
+// a row comparison does not match rows containing NULL. NULL values are
+// placed in the same way as in the ORDER BY clause, that is after other
+// values in ascending order and before them in descending order unless
+// specified otherwise.
+type keysetSqlizer struct {
+	exprs  []string
+	orders []skydb.SortOrder
+	nulls  []skydb.NullOrder
+	values []interface{}
+}
+
+func (s *keysetSqlizer) ToSql() (sql string, args []interface{}, err error) {
+	clauses := make([]string, 0, len(s.exprs))
+	for i, expr := range s.exprs {
+		after, afterArgs, ok := s.afterSQL(i, expr)
+		if !ok {
+			// nothing is sorted after NULL in this expression
+			continue
+		}
+
+		conds := make([]string, 0, i+1)
+		clauseArgs := []interface{}{}
+		for j := 0; j < i; j++ {
+			if s.values[j] == nil {
+				conds = append(conds, s.exprs[j]+" IS NULL")
+			} else {
+				conds = append(conds, s.exprs[j]+" = ?")
+				clauseArgs = append(clauseArgs, s.values[j])
+			}
+		}
+		conds = append(conds, after)
+		clauses = append(clauses, "("+strings.Join(conds, " AND ")+")")
+		args = append(args, clauseArgs...)
+		args = append(args, afterArgs...)
+	}
+
+	if len(clauses) == 0 {
+		return "FALSE", nil, nil
+	}
+	sql = "(" + strings.Join(clauses, " OR ") + ")"
+	return sql, args, nil
+}
+
+// afterSQL returns the condition matching values of the i-th expression
+// sorted after the specified value. ok is false if no value is sorted
+// after it.
+func (s *keysetSqlizer) afterSQL(i int, expr string) (sql string, args []interface{}, ok bool) {
+	operator := ">"
+	if s.orders[i] == skydb.Descending {
+		operator = "<"
+	}
+
+	nullsFirst := s.orders[i] == skydb.Descending
+	if i < len(s.nulls) {
+		switch s.nulls[i] {
+		case skydb.NullsFirst:
+			nullsFirst = true
+		case skydb.NullsLast:
+			nullsFirst = false
+		}
+	}
+
+	if s.values[i] == nil {
+		if nullsFirst {
+			return expr + " IS NOT NULL", nil, true
+		}
+		return "", nil, false
+	}
+
+	sql = expr + " " + operator + " ?"
+	if !nullsFirst {
+		sql = "(" + sql + " OR " + expr + " IS NULL)"
+	}
+	return sql, []interface{}{s.values[i]}, true
 }
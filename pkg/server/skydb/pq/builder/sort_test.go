@@ -0,0 +1,75 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestSortOrderBySQL(t *testing.T) {
+	Convey("SortOrderBySQL", t, func() {
+		Convey("sort with nulls last", func() {
+			orderBy, err := SortOrderBySQL("note", skydb.Sort{
+				Expression: skydb.Expression{skydb.KeyPath, "score"},
+				Order:      skydb.Desc,
+				Nulls:      skydb.NullsLast,
+			})
+			So(err, ShouldBeNil)
+			So(orderBy, ShouldEqual, `"note"."score" DESC NULLS LAST`)
+		})
+
+		Convey("sort with nulls first", func() {
+			orderBy, err := SortOrderBySQL("note", skydb.Sort{
+				Expression: skydb.Expression{skydb.KeyPath, "score"},
+				Order:      skydb.Asc,
+				Nulls:      skydb.NullsFirst,
+			})
+			So(err, ShouldBeNil)
+			So(orderBy, ShouldEqual, `"note"."score" ASC NULLS FIRST`)
+		})
+
+		Convey("random sort", func() {
+			orderBy, err := SortOrderBySQL("note", skydb.Sort{
+				Expression: skydb.Expression{skydb.Function, skydb.RandomFunc{}},
+				Order:      skydb.Asc,
+			})
+			So(err, ShouldBeNil)
+			So(orderBy, ShouldEqual, `random() ASC`)
+		})
+
+		Convey("seeded random sort", func() {
+			seed := 0.5
+			orderBy, err := SortOrderBySQL("note", skydb.Sort{
+				Expression: skydb.Expression{skydb.Function, skydb.RandomFunc{&seed}},
+				Order:      skydb.Asc,
+			})
+			So(err, ShouldBeNil)
+			So(orderBy, ShouldEqual, `((SELECT 0 FROM (SELECT setseed(0.5)) AS "_seed") + random()) ASC`)
+		})
+
+		Convey("random sort with invalid seed", func() {
+			seed := float64(2)
+			_, err := SortOrderBySQL("note", skydb.Sort{
+				Expression: skydb.Expression{skydb.Function, skydb.RandomFunc{&seed}},
+				Order:      skydb.Asc,
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
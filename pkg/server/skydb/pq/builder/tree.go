@@ -0,0 +1,99 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Tree functions are compiled into recursive CTEs walking a
+// self-referencing parent field. Each recursion carries the path of
+// visited record IDs so that a cycle in the parent field terminates the
+// walk instead of recursing forever.
+
+// treeDepthFunc is a skydb.TreeDepthFunc bound to the SQL table of the
+// record type, which is required to generate the correlated subquery.
+type treeDepthFunc struct {
+	skydb.TreeDepthFunc
+	table string
+}
+
+// ancestorTreeSQL generates a recursive CTE named "_tree" containing
+// the record matching the anchor condition and all of its ancestors.
+// The anchor condition is evaluated against the alias "_c".
+func ancestorTreeSQL(table string, parentColumn string, anchor string) string {
+	return fmt.Sprintf(`WITH RECURSIVE "_tree"("_id", "_parent", "_path") AS (`+
+		`SELECT "_c"."_id", %[2]s, ARRAY["_c"."_id"] FROM %[1]s AS "_c" WHERE %[3]s `+
+		`UNION ALL `+
+		`SELECT "_c"."_id", %[2]s, "_tree"."_path" || "_c"."_id" FROM %[1]s AS "_c" `+
+		`JOIN "_tree" ON "_c"."_id" = "_tree"."_parent" `+
+		`WHERE NOT "_c"."_id" = ANY("_tree"."_path"))`,
+		table, fullQuoteIdentifier("_c", parentColumn), anchor)
+}
+
+// treeDepthSQL generates a correlated subquery counting the ancestors
+// of the record in the table of the specified alias.
+func treeDepthSQL(alias string, f treeDepthFunc) string {
+	anchor := fmt.Sprintf(`"_c"."_id" = %s`, fullQuoteIdentifier(alias, "_id"))
+	return fmt.Sprintf(`(%s SELECT max(array_length("_path", 1)) - 1 FROM "_tree")`,
+		ancestorTreeSQL(f.table, f.ParentKeyPath, anchor))
+}
+
+type descendantPredicateSqlizer struct {
+	alias        string
+	table        string
+	parentColumn string
+	recordKey    string
+	maxDepth     int
+}
+
+func (p descendantPredicateSqlizer) ToSql() (sql string, args []interface{}, err error) {
+	// The path starts with the specified record so that it is not
+	// matched as its own descendant when it is part of a cycle.
+	depthCondition := ""
+	args = []interface{}{p.recordKey, p.recordKey}
+	if p.maxDepth > 0 {
+		depthCondition = ` AND array_length("_tree"."_path", 1) <= ?`
+		args = append(args, p.maxDepth)
+	}
+
+	parentColumn := fullQuoteIdentifier("_c", p.parentColumn)
+	sql = fmt.Sprintf(`%[1]s IN (WITH RECURSIVE "_tree"("_id", "_path") AS (`+
+		`SELECT "_c"."_id", ARRAY[?::text, "_c"."_id"] FROM %[2]s AS "_c" WHERE %[3]s = ? `+
+		`UNION ALL `+
+		`SELECT "_c"."_id", "_tree"."_path" || "_c"."_id" FROM %[2]s AS "_c" `+
+		`JOIN "_tree" ON %[3]s = "_tree"."_id" `+
+		`WHERE NOT "_c"."_id" = ANY("_tree"."_path")%[4]s) `+
+		`SELECT "_id" FROM "_tree")`,
+		fullQuoteIdentifier(p.alias, "_id"), p.table, parentColumn, depthCondition)
+	return
+}
+
+type ancestorPredicateSqlizer struct {
+	alias        string
+	table        string
+	parentColumn string
+	recordKey    string
+}
+
+func (p ancestorPredicateSqlizer) ToSql() (sql string, args []interface{}, err error) {
+	sql = fmt.Sprintf(`%s IN (%s SELECT "_id" FROM "_tree" WHERE array_length("_path", 1) > 1)`,
+		fullQuoteIdentifier(p.alias, "_id"),
+		ancestorTreeSQL(p.table, p.parentColumn, `"_c"."_id" = ?`))
+	args = []interface{}{p.recordKey}
+	return
+}
@@ -29,8 +29,8 @@ const upsertTemplateText = `
 WITH updated AS (
 	{{if .UpdateCols }}
 		UPDATE {{.Table}}
-		SET ({{template "commaSeparatedList" .UpdateCols}}) = ({{placeholderList (len .Keys) (len .UpdateCols) .WrappersAtIndex}})
-		WHERE {{range $i, $_ := .Keys}}{{if $i}} AND {{end}}{{quoted .}} = ${{addOne $i}}{{end}}
+		SET ({{template "commaSeparatedList" .UpdateCols}}) = ({{placeholderList (len .Keys) (len .UpdateCols) .UpdateWrappersAtIndex}}){{range .IncrementCols}}, {{quoted .}} = {{quoted .}} + 1{{end}}
+		WHERE {{range $i, $_ := .Keys}}{{if $i}} AND {{end}}{{quoted .}} = ${{addOne $i}}{{end}}{{range .Conditions}} AND {{.}}{{end}}
 		RETURNING *
 	{{else}}
 		SELECT {{template "commaSeparatedList" .Keys}}
@@ -41,7 +41,8 @@ WITH updated AS (
 	INSERT INTO {{.Table}}
 		({{template "commaSeparatedList" .InsertCols}})
 	SELECT {{placeholderList 0 (len .InsertCols) .WrappersAtIndex}}
-	WHERE NOT EXISTS (SELECT * FROM updated)
+	WHERE NOT EXISTS (SELECT * FROM updated){{if .Conditions}}
+		AND NOT EXISTS (SELECT * FROM {{.Table}} WHERE {{range $i, $_ := .Keys}}{{if $i}} AND {{end}}{{quoted .}} = ${{addOne $i}}{{end}}){{end}}
 	RETURNING *
 )
 SELECT {{ .SelectColumnsSQL }} FROM updated
@@ -127,6 +128,16 @@ type UpsertQueryBuilder struct {
 	updateIngnores map[string]struct{}
 	wrappers       map[string]func(string) string
 	selectColumns  map[string]sq.Sqlizer
+	increments     []string
+	conditions     []upsertCondition
+	updateWrappers map[string]func(string) string
+}
+
+// upsertCondition is a column which must equal the value for an existing
+// row to be updated.
+type upsertCondition struct {
+	column string
+	value  interface{}
 }
 
 // TODO(limouren): we can support a better fluent builder like this
@@ -149,6 +160,9 @@ func UpsertQuery(table string, pkData, data map[string]interface{}) *UpsertQuery
 		map[string]struct{}{},
 		map[string]func(string) string{},
 		map[string]sq.Sqlizer{},
+		nil,
+		nil,
+		map[string]func(string) string{},
 	}
 }
 
@@ -160,6 +174,9 @@ func UpsertQueryWithWrappers(table string, pkData, data map[string]interface{},
 		map[string]struct{}{},
 		wrappers,
 		map[string]sq.Sqlizer{},
+		nil,
+		nil,
+		map[string]func(string) string{},
 	}
 }
 
@@ -168,6 +185,30 @@ func (upsert *UpsertQueryBuilder) IgnoreKeyOnUpdate(col string) *UpsertQueryBuil
 	return upsert
 }
 
+// IncrementOnUpdate increments the column by one when an existing row is
+// updated. The column should also be ignored on update, so that the value
+// in data is only used when a new row is inserted.
+func (upsert *UpsertQueryBuilder) IncrementOnUpdate(col string) *UpsertQueryBuilder {
+	upsert.increments = append(upsert.increments, col)
+	return upsert
+}
+
+// UpdateCondition requires the column of an existing row to equal the
+// value for the row to be updated. If an existing row does not satisfy
+// the condition, it is neither updated nor inserted, and no rows are
+// returned.
+func (upsert *UpsertQueryBuilder) UpdateCondition(col string, value interface{}) *UpsertQueryBuilder {
+	upsert.conditions = append(upsert.conditions, upsertCondition{col, value})
+	return upsert
+}
+
+// UpdateWrapper wraps the placeholder of the column when an existing row
+// is updated, in place of the wrapper used when a new row is inserted.
+func (upsert *UpsertQueryBuilder) UpdateWrapper(col string, wrapper func(string) string) *UpsertQueryBuilder {
+	upsert.updateWrappers[col] = wrapper
+	return upsert
+}
+
 func (upsert *UpsertQueryBuilder) SelectColumn(col string, sqlizer sq.Sqlizer) *UpsertQueryBuilder {
 	upsert.selectColumns[col] = sqlizer
 	return upsert
@@ -185,33 +226,51 @@ func (upsert *UpsertQueryBuilder) ToSql() (sql string, args []interface{}, err e
 
 	insertCols := append(pks, cols...)
 	wrappers := map[int]func(string) string{}
+	updateWrappers := map[int]func(string) string{}
 
 	for i, col := range insertCols {
 		if wrapper, ok := upsert.wrappers[col]; ok {
 			wrappers[i+1] = wrapper
+			updateWrappers[i+1] = wrapper
+		}
+		if wrapper, ok := upsert.updateWrappers[col]; ok {
+			updateWrappers[i+1] = wrapper
 		}
 	}
 
+	args = append(pkArgs, args...)
+	conditions := make([]string, len(upsert.conditions))
+	for i, condition := range upsert.conditions {
+		args = append(args, condition.value)
+		conditions[i] = fmt.Sprintf("%s = $%d", pq.QuoteIdentifier(condition.column), len(args))
+	}
+
 	err = upsertTemplate.Execute(&b, struct {
-		Table            string
-		Keys             []string
-		UpdateCols       []string
-		InsertCols       []string
-		WrappersAtIndex  map[int]func(string) string
-		SelectColumnsSQL string
+		Table                 string
+		Keys                  []string
+		UpdateCols            []string
+		InsertCols            []string
+		WrappersAtIndex       map[int]func(string) string
+		UpdateWrappersAtIndex map[int]func(string) string
+		IncrementCols         []string
+		Conditions            []string
+		SelectColumnsSQL      string
 	}{
-		Table:            upsert.table,
-		Keys:             pks,
-		UpdateCols:       updateCols,
-		InsertCols:       insertCols,
-		WrappersAtIndex:  wrappers,
-		SelectColumnsSQL: upsertSelectClause(upsert.selectColumns),
+		Table:                 upsert.table,
+		Keys:                  pks,
+		UpdateCols:            updateCols,
+		InsertCols:            insertCols,
+		WrappersAtIndex:       wrappers,
+		UpdateWrappersAtIndex: updateWrappers,
+		IncrementCols:         upsert.increments,
+		Conditions:            conditions,
+		SelectColumnsSQL:      upsertSelectClause(upsert.selectColumns),
 	})
 	if err != nil {
 		panic(err)
 	}
 
-	return b.String(), append(pkArgs, args...), nil
+	return b.String(), args, nil
 }
 
 func extractKeyAndValue(data map[string]interface{}) (keys []string, values []interface{}) {
@@ -0,0 +1,198 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// SaveBulk inserts the records with COPY FROM, grouped by record type.
+//
+// Records of a type with geometry or sequence fields, or with history
+// enabled, are saved one by one instead, because COPY cannot convert the
+// geometries, fill the sequences or write the history. So are records
+// with patched fields, which COPY cannot apply.
+//
+// The records are inserted in a transaction, which is begun if the
+// connection is not in one.
+func (db *database) SaveBulk(records []*skydb.Record) (err error) {
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	recordTypes := []string{}
+	recordsByType := map[string][]*skydb.Record{}
+	for _, record := range records {
+		if record.ID.Key == "" {
+			return errors.New("db.saveBulk: got empty record id")
+		}
+		if record.ID.Type == "" {
+			return fmt.Errorf("db.saveBulk %s: got empty record type", record.ID.Key)
+		}
+		if record.OwnerID == "" {
+			return fmt.Errorf("db.saveBulk %s: got empty OwnerID", record.ID.Key)
+		}
+
+		recordType := record.ID.Type
+		if _, ok := recordsByType[recordType]; !ok {
+			recordTypes = append(recordTypes, recordType)
+		}
+		recordsByType[recordType] = append(recordsByType[recordType], record)
+	}
+
+	if db.c.tx == nil {
+		if err := db.c.Begin(); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				db.c.Rollback()
+			} else {
+				err = db.c.Commit()
+			}
+		}()
+	}
+
+	for _, recordType := range recordTypes {
+		if err := db.copyRecords(recordType, recordsByType[recordType]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRecords inserts the records of a record type with COPY FROM.
+func (db *database) copyRecords(recordType string, records []*skydb.Record) error {
+	typemap, err := db.RemoteColumnTypes(recordType)
+	if err != nil {
+		return err
+	}
+
+	if !canCopyRecords(recordType, typemap) || hasPatches(records) {
+		for _, record := range records {
+			if err := db.Save(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	rows := make([]map[string]interface{}, len(records))
+	columnSet := map[string]bool{}
+	for i, record := range records {
+		if err := db.preSave(typemap, record); err != nil {
+			return err
+		}
+
+		row := convert(record)
+		row["_id"] = record.ID.Key
+		row["_database_id"] = db.userID
+		row["_rev"] = 1
+		for column := range row {
+			columnSet[column] = true
+		}
+		rows[i] = row
+	}
+
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	stmt, err := db.c.tx.Prepare(pq.CopyInSchema(db.schemaName(), recordType, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	values := make([]interface{}, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			if values[i], err = copyValue(row[column]); err != nil {
+				return err
+			}
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			return copyError(recordType, err)
+		}
+	}
+
+	// COPY is completed by executing the statement without values
+	if _, err := stmt.Exec(); err != nil {
+		return copyError(recordType, err)
+	}
+
+	db.c.invalidateQueryCache(recordType)
+
+	for _, record := range records {
+		record.DatabaseID = db.userID
+	}
+	return nil
+}
+
+// canCopyRecords returns whether records of the record type can be
+// inserted with COPY FROM.
+func canCopyRecords(recordType string, typemap skydb.RecordSchema) bool {
+	if hasHistory(recordType) {
+		return false
+	}
+	for _, fieldType := range typemap {
+		switch fieldType.Type {
+		case skydb.TypeGeometry, skydb.TypeSequence:
+			return false
+		}
+	}
+	return true
+}
+
+// copyValue converts a value of a column to one accepted by COPY FROM.
+//
+// JSON values are converted to strings, otherwise they are encoded as
+// bytea.
+func copyValue(value interface{}) (interface{}, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return nil, err
+		}
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b), nil
+	}
+	return value, nil
+}
+
+func copyError(recordType string, err error) error {
+	if isUniqueViolated(err) {
+		return skyerr.NewError(skyerr.Duplicated, "violate unique constraint")
+	}
+	if isNotNullViolated(err) || isInvalidInputSyntax(err) {
+		return skyerr.NewErrorf(skyerr.InvalidArgument,
+			"failed to save records of %s: %s", recordType, err)
+	}
+	return skyerr.MakeError(err)
+}
@@ -0,0 +1,94 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestSaveBulk(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content":  skydb.FieldType{Type: skydb.TypeString},
+			"tags":     skydb.FieldType{Type: skydb.TypeJSON},
+			"location": skydb.FieldType{Type: skydb.TypeLocation},
+		})
+		So(err, ShouldBeNil)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		newNote := func(key string, data map[string]interface{}) *skydb.Record {
+			return &skydb.Record{
+				ID:        skydb.NewRecordID("note", key),
+				OwnerID:   "user",
+				CreatedAt: now,
+				CreatorID: "user",
+				UpdatedAt: now,
+				UpdaterID: "user",
+				Data:      data,
+			}
+		}
+
+		Convey("inserts records with COPY", func() {
+			err := db.SaveBulk([]*skydb.Record{
+				newNote("1", map[string]interface{}{
+					"content":  "hello",
+					"tags":     []interface{}{"a", "b"},
+					"location": skydb.NewLocation(1, 2),
+				}),
+				newNote("2", map[string]interface{}{
+					"content": "world",
+				}),
+			})
+			So(err, ShouldBeNil)
+
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &record), ShouldBeNil)
+			So(record.OwnerID, ShouldEqual, "user")
+			So(record.Revision, ShouldEqual, 1)
+			So(record.CreatedAt, ShouldResemble, now)
+			So(record.Data["content"], ShouldEqual, "hello")
+			So(record.Data["tags"], ShouldResemble, []interface{}{"a", "b"})
+			So(record.Data["location"], ShouldResemble, skydb.NewLocation(1, 2))
+
+			So(db.Get(skydb.NewRecordID("note", "2"), &record), ShouldBeNil)
+			So(record.Data["content"], ShouldEqual, "world")
+			So(record.Data["tags"], ShouldBeNil)
+		})
+
+		Convey("inserts none of the records if any exists", func() {
+			So(db.Save(newNote("2", map[string]interface{}{})), ShouldBeNil)
+
+			err := db.SaveBulk([]*skydb.Record{
+				newNote("1", map[string]interface{}{}),
+				newNote("2", map[string]interface{}{}),
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.Duplicated)
+
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &record), ShouldEqual, skydb.ErrRecordNotFound)
+		})
+	})
+}
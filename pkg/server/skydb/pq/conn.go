@@ -74,8 +74,11 @@ type ExtContext interface {
 }
 
 type conn struct {
-	db             *sqlx.DB // database wrapper
-	tx             *sqlx.Tx // transaction wrapper, nil when no transaction
+	db             *sqlx.DB   // database wrapper
+	tx             *sqlx.Tx   // transaction wrapper, nil when no transaction
+	stmtCache      *stmtCache // prepared statements, nil when disabled
+	replicas       []*replica // read replicas, empty when there is none
+	wrote          bool       // whether a statement has written to the primary
 	RecordSchema   map[string]skydb.RecordSchema
 	FieldACL       *skydb.FieldACL
 	appName        string
@@ -84,6 +87,14 @@ type conn struct {
 	accessModel    skydb.AccessModel
 	canMigrate     bool
 	context        context.Context
+
+	// staleRecordTypes are the record types changed in the current
+	// transaction, of which the query cache is invalidated on commit.
+	staleRecordTypes map[string]bool
+
+	// pendingSchemaChanges are the schema changes made in the current
+	// transaction, of which the listener is notified on commit.
+	pendingSchemaChanges []skydb.SchemaChange
 }
 
 // Db returns the current database wrapper, or a transaction wrapper when
@@ -102,7 +113,15 @@ func (c *conn) Begin() error {
 		return skydb.ErrDatabaseTxDidBegin
 	}
 
-	tx, err := c.db.Beginx()
+	// No statement is executed before the transaction begins, so beginning
+	// is retried on transient errors as reads are.
+	tx, err := c.db.BeginTxx(c.context, nil)
+	for attempt := 0; attempt < retryPolicy.MaxRetries && isTransientError(err); attempt++ {
+		if !sleepContext(c.context, retryPolicy.backoff(attempt)) {
+			break
+		}
+		tx, err = c.db.BeginTxx(c.context, nil)
+	}
 	if err != nil {
 		log.Debugf("%p: Unable to begin transaction %p: %v", c, err)
 		return err
@@ -123,6 +142,8 @@ func (c *conn) Commit() error {
 		return err
 	}
 	c.tx = nil
+	c.commitQueryCache()
+	c.commitSchemaChanges()
 	log.Debugf("%p: Committed transaction", c)
 	return nil
 }
@@ -138,6 +159,8 @@ func (c *conn) Rollback() error {
 		return err
 	}
 	c.tx = nil
+	c.staleRecordTypes = nil
+	c.pendingSchemaChanges = nil
 	log.Debugf("%p: Rolled back transaction", c)
 	return nil
 }
@@ -15,21 +15,66 @@
 package pq
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 
 	"github.com/sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
 	sq "github.com/lann/squirrel"
 )
 
-func (c *conn) Get(dest interface{}, query string, args ...interface{}) (err error) {
+// withStmt executes the query on the target with a cached prepared
+// statement when prepare is true and the statement cache of the target is
+// enabled. The query is executed without preparing when the statement
+// cannot be used.
+func (c *conn) withStmt(ctx context.Context, t target, query string, prepare bool, prepared func(*sqlx.Stmt) error, unprepared func() error) error {
+	if !t.replica && !isReadStatement(query) {
+		c.wrote = true
+	}
+	if !prepare || t.stmtCache == nil {
+		return unprepared()
+	}
+
+	stmt, err := t.stmtCache.Get(ctx, query)
+	if err != nil {
+		log.Debugf("conn: unable to prepare statement: %s", err)
+		return unprepared()
+	}
+	if c.tx != nil && !t.replica {
+		stmt = c.tx.StmtxContext(ctx, stmt)
+	}
+
+	err = prepared(stmt)
+	if isStaleStatement(err) {
+		t.stmtCache.Remove(query)
+		return unprepared()
+	}
+	return err
+}
+
+func (c *conn) Get(dest interface{}, query string, args ...interface{}) error {
+	return c.get(c.primary(), dest, query, false, args...)
+}
+
+func (c *conn) get(t target, dest interface{}, query string, prepare bool, args ...interface{}) (err error) {
 	c.statementCount++
-	err = c.Db().GetContext(c.context, dest, query, args...)
+	watch := c.watch(query)
+	err = c.retry(watch.ctx, t, query, func(t target) error {
+		return c.withStmt(watch.ctx, t, query, prepare, func(stmt *sqlx.Stmt) error {
+			return stmt.GetContext(watch.ctx, dest, args...)
+		}, func() error {
+			return t.db.GetContext(watch.ctx, dest, query, args...)
+		})
+	})
+	watch.Release()
 	logFields := logrus.Fields{
 		"sql":            query,
 		"args":           args,
 		"error":          err,
 		"executionCount": c.statementCount,
+		"replica":        t.replica,
 	}
 	if err != nil {
 		log.WithFields(logFields).Errorln("Failed to execute SQL with sql.Get")
@@ -44,12 +89,15 @@ func (c *conn) GetWith(dest interface{}, sqlizeri sq.Sqlizer) (err error) {
 	if err != nil {
 		panic(err)
 	}
-	return c.Get(dest, sql, args...)
+	return c.get(c.primary(), dest, sql, true, args...)
 }
 
 func (c *conn) Exec(query string, args ...interface{}) (result sql.Result, err error) {
 	c.statementCount++
-	result, err = c.Db().ExecContext(c.context, query, args...)
+	c.wrote = true
+	watch := c.watch(query)
+	result, err = c.Db().ExecContext(watch.ctx, query, args...)
+	watch.Release()
 
 	var rowsAffected int64
 	if result != nil {
@@ -85,14 +133,31 @@ func (c *conn) ExecWith(sqlizeri sq.Sqlizer) (sql.Result, error) {
 	return c.Exec(sql, args...)
 }
 
-func (c *conn) Queryx(query string, args ...interface{}) (rows *sqlx.Rows, err error) {
+func (c *conn) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
+	return c.queryx(c.primary(), query, false, args...)
+}
+
+func (c *conn) queryx(t target, query string, prepare bool, args ...interface{}) (rows *sqlx.Rows, err error) {
 	c.statementCount++
-	rows, err = c.Db().QueryxContext(c.context, query, args...)
+	// The rows are read after the query returns, so the context is kept
+	// until the ceiling of the watchdog.
+	watch := c.watch(query)
+	err = c.retry(watch.ctx, t, query, func(t target) error {
+		return c.withStmt(watch.ctx, t, query, prepare, func(stmt *sqlx.Stmt) (err error) {
+			rows, err = stmt.QueryxContext(watch.ctx, args...)
+			return
+		}, func() (err error) {
+			rows, err = t.db.QueryxContext(watch.ctx, query, args...)
+			return
+		})
+	})
+	watch.Finish()
 	logFields := logrus.Fields{
 		"sql":            query,
 		"args":           args,
 		"error":          err,
 		"executionCount": c.statementCount,
+		"replica":        t.replica,
 	}
 	if err != nil {
 		log.WithFields(logFields).Errorln("Failed to execute SQL with sql.Queryx")
@@ -107,16 +172,40 @@ func (c *conn) QueryWith(sqlizeri sq.Sqlizer) (*sqlx.Rows, error) {
 	if err != nil {
 		panic(err)
 	}
-	return c.Queryx(sql, args...)
+	return c.queryx(c.primary(), sql, true, args...)
+}
+
+// readQueryWith is QueryWith reading records from a replica if possible.
+func (c *conn) readQueryWith(sqlizeri sq.Sqlizer) (*sqlx.Rows, error) {
+	sql, args, err := sqlizeri.ToSql()
+	if err != nil {
+		panic(err)
+	}
+	return c.queryx(c.reader(), sql, true, args...)
+}
+
+func (c *conn) QueryRowx(query string, args ...interface{}) *sqlx.Row {
+	return c.queryRowx(c.primary(), query, false, args...)
 }
 
-func (c *conn) QueryRowx(query string, args ...interface{}) (row *sqlx.Row) {
+func (c *conn) queryRowx(t target, query string, prepare bool, args ...interface{}) (row *sqlx.Row) {
 	c.statementCount++
-	row = c.Db().QueryRowxContext(c.context, query, args...)
+	watch := c.watch(query)
+	c.retry(watch.ctx, t, query, func(t target) error {
+		return c.withStmt(watch.ctx, t, query, prepare, func(stmt *sqlx.Stmt) error {
+			row = stmt.QueryRowxContext(watch.ctx, args...)
+			return row.Err()
+		}, func() error {
+			row = t.db.QueryRowxContext(watch.ctx, query, args...)
+			return row.Err()
+		})
+	})
+	watch.Finish()
 	log.WithFields(logrus.Fields{
 		"sql":            query,
 		"args":           args,
 		"executionCount": c.statementCount,
+		"replica":        t.replica,
 	}).Debugln("Executed SQL with sql.QueryRowx")
 	return
 }
@@ -126,5 +215,63 @@ func (c *conn) QueryRowWith(sqlizeri sq.Sqlizer) *sqlx.Row {
 	if err != nil {
 		panic(err)
 	}
-	return c.QueryRowx(sql, args...)
+	return c.queryRowx(c.primary(), sql, true, args...)
+}
+
+// readQueryRowWith is QueryRowWith reading records from a replica if
+// possible.
+func (c *conn) readQueryRowWith(sqlizeri sq.Sqlizer) *sqlx.Row {
+	sql, args, err := sqlizeri.ToSql()
+	if err != nil {
+		panic(err)
+	}
+	return c.queryRowx(c.reader(), sql, true, args...)
+}
+
+// ExplainWith analyzes the execution of the statement and returns the
+// plan in JSON format. The statement is executed, so it should only be
+// used on statements without side effect.
+func (c *conn) ExplainWith(sqlizeri sq.Sqlizer) (interface{}, error) {
+	sql, args, err := sqlizeri.ToSql()
+	if err != nil {
+		panic(err)
+	}
+
+	var plan []byte
+	if err := c.QueryRowx("EXPLAIN (ANALYZE, FORMAT JSON) "+sql, args...).Scan(&plan); err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(plan, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EstimateRowsWith returns the number of rows returned by the statement as
+// estimated by the query planner. The statement is not executed.
+func (c *conn) EstimateRowsWith(sqlizeri sq.Sqlizer) (uint64, error) {
+	sql, args, err := sqlizeri.ToSql()
+	if err != nil {
+		panic(err)
+	}
+
+	var plan []byte
+	if err := c.QueryRowx("EXPLAIN (FORMAT JSON) "+sql, args...).Scan(&plan); err != nil {
+		return 0, err
+	}
+
+	var result []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, fmt.Errorf("unexpected query plan: %s", plan)
+	}
+	return uint64(result[0].Plan.Rows), nil
 }
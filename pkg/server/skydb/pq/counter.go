@@ -0,0 +1,52 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"math/rand"
+)
+
+// counterShardCount is the number of rows a counter is spread over.
+//
+// An increment only locks one randomly chosen shard row, so concurrent
+// increments of a hot counter rarely wait for each other. Reading
+// a counter sums up all of its shard rows.
+const counterShardCount = 16
+
+func (c *conn) IncrementCounter(name string, delta int64) (int64, error) {
+	log.Debugf("Increment Counter: %v, %v", name, delta)
+	shard := rand.Intn(counterShardCount)
+	builder := psql.Insert(c.tableName("_counter")+" AS c").
+		Columns("name", "shard", "value").
+		Values(name, shard, delta).
+		Suffix("ON CONFLICT (name, shard) DO UPDATE SET value = c.value + EXCLUDED.value")
+	if _, err := c.ExecWith(builder); err != nil {
+		return 0, err
+	}
+
+	return c.GetCounter(name)
+}
+
+func (c *conn) GetCounter(name string) (int64, error) {
+	builder := psql.Select("COALESCE(SUM(value), 0)::bigint").
+		From(c.tableName("_counter")).
+		Where("name = ?", name)
+
+	var value int64
+	if err := c.GetWith(&value, builder); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCounter(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		Convey("get counter never incremented", func() {
+			value, err := c.GetCounter("likes")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 0)
+		})
+
+		Convey("increment counter", func() {
+			value, err := c.IncrementCounter("likes", 1)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 1)
+
+			value, err = c.IncrementCounter("likes", 2)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 3)
+
+			value, err = c.GetCounter("likes")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 3)
+		})
+
+		Convey("decrement counter", func() {
+			_, err := c.IncrementCounter("likes", 5)
+			So(err, ShouldBeNil)
+
+			value, err := c.IncrementCounter("likes", -2)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 3)
+		})
+
+		Convey("sum all shards of a counter", func() {
+			for i := 0; i < counterShardCount*4; i++ {
+				_, err := c.IncrementCounter("likes", 1)
+				So(err, ShouldBeNil)
+			}
+
+			var shards int
+			err := c.Get(&shards, "SELECT COUNT(*) FROM _counter WHERE name = 'likes'")
+			So(err, ShouldBeNil)
+			So(shards, ShouldBeGreaterThan, 1)
+			So(shards, ShouldBeLessThanOrEqualTo, counterShardCount)
+
+			value, err := c.GetCounter("likes")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, counterShardCount*4)
+		})
+
+		Convey("counters are independent", func() {
+			_, err := c.IncrementCounter("likes", 1)
+			So(err, ShouldBeNil)
+
+			value, err := c.GetCounter("shares")
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CheckConnection opens a new connection to the database and verifies
+// that the database server responds.
+func CheckConnection(connString string) error {
+	db, err := sqlx.Open("postgres", connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Ping()
+}
+
+// CheckPrivileges verifies that the current role is able to create and use
+// objects in the schema of the specified app. If the schema does not
+// exist yet, the role must be able to create it.
+func CheckPrivileges(connString string, appName string) error {
+	db, err := sqlx.Open("postgres", connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	schema := "app_" + toLowerAndUnderscore(appName)
+
+	var exists bool
+	err = db.QueryRowx(`SELECT EXISTS(SELECT 1 FROM pg_namespace WHERE nspname = $1)`, schema).
+		Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	var granted bool
+	if exists {
+		err = db.QueryRowx(`SELECT has_schema_privilege($1, 'USAGE') AND has_schema_privilege($1, 'CREATE')`, schema).
+			Scan(&granted)
+		if err != nil {
+			return err
+		}
+		if !granted {
+			return fmt.Errorf("current role cannot use or create objects in schema %s", schema)
+		}
+		return nil
+	}
+
+	err = db.QueryRowx(`SELECT has_database_privilege(current_database(), 'CREATE')`).
+		Scan(&granted)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return fmt.Errorf("schema %s does not exist and current role cannot create it", schema)
+	}
+	return nil
+}
+
+// CheckExtensions verifies that each of the specified extensions is
+// either installed, or available to be installed by a migration.
+func CheckExtensions(connString string, extensions []string) error {
+	db, err := sqlx.Open("postgres", connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	missing := []string{}
+	for _, extension := range extensions {
+		var available bool
+		err := db.QueryRowx(`SELECT EXISTS(SELECT 1 FROM pg_available_extensions WHERE name = $1)`, extension).
+			Scan(&available)
+		if err != nil {
+			return err
+		}
+		if !available {
+			missing = append(missing, extension)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("extensions not available on the database server: %s",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	sq "github.com/lann/squirrel"
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func (db *database) FindDuplicates(recordType string, keys []skydb.DuplicateKey, limit int) ([][]skydb.RecordID, error) {
+	if len(keys) == 0 {
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"at least one key is required to find duplicates")
+	}
+
+	typemap, err := db.RemoteColumnTypes(recordType)
+	if err != nil {
+		return nil, err
+	}
+	if len(typemap) == 0 { // record type has not been created
+		return [][]skydb.RecordID{}, nil
+	}
+
+	table := db.TableName(recordType)
+	q := psql.Select(`"a"."_id"`, `"b"."_id"`).
+		From(fmt.Sprintf(`%s AS "a", %s AS "b"`, table, table)).
+		Where(`"a"."_id" < "b"."_id"`)
+
+	for _, key := range keys {
+		fieldType, ok := typemap[key.Field]
+		if !ok {
+			return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+				"field %s does not exist in record type %s", key.Field, recordType)
+		}
+
+		column := pq.QuoteIdentifier(key.Field)
+		a := `"a".` + column
+		b := `"b".` + column
+		if key.IsFuzzy() {
+			if fieldType.Type != skydb.TypeString {
+				return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+					"field %s must be a string to compare by similarity", key.Field)
+			}
+			q = q.Where(fmt.Sprintf("similarity(%s, %s) >= ?", a, b), key.Similarity)
+		} else {
+			q = q.Where(fmt.Sprintf("%s = %s", a, b))
+		}
+	}
+
+	if db.DatabaseType() != skydb.UnionDatabase {
+		q = q.Where(`"a"."_database_id" = ? AND "b"."_database_id" = ?`, db.userID, db.userID)
+	}
+
+	q = q.OrderBy(`"a"."_id"`, `"b"."_id"`)
+	if limit > 0 {
+		q = q.Limit(uint64(limit))
+	}
+
+	rows, err := db.c.QueryWith(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := newDuplicateGroups()
+	for rows.Next() {
+		var a, b string
+		if err := rows.Scan(&a, &b); err != nil {
+			return nil, err
+		}
+		groups.union(a, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groups.recordIDs(recordType), nil
+}
+
+// duplicateGroups is a disjoint set of record IDs. Record IDs in the same
+// set are candidate duplicates of each other.
+type duplicateGroups struct {
+	parent map[string]string
+}
+
+func newDuplicateGroups() *duplicateGroups {
+	return &duplicateGroups{map[string]string{}}
+}
+
+func (g *duplicateGroups) find(id string) string {
+	parent, ok := g.parent[id]
+	if !ok {
+		g.parent[id] = id
+		return id
+	}
+	if parent == id {
+		return id
+	}
+
+	root := g.find(parent)
+	g.parent[id] = root
+	return root
+}
+
+func (g *duplicateGroups) union(a, b string) {
+	rootA, rootB := g.find(a), g.find(b)
+	if rootA < rootB {
+		g.parent[rootB] = rootA
+	} else if rootB < rootA {
+		g.parent[rootA] = rootB
+	}
+}
+
+// recordIDs returns the groups with the record IDs sorted in each group,
+// and the groups sorted by their first record ID.
+func (g *duplicateGroups) recordIDs(recordType string) [][]skydb.RecordID {
+	members := map[string][]string{}
+	for id := range g.parent {
+		root := g.find(id)
+		members[root] = append(members[root], id)
+	}
+
+	roots := []string{}
+	for root := range members {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	result := [][]skydb.RecordID{}
+	for _, root := range roots {
+		ids := members[root]
+		sort.Strings(ids)
+
+		group := make([]skydb.RecordID, len(ids))
+		for i, id := range ids {
+			group[i] = skydb.NewRecordID(recordType, id)
+		}
+		result = append(result, group)
+	}
+	return result
+}
+
+// MergeRecords should be called in a transaction so that the merge does
+// not take effect partially.
+func (db *database) MergeRecords(winnerID skydb.RecordID, loserIDs []skydb.RecordID) error {
+	if db.IsReadOnly() {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	winner := skydb.Record{}
+	if err := db.Get(winnerID, &winner); err != nil {
+		return err
+	}
+
+	losers := make([]skydb.Record, len(loserIDs))
+	loserKeys := make([]interface{}, len(loserIDs))
+	for i, loserID := range loserIDs {
+		if loserID.Type != winnerID.Type {
+			return skyerr.NewInvalidArgument(
+				fmt.Sprintf("cannot merge %s into %s of a different type", loserID, winnerID),
+				[]string{"losers"})
+		}
+		if loserID.Key == winnerID.Key {
+			return skyerr.NewInvalidArgument(
+				fmt.Sprintf("cannot merge %s into itself", loserID),
+				[]string{"losers"})
+		}
+
+		if err := db.Get(loserID, &losers[i]); err != nil {
+			return err
+		}
+		loserKeys[i] = loserID.Key
+	}
+
+	now := time.Now().UTC()
+	for _, loser := range losers {
+		winner.ACL = winner.ACL.Merge(loser.ACL)
+	}
+	winner.UpdatedAt = now
+	if err := db.Save(&winner); err != nil {
+		return err
+	}
+
+	if err := db.rewriteReferences(winnerID, loserKeys); err != nil {
+		return err
+	}
+
+	for _, loser := range losers {
+		if err := db.archiveRecord(&loser, winnerID.Key, now); err != nil {
+			return err
+		}
+		if err := db.Delete(loser.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteReferences updates the reference fields of all record types
+// referencing a record in loserKeys to reference the winner instead.
+func (db *database) rewriteReferences(winnerID skydb.RecordID, loserKeys []interface{}) error {
+	schemas, err := db.GetRecordSchemas()
+	if err != nil {
+		return err
+	}
+
+	placeholders := sq.Placeholders(len(loserKeys))
+	for recordType, schema := range schemas {
+		for field, fieldType := range schema {
+			if fieldType.Type != skydb.TypeReference || fieldType.ReferenceType != winnerID.Type {
+				continue
+			}
+
+			column := pq.QuoteIdentifier(field)
+			builder := psql.Update(db.TableName(recordType)).
+				Set(field, winnerID.Key).
+				Where(fmt.Sprintf("%s IN (%s)", column, placeholders), loserKeys...)
+			if _, err := db.c.ExecWith(builder); err != nil {
+				return err
+			}
+			db.c.invalidateQueryCache(recordType)
+		}
+	}
+	return nil
+}
+
+func (db *database) archiveRecord(record *skydb.Record, mergedInto string, archivedAt time.Time) error {
+	data, err := json.Marshal((*skyconv.JSONRecord)(record))
+	if err != nil {
+		return err
+	}
+
+	builder := psql.Insert(db.TableName("_record_archive")).
+		Columns("record_type", "record_id", "database_id", "owner_id",
+			"data", "merged_into", "archived_at").
+		Values(record.ID.Type, record.ID.Key, db.userID, record.OwnerID,
+			string(data), mergedInto, archivedAt)
+
+	_, err = db.c.ExecWith(builder)
+	return err
+}
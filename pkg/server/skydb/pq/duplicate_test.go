@@ -0,0 +1,174 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("contact", skydb.RecordSchema{
+			"name":  skydb.FieldType{Type: skydb.TypeString},
+			"email": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		saveContact := func(id string, name string, email string) {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("contact", id),
+				OwnerID: "user",
+				Data: map[string]interface{}{
+					"name":  name,
+					"email": email,
+				},
+			}), ShouldBeNil)
+		}
+
+		saveContact("1", "John Doe", "john@example.com")
+		saveContact("2", "Johnny Doe", "john@example.com")
+		saveContact("3", "John Doe", "johndoe@example.com")
+		saveContact("4", "Jane Roe", "jane@example.com")
+
+		Convey("finds duplicates by exact key", func() {
+			groups, err := db.FindDuplicates("contact", []skydb.DuplicateKey{
+				{Field: "email"},
+			}, 0)
+			So(err, ShouldBeNil)
+			So(groups, ShouldResemble, [][]skydb.RecordID{
+				{skydb.NewRecordID("contact", "1"), skydb.NewRecordID("contact", "2")},
+			})
+		})
+
+		Convey("groups duplicates transitively by fuzzy key", func() {
+			groups, err := db.FindDuplicates("contact", []skydb.DuplicateKey{
+				{Field: "name", Similarity: 0.5},
+			}, 0)
+			So(err, ShouldBeNil)
+			So(groups, ShouldResemble, [][]skydb.RecordID{
+				{
+					skydb.NewRecordID("contact", "1"),
+					skydb.NewRecordID("contact", "2"),
+					skydb.NewRecordID("contact", "3"),
+				},
+			})
+		})
+
+		Convey("returns error for unknown field", func() {
+			_, err := db.FindDuplicates("contact", []skydb.DuplicateKey{
+				{Field: "phone"},
+			}, 0)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("returns no duplicates for record type not yet created", func() {
+			groups, err := db.FindDuplicates("unknown", []skydb.DuplicateKey{
+				{Field: "name"},
+			}, 0)
+			So(err, ShouldBeNil)
+			So(groups, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestMergeRecords(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("contact", skydb.RecordSchema{
+			"name": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+		_, err = db.Extend("note", skydb.RecordSchema{
+			"contact": skydb.FieldType{
+				Type:          skydb.TypeReference,
+				ReferenceType: "contact",
+			},
+		})
+		So(err, ShouldBeNil)
+
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("contact", "winner"),
+			OwnerID: "user",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("user", skydb.WriteLevel),
+			},
+			Data: map[string]interface{}{"name": "John Doe"},
+		}), ShouldBeNil)
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("contact", "loser"),
+			OwnerID: "user",
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryRole("admin", skydb.WriteLevel),
+			},
+			Data: map[string]interface{}{"name": "Johnny Doe"},
+		}), ShouldBeNil)
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("note", "1"),
+			OwnerID: "user",
+			Data: map[string]interface{}{
+				"contact": skydb.NewReference("contact", "loser"),
+			},
+		}), ShouldBeNil)
+
+		Convey("merges loser into winner", func() {
+			err := db.MergeRecords(
+				skydb.NewRecordID("contact", "winner"),
+				[]skydb.RecordID{skydb.NewRecordID("contact", "loser")},
+			)
+			So(err, ShouldBeNil)
+
+			winner := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("contact", "winner"), &winner), ShouldBeNil)
+			So(winner.ACL, ShouldResemble, skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("user", skydb.WriteLevel),
+				skydb.NewRecordACLEntryRole("admin", skydb.WriteLevel),
+			})
+
+			note := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &note), ShouldBeNil)
+			So(note.Data["contact"], ShouldResemble, skydb.NewReference("contact", "winner"))
+
+			loser := skydb.Record{}
+			err = db.Get(skydb.NewRecordID("contact", "loser"), &loser)
+			So(err, ShouldEqual, skydb.ErrRecordNotFound)
+
+			var mergedInto string
+			err = c.QueryRowx(`SELECT merged_into FROM _record_archive
+				WHERE record_type = 'contact' AND record_id = 'loser'`).
+				Scan(&mergedInto)
+			So(err, ShouldBeNil)
+			So(mergedInto, ShouldEqual, "winner")
+		})
+
+		Convey("rejects merging record into itself", func() {
+			err := db.MergeRecords(
+				skydb.NewRecordID("contact", "winner"),
+				[]skydb.RecordID{skydb.NewRecordID("contact", "winner")},
+			)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
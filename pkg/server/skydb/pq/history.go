@@ -0,0 +1,340 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sq "github.com/lann/squirrel"
+	"github.com/lib/pq"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// historyRecordTypes is the set of record types whose changes are
+// written to the _history table.
+var historyRecordTypes = map[string]bool{}
+
+// SetHistoryRecordTypes sets the record types whose saves and deletes
+// are written to the change history. It should be called before opening
+// any connection.
+func SetHistoryRecordTypes(recordTypes []string) {
+	historyRecordTypes = map[string]bool{}
+	for _, recordType := range recordTypes {
+		historyRecordTypes[recordType] = true
+	}
+}
+
+func hasHistory(recordType string) bool {
+	return historyRecordTypes[recordType]
+}
+
+// historyBase returns the record before it is changed, or nil if the
+// record does not exist.
+func (db *database) historyBase(id skydb.RecordID) (*skydb.Record, error) {
+	record := skydb.Record{}
+	if err := db.Get(id, &record); err == skydb.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (db *database) writeHistory(id skydb.RecordID, action skydb.RecordHistoryAction, old *skydb.Record, actorID string) error {
+	var data interface{}
+	if old != nil {
+		bytes, err := json.Marshal((*skyconv.JSONRecord)(old))
+		if err != nil {
+			return err
+		}
+		data = string(bytes)
+	}
+
+	if contextActorID := skydb.ActorFromContext(db.c.context); contextActorID != "" {
+		actorID = contextActorID
+	}
+	var actor interface{}
+	if actorID != "" {
+		actor = actorID
+	}
+
+	builder := psql.Insert(db.TableName("_history")).
+		Columns("record_type", "record_id", "database_id", "action",
+			"data", "actor", "created_at").
+		Values(id.Type, id.Key, db.userID, string(action),
+			data, actor, time.Now().UTC())
+
+	_, err := db.c.ExecWith(builder)
+	return err
+}
+
+func (db *database) GetRecordHistory(id skydb.RecordID, limit int) ([]skydb.RecordHistory, error) {
+	builder := psql.Select("id", "action", "data", "actor", "created_at").
+		From(db.TableName("_history")).
+		Where("record_type = ? AND record_id = ?", id.Type, id.Key).
+		OrderBy("id DESC")
+	if db.DatabaseType() != skydb.UnionDatabase {
+		builder = builder.Where("database_id = ?", db.userID)
+	}
+	if limit > 0 {
+		builder = builder.Limit(uint64(limit))
+	}
+
+	rows, err := db.c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []skydb.RecordHistory{}
+	for rows.Next() {
+		var (
+			entry skydb.RecordHistory
+			data  []byte
+			actor sql.NullString
+		)
+		if err := rows.Scan(&entry.ID, &entry.Action, &data, &actor, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		if data != nil {
+			record, err := unmarshalHistoryRecord(data)
+			if err != nil {
+				return nil, err
+			}
+			entry.Record = record
+		}
+		entry.RecordID = id
+		entry.ActorID = actor.String
+		entry.CreatedAt = entry.CreatedAt.UTC()
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// historyRecordMeta is the record metadata which is not restored by
+// unmarshalling a skyconv.JSONRecord.
+type historyRecordMeta struct {
+	OwnerID   string     `json:"_ownerID"`
+	CreatedAt time.Time  `json:"_created_at"`
+	CreatorID string     `json:"_created_by"`
+	UpdatedAt time.Time  `json:"_updated_at"`
+	UpdaterID string     `json:"_updated_by"`
+	DeletedAt *time.Time `json:"_deleted_at"`
+}
+
+func unmarshalHistoryRecord(data []byte) (*skydb.Record, error) {
+	record := skydb.Record{}
+	if err := json.Unmarshal(data, (*skyconv.JSONRecord)(&record)); err != nil {
+		return nil, err
+	}
+
+	meta := historyRecordMeta{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	record.OwnerID = meta.OwnerID
+	record.CreatedAt = meta.CreatedAt
+	record.CreatorID = meta.CreatorID
+	record.UpdatedAt = meta.UpdatedAt
+	record.UpdaterID = meta.UpdaterID
+	record.DeletedAt = meta.DeletedAt
+	return &record, nil
+}
+
+// applyQueryAsOf makes the query select the records as they were at the
+// time. The records are selected from a common table expression named
+// after the record type, which shadows the record table in the query.
+//
+// A record is as it is now if it has not been changed since the time.
+// Otherwise, the history entry of its first change after the time holds
+// the record before the change, which is how it was at the time. The
+// record did not exist if the entry has no record.
+func (db *database) applyQueryAsOf(q sq.SelectBuilder, recordType string, typemap skydb.RecordSchema, asOf time.Time) (sq.SelectBuilder, error) {
+	if !hasHistory(recordType) {
+		return q, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			"record type %s has no history to query as of a time", recordType)
+	}
+
+	snapshots, err := db.historySnapshots(recordType, typemap, asOf)
+	if err != nil {
+		return q, err
+	}
+	snapshotsJSON, err := json.Marshal(snapshots)
+	if err != nil {
+		return q, err
+	}
+
+	columns := []string{}
+	for column, fieldType := range typemap {
+		if fieldType.Expression.IsEmpty() {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+
+	currentColumns := make([]string, len(columns))
+	snapshotColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quoted := pq.QuoteIdentifier(column)
+		currentColumns[i] = "r." + quoted
+		if typemap[column].Type == skydb.TypeGeometry {
+			snapshotColumns[i] = fmt.Sprintf("ST_GeomFromGeoJSON(s.value->'geometry'->>'%s') AS %s",
+				strings.Replace(column, "'", "''", -1), quoted)
+		} else {
+			snapshotColumns[i] = "r." + quoted
+		}
+	}
+
+	asOfSQL := fmt.Sprintf(`WITH %[1]s AS (
+	SELECT %[2]s FROM %[3]s AS r
+	WHERE NOT EXISTS (
+		SELECT 1 FROM %[4]s AS h
+		WHERE h.record_type = ? AND h.record_id = r."_id"
+			AND h.database_id = r."_database_id" AND h.created_at > ?
+	)
+	UNION ALL
+	SELECT %[5]s FROM jsonb_array_elements(?::jsonb) AS s,
+		jsonb_populate_record(NULL::%[3]s, s.value->'row') AS r
+)`,
+		pq.QuoteIdentifier(recordType),
+		strings.Join(currentColumns, ", "),
+		db.TableName(recordType),
+		db.TableName("_history"),
+		strings.Join(snapshotColumns, ", "),
+	)
+
+	q = q.Prefix(asOfSQL, recordType, asOf.UTC(), string(snapshotsJSON)).
+		From(pq.QuoteIdentifier(recordType))
+	return q, nil
+}
+
+// historySnapshot is a record as it was at a time, encoded for
+// jsonb_populate_record. Geometry values are kept apart because
+// they are parsed from GeoJSON.
+type historySnapshot struct {
+	Row      map[string]interface{} `json:"row"`
+	Geometry map[string]interface{} `json:"geometry"`
+}
+
+// historySnapshots returns the records which have been changed since
+// the time, as they were at the time.
+func (db *database) historySnapshots(recordType string, typemap skydb.RecordSchema, asOf time.Time) ([]historySnapshot, error) {
+	builder := psql.Select("DISTINCT ON (record_id, database_id) record_id", "database_id", "data").
+		From(db.TableName("_history")).
+		Where("record_type = ? AND created_at > ?", recordType, asOf.UTC()).
+		OrderBy("record_id", "database_id", "id")
+
+	rows, err := db.c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []historySnapshot{}
+	for rows.Next() {
+		var (
+			recordID, databaseID string
+			data                 []byte
+		)
+		if err := rows.Scan(&recordID, &databaseID, &data); err != nil {
+			return nil, err
+		}
+		if data == nil {
+			// the record was created after the time
+			continue
+		}
+
+		record, err := unmarshalHistoryRecord(data)
+		if err != nil {
+			return nil, err
+		}
+		record.ID = skydb.NewRecordID(recordType, recordID)
+		record.DatabaseID = databaseID
+
+		snapshot, err := newHistorySnapshot(record, typemap)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+func newHistorySnapshot(record *skydb.Record, typemap skydb.RecordSchema) (historySnapshot, error) {
+	snapshot := historySnapshot{
+		Row:      map[string]interface{}{},
+		Geometry: map[string]interface{}{},
+	}
+
+	values := convert(record)
+	values["_id"] = record.ID.Key
+	values["_database_id"] = record.DatabaseID
+	if record.Revision != 0 {
+		values["_rev"] = record.Revision
+	}
+
+	for column, value := range values {
+		fieldType, ok := typemap[column]
+		if !ok {
+			// the field has been deleted since the time
+			continue
+		}
+
+		if valuer, ok := value.(driver.Valuer); ok {
+			var err error
+			if value, err = valuer.Value(); err != nil {
+				return snapshot, err
+			}
+		}
+
+		switch v := value.(type) {
+		case nil:
+			continue
+		case *time.Time:
+			if v == nil {
+				continue
+			}
+			value = v.UTC().Format("2006-01-02 15:04:05.999999")
+		case time.Time:
+			value = v.UTC().Format("2006-01-02 15:04:05.999999")
+		case []byte:
+			// JSON values are put as they are, so that they are not
+			// populated as JSON strings.
+			switch fieldType.Type {
+			case skydb.TypeJSON, skydb.TypeACL, skydb.TypeGeometry:
+				value = json.RawMessage(v)
+			default:
+				value = string(v)
+			}
+		}
+
+		if fieldType.Type == skydb.TypeGeometry {
+			snapshot.Geometry[column] = value
+		} else {
+			snapshot.Row[column] = value
+		}
+	}
+	return snapshot, nil
+}
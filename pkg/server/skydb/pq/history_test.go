@@ -0,0 +1,152 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestRecordHistory(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		SetHistoryRecordTypes([]string{"note"})
+		defer SetHistoryRecordTypes(nil)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+		_, err = db.Extend("memo", skydb.RecordSchema{
+			"content": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		noteID := skydb.NewRecordID("note", "1")
+		saveNote := func(content string) {
+			So(db.Save(&skydb.Record{
+				ID:        noteID,
+				OwnerID:   "user",
+				UpdaterID: "user",
+				Data:      map[string]interface{}{"content": content},
+			}), ShouldBeNil)
+		}
+
+		Convey("records saves with the old record", func() {
+			saveNote("first")
+			saveNote("second")
+
+			history, err := db.GetRecordHistory(noteID, 0)
+			So(err, ShouldBeNil)
+			So(len(history), ShouldEqual, 2)
+
+			So(history[0].Action, ShouldEqual, skydb.RecordHistorySave)
+			So(history[0].ActorID, ShouldEqual, "user")
+			So(history[0].Record, ShouldNotBeNil)
+			So(history[0].Record.OwnerID, ShouldEqual, "user")
+			So(history[0].Record.Data["content"], ShouldEqual, "first")
+
+			So(history[1].Action, ShouldEqual, skydb.RecordHistorySave)
+			So(history[1].Record, ShouldBeNil)
+		})
+
+		Convey("records deletes with the actor from context", func() {
+			saveNote("first")
+
+			c.context = skydb.ContextWithActor(c.context, "admin")
+			So(db.Delete(noteID), ShouldBeNil)
+
+			history, err := db.GetRecordHistory(noteID, 1)
+			So(err, ShouldBeNil)
+			So(len(history), ShouldEqual, 1)
+			So(history[0].Action, ShouldEqual, skydb.RecordHistoryDelete)
+			So(history[0].ActorID, ShouldEqual, "admin")
+			So(history[0].Record.Data["content"], ShouldEqual, "first")
+		})
+
+		Convey("queries records as of a time", func() {
+			saveNote("first")
+			time.Sleep(10 * time.Millisecond)
+			asOf := time.Now().UTC()
+			time.Sleep(10 * time.Millisecond)
+			saveNote("second")
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("note", "2"),
+				OwnerID: "user",
+				Data:    map[string]interface{}{"content": "later"},
+			}), ShouldBeNil)
+
+			records, err := exhaustRows(db.Query(&skydb.Query{
+				Type: "note",
+				AsOf: &asOf,
+			}))
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 1)
+			So(records[0].ID, ShouldResemble, noteID)
+			So(records[0].Data["content"], ShouldEqual, "first")
+
+			count, err := db.QueryCount(&skydb.Query{
+				Type: "note",
+				AsOf: &asOf,
+			})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("queries deleted records as of a time", func() {
+			saveNote("first")
+			time.Sleep(10 * time.Millisecond)
+			asOf := time.Now().UTC()
+			time.Sleep(10 * time.Millisecond)
+			So(db.Delete(noteID), ShouldBeNil)
+
+			records, err := exhaustRows(db.Query(&skydb.Query{
+				Type: "note",
+				AsOf: &asOf,
+			}))
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 1)
+			So(records[0].Data["content"], ShouldEqual, "first")
+		})
+
+		Convey("rejects querying record types without history as of a time", func() {
+			asOf := time.Now().UTC()
+			_, err := db.Query(&skydb.Query{
+				Type: "memo",
+				AsOf: &asOf,
+			})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("does not record record types without history", func() {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("memo", "1"),
+				OwnerID: "user",
+				Data:    map[string]interface{}{"content": "hello"},
+			}), ShouldBeNil)
+
+			history, err := db.GetRecordHistory(skydb.NewRecordID("memo", "1"), 0)
+			So(err, ShouldBeNil)
+			So(history, ShouldBeEmpty)
+		})
+	})
+}
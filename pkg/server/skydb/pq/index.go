@@ -0,0 +1,157 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func (db *database) CreateIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	method := index.Method
+	if method == "" {
+		method = skydb.BTreeIndex
+	}
+	if !method.IsValid() {
+		return skyerr.NewErrorf(skyerr.InvalidArgument, "unknown index method %s", method)
+	}
+	if index.Unique && method != skydb.BTreeIndex {
+		return skyerr.NewErrorf(skyerr.InvalidArgument, "%s index cannot be unique", method)
+	}
+	if len(index.Fields) == 0 {
+		return skyerr.NewError(skyerr.InvalidArgument, "at least one field is required to create an index")
+	}
+
+	typemap, err := db.RemoteColumnTypes(recordType)
+	if err != nil {
+		return err
+	}
+	if len(typemap) == 0 {
+		return skyerr.NewErrorf(skyerr.ResourceNotFound, "record type %s does not exist", recordType)
+	}
+
+	quotedColumns := []string{}
+	for _, field := range index.Fields {
+		if _, ok := typemap[field]; !ok {
+			return skyerr.NewErrorf(skyerr.InvalidArgument,
+				"field %s does not exist in record type %s", field, recordType)
+		}
+		quotedColumns = append(quotedColumns, pq.QuoteIdentifier(field))
+	}
+
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	stmt := fmt.Sprintf(`CREATE %sINDEX %s ON %s USING %s (%s);`,
+		unique, pq.QuoteIdentifier(indexName), db.TableName(recordType),
+		method, strings.Join(quotedColumns, ", "))
+	log.WithField("stmt", stmt).Debugln("Creating index")
+	if _, err := db.c.Exec(stmt); err != nil {
+		if isDuplicateTable(err) {
+			return skyerr.NewErrorf(skyerr.Duplicated, "index %s already exists", indexName)
+		}
+		if isUniqueViolated(err) {
+			return skyerr.NewErrorf(skyerr.ConstraintViolated,
+				"fields of unique index %s have duplicated values", indexName)
+		}
+		return err
+	}
+	return nil
+}
+
+func (db *database) DropIndex(recordType, indexName string) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	// Only indexes of the record type are dropped, so that the indexes
+	// of other tables and constraints are not dropped by mistake.
+	indexes, err := db.ListIndexes(recordType)
+	if err != nil {
+		return err
+	}
+	if _, ok := indexes[indexName]; !ok {
+		return skydb.ErrIndexNotFound
+	}
+
+	stmt := fmt.Sprintf(`DROP INDEX %s.%s;`,
+		pq.QuoteIdentifier(db.schemaName()), pq.QuoteIdentifier(indexName))
+	log.WithField("stmt", stmt).Debugln("Dropping index")
+	if _, err := db.c.Exec(stmt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ListIndexes returns the indexes on columns of the record table. Primary
+// keys, indexes backing constraints and expression indexes are excluded.
+func (db *database) ListIndexes(recordType string) (map[string]skydb.RecordIndex, error) {
+	rows, err := db.c.Queryx(`
+SELECT
+    i.relname AS index_name,
+    am.amname AS method,
+    ix.indisunique AS is_unique,
+    array_to_string(
+        array_agg(a.attname ORDER BY array_position(ix.indkey::smallint[], a.attnum)),
+        ','
+    ) AS column_names
+FROM pg_index ix
+    JOIN pg_class t ON t.oid = ix.indrelid
+    JOIN pg_class i ON i.oid = ix.indexrelid
+    JOIN pg_namespace ns ON ns.oid = t.relnamespace
+    JOIN pg_am am ON am.oid = i.relam
+    JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+WHERE
+    ns.nspname = $1
+    AND t.relname = $2
+    AND NOT ix.indisprimary
+    AND 0 <> ALL(ix.indkey)
+    AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = ix.indexrelid)
+GROUP BY i.relname, am.amname, ix.indisunique;`,
+		db.schemaName(), recordType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := map[string]skydb.RecordIndex{}
+	for rows.Next() {
+		var (
+			name        string
+			method      string
+			unique      bool
+			columnNames string
+		)
+		if err := rows.Scan(&name, &method, &unique, &columnNames); err != nil {
+			return nil, err
+		}
+
+		indexes[name] = skydb.RecordIndex{
+			Fields: strings.Split(columnNames, ","),
+			Method: skydb.IndexMethod(method),
+			Unique: unique,
+		}
+	}
+	return indexes, rows.Err()
+}
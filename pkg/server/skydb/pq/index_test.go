@@ -0,0 +1,94 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestIndex(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"title":    skydb.FieldType{Type: skydb.TypeString},
+			"priority": skydb.FieldType{Type: skydb.TypeNumber},
+			"tags":     skydb.FieldType{Type: skydb.TypeJSON},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("creates and lists indexes", func() {
+			So(db.CreateIndex("note", "note_title_priority_idx", skydb.RecordIndex{
+				Fields: []string{"title", "priority"},
+				Unique: true,
+			}), ShouldBeNil)
+			So(db.CreateIndex("note", "note_tags_idx", skydb.RecordIndex{
+				Fields: []string{"tags"},
+				Method: skydb.GINIndex,
+			}), ShouldBeNil)
+
+			indexes, err := db.ListIndexes("note")
+			So(err, ShouldBeNil)
+			So(indexes, ShouldResemble, map[string]skydb.RecordIndex{
+				"note_title_priority_idx": {
+					Fields: []string{"title", "priority"},
+					Method: skydb.BTreeIndex,
+					Unique: true,
+				},
+				"note_tags_idx": {
+					Fields: []string{"tags"},
+					Method: skydb.GINIndex,
+				},
+			})
+		})
+
+		Convey("drops index", func() {
+			So(db.CreateIndex("note", "note_title_idx", skydb.RecordIndex{
+				Fields: []string{"title"},
+			}), ShouldBeNil)
+
+			So(db.DropIndex("note", "note_title_idx"), ShouldBeNil)
+			indexes, err := db.ListIndexes("note")
+			So(err, ShouldBeNil)
+			So(indexes, ShouldBeEmpty)
+		})
+
+		Convey("returns ErrIndexNotFound when dropping missing index", func() {
+			So(db.DropIndex("note", "note_title_idx"), ShouldEqual, skydb.ErrIndexNotFound)
+		})
+
+		Convey("returns error for duplicated index name", func() {
+			index := skydb.RecordIndex{Fields: []string{"title"}}
+			So(db.CreateIndex("note", "note_title_idx", index), ShouldBeNil)
+
+			err := db.CreateIndex("note", "note_title_idx", index)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.Duplicated)
+		})
+
+		Convey("returns error for unknown field", func() {
+			err := db.CreateIndex("note", "note_body_idx", skydb.RecordIndex{
+				Fields: []string{"body"},
+			})
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+
+	sq "github.com/lann/squirrel"
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/pq/builder"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// Leaderboard ranks the records with window functions in a common table
+// expression, so that the top ranked records and the entry of the user
+// are returned in one query.
+func (db *database) Leaderboard(query skydb.LeaderboardQuery) (*skydb.Leaderboard, error) {
+	typemap, err := db.RemoteColumnTypes(query.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(typemap) == 0 { // record type has not been created
+		return &skydb.Leaderboard{Top: []skydb.LeaderboardEntry{}}, nil
+	}
+
+	fieldType, ok := typemap[query.ScoreField]
+	if !ok {
+		return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			"field %s does not exist in record type %s", query.ScoreField, query.Type)
+	}
+	if fieldType.Type != skydb.TypeNumber && fieldType.Type != skydb.TypeInteger {
+		return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			"field %s must be a number to rank records by", query.ScoreField)
+	}
+
+	alias := pq.QuoteIdentifier(query.Type)
+	score := alias + "." + pq.QuoteIdentifier(query.ScoreField)
+	direction := "DESC"
+	if query.Ascending {
+		direction = "ASC"
+	}
+
+	ranked := sq.Select(
+		alias+`."_id" AS "_id"`,
+		`COALESCE(`+alias+`."_owner_id", '') AS "_owner_id"`,
+		score+`::double precision AS "_score"`,
+		fmt.Sprintf(`RANK() OVER (ORDER BY %s %s) AS "_rank"`, score, direction),
+		fmt.Sprintf(`ROW_NUMBER() OVER (ORDER BY %s %s, %s."_id") AS "_row_number"`, score, direction, alias),
+	).
+		From(db.TableName(query.Type)).
+		Where(score + " IS NOT NULL").
+		Where(notDeletedSqlizer(query.Type))
+
+	if db.DatabaseType() != skydb.UnionDatabase {
+		ranked = ranked.Where(alias+`."_database_id" = ?`, db.userID)
+	}
+	if db.DatabaseType() == skydb.PublicDatabase && !query.BypassAccessControl {
+		factory := builder.NewPredicateSqlizerFactory(db, query.Type)
+		aclSqlizer, err := factory.NewAccessControlSqlizer(query.ViewAsUser, skydb.ReadLevel)
+		if err != nil {
+			return nil, err
+		}
+		ranked = ranked.Where(aclSqlizer)
+	}
+
+	rankedSQL, rankedArgs, err := ranked.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := sq.Or{sq.Expr(`"_row_number" <= ?`, query.Limit)}
+	if query.UserID != "" {
+		selected = append(selected, sq.Expr(
+			`"_row_number" = (SELECT MIN("_row_number") FROM "_ranked" WHERE "_owner_id" = ?)`,
+			query.UserID))
+	}
+	q := psql.Select(`"_id"`, `"_owner_id"`, `"_score"`, `"_rank"`, `"_row_number"`).
+		Prefix(`WITH "_ranked" AS (`+rankedSQL+`)`, rankedArgs...).
+		From(`"_ranked"`).
+		Where(selected).
+		OrderBy(`"_row_number"`)
+
+	rows, err := db.c.QueryWith(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaderboard := &skydb.Leaderboard{Top: []skydb.LeaderboardEntry{}}
+	for rows.Next() {
+		var (
+			entry     skydb.LeaderboardEntry
+			rowNumber uint64
+		)
+		if err := rows.Scan(&entry.RecordID.Key, &entry.OwnerID, &entry.Score, &entry.Rank, &rowNumber); err != nil {
+			return nil, err
+		}
+		entry.RecordID.Type = query.Type
+
+		if rowNumber <= query.Limit {
+			leaderboard.Top = append(leaderboard.Top, entry)
+		}
+		if query.UserID != "" && entry.OwnerID == query.UserID && leaderboard.User == nil {
+			userEntry := entry
+			leaderboard.User = &userEntry
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return leaderboard, nil
+}
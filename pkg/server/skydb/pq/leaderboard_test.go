@@ -0,0 +1,118 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestLeaderboard(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("score", skydb.RecordSchema{
+			"points": skydb.FieldType{Type: skydb.TypeNumber},
+			"player": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		saveScore := func(id string, ownerID string, points interface{}) {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("score", id),
+				OwnerID: ownerID,
+				Data: map[string]interface{}{
+					"points": points,
+				},
+			}), ShouldBeNil)
+		}
+
+		saveScore("1", "alice", float64(100))
+		saveScore("2", "bob", float64(300))
+		saveScore("3", "carol", float64(200))
+		saveScore("4", "dave", float64(200))
+		saveScore("5", "erin", float64(50))
+		saveScore("6", "erin", float64(10))
+		saveScore("7", "frank", nil)
+
+		entry := func(rank uint64, id string, ownerID string, score float64) skydb.LeaderboardEntry {
+			return skydb.LeaderboardEntry{
+				Rank:     rank,
+				RecordID: skydb.NewRecordID("score", id),
+				OwnerID:  ownerID,
+				Score:    score,
+			}
+		}
+
+		Convey("returns top ranked records and rank of user", func() {
+			leaderboard, err := db.Leaderboard(skydb.LeaderboardQuery{
+				Type:       "score",
+				ScoreField: "points",
+				Limit:      3,
+				UserID:     "erin",
+			})
+			So(err, ShouldBeNil)
+			So(leaderboard.Top, ShouldResemble, []skydb.LeaderboardEntry{
+				entry(1, "2", "bob", 300),
+				entry(2, "3", "carol", 200),
+				entry(2, "4", "dave", 200),
+			})
+			So(*leaderboard.User, ShouldResemble, entry(5, "5", "erin", 50))
+		})
+
+		Convey("returns rank of user in top ranked records", func() {
+			leaderboard, err := db.Leaderboard(skydb.LeaderboardQuery{
+				Type:       "score",
+				ScoreField: "points",
+				Limit:      2,
+				UserID:     "bob",
+			})
+			So(err, ShouldBeNil)
+			So(leaderboard.Top, ShouldHaveLength, 2)
+			So(*leaderboard.User, ShouldResemble, entry(1, "2", "bob", 300))
+		})
+
+		Convey("ranks lower scores higher in ascending order", func() {
+			leaderboard, err := db.Leaderboard(skydb.LeaderboardQuery{
+				Type:       "score",
+				ScoreField: "points",
+				Ascending:  true,
+				Limit:      1,
+				UserID:     "frank",
+			})
+			So(err, ShouldBeNil)
+			So(leaderboard.Top, ShouldResemble, []skydb.LeaderboardEntry{
+				entry(1, "6", "erin", 10),
+			})
+			So(leaderboard.User, ShouldBeNil)
+		})
+
+		Convey("returns error for field that is not a number", func() {
+			_, err := db.Leaderboard(skydb.LeaderboardQuery{
+				Type:       "score",
+				ScoreField: "player",
+				Limit:      1,
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.RecordQueryInvalid)
+		})
+	})
+}
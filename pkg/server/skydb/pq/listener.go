@@ -53,6 +53,7 @@ func emit(n *notification) {
 			ch <- skydb.RecordEvent{
 				Record: &n.Record,
 				Event:  n.ChangeEvent,
+				Patch:  n.Patch,
 			}
 		}(channel)
 	}
@@ -65,6 +66,7 @@ type notification struct {
 	AppName     string
 	ChangeEvent skydb.RecordHookEvent
 	Record      skydb.Record
+	Patch       map[string]interface{}
 }
 
 type rawNotification struct {
@@ -72,6 +74,7 @@ type rawNotification struct {
 	Op         string
 	RecordType string
 	Record     []byte
+	OldRecord  []byte `db:"old_record"`
 }
 
 type recordListener struct {
@@ -142,7 +145,7 @@ func (l *recordListener) Listen() {
 // NOTE(limouren): pending_notification.id is integer in database.
 func (l *recordListener) fetchNotification(notificationID string, n *notification) error {
 	var rawNoti rawNotification
-	err := l.db.QueryRowx("SELECT op, appname, recordtype, record FROM public.pending_notification WHERE id = $1", notificationID).
+	err := l.db.QueryRowx("SELECT op, appname, recordtype, record, old_record FROM public.pending_notification WHERE id = $1", notificationID).
 		StructScan(&rawNoti)
 	if err != nil {
 		log.WithFields(logrus.Fields{
@@ -203,6 +206,15 @@ func parseNotification(raw *rawNotification, n *notification) error {
 	}
 	n.Record.ID.Type = raw.RecordType
 
+	// the record before the change is only notified for updates
+	if raw.OldRecord != nil {
+		var oldRecord skydb.Record
+		if err := parseRecordData(raw.OldRecord, &oldRecord); err != nil {
+			return err
+		}
+		n.Patch = skydb.CreateMergePatch(oldRecord.Data, n.Record.Data)
+	}
+
 	return nil
 }
 
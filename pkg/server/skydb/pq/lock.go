@@ -0,0 +1,121 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) AcquireLock(recordID skydb.RecordID, ownerID string, ttl time.Duration) (*skydb.Lock, error) {
+	now := time.Now().UTC()
+	lock := skydb.Lock{
+		RecordID: recordID,
+	}
+
+	// The existing lock is only taken over if it is held by the
+	// same owner or it has expired. Otherwise no rows are returned.
+	builder := psql.Insert(c.tableName("_lock")+" AS l").
+		Columns("record_type", "record_id", "owner_id", "expire_at").
+		Values(recordID.Type, recordID.Key, ownerID, now.Add(ttl)).
+		Suffix(`ON CONFLICT (record_type, record_id) DO UPDATE
+			SET owner_id = EXCLUDED.owner_id, expire_at = EXCLUDED.expire_at
+			WHERE l.owner_id = EXCLUDED.owner_id OR l.expire_at <= ?
+			RETURNING owner_id, expire_at`, now)
+
+	err := c.QueryRowWith(builder).Scan(&lock.OwnerID, &lock.ExpireAt)
+	if err == sql.ErrNoRows {
+		current, err := c.GetLock(recordID)
+		if err == skydb.ErrLockNotFound {
+			// the lock expired after the insert, try again
+			return c.AcquireLock(recordID, ownerID, ttl)
+		} else if err != nil {
+			return nil, err
+		}
+		return current, skydb.ErrRecordLocked
+	} else if err != nil {
+		return nil, err
+	}
+
+	lock.ExpireAt = lock.ExpireAt.In(time.UTC)
+	return &lock, nil
+}
+
+func (c *conn) RenewLock(recordID skydb.RecordID, ownerID string, ttl time.Duration) (*skydb.Lock, error) {
+	now := time.Now().UTC()
+	lock := skydb.Lock{
+		RecordID: recordID,
+		OwnerID:  ownerID,
+	}
+
+	builder := psql.Update(c.tableName("_lock")).
+		Set("expire_at", now.Add(ttl)).
+		Where("record_type = ? AND record_id = ? AND owner_id = ? AND expire_at > ?",
+			recordID.Type, recordID.Key, ownerID, now).
+		Suffix("RETURNING expire_at")
+
+	err := c.QueryRowWith(builder).Scan(&lock.ExpireAt)
+	if err == sql.ErrNoRows {
+		return nil, skydb.ErrLockNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	lock.ExpireAt = lock.ExpireAt.In(time.UTC)
+	return &lock, nil
+}
+
+func (c *conn) ReleaseLock(recordID skydb.RecordID, ownerID string) error {
+	builder := psql.Delete(c.tableName("_lock")).
+		Where("record_type = ? AND record_id = ? AND owner_id = ? AND expire_at > ?",
+			recordID.Type, recordID.Key, ownerID, time.Now().UTC())
+
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return skydb.ErrLockNotFound
+	}
+	return nil
+}
+
+func (c *conn) GetLock(recordID skydb.RecordID) (*skydb.Lock, error) {
+	lock := skydb.Lock{
+		RecordID: recordID,
+	}
+
+	builder := psql.Select("owner_id", "expire_at").
+		From(c.tableName("_lock")).
+		Where("record_type = ? AND record_id = ? AND expire_at > ?",
+			recordID.Type, recordID.Key, time.Now().UTC())
+
+	err := c.QueryRowWith(builder).Scan(&lock.OwnerID, &lock.ExpireAt)
+	if err == sql.ErrNoRows {
+		return nil, skydb.ErrLockNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	lock.ExpireAt = lock.ExpireAt.In(time.UTC)
+	return &lock, nil
+}
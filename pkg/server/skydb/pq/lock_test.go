@@ -0,0 +1,98 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestLock(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		recordID := skydb.NewRecordID("note", "1")
+
+		Convey("acquire lock", func() {
+			lock, err := c.AcquireLock(recordID, "alice", time.Minute)
+			So(err, ShouldBeNil)
+			So(lock.RecordID, ShouldResemble, recordID)
+			So(lock.OwnerID, ShouldEqual, "alice")
+			So(lock.ExpireAt, ShouldHappenAfter, time.Now())
+
+			lock, err = c.GetLock(recordID)
+			So(err, ShouldBeNil)
+			So(lock.OwnerID, ShouldEqual, "alice")
+		})
+
+		Convey("reacquire lock by the same owner", func() {
+			_, err := c.AcquireLock(recordID, "alice", time.Minute)
+			So(err, ShouldBeNil)
+
+			lock, err := c.AcquireLock(recordID, "alice", time.Hour)
+			So(err, ShouldBeNil)
+			So(lock.ExpireAt, ShouldHappenAfter, time.Now().Add(time.Minute))
+		})
+
+		Convey("fail to acquire lock held by another owner", func() {
+			_, err := c.AcquireLock(recordID, "alice", time.Minute)
+			So(err, ShouldBeNil)
+
+			lock, err := c.AcquireLock(recordID, "bob", time.Minute)
+			So(err, ShouldEqual, skydb.ErrRecordLocked)
+			So(lock.OwnerID, ShouldEqual, "alice")
+		})
+
+		Convey("acquire expired lock held by another owner", func() {
+			_, err := c.AcquireLock(recordID, "alice", -time.Second)
+			So(err, ShouldBeNil)
+
+			_, err = c.GetLock(recordID)
+			So(err, ShouldEqual, skydb.ErrLockNotFound)
+
+			lock, err := c.AcquireLock(recordID, "bob", time.Minute)
+			So(err, ShouldBeNil)
+			So(lock.OwnerID, ShouldEqual, "bob")
+		})
+
+		Convey("renew lock", func() {
+			_, err := c.AcquireLock(recordID, "alice", time.Minute)
+			So(err, ShouldBeNil)
+
+			lock, err := c.RenewLock(recordID, "alice", time.Hour)
+			So(err, ShouldBeNil)
+			So(lock.ExpireAt, ShouldHappenAfter, time.Now().Add(time.Minute))
+
+			_, err = c.RenewLock(recordID, "bob", time.Hour)
+			So(err, ShouldEqual, skydb.ErrLockNotFound)
+		})
+
+		Convey("release lock", func() {
+			_, err := c.AcquireLock(recordID, "alice", time.Minute)
+			So(err, ShouldBeNil)
+
+			So(c.ReleaseLock(recordID, "bob"), ShouldEqual, skydb.ErrLockNotFound)
+			So(c.ReleaseLock(recordID, "alice"), ShouldBeNil)
+
+			_, err = c.GetLock(recordID)
+			So(err, ShouldEqual, skydb.ErrLockNotFound)
+		})
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestMaintenance(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		Convey("gets disabled maintenance if never set", func() {
+			maintenance, err := c.GetMaintenance()
+			So(err, ShouldBeNil)
+			So(*maintenance, ShouldResemble, skydb.Maintenance{})
+		})
+
+		Convey("sets maintenance", func() {
+			enabled := skydb.Maintenance{
+				Enabled:   true,
+				Message:   "migrating",
+				UpdatedAt: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+				UpdatedBy: "admin",
+			}
+			So(c.SetMaintenance(&enabled), ShouldBeNil)
+
+			maintenance, err := c.GetMaintenance()
+			So(err, ShouldBeNil)
+			So(*maintenance, ShouldResemble, enabled)
+
+			disabled := skydb.Maintenance{
+				UpdatedAt: time.Date(2017, 1, 1, 1, 0, 0, 0, time.UTC),
+				UpdatedBy: "admin",
+			}
+			So(c.SetMaintenance(&disabled), ShouldBeNil)
+
+			maintenance, err = c.GetMaintenance()
+			So(err, ShouldBeNil)
+			So(*maintenance, ShouldResemble, disabled)
+		})
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_3a8f1d6c9e42 struct {
+}
+
+func (r *revision_3a8f1d6c9e42) Version() string {
+	return "3a8f1d6c9e42"
+}
+
+// IsBackwardCompatible returns true because only a new table is created.
+func (r *revision_3a8f1d6c9e42) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_3a8f1d6c9e42) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _annotation (
+	id text PRIMARY KEY,
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	author_id text NOT NULL,
+	content text NOT NULL,
+	created_at timestamp without time zone NOT NULL
+);
+CREATE INDEX _annotation_record_type_record_id_idx ON _annotation (record_type, record_id, created_at);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_3a8f1d6c9e42) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _annotation;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,53 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_3b7e5d91c4a2 struct {
+}
+
+func (r *revision_3b7e5d91c4a2) Version() string {
+	return "3b7e5d91c4a2"
+}
+
+// IsBackwardCompatible returns true because only a new table is added.
+func (r *revision_3b7e5d91c4a2) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_3b7e5d91c4a2) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _report (
+	id text PRIMARY KEY,
+	name text NOT NULL,
+	query jsonb NOT NULL,
+	schedule text NOT NULL,
+	roles jsonb NOT NULL,
+	updated_at timestamp without time zone NOT NULL,
+	last_run_at timestamp without time zone
+);
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_3b7e5d91c4a2) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _report;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
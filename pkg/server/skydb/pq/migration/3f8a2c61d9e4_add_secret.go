@@ -0,0 +1,58 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_3f8a2c61d9e4 struct {
+}
+
+func (r *revision_3f8a2c61d9e4) Version() string {
+	return "3f8a2c61d9e4"
+}
+
+// IsBackwardCompatible returns true because only a new table is added.
+func (r *revision_3f8a2c61d9e4) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_3f8a2c61d9e4) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		`
+CREATE TABLE _secret (
+	name text PRIMARY KEY,
+	key_id text NOT NULL,
+	sealed bytea NOT NULL,
+	updated_at timestamp without time zone NOT NULL
+);
+`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *revision_3f8a2c61d9e4) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _secret;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,219 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+// recordPatchFunctions are the functions applying the patches of record
+// fields, which raise invalid_text_representation if a patch cannot be
+// applied. They are created in the public schema, which is shared by
+// all apps, so they are not dropped on downgrade.
+const recordPatchFunctions = `
+CREATE OR REPLACE FUNCTION public.json_merge_patch(target jsonb, patch jsonb) RETURNS jsonb AS $$
+	BEGIN
+		IF jsonb_typeof(patch) <> 'object' THEN
+			RETURN patch;
+		END IF;
+		IF target IS NULL OR jsonb_typeof(target) <> 'object' THEN
+			target := '{}'::jsonb;
+		END IF;
+		RETURN (
+			SELECT COALESCE(jsonb_object_agg(merged.key, merged.value), '{}'::jsonb)
+			FROM (
+				SELECT t.key, t.value FROM jsonb_each(target) AS t
+				WHERE NOT patch ? t.key
+				UNION ALL
+				SELECT p.key, public.json_merge_patch(target->p.key, p.value)
+				FROM jsonb_each(patch) AS p
+				WHERE jsonb_typeof(p.value) <> 'null'
+			) AS merged
+		);
+	END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION public.json_patch(target jsonb, patch jsonb) RETURNS jsonb AS $$
+	DECLARE
+		op jsonb;
+		path text[];
+		parent text[];
+		n integer;
+		value jsonb;
+	BEGIN
+		FOR op IN SELECT * FROM jsonb_array_elements(patch) LOOP
+			path := ARRAY(SELECT jsonb_array_elements_text(op->'path'));
+			n := COALESCE(array_length(path, 1), 0);
+			parent := path[1:n - 1];
+			value := op->'value';
+
+			IF op->>'op' IN ('remove', 'replace', 'test') AND target #> path IS NULL THEN
+				RAISE EXCEPTION 'json patch path % does not exist', op->'path'
+					USING ERRCODE = 'invalid_text_representation';
+			END IF;
+
+			IF op->>'op' IN ('move', 'copy') THEN
+				value := target #> ARRAY(SELECT jsonb_array_elements_text(op->'from'));
+				IF value IS NULL THEN
+					RAISE EXCEPTION 'json patch path % does not exist', op->'from'
+						USING ERRCODE = 'invalid_text_representation';
+				END IF;
+				IF op->>'op' = 'move' THEN
+					target := target #- ARRAY(SELECT jsonb_array_elements_text(op->'from'));
+				END IF;
+			END IF;
+
+			CASE op->>'op'
+			WHEN 'test' THEN
+				IF target #> path <> value THEN
+					RAISE EXCEPTION 'json patch test of % failed', op->'path'
+						USING ERRCODE = 'invalid_text_representation';
+				END IF;
+			WHEN 'remove' THEN
+				target := target #- path;
+			WHEN 'replace' THEN
+				IF n = 0 THEN
+					target := value;
+				ELSE
+					target := jsonb_set(target, path, value, false);
+				END IF;
+			ELSE
+				IF n = 0 THEN
+					target := value;
+				ELSIF jsonb_typeof(target #> parent) = 'array' THEN
+					IF path[n] = '-' THEN
+						path[n] := jsonb_array_length(target #> parent)::text;
+					ELSIF path[n] !~ '^(0|[1-9][0-9]*)$' THEN
+						RAISE EXCEPTION 'json patch array index % is invalid', path[n]
+							USING ERRCODE = 'invalid_text_representation';
+					ELSIF path[n]::integer > jsonb_array_length(target #> parent) THEN
+						RAISE EXCEPTION 'json patch array index % is out of bounds', path[n]
+							USING ERRCODE = 'invalid_text_representation';
+					END IF;
+					target := jsonb_insert(target, path, value);
+				ELSIF jsonb_typeof(target #> parent) = 'object' THEN
+					target := jsonb_set(target, path, value, true);
+				ELSE
+					RAISE EXCEPTION 'json patch path % has no parent object or array', op->'path'
+						USING ERRCODE = 'invalid_text_representation';
+				END IF;
+			END CASE;
+		END LOOP;
+		RETURN target;
+	END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION public.text_patch(target text, patch jsonb) RETURNS text AS $$
+	DECLARE
+		splice jsonb;
+	BEGIN
+		target := COALESCE(target, '');
+		FOR splice IN SELECT * FROM jsonb_array_elements(patch) LOOP
+			IF (splice->>'offset')::integer > char_length(target) THEN
+				RAISE EXCEPTION 'text patch offset % is out of bounds', splice->'offset'
+					USING ERRCODE = 'invalid_text_representation';
+			END IF;
+			target := overlay(target PLACING splice->>'insert'
+				FROM (splice->>'offset')::integer + 1 FOR (splice->>'delete')::integer);
+		END LOOP;
+		RETURN target;
+	END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+`
+
+type revision_4e1b7d9c2a86 struct {
+}
+
+func (r *revision_4e1b7d9c2a86) Version() string {
+	return "4e1b7d9c2a86"
+}
+
+// IsBackwardCompatible returns true because only functions and columns
+// with defaults are added, and the notification of record changes only
+// gains the record before an update.
+func (r *revision_4e1b7d9c2a86) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_4e1b7d9c2a86) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		recordPatchFunctions,
+		`ALTER TABLE public.pending_notification ADD COLUMN IF NOT EXISTS old_record jsonb;`,
+		`
+CREATE OR REPLACE FUNCTION public.notify_record_change() RETURNS TRIGGER AS $$
+	DECLARE
+		affected_record RECORD;
+		previous_record jsonb;
+		inserted_id integer;
+	BEGIN
+		IF (TG_OP = 'DELETE') THEN
+			affected_record := OLD;
+		ELSE
+			affected_record := NEW;
+		END IF;
+		IF (TG_OP = 'UPDATE') THEN
+			previous_record := row_to_json(OLD)::jsonb;
+		END IF;
+		INSERT INTO public.pending_notification (op, appname, recordtype, record, old_record)
+			VALUES (TG_OP, TG_TABLE_SCHEMA, TG_TABLE_NAME, row_to_json(affected_record)::jsonb, previous_record)
+			RETURNING id INTO inserted_id;
+		PERFORM pg_notify('record_change', inserted_id::TEXT);
+		RETURN affected_record;
+	END;
+$$ LANGUAGE plpgsql;
+`,
+		`ALTER TABLE _webhook ADD COLUMN delta boolean NOT NULL DEFAULT FALSE;`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down restores the notification of record changes, but leaves the
+// functions and the column of the notifications in the public schema,
+// which might be used by other apps.
+func (r *revision_4e1b7d9c2a86) Down(tx *sqlx.Tx) error {
+	stmts := []string{
+		`
+CREATE OR REPLACE FUNCTION public.notify_record_change() RETURNS TRIGGER AS $$
+	DECLARE
+		affected_record RECORD;
+		inserted_id integer;
+	BEGIN
+		IF (TG_OP = 'DELETE') THEN
+			affected_record := OLD;
+		ELSE
+			affected_record := NEW;
+		END IF;
+		INSERT INTO public.pending_notification (op, appname, recordtype, record)
+			VALUES (TG_OP, TG_TABLE_SCHEMA, TG_TABLE_NAME, row_to_json(affected_record)::jsonb)
+			RETURNING id INTO inserted_id;
+		PERFORM pg_notify('record_change', inserted_id::TEXT);
+		RETURN affected_record;
+	END;
+$$ LANGUAGE plpgsql;
+`,
+		`ALTER TABLE _webhook DROP COLUMN delta;`,
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_5b9e3a7c2f14 struct {
+}
+
+func (r *revision_5b9e3a7c2f14) Version() string {
+	return "5b9e3a7c2f14"
+}
+
+// IsBackwardCompatible returns true because only a new table is created.
+func (r *revision_5b9e3a7c2f14) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_5b9e3a7c2f14) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		`
+CREATE TABLE _history (
+	id bigserial PRIMARY KEY,
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	action text NOT NULL,
+	data jsonb,
+	actor text,
+	created_at timestamp without time zone NOT NULL
+);
+`,
+		`CREATE INDEX _history_record_type_record_id_idx ON _history (record_type, record_id, database_id, id);`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *revision_5b9e3a7c2f14) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _history;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
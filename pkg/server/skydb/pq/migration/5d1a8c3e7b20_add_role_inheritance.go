@@ -0,0 +1,48 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_5d1a8c3e7b20 struct {
+}
+
+func (r *revision_5d1a8c3e7b20) Version() string {
+	return "5d1a8c3e7b20"
+}
+
+// IsBackwardCompatible returns true because only a new table is created.
+func (r *revision_5d1a8c3e7b20) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_5d1a8c3e7b20) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _role_inheritance (
+	role_id text REFERENCES _role (id) NOT NULL,
+	inherited_role_id text REFERENCES _role (id) NOT NULL,
+	PRIMARY KEY (role_id, inherited_role_id)
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_5d1a8c3e7b20) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _role_inheritance;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
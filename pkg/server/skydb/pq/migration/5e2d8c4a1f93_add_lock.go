@@ -0,0 +1,51 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_5e2d8c4a1f93 struct {
+}
+
+func (r *revision_5e2d8c4a1f93) Version() string {
+	return "5e2d8c4a1f93"
+}
+
+// IsBackwardCompatible returns true because only a new table is added.
+func (r *revision_5e2d8c4a1f93) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_5e2d8c4a1f93) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _lock (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	owner_id text NOT NULL,
+	expire_at timestamp without time zone NOT NULL,
+	PRIMARY KEY (record_type, record_id)
+);
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_5e2d8c4a1f93) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _lock;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,51 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_6f1d0c8a3b25 struct {
+}
+
+func (r *revision_6f1d0c8a3b25) Version() string {
+	return "6f1d0c8a3b25"
+}
+
+// IsBackwardCompatible returns true because only a new table is added.
+func (r *revision_6f1d0c8a3b25) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_6f1d0c8a3b25) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _maintenance (
+	id boolean PRIMARY KEY DEFAULT TRUE CHECK (id),
+	enabled boolean NOT NULL,
+	message text NOT NULL DEFAULT '',
+	updated_at timestamp without time zone NOT NULL,
+	updated_by text NOT NULL DEFAULT ''
+);
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_6f1d0c8a3b25) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _maintenance;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,45 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_7a3c19e4d2b6 struct {
+}
+
+func (r *revision_7a3c19e4d2b6) Version() string {
+	return "7a3c19e4d2b6"
+}
+
+func (r *revision_7a3c19e4d2b6) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _counter (
+	name text NOT NULL,
+	shard integer NOT NULL,
+	value bigint NOT NULL DEFAULT 0,
+	PRIMARY KEY (name, shard)
+);
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_7a3c19e4d2b6) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _counter;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,60 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_7b1d4e9a2f60 struct {
+}
+
+func (r *revision_7b1d4e9a2f60) Version() string {
+	return "7b1d4e9a2f60"
+}
+
+// IsBackwardCompatible returns true because the phone field is added to user records.
+func (r *revision_7b1d4e9a2f60) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_7b1d4e9a2f60) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		`ALTER TABLE "user" ADD COLUMN IF NOT EXISTS phone citext`,
+		`
+INSERT INTO _record_field_access
+  (record_type, record_field, user_role, writable, readable, comparable, discoverable)
+VALUES
+  ('user', 'phone', '_any_user', 'FALSE', 'FALSE', 'FALSE', 'TRUE'),
+  ('user', 'phone', '_owner', 'TRUE', 'TRUE', 'TRUE', 'TRUE')
+ON CONFLICT (record_type, record_field, user_role) DO NOTHING
+`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *revision_7b1d4e9a2f60) Down(tx *sqlx.Tx) error {
+	stmt := `ALTER TABLE "user" DROP COLUMN IF EXISTS phone;
+DELETE FROM _record_field_access WHERE record_type = 'user' AND record_field = 'phone';`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
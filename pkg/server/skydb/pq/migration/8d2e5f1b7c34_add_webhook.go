@@ -0,0 +1,68 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_8d2e5f1b7c34 struct {
+}
+
+func (r *revision_8d2e5f1b7c34) Version() string {
+	return "8d2e5f1b7c34"
+}
+
+// IsBackwardCompatible returns true because a new table is added.
+func (r *revision_8d2e5f1b7c34) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_8d2e5f1b7c34) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		`
+CREATE TABLE _webhook (
+	id text PRIMARY KEY,
+	url text NOT NULL,
+	secret text NOT NULL,
+	events jsonb NOT NULL,
+	record_types jsonb NOT NULL,
+	paused boolean NOT NULL DEFAULT FALSE,
+	disabled boolean NOT NULL DEFAULT FALSE,
+	created_at timestamp without time zone NOT NULL,
+	delivered integer NOT NULL DEFAULT 0,
+	failed integer NOT NULL DEFAULT 0,
+	consecutive_failures integer NOT NULL DEFAULT 0,
+	last_delivered_at timestamp without time zone,
+	last_failed_at timestamp without time zone,
+	last_error text NOT NULL DEFAULT ''
+);
+`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *revision_8d2e5f1b7c34) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _webhook;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,73 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_9c41e7b2d0a5 struct {
+}
+
+func (r *revision_9c41e7b2d0a5) Version() string {
+	return "9c41e7b2d0a5"
+}
+
+// IsBackwardCompatible returns true because only an extension and a new
+// table are added.
+func (r *revision_9c41e7b2d0a5) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_9c41e7b2d0a5) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;`,
+		`
+CREATE TABLE _record_archive (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	owner_id text NOT NULL,
+	data jsonb NOT NULL,
+	merged_into text,
+	archived_at timestamp without time zone NOT NULL
+);
+`,
+		`CREATE INDEX _record_archive_record_type_record_id_idx ON _record_archive (record_type, record_id);`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *revision_9c41e7b2d0a5) Down(tx *sqlx.Tx) error {
+	stmts := []string{
+		`DROP TABLE _record_archive;`,
+		`DROP EXTENSION pg_trgm;`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
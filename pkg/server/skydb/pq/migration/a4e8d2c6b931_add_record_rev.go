@@ -0,0 +1,64 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type revision_a4e8d2c6b931 struct {
+}
+
+func (r *revision_a4e8d2c6b931) Version() string {
+	return "a4e8d2c6b931"
+}
+
+// IsBackwardCompatible returns true because only a column with a default
+// value is added to record tables.
+func (r *revision_a4e8d2c6b931) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_a4e8d2c6b931) Up(tx *sqlx.Tx) error {
+	tables, err := getAllRecordTables(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tables {
+		stmt := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN _rev BIGINT NOT NULL DEFAULT 1;`, name)
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *revision_a4e8d2c6b931) Down(tx *sqlx.Tx) error {
+	tables, err := getAllRecordTables(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tables {
+		stmt := fmt.Sprintf(`ALTER TABLE %s DROP COLUMN _rev;`, name)
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_a7c3e9f2b851 struct {
+}
+
+func (r *revision_a7c3e9f2b851) Version() string {
+	return "a7c3e9f2b851"
+}
+
+// IsBackwardCompatible returns true because only a new table is added.
+func (r *revision_a7c3e9f2b851) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_a7c3e9f2b851) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		`
+CREATE TABLE _service_account (
+	id text PRIMARY KEY REFERENCES _auth (id) ON DELETE CASCADE,
+	name text NOT NULL,
+	hashed_secret bytea,
+	public_key text NOT NULL DEFAULT '',
+	created_at timestamp without time zone NOT NULL,
+	last_used_at timestamp without time zone
+);
+`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *revision_a7c3e9f2b851) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _service_account;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_b2d7e4f1a963 struct {
+}
+
+func (r *revision_b2d7e4f1a963) Version() string {
+	return "b2d7e4f1a963"
+}
+
+// IsBackwardCompatible returns true because only new tables are created.
+func (r *revision_b2d7e4f1a963) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_b2d7e4f1a963) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _reaction (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	user_id text NOT NULL,
+	type text NOT NULL,
+	created_at timestamp without time zone NOT NULL,
+	PRIMARY KEY (record_type, record_id, user_id, type)
+);
+CREATE TABLE _reaction_count (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	type text NOT NULL,
+	count bigint NOT NULL,
+	PRIMARY KEY (record_type, record_id, type)
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_b2d7e4f1a963) Down(tx *sqlx.Tx) error {
+	stmt := `
+DROP TABLE _reaction_count;
+DROP TABLE _reaction;
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
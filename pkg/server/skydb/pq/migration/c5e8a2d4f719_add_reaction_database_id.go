@@ -0,0 +1,110 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type revision_c5e8a2d4f719 struct {
+}
+
+func (r *revision_c5e8a2d4f719) Version() string {
+	return "c5e8a2d4f719"
+}
+
+// IsBackwardCompatible returns false because the primary keys of the
+// reaction tables are changed.
+func (r *revision_c5e8a2d4f719) IsBackwardCompatible() bool {
+	return false
+}
+
+func (r *revision_c5e8a2d4f719) Up(tx *sqlx.Tx) error {
+	stmt := `
+ALTER TABLE _reaction ADD COLUMN database_id text NOT NULL DEFAULT '';
+`
+	if _, err := tx.Exec(stmt); err != nil {
+		return err
+	}
+
+	// Reactions were keyed by record ID only, so the database of the
+	// reacted record is looked up from the record table.
+	tables, err := getAllRecordTables(tx)
+	if err != nil {
+		return err
+	}
+	for _, name := range tables {
+		stmt := fmt.Sprintf(`
+UPDATE _reaction AS r SET database_id = t._database_id
+FROM "%s" AS t
+WHERE r.record_type = $1 AND r.record_id = t._id;
+`, name)
+		if _, err := tx.Exec(stmt, name); err != nil {
+			return err
+		}
+	}
+
+	stmt = `
+ALTER TABLE _reaction DROP CONSTRAINT _reaction_pkey;
+ALTER TABLE _reaction ADD PRIMARY KEY (record_type, record_id, database_id, user_id, type);
+DROP TABLE _reaction_count;
+CREATE TABLE _reaction_count (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	type text NOT NULL,
+	count bigint NOT NULL,
+	PRIMARY KEY (record_type, record_id, database_id, type)
+);
+INSERT INTO _reaction_count (record_type, record_id, database_id, type, count)
+SELECT record_type, record_id, database_id, type, count(*)
+FROM _reaction
+GROUP BY record_type, record_id, database_id, type;
+`
+	_, err = tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_c5e8a2d4f719) Down(tx *sqlx.Tx) error {
+	stmt := `
+DELETE FROM _reaction AS r
+USING _reaction AS other
+WHERE r.record_type = other.record_type
+	AND r.record_id = other.record_id
+	AND r.user_id = other.user_id
+	AND r.type = other.type
+	AND r.database_id > other.database_id;
+ALTER TABLE _reaction DROP CONSTRAINT _reaction_pkey;
+ALTER TABLE _reaction DROP COLUMN database_id;
+ALTER TABLE _reaction ADD PRIMARY KEY (record_type, record_id, user_id, type);
+DROP TABLE _reaction_count;
+CREATE TABLE _reaction_count (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	type text NOT NULL,
+	count bigint NOT NULL,
+	PRIMARY KEY (record_type, record_id, type)
+);
+INSERT INTO _reaction_count (record_type, record_id, type, count)
+SELECT record_type, record_id, type, count(*)
+FROM _reaction
+GROUP BY record_type, record_id, type;
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
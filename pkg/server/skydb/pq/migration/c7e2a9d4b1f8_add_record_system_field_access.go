@@ -0,0 +1,48 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_c7e2a9d4b1f8 struct {
+}
+
+func (r *revision_c7e2a9d4b1f8) Version() string {
+	return "c7e2a9d4b1f8"
+}
+
+// IsBackwardCompatible returns true because only a new table is created.
+func (r *revision_c7e2a9d4b1f8) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_c7e2a9d4b1f8) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _record_system_field_access (
+	record_type text PRIMARY KEY,
+	masks jsonb NOT NULL,
+	salt text NOT NULL
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_c7e2a9d4b1f8) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _record_system_field_access;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
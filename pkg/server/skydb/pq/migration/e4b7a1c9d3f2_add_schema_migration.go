@@ -0,0 +1,49 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_e4b7a1c9d3f2 struct {
+}
+
+func (r *revision_e4b7a1c9d3f2) Version() string {
+	return "e4b7a1c9d3f2"
+}
+
+// IsBackwardCompatible returns true because only a new table is created.
+func (r *revision_e4b7a1c9d3f2) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_e4b7a1c9d3f2) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _schema_migration (
+	record_type text NOT NULL,
+	version text NOT NULL,
+	applied_at timestamp without time zone NOT NULL,
+	PRIMARY KEY (record_type, version)
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_e4b7a1c9d3f2) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _schema_migration;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
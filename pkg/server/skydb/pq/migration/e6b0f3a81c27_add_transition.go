@@ -0,0 +1,64 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_e6b0f3a81c27 struct {
+}
+
+func (r *revision_e6b0f3a81c27) Version() string {
+	return "e6b0f3a81c27"
+}
+
+// IsBackwardCompatible returns true because only a new table is added.
+func (r *revision_e6b0f3a81c27) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_e6b0f3a81c27) Up(tx *sqlx.Tx) error {
+	stmts := []string{
+		`
+CREATE TABLE _transition (
+	id text PRIMARY KEY,
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	data jsonb NOT NULL,
+	scheduled_at timestamp without time zone NOT NULL,
+	created_at timestamp without time zone NOT NULL,
+	created_by text
+);
+`,
+		`CREATE INDEX _transition_scheduled_at_idx ON _transition (scheduled_at);`,
+		`CREATE INDEX _transition_record_type_record_id_idx ON _transition (record_type, record_id);`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *revision_e6b0f3a81c27) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _transition;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_e7a94c2b6f18 struct {
+}
+
+func (r *revision_e7a94c2b6f18) Version() string {
+	return "e7a94c2b6f18"
+}
+
+// IsBackwardCompatible returns true because only a new table is created.
+func (r *revision_e7a94c2b6f18) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_e7a94c2b6f18) Up(tx *sqlx.Tx) error {
+	stmt := `
+CREATE TABLE _unread_count (
+	user_id text NOT NULL,
+	channel text NOT NULL,
+	count bigint NOT NULL,
+	PRIMARY KEY (user_id, channel)
+);
+`
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_e7a94c2b6f18) Down(tx *sqlx.Tx) error {
+	stmt := `DROP TABLE _unread_count;`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -0,0 +1,52 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import "github.com/jmoiron/sqlx"
+
+type revision_f1c8a4d7e293 struct {
+}
+
+func (r *revision_f1c8a4d7e293) Version() string {
+	return "f1c8a4d7e293"
+}
+
+// IsBackwardCompatible returns true because only columns with default
+// values are added.
+func (r *revision_f1c8a4d7e293) IsBackwardCompatible() bool {
+	return true
+}
+
+func (r *revision_f1c8a4d7e293) Up(tx *sqlx.Tx) error {
+	stmt := `
+ALTER TABLE _transition
+	ADD COLUMN with_master_key boolean NOT NULL DEFAULT FALSE,
+	ADD COLUMN claimed_until timestamp without time zone;
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
+
+func (r *revision_f1c8a4d7e293) Down(tx *sqlx.Tx) error {
+	stmt := `
+ALTER TABLE _transition
+	DROP COLUMN with_master_key,
+	DROP COLUMN claimed_until;
+`
+
+	_, err := tx.Exec(stmt)
+	return err
+}
@@ -23,22 +23,26 @@ import (
 type fullMigration struct {
 }
 
-func (r *fullMigration) Version() string { return "b55e91bc9391" }
+func (r *fullMigration) Version() string { return "c5e8a2d4f719" }
 
 func (r *fullMigration) createTable(tx *sqlx.Tx) error {
 	const stmt = `
 CREATE EXTENSION IF NOT EXISTS postgis WITH SCHEMA public;
 CREATE EXTENSION IF NOT EXISTS citext WITH SCHEMA public;
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
 CREATE TABLE IF NOT EXISTS public.pending_notification (
 	id SERIAL NOT NULL PRIMARY KEY,
 	op text NOT NULL,
 	appname text NOT NULL,
 	recordtype text NOT NULL,
-	record jsonb NOT NULL
+	record jsonb NOT NULL,
+	old_record jsonb
 );
+ALTER TABLE public.pending_notification ADD COLUMN IF NOT EXISTS old_record jsonb;
 CREATE OR REPLACE FUNCTION public.notify_record_change() RETURNS TRIGGER AS $$
 	DECLARE
 		affected_record RECORD;
+		previous_record jsonb;
 		inserted_id integer;
 	BEGIN
 		IF (TG_OP = 'DELETE') THEN
@@ -46,8 +50,11 @@ CREATE OR REPLACE FUNCTION public.notify_record_change() RETURNS TRIGGER AS $$
 		ELSE
 			affected_record := NEW;
 		END IF;
-		INSERT INTO public.pending_notification (op, appname, recordtype, record)
-			VALUES (TG_OP, TG_TABLE_SCHEMA, TG_TABLE_NAME, row_to_json(affected_record)::jsonb)
+		IF (TG_OP = 'UPDATE') THEN
+			previous_record := row_to_json(OLD)::jsonb;
+		END IF;
+		INSERT INTO public.pending_notification (op, appname, recordtype, record, old_record)
+			VALUES (TG_OP, TG_TABLE_SCHEMA, TG_TABLE_NAME, row_to_json(affected_record)::jsonb, previous_record)
 			RETURNING id INTO inserted_id;
 		PERFORM pg_notify('record_change', inserted_id::TEXT);
 		RETURN affected_record;
@@ -131,6 +138,152 @@ CREATE TABLE _record_field_access (
     discoverable boolean NOT NULL,
     PRIMARY KEY (record_type, record_field, user_role)
 );
+CREATE TABLE _counter (
+	name text NOT NULL,
+	shard integer NOT NULL,
+	value bigint NOT NULL DEFAULT 0,
+	PRIMARY KEY (name, shard)
+);
+CREATE TABLE _lock (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	owner_id text NOT NULL,
+	expire_at timestamp without time zone NOT NULL,
+	PRIMARY KEY (record_type, record_id)
+);
+CREATE TABLE _record_archive (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	owner_id text NOT NULL,
+	data jsonb NOT NULL,
+	merged_into text,
+	archived_at timestamp without time zone NOT NULL
+);
+CREATE INDEX _record_archive_record_type_record_id_idx ON _record_archive (record_type, record_id);
+CREATE TABLE _transition (
+	id text PRIMARY KEY,
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	data jsonb NOT NULL,
+	scheduled_at timestamp without time zone NOT NULL,
+	created_at timestamp without time zone NOT NULL,
+	created_by text,
+	with_master_key boolean NOT NULL DEFAULT FALSE,
+	claimed_until timestamp without time zone
+);
+CREATE INDEX _transition_scheduled_at_idx ON _transition (scheduled_at);
+CREATE INDEX _transition_record_type_record_id_idx ON _transition (record_type, record_id);
+CREATE TABLE _report (
+	id text PRIMARY KEY,
+	name text NOT NULL,
+	query jsonb NOT NULL,
+	schedule text NOT NULL,
+	roles jsonb NOT NULL,
+	updated_at timestamp without time zone NOT NULL,
+	last_run_at timestamp without time zone
+);
+CREATE TABLE _secret (
+	name text PRIMARY KEY,
+	key_id text NOT NULL,
+	sealed bytea NOT NULL,
+	updated_at timestamp without time zone NOT NULL
+);
+CREATE TABLE _webhook (
+	id text PRIMARY KEY,
+	url text NOT NULL,
+	secret text NOT NULL,
+	events jsonb NOT NULL,
+	record_types jsonb NOT NULL,
+	paused boolean NOT NULL DEFAULT FALSE,
+	disabled boolean NOT NULL DEFAULT FALSE,
+	created_at timestamp without time zone NOT NULL,
+	delivered integer NOT NULL DEFAULT 0,
+	failed integer NOT NULL DEFAULT 0,
+	consecutive_failures integer NOT NULL DEFAULT 0,
+	last_delivered_at timestamp without time zone,
+	last_failed_at timestamp without time zone,
+	last_error text NOT NULL DEFAULT '',
+	delta boolean NOT NULL DEFAULT FALSE
+);
+CREATE TABLE _maintenance (
+	id boolean PRIMARY KEY DEFAULT TRUE CHECK (id),
+	enabled boolean NOT NULL,
+	message text NOT NULL DEFAULT '',
+	updated_at timestamp without time zone NOT NULL,
+	updated_by text NOT NULL DEFAULT ''
+);
+CREATE TABLE _history (
+	id bigserial PRIMARY KEY,
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	action text NOT NULL,
+	data jsonb,
+	actor text,
+	created_at timestamp without time zone NOT NULL
+);
+CREATE INDEX _history_record_type_record_id_idx ON _history (record_type, record_id, database_id, id);
+CREATE TABLE _record_system_field_access (
+	record_type text PRIMARY KEY,
+	masks jsonb NOT NULL,
+	salt text NOT NULL
+);
+CREATE TABLE _schema_migration (
+	record_type text NOT NULL,
+	version text NOT NULL,
+	applied_at timestamp without time zone NOT NULL,
+	PRIMARY KEY (record_type, version)
+);
+CREATE TABLE _record_strict_schema (
+	record_type text PRIMARY KEY
+);
+CREATE TABLE _annotation (
+	id text PRIMARY KEY,
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	author_id text NOT NULL,
+	content text NOT NULL,
+	created_at timestamp without time zone NOT NULL
+);
+CREATE INDEX _annotation_record_type_record_id_idx ON _annotation (record_type, record_id, created_at);
+CREATE TABLE _reaction (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL DEFAULT '',
+	user_id text NOT NULL,
+	type text NOT NULL,
+	created_at timestamp without time zone NOT NULL,
+	PRIMARY KEY (record_type, record_id, database_id, user_id, type)
+);
+CREATE TABLE _reaction_count (
+	record_type text NOT NULL,
+	record_id text NOT NULL,
+	database_id text NOT NULL,
+	type text NOT NULL,
+	count bigint NOT NULL,
+	PRIMARY KEY (record_type, record_id, database_id, type)
+);
+CREATE TABLE _role_inheritance (
+	role_id text REFERENCES _role (id) NOT NULL,
+	inherited_role_id text REFERENCES _role (id) NOT NULL,
+	PRIMARY KEY (role_id, inherited_role_id)
+);
+CREATE TABLE _unread_count (
+	user_id text NOT NULL,
+	channel text NOT NULL,
+	count bigint NOT NULL,
+	PRIMARY KEY (user_id, channel)
+);
+CREATE TABLE _service_account (
+	id text PRIMARY KEY REFERENCES _auth (id) ON DELETE CASCADE,
+	name text NOT NULL,
+	hashed_secret bytea,
+	public_key text NOT NULL DEFAULT '',
+	created_at timestamp without time zone NOT NULL,
+	last_used_at timestamp without time zone
+);
 CREATE TABLE "user" (
     _id text,
     _database_id text,
@@ -140,8 +293,11 @@ CREATE TABLE "user" (
     _created_by text,
     _updated_at timestamp without time zone NOT NULL,
     _updated_by text,
+    _deleted_at timestamp without time zone,
+    _rev bigint NOT NULL DEFAULT 1,
     username citext,
     email citext,
+    phone citext,
     last_login_at timestamp without time zone,
     PRIMARY KEY(_id, _database_id, _owner_id),
     UNIQUE (_id)
@@ -180,6 +336,16 @@ INSERT INTO _record_field_access
   (record_type, record_field, user_role, writable, readable, comparable, discoverable)
 VALUES
   ('user', 'email', '_owner', 'TRUE', 'TRUE', 'TRUE', 'TRUE');
+
+INSERT INTO _record_field_access
+  (record_type, record_field, user_role, writable, readable, comparable, discoverable)
+VALUES
+  ('user', 'phone', '_any_user', 'FALSE', 'FALSE', 'FALSE', 'TRUE');
+
+INSERT INTO _record_field_access
+  (record_type, record_field, user_role, writable, readable, comparable, discoverable)
+VALUES
+  ('user', 'phone', '_owner', 'TRUE', 'TRUE', 'TRUE', 'TRUE');
 `
 	_, err := tx.Exec(stmt)
 	return err
@@ -208,6 +374,10 @@ func (r *fullMigration) Up(tx *sqlx.Tx) error {
 		return err
 	}
 
+	if _, err = tx.Exec(recordPatchFunctions); err != nil {
+		return err
+	}
+
 	if err = r.insertSeedData(tx); err != nil {
 		return err
 	}
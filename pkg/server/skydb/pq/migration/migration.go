@@ -157,6 +157,14 @@ func EnsureLatest(db *sqlx.DB, schema string, allowMigration bool) error {
 
 	log.Infof(`Database schema requires migration.`)
 
+	if versionNum != "" {
+		revs := findRevisions(versionNum, full.Version())
+		if err := checkRunningServers(tx, schema, revs, full.Version()); err != nil {
+			log.Errorf(`Refusing schema migration: %v`, err)
+			return err
+		}
+	}
+
 	if err := executeSchemaMigrations(tx, schema, versionNum, full.Version(), false); err != nil {
 		return fmt.Errorf("skydb/pq: failed to init database: %v", err)
 	}
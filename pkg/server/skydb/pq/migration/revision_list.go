@@ -40,4 +40,27 @@ var revisions = []Revision{
 	&revision_83f549ff247b{},
 	&revision_81beb4d8658c{},
 	&revision_b55e91bc9391{},
+	&revision_7a3c19e4d2b6{},
+	&revision_5e2d8c4a1f93{},
+	&revision_9c41e7b2d0a5{},
+	&revision_e6b0f3a81c27{},
+	&revision_3f8a2c61d9e4{},
+	&revision_7b1d4e9a2f60{},
+	&revision_8d2e5f1b7c34{},
+	&revision_2e7c4b9f1a08{},
+	&revision_6f1d0c8a3b25{},
+	&revision_a4e8d2c6b931{},
+	&revision_5b9e3a7c2f14{},
+	&revision_c7e2a9d4b1f8{},
+	&revision_e4b7a1c9d3f2{},
+	&revision_9c3f6e2a1d57{},
+	&revision_3a8f1d6c9e42{},
+	&revision_b2d7e4f1a963{},
+	&revision_5d1a8c3e7b20{},
+	&revision_e7a94c2b6f18{},
+	&revision_4e1b7d9c2a86{},
+	&revision_a7c3e9f2b851{},
+	&revision_f1c8a4d7e293{},
+	&revision_3b7e5d91c4a2{},
+	&revision_c5e8a2d4f719{},
 }
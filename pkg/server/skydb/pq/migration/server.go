@@ -0,0 +1,146 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ServerTableName is the name of the table in which each running server
+// registers the schema version it expects.
+const ServerTableName = "_server"
+
+// ServerTimeout is the duration after which a server that has not
+// renewed its registration is considered stopped.
+var ServerTimeout = 90 * time.Second
+
+// Server is a server registered in the server table.
+type Server struct {
+	ID            string    `db:"id"`
+	Version       string    `db:"version"`
+	SchemaVersion string    `db:"schema_version"`
+	LastSeenAt    time.Time `db:"last_seen_at"`
+}
+
+// IncompatibleServersError is returned by EnsureLatest when the schema
+// migration contains a breaking revision while servers expecting the
+// current schema are still running.
+type IncompatibleServersError struct {
+	Revision string
+	Servers  []Server
+}
+
+func (e *IncompatibleServersError) Error() string {
+	versions := []string{}
+	for _, server := range e.Servers {
+		versions = append(versions, fmt.Sprintf("%s (schema %s)", server.Version, server.SchemaVersion))
+	}
+	return fmt.Sprintf(
+		`skydb/pq/migration: revision "%s" is not backward compatible and is refused while servers are running: %s`,
+		e.Revision, strings.Join(versions, ", "))
+}
+
+// CompatibleRevision is implemented by a Revision whose changes do not
+// break servers expecting the previous revision, such as adding a
+// nullable column or a new table.
+type CompatibleRevision interface {
+	Revision
+	IsBackwardCompatible() bool
+}
+
+func isBackwardCompatible(revision Revision) bool {
+	compatible, ok := revision.(CompatibleRevision)
+	return ok && compatible.IsBackwardCompatible()
+}
+
+func ensureServerTable(execer sqlx.Execer, schema string) error {
+	_, err := execer.Exec(fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s.%s (
+	id text PRIMARY KEY,
+	version text NOT NULL,
+	schema_version character varying(32) NOT NULL,
+	last_seen_at timestamp without time zone NOT NULL
+);`, schema, ServerTableName))
+	return err
+}
+
+// RegisterServer records that the server of the specified ID is running
+// and expects the latest schema. It is called periodically by each server
+// to renew its registration.
+func RegisterServer(db *sqlx.DB, schema string, id string, version string) error {
+	if err := ensureServerTable(db, schema); err != nil {
+		return err
+	}
+
+	full := &fullMigration{}
+	_, err := db.Exec(fmt.Sprintf(`
+INSERT INTO %s.%s (id, version, schema_version, last_seen_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE SET
+	version = EXCLUDED.version,
+	schema_version = EXCLUDED.schema_version,
+	last_seen_at = EXCLUDED.last_seen_at;`, schema, ServerTableName),
+		id, version, full.Version(), time.Now().UTC())
+	return err
+}
+
+// runningServers returns the servers that renewed their registration
+// within ServerTimeout and expect a schema other than the specified version.
+func runningServers(tx *sqlx.Tx, schema string, schemaVersion string) ([]Server, error) {
+	exists, err := tableExists(tx, schema, ServerTableName)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	servers := []Server{}
+	err = tx.Select(&servers, fmt.Sprintf(`
+SELECT id, version, schema_version, last_seen_at FROM %s.%s
+WHERE last_seen_at > $1 AND schema_version <> $2
+ORDER BY last_seen_at DESC;`, schema, ServerTableName),
+		time.Now().UTC().Add(-ServerTimeout), schemaVersion)
+	return servers, err
+}
+
+// checkRunningServers refuses revisions that are not backward compatible
+// while servers expecting another schema are running, so that a rolling
+// deploy does not break the servers it has yet to replace.
+func checkRunningServers(tx *sqlx.Tx, schema string, revs []Revision, target string) error {
+	var breaking Revision
+	for _, revision := range revs {
+		if !isBackwardCompatible(revision) {
+			breaking = revision
+			break
+		}
+	}
+	if breaking == nil {
+		return nil
+	}
+
+	servers, err := runningServers(tx, schema, target)
+	if err != nil {
+		return err
+	}
+	if len(servers) > 0 {
+		return &IncompatibleServersError{
+			Revision: breaking.Version(),
+			Servers:  servers,
+		}
+	}
+	return nil
+}
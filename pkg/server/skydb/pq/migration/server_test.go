@@ -0,0 +1,91 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type compatibleRevision struct {
+	naiveRevision
+}
+
+func (r *compatibleRevision) IsBackwardCompatible() bool { return true }
+
+func TestCheckRunningServers(t *testing.T) {
+	schema := testSchemaName()
+
+	Convey("Running servers", t, func() {
+		db := getTestDB(t)
+		defer cleanupDB(t, db, schema)
+
+		executeInTransaction(t, db, func(tx *sqlx.Tx) {
+			So(ensureSchema(tx, schema), ShouldBeNil)
+			So(tx.Commit(), ShouldBeNil)
+		})
+
+		So(RegisterServer(db, schema, "old-server", "v1.0.0"), ShouldBeNil)
+		_, err := db.Exec(fmt.Sprintf(`UPDATE %s.%s SET schema_version = 'version1'`, schema, ServerTableName))
+		So(err, ShouldBeNil)
+
+		breaking := &naiveRevision{VersionNum: "version2"}
+		compatible := &compatibleRevision{naiveRevision{VersionNum: "version2"}}
+
+		Convey("refuses breaking revision", func() {
+			executeInTransaction(t, db, func(tx *sqlx.Tx) {
+				err := checkRunningServers(tx, schema, []Revision{breaking}, "version2")
+				So(err, ShouldHaveSameTypeAs, &IncompatibleServersError{})
+
+				serversErr := err.(*IncompatibleServersError)
+				So(serversErr.Revision, ShouldEqual, "version2")
+				So(serversErr.Servers, ShouldHaveLength, 1)
+				So(serversErr.Servers[0].ID, ShouldEqual, "old-server")
+				So(serversErr.Servers[0].Version, ShouldEqual, "v1.0.0")
+			})
+		})
+
+		Convey("allows backward compatible revision", func() {
+			executeInTransaction(t, db, func(tx *sqlx.Tx) {
+				err := checkRunningServers(tx, schema, []Revision{compatible}, "version2")
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("ignores servers expecting the target schema", func() {
+			executeInTransaction(t, db, func(tx *sqlx.Tx) {
+				err := checkRunningServers(tx, schema, []Revision{breaking}, "version1")
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("ignores stopped servers", func() {
+			_, err := db.Exec(
+				fmt.Sprintf(`UPDATE %s.%s SET last_seen_at = $1`, schema, ServerTableName),
+				time.Now().UTC().Add(-2*ServerTimeout),
+			)
+			So(err, ShouldBeNil)
+
+			executeInTransaction(t, db, func(tx *sqlx.Tx) {
+				err := checkRunningServers(tx, schema, []Revision{breaking}, "version2")
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// patchValue is a patch of a field, which is applied in the database by
+// the patch functions created by the migration.
+type patchValue struct {
+	patch interface{}
+}
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  []string    `json:"path"`
+	From  []string    `json:"from,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+type textSplice struct {
+	Offset int    `json:"offset"`
+	Delete int    `json:"delete"`
+	Insert string `json:"insert"`
+}
+
+// Value encodes the patch as jsonb. The JSON pointers of a JSON Patch
+// are parsed into paths, as expected by the #> operator.
+func (v patchValue) Value() (driver.Value, error) {
+	switch patch := v.patch.(type) {
+	case skydb.MergePatch:
+		return json.Marshal(map[string]interface{}(patch))
+	case skydb.JSONPatch:
+		ops := make([]jsonPatchOperation, len(patch))
+		for i, op := range patch {
+			path, err := skydb.ParseJSONPointer(op.Path)
+			if err != nil {
+				return nil, err
+			}
+			ops[i] = jsonPatchOperation{Op: op.Op, Path: path, Value: op.Value}
+			if op.Op == "move" || op.Op == "copy" {
+				if ops[i].From, err = skydb.ParseJSONPointer(op.From); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return json.Marshal(ops)
+	case skydb.TextPatch:
+		splices := make([]textSplice, len(patch))
+		for i, splice := range patch {
+			splices[i] = textSplice(splice)
+		}
+		return json.Marshal(splices)
+	}
+	return nil, fmt.Errorf("unsupported patch %T", v.patch)
+}
+
+// patchWrapper returns a wrapper which applies the patch to the target,
+// which is the current value of the column, or NULL for a new record.
+func patchWrapper(target string, patch interface{}) func(string) string {
+	var function string
+	switch patch.(type) {
+	case skydb.MergePatch:
+		function = "public.json_merge_patch"
+	case skydb.JSONPatch:
+		function = "public.json_patch"
+	case skydb.TextPatch:
+		function = "public.text_patch"
+	}
+	return func(val string) string {
+		return fmt.Sprintf("%s(%s, %s::jsonb)", function, target, val)
+	}
+}
+
+// checkPatches returns an error if a field of the record is patched
+// with a patch of a different type from the column.
+func checkPatches(typemap skydb.RecordSchema, record *skydb.Record) error {
+	for key, value := range record.Data {
+		var dataType skydb.DataType
+		switch value.(type) {
+		case skydb.MergePatch, skydb.JSONPatch:
+			dataType = skydb.TypeJSON
+		case skydb.TextPatch:
+			dataType = skydb.TypeString
+		default:
+			continue
+		}
+
+		if fieldType, ok := typemap[key]; ok && fieldType.Type != dataType {
+			return skyerr.NewInvalidArgument(
+				fmt.Sprintf(`cannot patch field "%s" of type %s`, key, fieldType.ToSimpleName()),
+				[]string{key},
+			)
+		}
+	}
+	return nil
+}
+
+// hasPatches returns true if a field of the records is patched.
+func hasPatches(records []*skydb.Record) bool {
+	for _, record := range records {
+		for _, value := range record.Data {
+			switch value.(type) {
+			case skydb.JSONPatch, skydb.MergePatch, skydb.TextPatch:
+				return true
+			}
+		}
+	}
+	return false
+}
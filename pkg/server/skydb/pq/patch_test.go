@@ -0,0 +1,134 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestPatch(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content":  skydb.FieldType{Type: skydb.TypeString},
+			"settings": skydb.FieldType{Type: skydb.TypeJSON},
+			"number":   skydb.FieldType{Type: skydb.TypeNumber},
+		})
+		So(err, ShouldBeNil)
+
+		record := skydb.Record{
+			ID:      skydb.NewRecordID("note", "someid"),
+			OwnerID: "user_id",
+			Data: map[string]interface{}{
+				"content": "hello world",
+				"settings": map[string]interface{}{
+					"theme": "light",
+					"font":  "serif",
+					"tags":  []interface{}{"a", "b"},
+				},
+			},
+		}
+		So(db.Save(&record), ShouldBeNil)
+
+		Convey("merges merge patch into json field", func() {
+			record.Set("settings", skydb.MergePatch{"theme": "dark", "font": nil})
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Get("settings"), ShouldResemble, map[string]interface{}{
+				"theme": "dark",
+				"tags":  []interface{}{"a", "b"},
+			})
+		})
+
+		Convey("applies json patch to json field", func() {
+			record.Set("settings", skydb.JSONPatch{
+				{Op: "test", Path: "/theme", Value: "light"},
+				{Op: "add", Path: "/tags/-", Value: "c"},
+				{Op: "add", Path: "/tags/0", Value: "z"},
+				{Op: "remove", Path: "/font"},
+				{Op: "move", From: "/theme", Path: "/mode"},
+			})
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Get("settings"), ShouldResemble, map[string]interface{}{
+				"mode": "light",
+				"tags": []interface{}{"z", "a", "b", "c"},
+			})
+		})
+
+		Convey("rejects json patch failing test", func() {
+			record.Set("settings", skydb.JSONPatch{
+				{Op: "test", Path: "/theme", Value: "dark"},
+				{Op: "replace", Path: "/theme", Value: "light"},
+			})
+			err := db.Save(&record)
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+
+		Convey("splices text field", func() {
+			record.Set("content", skydb.TextPatch{
+				{Offset: 6, Delete: 5, Insert: "there"},
+				{Offset: 0, Delete: 0, Insert: "oh, "},
+			})
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Get("content"), ShouldEqual, "oh, hello there")
+		})
+
+		Convey("patches fields of new record", func() {
+			newRecord := skydb.Record{
+				ID:      skydb.NewRecordID("note", "newid"),
+				OwnerID: "user_id",
+				Data: map[string]interface{}{
+					"content":  skydb.TextPatch{{Insert: "hello"}},
+					"settings": skydb.MergePatch{"theme": "dark"},
+				},
+			}
+			So(db.Save(&newRecord), ShouldBeNil)
+			So(newRecord.Get("content"), ShouldEqual, "hello")
+			So(newRecord.Get("settings"), ShouldResemble, map[string]interface{}{"theme": "dark"})
+		})
+
+		Convey("patches fields with merge", func() {
+			mergeRecord := skydb.Record{
+				ID: skydb.NewRecordID("note", "someid"),
+				Data: map[string]interface{}{
+					"content":  skydb.TextPatch{{Offset: 11, Insert: "!"}},
+					"settings": skydb.JSONPatch{{Op: "replace", Path: "/tags/1", Value: "x"}},
+				},
+			}
+			So(db.Merge(&mergeRecord), ShouldBeNil)
+			So(mergeRecord.Get("content"), ShouldEqual, "hello world!")
+			So(mergeRecord.Get("settings"), ShouldResemble, map[string]interface{}{
+				"theme": "light",
+				"font":  "serif",
+				"tags":  []interface{}{"a", "x"},
+			})
+		})
+
+		Convey("rejects patch of field of other type", func() {
+			record.Set("number", skydb.TextPatch{{Insert: "1"}})
+			err := db.Save(&record)
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
+	})
+}
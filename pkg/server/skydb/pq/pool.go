@@ -0,0 +1,101 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// PoolConfig configures the connection pools of databases. Each app has
+// its own pools, so that an app exhausting its connections does not
+// starve the other apps.
+type PoolConfig struct {
+	// MaxOpenConns is the maximum number of open connections of a pool.
+	// Zero means unlimited.
+	MaxOpenConns int
+
+	// MaxIdleConns is the maximum number of idle connections kept by a
+	// pool. Zero means no idle connections are kept.
+	MaxIdleConns int
+
+	// ConnMaxLifetime is the maximum time a connection may be reused.
+	// Zero means connections are reused forever.
+	ConnMaxLifetime time.Duration
+
+	// StatementTimeout aborts statements running longer than it on the
+	// database server. Zero means no timeout.
+	StatementTimeout time.Duration
+}
+
+// poolConfig is the configuration of connection pools opened afterwards.
+var poolConfig = PoolConfig{
+	MaxOpenConns: 10,
+	MaxIdleConns: 2,
+}
+
+// SetPoolConfig sets the configuration of connection pools. It only
+// affects databases opened after the call, and should be called before
+// opening any connection.
+func SetPoolConfig(config PoolConfig) {
+	poolConfig = config
+}
+
+// poolKey returns the key of the connection pool of the app to the
+// database of the connection string.
+func poolKey(appName, connString string) string {
+	return appName + "\x00" + connString
+}
+
+// openPool opens a connection pool to the database of the connection
+// string as configured by poolConfig.
+func openPool(connString string) (*sqlx.DB, error) {
+	connString, err := withStatementTimeout(connString, poolConfig.StatementTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sqlx.Open("postgres", connString)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(poolConfig.MaxOpenConns)
+	db.SetMaxIdleConns(poolConfig.MaxIdleConns)
+	db.SetConnMaxLifetime(poolConfig.ConnMaxLifetime)
+	return db, nil
+}
+
+// withStatementTimeout returns the connection string setting the
+// statement_timeout run-time parameter of connections. A URL is converted
+// to the key-value form to add the parameter.
+func withStatementTimeout(connString string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return connString, nil
+	}
+
+	if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
+		var err error
+		if connString, err = pq.ParseURL(connString); err != nil {
+			return "", err
+		}
+	}
+	param := fmt.Sprintf("statement_timeout=%d", timeout/time.Millisecond)
+	return strings.TrimSpace(connString + " " + param), nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithStatementTimeout(t *testing.T) {
+	Convey("withStatementTimeout", t, func() {
+		Convey("keeps the connection string without timeout", func() {
+			connString, err := withStatementTimeout("postgres://localhost/db", 0)
+			So(err, ShouldBeNil)
+			So(connString, ShouldEqual, "postgres://localhost/db")
+		})
+
+		Convey("adds the parameter to key-value connection strings", func() {
+			connString, err := withStatementTimeout("dbname=db sslmode=disable", 30*time.Second)
+			So(err, ShouldBeNil)
+			So(connString, ShouldEqual, "dbname=db sslmode=disable statement_timeout=30000")
+
+			connString, err = withStatementTimeout("", time.Second)
+			So(err, ShouldBeNil)
+			So(connString, ShouldEqual, "statement_timeout=1000")
+		})
+
+		Convey("converts URLs to add the parameter", func() {
+			connString, err := withStatementTimeout("postgres://localhost/db?sslmode=disable", time.Second)
+			So(err, ShouldBeNil)
+			So(connString, ShouldEqual, "dbname=db host=localhost sslmode=disable statement_timeout=1000")
+		})
+	})
+}
+
+func TestPoolConfig(t *testing.T) {
+	Convey("openPool", t, func() {
+		defer SetPoolConfig(poolConfig)
+		SetPoolConfig(PoolConfig{MaxOpenConns: 3, MaxIdleConns: 1})
+
+		db, err := openPool("dbname=db")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(db.Stats().MaxOpenConnections, ShouldEqual, 3)
+	})
+
+	Convey("poolKey isolates apps", t, func() {
+		So(poolKey("app1", "dbname=db"), ShouldNotEqual, poolKey("app2", "dbname=db"))
+	})
+}
@@ -0,0 +1,97 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) RebalancePositions(recordType string, field string, maxLength int) (bool, error) {
+	table := c.tableName(recordType)
+	column := pq.QuoteIdentifier(field)
+
+	var length sql.NullInt64
+	err := c.QueryRowx(fmt.Sprintf(`SELECT max(length(%s)) FROM %s`, column, table)).
+		Scan(&length)
+	if err != nil {
+		return false, err
+	}
+	if !length.Valid || int(length.Int64) <= maxLength {
+		return false, nil
+	}
+
+	if err := c.Begin(); err != nil {
+		return false, err
+	}
+	if err := c.rebalancePositions(table, column); err != nil {
+		c.Rollback()
+		return false, err
+	}
+	c.invalidateQueryCache(recordType)
+	if err := c.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *conn) rebalancePositions(table string, column string) error {
+	// Records cannot be saved while positions are replaced, otherwise
+	// a position saved concurrently may be sorted at the wrong place.
+	if _, err := c.Exec(fmt.Sprintf(`LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE`, table)); err != nil {
+		return err
+	}
+
+	rows, err := c.Queryx(fmt.Sprintf(
+		`SELECT "_id", "_database_id", "_owner_id" FROM %s WHERE %s IS NOT NULL ORDER BY %s, "_id"`,
+		table, column, column))
+	if err != nil {
+		return err
+	}
+
+	type recordKey struct {
+		id         string
+		databaseID string
+		ownerID    string
+	}
+	keys := []recordKey{}
+	for rows.Next() {
+		key := recordKey{}
+		if err := rows.Scan(&key.id, &key.databaseID, &key.ownerID); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	positions := skydb.EvenPositions(len(keys))
+	for i, key := range keys {
+		builder := psql.Update(table).
+			Set(column, positions[i]).
+			Where(`"_id" = ? AND "_database_id" = ? AND "_owner_id" = ?`,
+				key.id, key.databaseID, key.ownerID)
+		if _, err := c.ExecWith(builder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestPosition(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("item", skydb.RecordSchema{
+			"position": skydb.FieldType{Type: skydb.TypePosition},
+		})
+		So(err, ShouldBeNil)
+
+		saveItem := func(id string, position string) {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("item", id),
+				OwnerID: "user",
+				Data: map[string]interface{}{
+					"position": position,
+				},
+			}), ShouldBeNil)
+		}
+
+		queryItemIDs := func() []string {
+			rows, err := db.Query(&skydb.Query{
+				Type: "item",
+				Sorts: []skydb.Sort{
+					{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "position",
+						},
+						Order: skydb.Ascending,
+					},
+				},
+			})
+			So(err, ShouldBeNil)
+			records, err := exhaustRows(rows, err)
+			So(err, ShouldBeNil)
+
+			ids := []string{}
+			for _, record := range records {
+				ids = append(ids, record.ID.Key)
+			}
+			return ids
+		}
+
+		Convey("reads position field from schema", func() {
+			schema, err := db.RemoteColumnTypes("item")
+			So(err, ShouldBeNil)
+			So(schema["position"].Type, ShouldEqual, skydb.TypePosition)
+		})
+
+		Convey("sorts positions by byte values", func() {
+			saveItem("lower", "a")
+			saveItem("upper", "B")
+			saveItem("digit", "1")
+			So(queryItemIDs(), ShouldResemble, []string{"digit", "upper", "lower"})
+		})
+
+		Convey("rejects invalid position", func() {
+			err := db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("item", "1"),
+				OwnerID: "user",
+				Data: map[string]interface{}{
+					"position": "V0",
+				},
+			})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rebalances long positions", func() {
+			saveItem("1", "V")
+			saveItem("2", "VVVVVV")
+			saveItem("3", "VVVVVVV")
+
+			rebalanced, err := c.RebalancePositions("item", "position", 4)
+			So(err, ShouldBeNil)
+			So(rebalanced, ShouldBeTrue)
+			So(queryItemIDs(), ShouldResemble, []string{"1", "2", "3"})
+
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("item", "3"), &record), ShouldBeNil)
+			So(record.Data["position"], ShouldEqual, skydb.EvenPositions(3)[2])
+		})
+
+		Convey("does not rebalance short positions", func() {
+			saveItem("1", "V")
+
+			rebalanced, err := c.RebalancePositions("item", "position", 4)
+			So(err, ShouldBeNil)
+			So(rebalanced, ShouldBeFalse)
+		})
+	})
+}
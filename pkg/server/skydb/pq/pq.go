@@ -20,6 +20,7 @@ import (
 	"net"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
@@ -27,6 +28,8 @@ import (
 	"github.com/skygeario/skygear-server/pkg/server/logging"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
 	"github.com/skygeario/skygear-server/pkg/server/skydb/pq/migration"
+	"github.com/skygeario/skygear-server/pkg/server/skyversion"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
 )
 
 var log = logging.LoggerEntry("skydb")
@@ -53,6 +56,11 @@ func isUniqueViolated(err error) bool {
 	return false
 }
 
+func isNotNullViolated(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23502"
+}
+
 func isInvalidInputSyntax(err error) bool {
 	pqErr, ok := err.(*pq.Error)
 	return ok && (pqErr.Code == "22P02" || pqErr.Code == "22P03")
@@ -66,6 +74,11 @@ func isUndefinedTable(err error) bool {
 	return false
 }
 
+func isDuplicateTable(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "42P07"
+}
+
 func isNetworkError(err error) bool {
 	_, ok := err.(*net.OpError)
 	return ok
@@ -73,7 +86,7 @@ func isNetworkError(err error) bool {
 
 // Open returns a new connection to postgresql implementation
 func Open(ctx context.Context, appName string, accessModel skydb.AccessModel, connString string, migrate bool) (skydb.Conn, error) {
-	db, err := getDB(appName, connString, migrate)
+	db, stmtCache, replicas, err := getDB(appName, connString, migrate)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +96,8 @@ func Open(ctx context.Context, appName string, accessModel skydb.AccessModel, co
 
 	return &conn{
 		db:           db,
+		stmtCache:    stmtCache,
+		replicas:     replicas,
 		RecordSchema: map[string]skydb.RecordSchema{},
 		appName:      appName,
 		option:       connString,
@@ -100,45 +115,78 @@ type getDBReq struct {
 }
 
 type getDBResp struct {
-	db  *sqlx.DB
-	err error
+	db        *sqlx.DB
+	stmtCache *stmtCache
+	replicas  []*replica
+	err       error
 }
 
 var dbs = map[string]*sqlx.DB{}
+var stmtCaches = map[string]*stmtCache{}
+var replicaDBs = map[string]*replica{}
 var getDBChan = make(chan getDBReq)
 
-func getDB(appName, connString string, migrate bool) (*sqlx.DB, error) {
+func getDB(appName, connString string, migrate bool) (*sqlx.DB, *stmtCache, []*replica, error) {
 	ch := make(chan getDBResp)
 	getDBChan <- getDBReq{appName, connString, migrate, ch}
 	resp := <-ch
-	return resp.db, resp.err
+	return resp.db, resp.stmtCache, resp.replicas, resp.err
+}
+
+// getReplicas returns the replicas of replicaOptions for the app, opening
+// those not opened yet. It is only called by dbInitializer.
+func getReplicas(appName string) ([]*replica, error) {
+	results := []*replica{}
+	for _, option := range replicaOptions {
+		key := poolKey(appName, option)
+		r, ok := replicaDBs[key]
+		if !ok {
+			var err error
+			if r, err = openReplica(option); err != nil {
+				return nil, fmt.Errorf("failed to open replica connection: %s", err)
+			}
+			replicaDBs[key] = r
+		}
+		results = append(results, r)
+	}
+	return results, nil
 }
 
-// goroutine that initialize the database for use
+// goroutine that initialize the database for use. Each app has its own
+// connection pool to the database.
 func dbInitializer() {
 	for {
 		req := <-getDBChan
-		db, ok := dbs[req.connString]
+		key := poolKey(req.appName, req.connString)
+		db, ok := dbs[key]
 		if !ok {
 			var err error
-			db, err = sqlx.Open("postgres", req.connString)
+			db, err = openPool(req.connString)
 			if err != nil {
-				req.done <- getDBResp{nil, fmt.Errorf("failed to open connection: %s", err)}
+				req.done <- getDBResp{nil, nil, nil, fmt.Errorf("failed to open connection: %s", err)}
 				continue
 			}
 
-			db.SetMaxOpenConns(10)
-
 			if err := mustInitDB(db, req.appName, req.migrate); err != nil {
 				db.Close()
-				req.done <- getDBResp{nil, fmt.Errorf("failed to open connection: %s", err)}
+				req.done <- getDBResp{nil, nil, nil, fmt.Errorf("failed to open connection: %s", err)}
 				continue
 			}
 
-			dbs[req.connString] = db
+			dbs[key] = db
+			if statementCacheSize > 0 {
+				stmtCaches[key] = newStmtCache(db, statementCacheSize)
+			}
+			go registerServer(db, req.appName)
+		}
+
+		replicas, err := getReplicas(req.appName)
+		if err != nil {
+			req.done <- getDBResp{nil, nil, nil, err}
+			continue
 		}
 
-		req.done <- getDBResp{db, nil}
+		req.done <- getDBResp{db, stmtCaches[key], replicas, nil}
 	}
 }
 
@@ -161,6 +209,23 @@ func mustInitDB(db *sqlx.DB, appName string, migrate bool) error {
 	return nil
 }
 
+// serverID identifies this process in the server table.
+var serverID = uuid.New()
+
+// registerServer renews the registration of this server periodically, so
+// that schema migrations started by other servers know which schema
+// this server expects.
+func registerServer(db *sqlx.DB, appName string) {
+	schema := "app_" + toLowerAndUnderscore(appName)
+	for {
+		err := migration.RegisterServer(db, schema, serverID, skyversion.Version())
+		if err != nil {
+			log.Warnf("Failed to register server in schema %s: %v", schema, err)
+		}
+		time.Sleep(migration.ServerTimeout / 3)
+	}
+}
+
 func init() {
 	skydb.Register("pq", skydb.DriverFunc(Open))
 	go dbInitializer()
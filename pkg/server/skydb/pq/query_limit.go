@@ -0,0 +1,27 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"github.com/skygeario/skygear-server/pkg/server/skydb/pq/builder"
+)
+
+// SetMaxQueryJoins sets the maximum number of referenced record types
+// joined by a query, such as by a predicate on the key path
+// `author.city.name`. Queries joining more record types are rejected
+// with skyerr.RecordQueryTooComplex. A limit of zero means unlimited.
+func SetMaxQueryJoins(joins int) {
+	builder.SetMaxJoins(joins)
+}
@@ -0,0 +1,128 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"io"
+
+	sq "github.com/lann/squirrel"
+
+	"github.com/skygeario/skygear-server/pkg/server/querycache"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/pq/builder"
+)
+
+// queryCache caches the results of queries keyed by the generated SQL
+// and arguments. Queries are not cached if it is nil.
+var queryCache *querycache.Cache
+
+// SetQueryCache sets the cache of query results. It should be called
+// before opening any connection.
+func SetQueryCache(cache *querycache.Cache) {
+	queryCache = cache
+}
+
+// queryCacheKey returns the key of the cached result of the query, or an
+// empty string if the query is not cached.
+//
+// Queries in a transaction are not cached since they may read changes not
+// yet committed. Queries with included records, transient fields, counts
+// or keyset pagination are not cached either, because only records are
+// kept in the cache.
+//
+// Besides the queried record types, the key depends on the user relations
+// referenced by the query, which are invalidated when relations are added
+// or removed.
+func (db *database) queryCacheKey(q sq.SelectBuilder, factory builder.PredicateSqlizerFactory, query *skydb.Query, includes map[string]includedColumn) string {
+	if queryCache == nil || db.c.tx != nil {
+		return ""
+	}
+	if query.Explain || query.GetCount || query.IsPaginatedByKeyset() || query.AsOf != nil ||
+		len(query.ComputedKeys) > 0 || len(includes) > 0 {
+		return ""
+	}
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return ""
+	}
+	recordTypes := append([]string{query.Type}, factory.JoinedRecordTypes()...)
+	recordTypes = append(recordTypes, factory.RelationNames()...)
+	key, err := queryCache.Key(recordTypes, sql, args)
+	if err != nil {
+		log.WithError(err).Warnln("Failed to get key of query cache")
+		return ""
+	}
+	return key
+}
+
+// invalidateQueryCache invalidates the cached results of queries on the
+// record type. In a transaction, the record type is invalidated again when
+// the transaction is committed, so that results cached by other
+// connections before the commit are not kept.
+func (c *conn) invalidateQueryCache(recordType string) {
+	if queryCache == nil {
+		return
+	}
+
+	queryCache.Invalidate(recordType)
+	if c.tx != nil {
+		if c.staleRecordTypes == nil {
+			c.staleRecordTypes = map[string]bool{}
+		}
+		c.staleRecordTypes[recordType] = true
+	}
+}
+
+// commitQueryCache invalidates the record types changed in the committed
+// transaction.
+func (c *conn) commitQueryCache() {
+	for recordType := range c.staleRecordTypes {
+		queryCache.Invalidate(recordType)
+	}
+	c.staleRecordTypes = nil
+}
+
+// readAllRecords reads all records of the iterator to be cached.
+func readAllRecords(iter skydb.RowsIter) ([]skydb.Record, error) {
+	defer iter.Close()
+
+	records := []skydb.Record{}
+	for {
+		record := skydb.Record{}
+		err := iter.Next(&record)
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// cachedRowsIter iterates records of a cached query result. Unlike
+// skydb.MemoryRows, it does not tell the overall record count, which is
+// not the number of records returned by a query with limit or offset.
+type cachedRowsIter struct {
+	*skydb.MemoryRows
+}
+
+func (rowsi cachedRowsIter) OverallRecordCount() *uint64 {
+	return nil
+}
+
+func newCachedRows(records []skydb.Record) *skydb.Rows {
+	return skydb.NewRows(cachedRowsIter{skydb.NewMemoryRows(records)})
+}
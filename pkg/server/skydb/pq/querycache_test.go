@@ -0,0 +1,144 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/querycache"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestQueryCache(t *testing.T) {
+	Convey("Database with query cache", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		SetQueryCache(querycache.New(querycache.NewMemoryStore(), "test", time.Minute))
+		defer SetQueryCache(nil)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		saveNote := func(key string, content string) {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("note", key),
+				OwnerID: "user",
+				Data:    map[string]interface{}{"content": content},
+			}), ShouldBeNil)
+		}
+		queryRecords := func(query skydb.Query) []skydb.Record {
+			query.BypassAccessControl = true
+			rows, err := db.Query(&query)
+			So(err, ShouldBeNil)
+			defer rows.Close()
+
+			records := []skydb.Record{}
+			for rows.Scan() {
+				records = append(records, rows.Record())
+			}
+			So(rows.Err(), ShouldBeNil)
+			return records
+		}
+		queryNotes := func() []skydb.Record {
+			return queryRecords(skydb.Query{Type: "note"})
+		}
+
+		saveNote("1", "first")
+		So(queryNotes(), ShouldHaveLength, 1)
+
+		Convey("returns cached result", func() {
+			_, err := c.Exec(`UPDATE "note" SET "content" = 'changed'`)
+			So(err, ShouldBeNil)
+
+			records := queryNotes()
+			So(records, ShouldHaveLength, 1)
+			So(records[0].Data["content"], ShouldEqual, "first")
+		})
+
+		Convey("invalidates cached result on save", func() {
+			saveNote("2", "second")
+			So(queryNotes(), ShouldHaveLength, 2)
+		})
+
+		Convey("invalidates cached result on delete", func() {
+			So(db.Delete(skydb.NewRecordID("note", "1")), ShouldBeNil)
+			So(queryNotes(), ShouldBeEmpty)
+		})
+
+		Convey("invalidates cached result on commit", func() {
+			So(c.Begin(), ShouldBeNil)
+			saveNote("2", "second")
+			So(c.Commit(), ShouldBeNil)
+			So(queryNotes(), ShouldHaveLength, 2)
+		})
+
+		Convey("invalidates cached result on rebalancing positions", func() {
+			_, err := db.Extend("item", skydb.RecordSchema{
+				"position": skydb.FieldType{Type: skydb.TypePosition},
+			})
+			So(err, ShouldBeNil)
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("item", "1"),
+				OwnerID: "user",
+				Data:    map[string]interface{}{"position": "VVVVVV"},
+			}), ShouldBeNil)
+			So(queryRecords(skydb.Query{Type: "item"})[0].Data["position"], ShouldEqual, "VVVVVV")
+
+			rebalanced, err := c.RebalancePositions("item", "position", 4)
+			So(err, ShouldBeNil)
+			So(rebalanced, ShouldBeTrue)
+			So(queryRecords(skydb.Query{Type: "item"})[0].Data["position"], ShouldEqual, skydb.EvenPositions(1)[0])
+		})
+
+		Convey("invalidates cached result of user relation query", func() {
+			addUser(t, c, "user1")
+			addUser(t, c, "user2")
+			saveOwnedNote := func(key string, ownerID string) {
+				So(db.Save(&skydb.Record{
+					ID:      skydb.NewRecordID("note", key),
+					OwnerID: ownerID,
+				}), ShouldBeNil)
+			}
+			saveOwnedNote("2", "user2")
+			queryFriendNotes := func() []skydb.Record {
+				return queryRecords(skydb.Query{
+					Type: "note",
+					Predicate: skydb.Predicate{
+						Operator: skydb.Functional,
+						Children: []interface{}{
+							skydb.Expression{
+								Type:  skydb.Function,
+								Value: skydb.UserRelationFunc{"_owner", "_friend", "outward", "user1"},
+							},
+						},
+					},
+				})
+			}
+
+			So(queryFriendNotes(), ShouldBeEmpty)
+			So(c.AddRelation("user1", "_friend", "user2"), ShouldBeNil)
+			So(queryFriendNotes(), ShouldHaveLength, 1)
+			So(c.RemoveRelation("user1", "_friend", "user2"), ShouldBeNil)
+			So(queryFriendNotes(), ShouldBeEmpty)
+		})
+	})
+}
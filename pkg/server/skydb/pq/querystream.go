@@ -0,0 +1,208 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// queryStreamBatchSize is the number of rows fetched from the cursor of a
+// streaming query at a time.
+var queryStreamBatchSize = 1000
+
+// queryStreamCursorSeq numbers the cursors of streaming queries, so that
+// cursors declared in the same transaction have distinct names.
+var queryStreamCursorSeq uint64
+
+// QueryStream declares a server-side cursor of the query and fetches the
+// records from the cursor in batches while the rows are iterated.
+//
+// A cursor only lives in a transaction, so a read-only transaction is
+// begun for the cursor if the connection is not in one. It is ended when
+// the rows are closed.
+func (db *database) QueryStream(query *skydb.Query) (*skydb.Rows, error) {
+	if query.Type == "" {
+		return nil, errors.New("got empty query type")
+	}
+
+	if len(query.Includes) > 0 || query.PageSize > 0 || query.GetCount {
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"includes, page size and count cannot be used with streaming query")
+	}
+
+	typemap, err := db.RemoteColumnTypes(query.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(typemap) == 0 { // record type has not been created
+		return skydb.EmptyRows, nil
+	}
+
+	sel, err := db.selectRecordQuery(query, typemap)
+	if err != nil {
+		return nil, err
+	}
+
+	selectSQL, args, err := sel.builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	iter := &streamRowsIter{
+		ctx:        db.c.context,
+		tx:         db.c.tx,
+		cursor:     fmt.Sprintf("_skygear_stream_%d", atomic.AddUint64(&queryStreamCursorSeq, 1)),
+		recordType: query.Type,
+		typemap:    sel.typemap,
+	}
+	if iter.tx == nil {
+		iter.tx, err = db.c.db.BeginTxx(db.c.context, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		iter.ownTx = true
+	}
+
+	declareSQL := fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", pq.QuoteIdentifier(iter.cursor), selectSQL)
+	db.c.statementCount++
+	_, err = iter.tx.ExecContext(iter.ctx, declareSQL, args...)
+	logFields := logrus.Fields{
+		"sql":            declareSQL,
+		"args":           args,
+		"error":          err,
+		"executionCount": db.c.statementCount,
+	}
+	if err != nil {
+		log.WithFields(logFields).Errorln("Failed to declare cursor of streaming query")
+		if iter.ownTx {
+			iter.tx.Rollback()
+		}
+		return nil, err
+	}
+	log.WithFields(logFields).Debugln("Declared cursor of streaming query")
+
+	return skydb.NewRows(iter), nil
+}
+
+// streamRowsIter iterates the records of a streaming query by fetching
+// them from its cursor in batches.
+type streamRowsIter struct {
+	ctx        context.Context
+	tx         *sqlx.Tx
+	ownTx      bool
+	cursor     string
+	recordType string
+	typemap    skydb.RecordSchema
+
+	rows    *sqlx.Rows
+	rs      *recordScanner
+	fetched int
+	done    bool
+}
+
+func (iter *streamRowsIter) fetch() error {
+	query := fmt.Sprintf("FETCH %d FROM %s", queryStreamBatchSize, pq.QuoteIdentifier(iter.cursor))
+	rows, err := iter.tx.QueryxContext(iter.ctx, query)
+	if err != nil {
+		return err
+	}
+
+	iter.rows = rows
+	iter.fetched = 0
+	if iter.rs == nil {
+		iter.rs = newRecordScanner(iter.recordType, iter.typemap, rows)
+	} else {
+		// every batch has the same columns
+		iter.rs.cs = rows
+	}
+	return nil
+}
+
+func (iter *streamRowsIter) Next(record *skydb.Record) error {
+	for {
+		if iter.rows == nil {
+			if iter.done {
+				return io.EOF
+			}
+			if err := iter.fetch(); err != nil {
+				return err
+			}
+		}
+
+		if iter.rows.Next() {
+			iter.fetched++
+			return iter.rs.Scan(record)
+		}
+
+		err := iter.rows.Err()
+		iter.rows.Close()
+		iter.rows = nil
+		if err != nil {
+			return err
+		}
+
+		// a batch with fewer rows than requested is the last one
+		if iter.fetched < queryStreamBatchSize {
+			iter.done = true
+		}
+	}
+}
+
+func (iter *streamRowsIter) Close() error {
+	if iter.rows != nil {
+		iter.rows.Close()
+		iter.rows = nil
+	}
+	iter.done = true
+
+	if iter.tx == nil {
+		return nil
+	}
+	tx := iter.tx
+	iter.tx = nil
+
+	// The transaction begun for the cursor is read-only, rolling back
+	// closes the cursor as well.
+	if iter.ownTx {
+		return tx.Rollback()
+	}
+	_, err := tx.ExecContext(iter.ctx, "CLOSE "+pq.QuoteIdentifier(iter.cursor))
+	return err
+}
+
+func (iter *streamRowsIter) OverallRecordCount() *uint64 {
+	return nil
+}
+
+func (iter *streamRowsIter) IncludedRecords() map[string]map[string]*skydb.Record {
+	return nil
+}
+
+func (iter *streamRowsIter) NextCursor() *skydb.Cursor {
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestQueryStream(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		originalBatchSize := queryStreamBatchSize
+		queryStreamBatchSize = 2
+		defer func() {
+			queryStreamBatchSize = originalBatchSize
+		}()
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"order": skydb.FieldType{Type: skydb.TypeNumber},
+		})
+		So(err, ShouldBeNil)
+
+		for i := 0; i < 5; i++ {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("note", fmt.Sprintf("%d", i)),
+				OwnerID: "user",
+				Data:    map[string]interface{}{"order": float64(i)},
+			}), ShouldBeNil)
+		}
+
+		query := &skydb.Query{
+			Type: "note",
+			Sorts: []skydb.Sort{
+				{
+					Expression: skydb.Expression{
+						Type:  skydb.KeyPath,
+						Value: "order",
+					},
+					Order: skydb.Descending,
+				},
+			},
+			BypassAccessControl: true,
+		}
+
+		streamNotes := func() []string {
+			rows, err := db.QueryStream(query)
+			So(err, ShouldBeNil)
+			defer rows.Close()
+
+			keys := []string{}
+			for rows.Scan() {
+				keys = append(keys, rows.Record().ID.Key)
+			}
+			So(rows.Err(), ShouldBeNil)
+			return keys
+		}
+
+		Convey("streams records in batches", func() {
+			So(streamNotes(), ShouldResemble, []string{"4", "3", "2", "1", "0"})
+		})
+
+		Convey("streams records matching predicate", func() {
+			query.Predicate = skydb.Predicate{
+				Operator: skydb.LessThan,
+				Children: []interface{}{
+					skydb.Expression{Type: skydb.KeyPath, Value: "order"},
+					skydb.Expression{Type: skydb.Literal, Value: float64(2)},
+				},
+			}
+			So(streamNotes(), ShouldResemble, []string{"1", "0"})
+		})
+
+		Convey("streams records in transaction", func() {
+			So(c.Begin(), ShouldBeNil)
+			So(streamNotes(), ShouldHaveLength, 5)
+			So(streamNotes(), ShouldHaveLength, 5)
+			So(c.Commit(), ShouldBeNil)
+		})
+
+		Convey("returns no records of nonexistent record type", func() {
+			query.Type = "nonexistent"
+			So(streamNotes(), ShouldBeEmpty)
+		})
+
+		Convey("rejects query with page size", func() {
+			query.PageSize = 2
+			_, err := db.QueryStream(query)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
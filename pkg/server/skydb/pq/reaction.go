@@ -0,0 +1,156 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql"
+	"fmt"
+
+	sq "github.com/lann/squirrel"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// AddReaction inserts the reaction and increments the reaction count in
+// a single statement, so that the count is incremented only if the
+// reaction row is actually inserted.
+func (c *conn) AddReaction(reaction *skydb.Reaction) (bool, error) {
+	stmt := fmt.Sprintf(`
+WITH inserted AS (
+	INSERT INTO %s (record_type, record_id, database_id, user_id, type, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (record_type, record_id, database_id, user_id, type) DO NOTHING
+	RETURNING record_type, record_id, database_id, type
+)
+INSERT INTO %s AS rc (record_type, record_id, database_id, type, count)
+SELECT record_type, record_id, database_id, type, 1 FROM inserted
+ON CONFLICT (record_type, record_id, database_id, type) DO UPDATE SET count = rc.count + 1
+RETURNING count`,
+		c.tableName("_reaction"), c.tableName("_reaction_count"))
+
+	var count int64
+	err := c.QueryRowx(stmt,
+		reaction.RecordID.Type, reaction.RecordID.Key, reaction.DatabaseID,
+		reaction.UserID, reaction.Type, reaction.CreatedAt.UTC()).Scan(&count)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveReaction deletes the reaction and decrements the reaction count
+// in a single statement, so that the count is decremented only if the
+// reaction row is actually deleted.
+func (c *conn) RemoveReaction(databaseID string, recordID skydb.RecordID, userID string, reactionType string) (bool, error) {
+	stmt := fmt.Sprintf(`
+WITH deleted AS (
+	DELETE FROM %s
+	WHERE record_type = $1 AND record_id = $2 AND database_id = $3
+		AND user_id = $4 AND type = $5
+	RETURNING record_type, record_id, database_id, type
+)
+UPDATE %s AS rc SET count = rc.count - 1
+FROM deleted
+WHERE rc.record_type = deleted.record_type
+	AND rc.record_id = deleted.record_id
+	AND rc.database_id = deleted.database_id
+	AND rc.type = deleted.type
+RETURNING rc.count`,
+		c.tableName("_reaction"), c.tableName("_reaction_count"))
+
+	var count int64
+	err := c.QueryRowx(stmt,
+		recordID.Type, recordID.Key, databaseID, userID, reactionType).Scan(&count)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *conn) GetReactionCounts(databaseID string, recordID skydb.RecordID) (skydb.ReactionCounts, error) {
+	builder := psql.Select("type", "count").
+		From(c.tableName("_reaction_count")).
+		Where("record_type = ? AND record_id = ? AND database_id = ? AND count > 0",
+			recordID.Type, recordID.Key, databaseID)
+
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := skydb.ReactionCounts{}
+	for rows.Next() {
+		var (
+			reactionType string
+			count        int64
+		)
+		if err := rows.Scan(&reactionType, &count); err != nil {
+			return nil, err
+		}
+		counts[reactionType] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (c *conn) GetUserReactions(databaseID string, recordID skydb.RecordID, userID string) ([]string, error) {
+	builder := psql.Select("type").
+		From(c.tableName("_reaction")).
+		Where("record_type = ? AND record_id = ? AND database_id = ? AND user_id = ?",
+			recordID.Type, recordID.Key, databaseID, userID).
+		OrderBy("type")
+
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactionTypes := []string{}
+	for rows.Next() {
+		var reactionType string
+		if err := rows.Scan(&reactionType); err != nil {
+			return nil, err
+		}
+		reactionTypes = append(reactionTypes, reactionType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reactionTypes, nil
+}
+
+// deleteRecordReactions removes the reactions and reaction counts of a
+// deleted record of the database.
+func (c *conn) deleteRecordReactions(databaseID string, recordID skydb.RecordID) error {
+	for _, table := range []string{"_reaction", "_reaction_count"} {
+		builder := psql.Delete(c.tableName(table)).
+			Where(sq.Eq{
+				"record_type": recordID.Type,
+				"record_id":   recordID.Key,
+				"database_id": databaseID,
+			})
+		if _, err := c.ExecWith(builder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
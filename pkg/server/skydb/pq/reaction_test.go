@@ -0,0 +1,137 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestReaction(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		recordID := skydb.NewRecordID("article", "1")
+		react := func(userID string, reactionType string) bool {
+			added, err := c.AddReaction(&skydb.Reaction{
+				RecordID:  recordID,
+				UserID:    userID,
+				Type:      reactionType,
+				CreatedAt: now,
+			})
+			So(err, ShouldBeNil)
+			return added
+		}
+
+		So(react("alice", "like"), ShouldBeTrue)
+		So(react("bob", "like"), ShouldBeTrue)
+		So(react("bob", "love"), ShouldBeTrue)
+
+		Convey("counts reactions of record", func() {
+			counts, err := c.GetReactionCounts("", recordID)
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.ReactionCounts{"like": 2, "love": 1})
+
+			counts, err = c.GetReactionCounts("", skydb.NewRecordID("article", "2"))
+			So(err, ShouldBeNil)
+			So(counts, ShouldBeEmpty)
+		})
+
+		Convey("does not count the same reaction twice", func() {
+			So(react("alice", "like"), ShouldBeFalse)
+
+			counts, err := c.GetReactionCounts("", recordID)
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.ReactionCounts{"like": 2, "love": 1})
+		})
+
+		Convey("gets reactions of user", func() {
+			reactionTypes, err := c.GetUserReactions("", recordID, "bob")
+			So(err, ShouldBeNil)
+			So(reactionTypes, ShouldResemble, []string{"like", "love"})
+
+			reactionTypes, err = c.GetUserReactions("", recordID, "carol")
+			So(err, ShouldBeNil)
+			So(reactionTypes, ShouldBeEmpty)
+		})
+
+		Convey("removes reaction", func() {
+			removed, err := c.RemoveReaction("", recordID, "bob", "love")
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeTrue)
+
+			removed, err = c.RemoveReaction("", recordID, "bob", "love")
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeFalse)
+
+			counts, err := c.GetReactionCounts("", recordID)
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.ReactionCounts{"like": 2})
+		})
+
+		Convey("counts reactions of records of other databases separately", func() {
+			added, err := c.AddReaction(&skydb.Reaction{
+				RecordID:   recordID,
+				DatabaseID: "alice",
+				UserID:     "alice",
+				Type:       "like",
+				CreatedAt:  now,
+			})
+			So(err, ShouldBeNil)
+			So(added, ShouldBeTrue)
+
+			counts, err := c.GetReactionCounts("alice", recordID)
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.ReactionCounts{"like": 1})
+
+			counts, err = c.GetReactionCounts("", recordID)
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.ReactionCounts{"like": 2, "love": 1})
+
+			removed, err := c.RemoveReaction("bob", recordID, "bob", "like")
+			So(err, ShouldBeNil)
+			So(removed, ShouldBeFalse)
+		})
+
+		Convey("deletes reactions with the record", func() {
+			db := c.PublicDB()
+			_, err := db.Extend("article", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(db.Save(&skydb.Record{
+				ID:      recordID,
+				OwnerID: "alice",
+				Data:    map[string]interface{}{"title": "Hello"},
+			}), ShouldBeNil)
+
+			So(db.Delete(recordID), ShouldBeNil)
+
+			counts, err := c.GetReactionCounts("", recordID)
+			So(err, ShouldBeNil)
+			So(counts, ShouldBeEmpty)
+
+			reactionTypes, err := c.GetUserReactions("", recordID, "bob")
+			So(err, ShouldBeNil)
+			So(reactionTypes, ShouldBeEmpty)
+		})
+	})
+}
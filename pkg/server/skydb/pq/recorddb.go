@@ -43,7 +43,7 @@ func (db *database) Get(id skydb.RecordID, record *skydb.Record) error {
 	}
 
 	builder := db.selectQuery(psql.Select(), id.Type, typemap).Where("_id = ?", id.Key)
-	row := db.c.QueryRowWith(builder)
+	row := db.c.readQueryRowWith(builder)
 	if err := newRecordScanner(id.Type, typemap, row).Scan(record); err == sql.ErrNoRows {
 		return skydb.ErrRecordNotFound
 	} else if err != nil {
@@ -83,8 +83,9 @@ func (db *database) GetByIDs(ids []skydb.RecordID) (*skydb.Rows, error) {
 
 	inCause, inArgs := builder.LiteralToSQLOperand(idStrs)
 	query := db.selectQuery(psql.Select(), recordType, typemap).
-		Where(pq.QuoteIdentifier("_id")+" IN "+inCause, inArgs...)
-	rows, err := db.c.QueryWith(query)
+		Where(pq.QuoteIdentifier("_id")+" IN "+inCause, inArgs...).
+		Where(notDeletedSqlizer(recordType))
+	rows, err := db.c.readQueryWith(query)
 	if err != nil {
 		log.Debugf("Getting records by ID failed %v", err)
 		return nil, err
@@ -122,6 +123,10 @@ func (db *database) Save(record *skydb.Record) error {
 		return err
 	}
 
+	if err := checkPatches(typemap, record); err != nil {
+		return err
+	}
+
 	wrappers := map[string]func(string) string{}
 	for column, fieldType := range typemap {
 		if fieldType.Type == skydb.TypeGeometry {
@@ -130,11 +135,35 @@ func (db *database) Save(record *skydb.Record) error {
 			}
 		}
 	}
+	for key, value := range record.Data {
+		switch value.(type) {
+		case skydb.JSONPatch, skydb.MergePatch, skydb.TextPatch:
+			wrappers[key] = patchWrapper("NULL", value)
+		}
+	}
+
+	// A new record starts at revision 1, and the revision is incremented
+	// every time the record is updated.
+	data := convert(record)
+	data["_rev"] = 1
 
-	upsert := builder.UpsertQueryWithWrappers(db.TableName(record.ID.Type), pkData, convert(record), wrappers).
+	upsert := builder.UpsertQueryWithWrappers(db.TableName(record.ID.Type), pkData, data, wrappers).
 		IgnoreKeyOnUpdate("_owner_id").
 		IgnoreKeyOnUpdate("_created_at").
-		IgnoreKeyOnUpdate("_created_by")
+		IgnoreKeyOnUpdate("_created_by").
+		IgnoreKeyOnUpdate("_rev").
+		IncrementOnUpdate("_rev")
+	if record.BaseRevision != 0 {
+		upsert = upsert.UpdateCondition("_rev", record.BaseRevision)
+	}
+	for key, value := range record.Data {
+		switch value.(type) {
+		case skydb.Increment:
+			upsert = upsert.UpdateWrapper(key, incrementWrapper(key))
+		case skydb.JSONPatch, skydb.MergePatch, skydb.TextPatch:
+			upsert = upsert.UpdateWrapper(key, patchWrapper(pq.QuoteIdentifier(key), value))
+		}
+	}
 
 	// record type is empty in the following statement because upsert
 	// only concerns with one record type, and that specifying the
@@ -148,8 +177,19 @@ func (db *database) Save(record *skydb.Record) error {
 		return err
 	}
 
+	var historyBase *skydb.Record
+	if hasHistory(record.ID.Type) {
+		if historyBase, err = db.historyBase(record.ID); err != nil {
+			return err
+		}
+	}
+
 	row := db.c.QueryRowWith(upsert)
 	if err = newRecordScanner(record.ID.Type, typemap, row).Scan(record); err != nil {
+		if err == sql.ErrNoRows && record.BaseRevision != 0 {
+			return skydb.ErrRecordConflict
+		}
+
 		if isUniqueViolated(err) {
 			return skyerr.NewErrorf(
 				skyerr.Duplicated,
@@ -157,6 +197,13 @@ func (db *database) Save(record *skydb.Record) error {
 			)
 		}
 
+		if isNotNullViolated(err) {
+			return skyerr.NewErrorf(
+				skyerr.ConstraintViolated,
+				fmt.Sprintf("failed to save %s: %s", record.ID, err),
+			)
+		}
+
 		if isInvalidInputSyntax(err) {
 			return skyerr.NewErrorf(
 				skyerr.InvalidArgument,
@@ -166,14 +213,150 @@ func (db *database) Save(record *skydb.Record) error {
 		return skyerr.MakeError(err)
 	}
 
+	db.c.invalidateQueryCache(record.ID.Type)
+
+	if hasHistory(record.ID.Type) {
+		if err := db.writeHistory(record.ID, skydb.RecordHistorySave, historyBase, record.UpdaterID); err != nil {
+			return err
+		}
+	}
+
 	record.DatabaseID = db.userID
 	return nil
 }
 
+// Merge updates the fields in record with a single UPDATE statement, so
+// that fields not in record are not overwritten by stale values.
+func (db *database) Merge(record *skydb.Record) error {
+	if record.ID.Key == "" {
+		return errors.New("db.merge: got empty record id")
+	}
+	if record.ID.Type == "" {
+		return fmt.Errorf("db.merge %s: got empty record type", record.ID.Key)
+	}
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	typemap, err := db.RemoteColumnTypes(record.ID.Type)
+	if err != nil {
+		return err
+	}
+	if len(typemap) == 0 { // record type has not been created
+		return skydb.ErrRecordNotFound
+	}
+	if err := checkPatches(typemap, record); err != nil {
+		return err
+	}
+
+	data := convert(record)
+	delete(data, "_owner_id")
+	delete(data, "_created_at")
+	delete(data, "_created_by")
+
+	update := psql.Update(db.TableName(record.ID.Type)).
+		Set("_rev", sq.Expr(`"_rev" + 1`)).
+		Where("_id = ? AND _database_id = ?", record.ID.Key, db.userID)
+	for column, value := range data {
+		if _, ok := record.Data[column].(skydb.Increment); ok {
+			update = update.Set(pq.QuoteIdentifier(column), sq.Expr(incrementWrapper(column)("?"), value))
+		} else if _, ok := value.(patchValue); ok {
+			wrapper := patchWrapper(pq.QuoteIdentifier(column), record.Data[column])
+			update = update.Set(pq.QuoteIdentifier(column), sq.Expr(wrapper("?"), value))
+		} else if typemap[column].Type == skydb.TypeGeometry {
+			update = update.Set(pq.QuoteIdentifier(column), sq.Expr("ST_GeomFromGeoJSON(?)", value))
+		} else {
+			update = update.Set(pq.QuoteIdentifier(column), value)
+		}
+	}
+	if record.BaseRevision != 0 {
+		update = update.Where("_rev = ?", record.BaseRevision)
+	}
+
+	returning := []string{}
+	returningArgs := []interface{}{}
+	for column, sqlizer := range columnSqlizersForSelect(record.ID.Type, typemap) {
+		sqlOperand, opArgs, _ := sqlizer.ToSql()
+		returning = append(returning, sqlOperand+" as "+pq.QuoteIdentifier(column))
+		returningArgs = append(returningArgs, opArgs...)
+	}
+	update = update.Suffix("RETURNING "+strings.Join(returning, ", "), returningArgs...)
+
+	if err := db.preSave(typemap, record); err != nil {
+		return err
+	}
+
+	var historyBase *skydb.Record
+	if hasHistory(record.ID.Type) {
+		if historyBase, err = db.historyBase(record.ID); err != nil {
+			return err
+		}
+	}
+
+	row := db.c.QueryRowWith(update)
+	if err = newRecordScanner(record.ID.Type, typemap, row).Scan(record); err != nil {
+		if err == sql.ErrNoRows {
+			if record.BaseRevision != 0 {
+				return skydb.ErrRecordConflict
+			}
+			return skydb.ErrRecordNotFound
+		}
+
+		if isUniqueViolated(err) {
+			return skyerr.NewErrorf(
+				skyerr.Duplicated,
+				fmt.Sprintf("violate unique constraint"),
+			)
+		}
+
+		if isNotNullViolated(err) {
+			return skyerr.NewErrorf(
+				skyerr.ConstraintViolated,
+				fmt.Sprintf("failed to merge %s: %s", record.ID, err),
+			)
+		}
+
+		if isInvalidInputSyntax(err) {
+			return skyerr.NewErrorf(
+				skyerr.InvalidArgument,
+				fmt.Sprintf("failed to merge %s: %s", record.ID, err),
+			)
+		}
+		return skyerr.MakeError(err)
+	}
+
+	db.c.invalidateQueryCache(record.ID.Type)
+
+	if hasHistory(record.ID.Type) {
+		if err := db.writeHistory(record.ID, skydb.RecordHistorySave, historyBase, record.UpdaterID); err != nil {
+			return err
+		}
+	}
+
+	record.DatabaseID = db.userID
+	return nil
+}
+
+// incrementWrapper returns a wrapper which adds the value to the current
+// value of the column. The incremented value is returned with the other
+// columns of the saved record, replacing the skydb.Increment in the data.
+func incrementWrapper(column string) func(string) string {
+	return func(val string) string {
+		return fmt.Sprintf("COALESCE(%s, 0) + %s", pq.QuoteIdentifier(column), val)
+	}
+}
+
 func (db *database) preSave(schema skydb.RecordSchema, record *skydb.Record) error {
 	const SetSequenceMaxValue = `SELECT setval($1, GREATEST(max(%v), $2)) FROM %v;`
 
 	for key, value := range record.Data {
+		if schema[key].Type == skydb.TypePosition {
+			if position, ok := value.(string); ok && !skydb.IsValidPosition(position) {
+				return skyerr.NewInvalidArgument(
+					fmt.Sprintf("invalid position for field %s", key), []string{key})
+			}
+		}
+
 		// we are setting a sequence field
 		if schema[key].Type == skydb.TypeSequence {
 			selectSQL := fmt.Sprintf(SetSequenceMaxValue, pq.QuoteIdentifier(key), db.TableName(record.ID.Type))
@@ -203,6 +386,10 @@ func convert(r *skydb.Record) map[string]interface{} {
 			m[key] = locationValue(value)
 		case skydb.Geometry:
 			m[key] = geometryValue(value)
+		case skydb.Increment:
+			m[key] = value.Delta
+		case skydb.JSONPatch, skydb.MergePatch, skydb.TextPatch:
+			m[key] = patchValue{value}
 		case skydb.Unknown:
 			// Do not modify columns with unknown type because they are
 			// managed by the developer.
@@ -216,6 +403,7 @@ func convert(r *skydb.Record) map[string]interface{} {
 	m["_created_by"] = r.CreatorID
 	m["_updated_at"] = r.UpdatedAt
 	m["_updated_by"] = r.UpdaterID
+	m["_deleted_at"] = r.DeletedAt
 	return m
 }
 
@@ -232,6 +420,14 @@ func (db *database) Delete(id skydb.RecordID) error {
 		builder = builder.Where("_database_id = ?", db.userID)
 	}
 
+	var historyBase *skydb.Record
+	if hasHistory(id.Type) {
+		var err error
+		if historyBase, err = db.historyBase(id); err != nil {
+			return err
+		}
+	}
+
 	result, err := db.c.ExecWith(builder)
 	if isUndefinedTable(err) {
 		return skydb.ErrRecordNotFound
@@ -260,17 +456,39 @@ func (db *database) Delete(id skydb.RecordID) error {
 		return fmt.Errorf("delete %s: got %v rows deleted, want 1", id, rowsAffected)
 	}
 
+	db.c.invalidateQueryCache(id.Type)
+
+	if err := db.c.deleteRecordAnnotations(id); err != nil {
+		return fmt.Errorf("delete %s: failed to delete annotations: %s", id, err)
+	}
+
+	if err := db.c.deleteRecordReactions(db.userID, id); err != nil {
+		return fmt.Errorf("delete %s: failed to delete reactions: %s", id, err)
+	}
+
+	if hasHistory(id.Type) {
+		return db.writeHistory(id, skydb.RecordHistoryDelete, historyBase, "")
+	}
+
 	return err
 }
 
+// notDeletedSqlizer excludes soft-deleted records of the record type.
+func notDeletedSqlizer(recordType string) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf(`%s."_deleted_at" IS NULL`, pq.QuoteIdentifier(recordType)))
+}
+
 func (db *database) applyQueryPredicate(q sq.SelectBuilder, factory builder.PredicateSqlizerFactory, query *skydb.Query) (sq.SelectBuilder, error) {
+	if !query.IncludeDeleted {
+		q = q.Where(notDeletedSqlizer(query.Type))
+	}
+
 	if p := query.Predicate; !p.IsEmpty() {
 		sqlizer, err := factory.NewPredicateSqlizer(p)
 		if err != nil {
 			return q, err
 		}
 		q = q.Where(sqlizer)
-		q = factory.AddJoinsToSelectBuilder(q)
 	}
 
 	if db.DatabaseType() == skydb.PublicDatabase && !query.BypassAccessControl {
@@ -298,22 +516,113 @@ func (db *database) Query(query *skydb.Query) (*skydb.Rows, error) {
 		return skydb.EmptyRows, nil
 	}
 
+	sel, err := db.selectRecordQuery(query, typemap)
+	if err != nil {
+		return nil, err
+	}
+	q := sel.builder
+
+	cacheKey := db.queryCacheKey(q, sel.factory, query, sel.includes)
+	if cacheKey != "" {
+		if records, ok := queryCache.Get(cacheKey); ok {
+			return newCachedRows(records), nil
+		}
+	}
+
+	var plan interface{}
+	if query.Explain {
+		plan, err = db.c.ExplainWith(q)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := db.c.readQueryWith(q)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := newRecordScanner(query.Type, sel.typemap, rows)
+	rs.setIncludes(sel.includes)
+	rs.cursorColumns = sel.cursorColumns
+	rs.pageSize = query.PageSize
+	if cacheKey != "" {
+		records, err := readAllRecords(rowsIter{rows, rs, plan})
+		if err != nil {
+			return nil, err
+		}
+		queryCache.Set(cacheKey, records)
+		return newCachedRows(records), nil
+	}
+	return skydb.NewRows(rowsIter{rows, rs, plan}), nil
+}
+
+// recordSelect is a record query translated into a select statement.
+type recordSelect struct {
+	builder       sq.SelectBuilder
+	factory       builder.PredicateSqlizerFactory
+	typemap       skydb.RecordSchema
+	includes      map[string]includedColumn
+	cursorColumns map[string]int
+}
+
+// selectRecordQuery translates a record query into a select statement of
+// the record type, which has the columns in typemap.
+func (db *database) selectRecordQuery(query *skydb.Query, typemap skydb.RecordSchema) (*recordSelect, error) {
+	columns := typemap
 	q := psql.Select()
 	factory := builder.NewPredicateSqlizerFactory(db, query.Type)
-	q, err = db.applyQueryPredicate(q, factory, query)
+	q, err := db.applyQueryPredicate(q, factory, query)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, sort := range query.Sorts {
-		orderBy, err := builder.SortOrderBySQL(query.Type, sort)
+	sorts := query.Sorts
+	if len(query.DistinctOn) > 0 {
+		if query.IsPaginatedByKeyset() {
+			return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+				"distinct on cannot be used with keyset pagination")
+		}
+		if err := factory.SetDistinctOn(query.DistinctOn); err != nil {
+			return nil, err
+		}
+		sorts = builder.DistinctOnSorts(query.DistinctOn, query.Sorts)
+	}
+	if query.IsPaginatedByKeyset() {
+		for _, sort := range query.Sorts {
+			if _, ok := sort.Expression.Value.(skydb.RandomFunc); ok {
+				return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+					"random sort cannot be used with keyset pagination")
+			}
+		}
+		sorts = builder.KeysetSorts(query.Sorts)
+	}
+
+	for _, sort := range sorts {
+		orderBy, err := factory.NewSortOrderBySQL(sort)
 		if err != nil {
 			return nil, err
 		}
 		q = q.OrderBy(orderBy)
 	}
 
-	if query.Limit != nil {
+	cursorColumns := map[string]int{}
+	if query.IsPaginatedByKeyset() {
+		q, cursorColumns, err = db.applyQueryKeyset(q, factory, query, sorts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	includes, err := db.recordIncludesForQuery(factory, query, typemap)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.PageSize > 0 {
+		// fetch one more record to tell if there is a next page
+		q = q.Limit(query.PageSize + 1)
+	} else if query.Limit != nil {
 		q = q.Limit(*query.Limit)
 	}
 
@@ -329,10 +638,133 @@ func (db *database) Query(query *skydb.Query) (*skydb.Rows, error) {
 		return nil, err
 	}
 	typemap = factory.UpdateTypemap(typemap)
+
+	// Add joins after predicate, sorts, includes and computed keys are
+	// applied because all of them may reference fields of other records.
+	q = factory.AddJoinsToSelectBuilder(q)
 	q = db.selectQuery(q, query.Type, typemap)
+	q = selectIncludedColumns(q, includes)
+	if query.AsOf != nil {
+		if q, err = db.applyQueryAsOf(q, query.Type, columns, *query.AsOf); err != nil {
+			return nil, err
+		}
+	}
+
+	return &recordSelect{
+		builder:       q,
+		factory:       factory,
+		typemap:       typemap,
+		includes:      includes,
+		cursorColumns: cursorColumns,
+	}, nil
+}
+
+// applyQueryKeyset selects the values of the sort expressions of a query
+// paginated by keyset, and filters records sorted after query.After.
+//
+// The returned map contains the indices of sort expressions keyed by the
+// name of the selected columns.
+func (db *database) applyQueryKeyset(q sq.SelectBuilder, factory builder.PredicateSqlizerFactory, query *skydb.Query, keysetSorts []skydb.Sort) (sq.SelectBuilder, map[string]int, error) {
+	cursorColumns := map[string]int{}
+	for i, sort := range query.Sorts {
+		expr, err := factory.NewSortExpressionSQL(sort)
+		if err != nil {
+			return q, nil, err
+		}
+		column := fmt.Sprintf("_cursor_%d", i)
+		q = q.Column(expr + " as " + pq.QuoteIdentifier(column))
+		cursorColumns[column] = i
+	}
+
+	if query.After != nil {
+		values := make([]interface{}, 0, len(query.After.Values)+1)
+		values = append(values, query.After.Values...)
+		values = append(values, query.After.Key)
+		sqlizer, err := factory.NewKeysetSqlizer(keysetSorts, values)
+		if err != nil {
+			return q, nil, err
+		}
+		q = q.Where(sqlizer)
+	}
+	return q, cursorColumns, nil
+}
+
+// recordInclude specifies a record referenced by a field in the queried
+// record, which is loaded together with the queried record.
+type recordInclude struct {
+	field      string
+	alias      string
+	recordType string
+	typemap    skydb.RecordSchema
+}
+
+// includedColumn identifies a column of an included record in a result set.
+type includedColumn struct {
+	include *recordInclude
+	column  string
+}
+
+// recordIncludesForQuery joins the tables of records referenced by
+// query.Includes, and returns the included columns keyed by column name
+// in the result set.
+func (db *database) recordIncludesForQuery(factory builder.PredicateSqlizerFactory, query *skydb.Query, typemap skydb.RecordSchema) (map[string]includedColumn, error) {
+	if len(query.Includes) == 0 {
+		return nil, nil
+	}
 
-	rows, err := db.c.QueryWith(q)
-	return newRows(query.Type, typemap, rows, err)
+	columns := map[string]includedColumn{}
+	for i, field := range query.Includes {
+		fieldType, ok := typemap[field]
+		if !ok || fieldType.Type != skydb.TypeReference {
+			return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+				`field "%s" to be included is not a reference`, field)
+		}
+
+		includedTypemap, err := db.RemoteColumnTypes(fieldType.ReferenceType)
+		if err != nil {
+			return nil, err
+		}
+		if len(includedTypemap) == 0 {
+			// referenced record type has not been created
+			continue
+		}
+
+		alias, err := factory.JoinReferencedTable(field)
+		if err != nil {
+			return nil, err
+		}
+
+		include := &recordInclude{
+			field:      field,
+			alias:      alias,
+			recordType: fieldType.ReferenceType,
+			typemap:    includedTypemap,
+		}
+		for column := range includedTypemap {
+			// Columns are named by index because a name derived from
+			// the field and the column may exceed the identifier length
+			// limit.
+			name := fmt.Sprintf("_include_%d_%d", i, len(columns))
+			columns[name] = includedColumn{include, column}
+		}
+	}
+	return columns, nil
+}
+
+func selectIncludedColumns(q sq.SelectBuilder, includes map[string]includedColumn) sq.SelectBuilder {
+	for name, c := range includes {
+		fieldType := c.include.typemap[c.column]
+		var sqlizer sq.Sqlizer = builder.NewExpressionSqlizer(c.include.alias, fieldType, skydb.Expression{
+			Type:  skydb.KeyPath,
+			Value: c.column,
+		})
+		if fieldType.Type == skydb.TypeGeometry {
+			sqlizer, _ = builder.RequireCast(sqlizer)
+		}
+		sqlOperand, opArgs, _ := sqlizer.ToSql()
+		q = q.Column(sqlOperand+" as "+pq.QuoteIdentifier(name), opArgs...)
+	}
+	return q
 }
 
 func (db *database) QueryCount(query *skydb.Query) (uint64, error) {
@@ -345,26 +777,44 @@ func (db *database) QueryCount(query *skydb.Query) (uint64, error) {
 		return 0, err
 	}
 
-	typemap = skydb.RecordSchema{
-		"_record_count": skydb.FieldType{
-			Type: skydb.TypeNumber,
-			Expression: skydb.Expression{
-				Type: skydb.Function,
-				Value: skydb.CountFunc{
-					OverallRecords: false,
+	columns := typemap
+	var q sq.SelectBuilder
+	if query.EstimateCount {
+		// The planner estimates the number of rows of a plain select
+		// rather than of the aggregate.
+		q = db.selectQuery(psql.Select("1"), query.Type, skydb.RecordSchema{})
+	} else {
+		typemap = skydb.RecordSchema{
+			"_record_count": skydb.FieldType{
+				Type: skydb.TypeNumber,
+				Expression: skydb.Expression{
+					Type: skydb.Function,
+					Value: skydb.CountFunc{
+						OverallRecords: false,
+					},
 				},
 			},
-		},
+		}
+		q = db.selectQuery(psql.Select(), query.Type, typemap)
 	}
 
-	q := db.selectQuery(psql.Select(), query.Type, typemap)
 	factory := builder.NewPredicateSqlizerFactory(db, query.Type)
 	q, err = db.applyQueryPredicate(q, factory, query)
 	if err != nil {
 		return 0, err
 	}
+	q = factory.AddJoinsToSelectBuilder(q)
+	if query.AsOf != nil {
+		if q, err = db.applyQueryAsOf(q, query.Type, columns, *query.AsOf); err != nil {
+			return 0, err
+		}
+	}
 
-	rows, err := db.c.QueryWith(q)
+	if query.EstimateCount {
+		return db.c.EstimateRowsWith(q)
+	}
+
+	rows, err := db.c.readQueryWith(q)
 	if err != nil {
 		return 0, err
 	}
@@ -391,17 +841,43 @@ type columnsScanner interface {
 }
 
 type recordScanner struct {
-	recordType  string
-	typemap     skydb.RecordSchema
-	cs          columnsScanner
-	columns     []string
-	err         error
-	recordCount *uint64
+	recordType      string
+	typemap         skydb.RecordSchema
+	cs              columnsScanner
+	columns         []string
+	err             error
+	recordCount     *uint64
+	includes        map[string]includedColumn
+	includedRecords map[string]map[string]*skydb.Record
+
+	// the following fields are used in queries paginated by keyset
+	cursorColumns map[string]int
+	pageSize      uint64
+	count         uint64
+	cursor        *skydb.Cursor
+	nextCursor    *skydb.Cursor
 }
 
 func newRecordScanner(recordType string, typemap skydb.RecordSchema, cs columnsScanner) *recordScanner {
 	columns, err := cs.Columns()
-	return &recordScanner{recordType, typemap, cs, columns, err, nil}
+	return &recordScanner{
+		recordType: recordType,
+		typemap:    typemap,
+		cs:         cs,
+		columns:    columns,
+		err:        err,
+	}
+}
+
+func (rs *recordScanner) setIncludes(includes map[string]includedColumn) {
+	if len(includes) == 0 {
+		return
+	}
+	rs.includes = includes
+	rs.includedRecords = map[string]map[string]*skydb.Record{}
+	for _, c := range includes {
+		rs.includedRecords[c.include.field] = map[string]*skydb.Record{}
+	}
 }
 
 func (rs *recordScanner) Scan(record *skydb.Record) error {
@@ -409,17 +885,29 @@ func (rs *recordScanner) Scan(record *skydb.Record) error {
 		return rs.err
 	}
 
+	schemas := make([]skydb.FieldType, 0, len(rs.columns))
 	values := make([]interface{}, 0, len(rs.columns))
 	for _, column := range rs.columns {
+		if _, ok := rs.cursorColumns[column]; ok {
+			var value interface{}
+			schemas = append(schemas, skydb.FieldType{})
+			values = append(values, &value)
+			continue
+		}
+
 		schema, ok := rs.typemap[column]
+		if c, included := rs.includes[column]; included {
+			schema, ok = c.include.typemap[c.column]
+		}
 		if !ok {
 			return fmt.Errorf("received unknown column = %s", column)
 		}
+		schemas = append(schemas, schema)
 		switch schema.Type {
 		case skydb.TypeNumber:
 			var number sql.NullFloat64
 			values = append(values, &number)
-		case skydb.TypeString, skydb.TypeReference, skydb.TypeACL:
+		case skydb.TypeString, skydb.TypeReference, skydb.TypeACL, skydb.TypePosition:
 			var str sql.NullString
 			values = append(values, &str)
 		case skydb.TypeDateTime:
@@ -461,8 +949,40 @@ func (rs *recordScanner) Scan(record *skydb.Record) error {
 	record.ID.Type = rs.recordType
 	record.Data = map[string]interface{}{}
 
+	var includedRecords map[*recordInclude]*skydb.Record
+	if len(rs.includes) > 0 {
+		includedRecords = map[*recordInclude]*skydb.Record{}
+	}
+
+	cursorValues := make([]interface{}, len(rs.cursorColumns))
+
 	for i, column := range rs.columns {
 		value := values[i]
+		schema := schemas[i]
+
+		if j, ok := rs.cursorColumns[column]; ok {
+			cursorValue := *value.(*interface{})
+			if b, ok := cursorValue.([]byte); ok {
+				cursorValue = string(b)
+			}
+			cursorValues[j] = cursorValue
+			continue
+		}
+
+		if c, included := rs.includes[column]; included {
+			includedRecord, ok := includedRecords[c.include]
+			if !ok {
+				includedRecord = &skydb.Record{
+					ID:   skydb.RecordID{Type: c.include.recordType},
+					Data: map[string]interface{}{},
+				}
+				includedRecords[c.include] = includedRecord
+			}
+			if err := setScannedValue(includedRecord, c.column, schema, value); err != nil {
+				return err
+			}
+			continue
+		}
 
 		if column == "_record_count" {
 			svalue, ok := value.(*sql.NullFloat64)
@@ -475,70 +995,91 @@ func (rs *recordScanner) Scan(record *skydb.Record) error {
 			continue
 		}
 
-		switch svalue := value.(type) {
-		default:
-			return fmt.Errorf("received unexpected scanned type = %T for column = %s", value, column)
-		case *sql.NullFloat64:
-			if svalue.Valid {
-				record.Set(column, svalue.Float64)
-			}
-		case *sql.NullString:
-			if svalue.Valid {
-				schema := rs.typemap[column]
-				if schema.Type == skydb.TypeReference {
-					record.Set(column, skydb.NewReference(schema.ReferenceType, svalue.String))
-				} else if schema.Type == skydb.TypeACL {
-					acl := skydb.RecordACL{}
-					json.Unmarshal([]byte(svalue.String), &acl)
-					record.Set(column, acl)
-				} else {
-					record.Set(column, svalue.String)
-				}
-			}
-		case *pq.NullTime:
-			if svalue.Valid {
-				// it is to support direct deep-equal of value between
-				// a empty record and a record materialized from the database
-				if svalue.Time.IsZero() {
-					record.Set(column, time.Time{})
-				} else {
-					record.Set(column, svalue.Time.In(time.UTC))
-				}
-			}
-		case *sql.NullBool:
-			if svalue.Valid {
-				record.Set(column, svalue.Bool)
-			}
-		case *nullAsset:
-			if svalue.Valid {
-				record.Set(column, svalue.Asset)
-			}
-		case *nullJSON:
-			if svalue.Valid {
-				record.Set(column, svalue.JSON)
-			}
-		case *nullLocation:
-			if svalue.Valid {
-				record.Set(column, svalue.Location)
-			}
-		case *nullGeometry:
-			if svalue.Valid {
-				record.Set(column, svalue.Geometry)
-			}
-		case *nullUnknown:
-			if svalue.Valid {
-				val := skydb.Unknown{}
-				if schema, ok := rs.typemap[column]; ok {
-					val.UnderlyingType = schema.UnderlyingType
-				}
-				record.Set(column, val)
+		if err := setScannedValue(record, column, schema, value); err != nil {
+			return err
+		}
+	}
+
+	for include, includedRecord := range includedRecords {
+		// the referenced record does not exist if the left join
+		// matches nothing
+		if includedRecord.ID.Key == "" {
+			continue
+		}
+		rs.includedRecords[include.field][includedRecord.ID.Key] = includedRecord
+	}
+
+	if rs.pageSize > 0 {
+		rs.cursor = &skydb.Cursor{
+			Values: cursorValues,
+			Key:    record.ID.Key,
+		}
+	}
+
+	return nil
+}
+
+// setScannedValue sets a value scanned from a column to the record.
+func setScannedValue(record *skydb.Record, column string, schema skydb.FieldType, value interface{}) error {
+	switch svalue := value.(type) {
+	default:
+		return fmt.Errorf("received unexpected scanned type = %T for column = %s", value, column)
+	case *sql.NullFloat64:
+		if svalue.Valid {
+			record.Set(column, svalue.Float64)
+		}
+	case *sql.NullString:
+		if svalue.Valid {
+			if schema.Type == skydb.TypeReference {
+				record.Set(column, skydb.NewReference(schema.ReferenceType, svalue.String))
+			} else if schema.Type == skydb.TypeACL {
+				acl := skydb.RecordACL{}
+				json.Unmarshal([]byte(svalue.String), &acl)
+				record.Set(column, acl)
+			} else {
+				record.Set(column, svalue.String)
 			}
-		case *sql.NullInt64:
-			if svalue.Valid {
-				record.Set(column, svalue.Int64)
+		}
+	case *pq.NullTime:
+		if svalue.Valid {
+			// it is to support direct deep-equal of value between
+			// a empty record and a record materialized from the database
+			if svalue.Time.IsZero() {
+				record.Set(column, time.Time{})
+			} else {
+				record.Set(column, svalue.Time.In(time.UTC))
 			}
 		}
-
+	case *sql.NullBool:
+		if svalue.Valid {
+			record.Set(column, svalue.Bool)
+		}
+	case *nullAsset:
+		if svalue.Valid {
+			record.Set(column, svalue.Asset)
+		}
+	case *nullJSON:
+		if svalue.Valid {
+			record.Set(column, svalue.JSON)
+		}
+	case *nullLocation:
+		if svalue.Valid {
+			record.Set(column, svalue.Location)
+		}
+	case *nullGeometry:
+		if svalue.Valid {
+			record.Set(column, svalue.Geometry)
+		}
+	case *nullUnknown:
+		if svalue.Valid {
+			val := skydb.Unknown{}
+			val.UnderlyingType = schema.UnderlyingType
+			record.Set(column, val)
+		}
+	case *sql.NullInt64:
+		if svalue.Valid {
+			record.Set(column, svalue.Int64)
+		}
 	}
 
 	return nil
@@ -547,6 +1088,11 @@ func (rs *recordScanner) Scan(record *skydb.Record) error {
 type rowsIter struct {
 	rows *sqlx.Rows
 	rs   *recordScanner
+	plan interface{}
+}
+
+func (rowsi rowsIter) QueryPlan() interface{} {
+	return rowsi.plan
 }
 
 func (rowsi rowsIter) Close() error {
@@ -554,8 +1100,21 @@ func (rowsi rowsIter) Close() error {
 }
 
 func (rowsi rowsIter) Next(record *skydb.Record) error {
+	rs := rowsi.rs
+	if rs.pageSize > 0 && rs.count >= rs.pageSize {
+		// the extra record fetched tells there is a next page
+		if rowsi.rows.Next() {
+			rs.nextCursor = rs.cursor
+		}
+		if rowsi.rows.Err() != nil {
+			return rowsi.rows.Err()
+		}
+		return io.EOF
+	}
+
 	if rowsi.rows.Next() {
-		return rowsi.rs.Scan(record)
+		rs.count++
+		return rs.Scan(record)
 	} else if rowsi.rows.Err() != nil {
 		return rowsi.rows.Err()
 	} else {
@@ -567,12 +1126,20 @@ func (rowsi rowsIter) OverallRecordCount() *uint64 {
 	return rowsi.rs.recordCount
 }
 
+func (rowsi rowsIter) IncludedRecords() map[string]map[string]*skydb.Record {
+	return rowsi.rs.includedRecords
+}
+
+func (rowsi rowsIter) NextCursor() *skydb.Cursor {
+	return rowsi.rs.nextCursor
+}
+
 func newRows(recordType string, typemap skydb.RecordSchema, rows *sqlx.Rows, err error) (*skydb.Rows, error) {
 	if err != nil {
 		return nil, err
 	}
 	rs := newRecordScanner(recordType, typemap, rows)
-	return skydb.NewRows(rowsIter{rows, rs}), nil
+	return skydb.NewRows(rowsIter{rows: rows, rs: rs}), nil
 }
 
 func columnSqlizersForSelect(recordType string, typemap skydb.RecordSchema) map[string]sq.Sqlizer {
@@ -630,10 +1197,14 @@ func updateTypemapForQuery(query *skydb.Query, typemap skydb.RecordSchema) (skyd
 		}
 
 		v := value // because value will be overwritten in the next loop
-		typemap["_transient_"+key] = skydb.FieldType{
+		fieldType := skydb.FieldType{
 			Type:       skydb.TypeNumber,
 			Expression: v,
 		}
+		if fn, ok := v.Value.(skydb.Func); v.Type == skydb.Function && ok {
+			fieldType.Type = fn.DataType()
+		}
+		typemap["_transient_"+key] = fieldType
 	}
 
 	if query.GetCount {
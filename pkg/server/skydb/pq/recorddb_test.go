@@ -255,6 +255,64 @@ func TestSave(t *testing.T) {
 			So(content, ShouldEqual, "more content")
 		})
 
+		Convey("increments revision when updating record", func() {
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Revision, ShouldEqual, 1)
+
+			record.Set("content", "more content")
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Revision, ShouldEqual, 2)
+		})
+
+		Convey("increments number field", func() {
+			So(db.Save(&record), ShouldBeNil)
+
+			record.Set("number", skydb.Increment{Delta: 2})
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Get("number"), ShouldEqual, float64(3))
+
+			var number float64
+			err = c.QueryRowx("SELECT number FROM note WHERE _id = 'someid' and _database_id = ''").
+				Scan(&number)
+			So(err, ShouldBeNil)
+			So(number, ShouldEqual, 3)
+		})
+
+		Convey("increments number field of new record from delta", func() {
+			record.Set("number", skydb.Increment{Delta: 2})
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Get("number"), ShouldEqual, float64(2))
+		})
+
+		Convey("updates record with current base revision", func() {
+			So(db.Save(&record), ShouldBeNil)
+
+			record.Set("content", "more content")
+			record.BaseRevision = 1
+			So(db.Save(&record), ShouldBeNil)
+			So(record.Revision, ShouldEqual, 2)
+		})
+
+		Convey("errors if base revision is stale", func() {
+			So(db.Save(&record), ShouldBeNil)
+			record.Set("content", "more content")
+			So(db.Save(&record), ShouldBeNil)
+
+			record.Set("content", "stale content")
+			record.BaseRevision = 1
+			So(db.Save(&record), ShouldEqual, skydb.ErrRecordConflict)
+
+			var (
+				content string
+				rev     int64
+			)
+			err = c.QueryRowx("SELECT content, _rev FROM note WHERE _id = 'someid' and _database_id = ''").
+				Scan(&content, &rev)
+			So(err, ShouldBeNil)
+			So(content, ShouldEqual, "more content")
+			So(rev, ShouldEqual, 2)
+		})
+
 		Convey("error if saving with recordid already taken by other user", func() {
 			ownerDB := c.PrivateDB("ownerid")
 			err := ownerDB.Save(&record)
@@ -367,6 +425,93 @@ func TestSave(t *testing.T) {
 	})
 }
 
+func TestMerge(t *testing.T) {
+	var c *conn
+	Convey("Database", t, func() {
+		c = getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content": skydb.FieldType{Type: skydb.TypeString},
+			"number":  skydb.FieldType{Type: skydb.TypeNumber},
+		})
+		So(err, ShouldBeNil)
+
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("note", "someid"),
+			OwnerID: "user_id",
+			Data: map[string]interface{}{
+				"content": "some content",
+				"number":  float64(1),
+			},
+		}), ShouldBeNil)
+
+		Convey("updates only the supplied fields", func() {
+			record := skydb.Record{
+				ID:        skydb.NewRecordID("note", "someid"),
+				UpdaterID: "updater",
+				Data: map[string]interface{}{
+					"number": float64(2),
+				},
+			}
+			So(db.Merge(&record), ShouldBeNil)
+			So(record.OwnerID, ShouldEqual, "user_id")
+			So(record.Revision, ShouldEqual, 2)
+			So(record.Data, ShouldResemble, skydb.Data{
+				"content": "some content",
+				"number":  float64(2),
+			})
+
+			var (
+				content string
+				number  float64
+			)
+			err = c.QueryRowx("SELECT content, number FROM note WHERE _id = 'someid' and _database_id = ''").
+				Scan(&content, &number)
+			So(err, ShouldBeNil)
+			So(content, ShouldEqual, "some content")
+			So(number, ShouldEqual, 2)
+		})
+
+		Convey("increments number field", func() {
+			record := skydb.Record{
+				ID: skydb.NewRecordID("note", "someid"),
+				Data: map[string]interface{}{
+					"number": skydb.Increment{Delta: 2},
+				},
+			}
+			So(db.Merge(&record), ShouldBeNil)
+			So(record.Get("number"), ShouldEqual, float64(3))
+		})
+
+		Convey("errors if record does not exist", func() {
+			record := skydb.Record{
+				ID: skydb.NewRecordID("note", "notexistid"),
+				Data: map[string]interface{}{
+					"number": float64(2),
+				},
+			}
+			So(db.Merge(&record), ShouldEqual, skydb.ErrRecordNotFound)
+		})
+
+		Convey("errors if base revision is stale", func() {
+			record := skydb.Record{
+				ID:           skydb.NewRecordID("note", "someid"),
+				BaseRevision: 2,
+				Data: map[string]interface{}{
+					"number": float64(2),
+				},
+			}
+			So(db.Merge(&record), ShouldEqual, skydb.ErrRecordConflict)
+
+			record.BaseRevision = 1
+			So(db.Merge(&record), ShouldBeNil)
+			So(record.Revision, ShouldEqual, 2)
+		})
+	})
+}
+
 func TestDelete(t *testing.T) {
 	var c *conn
 	Convey("Database", t, func() {
@@ -399,6 +544,41 @@ func TestDelete(t *testing.T) {
 			So(err, ShouldEqual, sql.ErrNoRows)
 		})
 
+		Convey("excludes soft-deleted record from query", func() {
+			deletedAt := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+			record.DeletedAt = &deletedAt
+			err := db.Save(&record)
+			So(err, ShouldBeNil)
+
+			fetched := skydb.Record{}
+			err = db.Get(skydb.NewRecordID("note", "someid"), &fetched)
+			So(err, ShouldBeNil)
+			So(*fetched.DeletedAt, ShouldResemble, deletedAt)
+
+			records, err := exhaustRows(db.Query(&skydb.Query{Type: "note"}))
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+
+			records, err = exhaustRows(db.Query(&skydb.Query{
+				Type:           "note",
+				IncludeDeleted: true,
+			}))
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+
+			records, err = exhaustRows(db.GetByIDs([]skydb.RecordID{skydb.NewRecordID("note", "someid")}))
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+
+			record.DeletedAt = nil
+			err = db.Save(&record)
+			So(err, ShouldBeNil)
+
+			count, err := db.QueryCount(&skydb.Query{Type: "note"})
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+
 		Convey("returns ErrRecordNotFound when record to delete doesn't exist", func() {
 			err := db.Delete(skydb.NewRecordID("note", "notexistid"))
 			So(err, ShouldEqual, skydb.ErrRecordNotFound)
@@ -703,13 +883,295 @@ func TestQuery(t *testing.T) {
 					},
 				},
 			}
-			*query.Limit = 2
-			records, err := exhaustRows(db.Query(&query))
+			*query.Limit = 2
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records[0], ShouldResemble, record2)
+			So(records[1], ShouldResemble, record1)
+			So(len(records), ShouldEqual, 2)
+		})
+
+		Convey("query records in seeded random order", func() {
+			seed := 0.25
+			query := skydb.Query{
+				Type: "note",
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.Function,
+							Value: skydb.RandomFunc{Seed: &seed},
+						},
+						Order: skydb.Ascending,
+					},
+				},
+			}
+			records, err := exhaustRows(db.Query(&query))
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 3)
+
+			repeated, err := exhaustRows(db.Query(&query))
+			So(err, ShouldBeNil)
+			So(repeated, ShouldResemble, records)
+		})
+
+		Convey("query records in random order by keyset pagination", func() {
+			query := skydb.Query{
+				Type:     "note",
+				PageSize: 2,
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.Function,
+							Value: skydb.RandomFunc{},
+						},
+						Order: skydb.Ascending,
+					},
+				},
+			}
+			_, err := db.Query(&query)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("query records with explain", func() {
+			query := skydb.Query{
+				Type:    "note",
+				Explain: true,
+			}
+			rows, err := db.Query(&query)
+			So(err, ShouldBeNil)
+			defer rows.Close()
+
+			plan, ok := rows.QueryPlan().([]interface{})
+			So(ok, ShouldBeTrue)
+			So(plan, ShouldHaveLength, 1)
+			So(plan[0], ShouldContainKey, "Plan")
+		})
+
+		Convey("query records with nulls first", func() {
+			query := skydb.Query{
+				Type: "note",
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "emotion",
+						},
+						Order: skydb.Ascending,
+						Nulls: skydb.NullsFirst,
+					},
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "noteOrder",
+						},
+						Order: skydb.Ascending,
+					},
+				},
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record1, record2, record3})
+		})
+
+		Convey("query records with nulls last by keyset pagination", func() {
+			query := skydb.Query{
+				Type:     "note",
+				PageSize: 2,
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "emotion",
+						},
+						Order: skydb.Ascending,
+						Nulls: skydb.NullsLast,
+					},
+				},
+			}
+			rows, err := db.Query(&query)
+			records, err := exhaustRows(rows, err)
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{
+				record3,
+				record1,
+			})
+			So(rows.NextCursor(), ShouldResemble, &skydb.Cursor{
+				Values: []interface{}{nil},
+				Key:    "id1",
+			})
+
+			query.After = rows.NextCursor()
+			rows, err = db.Query(&query)
+			records, err = exhaustRows(rows, err)
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{
+				record2,
+			})
+			So(rows.NextCursor(), ShouldBeNil)
+		})
+
+		Convey("query records with nulls first by keyset pagination", func() {
+			query := skydb.Query{
+				Type:     "note",
+				PageSize: 2,
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "emotion",
+						},
+						Order: skydb.Ascending,
+						Nulls: skydb.NullsFirst,
+					},
+				},
+			}
+			rows, err := db.Query(&query)
+			records, err := exhaustRows(rows, err)
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{
+				record1,
+				record2,
+			})
+			So(rows.NextCursor(), ShouldResemble, &skydb.Cursor{
+				Values: []interface{}{nil},
+				Key:    "id2",
+			})
+
+			query.After = rows.NextCursor()
+			rows, err = db.Query(&query)
+			records, err = exhaustRows(rows, err)
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{
+				record3,
+			})
+			So(rows.NextCursor(), ShouldBeNil)
+		})
+
+		Convey("query records distinct on keypath", func() {
+			query := skydb.Query{
+				Type:       "note",
+				DistinctOn: []string{"emotion"},
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "noteOrder",
+						},
+						Order: skydb.Descending,
+					},
+				},
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{
+				record3,
+				record2,
+			})
+		})
+
+		Convey("query records distinct on keypath by keyset pagination", func() {
+			query := skydb.Query{
+				Type:       "note",
+				DistinctOn: []string{"emotion"},
+				PageSize:   1,
+			}
+			_, err := db.Query(&query)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("query records by keyset pagination", func() {
+			query := skydb.Query{
+				Type:     "note",
+				PageSize: 2,
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "noteOrder",
+						},
+						Order: skydb.Descending,
+					},
+				},
+			}
+			rows, err := db.Query(&query)
+			records, err := exhaustRows(rows, err)
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{
+				record3,
+				record2,
+			})
+			So(rows.NextCursor(), ShouldResemble, &skydb.Cursor{
+				Values: []interface{}{float64(2)},
+				Key:    "id2",
+			})
+
+			query.After = rows.NextCursor()
+			rows, err = db.Query(&query)
+			records, err = exhaustRows(rows, err)
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{
+				record1,
+			})
+			So(rows.NextCursor(), ShouldBeNil)
+		})
+
+		Convey("query records by keyset pagination with mixed sort orders", func() {
+			query := skydb.Query{
+				Type:     "note",
+				PageSize: 1,
+				After: &skydb.Cursor{
+					Values: []interface{}{float64(3), "Good Hello"},
+					Key:    "id3",
+				},
+				Sorts: []skydb.Sort{
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "noteOrder",
+						},
+						Order: skydb.Descending,
+					},
+					skydb.Sort{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "content",
+						},
+						Order: skydb.Ascending,
+					},
+				},
+			}
+			rows, err := db.Query(&query)
+			records, err := exhaustRows(rows, err)
 
 			So(err, ShouldBeNil)
-			So(records[0], ShouldResemble, record2)
-			So(records[1], ShouldResemble, record1)
-			So(len(records), ShouldEqual, 2)
+			So(records, ShouldResemble, []skydb.Record{
+				record2,
+			})
+			So(rows.NextCursor(), ShouldResemble, &skydb.Cursor{
+				Values: []interface{}{float64(2), "Bye World"},
+				Key:    "id2",
+			})
+		})
+
+		Convey("query records by cursor not matching sorts", func() {
+			query := skydb.Query{
+				Type: "note",
+				After: &skydb.Cursor{
+					Values: []interface{}{float64(3)},
+					Key:    "id3",
+				},
+			}
+			_, err := db.Query(&query)
+			So(err, ShouldNotBeNil)
 		})
 
 		Convey("query records for nil item", func() {
@@ -896,6 +1358,104 @@ func TestQuery(t *testing.T) {
 			So(len(records), ShouldEqual, 1)
 			So(records[0], ShouldResemble, record3)
 		})
+
+		Convey("query records sorted by field in a referenced record", func() {
+			query := skydb.Query{
+				Type: "note",
+				Sorts: []skydb.Sort{
+					{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "category.hidden",
+						},
+						Order: skydb.Desc,
+					},
+				},
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record1, record3, record2})
+		})
+
+		Convey("query records with referenced records included", func() {
+			query := skydb.Query{
+				Type:     "note",
+				Includes: []string{"category"},
+				Sorts: []skydb.Sort{
+					{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "noteOrder",
+						},
+						Order: skydb.Asc,
+					},
+				},
+			}
+			rows, err := db.Query(&query)
+			So(err, ShouldBeNil)
+
+			records, err := exhaustRows(rows, err)
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record1, record2, record3})
+			So(rows.IncludedRecords(), ShouldResemble, map[string]map[string]*skydb.Record{
+				"category": {
+					"important": &category1,
+					"funny":     &category2,
+				},
+			})
+		})
+
+		Convey("query records with computed columns", func() {
+			negatedOrder := skydb.Expression{
+				Type: skydb.Function,
+				Value: skydb.ArithmeticFunc{
+					Operator: skydb.Multiply,
+					Left:     skydb.Expression{Type: skydb.KeyPath, Value: "noteOrder"},
+					Right:    skydb.Expression{Type: skydb.Literal, Value: float64(-1)},
+				},
+			}
+			query := skydb.Query{
+				Type: "note",
+				ComputedKeys: map[string]skydb.Expression{
+					"negatedOrder": negatedOrder,
+					"label": skydb.Expression{
+						Type: skydb.Function,
+						Value: skydb.ConcatFunc{
+							Expressions: []skydb.Expression{
+								{Type: skydb.Literal, Value: "note-"},
+								{Type: skydb.KeyPath, Value: "noteOrder"},
+							},
+						},
+					},
+				},
+				Sorts: []skydb.Sort{
+					{
+						Expression: negatedOrder,
+						Order:      skydb.Asc,
+					},
+				},
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 3)
+			So(records[0].ID, ShouldResemble, record3.ID)
+			So(records[0].Transient, ShouldResemble, skydb.Data{
+				"negatedOrder": float64(-3),
+				"label":        "note-3",
+			})
+			So(records[2].ID, ShouldResemble, record1.ID)
+		})
+
+		Convey("query records including non-reference field", func() {
+			query := skydb.Query{
+				Type:     "note",
+				Includes: []string{"noteOrder"},
+			}
+			_, err := db.Query(&query)
+			So(err, ShouldNotBeNil)
+		})
 	})
 
 	Convey("Database with location", t, func() {
@@ -1457,6 +2017,53 @@ func TestQuery(t *testing.T) {
 			So(records, ShouldResemble, []skydb.Record{record2, record4, record5})
 		})
 
+		Convey("can be queried by inherited role", func() {
+			query := skydb.Query{
+				Type: "note",
+				ViewAsUser: &skydb.AuthInfo{
+					ID:             "carol",
+					Roles:          []string{"sales-director"},
+					InheritedRoles: []string{"marketing"},
+				},
+				Sorts: sortsByID,
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record2, record4, record5})
+		})
+
+		Convey("cannot be queried by denied user", func() {
+			record6 := skydb.Record{
+				ID:      skydb.NewRecordID("note", "id6"),
+				OwnerID: "alice",
+				ACL: skydb.RecordACL{
+					skydb.NewRecordACLEntryPublic(skydb.ReadLevel),
+					skydb.NewRecordACLEntryDeny(skydb.NewRecordACLEntryDirect("bob", skydb.ReadLevel)),
+				},
+			}
+			So(db.Save(&record6), ShouldBeNil)
+
+			query := skydb.Query{
+				Type:       "note",
+				ViewAsUser: &skydb.AuthInfo{ID: "bob"},
+				Sorts:      sortsByID,
+			}
+			records, err := exhaustRows(db.Query(&query))
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record2, record3, record5})
+
+			query.ViewAsUser = &skydb.AuthInfo{ID: "carol"}
+			records, err = exhaustRows(db.Query(&query))
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record2, record6})
+
+			query.ViewAsUser = &skydb.AuthInfo{ID: "alice"}
+			records, err = exhaustRows(db.Query(&query))
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 6)
+		})
+
 		Convey("can be queried by explicit user and role", func() {
 			query := skydb.Query{
 				Type: "note",
@@ -1613,6 +2220,17 @@ func TestQueryCount(t *testing.T) {
 			So(count, ShouldEqual, 3)
 		})
 
+		Convey("estimate count of records", func() {
+			query := skydb.Query{
+				Type:          "note",
+				EstimateCount: true,
+			}
+			_, err := db.QueryCount(&query)
+
+			// The estimate depends on the table statistics.
+			So(err, ShouldBeNil)
+		})
+
 		Convey("count records by content matching", func() {
 			query := skydb.Query{
 				Type: "note",
@@ -2166,6 +2784,158 @@ func TestUserRelationQuery(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(count, ShouldEqual, 2)
 		})
+
+		Convey("query NOT follow outward", func() {
+			query := skydb.Query{
+				Type: "record",
+				Predicate: skydb.Predicate{
+					Operator: skydb.Not,
+					Children: []interface{}{
+						skydb.Predicate{
+							Operator: skydb.Functional,
+							Children: []interface{}{
+								skydb.Expression{
+									Type:  skydb.Function,
+									Value: skydb.UserRelationFunc{"_owner", "_follow", "outward", "user1"},
+								},
+							},
+						},
+					},
+				},
+				Sorts: sortsByID,
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record0, record3, record4})
+		})
+
+		Convey("query NOT follow mutual", func() {
+			query := skydb.Query{
+				Type: "record",
+				Predicate: skydb.Predicate{
+					Operator: skydb.Not,
+					Children: []interface{}{
+						skydb.Predicate{
+							Operator: skydb.Functional,
+							Children: []interface{}{
+								skydb.Expression{
+									Type:  skydb.Function,
+									Value: skydb.UserRelationFunc{"_owner", "_follow", "mutual", "user1"},
+								},
+							},
+						},
+					},
+				},
+				Sorts: sortsByID,
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record0, record1, record3, record4})
+		})
+
+		Convey("query friend mutual AND NOT follow outward", func() {
+			query := skydb.Query{
+				Type: "record",
+				Predicate: skydb.Predicate{
+					Operator: skydb.And,
+					Children: []interface{}{
+						skydb.Predicate{
+							Operator: skydb.Functional,
+							Children: []interface{}{
+								skydb.Expression{
+									Type:  skydb.Function,
+									Value: skydb.UserRelationFunc{"_owner", "_friend", "mutual", "user4"},
+								},
+							},
+						},
+						skydb.Predicate{
+							Operator: skydb.Not,
+							Children: []interface{}{
+								skydb.Predicate{
+									Operator: skydb.Functional,
+									Children: []interface{}{
+										skydb.Expression{
+											Type:  skydb.Function,
+											Value: skydb.UserRelationFunc{"_owner", "_follow", "outward", "user4"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Sorts: sortsByID,
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record0})
+		})
+
+		Convey("query follow outward of two users", func() {
+			query := skydb.Query{
+				Type: "record",
+				Predicate: skydb.Predicate{
+					Operator: skydb.And,
+					Children: []interface{}{
+						skydb.Predicate{
+							Operator: skydb.Functional,
+							Children: []interface{}{
+								skydb.Expression{
+									Type:  skydb.Function,
+									Value: skydb.UserRelationFunc{"_owner", "_friend", "outward", "user1"},
+								},
+							},
+						},
+						skydb.Predicate{
+							Operator: skydb.Functional,
+							Children: []interface{}{
+								skydb.Expression{
+									Type:  skydb.Function,
+									Value: skydb.UserRelationFunc{"_owner", "_friend", "outward", "user5"},
+								},
+							},
+						},
+					},
+				},
+				Sorts: sortsByID,
+			}
+			records, err := exhaustRows(db.Query(&query))
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record3})
+		})
+
+		Convey("query follow outward with count and limit", func() {
+			query := skydb.Query{
+				Type: "record",
+				Predicate: skydb.Predicate{
+					Operator: skydb.Functional,
+					Children: []interface{}{
+						skydb.Expression{
+							Type:  skydb.Function,
+							Value: skydb.UserRelationFunc{"_owner", "_follow", "outward", "user1"},
+						},
+					},
+				},
+				Sorts:    sortsByID,
+				GetCount: true,
+				Limit:    new(uint64),
+				Offset:   1,
+			}
+			*query.Limit = 1
+			rows, err := db.Query(&query)
+			records, err := exhaustRows(rows, err)
+
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []skydb.Record{record2})
+
+			recordCount := rows.OverallRecordCount()
+			So(recordCount, ShouldNotBeNil)
+			So(*recordCount, ShouldEqual, 2)
+		})
 	})
 }
 
@@ -2281,6 +3051,7 @@ func TestRecordJSON(t *testing.T) {
 						"bool":   false,
 					},
 				},
+				Revision: 1,
 			})
 		})
 
@@ -2382,7 +3153,8 @@ func TestRecordAssetField(t *testing.T) {
 				Data: map[string]interface{}{
 					"image": &skydb.Asset{Name: "picture.png"},
 				},
-				OwnerID: "user_id",
+				OwnerID:  "user_id",
+				Revision: 1,
 			})
 		})
 	})
@@ -2418,7 +3190,8 @@ func TestRecordLocationField(t *testing.T) {
 				Data: map[string]interface{}{
 					"location": skydb.NewLocation(1, 2),
 				},
-				OwnerID: "userid",
+				OwnerID:  "userid",
+				Revision: 1,
 			})
 		})
 	})
@@ -2448,7 +3221,8 @@ func TestRecordSequenceField(t *testing.T) {
 				Data: map[string]interface{}{
 					"seq": int64(1),
 				},
-				OwnerID: "userid",
+				OwnerID:  "userid",
+				Revision: 1,
 			})
 
 			record = skydb.Record{
@@ -2463,7 +3237,8 @@ func TestRecordSequenceField(t *testing.T) {
 				Data: map[string]interface{}{
 					"seq": int64(2),
 				},
-				OwnerID: "userid",
+				OwnerID:  "userid",
+				Revision: 1,
 			})
 		})
 
@@ -2484,7 +3259,8 @@ func TestRecordSequenceField(t *testing.T) {
 				Data: map[string]interface{}{
 					"seq": int64(10),
 				},
-				OwnerID: "userid",
+				OwnerID:  "userid",
+				Revision: 2,
 			})
 
 			// next record should's seq value should be 11
@@ -2498,7 +3274,8 @@ func TestRecordSequenceField(t *testing.T) {
 				Data: map[string]interface{}{
 					"seq": int64(11),
 				},
-				OwnerID: "userid",
+				OwnerID:  "userid",
+				Revision: 1,
 			})
 		})
 	})
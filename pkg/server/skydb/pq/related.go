@@ -0,0 +1,166 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/lann/squirrel"
+	"github.com/lib/pq"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// QueryRelated queries the first page of the related records of all keys
+// in a single statement. The related query is joined laterally with the
+// keys, so that the records referencing each key are sorted and limited
+// separately.
+func (db *database) QueryRelated(related *skydb.RelatedQuery, keys []string) (map[string]*skydb.RelatedPage, error) {
+	query := related.Query
+	if query.Type == "" {
+		return nil, errors.New("got empty query type")
+	}
+
+	switch {
+	case query.PageSize == 0:
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"page size is required for related query")
+	case query.After != nil, query.Offset > 0:
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"related query always starts from the first page")
+	case len(query.Includes) > 0, len(query.RelatedIncludes) > 0, query.AsOf != nil, query.GetCount:
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"includes, count and as of cannot be used with related query")
+	}
+
+	pages := map[string]*skydb.RelatedPage{}
+	if len(keys) == 0 {
+		return pages, nil
+	}
+
+	typemap, err := db.RemoteColumnTypes(query.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(typemap) == 0 { // record type has not been created
+		return pages, nil
+	}
+
+	if fieldType, ok := typemap[related.Field]; !ok || fieldType.Type != skydb.TypeReference {
+		return nil, skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			`field "%s" of related query is not a reference`, related.Field)
+	}
+
+	sel, err := db.selectRecordQuery(&query, typemap)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.c.readQueryWith(relatedSelect{
+		inner: sel.builder.Where(fmt.Sprintf(`%s.%s = "_parent"."_key"`,
+			pq.QuoteIdentifier(query.Type), pq.QuoteIdentifier(related.Field))),
+		sorts: query.Sorts,
+		keys:  keys,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scanTypemap := skydb.RecordSchema{
+		"_parent_key": skydb.FieldType{Type: skydb.TypeString},
+	}
+	for column, fieldType := range sel.typemap {
+		scanTypemap[column] = fieldType
+	}
+	rs := newRecordScanner(query.Type, scanTypemap, rows)
+	rs.cursorColumns = sel.cursorColumns
+	rs.pageSize = query.PageSize
+
+	// the cursor of the last record of each page
+	cursors := map[string]*skydb.Cursor{}
+	for rows.Next() {
+		record := skydb.Record{}
+		if err := rs.Scan(&record); err != nil {
+			return nil, err
+		}
+		key, _ := record.Data["_parent_key"].(string)
+		delete(record.Data, "_parent_key")
+
+		page, ok := pages[key]
+		if !ok {
+			page = &skydb.RelatedPage{}
+			pages[key] = page
+		}
+		if uint64(len(page.Records)) >= query.PageSize {
+			// the extra record fetched tells there is a next page
+			page.NextCursor = cursors[key]
+			continue
+		}
+		page.Records = append(page.Records, record)
+		cursors[key] = rs.cursor
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// relatedSelect joins the select statement of a related query laterally
+// with the keys of the referenced records, which are referred to as
+// "_parent"."_key" in the statement.
+type relatedSelect struct {
+	inner sq.SelectBuilder
+	sorts []skydb.Sort
+	keys  []string
+}
+
+func (s relatedSelect) ToSql() (sql string, args []interface{}, err error) {
+	innerSQL, innerArgs, err := s.inner.PlaceholderFormat(sq.Question).ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The related records of a key are returned in the order of the
+	// keyset sorts, which are selected as the cursor columns.
+	orderBy := []string{`"_parent"."_ord"`}
+	order := skydb.Ascending
+	for i, sort := range s.sorts {
+		column := `"_related".` + pq.QuoteIdentifier(fmt.Sprintf("_cursor_%d", i))
+		orderBy = append(orderBy, column+" "+sortOrderSQL(sort.Order))
+		order = sort.Order
+	}
+	orderBy = append(orderBy, `"_related"."_id" `+sortOrderSQL(order))
+
+	sql = fmt.Sprintf(`SELECT "_parent"."_key" AS "_parent_key", "_related".* `+
+		`FROM unnest(?::text[]) WITH ORDINALITY AS "_parent"("_key", "_ord") `+
+		`CROSS JOIN LATERAL (%s) AS "_related" ORDER BY %s`,
+		innerSQL, strings.Join(orderBy, ", "))
+	args = append([]interface{}{pq.Array(s.keys)}, innerArgs...)
+	sql, err = sq.Dollar.ReplacePlaceholders(sql)
+	return
+}
+
+func sortOrderSQL(order skydb.SortOrder) string {
+	if order == skydb.Descending {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+var _ skydb.RelatedQuerier = &database{}
@@ -0,0 +1,156 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestQueryRelated(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("post", skydb.RecordSchema{
+			"title": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+		_, err = db.Extend("comment", skydb.RecordSchema{
+			"post":  skydb.FieldType{Type: skydb.TypeReference, ReferenceType: "post"},
+			"order": skydb.FieldType{Type: skydb.TypeNumber},
+		})
+		So(err, ShouldBeNil)
+
+		for _, post := range []string{"p1", "p2", "p3"} {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("post", post),
+				OwnerID: "user",
+				Data:    map[string]interface{}{"title": post},
+			}), ShouldBeNil)
+		}
+		comments := map[string]int{"p1": 4, "p2": 1}
+		for post, count := range comments {
+			for i := 0; i < count; i++ {
+				So(db.Save(&skydb.Record{
+					ID:      skydb.NewRecordID("comment", fmt.Sprintf("%s-%d", post, i)),
+					OwnerID: "user",
+					Data: map[string]interface{}{
+						"post":  skydb.NewReference("post", post),
+						"order": float64(i),
+					},
+				}), ShouldBeNil)
+			}
+		}
+
+		related := &skydb.RelatedQuery{
+			Query: skydb.Query{
+				Type: "comment",
+				Sorts: []skydb.Sort{
+					{
+						Expression: skydb.Expression{
+							Type:  skydb.KeyPath,
+							Value: "order",
+						},
+						Order: skydb.Descending,
+					},
+				},
+				PageSize:            2,
+				BypassAccessControl: true,
+			},
+			Field: "post",
+		}
+
+		pageKeys := func(page *skydb.RelatedPage) []string {
+			keys := []string{}
+			for _, record := range page.Records {
+				keys = append(keys, record.ID.Key)
+			}
+			return keys
+		}
+
+		Convey("limits the related records of each record", func() {
+			pages, err := db.(skydb.RelatedQuerier).QueryRelated(related, []string{"p1", "p2", "p3"})
+			So(err, ShouldBeNil)
+			So(pages, ShouldHaveLength, 2)
+
+			So(pageKeys(pages["p1"]), ShouldResemble, []string{"p1-3", "p1-2"})
+			So(pages["p1"].Records[0].Data, ShouldNotContainKey, "_parent_key")
+			So(pages["p1"].NextCursor, ShouldResemble, &skydb.Cursor{
+				Values: []interface{}{float64(2)},
+				Key:    "p1-2",
+			})
+
+			So(pageKeys(pages["p2"]), ShouldResemble, []string{"p2-0"})
+			So(pages["p2"].NextCursor, ShouldBeNil)
+		})
+
+		Convey("fetches the next page with the cursor", func() {
+			pages, err := db.(skydb.RelatedQuerier).QueryRelated(related, []string{"p1"})
+			So(err, ShouldBeNil)
+
+			query := related.Query
+			query.Predicate = skydb.Predicate{
+				Operator: skydb.Equal,
+				Children: []interface{}{
+					skydb.Expression{Type: skydb.KeyPath, Value: "post"},
+					skydb.Expression{Type: skydb.Literal, Value: skydb.NewReference("post", "p1")},
+				},
+			}
+			query.After = pages["p1"].NextCursor
+			records, err := exhaustRows(db.Query(&query))
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 2)
+			So(records[0].ID.Key, ShouldEqual, "p1-1")
+			So(records[1].ID.Key, ShouldEqual, "p1-0")
+		})
+
+		Convey("returns the same pages as querying one by one", func() {
+			pages, err := db.(skydb.RelatedQuerier).QueryRelated(related, []string{"p1", "p2"})
+			So(err, ShouldBeNil)
+
+			for _, key := range []string{"p1", "p2"} {
+				query := related.Query
+				query.Predicate = skydb.Predicate{
+					Operator: skydb.Equal,
+					Children: []interface{}{
+						skydb.Expression{Type: skydb.KeyPath, Value: "post"},
+						skydb.Expression{Type: skydb.Literal, Value: skydb.NewReference("post", key)},
+					},
+				}
+				records, err := exhaustRows(db.Query(&query))
+				So(err, ShouldBeNil)
+				So(pages[key].Records, ShouldResemble, records)
+			}
+		})
+
+		Convey("rejects related query without page size", func() {
+			related.PageSize = 0
+			_, err := db.(skydb.RelatedQuerier).QueryRelated(related, []string{"p1"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rejects field which is not a reference", func() {
+			related.Field = "order"
+			_, err := db.(skydb.RelatedQuerier).QueryRelated(related, []string{"p1"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -105,9 +105,11 @@ func (c *conn) AddRelation(user string, name string, targetUser string) error {
 		if isForeignKeyViolated(err) {
 			return fmt.Errorf("userID not exist")
 		}
+		return err
 	}
 
-	return err
+	c.invalidateQueryCache(name)
+	return nil
 }
 
 func (c *conn) RemoveRelation(user string, name string, targetUser string) error {
@@ -128,5 +130,7 @@ func (c *conn) RemoveRelation(user string, name string, targetUser string) error
 	} else if rowsAffected > 1 {
 		panic(fmt.Errorf("want 1 rows updated, got %v", rowsAffected))
 	}
+
+	c.invalidateQueryCache(name)
 	return nil
 }
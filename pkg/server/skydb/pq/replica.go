@@ -0,0 +1,93 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// replicaOptions are the connection strings of the read replicas of the
+// database.
+var replicaOptions []string
+
+// SetReplicaOptions sets the connection strings of the read replicas,
+// which serve the record reads of connections that have not written to
+// the primary database. It only affects databases opened after the call,
+// and should be called before opening any connection.
+func SetReplicaOptions(options []string) {
+	replicaOptions = options
+}
+
+// replica is a read replica of the database, with its own cache of
+// prepared statements.
+type replica struct {
+	db        *sqlx.DB
+	stmtCache *stmtCache
+}
+
+// openReplica opens the replica of the connection string. Unlike the
+// primary database, the schema of a replica is not migrated.
+func openReplica(connString string) (*replica, error) {
+	db, err := openPool(connString)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &replica{db: db}
+	if statementCacheSize > 0 {
+		r.stmtCache = newStmtCache(db, statementCacheSize)
+	}
+	return r, nil
+}
+
+// target is the database a statement is executed on.
+type target struct {
+	db        ExtContext
+	stmtCache *stmtCache
+	replica   bool
+}
+
+// primary returns the primary database, or the transaction in effect.
+func (c *conn) primary() target {
+	return target{db: c.Db(), stmtCache: c.stmtCache}
+}
+
+var replicaCounter uint64
+
+// reader returns a replica to read records from, chosen in turn. The
+// primary database is returned when there is no replica, when the context
+// does not allow reading from replicas, in a transaction, or after the
+// connection has written to the primary database, so that the connection
+// reads its own writes.
+func (c *conn) reader() target {
+	if len(c.replicas) == 0 || !skydb.ReplicaReadFromContext(c.context) || c.tx != nil || c.wrote {
+		return c.primary()
+	}
+
+	r := c.replicas[atomic.AddUint64(&replicaCounter, 1)%uint64(len(c.replicas))]
+	return target{db: r.db, stmtCache: r.stmtCache, replica: true}
+}
+
+// isReadStatement returns whether the statement only reads from the
+// database. Statements other than SELECT are assumed to write.
+func isReadStatement(query string) bool {
+	fields := strings.Fields(query)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "SELECT")
+}
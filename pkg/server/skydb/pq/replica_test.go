@@ -0,0 +1,86 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestIsReadStatement(t *testing.T) {
+	Convey("isReadStatement", t, func() {
+		So(isReadStatement("SELECT 1"), ShouldBeTrue)
+		So(isReadStatement("\n\tselect * FROM note"), ShouldBeTrue)
+		So(isReadStatement("INSERT INTO note VALUES (1)"), ShouldBeFalse)
+		So(isReadStatement("WITH deleted AS (DELETE FROM note) SELECT 1"), ShouldBeFalse)
+		So(isReadStatement(""), ShouldBeFalse)
+	})
+}
+
+func TestReplicaRead(t *testing.T) {
+	Convey("Conn with a replica", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+		So(db.Save(&skydb.Record{
+			ID:      skydb.NewRecordID("note", "1"),
+			OwnerID: "user",
+			Data:    map[string]interface{}{"content": "hello"},
+		}), ShouldBeNil)
+
+		// The test database stands in for its replica.
+		r, err := openReplica("")
+		So(err, ShouldBeNil)
+		defer r.db.Close()
+		c.replicas = []*replica{r}
+		c.wrote = false
+
+		Convey("reads from the primary unless allowed by the context", func() {
+			So(c.reader().replica, ShouldBeFalse)
+		})
+
+		Convey("reads records from the replica", func() {
+			c.context = skydb.ContextWithReplicaRead(c.context)
+			So(c.reader().replica, ShouldBeTrue)
+
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &record), ShouldBeNil)
+			So(record.Data["content"], ShouldEqual, "hello")
+			So(c.wrote, ShouldBeFalse)
+		})
+
+		Convey("reads from the primary after writing", func() {
+			c.context = skydb.ContextWithReplicaRead(c.context)
+			So(db.Delete(skydb.NewRecordID("note", "1")), ShouldBeNil)
+			So(c.wrote, ShouldBeTrue)
+			So(c.reader().replica, ShouldBeFalse)
+		})
+
+		Convey("reads from the primary in a transaction", func() {
+			c.context = skydb.ContextWithReplicaRead(c.context)
+			So(c.Begin(), ShouldBeNil)
+			defer c.Rollback()
+			So(c.reader().replica, ShouldBeFalse)
+		})
+	})
+}
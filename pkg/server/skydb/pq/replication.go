@@ -0,0 +1,105 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReplicationProber checks and promotes PostgreSQL servers for failing
+// over to a standby database. It implements failover.Prober.
+type ReplicationProber struct{}
+
+// Ping verifies that the database server responds.
+func (p ReplicationProber) Ping(connString string) error {
+	return CheckConnection(connString)
+}
+
+// ReplicationLag returns the time since the last transaction replayed
+// by the replica. Zero is returned if the database is not a replica.
+func (p ReplicationProber) ReplicationLag(connString string) (time.Duration, error) {
+	db, err := sqlx.Open("postgres", connString)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var seconds float64
+	err = db.QueryRowx(`
+		SELECT CASE WHEN pg_is_in_recovery()
+		THEN COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		ELSE 0 END`).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// promotionLockKey is the key of the advisory lock held on the replica
+// while promoting it. An advisory lock of the replica is shared by all
+// servers connected to the replica.
+const promotionLockKey = 7466290512
+
+// LockPromotion acquires the advisory lock of promoting the replica. The
+// lock is held by a session, so the connection is kept open until the
+// lock is released.
+func (p ReplicationProber) LockPromotion(connString string) (func(), bool, error) {
+	db, err := sqlx.Open("postgres", connString)
+	if err != nil {
+		return nil, false, err
+	}
+	db.SetMaxOpenConns(1)
+
+	var locked bool
+	if err := db.QueryRowx(`SELECT pg_try_advisory_lock($1)`, promotionLockKey).Scan(&locked); err != nil {
+		db.Close()
+		return nil, false, err
+	}
+	if !locked {
+		db.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		if _, err := db.Exec(`SELECT pg_advisory_unlock($1)`, promotionLockKey); err != nil {
+			log.WithField("err", err).Warnln("Failed to release promotion lock")
+		}
+		db.Close()
+	}
+	return unlock, true, nil
+}
+
+// Promote promotes the replica to accept writes and waits for the
+// promotion to complete. It is a no-op if the database is not a replica.
+func (p ReplicationProber) Promote(connString string) error {
+	db, err := sqlx.Open("postgres", connString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var inRecovery bool
+	if err := db.QueryRowx(`SELECT pg_is_in_recovery()`).Scan(&inRecovery); err != nil {
+		return err
+	}
+	if !inRecovery {
+		return nil
+	}
+
+	var promoted bool
+	return db.QueryRowx(`SELECT pg_promote(true)`).Scan(&promoted)
+}
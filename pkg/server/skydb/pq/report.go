@@ -0,0 +1,136 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) SaveReport(report *skydb.Report) error {
+	query, err := json.Marshal(report.Query)
+	if err != nil {
+		return err
+	}
+	roles, err := json.Marshal(report.Roles)
+	if err != nil {
+		return err
+	}
+
+	// The last run time is kept when the definition is replaced, so
+	// that the report is not run again for the same scheduled time.
+	builder := psql.Insert(c.tableName("_report")).
+		Columns("id", "name", "query", "schedule", "roles", "updated_at").
+		Values(report.ID, report.Name, string(query), report.Schedule,
+			string(roles), report.UpdatedAt.UTC()).
+		Suffix(`ON CONFLICT (id) DO UPDATE
+			SET name = EXCLUDED.name, query = EXCLUDED.query,
+			schedule = EXCLUDED.schedule, roles = EXCLUDED.roles,
+			updated_at = EXCLUDED.updated_at`)
+
+	_, err = c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) GetReports() ([]skydb.Report, error) {
+	builder := psql.Select("id", "name", "query", "schedule", "roles",
+		"updated_at", "last_run_at").
+		From(c.tableName("_report")).
+		OrderBy("id")
+
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []skydb.Report{}
+	for rows.Next() {
+		var (
+			report    skydb.Report
+			query     []byte
+			roles     []byte
+			lastRunAt *time.Time
+		)
+		if err := rows.Scan(
+			&report.ID,
+			&report.Name,
+			&query,
+			&report.Schedule,
+			&roles,
+			&report.UpdatedAt,
+			&lastRunAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(query, &report.Query); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(roles, &report.Roles); err != nil {
+			return nil, err
+		}
+		report.UpdatedAt = report.UpdatedAt.In(time.UTC)
+		if lastRunAt != nil {
+			utc := lastRunAt.In(time.UTC)
+			report.LastRunAt = &utc
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (c *conn) ClaimReportRun(id string, runAt time.Time) (bool, error) {
+	runAt = runAt.UTC()
+	builder := psql.Update(c.tableName("_report")).
+		Set("last_run_at", runAt).
+		Where("id = ?", id).
+		Where("(last_run_at IS NULL OR last_run_at < ?)", runAt)
+
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (c *conn) DeleteReport(id string) error {
+	builder := psql.Delete(c.tableName("_report")).
+		Where("id = ?", id)
+
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return skydb.ErrReportNotFound
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestReport(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		report := skydb.Report{
+			ID:        "orders",
+			Name:      "Daily orders",
+			Query:     map[string]interface{}{"record_type": "order"},
+			Schedule:  "0 0 0 * * *",
+			Roles:     []string{"manager"},
+			UpdatedAt: now,
+		}
+		So(c.SaveReport(&report), ShouldBeNil)
+
+		Convey("gets reports", func() {
+			reports, err := c.GetReports()
+			So(err, ShouldBeNil)
+			So(reports, ShouldResemble, []skydb.Report{report})
+		})
+
+		Convey("claims each run once", func() {
+			runAt := now.Add(24 * time.Hour)
+			claimed, err := c.ClaimReportRun("orders", runAt)
+			So(err, ShouldBeNil)
+			So(claimed, ShouldBeTrue)
+
+			claimed, err = c.ClaimReportRun("orders", runAt)
+			So(err, ShouldBeNil)
+			So(claimed, ShouldBeFalse)
+
+			reports, err := c.GetReports()
+			So(err, ShouldBeNil)
+			So(*reports[0].LastRunAt, ShouldResemble, runAt)
+		})
+
+		Convey("keeps last run time when replacing report", func() {
+			runAt := now.Add(24 * time.Hour)
+			_, err := c.ClaimReportRun("orders", runAt)
+			So(err, ShouldBeNil)
+
+			report.Name = "Orders"
+			So(c.SaveReport(&report), ShouldBeNil)
+
+			reports, err := c.GetReports()
+			So(err, ShouldBeNil)
+			So(reports[0].Name, ShouldEqual, "Orders")
+			So(*reports[0].LastRunAt, ShouldResemble, runAt)
+		})
+
+		Convey("deletes report", func() {
+			So(c.DeleteReport("orders"), ShouldBeNil)
+
+			reports, err := c.GetReports()
+			So(err, ShouldBeNil)
+			So(reports, ShouldBeEmpty)
+
+			So(c.DeleteReport("orders"), ShouldEqual, skydb.ErrReportNotFound)
+		})
+	})
+}
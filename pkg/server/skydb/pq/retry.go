@@ -0,0 +1,144 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// RetryPolicy configures the retry of statements failed with transient
+// errors, such as serialization failures and connections reset when the
+// database fails over.
+//
+// Only statements reading from the database outside of a transaction are
+// retried, since they can be executed again without side effect. A read
+// from a replica is retried on the primary database.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries of a statement. Zero
+	// means statements are not retried.
+	MaxRetries int
+
+	// InitialBackoff is the wait before the first retry, which is doubled
+	// for each subsequent retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum wait before a retry.
+	MaxBackoff time.Duration
+}
+
+// retryPolicy is the policy of retrying statements of all connections.
+var retryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: 50 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// SetRetryPolicy sets the policy of retrying statements failed with
+// transient errors.
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicy = policy
+}
+
+// backoff returns the wait before the retry of the attempt, counting
+// from zero.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// isTransientError returns true if the error is likely to go away when the
+// statement is executed again.
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// connection_exception
+		return pqErr.Code.Class() == "08"
+	}
+	return err == driver.ErrBadConn ||
+		err == io.EOF ||
+		err == io.ErrUnexpectedEOF ||
+		isNetworkError(err)
+}
+
+// retry executes the statement on the target by calling fn, and executes
+// it again as configured by retryPolicy if it fails with a transient
+// error.
+func (c *conn) retry(ctx context.Context, t target, query string, fn func(target) error) error {
+	err := fn(t)
+	if c.tx != nil || !isReadStatement(query) {
+		return err
+	}
+
+	for attempt := 0; attempt < retryPolicy.MaxRetries && isTransientError(err); attempt++ {
+		log.WithFields(logrus.Fields{
+			"sql":     query,
+			"error":   err,
+			"attempt": attempt + 1,
+			"replica": t.replica,
+		}).Warnln("Retrying SQL failed with transient error")
+
+		if !sleepContext(ctx, retryPolicy.backoff(attempt)) {
+			return err
+		}
+		if t.replica {
+			t = c.primary()
+		}
+		err = fn(t)
+	}
+	return err
+}
+
+// sleepContext waits for the duration, and returns false if the context
+// is done before that.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if ctx == nil {
+		time.Sleep(d)
+		return true
+	}
+
+	if ctx.Err() != nil {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
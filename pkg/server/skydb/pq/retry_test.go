@@ -0,0 +1,133 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIsTransientError(t *testing.T) {
+	Convey("isTransientError", t, func() {
+		So(isTransientError(&pq.Error{Code: "40001"}), ShouldBeTrue)
+		So(isTransientError(&pq.Error{Code: "57P01"}), ShouldBeTrue)
+		So(isTransientError(&pq.Error{Code: "08006"}), ShouldBeTrue)
+		So(isTransientError(driver.ErrBadConn), ShouldBeTrue)
+		So(isTransientError(&pq.Error{Code: "23505"}), ShouldBeFalse)
+		So(isTransientError(errors.New("an error")), ShouldBeFalse)
+		So(isTransientError(nil), ShouldBeFalse)
+	})
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	Convey("backoff doubles up to the maximum", t, func() {
+		policy := RetryPolicy{
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+		}
+		So(policy.backoff(0), ShouldEqual, 10*time.Millisecond)
+		So(policy.backoff(1), ShouldEqual, 20*time.Millisecond)
+		So(policy.backoff(2), ShouldEqual, 40*time.Millisecond)
+		So(policy.backoff(3), ShouldEqual, 50*time.Millisecond)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	Convey("retry", t, func() {
+		originalPolicy := retryPolicy
+		defer SetRetryPolicy(originalPolicy)
+		SetRetryPolicy(RetryPolicy{
+			MaxRetries:     2,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+		})
+
+		c := &conn{}
+		ctx := context.Background()
+		transientErr := &pq.Error{Code: "40001"}
+
+		Convey("retries reads failed with transient errors", func() {
+			attempts := 0
+			err := c.retry(ctx, c.primary(), "SELECT 1", func(target) error {
+				attempts++
+				if attempts < 2 {
+					return transientErr
+				}
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(attempts, ShouldEqual, 2)
+		})
+
+		Convey("gives up after the maximum retries", func() {
+			attempts := 0
+			err := c.retry(ctx, c.primary(), "SELECT 1", func(target) error {
+				attempts++
+				return transientErr
+			})
+			So(err, ShouldEqual, transientErr)
+			So(attempts, ShouldEqual, 3)
+		})
+
+		Convey("does not retry other errors", func() {
+			attempts := 0
+			c.retry(ctx, c.primary(), "SELECT 1", func(target) error {
+				attempts++
+				return &pq.Error{Code: "23505"}
+			})
+			So(attempts, ShouldEqual, 1)
+		})
+
+		Convey("does not retry writes", func() {
+			attempts := 0
+			c.retry(ctx, c.primary(), "DELETE FROM note", func(target) error {
+				attempts++
+				return transientErr
+			})
+			So(attempts, ShouldEqual, 1)
+		})
+
+		Convey("retries reads from replicas on the primary", func() {
+			targets := []target{}
+			c.retry(ctx, target{replica: true}, "SELECT 1", func(t target) error {
+				targets = append(targets, t)
+				if len(targets) < 2 {
+					return transientErr
+				}
+				return nil
+			})
+			So(len(targets), ShouldEqual, 2)
+			So(targets[0].replica, ShouldBeTrue)
+			So(targets[1].replica, ShouldBeFalse)
+		})
+
+		Convey("stops retrying when the context is done", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+			attempts := 0
+			c.retry(ctx, c.primary(), "SELECT 1", func(target) error {
+				attempts++
+				return transientErr
+			})
+			So(attempts, ShouldEqual, 1)
+		})
+	})
+}
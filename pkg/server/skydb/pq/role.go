@@ -279,6 +279,122 @@ func (c *conn) UpdateUserRoles(authinfo *skydb.AuthInfo) error {
 	return nil
 }
 
+func (c *conn) GetAllRoles() ([]string, error) {
+	builder := psql.Select("id").
+		From(c.tableName("_role")).
+		OrderBy("id")
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []string{}
+	for rows.Next() {
+		var roleStr string
+		if err := rows.Scan(&roleStr); err != nil {
+			return nil, err
+		}
+		roles = append(roles, roleStr)
+	}
+	return roles, rows.Err()
+}
+
+func (c *conn) GetUserIDsByRoles(roles []string) ([]string, error) {
+	userIDs := []string{}
+	if len(roles) == 0 {
+		return userIDs, nil
+	}
+
+	roleArgs := make([]interface{}, len(roles))
+	for idx, role := range roles {
+		roleArgs[idx] = role
+	}
+
+	builder := psql.Select("DISTINCT auth_id").
+		From(c.tableName("_auth_role")).
+		Where("role_id IN ("+sq.Placeholders(len(roleArgs))+")", roleArgs...).
+		OrderBy("auth_id")
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+func (c *conn) EnsureRoles(roles []string) error {
+	log.Debugf("EnsureRoles %v", roles)
+	_, err := c.ensureRole(roles)
+	return err
+}
+
+func (c *conn) GetRoleHierarchy() (skydb.RoleHierarchy, error) {
+	builder := psql.Select("role_id", "inherited_role_id").
+		From(c.tableName("_role_inheritance")).
+		OrderBy("role_id", "inherited_role_id")
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hierarchy := skydb.RoleHierarchy{}
+	for rows.Next() {
+		var role, inheritedRole string
+		if err := rows.Scan(&role, &inheritedRole); err != nil {
+			return nil, err
+		}
+		hierarchy[role] = append(hierarchy[role], inheritedRole)
+	}
+	return hierarchy, rows.Err()
+}
+
+func (c *conn) SetRoleInheritance(role string, inheritedRoles []string) error {
+	log.Debugf("SetRoleInheritance %v inherits %v", role, inheritedRoles)
+	if _, err := c.ensureRole(append([]string{role}, inheritedRoles...)); err != nil {
+		return err
+	}
+
+	deleteBuilder := psql.Delete(c.tableName("_role_inheritance")).
+		Where("role_id = ?", role)
+	if _, err := c.ExecWith(deleteBuilder); err != nil {
+		return err
+	}
+	if len(inheritedRoles) == 0 {
+		return nil
+	}
+
+	insertBuilder := psql.Insert(c.tableName("_role_inheritance")).
+		Columns("role_id", "inherited_role_id")
+	for _, inheritedRole := range inheritedRoles {
+		insertBuilder = insertBuilder.Values(role, inheritedRole)
+	}
+	_, err := c.ExecWith(insertBuilder)
+	return err
+}
+
+// setInheritedRoles sets the roles inherited by the roles of the user.
+func (c *conn) setInheritedRoles(authinfo *skydb.AuthInfo) error {
+	if len(authinfo.Roles) == 0 {
+		authinfo.InheritedRoles = nil
+		return nil
+	}
+
+	hierarchy, err := c.GetRoleHierarchy()
+	if err != nil {
+		return err
+	}
+	authinfo.InheritedRoles = hierarchy.InheritedRoles(authinfo.Roles)
+	return nil
+}
+
 func (c *conn) AssignRoles(userIDs []string, roles []string) error {
 	log.Debugf("AssignRoles %v to %v", roles, userIDs)
 	c.ensureRole(roles)
@@ -119,6 +119,38 @@ func TestRoleCRUD(t *testing.T) {
 				"userid-5": []string{},
 			})
 		})
+
+		Convey("get users of roles", func() {
+			So(c.CreateAuth(&skydb.AuthInfo{
+				ID:    "userid-3",
+				Roles: []string{"developer"},
+			}), ShouldBeNil)
+			So(c.CreateAuth(&skydb.AuthInfo{
+				ID:    "userid-4",
+				Roles: []string{"developer", "project-manager"},
+			}), ShouldBeNil)
+			So(c.CreateAuth(&skydb.AuthInfo{
+				ID:    "userid-5",
+				Roles: []string{"tester"},
+			}), ShouldBeNil)
+
+			userIDs, err := c.GetUserIDsByRoles([]string{"developer", "project-manager"})
+			So(err, ShouldBeNil)
+			So(userIDs, ShouldResemble, []string{"userid-3", "userid-4"})
+
+			userIDs, err = c.GetUserIDsByRoles([]string{})
+			So(err, ShouldBeNil)
+			So(userIDs, ShouldBeEmpty)
+		})
+
+		Convey("ensure and list all roles", func() {
+			So(c.EnsureRoles([]string{"writer", "admin"}), ShouldBeNil)
+			So(c.EnsureRoles([]string{"admin", "reader"}), ShouldBeNil)
+
+			roles, err := c.GetAllRoles()
+			So(err, ShouldBeNil)
+			So(roles, ShouldResemble, []string{"admin", "reader", "writer"})
+		})
 	})
 }
 
@@ -357,3 +389,50 @@ func TestRoleType(t *testing.T) {
 		})
 	})
 }
+
+func TestRoleInheritance(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		So(c.SetRoleInheritance("admin", []string{"moderator"}), ShouldBeNil)
+		So(c.SetRoleInheritance("moderator", []string{"member", "commenter"}), ShouldBeNil)
+
+		Convey("get role hierarchy", func() {
+			hierarchy, err := c.GetRoleHierarchy()
+			So(err, ShouldBeNil)
+			So(hierarchy, ShouldResemble, skydb.RoleHierarchy{
+				"admin":     []string{"moderator"},
+				"moderator": []string{"commenter", "member"},
+			})
+
+			roles, err := c.GetAllRoles()
+			So(err, ShouldBeNil)
+			So(roles, ShouldResemble, []string{"admin", "commenter", "member", "moderator"})
+		})
+
+		Convey("replace inherited roles", func() {
+			So(c.SetRoleInheritance("moderator", []string{"member"}), ShouldBeNil)
+			So(c.SetRoleInheritance("admin", []string{}), ShouldBeNil)
+
+			hierarchy, err := c.GetRoleHierarchy()
+			So(err, ShouldBeNil)
+			So(hierarchy, ShouldResemble, skydb.RoleHierarchy{
+				"moderator": []string{"member"},
+			})
+		})
+
+		Convey("get auth with inherited roles", func() {
+			authinfo := skydb.AuthInfo{
+				ID:    "userid-1",
+				Roles: []string{"admin"},
+			}
+			So(c.CreateAuth(&authinfo), ShouldBeNil)
+
+			fetched := skydb.AuthInfo{}
+			So(c.GetAuth("userid-1", &fetched), ShouldBeNil)
+			So(fetched.Roles, ShouldResemble, []string{"admin"})
+			So(fetched.InheritedRoles, ShouldResemble, []string{"commenter", "member", "moderator"})
+		})
+	})
+}
@@ -16,9 +16,13 @@ package pq
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"database/sql"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 
@@ -34,12 +38,31 @@ func (db *database) Extend(recordType string, recordSchema skydb.RecordSchema) (
 		return
 	}
 
-	if len(remoteRecordSchema) > 0 && remoteRecordSchema.DefinitionCompatibleTo(recordSchema) {
+	for key, fieldType := range recordSchema {
+		if !fieldType.DefaultCompatible() {
+			err = skyerr.NewInvalidArgument(
+				fmt.Sprintf("default value of %s is not a valid %s", key, fieldType.ToSimpleName()),
+				[]string{key},
+			)
+			return
+		}
+	}
+
+	constraintStmts := db.alterConstraintStmts(recordType, remoteRecordSchema, recordSchema)
+	if len(remoteRecordSchema) > 0 && remoteRecordSchema.DefinitionCompatibleTo(recordSchema) && len(constraintStmts) == 0 {
 		// The current record schema is superset of requested record
 		// schema. There is no need to extend the schema.
 		return
 	}
 
+	if err = db.checkStrictSchema(recordType, remoteRecordSchema, recordSchema); err != nil {
+		return
+	}
+
+	if err = db.checkSchemaLimits(recordType, remoteRecordSchema, recordSchema); err != nil {
+		return
+	}
+
 	if !db.c.canMigrate {
 		// The record schemas are different, but the database connection
 		// does not allow migration.
@@ -90,11 +113,21 @@ func (db *database) Extend(recordType string, recordSchema skydb.RecordSchema) (
 		extended = true
 	}
 
+	for _, stmt := range constraintStmts {
+		log.WithField("stmt", stmt).Debugln("Altering column constraints")
+		if _, err := tx.Exec(stmt); err != nil {
+			return false, fmt.Errorf("failed to alter column: %s", err)
+		}
+
+		extended = true
+	}
+
 	if err = tx.Commit(); err != nil {
 		return false, fmt.Errorf("unable to commit transaction for Extend: %s", err)
 	}
 
 	delete(db.c.RecordSchema, recordType)
+	notifySchemaChange(extendSchemaChange(recordType, remoteRecordSchema, recordSchema))
 
 	return
 }
@@ -107,13 +140,20 @@ func (db *database) RenameSchema(recordType, oldName, newName string) error {
 	}
 
 	tableName := db.TableName(recordType)
-	oldName = pq.QuoteIdentifier(oldName)
-	newName = pq.QuoteIdentifier(newName)
 
-	stmt := fmt.Sprintf("ALTER TABLE %s RENAME %s TO %s", tableName, oldName, newName)
+	stmt := fmt.Sprintf("ALTER TABLE %s RENAME %s TO %s", tableName, pq.QuoteIdentifier(oldName), pq.QuoteIdentifier(newName))
 	if _, err := db.c.Exec(stmt); err != nil {
 		return fmt.Errorf("failed to alter table: %s", err)
 	}
+
+	db.c.notifySchemaChange(skydb.SchemaChange{
+		RecordType: recordType,
+		Operations: []skydb.SchemaOperation{{
+			Type:    skydb.RenameColumnOperation,
+			Column:  oldName,
+			NewName: newName,
+		}},
+	})
 	return nil
 }
 
@@ -125,12 +165,19 @@ func (db *database) DeleteSchema(recordType, columnName string) error {
 	}
 
 	tableName := db.TableName(recordType)
-	columnName = pq.QuoteIdentifier(columnName)
 
-	stmt := fmt.Sprintf("ALTER TABLE %s DROP %s", tableName, columnName)
+	stmt := fmt.Sprintf("ALTER TABLE %s DROP %s", tableName, pq.QuoteIdentifier(columnName))
 	if _, err := db.c.Exec(stmt); err != nil {
 		return fmt.Errorf("failed to alter table: %s", err)
 	}
+
+	db.c.notifySchemaChange(skydb.SchemaChange{
+		RecordType: recordType,
+		Operations: []skydb.SchemaOperation{{
+			Type:   skydb.DeleteColumnOperation,
+			Column: columnName,
+		}},
+	})
 	return nil
 }
 
@@ -228,6 +275,8 @@ CREATE TABLE %s (
     _created_by text,
     _updated_at timestamp without time zone NOT NULL,
     _updated_by text,
+    _deleted_at timestamp without time zone,
+    _rev bigint NOT NULL DEFAULT 1,
     PRIMARY KEY(_id, _database_id, _owner_id),
     UNIQUE (_id)
 );
@@ -320,8 +369,13 @@ WHERE c.relname = $1
 	// STEP 2: Get column name and data type
 	rows, err := db.c.Queryx(`
 SELECT a.attname,
-  pg_catalog.format_type(a.atttypid, a.atttypmod)
+  pg_catalog.format_type(a.atttypid, a.atttypmod),
+  COALESCE(co.collname, ''),
+  a.attnotnull,
+  COALESCE(pg_catalog.pg_get_expr(d.adbin, d.adrelid), '')
 FROM pg_catalog.pg_attribute a
+     LEFT JOIN pg_catalog.pg_collation co ON co.oid = a.attcollation
+     LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
 WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped`,
 		oid)
 
@@ -335,21 +389,27 @@ WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped`,
 		return nil, err
 	}
 
-	var columnName, pqType string
+	var columnName, pqType, collation, defaultExpr string
+	var notNull bool
 	var integerColumns = []string{}
 	for rows.Next() {
-		if err := rows.Scan(&columnName, &pqType); err != nil {
+		if err := rows.Scan(&columnName, &pqType, &collation, &notNull, &defaultExpr); err != nil {
 			return nil, err
 		}
 
 		schema := skydb.FieldType{
 			UnderlyingType: pqType,
+			Required:       notNull,
 		}
 		switch pqType {
 		case TypeCaseInsensitiveString:
 			fallthrough
 		case TypeString:
-			schema.Type = skydb.TypeString
+			if collation == "C" {
+				schema.Type = skydb.TypePosition
+			} else {
+				schema.Type = skydb.TypeString
+			}
 		case TypeNumber:
 			schema.Type = skydb.TypeNumber
 		case TypeTimestamp:
@@ -374,6 +434,7 @@ WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped`,
 		default:
 			schema.Type = skydb.TypeUnknown
 		}
+		schema.Default = parseColumnDefault(schema.Type, defaultExpr)
 
 		typemap[columnName] = schema
 	}
@@ -419,12 +480,14 @@ WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped`,
 	}
 
 	for refs.Next() {
-		s := skydb.FieldType{}
 		var primaryColumn, referencedTable string
 		if err := refs.Scan(&primaryColumn, &referencedTable); err != nil {
 			log.Debugf("err %v", err)
 			return nil, err
 		}
+		s := skydb.FieldType{
+			Required: typemap[primaryColumn].Required,
+		}
 		switch referencedTable {
 		case "_asset":
 			s.Type = skydb.TypeAsset
@@ -455,6 +518,13 @@ func (db *database) addColumnStmt(recordType string, recordSchema skydb.RecordSc
 		buf.WriteString(pq.QuoteIdentifier(column))
 		buf.WriteByte(' ')
 		buf.WriteString(pqDataType(schema.Type))
+		if schema.Default != nil {
+			buf.WriteString(" DEFAULT ")
+			buf.WriteString(pqDefaultValue(schema))
+		}
+		if schema.Required {
+			buf.WriteString(" NOT NULL")
+		}
 		buf.WriteByte(',')
 		switch schema.Type {
 		case skydb.TypeAsset:
@@ -470,6 +540,42 @@ func (db *database) addColumnStmt(recordType string, recordSchema skydb.RecordSc
 	return buf.String()
 }
 
+// alterConstraintStmts returns the statements adding the constraints of
+// the requested schema to the existing columns. Constraints are only
+// added, so that a field derived from a saved record does not remove
+// the constraints declared for the field.
+func (db *database) alterConstraintStmts(recordType string, remoteRecordSchema skydb.RecordSchema, recordSchema skydb.RecordSchema) []string {
+	stmts := []string{}
+	for key, fieldType := range recordSchema {
+		remoteFieldType, ok := remoteRecordSchema[key]
+		if !ok {
+			continue
+		}
+
+		column := pq.QuoteIdentifier(key)
+		if defaultChanged(remoteFieldType, fieldType) {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s",
+				db.TableName(recordType), column, pqDefaultValue(fieldType)))
+		}
+		if fieldType.Required && !remoteFieldType.Required {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL",
+				db.TableName(recordType), column))
+		}
+	}
+	sort.Strings(stmts)
+	return stmts
+}
+
+func defaultChanged(remoteFieldType skydb.FieldType, fieldType skydb.FieldType) bool {
+	return fieldType.Default != nil && !reflect.DeepEqual(fieldType.Default, remoteFieldType.Default)
+}
+
+// constraintChanged returns true if altering the column of the remote
+// field type to the field type changes its constraints.
+func constraintChanged(remoteFieldType skydb.FieldType, fieldType skydb.FieldType) bool {
+	return defaultChanged(remoteFieldType, fieldType) || fieldType.Required && !remoteFieldType.Required
+}
+
 func (db *database) writeForeignKeyConstraint(buf *bytes.Buffer, localCol, referent, remoteCol string) {
 	buf.Write([]byte(`ADD CONSTRAINT `))
 	buf.WriteString(pq.QuoteIdentifier(fmt.Sprintf(`fk_%s_%s_%s`, localCol, referent, remoteCol)))
@@ -482,6 +588,29 @@ func (db *database) writeForeignKeyConstraint(buf *bytes.Buffer, localCol, refer
 	buf.Write([]byte(`),`))
 }
 
+// maxIdentifierLength is the maximum length in bytes of identifiers in
+// PostgreSQL, beyond which identifiers are truncated.
+const maxIdentifierLength = 63
+
+// lowerIndexName returns the name of the index on LOWER(column) of the
+// record type. Names longer than an identifier are truncated and suffixed
+// with a hash of the full name, so that they are still distinct.
+func lowerIndexName(recordType string, column string) string {
+	const suffix = "_lower_idx"
+	name := fmt.Sprintf("%s_%s%s", recordType, column, suffix)
+	if len(name) <= maxIdentifierLength {
+		return name
+	}
+
+	hash := fmt.Sprintf("_%x", sha1.Sum([]byte(name)))[:9]
+	prefix := strings.TrimSuffix(name, suffix)
+	end := maxIdentifierLength - len(hash) - len(suffix)
+	for end > 0 && !utf8.RuneStart(prefix[end]) {
+		end--
+	}
+	return prefix[:end] + hash + suffix
+}
+
 func (db *database) GetIndexesByRecordType(recordType string) (indexes map[string]skydb.Index, err error) {
 	schemaName := db.schemaName()
 	rows, err := db.c.Queryx(`
@@ -0,0 +1,96 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"sort"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// schemaChangeListener is called after the schema of a record type is
+// changed. No one is notified if it is nil.
+var schemaChangeListener skydb.SchemaChangeListener
+
+// SetSchemaChangeListener sets the listener called after the schema of
+// a record type is changed, including the columns added implicitly when
+// records are saved. It should be called before opening any connection.
+func SetSchemaChangeListener(listener skydb.SchemaChangeListener) {
+	schemaChangeListener = listener
+}
+
+// notifySchemaChange notifies the listener of a change committed to the
+// database.
+func notifySchemaChange(change skydb.SchemaChange) {
+	if schemaChangeListener == nil || len(change.Operations) == 0 && !change.Created {
+		return
+	}
+	schemaChangeListener(change)
+}
+
+// notifySchemaChange notifies the listener of a change made by the
+// connection. Changes made in the transaction of the connection are
+// notified when the transaction is committed.
+func (c *conn) notifySchemaChange(change skydb.SchemaChange) {
+	if c.tx != nil {
+		c.pendingSchemaChanges = append(c.pendingSchemaChanges, change)
+		return
+	}
+	notifySchemaChange(change)
+}
+
+// commitSchemaChanges notifies the listener of the changes made in the
+// committed transaction.
+func (c *conn) commitSchemaChanges() {
+	changes := c.pendingSchemaChanges
+	c.pendingSchemaChanges = nil
+	for _, change := range changes {
+		notifySchemaChange(change)
+	}
+}
+
+// extendSchemaChange returns the change made by extending the remote
+// record schema with the record schema, which adds the new columns and
+// alters the constraints of the existing columns.
+func extendSchemaChange(recordType string, remoteRecordSchema skydb.RecordSchema, recordSchema skydb.RecordSchema) skydb.SchemaChange {
+	keys := make([]string, 0, len(recordSchema))
+	for key := range recordSchema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	change := skydb.SchemaChange{
+		RecordType: recordType,
+		Created:    len(remoteRecordSchema) == 0,
+	}
+	for _, key := range keys {
+		fieldType := recordSchema[key]
+		remoteFieldType, ok := remoteRecordSchema[key]
+		if !ok {
+			change.Operations = append(change.Operations, skydb.SchemaOperation{
+				Type:      skydb.AddColumnOperation,
+				Column:    key,
+				FieldType: fieldType,
+			})
+		} else if constraintChanged(remoteFieldType, fieldType) {
+			change.Operations = append(change.Operations, skydb.SchemaOperation{
+				Type:      skydb.AlterColumnOperation,
+				Column:    key,
+				FieldType: fieldType,
+			})
+		}
+	}
+	return change
+}
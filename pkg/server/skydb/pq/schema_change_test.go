@@ -0,0 +1,120 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchemaChangeListener(t *testing.T) {
+	Convey("Schema change listener", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+		db := c.PublicDB()
+
+		changes := []skydb.SchemaChange{}
+		SetSchemaChangeListener(func(change skydb.SchemaChange) {
+			changes = append(changes, change)
+		})
+		defer SetSchemaChangeListener(nil)
+
+		Convey("is notified of the record type created by Extend", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(changes, ShouldResemble, []skydb.SchemaChange{{
+				RecordType: "note",
+				Created:    true,
+				Operations: []skydb.SchemaOperation{
+					{Type: skydb.AddColumnOperation, Column: "title", FieldType: skydb.FieldType{Type: skydb.TypeString}},
+				},
+			}})
+		})
+
+		Convey("is notified of the columns added and altered by Extend", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			changes = changes[:0]
+
+			_, err = db.Extend("note", skydb.RecordSchema{
+				"title":   skydb.FieldType{Type: skydb.TypeString, Default: "untitled"},
+				"content": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(changes, ShouldResemble, []skydb.SchemaChange{{
+				RecordType: "note",
+				Operations: []skydb.SchemaOperation{
+					{Type: skydb.AddColumnOperation, Column: "content", FieldType: skydb.FieldType{Type: skydb.TypeString}},
+					{Type: skydb.AlterColumnOperation, Column: "title", FieldType: skydb.FieldType{Type: skydb.TypeString, Default: "untitled"}},
+				},
+			}})
+		})
+
+		Convey("is not notified when Extend does not change the schema", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			changes = changes[:0]
+
+			extended, err := db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeFalse)
+			So(changes, ShouldBeEmpty)
+		})
+
+		Convey("is notified of the column deleted in a transaction on commit", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"title":   skydb.FieldType{Type: skydb.TypeString},
+				"content": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			changes = changes[:0]
+
+			So(c.Begin(), ShouldBeNil)
+			So(db.DeleteSchema("note", "content"), ShouldBeNil)
+			So(changes, ShouldBeEmpty)
+
+			So(c.Commit(), ShouldBeNil)
+			So(changes, ShouldResemble, []skydb.SchemaChange{{
+				RecordType: "note",
+				Operations: []skydb.SchemaOperation{
+					{Type: skydb.DeleteColumnOperation, Column: "content"},
+				},
+			}})
+		})
+
+		Convey("is not notified of the column renamed in a rolled back transaction", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			changes = changes[:0]
+
+			So(c.Begin(), ShouldBeNil)
+			So(db.RenameSchema("note", "title", "subject"), ShouldBeNil)
+			So(c.Rollback(), ShouldBeNil)
+			So(changes, ShouldBeEmpty)
+		})
+	})
+}
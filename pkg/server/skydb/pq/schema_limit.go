@@ -0,0 +1,133 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// schemaLimitWarningRatio is the ratio of a schema limit at which a
+// warning is logged when the schema is extended.
+const schemaLimitWarningRatio = 0.8
+
+// maxRecordTypes and maxColumnsPerType are the maximum number of record
+// types and of the columns of a record type, which are created when the
+// schema is extended. Zero means unlimited.
+var (
+	maxRecordTypes    int
+	maxColumnsPerType int
+)
+
+// SetSchemaLimits sets the maximum number of record types and of the
+// columns of a record type, excluding the reserved columns. A limit of
+// zero means unlimited. It should be called before opening any connection.
+func SetSchemaLimits(recordTypes int, columnsPerType int) {
+	maxRecordTypes = recordTypes
+	maxColumnsPerType = columnsPerType
+}
+
+// checkSchemaLimits returns an error if extending the schema would
+// create more record types or columns than the limits, and logs a
+// warning if the limits are being approached.
+func (db *database) checkSchemaLimits(recordType string, remoteRecordSchema skydb.RecordSchema, recordSchema skydb.RecordSchema) error {
+	if maxRecordTypes > 0 && len(remoteRecordSchema) == 0 {
+		count, err := db.countRecordTypes()
+		if err != nil {
+			return err
+		}
+		if count+1 > maxRecordTypes {
+			return skyerr.NewErrorWithInfo(
+				skyerr.IncompatibleSchema,
+				fmt.Sprintf(`cannot create record type "%s" because the number of record types would exceed the limit of %d`, recordType, maxRecordTypes),
+				map[string]interface{}{
+					"limit": maxRecordTypes,
+					"count": count,
+				},
+			)
+		}
+		warnSchemaLimit("record types", "", count+1, maxRecordTypes)
+	}
+
+	if maxColumnsPerType > 0 {
+		count := countUserColumns(remoteRecordSchema)
+		newColumns := []string{}
+		for key := range recordSchema {
+			if _, ok := remoteRecordSchema[key]; !ok && !isReservedColumn(key) {
+				newColumns = append(newColumns, key)
+			}
+		}
+		if len(newColumns) == 0 {
+			return nil
+		}
+		if count+len(newColumns) > maxColumnsPerType {
+			sort.Strings(newColumns)
+			return skyerr.NewErrorWithInfo(
+				skyerr.IncompatibleSchema,
+				fmt.Sprintf(
+					`cannot create fields %s of record type "%s" because the number of fields would exceed the limit of %d`,
+					strings.Join(newColumns, ", "), recordType, maxColumnsPerType,
+				),
+				map[string]interface{}{
+					"arguments": newColumns,
+					"limit":     maxColumnsPerType,
+					"count":     count,
+				},
+			)
+		}
+		warnSchemaLimit("fields", recordType, count+len(newColumns), maxColumnsPerType)
+	}
+
+	return nil
+}
+
+func warnSchemaLimit(what string, recordType string, count int, limit int) {
+	if float64(count) < float64(limit)*schemaLimitWarningRatio {
+		return
+	}
+	logger := log.WithField("count", count).WithField("limit", limit)
+	if recordType != "" {
+		logger = logger.WithField("type", recordType)
+	}
+	logger.Warnf("Number of %s is approaching the limit", what)
+}
+
+func (db *database) countRecordTypes() (int, error) {
+	var count int
+	err := db.c.Get(&count, `
+	SELECT COUNT(*)
+	FROM information_schema.tables
+	WHERE (table_name NOT LIKE '\_%') AND (table_schema=$1)
+	`, db.schemaName())
+	return count, err
+}
+
+func isReservedColumn(key string) bool {
+	return strings.HasPrefix(key, "_")
+}
+
+func countUserColumns(schema skydb.RecordSchema) int {
+	count := 0
+	for key := range schema {
+		if !isReservedColumn(key) {
+			count++
+		}
+	}
+	return count
+}
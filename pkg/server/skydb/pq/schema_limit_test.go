@@ -0,0 +1,78 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchemaLimits(t *testing.T) {
+	Convey("Extend with schema limits", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+		db := c.PublicDB()
+
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"title":   skydb.FieldType{Type: skydb.TypeString},
+			"content": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		SetSchemaLimits(1, 3)
+		defer SetSchemaLimits(0, 0)
+
+		Convey("allows columns within the limit", func() {
+			extended, err := db.Extend("note", skydb.RecordSchema{
+				"author": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeTrue)
+		})
+
+		Convey("refuses to create columns exceeding the limit", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"author": skydb.FieldType{Type: skydb.TypeString},
+				"tags":   skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.IncompatibleSchema)
+			So(err.(skyerr.Error).Info(), ShouldResemble, map[string]interface{}{
+				"arguments": []string{"author", "tags"},
+				"limit":     3,
+				"count":     2,
+			})
+
+			schema, err := db.RemoteColumnTypes("note")
+			So(err, ShouldBeNil)
+			So(schema, ShouldNotContainKey, "author")
+		})
+
+		Convey("refuses to create record types exceeding the limit", func() {
+			_, err := db.Extend("comment", skydb.RecordSchema{
+				"content": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.IncompatibleSchema)
+
+			schema, err := db.RemoteColumnTypes("comment")
+			So(err, ShouldBeNil)
+			So(schema, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func (db *database) SchemaVersions() (map[string]string, error) {
+	builder := psql.
+		Select("record_type", "max(version)").
+		From(db.c.tableName("_schema_migration")).
+		GroupBy("record_type")
+
+	rows, err := db.c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[string]string{}
+	for rows.Next() {
+		var recordType, version string
+		if err := rows.Scan(&recordType, &version); err != nil {
+			return nil, err
+		}
+		versions[recordType] = version
+	}
+	return versions, rows.Err()
+}
+
+// MigrateSchema applies the migration even if the connection cannot
+// migrate, because unlike extending schema on save, a declarative
+// migration is run explicitly by the operator.
+func (db *database) MigrateSchema(migration skydb.SchemaMigration) error {
+	if err := migration.Validate(); err != nil {
+		return skyerr.NewError(skyerr.InvalidArgument, err.Error())
+	}
+
+	remoteRecordSchema, err := db.RemoteColumnTypes(migration.RecordType)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.c.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(remoteRecordSchema) == 0 {
+		if err := createTable(tx, db.TableName(migration.RecordType)); err != nil {
+			return fmt.Errorf("failed to create table: %s", err)
+		}
+	}
+
+	for _, op := range migration.Operations {
+		stmt := db.schemaOperationStmt(migration.RecordType, op)
+		log.WithField("stmt", stmt).Debugln("Migrating schema")
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to %s %s: %s", op.Type, op.Column, err)
+		}
+	}
+
+	_, err = tx.Exec(
+		fmt.Sprintf("INSERT INTO %s (record_type, version, applied_at) VALUES ($1, $2, $3)",
+			db.c.tableName("_schema_migration")),
+		migration.RecordType, migration.Version, time.Now().UTC())
+	if isUniqueViolated(err) {
+		return skyerr.NewErrorf(skyerr.Duplicated,
+			`migration "%s" of %s is already applied`, migration.Version, migration.RecordType)
+	} else if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit transaction for MigrateSchema: %s", err)
+	}
+
+	delete(db.c.RecordSchema, migration.RecordType)
+	notifySchemaChange(skydb.SchemaChange{
+		RecordType: migration.RecordType,
+		Created:    len(remoteRecordSchema) == 0,
+		Operations: migration.Operations,
+	})
+	return nil
+}
+
+// ALTER TABLE app__.note ALTER COLUMN priority TYPE integer USING priority::integer;
+func (db *database) schemaOperationStmt(recordType string, op skydb.SchemaOperation) string {
+	tableName := db.TableName(recordType)
+	column := pq.QuoteIdentifier(op.Column)
+
+	switch op.Type {
+	case skydb.AddColumnOperation:
+		return db.addColumnStmt(recordType, skydb.RecordSchema{op.Column: op.FieldType})
+	case skydb.RenameColumnOperation:
+		return fmt.Sprintf("ALTER TABLE %s RENAME %s TO %s",
+			tableName, column, pq.QuoteIdentifier(op.NewName))
+	case skydb.RetypeColumnOperation:
+		dataType := pqDataType(op.FieldType.Type)
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s",
+			tableName, column, dataType, column, dataType)
+	case skydb.AddLowerIndexOperation:
+		return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (LOWER(%s))",
+			pq.QuoteIdentifier(lowerIndexName(recordType, op.Column)), tableName, column)
+	}
+	panic(fmt.Sprintf("unknown schema operation %s", op.Type))
+}
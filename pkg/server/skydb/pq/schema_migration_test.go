@@ -0,0 +1,165 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content":  skydb.FieldType{Type: skydb.TypeString},
+			"priority": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		_, err = c.Exec(`INSERT INTO "note" ` +
+			`(_database_id, _id, _owner_id, _created_at, _created_by, _updated_at, _updated_by, content, priority) ` +
+			`VALUES ('', '1', 'user', '1988-02-06', 'user', '1988-02-06', 'user', 'hello', '3')`)
+		So(err, ShouldBeNil)
+
+		Convey("applies operations and records version", func() {
+			err := db.MigrateSchema(skydb.SchemaMigration{
+				Version:    "20170101000000",
+				RecordType: "note",
+				Operations: []skydb.SchemaOperation{
+					{
+						Type:      skydb.AddColumnOperation,
+						Column:    "due_at",
+						FieldType: skydb.FieldType{Type: skydb.TypeDateTime},
+					},
+					{
+						Type:    skydb.RenameColumnOperation,
+						Column:  "content",
+						NewName: "body",
+					},
+					{
+						Type:      skydb.RetypeColumnOperation,
+						Column:    "priority",
+						FieldType: skydb.FieldType{Type: skydb.TypeInteger},
+					},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			schema, err := db.GetSchema("note")
+			So(err, ShouldBeNil)
+			So(schema["due_at"].Type, ShouldEqual, skydb.TypeDateTime)
+			So(schema["body"].Type, ShouldEqual, skydb.TypeString)
+			So(schema["priority"].Type, ShouldEqual, skydb.TypeInteger)
+			So(schema, ShouldNotContainKey, "content")
+
+			var priority int
+			So(c.QueryRowx(`SELECT priority FROM "note" WHERE _id = '1'`).Scan(&priority), ShouldBeNil)
+			So(priority, ShouldEqual, 3)
+
+			versions, err := db.SchemaVersions()
+			So(err, ShouldBeNil)
+			So(versions, ShouldResemble, map[string]string{"note": "20170101000000"})
+		})
+
+		Convey("adds index on lower case values", func() {
+			migration := skydb.SchemaMigration{
+				Version:    "20170101000000",
+				RecordType: "note",
+				Operations: []skydb.SchemaOperation{
+					{Type: skydb.AddLowerIndexOperation, Column: "content"},
+				},
+			}
+			So(db.MigrateSchema(migration), ShouldBeNil)
+
+			var definition string
+			So(c.QueryRowx(`SELECT indexdef FROM pg_indexes WHERE indexname = 'note_content_lower_idx'`).
+				Scan(&definition), ShouldBeNil)
+			So(definition, ShouldContainSubstring, "lower(content)")
+		})
+
+		Convey("creates table of new record type", func() {
+			err := db.MigrateSchema(skydb.SchemaMigration{
+				Version:    "20170101000000",
+				RecordType: "tag",
+				Operations: []skydb.SchemaOperation{
+					{
+						Type:      skydb.AddColumnOperation,
+						Column:    "name",
+						FieldType: skydb.FieldType{Type: skydb.TypeString},
+					},
+				},
+			})
+			So(err, ShouldBeNil)
+
+			schema, err := db.GetSchema("tag")
+			So(err, ShouldBeNil)
+			So(schema["name"].Type, ShouldEqual, skydb.TypeString)
+		})
+
+		Convey("rolls back failed migration", func() {
+			err := db.MigrateSchema(skydb.SchemaMigration{
+				Version:    "20170101000000",
+				RecordType: "note",
+				Operations: []skydb.SchemaOperation{
+					{
+						Type:      skydb.AddColumnOperation,
+						Column:    "due_at",
+						FieldType: skydb.FieldType{Type: skydb.TypeDateTime},
+					},
+					{
+						Type:    skydb.RenameColumnOperation,
+						Column:  "title",
+						NewName: "heading",
+					},
+				},
+			})
+			So(err, ShouldNotBeNil)
+
+			schema, err := db.GetSchema("note")
+			So(err, ShouldBeNil)
+			So(schema, ShouldNotContainKey, "due_at")
+
+			versions, err := db.SchemaVersions()
+			So(err, ShouldBeNil)
+			So(versions, ShouldBeEmpty)
+		})
+
+		Convey("rejects applied version", func() {
+			migration := skydb.SchemaMigration{
+				Version:    "20170101000000",
+				RecordType: "note",
+				Operations: []skydb.SchemaOperation{
+					{
+						Type:      skydb.AddColumnOperation,
+						Column:    "due_at",
+						FieldType: skydb.FieldType{Type: skydb.TypeDateTime},
+					},
+				},
+			}
+			So(db.MigrateSchema(migration), ShouldBeNil)
+
+			migration.Operations[0].Column = "done_at"
+			err := db.MigrateSchema(migration)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.Duplicated)
+		})
+	})
+}
@@ -15,9 +15,12 @@
 package pq
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -347,6 +350,79 @@ func TestExtend(t *testing.T) {
 				So(extended, ShouldBeFalse)
 			}
 		})
+
+		Convey("creates columns with default and not null", func() {
+			extended, err := db.Extend("note", skydb.RecordSchema{
+				"title":    skydb.FieldType{Type: skydb.TypeString, Required: true},
+				"status":   skydb.FieldType{Type: skydb.TypeString, Required: true, Default: "it's draft"},
+				"priority": skydb.FieldType{Type: skydb.TypeInteger, Default: float64(-1)},
+				"pinned":   skydb.FieldType{Type: skydb.TypeBoolean, Default: false},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeTrue)
+
+			schema, err := db.RemoteColumnTypes("note")
+			So(err, ShouldBeNil)
+			So(schema["title"].Required, ShouldBeTrue)
+			So(schema["title"].Default, ShouldBeNil)
+			So(schema["status"].Required, ShouldBeTrue)
+			So(schema["status"].Default, ShouldEqual, "it's draft")
+			So(schema["priority"].Required, ShouldBeFalse)
+			So(schema["priority"].Default, ShouldEqual, float64(-1))
+			So(schema["pinned"].Default, ShouldEqual, false)
+
+			_, err = c.Exec(
+				`INSERT INTO "note" ` +
+					`(_id, _database_id, _owner_id, _created_at, _created_by, _updated_at, _updated_by) ` +
+					`VALUES (1, 1, 1, '1988-02-06', 'creator', '1988-02-06', 'updater')`)
+			So(err, ShouldNotBeNil)
+
+			_, err = c.Exec(
+				`INSERT INTO "note" ` +
+					`(_id, _database_id, _owner_id, _created_at, _created_by, _updated_at, _updated_by, "title") ` +
+					`VALUES (1, 1, 1, '1988-02-06', 'creator', '1988-02-06', 'updater', 'Hello')`)
+			So(err, ShouldBeNil)
+
+			var status string
+			err = c.QueryRowx(`SELECT "status" FROM "note" WHERE _id = '1'`).Scan(&status)
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, "it's draft")
+		})
+
+		Convey("adds constraints to existing column", func() {
+			extended, err := db.Extend("note", skydb.RecordSchema{
+				"status": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeTrue)
+
+			extended, err = db.Extend("note", skydb.RecordSchema{
+				"status": skydb.FieldType{Type: skydb.TypeString, Required: true, Default: "draft"},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeTrue)
+
+			schema, err := db.RemoteColumnTypes("note")
+			So(err, ShouldBeNil)
+			So(schema["status"].Required, ShouldBeTrue)
+			So(schema["status"].Default, ShouldEqual, "draft")
+
+			// a schema derived from saved record does not remove the
+			// constraints
+			extended, err = db.Extend("note", skydb.RecordSchema{
+				"status": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeFalse)
+		})
+
+		Convey("rejects default value of other type", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"priority": skydb.FieldType{Type: skydb.TypeInteger, Default: "high"},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.InvalidArgument)
+		})
 	})
 
 	Convey("RenameSchema", t, func() {
@@ -560,3 +636,26 @@ func TestExtend(t *testing.T) {
 		})
 	})
 }
+
+func TestLowerIndexName(t *testing.T) {
+	Convey("lowerIndexName", t, func() {
+		Convey("joins record type and column", func() {
+			So(lowerIndexName("note", "title"), ShouldEqual, "note_title_lower_idx")
+		})
+
+		Convey("truncates long name with hash", func() {
+			long := strings.Repeat("a", 40)
+			name := lowerIndexName(long, long)
+			So(len(name), ShouldBeLessThanOrEqualTo, 63)
+			So(name, ShouldStartWith, "aaaa")
+			So(name, ShouldEndWith, "_lower_idx")
+			So(lowerIndexName(long, long+"b"), ShouldNotEqual, name)
+		})
+
+		Convey("does not split multibyte characters", func() {
+			name := lowerIndexName(strings.Repeat("字", 20), "title")
+			So(len(name), ShouldBeLessThanOrEqualTo, 63)
+			So(utf8.ValidString(name), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,178 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// ScrubRecords rewrites the fields in a single UPDATE statement. Values
+// are hashed with md5 prefixed by the salt, and null values are left
+// null.
+func (c *conn) ScrubRecords(recordType string, fields map[string]skydb.ScrubMethod, salt string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := make([]string, len(names))
+	conditions := make([]string, len(names))
+	usesSalt := false
+	for i, name := range names {
+		column := pq.QuoteIdentifier(name)
+		value, err := scrubValue(fields[name], column, "$1")
+		if err != nil {
+			return 0, err
+		}
+		usesSalt = usesSalt || fields[name] != skydb.ScrubNull
+
+		sets[i] = fmt.Sprintf("%s = %s", column, value)
+		conditions[i] = fmt.Sprintf("%s IS NOT NULL", column)
+	}
+
+	stmt := fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		c.tableName(recordType),
+		strings.Join(sets, ", "),
+		strings.Join(conditions, " OR "))
+	args := []interface{}{}
+	if usesSalt {
+		args = append(args, salt)
+	}
+
+	result, err := c.Exec(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	c.invalidateQueryCache(recordType)
+	return result.RowsAffected()
+}
+
+// scrubValue returns the SQL expression of the scrubbed value of the
+// string expression, hashed with the salt in the placeholder.
+func scrubValue(method skydb.ScrubMethod, expr string, salt string) (string, error) {
+	hash := fmt.Sprintf("md5(%s || %s)", salt, expr)
+	switch method {
+	case skydb.ScrubHash:
+		return hash, nil
+	case skydb.ScrubEmail:
+		return fmt.Sprintf("'user-' || %s || '@example.com'", hash), nil
+	case skydb.ScrubName:
+		return fmt.Sprintf("'User ' || left(%s, 8)", hash), nil
+	case skydb.ScrubNull:
+		return "NULL", nil
+	}
+	return "", fmt.Errorf("unknown scrub method %s", method)
+}
+
+// ScrubSystemTables empties the OAuth profiles of users, keeping the
+// linked providers, rewrites the fields of the record types in the data
+// of the record history in the same way as ScrubRecords, and hashes the
+// content of annotations.
+func (c *conn) ScrubSystemTables(recordTypes map[string]map[string]skydb.ScrubMethod, salt string) (map[string]int64, error) {
+	counts := map[string]int64{}
+
+	result, err := c.Exec(fmt.Sprintf(`
+		UPDATE %s SET provider_info = (
+			SELECT coalesce(jsonb_object_agg(key, '{}'::jsonb), '{}'::jsonb)
+			FROM jsonb_each(provider_info)
+		)
+		WHERE jsonb_typeof(provider_info) = 'object' AND provider_info != '{}'::jsonb`,
+		c.tableName("_auth")))
+	if err != nil {
+		return nil, err
+	}
+	if counts["_auth"], err = result.RowsAffected(); err != nil {
+		return nil, err
+	}
+
+	counts["_history"] = 0
+	for recordType, fields := range recordTypes {
+		count, err := c.scrubHistory(recordType, fields, salt)
+		if err != nil {
+			return nil, err
+		}
+		counts["_history"] += count
+	}
+
+	result, err = c.Exec(fmt.Sprintf("UPDATE %s SET content = md5($1 || content)",
+		c.tableName("_annotation")), salt)
+	if err != nil {
+		return nil, err
+	}
+	if counts["_annotation"], err = result.RowsAffected(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// scrubHistory rewrites the fields in the data of the history entries of
+// the record type. Fields scrubbed with ScrubNull are removed from the
+// data.
+func (c *conn) scrubHistory(recordType string, fields map[string]skydb.ScrubMethod, salt string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []interface{}{recordType}
+	placeholder := func(arg interface{}) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d::text", len(args))
+	}
+
+	data := "data"
+	saltPlaceholder := ""
+	for _, name := range names {
+		key := placeholder(name)
+		if fields[name] == skydb.ScrubNull {
+			data = fmt.Sprintf("(%s - %s)", data, key)
+			continue
+		}
+
+		if saltPlaceholder == "" {
+			saltPlaceholder = placeholder(salt)
+		}
+		value, err := scrubValue(fields[name], fmt.Sprintf("(data ->> %s)", key), saltPlaceholder)
+		if err != nil {
+			return 0, err
+		}
+		data = fmt.Sprintf(
+			"CASE WHEN data ->> %[2]s IS NULL THEN %[1]s ELSE jsonb_set(%[1]s, ARRAY[%[2]s], to_jsonb(%[3]s)) END",
+			data, key, value)
+	}
+
+	stmt := fmt.Sprintf("UPDATE %s SET data = %s WHERE record_type = $1 AND data IS NOT NULL",
+		c.tableName("_history"), data)
+	result, err := c.Exec(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
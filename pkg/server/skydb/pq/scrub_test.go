@@ -0,0 +1,196 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestScrubRecords(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		db := c.PublicDB()
+		_, err := db.Extend("contact", skydb.RecordSchema{
+			"name":  skydb.FieldType{Type: skydb.TypeString},
+			"email": skydb.FieldType{Type: skydb.TypeString},
+			"phone": skydb.FieldType{Type: skydb.TypeString},
+			"note":  skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+
+		saveContact := func(db skydb.Database, id string, data map[string]interface{}) {
+			So(db.Save(&skydb.Record{
+				ID:      skydb.NewRecordID("contact", id),
+				OwnerID: "alice",
+				Data:    data,
+			}), ShouldBeNil)
+		}
+		saveContact(db, "1", map[string]interface{}{
+			"name":  "Alice",
+			"email": "alice@example.org",
+			"phone": "12345678",
+			"note":  "friend",
+		})
+		saveContact(db, "2", map[string]interface{}{
+			"name":  "Alice",
+			"email": "alice2@example.org",
+		})
+		saveContact(c.PrivateDB("bob"), "3", map[string]interface{}{
+			"name": "Bob",
+		})
+		saveContact(db, "4", map[string]interface{}{
+			"note": "no contact",
+		})
+
+		fetch := func(db skydb.Database, id string) map[string]interface{} {
+			record := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("contact", id), &record), ShouldBeNil)
+			return record.Data
+		}
+
+		Convey("rewrites fields of records in all databases", func() {
+			count, err := c.ScrubRecords("contact", map[string]skydb.ScrubMethod{
+				"name":  skydb.ScrubName,
+				"email": skydb.ScrubEmail,
+				"phone": skydb.ScrubNull,
+			}, "salt")
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+
+			contact1 := fetch(db, "1")
+			So(contact1["name"], ShouldStartWith, "User ")
+			So(contact1["name"], ShouldHaveLength, 13)
+			So(contact1["email"], ShouldStartWith, "user-")
+			So(contact1["email"], ShouldEndWith, "@example.com")
+			So(contact1["phone"], ShouldBeNil)
+			So(contact1["note"], ShouldEqual, "friend")
+
+			contact2 := fetch(db, "2")
+			So(contact2["name"], ShouldEqual, contact1["name"])
+			So(contact2["email"], ShouldNotEqual, contact1["email"])
+
+			contact3 := fetch(c.PrivateDB("bob"), "3")
+			So(contact3["name"], ShouldNotEqual, "Bob")
+
+			contact4 := fetch(db, "4")
+			So(contact4["name"], ShouldBeNil)
+			So(contact4["note"], ShouldEqual, "no contact")
+		})
+
+		Convey("hashes with salt", func() {
+			_, err := c.ScrubRecords("contact", map[string]skydb.ScrubMethod{
+				"note": skydb.ScrubHash,
+			}, "salt")
+			So(err, ShouldBeNil)
+			hashed := fetch(db, "1")["note"]
+			So(hashed, ShouldHaveLength, 32)
+			So(hashed, ShouldNotEqual, "friend")
+		})
+
+		Convey("removes values without salt", func() {
+			_, err := c.ScrubRecords("contact", map[string]skydb.ScrubMethod{
+				"note": skydb.ScrubNull,
+			}, "")
+			So(err, ShouldBeNil)
+			So(fetch(db, "1")["note"], ShouldBeNil)
+		})
+	})
+}
+
+func TestScrubSystemTables(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		So(c.CreateAuth(&skydb.AuthInfo{
+			ID: "alice",
+			ProviderInfo: skydb.ProviderInfo{
+				"com.example:alice": map[string]interface{}{
+					"email": "alice@example.org",
+				},
+			},
+		}), ShouldBeNil)
+		So(c.CreateAuth(&skydb.AuthInfo{ID: "bob"}), ShouldBeNil)
+
+		_, err := c.Exec(`
+			INSERT INTO _history (record_type, record_id, database_id, action, data, actor, created_at)
+			VALUES
+				('contact', '1', '', 'update', '{"name": "Alice", "phone": "12345678", "note": "friend"}', 'alice', now()),
+				('contact', '2', '', 'delete', '{"note": "no contact"}', 'alice', now()),
+				('note', '1', '', 'update', '{"name": "Alice"}', 'alice', now())`)
+		So(err, ShouldBeNil)
+		So(c.AddAnnotation(&skydb.Annotation{
+			ID:        "annotation",
+			RecordID:  skydb.NewRecordID("contact", "1"),
+			AuthorID:  "alice",
+			Content:   "call Alice at 12345678",
+			CreatedAt: time.Now(),
+		}), ShouldBeNil)
+
+		counts, err := c.ScrubSystemTables(map[string]map[string]skydb.ScrubMethod{
+			"contact": {
+				"name":  skydb.ScrubName,
+				"phone": skydb.ScrubNull,
+			},
+		}, "salt")
+		So(err, ShouldBeNil)
+		So(counts, ShouldResemble, map[string]int64{
+			"_auth":       1,
+			"_history":    2,
+			"_annotation": 1,
+		})
+
+		Convey("empties OAuth profiles", func() {
+			providerInfo := providerInfoValue{}
+			So(c.QueryRowx("SELECT provider_info FROM _auth WHERE id = 'alice'").
+				Scan(&providerInfo), ShouldBeNil)
+			So(providerInfo.ProviderInfo, ShouldResemble, skydb.ProviderInfo{
+				"com.example:alice": map[string]interface{}{},
+			})
+		})
+
+		Convey("rewrites fields of record history", func() {
+			history := func(recordType, recordID string) map[string]interface{} {
+				var data []byte
+				So(c.QueryRowx("SELECT data FROM _history WHERE record_type = $1 AND record_id = $2",
+					recordType, recordID).Scan(&data), ShouldBeNil)
+				m := map[string]interface{}{}
+				So(json.Unmarshal(data, &m), ShouldBeNil)
+				return m
+			}
+
+			contact1 := history("contact", "1")
+			So(contact1["name"], ShouldStartWith, "User ")
+			So(contact1, ShouldNotContainKey, "phone")
+			So(contact1["note"], ShouldEqual, "friend")
+			So(history("contact", "2"), ShouldResemble, map[string]interface{}{"note": "no contact"})
+			So(history("note", "1"), ShouldResemble, map[string]interface{}{"name": "Alice"})
+		})
+
+		Convey("hashes content of annotations", func() {
+			annotation, err := c.GetAnnotation("annotation")
+			So(err, ShouldBeNil)
+			So(annotation.Content, ShouldHaveLength, 32)
+		})
+	})
+}
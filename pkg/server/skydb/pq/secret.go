@@ -0,0 +1,109 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql"
+	"time"
+
+	sq "github.com/lann/squirrel"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) GetSecret(name string) (*skydb.Secret, error) {
+	builder := c.selectSecrets().Where("name = ?", name)
+
+	secret, err := scanSecret(c.QueryRowWith(builder))
+	if err == sql.ErrNoRows {
+		return nil, skydb.ErrSecretNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (c *conn) GetSecrets() ([]skydb.Secret, error) {
+	rows, err := c.QueryWith(c.selectSecrets().OrderBy("name"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets := []skydb.Secret{}
+	for rows.Next() {
+		secret, err := scanSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, *secret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (c *conn) SetSecret(secret *skydb.Secret) error {
+	builder := psql.Insert(c.tableName("_secret")).
+		Columns("name", "key_id", "sealed", "updated_at").
+		Values(secret.Name, secret.KeyID, secret.Sealed, secret.UpdatedAt.UTC()).
+		Suffix(`ON CONFLICT (name) DO UPDATE
+			SET key_id = EXCLUDED.key_id, sealed = EXCLUDED.sealed, updated_at = EXCLUDED.updated_at`)
+
+	_, err := c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) DeleteSecret(name string) error {
+	builder := psql.Delete(c.tableName("_secret")).
+		Where("name = ?", name)
+
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return skydb.ErrSecretNotFound
+	}
+	return nil
+}
+
+func (c *conn) selectSecrets() sq.SelectBuilder {
+	return psql.Select("name", "key_id", "sealed", "updated_at").
+		From(c.tableName("_secret"))
+}
+
+type secretScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSecret(scanner secretScanner) (*skydb.Secret, error) {
+	secret := skydb.Secret{}
+	if err := scanner.Scan(
+		&secret.Name,
+		&secret.KeyID,
+		&secret.Sealed,
+		&secret.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	secret.UpdatedAt = secret.UpdatedAt.In(time.UTC)
+	return &secret, nil
+}
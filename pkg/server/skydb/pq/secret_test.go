@@ -0,0 +1,81 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestSecret(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		gcmKey := skydb.Secret{
+			Name:      "gcm_api_key",
+			KeyID:     "key1",
+			Sealed:    []byte{1, 2, 3},
+			UpdatedAt: now,
+		}
+		apnsKey := skydb.Secret{
+			Name:      "apns_private_key",
+			KeyID:     "key1",
+			Sealed:    []byte{4, 5, 6},
+			UpdatedAt: now,
+		}
+		So(c.SetSecret(&gcmKey), ShouldBeNil)
+		So(c.SetSecret(&apnsKey), ShouldBeNil)
+
+		Convey("gets secret", func() {
+			secret, err := c.GetSecret("gcm_api_key")
+			So(err, ShouldBeNil)
+			So(*secret, ShouldResemble, gcmKey)
+		})
+
+		Convey("gets secrets ordered by name", func() {
+			secrets, err := c.GetSecrets()
+			So(err, ShouldBeNil)
+			So(secrets, ShouldResemble, []skydb.Secret{apnsKey, gcmKey})
+		})
+
+		Convey("replaces secret of the same name", func() {
+			rotated := skydb.Secret{
+				Name:      "gcm_api_key",
+				KeyID:     "key2",
+				Sealed:    []byte{7, 8, 9},
+				UpdatedAt: now.Add(time.Hour),
+			}
+			So(c.SetSecret(&rotated), ShouldBeNil)
+
+			secret, err := c.GetSecret("gcm_api_key")
+			So(err, ShouldBeNil)
+			So(*secret, ShouldResemble, rotated)
+		})
+
+		Convey("deletes secret", func() {
+			So(c.DeleteSecret("gcm_api_key"), ShouldBeNil)
+
+			_, err := c.GetSecret("gcm_api_key")
+			So(err, ShouldEqual, skydb.ErrSecretNotFound)
+			So(c.DeleteSecret("gcm_api_key"), ShouldEqual, skydb.ErrSecretNotFound)
+		})
+	})
+}
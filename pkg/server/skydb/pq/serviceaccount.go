@@ -0,0 +1,146 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql"
+	"time"
+
+	sq "github.com/lann/squirrel"
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) CreateServiceAccount(account *skydb.ServiceAccount) error {
+	builder := psql.Insert(c.tableName("_service_account")).
+		Columns("id", "name", "hashed_secret", "public_key", "created_at", "last_used_at").
+		Values(account.ID, account.Name, account.HashedSecret, account.PublicKey,
+			account.CreatedAt.UTC(), utcTime(account.LastUsedAt))
+
+	_, err := c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) GetServiceAccount(id string) (*skydb.ServiceAccount, error) {
+	builder := c.selectServiceAccounts().Where("id = ?", id)
+
+	account, err := scanServiceAccount(c.QueryRowWith(builder))
+	if err == sql.ErrNoRows {
+		return nil, skydb.ErrServiceAccountNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (c *conn) GetServiceAccounts() ([]skydb.ServiceAccount, error) {
+	rows, err := c.QueryWith(c.selectServiceAccounts().OrderBy("created_at", "id"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []skydb.ServiceAccount{}
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (c *conn) UpdateServiceAccount(account *skydb.ServiceAccount) error {
+	builder := psql.Update(c.tableName("_service_account")).
+		Set("name", account.Name).
+		Set("hashed_secret", account.HashedSecret).
+		Set("public_key", account.PublicKey).
+		Set("last_used_at", utcTime(account.LastUsedAt)).
+		Where("id = ?", account.ID)
+
+	return c.execServiceAccount(builder)
+}
+
+func (c *conn) DeleteServiceAccount(id string) error {
+	builder := psql.Delete(c.tableName("_service_account")).
+		Where("id = ?", id)
+
+	return c.execServiceAccount(builder)
+}
+
+// execServiceAccount executes the statement modifying a service account,
+// and returns ErrServiceAccountNotFound if no service account is modified.
+func (c *conn) execServiceAccount(builder sq.Sqlizer) error {
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return skydb.ErrServiceAccountNotFound
+	}
+	return nil
+}
+
+func (c *conn) selectServiceAccounts() sq.SelectBuilder {
+	return psql.Select("id", "name", "hashed_secret", "public_key",
+		"created_at", "last_used_at").
+		From(c.tableName("_service_account"))
+}
+
+type serviceAccountScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanServiceAccount(scanner serviceAccountScanner) (*skydb.ServiceAccount, error) {
+	var (
+		account    skydb.ServiceAccount
+		lastUsedAt pq.NullTime
+	)
+	if err := scanner.Scan(
+		&account.ID,
+		&account.Name,
+		&account.HashedSecret,
+		&account.PublicKey,
+		&account.CreatedAt,
+		&lastUsedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	account.CreatedAt = account.CreatedAt.In(time.UTC)
+	if lastUsedAt.Valid {
+		t := lastUsedAt.Time.In(time.UTC)
+		account.LastUsedAt = &t
+	}
+	return &account, nil
+}
+
+// utcTime returns the time in UTC, or nil if t is nil.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
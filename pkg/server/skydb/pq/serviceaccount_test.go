@@ -0,0 +1,94 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestServiceAccount(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		So(c.CreateAuth(&skydb.AuthInfo{ID: "account1"}), ShouldBeNil)
+		So(c.CreateAuth(&skydb.AuthInfo{ID: "account2"}), ShouldBeNil)
+
+		secretAccount := skydb.ServiceAccount{
+			ID:        "account1",
+			Name:      "report job",
+			CreatedAt: now,
+		}
+		secretAccount.SetSecret("secret")
+		keyAccount := skydb.ServiceAccount{
+			ID:        "account2",
+			Name:      "sync job",
+			PublicKey: "PUBLIC KEY",
+			CreatedAt: now.Add(time.Second),
+		}
+		So(c.CreateServiceAccount(&secretAccount), ShouldBeNil)
+		So(c.CreateServiceAccount(&keyAccount), ShouldBeNil)
+
+		Convey("gets service account", func() {
+			account, err := c.GetServiceAccount("account1")
+			So(err, ShouldBeNil)
+			So(*account, ShouldResemble, secretAccount)
+			So(account.IsSameSecret("secret"), ShouldBeTrue)
+		})
+
+		Convey("gets service accounts ordered by creation time", func() {
+			accounts, err := c.GetServiceAccounts()
+			So(err, ShouldBeNil)
+			So(accounts, ShouldHaveLength, 2)
+			So(accounts[0].ID, ShouldEqual, "account1")
+			So(accounts[1], ShouldResemble, keyAccount)
+		})
+
+		Convey("returns error if service account does not exist", func() {
+			_, err := c.GetServiceAccount("notexist")
+			So(err, ShouldEqual, skydb.ErrServiceAccountNotFound)
+		})
+
+		Convey("updates service account", func() {
+			lastUsedAt := now.Add(time.Hour)
+			keyAccount.SetSecret("new secret")
+			keyAccount.LastUsedAt = &lastUsedAt
+			So(c.UpdateServiceAccount(&keyAccount), ShouldBeNil)
+
+			account, err := c.GetServiceAccount("account2")
+			So(err, ShouldBeNil)
+			So(*account, ShouldResemble, keyAccount)
+		})
+
+		Convey("deletes service account", func() {
+			So(c.DeleteServiceAccount("account1"), ShouldBeNil)
+			_, err := c.GetServiceAccount("account1")
+			So(err, ShouldEqual, skydb.ErrServiceAccountNotFound)
+			So(c.DeleteServiceAccount("account1"), ShouldEqual, skydb.ErrServiceAccountNotFound)
+		})
+
+		Convey("deletes service account with its auth info", func() {
+			So(c.DeleteAuth("account2"), ShouldBeNil)
+			_, err := c.GetServiceAccount("account2")
+			So(err, ShouldEqual, skydb.ErrServiceAccountNotFound)
+		})
+	})
+}
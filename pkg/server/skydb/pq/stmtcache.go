@@ -0,0 +1,138 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// statementCacheSize is the maximum number of prepared statements
+// cached for each database. Zero disables the statement cache.
+var statementCacheSize = 100
+
+// SetStatementCacheSize sets the maximum number of prepared statements
+// cached for each database. It only affects databases opened after the
+// call, and should be called before opening any connection.
+func SetStatementCacheSize(size int) {
+	statementCacheSize = size
+}
+
+type stmtCacheEntry struct {
+	query string
+	stmt  *sqlx.Stmt
+}
+
+// stmtCache keeps prepared statements of a database keyed by the SQL
+// text, so that statements generated for the same query are parsed and
+// planned by the database server only once. The least recently used
+// statement is closed when the cache is full.
+type stmtCache struct {
+	db      *sqlx.DB
+	size    int
+	mutex   sync.Mutex
+	entries *list.List
+	index   map[string]*list.Element
+}
+
+func newStmtCache(db *sqlx.DB, size int) *stmtCache {
+	return &stmtCache{
+		db:      db,
+		size:    size,
+		entries: list.New(),
+		index:   map[string]*list.Element{},
+	}
+}
+
+// Get returns the prepared statement of the query, preparing the query
+// if it is not found in the cache.
+func (c *stmtCache) Get(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	c.mutex.Lock()
+	if elem, ok := c.index[query]; ok {
+		c.entries.MoveToFront(elem)
+		c.mutex.Unlock()
+		return elem.Value.(*stmtCacheEntry).stmt, nil
+	}
+	c.mutex.Unlock()
+
+	// Prepare without holding the lock so that a slow prepare does not
+	// block queries using other cached statements.
+	stmt, err := c.db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another goroutine may have prepared the same query in the meantime.
+	if elem, ok := c.index[query]; ok {
+		stmt.Close()
+		c.entries.MoveToFront(elem)
+		return elem.Value.(*stmtCacheEntry).stmt, nil
+	}
+
+	c.index[query] = c.entries.PushFront(&stmtCacheEntry{query, stmt})
+	for c.entries.Len() > c.size {
+		c.removeElement(c.entries.Back())
+	}
+	return stmt, nil
+}
+
+// Remove closes the prepared statement of the query and removes it from
+// the cache.
+func (c *stmtCache) Remove(query string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.index[query]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Len returns the number of prepared statements in the cache.
+func (c *stmtCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.entries.Len()
+}
+
+func (c *stmtCache) removeElement(elem *list.Element) {
+	entry := c.entries.Remove(elem).(*stmtCacheEntry)
+	delete(c.index, entry.query)
+	if err := entry.stmt.Close(); err != nil {
+		log.Debugf("stmtcache: unable to close statement: %s", err)
+	}
+}
+
+// isStaleStatement returns true if the error indicates that the prepared
+// statement can no longer be used, e.g. the result type of the statement
+// is changed by a schema migration, or the statement is closed because
+// it is evicted from the cache.
+func isStaleStatement(err error) bool {
+	if err == nil {
+		return false
+	}
+	if pqErr, ok := err.(*pq.Error); ok {
+		// feature_not_supported: cached plan must not change result type
+		// invalid_sql_statement_name: prepared statement does not exist
+		return pqErr.Code == "0A000" || pqErr.Code == "26000"
+	}
+	return err.Error() == "sql: statement is closed"
+}
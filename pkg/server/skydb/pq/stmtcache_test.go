@@ -0,0 +1,109 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"context"
+	"testing"
+
+	sq "github.com/lann/squirrel"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStmtCache(t *testing.T) {
+	Convey("stmtCache", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		cache := newStmtCache(c.db, 2)
+
+		Convey("reuse prepared statement of the same query", func() {
+			stmt1, err := cache.Get(context.Background(), "SELECT 1")
+			So(err, ShouldBeNil)
+			stmt2, err := cache.Get(context.Background(), "SELECT 1")
+			So(err, ShouldBeNil)
+			So(stmt2, ShouldEqual, stmt1)
+			So(cache.Len(), ShouldEqual, 1)
+		})
+
+		Convey("evict least recently used statement", func() {
+			stmt1, err := cache.Get(context.Background(), "SELECT 1")
+			So(err, ShouldBeNil)
+			_, err = cache.Get(context.Background(), "SELECT 2")
+			So(err, ShouldBeNil)
+			_, err = cache.Get(context.Background(), "SELECT 1")
+			So(err, ShouldBeNil)
+			_, err = cache.Get(context.Background(), "SELECT 3")
+			So(err, ShouldBeNil)
+			So(cache.Len(), ShouldEqual, 2)
+
+			stmt, err := cache.Get(context.Background(), "SELECT 1")
+			So(err, ShouldBeNil)
+			So(stmt, ShouldEqual, stmt1)
+			So(cache.index, ShouldNotContainKey, "SELECT 2")
+		})
+
+		Convey("return error of invalid query", func() {
+			_, err := cache.Get(context.Background(), "SELECT FROM WHERE")
+			So(err, ShouldNotBeNil)
+			So(cache.Len(), ShouldEqual, 0)
+		})
+
+		Convey("conn caches generated queries", func() {
+			c.stmtCache = cache
+
+			var n int
+			So(c.GetWith(&n, sq.Select("1")), ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(c.QueryRowWith(sq.Select("2")).Scan(&n), ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(cache.index, ShouldContainKey, "SELECT 1")
+			So(cache.index, ShouldContainKey, "SELECT 2")
+		})
+
+		Convey("conn uses cached statement in transaction", func() {
+			c.stmtCache = cache
+			So(c.Begin(), ShouldBeNil)
+			defer c.Rollback()
+
+			var n int
+			So(c.GetWith(&n, sq.Select("1")), ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(cache.Len(), ShouldEqual, 1)
+		})
+
+		Convey("conn re-executes stale statement without preparing", func() {
+			c.stmtCache = cache
+			_, err := c.Exec(`CREATE TABLE "stmt_cache_test" ("a" integer)`)
+			So(err, ShouldBeNil)
+			defer c.Exec(`DROP TABLE "stmt_cache_test"`)
+
+			query := sq.Select("*").From(`"stmt_cache_test"`)
+			rows, err := c.QueryWith(query)
+			So(err, ShouldBeNil)
+			rows.Close()
+
+			_, err = c.Exec(`ALTER TABLE "stmt_cache_test" ADD COLUMN "b" integer`)
+			So(err, ShouldBeNil)
+
+			rows, err = c.QueryWith(query)
+			So(err, ShouldBeNil)
+			columns, err := rows.Columns()
+			So(err, ShouldBeNil)
+			So(columns, ShouldResemble, []string{"a", "b"})
+			rows.Close()
+		})
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	sq "github.com/lann/squirrel"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func (c *conn) SetRecordStrictSchema(recordType string, strict bool) error {
+	if !strict {
+		builder := psql.Delete(c.tableName("_record_strict_schema")).
+			Where(sq.Eq{"record_type": recordType})
+		_, err := c.ExecWith(builder)
+		return err
+	}
+
+	builder := psql.Insert(c.tableName("_record_strict_schema")).
+		Columns("record_type").
+		Values(recordType).
+		Suffix("ON CONFLICT (record_type) DO NOTHING")
+	_, err := c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) GetRecordStrictSchema() (skydb.StrictSchema, error) {
+	builder := psql.Select("record_type").
+		From(c.tableName("_record_strict_schema"))
+
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	strictSchema := skydb.StrictSchema{}
+	for rows.Next() {
+		var recordType string
+		if err := rows.Scan(&recordType); err != nil {
+			return nil, err
+		}
+		strictSchema[recordType] = true
+	}
+	return strictSchema, rows.Err()
+}
+
+// checkStrictSchema returns an error if the record type is in strict
+// schema mode, and the table or some of the columns of the record type
+// would be created to extend the schema.
+func (db *database) checkStrictSchema(recordType string, remoteRecordSchema skydb.RecordSchema, recordSchema skydb.RecordSchema) error {
+	newColumns := []string{}
+	for key := range recordSchema {
+		if _, ok := remoteRecordSchema[key]; !ok {
+			newColumns = append(newColumns, key)
+		}
+	}
+	if len(remoteRecordSchema) > 0 && len(newColumns) == 0 {
+		return nil
+	}
+
+	strictSchema, err := db.c.GetRecordStrictSchema()
+	if err != nil {
+		return err
+	}
+	if !strictSchema.IsStrict(recordType) {
+		return nil
+	}
+
+	if len(remoteRecordSchema) == 0 {
+		return skyerr.NewErrorf(
+			skyerr.IncompatibleSchema,
+			`record type "%s" does not exist and cannot be created in strict schema mode`,
+			recordType,
+		)
+	}
+
+	sort.Strings(newColumns)
+	return skyerr.NewErrorWithInfo(
+		skyerr.IncompatibleSchema,
+		fmt.Sprintf(
+			`cannot create fields %s of record type "%s" in strict schema mode`,
+			strings.Join(newColumns, ", "), recordType,
+		),
+		map[string]interface{}{"arguments": newColumns},
+	)
+}
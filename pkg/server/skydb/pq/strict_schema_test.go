@@ -0,0 +1,91 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecordStrictSchema(t *testing.T) {
+	Convey("RecordStrictSchema", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		Convey("sets and gets strict schema mode", func() {
+			So(c.SetRecordStrictSchema("note", true), ShouldBeNil)
+			So(c.SetRecordStrictSchema("note", true), ShouldBeNil)
+			So(c.SetRecordStrictSchema("comment", true), ShouldBeNil)
+			So(c.SetRecordStrictSchema("comment", false), ShouldBeNil)
+
+			strictSchema, err := c.GetRecordStrictSchema()
+			So(err, ShouldBeNil)
+			So(strictSchema, ShouldResemble, skydb.StrictSchema{"note": true})
+		})
+	})
+
+	Convey("Extend in strict schema mode", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+		db := c.PublicDB()
+
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"content": skydb.FieldType{Type: skydb.TypeString},
+		})
+		So(err, ShouldBeNil)
+		So(c.SetRecordStrictSchema("note", true), ShouldBeNil)
+
+		Convey("allows schema without new columns", func() {
+			extended, err := db.Extend("note", skydb.RecordSchema{
+				"content": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeFalse)
+		})
+
+		Convey("refuses to create columns", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"content": skydb.FieldType{Type: skydb.TypeString},
+				"titel":   skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.IncompatibleSchema)
+			So(err.(skyerr.Error).Info(), ShouldResemble, map[string]interface{}{
+				"arguments": []string{"titel"},
+			})
+
+			schema, err := db.RemoteColumnTypes("note")
+			So(err, ShouldBeNil)
+			So(schema, ShouldNotContainKey, "titel")
+		})
+
+		Convey("refuses to create table of any type in strict mode for all types", func() {
+			So(c.SetRecordStrictSchema(skydb.AllRecordTypes, true), ShouldBeNil)
+
+			_, err := db.Extend("comment", skydb.RecordSchema{
+				"content": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.IncompatibleSchema)
+
+			schema, err := db.RemoteColumnTypes("comment")
+			So(err, ShouldBeNil)
+			So(schema, ShouldBeNil)
+		})
+	})
+}
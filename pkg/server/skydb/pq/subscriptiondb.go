@@ -411,6 +411,30 @@ func predMatchRecord(p *skydb.Predicate, record *skydb.Record) (b bool) {
 		return deepEqualIn(lv, haystack)
 	// case skydb.Like:
 	// case skydb.ILike:
+	case skydb.IsNull:
+		return extractValue(p.GetExpressions()[0], record) == nil
+	case skydb.IsNotNull:
+		return extractValue(p.GetExpressions()[0], record) != nil
+	case skydb.ContainsAll, skydb.ContainsAny:
+		lv, rv := extractBinaryOperands(p.GetExpressions(), record)
+		haystack, ok := lv.([]interface{})
+		if !ok {
+			return false
+		}
+		needles, ok := rv.([]interface{})
+		if !ok {
+			log.Panicf("unknown value in right hand side of `%v` operand = %v", p.Operator, rv)
+		}
+
+		for _, needle := range needles {
+			found := deepEqualIn(needle, haystack)
+			if found && p.Operator == skydb.ContainsAny {
+				return true
+			} else if !found && p.Operator == skydb.ContainsAll {
+				return false
+			}
+		}
+		return p.Operator == skydb.ContainsAll
 	default:
 		log.Panicf("unknown Predicate.Operator = %v", p.Operator)
 	}
@@ -447,5 +447,29 @@ func TestPredicateMatchRecord(t *testing.T) {
 
 			So(predMatchRecord(&predicate, &record1), ShouldBeFalse)
 		})
+
+		Convey("Match record with predicate containsall and containsany", func() {
+			record1.Data["tags"] = []interface{}{"quick", "vegan"}
+			keyPath := skydb.Expression{
+				Type:  skydb.KeyPath,
+				Value: "tags",
+			}
+			values := skydb.Expression{
+				Type:  skydb.Literal,
+				Value: []interface{}{"vegan", "dessert"},
+			}
+
+			containsAll := skydb.Predicate{
+				Operator: skydb.ContainsAll,
+				Children: []interface{}{keyPath, values},
+			}
+			containsAny := skydb.Predicate{
+				Operator: skydb.ContainsAny,
+				Children: []interface{}{keyPath, values},
+			}
+
+			So(predMatchRecord(&containsAll, &record1), ShouldBeFalse)
+			So(predMatchRecord(&containsAny, &record1), ShouldBeTrue)
+		})
 	})
 }
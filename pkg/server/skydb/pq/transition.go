@@ -0,0 +1,155 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	sq "github.com/lann/squirrel"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) ScheduleTransition(transition *skydb.Transition) error {
+	data, err := json.Marshal(transition.Data)
+	if err != nil {
+		return err
+	}
+
+	builder := psql.Insert(c.tableName("_transition")).
+		Columns("id", "record_type", "record_id", "database_id", "data",
+			"scheduled_at", "created_at", "created_by", "with_master_key").
+		Values(transition.ID, transition.RecordID.Type, transition.RecordID.Key,
+			transition.DatabaseID, string(data), transition.ScheduledAt.UTC(),
+			transition.CreatedAt.UTC(), transition.CreatedBy, transition.WithMasterKey)
+
+	_, err = c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) GetTransitions(recordID skydb.RecordID) ([]skydb.Transition, error) {
+	builder := c.selectTransitions().
+		Where("record_type = ? AND record_id = ?", recordID.Type, recordID.Key)
+	return c.queryTransitions(builder)
+}
+
+func (c *conn) ClaimDueTransitions(before time.Time, lease time.Duration, limit int) ([]skydb.Transition, error) {
+	before = before.UTC()
+
+	// Rows locked by another server claiming transitions are skipped,
+	// so that each transition is claimed by one server only.
+	dueIDs, dueArgs, err := sq.Select("id").
+		From(c.tableName("_transition")).
+		Where("scheduled_at <= ?", before).
+		Where("(claimed_until IS NULL OR claimed_until <= ?)", before).
+		OrderBy("scheduled_at", "id").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	builder := psql.Update(c.tableName("_transition")).
+		Set("claimed_until", before.Add(lease)).
+		Where("id IN ("+dueIDs+")", dueArgs...).
+		Suffix("RETURNING " + transitionColumns)
+	transitions, err := c.queryTransitions(builder)
+	if err != nil {
+		return nil, err
+	}
+
+	// UPDATE does not return rows in the order of the subquery.
+	sort.Slice(transitions, func(i, j int) bool {
+		if transitions[i].ScheduledAt.Equal(transitions[j].ScheduledAt) {
+			return transitions[i].ID < transitions[j].ID
+		}
+		return transitions[i].ScheduledAt.Before(transitions[j].ScheduledAt)
+	})
+	return transitions, nil
+}
+
+func (c *conn) DeleteTransition(id string) error {
+	builder := psql.Delete(c.tableName("_transition")).
+		Where("id = ?", id)
+
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return skydb.ErrTransitionNotFound
+	}
+	return nil
+}
+
+const transitionColumns = "id, record_type, record_id, database_id, data, " +
+	"scheduled_at, created_at, created_by, with_master_key"
+
+func (c *conn) selectTransitions() sq.SelectBuilder {
+	return psql.Select(transitionColumns).
+		From(c.tableName("_transition")).
+		OrderBy("scheduled_at", "id")
+}
+
+func (c *conn) queryTransitions(builder sq.Sqlizer) ([]skydb.Transition, error) {
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []skydb.Transition{}
+	for rows.Next() {
+		var (
+			transition skydb.Transition
+			data       []byte
+			createdBy  *string
+		)
+		if err := rows.Scan(
+			&transition.ID,
+			&transition.RecordID.Type,
+			&transition.RecordID.Key,
+			&transition.DatabaseID,
+			&data,
+			&transition.ScheduledAt,
+			&transition.CreatedAt,
+			&createdBy,
+			&transition.WithMasterKey,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &transition.Data); err != nil {
+			return nil, err
+		}
+		if createdBy != nil {
+			transition.CreatedBy = *createdBy
+		}
+		transition.ScheduledAt = transition.ScheduledAt.In(time.UTC)
+		transition.CreatedAt = transition.CreatedAt.In(time.UTC)
+		transitions = append(transitions, transition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
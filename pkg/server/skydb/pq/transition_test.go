@@ -0,0 +1,122 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestTransition(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		publish := skydb.Transition{
+			ID:          "publish",
+			RecordID:    skydb.NewRecordID("article", "1"),
+			DatabaseID:  "_public",
+			Data:        map[string]interface{}{"status": "published"},
+			ScheduledAt: now.Add(time.Hour),
+			CreatedAt:   now,
+			CreatedBy:   "user",
+		}
+		archive := skydb.Transition{
+			ID:          "archive",
+			RecordID:    skydb.NewRecordID("article", "1"),
+			DatabaseID:  "_public",
+			Data:        map[string]interface{}{"status": "archived"},
+			ScheduledAt: now.Add(2 * time.Hour),
+			CreatedAt:   now,
+			CreatedBy:   "user",
+		}
+		So(c.ScheduleTransition(&archive), ShouldBeNil)
+		So(c.ScheduleTransition(&publish), ShouldBeNil)
+
+		Convey("gets transitions of record in scheduled order", func() {
+			transitions, err := c.GetTransitions(skydb.NewRecordID("article", "1"))
+			So(err, ShouldBeNil)
+			So(transitions, ShouldResemble, []skydb.Transition{publish, archive})
+		})
+
+		Convey("gets no transitions of another record", func() {
+			transitions, err := c.GetTransitions(skydb.NewRecordID("article", "2"))
+			So(err, ShouldBeNil)
+			So(transitions, ShouldBeEmpty)
+		})
+
+		Convey("claims due transitions", func() {
+			transitions, err := c.ClaimDueTransitions(now.Add(150*time.Minute), time.Minute, 10)
+			So(err, ShouldBeNil)
+			So(transitions, ShouldResemble, []skydb.Transition{publish, archive})
+		})
+
+		Convey("claims due transitions up to limit", func() {
+			transitions, err := c.ClaimDueTransitions(now.Add(150*time.Minute), time.Minute, 1)
+			So(err, ShouldBeNil)
+			So(transitions, ShouldResemble, []skydb.Transition{publish})
+		})
+
+		Convey("does not claim claimed transitions until lease passed", func() {
+			claimedAt := now.Add(90 * time.Minute)
+			transitions, err := c.ClaimDueTransitions(claimedAt, time.Minute, 10)
+			So(err, ShouldBeNil)
+			So(transitions, ShouldResemble, []skydb.Transition{publish})
+
+			transitions, err = c.ClaimDueTransitions(claimedAt.Add(30*time.Second), time.Minute, 10)
+			So(err, ShouldBeNil)
+			So(transitions, ShouldBeEmpty)
+
+			transitions, err = c.ClaimDueTransitions(claimedAt.Add(time.Minute), time.Minute, 10)
+			So(err, ShouldBeNil)
+			So(transitions, ShouldResemble, []skydb.Transition{publish})
+		})
+
+		Convey("keeps whether transition is scheduled with master key", func() {
+			master := skydb.Transition{
+				ID:            "master",
+				RecordID:      skydb.NewRecordID("article", "2"),
+				DatabaseID:    "_public",
+				Data:          map[string]interface{}{"status": "published"},
+				ScheduledAt:   now.Add(time.Hour),
+				CreatedAt:     now,
+				WithMasterKey: true,
+			}
+			So(c.ScheduleTransition(&master), ShouldBeNil)
+
+			transitions, err := c.GetTransitions(skydb.NewRecordID("article", "2"))
+			So(err, ShouldBeNil)
+			So(transitions, ShouldResemble, []skydb.Transition{master})
+		})
+
+		Convey("deletes transition", func() {
+			So(c.DeleteTransition("publish"), ShouldBeNil)
+
+			transitions, err := c.GetTransitions(skydb.NewRecordID("article", "1"))
+			So(err, ShouldBeNil)
+			So(transitions, ShouldResemble, []skydb.Transition{archive})
+		})
+
+		Convey("returns error when deleting non-existent transition", func() {
+			err := c.DeleteTransition("nonexistent")
+			So(err, ShouldEqual, skydb.ErrTransitionNotFound)
+		})
+	})
+}
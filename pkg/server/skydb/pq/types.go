@@ -19,6 +19,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/paulmach/go.geo"
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
@@ -42,6 +44,10 @@ const (
 	TypeSerial                = "serial UNIQUE"
 	TypeBigInteger            = "bigint"
 	TypeGeometry              = "geometry"
+
+	// TypePosition sorts positions by their byte values regardless of
+	// the collation of the database
+	TypePosition = `text COLLATE "C"`
 )
 
 func pqDataType(dataType skydb.DataType) string {
@@ -66,7 +72,65 @@ func pqDataType(dataType skydb.DataType) string {
 		return TypeSerial
 	case skydb.TypeGeometry:
 		return TypeGeometry
+	case skydb.TypePosition:
+		return TypePosition
+	}
+}
+
+// pqDefaultValue returns the SQL literal of the default value of a field.
+// The default value must be compatible with the type of the field.
+func pqDefaultValue(fieldType skydb.FieldType) string {
+	switch value := fieldType.Default.(type) {
+	case string:
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	panic(fmt.Sprintf("Unsupported default value = %v", fieldType.Default))
+}
+
+// parseColumnDefault parses the default expression of a column, such as
+// 'draft'::text or 42, into the default value of a field. It returns nil
+// if the expression is not a constant of the data type, for example the
+// nextval() of a sequence column.
+func parseColumnDefault(dataType skydb.DataType, expr string) interface{} {
+	if expr == "" {
+		return nil
+	}
+
+	var literal string
+	quoted := strings.HasPrefix(expr, "'")
+	if quoted {
+		end := strings.LastIndex(expr, "'")
+		if end == 0 {
+			return nil
+		}
+		literal = strings.Replace(expr[1:end], "''", "'", -1)
+	} else {
+		literal = expr
+		if i := strings.Index(literal, "::"); i >= 0 {
+			literal = literal[:i]
+		}
+		literal = strings.Trim(literal, "()")
+	}
+
+	switch dataType {
+	case skydb.TypeString:
+		if quoted {
+			return literal
+		}
+	case skydb.TypeNumber, skydb.TypeInteger:
+		if n, err := strconv.ParseFloat(literal, 64); err == nil {
+			return n
+		}
+	case skydb.TypeBoolean:
+		if b, err := strconv.ParseBool(literal); err == nil {
+			return b
+		}
 	}
+	return nil
 }
 
 type nullJSON struct {
@@ -0,0 +1,87 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/lann/squirrel"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// IncrementUnreadCounts upserts the unread counts of all users in
+// a single statement.
+func (c *conn) IncrementUnreadCounts(userIDs []string, channel string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(userIDs))
+	args := make([]interface{}, len(userIDs)+1)
+	args[0] = channel
+	for i, userID := range userIDs {
+		values[i] = fmt.Sprintf("($%d, $1, 1)", i+2)
+		args[i+1] = userID
+	}
+
+	stmt := fmt.Sprintf(`
+INSERT INTO %s AS uc (user_id, channel, count)
+VALUES %s
+ON CONFLICT (user_id, channel) DO UPDATE SET count = uc.count + 1`,
+		c.tableName("_unread_count"), strings.Join(values, ", "))
+
+	_, err := c.Exec(stmt, args...)
+	return err
+}
+
+func (c *conn) GetUnreadCounts(userID string) (skydb.UnreadCounts, error) {
+	builder := psql.Select("channel", "count").
+		From(c.tableName("_unread_count")).
+		Where("user_id = ? AND count > 0", userID)
+
+	rows, err := c.QueryWith(builder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := skydb.UnreadCounts{}
+	for rows.Next() {
+		var (
+			channel string
+			count   int64
+		)
+		if err := rows.Scan(&channel, &count); err != nil {
+			return nil, err
+		}
+		counts[channel] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (c *conn) ResetUnreadCounts(userID string, channel string) error {
+	builder := psql.Delete(c.tableName("_unread_count")).
+		Where("user_id = ?", userID)
+	if channel != "" {
+		builder = builder.Where(sq.Eq{"channel": channel})
+	}
+
+	_, err := c.ExecWith(builder)
+	return err
+}
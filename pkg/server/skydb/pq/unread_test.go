@@ -0,0 +1,68 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestUnreadCounts(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		So(c.IncrementUnreadCounts([]string{"alice", "bob"}, "message"), ShouldBeNil)
+		So(c.IncrementUnreadCounts([]string{"alice"}, "message"), ShouldBeNil)
+		So(c.IncrementUnreadCounts([]string{"alice"}, "comment"), ShouldBeNil)
+
+		Convey("counts unread of user", func() {
+			counts, err := c.GetUnreadCounts("alice")
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.UnreadCounts{"message": 2, "comment": 1})
+
+			counts, err = c.GetUnreadCounts("carol")
+			So(err, ShouldBeNil)
+			So(counts, ShouldBeEmpty)
+		})
+
+		Convey("increments nothing without users", func() {
+			So(c.IncrementUnreadCounts([]string{}, "message"), ShouldBeNil)
+		})
+
+		Convey("resets unread of channel", func() {
+			So(c.ResetUnreadCounts("alice", "message"), ShouldBeNil)
+
+			counts, err := c.GetUnreadCounts("alice")
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.UnreadCounts{"comment": 1})
+
+			counts, err = c.GetUnreadCounts("bob")
+			So(err, ShouldBeNil)
+			So(counts, ShouldResemble, skydb.UnreadCounts{"message": 1})
+		})
+
+		Convey("resets unread of all channels", func() {
+			So(c.ResetUnreadCounts("alice", ""), ShouldBeNil)
+
+			counts, err := c.GetUnreadCounts("alice")
+			So(err, ShouldBeNil)
+			So(counts, ShouldBeEmpty)
+		})
+	})
+}
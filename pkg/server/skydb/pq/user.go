@@ -164,13 +164,19 @@ func (c *conn) GetAuth(id string, authinfo *skydb.AuthInfo) error {
 	log.Warnf(id)
 	builder := c.baseUserBuilder().Where("id = ?", id)
 	scanner := c.QueryRowWith(builder)
-	return c.doScanAuth(authinfo, scanner)
+	if err := c.doScanAuth(authinfo, scanner); err != nil {
+		return err
+	}
+	return c.setInheritedRoles(authinfo)
 }
 
 func (c *conn) GetAuthByPrincipalID(principalID string, authinfo *skydb.AuthInfo) error {
 	builder := c.baseUserBuilder().Where("jsonb_exists(provider_info, ?)", principalID)
 	scanner := c.QueryRowWith(builder)
-	return c.doScanAuth(authinfo, scanner)
+	if err := c.doScanAuth(authinfo, scanner); err != nil {
+		return err
+	}
+	return c.setInheritedRoles(authinfo)
 }
 
 func (c *conn) DeleteAuth(id string) error {
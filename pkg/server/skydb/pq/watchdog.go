@@ -0,0 +1,98 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/skygeario/skygear-server/pkg/server/querywatchdog"
+)
+
+// queryWatchdog cancels queries exceeding its ceiling. Queries are not
+// cancelled if it is nil.
+var queryWatchdog *querywatchdog.Watchdog
+
+// SetQueryWatchdog sets the watchdog which cancels queries exceeding its
+// ceiling. It should be called before opening any connection.
+func SetQueryWatchdog(watchdog *querywatchdog.Watchdog) {
+	queryWatchdog = watchdog
+}
+
+// queryWatch is the watch of the query watchdog on an executing query.
+//
+// When the query exceeds the ceiling, the context is cancelled, which
+// makes lib/pq cancel the query on the server as pg_cancel_backend does.
+// The violation is reported only if the query has not yet returned, so
+// that reading rows of a finished query slowly is not reported.
+type queryWatch struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	timer    *time.Timer
+	mutex    sync.Mutex
+	finished bool
+}
+
+func (c *conn) watch(query string) *queryWatch {
+	w := &queryWatch{ctx: c.context}
+	if !queryWatchdog.Enabled() {
+		return w
+	}
+
+	watchdog := queryWatchdog
+	w.ctx, w.cancel = context.WithCancel(c.context)
+	w.timer = time.AfterFunc(watchdog.Ceiling, func() {
+		w.mutex.Lock()
+		executing := !w.finished
+		w.mutex.Unlock()
+
+		if executing {
+			key := watchdog.Report(query, time.Now().UTC())
+			log.WithFields(logrus.Fields{
+				"sql":     query,
+				"key":     key,
+				"ceiling": watchdog.Ceiling,
+			}).Warnln("Cancelling SQL exceeding the ceiling of query watchdog")
+		}
+		w.cancel()
+	})
+	return w
+}
+
+// Finish marks the query as returned. The context is still cancelled at
+// the ceiling, because rows of the query may still be read.
+func (w *queryWatch) Finish() {
+	if w.timer == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.finished = true
+}
+
+// Release marks the query as returned and releases the context.
+func (w *queryWatch) Release() {
+	if w.timer == nil {
+		return
+	}
+
+	w.Finish()
+	w.timer.Stop()
+	w.cancel()
+}
@@ -0,0 +1,68 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/querywatchdog"
+)
+
+func TestQueryWatchdog(t *testing.T) {
+	Convey("Conn with query watchdog", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		watchdog := querywatchdog.New(100 * time.Millisecond)
+		SetQueryWatchdog(watchdog)
+		defer SetQueryWatchdog(nil)
+
+		Convey("cancel query exceeding ceiling", func() {
+			_, err := c.Exec("SELECT pg_sleep(1)")
+			So(err, ShouldNotBeNil)
+
+			snapshot := watchdog.Snapshot()
+			So(snapshot, ShouldHaveLength, 1)
+			So(snapshot[querywatchdog.Key("SELECT pg_sleep(1)")].Count, ShouldEqual, 1)
+		})
+
+		Convey("cancel query returning rows exceeding ceiling", func() {
+			rows, err := c.Queryx("SELECT pg_sleep(1)")
+			if err == nil {
+				for rows.Next() {
+				}
+				err = rows.Err()
+				rows.Close()
+			}
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("not report query within ceiling", func() {
+			var n int
+			So(c.Get(&n, "SELECT 1"), ShouldBeNil)
+			So(n, ShouldEqual, 1)
+
+			rows, err := c.Queryx("SELECT 1")
+			So(err, ShouldBeNil)
+			rows.Close()
+
+			time.Sleep(200 * time.Millisecond)
+			So(watchdog.Snapshot(), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,200 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	sq "github.com/lann/squirrel"
+	"github.com/lib/pq"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) GetWebhook(id string) (*skydb.Webhook, error) {
+	builder := c.selectWebhooks().Where("id = ?", id)
+
+	webhook, err := scanWebhook(c.QueryRowWith(builder))
+	if err == sql.ErrNoRows {
+		return nil, skydb.ErrWebhookNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (c *conn) GetWebhooks() ([]skydb.Webhook, error) {
+	rows, err := c.QueryWith(c.selectWebhooks().OrderBy("created_at", "id"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []skydb.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (c *conn) SaveWebhook(webhook *skydb.Webhook) error {
+	events, err := json.Marshal(nonNilStrings(webhook.Events))
+	if err != nil {
+		return err
+	}
+	recordTypes, err := json.Marshal(nonNilStrings(webhook.RecordTypes))
+	if err != nil {
+		return err
+	}
+
+	builder := psql.Insert(c.tableName("_webhook")).
+		Columns("id", "url", "secret", "events", "record_types", "delta",
+			"paused", "disabled", "created_at").
+		Values(webhook.ID, webhook.URL, webhook.Secret, string(events),
+			string(recordTypes), webhook.Delta, webhook.Paused,
+			webhook.Disabled, webhook.CreatedAt.UTC()).
+		Suffix(`ON CONFLICT (id) DO UPDATE
+			SET url = EXCLUDED.url, secret = EXCLUDED.secret,
+				events = EXCLUDED.events, record_types = EXCLUDED.record_types,
+				delta = EXCLUDED.delta,
+				paused = EXCLUDED.paused, disabled = EXCLUDED.disabled,
+				consecutive_failures = CASE WHEN EXCLUDED.disabled
+					THEN _webhook.consecutive_failures ELSE 0 END`)
+
+	_, err = c.ExecWith(builder)
+	return err
+}
+
+func (c *conn) DeleteWebhook(id string) error {
+	builder := psql.Delete(c.tableName("_webhook")).
+		Where("id = ?", id)
+
+	result, err := c.ExecWith(builder)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return skydb.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (c *conn) RecordWebhookDelivery(id string, deliveryErr string, at time.Time, failureThreshold int) (bool, error) {
+	builder := psql.Update(c.tableName("_webhook")).
+		Where("id = ?", id).
+		Suffix("RETURNING disabled")
+	if deliveryErr == "" {
+		builder = builder.
+			Set("delivered", sq.Expr("delivered + 1")).
+			Set("consecutive_failures", 0).
+			Set("last_delivered_at", at.UTC())
+	} else {
+		builder = builder.
+			Set("failed", sq.Expr("failed + 1")).
+			Set("consecutive_failures", sq.Expr("consecutive_failures + 1")).
+			Set("last_failed_at", at.UTC()).
+			Set("last_error", deliveryErr).
+			Set("disabled", sq.Expr("disabled OR (? > 0 AND consecutive_failures + 1 >= ?)",
+				failureThreshold, failureThreshold))
+	}
+
+	var disabled bool
+	err := c.QueryRowWith(builder).Scan(&disabled)
+	if err == sql.ErrNoRows {
+		return false, skydb.ErrWebhookNotFound
+	} else if err != nil {
+		return false, err
+	}
+	return disabled, nil
+}
+
+func (c *conn) selectWebhooks() sq.SelectBuilder {
+	return psql.Select("id", "url", "secret", "events", "record_types",
+		"delta", "paused", "disabled", "created_at", "delivered", "failed",
+		"consecutive_failures", "last_delivered_at", "last_failed_at",
+		"last_error").
+		From(c.tableName("_webhook"))
+}
+
+type webhookScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(scanner webhookScanner) (*skydb.Webhook, error) {
+	var (
+		webhook         skydb.Webhook
+		events          []byte
+		recordTypes     []byte
+		lastDeliveredAt pq.NullTime
+		lastFailedAt    pq.NullTime
+	)
+	if err := scanner.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Secret,
+		&events,
+		&recordTypes,
+		&webhook.Delta,
+		&webhook.Paused,
+		&webhook.Disabled,
+		&webhook.CreatedAt,
+		&webhook.Stats.Delivered,
+		&webhook.Stats.Failed,
+		&webhook.Stats.ConsecutiveFailures,
+		&lastDeliveredAt,
+		&lastFailedAt,
+		&webhook.Stats.LastError,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(events, &webhook.Events); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(recordTypes, &webhook.RecordTypes); err != nil {
+		return nil, err
+	}
+
+	webhook.CreatedAt = webhook.CreatedAt.In(time.UTC)
+	if lastDeliveredAt.Valid {
+		t := lastDeliveredAt.Time.In(time.UTC)
+		webhook.Stats.LastDeliveredAt = &t
+	}
+	if lastFailedAt.Valid {
+		t := lastFailedAt.Time.In(time.UTC)
+		webhook.Stats.LastFailedAt = &t
+	}
+	return &webhook, nil
+}
+
+func nonNilStrings(strs []string) []string {
+	if strs == nil {
+		return []string{}
+	}
+	return strs
+}
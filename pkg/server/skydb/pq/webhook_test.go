@@ -0,0 +1,138 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pq
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func TestWebhook(t *testing.T) {
+	Convey("Conn", t, func() {
+		c := getTestConn(t)
+		defer cleanupConn(t, c)
+
+		now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		noteHook := skydb.Webhook{
+			ID:          "webhook1",
+			URL:         "https://example.com/note",
+			Secret:      "secret",
+			Events:      []string{"record:*"},
+			RecordTypes: []string{"note"},
+			Delta:       true,
+			CreatedAt:   now,
+		}
+		authHook := skydb.Webhook{
+			ID:          "webhook2",
+			URL:         "https://example.com/auth",
+			Events:      []string{"auth:signup"},
+			RecordTypes: []string{},
+			CreatedAt:   now.Add(time.Second),
+		}
+		So(c.SaveWebhook(&noteHook), ShouldBeNil)
+		So(c.SaveWebhook(&authHook), ShouldBeNil)
+
+		Convey("gets webhook", func() {
+			webhook, err := c.GetWebhook("webhook1")
+			So(err, ShouldBeNil)
+			So(*webhook, ShouldResemble, noteHook)
+		})
+
+		Convey("gets webhooks ordered by creation time", func() {
+			webhooks, err := c.GetWebhooks()
+			So(err, ShouldBeNil)
+			So(webhooks, ShouldResemble, []skydb.Webhook{noteHook, authHook})
+		})
+
+		Convey("returns error of non-existent webhook", func() {
+			_, err := c.GetWebhook("webhook3")
+			So(err, ShouldEqual, skydb.ErrWebhookNotFound)
+			So(c.DeleteWebhook("webhook3"), ShouldEqual, skydb.ErrWebhookNotFound)
+		})
+
+		Convey("updates webhook", func() {
+			noteHook.Paused = true
+			noteHook.Events = []string{"record:created"}
+			So(c.SaveWebhook(&noteHook), ShouldBeNil)
+
+			webhook, err := c.GetWebhook("webhook1")
+			So(err, ShouldBeNil)
+			So(webhook.Paused, ShouldBeTrue)
+			So(webhook.Events, ShouldResemble, []string{"record:created"})
+		})
+
+		Convey("deletes webhook", func() {
+			So(c.DeleteWebhook("webhook1"), ShouldBeNil)
+			_, err := c.GetWebhook("webhook1")
+			So(err, ShouldEqual, skydb.ErrWebhookNotFound)
+		})
+
+		Convey("records delivery", func() {
+			disabled, err := c.RecordWebhookDelivery("webhook1", "", now, 2)
+			So(err, ShouldBeNil)
+			So(disabled, ShouldBeFalse)
+			disabled, err = c.RecordWebhookDelivery("webhook1", "status 500", now.Add(time.Second), 2)
+			So(err, ShouldBeNil)
+			So(disabled, ShouldBeFalse)
+
+			webhook, err := c.GetWebhook("webhook1")
+			So(err, ShouldBeNil)
+			lastDeliveredAt := now
+			lastFailedAt := now.Add(time.Second)
+			So(webhook.Stats, ShouldResemble, skydb.WebhookStats{
+				Delivered:           1,
+				Failed:              1,
+				ConsecutiveFailures: 1,
+				LastDeliveredAt:     &lastDeliveredAt,
+				LastFailedAt:        &lastFailedAt,
+				LastError:           "status 500",
+			})
+		})
+
+		Convey("disables webhook after consecutive failures", func() {
+			disabled, err := c.RecordWebhookDelivery("webhook1", "status 500", now, 2)
+			So(err, ShouldBeNil)
+			So(disabled, ShouldBeFalse)
+			disabled, err = c.RecordWebhookDelivery("webhook1", "status 500", now, 2)
+			So(err, ShouldBeNil)
+			So(disabled, ShouldBeTrue)
+
+			Convey("and resets failures when enabled again", func() {
+				webhook, err := c.GetWebhook("webhook1")
+				So(err, ShouldBeNil)
+				webhook.Disabled = false
+				So(c.SaveWebhook(webhook), ShouldBeNil)
+
+				webhook, err = c.GetWebhook("webhook1")
+				So(err, ShouldBeNil)
+				So(webhook.Disabled, ShouldBeFalse)
+				So(webhook.Stats.ConsecutiveFailures, ShouldEqual, 0)
+				So(webhook.Stats.Failed, ShouldEqual, 2)
+			})
+		})
+
+		Convey("never disables webhook without threshold", func() {
+			for i := 0; i < 3; i++ {
+				disabled, err := c.RecordWebhookDelivery("webhook1", "status 500", now, 0)
+				So(err, ShouldBeNil)
+				So(disabled, ShouldBeFalse)
+			}
+		})
+	})
+}
@@ -18,6 +18,7 @@ package skydb
 
 import (
 	"strings"
+	"time"
 
 	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
@@ -33,6 +34,19 @@ const (
 	Desc = Descending
 )
 
+// NullOrder denotes where records with null values are placed in the
+// order of Records returned from a Query.
+type NullOrder int
+
+// A list of NullOrder. NullsDefault places nulls as the database does by
+// default, which in Postgres is after non-null values in ascending order
+// and before them in descending order.
+const (
+	NullsDefault NullOrder = iota
+	NullsFirst
+	NullsLast
+)
+
 // Sort specifies the order of a collection of Records returned from a Query.
 //
 // Record order can be sorted w.r.t. a record field or a value returned
@@ -40,6 +54,7 @@ const (
 type Sort struct {
 	Expression Expression
 	Order      SortOrder
+	Nulls      NullOrder
 }
 
 // Accept implements the Visitor pattern.
@@ -73,6 +88,11 @@ const (
 	ILike
 	In
 	Functional
+	IsNull
+	IsNotNull
+	ContainsAll
+	ContainsAny
+	Between
 )
 
 // IsCompound checks whether the Operator is a compound operator, meaning the
@@ -92,7 +112,29 @@ func (op Operator) IsBinary() bool {
 	switch op {
 	default:
 		return false
-	case Equal, GreaterThan, LessThan, GreaterThanOrEqual, LessThanOrEqual, NotEqual, Like, ILike, In:
+	case Equal, GreaterThan, LessThan, GreaterThanOrEqual, LessThanOrEqual, NotEqual, Like, ILike, In, ContainsAll, ContainsAny:
+		return true
+	}
+}
+
+// IsUnary checks whether the Operator determines the result of a predicate
+// by examining a single subexpression.
+func (op Operator) IsUnary() bool {
+	switch op {
+	default:
+		return false
+	case IsNull, IsNotNull:
+		return true
+	}
+}
+
+// IsTernary checks whether the Operator determines the result of a
+// predicate by comparing a subexpression with two other subexpressions.
+func (op Operator) IsTernary() bool {
+	switch op {
+	default:
+		return false
+	case Between:
 		return true
 	}
 }
@@ -209,6 +251,14 @@ func (p Predicate) validate(parentPredicate *Predicate) skyerr.Error {
 		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
 			"binary predicate must have 2 operands, got %d", len(p.Children))
 	}
+	if p.Operator.IsUnary() && len(p.Children) != 1 {
+		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			"unary predicate must have 1 operand, got %d", len(p.Children))
+	}
+	if p.Operator.IsTernary() && len(p.Children) != 3 {
+		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			"ternary predicate must have 3 operands, got %d", len(p.Children))
+	}
 	if p.Operator == Functional && len(p.Children) != 1 {
 		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
 			"functional predicate must have 1 operand, got %d", len(p.Children))
@@ -241,6 +291,8 @@ func (p Predicate) validate(parentPredicate *Predicate) skyerr.Error {
 		return p.validateFunctionalPredicate(parentPredicate)
 	case Equal:
 		return p.validateEqualPredicate(parentPredicate)
+	case ContainsAll, ContainsAny:
+		return p.validateArrayPredicate(parentPredicate)
 	}
 	return nil
 }
@@ -259,6 +311,13 @@ func (p Predicate) validateFunctionalPredicate(parentPredicate *Predicate) skyer
 				`user relation predicate with "%d" relation is not supported`,
 				f.RelationName)
 		}
+	case DescendantFunc:
+		if f.MaxDepth < 0 {
+			return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+				`max depth of descendant predicate must not be negative, got %d`,
+				f.MaxDepth)
+		}
+	case AncestorFunc:
 	default:
 		return skyerr.NewError(skyerr.NotSupported,
 			`unsupported function for functional predicate`)
@@ -290,6 +349,31 @@ func (p Predicate) validateEqualPredicate(parentPredicate *Predicate) skyerr.Err
 	return nil
 }
 
+func (p Predicate) validateArrayPredicate(parentPredicate *Predicate) skyerr.Error {
+	lhs := p.Children[0].(Expression)
+	rhs := p.Children[1].(Expression)
+
+	if !lhs.IsKeyPath() {
+		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			`left operand of %v must be a key path`, p.Operator)
+	}
+	if !rhs.IsLiteralArray() {
+		return skyerr.NewErrorf(skyerr.RecordQueryInvalid,
+			`right operand of %v must be an array`, p.Operator)
+	}
+
+	if p.Operator == ContainsAny {
+		for _, value := range rhs.Value.([]interface{}) {
+			if _, ok := value.(string); !ok {
+				return skyerr.NewErrorf(skyerr.NotSupported,
+					`%v comparison of non-string value "%v" is not supported`,
+					p.Operator, value)
+			}
+		}
+	}
+	return nil
+}
+
 // GetSubPredicates returns Predicate.Children as []Predicate.
 //
 // This method is only valid when Operator is either And, Or and Not. Caller
@@ -355,12 +439,64 @@ type Query struct {
 	Limit        *uint64
 	Offset       uint64
 
+	// Includes is a list of reference fields. Records referenced by
+	// these fields are loaded together with the queried records, and
+	// are available from Rows.IncludedRecords.
+	Includes []string
+
+	// RelatedIncludes are the queries of records referencing the queried
+	// records, keyed by the transient field of the queried records
+	// in which the related records are returned.
+	RelatedIncludes map[string]RelatedQuery
+
+	// After, if not nil, paginates the query by keyset. Only records
+	// sorted after the cursor are returned, where records having the
+	// same values in Sorts are ordered by their keys.
+	After *Cursor
+
+	// PageSize, if not zero, paginates the query by keyset and limits
+	// the number of returned records. Rows.NextCursor returns the
+	// cursor of the next page if there are more records.
+	PageSize uint64
+
+	// DistinctOn is a list of key paths. If not empty, only the first
+	// record of each group of records having the same values in these
+	// key paths is returned, where records in a group are ordered
+	// by Sorts. It cannot be used with keyset pagination.
+	DistinctOn []string
+
+	// Explain, if true, requests the database to analyze the execution
+	// of the query. The query plan is available from Rows.QueryPlan.
+	// It is intended for debugging slow queries.
+	Explain bool
+
+	// IncludeDeleted, if true, returns soft-deleted records together
+	// with the other records. Soft-deleted records are excluded
+	// otherwise.
+	IncludeDeleted bool
+
+	// AsOf, if not nil, returns the records as they were at the time,
+	// which are resolved against the change history of the record type.
+	// It requires the history of the record type to be enabled. Records
+	// loaded by Includes are not resolved and are as they are now.
+	AsOf *time.Time
+
+	// EstimateCount, if true, makes Database.QueryCount return the number
+	// of matching records estimated by the database instead of counting
+	// them, which is much cheaper on large record types.
+	EstimateCount bool
+
 	// The following fields are generated from the server side, rather
 	// than supplied from the client side.
 	ViewAsUser          *AuthInfo
 	BypassAccessControl bool
 }
 
+// IsPaginatedByKeyset returns true if the query is paginated by keyset.
+func (q Query) IsPaginatedByKeyset() bool {
+	return q.After != nil || q.PageSize > 0
+}
+
 // Accept implements the Visitor pattern.
 func (q Query) Accept(visitor Visitor) {
 	if v, ok := visitor.(QueryVisitor); ok {
@@ -439,6 +575,338 @@ func (f CountFunc) DataType() DataType {
 	return TypeNumber
 }
 
+// RandomFunc represents a function that returns a random number between
+// 0 and 1 for each record, which is used to sort records in random order.
+//
+// If Seed is not nil, the random numbers are seeded with it so that
+// queries with the same seed sort records in the same order. The seed
+// must be between -1 and 1.
+type RandomFunc struct {
+	Seed *float64
+}
+
+// Args implements the Func interface
+func (f RandomFunc) Args() []interface{} {
+	if f.Seed == nil {
+		return []interface{}{}
+	}
+	return []interface{}{*f.Seed}
+}
+
+func (f RandomFunc) DataType() DataType {
+	return TypeNumber
+}
+
+// LowerFunc represents a function that converts the value of a Record's
+// string field to lower case. When compared with a string literal, the
+// literal is converted to lower case too, making the comparison
+// case-insensitive. Such comparisons are indexed by an index added by the
+// add_lower_index operation of a schema migration.
+type LowerFunc struct {
+	KeyPath string
+}
+
+// Args implements the Func interface
+func (f LowerFunc) Args() []interface{} {
+	return []interface{}{f.KeyPath}
+}
+
+func (f LowerFunc) DataType() DataType {
+	return TypeString
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f LowerFunc) ReferencedKeyPaths() []string {
+	return []string{f.KeyPath}
+}
+
+// ConcatFunc represents a function that concatenates the string
+// representation of its expressions.
+type ConcatFunc struct {
+	Expressions []Expression
+}
+
+// Args implements the Func interface
+func (f ConcatFunc) Args() []interface{} {
+	args := []interface{}{}
+	for _, expr := range f.Expressions {
+		args = append(args, expr)
+	}
+	return args
+}
+
+func (f ConcatFunc) DataType() DataType {
+	return TypeString
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f ConcatFunc) ReferencedKeyPaths() []string {
+	return referencedKeyPaths(f.Expressions...)
+}
+
+// ArithmeticOperator is the operator of an ArithmeticFunc.
+type ArithmeticOperator string
+
+// A list of supported arithmetic operators.
+const (
+	Add      ArithmeticOperator = "+"
+	Subtract ArithmeticOperator = "-"
+	Multiply ArithmeticOperator = "*"
+	Divide   ArithmeticOperator = "/"
+)
+
+// ArithmeticFunc represents a function that evaluates an arithmetic
+// operation on two numeric expressions.
+type ArithmeticFunc struct {
+	Operator ArithmeticOperator
+	Left     Expression
+	Right    Expression
+}
+
+// Args implements the Func interface
+func (f ArithmeticFunc) Args() []interface{} {
+	return []interface{}{f.Left, f.Right}
+}
+
+func (f ArithmeticFunc) DataType() DataType {
+	return TypeNumber
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f ArithmeticFunc) ReferencedKeyPaths() []string {
+	return referencedKeyPaths(f.Left, f.Right)
+}
+
+// UpperFunc represents a function that converts a string expression to
+// upper case.
+type UpperFunc struct {
+	Expression Expression
+}
+
+// Args implements the Func interface
+func (f UpperFunc) Args() []interface{} {
+	return []interface{}{f.Expression}
+}
+
+func (f UpperFunc) DataType() DataType {
+	return TypeString
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f UpperFunc) ReferencedKeyPaths() []string {
+	return referencedKeyPaths(f.Expression)
+}
+
+// LengthFunc represents a function that returns the number of characters
+// of a string expression.
+type LengthFunc struct {
+	Expression Expression
+}
+
+// Args implements the Func interface
+func (f LengthFunc) Args() []interface{} {
+	return []interface{}{f.Expression}
+}
+
+func (f LengthFunc) DataType() DataType {
+	return TypeNumber
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f LengthFunc) ReferencedKeyPaths() []string {
+	return referencedKeyPaths(f.Expression)
+}
+
+// AbsFunc represents a function that returns the absolute value of a
+// numeric expression.
+type AbsFunc struct {
+	Expression Expression
+}
+
+// Args implements the Func interface
+func (f AbsFunc) Args() []interface{} {
+	return []interface{}{f.Expression}
+}
+
+func (f AbsFunc) DataType() DataType {
+	return TypeNumber
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f AbsFunc) ReferencedKeyPaths() []string {
+	return referencedKeyPaths(f.Expression)
+}
+
+// CoalesceFunc represents a function that returns the value of the first
+// of its expressions that is not null.
+type CoalesceFunc struct {
+	Expressions []Expression
+}
+
+// Args implements the Func interface
+func (f CoalesceFunc) Args() []interface{} {
+	args := []interface{}{}
+	for _, expr := range f.Expressions {
+		args = append(args, expr)
+	}
+	return args
+}
+
+// DataType implements the Func interface. The data type is derived from
+// the first literal or function in the expressions, because the type of
+// a key path is not known without the record schema.
+func (f CoalesceFunc) DataType() DataType {
+	for _, expr := range f.Expressions {
+		switch expr.Type {
+		case Function:
+			return expr.Value.(Func).DataType()
+		case Literal:
+			switch expr.Value.(type) {
+			case string:
+				return TypeString
+			case bool:
+				return TypeBoolean
+			case float64:
+				return TypeNumber
+			}
+		}
+	}
+	return TypeString
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f CoalesceFunc) ReferencedKeyPaths() []string {
+	return referencedKeyPaths(f.Expressions...)
+}
+
+func referencedKeyPaths(exprs ...Expression) []string {
+	keyPaths := []string{}
+	for _, expr := range exprs {
+		switch expr.Type {
+		case KeyPath:
+			keyPaths = append(keyPaths, expr.Value.(string))
+		case Function:
+			if fn, ok := expr.Value.(KeyPathFunc); ok {
+				keyPaths = append(keyPaths, fn.ReferencedKeyPaths()...)
+			}
+		}
+	}
+	return keyPaths
+}
+
+// DatePart is a field of a date extracted by DatePartFunc.
+type DatePart string
+
+// A list of supported date parts.
+const (
+	DatePartYear  DatePart = "year"
+	DatePartMonth DatePart = "month"
+	DatePartDay   DatePart = "day"
+	// DatePartDayOfWeek is the day of week, from 0 (Sunday) to 6 (Saturday).
+	DatePartDayOfWeek DatePart = "dow"
+	DatePartHour      DatePart = "hour"
+)
+
+// IsValid returns whether the date part is supported.
+func (part DatePart) IsValid() bool {
+	switch part {
+	case DatePartYear, DatePartMonth, DatePartDay, DatePartDayOfWeek, DatePartHour:
+		return true
+	default:
+		return false
+	}
+}
+
+// DatePartFunc represents a function that extracts a part, such as the
+// year or the day of week, from the value of a Record's datetime field.
+type DatePartFunc struct {
+	KeyPath string
+	Part    DatePart
+}
+
+// Args implements the Func interface
+func (f DatePartFunc) Args() []interface{} {
+	return []interface{}{f.KeyPath, f.Part}
+}
+
+func (f DatePartFunc) DataType() DataType {
+	return TypeNumber
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f DatePartFunc) ReferencedKeyPaths() []string {
+	return []string{f.KeyPath}
+}
+
+// DescendantFunc represents a function that evaluates whether a record
+// is a descendant of the specified record, by following the
+// self-referencing parent field named by ParentKeyPath.
+//
+// Only descendants within MaxDepth levels are matched if MaxDepth
+// is positive.
+type DescendantFunc struct {
+	ParentKeyPath string
+	RecordID      RecordID
+	MaxDepth      int
+}
+
+// Args implements the Func interface
+func (f DescendantFunc) Args() []interface{} {
+	return []interface{}{f.ParentKeyPath, f.RecordID, f.MaxDepth}
+}
+
+func (f DescendantFunc) DataType() DataType {
+	return TypeBoolean
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f DescendantFunc) ReferencedKeyPaths() []string {
+	return []string{f.ParentKeyPath}
+}
+
+// AncestorFunc represents a function that evaluates whether a record
+// is an ancestor of the specified record, by following the
+// self-referencing parent field named by ParentKeyPath.
+type AncestorFunc struct {
+	ParentKeyPath string
+	RecordID      RecordID
+}
+
+// Args implements the Func interface
+func (f AncestorFunc) Args() []interface{} {
+	return []interface{}{f.ParentKeyPath, f.RecordID}
+}
+
+func (f AncestorFunc) DataType() DataType {
+	return TypeBoolean
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f AncestorFunc) ReferencedKeyPaths() []string {
+	return []string{f.ParentKeyPath}
+}
+
+// TreeDepthFunc represents a function that returns the number of
+// ancestors of a record, by following the self-referencing parent
+// field named by ParentKeyPath. A record without parent has a depth of 0.
+type TreeDepthFunc struct {
+	ParentKeyPath string
+}
+
+// Args implements the Func interface
+func (f TreeDepthFunc) Args() []interface{} {
+	return []interface{}{f.ParentKeyPath}
+}
+
+func (f TreeDepthFunc) DataType() DataType {
+	return TypeNumber
+}
+
+// ReferencedKeyPaths implements the KeyPathFunc interface.
+func (f TreeDepthFunc) ReferencedKeyPaths() []string {
+	return []string{f.ParentKeyPath}
+}
+
 // UserRelationFunc represents a function that is used to evaulate
 // whether a record satisfy certain user-based relation
 type UserRelationFunc struct {
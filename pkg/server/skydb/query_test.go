@@ -213,4 +213,61 @@ func TestMalformedPredicate(t *testing.T) {
 			So(err, ShouldNotBeNil)
 		})
 	})
+	Convey("Predicate with IsNull", t, func() {
+		Convey("having two operands", func() {
+			predicate := Predicate{
+				Operator: IsNull,
+				Children: []interface{}{
+					Expression{
+						Type:  KeyPath,
+						Value: "content",
+					},
+					Expression{
+						Type:  Literal,
+						Value: nil,
+					},
+				},
+			}
+			err := predicate.Validate()
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("Predicate with ContainsAny", t, func() {
+		Convey("comparing non-string values", func() {
+			predicate := Predicate{
+				Operator: ContainsAny,
+				Children: []interface{}{
+					Expression{
+						Type:  KeyPath,
+						Value: "tags",
+					},
+					Expression{
+						Type:  Literal,
+						Value: []interface{}{"hello", float64(1)},
+					},
+				},
+			}
+			err := predicate.Validate()
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("Predicate with Between", t, func() {
+		Convey("having two operands", func() {
+			predicate := Predicate{
+				Operator: Between,
+				Children: []interface{}{
+					Expression{
+						Type:  KeyPath,
+						Value: "price",
+					},
+					Expression{
+						Type:  Literal,
+						Value: float64(10),
+					},
+				},
+			}
+			err := predicate.Validate()
+			So(err, ShouldNotBeNil)
+		})
+	})
 }
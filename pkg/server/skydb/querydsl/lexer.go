@@ -0,0 +1,137 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querydsl
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	Type  tokenType
+	Value string
+	Pos   int
+}
+
+// is returns true if the token is the keyword, case-insensitively.
+func (t token) is(keyword string) bool {
+	return t.Type == tokenIdent && strings.EqualFold(t.Value, keyword)
+}
+
+func (t token) String() string {
+	switch t.Type {
+	case tokenEOF:
+		return "end of query"
+	case tokenString:
+		return strconv.Quote(t.Value)
+	}
+	return t.Value
+}
+
+func lex(s string) ([]token, error) {
+	runes := []rune(s)
+	tokens := []token{}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case r == '"' || r == '\'':
+			value, end, err := lexString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenString, value, i})
+			i = end
+		case strings.ContainsRune("=!<>", r):
+			end := i + 1
+			if end < len(runes) && (runes[end] == '=' || (r == '<' && runes[end] == '>')) {
+				end++
+			}
+			op := string(runes[i:end])
+			if op == "!" {
+				return nil, &Error{Pos: i, Message: `unexpected "!"`}
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i = end
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.' ||
+				runes[end] == 'e' || runes[end] == 'E') {
+				end++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[i:end]), i})
+			i = end
+		case isIdentRune(r):
+			end := i + 1
+			for end < len(runes) && (isIdentRune(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[i:end]), i})
+			i = end
+		default:
+			return nil, &Error{Pos: i, Message: "unexpected " + strconv.QuoteRune(r)}
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(runes)}), nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// lexString reads the string quoted by the rune at start. The quote can
+// be escaped with a backslash.
+func lexString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	value := []rune{}
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				value = append(value, runes[i])
+			}
+		case quote:
+			return string(value), i + 1, nil
+		default:
+			value = append(value, runes[i])
+		}
+	}
+	return "", 0, &Error{Pos: start, Message: "unterminated string"}
+}
@@ -0,0 +1,394 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package querydsl parses a query written in a concise string language
+into the predicate and sorts of a skydb.Query, for example:
+
+	status = "open" AND (price < 100 OR tags CONTAINS ANY ("sale"))
+	ORDER BY created_at DESC NULLS LAST, title
+
+A comparison compares a key path with a literal or another key path with
+one of =, !=, <>, <, <=, > and >=. Other conditions are:
+
+	title LIKE "%skygear%"      title ILIKE "%skygear%"
+	category IN ("a", "b")      category NOT IN ("a", "b")
+	price BETWEEN 10 AND 20     due_at IS NULL
+	tags CONTAINS ALL ("a")     due_at IS NOT NULL
+
+Conditions are combined with AND, OR, NOT and parentheses. Keywords are
+case-insensitive. Literals are strings quoted by double or single quotes,
+numbers, TRUE, FALSE and NULL.
+*/
+package querydsl
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Error is returned when the query cannot be parsed.
+type Error struct {
+	// Pos is the position of the character in the query at which the
+	// error occurs.
+	Pos     int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos)
+}
+
+// Parse parses the query into the predicate and the sorts. The predicate
+// is empty if the query only specifies the sorts.
+func Parse(query string) (predicate skydb.Predicate, sorts []skydb.Sort, err error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return
+	}
+
+	p := parser{tokens: tokens}
+	if !p.peek().is("ORDER") && p.peek().Type != tokenEOF {
+		if predicate, err = p.parseOr(); err != nil {
+			return
+		}
+	}
+
+	if p.peek().is("ORDER") {
+		p.next()
+		if err = p.expectKeyword("BY"); err != nil {
+			return
+		}
+		if sorts, err = p.parseSorts(); err != nil {
+			return
+		}
+	}
+
+	if t := p.peek(); t.Type != tokenEOF {
+		err = p.unexpected(t)
+	}
+	return
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.Type != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token) error {
+	return &Error{Pos: t.Pos, Message: "unexpected " + t.String()}
+}
+
+func (p *parser) expect(tokenType tokenType) (token, error) {
+	t := p.next()
+	if t.Type != tokenType {
+		return t, p.unexpected(t)
+	}
+	return t, nil
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if t := p.next(); !t.is(keyword) {
+		return &Error{Pos: t.Pos, Message: fmt.Sprintf("expected %s, got %s", keyword, t)}
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (skydb.Predicate, error) {
+	return p.parseCompound(skydb.Or, "OR", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (skydb.Predicate, error) {
+	return p.parseCompound(skydb.And, "AND", p.parseNot)
+}
+
+func (p *parser) parseCompound(op skydb.Operator, keyword string, parseOperand func() (skydb.Predicate, error)) (skydb.Predicate, error) {
+	first, err := parseOperand()
+	if err != nil {
+		return skydb.Predicate{}, err
+	}
+
+	children := []interface{}{first}
+	for p.peek().is(keyword) {
+		p.next()
+		operand, err := parseOperand()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		children = append(children, operand)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return skydb.Predicate{Operator: op, Children: children}, nil
+}
+
+func (p *parser) parseNot() (skydb.Predicate, error) {
+	if p.peek().is("NOT") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		return not(operand), nil
+	}
+
+	if p.peek().Type == tokenLParen {
+		p.next()
+		predicate, err := p.parseOr()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return skydb.Predicate{}, err
+		}
+		return predicate, nil
+	}
+
+	return p.parseCondition()
+}
+
+func not(predicate skydb.Predicate) skydb.Predicate {
+	return skydb.Predicate{Operator: skydb.Not, Children: []interface{}{predicate}}
+}
+
+var comparisonOperators = map[string]skydb.Operator{
+	"=":  skydb.Equal,
+	"!=": skydb.NotEqual,
+	"<>": skydb.NotEqual,
+	"<":  skydb.LessThan,
+	"<=": skydb.LessThanOrEqual,
+	">":  skydb.GreaterThan,
+	">=": skydb.GreaterThanOrEqual,
+}
+
+func (p *parser) parseCondition() (skydb.Predicate, error) {
+	lhs, err := p.parseKeyPath()
+	if err != nil {
+		return skydb.Predicate{}, err
+	}
+
+	t := p.next()
+	if t.Type == tokenOperator {
+		rhs, err := p.parseOperand()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		return binary(comparisonOperators[t.Value], lhs, rhs), nil
+	}
+
+	negated := false
+	if t.is("NOT") {
+		negated = true
+		t = p.next()
+	}
+
+	var predicate skydb.Predicate
+	switch {
+	case t.is("LIKE"), t.is("ILIKE"):
+		pattern, err := p.expect(tokenString)
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		op := skydb.Like
+		if t.is("ILIKE") {
+			op = skydb.ILike
+		}
+		predicate = binary(op, lhs, literal(pattern.Value))
+	case t.is("IN"):
+		values, err := p.parseList()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		predicate = binary(skydb.In, lhs, literal(values))
+	case t.is("BETWEEN"):
+		low, err := p.parseOperand()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return skydb.Predicate{}, err
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		predicate = skydb.Predicate{
+			Operator: skydb.Between,
+			Children: []interface{}{lhs, low, high},
+		}
+	case t.is("CONTAINS") && !negated:
+		quantifier := p.next()
+		op := skydb.ContainsAll
+		if quantifier.is("ANY") {
+			op = skydb.ContainsAny
+		} else if !quantifier.is("ALL") {
+			return skydb.Predicate{}, &Error{Pos: quantifier.Pos, Message: fmt.Sprintf("expected ALL or ANY, got %s", quantifier)}
+		}
+		values, err := p.parseList()
+		if err != nil {
+			return skydb.Predicate{}, err
+		}
+		predicate = binary(op, lhs, literal(values))
+	case t.is("IS") && !negated:
+		op := skydb.IsNull
+		if p.peek().is("NOT") {
+			p.next()
+			op = skydb.IsNotNull
+		}
+		if err := p.expectKeyword("NULL"); err != nil {
+			return skydb.Predicate{}, err
+		}
+		predicate = skydb.Predicate{Operator: op, Children: []interface{}{lhs}}
+	default:
+		return skydb.Predicate{}, p.unexpected(t)
+	}
+
+	if negated {
+		return not(predicate), nil
+	}
+	return predicate, nil
+}
+
+func binary(op skydb.Operator, lhs skydb.Expression, rhs skydb.Expression) skydb.Predicate {
+	return skydb.Predicate{Operator: op, Children: []interface{}{lhs, rhs}}
+}
+
+func literal(value interface{}) skydb.Expression {
+	return skydb.Expression{Type: skydb.Literal, Value: value}
+}
+
+func (p *parser) parseKeyPath() (skydb.Expression, error) {
+	t, err := p.expect(tokenIdent)
+	if err != nil {
+		return skydb.Expression{}, err
+	}
+
+	keyPath := t.Value
+	if keyPath == "_owner" {
+		keyPath = "_owner_id"
+	}
+	return skydb.Expression{Type: skydb.KeyPath, Value: keyPath}, nil
+}
+
+// parseOperand parses a literal, or a key path which is an identifier
+// other than TRUE, FALSE and NULL.
+func (p *parser) parseOperand() (skydb.Expression, error) {
+	t := p.peek()
+	if t.Type == tokenIdent && !t.is("TRUE") && !t.is("FALSE") && !t.is("NULL") {
+		return p.parseKeyPath()
+	}
+
+	value, err := p.parseLiteral()
+	if err != nil {
+		return skydb.Expression{}, err
+	}
+	return literal(value), nil
+}
+
+func (p *parser) parseLiteral() (interface{}, error) {
+	t := p.next()
+	switch {
+	case t.Type == tokenString:
+		return t.Value, nil
+	case t.Type == tokenNumber:
+		number, err := strconv.ParseFloat(t.Value, 64)
+		if err != nil {
+			return nil, &Error{Pos: t.Pos, Message: "invalid number " + t.Value}
+		}
+		return number, nil
+	case t.is("TRUE"):
+		return true, nil
+	case t.is("FALSE"):
+		return false, nil
+	case t.is("NULL"):
+		return nil, nil
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *parser) parseList() ([]interface{}, error) {
+	if _, err := p.expect(tokenLParen); err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{}
+	for {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		t := p.next()
+		if t.Type == tokenRParen {
+			return values, nil
+		}
+		if t.Type != tokenComma {
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+func (p *parser) parseSorts() ([]skydb.Sort, error) {
+	sorts := []skydb.Sort{}
+	for {
+		expr, err := p.parseKeyPath()
+		if err != nil {
+			return nil, err
+		}
+
+		sort := skydb.Sort{Expression: expr, Order: skydb.Asc}
+		if p.peek().is("ASC") {
+			p.next()
+		} else if p.peek().is("DESC") {
+			p.next()
+			sort.Order = skydb.Desc
+		}
+
+		if p.peek().is("NULLS") {
+			p.next()
+			t := p.next()
+			switch {
+			case t.is("FIRST"):
+				sort.Nulls = skydb.NullsFirst
+			case t.is("LAST"):
+				sort.Nulls = skydb.NullsLast
+			default:
+				return nil, &Error{Pos: t.Pos, Message: fmt.Sprintf("expected FIRST or LAST, got %s", t)}
+			}
+		}
+		sorts = append(sorts, sort)
+
+		if p.peek().Type != tokenComma {
+			return sorts, nil
+		}
+		p.next()
+	}
+}
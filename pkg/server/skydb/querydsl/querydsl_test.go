@@ -0,0 +1,135 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package querydsl
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func keyPath(keyPath string) skydb.Expression {
+	return skydb.Expression{Type: skydb.KeyPath, Value: keyPath}
+}
+
+func TestParse(t *testing.T) {
+	Convey("Parse", t, func() {
+		Convey("parses comparison and sort", func() {
+			predicate, sorts, err := Parse(`status = "open" AND price < 100 ORDER BY created_at DESC`)
+			So(err, ShouldBeNil)
+			So(predicate, ShouldResemble, skydb.Predicate{
+				Operator: skydb.And,
+				Children: []interface{}{
+					binary(skydb.Equal, keyPath("status"), literal("open")),
+					binary(skydb.LessThan, keyPath("price"), literal(float64(100))),
+				},
+			})
+			So(sorts, ShouldResemble, []skydb.Sort{
+				{Expression: keyPath("created_at"), Order: skydb.Desc},
+			})
+		})
+
+		Convey("binds AND tighter than OR", func() {
+			predicate, _, err := Parse(`a = 1 or b = 2 and not c = 3`)
+			So(err, ShouldBeNil)
+			So(predicate, ShouldResemble, skydb.Predicate{
+				Operator: skydb.Or,
+				Children: []interface{}{
+					binary(skydb.Equal, keyPath("a"), literal(float64(1))),
+					skydb.Predicate{
+						Operator: skydb.And,
+						Children: []interface{}{
+							binary(skydb.Equal, keyPath("b"), literal(float64(2))),
+							not(binary(skydb.Equal, keyPath("c"), literal(float64(3)))),
+						},
+					},
+				},
+			})
+		})
+
+		Convey("parses parentheses", func() {
+			predicate, _, err := Parse(`(a = TRUE OR b != NULL) AND c >= d.e`)
+			So(err, ShouldBeNil)
+			So(predicate, ShouldResemble, skydb.Predicate{
+				Operator: skydb.And,
+				Children: []interface{}{
+					skydb.Predicate{
+						Operator: skydb.Or,
+						Children: []interface{}{
+							binary(skydb.Equal, keyPath("a"), literal(true)),
+							binary(skydb.NotEqual, keyPath("b"), literal(nil)),
+						},
+					},
+					binary(skydb.GreaterThanOrEqual, keyPath("c"), keyPath("d.e")),
+				},
+			})
+		})
+
+		Convey("parses conditions", func() {
+			predicate, _, err := Parse(`title ILIKE 'sky%' AND category NOT IN ("a", "b") AND ` +
+				`price BETWEEN 10 AND 20.5 AND due_at IS NOT NULL AND tags CONTAINS ANY ("sale") AND _owner = "me"`)
+			So(err, ShouldBeNil)
+			So(predicate, ShouldResemble, skydb.Predicate{
+				Operator: skydb.And,
+				Children: []interface{}{
+					binary(skydb.ILike, keyPath("title"), literal("sky%")),
+					not(binary(skydb.In, keyPath("category"), literal([]interface{}{"a", "b"}))),
+					skydb.Predicate{
+						Operator: skydb.Between,
+						Children: []interface{}{keyPath("price"), literal(float64(10)), literal(20.5)},
+					},
+					skydb.Predicate{
+						Operator: skydb.IsNotNull,
+						Children: []interface{}{keyPath("due_at")},
+					},
+					binary(skydb.ContainsAny, keyPath("tags"), literal([]interface{}{"sale"})),
+					binary(skydb.Equal, keyPath("_owner_id"), literal("me")),
+				},
+			})
+		})
+
+		Convey("parses escaped quote", func() {
+			predicate, _, err := Parse(`name = "say \"hi\""`)
+			So(err, ShouldBeNil)
+			So(predicate, ShouldResemble, binary(skydb.Equal, keyPath("name"), literal(`say "hi"`)))
+		})
+
+		Convey("parses sorts only", func() {
+			predicate, sorts, err := Parse(`order by priority, due_at asc nulls last`)
+			So(err, ShouldBeNil)
+			So(predicate.IsEmpty(), ShouldBeTrue)
+			So(sorts, ShouldResemble, []skydb.Sort{
+				{Expression: keyPath("priority"), Order: skydb.Asc},
+				{Expression: keyPath("due_at"), Order: skydb.Asc, Nulls: skydb.NullsLast},
+			})
+		})
+
+		Convey("reports position of error", func() {
+			_, _, err := Parse(`status = "open" price < 100`)
+			So(err, ShouldResemble, &Error{Pos: 16, Message: "unexpected price"})
+
+			_, _, err = Parse(`status =`)
+			So(err.Error(), ShouldEqual, "unexpected end of query at position 8")
+
+			_, _, err = Parse(`status = "open`)
+			So(err.Error(), ShouldEqual, "unterminated string at position 9")
+
+			_, _, err = Parse(`a = 1 ORDER created_at`)
+			So(err.Error(), ShouldEqual, "expected BY, got created_at at position 12")
+		})
+	})
+}
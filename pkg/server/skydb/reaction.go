@@ -0,0 +1,34 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "time"
+
+// Reaction is a reaction, such as a like, of a user to a record. A user
+// reacts to a record at most once for each type of reaction.
+//
+// Records of different private databases may have the same ID, so a
+// record is identified by its database ID together with its record ID.
+type Reaction struct {
+	RecordID   RecordID
+	DatabaseID string
+	UserID     string
+	Type       string
+	CreatedAt  time.Time
+}
+
+// ReactionCounts maps a reaction type to the number of users who reacted
+// to a record with that type.
+type ReactionCounts map[string]int64
@@ -18,8 +18,10 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -148,6 +150,13 @@ func (loc Location) String() string {
 // Geometry represent a geometry in GeoJSON.
 type Geometry map[string]interface{}
 
+// Increment is a field value which adds Delta to the current value of the
+// field when the record is saved, instead of replacing it. A field without
+// a value is incremented from zero.
+type Increment struct {
+	Delta float64
+}
+
 // Sequence is a bogus data type for creating a sequence field
 // via JIT schema migration
 type Sequence struct{}
@@ -170,8 +179,17 @@ type Record struct {
 	UpdatedAt  time.Time
 	UpdaterID  string
 	ACL        RecordACL
+	DeletedAt  *time.Time
 	Data       Data
 	Transient  Data `json:"-"`
+
+	// Revision is maintained by the database, and is incremented every
+	// time the record is saved.
+	Revision int64
+
+	// BaseRevision, if not zero, is the revision which the changes to be
+	// saved are based on. It is used to detect concurrent modifications.
+	BaseRevision int64 `json:"-"`
 }
 
 // Copy makes a shadow copy of itself
@@ -209,6 +227,13 @@ func (r *Record) Get(key string) interface{} {
 			return r.UpdatedAt
 		case "_updated_by":
 			return r.UpdaterID
+		case "_deleted_at":
+			if r.DeletedAt == nil {
+				return nil
+			}
+			return *r.DeletedAt
+		case "_rev":
+			return r.Revision
 		case "_transient":
 			return r.Transient
 		default:
@@ -247,6 +272,11 @@ func (r *Record) Set(key string, i interface{}) {
 			r.UpdatedAt = i.(time.Time)
 		case "_updated_by":
 			r.UpdaterID = i.(string)
+		case "_deleted_at":
+			deletedAt := i.(time.Time)
+			r.DeletedAt = &deletedAt
+		case "_rev":
+			r.Revision = i.(int64)
 		case "_transient":
 			r.Transient = i.(Data)
 		default:
@@ -376,6 +406,31 @@ func (schema RecordSchema) DefinitionCompatibleTo(other RecordSchema) bool {
 	return true
 }
 
+// MissingRequiredFields returns the sorted names of required fields that
+// are null in the data. Reserved fields are not checked because they are
+// populated by the server.
+//
+// If isNew is true, the data is of a record to be created, and a field
+// with default value is not missing if it is absent from the data.
+func (schema RecordSchema) MissingRequiredFields(data Data, isNew bool) []string {
+	missing := []string{}
+	for key, fieldType := range schema {
+		if !fieldType.Required || strings.HasPrefix(key, "_") {
+			continue
+		}
+
+		value, ok := data[key]
+		if !ok && isNew && fieldType.Default != nil {
+			continue
+		}
+		if value == nil {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
 func (schema RecordSchema) HasField(field string) bool {
 	_, found := schema[field]
 	return found
@@ -398,6 +453,39 @@ type FieldType struct {
 	ReferenceType  string     // used only by TypeReference
 	Expression     Expression // used by Computed Keys
 	UnderlyingType string     // indicates the underlying (pq) type
+
+	// Required is true if the field cannot be null.
+	Required bool
+
+	// Default is the value saved to the field of a new record if the
+	// field is not specified. Only string, number, integer and boolean
+	// fields can have a default value.
+	Default interface{}
+}
+
+// DefaultCompatible returns if the default value of the FieldType can be
+// saved to a field of the type. A FieldType without default value is
+// always compatible.
+func (f FieldType) DefaultCompatible() bool {
+	if f.Default == nil {
+		return true
+	}
+
+	switch f.Type {
+	case TypeString:
+		_, ok := f.Default.(string)
+		return ok
+	case TypeNumber:
+		_, ok := f.Default.(float64)
+		return ok
+	case TypeInteger:
+		n, ok := f.Default.(float64)
+		return ok && n == math.Trunc(n)
+	case TypeBoolean:
+		_, ok := f.Default.(bool)
+		return ok
+	}
+	return false
 }
 
 // DefinitionCompatibleTo returns if a value of the specifed FieldType can
@@ -418,6 +506,11 @@ func (f FieldType) DefinitionCompatibleTo(other FieldType) bool {
 		return true
 	}
 
+	if f.Type == TypePosition && other.Type == TypeString {
+		// Positions are saved as strings
+		return true
+	}
+
 	if f.Type == TypeGeometry && other.Type.IsGeometryCompatibleType() {
 		// Note: Saving skydb.Location to skydb.Geometry is currently
 		// not supported (see #343)
@@ -454,6 +547,8 @@ func (f FieldType) ToSimpleName() string {
 		return "sequence"
 	case TypeGeometry:
 		return "geometry"
+	case TypePosition:
+		return "position"
 	case TypeUnknown:
 		return "unknown"
 	}
@@ -478,6 +573,7 @@ const (
 	TypeInteger
 	TypeSequence
 	TypeGeometry
+	TypePosition
 	TypeUnknown
 )
 
@@ -519,6 +615,8 @@ func SimpleNameToFieldType(s string) (result FieldType, err error) {
 		result.Type = TypeSequence
 	case "geometry":
 		result.Type = TypeGeometry
+	case "position":
+		result.Type = TypePosition
 	case "unknown":
 		result.Type = TypeUnknown
 	default:
@@ -585,6 +683,18 @@ func DeriveFieldType(value interface{}) (fieldType FieldType, err error) {
 		fieldType = FieldType{
 			Type: TypeSequence,
 		}
+	case Increment:
+		fieldType = FieldType{
+			Type: TypeNumber,
+		}
+	case JSONPatch, MergePatch:
+		fieldType = FieldType{
+			Type: TypeJSON,
+		}
+	case TextPatch:
+		fieldType = FieldType{
+			Type: TypeString,
+		}
 	case Geometry:
 		fieldType = FieldType{
 			Type: TypeGeometry,
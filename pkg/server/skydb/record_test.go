@@ -167,6 +167,36 @@ func TestRecordACL(t *testing.T) {
 			So(note.Accessible(authinfo, WriteLevel), ShouldBeFalse)
 			So(note.Accessible(stranger, WriteLevel), ShouldBeFalse)
 		})
+
+		Convey("Deny entry overrides granted permission", func() {
+			note := Record{
+				ID:         NewRecordID("note", "0"),
+				DatabaseID: "",
+				ACL: RecordACL{
+					NewRecordACLEntryPublic(WriteLevel),
+					NewRecordACLEntryDeny(NewRecordACLEntryDirect("stranger", ReadLevel)),
+				},
+			}
+
+			So(note.Accessible(authinfo, WriteLevel), ShouldBeTrue)
+			So(note.Accessible(stranger, ReadLevel), ShouldBeFalse)
+			So(note.Accessible(stranger, WriteLevel), ShouldBeFalse)
+		})
+
+		Convey("Deny entry on role", func() {
+			note := Record{
+				ID:         NewRecordID("note", "0"),
+				DatabaseID: "",
+				ACL: RecordACL{
+					NewRecordACLEntryDirect("stranger", ReadLevel),
+					NewRecordACLEntryRole("admin", WriteLevel),
+					NewRecordACLEntryDeny(NewRecordACLEntryRole("admin", ReadLevel)),
+				},
+			}
+
+			So(note.Accessible(authinfo, ReadLevel), ShouldBeFalse)
+			So(note.Accessible(stranger, ReadLevel), ShouldBeTrue)
+		})
 	})
 }
 
@@ -235,4 +265,48 @@ func TestRecordSchema(t *testing.T) {
 			So(target.DefinitionCompatibleTo(other), ShouldBeFalse)
 		})
 	})
+
+	Convey("RecordSchema with required fields", t, func() {
+		schema := RecordSchema{
+			"_owner_id": FieldType{Type: TypeString, Required: true},
+			"title":     FieldType{Type: TypeString, Required: true},
+			"status":    FieldType{Type: TypeString, Required: true, Default: "draft"},
+			"body":      FieldType{Type: TypeString},
+		}
+
+		Convey("reports null and absent fields", func() {
+			So(schema.MissingRequiredFields(Data{
+				"status": nil,
+			}, false), ShouldResemble, []string{"status", "title"})
+		})
+
+		Convey("allows absent field with default in new record", func() {
+			So(schema.MissingRequiredFields(Data{
+				"title": "Hello",
+			}, true), ShouldBeEmpty)
+		})
+
+		Convey("reports null field with default in new record", func() {
+			So(schema.MissingRequiredFields(Data{
+				"title":  "Hello",
+				"status": nil,
+			}, true), ShouldResemble, []string{"status"})
+		})
+	})
+
+	Convey("FieldType with default", t, func() {
+		Convey("is compatible with value of the same type", func() {
+			So(FieldType{Type: TypeString, Default: "draft"}.DefaultCompatible(), ShouldBeTrue)
+			So(FieldType{Type: TypeNumber, Default: 1.5}.DefaultCompatible(), ShouldBeTrue)
+			So(FieldType{Type: TypeInteger, Default: float64(2)}.DefaultCompatible(), ShouldBeTrue)
+			So(FieldType{Type: TypeBoolean, Default: true}.DefaultCompatible(), ShouldBeTrue)
+			So(FieldType{Type: TypeDateTime}.DefaultCompatible(), ShouldBeTrue)
+		})
+
+		Convey("is not compatible with value of other type", func() {
+			So(FieldType{Type: TypeString, Default: 1.5}.DefaultCompatible(), ShouldBeFalse)
+			So(FieldType{Type: TypeInteger, Default: 1.5}.DefaultCompatible(), ShouldBeFalse)
+			So(FieldType{Type: TypeJSON, Default: "{}"}.DefaultCompatible(), ShouldBeFalse)
+		})
+	})
 }
@@ -0,0 +1,107 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+// RelatedQuery queries the records referencing each of a set of records,
+// for example the comments of each post in a page of posts. The records
+// referencing the same record are paginated by keyset separately, where
+// Query.PageSize is the number of records returned per referenced record.
+type RelatedQuery struct {
+	Query
+
+	// Field is the reference field of Query.Type referencing the
+	// records.
+	Field string
+}
+
+// RelatedPage is the first page of the records referencing a record.
+type RelatedPage struct {
+	Records []Record
+
+	// NextCursor is the cursor of the next page, or nil if there are
+	// no more records.
+	NextCursor *Cursor
+}
+
+// RelatedQuerier is implemented by a Database which can query the related
+// records of many records at once.
+type RelatedQuerier interface {
+	// QueryRelated returns the first page of the records referencing
+	// each of the records of keys, keyed by the key of the referenced
+	// record. Records referenced by nothing are absent from the result.
+	QueryRelated(related *RelatedQuery, keys []string) (map[string]*RelatedPage, error)
+}
+
+// QueryRelated returns the first page of the records referencing each of
+// the records of recordType with keys.
+//
+// If db is not a RelatedQuerier, the records referencing each record are
+// queried one by one.
+func QueryRelated(db Database, related *RelatedQuery, recordType string, keys []string) (map[string]*RelatedPage, error) {
+	if querier, ok := db.(RelatedQuerier); ok {
+		return querier.QueryRelated(related, keys)
+	}
+
+	pages := map[string]*RelatedPage{}
+	for _, key := range keys {
+		query := related.Query
+		query.Predicate = related.ReferencingPredicate(NewReference(recordType, key))
+		page, err := queryRelatedPage(db, &query)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Records) > 0 {
+			pages[key] = page
+		}
+	}
+	return pages, nil
+}
+
+// ReferencingPredicate returns the predicate of the related query
+// restricted to the records referencing ref.
+func (related *RelatedQuery) ReferencingPredicate(ref Reference) Predicate {
+	predicate := Predicate{
+		Operator: Equal,
+		Children: []interface{}{
+			Expression{Type: KeyPath, Value: related.Field},
+			Expression{Type: Literal, Value: ref},
+		},
+	}
+	if related.Predicate.IsEmpty() {
+		return predicate
+	}
+	return Predicate{
+		Operator: And,
+		Children: []interface{}{related.Predicate, predicate},
+	}
+}
+
+func queryRelatedPage(db Database, query *Query) (*RelatedPage, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &RelatedPage{}
+	for rows.Scan() {
+		page.Records = append(page.Records, rows.Record())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	page.NextCursor = rows.NextCursor()
+	return page, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"context"
+)
+
+type replicaReadContextKey struct{}
+
+// ContextWithReplicaRead returns a copy of ctx allowing the connection to
+// read records from the read replicas of the database, which may not have
+// the latest writes of the primary database.
+func ContextWithReplicaRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadContextKey{}, true)
+}
+
+// ReplicaReadFromContext returns whether the connection may read records
+// from the read replicas. Reads are strong, i.e. from the primary
+// database, unless allowed by ContextWithReplicaRead.
+func ReplicaReadFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	replicaRead, _ := ctx.Value(replicaReadContextKey{}).(bool)
+	return replicaRead
+}
@@ -0,0 +1,42 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "time"
+
+// Report is a record query run on a schedule. The records found are
+// rendered as CSV and stored as an asset, and the users of the
+// designated roles are notified with a signed link to download it.
+type Report struct {
+	ID   string
+	Name string
+
+	// Query is the record query, in the same serialized form as the
+	// payload of record:query.
+	Query map[string]interface{}
+
+	// Schedule is the cron spec of the times to run the report.
+	Schedule string
+
+	// Roles are the roles of the users notified of the report.
+	Roles []string
+
+	// UpdatedAt is the time the report is saved.
+	UpdatedAt time.Time
+
+	// LastRunAt is the scheduled time of the last run, or nil if the
+	// report has not run.
+	LastRunAt *time.Time
+}
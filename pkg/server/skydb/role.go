@@ -0,0 +1,62 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "sort"
+
+// RoleHierarchy maps a role to the roles it inherits directly. A user
+// having a role also has the roles inherited by the role, directly or
+// indirectly. For example, with admin inheriting moderator and moderator
+// inheriting member, an admin is also a moderator and a member.
+type RoleHierarchy map[string][]string
+
+// InheritedRoles returns the sorted roles inherited by the roles, not
+// including the roles themselves. It returns nil if no roles are
+// inherited.
+func (h RoleHierarchy) InheritedRoles(roles []string) []string {
+	seen := map[string]bool{}
+	for _, role := range roles {
+		seen[role] = true
+	}
+
+	var inherited []string
+	queue := append([]string{}, roles...)
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		for _, inheritedRole := range h[role] {
+			if seen[inheritedRole] {
+				continue
+			}
+			seen[inheritedRole] = true
+			inherited = append(inherited, inheritedRole)
+			queue = append(queue, inheritedRole)
+		}
+	}
+
+	sort.Strings(inherited)
+	return inherited
+}
+
+// Inherits returns true if the role inherits the other role, directly or
+// indirectly.
+func (h RoleHierarchy) Inherits(role string, other string) bool {
+	for _, inheritedRole := range h.InheritedRoles([]string{role}) {
+		if inheritedRole == other {
+			return true
+		}
+	}
+	return false
+}
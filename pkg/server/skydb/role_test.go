@@ -0,0 +1,68 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoleHierarchy(t *testing.T) {
+	Convey("RoleHierarchy", t, func() {
+		hierarchy := RoleHierarchy{
+			"admin":     []string{"moderator"},
+			"moderator": []string{"member", "commenter"},
+			"owner":     []string{"admin", "member"},
+		}
+
+		Convey("returns roles inherited indirectly", func() {
+			So(hierarchy.InheritedRoles([]string{"owner"}), ShouldResemble, []string{
+				"admin", "commenter", "member", "moderator",
+			})
+		})
+
+		Convey("does not return the roles themselves", func() {
+			So(hierarchy.InheritedRoles([]string{"admin", "member"}), ShouldResemble, []string{
+				"commenter", "moderator",
+			})
+		})
+
+		Convey("returns nil if no roles are inherited", func() {
+			So(hierarchy.InheritedRoles([]string{"member"}), ShouldBeNil)
+		})
+
+		Convey("tells if a role inherits another role", func() {
+			So(hierarchy.Inherits("owner", "member"), ShouldBeTrue)
+			So(hierarchy.Inherits("member", "owner"), ShouldBeFalse)
+			So(hierarchy.Inherits("admin", "admin"), ShouldBeFalse)
+		})
+
+		Convey("grants access of inherited roles", func() {
+			authinfo := &AuthInfo{
+				ID:             "user",
+				Roles:          []string{"admin"},
+				InheritedRoles: hierarchy.InheritedRoles([]string{"admin"}),
+			}
+			acl := RecordACL{NewRecordACLEntryRole("member", ReadLevel)}
+			record := &Record{OwnerID: "other", ACL: acl}
+
+			So(authinfo.HasAnyRoles([]string{"member"}), ShouldBeTrue)
+			So(authinfo.HasAllRoles([]string{"admin", "commenter"}), ShouldBeTrue)
+			So(record.Accessible(authinfo, ReadLevel), ShouldBeTrue)
+			So(record.Accessible(&AuthInfo{ID: "user", Roles: []string{"admin"}}, ReadLevel), ShouldBeFalse)
+		})
+	})
+}
@@ -50,3 +50,19 @@ func TraverseColumnTypes(db Database, recordType string, keyPath string) ([]Fiel
 	}
 	return fields, nil
 }
+
+// AllRecordTypes is the record type of the strict schema setting that
+// applies to every record type of the app.
+const AllRecordTypes = "*"
+
+// StrictSchema is the set of record types in strict schema mode. Tables
+// and columns of a record type in strict schema mode are not created
+// automatically when a record is saved. They can only be created by
+// schema migration.
+type StrictSchema map[string]bool
+
+// IsStrict returns true if the record type is in strict schema mode,
+// either by itself or because the whole app is.
+func (s StrictSchema) IsStrict(recordType string) bool {
+	return s[recordType] || s[AllRecordTypes]
+}
@@ -0,0 +1,78 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"encoding/json"
+)
+
+// The operations reported in a SchemaChange in addition to those of a
+// schema migration. They cannot be used in a schema migration.
+const (
+	// DeleteColumnOperation deletes a column.
+	DeleteColumnOperation SchemaOperationType = "delete_column"
+	// AlterColumnOperation changes the required or default value
+	// constraint of a column to those of the field type.
+	AlterColumnOperation SchemaOperationType = "alter_column"
+)
+
+// SchemaChange is a change to the schema of a record type, such as the
+// columns added when records with new fields are saved.
+type SchemaChange struct {
+	RecordType string
+
+	// Created is true if the record type is created by the change.
+	Created bool
+
+	Operations []SchemaOperation
+}
+
+// SchemaChangeListener is called after the schema of a record type is
+// changed.
+type SchemaChangeListener func(change SchemaChange)
+
+type jsonSchemaOperation struct {
+	Op       SchemaOperationType `json:"op"`
+	Name     string              `json:"name"`
+	NewName  string              `json:"new_name,omitempty"`
+	Type     string              `json:"type,omitempty"`
+	Required bool                `json:"required,omitempty"`
+	Default  interface{}         `json:"default,omitempty"`
+}
+
+// MarshalJSON encodes the operations in the same form as the operations
+// of a schema migration file.
+func (change SchemaChange) MarshalJSON() ([]byte, error) {
+	operations := make([]jsonSchemaOperation, len(change.Operations))
+	for i, op := range change.Operations {
+		operations[i] = jsonSchemaOperation{
+			Op:      op.Type,
+			Name:    op.Column,
+			NewName: op.NewName,
+		}
+		switch op.Type {
+		case AddColumnOperation, RetypeColumnOperation, AlterColumnOperation:
+			operations[i].Type = op.FieldType.ToSimpleName()
+			operations[i].Required = op.FieldType.Required
+			operations[i].Default = op.FieldType.Default
+		}
+	}
+
+	return json.Marshal(struct {
+		RecordType string                `json:"record_type"`
+		Created    bool                  `json:"created"`
+		Operations []jsonSchemaOperation `json:"operations"`
+	}{change.RecordType, change.Created, operations})
+}
@@ -0,0 +1,56 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/skygeario/skygear-server/pkg/server/skytest"
+)
+
+func TestSchemaChangeMarshalJSON(t *testing.T) {
+	Convey("SchemaChange", t, func() {
+		Convey("encodes operations like a schema migration", func() {
+			change := SchemaChange{
+				RecordType: "note",
+				Created:    true,
+				Operations: []SchemaOperation{
+					{Type: AddColumnOperation, Column: "done", FieldType: FieldType{
+						Type:     TypeBoolean,
+						Required: true,
+						Default:  false,
+					}},
+					{Type: RenameColumnOperation, Column: "content", NewName: "body"},
+					{Type: DeleteColumnOperation, Column: "priority"},
+				},
+			}
+
+			data, err := json.Marshal(change)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqualJSON, `{
+				"record_type": "note",
+				"created": true,
+				"operations": [
+					{"op": "add_column", "name": "done", "type": "boolean", "required": true, "default": false},
+					{"op": "rename_column", "name": "content", "new_name": "body"},
+					{"op": "delete_column", "name": "priority"}
+				]
+			}`)
+		})
+	})
+}
@@ -0,0 +1,239 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SchemaOperationType is the type of an operation of a schema migration.
+type SchemaOperationType string
+
+// The supported operations of a schema migration.
+const (
+	// AddColumnOperation adds a column of the field type.
+	AddColumnOperation SchemaOperationType = "add_column"
+	// RenameColumnOperation renames a column to the new name.
+	RenameColumnOperation SchemaOperationType = "rename_column"
+	// RetypeColumnOperation changes the type of a column to the field
+	// type, casting the existing values to the new type.
+	RetypeColumnOperation SchemaOperationType = "retype_column"
+	// AddLowerIndexOperation adds an index on the lower case values of
+	// a column, which is used by case-insensitive comparisons with
+	// LowerFunc.
+	AddLowerIndexOperation SchemaOperationType = "add_lower_index"
+)
+
+// SchemaOperation is an operation on a column of a record type.
+type SchemaOperation struct {
+	Type      SchemaOperationType
+	Column    string
+	NewName   string
+	FieldType FieldType
+}
+
+// SchemaMigration is a versioned list of operations on the schema of a
+// record type. Migrations of a record type are applied in the
+// lexicographical order of their versions.
+type SchemaMigration struct {
+	Version    string
+	RecordType string
+	Operations []SchemaOperation
+}
+
+// Validate returns error if the migration cannot be applied to a record
+// type regardless of its schema.
+func (m SchemaMigration) Validate() error {
+	if m.Version == "" {
+		return fmt.Errorf("migration of %s has no version", m.RecordType)
+	}
+	if m.RecordType == "" || strings.HasPrefix(m.RecordType, "_") {
+		return fmt.Errorf(`migration "%s" has invalid record type "%s"`, m.Version, m.RecordType)
+	}
+	if len(m.Operations) == 0 {
+		return fmt.Errorf(`migration "%s" has no operations`, m.Version)
+	}
+
+	for _, op := range m.Operations {
+		if op.Column == "" || strings.HasPrefix(op.Column, "_") {
+			return fmt.Errorf(`migration "%s" has invalid column "%s"`, m.Version, op.Column)
+		}
+
+		switch op.Type {
+		case AddColumnOperation:
+			switch op.FieldType.Type {
+			case 0, TypeUnknown, TypeACL:
+				return fmt.Errorf(`migration "%s" cannot add column %s of type %s`,
+					m.Version, op.Column, op.FieldType.ToSimpleName())
+			}
+			if !op.FieldType.DefaultCompatible() {
+				return fmt.Errorf(`migration "%s" has invalid default value of column %s`, m.Version, op.Column)
+			}
+		case RenameColumnOperation:
+			if op.NewName == "" || strings.HasPrefix(op.NewName, "_") {
+				return fmt.Errorf(`migration "%s" renames column %s to invalid name "%s"`, m.Version, op.Column, op.NewName)
+			}
+		case RetypeColumnOperation:
+			switch op.FieldType.Type {
+			case 0, TypeUnknown, TypeACL, TypeAsset, TypeReference, TypeSequence:
+				return fmt.Errorf(`migration "%s" cannot retype column %s to %s`,
+					m.Version, op.Column, op.FieldType.ToSimpleName())
+			}
+		case AddLowerIndexOperation:
+		default:
+			return fmt.Errorf(`migration "%s" has unknown operation "%s"`, m.Version, op.Type)
+		}
+	}
+	return nil
+}
+
+type schemaMigrationFile struct {
+	RecordType string `json:"record_type"`
+	Operations []struct {
+		Op       string      `json:"op"`
+		Name     string      `json:"name"`
+		NewName  string      `json:"new_name"`
+		Type     string      `json:"type"`
+		Required bool        `json:"required"`
+		Default  interface{} `json:"default"`
+	} `json:"operations"`
+}
+
+// LoadSchemaMigrations reads the migrations from the JSON files in the
+// directory. The version of a migration is the part of the file name
+// before the first underscore, for example a file named
+// 20170102150405_add_note_due_at.json has version 20170102150405.
+//
+//	{
+//	    "record_type": "note",
+//	    "operations": [
+//	        {"op": "add_column", "name": "due_at", "type": "datetime"},
+//	        {"op": "rename_column", "name": "content", "new_name": "body"},
+//	        {"op": "retype_column", "name": "priority", "type": "integer"},
+//	        {"op": "add_lower_index", "name": "title"}
+//	    ]
+//	}
+//
+// The returned migrations are sorted by version.
+func LoadSchemaMigrations(dir string) ([]SchemaMigration, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []SchemaMigration{}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, err := ParseSchemaMigration(filepath.Base(path), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version &&
+			migrations[i].RecordType == migrations[i-1].RecordType {
+			return nil, fmt.Errorf(`duplicated migration "%s" of %s`,
+				migrations[i].Version, migrations[i].RecordType)
+		}
+	}
+	return migrations, nil
+}
+
+// ParseSchemaMigration parses a migration file of the file name.
+func ParseSchemaMigration(filename string, data []byte) (SchemaMigration, error) {
+	file := schemaMigrationFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return SchemaMigration{}, err
+	}
+
+	version := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if i := strings.Index(version, "_"); i >= 0 {
+		version = version[:i]
+	}
+
+	migration := SchemaMigration{
+		Version:    version,
+		RecordType: file.RecordType,
+	}
+	for _, fileOp := range file.Operations {
+		op := SchemaOperation{
+			Type:    SchemaOperationType(fileOp.Op),
+			Column:  fileOp.Name,
+			NewName: fileOp.NewName,
+		}
+		if fileOp.Type != "" {
+			fieldType, err := SimpleNameToFieldType(fileOp.Type)
+			if err != nil {
+				return SchemaMigration{}, err
+			}
+			fieldType.Required = fileOp.Required
+			fieldType.Default = fileOp.Default
+			op.FieldType = fieldType
+		}
+		migration.Operations = append(migration.Operations, op)
+	}
+
+	if err := migration.Validate(); err != nil {
+		return SchemaMigration{}, err
+	}
+	return migration, nil
+}
+
+// PendingSchemaMigrations returns the migrations of versions later than
+// the applied versions of their record types.
+func PendingSchemaMigrations(migrations []SchemaMigration, versions map[string]string) []SchemaMigration {
+	pending := []SchemaMigration{}
+	for _, migration := range migrations {
+		if migration.Version > versions[migration.RecordType] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// MigrateSchemas applies the pending migrations to the database in the
+// order of versions. It returns the applied migrations, which are
+// applied before an error occurs if any.
+func MigrateSchemas(db Database, migrations []SchemaMigration) ([]SchemaMigration, error) {
+	versions, err := db.SchemaVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []SchemaMigration{}
+	for _, migration := range PendingSchemaMigrations(migrations, versions) {
+		if err := db.MigrateSchema(migration); err != nil {
+			return applied, fmt.Errorf(`failed to apply migration "%s" of %s: %v`,
+				migration.Version, migration.RecordType, err)
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
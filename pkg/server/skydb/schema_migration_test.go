@@ -0,0 +1,172 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseSchemaMigration(t *testing.T) {
+	Convey("ParseSchemaMigration", t, func() {
+		Convey("parses operations", func() {
+			migration, err := ParseSchemaMigration("20170102150405_migrate_note.json", []byte(`{
+				"record_type": "note",
+				"operations": [
+					{"op": "add_column", "name": "due_at", "type": "datetime"},
+					{"op": "add_column", "name": "done", "type": "boolean", "required": true, "default": false},
+					{"op": "rename_column", "name": "content", "new_name": "body"},
+					{"op": "retype_column", "name": "priority", "type": "integer"},
+					{"op": "add_lower_index", "name": "body"}
+				]
+			}`))
+			So(err, ShouldBeNil)
+			So(migration, ShouldResemble, SchemaMigration{
+				Version:    "20170102150405",
+				RecordType: "note",
+				Operations: []SchemaOperation{
+					{Type: AddColumnOperation, Column: "due_at", FieldType: FieldType{Type: TypeDateTime}},
+					{Type: AddColumnOperation, Column: "done", FieldType: FieldType{
+						Type:     TypeBoolean,
+						Required: true,
+						Default:  false,
+					}},
+					{Type: RenameColumnOperation, Column: "content", NewName: "body"},
+					{Type: RetypeColumnOperation, Column: "priority", FieldType: FieldType{Type: TypeInteger}},
+					{Type: AddLowerIndexOperation, Column: "body"},
+				},
+			})
+		})
+
+		Convey("rejects unknown operation", func() {
+			_, err := ParseSchemaMigration("1.json", []byte(`{
+				"record_type": "note",
+				"operations": [{"op": "drop_column", "name": "content"}]
+			}`))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rejects reserved column", func() {
+			_, err := ParseSchemaMigration("1.json", []byte(`{
+				"record_type": "note",
+				"operations": [{"op": "rename_column", "name": "_owner_id", "new_name": "owner"}]
+			}`))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("rejects retyping to reference", func() {
+			_, err := ParseSchemaMigration("1.json", []byte(`{
+				"record_type": "note",
+				"operations": [{"op": "retype_column", "name": "category", "type": "ref(category)"}]
+			}`))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestLoadSchemaMigrations(t *testing.T) {
+	Convey("LoadSchemaMigrations", t, func() {
+		dir, err := ioutil.TempDir("", "skydb.schema_migration.test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		writeFile := func(name string, content string) {
+			So(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644), ShouldBeNil)
+		}
+
+		Convey("loads migrations in order of versions", func() {
+			writeFile("2_rename_note_content.json", `{
+				"record_type": "note",
+				"operations": [{"op": "rename_column", "name": "content", "new_name": "body"}]
+			}`)
+			writeFile("1_add_note_content.json", `{
+				"record_type": "note",
+				"operations": [{"op": "add_column", "name": "content", "type": "string"}]
+			}`)
+			writeFile("README.md", `not a migration`)
+
+			migrations, err := LoadSchemaMigrations(dir)
+			So(err, ShouldBeNil)
+			So(migrations, ShouldHaveLength, 2)
+			So(migrations[0].Version, ShouldEqual, "1")
+			So(migrations[1].Version, ShouldEqual, "2")
+		})
+
+		Convey("rejects duplicated versions of a record type", func() {
+			writeFile("1_add_note_content.json", `{
+				"record_type": "note",
+				"operations": [{"op": "add_column", "name": "content", "type": "string"}]
+			}`)
+			writeFile("1_add_note_title.json", `{
+				"record_type": "note",
+				"operations": [{"op": "add_column", "name": "title", "type": "string"}]
+			}`)
+
+			_, err := LoadSchemaMigrations(dir)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMigrateSchemas(t *testing.T) {
+	Convey("MigrateSchemas", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		newMigration := func(recordType string, version string) SchemaMigration {
+			return SchemaMigration{
+				Version:    version,
+				RecordType: recordType,
+				Operations: []SchemaOperation{
+					{Type: AddColumnOperation, Column: "field" + version, FieldType: FieldType{Type: TypeString}},
+				},
+			}
+		}
+		migrations := []SchemaMigration{
+			newMigration("note", "1"),
+			newMigration("tag", "1"),
+			newMigration("note", "2"),
+			newMigration("note", "3"),
+		}
+
+		db := NewMockDatabase(ctrl)
+		db.EXPECT().SchemaVersions().Return(map[string]string{"note": "2"}, nil)
+
+		Convey("applies pending migrations", func() {
+			gomock.InOrder(
+				db.EXPECT().MigrateSchema(migrations[1]).Return(nil),
+				db.EXPECT().MigrateSchema(migrations[3]).Return(nil),
+			)
+
+			applied, err := MigrateSchemas(db, migrations)
+			So(err, ShouldBeNil)
+			So(applied, ShouldResemble, []SchemaMigration{migrations[1], migrations[3]})
+		})
+
+		Convey("stops at failed migration", func() {
+			db.EXPECT().MigrateSchema(migrations[1]).Return(errors.New("column exists"))
+
+			applied, err := MigrateSchemas(db, migrations)
+			So(err.Error(), ShouldEqual, `failed to apply migration "1" of tag: column exists`)
+			So(applied, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+// ScrubMethod is how the value of a field is rewritten when the records
+// are scrubbed. Values are rewritten deterministically for the same salt,
+// so that equal values are still equal after scrubbing.
+type ScrubMethod string
+
+// The supported scrub methods.
+const (
+	// ScrubHash replaces a string value with its hash in hex.
+	ScrubHash ScrubMethod = "hash"
+	// ScrubEmail replaces a string value with a fake email address
+	// derived from its hash, which is unique for unique values.
+	ScrubEmail ScrubMethod = "email"
+	// ScrubName replaces a string value with a fake name derived from
+	// its hash.
+	ScrubName ScrubMethod = "name"
+	// ScrubNull removes the value of a field of any type.
+	ScrubNull ScrubMethod = "null"
+)
+
+// IsValid returns true if the scrub method is supported.
+func (method ScrubMethod) IsValid() bool {
+	switch method {
+	case ScrubHash, ScrubEmail, ScrubName, ScrubNull:
+		return true
+	}
+	return false
+}
+
+// RequiresString returns true if the scrub method only applies to string
+// fields.
+func (method ScrubMethod) RequiresString() bool {
+	return method != ScrubNull
+}
@@ -0,0 +1,32 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "time"
+
+// Secret is a credential stored encrypted at rest, such as the private
+// key of APNS or the client secret of an OAuth provider.
+type Secret struct {
+	Name string
+
+	// KeyID identifies the key sealing the secret, so that secrets
+	// sealed by an old key can be opened during key rotation.
+	KeyID string
+
+	// Sealed is the encrypted value of the secret.
+	Sealed []byte
+
+	UpdatedAt time.Time
+}
@@ -0,0 +1,64 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"time"
+)
+
+// ServiceAccount is a non-human principal, such as a backend job, which
+// authenticates with a client secret or a key pair instead of a password.
+//
+// A service account has an AuthInfo of the same ID, so that it is
+// assigned roles and evaluated by access control like a user.
+type ServiceAccount struct {
+	// ID is the ID of the AuthInfo of the service account, which is
+	// also the client ID.
+	ID   string
+	Name string
+
+	// HashedSecret is the SHA-256 hash of the client secret, or nil if
+	// the service account authenticates with its key pair only.
+	HashedSecret []byte
+
+	// PublicKey is the PEM encoded RSA or ECDSA public key verifying
+	// the assertions signed by the service account, or empty if the
+	// service account authenticates with its client secret only.
+	PublicKey string
+
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// SetSecret sets the hash of the client secret.
+//
+// Client secrets are generated by the server with enough entropy, so they
+// are not hashed with a slow password hashing algorithm.
+func (account *ServiceAccount) SetSecret(secret string) {
+	hash := sha256.Sum256([]byte(secret))
+	account.HashedSecret = hash[:]
+}
+
+// IsSameSecret determines whether the specified secret is the client
+// secret of the service account.
+func (account *ServiceAccount) IsSameSecret(secret string) bool {
+	if len(account.HashedSecret) == 0 {
+		return false
+	}
+	hash := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(account.HashedSecret, hash[:]) == 1
+}
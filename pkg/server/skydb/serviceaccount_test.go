@@ -0,0 +1,38 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServiceAccount(t *testing.T) {
+	Convey("ServiceAccount", t, func() {
+		Convey("matches the secret set", func() {
+			account := ServiceAccount{}
+			account.SetSecret("secret")
+			So(account.IsSameSecret("secret"), ShouldBeTrue)
+			So(account.IsSameSecret("wrong"), ShouldBeFalse)
+			So(string(account.HashedSecret), ShouldNotEqual, "secret")
+		})
+
+		Convey("matches no secret without secret set", func() {
+			account := ServiceAccount{}
+			So(account.IsSameSecret(""), ShouldBeFalse)
+		})
+	})
+}
@@ -328,6 +328,7 @@ type MapACLEntry skydb.RecordACLEntry
 // FromMap initializes a RecordACLEntry from a unmarshalled JSON of
 // access control definition
 func (ace *MapACLEntry) FromMap(m map[string]interface{}) error {
+	deny, _ := m["deny"].(bool)
 	level, _ := m["level"].(string)
 	var entryLevel skydb.RecordACLLevel
 	switch level {
@@ -336,7 +337,10 @@ func (ace *MapACLEntry) FromMap(m map[string]interface{}) error {
 	case "write":
 		entryLevel = skydb.WriteLevel
 	case "":
-		return errors.New("empty level")
+		// the level of a deny entry is ignored
+		if !deny {
+			return errors.New("empty level")
+		}
 	default:
 		return fmt.Errorf("unknown level = %s", level)
 	}
@@ -362,6 +366,7 @@ func (ace *MapACLEntry) FromMap(m map[string]interface{}) error {
 	if hasPublic {
 		ace.Public = public
 	}
+	ace.Deny = deny
 	return nil
 }
 
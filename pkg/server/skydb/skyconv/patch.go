@@ -0,0 +1,181 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skyconv
+
+import (
+	"fmt"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// ExtractPatches removes the "$patch" key from m, and returns the fields
+// to be patched as skydb.JSONPatch, skydb.MergePatch or skydb.TextPatch
+// values. It must be called before ExtractIncrements, so that a field
+// is not both patched and incremented.
+//
+// A patch is a JSON merge patch if it is an object, and an array of
+// JSON Patch operations otherwise. An array of "splice" operations is
+// a patch of a string field.
+func ExtractPatches(m map[string]interface{}) (map[string]interface{}, error) {
+	rawPatches, ok := m["$patch"]
+	if !ok {
+		return nil, nil
+	}
+	delete(m, "$patch")
+
+	patchMap, ok := rawPatches.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`key "$patch" is not a map: %v`, rawPatches)
+	}
+
+	increments, _ := m["$inc"].(map[string]interface{})
+	patches := map[string]interface{}{}
+	for key, value := range patchMap {
+		if key == "" || key[0] == '_' {
+			return nil, fmt.Errorf(`cannot patch reserved key "%s"`, key)
+		}
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf(`cannot both set and patch key "%s"`, key)
+		}
+		if _, ok := increments[key]; ok {
+			return nil, fmt.Errorf(`cannot both increment and patch key "%s"`, key)
+		}
+
+		patch, err := parsePatch(value)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid patch of key "%s": %v`, key, err)
+		}
+		patches[key] = patch
+	}
+	return patches, nil
+}
+
+func parsePatch(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return skydb.MergePatch(v), nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("patch has no operations")
+		}
+		if op, _ := v[0].(map[string]interface{}); op["op"] == "splice" {
+			return parseTextPatch(v)
+		}
+		return parseJSONPatch(v)
+	default:
+		return nil, fmt.Errorf("patch is neither an object nor an array: %v", value)
+	}
+}
+
+func parseJSONPatch(ops []interface{}) (skydb.JSONPatch, error) {
+	patch := make(skydb.JSONPatch, len(ops))
+	for i, rawOp := range ops {
+		m, ok := rawOp.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation at %d is not a map", i)
+		}
+
+		op := skydb.JSONPatchOperation{}
+		if op.Op, ok = m["op"].(string); !ok {
+			return nil, fmt.Errorf(`operation at %d has no "op"`, i)
+		}
+		if op.Path, ok = m["path"].(string); !ok {
+			return nil, fmt.Errorf(`operation at %d has no "path"`, i)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value, ok = m["value"]; !ok {
+				return nil, fmt.Errorf(`operation at %d has no "value"`, i)
+			}
+		case "move", "copy":
+			if op.From, ok = m["from"].(string); !ok {
+				return nil, fmt.Errorf(`operation at %d has no "from"`, i)
+			}
+		}
+		patch[i] = op
+	}
+
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+func parseTextPatch(ops []interface{}) (skydb.TextPatch, error) {
+	patch := make(skydb.TextPatch, len(ops))
+	for i, rawOp := range ops {
+		m, ok := rawOp.(map[string]interface{})
+		if !ok || m["op"] != "splice" {
+			return nil, fmt.Errorf("operation at %d is not a splice", i)
+		}
+
+		offset, ok := m["offset"].(float64)
+		if !ok || offset != float64(int(offset)) {
+			return nil, fmt.Errorf(`splice at %d has no integer "offset"`, i)
+		}
+		length, _ := m["delete"].(float64)
+		if length != float64(int(length)) {
+			return nil, fmt.Errorf(`splice at %d has no integer "delete"`, i)
+		}
+		insert, _ := m["insert"].(string)
+
+		patch[i] = skydb.TextSplice{
+			Offset: int(offset),
+			Delete: int(length),
+			Insert: insert,
+		}
+	}
+
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// patchToMap returns the patch in the form parsed by ExtractPatches.
+func patchToMap(value interface{}) interface{} {
+	switch patch := value.(type) {
+	case skydb.MergePatch:
+		return map[string]interface{}(patch)
+	case skydb.JSONPatch:
+		ops := make([]interface{}, len(patch))
+		for i, op := range patch {
+			m := map[string]interface{}{
+				"op":   op.Op,
+				"path": op.Path,
+			}
+			switch op.Op {
+			case "add", "replace", "test":
+				m["value"] = op.Value
+			case "move", "copy":
+				m["from"] = op.From
+			}
+			ops[i] = m
+		}
+		return ops
+	case skydb.TextPatch:
+		ops := make([]interface{}, len(patch))
+		for i, splice := range patch {
+			ops[i] = map[string]interface{}{
+				"op":     "splice",
+				"offset": splice.Offset,
+				"delete": splice.Delete,
+				"insert": splice.Insert,
+			}
+		}
+		return ops
+	}
+	return nil
+}
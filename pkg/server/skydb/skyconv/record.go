@@ -28,8 +28,14 @@ type JSONRecord skydb.Record
 // MarshalJSON implements json.Marshaler
 func (record *JSONRecord) MarshalJSON() ([]byte, error) {
 	data := map[string]interface{}{}
+	increments := map[string]interface{}{}
+	patches := map[string]interface{}{}
 	for key, value := range record.Data {
 		switch v := value.(type) {
+		case skydb.Increment:
+			increments[key] = v.Delta
+		case skydb.JSONPatch, skydb.MergePatch, skydb.TextPatch:
+			patches[key] = patchToMap(v)
 		case time.Time:
 			data[key] = (MapTime)(v)
 		case skydb.Reference:
@@ -57,6 +63,12 @@ func (record *JSONRecord) MarshalJSON() ([]byte, error) {
 	m["_id"] = record.ID.String()
 	m["_type"] = "record"
 	m["_access"] = record.ACL
+	if len(increments) > 0 {
+		m["$inc"] = increments
+	}
+	if len(patches) > 0 {
+		m["$patch"] = patches
+	}
 
 	if record.OwnerID != "" {
 		m["_ownerID"] = record.OwnerID
@@ -73,6 +85,12 @@ func (record *JSONRecord) MarshalJSON() ([]byte, error) {
 	if record.UpdaterID != "" {
 		m["_updated_by"] = record.UpdaterID
 	}
+	if record.DeletedAt != nil {
+		m["_deleted_at"] = *record.DeletedAt
+	}
+	if record.Revision != 0 {
+		m["_rev"] = record.Revision
+	}
 
 	transient := record.marshalTransient(record.Transient)
 	if len(transient) > 0 {
@@ -133,17 +151,75 @@ func (record *JSONRecord) FromMap(m map[string]interface{}) error {
 		return extractor.Err()
 	}
 
+	// _rev is optional, it is the revision of the record the client has
+	// based its modification on.
+	var revision int64
+	if rev, ok := m["_rev"]; ok {
+		f, ok := rev.(float64)
+		if !ok || f != float64(int64(f)) {
+			return fmt.Errorf("key _rev is not an integer: %v", rev)
+		}
+		revision = int64(f)
+	}
+
+	patches, err := ExtractPatches(m)
+	if err != nil {
+		return err
+	}
+	increments, err := ExtractIncrements(m)
+	if err != nil {
+		return err
+	}
+
 	m = sanitizedDataMap(m)
 	if err := (*MapData)(&dataMap).FromMap(m); err != nil {
 		return err
 	}
+	for key, value := range increments {
+		dataMap[key] = value
+	}
+	for key, value := range patches {
+		dataMap[key] = value
+	}
 
 	record.ID = id
 	record.ACL = acl
 	record.Data = dataMap
+	record.Revision = revision
 	return nil
 }
 
+// ExtractIncrements removes the "$inc" key from m, and returns the fields
+// to be incremented as skydb.Increment values.
+func ExtractIncrements(m map[string]interface{}) (map[string]interface{}, error) {
+	rawIncrements, ok := m["$inc"]
+	if !ok {
+		return nil, nil
+	}
+	delete(m, "$inc")
+
+	incMap, ok := rawIncrements.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`key "$inc" is not a map: %v`, rawIncrements)
+	}
+
+	increments := map[string]interface{}{}
+	for key, value := range incMap {
+		if key == "" || key[0] == '_' {
+			return nil, fmt.Errorf(`cannot increment reserved key "%s"`, key)
+		}
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf(`cannot both set and increment key "%s"`, key)
+		}
+		delta, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf(`increment of key "%s" is not a number: %v`, key, value)
+		}
+		increments[key] = skydb.Increment{Delta: delta}
+	}
+	return increments, nil
+}
+
 func sanitizedDataMap(m map[string]interface{}) map[string]interface{} {
 	mm := map[string]interface{}{}
 	for key, value := range m {
@@ -29,6 +29,9 @@ func ExpectDBSaveUser(db *mock_skydb.MockTxDatabase, extendedSchema *skydb.Recor
 		Return(skydb.ErrRecordNotFound).
 		AnyTimes()
 
+	// no required fields
+	db.EXPECT().GetSchema(gomock.Any()).Return(skydb.RecordSchema{}, nil).AnyTimes()
+
 	// extend Schema
 	if extendedSchema != nil {
 		ExpectDBExtendSchema(db, *extendedSchema)
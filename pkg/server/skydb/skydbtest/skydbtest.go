@@ -17,9 +17,12 @@ package skydbtest
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
 )
 
 // MapConn is a naive memory implementation of skydb.Conn
@@ -30,6 +33,8 @@ type MapConn struct {
 	recordAccessMap        map[string]skydb.RecordACL
 	recordDefaultAccessMap map[string]skydb.RecordACL
 	fieldAccess            skydb.FieldACL
+	systemFieldAccessMap   map[string]skydb.SystemFieldAccess
+	strictSchema           skydb.StrictSchema
 	skydb.Conn
 }
 
@@ -40,6 +45,8 @@ func NewMapConn() *MapConn {
 		recordAccessMap:        map[string]skydb.RecordACL{},
 		recordDefaultAccessMap: map[string]skydb.RecordACL{},
 		fieldAccess:            skydb.FieldACL{},
+		systemFieldAccessMap:   map[string]skydb.SystemFieldAccess{},
+		strictSchema:           skydb.StrictSchema{},
 		AssetMap:               map[string]skydb.Asset{},
 	}
 }
@@ -163,14 +170,53 @@ func (conn *MapConn) GetRecordFieldAccess() (skydb.FieldACL, error) {
 	return conn.fieldAccess, nil
 }
 
-// GetAsset is not implemented.
+// SetRecordSystemFieldAccess sets the masks of system fields of a
+// specific type
+func (conn *MapConn) SetRecordSystemFieldAccess(recordType string, masks skydb.SystemFieldMasks) error {
+	access := conn.systemFieldAccessMap[recordType]
+	if access.Salt == "" {
+		access.Salt = recordType
+	}
+	access.Masks = masks
+	conn.systemFieldAccessMap[recordType] = access
+	return nil
+}
+
+// GetRecordSystemFieldAccess returns system field access for all types
+func (conn *MapConn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	return conn.systemFieldAccessMap, nil
+}
+
+// SetRecordStrictSchema turns strict schema mode of a specific type on
+// or off
+func (conn *MapConn) SetRecordStrictSchema(recordType string, strict bool) error {
+	if strict {
+		conn.strictSchema[recordType] = true
+	} else {
+		delete(conn.strictSchema, recordType)
+	}
+	return nil
+}
+
+// GetRecordStrictSchema returns the record types in strict schema mode
+func (conn *MapConn) GetRecordStrictSchema() (skydb.StrictSchema, error) {
+	return conn.strictSchema, nil
+}
+
+// GetAsset returns an Asset from AssetMap.
 func (conn *MapConn) GetAsset(name string, asset *skydb.Asset) error {
-	panic("not implemented")
+	a, ok := conn.AssetMap[name]
+	if !ok {
+		return skydb.ErrAssetNotFound
+	}
+	*asset = a
+	return nil
 }
 
-// SaveAsset is not implemented.
+// SaveAsset assigns Asset to AssetMap.
 func (conn *MapConn) SaveAsset(asset *skydb.Asset) error {
-	panic("not implemented")
+	conn.AssetMap[asset.Name] = *asset
+	return nil
 }
 
 // GetAssets always returns empty array.
@@ -185,6 +231,39 @@ func (conn *MapConn) GetAssets(names []string) ([]skydb.Asset, error) {
 	return assets, nil
 }
 
+// GetAssetNames returns the names of assets in AssetMap with the prefix.
+func (conn *MapConn) GetAssetNames(prefix string) ([]string, error) {
+	names := []string{}
+	for name := range conn.AssetMap {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RenameAsset renames an Asset in AssetMap. Records are not updated.
+func (conn *MapConn) RenameAsset(oldName, newName string) error {
+	asset, ok := conn.AssetMap[oldName]
+	if !ok {
+		return skydb.ErrAssetNotFound
+	}
+	delete(conn.AssetMap, oldName)
+	asset.Name = newName
+	conn.AssetMap[newName] = asset
+	return nil
+}
+
+// DeleteAsset removes an Asset from AssetMap.
+func (conn *MapConn) DeleteAsset(name string) error {
+	if _, ok := conn.AssetMap[name]; !ok {
+		return skydb.ErrAssetNotFound
+	}
+	delete(conn.AssetMap, name)
+	return nil
+}
+
 // QueryRelation is not implemented.
 func (conn *MapConn) QueryRelation(user string, name string, direction string, config skydb.QueryConfig) []skydb.AuthInfo {
 	panic("not implemented")
@@ -273,20 +352,22 @@ type RecordSchemaMap map[string]skydb.RecordSchema
 
 // MapDB is a naive memory implementation of skydb.Database.
 type MapDB struct {
-	RecordMap       RecordMap
-	SubscriptionMap SubscriptionMap
-	RecordSchemaMap RecordSchemaMap
-	DBConn          skydb.Conn
+	RecordMap        RecordMap
+	SubscriptionMap  SubscriptionMap
+	RecordSchemaMap  RecordSchemaMap
+	SchemaVersionMap map[string]string
+	DBConn           skydb.Conn
 	skydb.Database
 }
 
 // NewMapDB returns a new MapDB ready for use.
 func NewMapDB() *MapDB {
 	return &MapDB{
-		RecordMap:       RecordMap{},
-		SubscriptionMap: SubscriptionMap{},
-		RecordSchemaMap: RecordSchemaMap{},
-		DBConn:          &MapConn{},
+		RecordMap:        RecordMap{},
+		SubscriptionMap:  SubscriptionMap{},
+		RecordSchemaMap:  RecordSchemaMap{},
+		SchemaVersionMap: map[string]string{},
+		DBConn:           &MapConn{},
 	}
 }
 
@@ -317,8 +398,38 @@ func (db *MapDB) Get(id skydb.RecordID, record *skydb.Record) error {
 // Save assigns Record to RecordMap.
 func (db *MapDB) Save(record *skydb.Record) error {
 	recordID := record.ID.String()
+	origRecord, exists := db.RecordMap[recordID]
+	if exists && record.BaseRevision != 0 && record.BaseRevision != origRecord.Revision {
+		return skydb.ErrRecordConflict
+	}
+
+	// add increments and apply patches to the current values of the
+	// fields
+	for key, value := range record.Data {
+		switch v := value.(type) {
+		case skydb.Increment:
+			current, _ := origRecord.Data[key].(float64)
+			record.Data[key] = current + v.Delta
+		case skydb.MergePatch:
+			record.Data[key] = v.Apply(origRecord.Data[key])
+		case skydb.JSONPatch:
+			patched, err := v.Apply(origRecord.Data[key])
+			if err != nil {
+				return err
+			}
+			record.Data[key] = patched
+		case skydb.TextPatch:
+			current, _ := origRecord.Data[key].(string)
+			patched, err := v.Apply(current)
+			if err != nil {
+				return err
+			}
+			record.Data[key] = patched
+		}
+	}
+
+	if exists {
 
-	if origRecord, ok := db.RecordMap[recordID]; ok {
 		// keep the meta-data of record, only update record.Data
 		origRecordMergedCopy := origRecord.MergedCopy(record)
 		record.Apply(&origRecordMergedCopy)
@@ -328,6 +439,27 @@ func (db *MapDB) Save(record *skydb.Record) error {
 	return nil
 }
 
+// Merge updates the existing record in RecordMap as Save does.
+func (db *MapDB) Merge(record *skydb.Record) error {
+	if _, ok := db.RecordMap[record.ID.String()]; !ok {
+		return skydb.ErrRecordNotFound
+	}
+	return db.Save(record)
+}
+
+// SaveBulk assigns the Records to RecordMap if none of them exists.
+func (db *MapDB) SaveBulk(records []*skydb.Record) error {
+	for _, record := range records {
+		if _, ok := db.RecordMap[record.ID.String()]; ok {
+			return skyerr.NewError(skyerr.Duplicated, "violate unique constraint")
+		}
+	}
+	for _, record := range records {
+		db.RecordMap[record.ID.String()] = *record
+	}
+	return nil
+}
+
 // Delete remove the specified key from RecordMap.
 func (db *MapDB) Delete(id skydb.RecordID) error {
 	_, ok := db.RecordMap[id.String()]
@@ -347,11 +479,16 @@ func (db *MapDB) Query(query *skydb.Query) (*skydb.Rows, error) {
 func (db *MapDB) Extend(recordType string, schema skydb.RecordSchema) (bool, error) {
 	if _, ok := db.RecordSchemaMap[recordType]; ok {
 		for fieldName, fieldType := range schema {
-			if _, ok := db.RecordSchemaMap[recordType][fieldName]; ok {
-				ft := db.RecordSchemaMap[recordType][fieldName]
-				if !reflect.DeepEqual(ft, fieldType) {
+			if ft, ok := db.RecordSchemaMap[recordType][fieldName]; ok {
+				if !reflect.DeepEqual(withoutConstraints(ft), withoutConstraints(fieldType)) {
 					return false, fmt.Errorf("Wrong type")
 				}
+
+				// constraints are only added, as in the pq implementation
+				fieldType.Required = fieldType.Required || ft.Required
+				if fieldType.Default == nil {
+					fieldType.Default = ft.Default
+				}
 			}
 			db.RecordSchemaMap[recordType][fieldName] = fieldType
 		}
@@ -361,6 +498,12 @@ func (db *MapDB) Extend(recordType string, schema skydb.RecordSchema) (bool, err
 	return true, nil
 }
 
+func withoutConstraints(fieldType skydb.FieldType) skydb.FieldType {
+	fieldType.Required = false
+	fieldType.Default = nil
+	return fieldType
+}
+
 func (db *MapDB) RenameSchema(recordType, oldColumnName, newColumnName string) error {
 	if _, ok := db.RecordSchemaMap[recordType]; !ok {
 		return fmt.Errorf("record type %s does not exist", recordType)
@@ -393,6 +536,62 @@ func (db *MapDB) DeleteSchema(recordType, columnName string) error {
 	return nil
 }
 
+// SchemaVersions returns the versions of applied schema migrations.
+func (db *MapDB) SchemaVersions() (map[string]string, error) {
+	versions := map[string]string{}
+	for recordType, version := range db.SchemaVersionMap {
+		versions[recordType] = version
+	}
+	return versions, nil
+}
+
+// MigrateSchema applies the operations to RecordSchemaMap and records
+// the version of the migration.
+func (db *MapDB) MigrateSchema(migration skydb.SchemaMigration) error {
+	schema := skydb.RecordSchema{}
+	for fieldName, fieldType := range db.RecordSchemaMap[migration.RecordType] {
+		schema[fieldName] = fieldType
+	}
+
+	for _, op := range migration.Operations {
+		fieldType, ok := schema[op.Column]
+		switch op.Type {
+		case skydb.AddColumnOperation:
+			if ok {
+				return fmt.Errorf("column %s already exists", op.Column)
+			}
+			schema[op.Column] = op.FieldType
+		case skydb.RenameColumnOperation:
+			if !ok {
+				return fmt.Errorf("column %s does not exist", op.Column)
+			}
+			if _, ok := schema[op.NewName]; ok {
+				return fmt.Errorf("column %s already exists", op.NewName)
+			}
+			schema[op.NewName] = fieldType
+			delete(schema, op.Column)
+		case skydb.RetypeColumnOperation:
+			if !ok {
+				return fmt.Errorf("column %s does not exist", op.Column)
+			}
+			schema[op.Column] = op.FieldType
+		case skydb.AddLowerIndexOperation:
+			if !ok {
+				return fmt.Errorf("column %s does not exist", op.Column)
+			}
+		default:
+			return fmt.Errorf("unknown operation %s", op.Type)
+		}
+	}
+
+	db.RecordSchemaMap[migration.RecordType] = schema
+	if db.SchemaVersionMap == nil {
+		db.SchemaVersionMap = map[string]string{}
+	}
+	db.SchemaVersionMap[migration.RecordType] = migration.Version
+	return nil
+}
+
 // GetSchema returns the record schema of a record type
 func (db *MapDB) GetSchema(recordType string) (skydb.RecordSchema, error) {
 	if _, ok := db.RecordSchemaMap[recordType]; !ok {
@@ -401,6 +600,12 @@ func (db *MapDB) GetSchema(recordType string) (skydb.RecordSchema, error) {
 	return db.RecordSchemaMap[recordType], nil
 }
 
+// RemoteColumnTypes returns the record schema of a record type, or nil
+// if the record type does not exist.
+func (db *MapDB) RemoteColumnTypes(recordType string) (skydb.RecordSchema, error) {
+	return db.RecordSchemaMap[recordType], nil
+}
+
 // GetRecordSchemas returns a list of all existing record type
 func (db *MapDB) GetRecordSchemas() (map[string]skydb.RecordSchema, error) {
 	return db.RecordSchemaMap, nil
@@ -0,0 +1,239 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+func (c *conn) SetRecordAccess(recordType string, acl skydb.RecordACL) error {
+	return c.withTx(func() error {
+		roles := []string{}
+		for _, ace := range acl {
+			if ace.Role != "" {
+				roles = append(roles, ace.Role)
+			}
+		}
+		if err := c.EnsureRoles(roles); err != nil {
+			return err
+		}
+
+		if _, err := c.exec("DELETE FROM _record_creation WHERE record_type = ?", recordType); err != nil {
+			return err
+		}
+		for _, role := range roles {
+			_, err := c.exec("INSERT OR IGNORE INTO _record_creation (record_type, role_id) VALUES (?, ?)",
+				recordType, role)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetRecordAccess(recordType string) (skydb.RecordACL, error) {
+	roles, err := c.queryStrings("SELECT role_id FROM _record_creation WHERE record_type = ? ORDER BY role_id", recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []skydb.RecordACLEntry{}
+	for _, role := range roles {
+		entries = append(entries, skydb.NewRecordACLEntryRole(role, skydb.CreateLevel))
+	}
+	return skydb.NewRecordACL(entries), nil
+}
+
+func (c *conn) SetRecordDefaultAccess(recordType string, acl skydb.RecordACL) error {
+	aclJSON, err := aclValue(acl)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.exec(`INSERT INTO _record_default_access (record_type, default_access) VALUES (?, ?)
+		ON CONFLICT (record_type) DO UPDATE SET default_access = excluded.default_access`,
+		recordType, aclJSON)
+	return err
+}
+
+func (c *conn) GetRecordDefaultAccess(recordType string) (skydb.RecordACL, error) {
+	var aclJSON sql.NullString
+	err := c.queryRow("SELECT default_access FROM _record_default_access WHERE record_type = ?", recordType).
+		Scan(&aclJSON)
+	if err == sql.ErrNoRows {
+		// no default access is set for the record type
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseACL(aclJSON)
+}
+
+func (c *conn) SetRecordFieldAccess(acl skydb.FieldACL) error {
+	return c.withTx(func() error {
+		if _, err := c.exec("DELETE FROM _record_field_access"); err != nil {
+			return err
+		}
+
+		for _, entry := range acl.AllEntries() {
+			_, err := c.exec(`INSERT INTO _record_field_access
+				(record_type, record_field, user_role, writable, readable, comparable, discoverable)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				entry.RecordType,
+				entry.RecordField,
+				entry.UserRole.String(),
+				entry.Writable,
+				entry.Readable,
+				entry.Comparable,
+				entry.Discoverable,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetRecordFieldAccess() (skydb.FieldACL, error) {
+	rows, err := c.query(`SELECT record_type, record_field, user_role,
+		writable, readable, comparable, discoverable
+		FROM _record_field_access`)
+	if err != nil {
+		return skydb.FieldACL{}, err
+	}
+	defer rows.Close()
+
+	entries := skydb.FieldACLEntryList{}
+	for rows.Next() {
+		var (
+			entry    skydb.FieldACLEntry
+			userRole string
+		)
+		err := rows.Scan(
+			&entry.RecordType,
+			&entry.RecordField,
+			&userRole,
+			&entry.Writable,
+			&entry.Readable,
+			&entry.Comparable,
+			&entry.Discoverable,
+		)
+		if err != nil {
+			return skydb.FieldACL{}, err
+		}
+		if entry.UserRole, err = skydb.ParseFieldUserRole(userRole); err != nil {
+			return skydb.FieldACL{}, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return skydb.FieldACL{}, err
+	}
+	return skydb.NewFieldACL(entries), nil
+}
+
+func (c *conn) SetRecordSystemFieldAccess(recordType string, masks skydb.SystemFieldMasks) error {
+	masksJSON, err := json.Marshal(masks)
+	if err != nil {
+		return err
+	}
+
+	// The salt is kept on update, so that pseudonyms do not change when
+	// the masks are changed.
+	_, err = c.exec(`INSERT INTO _record_system_field_access (record_type, masks, salt) VALUES (?, ?, ?)
+		ON CONFLICT (record_type) DO UPDATE SET masks = excluded.masks`,
+		recordType, string(masksJSON), uuid.New())
+	return err
+}
+
+func (c *conn) GetRecordSystemFieldAccess() (map[string]skydb.SystemFieldAccess, error) {
+	rows, err := c.query("SELECT record_type, masks, salt FROM _record_system_field_access")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]skydb.SystemFieldAccess{}
+	for rows.Next() {
+		var (
+			recordType string
+			masksJSON  string
+			access     skydb.SystemFieldAccess
+		)
+		if err := rows.Scan(&recordType, &masksJSON, &access.Salt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(masksJSON), &access.Masks); err != nil {
+			return nil, err
+		}
+		result[recordType] = access
+	}
+	return result, rows.Err()
+}
+
+func (c *conn) SetRecordStrictSchema(recordType string, strict bool) error {
+	if !strict {
+		_, err := c.exec("DELETE FROM _record_strict_schema WHERE record_type = ?", recordType)
+		return err
+	}
+
+	_, err := c.exec("INSERT OR IGNORE INTO _record_strict_schema (record_type) VALUES (?)", recordType)
+	return err
+}
+
+func (c *conn) GetRecordStrictSchema() (skydb.StrictSchema, error) {
+	recordTypes, err := c.queryStrings("SELECT record_type FROM _record_strict_schema")
+	if err != nil {
+		return nil, err
+	}
+
+	strictSchema := skydb.StrictSchema{}
+	for _, recordType := range recordTypes {
+		strictSchema[recordType] = true
+	}
+	return strictSchema, nil
+}
+
+// aclValue encodes the ACL in JSON. A nil ACL is saved as NULL, which
+// means the record is accessible by everyone.
+func aclValue(acl skydb.RecordACL) (interface{}, error) {
+	if acl == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(acl)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func parseACL(aclJSON sql.NullString) (skydb.RecordACL, error) {
+	if !aclJSON.Valid {
+		return nil, nil
+	}
+	acl := skydb.RecordACL{}
+	if err := json.Unmarshal([]byte(aclJSON.String), &acl); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+func (c *conn) GetAsset(name string, asset *skydb.Asset) error {
+	assets, err := c.GetAssets([]string{name})
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return errors.New("asset not found")
+	}
+
+	*asset = assets[0]
+	return nil
+}
+
+func (c *conn) GetAssets(names []string) ([]skydb.Asset, error) {
+	if len(names) == 0 {
+		return []skydb.Asset{}, nil
+	}
+
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	rows, err := c.query("SELECT id, content_type, size FROM _asset WHERE id IN (?"+
+		repeatPlaceholders(len(names)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []skydb.Asset{}
+	for rows.Next() {
+		a := skydb.Asset{}
+		if err := rows.Scan(&a.Name, &a.ContentType, &a.Size); err != nil {
+			return nil, err
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+func (c *conn) SaveAsset(asset *skydb.Asset) error {
+	_, err := c.exec("INSERT OR REPLACE INTO _asset (id, content_type, size) VALUES (?, ?, ?)",
+		asset.Name, asset.ContentType, asset.Size)
+	return err
+}
+
+func (c *conn) GetAssetNames(prefix string) ([]string, error) {
+	// escape the wildcards of LIKE in the prefix
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+	return c.queryStrings(`SELECT id FROM _asset WHERE id LIKE ? ESCAPE '\' ORDER BY id`, pattern)
+}
+
+// RenameAsset copies the asset to the new name, updates the asset fields
+// of all records referencing the old name, and then deletes the old
+// asset.
+func (c *conn) RenameAsset(oldName, newName string) error {
+	return c.withTx(func() error {
+		var asset skydb.Asset
+		if err := c.GetAsset(oldName, &asset); err != nil {
+			return skydb.ErrAssetNotFound
+		}
+		asset.Name = newName
+		if err := c.SaveAsset(&asset); err != nil {
+			return err
+		}
+
+		schemas, err := c.PublicDB().GetRecordSchemas()
+		if err != nil {
+			return err
+		}
+		for recordType, schema := range schemas {
+			fields := assetFields(schema)
+			if len(fields) == 0 {
+				continue
+			}
+
+			err := c.rewriteRecords(recordType, func(record *skydb.Record) bool {
+				rewritten := false
+				for _, field := range fields {
+					if a, ok := record.Data[field].(*skydb.Asset); ok && a.Name == oldName {
+						record.Data[field] = &skydb.Asset{Name: newName, ContentType: a.ContentType}
+						rewritten = true
+					}
+				}
+				return rewritten
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return c.DeleteAsset(oldName)
+	})
+}
+
+// DeleteAsset deletes the asset unless it is referenced by records, like
+// the foreign keys of asset columns in pq.
+func (c *conn) DeleteAsset(name string) error {
+	return c.withTx(func() error {
+		schemas, err := c.PublicDB().GetRecordSchemas()
+		if err != nil {
+			return err
+		}
+		for recordType, schema := range schemas {
+			fields := assetFields(schema)
+			if len(fields) == 0 {
+				continue
+			}
+
+			records, err := c.selectRecords("record_type = ?", recordType)
+			if err != nil {
+				return err
+			}
+			for _, record := range records {
+				for _, field := range fields {
+					if a, ok := record.Data[field].(*skydb.Asset); ok && a.Name == name {
+						return skyerr.NewErrorf(skyerr.ConstraintViolated,
+							"asset %s is referenced by records", name)
+					}
+				}
+			}
+		}
+
+		rowsAffected, err := c.execAffected("DELETE FROM _asset WHERE id = ?", name)
+		if err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return skydb.ErrAssetNotFound
+		}
+		return nil
+	})
+}
+
+func assetFields(schema skydb.RecordSchema) []string {
+	fields := []string{}
+	for field, fieldType := range schema {
+		if fieldType.Type == skydb.TypeAsset {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,41 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+)
+
+func (c *conn) IncrementCounter(name string, delta int64) (int64, error) {
+	log.Debugf("Increment Counter: %v, %v", name, delta)
+	_, err := c.exec(`INSERT INTO _counter (name, value) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = value + excluded.value`, name, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.GetCounter(name)
+}
+
+func (c *conn) GetCounter(name string) (int64, error) {
+	var value int64
+	err := c.queryRow("SELECT value FROM _counter WHERE name = ?", name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return value, err
+}
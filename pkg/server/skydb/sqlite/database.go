@@ -0,0 +1,340 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/unsupported"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+type database struct {
+	c            *conn
+	userID       string
+	databaseType skydb.DatabaseType
+
+	unsupported.Database
+}
+
+func (db *database) Conn() skydb.Conn       { return db.c }
+func (db *database) UserRecordType() string { return "user" }
+
+func (db *database) ID() string {
+	if db.DatabaseType() == skydb.PublicDatabase {
+		return skydb.PublicDatabaseIdentifier
+	} else if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.UnionDatabaseIdentifier
+	}
+
+	if db.userID == "" {
+		panic("Private database but userID is empty")
+	}
+	return db.userID
+}
+
+func (db *database) DatabaseType() skydb.DatabaseType { return db.databaseType }
+func (db *database) IsReadOnly() bool                 { return db.DatabaseType() == skydb.UnionDatabase }
+
+// TableName returns the name of the table storing the records, which is
+// the same for all record types.
+func (db *database) TableName(table string) string {
+	return "_record"
+}
+
+func (db *database) Begin() error    { return db.c.Begin() }
+func (db *database) Commit() error   { return db.c.Commit() }
+func (db *database) Rollback() error { return db.c.Rollback() }
+
+func (db *database) Get(id skydb.RecordID, record *skydb.Record) error {
+	found, err := db.c.getRecord(id)
+	if err != nil {
+		return err
+	}
+	*record = *found
+	return nil
+}
+
+// GetByIDs returns the records of the IDs which are not deleted. Like pq,
+// all IDs are assumed to be of the same record type.
+func (db *database) GetByIDs(ids []skydb.RecordID) (*skydb.Rows, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("db.GetByIDs received empty array")
+	}
+
+	recordType := ""
+	keys := []interface{}{}
+	for _, id := range ids {
+		if id.Key != "" {
+			keys = append(keys, id.Key)
+		}
+		if id.Type != "" && recordType == "" {
+			recordType = id.Type
+		}
+	}
+
+	schema, err := db.GetSchema(recordType)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, skydb.ErrRecordNotFound
+	}
+
+	records := []skydb.Record{}
+	if len(keys) > 0 {
+		condition := "record_type = ? AND deleted_at IS NULL AND id IN (?" +
+			repeatPlaceholders(len(keys)-1) + ")"
+		args := append([]interface{}{recordType}, keys...)
+		if records, err = db.c.selectRecords(condition, args...); err != nil {
+			return nil, err
+		}
+	}
+	return skydb.NewRows(skydb.NewMemoryRows(records)), nil
+}
+
+func repeatPlaceholders(n int) string {
+	s := ""
+	for i := 0; i < n; i++ {
+		s += ", ?"
+	}
+	return s
+}
+
+// Save upserts the record. Like pq, fields not in the record are kept,
+// and the owner and creation of an existing record are not changed.
+func (db *database) Save(record *skydb.Record) error {
+	if record.ID.Key == "" {
+		return errors.New("db.save: got empty record id")
+	}
+	if record.ID.Type == "" {
+		return fmt.Errorf("db.save %s: got empty record type", record.ID.Key)
+	}
+	if record.OwnerID == "" {
+		return fmt.Errorf("db.save %s: got empty OwnerID", record.ID.Key)
+	}
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	return db.c.withTx(func() error {
+		existing, err := db.c.getRecord(record.ID)
+		if err == skydb.ErrRecordNotFound {
+			existing = nil
+		} else if err != nil {
+			return err
+		} else if existing.DatabaseID != db.userID {
+			// The record key is taken by a record in another database.
+			return skyerr.NewError(skyerr.Duplicated, "violate unique constraint")
+		}
+
+		if existing == nil {
+			if record.BaseRevision != 0 {
+				return skydb.ErrRecordConflict
+			}
+			return db.write(record, nil)
+		}
+
+		if record.BaseRevision != 0 && record.BaseRevision != existing.Revision {
+			return skydb.ErrRecordConflict
+		}
+		saved := *record
+		saved.OwnerID = existing.OwnerID
+		saved.CreatedAt = existing.CreatedAt
+		saved.CreatorID = existing.CreatorID
+		if err := db.write(&saved, existing); err != nil {
+			return err
+		}
+		*record = saved
+		return nil
+	})
+}
+
+// Merge updates the fields in the record of an existing record in the
+// database.
+func (db *database) Merge(record *skydb.Record) error {
+	if record.ID.Key == "" {
+		return errors.New("db.merge: got empty record id")
+	}
+	if record.ID.Type == "" {
+		return fmt.Errorf("db.merge %s: got empty record type", record.ID.Key)
+	}
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	return db.c.withTx(func() error {
+		existing, err := db.c.getRecord(record.ID)
+		if err == skydb.ErrRecordNotFound || err == nil && existing.DatabaseID != db.userID {
+			if record.BaseRevision != 0 {
+				return skydb.ErrRecordConflict
+			}
+			return skydb.ErrRecordNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if record.BaseRevision != 0 && record.BaseRevision != existing.Revision {
+			return skydb.ErrRecordConflict
+		}
+		merged := *record
+		merged.OwnerID = existing.OwnerID
+		merged.CreatedAt = existing.CreatedAt
+		merged.CreatorID = existing.CreatorID
+		if err := db.write(&merged, existing); err != nil {
+			return err
+		}
+		*record = merged
+		return nil
+	})
+}
+
+// write writes the record merged with the existing record, which is nil
+// if the record is new. The record is updated to the saved record.
+func (db *database) write(record *skydb.Record, existing *skydb.Record) error {
+	schema, err := db.GetSchema(record.ID.Type)
+	if err != nil {
+		return err
+	}
+
+	data := skydb.Data{}
+	if existing != nil {
+		data = existing.Data.Copy()
+	}
+	for key, value := range record.Data {
+		switch v := value.(type) {
+		case skydb.Increment:
+			current, _ := toFloat(data[key])
+			if schema[key].Type == skydb.TypeInteger {
+				data[key] = int64(current + v.Delta)
+			} else {
+				data[key] = current + v.Delta
+			}
+		case skydb.MergePatch:
+			data[key] = v.Apply(data[key])
+		case skydb.JSONPatch:
+			if data[key], err = v.Apply(data[key]); err != nil {
+				return skyerr.NewInvalidArgument(err.Error(), []string{key})
+			}
+		case skydb.TextPatch:
+			current, _ := data[key].(string)
+			if data[key], err = v.Apply(current); err != nil {
+				return skyerr.NewInvalidArgument(err.Error(), []string{key})
+			}
+		case skydb.Sequence:
+			// The value of a sequence field is assigned when the
+			// record is created.
+		case skydb.Unknown:
+			// Do not modify fields with unknown type because they are
+			// managed by the developer.
+		default:
+			data[key] = value
+		}
+	}
+
+	for key, fieldType := range schema {
+		if key[0] == '_' {
+			continue
+		}
+		if position, ok := data[key].(string); ok && fieldType.Type == skydb.TypePosition && !skydb.IsValidPosition(position) {
+			return skyerr.NewInvalidArgument(
+				fmt.Sprintf("invalid position for field %s", key), []string{key})
+		}
+		if existing == nil {
+			if err := db.fillNewField(record.ID.Type, key, fieldType, data); err != nil {
+				return err
+			}
+		}
+		if fieldType.Required && data[key] == nil {
+			return skyerr.NewErrorf(
+				skyerr.ConstraintViolated,
+				"failed to save %s: field %s is required", record.ID, key,
+			)
+		}
+	}
+
+	record.Data = data
+	record.DatabaseID = db.userID
+	record.Revision = 1
+	if existing != nil {
+		record.Revision = existing.Revision + 1
+	}
+
+	if err := db.checkUniqueIndexes(record); err != nil {
+		return err
+	}
+	return db.c.writeRecord(record)
+}
+
+// fillNewField sets the default value of the field of a new record, or
+// the next value of a sequence field.
+func (db *database) fillNewField(recordType, key string, fieldType skydb.FieldType, data skydb.Data) error {
+	if fieldType.Type == skydb.TypeSequence {
+		counterName := fmt.Sprintf("_sequence:%s:%s", recordType, key)
+		if n, ok := toFloat(data[key]); ok {
+			// An explicit value advances the sequence past it.
+			current, err := db.c.GetCounter(counterName)
+			if err != nil {
+				return err
+			}
+			if int64(n) > current {
+				_, err = db.c.IncrementCounter(counterName, int64(n)-current)
+			}
+			return err
+		}
+
+		next, err := db.c.IncrementCounter(counterName, 1)
+		if err != nil {
+			return err
+		}
+		data[key] = next
+		return nil
+	}
+
+	if _, ok := data[key]; !ok && fieldType.Default != nil {
+		data[key] = fieldType.Default
+	}
+	return nil
+}
+
+func (db *database) SaveBulk(records []*skydb.Record) error {
+	return db.c.withTx(func() error {
+		for _, record := range records {
+			if err := db.Save(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *database) Delete(id skydb.RecordID) error {
+	if db.DatabaseType() == skydb.UnionDatabase {
+		return skydb.ErrDatabaseIsReadOnly
+	}
+
+	rowsAffected, err := db.c.execAffected("DELETE FROM _record WHERE record_type = ? AND id = ? AND database_id = ?",
+		id.Type, id.Key, db.userID)
+	if err != nil {
+		return fmt.Errorf("delete %s: failed to delete record", id)
+	} else if rowsAffected == 0 {
+		return skydb.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+const deviceColumns = "id, type, token, auth_id, topic, last_registered_at"
+
+func (c *conn) GetDevice(id string, device *skydb.Device) error {
+	devices, err := c.queryDevices("id = ?", id)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return skydb.ErrDeviceNotFound
+	}
+
+	*device = devices[0]
+	return nil
+}
+
+func (c *conn) QueryDevicesByUser(user string) ([]skydb.Device, error) {
+	return c.queryDevices("auth_id = ?", user)
+}
+
+func (c *conn) QueryDevicesByUserAndTopic(user, topic string) ([]skydb.Device, error) {
+	return c.queryDevices("auth_id = ? AND topic = ?", user, topic)
+}
+
+func (c *conn) queryDevices(condition string, args ...interface{}) ([]skydb.Device, error) {
+	rows, err := c.query("SELECT "+deviceColumns+" FROM _device WHERE "+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []skydb.Device{}
+	for rows.Next() {
+		var (
+			d                    skydb.Device
+			token, authID, topic sql.NullString
+		)
+		if err := rows.Scan(&d.ID, &d.Type, &token, &authID, &topic, &d.LastRegisteredAt); err != nil {
+			return nil, err
+		}
+		d.Token = token.String
+		d.AuthInfoID = authID.String
+		d.Topic = topic.String
+		d.LastRegisteredAt = d.LastRegisteredAt.UTC()
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+// SaveDevice upserts the device. Like pq, an empty token or topic does
+// not overwrite the saved one.
+func (c *conn) SaveDevice(device *skydb.Device) error {
+	if device.ID == "" || device.Type == "" || device.LastRegisteredAt.IsZero() {
+		return errors.New("invalid device: empty id, type, or last registered at")
+	}
+
+	_, err := c.exec(`INSERT INTO _device (`+deviceColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			type = excluded.type,
+			token = COALESCE(excluded.token, token),
+			auth_id = excluded.auth_id,
+			topic = COALESCE(excluded.topic, topic),
+			last_registered_at = excluded.last_registered_at`,
+		device.ID,
+		device.Type,
+		nullableString(device.Token),
+		nullableString(device.AuthInfoID),
+		nullableString(device.Topic),
+		device.LastRegisteredAt.UTC(),
+	)
+	return err
+}
+
+func (c *conn) DeleteDevice(id string) error {
+	rowsAffected, err := c.execAffected("DELETE FROM _device WHERE id = ?", id)
+	if err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return skydb.ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (c *conn) DeleteDevicesByToken(token string, t time.Time) error {
+	stmt := "DELETE FROM _device WHERE token = ?"
+	args := []interface{}{token}
+	if t != skydb.ZeroTime {
+		stmt += " AND last_registered_at < ?"
+		args = append(args, t.UTC())
+	}
+
+	rowsAffected, err := c.execAffected(stmt, args...)
+	if err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return skydb.ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (c *conn) DeleteEmptyDevicesByTime(t time.Time) error {
+	stmt := "DELETE FROM _device WHERE token IS NULL"
+	args := []interface{}{}
+	if t != skydb.ZeroTime {
+		stmt += " AND last_registered_at < ?"
+		args = append(args, t.UTC())
+	}
+
+	rowsAffected, err := c.execAffected(stmt, args...)
+	if err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return skydb.ErrDeviceNotFound
+	}
+	return nil
+}
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,36 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlite implements skydb on an embedded SQLite database, so
+// that the server can be run for local development and tests without
+// provisioning PostgreSQL.
+//
+// The driver is registered as "sqlite" and is only compiled with the
+// sqlite build tag, because it requires cgo. The JSON1 extension of
+// SQLite is used to query the fields of records, which is enabled by the
+// sqlite_json build tag of go-sqlite3:
+//
+//	go build --tags "sqlite sqlite_json"
+//
+// The option string is passed to go-sqlite3 as the data source name,
+// such as "skygear.db" or "file::memory:?cache=shared".
+//
+// Records of all record types are stored in a single table with their
+// fields encoded in JSON. Predicates on fields of scalar types are
+// evaluated in SQL, and the others in the server. It is not intended for
+// production. Features relying on PostgreSQL, such as relations, locks,
+// annotations, reactions, transitions, secrets, webhooks and
+// subscriptions, return skyerr.NotSupported when written and nothing
+// when read.
+package sqlite
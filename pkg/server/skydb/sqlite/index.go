@@ -0,0 +1,222 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// Indexes are kept in the _record_index table instead of being created
+// in the database. They only matter for unique indexes, which are
+// enforced when records are saved.
+
+func (db *database) listIndexes(recordType string, uniqueOnly bool) (map[string]skydb.RecordIndex, error) {
+	stmt := "SELECT name, fields, method, is_unique FROM _record_index WHERE record_type = ?"
+	if uniqueOnly {
+		stmt += " AND is_unique"
+	}
+	rows, err := db.c.query(stmt, recordType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := map[string]skydb.RecordIndex{}
+	for rows.Next() {
+		var (
+			name, fieldsJSON, method string
+			index                    skydb.RecordIndex
+		)
+		if err := rows.Scan(&name, &fieldsJSON, &method, &index.Unique); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(fieldsJSON), &index.Fields); err != nil {
+			return nil, err
+		}
+		index.Method = skydb.IndexMethod(method)
+		indexes[name] = index
+	}
+	return indexes, rows.Err()
+}
+
+func (db *database) insertIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	var exists bool
+	err := db.c.queryRow("SELECT EXISTS (SELECT 1 FROM _record_index WHERE record_type = ? AND name = ?)",
+		recordType, indexName).Scan(&exists)
+	if err != nil {
+		return err
+	} else if exists {
+		return skyerr.NewErrorf(skyerr.Duplicated, "index %s already exists", indexName)
+	}
+
+	if index.Unique {
+		if err := db.checkUniqueIndex(recordType, index.Fields); err != nil {
+			return skyerr.NewErrorf(skyerr.ConstraintViolated,
+				"fields of unique index %s have duplicated values", indexName)
+		}
+	}
+
+	fieldsJSON, err := json.Marshal(index.Fields)
+	if err != nil {
+		return err
+	}
+	_, err = db.c.exec(`INSERT INTO _record_index (record_type, name, fields, method, is_unique)
+		VALUES (?, ?, ?, ?, ?)`,
+		recordType, indexName, string(fieldsJSON), string(index.Method), index.Unique)
+	return err
+}
+
+// checkUniqueIndex returns an error if records of the record type in the
+// database have the same values of the fields.
+func (db *database) checkUniqueIndex(recordType string, fields []string) error {
+	records, err := db.c.selectRecords("record_type = ? AND database_id = ?", recordType, db.userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		for j := i + 1; j < len(records); j++ {
+			if sameFieldValues(&records[i], &records[j], fields) {
+				return skyerr.NewError(skyerr.Duplicated, "violate unique constraint")
+			}
+		}
+	}
+	return nil
+}
+
+// checkUniqueIndexes returns an error if saving the record violates a
+// unique index of its record type. Like a unique constraint, records
+// having a null value in the fields never conflict.
+func (db *database) checkUniqueIndexes(record *skydb.Record) error {
+	indexes, err := db.listIndexes(record.ID.Type, true)
+	if err != nil || len(indexes) == 0 {
+		return err
+	}
+
+	others, err := db.c.selectRecords("record_type = ? AND database_id = ? AND id != ?",
+		record.ID.Type, db.userID, record.ID.Key)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		for i := range others {
+			if sameFieldValues(record, &others[i], index.Fields) {
+				return skyerr.NewError(skyerr.Duplicated, "violate unique constraint")
+			}
+		}
+	}
+	return nil
+}
+
+func sameFieldValues(record, other *skydb.Record, fields []string) bool {
+	for _, field := range fields {
+		value, otherValue := record.Get(field), other.Get(field)
+		if value == nil || otherValue == nil {
+			return false
+		}
+		if !reflect.DeepEqual(normalizeValue(value), normalizeValue(otherValue)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *database) GetIndexesByRecordType(recordType string) (map[string]skydb.Index, error) {
+	recordIndexes, err := db.listIndexes(recordType, true)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := map[string]skydb.Index{}
+	for name, index := range recordIndexes {
+		indexes[name] = skydb.Index{Fields: index.Fields}
+	}
+	return indexes, nil
+}
+
+func (db *database) SaveIndex(recordType, indexName string, index skydb.Index) error {
+	return db.insertIndex(recordType, indexName, skydb.RecordIndex{
+		Fields: index.Fields,
+		Method: skydb.BTreeIndex,
+		Unique: true,
+	})
+}
+
+func (db *database) DeleteIndex(recordType string, indexName string) error {
+	_, err := db.c.exec("DELETE FROM _record_index WHERE record_type = ? AND name = ? AND is_unique",
+		recordType, indexName)
+	return err
+}
+
+func (db *database) CreateIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	if index.Method == "" {
+		index.Method = skydb.BTreeIndex
+	}
+	if !index.Method.IsValid() {
+		return skyerr.NewErrorf(skyerr.InvalidArgument, "unknown index method %s", index.Method)
+	}
+	if index.Unique && index.Method != skydb.BTreeIndex {
+		return skyerr.NewErrorf(skyerr.InvalidArgument, "%s index cannot be unique", index.Method)
+	}
+	if len(index.Fields) == 0 {
+		return skyerr.NewError(skyerr.InvalidArgument, "at least one field is required to create an index")
+	}
+
+	schema, err := db.GetSchema(recordType)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return skyerr.NewErrorf(skyerr.ResourceNotFound, "record type %s does not exist", recordType)
+	}
+	for _, field := range index.Fields {
+		if _, ok := schema[field]; !ok {
+			return skyerr.NewErrorf(skyerr.InvalidArgument,
+				"field %s does not exist in record type %s", field, recordType)
+		}
+	}
+
+	return db.insertIndex(recordType, indexName, index)
+}
+
+func (db *database) DropIndex(recordType, indexName string) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	rowsAffected, err := db.c.execAffected("DELETE FROM _record_index WHERE record_type = ? AND name = ?",
+		recordType, indexName)
+	if err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return skydb.ErrIndexNotFound
+	}
+	return nil
+}
+
+func (db *database) ListIndexes(recordType string) (map[string]skydb.RecordIndex, error) {
+	return db.listIndexes(recordType, false)
+}
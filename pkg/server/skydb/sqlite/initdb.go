@@ -0,0 +1,166 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+)
+
+// initStmts create the tables if they do not exist. Unlike pq, records
+// of all record types are stored in the _record table, and the columns
+// of record types are kept in the _record_column table.
+var initStmts = []string{
+	`CREATE TABLE IF NOT EXISTS _record (
+		record_type text NOT NULL,
+		id text NOT NULL,
+		database_id text NOT NULL,
+		owner_id text NOT NULL,
+		access text,
+		created_at timestamp NOT NULL,
+		created_by text,
+		updated_at timestamp NOT NULL,
+		updated_by text,
+		deleted_at timestamp,
+		rev integer NOT NULL DEFAULT 1,
+		data text NOT NULL,
+		PRIMARY KEY (record_type, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_type (
+		record_type text PRIMARY KEY
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_column (
+		record_type text NOT NULL,
+		name text NOT NULL,
+		type text NOT NULL,
+		required boolean NOT NULL DEFAULT 0,
+		default_value text,
+		PRIMARY KEY (record_type, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _schema_migration (
+		record_type text NOT NULL,
+		version text NOT NULL,
+		applied_at timestamp NOT NULL,
+		PRIMARY KEY (record_type, version)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_index (
+		record_type text NOT NULL,
+		name text NOT NULL,
+		fields text NOT NULL,
+		method text NOT NULL,
+		is_unique boolean NOT NULL,
+		PRIMARY KEY (record_type, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _auth (
+		id text PRIMARY KEY,
+		password blob,
+		provider_info text,
+		token_valid_since timestamp,
+		last_seen_at timestamp
+	)`,
+	`CREATE TABLE IF NOT EXISTS _auth_principal (
+		auth_id text NOT NULL,
+		principal_id text NOT NULL,
+		PRIMARY KEY (auth_id, principal_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _role (
+		id text PRIMARY KEY,
+		is_admin boolean NOT NULL DEFAULT 0,
+		by_default boolean NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS _auth_role (
+		auth_id text NOT NULL,
+		role_id text NOT NULL,
+		PRIMARY KEY (auth_id, role_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _role_inheritance (
+		role_id text NOT NULL,
+		inherited_role_id text NOT NULL,
+		PRIMARY KEY (role_id, inherited_role_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_creation (
+		record_type text NOT NULL,
+		role_id text NOT NULL,
+		PRIMARY KEY (record_type, role_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_default_access (
+		record_type text PRIMARY KEY,
+		default_access text
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_field_access (
+		record_type text NOT NULL,
+		record_field text NOT NULL,
+		user_role text NOT NULL,
+		writable boolean NOT NULL,
+		readable boolean NOT NULL,
+		comparable boolean NOT NULL,
+		discoverable boolean NOT NULL,
+		PRIMARY KEY (record_type, record_field, user_role)
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_system_field_access (
+		record_type text PRIMARY KEY,
+		masks text NOT NULL,
+		salt text NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS _record_strict_schema (
+		record_type text PRIMARY KEY
+	)`,
+	`CREATE TABLE IF NOT EXISTS _asset (
+		id text PRIMARY KEY,
+		content_type text NOT NULL,
+		size integer NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS _device (
+		id text PRIMARY KEY,
+		type text NOT NULL,
+		token text,
+		auth_id text,
+		topic text,
+		last_registered_at timestamp NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS _counter (
+		name text PRIMARY KEY,
+		value integer NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS _maintenance (
+		id integer PRIMARY KEY CHECK (id = 1),
+		enabled boolean NOT NULL,
+		message text NOT NULL,
+		updated_at timestamp NOT NULL,
+		updated_by text NOT NULL
+	)`,
+	`INSERT OR IGNORE INTO _record_type (record_type) VALUES ('user')`,
+	`INSERT OR IGNORE INTO _record_column (record_type, name, type) VALUES
+		('user', 'username', 'string'),
+		('user', 'email', 'string'),
+		('user', 'phone', 'string'),
+		('user', 'last_login_at', 'datetime')`,
+}
+
+func initDB(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range initStmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
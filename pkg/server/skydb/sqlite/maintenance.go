@@ -0,0 +1,52 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) GetMaintenance() (*skydb.Maintenance, error) {
+	maintenance := skydb.Maintenance{}
+	err := c.queryRow("SELECT enabled, message, updated_at, updated_by FROM _maintenance").Scan(
+		&maintenance.Enabled,
+		&maintenance.Message,
+		&maintenance.UpdatedAt,
+		&maintenance.UpdatedBy,
+	)
+	if err == sql.ErrNoRows {
+		return &skydb.Maintenance{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	maintenance.UpdatedAt = maintenance.UpdatedAt.UTC()
+	return &maintenance, nil
+}
+
+func (c *conn) SetMaintenance(maintenance *skydb.Maintenance) error {
+	// The table has at most one row, whose primary key is always 1.
+	_, err := c.exec("INSERT OR REPLACE INTO _maintenance (id, enabled, message, updated_at, updated_by) VALUES (1, ?, ?, ?, ?)",
+		maintenance.Enabled,
+		maintenance.Message,
+		maintenance.UpdatedAt.UTC(),
+		maintenance.UpdatedBy,
+	)
+	return err
+}
@@ -0,0 +1,277 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// Predicates are translated to SQL conditions on the _record table where
+// the result is the same as evaluating them in Go. Fields are extracted
+// from the JSON data with json_extract, so only fields of types stored
+// as JSON scalars are compared, and only with literals of the same type.
+// The other predicates are left to be evaluated in Go.
+
+// splitPredicate returns the SQL condition of the part of the predicate
+// which can be translated, and the rest of the predicate. Children of
+// an and predicate are translated separately, so that the records are
+// filtered by the translatable children in SQL.
+func splitPredicate(p skydb.Predicate, schema skydb.RecordSchema) (string, []interface{}, skydb.Predicate) {
+	if p.IsEmpty() {
+		return "", nil, skydb.Predicate{}
+	}
+
+	if p.Operator != skydb.And {
+		if sql, args, ok := sqlPredicate(p, schema); ok {
+			return sql, args, skydb.Predicate{}
+		}
+		return "", nil, p
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	rest := []interface{}{}
+	for _, child := range p.Children {
+		childPredicate, ok := child.(skydb.Predicate)
+		if !ok {
+			rest = append(rest, child)
+			continue
+		}
+		sql, childArgs, ok := sqlPredicate(childPredicate, schema)
+		if !ok {
+			rest = append(rest, child)
+			continue
+		}
+		conditions = append(conditions, sql)
+		args = append(args, childArgs...)
+	}
+
+	switch len(rest) {
+	case 0:
+		return strings.Join(conditions, " AND "), args, skydb.Predicate{}
+	case 1:
+		if restPredicate, ok := rest[0].(skydb.Predicate); ok {
+			return strings.Join(conditions, " AND "), args, restPredicate
+		}
+	}
+	return strings.Join(conditions, " AND "), args, skydb.Predicate{
+		Operator: skydb.And,
+		Children: rest,
+	}
+}
+
+// sqlPredicate translates the predicate to a SQL condition. ok is false
+// if the predicate cannot be translated.
+func sqlPredicate(p skydb.Predicate, schema skydb.RecordSchema) (sql string, args []interface{}, ok bool) {
+	switch p.Operator {
+	case skydb.And, skydb.Or:
+		if len(p.Children) == 0 {
+			return "", nil, false
+		}
+		conditions := make([]string, len(p.Children))
+		for i, child := range p.Children {
+			childPredicate, ok := child.(skydb.Predicate)
+			if !ok {
+				return "", nil, false
+			}
+			childSQL, childArgs, ok := sqlPredicate(childPredicate, schema)
+			if !ok {
+				return "", nil, false
+			}
+			conditions[i] = childSQL
+			args = append(args, childArgs...)
+		}
+		separator := " AND "
+		if p.Operator == skydb.Or {
+			separator = " OR "
+		}
+		return "(" + strings.Join(conditions, separator) + ")", args, true
+	case skydb.Not:
+		if len(p.Children) != 1 {
+			return "", nil, false
+		}
+		childPredicate, ok := p.Children[0].(skydb.Predicate)
+		if !ok {
+			return "", nil, false
+		}
+		childSQL, childArgs, ok := sqlPredicate(childPredicate, schema)
+		if !ok {
+			return "", nil, false
+		}
+		// A comparison with null is null in SQL, but false in Go.
+		return "NOT COALESCE(" + childSQL + ", 0)", childArgs, true
+	}
+
+	if len(p.Children) == 0 {
+		return "", nil, false
+	}
+	keyPath, ok := p.Children[0].(skydb.Expression)
+	if !ok || keyPath.Type != skydb.KeyPath {
+		return "", nil, false
+	}
+	field, args, dataType, ok := sqlField(keyPath.Value, schema)
+	if !ok {
+		return "", nil, false
+	}
+
+	switch p.Operator {
+	case skydb.IsNull:
+		return field + " IS NULL", args, true
+	case skydb.IsNotNull:
+		return field + " IS NOT NULL", args, true
+	case skydb.In:
+		if len(p.Children) != 2 {
+			return "", nil, false
+		}
+		list, ok := literalValue(p.Children[1]).([]interface{})
+		if !ok {
+			return "", nil, false
+		}
+		if len(list) == 0 {
+			return "0", nil, true
+		}
+		placeholders := make([]string, len(list))
+		for i, item := range list {
+			value, ok := sqlLiteral(item, dataType)
+			if !ok {
+				return "", nil, false
+			}
+			placeholders[i] = "?"
+			args = append(args, value)
+		}
+		return field + " IN (" + strings.Join(placeholders, ", ") + ")", args, true
+	case skydb.Between:
+		if len(p.Children) != 3 {
+			return "", nil, false
+		}
+		lower, lowerOK := sqlLiteral(literalValue(p.Children[1]), dataType)
+		upper, upperOK := sqlLiteral(literalValue(p.Children[2]), dataType)
+		if !lowerOK || !upperOK {
+			return "", nil, false
+		}
+		return field + " BETWEEN ? AND ?", append(args, lower, upper), true
+	}
+
+	operators := map[skydb.Operator]string{
+		skydb.Equal:              "=",
+		skydb.NotEqual:           "<>",
+		skydb.GreaterThan:        ">",
+		skydb.LessThan:           "<",
+		skydb.GreaterThanOrEqual: ">=",
+		skydb.LessThanOrEqual:    "<=",
+	}
+	operator, ok := operators[p.Operator]
+	if !ok || len(p.Children) != 2 {
+		return "", nil, false
+	}
+	value, ok := sqlLiteral(literalValue(p.Children[1]), dataType)
+	if !ok {
+		return "", nil, false
+	}
+	return field + " " + operator + " ?", append(args, value), true
+}
+
+// sqlOrderBy translates the sorts to a SQL order by clause. Like the
+// sorting in Go, nulls are placed after other values in ascending order
+// by default, and records of the same sort values are ordered by ID.
+func sqlOrderBy(sorts []skydb.Sort, schema skydb.RecordSchema) (string, []interface{}, bool) {
+	terms := []string{}
+	args := []interface{}{}
+	for _, s := range sorts {
+		if s.Expression.Type != skydb.KeyPath {
+			return "", nil, false
+		}
+		field, fieldArgs, _, ok := sqlField(s.Expression.Value, schema)
+		if !ok {
+			return "", nil, false
+		}
+
+		nullsFirst := s.Nulls == skydb.NullsFirst ||
+			s.Nulls == skydb.NullsDefault && s.Order == skydb.Descending
+		nullOrder := "ASC"
+		if nullsFirst {
+			nullOrder = "DESC"
+		}
+		order := "ASC"
+		if s.Order == skydb.Descending {
+			order = "DESC"
+		}
+		terms = append(terms, field+" IS NULL "+nullOrder, field+" "+order)
+		args = append(args, fieldArgs...)
+		args = append(args, fieldArgs...)
+	}
+	terms = append(terms, "id")
+	return strings.Join(terms, ", "), args, true
+}
+
+// sqlField returns the SQL expression of the key path and its type. ok
+// is false if the key path cannot be compared in SQL.
+func sqlField(value interface{}, schema skydb.RecordSchema) (string, []interface{}, skydb.DataType, bool) {
+	keyPath, _ := value.(string)
+	switch keyPath {
+	case "_id":
+		return "id", nil, skydb.TypeString, true
+	case "_owner_id":
+		return "owner_id", nil, skydb.TypeString, true
+	case "_database_id":
+		return "database_id", nil, skydb.TypeString, true
+	}
+	if keyPath == "" || strings.HasPrefix(keyPath, "_") || strings.ContainsAny(keyPath, `."`) {
+		return "", nil, 0, false
+	}
+
+	dataType := schema[keyPath].Type
+	switch dataType {
+	case skydb.TypeString, skydb.TypeNumber, skydb.TypeInteger, skydb.TypeBoolean:
+		return "json_extract(data, ?)", []interface{}{`$."` + keyPath + `"`}, dataType, true
+	}
+	return "", nil, 0, false
+}
+
+// sqlLiteral returns the SQL value of the literal compared with a field
+// of the type. ok is false if the literal is of another type, which is
+// never equal to the values of the field.
+func sqlLiteral(value interface{}, dataType skydb.DataType) (interface{}, bool) {
+	switch dataType {
+	case skydb.TypeString:
+		s, ok := value.(string)
+		return s, ok
+	case skydb.TypeNumber, skydb.TypeInteger:
+		return toFloat(value)
+	case skydb.TypeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, false
+		}
+		// JSON booleans are extracted as integers.
+		if b {
+			return 1, true
+		}
+		return 0, true
+	}
+	return nil, false
+}
+
+func literalValue(child interface{}) interface{} {
+	expr, ok := child.(skydb.Expression)
+	if !ok || expr.Type != skydb.Literal {
+		return nil
+	}
+	return expr.Value
+}
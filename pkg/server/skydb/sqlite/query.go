@@ -0,0 +1,486 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// Queries are evaluated in SQL where the predicate and the sorts can be
+// translated, see predicate.go. The rest of the predicate, the sorts and
+// the access control are evaluated in Go over the records filtered in
+// SQL. Includes and computed keys are ignored, and the handlers fall
+// back to fetching the referenced records.
+
+func (db *database) Query(query *skydb.Query) (*skydb.Rows, error) {
+	records, count, err := db.queryRecords(query)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		return skydb.EmptyRows, nil
+	}
+
+	rows := &queryRows{MemoryRows: skydb.NewMemoryRows(records)}
+	if query.GetCount {
+		rows.count = &count
+	}
+	return skydb.NewRows(rows), nil
+}
+
+// QueryStream is the same as Query because the records are already in
+// memory.
+func (db *database) QueryStream(query *skydb.Query) (*skydb.Rows, error) {
+	if len(query.Includes) > 0 || query.PageSize > 0 || query.GetCount {
+		return nil, skyerr.NewError(skyerr.RecordQueryInvalid,
+			"includes, page size and count cannot be used with streaming query")
+	}
+	return db.Query(query)
+}
+
+func (db *database) QueryCount(query *skydb.Query) (uint64, error) {
+	countQuery := *query
+	countQuery.Sorts = nil
+	countQuery.Limit = nil
+	countQuery.Offset = 0
+	_, count, err := db.queryRecords(&countQuery)
+	return count, err
+}
+
+// queryRecords returns the page of records matching the query, and the
+// number of all matching records. The records are nil if the record
+// type does not exist.
+func (db *database) queryRecords(query *skydb.Query) ([]skydb.Record, uint64, error) {
+	if query.Type == "" {
+		return nil, 0, errors.New("got empty query type")
+	}
+	if query.IsPaginatedByKeyset() {
+		return nil, 0, skyerr.NewError(skyerr.NotSupported, "keyset pagination is not supported by the sqlite backend")
+	}
+	if len(query.DistinctOn) > 0 {
+		return nil, 0, skyerr.NewError(skyerr.NotSupported, "distinct on is not supported by the sqlite backend")
+	}
+	if query.AsOf != nil {
+		return nil, 0, skyerr.NewError(skyerr.NotSupported, "as of queries are not supported by the sqlite backend")
+	}
+
+	schema, err := db.GetSchema(query.Type)
+	if err != nil {
+		return nil, 0, err
+	}
+	if schema == nil { // record type has not been created
+		return nil, 0, nil
+	}
+
+	condition := "record_type = ?"
+	args := []interface{}{query.Type}
+	if db.DatabaseType() != skydb.UnionDatabase {
+		condition += " AND database_id = ?"
+		args = append(args, db.userID)
+	}
+	if !query.IncludeDeleted {
+		condition += " AND deleted_at IS NULL"
+	}
+	predicateSQL, predicateArgs, predicate := splitPredicate(query.Predicate, schema)
+	if predicateSQL != "" {
+		condition += " AND " + predicateSQL
+		args = append(args, predicateArgs...)
+	}
+
+	// Records are sorted and paged in SQL only if no records are left
+	// to be filtered in Go.
+	checkACL := db.DatabaseType() == skydb.PublicDatabase && !query.BypassAccessControl
+	if predicate.IsEmpty() && !checkACL {
+		if orderBy, orderArgs, ok := sqlOrderBy(query.Sorts, schema); ok {
+			return db.c.selectRecordPage(condition, args, orderBy, orderArgs, query.Offset, query.Limit)
+		}
+	}
+
+	all, err := db.c.selectRecords(condition, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	records := []skydb.Record{}
+	for i := range all {
+		if checkACL && !all[i].Accessible(query.ViewAsUser, skydb.ReadLevel) {
+			continue
+		}
+		matched, err := matchPredicate(&all[i], predicate)
+		if err != nil {
+			return nil, 0, err
+		}
+		if matched {
+			records = append(records, all[i])
+		}
+	}
+
+	if err := sortRecords(records, query.Sorts); err != nil {
+		return nil, 0, err
+	}
+
+	count := uint64(len(records))
+	if query.Offset >= count {
+		records = []skydb.Record{}
+	} else {
+		records = records[query.Offset:]
+	}
+	if query.Limit != nil && uint64(len(records)) > *query.Limit {
+		records = records[:*query.Limit]
+	}
+	return records, count, nil
+}
+
+// queryRows returns the number of all matching records as the overall
+// record count if the count is requested.
+type queryRows struct {
+	*skydb.MemoryRows
+	count *uint64
+}
+
+func (rs *queryRows) OverallRecordCount() *uint64 {
+	return rs.count
+}
+
+func matchPredicate(record *skydb.Record, p skydb.Predicate) (bool, error) {
+	if p.IsEmpty() {
+		return true, nil
+	}
+
+	switch p.Operator {
+	case skydb.And, skydb.Or:
+		for _, child := range p.Children {
+			childPredicate, ok := child.(skydb.Predicate)
+			if !ok {
+				return false, skyerr.NewError(skyerr.RecordQueryInvalid, "compound predicate must contain predicates")
+			}
+			matched, err := matchPredicate(record, childPredicate)
+			if err != nil {
+				return false, err
+			}
+			if p.Operator == skydb.And && !matched {
+				return false, nil
+			}
+			if p.Operator == skydb.Or && matched {
+				return true, nil
+			}
+		}
+		return p.Operator == skydb.And, nil
+	case skydb.Not:
+		childPredicate, ok := p.Children[0].(skydb.Predicate)
+		if !ok {
+			return false, skyerr.NewError(skyerr.RecordQueryInvalid, "not predicate must contain a predicate")
+		}
+		matched, err := matchPredicate(record, childPredicate)
+		return !matched, err
+	case skydb.Functional:
+		return false, skyerr.NewError(skyerr.NotSupported, "functional predicate is not supported by the sqlite backend")
+	}
+
+	values := make([]interface{}, len(p.Children))
+	for i, child := range p.Children {
+		expr, ok := child.(skydb.Expression)
+		if !ok {
+			return false, skyerr.NewError(skyerr.RecordQueryInvalid, "comparison predicate must contain expressions")
+		}
+		value, err := evaluate(record, expr)
+		if err != nil {
+			return false, err
+		}
+		values[i] = normalizeValue(value)
+	}
+
+	switch p.Operator {
+	case skydb.IsNull:
+		return values[0] == nil, nil
+	case skydb.IsNotNull:
+		return values[0] != nil, nil
+	case skydb.Equal:
+		return equalValues(values[0], values[1]), nil
+	case skydb.NotEqual:
+		// Like SQL, null is neither equal nor not equal to a value.
+		return values[0] != nil && values[1] != nil && !equalValues(values[0], values[1]), nil
+	case skydb.GreaterThan, skydb.LessThan, skydb.GreaterThanOrEqual, skydb.LessThanOrEqual:
+		result, ok := compareValues(values[0], values[1])
+		if !ok {
+			return false, nil
+		}
+		switch p.Operator {
+		case skydb.GreaterThan:
+			return result > 0, nil
+		case skydb.LessThan:
+			return result < 0, nil
+		case skydb.GreaterThanOrEqual:
+			return result >= 0, nil
+		default:
+			return result <= 0, nil
+		}
+	case skydb.Between:
+		lower, lowerOK := compareValues(values[0], values[1])
+		upper, upperOK := compareValues(values[0], values[2])
+		return lowerOK && upperOK && lower >= 0 && upper <= 0, nil
+	case skydb.Like, skydb.ILike:
+		s, ok := values[0].(string)
+		pattern, patternOK := values[1].(string)
+		if !ok || !patternOK {
+			return false, nil
+		}
+		return likeMatch(s, pattern, p.Operator == skydb.ILike), nil
+	case skydb.In:
+		list, ok := values[1].([]interface{})
+		if !ok {
+			return false, skyerr.NewError(skyerr.RecordQueryInvalid, "in predicate requires an array")
+		}
+		for _, item := range list {
+			if equalValues(values[0], normalizeValue(item)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case skydb.ContainsAll, skydb.ContainsAny:
+		field, fieldOK := values[0].([]interface{})
+		list, ok := values[1].([]interface{})
+		if !ok {
+			return false, skyerr.NewErrorf(skyerr.RecordQueryInvalid, "comparison operator `%v` requires an array", p.Operator)
+		}
+		if !fieldOK {
+			return false, nil
+		}
+		for _, item := range list {
+			found := false
+			for _, fieldItem := range field {
+				if equalValues(normalizeValue(fieldItem), normalizeValue(item)) {
+					found = true
+					break
+				}
+			}
+			if p.Operator == skydb.ContainsAny && found {
+				return true, nil
+			}
+			if p.Operator == skydb.ContainsAll && !found {
+				return false, nil
+			}
+		}
+		return p.Operator == skydb.ContainsAll, nil
+	}
+	return false, skyerr.NewErrorf(skyerr.NotSupported, "operator `%v` is not supported by the sqlite backend", p.Operator)
+}
+
+func evaluate(record *skydb.Record, expr skydb.Expression) (interface{}, error) {
+	switch expr.Type {
+	case skydb.Literal:
+		return expr.Value, nil
+	case skydb.KeyPath:
+		keyPath, _ := expr.Value.(string)
+		if keyPath == "" || strings.Contains(keyPath, ".") {
+			return nil, skyerr.NewErrorf(skyerr.NotSupported,
+				"key path %s is not supported by the sqlite backend", keyPath)
+		}
+		if keyPath == "_access" {
+			return nil, nil
+		}
+		return record.Get(keyPath), nil
+	}
+	return nil, skyerr.NewError(skyerr.NotSupported, "functions are not supported by the sqlite backend")
+}
+
+// normalizeValue converts the value to a form that can be compared: a
+// number is converted to float64, and a reference or an asset to its
+// key or name.
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case skydb.Reference:
+		return v.ID.Key
+	case *skydb.Asset:
+		return v.Name
+	case time.Time:
+		return v.UTC()
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC()
+	}
+	if n, ok := toFloat(value); ok {
+		return n
+	}
+	return value
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func equalValues(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if result, ok := compareValues(a, b); ok {
+		return result == 0
+	}
+	if aTime, ok := a.(time.Time); ok {
+		if bTime, ok := b.(time.Time); ok {
+			return aTime.Equal(bTime)
+		}
+	}
+	return false
+}
+
+// compareValues returns the order of two values of the same comparable
+// type. ok is false if the values cannot be compared.
+func compareValues(a, b interface{}) (result int, ok bool) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return compareFloats(av, bv), true
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0, true
+			} else if bv {
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			if av.Before(bv) {
+				return -1, true
+			} else if av.After(bv) {
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// likeMatch matches the string with a SQL LIKE pattern, where % matches
+// any characters and _ matches one character.
+func likeMatch(s, pattern string, caseInsensitive bool) bool {
+	expr := "^"
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr += regexp.QuoteMeta(string(r))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr += ".*"
+		case r == '_':
+			expr += "."
+		default:
+			expr += regexp.QuoteMeta(string(r))
+		}
+	}
+	expr += "$"
+	if caseInsensitive {
+		expr = "(?is)" + expr
+	} else {
+		expr = "(?s)" + expr
+	}
+	return regexp.MustCompile(expr).MatchString(s)
+}
+
+// sortRecords sorts the records in place. Like PostgreSQL, nulls are
+// placed after other values in ascending order by default.
+func sortRecords(records []skydb.Record, sorts []skydb.Sort) error {
+	for _, s := range sorts {
+		if s.Expression.Type != skydb.KeyPath {
+			return skyerr.NewError(skyerr.NotSupported, "sorting by functions is not supported by the sqlite backend")
+		}
+	}
+	if len(sorts) == 0 {
+		return nil
+	}
+
+	var sortErr error
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, s := range sorts {
+			a, err := evaluate(&records[i], s.Expression)
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			b, err := evaluate(&records[j], s.Expression)
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			a, b = normalizeValue(a), normalizeValue(b)
+
+			if a == nil || b == nil {
+				if a == nil && b == nil {
+					continue
+				}
+				nullsFirst := s.Nulls == skydb.NullsFirst ||
+					s.Nulls == skydb.NullsDefault && s.Order == skydb.Descending
+				return (a == nil) == nullsFirst
+			}
+
+			result, _ := compareValues(a, b)
+			if result == 0 {
+				continue
+			}
+			if s.Order == skydb.Descending {
+				return result > 0
+			}
+			return result < 0
+		}
+		return false
+	})
+	return sortErr
+}
+
+var _ skydb.RowsIter = &queryRows{}
@@ -0,0 +1,269 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+)
+
+const recordColumns = `record_type, id, database_id, owner_id, access,
+	created_at, created_by, updated_at, updated_by, deleted_at, rev, data`
+
+// encodeData encodes the record data in JSON, where values that are not
+// JSON types are encoded in the same form as in the record API.
+func encodeData(data skydb.Data) (string, error) {
+	m := map[string]interface{}{}
+	for key, value := range data {
+		switch v := value.(type) {
+		case nil:
+			// absent fields are null
+		case time.Time:
+			m[key] = skyconv.ToMap(skyconv.MapTime(v))
+		case skydb.Reference:
+			m[key] = skyconv.ToMap(skyconv.MapReference(v))
+		case skydb.Location:
+			m[key] = skyconv.ToMap(skyconv.MapLocation(v))
+		case *skydb.Location:
+			m[key] = skyconv.ToMap(skyconv.MapLocation(*v))
+		case skydb.Geometry:
+			m[key] = skyconv.ToMap(skyconv.MapGeometry(v))
+		case *skydb.Asset:
+			// the URL of the asset is not saved because it is signed
+			m[key] = map[string]interface{}{
+				"$type":         "asset",
+				"$name":         v.Name,
+				"$content_type": v.ContentType,
+			}
+		case skydb.Unknown, skydb.Sequence, skydb.Increment:
+			return "", fmt.Errorf("cannot save value of type %T to field %s", value, key)
+		default:
+			m[key] = value
+		}
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decodeData decodes the record data encoded by encodeData. Values of
+// integer fields are decoded as int64.
+func decodeData(dataJSON string, schema skydb.RecordSchema) (skydb.Data, error) {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(dataJSON), &m); err != nil {
+		return nil, err
+	}
+
+	data := skyconv.MapData{}
+	if err := data.FromMap(m); err != nil {
+		return nil, err
+	}
+
+	for key, value := range data {
+		n, ok := value.(float64)
+		if !ok {
+			continue
+		}
+		switch schema[key].Type {
+		case skydb.TypeInteger, skydb.TypeSequence:
+			data[key] = int64(n)
+		}
+	}
+	return skydb.Data(data), nil
+}
+
+// scanRecords scans the rows of the _record table selected with
+// recordColumns. The rows are closed.
+func (c *conn) scanRecords(rows *sql.Rows) ([]skydb.Record, error) {
+	type scanned struct {
+		record   skydb.Record
+		dataJSON string
+	}
+
+	defer rows.Close()
+	results := []scanned{}
+	for rows.Next() {
+		var (
+			s         scanned
+			aclJSON   sql.NullString
+			createdBy sql.NullString
+			updatedBy sql.NullString
+			deletedAt *time.Time
+		)
+		err := rows.Scan(
+			&s.record.ID.Type,
+			&s.record.ID.Key,
+			&s.record.DatabaseID,
+			&s.record.OwnerID,
+			&aclJSON,
+			&s.record.CreatedAt,
+			&createdBy,
+			&s.record.UpdatedAt,
+			&updatedBy,
+			&deletedAt,
+			&s.record.Revision,
+			&s.dataJSON,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.record.ACL, err = parseACL(aclJSON); err != nil {
+			return nil, err
+		}
+		s.record.CreatedAt = s.record.CreatedAt.UTC()
+		s.record.CreatorID = createdBy.String
+		s.record.UpdatedAt = s.record.UpdatedAt.UTC()
+		s.record.UpdaterID = updatedBy.String
+		s.record.DeletedAt = utcTime(deletedAt)
+		results = append(results, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// The data is decoded after the rows are closed, because querying
+	// the schemas requires the connection held by the rows.
+	schemas := map[string]skydb.RecordSchema{}
+	records := make([]skydb.Record, len(results))
+	for i, s := range results {
+		schema, ok := schemas[s.record.ID.Type]
+		if !ok {
+			var err error
+			if schema, err = c.getSchema(s.record.ID.Type); err != nil {
+				return nil, err
+			}
+			schemas[s.record.ID.Type] = schema
+		}
+
+		data, err := decodeData(s.dataJSON, schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %s", s.record.ID, err)
+		}
+		records[i] = s.record
+		records[i].Data = data
+	}
+	return records, nil
+}
+
+// selectRecords returns the records matching the condition on the
+// columns of the _record table.
+func (c *conn) selectRecords(condition string, args ...interface{}) ([]skydb.Record, error) {
+	rows, err := c.query("SELECT "+recordColumns+" FROM _record WHERE "+condition+
+		" ORDER BY record_type, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	return c.scanRecords(rows)
+}
+
+// selectRecordPage returns the page of records matching the condition
+// in the order, and the number of all matching records.
+func (c *conn) selectRecordPage(condition string, args []interface{}, orderBy string, orderArgs []interface{}, offset uint64, limit *uint64) ([]skydb.Record, uint64, error) {
+	var count uint64
+	if err := c.queryRow("SELECT COUNT(*) FROM _record WHERE "+condition, args...).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	// A negative limit means no limit in SQLite.
+	pageLimit := int64(-1)
+	if limit != nil {
+		pageLimit = int64(*limit)
+	}
+	pageArgs := append(append(append([]interface{}{}, args...), orderArgs...), pageLimit, int64(offset))
+	rows, err := c.query("SELECT "+recordColumns+" FROM _record WHERE "+condition+
+		" ORDER BY "+orderBy+" LIMIT ? OFFSET ?", pageArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	records, err := c.scanRecords(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, count, nil
+}
+
+// getRecord returns the record of the ID in any database, or
+// skydb.ErrRecordNotFound if it does not exist.
+func (c *conn) getRecord(id skydb.RecordID) (*skydb.Record, error) {
+	records, err := c.selectRecords("record_type = ? AND id = ?", id.Type, id.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, skydb.ErrRecordNotFound
+	}
+	return &records[0], nil
+}
+
+// writeRecord inserts or replaces the row of the record.
+func (c *conn) writeRecord(record *skydb.Record) error {
+	dataJSON, err := encodeData(record.Data)
+	if err != nil {
+		return err
+	}
+	aclJSON, err := aclValue(record.ACL)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.exec("INSERT OR REPLACE INTO _record ("+recordColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.ID.Type,
+		record.ID.Key,
+		record.DatabaseID,
+		record.OwnerID,
+		aclJSON,
+		record.CreatedAt.UTC(),
+		record.CreatorID,
+		record.UpdatedAt.UTC(),
+		record.UpdaterID,
+		nullableTime(record.DeletedAt),
+		record.Revision,
+		dataJSON,
+	)
+	return err
+}
+
+// rewriteRecords calls rewrite on each record of the record type in all
+// databases, and saves the records for which rewrite returns true. The
+// revisions of the records are not changed.
+func (c *conn) rewriteRecords(recordType string, rewrite func(record *skydb.Record) bool) error {
+	records, err := c.selectRecords("record_type = ?", recordType)
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		if !rewrite(&records[i]) {
+			continue
+		}
+		if err := c.writeRecord(&records[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
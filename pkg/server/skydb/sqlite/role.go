@@ -0,0 +1,209 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) GetAdminRoles() ([]string, error) {
+	return c.queryStrings("SELECT id FROM _role WHERE is_admin ORDER BY id")
+}
+
+func (c *conn) SetAdminRoles(roles []string) error {
+	return c.setRoleFlag(roles, "is_admin")
+}
+
+func (c *conn) GetDefaultRoles() ([]string, error) {
+	return c.queryStrings("SELECT id FROM _role WHERE by_default ORDER BY id")
+}
+
+func (c *conn) SetDefaultRoles(roles []string) error {
+	return c.setRoleFlag(roles, "by_default")
+}
+
+// setRoleFlag sets the flag column of the roles, and resets the flag of
+// the other roles.
+func (c *conn) setRoleFlag(roles []string, col string) error {
+	return c.withTx(func() error {
+		if err := c.EnsureRoles(roles); err != nil {
+			return err
+		}
+		if _, err := c.exec("UPDATE _role SET " + col + " = 0"); err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if _, err := c.exec("UPDATE _role SET "+col+" = 1 WHERE id = ?", role); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetAllRoles() ([]string, error) {
+	return c.queryStrings("SELECT id FROM _role ORDER BY id")
+}
+
+func (c *conn) EnsureRoles(roles []string) error {
+	for _, role := range roles {
+		if _, err := c.exec("INSERT OR IGNORE INTO _role (id) VALUES (?)", role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) updateUserRoles(authinfo *skydb.AuthInfo) error {
+	if _, err := c.exec("DELETE FROM _auth_role WHERE auth_id = ?", authinfo.ID); err != nil {
+		return err
+	}
+	return c.AssignRoles([]string{authinfo.ID}, authinfo.Roles)
+}
+
+func (c *conn) AssignRoles(userIDs []string, roles []string) error {
+	return c.withTx(func() error {
+		if err := c.EnsureRoles(roles); err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			for _, role := range roles {
+				_, err := c.exec("INSERT OR IGNORE INTO _auth_role (auth_id, role_id) VALUES (?, ?)",
+					userID, role)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) RevokeRoles(userIDs []string, roles []string) error {
+	return c.withTx(func() error {
+		for _, userID := range userIDs {
+			for _, role := range roles {
+				_, err := c.exec("DELETE FROM _auth_role WHERE auth_id = ? AND role_id = ?",
+					userID, role)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetRoles(userIDs []string) (map[string][]string, error) {
+	roleMap := map[string][]string{}
+	for _, userID := range userIDs {
+		// keep an empty array even no roles found for that user
+		roles, err := c.queryStrings("SELECT role_id FROM _auth_role WHERE auth_id = ? ORDER BY role_id", userID)
+		if err != nil {
+			return nil, err
+		}
+		roleMap[userID] = roles
+	}
+	return roleMap, nil
+}
+
+func (c *conn) GetUserIDsByRoles(roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return []string{}, nil
+	}
+
+	args := make([]interface{}, len(roles))
+	for idx, role := range roles {
+		args[idx] = role
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(roles)), ",")
+	return c.queryStrings("SELECT DISTINCT auth_id FROM _auth_role WHERE role_id IN ("+placeholders+") ORDER BY auth_id", args...)
+}
+
+func (c *conn) GetRoleHierarchy() (skydb.RoleHierarchy, error) {
+	rows, err := c.query("SELECT role_id, inherited_role_id FROM _role_inheritance ORDER BY role_id, inherited_role_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hierarchy := skydb.RoleHierarchy{}
+	for rows.Next() {
+		var role, inheritedRole string
+		if err := rows.Scan(&role, &inheritedRole); err != nil {
+			return nil, err
+		}
+		hierarchy[role] = append(hierarchy[role], inheritedRole)
+	}
+	return hierarchy, rows.Err()
+}
+
+func (c *conn) SetRoleInheritance(role string, inheritedRoles []string) error {
+	return c.withTx(func() error {
+		if err := c.EnsureRoles(append([]string{role}, inheritedRoles...)); err != nil {
+			return err
+		}
+		if _, err := c.exec("DELETE FROM _role_inheritance WHERE role_id = ?", role); err != nil {
+			return err
+		}
+		for _, inheritedRole := range inheritedRoles {
+			_, err := c.exec("INSERT OR IGNORE INTO _role_inheritance (role_id, inherited_role_id) VALUES (?, ?)",
+				role, inheritedRole)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// setInheritedRoles sets the roles inherited by the roles of the user.
+func (c *conn) setInheritedRoles(authinfo *skydb.AuthInfo) error {
+	if len(authinfo.Roles) == 0 {
+		authinfo.InheritedRoles = nil
+		return nil
+	}
+
+	hierarchy, err := c.GetRoleHierarchy()
+	if err != nil {
+		return err
+	}
+	authinfo.InheritedRoles = hierarchy.InheritedRoles(authinfo.Roles)
+	return nil
+}
+
+// queryStrings returns the first column of the rows returned by the
+// query.
+func (c *conn) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
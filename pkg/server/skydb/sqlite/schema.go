@@ -0,0 +1,382 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// reservedSchema is the schema of the reserved fields of every record
+// type, which are stored in the columns of the _record table.
+var reservedSchema = skydb.RecordSchema{
+	"_id":          skydb.FieldType{Type: skydb.TypeString},
+	"_database_id": skydb.FieldType{Type: skydb.TypeString},
+	"_owner_id":    skydb.FieldType{Type: skydb.TypeString},
+	"_access":      skydb.FieldType{Type: skydb.TypeACL},
+	"_created_at":  skydb.FieldType{Type: skydb.TypeDateTime, Required: true},
+	"_created_by":  skydb.FieldType{Type: skydb.TypeString},
+	"_updated_at":  skydb.FieldType{Type: skydb.TypeDateTime, Required: true},
+	"_updated_by":  skydb.FieldType{Type: skydb.TypeString},
+	"_deleted_at":  skydb.FieldType{Type: skydb.TypeDateTime},
+	"_rev":         skydb.FieldType{Type: skydb.TypeInteger, Required: true},
+}
+
+// getSchema returns the schema of the record type, or nil if the record
+// type does not exist.
+func (c *conn) getSchema(recordType string) (skydb.RecordSchema, error) {
+	var exists bool
+	err := c.queryRow("SELECT EXISTS (SELECT 1 FROM _record_type WHERE record_type = ?)", recordType).
+		Scan(&exists)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, nil
+	}
+
+	rows, err := c.query(`SELECT name, type, required, default_value
+		FROM _record_column WHERE record_type = ?`, recordType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := skydb.RecordSchema{}
+	for key, fieldType := range reservedSchema {
+		schema[key] = fieldType
+	}
+	for rows.Next() {
+		var (
+			name, typeName string
+			required       bool
+			defaultJSON    sql.NullString
+		)
+		if err := rows.Scan(&name, &typeName, &required, &defaultJSON); err != nil {
+			return nil, err
+		}
+
+		fieldType, err := skydb.SimpleNameToFieldType(typeName)
+		if err != nil {
+			return nil, err
+		}
+		fieldType.Required = required
+		if defaultJSON.Valid {
+			if err := json.Unmarshal([]byte(defaultJSON.String), &fieldType.Default); err != nil {
+				return nil, err
+			}
+		}
+		schema[name] = fieldType
+	}
+	return schema, rows.Err()
+}
+
+// putColumn inserts or replaces the column of the record type.
+func (c *conn) putColumn(recordType, name string, fieldType skydb.FieldType) error {
+	var defaultJSON interface{}
+	if fieldType.Default != nil {
+		b, err := json.Marshal(fieldType.Default)
+		if err != nil {
+			return err
+		}
+		defaultJSON = string(b)
+	}
+
+	_, err := c.exec(`INSERT OR REPLACE INTO _record_column (record_type, name, type, required, default_value)
+		VALUES (?, ?, ?, ?, ?)`,
+		recordType, name, fieldType.ToSimpleName(), fieldType.Required, defaultJSON)
+	return err
+}
+
+func (db *database) RemoteColumnTypes(recordType string) (skydb.RecordSchema, error) {
+	return db.c.getSchema(recordType)
+}
+
+func (db *database) GetSchema(recordType string) (skydb.RecordSchema, error) {
+	return db.c.getSchema(recordType)
+}
+
+func (db *database) GetRecordSchemas() (map[string]skydb.RecordSchema, error) {
+	recordTypes, err := db.c.queryStrings("SELECT record_type FROM _record_type ORDER BY record_type")
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]skydb.RecordSchema{}
+	for _, recordType := range recordTypes {
+		schema, err := db.GetSchema(recordType)
+		if err != nil {
+			return nil, err
+		}
+		result[recordType] = schema
+	}
+	return result, nil
+}
+
+func (db *database) Extend(recordType string, recordSchema skydb.RecordSchema) (extended bool, err error) {
+	remoteRecordSchema, err := db.GetSchema(recordType)
+	if err != nil {
+		return false, err
+	}
+
+	for key, fieldType := range recordSchema {
+		if !fieldType.DefaultCompatible() {
+			return false, skyerr.NewInvalidArgument(
+				fmt.Sprintf("default value of %s is not a valid %s", key, fieldType.ToSimpleName()),
+				[]string{key},
+			)
+		}
+	}
+
+	// Find new columns, and columns with constraints added. Constraints
+	// are only added, so that a field derived from a saved record does
+	// not remove the constraints declared for the field.
+	updatingSchema := skydb.RecordSchema{}
+	for key, fieldType := range recordSchema {
+		remoteFieldType, ok := remoteRecordSchema[key]
+		if !ok {
+			updatingSchema[key] = fieldType
+			continue
+		}
+
+		if !remoteFieldType.DefinitionCompatibleTo(fieldType) {
+			return false, skyerr.NewError(
+				skyerr.IncompatibleSchema,
+				fmt.Sprintf("conflicting schema %v => %v", remoteFieldType, fieldType),
+			)
+		}
+		if constraintChanged(remoteFieldType, fieldType) {
+			remoteFieldType.Required = remoteFieldType.Required || fieldType.Required
+			if fieldType.Default != nil {
+				remoteFieldType.Default = fieldType.Default
+			}
+			updatingSchema[key] = remoteFieldType
+		}
+	}
+	if len(remoteRecordSchema) > 0 && len(updatingSchema) == 0 {
+		// The current record schema is superset of requested record
+		// schema. There is no need to extend the schema.
+		return false, nil
+	}
+
+	if err := db.checkStrictSchema(recordType, remoteRecordSchema, recordSchema); err != nil {
+		return false, err
+	}
+
+	if !db.c.canMigrate {
+		// The record schemas are different, but the database connection
+		// does not allow migration.
+		return false, skyerr.NewError(
+			skyerr.IncompatibleSchema,
+			"Record schema requires migration but migration is disabled.",
+		)
+	}
+
+	err = db.c.withTx(func() error {
+		if _, err := db.c.exec("INSERT OR IGNORE INTO _record_type (record_type) VALUES (?)", recordType); err != nil {
+			return err
+		}
+		for key, fieldType := range updatingSchema {
+			if err := db.c.putColumn(recordType, key, fieldType); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to extend schema: %s", err)
+	}
+	return true, nil
+}
+
+// constraintChanged returns true if altering the column of the remote
+// field type to the field type changes its constraints.
+func constraintChanged(remoteFieldType skydb.FieldType, fieldType skydb.FieldType) bool {
+	defaultChanged := fieldType.Default != nil && !reflect.DeepEqual(fieldType.Default, remoteFieldType.Default)
+	return defaultChanged || fieldType.Required && !remoteFieldType.Required
+}
+
+// checkStrictSchema returns an error if the record type is in strict
+// schema mode, and the record type or some of its fields would be
+// created to extend the schema.
+func (db *database) checkStrictSchema(recordType string, remoteRecordSchema skydb.RecordSchema, recordSchema skydb.RecordSchema) error {
+	newColumns := []string{}
+	for key := range recordSchema {
+		if _, ok := remoteRecordSchema[key]; !ok {
+			newColumns = append(newColumns, key)
+		}
+	}
+	if len(remoteRecordSchema) > 0 && len(newColumns) == 0 {
+		return nil
+	}
+
+	strictSchema, err := db.c.GetRecordStrictSchema()
+	if err != nil {
+		return err
+	}
+	if !strictSchema.IsStrict(recordType) {
+		return nil
+	}
+
+	if len(remoteRecordSchema) == 0 {
+		return skyerr.NewErrorf(
+			skyerr.IncompatibleSchema,
+			`record type "%s" does not exist and cannot be created in strict schema mode`,
+			recordType,
+		)
+	}
+
+	sort.Strings(newColumns)
+	return skyerr.NewErrorWithInfo(
+		skyerr.IncompatibleSchema,
+		fmt.Sprintf(
+			`cannot create fields %s of record type "%s" in strict schema mode`,
+			strings.Join(newColumns, ", "), recordType,
+		),
+		map[string]interface{}{"arguments": newColumns},
+	)
+}
+
+func (db *database) RenameSchema(recordType, oldName, newName string) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	return db.c.withTx(func() error {
+		return db.c.renameColumn(recordType, oldName, newName)
+	})
+}
+
+func (c *conn) renameColumn(recordType, oldName, newName string) error {
+	schema, err := c.getSchema(recordType)
+	if err != nil {
+		return err
+	}
+	if _, ok := schema[oldName]; !ok {
+		return fmt.Errorf("failed to rename column: column %s of %s does not exist", oldName, recordType)
+	}
+	if _, ok := schema[newName]; ok {
+		return fmt.Errorf("failed to rename column: column %s of %s already exists", newName, recordType)
+	}
+
+	_, err = c.exec("UPDATE _record_column SET name = ? WHERE record_type = ? AND name = ?",
+		newName, recordType, oldName)
+	if err != nil {
+		return err
+	}
+
+	return c.rewriteRecords(recordType, func(record *skydb.Record) bool {
+		value, ok := record.Data[oldName]
+		if !ok {
+			return false
+		}
+		delete(record.Data, oldName)
+		record.Data[newName] = value
+		return true
+	})
+}
+
+func (db *database) DeleteSchema(recordType, columnName string) error {
+	if !db.c.canMigrate {
+		return skyerr.NewError(skyerr.IncompatibleSchema, "Record schema requires migration but migration is disabled.")
+	}
+
+	return db.c.withTx(func() error {
+		rowsAffected, err := db.c.execAffected("DELETE FROM _record_column WHERE record_type = ? AND name = ?",
+			recordType, columnName)
+		if err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return fmt.Errorf("failed to delete column: column %s of %s does not exist", columnName, recordType)
+		}
+
+		return db.c.rewriteRecords(recordType, func(record *skydb.Record) bool {
+			if _, ok := record.Data[columnName]; !ok {
+				return false
+			}
+			delete(record.Data, columnName)
+			return true
+		})
+	})
+}
+
+func (db *database) SchemaVersions() (map[string]string, error) {
+	rows, err := db.c.query("SELECT record_type, max(version) FROM _schema_migration GROUP BY record_type")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[string]string{}
+	for rows.Next() {
+		var recordType, version string
+		if err := rows.Scan(&recordType, &version); err != nil {
+			return nil, err
+		}
+		versions[recordType] = version
+	}
+	return versions, rows.Err()
+}
+
+// MigrateSchema applies the migration even if the connection cannot
+// migrate, because a declarative migration is run explicitly by the
+// operator. Retyping a column does not convert the existing values.
+func (db *database) MigrateSchema(migration skydb.SchemaMigration) error {
+	if err := migration.Validate(); err != nil {
+		return skyerr.NewError(skyerr.InvalidArgument, err.Error())
+	}
+
+	return db.c.withTx(func() error {
+		var applied bool
+		err := db.c.queryRow("SELECT EXISTS (SELECT 1 FROM _schema_migration WHERE record_type = ? AND version = ?)",
+			migration.RecordType, migration.Version).Scan(&applied)
+		if err != nil {
+			return err
+		} else if applied {
+			return skyerr.NewErrorf(skyerr.Duplicated,
+				`migration "%s" of %s is already applied`, migration.Version, migration.RecordType)
+		}
+
+		if _, err := db.c.exec("INSERT OR IGNORE INTO _record_type (record_type) VALUES (?)", migration.RecordType); err != nil {
+			return err
+		}
+
+		for _, op := range migration.Operations {
+			var err error
+			switch op.Type {
+			case skydb.AddColumnOperation, skydb.RetypeColumnOperation:
+				err = db.c.putColumn(migration.RecordType, op.Column, op.FieldType)
+			case skydb.RenameColumnOperation:
+				err = db.c.renameColumn(migration.RecordType, op.Column, op.NewName)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to %s %s: %s", op.Type, op.Column, err)
+			}
+		}
+
+		_, err = db.c.exec("INSERT INTO _schema_migration (record_type, version, applied_at) VALUES (?, ?, ?)",
+			migration.RecordType, migration.Version, time.Now().UTC())
+		return err
+	})
+}
@@ -0,0 +1,235 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	// register the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/unsupported"
+)
+
+var log = logging.LoggerEntry("skydb")
+
+var (
+	dbs      = map[string]*sql.DB{}
+	dbsMutex sync.Mutex
+)
+
+// Open returns a new connection to the SQLite database specified by the
+// data source name. The tables are created if migrate is true.
+func Open(ctx context.Context, appName string, accessModel skydb.AccessModel, dataSourceName string, migrate bool) (skydb.Conn, error) {
+	if accessModel == skydb.RelationBasedAccess {
+		return nil, fmt.Errorf("Unsupported AccessModel: RelationBasedAccess")
+	}
+
+	db, err := getDB(dataSourceName, migrate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{
+		db:          db,
+		appName:     appName,
+		accessModel: accessModel,
+		canMigrate:  migrate,
+		context:     ctx,
+		Conn:        unsupported.Conn{Backend: "sqlite"},
+	}, nil
+}
+
+func getDB(dataSourceName string, migrate bool) (*sql.DB, error) {
+	dbsMutex.Lock()
+	defer dbsMutex.Unlock()
+
+	if db, ok := dbs[dataSourceName]; ok {
+		return db, nil
+	}
+
+	db, err := sql.Open("sqlite3", dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection: %s", err)
+	}
+
+	// SQLite allows only one writer at a time. Statements are serialized
+	// over a single connection, which also keeps an in-memory database
+	// alive and shared by all conns.
+	db.SetMaxOpenConns(1)
+
+	if migrate {
+		if err := initDB(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to init database: %s", err)
+		}
+	}
+
+	dbs[dataSourceName] = db
+	return db, nil
+}
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type conn struct {
+	db          *sql.DB
+	tx          *sql.Tx
+	appName     string
+	accessModel skydb.AccessModel
+	canMigrate  bool
+	context     context.Context
+
+	unsupported.Conn
+}
+
+// queryer returns the transaction if one has begun. Statements must not
+// be executed outside the transaction, otherwise they wait for the only
+// connection held by the transaction.
+func (c *conn) queryer() queryer {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.db
+}
+
+func (c *conn) exec(query string, args ...interface{}) (sql.Result, error) {
+	log.WithField("sql", query).Debugln("Executing SQL")
+	return c.queryer().Exec(query, args...)
+}
+
+func (c *conn) query(query string, args ...interface{}) (*sql.Rows, error) {
+	log.WithField("sql", query).Debugln("Querying SQL")
+	return c.queryer().Query(query, args...)
+}
+
+func (c *conn) queryRow(query string, args ...interface{}) *sql.Row {
+	log.WithField("sql", query).Debugln("Querying SQL")
+	return c.queryer().QueryRow(query, args...)
+}
+
+// execAffected executes the statement and returns the number of rows
+// affected.
+func (c *conn) execAffected(query string, args ...interface{}) (int64, error) {
+	result, err := c.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Begin begins a transaction.
+func (c *conn) Begin() error {
+	if c.tx != nil {
+		return skydb.ErrDatabaseTxDidBegin
+	}
+
+	tx, err := c.db.BeginTx(c.context, nil)
+	if err != nil {
+		return err
+	}
+	c.tx = tx
+	return nil
+}
+
+// Commit commits a transaction.
+func (c *conn) Commit() error {
+	if c.tx == nil {
+		return skydb.ErrDatabaseTxDidNotBegin
+	}
+
+	if err := c.tx.Commit(); err != nil {
+		log.Errorf("%p: Unable to commit transaction: %v", c, err)
+		return err
+	}
+	c.tx = nil
+	return nil
+}
+
+// Rollback rollbacks a transaction.
+func (c *conn) Rollback() error {
+	if c.tx == nil {
+		return skydb.ErrDatabaseTxDidNotBegin
+	}
+
+	if err := c.tx.Rollback(); err != nil {
+		log.Errorf("%p: Unable to rollback transaction: %v", c, err)
+		return err
+	}
+	c.tx = nil
+	return nil
+}
+
+// withTx runs do in a transaction, or in the current transaction if one
+// has begun.
+func (c *conn) withTx(do func() error) error {
+	if c.tx != nil {
+		return do()
+	}
+	return skydb.WithTransaction(c, do)
+}
+
+func (c *conn) PublicDB() skydb.Database {
+	return &database{
+		c:            c,
+		databaseType: skydb.PublicDatabase,
+		Database:     unsupported.Database{Backend: "sqlite"},
+	}
+}
+
+func (c *conn) PrivateDB(userKey string) skydb.Database {
+	return &database{
+		c:            c,
+		databaseType: skydb.PrivateDatabase,
+		userID:       userKey,
+		Database:     unsupported.Database{Backend: "sqlite"},
+	}
+}
+
+func (c *conn) UnionDB() skydb.Database {
+	return &database{
+		c:            c,
+		databaseType: skydb.UnionDatabase,
+		Database:     unsupported.Database{Backend: "sqlite"},
+	}
+}
+
+// Subscribe is a no-op because SQLite does not notify record changes.
+func (c *conn) Subscribe(recordEventChan chan skydb.RecordEvent) error {
+	return nil
+}
+
+func (c *conn) Close() error { return nil }
+
+// this ensures that our structure conform to certain interfaces.
+var (
+	_ skydb.Conn          = &conn{}
+	_ skydb.Database      = &database{}
+	_ skydb.Transactional = &database{}
+)
+
+func init() {
+	skydb.Register("sqlite", skydb.DriverFunc(Open))
+}
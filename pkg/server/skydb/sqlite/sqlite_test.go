@@ -0,0 +1,323 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+var testDBSeq int
+
+// getTestConn returns a connection to a new in-memory database.
+func getTestConn(t *testing.T) *conn {
+	testDBSeq++
+	dsn := fmt.Sprintf("file:test%d?mode=memory&cache=shared", testDBSeq)
+	c, err := Open(context.Background(), "com.oursky.skygear", skydb.RoleBasedAccess, dsn, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*conn)
+}
+
+func readAll(rows *skydb.Rows) []skydb.Record {
+	records := []skydb.Record{}
+	for rows.Scan() {
+		records = append(records, rows.Record())
+	}
+	So(rows.Err(), ShouldBeNil)
+	return records
+}
+
+func newNote(key string, data skydb.Data) *skydb.Record {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &skydb.Record{
+		ID:        skydb.NewRecordID("note", key),
+		OwnerID:   "user0",
+		CreatedAt: now,
+		UpdatedAt: now,
+		Data:      data,
+	}
+}
+
+func TestRecord(t *testing.T) {
+	Convey("Database", t, func() {
+		c := getTestConn(t)
+		db := c.PublicDB()
+		_, err := db.Extend("note", skydb.RecordSchema{
+			"title":    skydb.FieldType{Type: skydb.TypeString},
+			"priority": skydb.FieldType{Type: skydb.TypeInteger},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("saves and gets record", func() {
+			record := newNote("1", skydb.Data{"title": "hello", "priority": 1})
+			So(db.Save(record), ShouldBeNil)
+			So(record.Revision, ShouldEqual, 1)
+
+			fetched := skydb.Record{}
+			So(db.Get(skydb.NewRecordID("note", "1"), &fetched), ShouldBeNil)
+			So(fetched.Data, ShouldResemble, skydb.Data{"title": "hello", "priority": int64(1)})
+			So(fetched.OwnerID, ShouldEqual, "user0")
+		})
+
+		Convey("keeps fields not saved and increments revision", func() {
+			So(db.Save(newNote("1", skydb.Data{"title": "hello", "priority": 1})), ShouldBeNil)
+			record := newNote("1", skydb.Data{"title": "world"})
+			record.OwnerID = "user1"
+			So(db.Save(record), ShouldBeNil)
+			So(record.Revision, ShouldEqual, 2)
+			So(record.OwnerID, ShouldEqual, "user0")
+			So(record.Data, ShouldResemble, skydb.Data{"title": "world", "priority": int64(1)})
+		})
+
+		Convey("returns conflict for stale base revision", func() {
+			So(db.Save(newNote("1", skydb.Data{"title": "hello"})), ShouldBeNil)
+			record := newNote("1", skydb.Data{"title": "world"})
+			record.BaseRevision = 2
+			So(db.Save(record), ShouldEqual, skydb.ErrRecordConflict)
+		})
+
+		Convey("deletes record", func() {
+			So(db.Save(newNote("1", skydb.Data{"title": "hello"})), ShouldBeNil)
+			So(db.Delete(skydb.NewRecordID("note", "1")), ShouldBeNil)
+			So(db.Delete(skydb.NewRecordID("note", "1")), ShouldEqual, skydb.ErrRecordNotFound)
+		})
+
+		Convey("returns error for unique index violation", func() {
+			So(db.SaveIndex("note", "note_title_key", skydb.Index{Fields: []string{"title"}}), ShouldBeNil)
+			So(db.Save(newNote("1", skydb.Data{"title": "hello"})), ShouldBeNil)
+			err := db.Save(newNote("2", skydb.Data{"title": "hello"}))
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.Duplicated)
+		})
+
+		Convey("queries records", func() {
+			So(db.Save(newNote("1", skydb.Data{"title": "apple", "priority": 3})), ShouldBeNil)
+			So(db.Save(newNote("2", skydb.Data{"title": "banana", "priority": 1})), ShouldBeNil)
+			So(db.Save(newNote("3", skydb.Data{"title": "cherry"})), ShouldBeNil)
+
+			limit := uint64(1)
+			query := skydb.Query{
+				Type: "note",
+				Predicate: skydb.Predicate{
+					Operator: skydb.Like,
+					Children: []interface{}{
+						skydb.Expression{Type: skydb.KeyPath, Value: "title"},
+						skydb.Expression{Type: skydb.Literal, Value: "%an%"},
+					},
+				},
+				Sorts: []skydb.Sort{{
+					Expression: skydb.Expression{Type: skydb.KeyPath, Value: "priority"},
+					Order:      skydb.Descending,
+				}},
+				GetCount:            true,
+				Limit:               &limit,
+				BypassAccessControl: true,
+			}
+			rows, err := db.Query(&query)
+			So(err, ShouldBeNil)
+			records := readAll(rows)
+			So(len(records), ShouldEqual, 1)
+			So(records[0].ID.Key, ShouldEqual, "2")
+			So(*rows.OverallRecordCount(), ShouldEqual, 1)
+
+			query = skydb.Query{
+				Type: "note",
+				Sorts: []skydb.Sort{{
+					Expression: skydb.Expression{Type: skydb.KeyPath, Value: "priority"},
+				}},
+				BypassAccessControl: true,
+			}
+			rows, err = db.Query(&query)
+			So(err, ShouldBeNil)
+			records = readAll(rows)
+			So([]string{records[0].ID.Key, records[1].ID.Key, records[2].ID.Key},
+				ShouldResemble, []string{"2", "1", "3"})
+
+			count, err := db.QueryCount(&query)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+		})
+
+		Convey("queries records with predicates and sorts in SQL", func() {
+			So(db.Save(newNote("1", skydb.Data{"title": "apple", "priority": 3})), ShouldBeNil)
+			So(db.Save(newNote("2", skydb.Data{"title": "banana", "priority": 1})), ShouldBeNil)
+			So(db.Save(newNote("3", skydb.Data{"title": "cherry"})), ShouldBeNil)
+			So(db.Save(newNote("4", skydb.Data{"title": "durian", "priority": 2})), ShouldBeNil)
+
+			keyPath := func(key string) skydb.Expression {
+				return skydb.Expression{Type: skydb.KeyPath, Value: key}
+			}
+			literal := func(value interface{}) skydb.Expression {
+				return skydb.Expression{Type: skydb.Literal, Value: value}
+			}
+			limit := uint64(2)
+			query := skydb.Query{
+				Type: "note",
+				Predicate: skydb.Predicate{
+					Operator: skydb.Not,
+					Children: []interface{}{
+						skydb.Predicate{
+							Operator: skydb.In,
+							Children: []interface{}{
+								keyPath("priority"),
+								literal([]interface{}{float64(3)}),
+							},
+						},
+					},
+				},
+				Sorts: []skydb.Sort{{
+					Expression: keyPath("priority"),
+					Order:      skydb.Descending,
+				}},
+				GetCount:            true,
+				Limit:               &limit,
+				BypassAccessControl: true,
+			}
+
+			sql, args, rest := splitPredicate(query.Predicate, skydb.RecordSchema{
+				"priority": skydb.FieldType{Type: skydb.TypeInteger},
+			})
+			So(sql, ShouldEqual, "NOT COALESCE(json_extract(data, ?) IN (?), 0)")
+			So(args, ShouldResemble, []interface{}{`$."priority"`, float64(3)})
+			So(rest.IsEmpty(), ShouldBeTrue)
+
+			rows, err := db.Query(&query)
+			So(err, ShouldBeNil)
+			records := readAll(rows)
+			So([]string{records[0].ID.Key, records[1].ID.Key}, ShouldResemble, []string{"3", "4"})
+			So(*rows.OverallRecordCount(), ShouldEqual, 3)
+
+			query.Offset = 2
+			rows, err = db.Query(&query)
+			So(err, ShouldBeNil)
+			records = readAll(rows)
+			So(len(records), ShouldEqual, 1)
+			So(records[0].ID.Key, ShouldEqual, "2")
+		})
+
+		Convey("leaves predicates not translated to SQL to Go", func() {
+			predicate := skydb.Predicate{
+				Operator: skydb.And,
+				Children: []interface{}{
+					skydb.Predicate{
+						Operator: skydb.GreaterThan,
+						Children: []interface{}{
+							skydb.Expression{Type: skydb.KeyPath, Value: "priority"},
+							skydb.Expression{Type: skydb.Literal, Value: float64(1)},
+						},
+					},
+					skydb.Predicate{
+						Operator: skydb.Like,
+						Children: []interface{}{
+							skydb.Expression{Type: skydb.KeyPath, Value: "title"},
+							skydb.Expression{Type: skydb.Literal, Value: "%an%"},
+						},
+					},
+				},
+			}
+			sql, args, rest := splitPredicate(predicate, skydb.RecordSchema{
+				"title":    skydb.FieldType{Type: skydb.TypeString},
+				"priority": skydb.FieldType{Type: skydb.TypeInteger},
+			})
+			So(sql, ShouldEqual, "json_extract(data, ?) > ?")
+			So(args, ShouldResemble, []interface{}{`$."priority"`, float64(1)})
+			So(rest, ShouldResemble, predicate.Children[1])
+		})
+
+		Convey("does not query records of other databases", func() {
+			So(db.Save(newNote("1", skydb.Data{"title": "apple"})), ShouldBeNil)
+			rows, err := c.PrivateDB("user0").Query(&skydb.Query{Type: "note"})
+			So(err, ShouldBeNil)
+			So(readAll(rows), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSchema(t *testing.T) {
+	Convey("Schema", t, func() {
+		c := getTestConn(t)
+		db := c.PublicDB()
+
+		Convey("extends and renames schema", func() {
+			extended, err := db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			So(extended, ShouldBeTrue)
+
+			So(db.RenameSchema("note", "title", "name"), ShouldBeNil)
+			schema, err := db.GetSchema("note")
+			So(err, ShouldBeNil)
+			So(schema["name"], ShouldResemble, skydb.FieldType{Type: skydb.TypeString})
+			So(schema, ShouldNotContainKey, "title")
+		})
+
+		Convey("returns nil schema of nonexistent record type", func() {
+			schema, err := db.GetSchema("note")
+			So(err, ShouldBeNil)
+			So(schema, ShouldBeNil)
+		})
+
+		Convey("returns error for conflicting field type", func() {
+			_, err := db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(err, ShouldBeNil)
+			_, err = db.Extend("note", skydb.RecordSchema{
+				"title": skydb.FieldType{Type: skydb.TypeNumber},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.(skyerr.Error).Code(), ShouldEqual, skyerr.IncompatibleSchema)
+		})
+	})
+}
+
+func TestAuth(t *testing.T) {
+	Convey("Auth", t, func() {
+		c := getTestConn(t)
+
+		Convey("creates and gets auth with roles", func() {
+			So(c.SetDefaultRoles([]string{"member"}), ShouldBeNil)
+			authinfo := skydb.AuthInfo{ID: "user0", Roles: []string{"member"}}
+			So(c.CreateAuth(&authinfo), ShouldBeNil)
+			So(c.CreateAuth(&authinfo), ShouldEqual, skydb.ErrUserDuplicated)
+
+			fetched := skydb.AuthInfo{}
+			So(c.GetAuth("user0", &fetched), ShouldBeNil)
+			So(fetched.ID, ShouldEqual, "user0")
+			So(fetched.Roles, ShouldResemble, []string{"member"})
+		})
+
+		Convey("increments counter", func() {
+			value, err := c.IncrementCounter("visits", 2)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 2)
+			value, err = c.IncrementCounter("visits", 3)
+			So(err, ShouldBeNil)
+			So(value, ShouldEqual, 5)
+		})
+	})
+}
@@ -0,0 +1,253 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build sqlite
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+func (c *conn) CreateAuth(authinfo *skydb.AuthInfo) error {
+	providerInfo, err := json.Marshal(authinfo.ProviderInfo)
+	if err != nil {
+		return err
+	}
+
+	return c.withTx(func() error {
+		var exists bool
+		err := c.queryRow("SELECT EXISTS (SELECT 1 FROM _auth WHERE id = ?)", authinfo.ID).Scan(&exists)
+		if err != nil {
+			return err
+		} else if exists {
+			return skydb.ErrUserDuplicated
+		}
+
+		_, err = c.exec(`INSERT INTO _auth (id, password, provider_info, token_valid_since, last_seen_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			authinfo.ID,
+			authinfo.HashedPassword,
+			string(providerInfo),
+			nullableTime(authinfo.TokenValidSince),
+			nullableTime(authinfo.LastSeenAt),
+		)
+		if err != nil {
+			return err
+		}
+
+		if err := c.updatePrincipals(authinfo); err != nil {
+			return err
+		}
+		if err := c.updateUserRoles(authinfo); err != nil {
+			return skydb.ErrRoleUpdatesFailed
+		}
+		return nil
+	})
+}
+
+func (c *conn) UpdateAuth(authinfo *skydb.AuthInfo) error {
+	providerInfo, err := json.Marshal(authinfo.ProviderInfo)
+	if err != nil {
+		return err
+	}
+
+	return c.withTx(func() error {
+		rowsAffected, err := c.execAffected(`UPDATE _auth
+			SET password = ?, provider_info = ?, token_valid_since = ?, last_seen_at = ?
+			WHERE id = ?`,
+			authinfo.HashedPassword,
+			string(providerInfo),
+			nullableTime(authinfo.TokenValidSince),
+			nullableTime(authinfo.LastSeenAt),
+			authinfo.ID,
+		)
+		if err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return skydb.ErrUserNotFound
+		}
+
+		if err := c.updatePrincipals(authinfo); err != nil {
+			return err
+		}
+		if err := c.updateUserRoles(authinfo); err != nil {
+			return skydb.ErrRoleUpdatesFailed
+		}
+		return nil
+	})
+}
+
+// updatePrincipals indexes the principal IDs of the provider info, so
+// that a user can be found by principal ID without JSON functions.
+func (c *conn) updatePrincipals(authinfo *skydb.AuthInfo) error {
+	if _, err := c.exec("DELETE FROM _auth_principal WHERE auth_id = ?", authinfo.ID); err != nil {
+		return err
+	}
+	for principalID := range authinfo.ProviderInfo {
+		_, err := c.exec("INSERT INTO _auth_principal (auth_id, principal_id) VALUES (?, ?)",
+			authinfo.ID, principalID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) GetAuth(id string, authinfo *skydb.AuthInfo) error {
+	return c.getAuth(authinfo, `SELECT id, password, provider_info, token_valid_since, last_seen_at
+		FROM _auth WHERE id = ?`, id)
+}
+
+func (c *conn) GetAuthByPrincipalID(principalID string, authinfo *skydb.AuthInfo) error {
+	return c.getAuth(authinfo, `SELECT id, password, provider_info, token_valid_since, last_seen_at
+		FROM _auth JOIN _auth_principal ON id = auth_id
+		WHERE principal_id = ?`, principalID)
+}
+
+func (c *conn) getAuth(authinfo *skydb.AuthInfo, query string, args ...interface{}) error {
+	var (
+		id              string
+		password        []byte
+		providerInfo    sql.NullString
+		tokenValidSince *time.Time
+		lastSeenAt      *time.Time
+	)
+	err := c.queryRow(query, args...).Scan(
+		&id,
+		&password,
+		&providerInfo,
+		&tokenValidSince,
+		&lastSeenAt,
+	)
+	if err == sql.ErrNoRows {
+		return skydb.ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+
+	authinfo.ID = id
+	authinfo.HashedPassword = password
+	authinfo.ProviderInfo = nil
+	if providerInfo.Valid {
+		if err := json.Unmarshal([]byte(providerInfo.String), &authinfo.ProviderInfo); err != nil {
+			return fmt.Errorf("failed to decode provider info of %s: %s", id, err)
+		}
+	}
+	authinfo.TokenValidSince = utcTime(tokenValidSince)
+	authinfo.LastSeenAt = utcTime(lastSeenAt)
+
+	roles, err := c.GetRoles([]string{id})
+	if err != nil {
+		return err
+	}
+	authinfo.Roles = nil
+	if len(roles[id]) > 0 {
+		authinfo.Roles = roles[id]
+	}
+	return c.setInheritedRoles(authinfo)
+}
+
+func (c *conn) DeleteAuth(id string) error {
+	return c.withTx(func() error {
+		rowsAffected, err := c.execAffected("DELETE FROM _auth WHERE id = ?", id)
+		if err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return skydb.ErrUserNotFound
+		}
+
+		if _, err := c.exec("DELETE FROM _auth_principal WHERE auth_id = ?", id); err != nil {
+			return err
+		}
+		_, err = c.exec("DELETE FROM _auth_role WHERE auth_id = ?", id)
+		return err
+	})
+}
+
+// EnsureAuthRecordKeysExist adds the auth record keys to the user record
+// type as string fields.
+func (c *conn) EnsureAuthRecordKeysExist(authRecordKeys [][]string) error {
+	db := c.PublicDB()
+	schema, err := db.GetSchema(db.UserRecordType())
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve user record schema")
+	}
+
+	schemaToExtend := skydb.RecordSchema{}
+	for _, keys := range authRecordKeys {
+		for _, key := range keys {
+			if _, ok := schema[key]; !ok {
+				schemaToExtend[key] = skydb.FieldType{Type: skydb.TypeString}
+			}
+		}
+	}
+	if len(schemaToExtend) == 0 {
+		return nil
+	}
+
+	_, err = db.Extend(db.UserRecordType(), schemaToExtend)
+	return err
+}
+
+// EnsureAuthRecordKeysIndexesMatch makes each group of auth record keys
+// unique among user records.
+func (c *conn) EnsureAuthRecordKeysIndexesMatch(authRecordKeys [][]string) error {
+	db := c.PublicDB()
+	indexes, err := db.GetIndexesByRecordType(db.UserRecordType())
+	if err != nil {
+		return err
+	}
+
+	for _, keys := range authRecordKeys {
+		name := authRecordKeysIndexName(keys)
+		if _, ok := indexes[name]; ok {
+			continue
+		}
+		if err := db.SaveIndex(db.UserRecordType(), name, skydb.Index{Fields: keys}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func authRecordKeysIndexName(keys []string) string {
+	name := "auth_record_keys_user"
+	for _, key := range keys {
+		name += "_" + key
+	}
+	return name + "_key"
+}
+
+// nullableTime returns nil for a nil or zero time, which is saved as
+// NULL.
+func nullableTime(t *time.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
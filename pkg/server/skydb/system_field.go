@@ -0,0 +1,119 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SystemFieldMask is the rule of serializing a system field of a record
+// to requesters other than the owner of the record.
+type SystemFieldMask string
+
+// The rules of serializing a system field.
+const (
+	// SystemFieldVisible serializes the field as is. This is the default.
+	SystemFieldVisible SystemFieldMask = "visible"
+
+	// SystemFieldHidden removes the field.
+	SystemFieldHidden SystemFieldMask = "hidden"
+
+	// SystemFieldPseudonym replaces the user ID in the field with a
+	// pseudonym, which is the same for the same user in records of the
+	// same type. Requesters can tell whether two records are created by
+	// the same user without knowing who the user is.
+	SystemFieldPseudonym SystemFieldMask = "pseudonym"
+)
+
+// maskableSystemFields are the system fields that can be masked, and
+// whether the field can be masked with a pseudonym.
+var maskableSystemFields = map[string]bool{
+	"_owner_id":   true,
+	"_created_by": true,
+	"_updated_by": true,
+	"_access":     false,
+}
+
+// SystemFieldMasks maps the name of system fields to the masks.
+type SystemFieldMasks map[string]SystemFieldMask
+
+// Validate returns error if a field cannot be masked, or cannot be
+// masked with the specified mask.
+func (masks SystemFieldMasks) Validate() error {
+	for field, mask := range masks {
+		allowPseudonym, ok := maskableSystemFields[field]
+		if !ok {
+			return fmt.Errorf("%s is not a maskable system field", field)
+		}
+
+		switch mask {
+		case SystemFieldVisible, SystemFieldHidden:
+		case SystemFieldPseudonym:
+			if !allowPseudonym {
+				return fmt.Errorf("%s cannot be masked with pseudonym", field)
+			}
+		default:
+			return fmt.Errorf("unknown mask %s of %s", mask, field)
+		}
+	}
+	return nil
+}
+
+// SystemFieldAccess is the setting of a record type on serializing
+// system fields to requesters other than the owner of a record.
+type SystemFieldAccess struct {
+	Masks SystemFieldMasks
+
+	// Salt is the secret of generating pseudonyms of the record type,
+	// so that a pseudonym cannot be reversed by hashing known user IDs.
+	Salt string
+}
+
+// Apply masks the system fields of the record, unless the record is
+// owned by the requester.
+func (access SystemFieldAccess) Apply(authInfo *AuthInfo, record *Record) {
+	if len(access.Masks) == 0 {
+		return
+	}
+	if authInfo != nil && authInfo.ID == record.OwnerID {
+		return
+	}
+
+	record.OwnerID = access.maskUserID(access.Masks["_owner_id"], record.OwnerID)
+	record.CreatorID = access.maskUserID(access.Masks["_created_by"], record.CreatorID)
+	record.UpdaterID = access.maskUserID(access.Masks["_updated_by"], record.UpdaterID)
+	if access.Masks["_access"] == SystemFieldHidden {
+		record.ACL = nil
+	}
+}
+
+func (access SystemFieldAccess) maskUserID(mask SystemFieldMask, userID string) string {
+	if userID == "" {
+		return userID
+	}
+
+	switch mask {
+	case SystemFieldHidden:
+		return ""
+	case SystemFieldPseudonym:
+		mac := hmac.New(sha256.New, []byte(access.Salt))
+		mac.Write([]byte(userID))
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+	return userID
+}
@@ -0,0 +1,99 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSystemFieldAccess(t *testing.T) {
+	Convey("SystemFieldMasks", t, func() {
+		Convey("accepts masks of maskable fields", func() {
+			So(SystemFieldMasks{
+				"_owner_id":   SystemFieldPseudonym,
+				"_created_by": SystemFieldHidden,
+				"_access":     SystemFieldVisible,
+			}.Validate(), ShouldBeNil)
+		})
+
+		Convey("rejects unmaskable field", func() {
+			So(SystemFieldMasks{"_created_at": SystemFieldHidden}.Validate(), ShouldNotBeNil)
+		})
+
+		Convey("rejects pseudonym of access", func() {
+			So(SystemFieldMasks{"_access": SystemFieldPseudonym}.Validate(), ShouldNotBeNil)
+		})
+
+		Convey("rejects unknown mask", func() {
+			So(SystemFieldMasks{"_owner_id": "blurred"}.Validate(), ShouldNotBeNil)
+		})
+	})
+
+	Convey("SystemFieldAccess", t, func() {
+		access := SystemFieldAccess{
+			Masks: SystemFieldMasks{
+				"_owner_id":   SystemFieldPseudonym,
+				"_created_by": SystemFieldPseudonym,
+				"_updated_by": SystemFieldHidden,
+				"_access":     SystemFieldHidden,
+			},
+			Salt: "salt",
+		}
+		newRecord := func() Record {
+			return Record{
+				ID:        NewRecordID("confession", "1"),
+				OwnerID:   "alice",
+				CreatorID: "alice",
+				UpdaterID: "bob",
+				ACL: RecordACL{
+					NewRecordACLEntryPublic(ReadLevel),
+				},
+			}
+		}
+
+		Convey("masks fields to other users", func() {
+			record := newRecord()
+			access.Apply(&AuthInfo{ID: "bob"}, &record)
+			So(record.OwnerID, ShouldNotEqual, "alice")
+			So(record.OwnerID, ShouldHaveLength, 32)
+			So(record.CreatorID, ShouldEqual, record.OwnerID)
+			So(record.UpdaterID, ShouldEqual, "")
+			So(record.ACL, ShouldBeNil)
+		})
+
+		Convey("masks fields to anonymous requester", func() {
+			record := newRecord()
+			access.Apply(nil, &record)
+			So(record.UpdaterID, ShouldEqual, "")
+		})
+
+		Convey("generates pseudonym by salt", func() {
+			record := newRecord()
+			access.Apply(nil, &record)
+
+			otherRecord := newRecord()
+			SystemFieldAccess{Masks: access.Masks, Salt: "pepper"}.Apply(nil, &otherRecord)
+			So(otherRecord.OwnerID, ShouldNotEqual, record.OwnerID)
+		})
+
+		Convey("does not mask fields to owner", func() {
+			record := newRecord()
+			access.Apply(&AuthInfo{ID: "alice"}, &record)
+			So(record, ShouldResemble, newRecord())
+		})
+	})
+}
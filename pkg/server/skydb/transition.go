@@ -0,0 +1,41 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import "time"
+
+// Transition is a change of fields of a record scheduled at a future
+// time, for example setting the status of an article to published at
+// the time of publication.
+type Transition struct {
+	ID       string
+	RecordID RecordID
+
+	// DatabaseID is the ID of the Database containing the record.
+	DatabaseID string
+
+	// Data contains the fields to set on the record, in the same
+	// serialized form as a record in a request.
+	Data map[string]interface{}
+
+	ScheduledAt time.Time
+	CreatedAt   time.Time
+	CreatedBy   string
+
+	// WithMasterKey is true if the transition is scheduled with the
+	// master key. Otherwise the transition is executed with the access
+	// control of the user who scheduled it.
+	WithMasterKey bool
+}
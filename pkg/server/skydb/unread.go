@@ -0,0 +1,30 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+// UnreadCounts maps a channel to the number of unread notifications of a
+// user in that channel. The channel of records fanned out to
+// subscribers is the record type.
+type UnreadCounts map[string]int64
+
+// Total returns the sum of the unread counts of all channels, which is
+// used as the badge number of push notifications.
+func (counts UnreadCounts) Total() int64 {
+	var total int64
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
@@ -0,0 +1,270 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package unsupported implements the skydb features which depend on
+// PostgreSQL for the other backends. Writes return a NotSupported error,
+// while reads return nothing, so that the server can start and serve the
+// supported requests.
+//
+// A backend embeds Conn in its skydb.Conn and Database in its
+// skydb.Database, and implements the features it supports by itself.
+package unsupported
+
+import (
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skyerr"
+)
+
+// Error returns the NotSupported error of a feature of the backend.
+func Error(backend string, feature string) error {
+	return skyerr.NewErrorf(skyerr.NotSupported, "%s is not supported by the %s backend", feature, backend)
+}
+
+// Conn implements the methods of skydb.Conn of the features not
+// supported by the backend.
+type Conn struct {
+	Backend string
+}
+
+func (c Conn) notSupported(feature string) error {
+	return Error(c.Backend, feature)
+}
+
+// Database implements the methods of skydb.Database of the features not
+// supported by the backend.
+type Database struct {
+	Backend string
+}
+
+func (db Database) notSupported(feature string) error {
+	return Error(db.Backend, feature)
+}
+
+func (c Conn) QueryRelation(user string, name string, direction string, config skydb.QueryConfig) []skydb.AuthInfo {
+	return []skydb.AuthInfo{}
+}
+
+func (c Conn) QueryRelationCount(user string, name string, direction string) (uint64, error) {
+	return 0, nil
+}
+
+func (c Conn) AddRelation(user string, name string, targetUser string) error {
+	return c.notSupported("relation")
+}
+
+func (c Conn) RemoveRelation(user string, name string, targetUser string) error {
+	return c.notSupported("relation")
+}
+
+func (c Conn) AcquireLock(recordID skydb.RecordID, ownerID string, ttl time.Duration) (*skydb.Lock, error) {
+	return nil, c.notSupported("record lock")
+}
+
+func (c Conn) RenewLock(recordID skydb.RecordID, ownerID string, ttl time.Duration) (*skydb.Lock, error) {
+	return nil, c.notSupported("record lock")
+}
+
+func (c Conn) ReleaseLock(recordID skydb.RecordID, ownerID string) error {
+	return c.notSupported("record lock")
+}
+
+func (c Conn) GetLock(recordID skydb.RecordID) (*skydb.Lock, error) {
+	return nil, skydb.ErrLockNotFound
+}
+
+func (c Conn) AddAnnotation(annotation *skydb.Annotation) error {
+	return c.notSupported("annotation")
+}
+
+func (c Conn) GetAnnotation(id string) (*skydb.Annotation, error) {
+	return nil, skydb.ErrAnnotationNotFound
+}
+
+func (c Conn) GetAnnotations(recordID skydb.RecordID, offset uint64, limit uint64) ([]skydb.Annotation, error) {
+	return []skydb.Annotation{}, nil
+}
+
+func (c Conn) DeleteAnnotation(id string) error {
+	return skydb.ErrAnnotationNotFound
+}
+
+func (c Conn) AddReaction(reaction *skydb.Reaction) (bool, error) {
+	return false, c.notSupported("reaction")
+}
+
+func (c Conn) RemoveReaction(databaseID string, recordID skydb.RecordID, userID string, reactionType string) (bool, error) {
+	return false, nil
+}
+
+func (c Conn) GetReactionCounts(databaseID string, recordID skydb.RecordID) (skydb.ReactionCounts, error) {
+	return skydb.ReactionCounts{}, nil
+}
+
+func (c Conn) GetUserReactions(databaseID string, recordID skydb.RecordID, userID string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (c Conn) IncrementUnreadCounts(userIDs []string, channel string) error {
+	return c.notSupported("unread count")
+}
+
+func (c Conn) GetUnreadCounts(userID string) (skydb.UnreadCounts, error) {
+	return skydb.UnreadCounts{}, nil
+}
+
+func (c Conn) ResetUnreadCounts(userID string, channel string) error {
+	return nil
+}
+
+func (c Conn) ScrubRecords(recordType string, fields map[string]skydb.ScrubMethod, salt string) (int64, error) {
+	return 0, c.notSupported("scrubbing records")
+}
+
+func (c Conn) ScrubSystemTables(recordTypes map[string]map[string]skydb.ScrubMethod, salt string) (map[string]int64, error) {
+	return nil, c.notSupported("scrubbing records")
+}
+
+func (c Conn) ReplaceURLPrefix(recordType string, fields []string, oldPrefix, newPrefix string) (int64, error) {
+	return 0, c.notSupported("replacing URL prefix")
+}
+
+func (c Conn) RebalancePositions(recordType string, field string, maxLength int) (bool, error) {
+	return false, nil
+}
+
+func (c Conn) ScheduleTransition(transition *skydb.Transition) error {
+	return c.notSupported("scheduled transition")
+}
+
+func (c Conn) GetTransitions(recordID skydb.RecordID) ([]skydb.Transition, error) {
+	return []skydb.Transition{}, nil
+}
+
+func (c Conn) ClaimDueTransitions(before time.Time, lease time.Duration, limit int) ([]skydb.Transition, error) {
+	return []skydb.Transition{}, nil
+}
+
+func (c Conn) DeleteTransition(id string) error {
+	return skydb.ErrTransitionNotFound
+}
+
+func (c Conn) SaveReport(report *skydb.Report) error {
+	return c.notSupported("scheduled report")
+}
+
+func (c Conn) GetReports() ([]skydb.Report, error) {
+	return []skydb.Report{}, nil
+}
+
+func (c Conn) ClaimReportRun(id string, runAt time.Time) (bool, error) {
+	return false, nil
+}
+
+func (c Conn) DeleteReport(id string) error {
+	return skydb.ErrReportNotFound
+}
+
+func (c Conn) GetSecret(name string) (*skydb.Secret, error) {
+	return nil, skydb.ErrSecretNotFound
+}
+
+func (c Conn) GetSecrets() ([]skydb.Secret, error) {
+	return []skydb.Secret{}, nil
+}
+
+func (c Conn) SetSecret(secret *skydb.Secret) error {
+	return c.notSupported("secret")
+}
+
+func (c Conn) DeleteSecret(name string) error {
+	return skydb.ErrSecretNotFound
+}
+
+func (c Conn) CreateServiceAccount(account *skydb.ServiceAccount) error {
+	return c.notSupported("service account")
+}
+
+func (c Conn) GetServiceAccount(id string) (*skydb.ServiceAccount, error) {
+	return nil, skydb.ErrServiceAccountNotFound
+}
+
+func (c Conn) GetServiceAccounts() ([]skydb.ServiceAccount, error) {
+	return []skydb.ServiceAccount{}, nil
+}
+
+func (c Conn) UpdateServiceAccount(account *skydb.ServiceAccount) error {
+	return skydb.ErrServiceAccountNotFound
+}
+
+func (c Conn) DeleteServiceAccount(id string) error {
+	return skydb.ErrServiceAccountNotFound
+}
+
+func (c Conn) GetWebhook(id string) (*skydb.Webhook, error) {
+	return nil, skydb.ErrWebhookNotFound
+}
+
+func (c Conn) GetWebhooks() ([]skydb.Webhook, error) {
+	return []skydb.Webhook{}, nil
+}
+
+func (c Conn) SaveWebhook(webhook *skydb.Webhook) error {
+	return c.notSupported("webhook")
+}
+
+func (c Conn) DeleteWebhook(id string) error {
+	return skydb.ErrWebhookNotFound
+}
+
+func (c Conn) RecordWebhookDelivery(id string, deliveryErr string, at time.Time, failureThreshold int) (bool, error) {
+	return false, skydb.ErrWebhookNotFound
+}
+
+func (db Database) GetSubscription(key string, deviceID string, subscription *skydb.Subscription) error {
+	return skydb.ErrSubscriptionNotFound
+}
+
+func (db Database) SaveSubscription(subscription *skydb.Subscription) error {
+	return db.notSupported("subscription")
+}
+
+func (db Database) DeleteSubscription(key string, deviceID string) error {
+	return skydb.ErrSubscriptionNotFound
+}
+
+func (db Database) GetSubscriptionsByDeviceID(deviceID string) []skydb.Subscription {
+	return nil
+}
+
+func (db Database) GetMatchingSubscriptions(record *skydb.Record) []skydb.Subscription {
+	return nil
+}
+
+func (db Database) FindDuplicates(recordType string, keys []skydb.DuplicateKey, limit int) ([][]skydb.RecordID, error) {
+	return nil, db.notSupported("finding duplicates")
+}
+
+func (db Database) Leaderboard(query skydb.LeaderboardQuery) (*skydb.Leaderboard, error) {
+	return nil, db.notSupported("leaderboard")
+}
+
+func (db Database) MergeRecords(winnerID skydb.RecordID, loserIDs []skydb.RecordID) error {
+	return db.notSupported("merging records")
+}
+
+func (db Database) GetRecordHistory(id skydb.RecordID, limit int) ([]skydb.RecordHistory, error) {
+	return []skydb.RecordHistory{}, nil
+}
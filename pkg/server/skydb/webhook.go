@@ -0,0 +1,98 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook is an outbound subscription of server events, such as record
+// changes, auth events and push notification outcomes. Events are
+// delivered to the URL as HTTP POST requests.
+type Webhook struct {
+	ID  string
+	URL string
+
+	// Secret signs the payload of the delivered events if it is not
+	// empty.
+	Secret string
+
+	// Events are the names of the events delivered to the webhook.
+	// A name ending with `*` matches events with the prefix, such as
+	// `record:*`.
+	Events []string
+
+	// RecordTypes limits the record events delivered to the webhook to
+	// records of the specified types. Record events of all types are
+	// delivered if it is empty.
+	RecordTypes []string
+
+	// Delta is true if record:updated events are delivered with the JSON
+	// merge patch of the record instead of the whole record.
+	Delta bool
+
+	// Paused is true if the webhook is paused by the admin.
+	Paused bool
+
+	// Disabled is true if the webhook is disabled automatically after
+	// sustained delivery failures.
+	Disabled bool
+
+	CreatedAt time.Time
+	Stats     WebhookStats
+}
+
+// WebhookStats is the delivery statistics of a Webhook.
+type WebhookStats struct {
+	Delivered           int
+	Failed              int
+	ConsecutiveFailures int
+	LastDeliveredAt     *time.Time
+	LastFailedAt        *time.Time
+	LastError           string
+}
+
+// Active returns true if events are delivered to the webhook.
+func (w *Webhook) Active() bool {
+	return !w.Paused && !w.Disabled
+}
+
+// Matches returns true if the event of the specified name is delivered
+// to the webhook. recordType is empty for events not about a record.
+func (w *Webhook) Matches(event string, recordType string) bool {
+	if recordType != "" && len(w.RecordTypes) > 0 {
+		found := false
+		for _, t := range w.RecordTypes {
+			if t == recordType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, name := range w.Events {
+		if name == event {
+			return true
+		}
+		if strings.HasSuffix(name, "*") && strings.HasPrefix(event, strings.TrimSuffix(name, "*")) {
+			return true
+		}
+	}
+	return false
+}
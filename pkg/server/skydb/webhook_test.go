@@ -0,0 +1,58 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skydb
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWebhook(t *testing.T) {
+	Convey("Webhook", t, func() {
+		Convey("matches event by name", func() {
+			webhook := Webhook{Events: []string{"auth:signup", "record:*"}}
+			So(webhook.Matches("auth:signup", ""), ShouldBeTrue)
+			So(webhook.Matches("auth:login", ""), ShouldBeFalse)
+			So(webhook.Matches("record:created", "note"), ShouldBeTrue)
+			So(webhook.Matches("push:sent", ""), ShouldBeFalse)
+		})
+
+		Convey("matches all events", func() {
+			webhook := Webhook{Events: []string{"*"}}
+			So(webhook.Matches("push:failed", ""), ShouldBeTrue)
+		})
+
+		Convey("matches record events by record type", func() {
+			webhook := Webhook{
+				Events:      []string{"record:*", "auth:login"},
+				RecordTypes: []string{"note"},
+			}
+			So(webhook.Matches("record:updated", "note"), ShouldBeTrue)
+			So(webhook.Matches("record:updated", "comment"), ShouldBeFalse)
+			So(webhook.Matches("auth:login", ""), ShouldBeTrue)
+		})
+
+		Convey("is inactive when paused or disabled", func() {
+			webhook := Webhook{}
+			So(webhook.Active(), ShouldBeTrue)
+			webhook.Paused = true
+			So(webhook.Active(), ShouldBeFalse)
+			webhook.Paused = false
+			webhook.Disabled = true
+			So(webhook.Active(), ShouldBeFalse)
+		})
+	})
+}
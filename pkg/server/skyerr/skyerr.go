@@ -142,6 +142,28 @@ const (
 	// Examples include referencing a field that is disallowed by Field ACL.
 	RecordQueryDenied
 
+	// RateLimitExceeded is returned when the client has sent more
+	// requests than allowed in a period of time.
+	RateLimitExceeded
+
+	// RecordLocked is returned when the record is locked by another
+	// user.
+	RecordLocked
+
+	// UnderMaintenance is returned when the app is in maintenance mode
+	// and the request modifies data. The request can be retried after
+	// the maintenance.
+	UnderMaintenance
+
+	// RecordConflict is returned when the record has been modified
+	// since the revision the client based its modification on.
+	RecordConflict
+
+	// RecordQueryTooComplex is returned when a record query exceeds the
+	// limits on its cost, such as the number of records to return or
+	// the nesting depth of the predicate.
+	RecordQueryTooComplex
+
 	// Error codes for expected error condition should be placed
 	// above this line.
 )
@@ -0,0 +1,800 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skygear
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+
+	"github.com/skygeario/skygear-server/pkg/server/asset"
+	"github.com/skygeario/skygear-server/pkg/server/audit"
+	"github.com/skygeario/skygear-server/pkg/server/chaos"
+	"github.com/skygeario/skygear-server/pkg/server/httpcache"
+	"github.com/skygeario/skygear-server/pkg/server/plugin"
+	pluginEvent "github.com/skygeario/skygear-server/pkg/server/plugin/event"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	pp "github.com/skygeario/skygear-server/pkg/server/preprocessor"
+	"github.com/skygeario/skygear-server/pkg/server/pubsub"
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/querycache"
+	"github.com/skygeario/skygear-server/pkg/server/querylimit"
+	"github.com/skygeario/skygear-server/pkg/server/ratelimit"
+	"github.com/skygeario/skygear-server/pkg/server/recordutil"
+	"github.com/skygeario/skygear-server/pkg/server/report"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/secret"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/failover"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/offload"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/pq"
+	"github.com/skygeario/skygear-server/pkg/server/subscription"
+	"github.com/skygeario/skygear-server/pkg/server/tenant"
+	"github.com/skygeario/skygear-server/pkg/server/webhook"
+)
+
+func ensureDB(config skyconfig.Configuration, failoverManager *failover.Manager) func() (skydb.Conn, error) {
+	connOpener := func() (skydb.Conn, error) {
+		return skydb.Open(
+			context.Background(),
+			config.DB.ImplName,
+			config.App.Name,
+			config.App.AccessControl,
+			failoverManager.Option(),
+			config.App.DevMode,
+		)
+	}
+
+	// Attempt to open connection to database. Retry for a number of
+	// times before giving up.
+	attempt := 0
+	for {
+		conn, connError := connOpener()
+		if connError == nil {
+			conn.Close()
+			return connOpener
+		}
+
+		attempt++
+		log.Errorf("Failed to start skygear: %v", connError)
+		if attempt >= 5 {
+			log.Fatalf("Failed to start skygear server because connection to database cannot be opened.")
+		}
+
+		log.Info("Retrying in 1 second...")
+		time.Sleep(time.Second * time.Duration(1))
+	}
+}
+
+func initFailover(config skyconfig.Configuration) *failover.Manager {
+	manager := failover.NewManager(config.DB.Option, config.Failover.StandbyOption, pq.ReplicationProber{})
+	if !manager.Enabled() {
+		return manager
+	}
+
+	if config.DB.ImplName != "pq" {
+		log.Fatalf("Failover to standby database is only supported by pq.")
+	}
+
+	manager.AutoFailover = config.Failover.Auto
+	manager.FailureThreshold = config.Failover.FailureThreshold
+	manager.GracePeriod = time.Duration(config.Failover.GracePeriod) * time.Second
+	go manager.Run(time.Duration(config.Failover.CheckInterval) * time.Second)
+	return manager
+}
+
+func initSecretSealer(config skyconfig.Configuration) *secret.Keyring {
+	keys, err := secret.ParseKeys(config.Secret.OldKeys)
+	if err != nil {
+		log.Fatalf("Failed to parse SECRET_OLD_KEYS: %v", err)
+	}
+
+	keyID := config.Secret.KeyID
+	if config.Secret.Key == "" {
+		// Secrets are not sealed by a key derived from the master key,
+		// which is shared with clients and plugins.
+		keyID = ""
+		log.Warnf("SECRET_KEY is not set. Secrets cannot be saved.")
+	} else {
+		key, err := base64.StdEncoding.DecodeString(config.Secret.Key)
+		if err != nil {
+			log.Fatalf("Failed to decode SECRET_KEY: %v", err)
+		}
+		keys[keyID] = key
+
+		// Secrets sealed by the master key before SECRET_KEY was
+		// required are opened, so that they can be resealed by
+		// secret:rotate.
+		if _, ok := keys[secret.LegacyKeyID]; !ok {
+			keys[secret.LegacyKeyID] = secret.LegacyKey(config.App.MasterKey)
+		}
+	}
+
+	keyring, err := secret.NewKeyring(keyID, keys)
+	if err != nil {
+		log.Fatalf("Failed to set up secret keyring: %v", err)
+	}
+	return keyring
+}
+
+func initPasswordHasher(config skyconfig.Configuration) skydb.PasswordHasher {
+	c := config.PasswordHash
+	switch c.Algorithm {
+	case "scrypt":
+		return skydb.ScryptHasher{
+			N: c.ScryptN,
+			R: c.ScryptR,
+			P: c.ScryptP,
+		}
+	case "argon2id":
+		return skydb.Argon2idHasher{
+			Memory:      c.Argon2Memory,
+			Iterations:  c.Argon2Iterations,
+			Parallelism: c.Argon2Parallelism,
+		}
+	case "bcrypt":
+		return skydb.BcryptHasher{Cost: c.BcryptCost}
+	default:
+		return skydb.PreferredPasswordHasher
+	}
+}
+
+// pushSecretNames are the names of the secrets used as push notification
+// credentials when they are not configured.
+var pushSecretNames = []string{
+	"apns_certificate",
+	"apns_private_key",
+	"apns_token_key",
+	"gcm_api_key",
+}
+
+// pluginSecretPrefixes are the prefixes of the names of the secrets
+// passed to plugins, such as the client secrets of OAuth providers and
+// the SMTP password.
+var pluginSecretPrefixes = []string{
+	"oauth_",
+	"smtp_",
+}
+
+// secretReloadInterval is the interval to reload secrets, so that secrets
+// set through another server take effect.
+const secretReloadInterval = time.Minute
+
+func initSecretResolver(connOpener func() (skydb.Conn, error), sealer secret.Sealer) *secret.Resolver {
+	resolver := &secret.Resolver{
+		Sealer:     sealer,
+		ConnOpener: connOpener,
+		Names:      pushSecretNames,
+		Prefixes:   pluginSecretPrefixes,
+	}
+	if err := resolver.Reload(); err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+
+	go resolver.Watch(secretReloadInterval)
+	return resolver
+}
+
+// applyPushSecrets fills in push notification credentials which are not
+// configured with secrets stored in the database.
+func applyPushSecrets(config skyconfig.Configuration, values map[string]string) skyconfig.Configuration {
+	if config.APNS.Enable {
+		if config.APNS.CertConfig.Cert == "" && config.APNS.CertConfig.CertPath == "" {
+			config.APNS.CertConfig.Cert = values["apns_certificate"]
+		}
+		if config.APNS.CertConfig.Key == "" && config.APNS.CertConfig.KeyPath == "" {
+			config.APNS.CertConfig.Key = values["apns_private_key"]
+		}
+		if config.APNS.TokenConfig.Key == "" && config.APNS.TokenConfig.KeyPath == "" {
+			config.APNS.TokenConfig.Key = values["apns_token_key"]
+		}
+	}
+	if config.GCM.Enable && config.GCM.APIKey == "" {
+		config.GCM.APIKey = values["gcm_api_key"]
+	}
+	return config
+}
+
+// pluginSecrets returns the secrets passed to plugins.
+func pluginSecrets(values map[string]string) map[string]string {
+	secrets := map[string]string{}
+	for name, value := range values {
+		for _, prefix := range pluginSecretPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				secrets[name] = value
+				break
+			}
+		}
+	}
+	return secrets
+}
+
+// initSecretsChangedEvent sends the secrets-changed event with the
+// secrets of plugins when they change. The event is sent through the
+// plugin context directly, because the event sender logs the data.
+func initSecretsChangedEvent(pluginContext *plugin.Context, resolver *secret.Resolver) {
+	previous := pluginSecrets(resolver.Values())
+	resolver.OnChange(func(values map[string]string) {
+		secrets := pluginSecrets(values)
+		if reflect.DeepEqual(secrets, previous) {
+			return
+		}
+		previous = secrets
+
+		data, err := json.Marshal(map[string]interface{}{
+			"secrets": secrets,
+		})
+		if err != nil {
+			log.Warnf("Failed to encode changed secrets: %v", err)
+			return
+		}
+		pluginContext.SendEvent("secrets-changed", data, true)
+	})
+}
+
+func initUserAuthRecordKeys(connOpener func() (skydb.Conn, error), authRecordKeys [][]string) {
+	conn, err := connOpener()
+	if err != nil {
+		log.Warnf("Failed to init user auth record keys: %v", err)
+	}
+
+	defer conn.Close()
+
+	if err := conn.EnsureAuthRecordKeysExist(authRecordKeys); err != nil {
+		panic(err)
+	}
+
+	if err := conn.EnsureAuthRecordKeysIndexesMatch(authRecordKeys); err != nil {
+		panic(err)
+	}
+}
+
+func initSchemaMigration(config skyconfig.Configuration, connOpener func() (skydb.Conn, error)) {
+	if !config.DB.MigrateOnStart {
+		return
+	}
+
+	migrations, err := skydb.LoadSchemaMigrations(config.DB.MigrationDir)
+	if err != nil {
+		log.Fatalf("Failed to load schema migrations: %v", err)
+	}
+
+	conn, err := connOpener()
+	if err != nil {
+		log.Fatalf("Failed to open database for schema migrations: %v", err)
+	}
+	defer conn.Close()
+
+	applied, err := skydb.MigrateSchemas(conn.PublicDB(), migrations)
+	for _, migration := range applied {
+		log.Infof(`Applied schema migration "%s" of %s.`, migration.Version, migration.RecordType)
+	}
+	if err != nil {
+		log.Fatalf("Failed to migrate schema: %v", err)
+	}
+}
+
+func initRateLimit(config skyconfig.Configuration) router.Processor {
+	if config.RateLimit.Mode == "" || config.RateLimit.Rate <= 0 {
+		return nil
+	}
+
+	log.Infof("Rate limit of %v requests per second is in %s mode.",
+		config.RateLimit.Rate, config.RateLimit.Mode)
+	return &pp.RateLimitPreprocessor{
+		Limiter:   ratelimit.NewLimiter(config.RateLimit.Rate, config.RateLimit.Burst),
+		MasterKey: config.App.MasterKey,
+		Enforce:   config.RateLimit.Mode == "enforce",
+	}
+}
+
+func initChaos(config skyconfig.Configuration) *chaos.Injector {
+	if !config.Chaos.Enable {
+		return nil
+	}
+
+	log.Warnf("Chaos testing is enabled, faults will be injected into requests. Do not enable it in production.")
+	return &chaos.Injector{
+		Latency:   time.Duration(config.Chaos.Latency) * time.Millisecond,
+		ErrorRate: config.Chaos.ErrorRate,
+		DropRate:  config.Chaos.PluginDropRate,
+		Actions:   config.Chaos.Actions,
+	}
+}
+
+func initQueryCache(config skyconfig.Configuration) *querycache.Cache {
+	var store querycache.Store
+	switch config.QueryCache.ImplName {
+	case "":
+		return nil
+	case "memory":
+		store = querycache.NewMemoryStore()
+	case "redis":
+		store = querycache.NewRedisStore(config.QueryCache.Path)
+	default:
+		panic("unrecognized query cache implementation: " + config.QueryCache.ImplName)
+	}
+	return querycache.New(store, config.App.Name, time.Duration(config.QueryCache.TTL)*time.Second)
+}
+
+// initLambdaCache returns the store of the responses of cacheable
+// lambdas, which is shared with the query cache if it is in redis.
+func initLambdaCache(config skyconfig.Configuration) querycache.Store {
+	if config.QueryCache.ImplName == "redis" {
+		return querycache.NewRedisStore(config.QueryCache.Path)
+	}
+	return querycache.NewMemoryStore()
+}
+
+// initTenants returns the resolver of the tenants in multi-tenant mode, or
+// nil if no tenants are configured.
+func initTenants(config skyconfig.Configuration) *tenant.Resolver {
+	tenants := []tenant.Tenant{}
+	for _, tenantConfig := range config.Tenants {
+		tenants = append(tenants, tenant.Tenant{
+			Name:      tenantConfig.Name,
+			APIKey:    tenantConfig.APIKey,
+			MasterKey: tenantConfig.MasterKey,
+			Hosts:     tenantConfig.Hosts,
+		})
+	}
+	return tenant.NewResolver(tenants)
+}
+
+func initQueryLimits(config skyconfig.Configuration) *querylimit.Limits {
+	recordTypeMaxLimits := map[string]uint64{}
+	for recordType, maxLimit := range config.DB.QueryMaxLimits {
+		recordTypeMaxLimits[recordType] = uint64(maxLimit)
+	}
+	return &querylimit.Limits{
+		MaxLimit:            uint64(config.DB.QueryMaxLimit),
+		RecordTypeMaxLimits: recordTypeMaxLimits,
+		MaxPredicateDepth:   config.DB.QueryMaxPredicateDepth,
+	}
+}
+
+func initOffloader(config skyconfig.Configuration, assetStore asset.Store) *offload.Offloader {
+	if config.AssetStore.OffloadThreshold <= 0 {
+		return nil
+	}
+	return &offload.Offloader{
+		Store:     assetStore,
+		Threshold: config.AssetStore.OffloadThreshold,
+	}
+}
+
+// NewAssetStore returns the asset store configured in config.
+func NewAssetStore(config skyconfig.Configuration) asset.Store {
+	var store asset.Store
+	switch config.AssetStore.ImplName {
+	default:
+		panic("unrecgonized asset store implementation: " + config.AssetStore.ImplName)
+	case "fs":
+		store = asset.NewFileStore(
+			config.AssetStore.FileSystemStore.Path,
+			config.AssetStore.FileSystemStore.URLPrefix,
+			config.AssetStore.FileSystemStore.Secret,
+			config.AssetStore.Public,
+		)
+	case "s3":
+		s3Store, err := asset.NewS3Store(
+			config.AssetStore.S3Store.AccessToken,
+			config.AssetStore.S3Store.SecretToken,
+			config.AssetStore.S3Store.Region,
+			config.AssetStore.S3Store.Bucket,
+			config.AssetStore.S3Store.URLPrefix,
+			config.AssetStore.Public,
+		)
+		if err != nil {
+			panic("failed to initialize asset.S3Store: " + err.Error())
+		}
+		store = s3Store
+	case "cloud":
+		cloudStore, err := asset.NewCloudStore(
+			config.App.Name,
+			config.AssetStore.CloudStore.Host,
+			config.AssetStore.CloudStore.Token,
+			config.AssetStore.CloudStore.PublicPrefix,
+			config.AssetStore.CloudStore.PrivatePrefix,
+			config.AssetStore.Public,
+		)
+		if err != nil {
+			panic("Fail to initialize asset.CloudStore: " + err.Error())
+		}
+		store = cloudStore
+	}
+	return store
+}
+
+func initDevice(config skyconfig.Configuration, connOpener func() (skydb.Conn, error)) {
+	// TODO: Create a device service to check APNs to remove obsolete devices.
+	// The current implementaion deletes pubsub devices if the last registered
+	// time is more than 1 day old.
+	conn, err := connOpener()
+	if err != nil {
+		log.Warnf("Failed to delete outdated devices: %v", err)
+	}
+
+	conn.DeleteEmptyDevicesByTime(time.Now().AddDate(0, 0, -1))
+}
+
+func initPositionRebalance(config skyconfig.Configuration, connOpener func() (skydb.Conn, error), cronjob *cron.Cron) {
+	if config.Position.RebalanceSchedule == "" {
+		return
+	}
+
+	err := cronjob.AddFunc(config.Position.RebalanceSchedule, func() {
+		conn, err := connOpener()
+		if err != nil {
+			log.Warnf("Failed to rebalance positions: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		schemas, err := conn.PublicDB().GetRecordSchemas()
+		if err != nil {
+			log.Warnf("Failed to rebalance positions: %v", err)
+			return
+		}
+
+		for recordType, schema := range schemas {
+			for field, fieldType := range schema {
+				if fieldType.Type != skydb.TypePosition {
+					continue
+				}
+
+				rebalanced, err := conn.RebalancePositions(recordType, field, config.Position.RebalanceLength)
+				if err != nil {
+					log.Warnf("Failed to rebalance positions of %s.%s: %v", recordType, field, err)
+				} else if rebalanced {
+					log.Infof("Rebalanced positions of %s.%s", recordType, field)
+				}
+			}
+		}
+	})
+	if err != nil {
+		log.Fatalf("Invalid POSITION_REBALANCE_SCHEDULE: %v", err)
+	}
+}
+
+func initTransitionExecutor(config skyconfig.Configuration, connOpener func() (skydb.Conn, error), cronjob *cron.Cron, hookRegistry *hook.Registry, assetStore asset.Store) {
+	if config.Transition.Schedule == "" {
+		return
+	}
+
+	err := cronjob.AddFunc(config.Transition.Schedule, func() {
+		conn, err := connOpener()
+		if err != nil {
+			log.Warnf("Failed to execute transitions: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		lease := time.Duration(config.Transition.Lease) * time.Second
+		transitions, err := conn.ClaimDueTransitions(time.Now().UTC(), lease, config.Transition.BatchSize)
+		if err != nil {
+			log.Warnf("Failed to execute transitions: %v", err)
+			return
+		}
+
+		for _, transition := range transitions {
+			accessKey := router.ClientAccessKey
+			if transition.WithMasterKey {
+				accessKey = router.MasterAccessKey
+			}
+			ctx := context.WithValue(context.Background(), router.UserIDContextKey, transition.CreatedBy)
+			ctx = context.WithValue(ctx, router.AccessKeyTypeContextKey, accessKey)
+
+			// A transition failed for a transient reason is kept, and
+			// is claimed again after the lease. Other failed transitions
+			// are not retried, so that they do not block the transitions
+			// scheduled after them.
+			if err := recordutil.ExecuteTransition(ctx, conn, transition, hookRegistry, assetStore); err != nil {
+				log.Warnf("Failed to execute transition %s of %s: %v", transition.ID, transition.RecordID, err)
+				if recordutil.IsTransientTransitionError(err) {
+					continue
+				}
+			}
+			if err := conn.DeleteTransition(transition.ID); err != nil {
+				log.Warnf("Failed to delete transition %s: %v", transition.ID, err)
+			}
+		}
+	})
+	if err != nil {
+		log.Fatalf("Invalid TRANSITION_SCHEDULE: %v", err)
+	}
+}
+
+func initReportRunner(config skyconfig.Configuration, connOpener func() (skydb.Conn, error), cronjob *cron.Cron, assetStore asset.Store, pushSender push.Sender, pluginContext *plugin.Context) {
+	if config.Report.Schedule == "" {
+		return
+	}
+
+	runner := &report.Runner{
+		ConnOpener: connOpener,
+		AssetStore: assetStore,
+		PushSender: pushSender,
+		SendEvent:  pluginContext.SendEvent,
+	}
+	err := cronjob.AddFunc(config.Report.Schedule, func() {
+		runner.RunDue(time.Now().UTC())
+	})
+	if err != nil {
+		log.Fatalf("Invalid REPORT_SCHEDULE: %v", err)
+	}
+}
+
+func initAuditStream(config skyconfig.Configuration) *audit.Stream {
+	client := &http.Client{
+		Timeout: time.Duration(config.Audit.Timeout) * time.Second,
+	}
+
+	sinks := []audit.Sink{}
+	for _, name := range config.AuditSinks() {
+		switch name {
+		case "syslog":
+			sink, err := audit.NewSyslogSink(
+				config.Audit.SyslogNetwork,
+				config.Audit.SyslogAddress,
+				config.Audit.SyslogTag,
+			)
+			if err != nil {
+				log.Fatalf("Failed to connect to syslog: %v", err)
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			sinks = append(sinks, &audit.KafkaSink{
+				URL:    config.Audit.KafkaRESTURL,
+				Topic:  config.Audit.KafkaTopic,
+				Client: client,
+			})
+		case "webhook":
+			sinks = append(sinks, &audit.WebhookSink{
+				URL:    config.Audit.WebhookURL,
+				Secret: config.Audit.WebhookSecret,
+				Client: client,
+			})
+		}
+	}
+
+	stream := audit.NewStream(config.App.Name, config.Audit.QueueSize, sinks...)
+	go stream.Run()
+	return stream
+}
+
+func initWebhookDispatcher(config skyconfig.Configuration, connOpener func() (skydb.Conn, error)) *webhook.Dispatcher {
+	dispatcher := webhook.NewDispatcher(
+		connOpener,
+		time.Duration(config.Webhook.Timeout)*time.Second,
+		config.Webhook.FailureThreshold,
+		config.Webhook.QueueSize,
+	)
+	go dispatcher.Run()
+
+	// Record changes are listened by the master only, so that they are
+	// not delivered more than once.
+	if !config.App.Slave {
+		if err := dispatcher.DispatchRecordEvents(); err != nil {
+			log.Warnf("Failed to dispatch record events to webhooks: %v", err)
+		}
+	}
+	return dispatcher
+}
+
+// initSchemaChangeListener sends the changes to the schema of a record
+// type to plugins and webhooks. Unlike record changes, a schema change is
+// notified by the server making it, so that the columns added implicitly
+// by saves are included.
+func initSchemaChangeListener(sender pluginEvent.Sender, dispatcher *webhook.Dispatcher) {
+	pq.SetSchemaChangeListener(func(change skydb.SchemaChange) {
+		data, err := json.Marshal(change)
+		if err != nil {
+			log.Warnf("Failed to encode schema change of %s: %v", change.RecordType, err)
+			return
+		}
+		sender.Send("record-schema-changed", data, true)
+		dispatcher.Dispatch(webhook.SchemaChanged, change.RecordType, change)
+	})
+}
+
+func initPushSender(config skyconfig.Configuration, connOpener func() (skydb.Conn, error), dispatcher *webhook.Dispatcher, resolver *secret.Resolver) push.Sender {
+	switchSender := &push.SwitchSender{}
+	pushConfig := applyPushSecrets(config, resolver.Values())
+	routeSender, stop, err := newPushRouteSender(pushConfig, connOpener)
+	if err != nil {
+		log.Fatalf("Failed to set up push sender: %v", err)
+	}
+	switchSender.Switch(routeSender, stop)
+
+	// Push notification credentials stored as secrets take effect
+	// without restarting the server. The previous sender is kept if the
+	// changed credentials cannot be used.
+	resolver.OnChange(func(values map[string]string) {
+		nextConfig := applyPushSecrets(config, values)
+		if reflect.DeepEqual(nextConfig.APNS, pushConfig.APNS) && reflect.DeepEqual(nextConfig.GCM, pushConfig.GCM) {
+			return
+		}
+
+		routeSender, stop, err := newPushRouteSender(nextConfig, connOpener)
+		if err != nil {
+			log.Errorf("Failed to reload push sender with changed secrets: %v", err)
+			return
+		}
+		switchSender.Switch(routeSender, stop)
+		pushConfig = nextConfig
+		log.Infof("Reloaded push sender with changed secrets.")
+	})
+
+	var sender push.Sender = switchSender
+	if len(config.Unread.RecordTypes) > 0 {
+		sender = &push.BadgeSender{
+			Sender:     switchSender,
+			ConnOpener: connOpener,
+		}
+	}
+	return &webhook.PushSender{
+		Sender:     sender,
+		Dispatcher: dispatcher,
+	}
+}
+
+// newPushRouteSender returns a sender routing notifications to the
+// services configured in config, with a function stopping the started
+// APNS pusher.
+func newPushRouteSender(config skyconfig.Configuration, connOpener func() (skydb.Conn, error)) (push.RouteSender, func(), error) {
+	routeSender := push.NewRouteSender()
+	stop := func() {}
+	if config.APNS.Enable {
+		apns, err := NewAPNSPusher(config, connOpener)
+		if err != nil {
+			return routeSender, nil, err
+		}
+		go apns.Start()
+		stop = apns.Stop
+		routeSender.Route("aps", apns)
+		routeSender.Route("ios", apns)
+	}
+	if config.GCM.Enable {
+		gcm := NewGCMPusher(config)
+		routeSender.Route("gcm", gcm)
+		routeSender.Route("android", gcm)
+	}
+	return routeSender, stop, nil
+}
+
+// NewAPNSPusher returns the APNS pusher configured in config. The pusher
+// is not started.
+func NewAPNSPusher(config skyconfig.Configuration, connOpener func() (skydb.Conn, error)) (push.APNSPusher, error) {
+	switch config.APNS.Type {
+	case "cert":
+		return newCertBasedAPNSPusher(config, connOpener)
+	case "token":
+		return newTokenBasedAPNSPusher(config, connOpener)
+	default:
+		return nil, fmt.Errorf("Unknown APNS Type: %s", config.APNS.Type)
+	}
+}
+
+func newCertBasedAPNSPusher(
+	config skyconfig.Configuration,
+	connOpener func() (skydb.Conn, error),
+) (push.APNSPusher, error) {
+	cert := config.APNS.CertConfig.Cert
+	key := config.APNS.CertConfig.Key
+	if config.APNS.CertConfig.Cert == "" && config.APNS.CertConfig.CertPath != "" {
+		certPEMBlock, err := ioutil.ReadFile(config.APNS.CertConfig.CertPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the APNS Cert: %v", err)
+		}
+		cert = string(certPEMBlock)
+	}
+
+	if config.APNS.CertConfig.Key == "" && config.APNS.CertConfig.KeyPath != "" {
+		keyPEMBlock, err := ioutil.ReadFile(config.APNS.CertConfig.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the APNS Key: %v", err)
+		}
+		key = string(keyPEMBlock)
+	}
+
+	return push.NewCertBasedAPNSPusher(
+		connOpener,
+		push.GatewayType(config.APNS.Env),
+		cert,
+		key,
+	)
+}
+
+func newTokenBasedAPNSPusher(
+	config skyconfig.Configuration,
+	connOpener func() (skydb.Conn, error),
+) (push.APNSPusher, error) {
+	key := config.APNS.TokenConfig.Key
+	keyPath := config.APNS.TokenConfig.KeyPath
+	if key == "" && keyPath != "" {
+		keyBytes, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load APNS key: %v", err)
+		}
+
+		key = string(keyBytes)
+	}
+
+	return push.NewTokenBasedAPNSPusher(
+		connOpener,
+		push.GatewayType(config.APNS.Env),
+		config.APNS.TokenConfig.TeamID,
+		config.APNS.TokenConfig.KeyID,
+		key,
+	)
+}
+
+// NewGCMPusher returns the GCM pusher configured in config.
+func NewGCMPusher(config skyconfig.Configuration) *push.GCMPusher {
+	return &push.GCMPusher{APIKey: config.GCM.APIKey}
+}
+
+func initSubscription(config skyconfig.Configuration, connOpener func() (skydb.Conn, error), hub *pubsub.Hub, pushSender push.Sender) {
+	notifiers := []subscription.Notifier{subscription.NewHubNotifier(hub)}
+	if pushSender != nil {
+		notifiers = append(notifiers, subscription.NewPushNotifier(pushSender))
+	}
+
+	subscriptionService := &subscription.Service{
+		ConnOpener:        connOpener,
+		Notifier:          subscription.NewMultiNotifier(notifiers...),
+		UnreadRecordTypes: config.Unread.RecordTypes,
+	}
+	log.Infoln("Subscription Service listening...")
+	go subscriptionService.Run()
+}
+
+func initHTTPCacheInvalidator(config skyconfig.Configuration, connOpener func() (skydb.Conn, error)) {
+	if config.HTTPCache.PurgeURL == "" {
+		return
+	}
+
+	invalidator := &httpcache.Invalidator{
+		ConnOpener: connOpener,
+		Purger: &httpcache.HTTPPurger{
+			URL: config.HTTPCache.PurgeURL,
+		},
+	}
+	log.Infoln("HTTP cache invalidator listening...")
+	go invalidator.Run()
+}
+
+func initPlugin(config skyconfig.Configuration, ctx *plugin.Context) {
+	log.Infof("Supported plugin transports: %s", strings.Join(plugin.SupportedTransports(), ", "))
+
+	if ctx.Scheduler != nil {
+		ctx.Scheduler.Start()
+	}
+
+	for _, pluginConfig := range config.Plugin {
+		ctx.AddPluginConfiguration(pluginConfig.Transport, pluginConfig.Path, pluginConfig.Args)
+	}
+
+	ctx.InitPlugins()
+}
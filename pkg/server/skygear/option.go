@@ -0,0 +1,96 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skygear
+
+import (
+	"net/http"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+)
+
+// Option customizes the server constructed by New.
+type Option func(*options)
+
+type options struct {
+	handlers      []mappedHandler
+	preprocessors map[string]router.Processor
+	middlewares   []patternMiddlewares
+	services      map[string]interface{}
+	httpHandlers  map[string]http.Handler
+}
+
+type mappedHandler struct {
+	action  string
+	handler router.Handler
+}
+
+type patternMiddlewares struct {
+	pattern     string
+	middlewares []router.Middleware
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		preprocessors: map[string]router.Processor{},
+		services:      map[string]interface{}{},
+		httpHandlers:  map[string]http.Handler{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithHandler maps the action to the handler. The handler is injected
+// with services and preprocessors as the built-in handlers are, and
+// replaces the built-in handler of the same action.
+func WithHandler(action string, handler router.Handler) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, mappedHandler{action, handler})
+	}
+}
+
+// WithPreprocessor registers the preprocessor under the name, so that
+// handlers can refer to it in the `preprocessor` struct tag. It replaces
+// the built-in preprocessor of the same name.
+func WithPreprocessor(name string, preprocessor router.Processor) Option {
+	return func(o *options) {
+		o.preprocessors[name] = preprocessor
+	}
+}
+
+// WithMiddleware adds middlewares to the actions matching the pattern.
+// See router.Router.Use for the syntax of the pattern.
+func WithMiddleware(pattern string, middlewares ...router.Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, patternMiddlewares{pattern, middlewares})
+	}
+}
+
+// WithService provides the value to handlers under the name, so that
+// handlers can refer to it in the `inject` struct tag.
+func WithService(name string, value interface{}) Option {
+	return func(o *options) {
+		o.services[name] = value
+	}
+}
+
+// WithHTTPHandler serves requests to the URL path pattern with the
+// http.Handler, alongside the Skygear API.
+func WithHTTPHandler(pattern string, handler http.Handler) Option {
+	return func(o *options) {
+		o.httpHandlers[pattern] = handler
+	}
+}
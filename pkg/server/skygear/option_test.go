@@ -0,0 +1,81 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skygear
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type nopHandler struct{}
+
+func (h *nopHandler) Setup() {}
+
+func (h *nopHandler) GetPreprocessors() []router.Processor {
+	return nil
+}
+
+func (h *nopHandler) Handle(payload *router.Payload, response *router.Response) {}
+
+type nopProcessor struct{}
+
+func (p *nopProcessor) Preprocess(payload *router.Payload, response *router.Response) int {
+	return http.StatusOK
+}
+
+func TestOptions(t *testing.T) {
+	Convey("newOptions", t, func() {
+		Convey("collects handlers in order", func() {
+			h1 := &nopHandler{}
+			h2 := &nopHandler{}
+			o := newOptions(
+				WithHandler("custom:one", h1),
+				WithHandler("custom:two", h2),
+			)
+			So(o.handlers, ShouldResemble, []mappedHandler{
+				{"custom:one", h1},
+				{"custom:two", h2},
+			})
+		})
+
+		Convey("replaces preprocessors and services of the same name", func() {
+			p := &nopProcessor{}
+			o := newOptions(
+				WithPreprocessor("custom", &nopProcessor{}),
+				WithPreprocessor("custom", p),
+				WithService("Greeting", "hello"),
+				WithService("Greeting", "hi"),
+			)
+			So(o.preprocessors["custom"], ShouldEqual, p)
+			So(o.services, ShouldResemble, map[string]interface{}{
+				"Greeting": "hi",
+			})
+		})
+
+		Convey("collects middlewares and http handlers", func() {
+			o := newOptions(
+				WithMiddleware("record:*", router.ProcessorMiddleware(&nopProcessor{})),
+				WithHTTPHandler("/custom/", http.NotFoundHandler()),
+			)
+			So(o.middlewares, ShouldHaveLength, 1)
+			So(o.middlewares[0].pattern, ShouldEqual, "record:*")
+			So(o.middlewares[0].middlewares, ShouldHaveLength, 1)
+			So(o.httpHandlers, ShouldContainKey, "/custom/")
+		})
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skygear
+
+import (
+	"github.com/skygeario/skygear-server/pkg/server/handler"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+)
+
+// mapHandlers maps the built-in actions of Skygear Server to their handlers.
+func mapHandlers(r *router.Router, injector *router.HandlerInjector) {
+	r.Map("", &handler.HomeHandler{})
+	r.Map("_status:healthz", injector.Inject(&handler.HealthzHandler{}))
+	r.Map("_status:failover", injector.Inject(&handler.FailoverHandler{}))
+	r.Map("secret:set", injector.Inject(&handler.SecretSetHandler{}))
+	r.Map("secret:get", injector.Inject(&handler.SecretGetHandler{}))
+	r.Map("secret:list", injector.Inject(&handler.SecretListHandler{}))
+	r.Map("secret:delete", injector.Inject(&handler.SecretDeleteHandler{}))
+	r.Map("secret:rotate", injector.Inject(&handler.SecretRotateHandler{}))
+	r.Map("authz:export", injector.Inject(&handler.AuthzExportHandler{}))
+	r.Map("authz:import", injector.Inject(&handler.AuthzImportHandler{}))
+	r.Map("maintenance:get", injector.Inject(&handler.MaintenanceGetHandler{}))
+	r.Map("maintenance:set", injector.Inject(&handler.MaintenanceSetHandler{}))
+	r.Map("webhook:create", injector.Inject(&handler.WebhookCreateHandler{}))
+	r.Map("webhook:list", injector.Inject(&handler.WebhookListHandler{}))
+	r.Map("webhook:pause", injector.Inject(&handler.WebhookPauseHandler{}))
+	r.Map("webhook:resume", injector.Inject(&handler.WebhookResumeHandler{}))
+	r.Map("webhook:delete", injector.Inject(&handler.WebhookDeleteHandler{}))
+
+	r.Map("service_account:create", injector.Inject(&handler.ServiceAccountCreateHandler{}))
+	r.Map("service_account:list", injector.Inject(&handler.ServiceAccountListHandler{}))
+	r.Map("service_account:rotate", injector.Inject(&handler.ServiceAccountRotateHandler{}))
+	r.Map("service_account:delete", injector.Inject(&handler.ServiceAccountDeleteHandler{}))
+
+	r.Map("auth:signup", injector.Inject(&handler.SignupHandler{}))
+	r.Map("auth:login", injector.Inject(&handler.LoginHandler{}))
+	r.Map("auth:logout", injector.Inject(&handler.LogoutHandler{}))
+	r.Map("auth:password", injector.Inject(&handler.PasswordHandler{}))
+	r.Map("auth:service_account:token", injector.Inject(&handler.ServiceAccountTokenHandler{}))
+	r.Map("user:import", injector.Inject(&handler.UserImportHandler{}))
+
+	r.Map("asset:put", injector.Inject(&handler.AssetUploadHandler{}))
+	r.Map("asset:bulk", injector.Inject(&handler.AssetBulkHandler{}))
+	r.Map("asset:bulk:status", injector.Inject(&handler.AssetBulkStatusHandler{}))
+
+	r.Map("record:fetch", injector.Inject(&handler.RecordFetchHandler{}))
+	r.Map("record:query", injector.Inject(&handler.RecordQueryHandler{}))
+	r.Map("query:validate", injector.Inject(&handler.QueryValidateHandler{}))
+	r.Map("record:save", injector.Inject(&handler.RecordSaveHandler{}))
+	r.Map("record:delete", injector.Inject(&handler.RecordDeleteHandler{}))
+	r.Map("transaction:begin", injector.Inject(&handler.TransactionBeginHandler{}))
+	r.Map("transaction:commit", injector.Inject(&handler.TransactionCommitHandler{}))
+	r.Map("transaction:rollback", injector.Inject(&handler.TransactionRollbackHandler{}))
+	r.Map("record:lock", injector.Inject(&handler.RecordLockHandler{}))
+	r.Map("record:lock:renew", injector.Inject(&handler.RecordLockRenewHandler{}))
+	r.Map("record:lock:release", injector.Inject(&handler.RecordLockReleaseHandler{}))
+	r.Map("record:lock:get", injector.Inject(&handler.RecordLockGetHandler{}))
+	r.Map("record:history", injector.Inject(&handler.RecordHistoryHandler{}))
+	r.Map("record:position", injector.Inject(&handler.PositionBetweenHandler{}))
+	r.Map("record:duplicates", injector.Inject(&handler.RecordDuplicatesHandler{}))
+	r.Map("record:merge", injector.Inject(&handler.RecordMergeHandler{}))
+	r.Map("record:leaderboard", injector.Inject(&handler.RecordLeaderboardHandler{}))
+	r.Map("record:snapshot", injector.Inject(&handler.RecordSnapshotHandler{}))
+	r.Map("record:snapshot:restore", injector.Inject(&handler.RecordSnapshotRestoreHandler{}))
+	r.Map("record:transition:schedule", injector.Inject(&handler.TransitionScheduleHandler{}))
+	r.Map("record:transition:list", injector.Inject(&handler.TransitionListHandler{}))
+	r.Map("record:transition:cancel", injector.Inject(&handler.TransitionCancelHandler{}))
+	r.Map("report:save", injector.Inject(&handler.ReportSaveHandler{}))
+	r.Map("report:list", injector.Inject(&handler.ReportListHandler{}))
+	r.Map("report:delete", injector.Inject(&handler.ReportDeleteHandler{}))
+	r.Map("record:annotation:add", injector.Inject(&handler.AnnotationAddHandler{}))
+	r.Map("record:annotation:list", injector.Inject(&handler.AnnotationListHandler{}))
+	r.Map("record:annotation:remove", injector.Inject(&handler.AnnotationRemoveHandler{}))
+	r.Map("record:react", injector.Inject(&handler.ReactHandler{}))
+	r.Map("record:unreact", injector.Inject(&handler.UnreactHandler{}))
+	r.Map("record:reactions", injector.Inject(&handler.ReactionListHandler{}))
+
+	r.Map("device:register", injector.Inject(&handler.DeviceRegisterHandler{}))
+	r.Map("device:unregister", injector.Inject(&handler.DeviceUnregisterHandler{}))
+
+	// subscription shares the same set of preprocessor as record read at the moment
+	r.Map("subscription:fetch_all", injector.Inject(&handler.SubscriptionFetchAllHandler{}))
+	r.Map("subscription:fetch", injector.Inject(&handler.SubscriptionFetchHandler{}))
+	r.Map("subscription:save", injector.Inject(&handler.SubscriptionSaveHandler{}))
+	r.Map("subscription:delete", injector.Inject(&handler.SubscriptionDeleteHandler{}))
+
+	r.Map("unread:fetch", injector.Inject(&handler.UnreadFetchHandler{}))
+	r.Map("unread:reset", injector.Inject(&handler.UnreadResetHandler{}))
+
+	// relation shares the same setof preprocessor
+	r.Map("relation:query", injector.Inject(&handler.RelationQueryHandler{}))
+	r.Map("relation:add", injector.Inject(&handler.RelationAddHandler{}))
+	r.Map("relation:remove", injector.Inject(&handler.RelationRemoveHandler{}))
+
+	r.Map("counter:increment", injector.Inject(&handler.CounterIncrementHandler{}))
+	r.Map("counter:get", injector.Inject(&handler.CounterGetHandler{}))
+
+	r.Map("me", injector.Inject(&handler.MeHandler{}))
+
+	r.Map("role:default", injector.Inject(&handler.RoleDefaultHandler{}))
+	r.Map("role:admin", injector.Inject(&handler.RoleAdminHandler{}))
+	r.Map("role:assign", injector.Inject(&handler.RoleAssignHandler{}))
+	r.Map("role:revoke", injector.Inject(&handler.RoleRevokeHandler{}))
+	r.Map("role:get", injector.Inject(&handler.RoleGetHandler{}))
+	r.Map("role:inherit", injector.Inject(&handler.RoleInheritHandler{}))
+	r.Map("role:hierarchy", injector.Inject(&handler.RoleHierarchyHandler{}))
+
+	r.Map("push:user", injector.Inject(&handler.PushToUserHandler{}))
+	r.Map("push:device", injector.Inject(&handler.PushToDeviceHandler{}))
+
+	r.Map("schema:rename", injector.Inject(&handler.SchemaRenameHandler{}))
+	r.Map("schema:delete", injector.Inject(&handler.SchemaDeleteHandler{}))
+	r.Map("schema:create", injector.Inject(&handler.SchemaCreateHandler{}))
+	r.Map("schema:fetch", injector.Inject(&handler.SchemaFetchHandler{}))
+	r.Map("schema:access", injector.Inject(&handler.SchemaAccessHandler{}))
+	r.Map("schema:default_access", injector.Inject(&handler.SchemaDefaultAccessHandler{}))
+	r.Map("schema:system_field_access", injector.Inject(&handler.SchemaSystemFieldAccessHandler{}))
+	r.Map("schema:strict", injector.Inject(&handler.SchemaStrictHandler{}))
+	r.Map("schema:field_access:get", injector.Inject(&handler.SchemaFieldAccessGetHandler{}))
+	r.Map("schema:field_access:update", injector.Inject(&handler.SchemaFieldAccessUpdateHandler{}))
+
+	r.Map("index:create", injector.Inject(&handler.IndexCreateHandler{}))
+	r.Map("index:drop", injector.Inject(&handler.IndexDropHandler{}))
+	r.Map("index:fetch", injector.Inject(&handler.IndexFetchHandler{}))
+
+	r.Map("stats:record", injector.Inject(&handler.RecordStatsHandler{}))
+	r.Map("stats:query_watchdog", injector.Inject(&handler.QueryWatchdogStatsHandler{}))
+}
@@ -0,0 +1,472 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package skygear constructs Skygear Server from a configuration, so that
+// the server can be started by Go programs other than the skygear-server
+// command, such as integration tests and binaries with custom handlers.
+//
+// The database drivers and other services of Skygear Server are configured
+// through package-level settings, so only one server should be constructed
+// in a process.
+package skygear
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/facebookgo/inject"
+	"github.com/robfig/cron"
+	"golang.org/x/net/trace"
+
+	"github.com/skygeario/skygear-server/pkg/server/assetjob"
+	"github.com/skygeario/skygear-server/pkg/server/authtoken"
+	"github.com/skygeario/skygear-server/pkg/server/handler"
+	"github.com/skygeario/skygear-server/pkg/server/httpcache"
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/plugin"
+	pluginEvent "github.com/skygeario/skygear-server/pkg/server/plugin/event"
+	_ "github.com/skygeario/skygear-server/pkg/server/plugin/exec"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/hook"
+	_ "github.com/skygeario/skygear-server/pkg/server/plugin/http"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/provider"
+	"github.com/skygeario/skygear-server/pkg/server/plugin/transaction"
+	_ "github.com/skygeario/skygear-server/pkg/server/plugin/zmq"
+	pp "github.com/skygeario/skygear-server/pkg/server/preprocessor"
+	"github.com/skygeario/skygear-server/pkg/server/pubsub"
+	"github.com/skygeario/skygear-server/pkg/server/querywatchdog"
+	"github.com/skygeario/skygear-server/pkg/server/recordstats"
+	"github.com/skygeario/skygear-server/pkg/server/router"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	_ "github.com/skygeario/skygear-server/pkg/server/skydb/mongo"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/pq"
+	_ "github.com/skygeario/skygear-server/pkg/server/skydb/sqlite"
+)
+
+var log = logging.LoggerEntry("skygear")
+
+// Server is a Skygear Server constructed by New.
+type Server struct {
+	Config        skyconfig.Configuration
+	Router        *router.Router
+	PluginContext *plugin.Context
+
+	handler http.Handler
+}
+
+// New constructs a Skygear Server from the configuration. It connects to
+// the database and starts the background services, but plugins are not
+// initialized and requests are not served until Start or ListenAndServe
+// is called.
+func New(config skyconfig.Configuration, opts ...Option) (*Server, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts...)
+
+	pq.SetStatementCacheSize(config.DB.StatementCacheSize)
+	pq.SetReplicaOptions(config.DB.ReplicaOptions)
+	pq.SetPoolConfig(pq.PoolConfig{
+		MaxOpenConns:     config.DB.MaxOpenConns,
+		MaxIdleConns:     config.DB.MaxIdleConns,
+		ConnMaxLifetime:  time.Duration(config.DB.ConnMaxLifetime) * time.Second,
+		StatementTimeout: time.Duration(config.DB.StatementTimeout) * time.Second,
+	})
+	pq.SetRetryPolicy(pq.RetryPolicy{
+		MaxRetries:     config.DB.MaxRetries,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	})
+	queryWatchdog := querywatchdog.New(time.Duration(config.DB.QueryCeiling) * time.Second)
+	pq.SetQueryWatchdog(queryWatchdog)
+	pq.SetHistoryRecordTypes(config.DB.HistoryRecordTypes)
+	pq.SetSchemaLimits(config.DB.MaxRecordTypes, config.DB.MaxColumnsPerType)
+	pq.SetMaxQueryJoins(config.DB.QueryMaxJoins)
+	pq.SetQueryCache(initQueryCache(config))
+	failoverManager := initFailover(config)
+	connOpener := ensureDB(config, failoverManager) // Fatal on DB failed
+
+	initUserAuthRecordKeys(connOpener, config.App.AuthRecordKeys)
+	if !config.App.Slave {
+		initSchemaMigration(config, connOpener)
+	}
+	skydb.PreferredPasswordHasher = initPasswordHasher(config)
+	secretSealer := initSecretSealer(config)
+	secretResolver := initSecretResolver(connOpener, secretSealer)
+
+	if config.App.Slave {
+		log.Infof("Skygear Server is running in slave mode.")
+	}
+
+	// Init all the services
+	r := router.NewRouter()
+	r.ResponseTimeout = time.Duration(config.App.ResponseTimeout) * time.Second
+	if rateLimit := initRateLimit(config); rateLimit != nil {
+		r.Preprocessors = []router.Processor{rateLimit}
+	}
+	chaosInjector := initChaos(config)
+	if chaosInjector != nil {
+		r.Preprocessors = append(r.Preprocessors, &pp.ChaosPreprocessor{
+			Injector: chaosInjector,
+		})
+	}
+	serveMux := http.NewServeMux()
+	webhookDispatcher := initWebhookDispatcher(config, connOpener)
+	auditStream := initAuditStream(config)
+	pushSender := initPushSender(config, connOpener, webhookDispatcher, secretResolver)
+
+	tokenStore := authtoken.InitTokenStore(authtoken.Configuration{
+		Implementation: config.TokenStore.ImplName,
+		Path:           config.TokenStore.Path,
+		Prefix:         config.TokenStore.Prefix,
+		Expiry:         config.TokenStore.Expiry,
+		Secret:         config.TokenStore.Secret,
+	})
+
+	preprocessorRegistry := router.PreprocessorRegistry{}
+
+	var cronjob *cron.Cron
+	if !config.App.Slave {
+		cronjob = cron.New()
+	}
+	pluginContext := plugin.Context{
+		Router:           r,
+		Mux:              serveMux,
+		Preprocessors:    preprocessorRegistry,
+		HookRegistry:     hook.NewRegistry(),
+		ProviderRegistry: provider.NewRegistry(),
+		Scheduler:        cronjob,
+		Config:           config,
+		Chaos:            chaosInjector,
+		LambdaCache:      initLambdaCache(config),
+		Secrets: func() map[string]string {
+			return pluginSecrets(secretResolver.Values())
+		},
+	}
+	initSecretsChangedEvent(&pluginContext, secretResolver)
+	pluginEventSender := pluginEvent.NewSender(&pluginContext)
+	initSchemaChangeListener(pluginEventSender, webhookDispatcher)
+
+	// pubSubHub serves the public pubsub, including the private channel
+	// of each user, which handlers publish to with PublishToUser.
+	pubSubHub := pubsub.NewHub()
+
+	var internalHub *pubsub.Hub
+	if !config.App.Slave {
+		internalHub = pubsub.NewHub()
+		initSubscription(config, connOpener, internalHub, pushSender)
+		initHTTPCacheInvalidator(config, connOpener)
+		initDevice(config, connOpener)
+		initPositionRebalance(config, connOpener, cronjob)
+	}
+
+	assetStore := NewAssetStore(config)
+	if !config.App.Slave {
+		initTransitionExecutor(config, connOpener, cronjob, pluginContext.HookRegistry, assetStore)
+		initReportRunner(config, connOpener, cronjob, assetStore, pushSender, &pluginContext)
+	}
+
+	// Preprocessor
+	preprocessorRegistry["notification"] = &pp.NotificationPreprocessor{
+		NotificationSender: pushSender,
+	}
+	tenants := initTenants(config)
+	preprocessorRegistry["accesskey"] = &pp.AccessKeyValidationPreprocessor{
+		ClientKey: config.App.APIKey,
+		MasterKey: config.App.MasterKey,
+		AppName:   config.App.Name,
+		Tenants:   tenants,
+	}
+	preprocessorRegistry["authenticator"] = &pp.UserAuthenticator{
+		ClientKey:  config.App.APIKey,
+		MasterKey:  config.App.MasterKey,
+		AppName:    config.App.Name,
+		TokenStore: tokenStore,
+		Tenants:    tenants,
+	}
+	transactions := transaction.NewRegistry()
+	preprocessorRegistry["dbconn"] = &pp.ConnPreprocessor{
+		AppName:       config.App.Name,
+		AccessControl: config.App.AccessControl,
+		DBOpener:      skydb.Open,
+		DBImpl:        config.DB.ImplName,
+		Option:        config.DB.Option,
+		DevMode:       config.App.DevMode,
+		Offloader:     initOffloader(config, assetStore),
+		Failover:      failoverManager,
+	}
+	preprocessorRegistry["plugin_ready"] = &pp.EnsurePluginReadyPreprocessor{
+		PluginContext: &pluginContext,
+		ClientKey:     config.App.APIKey,
+		MasterKey:     config.App.MasterKey,
+	}
+	preprocessorRegistry["inject_auth"] = &pp.InjectAuthIfPresent{}
+	preprocessorRegistry["inject_user"] = &pp.InjectUserIfPresent{}
+	preprocessorRegistry["require_auth"] = &pp.RequireAuth{}
+	preprocessorRegistry["require_admin"] = &pp.RequireAdminOrMasterKey{}
+	preprocessorRegistry["require_master_key"] = &pp.RequireMasterKey{}
+	preprocessorRegistry["inject_db"] = &pp.InjectDatabase{}
+	preprocessorRegistry["inject_public_db"] = &pp.InjectPublicDatabase{}
+	preprocessorRegistry["maintenance"] = &pp.MaintenancePreprocessor{}
+	preprocessorRegistry["dev_only"] = &pp.DevOnlyProcessor{
+		DevMode: config.App.DevMode,
+	}
+	for name, preprocessor := range o.preprocessors {
+		preprocessorRegistry[name] = preprocessor
+	}
+
+	recordStats := recordstats.NewCollector()
+	expvar.Publish("record_stats", recordStats)
+	expvar.Publish("query_watchdog", queryWatchdog)
+
+	g := &inject.Graph{}
+	injectErr := g.Provide(
+		&inject.Object{
+			Value:    pluginContext.ProviderRegistry,
+			Complete: true,
+			Name:     "ProviderRegistry",
+		},
+		&inject.Object{
+			Value:    pluginContext.HookRegistry,
+			Complete: true,
+			Name:     "HookRegistry",
+		},
+		&inject.Object{
+			Value:    tokenStore,
+			Complete: true,
+			Name:     "TokenStore",
+		},
+		&inject.Object{
+			Value:    assetStore,
+			Complete: true,
+			Name:     "AssetStore",
+		},
+		&inject.Object{
+			Value:    pushSender,
+			Complete: true,
+			Name:     "PushSender",
+		},
+		&inject.Object{
+			Value:    pluginEventSender,
+			Complete: true,
+			Name:     "PluginEventSender",
+		},
+		&inject.Object{
+			Value:    skydb.GetAccessModel(config.App.AccessControl),
+			Complete: true,
+			Name:     "AccessModel",
+		},
+		&inject.Object{
+			Value:    &httpcache.Policy{MaxAge: config.HTTPCache.MaxAge},
+			Complete: true,
+			Name:     "CachePolicy",
+		},
+		&inject.Object{
+			Value:    config.App.AuthRecordKeys,
+			Complete: true,
+			Name:     "AuthRecordKeys",
+		},
+		&inject.Object{
+			Value:    config.App.RevisionPolicy,
+			Complete: true,
+			Name:     "RecordRevisionPolicy",
+		},
+		&inject.Object{
+			Value:    config.App.DeviceTokenPolicy,
+			Complete: true,
+			Name:     "DeviceTokenPolicy",
+		},
+		&inject.Object{
+			Value:    recordStats,
+			Complete: true,
+			Name:     "RecordStats",
+		},
+		&inject.Object{
+			Value:    failoverManager,
+			Complete: true,
+			Name:     "Failover",
+		},
+		&inject.Object{
+			Value:    queryWatchdog,
+			Complete: true,
+			Name:     "QueryWatchdog",
+		},
+		&inject.Object{
+			Value:    secretSealer,
+			Complete: true,
+			Name:     "SecretSealer",
+		},
+		&inject.Object{
+			Value:    secretResolver,
+			Complete: true,
+			Name:     "SecretResolver",
+		},
+		&inject.Object{
+			Value:    webhookDispatcher,
+			Complete: true,
+			Name:     "WebhookDispatcher",
+		},
+		&inject.Object{
+			Value:    auditStream,
+			Complete: true,
+			Name:     "AuditStream",
+		},
+		&inject.Object{
+			Value:    pubSubHub,
+			Complete: true,
+			Name:     "PubSubHub",
+		},
+		&inject.Object{
+			Value:    initQueryLimits(config),
+			Complete: true,
+			Name:     "QueryLimits",
+		},
+		&inject.Object{
+			Value:    assetjob.NewRunner(connOpener, assetStore),
+			Complete: true,
+			Name:     "AssetJobs",
+		},
+		&inject.Object{
+			Value:    transactions,
+			Complete: true,
+			Name:     "TransactionRegistry",
+		},
+	)
+	if injectErr != nil {
+		return nil, fmt.Errorf("unable to set up handler: %v", injectErr)
+	}
+	for name, value := range o.services {
+		if err := g.Provide(&inject.Object{
+			Value:    value,
+			Complete: true,
+			Name:     name,
+		}); err != nil {
+			return nil, fmt.Errorf("unable to provide service %s: %v", name, err)
+		}
+	}
+
+	injector := router.HandlerInjector{
+		ServiceGraph:    g,
+		PreprocessorMap: &preprocessorRegistry,
+	}
+
+	mapHandlers(r, &injector)
+	r.Use("*", transaction.Middleware(transactions))
+	for _, h := range o.handlers {
+		r.Map(h.action, injector.Inject(h.handler))
+	}
+	for _, m := range o.middlewares {
+		r.Use(m.pattern, m.middlewares...)
+	}
+
+	serveMux.Handle("/", r)
+
+	// Traces of requests and push notifications are served to local
+	// clients only, as decided by trace.AuthRequest.
+	serveMux.HandleFunc("/debug/requests", trace.Traces)
+
+	// Following section is for Gateway
+	if !config.App.Slave {
+		pubSub := pubsub.NewWsPubsub(pubSubHub)
+		pubSubGateway := router.NewGateway("", "/pubsub", serveMux)
+		pubSubGateway.GET(injector.InjectProcessors(&handler.PubSubHandler{
+			WebSocket: pubSub,
+		}))
+
+		internalPubSub := pubsub.NewWsPubsub(internalHub)
+		internalPubSubGateway := router.NewGateway("", "/_/pubsub", serveMux)
+		internalPubSubGateway.GET(injector.InjectProcessors(&handler.PubSubHandler{
+			WebSocket: internalPubSub,
+		}))
+	}
+
+	for pattern, h := range o.httpHandlers {
+		serveMux.Handle(pattern, h)
+	}
+
+	fileGateway := router.NewGateway("files/(.+)", "/files/", serveMux)
+	fileGateway.ResponseTimeout = time.Duration(config.App.ResponseTimeout) * time.Second
+	fileGateway.GET(injector.Inject(&handler.GetFileHandler{}))
+
+	uploadFileHandler := injector.Inject(&handler.UploadFileHandler{})
+	fileGateway.PUT(uploadFileHandler)
+	fileGateway.POST(uploadFileHandler)
+
+	recordImportGateway := router.NewGateway("record/import", "/record/import", serveMux)
+	recordImportGateway.POST(injector.Inject(&handler.RecordImportHandler{}))
+
+	corsHost := config.App.CORSHost
+
+	var finalMux http.Handler
+	if corsHost != "" {
+		finalMux = &router.CORSMiddleware{
+			Origin: corsHost,
+			Next:   serveMux,
+		}
+	} else {
+		finalMux = serveMux
+	}
+
+	if config.LOG.Level == "debug" {
+		loggingMiddleware := &router.LoggingMiddleware{
+			Skips: []string{
+				"/files/",
+				"/_/pubsub/",
+				"/pubsub/",
+			},
+			MimeConcern: []string{
+				"",
+				"application/json",
+			},
+			Next: finalMux,
+		}
+
+		if config.LOG.RouterByteLimit > 0 {
+			var limit int
+			limit = int(config.LOG.RouterByteLimit)
+			loggingMiddleware.ByteLimit = &limit
+		}
+
+		finalMux = loggingMiddleware
+	}
+
+	return &Server{
+		Config:        config,
+		Router:        r,
+		PluginContext: &pluginContext,
+		handler:       finalMux,
+	}, nil
+}
+
+// Handler returns the http.Handler serving the Skygear API, assets and
+// pubsub of the server.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Start starts the scheduler and initializes the plugins of the server.
+func (s *Server) Start() {
+	initPlugin(s.Config, s.PluginContext)
+}
+
+// ListenAndServe starts the server and serves requests on the configured
+// host. It always returns a non-nil error.
+func (s *Server) ListenAndServe() error {
+	s.Start()
+
+	log.Printf("Listening on %v...", s.Config.HTTP.Host)
+	return http.ListenAndServe(s.Config.HTTP.Host, s.handler)
+}
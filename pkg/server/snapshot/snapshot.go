@@ -0,0 +1,232 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot takes a snapshot of the records, schema and indexes of
+// a record type, and restores such a snapshot to the same or another app.
+//
+// Assets referenced by the records are not part of the snapshot. Records
+// of the record type created after the snapshot is taken are left
+// unchanged when the snapshot is restored.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+)
+
+var timeNow = func() time.Time { return time.Now().UTC() }
+
+// Snapshot is the records, schema and indexes of a record type.
+type Snapshot struct {
+	RecordType string           `json:"record_type"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Schema     []Field          `json:"schema"`
+	Indexes    map[string]Index `json:"indexes"`
+	Records    []Record         `json:"records"`
+}
+
+// Field is a field in the schema of the record type.
+type Field struct {
+	Name     string      `json:"name"`
+	TypeName string      `json:"type"`
+	Required bool        `json:"required,omitempty"`
+	Default  interface{} `json:"default,omitempty"`
+}
+
+// Index is an index on fields of the record type.
+type Index struct {
+	Fields []string          `json:"fields"`
+	Method skydb.IndexMethod `json:"method"`
+	Unique bool              `json:"unique"`
+}
+
+// Record is a record of the record type. Unlike skyconv.JSONRecord, the
+// owner, creation, update and deletion metadata of the record are
+// restored when unmarshalled.
+type Record skydb.Record
+
+// MarshalJSON implements json.Marshaler
+func (record Record) MarshalJSON() ([]byte, error) {
+	return (*skyconv.JSONRecord)(&record).MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (record *Record) UnmarshalJSON(data []byte) error {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	var (
+		r   skydb.Record
+		err error
+	)
+	r.OwnerID, _ = m["_ownerID"].(string)
+	r.CreatorID, _ = m["_created_by"].(string)
+	r.UpdaterID, _ = m["_updated_by"].(string)
+	if r.CreatedAt, err = parseTime(m, "_created_at"); err != nil {
+		return err
+	}
+	if r.UpdatedAt, err = parseTime(m, "_updated_at"); err != nil {
+		return err
+	}
+	if deletedAt, err := parseTime(m, "_deleted_at"); err != nil {
+		return err
+	} else if !deletedAt.IsZero() {
+		r.DeletedAt = &deletedAt
+	}
+
+	if err := (*skyconv.JSONRecord)(&r).FromMap(m); err != nil {
+		return err
+	}
+	r.Revision = 0
+
+	*record = Record(r)
+	return nil
+}
+
+func parseTime(m map[string]interface{}, key string) (time.Time, error) {
+	value, ok := m[key]
+	if !ok {
+		return time.Time{}, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("key %s is not a time: %v", key, value)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("key %s is not a time: %v", key, value)
+	}
+	return t, nil
+}
+
+// Take takes a snapshot of the record type from db, including the
+// soft-deleted records.
+func Take(db skydb.Database, recordType string) (*Snapshot, error) {
+	schema, err := db.GetSchema(recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := db.ListIndexes(recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := Snapshot{
+		RecordType: recordType,
+		CreatedAt:  timeNow(),
+		Schema:     []Field{},
+		Indexes:    map[string]Index{},
+		Records:    []Record{},
+	}
+	for name, fieldType := range schema {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		snapshot.Schema = append(snapshot.Schema, Field{
+			Name:     name,
+			TypeName: fieldType.ToSimpleName(),
+			Required: fieldType.Required,
+			Default:  fieldType.Default,
+		})
+	}
+	sort.Slice(snapshot.Schema, func(i, j int) bool {
+		return snapshot.Schema[i].Name < snapshot.Schema[j].Name
+	})
+	for name, index := range indexes {
+		snapshot.Indexes[name] = Index{
+			Fields: index.Fields,
+			Method: index.Method,
+			Unique: index.Unique,
+		}
+	}
+
+	rows, err := db.Query(&skydb.Query{
+		Type: recordType,
+		Sorts: []skydb.Sort{{
+			Expression: skydb.Expression{Type: skydb.KeyPath, Value: "_created_at"},
+			Order:      skydb.Ascending,
+		}},
+		IncludeDeleted:      true,
+		BypassAccessControl: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Scan() {
+		snapshot.Records = append(snapshot.Records, Record(rows.Record()))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Restore restores the snapshot to db. The schema of the record type is
+// extended with the fields of the snapshot, indexes not existing in db
+// are created, and the records of the snapshot are saved, replacing the
+// records of the same ID.
+func Restore(db skydb.Database, snapshot *Snapshot) error {
+	schema := skydb.RecordSchema{}
+	for _, field := range snapshot.Schema {
+		fieldType, err := skydb.SimpleNameToFieldType(field.TypeName)
+		if err != nil {
+			return fmt.Errorf("snapshot: field %s has unexpected type %s", field.Name, field.TypeName)
+		}
+		fieldType.Required = field.Required
+		fieldType.Default = field.Default
+		schema[field.Name] = fieldType
+	}
+	if _, err := db.Extend(snapshot.RecordType, schema); err != nil {
+		return err
+	}
+
+	indexes, err := db.ListIndexes(snapshot.RecordType)
+	if err != nil {
+		return err
+	}
+	for name, index := range snapshot.Indexes {
+		if _, ok := indexes[name]; ok {
+			continue
+		}
+		if err := db.CreateIndex(snapshot.RecordType, name, skydb.RecordIndex{
+			Fields: index.Fields,
+			Method: index.Method,
+			Unique: index.Unique,
+		}); err != nil {
+			return err
+		}
+	}
+
+	for i := range snapshot.Records {
+		record := skydb.Record(snapshot.Records[i])
+		if record.ID.Type != snapshot.RecordType {
+			return fmt.Errorf("snapshot: record %s is not of record type %s", record.ID, snapshot.RecordType)
+		}
+		if err := db.Save(&record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skydbtest"
+)
+
+type snapshotDB struct {
+	*skydbtest.MapDB
+	indexes map[string]skydb.RecordIndex
+}
+
+func newSnapshotDB() *snapshotDB {
+	return &snapshotDB{
+		MapDB:   skydbtest.NewMapDB(),
+		indexes: map[string]skydb.RecordIndex{},
+	}
+}
+
+func (db *snapshotDB) Query(query *skydb.Query) (*skydb.Rows, error) {
+	records := []skydb.Record{}
+	for _, record := range db.RecordMap {
+		if record.ID.Type == query.Type {
+			records = append(records, record)
+		}
+	}
+	return skydb.NewRows(skydb.NewMemoryRows(records)), nil
+}
+
+func (db *snapshotDB) ListIndexes(recordType string) (map[string]skydb.RecordIndex, error) {
+	return db.indexes, nil
+}
+
+func (db *snapshotDB) CreateIndex(recordType, indexName string, index skydb.RecordIndex) error {
+	db.indexes[indexName] = index
+	return nil
+}
+
+func TestSnapshot(t *testing.T) {
+	Convey("Snapshot", t, func() {
+		createdAt := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		deletedAt := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return deletedAt }
+		defer func() {
+			timeNow = func() time.Time { return time.Now().UTC() }
+		}()
+
+		source := newSnapshotDB()
+		source.Extend("note", skydb.RecordSchema{
+			"title":   skydb.FieldType{Type: skydb.TypeString, Required: true},
+			"content": skydb.FieldType{Type: skydb.TypeString},
+		})
+		source.indexes["note_title_idx"] = skydb.RecordIndex{
+			Fields: []string{"title"},
+			Method: skydb.BTreeIndex,
+			Unique: true,
+		}
+		So(source.Save(&skydb.Record{
+			ID:        skydb.NewRecordID("note", "1"),
+			OwnerID:   "alice",
+			CreatorID: "alice",
+			CreatedAt: createdAt,
+			UpdaterID: "bob",
+			UpdatedAt: createdAt,
+			ACL: skydb.RecordACL{
+				skydb.NewRecordACLEntryDirect("bob", skydb.WriteLevel),
+			},
+			Data: map[string]interface{}{
+				"title": "Hello",
+			},
+		}), ShouldBeNil)
+		So(source.Save(&skydb.Record{
+			ID:        skydb.NewRecordID("note", "2"),
+			OwnerID:   "alice",
+			CreatedAt: createdAt,
+			DeletedAt: &deletedAt,
+			Data: map[string]interface{}{
+				"title": "Deleted",
+			},
+		}), ShouldBeNil)
+
+		snapshot, err := Take(source, "note")
+		So(err, ShouldBeNil)
+		So(snapshot.RecordType, ShouldEqual, "note")
+		So(snapshot.CreatedAt, ShouldResemble, deletedAt)
+		So(snapshot.Schema, ShouldResemble, []Field{
+			{Name: "content", TypeName: "string"},
+			{Name: "title", TypeName: "string", Required: true},
+		})
+		So(snapshot.Indexes, ShouldResemble, map[string]Index{
+			"note_title_idx": {Fields: []string{"title"}, Method: skydb.BTreeIndex, Unique: true},
+		})
+		So(snapshot.Records, ShouldHaveLength, 2)
+
+		Convey("restores snapshot from JSON", func() {
+			data, err := json.Marshal(snapshot)
+			So(err, ShouldBeNil)
+
+			restored := Snapshot{}
+			So(json.Unmarshal(data, &restored), ShouldBeNil)
+
+			target := newSnapshotDB()
+			So(Restore(target, &restored), ShouldBeNil)
+
+			schema, err := target.GetSchema("note")
+			So(err, ShouldBeNil)
+			So(schema, ShouldResemble, skydb.RecordSchema{
+				"title":   skydb.FieldType{Type: skydb.TypeString, Required: true},
+				"content": skydb.FieldType{Type: skydb.TypeString},
+			})
+			So(target.indexes, ShouldResemble, map[string]skydb.RecordIndex{
+				"note_title_idx": {Fields: []string{"title"}, Method: skydb.BTreeIndex, Unique: true},
+			})
+
+			record := skydb.Record{}
+			So(target.Get(skydb.NewRecordID("note", "1"), &record), ShouldBeNil)
+			So(record, ShouldResemble, skydb.Record{
+				ID:        skydb.NewRecordID("note", "1"),
+				OwnerID:   "alice",
+				CreatorID: "alice",
+				CreatedAt: createdAt,
+				UpdaterID: "bob",
+				UpdatedAt: createdAt,
+				ACL: skydb.RecordACL{
+					skydb.NewRecordACLEntryDirect("bob", skydb.WriteLevel),
+				},
+				Data: map[string]interface{}{
+					"title": "Hello",
+				},
+			})
+
+			So(target.Get(skydb.NewRecordID("note", "2"), &record), ShouldBeNil)
+			So(record.DeletedAt, ShouldResemble, &deletedAt)
+		})
+
+		Convey("keeps existing index", func() {
+			target := newSnapshotDB()
+			target.indexes["note_title_idx"] = skydb.RecordIndex{
+				Fields: []string{"title"},
+				Method: skydb.BTreeIndex,
+			}
+			So(Restore(target, snapshot), ShouldBeNil)
+			So(target.indexes["note_title_idx"].Unique, ShouldBeFalse)
+		})
+
+		Convey("rejects record of another record type", func() {
+			snapshot.Records = append(snapshot.Records, Record{
+				ID:      skydb.NewRecordID("article", "1"),
+				OwnerID: "alice",
+			})
+			So(Restore(newSnapshotDB(), snapshot), ShouldNotBeNil)
+		})
+	})
+}
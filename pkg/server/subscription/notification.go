@@ -15,6 +15,7 @@
 package subscription
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -61,16 +62,20 @@ func (notifier *pushNotifier) CanNotify(device skydb.Device) bool {
 
 func (notifier *pushNotifier) Notify(device skydb.Device, notice Notice) error {
 	customMap := map[string]interface{}{
-		"aps": map[string]interface{}{
-			"content_available": 1,
-		},
-		"_skygear": map[string]interface{}{
-			"seq-num":         notice.SeqNum,
-			"subscription-id": notice.SubscriptionID,
+		"apns": map[string]interface{}{
+			"aps": map[string]interface{}{
+				"content-available": 1,
+			},
+			"_skygear": map[string]interface{}{
+				"seq-num":         notice.SeqNum,
+				"subscription-id": notice.SubscriptionID,
+			},
 		},
 	}
 
-	return notifier.sender.Send(push.MapMapper(customMap), device)
+	// Notices are sent on record changes rather than on behalf of a
+	// request, so there is no request context to cancel the sending.
+	return notifier.sender.Send(context.Background(), push.MapMapper(customMap), device)
 }
 
 type hubNotifier pubsub.Hub
@@ -25,10 +25,15 @@ var timeNow = time.Now
 
 // Service is responsible to send push notification to device whenever
 // a record has been modified in db.
+//
+// When a record of one of the UnreadRecordTypes is created, the unread
+// count of the record type is incremented for each user notified of it,
+// before the notices are sent.
 type Service struct {
-	ConnOpener func() (skydb.Conn, error)
-	Notifier   Notifier
-	stop       chan struct{}
+	ConnOpener        func() (skydb.Conn, error)
+	Notifier          Notifier
+	UnreadRecordTypes []string
+	stop              chan struct{}
 }
 
 // Run listens for Conn record event
@@ -99,15 +104,22 @@ func (s *Service) subscribe() chan skydb.RecordEvent {
 
 func (s *Service) handleRecordHook(db skydb.Database, e skydb.RecordEvent, seqNum uint64) {
 	subscriptions := db.GetMatchingSubscriptions(e.Record)
-	device := skydb.Device{}
-	for _, subscription := range subscriptions {
+	conn := db.Conn()
+	devices := make([]skydb.Device, len(subscriptions))
+	for i, subscription := range subscriptions {
 		log.Printf("subscription: got a matching sub id = %s", subscription.ID)
 
-		conn := db.Conn()
-		if err := conn.GetDevice(subscription.DeviceID, &device); err != nil {
+		if err := conn.GetDevice(subscription.DeviceID, &devices[i]); err != nil {
 			log.Panicf("subscription: failed to get device with id = %v: %v", subscription.DeviceID, err)
 		}
+	}
+
+	if e.Event == skydb.RecordCreated && s.isUnreadRecordType(e.Record.ID.Type) {
+		s.incrementUnreadCounts(conn, e.Record, devices)
+	}
 
+	for i, subscription := range subscriptions {
+		device := devices[i]
 		notice := Notice{seqNum, subscription.ID, e.Event, e.Record}
 		if err := s.Notifier.Notify(device, notice); err != nil {
 			log.Errorf("subscription: failed to send notice to device id = %s", device.ID)
@@ -115,6 +127,38 @@ func (s *Service) handleRecordHook(db skydb.Database, e skydb.RecordEvent, seqNu
 	}
 }
 
+func (s *Service) isUnreadRecordType(recordType string) bool {
+	for _, unreadRecordType := range s.UnreadRecordTypes {
+		if unreadRecordType == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// incrementUnreadCounts increments the unread counts of the owners of
+// the devices, except the creator of the record. A user is counted once
+// no matter how many of the devices are owned by the user.
+func (s *Service) incrementUnreadCounts(conn skydb.Conn, record *skydb.Record, devices []skydb.Device) {
+	userIDs := []string{}
+	seen := map[string]bool{}
+	for _, device := range devices {
+		userID := device.AuthInfoID
+		if userID == "" || userID == record.CreatorID || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := conn.IncrementUnreadCounts(userIDs, record.ID.Type); err != nil {
+		log.WithFields(logrus.Fields{
+			"record": record.ID,
+			"err":    err,
+		}).Errorln("subscription: failed to increment unread counts")
+	}
+}
+
 func getDB(conn skydb.Conn, record *skydb.Record) skydb.Database {
 	if record.DatabaseID == "" {
 		return conn.PublicDB()
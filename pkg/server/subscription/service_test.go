@@ -149,5 +149,56 @@ func TestService(t *testing.T) {
 			<-done
 			So(n.SeqNum, ShouldEqual, 0x43b940e60000000)
 		})
+
+		Convey("increments unread counts of notified users", func() {
+			service.UnreadRecordTypes = []string{"message"}
+
+			message := skydb.Record{
+				ID:        skydb.NewRecordID("message", "0"),
+				CreatorID: "alice",
+			}
+			db.EXPECT().GetMatchingSubscriptions(&message).Return([]skydb.Subscription{
+				{ID: "sub1", DeviceID: "bobdevice1"},
+				{ID: "sub2", DeviceID: "bobdevice2"},
+				{ID: "sub3", DeviceID: "alicedevice"},
+			})
+			conn.EXPECT().GetDevice("bobdevice1", gomock.Any()).
+				SetArg(1, skydb.Device{ID: "bobdevice1", AuthInfoID: "bob"}).
+				Return(nil)
+			conn.EXPECT().GetDevice("bobdevice2", gomock.Any()).
+				SetArg(1, skydb.Device{ID: "bobdevice2", AuthInfoID: "bob"}).
+				Return(nil)
+			conn.EXPECT().GetDevice("alicedevice", gomock.Any()).
+				SetArg(1, skydb.Device{ID: "alicedevice", AuthInfoID: "alice"}).
+				Return(nil)
+
+			var incremented bool
+			conn.EXPECT().IncrementUnreadCounts([]string{"bob"}, "message").
+				Do(func(userIDs []string, channel string) { incremented = true }).
+				Return(nil)
+
+			done := make(chan bool, 3)
+			service.Notifier = notifyFunc(func(device skydb.Device, notice Notice) error {
+				done <- incremented
+				return nil
+			})
+
+			ch <- skydb.RecordEvent{Record: &message, Event: skydb.RecordCreated}
+			for i := 0; i < 3; i++ {
+				So(<-done, ShouldBeTrue)
+			}
+
+			Convey("but not on update", func() {
+				db.EXPECT().GetMatchingSubscriptions(&message).Return([]skydb.Subscription{
+					{ID: "sub1", DeviceID: "bobdevice1"},
+				})
+				conn.EXPECT().GetDevice("bobdevice1", gomock.Any()).
+					SetArg(1, skydb.Device{ID: "bobdevice1", AuthInfoID: "bob"}).
+					Return(nil)
+
+				ch <- skydb.RecordEvent{Record: &message, Event: skydb.RecordUpdated}
+				<-done
+			})
+		})
 	})
 }
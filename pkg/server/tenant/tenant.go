@@ -0,0 +1,118 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tenant resolves the tenants of requests in multi-tenant mode,
+// in which a server serves multiple apps. Each tenant is an app with its
+// own keys, database schema, pubsub channels and asset names.
+package tenant
+
+import (
+	"net"
+	"strings"
+)
+
+// Tenant is an app served in multi-tenant mode.
+type Tenant struct {
+	// Name is the app name of the tenant, which names its database
+	// schema.
+	Name      string
+	APIKey    string
+	MasterKey string
+
+	// Hosts are the hostnames of the requests of the tenant, which is
+	// resolved from the host if the request has no API key.
+	Hosts []string
+}
+
+// Resolver resolves the tenant of requests. A nil Resolver resolves no
+// tenants, i.e. the server is in single-tenant mode.
+type Resolver struct {
+	byName map[string]*Tenant
+	byKey  map[string]*Tenant
+	byHost map[string]*Tenant
+}
+
+// NewResolver returns a Resolver of the tenants. It returns nil if there
+// is no tenant.
+func NewResolver(tenants []Tenant) *Resolver {
+	if len(tenants) == 0 {
+		return nil
+	}
+
+	r := &Resolver{
+		byName: map[string]*Tenant{},
+		byKey:  map[string]*Tenant{},
+		byHost: map[string]*Tenant{},
+	}
+	for i := range tenants {
+		t := &tenants[i]
+		r.byName[t.Name] = t
+		r.byKey[t.APIKey] = t
+		r.byKey[t.MasterKey] = t
+		for _, host := range t.Hosts {
+			r.byHost[strings.ToLower(host)] = t
+		}
+	}
+	return r
+}
+
+// Enabled returns whether the server is in multi-tenant mode.
+func (r *Resolver) Enabled() bool {
+	return r != nil
+}
+
+// Get returns the tenant of the name.
+func (r *Resolver) Get(name string) (*Tenant, bool) {
+	if r == nil {
+		return nil, false
+	}
+	t, ok := r.byName[name]
+	return t, ok
+}
+
+// Resolve returns the tenant of a request with the API key and host. The
+// tenant is resolved from the API key if it is not empty, otherwise from
+// the host, with the port ignored.
+func (r *Resolver) Resolve(apiKey string, host string) (*Tenant, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	if apiKey != "" {
+		t, ok := r.byKey[apiKey]
+		return t, ok
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	t, ok := r.byHost[strings.ToLower(host)]
+	return t, ok
+}
+
+// AssetName returns the name of an asset of the tenant in the asset
+// store, which is prefixed with the tenant name. The name is returned
+// as is if tenant is empty.
+func AssetName(tenant string, name string) string {
+	if tenant == "" {
+		return name
+	}
+	return tenant + "/" + name
+}
+
+// OwnsAsset returns whether the asset of the name in the asset store
+// belongs to the tenant. All assets belong to an empty tenant.
+func OwnsAsset(tenant string, name string) bool {
+	return tenant == "" || strings.HasPrefix(name, tenant+"/")
+}
@@ -0,0 +1,93 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tenant
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestResolver(t *testing.T) {
+	Convey("Resolver", t, func() {
+		r := NewResolver([]Tenant{
+			{
+				Name:      "acme",
+				APIKey:    "acme-api-key",
+				MasterKey: "acme-master-key",
+				Hosts:     []string{"acme.example.com"},
+			},
+			{
+				Name:      "globex",
+				APIKey:    "globex-api-key",
+				MasterKey: "globex-master-key",
+			},
+		})
+		So(r.Enabled(), ShouldBeTrue)
+
+		Convey("resolves tenant by keys", func() {
+			tenant, ok := r.Resolve("acme-api-key", "")
+			So(ok, ShouldBeTrue)
+			So(tenant.Name, ShouldEqual, "acme")
+
+			tenant, ok = r.Resolve("globex-master-key", "acme.example.com")
+			So(ok, ShouldBeTrue)
+			So(tenant.Name, ShouldEqual, "globex")
+
+			_, ok = r.Resolve("unknown-key", "acme.example.com")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("resolves tenant by host without api key", func() {
+			tenant, ok := r.Resolve("", "ACME.example.com:3000")
+			So(ok, ShouldBeTrue)
+			So(tenant.Name, ShouldEqual, "acme")
+
+			_, ok = r.Resolve("", "globex.example.com")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("gets tenant by name", func() {
+			tenant, ok := r.Get("globex")
+			So(ok, ShouldBeTrue)
+			So(tenant.APIKey, ShouldEqual, "globex-api-key")
+
+			_, ok = r.Get("initech")
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("nil Resolver is single-tenant", t, func() {
+		r := NewResolver(nil)
+		So(r.Enabled(), ShouldBeFalse)
+
+		_, ok := r.Resolve("api-key", "example.com")
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestAssetName(t *testing.T) {
+	Convey("AssetName", t, func() {
+		So(AssetName("acme", "uuid-cat.jpg"), ShouldEqual, "acme/uuid-cat.jpg")
+		So(AssetName("", "uuid-cat.jpg"), ShouldEqual, "uuid-cat.jpg")
+	})
+
+	Convey("OwnsAsset", t, func() {
+		So(OwnsAsset("acme", "acme/uuid-cat.jpg"), ShouldBeTrue)
+		So(OwnsAsset("acme", "globex/uuid-cat.jpg"), ShouldBeFalse)
+		So(OwnsAsset("acme", "uuid-cat.jpg"), ShouldBeFalse)
+		So(OwnsAsset("", "globex/uuid-cat.jpg"), ShouldBeTrue)
+	})
+}
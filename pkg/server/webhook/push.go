@@ -0,0 +1,49 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+// PushSender is a push.Sender dispatching the outcome of the
+// notifications sent by the wrapped Sender.
+type PushSender struct {
+	push.Sender
+	Dispatcher *Dispatcher
+}
+
+// Send sends the notification by the wrapped Sender, and dispatches
+// a push:sent or push:failed event.
+func (s *PushSender) Send(ctx context.Context, m push.Mapper, device skydb.Device) error {
+	err := s.Sender.Send(ctx, m, device)
+
+	data := map[string]interface{}{
+		"device_id": device.ID,
+		"user_id":   device.AuthInfoID,
+		"type":      device.Type,
+		"topic":     device.Topic,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+		s.Dispatcher.Dispatch(PushFailed, "", data)
+	} else {
+		s.Dispatcher.Dispatch(PushSent, "", data)
+	}
+	return err
+}
@@ -0,0 +1,295 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook delivers server events to the webhooks subscribed by
+// the admin, such as record changes, auth events and push notification
+// outcomes.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/skygeario/skygear-server/pkg/server/logging"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	"github.com/skygeario/skygear-server/pkg/server/skydb/skyconv"
+	"github.com/skygeario/skygear-server/pkg/server/uuid"
+)
+
+var log = logging.LoggerEntry("webhook")
+
+var timeNow = time.Now
+
+// Names of the events delivered to webhooks.
+const (
+	RecordCreated   = "record:created"
+	RecordUpdated   = "record:updated"
+	RecordDeleted   = "record:deleted"
+	AuthSignup      = "auth:signup"
+	AuthLogin       = "auth:login"
+	PushSent        = "push:sent"
+	PushFailed      = "push:failed"
+	ReactionAdded   = "reaction:added"
+	ReactionRemoved = "reaction:removed"
+	SchemaChanged   = "schema:changed"
+)
+
+// Events are the names of all events delivered to webhooks.
+var Events = []string{
+	RecordCreated,
+	RecordUpdated,
+	RecordDeleted,
+	AuthSignup,
+	AuthLogin,
+	PushSent,
+	PushFailed,
+	ReactionAdded,
+	ReactionRemoved,
+	SchemaChanged,
+}
+
+// SignatureHeader is the header of the HMAC-SHA256 signature of the
+// payload, signed with the secret of the webhook.
+const SignatureHeader = "X-Skygear-Webhook-Signature"
+
+// Event is the payload delivered to webhooks.
+type Event struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+
+	// recordType is the record type of a record, reaction or schema
+	// event, which is used to filter the webhooks.
+	recordType string
+
+	// delta is the data delivered to webhooks of record deltas instead
+	// of Data, which is nil if the event has no delta.
+	delta interface{}
+}
+
+// Dispatcher queues events and delivers them to the matching webhooks.
+//
+// Deliveries are recorded in the delivery statistics of the webhooks,
+// and a webhook is disabled after failing consecutively for
+// FailureThreshold times.
+type Dispatcher struct {
+	ConnOpener       func() (skydb.Conn, error)
+	Client           *http.Client
+	FailureThreshold int
+	events           chan Event
+}
+
+// NewDispatcher returns a Dispatcher queuing at most queueSize events.
+func NewDispatcher(connOpener func() (skydb.Conn, error), timeout time.Duration, failureThreshold int, queueSize int) *Dispatcher {
+	return &Dispatcher{
+		ConnOpener:       connOpener,
+		Client:           &http.Client{Timeout: timeout},
+		FailureThreshold: failureThreshold,
+		events:           make(chan Event, queueSize),
+	}
+}
+
+// Dispatch queues an event to be delivered. recordType is empty for
+// events not about a record. The event is dropped if the queue is full.
+//
+// It is a no-op on a nil Dispatcher.
+func (d *Dispatcher) Dispatch(name string, recordType string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	d.queue(newEvent(name, recordType, data))
+}
+
+func newEvent(name string, recordType string, data interface{}) Event {
+	return Event{
+		ID:         uuid.New(),
+		Name:       name,
+		Timestamp:  timeNow().UTC(),
+		Data:       data,
+		recordType: recordType,
+	}
+}
+
+func (d *Dispatcher) queue(event Event) {
+	select {
+	case d.events <- event:
+	default:
+		log.WithField("event", event.Name).Warnln("webhook: queue is full, dropping event")
+	}
+}
+
+// DispatchRecordEvents subscribes record changes of the database, and
+// dispatches them as record events.
+func (d *Dispatcher) DispatchRecordEvents() error {
+	conn, err := d.ConnOpener()
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan skydb.RecordEvent)
+	if err := conn.Subscribe(ch); err != nil {
+		return err
+	}
+
+	go func() {
+		for e := range ch {
+			d.dispatchRecordEvent(e)
+		}
+	}()
+	return nil
+}
+
+// dispatchRecordEvent dispatches the record event. The merge patch of an
+// updated record, with the _id of the record, is delivered to webhooks
+// of record deltas.
+func (d *Dispatcher) dispatchRecordEvent(e skydb.RecordEvent) {
+	var name string
+	switch e.Event {
+	case skydb.RecordCreated:
+		name = RecordCreated
+	case skydb.RecordUpdated:
+		name = RecordUpdated
+	case skydb.RecordDeleted:
+		name = RecordDeleted
+	default:
+		return
+	}
+
+	event := newEvent(name, e.Record.ID.Type, (*skyconv.JSONRecord)(e.Record))
+	if e.Event == skydb.RecordUpdated && e.Patch != nil {
+		delta := map[string]interface{}{}
+		for key, value := range e.Patch {
+			delta[key] = value
+		}
+		delta["_id"] = e.Record.ID.String()
+		event.delta = delta
+	}
+	d.queue(event)
+}
+
+// Run delivers the queued events until the Dispatcher is closed.
+func (d *Dispatcher) Run() {
+	for event := range d.events {
+		d.deliver(event)
+	}
+}
+
+// Close stops the Dispatcher from delivering events.
+func (d *Dispatcher) Close() {
+	close(d.events)
+}
+
+func (d *Dispatcher) deliver(event Event) {
+	logger := log.WithFields(logrus.Fields{
+		"event": event.Name,
+		"id":    event.ID,
+	})
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.WithError(err).Errorln("webhook: failed to encode event")
+		return
+	}
+
+	deltaBody := body
+	if event.delta != nil {
+		deltaEvent := event
+		deltaEvent.Data = event.delta
+		if deltaBody, err = json.Marshal(deltaEvent); err != nil {
+			logger.WithError(err).Errorln("webhook: failed to encode event delta")
+			return
+		}
+	}
+
+	conn, err := d.ConnOpener()
+	if err != nil {
+		logger.WithError(err).Errorln("webhook: failed to open skydb.Conn")
+		return
+	}
+	defer conn.Close()
+
+	webhooks, err := conn.GetWebhooks()
+	if err != nil {
+		logger.WithError(err).Errorln("webhook: failed to get webhooks")
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Active() || !webhook.Matches(event.Name, event.recordType) {
+			continue
+		}
+
+		webhookBody := body
+		if webhook.Delta {
+			webhookBody = deltaBody
+		}
+
+		deliveryErr := ""
+		if err := d.post(webhook, event, webhookBody); err != nil {
+			deliveryErr = err.Error()
+			logger.WithError(err).WithField("webhook", webhook.ID).Warnln("webhook: failed to deliver event")
+		}
+
+		disabled, err := conn.RecordWebhookDelivery(webhook.ID, deliveryErr, timeNow().UTC(), d.FailureThreshold)
+		if err != nil {
+			logger.WithError(err).WithField("webhook", webhook.ID).Errorln("webhook: failed to record delivery")
+		} else if disabled && !webhook.Disabled {
+			logger.WithField("webhook", webhook.ID).Warnln("webhook: disabled after consecutive failures")
+		}
+	}
+}
+
+func (d *Dispatcher) post(webhook skydb.Webhook, event Event, body []byte) error {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Skygear-Webhook-Event", event.Name)
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of the payload in the format of
+// `sha256=HEX_DIGEST`.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,210 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/push"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type delivery struct {
+	id          string
+	deliveryErr string
+}
+
+type webhookConn struct {
+	skydb.Conn
+	webhooks   []skydb.Webhook
+	deliveries []delivery
+}
+
+func (c *webhookConn) GetWebhooks() ([]skydb.Webhook, error) {
+	return c.webhooks, nil
+}
+
+func (c *webhookConn) RecordWebhookDelivery(id string, deliveryErr string, at time.Time, failureThreshold int) (bool, error) {
+	c.deliveries = append(c.deliveries, delivery{id, deliveryErr})
+	return false, nil
+}
+
+func (c *webhookConn) Close() error {
+	return nil
+}
+
+type fakeSender struct {
+	err error
+}
+
+func (s *fakeSender) Send(ctx context.Context, m push.Mapper, device skydb.Device) error {
+	return s.err
+}
+
+func TestDispatcher(t *testing.T) {
+	Convey("Dispatcher", t, func() {
+		realTime := timeNow
+		timeNow = func() time.Time { return time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC) }
+		defer func() {
+			timeNow = realTime
+		}()
+
+		var (
+			received   []Event
+			signatures []string
+			bodies     [][]byte
+			status     = http.StatusOK
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			event := Event{}
+			json.Unmarshal(body, &event)
+			received = append(received, event)
+			signatures = append(signatures, r.Header.Get(SignatureHeader))
+			bodies = append(bodies, body)
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		conn := &webhookConn{
+			webhooks: []skydb.Webhook{
+				{
+					ID:          "note",
+					URL:         server.URL,
+					Secret:      "secret",
+					Events:      []string{"record:*"},
+					RecordTypes: []string{"note"},
+				},
+				{
+					ID:     "paused",
+					URL:    server.URL,
+					Events: []string{"*"},
+					Paused: true,
+				},
+			},
+		}
+		dispatcher := NewDispatcher(func() (skydb.Conn, error) {
+			return conn, nil
+		}, time.Second, 3, 10)
+
+		Convey("delivers event to matching webhooks", func() {
+			dispatcher.Dispatch(RecordCreated, "note", map[string]interface{}{"_id": "note/1"})
+			dispatcher.deliver(<-dispatcher.events)
+
+			So(received, ShouldHaveLength, 1)
+			So(received[0].Name, ShouldEqual, RecordCreated)
+			So(received[0].Timestamp, ShouldResemble, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+			So(received[0].Data, ShouldResemble, map[string]interface{}{"_id": "note/1"})
+			So(signatures[0], ShouldEqual, Sign("secret", bodies[0]))
+			So(conn.deliveries, ShouldResemble, []delivery{{"note", ""}})
+		})
+
+		Convey("skips webhooks not matching the record type", func() {
+			dispatcher.Dispatch(RecordCreated, "comment", nil)
+			dispatcher.deliver(<-dispatcher.events)
+
+			So(received, ShouldBeEmpty)
+			So(conn.deliveries, ShouldBeEmpty)
+		})
+
+		Convey("records failed delivery", func() {
+			status = http.StatusInternalServerError
+			dispatcher.Dispatch(RecordDeleted, "note", nil)
+			dispatcher.deliver(<-dispatcher.events)
+
+			So(received, ShouldHaveLength, 1)
+			So(conn.deliveries, ShouldResemble, []delivery{{"note", "webhook responded with status 500"}})
+		})
+
+		Convey("delivers record delta to webhooks of deltas", func() {
+			conn.webhooks[0].Delta = true
+			dispatcher.dispatchRecordEvent(skydb.RecordEvent{
+				Record: &skydb.Record{
+					ID:   skydb.NewRecordID("note", "1"),
+					Data: map[string]interface{}{"title": "new", "content": "long"},
+				},
+				Event: skydb.RecordUpdated,
+				Patch: map[string]interface{}{"title": "new", "tag": nil},
+			})
+			dispatcher.deliver(<-dispatcher.events)
+
+			So(received, ShouldHaveLength, 1)
+			So(received[0].Name, ShouldEqual, RecordUpdated)
+			So(received[0].Data, ShouldResemble, map[string]interface{}{
+				"_id":   "note/1",
+				"title": "new",
+				"tag":   nil,
+			})
+			So(signatures[0], ShouldEqual, Sign("secret", bodies[0]))
+		})
+
+		Convey("delivers whole record if the delta is unknown", func() {
+			conn.webhooks[0].Delta = true
+			dispatcher.dispatchRecordEvent(skydb.RecordEvent{
+				Record: &skydb.Record{
+					ID:   skydb.NewRecordID("note", "1"),
+					Data: map[string]interface{}{"title": "new"},
+				},
+				Event: skydb.RecordUpdated,
+			})
+			dispatcher.deliver(<-dispatcher.events)
+
+			So(received, ShouldHaveLength, 1)
+			data := received[0].Data.(map[string]interface{})
+			So(data["_id"], ShouldEqual, "note/1")
+			So(data["_type"], ShouldEqual, "record")
+			So(data["title"], ShouldEqual, "new")
+		})
+
+		Convey("drops event if the queue is full", func() {
+			for i := 0; i < 11; i++ {
+				dispatcher.Dispatch(AuthLogin, "", nil)
+			}
+			So(dispatcher.events, ShouldHaveLength, 10)
+		})
+
+		Convey("dispatches push outcome", func() {
+			device := skydb.Device{ID: "device1", Type: "ios", AuthInfoID: "user1"}
+			sender := &PushSender{
+				Sender:     &fakeSender{errors.New("invalid token")},
+				Dispatcher: dispatcher,
+			}
+			So(sender.Send(context.Background(), nil, device), ShouldNotBeNil)
+
+			event := <-dispatcher.events
+			So(event.Name, ShouldEqual, PushFailed)
+			So(event.Data, ShouldResemble, map[string]interface{}{
+				"device_id": "device1",
+				"user_id":   "user1",
+				"type":      "ios",
+				"topic":     "",
+				"error":     "invalid token",
+			})
+		})
+	})
+
+	Convey("nil Dispatcher", t, func() {
+		var dispatcher *Dispatcher
+		So(func() { dispatcher.Dispatch(AuthSignup, "", nil) }, ShouldNotPanic)
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/skygeario/skygear-server/pkg/server/scenario"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skygear"
+)
+
+const testUsage = `usage: skygear-server test [-timeout DURATION] SCENARIO...
+
+test starts the server configured by the environment with its plugins,
+then runs the JSON scenario files against it. A scenario signs in its
+users, saves its seed records with the master key and issues the requests
+of its steps, asserting their responses and the hooks they fired. Run it
+on a database for testing, because the seed records are saved to it.
+
+-timeout is how long to wait for the plugins to be ready, 30s by default.`
+
+// runTest runs the scenario files against the server configured by the
+// environment. It returns the exit status of the command.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, testUsage)
+	}
+	timeout := flags.Duration("timeout", 30*time.Second, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	scenarios := make([]scenario.Scenario, flags.NArg())
+	for i, path := range flags.Args() {
+		s, err := scenario.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read scenario: %v\n", err)
+			return 1
+		}
+		scenarios[i] = s
+	}
+
+	config := skyconfig.NewConfiguration()
+	config.ReadFromEnv()
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	initLogger(config)
+
+	server, err := skygear.New(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start server: %v\n", err)
+		return 1
+	}
+	server.Start()
+
+	deadline := time.Now().Add(*timeout)
+	for !server.PluginContext.IsReady() {
+		if time.Now().After(deadline) {
+			fmt.Fprintln(os.Stderr, "plugins are not ready before timeout")
+			return 1
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	runner := &scenario.Runner{
+		Handler:   server.Handler(),
+		APIKey:    config.App.APIKey,
+		MasterKey: config.App.MasterKey,
+		Hooks:     server.PluginContext.HookRegistry,
+	}
+
+	status := 0
+	for _, s := range scenarios {
+		failures, err := runner.Run(s)
+		if err != nil {
+			fmt.Printf("ERROR %s: %v\n", s.Name, err)
+			status = 1
+			continue
+		}
+		if len(failures) > 0 {
+			fmt.Printf("FAIL  %s\n", s.Name)
+			for _, failure := range failures {
+				fmt.Printf("    %s\n", failure.Error())
+			}
+			status = 1
+			continue
+		}
+		fmt.Printf("ok    %s\n", s.Name)
+	}
+	return status
+}
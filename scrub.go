@@ -0,0 +1,171 @@
+// Copyright 2015-present Oursky Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/skygeario/skygear-server/pkg/server/scrub"
+	"github.com/skygeario/skygear-server/pkg/server/skyconfig"
+	"github.com/skygeario/skygear-server/pkg/server/skydb"
+)
+
+const scrubUsage = `usage: skygear-server scrub -target URL -confirm RULES
+
+scrub rewrites the fields holding personal data of the records in the
+database at URL with fake or hashed values, as classified by the JSON file
+RULES, or stdin if RULES is -. The auth record keys of the user records,
+the OAuth profiles of users, the classified fields in the record history
+and annotations are also scrubbed.
+
+Run it only on a copy of the production database, because the original
+values cannot be recovered. -confirm is required, and the database
+configured by the environment, its replicas and its standby are refused
+as the target.`
+
+// runScrub scrubs the records of the app configured by the environment in
+// the target database. It returns the exit status of the command.
+func runScrub(args []string) int {
+	flags := flag.NewFlagSet("scrub", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, scrubUsage)
+	}
+	target := flags.String("target", "", "")
+	confirm := flags.Bool("confirm", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 || *target == "" {
+		flags.Usage()
+		return 2
+	}
+	if !*confirm {
+		fmt.Fprintln(os.Stderr, "scrub rewrites the target database irreversibly, run it with -confirm")
+		return 2
+	}
+
+	rules, err := readScrubRules(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read rules: %v\n", err)
+		return 1
+	}
+
+	config := skyconfig.NewConfiguration()
+	config.ReadFromEnv()
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	for _, option := range productionDatabaseOptions(config) {
+		if sameDatabase(*target, option) {
+			fmt.Fprintln(os.Stderr, "refuse to scrub the database configured by the environment")
+			return 1
+		}
+	}
+
+	conn, err := skydb.Open(
+		context.Background(),
+		config.DB.ImplName,
+		config.App.Name,
+		config.App.AccessControl,
+		*target,
+		config.App.DevMode,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	counts, err := scrub.Scrub(conn, rules, config.App.AuthRecordKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to scrub: %v\n", err)
+		return 1
+	}
+
+	recordTypes := make([]string, 0, len(counts))
+	for recordType := range counts {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+	for _, recordType := range recordTypes {
+		fmt.Printf("%s: %d records scrubbed\n", recordType, counts[recordType])
+	}
+	return 0
+}
+
+// productionDatabaseOptions returns the options of the databases the
+// server configured by the environment uses, which are never scrubbed.
+func productionDatabaseOptions(config skyconfig.Configuration) []string {
+	options := []string{config.DB.Option}
+	options = append(options, config.DB.ReplicaOptions...)
+	if config.Failover.StandbyOption != "" {
+		options = append(options, config.Failover.StandbyOption)
+	}
+	return options
+}
+
+// sameDatabase returns true if the database options refer to the same
+// database. Options in URL form are compared by host, port and database
+// name, regardless of the credentials and parameters.
+func sameDatabase(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return true
+	}
+
+	urlA, errA := url.Parse(a)
+	urlB, errB := url.Parse(b)
+	if errA != nil || errB != nil || urlA.Host == "" || urlB.Host == "" {
+		return false
+	}
+
+	hostport := func(u *url.URL) string {
+		port := u.Port()
+		if port == "" {
+			port = "5432"
+		}
+		return strings.ToLower(u.Hostname()) + ":" + port
+	}
+	return hostport(urlA) == hostport(urlB) &&
+		strings.TrimPrefix(urlA.Path, "/") == strings.TrimPrefix(urlB.Path, "/")
+}
+
+func readScrubRules(path string) (*scrub.Rules, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rules := scrub.Rules{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}